@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ccoin/core/internal/p2p"
+	"github.com/ccoin/core/internal/rpc"
+)
+
+func cmdNetBan(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: ccoin-cli net ban <subcommand>")
+		fmt.Println("Subcommands: list, add [--reason] [--duration] <target>, remove <target>,")
+		fmt.Println("             export <file>, import <file>, sign-feed --key <file> <list> <feed>")
+		exit(1)
+	}
+
+	switch args[0] {
+	case "list":
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			resp, err := c.ListBans(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Bans: %d\n", len(resp.Bans))
+			for _, b := range resp.Bans {
+				expires := "never"
+				if b.Expires != 0 {
+					expires = time.Unix(b.Expires, 0).Format(time.RFC3339)
+				}
+				fmt.Printf("  %s\n", b.Target)
+				fmt.Printf("    Source: %s, expires %s\n", b.Source, expires)
+				if b.Reason != "" {
+					fmt.Printf("    Reason: %s\n", b.Reason)
+				}
+			}
+			return nil
+		})
+
+	case "add":
+		fs := newFlagSet("net ban add")
+		reason := fs.String("reason", "", "Why the target is banned")
+		duration := fs.Duration("duration", 0, "How long the ban lasts (0 bans permanently)")
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			fmt.Println("Usage: ccoin-cli net ban add [--reason <text>] [--duration <d>] <peer-id|ip|cidr>")
+			exit(1)
+		}
+
+		ban := rpc.BanEntry{Target: fs.Arg(0), Reason: *reason}
+		if *duration > 0 {
+			ban.Expires = time.Now().Add(*duration).Unix()
+		}
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			if _, err := c.AddBans(ctx, []rpc.BanEntry{ban}); err != nil {
+				return err
+			}
+			fmt.Printf("Banned %s\n", ban.Target)
+			return nil
+		})
+
+	case "remove":
+		if len(args) != 2 {
+			fmt.Println("Usage: ccoin-cli net ban remove <peer-id|ip|cidr>")
+			exit(1)
+		}
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			resp, err := c.RemoveBan(ctx, args[1])
+			if err != nil {
+				return err
+			}
+			if !resp.Removed {
+				return fmt.Errorf("%s is not banned", args[1])
+			}
+			fmt.Printf("Unbanned %s\n", args[1])
+			return nil
+		})
+
+	case "export":
+		if len(args) != 2 {
+			fmt.Println("Usage: ccoin-cli net ban export <file>")
+			exit(1)
+		}
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			resp, err := c.ListBans(ctx)
+			if err != nil {
+				return err
+			}
+			bans := make([]p2p.Ban, len(resp.Bans))
+			for i, b := range resp.Bans {
+				bans[i] = p2p.Ban(b)
+			}
+			if err := writeBanList(args[1], bans); err != nil {
+				return err
+			}
+			fmt.Printf("Exported %d ban(s) to %s\n", len(bans), args[1])
+			return nil
+		})
+
+	case "import":
+		if len(args) != 2 {
+			fmt.Println("Usage: ccoin-cli net ban import <file>")
+			exit(1)
+		}
+		bans, err := readBanList(args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		entries := make([]rpc.BanEntry, len(bans))
+		for i, b := range bans {
+			entries[i] = rpc.BanEntry(b)
+		}
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			resp, err := c.AddBans(ctx, entries)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Imported %d ban(s)\n", resp.Added)
+			return nil
+		})
+
+	case "sign-feed":
+		cmdNetBanSignFeed(args[1:])
+
+	default:
+		fmt.Printf("Unknown net ban subcommand: %s\n", args[0])
+		exit(1)
+	}
+}
+
+// cmdNetBanSignFeed signs a ban list for publishing as a community feed
+// that nodes follow with ccoind -ban-feed
+func cmdNetBanSignFeed(args []string) {
+	fs := newFlagSet("net ban sign-feed")
+	keyFile := fs.String("key", "", "File holding the feed's hex Ed25519 seed")
+	fs.Parse(args)
+	if *keyFile == "" || fs.NArg() != 2 {
+		fmt.Println("Usage: ccoin-cli net ban sign-feed --key <file> <list> <feed>")
+		exit(1)
+	}
+
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+	data, err := os.ReadFile(*keyFile)
+	if err != nil {
+		fail(err)
+	}
+	seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		fail(fmt.Errorf("%s must hold a hex Ed25519 seed", *keyFile))
+	}
+	key := ed25519.NewKeyFromSeed(seed)
+
+	bans, err := readBanList(fs.Arg(0))
+	if err != nil {
+		fail(err)
+	}
+	// Sources are set by the nodes ingesting the feed
+	for i := range bans {
+		bans[i].Source = ""
+	}
+	feed := &p2p.BanFeed{Issued: time.Now().Unix(), Bans: bans}
+	feed.Sign(key)
+
+	out, _ := json.MarshalIndent(feed, "", "  ")
+	if err := os.WriteFile(fs.Arg(1), out, 0644); err != nil {
+		fail(err)
+	}
+	fmt.Printf("Signed %d ban(s) into %s\n", len(bans), fs.Arg(1))
+	fmt.Printf("Public key: %x\n", key.Public())
+}
+
+// readBanList reads a ban list file
+func readBanList(path string) ([]p2p.Ban, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return p2p.ReadBanList(f)
+}
+
+// writeBanList writes a ban list file
+func writeBanList(path string, bans []p2p.Ban) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := p2p.WriteBanList(f, bans); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// cliCommand is a command or subcommand, for shell completion
+type cliCommand struct {
+	name     string
+	summary  string
+	flags    []string
+	commands []*cliCommand
+}
+
+// cliCommands lists every command with its subcommands and flags. It
+// must be kept in step with run and the command handlers.
+var cliCommands = []*cliCommand{
+	{name: "version", summary: "Show version information"},
+	{name: "help", summary: "Show the help message"},
+	{name: "status", summary: "Show node status"},
+	{name: "diagnostics", summary: "Capture a diagnostics bundle on the node"},
+	{name: "estimatefee", summary: "Estimate the fee to confirm within a number of blocks"},
+	{name: "dag", summary: "DAG operations", commands: []*cliCommand{
+		{name: "status", summary: "Show the DAG height and tips"},
+		{name: "tips", summary: "List the current tips"},
+		{name: "block", summary: "Show a block"},
+		{name: "committee", summary: "Audit a committee selection", commands: []*cliCommand{
+			{name: "pre-confirmation", summary: "Pre-confirmation committee"},
+			{name: "decryption", summary: "Threshold decryption committee"},
+			{name: "evaluator", summary: "Model evaluator committee"},
+		}},
+	}},
+	{name: "net", summary: "Network operations", commands: []*cliCommand{
+		{name: "peers", summary: "List connected peers and their scores", flags: []string{"verbose", "by-score"}},
+		{name: "info", summary: "Show peers by direction and network"},
+		{name: "reachability", summary: "Show whether peers can reach the node through NAT"},
+		{name: "ban", summary: "Manage peer bans", commands: []*cliCommand{
+			{name: "list", summary: "List bans"},
+			{name: "add", summary: "Ban a peer, IP or subnet", flags: []string{"reason", "duration"}},
+			{name: "remove", summary: "Lift a ban"},
+			{name: "export", summary: "Write the bans to a file"},
+			{name: "import", summary: "Add the bans in a file"},
+			{name: "sign-feed", summary: "Sign a ban list as a community feed", flags: []string{"key"}},
+		}},
+	}},
+	{name: "miner", summary: "Mining operations", commands: []*cliCommand{
+		{name: "start", summary: "Start mining"},
+		{name: "stop", summary: "Stop mining"},
+		{name: "status", summary: "Show mining status"},
+		{name: "delegations", summary: "List stake delegations", flags: []string{"delegator"}},
+	}},
+	{name: "tx", summary: "Transaction operations", commands: []*cliCommand{
+		{name: "send", summary: "Send a shielded payment", flags: []string{
+			"to", "amount", "fee", "memo", "ref", "request-id", "max-delay", "decoys", "round-change", "avoid-sync-peer"}},
+		{name: "send-batch", summary: "Send the payments in a file", flags: []string{"fee", "batch-id"}},
+		{name: "submit", summary: "Submit a signed transaction file"},
+		{name: "status", summary: "Show a transaction"},
+		{name: "receipt", summary: "Show a transaction receipt"},
+	}},
+	{name: "wallet", summary: "Wallet operations", commands: []*cliCommand{
+		{name: "new", summary: "Create a wallet", flags: []string{"language", "passphrase", "encrypt-at-rest", "encrypt-key-cmd"}},
+		{name: "restore", summary: "Restore a wallet from its seed phrase", flags: []string{"language", "passphrase", "encrypt-at-rest", "encrypt-key-cmd"}},
+		{name: "unlock", summary: "Unlock the node wallet"},
+		{name: "newaddress", summary: "Derive a new address"},
+		{name: "balance", summary: "Show the wallet balance"},
+		{name: "address", summary: "List the wallet addresses"},
+		{name: "payments", summary: "List received payments", flags: []string{"address", "from-height", "all", "type", "ref", "subject"}},
+		{name: "disclose", summary: "Disclose a payment to a third party", flags: []string{"block"}},
+		{name: "verify-disclosure", summary: "Verify a payment disclosure", flags: []string{"block"}},
+	}},
+	{name: "governance", summary: "Governance operations", commands: []*cliCommand{
+		{name: "proposals", summary: "List active proposals"},
+		{name: "vote", summary: "Vote on a proposal"},
+		{name: "propose", summary: "Submit a proposal"},
+		{name: "activity", summary: "Show an address's votes and proposals"},
+		{name: "preview", summary: "Simulate an economic parameter proposal"},
+	}},
+	{name: "model", summary: "AI model operations", commands: []*cliCommand{
+		{name: "list", summary: "List models"},
+		{name: "info", summary: "Show a model"},
+		{name: "download", summary: "Download and verify model weights", flags: []string{"version", "out"}},
+		{name: "propose", summary: "Propose a model"},
+	}},
+	{name: "zkp", summary: "Zero-knowledge key operations", commands: []*cliCommand{
+		{name: "setup", summary: "Generate proving and verifying keys", flags: []string{"out", "proof-system", "plonk-srs"}},
+	}},
+	{name: "policy", summary: "Disclosure policy operations", commands: []*cliCommand{
+		{name: "check", summary: "Check a policy file", flags: []string{"network"}},
+		{name: "explain", summary: "Explain the disclosures a recipient requires", flags: []string{"min", "max", "jurisdictions", "attached"}},
+	}},
+	{name: "faucet", summary: "Testnet faucet operations", commands: []*cliCommand{
+		{name: "request", summary: "Request testnet coins", flags: []string{"url"}},
+	}},
+	{name: "config", summary: "Node config file operations", commands: []*cliCommand{
+		{name: "init", summary: "Write a config file of the defaults", flags: []string{"out", "yaml", "force"}},
+		{name: "check", summary: "Check a config file"},
+	}},
+	{name: "watch", summary: "Watchtower alert subscriptions", commands: []*cliCommand{
+		{name: "subscribe", summary: "Subscribe a webhook to alerts", flags: []string{"url", "key", "miner", "model", "license"}},
+		{name: "unsubscribe", summary: "Unsubscribe a webhook", flags: []string{"url", "key", "miner", "model", "license"}},
+	}},
+	{name: "shell", summary: "Interactive shell"},
+	{name: "completion", summary: "Print a shell completion script", commands: []*cliCommand{
+		{name: "bash", summary: "Bash completion"},
+		{name: "zsh", summary: "Zsh completion"},
+		{name: "fish", summary: "Fish completion"},
+	}},
+}
+
+// completionNode is a command reached by a path of command names
+type completionNode struct {
+	path string
+	cmd  *cliCommand
+}
+
+// completionNodes returns the root and every command by path, the names
+// joined with slashes, in path order
+func completionNodes() []completionNode {
+	nodes := []completionNode{{cmd: &cliCommand{commands: cliCommands}}}
+	var walk func(prefix string, cmds []*cliCommand)
+	walk = func(prefix string, cmds []*cliCommand) {
+		for _, c := range cmds {
+			path := prefix + c.name
+			nodes = append(nodes, completionNode{path: path, cmd: c})
+			walk(path+"/", c.commands)
+		}
+	}
+	walk("", cliCommands)
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].path < nodes[j].path })
+	return nodes
+}
+
+// cmdCompletion prints the completion script for a shell
+func cmdCompletion(shell string) {
+	var b strings.Builder
+	switch shell {
+	case "bash":
+		writeBashCompletion(&b)
+	case "zsh":
+		writeZshCompletion(&b)
+	case "fish":
+		writeFishCompletion(&b)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: no completion for %q; use bash, zsh or fish\n", shell)
+		exit(1)
+	}
+	fmt.Print(b.String())
+}
+
+// flagNames returns a command's flags as typed
+func flagNames(c *cliCommand) []string {
+	names := make([]string, len(c.flags))
+	for i, f := range c.flags {
+		names[i] = "--" + f
+	}
+	return names
+}
+
+// commandNames returns the names of a command's subcommands
+func commandNames(c *cliCommand) []string {
+	names := make([]string, len(c.commands))
+	for i, sub := range c.commands {
+		names[i] = sub.name
+	}
+	return names
+}
+
+// allPaths returns every command path, space-separated
+func allPaths(nodes []completionNode) string {
+	var paths []string
+	for _, n := range nodes[1:] {
+		paths = append(paths, n.path)
+	}
+	return strings.Join(paths, " ")
+}
+
+// writeBashCompletion writes a bash completion script. Words that name
+// a subcommand of the command so far descend into it; flags complete
+// when the word starts with a dash, and files when nothing else does.
+func writeBashCompletion(b *strings.Builder) {
+	nodes := completionNodes()
+	fmt.Fprintf(b, `# bash completion for ccoin-cli, from: ccoin-cli completion bash
+_ccoin_cli_paths=" %s "
+
+_ccoin_cli() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" node="" next word i
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        word="${COMP_WORDS[i]}"
+        next="${node:+$node/}$word"
+        [[ $_ccoin_cli_paths == *" $next "* ]] && node="$next"
+    done
+
+    local commands="" flags=""
+    case "$node" in
+`, allPaths(nodes))
+	for _, n := range nodes {
+		if len(n.cmd.commands) == 0 && len(n.cmd.flags) == 0 {
+			continue
+		}
+		fmt.Fprintf(b, "        %q) commands=%q; flags=%q ;;\n",
+			n.path, strings.Join(commandNames(n.cmd), " "), strings.Join(flagNames(n.cmd), " "))
+	}
+	b.WriteString(`    esac
+
+    if [[ $cur == -* ]]; then
+        COMPREPLY=($(compgen -W "$flags" -- "$cur"))
+    elif [[ -n $commands ]]; then
+        COMPREPLY=($(compgen -W "$commands" -- "$cur"))
+    fi
+}
+
+complete -o default -F _ccoin_cli ccoin-cli
+`)
+}
+
+// writeZshCompletion writes a zsh completion script, describing each
+// subcommand
+func writeZshCompletion(b *strings.Builder) {
+	nodes := completionNodes()
+	fmt.Fprintf(b, `#compdef ccoin-cli
+# zsh completion for ccoin-cli, from: ccoin-cli completion zsh
+
+_ccoin_cli() {
+    local -a paths commands flags
+    local node="" next word i
+    paths=(%s)
+    for ((i = 2; i < CURRENT; i++)); do
+        word=${words[i]}
+        next=${node:+$node/}$word
+        (( ${paths[(Ie)$next]} )) && node=$next
+    done
+
+    case $node in
+`, allPaths(nodes))
+	for _, n := range nodes {
+		if len(n.cmd.commands) == 0 && len(n.cmd.flags) == 0 {
+			continue
+		}
+		var described []string
+		for _, sub := range n.cmd.commands {
+			described = append(described, zshQuote(sub.name+":"+sub.summary))
+		}
+		fmt.Fprintf(b, "        %s) commands=(%s); flags=(%s) ;;\n",
+			zshQuote(n.path), strings.Join(described, " "), strings.Join(flagNames(n.cmd), " "))
+	}
+	b.WriteString(`    esac
+
+    if [[ $PREFIX == -* ]] && (( $#flags )); then
+        compadd -a flags
+    elif (( $#commands )); then
+        _describe command commands
+    else
+        _files
+    fi
+}
+
+compdef _ccoin_cli ccoin-cli
+`)
+}
+
+// zshQuote single-quotes a word for zsh
+func zshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// writeFishCompletion writes a fish completion script, describing each
+// subcommand
+func writeFishCompletion(b *strings.Builder) {
+	nodes := completionNodes()
+	fmt.Fprintf(b, `# fish completion for ccoin-cli, from: ccoin-cli completion fish
+
+# __ccoin_cli_node prints the command path typed so far
+function __ccoin_cli_node
+    set -l paths %s
+    set -l node ""
+    for word in (commandline -opc)[2..-1]
+        set -l next $word
+        test -n "$node"; and set next "$node/$word"
+        contains -- $next $paths; and set node $next
+    end
+    echo $node
+end
+
+function __ccoin_cli_at
+    test (__ccoin_cli_node) = "$argv[1]"
+end
+
+complete -c ccoin-cli -f
+`, allPaths(nodes))
+	for _, n := range nodes {
+		for _, sub := range n.cmd.commands {
+			fmt.Fprintf(b, "complete -c ccoin-cli -n %s -a %s -d %s\n",
+				fishQuote("__ccoin_cli_at '"+n.path+"'"), fishQuote(sub.name), fishQuote(sub.summary))
+		}
+		for _, f := range n.cmd.flags {
+			fmt.Fprintf(b, "complete -c ccoin-cli -n %s -l %s -r -F\n", fishQuote("__ccoin_cli_at '"+n.path+"'"), f)
+		}
+	}
+}
+
+// fishQuote double-quotes a word for fish
+func fishQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`).Replace(s) + `"`
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ccoin/core/internal/config"
+)
+
+// configHeader opens the config files config init writes
+const configHeader = `ccoind configuration, written by ccoin-cli config init.
+Settings are named after ccoind's flags and set to their defaults.
+CCOIN_<SETTING> environment variables override this file (db-host is
+CCOIN_DB_HOST), and flags given to ccoind override both.`
+
+func cmdConfig(args []string) {
+	switch args[0] {
+	case "init":
+		cmdConfigInit(args[1:])
+	case "check":
+		cmdConfigCheck(args[1:])
+	default:
+		fmt.Printf("Unknown config subcommand: %s\n", args[0])
+		exit(1)
+	}
+}
+
+// cmdConfigInit writes a config file of every node setting at its
+// default, each annotated with its description
+func cmdConfigInit(args []string) {
+	fs := newFlagSet("config init")
+	out := fs.String("out", "", "File to write, TOML or YAML by extension (default: standard output)")
+	yaml := fs.Bool("yaml", false, "Write YAML to standard output instead of TOML")
+	force := fs.Bool("force", false, "Overwrite an existing file")
+	fs.Parse(args)
+
+	format := config.FormatTOML
+	if *yaml {
+		format = config.FormatYAML
+	}
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		format = config.FormatOf(*out)
+		mode := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+		if *force {
+			mode = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		}
+		f, err := os.OpenFile(*out, mode, 0600)
+		if os.IsExist(err) {
+			fmt.Fprintf(os.Stderr, "Error: %s exists; use -force to overwrite it\n", *out)
+			exit(1)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	var node config.Node
+	settings := flag.NewFlagSet("ccoind", flag.ContinueOnError)
+	node.RegisterFlags(settings)
+	if err := config.WriteDefaults(w, settings, format, configHeader); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+	if *out != "" {
+		fmt.Printf("Config written to %s\n", *out)
+	}
+}
+
+// cmdConfigCheck reads a config file as ccoind would and reports any
+// setting that is unknown or invalid
+func cmdConfigCheck(args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: ccoin-cli config check <file>")
+		exit(1)
+	}
+
+	var node config.Node
+	settings := flag.NewFlagSet("ccoind", flag.ContinueOnError)
+	node.RegisterFlags(settings)
+	err := config.ApplyFile(settings, args[0])
+	if err == nil {
+		err = node.Validate()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+
+	n := 0
+	settings.Visit(func(*flag.Flag) { n++ })
+	fmt.Printf("%s: %d setting(s), all valid\n", args[0], n)
+}
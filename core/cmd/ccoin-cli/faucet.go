@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ccoin/core/internal/economics"
+	"github.com/ccoin/core/internal/faucet"
+	"github.com/ccoin/core/internal/rpc"
+)
+
+// defaultFaucetURL is the faucet used when CCOIN_FAUCET is not set
+const defaultFaucetURL = "http://127.0.0.1:9003"
+
+// faucetURL returns the faucet URL (overridable via CCOIN_FAUCET)
+func faucetURL() string {
+	if url := os.Getenv("CCOIN_FAUCET"); url != "" {
+		return url
+	}
+	return defaultFaucetURL
+}
+
+func cmdFaucet(args []string) {
+	switch args[0] {
+	case "request":
+		cmdFaucetRequest(args[1:])
+	default:
+		fmt.Printf("Unknown faucet subcommand: %s\n", args[0])
+		exit(1)
+	}
+}
+
+// cmdFaucetRequest asks a testnet faucet for coins, paid to the given
+// address or else to the node wallet's first shielded address
+func cmdFaucetRequest(args []string) {
+	fs := newFlagSet("faucet request")
+	url := fs.String("url", faucetURL(), "Faucet URL")
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fmt.Println("Usage: ccoin-cli faucet request [-url <faucet>] [address]")
+		exit(1)
+	}
+
+	address := fs.Arg(0)
+	if address == "" {
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			addrs, err := c.ListAddresses(ctx)
+			if err != nil {
+				return err
+			}
+			if len(addrs.Shielded) == 0 {
+				return errors.New("wallet has no shielded address; pass one")
+			}
+			address = addrs.Shielded[0]
+			return nil
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+	defer cancel()
+
+	grant, err := faucet.RequestCoins(ctx, *url, address)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+	fmt.Printf("Faucet sent %s CCoin to %s\n", economics.FormatAmount(grant.Amount), address)
+	fmt.Printf("  Transaction: %s\n", grant.TxHash)
+}
@@ -2,21 +2,135 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ccoin/core/internal/aicommons"
+	"github.com/ccoin/core/internal/atrest"
+	"github.com/ccoin/core/internal/economics"
+	"github.com/ccoin/core/internal/ipfs"
+	"github.com/ccoin/core/internal/rpc"
+	"github.com/ccoin/core/internal/wallet"
+	"github.com/ccoin/core/pkg/common"
+	"github.com/ccoin/core/pkg/types"
 )
 
 const (
 	version = "0.1.0"
+
+	// defaultRPCAddr matches the daemon's default -rpc flag
+	defaultRPCAddr = "127.0.0.1:9001"
+
+	// rpcTimeout bounds every CLI call
+	rpcTimeout = 10 * time.Second
+
+	// defaultFeeTarget is the confirmation target used when tx send is
+	// not given a fee
+	defaultFeeTarget = 6
 )
 
+// rpcAddr returns the node RPC address (overridable via CCOIN_RPC)
+func rpcAddr() string {
+	if addr := os.Getenv("CCOIN_RPC"); addr != "" {
+		return addr
+	}
+	return defaultRPCAddr
+}
+
+// dataDir returns the node data directory (overridable via CCOIN_DATA_DIR)
+func dataDir() string {
+	if dir := os.Getenv("CCOIN_DATA_DIR"); dir != "" {
+		return dir
+	}
+	return "./data"
+}
+
+// ipfsGateway returns the IPFS gateway URL (overridable via CCOIN_IPFS_GATEWAY)
+func ipfsGateway() string {
+	if gw := os.Getenv("CCOIN_IPFS_GATEWAY"); gw != "" {
+		return gw
+	}
+	return ipfs.DefaultConfig().Gateway
+}
+
+// cliNetwork returns the network commands default to (overridable via
+// CCOIN_NETWORK)
+func cliNetwork() string {
+	if network := os.Getenv("CCOIN_NETWORK"); network != "" {
+		return network
+	}
+	return "testnet"
+}
+
+// stdin reads standard input for prompts and the shell alike, so
+// neither loses input the other buffered
+var stdin = bufio.NewReader(os.Stdin)
+
+// readSecret returns env[name] if set, otherwise prompts on stdin
+func readSecret(env, prompt string) (string, error) {
+	if v := os.Getenv(env); v != "" {
+		return v, nil
+	}
+
+	fmt.Print(prompt)
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// openAtRest derives the data directory's at-rest encryption key, from
+// keyCmd or the CCOIN_STORAGE_PASSPHRASE passphrase. It returns nil when
+// mode is off.
+func openAtRest(dataDir string, mode atrest.Mode, keyCmd string) (*atrest.Cipher, error) {
+	cfg := &atrest.Config{Mode: mode, KeyCommand: keyCmd}
+	if mode != atrest.ModeOff && keyCmd == "" {
+		passphrase, err := readSecret("CCOIN_STORAGE_PASSPHRASE", "Storage passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		cfg.Passphrase = passphrase
+	}
+	return atrest.Open(dataDir, cfg)
+}
+
+// withClient connects to the node and runs fn, exiting on connection
+// errors
+func withClient(fn func(ctx context.Context, c *rpc.Client) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+	defer cancel()
+
+	client, release := connect(ctx)
+	defer release()
+
+	if err := fn(ctx, client); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+}
+
 func main() {
 	if len(os.Args) < 2 {
 		printUsage()
-		os.Exit(1)
+		exit(1)
 	}
+	run(os.Args[1:])
+}
 
-	command := os.Args[1]
+// run runs one command line, from the process arguments or the shell
+func run(args []string) {
+	command := args[0]
 
 	switch command {
 	case "version":
@@ -28,61 +142,137 @@ func main() {
 	case "status":
 		cmdStatus()
 
+	case "diagnostics":
+		cmdDiagnostics(args[1:])
+
+	case "estimatefee":
+		cmdEstimateFee(args[1:])
+
 	case "dag":
-		if len(os.Args) < 3 {
+		if len(args) < 2 {
 			fmt.Println("Usage: ccoin-cli dag <subcommand>")
 			fmt.Println("Subcommands: status, tips, block <hash>")
-			os.Exit(1)
+			exit(1)
+		}
+		cmdDAG(args[1:])
+
+	case "net":
+		if len(args) < 2 {
+			fmt.Println("Usage: ccoin-cli net <subcommand>")
+			fmt.Println("Subcommands: peers [--verbose] [--by-score], info, reachability, ban <list|add|remove|export|import|sign-feed>")
+			exit(1)
 		}
-		cmdDAG(os.Args[2:])
+		cmdNet(args[1:])
 
 	case "miner":
-		if len(os.Args) < 3 {
+		if len(args) < 2 {
 			fmt.Println("Usage: ccoin-cli miner <subcommand>")
-			fmt.Println("Subcommands: start, stop, status")
-			os.Exit(1)
+			fmt.Println("Subcommands: start, stop, status, delegations [--delegator] <address>")
+			exit(1)
 		}
-		cmdMiner(os.Args[2:])
+		cmdMiner(args[1:])
 
 	case "tx":
-		if len(os.Args) < 3 {
+		if len(args) < 2 {
 			fmt.Println("Usage: ccoin-cli tx <subcommand>")
-			fmt.Println("Subcommands: send, status <txid>")
-			os.Exit(1)
+			fmt.Println("Subcommands: send, send-batch <file>, submit <file> [request-id], status <txid>, receipt <txid>")
+			exit(1)
 		}
-		cmdTransaction(os.Args[2:])
+		cmdTransaction(args[1:])
 
 	case "wallet":
-		if len(os.Args) < 3 {
+		if len(args) < 2 {
 			fmt.Println("Usage: ccoin-cli wallet <subcommand>")
-			fmt.Println("Subcommands: new, balance, address")
-			os.Exit(1)
+			fmt.Println("Subcommands: new [--language <lang>] [--passphrase], restore [--passphrase], balance, address")
+			exit(1)
 		}
-		cmdWallet(os.Args[2:])
+		cmdWallet(args[1:])
 
 	case "governance":
-		if len(os.Args) < 3 {
+		if len(args) < 2 {
 			fmt.Println("Usage: ccoin-cli governance <subcommand>")
-			fmt.Println("Subcommands: proposals, vote, propose")
-			os.Exit(1)
+			fmt.Println("Subcommands: proposals, vote, propose, activity <address>")
+			exit(1)
 		}
-		cmdGovernance(os.Args[2:])
+		cmdGovernance(args[1:])
 
 	case "model":
-		if len(os.Args) < 3 {
+		if len(args) < 2 {
 			fmt.Println("Usage: ccoin-cli model <subcommand>")
-			fmt.Println("Subcommands: list, info <id>, propose")
-			os.Exit(1)
+			fmt.Println("Subcommands: list, info <id>, download <id>, propose")
+			exit(1)
+		}
+		cmdModel(args[1:])
+
+	case "zkp":
+		if len(args) < 2 {
+			fmt.Println("Usage: ccoin-cli zkp <subcommand>")
+			fmt.Println("Subcommands: setup")
+			exit(1)
+		}
+		cmdZKP(args[1:])
+
+	case "policy":
+		if len(args) < 2 {
+			fmt.Println("Usage: ccoin-cli policy <subcommand>")
+			fmt.Println("Subcommands: check <file>, explain <recipient>")
+			exit(1)
+		}
+		cmdPolicy(args[1:])
+
+	case "faucet":
+		if len(args) < 2 {
+			fmt.Println("Usage: ccoin-cli faucet <subcommand>")
+			fmt.Println("Subcommands: request [address]")
+			exit(1)
+		}
+		cmdFaucet(args[1:])
+
+	case "config":
+		if len(args) < 2 {
+			fmt.Println("Usage: ccoin-cli config <subcommand>")
+			fmt.Println("Subcommands: init [-out <file>] [-yaml] [-force], check <file>")
+			exit(1)
+		}
+		cmdConfig(args[1:])
+
+	case "shell":
+		cmdShell()
+
+	case "completion":
+		if len(args) != 2 {
+			fmt.Println("Usage: ccoin-cli completion <bash|zsh|fish>")
+			exit(1)
+		}
+		cmdCompletion(args[1])
+
+	case "watch":
+		if len(args) < 2 {
+			fmt.Println("Usage: ccoin-cli watch <subcommand>")
+			fmt.Println("Subcommands: subscribe, unsubscribe --key <file> --miner|--model|--license <target> <webhook>")
+			exit(1)
 		}
-		cmdModel(os.Args[2:])
+		cmdWatch(args[1:])
 
 	default:
 		fmt.Printf("Unknown command: %s\n", command)
 		printUsage()
-		os.Exit(1)
+		exit(1)
 	}
 }
 
+// exitMnemonicError explains why a seed phrase was rejected and exits
+func exitMnemonicError(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	switch {
+	case errors.Is(err, wallet.ErrUnknownWord):
+		fmt.Fprintln(os.Stderr, "Check the spelling of that word against the BIP-39 wordlist.")
+	case errors.Is(err, wallet.ErrInvalidChecksum):
+		fmt.Fprintln(os.Stderr, "Every word is valid, but the phrase does not check out: a word is wrong or out of order.")
+	}
+	exit(1)
+}
+
 func printUsage() {
 	fmt.Println("CCoin CLI - Command-line interface for CCoin")
 	fmt.Println()
@@ -92,25 +282,215 @@ func printUsage() {
 	fmt.Println("  version     Show version information")
 	fmt.Println("  help        Show this help message")
 	fmt.Println("  status      Show node status")
-	fmt.Println("  dag         DAG operations (status, tips, block)")
-	fmt.Println("  miner       Mining operations (start, stop, status)")
-	fmt.Println("  tx          Transaction operations (send, status)")
-	fmt.Println("  wallet      Wallet operations (new, balance, address)")
-	fmt.Println("  governance  Governance operations (proposals, vote, propose)")
-	fmt.Println("  model       AI model operations (list, info, propose)")
+	fmt.Println("  diagnostics Capture a diagnostics bundle on the node [seconds]")
+	fmt.Println("  estimatefee Estimate the fee to confirm within <target_blocks>")
+	fmt.Println("  dag         DAG operations (status, tips, block, committee)")
+	fmt.Println("  net         Network operations (peers, ban)")
+	fmt.Println("  miner       Mining operations (start, stop, status, delegations)")
+	fmt.Println("  tx          Transaction operations (send, status, receipt)")
+	fmt.Println("  wallet      Wallet operations (new, restore, unlock, newaddress, balance, address, payments, disclose, verify-disclosure)")
+	fmt.Println("  governance  Governance operations (proposals, vote, propose, activity, preview)")
+	fmt.Println("  model       AI model operations (list, info, download, propose)")
+	fmt.Println("  zkp         Zero-knowledge key operations (setup)")
+	fmt.Println("  policy      Disclosure policy operations (check, explain)")
+	fmt.Println("  faucet      Testnet faucet operations (request)")
+	fmt.Println("  config      Node config file operations (init, check)")
+	fmt.Println("  watch       Watchtower alert subscriptions (subscribe, unsubscribe)")
+	fmt.Println("  shell       Interactive shell keeping one node connection and context")
+	fmt.Println("  completion  Print a shell completion script (bash, zsh, fish)")
+	fmt.Println()
+	fmt.Println("Environment:")
+	fmt.Printf("  CCOIN_RPC   Node RPC address (default %s)\n", defaultRPCAddr)
+	fmt.Printf("  CCOIN_IPFS_GATEWAY  IPFS gateway for model downloads (default %s)\n", ipfs.DefaultConfig().Gateway)
+	fmt.Printf("  CCOIN_FAUCET  Testnet faucet URL (default %s)\n", defaultFaucetURL)
+	fmt.Printf("  CCOIN_WATCHTOWER  Watchtower URL (default %s)\n", defaultWatchtowerURL)
+	fmt.Println("  CCOIN_ADDRESS  Default address for miner delegations and governance activity")
+	fmt.Println("  CCOIN_NETWORK  Default network for policy check (default testnet)")
+	fmt.Println("  CCOIN_WALLET_PASSPHRASE  Seed passphrase for wallet new and restore")
 	fmt.Println()
 	fmt.Println("Use 'ccoin-cli <command> help' for more information about a command.")
 }
 
 func cmdStatus() {
 	fmt.Println("Connecting to CCoin node...")
-	// TODO: Connect to RPC and fetch status
-	fmt.Println("Node Status:")
-	fmt.Println("  Version: 0.1.0")
-	fmt.Println("  Network: testnet")
-	fmt.Println("  Height: 0")
-	fmt.Println("  Peers: 0")
-	fmt.Println("  Syncing: false")
+	withClient(func(ctx context.Context, c *rpc.Client) error {
+		st, err := c.GetStatus(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Node Status:")
+		fmt.Printf("  Version: %s\n", st.Version)
+		fmt.Printf("  Network: %s\n", st.Network)
+		if len(st.Roles) > 0 {
+			fmt.Printf("  Roles: %s\n", strings.Join(st.Roles, ", "))
+		}
+		fmt.Printf("  Height: %d\n", st.Height)
+		fmt.Printf("  Epoch: %d\n", st.Epoch)
+		fmt.Printf("  Peers: %d\n", st.Peers)
+		fmt.Printf("  Mempool: %d\n", st.MempoolSize)
+		fmt.Printf("  Syncing: %v\n", st.Syncing)
+		fmt.Printf("  Degraded: %v\n", st.Degraded)
+		for _, sub := range st.Subsystems {
+			fmt.Printf("    %s: %s (restarts: %d)\n", sub.Name, sub.State, sub.Restarts)
+		}
+		return nil
+	})
+}
+
+func cmdEstimateFee(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: ccoin-cli estimatefee <target_blocks>")
+		exit(1)
+	}
+	target, err := strconv.Atoi(args[0])
+	if err != nil || target <= 0 {
+		fmt.Println("Usage: ccoin-cli estimatefee <target_blocks>")
+		exit(1)
+	}
+
+	withClient(func(ctx context.Context, c *rpc.Client) error {
+		est, err := c.EstimateFee(ctx, target, 0)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Fee for confirmation within %d block(s): %s CCoin\n", est.TargetBlocks, economics.FormatAmount(est.Fee))
+		fmt.Printf("  Fee rate: %d per gas (%d gas)\n", est.FeeRate, est.Gas)
+		fmt.Printf("  Base fee: %d\n", est.BaseFee)
+		fmt.Printf("  Mempool: %d\n", est.MempoolRate)
+		fmt.Printf("  Recent blocks: %d\n", est.HistoryRate)
+		fmt.Printf("  Mempool floor: %d\n", est.MinFeeRate)
+		return nil
+	})
+}
+
+func cmdDiagnostics(args []string) {
+	seconds := 30
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			fmt.Println("Usage: ccoin-cli diagnostics [seconds]")
+			exit(1)
+		}
+		seconds = n
+	}
+
+	fmt.Printf("Capturing %ds diagnostics bundle...\n", seconds)
+
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout+time.Duration(seconds)*time.Second)
+	defer cancel()
+
+	client, release := connect(ctx)
+	defer release()
+
+	resp, err := client.CaptureDiagnostics(ctx, seconds)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+	fmt.Printf("Bundle written on node: %s\n", resp.Path)
+}
+
+func cmdNet(args []string) {
+	switch args[0] {
+	case "peers":
+		fs := newFlagSet("net peers")
+		verbose := fs.Bool("verbose", false, "Show each peer's misbehavior log")
+		byScore := fs.Bool("by-score", false, "List the lowest-scoring peers first")
+		fs.Parse(args[1:])
+
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			resp, err := c.GetPeers(ctx, *verbose)
+			if err != nil {
+				return err
+			}
+			if *byScore {
+				sort.SliceStable(resp.Peers, func(i, j int) bool { return resp.Peers[i].Score < resp.Peers[j].Score })
+			}
+			fmt.Printf("Connected Peers: %d\n", len(resp.Peers))
+			for _, p := range resp.Peers {
+				fmt.Printf("  %s\n", p.ID)
+				if len(p.Addrs) > 0 {
+					fmt.Printf("    Address: %s\n", p.Addrs[0])
+				}
+				fmt.Printf("    Connected: %s, last seen %s\n",
+					time.Unix(p.ConnectedAt, 0).Format(time.RFC3339), time.Unix(p.LastSeen, 0).Format(time.RFC3339))
+				direction := "inbound"
+				if p.Outbound {
+					direction = "outbound"
+				}
+				if p.Network != "" {
+					fmt.Printf("    Network: %s (%s)\n", p.Network, direction)
+				} else {
+					fmt.Printf("    Direction: %s\n", direction)
+				}
+				fmt.Printf("    Height: %d\n", p.Height)
+				if len(p.Roles) > 0 {
+					fmt.Printf("    Roles: %s\n", strings.Join(p.Roles, ", "))
+				}
+				fmt.Printf("    Blocks relayed: %d (avg validation %.1fms)\n", p.BlocksRelayed, p.AvgValidationMillis)
+				fmt.Printf("    Invalid: %d, duplicate: %d\n", p.InvalidMessages, p.DuplicateMessages)
+				fmt.Printf("    Traffic: %d bytes in, %d bytes out\n", p.BytesIn, p.BytesOut)
+				fmt.Printf("    Score: %.1f (gossip %.1f)\n", p.Score, p.GossipScore)
+				fmt.Printf("    Misbehavior: %d\n", p.Misbehaviors)
+				for _, m := range p.Misbehavior {
+					fmt.Printf("      %s [%s] %s\n", time.Unix(m.Time, 0).Format(time.RFC3339), m.Topic, m.Reason)
+				}
+			}
+			return nil
+		})
+
+	case "info":
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			resp, err := c.GetNetInfo(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Peers: %d (%d outbound, %d inbound)\n", resp.Peers, resp.Outbound, resp.Inbound)
+			fmt.Printf("Networks: %d\n", len(resp.Networks))
+			for _, n := range resp.Networks {
+				name := n.Network
+				if name == "" {
+					name = "unknown"
+				}
+				fmt.Printf("  %-24s %d peers, %d outbound\n", name, n.Peers, n.Outbound)
+			}
+			for _, w := range resp.Warnings {
+				fmt.Printf("Warning: connectivity concentrated: %s\n", w)
+			}
+			return nil
+		})
+
+	case "reachability":
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			resp, err := c.GetReachability(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Reachability: %s\n", resp.Reachability)
+			for _, addr := range resp.Addrs {
+				fmt.Printf("  Address: %s\n", addr)
+			}
+			for _, addr := range resp.RelayAddrs {
+				fmt.Printf("  Relayed: %s\n", addr)
+			}
+			if len(resp.StaticRelays) > 0 {
+				fmt.Printf("Static relays: %s\n", strings.Join(resp.StaticRelays, ", "))
+			}
+			fmt.Printf("Hole punching: %t (%d succeeded, %d failed)\n", resp.HolePunching, resp.HolePunchesSucceeded, resp.HolePunchesFailed)
+			fmt.Printf("Relay service: %t\n", resp.RelayService)
+			if resp.Reachability == "private" && len(resp.RelayAddrs) == 0 {
+				fmt.Println("Warning: peers cannot reach this node; forward the P2P port or set -relay")
+			}
+			return nil
+		})
+
+	case "ban":
+		cmdNetBan(args[1:])
+
+	default:
+		fmt.Printf("Unknown net subcommand: %s\n", args[0])
+		exit(1)
+	}
 }
 
 func cmdDAG(args []string) {
@@ -120,27 +500,95 @@ func cmdDAG(args []string) {
 
 	switch args[0] {
 	case "status":
-		fmt.Println("DAG Status:")
-		fmt.Println("  Height: 0")
-		fmt.Println("  Tips: 1")
-		fmt.Println("  Total Blocks: 1")
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			tips, err := c.GetTips(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Println("DAG Status:")
+			fmt.Printf("  Height: %d\n", tips.Height)
+			fmt.Printf("  Tips: %d\n", len(tips.Tips))
+			fmt.Printf("  Main Chain Tip: %s\n", tips.MainChainTip)
+			return nil
+		})
 
 	case "tips":
-		fmt.Println("Current Tips:")
-		fmt.Println("  (genesis)")
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			tips, err := c.GetTips(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Println("Current Tips:")
+			for _, tip := range tips.Tips {
+				fmt.Printf("  %s\n", tip)
+			}
+			return nil
+		})
 
 	case "block":
 		if len(args) < 2 {
 			fmt.Println("Usage: ccoin-cli dag block <hash>")
 			return
 		}
-		fmt.Printf("Block %s not found\n", args[1])
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			resp, err := c.GetBlock(ctx, args[1])
+			if err != nil {
+				return err
+			}
+			h := resp.Block.Header
+			fmt.Printf("Block %s\n", h.Hash)
+			fmt.Printf("  Height: %d\n", h.Height)
+			fmt.Printf("  Timestamp: %d\n", h.Timestamp)
+			fmt.Printf("  Miner: %x\n", h.MinerAddress)
+			fmt.Printf("  Reputation: %.4f\n", h.ReputationScore)
+			fmt.Printf("  Quality: %.4f\n", h.QualityScore)
+			fmt.Printf("  Transactions: %d\n", len(resp.Block.Transactions))
+			fmt.Println("  Parents:")
+			for _, p := range h.Parents {
+				fmt.Printf("    %s\n", p)
+			}
+			return nil
+		})
+
+	case "committee":
+		if len(args) < 3 {
+			fmt.Println("Usage: ccoin-cli dag committee <pre-confirmation|decryption|evaluator> <epoch>")
+			return
+		}
+		epoch, err := strconv.ParseUint(args[2], 10, 64)
+		if err != nil {
+			fmt.Printf("Invalid epoch: %s\n", args[2])
+			return
+		}
+		cmdDAGCommittee(args[1], epoch)
 
 	default:
 		fmt.Printf("Unknown DAG command: %s\n", args[0])
 	}
 }
 
+// cmdDAGCommittee prints a committee's selection record and whether it
+// re-verifies
+func cmdDAGCommittee(kind string, epoch uint64) {
+	withClient(func(ctx context.Context, c *rpc.Client) error {
+		resp, err := c.AuditCommittee(ctx, kind, epoch)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Committee %s (selected at epoch %d)\n", resp.Kind, resp.Epoch)
+		fmt.Printf("  Beacon: %s (valid: %t)\n", resp.Beacon, resp.BeaconValid)
+		fmt.Printf("  Selection valid: %t\n", resp.SelectionValid)
+		fmt.Printf("  Record: %s\n", resp.RecordHash)
+		fmt.Printf("  Root: %s (committed by %d blocks)\n", resp.Root, resp.CommittedBlocks)
+		fmt.Printf("  Seats: %d of %d candidates\n", len(resp.Members), len(resp.Candidates))
+		fmt.Println("  Members:")
+		for i, m := range resp.Members {
+			fmt.Printf("    %d. %s\n", i+1, m)
+		}
+		return nil
+	})
+}
+
 func cmdMiner(args []string) {
 	if len(args) == 0 {
 		return
@@ -149,17 +597,88 @@ func cmdMiner(args []string) {
 	switch args[0] {
 	case "start":
 		fmt.Println("Starting miner...")
-		fmt.Println("Miner started.")
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			st, err := c.StartMining(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Miner started for %s.\n", st.Address)
+			return nil
+		})
 
 	case "stop":
 		fmt.Println("Stopping miner...")
-		fmt.Println("Miner stopped.")
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			if _, err := c.StopMining(ctx); err != nil {
+				return err
+			}
+			fmt.Println("Miner stopped.")
+			return nil
+		})
 
 	case "status":
-		fmt.Println("Miner Status:")
-		fmt.Println("  Running: false")
-		fmt.Println("  Reputation: 1.0")
-		fmt.Println("  Blocks Mined: 0")
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			st, err := c.GetMiningStatus(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Println("Miner Status:")
+			fmt.Printf("  Running: %t\n", st.Running)
+			fmt.Printf("  Address: %s\n", st.Address)
+			fmt.Printf("  Reputation: %.4f\n", st.Reputation)
+			if st.CurrentTask != "" {
+				fmt.Printf("  Current Task: %s\n", st.CurrentTask)
+			}
+			fmt.Printf("  Blocks Mined: %d\n", st.BlocksMined)
+			if st.LastBlock != "" {
+				fmt.Printf("  Last Block: %s (height %d, %s)\n", st.LastBlock, st.LastBlockHeight,
+					time.Unix(st.LastBlockTime, 0).Format(time.RFC3339))
+			}
+			if st.LastError != "" {
+				fmt.Printf("  Last Error: %s\n", st.LastError)
+			}
+			return nil
+		})
+
+	case "delegations":
+		fs := newFlagSet("miner delegations")
+		byDelegator := fs.Bool("delegator", false, "List the delegations made by the address instead of to it")
+		fs.Parse(args[1:])
+		addr := fs.Arg(0)
+		if addr == "" {
+			addr = os.Getenv("CCOIN_ADDRESS")
+		}
+		if fs.NArg() > 1 || addr == "" {
+			fmt.Println("Usage: ccoin-cli miner delegations [--delegator] <address>")
+			exit(1)
+		}
+
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			miner, delegator := addr, ""
+			if *byDelegator {
+				miner, delegator = "", addr
+			}
+			resp, err := c.GetDelegations(ctx, miner, delegator)
+			if err != nil {
+				return err
+			}
+			if miner != "" {
+				fmt.Printf("Miner %s\n", resp.Miner)
+				fmt.Printf("  Own Stake: %d\n", resp.OwnStake)
+				fmt.Printf("  Delegated Stake: %d\n", resp.DelegatedStake)
+			} else {
+				fmt.Printf("Delegator %s\n", resp.Delegator)
+			}
+			fmt.Printf("  Delegations: %d\n", len(resp.Delegations))
+			for _, d := range resp.Delegations {
+				fmt.Printf("    %s -> %s\n", d.Delegator, d.Miner)
+				fmt.Printf("      Bonded: %d, rewards %d, slashed %d\n", d.Bonded, d.Rewards, d.Slashed)
+				if d.Unbonding > 0 {
+					fmt.Printf("      Unbonding: %d until block %d\n", d.Unbonding, d.UnbondingUntil)
+				}
+			}
+			return nil
+		})
 
 	default:
 		fmt.Printf("Unknown miner command: %s\n", args[0])
@@ -173,15 +692,194 @@ func cmdTransaction(args []string) {
 
 	switch args[0] {
 	case "send":
-		fmt.Println("Transaction sending not yet implemented")
-		fmt.Println("Usage: ccoin-cli tx send --to <address> --amount <ccoin> [--shielded]")
+		fs := newFlagSet("send")
+		to := fs.String("to", "", "Recipient shielded address")
+		amount := fs.String("amount", "", "Amount in CCoin")
+		fee := fs.String("fee", "", "Fee in CCoin (estimated if omitted)")
+		memo := fs.String("memo", "", "Memo attached to the payment")
+		ref := fs.String("ref", "", "Payment reference memo, such as an invoice ID (replaces --memo)")
+		requestID := fs.String("request-id", "", "Idempotency key; resending with the same key reports the first result")
+		maxDelay := fs.Duration("max-delay", 0, "Broadcast after a random delay up to this (e.g. 10m)")
+		decoys := fs.Bool("decoys", false, "Split change across all free output slots")
+		roundChange := fs.String("round-change", "", "Round change down to a multiple of this many CCoin, paying the rest as fee")
+		avoidSyncPeer := fs.Bool("avoid-sync-peer", false, "Relay through a peer other than the sync peer")
+		fs.Parse(args[1:])
+		if *to == "" || *amount == "" || (*memo != "" && *ref != "") {
+			fmt.Println("Usage: ccoin-cli tx send --to <address> --amount <ccoin> [--fee <ccoin>] [--memo <text> | --ref <id>] [--request-id <id>]")
+			fmt.Println("                         [--max-delay <duration>] [--decoys] [--round-change <ccoin>] [--avoid-sync-peer]")
+			return
+		}
+
+		req := &rpc.SendTransactionRequest{RequestID: *requestID, To: *to, Memo: *memo}
+		if *ref != "" {
+			req.StructuredMemo = &rpc.StructuredMemo{Type: "payment_ref", Reference: *ref}
+		}
+		var err error
+		if req.Amount, err = economics.ParseAmount(*amount); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid amount %q\n", *amount)
+			exit(1)
+		}
+		if *fee != "" {
+			if req.Fee, err = economics.ParseAmount(*fee); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: invalid fee %q\n", *fee)
+				exit(1)
+			}
+		}
+		if *maxDelay > 0 || *decoys || *roundChange != "" || *avoidSyncPeer {
+			req.Privacy = &rpc.SendPrivacy{
+				MaxBroadcastDelaySeconds: uint32(maxDelay.Seconds()),
+				DecoyOutputs:             *decoys,
+				AvoidSyncPeer:            *avoidSyncPeer,
+			}
+			if *roundChange != "" {
+				if req.Privacy.RoundChange, err = economics.ParseAmount(*roundChange); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: invalid change rounding %q\n", *roundChange)
+					exit(1)
+				}
+			}
+		}
+
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			if *fee == "" {
+				est, err := c.EstimateFee(ctx, defaultFeeTarget, 0)
+				if err != nil {
+					return fmt.Errorf("fee estimation failed (pass --fee): %w", err)
+				}
+				req.Fee = est.Fee
+				fmt.Printf("Using estimated fee %s CCoin\n", economics.FormatAmount(req.Fee))
+			}
+			resp, err := c.SendTransaction(ctx, req)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Transaction %s: %s\n", resp.TxHash, resp.Status)
+			switch {
+			case resp.BroadcastAt != 0:
+				fmt.Printf("  Broadcast scheduled for %s\n", time.Unix(resp.BroadcastAt, 0).Format(time.RFC3339))
+			case resp.RelayPeer != "":
+				fmt.Printf("  Relayed via %s\n", resp.RelayPeer)
+			case resp.Broadcast:
+				fmt.Println("  Broadcast to peers")
+			case resp.BroadcastError != "":
+				fmt.Printf("  Broadcast failed: %s\n", resp.BroadcastError)
+			}
+			return nil
+		})
+
+	case "send-batch":
+		fs := newFlagSet("send-batch")
+		fee := fs.String("fee", "", "Fee in CCoin per transaction (estimated if omitted)")
+		batchID := fs.String("batch-id", "", "Idempotency key; resending with the same key reports the first result")
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			fmt.Println("Usage: ccoin-cli tx send-batch [--fee <ccoin>] [--batch-id <id>] <withdrawals.json>")
+			fmt.Println("The file holds a JSON array of {\"id\", \"to\", \"amount\" (base units), \"memo\"}.")
+			fmt.Println("A withdrawal may carry a \"structured_memo\" such as {\"type\": \"payment_ref\", \"reference\": \"INV-1\"} instead of a memo.")
+			return
+		}
+		data, err := os.ReadFile(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		req := &rpc.SendBatchRequest{BatchID: *batchID}
+		if err := json.Unmarshal(data, &req.Withdrawals); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid withdrawals file: %v\n", err)
+			exit(1)
+		}
+		if *fee != "" {
+			if req.FeePerTx, err = economics.ParseAmount(*fee); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: invalid fee %q\n", *fee)
+				exit(1)
+			}
+		}
+
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			if *fee == "" {
+				est, err := c.EstimateFee(ctx, defaultFeeTarget, 0)
+				if err != nil {
+					return fmt.Errorf("fee estimation failed (pass --fee): %w", err)
+				}
+				req.FeePerTx = est.Fee
+			}
+			resp, err := c.SendBatch(ctx, req)
+			if err != nil {
+				return err
+			}
+			out, _ := json.MarshalIndent(resp, "", "  ")
+			fmt.Println(string(out))
+			return nil
+		})
+
+	case "submit":
+		if len(args) < 2 {
+			fmt.Println("Usage: ccoin-cli tx submit <tx.json> [request-id]")
+			return
+		}
+		data, err := os.ReadFile(args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		tx := &types.Transaction{}
+		if err := json.Unmarshal(data, tx); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid transaction file: %v\n", err)
+			exit(1)
+		}
+		req := &rpc.SubmitTransactionRequest{Transaction: tx}
+		if len(args) > 2 {
+			req.RequestID = args[2]
+		}
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			resp, err := c.SubmitTransaction(ctx, req)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Transaction submitted: %s\n", resp.TxHash)
+			return nil
+		})
 
 	case "status":
 		if len(args) < 2 {
 			fmt.Println("Usage: ccoin-cli tx status <txid>")
 			return
 		}
-		fmt.Printf("Transaction %s not found\n", args[1])
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			resp, err := c.GetTransaction(ctx, args[1])
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Transaction %s\n", args[1])
+			fmt.Printf("  Pending: %v\n", resp.Pending)
+			fmt.Printf("  Fee: %d\n", resp.Transaction.Fee)
+			fmt.Printf("  Nullifiers: %d\n", len(resp.Transaction.Nullifiers))
+			fmt.Printf("  Commitments: %d\n", len(resp.Transaction.Commitments))
+			return nil
+		})
+
+	case "receipt":
+		if len(args) < 2 {
+			fmt.Println("Usage: ccoin-cli tx receipt <txid>")
+			return
+		}
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			resp, err := c.GetReceipt(ctx, args[1])
+			if err != nil {
+				return err
+			}
+			r := resp.Receipt
+			fmt.Printf("Receipt for %s\n", r.TxHash)
+			fmt.Printf("  Status: %s\n", resp.Status)
+			fmt.Printf("  Block: %s (height %d, index %d)\n", resp.BlockHash, resp.BlockHeight, resp.Index)
+			fmt.Printf("  Gas used: %d\n", r.GasUsed)
+			for _, d := range r.VerifiedDisclosures {
+				fmt.Printf("  Disclosure verified: type %d\n", d)
+			}
+			for i, pos := range r.CommitmentPositions {
+				fmt.Printf("  Output %d: commitment position %d\n", i, pos)
+			}
+			return nil
+		})
 
 	default:
 		fmt.Printf("Unknown transaction command: %s\n", args[0])
@@ -194,27 +892,262 @@ func cmdWallet(args []string) {
 	}
 
 	switch args[0] {
-	case "new":
-		fmt.Println("Creating new wallet...")
-		fmt.Println("Wallet created. Save your seed phrase:")
-		fmt.Println("  (seed phrase would be displayed here)")
+	case "new", "restore":
+		fs := newFlagSet("wallet " + args[0])
+		language := fs.String("language", wallet.LanguageEnglish, "Seed phrase wordlist for new wallets ("+strings.Join(wallet.Languages(), ", ")+")")
+		withPassphrase := fs.Bool("passphrase", false, "Extend the seed phrase with a passphrase (the \"25th word\")")
+		encryptAtRest := fs.String("encrypt-at-rest", string(atrest.ModeOff), "Encrypt the keystore at rest as ccoind -encrypt-at-rest does: off, keys, or full")
+		keyCmd := fs.String("encrypt-key-cmd", "", "Command printing the hex 32-byte at-rest key, instead of CCOIN_STORAGE_PASSPHRASE")
+		fs.Parse(args[1:])
+
+		cfg := wallet.DefaultConfig()
+		cfg.DataDir = dataDir()
+		cfg.Language = *language
+		if wallet.Exists(cfg.DataDir) {
+			fmt.Fprintf(os.Stderr, "Error: a wallet already exists in %s\n", cfg.DataDir)
+			exit(1)
+		}
+		atRest, err := openAtRest(cfg.DataDir, atrest.Mode(*encryptAtRest), *keyCmd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		cfg.AtRest = atRest
+		if _, err := wallet.LookupWordlist(cfg.Language); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+
+		var mnemonic string
+		if args[0] == "restore" {
+			m, err := readSecret("CCOIN_WALLET_MNEMONIC", "Seed phrase: ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
+			if _, err := wallet.MnemonicToEntropy(m); err != nil {
+				exitMnemonicError(err)
+			}
+			mnemonic = m
+		}
+
+		var passphrase string
+		if *withPassphrase || os.Getenv("CCOIN_WALLET_PASSPHRASE") != "" {
+			p, err := readSecret("CCOIN_WALLET_PASSPHRASE", "Seed passphrase: ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
+			// A mistyped passphrase silently derives another wallet, so
+			// new ones are entered twice
+			if args[0] == "new" && os.Getenv("CCOIN_WALLET_PASSPHRASE") == "" {
+				again, err := readSecret("CCOIN_WALLET_PASSPHRASE", "Repeat seed passphrase: ")
+				if err != nil || again != p {
+					fmt.Fprintln(os.Stderr, "Error: passphrases do not match")
+					exit(1)
+				}
+			}
+			passphrase = p
+		}
+
+		password, err := readSecret("CCOIN_WALLET_PASSWORD", "Wallet password: ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+
+		var w *wallet.Wallet
+		if mnemonic == "" {
+			w, mnemonic, err = wallet.New(cfg, password, passphrase)
+		} else {
+			w, err = wallet.Restore(cfg, mnemonic, password, passphrase)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+
+		fmt.Printf("Wallet written to %s\n", cfg.DataDir)
+		if args[0] == "new" {
+			fmt.Println("Save your seed phrase; it is the only way to recover this wallet:")
+			fmt.Printf("  %s\n", mnemonic)
+			if passphrase != "" {
+				fmt.Println("The wallet also needs the passphrase; the seed phrase alone restores a different, empty wallet.")
+			}
+		}
+		addr := w.Address()
+		fmt.Printf("  Address: %s\n", common.BytesToHex(addr[:]))
+
+	case "unlock":
+		seconds := 0
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
+				fmt.Println("Usage: ccoin-cli wallet unlock [seconds]")
+				exit(1)
+			}
+			seconds = n
+		}
+		password, err := readSecret("CCOIN_WALLET_PASSWORD", "Wallet password: ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			if _, err := c.UnlockWallet(ctx, password, seconds); err != nil {
+				return err
+			}
+			fmt.Println("Wallet unlocked")
+			return nil
+		})
+
+	case "newaddress":
+		shielded := len(args) > 1 && args[1] == "--shielded"
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			resp, err := c.NewAddress(ctx, shielded)
+			if err != nil {
+				return err
+			}
+			fmt.Println(resp.Address)
+			return nil
+		})
 
 	case "balance":
-		fmt.Println("Wallet Balance:")
-		fmt.Println("  Confirmed: 0 CCoin")
-		fmt.Println("  Pending: 0 CCoin")
-		fmt.Println("  Shielded: 0 CCoin")
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			bal, err := c.GetBalance(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Println("Wallet Balance:")
+			fmt.Printf("  Confirmed: %d\n", bal.Confirmed)
+			fmt.Printf("  Pending: %d\n", bal.Pending)
+			fmt.Printf("  Shielded: %d\n", bal.Shielded)
+			return nil
+		})
 
 	case "address":
-		fmt.Println("Wallet Addresses:")
-		fmt.Println("  Transparent: (none)")
-		fmt.Println("  Shielded: (none)")
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			addrs, err := c.ListAddresses(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Println("Wallet Addresses:")
+			for _, a := range addrs.Transparent {
+				fmt.Printf("  Transparent: %s\n", a)
+			}
+			for _, a := range addrs.Shielded {
+				fmt.Printf("  Shielded: %s\n", a)
+			}
+			return nil
+		})
+
+	case "disclose":
+		fs := newFlagSet("disclose")
+		block := fs.String("block", "", "Hash of the block containing the transaction (pending if omitted)")
+		fs.Parse(args[1:])
+		if fs.NArg() != 2 {
+			fmt.Println("Usage: ccoin-cli wallet disclose [--block <hash>] <tx-hash> <output-index>")
+			return
+		}
+		index, err := strconv.ParseUint(fs.Arg(1), 10, 32)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid output index: %v\n", err)
+			exit(1)
+		}
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			resp, err := c.DisclosePayment(ctx, fs.Arg(0), uint32(index), *block)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Paid %d to %s\n", resp.Value, resp.Recipient)
+			fmt.Println(resp.Disclosure)
+			return nil
+		})
+
+	case "verify-disclosure":
+		fs := newFlagSet("verify-disclosure")
+		block := fs.String("block", "", "Hash of the block containing the transaction (pending if omitted)")
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			fmt.Println("Usage: ccoin-cli wallet verify-disclosure [--block <hash>] <disclosure>")
+			return
+		}
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			resp, err := c.VerifyPaymentDisclosure(ctx, fs.Arg(0), *block)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Transaction: %s output %d\n", resp.TxHash, resp.OutputIndex)
+			fmt.Printf("  Value: %d\n", resp.Value)
+			fmt.Printf("  Recipient: %s\n", resp.Recipient)
+			if resp.Memo != "" {
+				fmt.Printf("  Memo: %s\n", resp.Memo)
+			}
+			if resp.StructuredMemo != nil {
+				fmt.Printf("  Memo: %s\n", formatStructuredMemo(resp.StructuredMemo))
+			}
+			if !resp.Valid {
+				return fmt.Errorf("disclosure invalid: %s", resp.Error)
+			}
+			fmt.Println("  Valid: yes")
+			return nil
+		})
+
+	case "payments":
+		fs := newFlagSet("payments")
+		req := &rpc.ListPaymentsRequest{}
+		fs.StringVar(&req.Address, "address", "", "Only payments to this address")
+		fs.Uint64Var(&req.FromHeight, "from-height", 0, "Only payments received at or above this height")
+		fs.BoolVar(&req.IncludeSpent, "all", false, "Include payments already spent")
+		fs.StringVar(&req.MemoType, "type", "", "Memo type: text, payment_ref, license or governance")
+		fs.StringVar(&req.Reference, "ref", "", "Payment reference or license ID")
+		fs.StringVar(&req.Subject, "subject", "", "Model or proposal ID named by the memo")
+		fs.Parse(args[1:])
+		withClient(func(ctx context.Context, c *rpc.Client) error {
+			resp, err := c.ListPayments(ctx, req)
+			if err != nil {
+				return err
+			}
+			if len(resp.Payments) == 0 {
+				fmt.Println("No matching payments")
+				return nil
+			}
+			for _, p := range resp.Payments {
+				spent := ""
+				if p.Spent {
+					spent = " (spent)"
+				}
+				fmt.Printf("%s  %s CCoin at height %d%s\n", p.Commitment, economics.FormatAmount(p.Value), p.Height, spent)
+				switch {
+				case p.StructuredMemo != nil:
+					fmt.Printf("  Memo: %s\n", formatStructuredMemo(p.StructuredMemo))
+				case p.Memo != "":
+					fmt.Printf("  Memo: %s\n", p.Memo)
+				}
+			}
+			return nil
+		})
 
 	default:
 		fmt.Printf("Unknown wallet command: %s\n", args[0])
 	}
 }
 
+// formatStructuredMemo renders a structured memo on one line
+func formatStructuredMemo(m *rpc.StructuredMemo) string {
+	out := m.Type
+	if m.Subject != "" {
+		out += " " + m.Subject
+	}
+	if m.Reference != "" {
+		out += " ref=" + m.Reference
+	}
+	if m.Text != "" {
+		out += fmt.Sprintf(" %q", m.Text)
+	}
+	return out
+}
+
 func cmdGovernance(args []string) {
 	if len(args) == 0 {
 		return
@@ -231,11 +1164,88 @@ func cmdGovernance(args []string) {
 	case "propose":
 		fmt.Println("Usage: ccoin-cli governance propose --type <model|treasury|upgrade> [options]")
 
+	case "activity":
+		addr := os.Getenv("CCOIN_ADDRESS")
+		if len(args) > 1 {
+			addr = args[1]
+		}
+		if addr == "" {
+			fmt.Println("Usage: ccoin-cli governance activity <address>")
+			return
+		}
+		cmdGovernanceActivity(addr)
+
+	case "preview":
+		if len(args) < 2 {
+			fmt.Println("Usage: ccoin-cli governance preview <proposal-id>")
+			return
+		}
+		cmdGovernancePreview(args[1])
+
 	default:
 		fmt.Printf("Unknown governance command: %s\n", args[0])
 	}
 }
 
+// cmdGovernanceActivity prints the votes and proposals of an address
+func cmdGovernanceActivity(addr string) {
+	withClient(func(ctx context.Context, c *rpc.Client) error {
+		votes, err := c.GetVoterHistory(ctx, addr)
+		if err != nil {
+			return err
+		}
+		proposals, err := c.GetProposerHistory(ctx, addr)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Governance activity for %s\n", addr)
+		fmt.Printf("Votes: %d (%d decided, %d with the outcome)\n", len(votes.Votes), votes.Decided, votes.WithOutcome)
+		for _, v := range votes.Votes {
+			choice := "no"
+			if v.Support {
+				choice = "yes"
+			}
+			fmt.Printf("  %s %-3s power %d at block %d -> %s  %s\n",
+				v.ProposalID, choice, v.VotePower, v.CastAt, v.Outcome, v.Title)
+		}
+		fmt.Printf("Proposals: %d (%d passed, %d rejected)\n", len(proposals.Proposals), proposals.Passed, proposals.Rejected)
+		for _, p := range proposals.Proposals {
+			fmt.Printf("  %s %s (for %d, against %d)  %s\n",
+				p.ProposalID, p.Status, p.VotesFor, p.VotesAgainst, p.Title)
+		}
+		return nil
+	})
+}
+
+// cmdGovernancePreview prints the simulation of an economic parameter
+// proposal next to the projection under the current parameters
+func cmdGovernancePreview(proposalID string) {
+	withClient(func(ctx context.Context, c *rpc.Client) error {
+		resp, err := c.PreviewParameterChange(ctx, proposalID, nil)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Simulation of %s\n", proposalID)
+		fmt.Printf("  From height %d, %d epochs, %d fees per block\n", resp.StartHeight, resp.Epochs, resp.FeesPerBlock)
+		fmt.Printf("  Hash:     %s\n", resp.SimulationHash)
+		fmt.Printf("  Attached: %s\n", resp.AttachedHash)
+		if !resp.Matches {
+			fmt.Println("  WARNING: simulation does not match the hash attached to the proposal")
+		}
+		fmt.Println("  Epoch  Emission  Burned  Treasury  Supply  (current: Emission  Supply)")
+		for i, p := range resp.Projections {
+			b := resp.Baseline[i]
+			fmt.Printf("  %d  %s  %s  %s  %s  (%s  %s)\n", p.Epoch,
+				economics.FormatAmount(p.Emission), economics.FormatAmount(p.Burned),
+				economics.FormatAmount(p.TreasuryInflow), economics.FormatAmount(p.Supply),
+				economics.FormatAmount(b.Emission), economics.FormatAmount(b.Supply))
+		}
+		return nil
+	})
+}
+
 func cmdModel(args []string) {
 	if len(args) == 0 {
 		return
@@ -253,6 +1263,9 @@ func cmdModel(args []string) {
 		}
 		fmt.Printf("Model %s not found\n", args[1])
 
+	case "download":
+		cmdModelDownload(args[1:])
+
 	case "propose":
 		fmt.Println("Usage: ccoin-cli model propose --architecture <arch> --domain <domain> --data <url>")
 
@@ -260,3 +1273,162 @@ func cmdModel(args []string) {
 		fmt.Printf("Unknown model command: %s\n", args[0])
 	}
 }
+
+// modelManifest is written next to downloaded weights
+type modelManifest struct {
+	ModelID      string                 `json:"model_id"`
+	Version      uint32                 `json:"version"`
+	WeightsCID   string                 `json:"weights_cid"`
+	ParentCID    string                 `json:"parent_cid,omitempty"`
+	Architecture string                 `json:"architecture"`
+	Domain       string                 `json:"domain"`
+	License      string                 `json:"license"`
+	LicenseTerms string                 `json:"license_terms"`
+	Accuracy     float64                `json:"accuracy"`
+	Attestations []manifestAttestation  `json:"attestations"`
+	Contributors []rpc.ModelContributor `json:"contributors"`
+	Size         int64                  `json:"size"`
+	DownloadedAt string                 `json:"downloaded_at"`
+}
+
+// manifestAttestation is one evaluator's result for the downloaded weights
+type manifestAttestation struct {
+	Evaluator string  `json:"evaluator"`
+	Accuracy  float64 `json:"accuracy"`
+	Loss      float64 `json:"loss"`
+}
+
+// cmdModelDownload resolves a model version, checks its attestation chain,
+// fetches and verifies the weights and writes them with a manifest
+func cmdModelDownload(args []string) {
+	usage := "Usage: ccoin-cli model download <model_id> [--version <n>] [--out <dir>]"
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fmt.Println(usage)
+		return
+	}
+	id := args[0]
+
+	fs := newFlagSet("download")
+	version := fs.Uint("version", 0, "Weights version (default latest)")
+	out := fs.String("out", "", "Output directory (default ./<model_id>-v<version>)")
+	fs.Parse(args[1:])
+
+	var model *rpc.GetModelResponse
+	withClient(func(ctx context.Context, c *rpc.Client) error {
+		var err error
+		model, err = c.GetModel(ctx, id, uint32(*version))
+		return err
+	})
+	if len(model.Versions) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: model %s has no published weights\n", id)
+		exit(1)
+	}
+	v := model.Versions[len(model.Versions)-1]
+
+	if err := verifyModelChain(model); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: attestation chain for %s v%d does not verify: %v\n", id, v.Version, err)
+		exit(1)
+	}
+	fmt.Printf("Verified attestation chain (%d versions)\n", len(model.Versions))
+
+	dir := *out
+	if dir == "" {
+		dir = fmt.Sprintf("%s-v%d", model.ModelID, v.Version)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+
+	fmt.Printf("Fetching weights %s from %s...\n", v.WeightsCID, ipfsGateway())
+	size, err := fetchWeights(v.WeightsCID, filepath.Join(dir, "weights"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to fetch weights: %v\n", err)
+		exit(1)
+	}
+
+	manifest := &modelManifest{
+		ModelID:      model.ModelID,
+		Version:      v.Version,
+		WeightsCID:   v.WeightsCID,
+		ParentCID:    v.ParentCID,
+		Architecture: model.Architecture,
+		Domain:       model.Domain,
+		License:      model.License,
+		LicenseTerms: model.LicenseTerms,
+		Accuracy:     v.Accuracy,
+		Contributors: model.Contributors,
+		Size:         size,
+		DownloadedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	for _, att := range v.Attestations {
+		manifest.Attestations = append(manifest.Attestations, manifestAttestation{
+			Evaluator: common.BytesToHex(att.Evaluator[:]),
+			Accuracy:  att.Accuracy,
+			Loss:      att.Loss,
+		})
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, "manifest.json"), data, 0644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to write manifest: %v\n", err)
+		exit(1)
+	}
+
+	fmt.Printf("Model %s v%d written to %s (%d bytes, accuracy %.4f, license %s)\n",
+		model.ModelID, v.Version, dir, size, v.Accuracy, model.License)
+}
+
+// verifyModelChain checks every version's attestations against the
+// evaluator keys reported by the node
+func verifyModelChain(model *rpc.GetModelResponse) error {
+	b, err := common.HexToBytes(model.ModelID)
+	if err != nil || len(b) != types.HashSize {
+		return fmt.Errorf("invalid model id %q", model.ModelID)
+	}
+
+	keys := make(map[types.Address]ed25519.PublicKey, len(model.Evaluators))
+	for _, e := range model.Evaluators {
+		addr, err := common.HexToBytes(e.Address)
+		if err != nil || len(addr) != types.AddressSize {
+			return fmt.Errorf("invalid evaluator address %q", e.Address)
+		}
+		key, err := common.HexToBytes(e.PublicKey)
+		if err != nil {
+			return fmt.Errorf("invalid evaluator key for %s", e.Address)
+		}
+		var a types.Address
+		copy(a[:], addr)
+		keys[a] = key
+	}
+
+	return aicommons.VerifyVersionChain(types.HashFromBytes(b), model.Versions, keys, aicommons.DefaultEvaluatorConfig().Tolerance)
+}
+
+// fetchWeights downloads and verifies weights into path. Content lands in
+// a temporary file that only replaces path once the whole DAG verified.
+func fetchWeights(cid, path string) (int64, error) {
+	client := ipfs.NewClient(&ipfs.Config{
+		Gateway: ipfsGateway(),
+		Timeout: ipfs.DefaultConfig().Timeout,
+	})
+
+	tmp := path + ".partial"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := client.Fetch(context.Background(), cid, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	return size, os.Rename(tmp, path)
+}
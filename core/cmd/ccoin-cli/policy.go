@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ccoin/core/internal/rpc"
+	"github.com/ccoin/core/internal/zkp"
+)
+
+func cmdPolicy(args []string) {
+	switch args[0] {
+	case "check":
+		cmdPolicyCheck(args[1:])
+	case "explain":
+		cmdPolicyExplain(args[1:])
+	default:
+		fmt.Printf("Unknown policy subcommand: %s\n", args[0])
+		exit(1)
+	}
+}
+
+// cmdPolicyCheck parses a policy file and prints the proposal data that
+// would put it in force
+func cmdPolicyCheck(args []string) {
+	fs := newFlagSet("policy check")
+	network := fs.String("network", cliNetwork(), "Network the policy is for")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Println("Usage: ccoin-cli policy check [-network <name>] <file>")
+		exit(1)
+	}
+
+	src, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+	rules, err := zkp.ParsePolicyRules(string(src))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+
+	fmt.Printf("%d rule(s) for %s:\n", len(rules), *network)
+	fmt.Print(zkp.FormatPolicyRules(rules))
+	fmt.Println()
+	fmt.Println("Parameter adjustment proposal data:")
+	data, _ := json.MarshalIndent(zkp.PolicyProposalData(*network, rules), "", "  ")
+	fmt.Println(string(data))
+}
+
+// cmdPolicyExplain asks the node which disclosures a planned transaction
+// needs
+func cmdPolicyExplain(args []string) {
+	fs := newFlagSet("policy explain")
+	minValue := fs.Uint64("min", 0, "Lower bound of the attached range disclosure")
+	maxValue := fs.Uint64("max", 0, "Upper bound of the attached range disclosure; 0 for none")
+	jurisdictions := fs.String("jurisdictions", "", "Comma-separated jurisdictions of attached identity disclosures")
+	attached := fs.String("attached", "", "Comma-separated disclosures already attached")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Println("Usage: ccoin-cli policy explain [options] <transfer|inference|payout|sealed|delegation>")
+		exit(1)
+	}
+
+	req := &rpc.ExplainDisclosuresRequest{
+		Recipient:     fs.Arg(0),
+		MinValue:      *minValue,
+		MaxValue:      *maxValue,
+		Jurisdictions: splitList(*jurisdictions),
+		Attached:      splitList(*attached),
+	}
+	withClient(func(ctx context.Context, c *rpc.Client) error {
+		resp, err := c.ExplainDisclosures(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Disclosure policy of %s\n", resp.Network)
+		for _, r := range resp.Rules {
+			mark := " "
+			if r.Matched {
+				mark = "*"
+			}
+			fmt.Printf(" %s %s\n     %s\n", mark, r.Rule, r.Reason)
+		}
+		fmt.Printf("Required: %s\n", listOrNone(resp.Required))
+		fmt.Printf("Missing:  %s\n", listOrNone(resp.Missing))
+		return nil
+	})
+}
+
+// splitList splits a comma-separated flag value
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// listOrNone joins names, or returns "none"
+func listOrNone(names []string) string {
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
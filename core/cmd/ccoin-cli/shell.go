@@ -0,0 +1,399 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/ccoin/core/internal/rpc"
+)
+
+// historyFile holds shell history in the home directory, and maxHistory
+// bounds the lines kept
+const (
+	historyFile = ".ccoin_history"
+	maxHistory  = 1000
+)
+
+// exitCode unwinds a command run in the shell in place of os.Exit
+type exitCode int
+
+var (
+	// inShell is set while the shell runs commands
+	inShell bool
+
+	// shellClient is the node connection the shell keeps across commands
+	shellClient *rpc.Client
+)
+
+// exit ends the command with code: the process, or in the shell just
+// the command
+func exit(code int) {
+	if inShell {
+		panic(exitCode(code))
+	}
+	os.Exit(code)
+}
+
+// newFlagSet returns the flag set of a command. Bad flags end the
+// command as exit does.
+func newFlagSet(name string) *flag.FlagSet {
+	if inShell {
+		return flag.NewFlagSet(name, flag.PanicOnError)
+	}
+	return flag.NewFlagSet(name, flag.ExitOnError)
+}
+
+// connect returns a client for the node and a function releasing it,
+// exiting on connection errors. The shell keeps one connection open for
+// all its commands.
+func connect(ctx context.Context) (*rpc.Client, func()) {
+	if inShell && shellClient != nil {
+		return shellClient, func() {}
+	}
+
+	client, err := rpc.Dial(ctx, rpcAddr())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to connect to node at %s: %v\n", rpcAddr(), err)
+		exit(1)
+	}
+	if inShell {
+		shellClient = client
+		return client, func() {}
+	}
+	return client, func() { client.Close() }
+}
+
+// shellSetting is shell context held in the CCOIN_* environment
+// variable commands already read, so setting it once spares repeating
+// it on each command
+type shellSetting struct {
+	name  string
+	env   string
+	usage string
+	value func() string
+}
+
+var shellSettings = []shellSetting{
+	{"rpc", "CCOIN_RPC", "Node RPC address", rpcAddr},
+	{"network", "CCOIN_NETWORK", "Network commands default to", cliNetwork},
+	{"address", "CCOIN_ADDRESS", "Address for miner delegations and governance activity", func() string { return os.Getenv("CCOIN_ADDRESS") }},
+	{"data-dir", "CCOIN_DATA_DIR", "Node data directory", dataDir},
+	{"faucet", "CCOIN_FAUCET", "Testnet faucet URL", faucetURL},
+	{"watchtower", "CCOIN_WATCHTOWER", "Watchtower URL", watchtowerURL},
+	{"ipfs-gateway", "CCOIN_IPFS_GATEWAY", "IPFS gateway for model downloads", ipfsGateway},
+}
+
+// cmdShell runs commands read from standard input until exit or end of
+// input, keeping the node connection, context settings and history
+// between them
+func cmdShell() {
+	inShell = true
+	defer func() {
+		if shellClient != nil {
+			shellClient.Close()
+		}
+	}()
+
+	info, _ := os.Stdin.Stat()
+	interactive := info != nil && info.Mode()&os.ModeCharDevice != 0
+	history := loadHistory()
+
+	if interactive {
+		fmt.Printf("CCoin CLI v%s shell. Type help for commands, exit to leave.\n", version)
+		shellConnect()
+	}
+
+	for {
+		if interactive {
+			fmt.Printf("ccoin %s> ", cliNetwork())
+		}
+		line, err := stdin.ReadString('\n')
+		if err != nil && line == "" {
+			if interactive {
+				fmt.Println()
+			}
+			return
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// !! and !<n> rerun a line from history
+		if strings.HasPrefix(line, "!") {
+			recalled, err := recallHistory(history, line)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				continue
+			}
+			line = recalled
+			fmt.Println(line)
+		}
+		history = appendHistory(history, line)
+
+		args, err := splitArgs(line)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			continue
+		}
+		if args[0] == "ccoin-cli" && len(args) > 1 {
+			args = args[1:]
+		}
+
+		switch args[0] {
+		case "exit", "quit":
+			return
+		case "help":
+			printUsage()
+			printShellUsage()
+		case "history":
+			for i, h := range history {
+				fmt.Printf("%5d  %s\n", i+1, h)
+			}
+		case "set":
+			shellSet(args[1:])
+		case "unset":
+			if len(args) != 2 {
+				fmt.Println("Usage: unset <setting>")
+				continue
+			}
+			shellSet([]string{args[1], ""})
+		case "shell":
+			fmt.Println("Already in the shell")
+		default:
+			runShellCommand(args)
+		}
+	}
+}
+
+// printShellUsage lists the commands only the shell has
+func printShellUsage() {
+	fmt.Println()
+	fmt.Println("Shell commands:")
+	fmt.Println("  set [<setting> [<value>]]  Show or change the context commands run in")
+	fmt.Println("  unset <setting>            Return a setting to its default")
+	fmt.Println("  history                    List earlier lines; !! or !<n> reruns one")
+	fmt.Println("  exit                       Leave the shell")
+	fmt.Println()
+	fmt.Println("Settings:")
+	for _, s := range shellSettings {
+		fmt.Printf("  %-12s %s (%s)\n", s.name, s.usage, s.env)
+	}
+}
+
+// shellConnect dials the node and, unless a network is set, takes the
+// node's as the shell's
+func shellConnect() {
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+	defer cancel()
+
+	if shellClient == nil {
+		client, err := rpc.Dial(ctx, rpcAddr())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to connect to node at %s: %v\n", rpcAddr(), err)
+			return
+		}
+		shellClient = client
+	}
+	st, err := shellClient.GetStatus(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: node at %s is not responding: %v\n", rpcAddr(), err)
+		return
+	}
+	fmt.Printf("Connected to %s node at %s, height %d\n", st.Network, rpcAddr(), st.Height)
+	if os.Getenv("CCOIN_NETWORK") == "" && st.Network != "" {
+		os.Setenv("CCOIN_NETWORK", st.Network)
+	}
+}
+
+// shellSet lists the settings, shows one or changes one. Changing the
+// node address reconnects.
+func shellSet(args []string) {
+	if len(args) == 0 {
+		for _, s := range shellSettings {
+			fmt.Printf("  %-12s %s\n", s.name, s.value())
+		}
+		return
+	}
+
+	var setting *shellSetting
+	for i := range shellSettings {
+		if shellSettings[i].name == args[0] {
+			setting = &shellSettings[i]
+		}
+	}
+	if setting == nil {
+		fmt.Fprintf(os.Stderr, "Error: unknown setting %q\n", args[0])
+		return
+	}
+	if len(args) == 1 {
+		fmt.Println(setting.value())
+		return
+	}
+
+	value := strings.Join(args[1:], " ")
+	if value == "" {
+		os.Unsetenv(setting.env)
+	} else {
+		os.Setenv(setting.env, value)
+	}
+	if setting.env == "CCOIN_RPC" {
+		if shellClient != nil {
+			shellClient.Close()
+			shellClient = nil
+		}
+		shellConnect()
+	}
+}
+
+// runShellCommand runs one command, ending just the command where it
+// would exit the process. Flag errors were already reported by the
+// command's flag set.
+func runShellCommand(args []string) {
+	defer func() {
+		switch r := recover().(type) {
+		case nil, exitCode:
+		case runtime.Error:
+			panic(r)
+		case error:
+		default:
+			panic(r)
+		}
+	}()
+	run(args)
+}
+
+// splitArgs splits a command line into arguments as a POSIX shell would
+// for quoting: single quotes are literal, double quotes and bare words
+// take backslash escapes
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inWord = true, true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				args = append(args, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote or escape")
+	}
+	if inWord {
+		args = append(args, cur.String())
+	}
+	if len(args) == 0 {
+		return nil, errors.New("empty command")
+	}
+	return args, nil
+}
+
+// historyPath returns the shell history file, or "" without a home
+// directory
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, historyFile)
+}
+
+// loadHistory reads the lines saved by earlier shells, trimming the
+// file to the most recent
+func loadHistory() []string {
+	path := historyPath()
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var history []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			history = append(history, line)
+		}
+	}
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+		os.WriteFile(path, []byte(strings.Join(history, "\n")+"\n"), 0600)
+	}
+	return history
+}
+
+// appendHistory adds a line to the history and the history file,
+// skipping an immediate repeat
+func appendHistory(history []string, line string) []string {
+	if len(history) > 0 && history[len(history)-1] == line {
+		return history
+	}
+	history = append(history, line)
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+
+	path := historyPath()
+	if path == "" {
+		return history
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return history
+	}
+	defer f.Close()
+	io.WriteString(f, line+"\n")
+	return history
+}
+
+// recallHistory expands !! to the last line and !<n> to line n
+func recallHistory(history []string, line string) (string, error) {
+	if line == "!!" {
+		if len(history) == 0 {
+			return "", errors.New("history is empty")
+		}
+		return history[len(history)-1], nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 1 || n > len(history) {
+		return "", fmt.Errorf("no history entry %s", line[1:])
+	}
+	return history[n-1], nil
+}
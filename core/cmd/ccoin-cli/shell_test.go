@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test splitting command lines with quotes and escapes
+func TestSplitArgs(t *testing.T) {
+	for line, want := range map[string][]string{
+		`status`:                            {"status"},
+		"  tx   send\t0x01  5 ":             {"tx", "send", "0x01", "5"},
+		`model get 'two words' "and \"q\""`: {"model", "get", "two words", `and "q"`},
+		`set address 'it\s'`:                {"set", "address", `it\s`},
+		`a\ b c`:                            {"a b", "c"},
+		`empty '' ""`:                       {"empty", "", ""},
+		`join"ed"'words'`:                   {"joinedwords"},
+	} {
+		got, err := splitArgs(line)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("splitArgs(%q) = %q, %v; want %q", line, got, err, want)
+		}
+	}
+
+	for _, line := range []string{`"open`, `'open`, `trailing\`, "   "} {
+		if args, err := splitArgs(line); err == nil {
+			t.Errorf("splitArgs(%q) = %q, want an error", line, args)
+		}
+	}
+}
+
+// Test recalling and recording history lines
+func TestShellHistory(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	if _, err := recallHistory(nil, "!!"); err == nil {
+		t.Error("Recalled from an empty history")
+	}
+
+	var history []string
+	for _, line := range []string{"status", "status", "dag tips", "net peers"} {
+		history = appendHistory(history, line)
+	}
+	want := []string{"status", "dag tips", "net peers"}
+	if !reflect.DeepEqual(history, want) {
+		t.Errorf("History = %q, want the repeat dropped: %q", history, want)
+	}
+
+	for line, want := range map[string]string{"!!": "net peers", "!1": "status", "!3": "net peers"} {
+		if got, err := recallHistory(history, line); err != nil || got != want {
+			t.Errorf("recallHistory(%s) = %q, %v; want %q", line, got, err, want)
+		}
+	}
+	for _, line := range []string{"!0", "!4", "!x"} {
+		if _, err := recallHistory(history, line); err == nil {
+			t.Errorf("recallHistory(%s) succeeded", line)
+		}
+	}
+
+	// The next shell starts with the saved history, trimmed to the most
+	// recent lines
+	if got := loadHistory(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Loaded history %q, want %q", got, want)
+	}
+	lines := make([]string, maxHistory+5)
+	for i := range lines {
+		lines[i] = "version"
+	}
+	lines[5] = "first kept"
+	path := filepath.Join(home, historyFile)
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got := loadHistory(); len(got) != maxHistory || got[0] != "first kept" {
+		t.Errorf("Loaded %d lines starting %q, want %d starting with the first kept", len(got), got[0], maxHistory)
+	}
+	if data, _ := os.ReadFile(path); strings.Count(string(data), "\n") != maxHistory {
+		t.Error("History file was not trimmed")
+	}
+}
+
+// runShell runs the shell over script and returns what it printed
+func runShell(t *testing.T, script string) string {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CCOIN_NETWORK", "")
+
+	// A pipe in place of standard input keeps the shell from prompting
+	// as it would on a terminal
+	notTerminal, unused, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer notTerminal.Close()
+	defer unused.Close()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, osStdin, input := os.Stdout, os.Stdin, stdin
+	os.Stdout, os.Stdin, stdin = w, notTerminal, bufio.NewReader(strings.NewReader(script))
+	defer func() {
+		os.Stdout, os.Stdin, stdin, inShell = stdout, osStdin, input, false
+	}()
+
+	out := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+	cmdShell()
+	w.Close()
+	return <-out
+}
+
+// Test that the shell keeps settings between commands, survives commands
+// that would exit the process and stops at exit
+func TestShell(t *testing.T) {
+	out := runShell(t, strings.Join([]string{
+		"# comments and blank lines are skipped",
+		"",
+		"set network devnet",
+		"set network",
+		"ccoin-cli version",
+		"!!",
+		"dag",
+		"set nosuch value",
+		"unset network",
+		"set network",
+		"history",
+		"exit",
+		"version",
+	}, "\n"))
+
+	for _, want := range []string{
+		"devnet\n",
+		"CCoin CLI v" + version + "\n",
+		"Usage: ccoin-cli dag <subcommand>",
+		"testnet\n",
+		"    1  set network devnet\n",
+		"    3  ccoin-cli version\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Shell output lacks %q:\n%s", want, out)
+		}
+	}
+	// The recalled line runs and is echoed, and nothing runs after exit
+	if n := strings.Count(out, "CCoin CLI v"); n != 2 {
+		t.Errorf("Version printed %d times, want 2:\n%s", n, out)
+	}
+	if strings.Contains(out, "    4  ccoin-cli version") {
+		t.Errorf("Recalled repeat was added to the history:\n%s", out)
+	}
+	if os.Getenv("CCOIN_NETWORK") != "" {
+		t.Error("unset left the network set")
+	}
+}
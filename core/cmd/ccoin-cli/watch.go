@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ccoin/core/internal/watchtower"
+)
+
+// defaultWatchtowerURL is the watchtower used when CCOIN_WATCHTOWER is
+// not set
+const defaultWatchtowerURL = "http://127.0.0.1:9005"
+
+// watchtowerURL returns the watchtower URL (overridable via
+// CCOIN_WATCHTOWER)
+func watchtowerURL() string {
+	if url := os.Getenv("CCOIN_WATCHTOWER"); url != "" {
+		return url
+	}
+	return defaultWatchtowerURL
+}
+
+func cmdWatch(args []string) {
+	switch args[0] {
+	case "subscribe":
+		cmdWatchRequest(watchtower.ActionSubscribe, args[1:])
+	case "unsubscribe":
+		cmdWatchRequest(watchtower.ActionUnsubscribe, args[1:])
+	default:
+		fmt.Printf("Unknown watch subcommand: %s\n", args[0])
+		exit(1)
+	}
+}
+
+// cmdWatchRequest signs a subscription request with the subscriber's key
+// and sends it to a watchtower
+func cmdWatchRequest(action string, args []string) {
+	fs := newFlagSet("watch " + action)
+	url := fs.String("url", watchtowerURL(), "Watchtower URL")
+	keyFile := fs.String("key", "", "File holding the subscriber's hex Ed25519 seed: a delegator's or licensee's key")
+	miner := fs.String("miner", "", "Miner address to watch for slashing")
+	model := fs.String("model", "", "Model ID to watch for deprecation")
+	license := fs.String("license", "", "License ID to watch for revocation")
+	fs.Parse(args)
+
+	var kind watchtower.Kind
+	var target string
+	n := 0
+	for k, t := range map[watchtower.Kind]string{watchtower.KindMiner: *miner, watchtower.KindModel: *model, watchtower.KindLicense: *license} {
+		if t != "" {
+			kind, target = k, t
+			n++
+		}
+	}
+	if *keyFile == "" || n != 1 || fs.NArg() != 1 {
+		fmt.Printf("Usage: ccoin-cli watch %s --key <file> [--url <watchtower>] --miner <address> | --model <id> | --license <id> <webhook>\n", action)
+		exit(1)
+	}
+
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+	data, err := os.ReadFile(*keyFile)
+	if err != nil {
+		fail(err)
+	}
+	seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		fail(fmt.Errorf("%s must hold a hex Ed25519 seed", *keyFile))
+	}
+
+	req := &watchtower.Request{
+		Action:  action,
+		Kind:    kind,
+		Target:  target,
+		Webhook: fs.Arg(0),
+		Nonce:   uint64(time.Now().UnixNano()),
+	}
+	req.Sign(ed25519.NewKeyFromSeed(seed))
+
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+	defer cancel()
+	sub, err := watchtower.Submit(ctx, *url, req)
+	if err != nil {
+		fail(err)
+	}
+
+	verb := "Subscribed"
+	if action == watchtower.ActionUnsubscribe {
+		verb = "Unsubscribed"
+	}
+	fmt.Printf("%s %s to %s %s\n", verb, sub.Webhook, sub.Kind, sub.Target)
+	fmt.Printf("  Subscription: %s\n", sub.ID)
+	fmt.Printf("  Subscriber: %s\n", sub.Subscriber)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ccoin/core/internal/zkp"
+)
+
+func cmdZKP(args []string) {
+	switch args[0] {
+	case "setup":
+		cmdZKPSetup(args[1:])
+	default:
+		fmt.Printf("Unknown zkp subcommand: %s\n", args[0])
+		exit(1)
+	}
+}
+
+// cmdZKPSetup compiles every circuit, runs setup once and writes the keys.
+// Nodes load them with -zk-keys instead of each running their own setup.
+func cmdZKPSetup(args []string) {
+	fs := newFlagSet("zkp setup")
+	out := fs.String("out", "keys", "Output directory")
+	system := fs.String("proof-system", "groth16", "Transaction proof system: groth16 or plonk")
+	srsPath := fs.String("plonk-srs", "", "Universal KZG SRS file for -proof-system=plonk")
+	fs.Parse(args)
+
+	circuits := zkp.NewCircuitManager()
+	switch *system {
+	case "groth16":
+	case "plonk":
+		if *srsPath == "" {
+			fmt.Fprintln(os.Stderr, "Error: -plonk-srs is required for plonk")
+			exit(1)
+		}
+		srs, err := zkp.LoadSRS(*srsPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to load SRS: %v\n", err)
+			exit(1)
+		}
+		circuits.SetBackend(zkp.ProofTypeTransaction, zkp.NewPLONKBackend(srs))
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown proof system %q\n", *system)
+		exit(1)
+	}
+
+	start := time.Now()
+	fmt.Println("Compiling circuits and running setup...")
+	if err := circuits.CompileTransactionCircuit(zkp.MaxTxInputs, zkp.MaxTxOutputs); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: transaction circuit: %v\n", err)
+		exit(1)
+	}
+	if err := circuits.CompileRangeCircuit(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: range circuit: %v\n", err)
+		exit(1)
+	}
+	if err := circuits.CompileAggregateCircuit(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: aggregate disclosure circuit: %v\n", err)
+		exit(1)
+	}
+	if err := circuits.CompileIdentityCircuit(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: identity disclosure circuit: %v\n", err)
+		exit(1)
+	}
+	if err := circuits.CompileTemporalCircuit(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: temporal disclosure circuit: %v\n", err)
+		exit(1)
+	}
+	if err := circuits.CompileGradientCircuit(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: gradient circuit: %v\n", err)
+		exit(1)
+	}
+
+	if err := circuits.SaveKeys(*out); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+
+	fmt.Printf("Wrote keys to %s in %s\n", *out, time.Since(start).Round(time.Second))
+	fmt.Println("Distribute the .pk files to provers; every node needs the .vk files.")
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/ccoin/core/internal/archive"
+	"github.com/ccoin/core/internal/storage"
+	"github.com/ccoin/core/internal/threshold"
+)
+
+const archiveUsage = `Usage: ccoind archive <command> [flags]
+
+Commands:
+  keygen   Split a new custodian key among DAO-appointed custodians
+  export   Export an encrypted archive of a date range
+  share    Compute a custodian's decryption share for an archive
+  open     Decrypt an archive with enough custodian shares`
+
+// runArchive implements `ccoind archive`, the compliance escrow export.
+// Archives hold disclosure proofs and public transaction metadata only,
+// sealed so that a threshold of custodians must cooperate to read them.
+func runArchive(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, archiveUsage)
+		return errors.New("missing archive command")
+	}
+
+	switch args[0] {
+	case "keygen":
+		return runArchiveKeygen(args[1:])
+	case "export":
+		return runArchiveExport(args[1:])
+	case "share":
+		return runArchiveShare(args[1:])
+	case "open":
+		return runArchiveOpen(args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, archiveUsage)
+		return fmt.Errorf("unknown archive command %q", args[0])
+	}
+}
+
+// runArchiveKeygen writes the custodian public key and one key share per
+// custodian. The shares must be handed out and deleted from this machine.
+func runArchiveKeygen(args []string) error {
+	fs := flag.NewFlagSet("archive keygen", flag.ExitOnError)
+	custodians := fs.Int("custodians", 5, "Number of custodians")
+	t := fs.Int("threshold", 3, "Custodians required to open an archive")
+	out := fs.String("out", ".", "Output directory")
+	fs.Parse(args)
+
+	pk, shares, err := threshold.Deal(*custodians, *t)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*out, 0700); err != nil {
+		return err
+	}
+
+	data, _ := pk.MarshalBinary()
+	if err := os.WriteFile(filepath.Join(*out, "custodians.pub"), data, 0644); err != nil {
+		return err
+	}
+	for _, share := range shares {
+		data, _ := share.MarshalBinary()
+		path := filepath.Join(*out, fmt.Sprintf("custodian-%d.key", share.Index))
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Wrote custodians.pub and %d key shares to %s (%d required to open)\n", len(shares), *out, *t)
+	fmt.Println("Give each custodian their share and delete the share files from this machine.")
+	return nil
+}
+
+// runArchiveExport exports main chain transactions in a date range
+func runArchiveExport(args []string) error {
+	cfg := &Config{}
+	fs := flag.NewFlagSet("archive export", flag.ExitOnError)
+	cfg.RegisterDBFlags(fs)
+	fs.StringVar(&cfg.DataDir, "data-dir", "./data", "Data directory")
+	from := fs.String("from", "", "Start date, inclusive (YYYY-MM-DD)")
+	to := fs.String("to", "", "End date, exclusive (YYYY-MM-DD)")
+	keyPath := fs.String("custodian-key", "custodians.pub", "Custodian public key")
+	out := fs.String("out", "", "Output archive file")
+	fs.Parse(args)
+
+	start, err := time.Parse(time.DateOnly, *from)
+	if err != nil {
+		return fmt.Errorf("invalid -from: %w", err)
+	}
+	end, err := time.Parse(time.DateOnly, *to)
+	if err != nil {
+		return fmt.Errorf("invalid -to: %w", err)
+	}
+	if *out == "" {
+		*out = fmt.Sprintf("ccoin-archive-%s-%s.bin", *from, *to)
+	}
+	if err := openAtRest(cfg); err != nil {
+		return err
+	}
+	pk, err := readCustodianKey(*keyPath)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	store, err := storage.Open(ctx, storageConfig(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer store.Close()
+
+	a, err := archive.Export(ctx, store, start, end, pk)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if _, err := a.WriteTo(&buf); err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+
+	fmt.Printf("Archived %d transactions from %d blocks to %s\n", a.Header.Transactions, a.Header.Blocks, *out)
+	return nil
+}
+
+// runArchiveShare computes a custodian's decryption share, showing the
+// archive header so the custodian can check what they are opening
+func runArchiveShare(args []string) error {
+	fs := flag.NewFlagSet("archive share", flag.ExitOnError)
+	in := fs.String("in", "", "Archive file")
+	keyPath := fs.String("key", "", "Custodian key share")
+	out := fs.String("out", "", "Output share file")
+	fs.Parse(args)
+
+	a, err := readArchive(*in)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(*keyPath)
+	if err != nil {
+		return err
+	}
+	ks := &threshold.KeyShare{}
+	if err := ks.UnmarshalBinary(data); err != nil {
+		return err
+	}
+
+	fmt.Printf("Archive %s to %s: %d transactions, created %s\n",
+		a.Header.From.Format(time.DateOnly), a.Header.To.Format(time.DateOnly),
+		a.Header.Transactions, a.Header.Created.Format(time.RFC3339))
+
+	share, err := a.Share(ks)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		*out = fmt.Sprintf("%s.share-%d", *in, ks.Index)
+	}
+	data, _ = share.MarshalBinary()
+	if err := os.WriteFile(*out, data, 0600); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote decryption share %d to %s\n", ks.Index, *out)
+	return nil
+}
+
+// runArchiveOpen decrypts an archive to JSON
+func runArchiveOpen(args []string) error {
+	fs := flag.NewFlagSet("archive open", flag.ExitOnError)
+	in := fs.String("in", "", "Archive file")
+	keyPath := fs.String("custodian-key", "custodians.pub", "Custodian public key")
+	out := fs.String("out", "", "Output JSON file (default stdout)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: ccoind archive open [flags] share-file...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	a, err := readArchive(*in)
+	if err != nil {
+		return err
+	}
+	pk, err := readCustodianKey(*keyPath)
+	if err != nil {
+		return err
+	}
+
+	var shares []*threshold.DecryptionShare
+	for _, path := range fs.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		share := &threshold.DecryptionShare{}
+		if err := share.UnmarshalBinary(data); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		shares = append(shares, share)
+	}
+
+	records, err := a.Open(pk, shares)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(recordViews(records), "", "  ")
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(append(data, '\n'))
+		return err
+	}
+	return os.WriteFile(*out, data, 0600)
+}
+
+// recordView is the JSON form of an archive record
+type recordView struct {
+	Block       string           `json:"block"`
+	Height      uint64           `json:"height"`
+	Timestamp   uint64           `json:"timestamp"`
+	Tx          string           `json:"tx"`
+	Fee         uint64           `json:"fee"`
+	Anchor      string           `json:"anchor"`
+	Nullifiers  []string         `json:"nullifiers"`
+	Commitments []string         `json:"commitments"`
+	ProofType   uint8            `json:"proof_type"`
+	Disclosures []disclosureView `json:"disclosures,omitempty"`
+}
+
+// disclosureView is the JSON form of a disclosure
+type disclosureView struct {
+	Type       uint8  `json:"type"`
+	ProofType  uint8  `json:"proof_type"`
+	Proof      []byte `json:"proof"`
+	PublicData []byte `json:"public_data"`
+}
+
+func recordViews(records []archive.Record) []recordView {
+	views := make([]recordView, len(records))
+	for i, r := range records {
+		v := recordView{
+			Block:     r.BlockHash.String(),
+			Height:    r.Height,
+			Timestamp: r.Timestamp,
+			Tx:        r.TxHash.String(),
+			Fee:       r.Fee,
+			Anchor:    r.Anchor.String(),
+			ProofType: r.ProofType,
+		}
+		for _, n := range r.Nullifiers {
+			v.Nullifiers = append(v.Nullifiers, n.String())
+		}
+		for _, c := range r.Commitments {
+			v.Commitments = append(v.Commitments, c.String())
+		}
+		for _, d := range r.Disclosures {
+			v.Disclosures = append(v.Disclosures, disclosureView{
+				Type:       uint8(d.Type),
+				ProofType:  d.Proof.ProofType,
+				Proof:      d.Proof.ProofData,
+				PublicData: d.PublicData,
+			})
+		}
+		views[i] = v
+	}
+	return views
+}
+
+func readArchive(path string) (*archive.Archive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return archive.Read(f)
+}
+
+func readCustodianKey(path string) (*threshold.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pk := &threshold.PublicKey{}
+	if err := pk.UnmarshalBinary(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return pk, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ccoin/core/internal/atrest"
+	"github.com/ccoin/core/internal/config"
+	"github.com/ccoin/core/internal/storage"
+)
+
+// storagePassphraseEnv holds the at-rest encryption passphrase; without
+// it the passphrase is prompted for
+const storagePassphraseEnv = config.StoragePassphraseEnv
+
+// openAtRest derives the at-rest encryption key the flags select,
+// verifying it against the data directory
+func openAtRest(cfg *Config) error {
+	mode := atrest.Mode(cfg.EncryptAtRest)
+	if mode == "" || mode == atrest.ModeOff {
+		return nil
+	}
+
+	atCfg := &atrest.Config{Mode: mode, KeyCommand: cfg.EncryptKeyCmd}
+	if atCfg.KeyCommand == "" {
+		passphrase, err := readSecret(storagePassphraseEnv, "Storage passphrase: ")
+		if err != nil {
+			return err
+		}
+		atCfg.Passphrase = passphrase
+	}
+
+	c, err := atrest.Open(cfg.DataDir, atCfg)
+	if err != nil {
+		return fmt.Errorf("encryption at rest: %w", err)
+	}
+	cfg.atRest = c
+
+	if mode == atrest.ModeFull && cfg.DBBackend != storage.BackendPebble {
+		fmt.Println("Note: -encrypt-at-rest=full covers the wallet and mempool journal; PostgreSQL data relies on the database's own encryption")
+	}
+	return nil
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/pnet"
+
+	"github.com/ccoin/core/internal/config"
+	"github.com/ccoin/core/internal/p2p"
+	"github.com/ccoin/core/internal/reputation"
+	"github.com/ccoin/core/internal/rpc"
+	"github.com/ccoin/core/internal/storage"
+	"github.com/ccoin/core/internal/wallet"
+	"github.com/ccoin/core/pkg/common"
+	"github.com/ccoin/core/pkg/types"
+)
+
+const devnetUsage = `Usage: ccoind devnet <command> [flags]
+
+Commands:
+  up       Start a local network of nodes generating blocks and print where to reach them`
+
+// devnetNetwork is the network devnet nodes run on, so they never connect
+// to testnet peers. They also share a private network key, kept in the
+// devnet directory, so no node outside the devnet can connect to them.
+const devnetNetwork = config.NetworkDevnet
+
+// devnetPassword encrypts devnet wallets unless CCOIN_WALLET_PASSWORD is
+// set
+const devnetPassword = "devnet"
+
+// devnetMnemonicFile holds a devnet wallet's seed phrase beside it
+const devnetMnemonicFile = "mnemonic.txt"
+
+// Ports of a devnet node, as offsets from its base port. Node i's base
+// port is -base-port plus devnetPortStride*i; in containers every node
+// uses the offsets from 9000.
+const (
+	devnetPortP2P      = 0
+	devnetPortRPC      = 1
+	devnetPortJSONRPC  = 2
+	devnetPortExplorer = 3
+	devnetPortStride   = 10
+
+	devnetContainerPort = 9000
+)
+
+// devnetNode is one node of a devnet
+type devnetNode struct {
+	name    string
+	dir     string
+	port    int
+	peerID  peer.ID
+	address types.Address
+	miner   bool
+}
+
+// hostAddr returns the host address of one of the node's ports
+func (n *devnetNode) hostAddr(offset int) string {
+	return "127.0.0.1:" + strconv.Itoa(n.port+offset)
+}
+
+// runDevnet implements `ccoind devnet`, which runs a local multi-node
+// network for application development
+func runDevnet(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, devnetUsage)
+		return errors.New("missing devnet command")
+	}
+	if args[0] != "up" {
+		fmt.Fprintln(os.Stderr, devnetUsage)
+		return fmt.Errorf("unknown devnet command %q", args[0])
+	}
+	return runDevnetUp(args[1:])
+}
+
+// runDevnetUp implements `ccoind devnet up`. Every node gets an identity,
+// a wallet and an embedded database under -dir; all but node0 bootstrap
+// from node0, and the block generators mine to their wallets, so those
+// wallets fill with coins. The nodes run as child processes until
+// interrupted, or with -compose in containers that outlive the command.
+// Re-running it reuses the identities, wallets and chains in -dir.
+func runDevnetUp(args []string) error {
+	fs := flag.NewFlagSet("devnet up", flag.ExitOnError)
+	count := fs.Int("nodes", 3, "Nodes to run")
+	miners := fs.Int("miners", 0, "Nodes generating blocks, counting from node0 (0 for all)")
+	dir := fs.String("dir", "./devnet", "Directory holding a data directory per node")
+	basePort := fs.Int("base-port", 19000, "First host port; node i listens on base-port+10*i for P2P, the next ports for RPC and JSON-RPC and, on node0, the explorer")
+	compose := fs.String("compose", "", "Run the nodes in containers: write this docker-compose file and start it instead of child processes")
+	image := fs.String("image", "ccoin-node", "Image the -compose containers run, built from docker/Dockerfile.core")
+	startTimeout := fs.Duration("start-timeout", 5*time.Minute, "How long the nodes have to start serving RPC")
+	fs.Parse(args)
+
+	switch {
+	case *count < 1:
+		return errors.New("-nodes must be at least 1")
+	case *miners < 0 || *miners > *count:
+		return fmt.Errorf("-miners must be between 0 and %d", *count)
+	case *basePort < 1 || *basePort+devnetPortStride**count > 65535:
+		return errors.New("-base-port leaves no room for the nodes' ports")
+	}
+	if *miners == 0 {
+		*miners = *count
+	}
+	password := os.Getenv("CCOIN_WALLET_PASSWORD")
+	if password == "" {
+		password = devnetPassword
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	// 1. Identities and wallets
+	fmt.Println("[1/4] Identities and wallets")
+	psk, err := devnetPSK(*dir)
+	if err != nil {
+		return fmt.Errorf("private network key: %w", err)
+	}
+	nodes := make([]*devnetNode, *count)
+	for i := range nodes {
+		n, err := prepareDevnetNode(*dir, i, *basePort, password, psk)
+		if err != nil {
+			return fmt.Errorf("node%d: %w", i, err)
+		}
+		n.miner = i < *miners
+		nodes[i] = n
+		fmt.Printf("      %s: peer %s, wallet %s\n", n.name, n.peerID, common.BytesToHex(n.address[:]))
+	}
+
+	// 2. Stakes: every node's chain bonds the block generators, so their
+	// blocks are accepted everywhere
+	fmt.Println("[2/4] Stakes")
+	for _, n := range nodes {
+		if err := bondDevnetMiners(ctx, n, nodes); err != nil {
+			return fmt.Errorf("%s: %w", n.name, err)
+		}
+	}
+
+	// 3. Nodes
+	fmt.Println("[3/4] Starting nodes")
+	containerized := *compose != ""
+	for _, n := range nodes {
+		header := fmt.Sprintf("Written by ccoind devnet up on %s\nPeer ID %s", time.Now().UTC().Format(time.RFC3339), n.peerID)
+		path := filepath.Join(n.dir, config.DefaultFile)
+		if err := config.WriteFile(path, header, devnetSettings(n, nodes[0], containerized)); err != nil {
+			return fmt.Errorf("%s: failed to write config: %w", n.name, err)
+		}
+	}
+	var stop func()
+	var exited <-chan error
+	if containerized {
+		if err := writeDevnetCompose(*compose, *image, nodes); err != nil {
+			return fmt.Errorf("failed to write %s: %w", *compose, err)
+		}
+		up := exec.CommandContext(ctx, "docker", "compose", "-f", *compose, "up", "-d")
+		up.Stdout, up.Stderr = os.Stdout, os.Stderr
+		if err := up.Run(); err != nil {
+			return fmt.Errorf("docker compose up: %w", err)
+		}
+	} else {
+		var err error
+		if stop, exited, err = startDevnetProcesses(nodes); err != nil {
+			return err
+		}
+		defer stop()
+	}
+
+	// 4. Block generation
+	fmt.Println("[4/4] Starting block generation")
+	startCtx, cancelStart := context.WithTimeout(ctx, *startTimeout)
+	err = startDevnetMiners(startCtx, nodes, password, exited)
+	cancelStart()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	printDevnetSummary(nodes, *dir, password)
+	if containerized {
+		fmt.Printf("Stop the devnet with: docker compose -f %s down\n", *compose)
+		return nil
+	}
+	fmt.Println("Press Ctrl+C to stop the devnet.")
+
+	select {
+	case <-ctx.Done():
+		fmt.Println("\nStopping the devnet...")
+		return nil
+	case err := <-exited:
+		return err
+	}
+}
+
+// devnetPSK creates or reuses the private network key in dir the devnet
+// nodes share
+func devnetPSK(dir string) (pnet.PSK, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, p2p.DefaultPSKFile)
+	psk, err := p2p.LoadPSK(path)
+	if os.IsNotExist(err) {
+		psk, err = p2p.CreatePSK(path)
+	}
+	return psk, err
+}
+
+// prepareDevnetNode creates or reuses node i's identity and wallet, and
+// gives it the devnet's private network key
+func prepareDevnetNode(dir string, i, basePort int, password string, psk pnet.PSK) (*devnetNode, error) {
+	n := &devnetNode{
+		name: fmt.Sprintf("node%d", i),
+		port: basePort + devnetPortStride*i,
+	}
+	var err error
+	if n.dir, err = filepath.Abs(filepath.Join(dir, n.name)); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(n.dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	keyPath := filepath.Join(n.dir, p2p.DefaultIdentityFile)
+	key, err := p2p.LoadIdentity(keyPath)
+	if os.IsNotExist(err) {
+		key, err = p2p.CreateIdentity(keyPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("node identity: %w", err)
+	}
+	if n.peerID, err = peer.IDFromPrivateKey(key); err != nil {
+		return nil, fmt.Errorf("node identity: %w", err)
+	}
+	if err := p2p.WritePSK(filepath.Join(n.dir, p2p.DefaultPSKFile), psk); err != nil {
+		return nil, fmt.Errorf("private network key: %w", err)
+	}
+
+	if n.address, err = devnetWallet(n.dir, password); err != nil {
+		return nil, fmt.Errorf("wallet: %w", err)
+	}
+	return n, nil
+}
+
+// devnetWallet opens the wallet in dir, or creates one and keeps its seed
+// phrase beside it so the test wallet can be restored elsewhere
+func devnetWallet(dir, password string) (types.Address, error) {
+	cfg := wallet.DefaultConfig()
+	cfg.DataDir = dir
+
+	if wallet.Exists(dir) {
+		w, err := wallet.Open(cfg)
+		if err != nil {
+			return types.Address{}, err
+		}
+		return w.Address(), nil
+	}
+
+	w, mnemonic, err := wallet.New(cfg, password, "")
+	if err != nil {
+		return types.Address{}, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, devnetMnemonicFile), []byte(mnemonic+"\n"), 0600); err != nil {
+		return types.Address{}, err
+	}
+	return w.Address(), nil
+}
+
+// bondDevnetMiners stakes the minimum bond for every block generator in
+// n's database
+func bondDevnetMiners(ctx context.Context, n *devnetNode, nodes []*devnetNode) error {
+	cfg := &Config{}
+	cfg.DataDir = n.dir
+	cfg.DBBackend = storage.BackendPebble
+	store, err := storage.Open(ctx, storageConfig(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer store.Close()
+
+	bond := reputation.DefaultSlashingConfig().MinimumStake
+	for _, m := range nodes {
+		if !m.miner {
+			continue
+		}
+		if err := initMinerBond(ctx, store, m.address, bond); err != nil {
+			return fmt.Errorf("stake for %s: %w", m.name, err)
+		}
+	}
+	return nil
+}
+
+// devnetSettings returns the config of a devnet node. Nodes bootstrap from
+// boot, by container name when containerized.
+func devnetSettings(n, boot *devnetNode, containerized bool) []config.Setting {
+	dataDir, host, port := n.dir, "127.0.0.1", n.port
+	bootHost, bootPort := "/ip4/127.0.0.1", boot.port
+	if containerized {
+		dataDir, host, port = "/data", "0.0.0.0", devnetContainerPort
+		bootHost, bootPort = "/dns4/"+boot.name, devnetContainerPort
+	}
+	addr := func(offset int) string {
+		return host + ":" + strconv.Itoa(port+offset)
+	}
+
+	settings := []config.Setting{
+		{Name: "network", Value: devnetNetwork},
+		{Name: "data-dir", Value: dataDir},
+		{Name: "node-key", Value: filepath.Join(dataDir, p2p.DefaultIdentityFile)},
+		{Name: "listen", Value: fmt.Sprintf("/ip4/%s/tcp/%d", host, port+devnetPortP2P)},
+		{Name: "rpc", Value: addr(devnetPortRPC)},
+		{Name: "jsonrpc", Value: addr(devnetPortJSONRPC)},
+		{Name: "db-backend", Value: storage.BackendPebble},
+		{Name: "admin", Value: ""},
+		{Name: "mine", Value: strconv.FormatBool(n.miner)},
+		{Name: "nat-port-map", Value: "false"},
+	}
+	if n != boot {
+		settings = append(settings, config.Setting{
+			Name:  "bootstrap",
+			Value: fmt.Sprintf("%s/tcp/%d/p2p/%s", bootHost, bootPort+devnetPortP2P, boot.peerID),
+		})
+	} else {
+		settings = append(settings, config.Setting{Name: "explorer-addr", Value: addr(devnetPortExplorer)})
+	}
+	return settings
+}
+
+// writeDevnetCompose writes a docker-compose file running each node in a
+// container of image, with its data directory mounted at /data and its
+// ports published on the host's loopback interface
+func writeDevnetCompose(path, image string, nodes []*devnetNode) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Written by ccoind devnet up on %s\n", time.Now().UTC().Format(time.RFC3339))
+	b.WriteString("services:\n")
+	for _, n := range nodes {
+		ports := []int{devnetPortP2P, devnetPortRPC, devnetPortJSONRPC}
+		if n == nodes[0] {
+			ports = append(ports, devnetPortExplorer)
+		}
+
+		fmt.Fprintf(&b, "  %s:\n", n.name)
+		fmt.Fprintf(&b, "    image: %s\n", image)
+		// The data directory belongs to the user running devnet up
+		fmt.Fprintf(&b, "    user: \"%d:%d\"\n", os.Getuid(), os.Getgid())
+		fmt.Fprintf(&b, "    command: [\"-config\", \"/data/%s\"]\n", config.DefaultFile)
+		if n != nodes[0] {
+			fmt.Fprintf(&b, "    depends_on:\n      - %s\n", nodes[0].name)
+		}
+		fmt.Fprintf(&b, "    volumes:\n      - %s:/data\n", n.dir)
+		b.WriteString("    ports:\n")
+		for _, offset := range ports {
+			fmt.Fprintf(&b, "      - \"%s:%d\"\n", n.hostAddr(offset), devnetContainerPort+offset)
+		}
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// startDevnetProcesses starts each node as a child process logging to
+// <data-dir>/ccoind.log. Stop interrupts them and waits for them to exit;
+// a node exiting before then is reported on exited.
+func startDevnetProcesses(nodes []*devnetNode) (stop func(), exited <-chan error, err error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var cmds []*exec.Cmd
+	done := make(chan struct{}, len(nodes))
+	errs := make(chan error, len(nodes))
+	stop = func() {
+		for _, cmd := range cmds {
+			cmd.Process.Signal(os.Interrupt)
+		}
+		for range cmds {
+			<-done
+		}
+	}
+
+	for _, n := range nodes {
+		logPath := filepath.Join(n.dir, "ccoind.log")
+		logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			stop()
+			return nil, nil, err
+		}
+		cmd := exec.Command(exe, "-config", filepath.Join(n.dir, config.DefaultFile))
+		cmd.Stdout, cmd.Stderr = logFile, logFile
+		detachDevnetProcess(cmd)
+		if err := cmd.Start(); err != nil {
+			logFile.Close()
+			stop()
+			return nil, nil, fmt.Errorf("failed to start %s: %w", n.name, err)
+		}
+		cmds = append(cmds, cmd)
+
+		name := n.name
+		go func() {
+			err := cmd.Wait()
+			logFile.Close()
+			errs <- fmt.Errorf("%s exited (%v); see %s", name, err, logPath)
+			done <- struct{}{}
+		}()
+		fmt.Printf("      %s: pid %d, log %s\n", n.name, cmd.Process.Pid, logPath)
+	}
+	return stop, errs, nil
+}
+
+// startDevnetMiners waits for every node's RPC server, then unlocks the
+// block generators' wallets and starts them mining. A node exiting on
+// exited, which may be nil, fails the start.
+func startDevnetMiners(ctx context.Context, nodes []*devnetNode, password string, exited <-chan error) error {
+	for _, n := range nodes {
+		client, err := waitDevnetRPC(ctx, n.hostAddr(devnetPortRPC), exited)
+		if err != nil {
+			return fmt.Errorf("%s: %w", n.name, err)
+		}
+		if n.miner {
+			if _, err = client.UnlockWallet(ctx, password, 0); err == nil {
+				_, err = client.StartMining(ctx)
+			}
+		}
+		client.Close()
+		if err != nil {
+			return fmt.Errorf("%s: failed to start mining: %w", n.name, err)
+		}
+		role := "relaying"
+		if n.miner {
+			role = "generating blocks"
+		}
+		fmt.Printf("      %s: %s\n", n.name, role)
+	}
+	return nil
+}
+
+// waitDevnetRPC polls a node's RPC server until it answers
+func waitDevnetRPC(ctx context.Context, addr string, exited <-chan error) (*rpc.Client, error) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		client, err := rpc.Dial(ctx, addr)
+		if err == nil {
+			if _, err = client.GetStatus(ctx); err == nil {
+				return client, nil
+			}
+			client.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("RPC server at %s did not start: %w", addr, err)
+		case err := <-exited:
+			return nil, err
+		case <-ticker.C:
+		}
+	}
+}
+
+// printDevnetSummary prints where to reach each node and its wallet
+func printDevnetSummary(nodes []*devnetNode, dir, password string) {
+	fmt.Printf("Devnet up: %d node(s) on network %q\n\n", len(nodes), devnetNetwork)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NODE\tP2P\tRPC\tJSON-RPC\tWALLET\tROLE")
+	for _, n := range nodes {
+		role := "relay"
+		if n.miner {
+			role = "block generator"
+		}
+		fmt.Fprintf(tw, "%s\t/ip4/127.0.0.1/tcp/%d/p2p/%s\t%s\thttp://%s\t%s\t%s\n",
+			n.name, n.port+devnetPortP2P, n.peerID, n.hostAddr(devnetPortRPC), n.hostAddr(devnetPortJSONRPC),
+			common.BytesToHex(n.address[:]), role)
+	}
+	tw.Flush()
+
+	fmt.Println()
+	fmt.Printf("Dashboard:  http://%s/api/v1/status (WebSocket feed at /api/v1/ws)\n", nodes[0].hostAddr(devnetPortExplorer))
+	fmt.Printf("CLI:        CCOIN_RPC=%s ccoin-cli status\n", nodes[0].hostAddr(devnetPortRPC))
+	fmt.Printf("Wallets:    password %q, seed phrases in %s/node*/%s\n", password, dir, devnetMnemonicFile)
+	fmt.Println("            Block generators' wallets are funded as they mine")
+}
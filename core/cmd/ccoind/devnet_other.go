@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+import "os/exec"
+
+// detachDevnetProcess leaves the node in devnet up's process group where
+// process groups are not supported
+func detachDevnetProcess(cmd *exec.Cmd) {}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ccoin/core/internal/config"
+	"github.com/ccoin/core/internal/p2p"
+	"github.com/ccoin/core/internal/reputation"
+	"github.com/ccoin/core/internal/storage"
+)
+
+// Test that bad devnet commands and flags are refused before anything is
+// created
+func TestDevnetArgs(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "devnet")
+
+	for _, args := range [][]string{
+		nil,
+		{"down"},
+		{"up", "-dir", dir, "-nodes", "0"},
+		{"up", "-dir", dir, "-nodes", "2", "-miners", "3"},
+		{"up", "-dir", dir, "-miners", "-1"},
+		{"up", "-dir", dir, "-base-port", "65530"},
+	} {
+		if err := runDevnet(args); err == nil {
+			t.Errorf("runDevnet(%q) succeeded", args)
+		}
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Refused runs created %s: %v", dir, err)
+	}
+}
+
+// prepareDevnet prepares count nodes in dir, the first miners of them
+// generating blocks
+func prepareDevnet(t *testing.T, dir string, count, miners int) []*devnetNode {
+	t.Helper()
+	psk, err := devnetPSK(dir)
+	if err != nil {
+		t.Fatalf("devnetPSK failed: %v", err)
+	}
+	nodes := make([]*devnetNode, count)
+	for i := range nodes {
+		if nodes[i], err = prepareDevnetNode(dir, i, 19000, devnetPassword, psk); err != nil {
+			t.Fatalf("prepareDevnetNode(%d) failed: %v", i, err)
+		}
+		nodes[i].miner = i < miners
+	}
+	return nodes
+}
+
+// Test that devnet nodes get distinct identities, wallets and ports that a
+// second run reuses, and share one private network key
+func TestDevnetNodes(t *testing.T) {
+	dir := t.TempDir()
+	nodes := prepareDevnet(t, dir, 2, 1)
+
+	a, b := nodes[0], nodes[1]
+	if a.peerID == b.peerID || a.address == b.address {
+		t.Error("Nodes share an identity or wallet")
+	}
+	if a.port != 19000 || b.port != 19000+devnetPortStride || b.hostAddr(devnetPortRPC) != "127.0.0.1:19011" {
+		t.Errorf("Ports %d and %d, RPC %s", a.port, b.port, b.hostAddr(devnetPortRPC))
+	}
+	if b.dir != filepath.Join(dir, "node1") {
+		t.Errorf("node1 data directory is %s", b.dir)
+	}
+	if _, err := os.Stat(filepath.Join(b.dir, devnetMnemonicFile)); err != nil {
+		t.Errorf("Seed phrase not kept: %v", err)
+	}
+
+	shared, _ := p2p.LoadPSK(filepath.Join(dir, p2p.DefaultPSKFile))
+	for _, n := range nodes {
+		psk, err := p2p.LoadPSK(filepath.Join(n.dir, p2p.DefaultPSKFile))
+		if err != nil || string(psk) != string(shared) {
+			t.Errorf("%s has another private network key: %v", n.name, err)
+		}
+	}
+
+	again := prepareDevnet(t, dir, 2, 1)
+	for i := range nodes {
+		if again[i].peerID != nodes[i].peerID || again[i].address != nodes[i].address {
+			t.Errorf("%s changed identity or wallet on a second run", nodes[i].name)
+		}
+	}
+}
+
+// loadDevnetConfig reads a written devnet config as ccoind would
+func loadDevnetConfig(t *testing.T, n *devnetNode) *Config {
+	t.Helper()
+	cfg := &Config{}
+	fs := flag.NewFlagSet("ccoind", flag.ContinueOnError)
+	cfg.RegisterFlags(fs)
+	if err := config.ApplyFile(fs, filepath.Join(n.dir, config.DefaultFile)); err != nil {
+		t.Fatalf("%s: ApplyFile failed: %v", n.name, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("%s: invalid config: %v", n.name, err)
+	}
+	return cfg
+}
+
+// Test that node configs load, bootstrap every node from node0 and run
+// only the block generators as miners
+func TestDevnetSettings(t *testing.T) {
+	nodes := prepareDevnet(t, t.TempDir(), 2, 1)
+	for _, n := range nodes {
+		if err := config.WriteFile(filepath.Join(n.dir, config.DefaultFile), "test", devnetSettings(n, nodes[0], false)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	boot := loadDevnetConfig(t, nodes[0])
+	if boot.Network != devnetNetwork || boot.DataDir != nodes[0].dir || boot.DBBackend != storage.BackendPebble {
+		t.Errorf("node0 config = network %s, data dir %s, backend %s", boot.Network, boot.DataDir, boot.DBBackend)
+	}
+	if !boot.MinerEnabled || boot.BootstrapPeers != "" || boot.ExplorerAddr != "127.0.0.1:19003" || boot.AdminAddr != "" {
+		t.Errorf("node0 config = mine %v, bootstrap %q, explorer %q, admin %q", boot.MinerEnabled, boot.BootstrapPeers, boot.ExplorerAddr, boot.AdminAddr)
+	}
+
+	relay := loadDevnetConfig(t, nodes[1])
+	wantBootstrap := "/ip4/127.0.0.1/tcp/19000/p2p/" + nodes[0].peerID.String()
+	if relay.MinerEnabled || relay.BootstrapPeers != wantBootstrap || relay.ExplorerAddr != "" {
+		t.Errorf("node1 config = mine %v, bootstrap %q, explorer %q", relay.MinerEnabled, relay.BootstrapPeers, relay.ExplorerAddr)
+	}
+	if relay.ListenAddr != "/ip4/127.0.0.1/tcp/19010" || relay.RPCAddr != "127.0.0.1:19011" || relay.JSONRPCAddr != "127.0.0.1:19012" {
+		t.Errorf("node1 listens on %s, RPC %s, JSON-RPC %s", relay.ListenAddr, relay.RPCAddr, relay.JSONRPCAddr)
+	}
+
+	// In containers nodes find node0 by name and all use the same ports
+	values := map[string]string{}
+	for _, s := range devnetSettings(nodes[1], nodes[0], true) {
+		values[s.Name] = s.Value
+	}
+	if values["bootstrap"] != "/dns4/node0/tcp/9000/p2p/"+nodes[0].peerID.String() ||
+		values["listen"] != "/ip4/0.0.0.0/tcp/9000" || values["data-dir"] != "/data" {
+		t.Errorf("Containerized node1 settings = %v", values)
+	}
+}
+
+// Test that the compose file publishes each node's ports and starts node0
+// first
+func TestDevnetCompose(t *testing.T) {
+	nodes := prepareDevnet(t, t.TempDir(), 2, 2)
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	if err := writeDevnetCompose(path, "ccoin-node:test", nodes); err != nil {
+		t.Fatalf("writeDevnetCompose failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compose := string(data)
+
+	for _, want := range []string{
+		"  node0:\n    image: ccoin-node:test\n",
+		"      - \"127.0.0.1:19003:9003\"\n",
+		"  node1:\n",
+		"    depends_on:\n      - node0\n",
+		"      - " + nodes[1].dir + ":/data\n",
+		"      - \"127.0.0.1:19012:9002\"\n",
+	} {
+		if !strings.Contains(compose, want) {
+			t.Errorf("Compose file lacks %q:\n%s", want, compose)
+		}
+	}
+	if strings.Contains(compose, "19013") {
+		t.Error("Explorer published for a node other than node0")
+	}
+}
+
+// Test that every node's chain bonds the block generators once
+func TestDevnetBonds(t *testing.T) {
+	ctx := context.Background()
+	nodes := prepareDevnet(t, t.TempDir(), 2, 1)
+
+	for i := 0; i < 2; i++ {
+		if err := bondDevnetMiners(ctx, nodes[1], nodes); err != nil {
+			t.Fatalf("bondDevnetMiners failed: %v", err)
+		}
+	}
+
+	cfg := &Config{}
+	cfg.DataDir = nodes[1].dir
+	cfg.DBBackend = storage.BackendPebble
+	store, err := storage.Open(ctx, storageConfig(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	stakes := reputation.NewSlashingManager(store, nil)
+	bond := reputation.DefaultSlashingConfig().MinimumStake
+	for _, n := range nodes {
+		stake, err := stakes.LoadStake(ctx, n.address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var have uint64
+		if stake != nil {
+			have = stake.AvailableStake
+		}
+		var want uint64
+		if n.miner {
+			want = bond
+		}
+		if have != want {
+			t.Errorf("%s staked %d, want %d", n.name, have, want)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachDevnetProcess puts a devnet node in its own process group, so a
+// Ctrl+C in the terminal reaches only devnet up, which then stops the
+// node once rather than the node seeing two interrupts and exiting
+// without draining
+func detachDevnetProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
@@ -0,0 +1,488 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/ccoin/core/internal/atrest"
+	"github.com/ccoin/core/internal/config"
+	"github.com/ccoin/core/internal/dag"
+	"github.com/ccoin/core/internal/p2p"
+	"github.com/ccoin/core/internal/reputation"
+	"github.com/ccoin/core/internal/storage"
+	"github.com/ccoin/core/internal/wallet"
+	"github.com/ccoin/core/pkg/common"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// maxClockOffset is the clock error init-miner accepts, well inside the
+// two minutes a block timestamp may run ahead of validators' clocks
+const maxClockOffset = 10 * time.Second
+
+// peerWait is how long the connectivity check waits for a first peer
+const peerWait = 20 * time.Second
+
+// stdin is shared by prompts and secrets so neither loses buffered input
+var stdin = bufio.NewReader(os.Stdin)
+
+// runInitMiner implements `ccoind init-miner`. It prompts for anything not
+// given as a flag (unless -yes), and every step can be re-run: existing
+// identities, wallets and stakes are reused rather than replaced.
+func runInitMiner(args []string) error {
+	cfg := &Config{}
+	fs := flag.NewFlagSet("init-miner", flag.ExitOnError)
+	cfg.RegisterDBFlags(fs)
+	fs.StringVar(&cfg.DataDir, "data-dir", "./data", "Data directory")
+	fs.StringVar(&cfg.Network, "network", config.NetworkTestnet, "Network to mine on: mainnet, testnet, devnet, or custom with -network-id")
+	fs.UintVar(&cfg.NetworkID, "network-id", 0, "Network ID of a -network=custom network")
+	fs.StringVar(&cfg.PSK, "psk", "", "Private network key file (default: <data-dir>/"+p2p.DefaultPSKFile+" if present)")
+	fs.StringVar(&cfg.ListenAddr, "listen", "/ip4/0.0.0.0/tcp/9000", "P2P listen address")
+	fs.StringVar(&cfg.BootstrapPeers, "bootstrap", "", "Comma-separated bootstrap peer multiaddrs")
+	fs.StringVar(&cfg.RPCAddr, "rpc", "127.0.0.1:9001", "RPC server address")
+	bond := fs.Uint64("bond", reputation.DefaultSlashingConfig().MinimumStake, "Stake to bond; at least the minimum")
+	cpus := fs.Int("cpus", runtime.NumCPU(), "CPU cores offered for tasks")
+	memoryMB := fs.Uint64("memory-mb", systemMemoryMB(), "Memory offered for tasks, in MB")
+	gpus := fs.String("gpus", "", "Comma-separated GPU names (default: detected with nvidia-smi)")
+	gpuMemoryMB := fs.Uint64("gpu-memory-mb", 0, "Memory of the smallest GPU, in MB (default: detected)")
+	ntpServer := fs.String("ntp", "pool.ntp.org:123", "NTP server the clock is checked against")
+	out := fs.String("out", "", "Config file to write, TOML or YAML by extension (default: <data-dir>/"+config.DefaultFile+")")
+	restore := fs.Bool("restore", false, "Restore the wallet from a seed phrase instead of creating one")
+	yes := fs.Bool("yes", false, "Do not prompt; secrets are read from CCOIN_WALLET_PASSWORD and CCOIN_WALLET_MNEMONIC")
+	fs.Parse(args)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	p := newPrompter(fs, !*yes)
+	p.ask("network", "Network")
+	if cfg.Network == config.NetworkCustom {
+		p.ask("network-id", "Network ID")
+	}
+	if _, ok := config.LookupNetwork(cfg.Network); !ok {
+		return fmt.Errorf("unknown network %q", cfg.Network)
+	}
+	if cfg.Network == config.NetworkCustom && cfg.NetworkID == 0 {
+		return errors.New("-network-id is required with -network=custom")
+	}
+	if err := config.ApplyNetwork(fs); err != nil {
+		return err
+	}
+	p.ask("data-dir", "Data directory")
+	p.ask("bootstrap", "Bootstrap peers (comma-separated multiaddrs)")
+	p.ask("db-backend", "Storage backend (postgres or pebble)")
+	p.ask("db-host", "PostgreSQL host")
+	p.ask("db-name", "PostgreSQL database")
+	p.ask("bond", "Stake to bond")
+
+	if err := os.MkdirAll(cfg.DataDir, 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	var warnings []string
+
+	// 1. Node identity
+	fmt.Println("[1/7] Node identity")
+	keyPath := filepath.Join(cfg.DataDir, p2p.DefaultIdentityFile)
+	key, err := p2p.LoadIdentity(keyPath)
+	if os.IsNotExist(err) {
+		key, err = p2p.CreateIdentity(keyPath)
+	}
+	if err != nil {
+		return fmt.Errorf("node identity: %w", err)
+	}
+	peerID, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("node identity: %w", err)
+	}
+	fmt.Printf("      Peer ID: %s\n", peerID)
+
+	// 2. Wallet and payout address
+	fmt.Println("[2/7] Wallet")
+	if err := openAtRest(cfg); err != nil {
+		return err
+	}
+	payout, err := initMinerWallet(cfg.DataDir, cfg.atRest, *restore)
+	if err != nil {
+		return fmt.Errorf("wallet: %w", err)
+	}
+	fmt.Printf("      Payout address: %s\n", common.BytesToHex(payout[:]))
+
+	// 3. Database
+	fmt.Println("[3/7] Database")
+	store, err := storage.Open(ctx, storageConfig(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer store.Close()
+	fmt.Println("      Connected")
+
+	// 4. Bond
+	fmt.Println("[4/7] Stake")
+	if err := initMinerBond(ctx, store, payout, *bond); err != nil {
+		return fmt.Errorf("stake: %w", err)
+	}
+
+	// 5. Compute capabilities
+	fmt.Println("[5/7] Compute capabilities")
+	caps := &types.ComputeCapabilities{CPUs: *cpus, MemoryMB: *memoryMB, GPUMemoryMB: *gpuMemoryMB}
+	if *gpus != "" {
+		caps.GPUs = splitFlagList(*gpus)
+	} else {
+		caps.GPUs, caps.GPUMemoryMB = detectGPUs()
+	}
+	if caps.CPUs <= 0 {
+		return errors.New("at least one CPU must be offered")
+	}
+	if err := store.SaveCapabilities(ctx, payout, caps); err != nil {
+		return fmt.Errorf("failed to register capabilities: %w", err)
+	}
+	fmt.Printf("      %d CPU(s), %d MB memory", caps.CPUs, caps.MemoryMB)
+	if len(caps.GPUs) > 0 {
+		fmt.Printf(", GPUs: %s (%d MB)", strings.Join(caps.GPUs, ", "), caps.GPUMemoryMB)
+	}
+	fmt.Println()
+
+	// 6. Connectivity
+	fmt.Println("[6/7] Connectivity")
+	peers, err := checkConnectivity(ctx, cfg, key)
+	switch {
+	case err != nil:
+		warnings = append(warnings, fmt.Sprintf("P2P node failed to start on %s: %v", cfg.ListenAddr, err))
+	case peers == 0:
+		warnings = append(warnings, "no peers reached; check -bootstrap and that the listen port is reachable")
+	default:
+		fmt.Printf("      Connected to %d peer(s)\n", peers)
+	}
+
+	// 7. Clock
+	fmt.Println("[7/7] Clock")
+	offset, err := clockOffset(*ntpServer, 5*time.Second)
+	switch {
+	case err != nil:
+		warnings = append(warnings, fmt.Sprintf("could not check the clock against %s: %v", *ntpServer, err))
+	case offset > maxClockOffset || offset < -maxClockOffset:
+		warnings = append(warnings, fmt.Sprintf("clock is off by %s; blocks may be rejected until it is synchronized", offset.Round(time.Millisecond)))
+	default:
+		fmt.Printf("      Offset %s\n", offset.Round(time.Millisecond))
+	}
+
+	// Write the config
+	path := *out
+	if path == "" {
+		path = filepath.Join(cfg.DataDir, config.DefaultFile)
+	}
+	dataDir, err := filepath.Abs(cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	settings := []config.Setting{
+		{Name: "network", Value: cfg.Network},
+		{Name: "data-dir", Value: dataDir},
+		{Name: "node-key", Value: filepath.Join(dataDir, p2p.DefaultIdentityFile)},
+		{Name: "listen", Value: cfg.ListenAddr},
+		{Name: "bootstrap", Value: cfg.BootstrapPeers},
+		{Name: "rpc", Value: cfg.RPCAddr},
+		{Name: "db-backend", Value: cfg.DBBackend},
+		{Name: "db-host", Value: cfg.DBHost},
+		{Name: "db-port", Value: strconv.Itoa(cfg.DBPort)},
+		{Name: "db-user", Value: cfg.DBUser},
+		{Name: "db-name", Value: cfg.DBName},
+		{Name: "mine", Value: "true"},
+		{Name: "miner-address", Value: common.BytesToHex(payout[:])},
+	}
+	if cfg.DBPassword != "" {
+		settings = append(settings, config.Setting{Name: "db-password", Value: cfg.DBPassword})
+	}
+	if cfg.NetworkID != 0 {
+		settings = append(settings, config.Setting{Name: "network-id", Value: strconv.FormatUint(uint64(cfg.NetworkID), 10)})
+	}
+	if cfg.PSK != "" {
+		settings = append(settings, config.Setting{Name: "psk", Value: cfg.PSK})
+	}
+	if cfg.atRest != nil {
+		settings = append(settings, config.Setting{Name: "encrypt-at-rest", Value: cfg.EncryptAtRest})
+		if cfg.EncryptKeyCmd != "" {
+			settings = append(settings, config.Setting{Name: "encrypt-key-cmd", Value: cfg.EncryptKeyCmd})
+		}
+	}
+	header := fmt.Sprintf("Written by ccoind init-miner on %s\nPeer ID %s", time.Now().UTC().Format(time.RFC3339), peerID)
+	if err := config.WriteFile(path, header, settings); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	fmt.Println()
+	for _, w := range warnings {
+		fmt.Printf("Warning: %s\n", w)
+	}
+	fmt.Printf("Config written to %s\n", path)
+	fmt.Printf("Start mining with: ccoind -config %s\n", path)
+	return nil
+}
+
+// initMinerWallet opens the wallet in dataDir, or creates or restores one
+// encrypted at rest with c, and returns its primary address
+func initMinerWallet(dataDir string, c *atrest.Cipher, restore bool) (types.Address, error) {
+	cfg := wallet.DefaultConfig()
+	cfg.DataDir = dataDir
+	cfg.AtRest = c
+
+	if wallet.Exists(dataDir) {
+		w, err := wallet.Open(cfg)
+		if err != nil {
+			return types.Address{}, err
+		}
+		fmt.Println("      Using the existing wallet")
+		return w.Address(), nil
+	}
+
+	var mnemonic string
+	if restore {
+		m, err := readSecret("CCOIN_WALLET_MNEMONIC", "      Seed phrase: ")
+		if err != nil {
+			return types.Address{}, err
+		}
+		mnemonic = m
+	}
+	password, err := readSecret("CCOIN_WALLET_PASSWORD", "      Wallet password: ")
+	if err != nil {
+		return types.Address{}, err
+	}
+	if password == "" {
+		return types.Address{}, errors.New("a wallet password is required")
+	}
+
+	var w *wallet.Wallet
+	if mnemonic == "" {
+		w, mnemonic, err = wallet.New(cfg, password, "")
+		if err == nil {
+			fmt.Println("      Save your seed phrase; it is the only way to recover this wallet:")
+			fmt.Printf("        %s\n", mnemonic)
+		}
+	} else {
+		w, err = wallet.Restore(cfg, mnemonic, password, "")
+	}
+	if err != nil {
+		return types.Address{}, err
+	}
+	return w.Address(), nil
+}
+
+// initMinerBond tops the miner's stake up to bond
+func initMinerBond(ctx context.Context, store storage.Store, addr types.Address, bond uint64) error {
+	stakes := reputation.NewSlashingManager(store, nil)
+	if bond < stakes.MinimumStake() {
+		return fmt.Errorf("bond %d is below the minimum stake of %d", bond, stakes.MinimumStake())
+	}
+
+	stake, err := stakes.LoadStake(ctx, addr)
+	if err != nil {
+		return err
+	}
+	var have uint64
+	if stake != nil {
+		have = stake.AvailableStake
+	}
+	if have >= bond {
+		fmt.Printf("      Already staked %d\n", have)
+		return nil
+	}
+
+	blockDAG := dag.NewDAG(store, nil)
+	if err := blockDAG.Initialize(ctx); err != nil {
+		return fmt.Errorf("failed to initialize DAG: %w", err)
+	}
+	if err := stakes.Stake(ctx, addr, bond-have, blockDAG.GetHeight()); err != nil {
+		return err
+	}
+	fmt.Printf("      Staked %d (locked until block %d)\n", bond, stakes.GetStakeInfo(addr).LockedUntilBlock)
+	return nil
+}
+
+// checkConnectivity starts a P2P node with the miner's identity and
+// returns how many peers it reached
+func checkConnectivity(ctx context.Context, cfg *Config, key crypto.PrivKey) (int, error) {
+	p2pConfig := p2p.DefaultConfig()
+	p2pConfig.ListenAddrs = []string{cfg.ListenAddr}
+	p2pConfig.BootstrapPeers = splitFlagList(cfg.BootstrapPeers)
+	p2pConfig.PrivateKey = key
+	if err := applyNetwork(p2pConfig, cfg); err != nil {
+		return 0, err
+	}
+
+	node, err := p2p.NewNode(ctx, p2pConfig)
+	if err != nil {
+		return 0, err
+	}
+	defer node.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, peerWait)
+	defer cancel()
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for node.PeerCount() == 0 {
+		select {
+		case <-ctx.Done():
+			return 0, nil
+		case <-ticker.C:
+		}
+	}
+	return node.PeerCount(), nil
+}
+
+// clockOffset asks an NTP server for the time (SNTP, RFC 4330) and returns
+// how far the local clock is ahead of it
+func clockOffset(server string, timeout time.Duration) (time.Duration, error) {
+	conn, err := net.DialTimeout("udp", server, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	req := make([]byte, 48)
+	req[0] = 0x1b // version 3, client mode
+	sent := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return 0, err
+	}
+	received := time.Now()
+	if n < 48 {
+		return 0, errors.New("short NTP response")
+	}
+
+	// Transmit timestamp: seconds since 1900 and a 32-bit fraction
+	const ntpEpochOffset = 2208988800
+	secs := binary.BigEndian.Uint32(resp[40:44])
+	frac := binary.BigEndian.Uint32(resp[44:48])
+	if secs == 0 {
+		return 0, errors.New("NTP server sent no time")
+	}
+	serverTime := time.Unix(int64(secs)-ntpEpochOffset, int64(frac)*1e9>>32)
+
+	// Assume the reply left the server halfway through the round trip
+	local := sent.Add(received.Sub(sent) / 2)
+	return local.Sub(serverTime), nil
+}
+
+// systemMemoryMB returns the machine's memory from /proc/meminfo, or 0
+func systemMemoryMB() uint64 {
+	data, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, _ := strconv.ParseUint(fields[1], 10, 64)
+			return kb / 1024
+		}
+	}
+	return 0
+}
+
+// detectGPUs lists NVIDIA GPUs and the smallest one's memory. Machines
+// without nvidia-smi report none.
+func detectGPUs() ([]string, uint64) {
+	out, err := exec.Command("nvidia-smi", "--query-gpu=name,memory.total", "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return nil, 0
+	}
+
+	var names []string
+	var minMemory uint64
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		name, memory, ok := strings.Cut(line, ",")
+		if !ok {
+			continue
+		}
+		names = append(names, strings.TrimSpace(name))
+		mb, _ := strconv.ParseUint(strings.TrimSpace(memory), 10, 64)
+		if minMemory == 0 || mb < minMemory {
+			minMemory = mb
+		}
+	}
+	return names, minMemory
+}
+
+// splitFlagList splits a comma-separated flag value
+func splitFlagList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// readSecret reads a secret from env, or prompts for it
+func readSecret(env, prompt string) (string, error) {
+	if v := os.Getenv(env); v != "" {
+		return v, nil
+	}
+
+	fmt.Print(prompt)
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// prompter asks for flag values that were not given on the command line
+type prompter struct {
+	fs       *flag.FlagSet
+	enabled  bool
+	explicit map[string]bool
+}
+
+func newPrompter(fs *flag.FlagSet, enabled bool) *prompter {
+	p := &prompter{fs: fs, enabled: enabled, explicit: make(map[string]bool)}
+	fs.Visit(func(f *flag.Flag) { p.explicit[f.Name] = true })
+	return p
+}
+
+// ask prompts for flag name, keeping its current value on an empty answer
+func (p *prompter) ask(name, label string) {
+	if !p.enabled || p.explicit[name] {
+		return
+	}
+	f := p.fs.Lookup(name)
+	for {
+		fmt.Printf("%s [%s]: ", label, f.Value.String())
+		line, err := stdin.ReadString('\n')
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			if err != nil {
+				p.enabled = false
+			}
+			return
+		}
+		if err := p.fs.Set(name, answer); err != nil {
+			fmt.Printf("  %v\n", err)
+			continue
+		}
+		return
+	}
+}
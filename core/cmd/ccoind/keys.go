@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/ccoin/core/internal/config"
+	"github.com/ccoin/core/internal/keys"
+	"github.com/ccoin/core/internal/p2p"
+	"github.com/ccoin/core/pkg/common"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// keyProviderTokenEnv holds the bearer token of a signing service
+const keyProviderTokenEnv = config.KeyProviderTokenEnv
+
+const keysUsage = `Usage: ccoind keys <command> [flags]
+
+Commands:
+  show     Print the peer ID and miner address of the provider's keys
+  rotate   Replace the node or miner key with a new one
+  serve    Serve the provider's keys to nodes over the signing service protocol`
+
+// openKeyProvider opens the key provider spec names: file:<dir> for key
+// files, or the http(s) URL of a signing service in front of a KMS or
+// HSM. An empty spec means none.
+func openKeyProvider(spec string) (keys.Provider, error) {
+	switch {
+	case spec == "":
+		return nil, nil
+	case strings.HasPrefix(spec, "file:"):
+		return keys.NewFileProvider(strings.TrimPrefix(spec, "file:")), nil
+	case strings.HasPrefix(spec, "https://"), strings.HasPrefix(spec, "http://"):
+		return keys.NewRemoteProvider(spec, os.Getenv(keyProviderTokenEnv)), nil
+	default:
+		return nil, fmt.Errorf("unknown key provider %q (want file:<dir> or a signing service URL)", spec)
+	}
+}
+
+// runKeys implements `ccoind keys`, which manages the node and miner keys
+// of a key provider, by default the key files of the data directory
+func runKeys(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, keysUsage)
+		return errors.New("missing keys command")
+	}
+
+	fs := flag.NewFlagSet("keys "+args[0], flag.ExitOnError)
+	dataDir := fs.String("data-dir", "./data", "Data directory")
+	spec := fs.String("key-provider", "", "Key provider (default: file:<data-dir>)")
+	listen := fs.String("listen", "127.0.0.1:9010", "Address serve listens on")
+	fs.Parse(args[1:])
+	if *spec == "" {
+		*spec = "file:" + *dataDir
+	}
+	provider, err := openKeyProvider(*spec)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "show":
+		for _, name := range []string{keys.KeyNode, keys.KeyMiner} {
+			s, err := provider.Signer(ctx, name)
+			if errors.Is(err, keys.ErrKeyNotFound) {
+				fmt.Printf("%-6s not set\n", name)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := printKey(name, s); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "rotate":
+		if fs.NArg() != 1 || (fs.Arg(0) != keys.KeyNode && fs.Arg(0) != keys.KeyMiner) {
+			return errors.New("usage: ccoind keys rotate [flags] <node|miner>")
+		}
+		name := fs.Arg(0)
+		if old, err := provider.Signer(ctx, name); err == nil {
+			fmt.Print("Old ")
+			printKey(name, old)
+		}
+		s, err := provider.Rotate(ctx, name)
+		if err != nil {
+			return err
+		}
+		fmt.Print("New ")
+		printKey(name, s)
+		if name == keys.KeyNode {
+			fmt.Println("Restart the node; peers that bootstrap from it need the new peer ID.")
+		} else {
+			fmt.Println("Restart the node. The miner address changed: bond stake and register capabilities under the new address.")
+		}
+		return nil
+
+	case "serve":
+		token := os.Getenv(keyProviderTokenEnv)
+		if token == "" {
+			return fmt.Errorf("set %s to the token nodes authenticate with", keyProviderTokenEnv)
+		}
+		server := &http.Server{
+			Addr:              *listen,
+			Handler:           keys.Handler(provider, token),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		fmt.Printf("Serving keys from %s on %s\n", *spec, *listen)
+		return server.ListenAndServe()
+
+	default:
+		fmt.Fprintln(os.Stderr, keysUsage)
+		return fmt.Errorf("unknown keys command %q", args[0])
+	}
+}
+
+// printKey prints what a key identifies: the peer ID of the node key and
+// the address of the miner key
+func printKey(name string, s keys.Signer) error {
+	if name == keys.KeyNode {
+		key, err := p2p.SignerIdentity(s)
+		if err != nil {
+			return err
+		}
+		id, err := peer.IDFromPrivateKey(key)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("node   peer ID %s\n", id)
+		return nil
+	}
+	addr := types.AddressFromPublicKey(s.Public())
+	fmt.Printf("%-6s address %s\n", name, common.BytesToHex(addr[:]))
+	return nil
+}
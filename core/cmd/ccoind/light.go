@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/ccoin/core/internal/mempool"
+	"github.com/ccoin/core/internal/p2p"
+	"github.com/ccoin/core/internal/rpc"
+	"github.com/ccoin/core/internal/supervisor"
+	"github.com/ccoin/core/internal/wallet"
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// runLight runs a light node. It keeps no database or blocks: it follows
+// headers from its peers, scans their note data for the wallet, and proves
+// sends against Merkle paths checked against those headers.
+func runLight(ctx context.Context, cfg *Config) error {
+	nodeLog.Info("initializing light node")
+
+	if cfg.MinerEnabled || cfg.FaucetAddr != "" {
+		return errors.New("-light cannot mine or run a faucet")
+	}
+	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	supCfg := supervisor.DefaultConfig()
+	supCfg.DataDir = cfg.DataDir
+	supCfg.Version = version
+	sup := supervisor.NewSupervisor(supCfg)
+	defer sup.Wait()
+
+	node, err := newP2PNode(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer node.Close()
+	node.SetSupervisor(sup)
+
+	lightConfig := p2p.DefaultLightConfig()
+	lightConfig.ScanFrom = cfg.LightScanFrom
+	client := p2p.NewLightClient(node, lightConfig)
+	node.SetFilterVerifier(client.AddFilter)
+
+	// The wallet finds its notes in the note data the client scans
+	var walletBackend rpc.WalletBackend
+	if wallet.Exists(cfg.DataDir) {
+		walletConfig := wallet.DefaultConfig()
+		walletConfig.DataDir = cfg.DataDir
+		walletConfig.AtRest = cfg.atRest
+		w, err := wallet.Open(walletConfig)
+		if err != nil {
+			return fmt.Errorf("failed to open wallet: %w", err)
+		}
+		walletBackend = w
+		scanner := wallet.NewScanner(w, client)
+		client.SetBlockHandler(func(ctx context.Context, block *types.Block) {
+			if _, err := scanner.ScanBlock(ctx, block); err != nil {
+				nodeLog.Warn("wallet scan failed", "block", block.Header.Hash.String(), "err", err)
+			}
+		})
+		nodeLog.Info("wallet loaded (locked)")
+	}
+
+	node.Start()
+	nodeLog.Info("P2P node listening", "peer_id", node.ID().String(), "addr", cfg.ListenAddr)
+	if err := sup.Go(ctx, "p2p.light", client.Run); err != nil {
+		return fmt.Errorf("failed to start light sync: %w", err)
+	}
+
+	if cfg.RPCAddr != "" {
+		rpcConfig := rpc.DefaultConfig()
+		rpcConfig.ListenAddr = cfg.RPCAddr
+		rpcConfig.JSONRPCAddr = cfg.JSONRPCAddr
+		rpcConfig.Version = version
+		rpcConfig.Network = cfg.Network
+		rpcConfig.Roles = []string{"light"}
+		backends := &rpc.Backends{
+			Wallet:      walletBackend,
+			Supervisor:  sup,
+			Peers:       node,
+			Bans:        node.BanManager(),
+			Broadcaster: node,
+			Relay:       &syncAvoidingRelay{node: node, syncer: client},
+		}
+
+		// Sends are proven locally and handed to peers for admission
+		if walletBackend != nil {
+			circuits := zkp.NewCircuitManager()
+			backend, err := proofBackend(cfg)
+			if err != nil {
+				return err
+			}
+			circuits.SetBackend(zkp.ProofTypeTransaction, backend)
+			if cfg.ZKKeys != "" {
+				if err := circuits.LoadKeys(cfg.ZKKeys); err != nil {
+					return fmt.Errorf("failed to load circuit keys: %w", err)
+				}
+			}
+			if err := circuits.CompileTransactionCircuit(zkp.MaxTxInputs, zkp.MaxTxOutputs); err != nil {
+				return fmt.Errorf("failed to compile transaction circuit: %w", err)
+			}
+			backends.Shielded = client
+			backends.Circuits = circuits
+			backends.Mempool = newLightTxPool(client)
+		}
+
+		rpcServer := rpc.NewServer(rpcConfig, backends)
+		if err := rpcServer.Start(); err != nil {
+			return fmt.Errorf("failed to start RPC server: %w", err)
+		}
+		defer rpcServer.Stop()
+		nodeLog.Info("RPC server listening", "addr", rpcServer.Addr().String())
+	}
+
+	nodeLog.Info("light node started")
+	fmt.Println("CCoin light node started. Press Ctrl+C to stop.")
+
+	<-ctx.Done()
+
+	nodeLog.Info("node stopped")
+	return nil
+}
+
+// lightSpendCheckTimeout bounds the spent nullifier proofs requested for
+// a send
+const lightSpendCheckTimeout = 30 * time.Second
+
+// lightTxPool stands in for the mempool of a light node. It keeps no
+// transactions: sends are only broadcast, and peers decide whether to
+// admit them. Sends spending a nullifier a peer proves spent are refused
+// first; if no proof can be had the send goes out and peers decide.
+// Submission results are remembered so retried sends report the original
+// outcome.
+type lightTxPool struct {
+	submissions *mempool.SubmissionCache
+	client      *p2p.LightClient
+}
+
+func newLightTxPool(client *p2p.LightClient) *lightTxPool {
+	cfg := mempool.DefaultConfig()
+	return &lightTxPool{
+		submissions: mempool.NewSubmissionCache(cfg.SubmissionRetention, cfg.MaxSubmissions),
+		client:      client,
+	}
+}
+
+func (p *lightTxPool) Submit(requestID string, tx *types.Transaction) (types.Hash, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), lightSpendCheckTimeout)
+	spent, err := p.client.CheckSpent(ctx, tx.Nullifiers)
+	cancel()
+	if err != nil {
+		nodeLog.Debug("spent nullifier check failed", "tx", tx.TxHash.String(), "err", err)
+	} else if slices.Contains(spent, true) {
+		return types.Hash{}, mempool.ErrDoubleSpend
+	}
+
+	if requestID != "" {
+		p.submissions.Put(&mempool.SubmissionResult{RequestID: requestID, TxHash: tx.TxHash, SubmittedAt: time.Now()})
+	}
+	return tx.TxHash, nil
+}
+
+func (p *lightTxPool) LookupSubmission(requestID string) (*mempool.SubmissionResult, bool) {
+	return p.submissions.Get(requestID)
+}
+
+func (p *lightTxPool) Get(txHash types.Hash) *types.Transaction {
+	return nil
+}
+
+func (p *lightTxPool) Size() int {
+	return 0
+}
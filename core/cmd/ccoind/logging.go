@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ccoin/core/internal/logging"
+)
+
+// nodeLog logs the node's startup and block-processing events
+var nodeLog = logging.Module("node")
+
+// setupLogging directs every module logger to the configured output at
+// the configured levels
+func setupLogging(cfg *Config) (io.Closer, error) {
+	logCfg := logging.DefaultConfig()
+	logCfg.Level = cfg.LogLevel
+	logCfg.File = cfg.LogFile
+	logCfg.MaxSize = cfg.LogMaxSize << 20
+	logCfg.MaxBackups = cfg.LogMaxBackups
+	logCfg.JSON = cfg.LogJSON
+
+	closer, err := logging.Setup(logCfg)
+	if err != nil {
+		return nil, fmt.Errorf("logging: %w", err)
+	}
+	return closer, nil
+}
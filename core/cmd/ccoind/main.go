@@ -3,14 +3,52 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
 	"syscall"
 
+	"github.com/ccoin/core/internal/atrest"
+	"github.com/ccoin/core/internal/committee"
+	"github.com/ccoin/core/internal/config"
+	"github.com/ccoin/core/internal/consensus"
+	"github.com/ccoin/core/internal/credentials"
 	"github.com/ccoin/core/internal/dag"
+	"github.com/ccoin/core/internal/diagnostics"
+	"github.com/ccoin/core/internal/economics"
+	"github.com/ccoin/core/internal/explorer"
+	"github.com/ccoin/core/internal/faucet"
+	"github.com/ccoin/core/internal/history"
+	"github.com/ccoin/core/internal/ipfs"
+	"github.com/ccoin/core/internal/keys"
+	"github.com/ccoin/core/internal/lifecycle"
+	"github.com/ccoin/core/internal/mempool"
+	"github.com/ccoin/core/internal/miner"
+	"github.com/ccoin/core/internal/p2p"
+	"github.com/ccoin/core/internal/pouw"
+	"github.com/ccoin/core/internal/reputation"
+	"github.com/ccoin/core/internal/rpc"
+	"github.com/ccoin/core/internal/sanctions"
+	"github.com/ccoin/core/internal/state"
 	"github.com/ccoin/core/internal/storage"
+	"github.com/ccoin/core/internal/supervisor"
+	"github.com/ccoin/core/internal/telemetry"
+	"github.com/ccoin/core/internal/wallet"
+	"github.com/ccoin/core/internal/watchtower"
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/common"
+	"github.com/ccoin/core/pkg/types"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
 )
 
 const (
@@ -28,34 +66,69 @@ const (
 `
 )
 
-// Config holds node configuration
+// Config holds node configuration: the node settings, and the flags and
+// state only the daemon has
 type Config struct {
-	// Database
-	DBHost     string
-	DBPort     int
-	DBUser     string
-	DBPassword string
-	DBName     string
-
-	// Network
-	ListenAddr string
-	RPCAddr    string
+	config.Node
 
-	// Mining
-	MinerEnabled bool
-	MinerAddress string
+	// ConfigFile is the config file settings are loaded from, by default
+	// <data-dir>/ccoin.toml
+	ConfigFile string
 
-	// Logging
-	LogLevel string
-	LogFile  string
+	// MigrateOnly brings the database schema up to date and exits;
+	// MigrateDown, when not negative, rolls it back to that version first
+	MigrateOnly bool
+	MigrateDown int
 
-	// Data
-	DataDir string
+	// atRest is the cipher openAtRest derives from the at-rest settings
+	atRest *atrest.Cipher
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "archive" {
+		if err := runArchive(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "keys" {
+		if err := runKeys(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "init-miner" {
+		if err := runInitMiner(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "devnet" {
+		if err := runDevnet(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Parse flags
 	cfg := parseFlags()
+	logCloser, err := setupLogging(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer logCloser.Close()
 
 	// Print banner
 	fmt.Printf(banner, version)
@@ -64,17 +137,38 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Handle shutdown signals
-	sigCh := make(chan os.Signal, 1)
+	// Handle shutdown signals; a second signal exits without waiting for
+	// services to drain
+	sigCh := make(chan os.Signal, 2)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		<-sigCh
 		fmt.Println("\nShutting down...")
 		cancel()
+		<-sigCh
+		fmt.Fprintln(os.Stderr, "Forced shutdown")
+		os.Exit(1)
 	}()
 
+	if cfg.MigrateOnly {
+		if err := runMigrateOnly(ctx, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := openAtRest(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Initialize components
-	if err := run(ctx, cfg); err != nil {
+	start := run
+	if cfg.Light {
+		start = runLight
+	}
+	if err := start(ctx, cfg); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -82,83 +176,1061 @@ func main() {
 
 func parseFlags() *Config {
 	cfg := &Config{}
+	cfg.RegisterFlags(flag.CommandLine)
+	flag.StringVar(&cfg.ConfigFile, "config", "", "Config file (TOML, or YAML if named .yaml) of settings named after these flags (default: <data-dir>/"+config.DefaultFile+" if present); CCOIN_<FLAG> environment variables override it and flags override both")
+	flag.BoolVar(&cfg.MigrateOnly, "migrate-only", false, "Apply pending database schema migrations and exit")
+	flag.IntVar(&cfg.MigrateDown, "migrate-down", -1, "With -migrate-only, roll the schema back to this version (development only; drops data)")
 
-	// Database flags
-	flag.StringVar(&cfg.DBHost, "db-host", "localhost", "PostgreSQL host")
-	flag.IntVar(&cfg.DBPort, "db-port", 5432, "PostgreSQL port")
-	flag.StringVar(&cfg.DBUser, "db-user", "ccoin", "PostgreSQL user")
-	flag.StringVar(&cfg.DBPassword, "db-password", "", "PostgreSQL password")
-	flag.StringVar(&cfg.DBName, "db-name", "ccoin", "PostgreSQL database name")
+	flag.Parse()
 
-	// Network flags
-	flag.StringVar(&cfg.ListenAddr, "listen", "/ip4/0.0.0.0/tcp/9000", "P2P listen address")
-	flag.StringVar(&cfg.RPCAddr, "rpc", "127.0.0.1:9001", "RPC server address")
+	path, err := config.Load(flag.CommandLine)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to read config: %v\n", err)
+		os.Exit(1)
+	}
+	cfg.ConfigFile = path
 
-	// Mining flags
-	flag.BoolVar(&cfg.MinerEnabled, "mine", false, "Enable mining")
-	flag.StringVar(&cfg.MinerAddress, "miner-address", "", "Miner reward address")
+	return cfg
+}
 
-	// Logging flags
-	flag.StringVar(&cfg.LogLevel, "log-level", "info", "Log level (debug, info, warn, error)")
-	flag.StringVar(&cfg.LogFile, "log-file", "", "Log file path (empty for stdout)")
+// storageConfig builds the database configuration
+func storageConfig(cfg *Config) *storage.Config {
+	return &storage.Config{
+		Backend:  cfg.DBBackend,
+		Dir:      filepath.Join(cfg.DataDir, "db"),
+		Cipher:   cfg.atRest.Data(),
+		Host:     cfg.DBHost,
+		Port:     cfg.DBPort,
+		User:     cfg.DBUser,
+		Password: cfg.DBPassword,
+		Database: cfg.DBName,
+		SSLMode:  "disable",
+		MaxConns: 20,
+	}
+}
 
-	// Data flags
-	flag.StringVar(&cfg.DataDir, "data-dir", "./data", "Data directory")
+// ipfsConfig builds the model weight store configuration
+func ipfsConfig(cfg *Config) *ipfs.Config {
+	ipfsCfg := ipfs.DefaultConfig()
+	ipfsCfg.Gateway = cfg.IPFSGateway
+	ipfsCfg.API = cfg.IPFSAPI
+	ipfsCfg.Pin = cfg.IPFSPin
+	ipfsCfg.CacheDir = filepath.Join(cfg.DataDir, "ipfs")
+	ipfsCfg.MaxCacheSize = cfg.IPFSCacheSize
+	ipfsCfg.MaxObjectSize = cfg.IPFSMaxSize
+	return ipfsCfg
+}
 
-	flag.Parse()
+// proofBackend returns the proving system selected for transactions
+func proofBackend(cfg *Config) (zkp.Backend, error) {
+	switch cfg.ProofSystem {
+	case "groth16":
+		return zkp.Groth16Backend{}, nil
+	case "plonk":
+		if cfg.PLONKSRS == "" {
+			return nil, zkp.ErrSRSRequired
+		}
+		srs, err := zkp.LoadSRS(cfg.PLONKSRS)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load PLONK SRS: %w", err)
+		}
+		return zkp.NewPLONKBackend(srs), nil
+	case "plonk-unsafe":
+		return zkp.NewUnsafePLONKBackend(), nil
+	default:
+		return nil, fmt.Errorf("unknown proof system %q", cfg.ProofSystem)
+	}
+}
 
-	return cfg
+// newP2PNode creates the P2P node with the configured identity
+func newP2PNode(ctx context.Context, cfg *Config) (*p2p.Node, error) {
+	p2pConfig := p2p.DefaultConfig()
+	p2pConfig.ListenAddrs = []string{cfg.ListenAddr}
+	if cfg.BootstrapPeers != "" {
+		p2pConfig.BootstrapPeers = strings.Split(cfg.BootstrapPeers, ",")
+	}
+	gossip, err := p2p.GossipProfile(cfg.GossipProfile)
+	if err != nil {
+		return nil, err
+	}
+	p2pConfig.Gossip = gossip
+	bans, err := p2p.NewBanManager(filepath.Join(cfg.DataDir, "bans.json"))
+	if err != nil {
+		return nil, err
+	}
+	p2pConfig.Bans = bans
+	p2pConfig.Scoring = p2p.DefaultScoringConfig()
+	p2pConfig.Scoring.BanThreshold = cfg.PeerBanThreshold
+	p2pConfig.Scoring.BanDuration = cfg.PeerBanDuration
+	if err := applyNetwork(p2pConfig, cfg); err != nil {
+		return nil, err
+	}
+	p2pConfig.Diversity = p2p.DefaultDiversityConfig()
+	p2pConfig.Diversity.MaxOutbound = cfg.MaxPeersPerNetwork
+	p2pConfig.NAT = &p2p.NATConfig{
+		HolePunching: cfg.HolePunching,
+		PortMapping:  cfg.NATPortMap,
+		RelayService: cfg.RelayService,
+	}
+	if cfg.Relays != "" {
+		p2pConfig.NAT.StaticRelays = strings.Split(cfg.Relays, ",")
+	}
+	var feed p2p.BanFeedConfig
+	if cfg.BanFeed != "" {
+		key, err := hex.DecodeString(cfg.BanFeedKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, errors.New("-ban-feed-key must be a hex Ed25519 public key")
+		}
+		feed = p2p.BanFeedConfig{URL: cfg.BanFeed, PublicKey: key, Interval: cfg.BanFeedInterval}
+	}
+	provider, err := openKeyProvider(cfg.KeyProvider)
+	if err != nil {
+		return nil, err
+	}
+	keyPath := cfg.NodeKey
+	if keyPath == "" {
+		keyPath = filepath.Join(cfg.DataDir, p2p.DefaultIdentityFile)
+	}
+	if provider != nil {
+		signer, err := provider.Signer(ctx, keys.KeyNode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load node identity: %w", err)
+		}
+		if p2pConfig.PrivateKey, err = p2p.SignerIdentity(signer); err != nil {
+			return nil, fmt.Errorf("failed to load node identity: %w", err)
+		}
+	} else if key, err := p2p.LoadIdentity(keyPath); err == nil {
+		p2pConfig.PrivateKey = key
+	} else if cfg.NodeKey != "" || !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load node identity: %w", err)
+	}
+	// The node runs until closed rather than until ctx is cancelled, so
+	// shutdown can close it after the services using it
+	node, err := p2p.NewNode(context.WithoutCancel(ctx), p2pConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start p2p node: %w", err)
+	}
+
+	if cfg.BanFeed != "" {
+		go bans.RunFeed(ctx, feed, func(err error) {
+			nodeLog.Warn("ban feed failed", "feed", cfg.BanFeed, "err", err)
+		})
+	}
+	return node, nil
+}
+
+// applyNetwork sets the network the node joins: its ID, pinned genesis
+// and, if a key file is given or in the data directory, the private
+// network key
+func applyNetwork(p2pConfig *p2p.Config, cfg *Config) error {
+	network := cfg.NetworkParams()
+	p2pConfig.NetworkID = network.ID
+	p2pConfig.GenesisHash = network.Genesis
+
+	pskPath := cfg.PSK
+	if pskPath == "" {
+		pskPath = filepath.Join(cfg.DataDir, p2p.DefaultPSKFile)
+	}
+	psk, err := p2p.LoadPSK(pskPath)
+	switch {
+	case err == nil:
+		p2pConfig.PSK = psk
+	case cfg.PSK != "" || !os.IsNotExist(err):
+		return fmt.Errorf("failed to load private network key: %w", err)
+	}
+	return nil
+}
+
+// checkGenesis refuses to start on a chain whose genesis is not the one
+// the network is pinned to
+func checkGenesis(ctx context.Context, cfg *Config, blockDAG *dag.DAG) error {
+	pinned := cfg.NetworkParams().Genesis
+	if pinned.IsEmpty() {
+		return nil
+	}
+	headers, err := blockDAG.GetMainChain(ctx, 0, 0)
+	if err != nil || len(headers) == 0 {
+		return nil
+	}
+	if headers[0].Hash != pinned {
+		return fmt.Errorf("data directory holds a chain with genesis %s, not the %s network's %s", headers[0].Hash, cfg.Network, pinned)
+	}
+	return nil
+}
+
+// nodeRoles returns the roles the node advertises to peers
+func nodeRoles(cfg *Config) p2p.Roles {
+	roles := p2p.RoleRelay
+	if cfg.MinerEnabled {
+		roles |= p2p.RoleMiner
+	}
+	if cfg.ShieldedEnabled {
+		roles |= p2p.RoleShielded
+	}
+	if cfg.IndexerEnabled {
+		roles |= p2p.RoleIndexer
+	}
+	if cfg.RPCAddr != "" {
+		roles |= p2p.RoleRPC
+	}
+	return roles
+}
+
+// faucetConfig builds the faucet configuration. The faucet only runs on
+// testnet and pays through the RPC server's shielded sends.
+func faucetConfig(cfg *Config) (*faucet.Config, error) {
+	if cfg.Network != faucet.Network {
+		return nil, fmt.Errorf("faucet is only available on %s", faucet.Network)
+	}
+	if cfg.RPCAddr == "" || !cfg.ShieldedEnabled {
+		return nil, errors.New("faucet requires RPC and shielded pool processing")
+	}
+	amount, err := economics.ParseAmount(cfg.FaucetAmount)
+	if err != nil || amount == 0 {
+		return nil, fmt.Errorf("invalid faucet amount %q", cfg.FaucetAmount)
+	}
+
+	faucetCfg := faucet.DefaultConfig()
+	faucetCfg.ListenAddr = cfg.FaucetAddr
+	faucetCfg.Amount = amount
+	faucetCfg.TrustProxy = cfg.FaucetTrustProxy
+	return faucetCfg, nil
+}
+
+// minerKey is a miner key kept outside the wallet
+type minerKey interface {
+	miner.KeySource
+	Address() types.Address
+}
+
+// loadMinerKey loads the miner key from the key provider, or the miner
+// identity key named by -miner-key, creating it if missing, or the
+// default key file if present. It returns nil when the wallet key should
+// be used.
+func loadMinerKey(ctx context.Context, cfg *Config) (minerKey, error) {
+	provider, err := openKeyProvider(cfg.KeyProvider)
+	if err != nil {
+		return nil, err
+	}
+	if provider != nil {
+		signer, err := provider.Signer(ctx, keys.KeyMiner)
+		if errors.Is(err, keys.ErrKeyNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return miner.NewProviderKey(signer), nil
+	}
+
+	path := cfg.MinerKey
+	if path == "" {
+		path = filepath.Join(cfg.DataDir, miner.DefaultKeyFile)
+	}
+	key, err := miner.LoadKey(path)
+	if os.IsNotExist(err) {
+		if cfg.MinerKey == "" {
+			return nil, nil
+		}
+		if key, err = miner.CreateKey(path); err == nil {
+			nodeLog.Info("created miner key", "path", path)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return miner.NewStaticKey(key), nil
+}
+
+// syncAvoidingRelay relays private sends through a peer other than the
+// one the node syncs from
+type syncAvoidingRelay struct {
+	node   *p2p.Node
+	syncer interface{ SyncPeer() peer.ID }
+}
+
+func (r *syncAvoidingRelay) RelayTransaction(ctx context.Context, data []byte) (string, error) {
+	id, err := r.node.RelayTransaction(ctx, data, r.syncer.SyncPeer())
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
 }
 
 func run(ctx context.Context, cfg *Config) error {
-	fmt.Println("Initializing CCoin node...")
+	nodeLog.Info("initializing node")
+
+	var faucetCfg *faucet.Config
+	if cfg.FaucetAddr != "" {
+		var err error
+		if faucetCfg, err = faucetConfig(cfg); err != nil {
+			return err
+		}
+	}
 
 	// Create data directory
 	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
 		return fmt.Errorf("failed to create data directory: %w", err)
 	}
 
-	// Initialize database
-	fmt.Println("Connecting to database...")
-	dbConfig := &storage.Config{
-		Host:     cfg.DBHost,
-		Port:     cfg.DBPort,
-		User:     cfg.DBUser,
-		Password: cfg.DBPassword,
-		Database: cfg.DBName,
-		SSLMode:  "disable",
-		MaxConns: 20,
-	}
+	// Initialize subsystem supervision
+	supCfg := supervisor.DefaultConfig()
+	supCfg.DataDir = cfg.DataDir
+	supCfg.Version = version
+	sup := supervisor.NewSupervisor(supCfg)
+	defer sup.Wait()
+
+	// Services are registered as they are built and started together in
+	// dependency order; on shutdown they drain in reverse
+	services := lifecycle.NewManager(nil)
 
-	store, err := storage.NewPostgresStore(ctx, dbConfig)
+	// Initialize database
+	nodeLog.Info("connecting to database", "backend", cfg.DBBackend)
+	store, err := storage.Open(ctx, storageConfig(cfg))
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 	defer store.Close()
-	fmt.Println("Database connected.")
+	nodeLog.Info("database connected")
 
 	// Initialize DAG
-	fmt.Println("Initializing BlockDAG...")
+	nodeLog.Info("initializing BlockDAG")
 	blockDAG := dag.NewDAG(store, nil)
 	if err := blockDAG.Initialize(ctx); err != nil {
 		return fmt.Errorf("failed to initialize DAG: %w", err)
 	}
-	fmt.Printf("DAG initialized. Height: %d, Tips: %d\n", 
-		blockDAG.GetHeight(), len(blockDAG.GetTips()))
+	nodeLog.Info("DAG initialized", "height", blockDAG.GetHeight(), "tips", len(blockDAG.GetTips()))
+	if err := checkGenesis(ctx, cfg, blockDAG); err != nil {
+		return err
+	}
 
-	// TODO: Initialize remaining components
-	// - P2P Network (libp2p)
-	// - Consensus Engine
-	// - Mempool
-	// - RPC Server
-	// - Mining Engine (if enabled)
+	// Initialize mempool
+	poolConfig := mempool.DefaultConfig()
+	poolConfig.JournalExpiry = cfg.MempoolExpiry
+	poolConfig.TxTTL = cfg.MempoolExpiry
+	poolConfig.ReplaceFeeBump = cfg.ReplaceFeeBump
+	poolConfig.FloorHalfLife = cfg.FloorHalfLife
+	poolConfig.JournalCipher = cfg.atRest.Data()
+	txPool := mempool.NewMempool(poolConfig)
+	feeEstimator := economics.NewFeeEstimator(economics.NewFeeMarket(nil), txPool, nil)
 
-	fmt.Println("CCoin node started successfully!")
-	fmt.Println("Press Ctrl+C to stop.")
+	// Initialize circuits; every node verifies PoUW gradient proofs
+	circuits := zkp.NewCircuitManager()
+	backend, err := proofBackend(cfg)
+	if err != nil {
+		return err
+	}
+	circuits.SetBackend(zkp.ProofTypeTransaction, backend)
+	if cfg.ZKKeys != "" {
+		if err := circuits.LoadKeys(cfg.ZKKeys); err != nil {
+			return fmt.Errorf("failed to load circuit keys: %w", err)
+		}
+	}
+	if err := circuits.CompileGradientCircuit(); err != nil {
+		return fmt.Errorf("failed to compile gradient circuit: %w", err)
+	}
+
+	// Without shielded pool processing the node relays transactions
+	// unchecked and skips proof and disclosure checks on blocks
+	var (
+		nullifierSet *zkp.NullifierSet
+		disclosures  *zkp.DisclosureManager
+		shieldedPool *zkp.ShieldedPool
+		policy       *zkp.PolicyEngine
+	)
+	if cfg.ShieldedEnabled {
+		if err := circuits.CompileTransactionCircuit(zkp.MaxTxInputs, zkp.MaxTxOutputs); err != nil {
+			return fmt.Errorf("failed to compile transaction circuit: %w", err)
+		}
+		if err := circuits.CompileSanctionsCircuit(); err != nil {
+			return fmt.Errorf("failed to compile sanctions circuit: %w", err)
+		}
+		if err := circuits.CompileAggregateCircuit(); err != nil {
+			return fmt.Errorf("failed to compile aggregate disclosure circuit: %w", err)
+		}
+		if err := circuits.CompileIdentityCircuit(); err != nil {
+			return fmt.Errorf("failed to compile identity disclosure circuit: %w", err)
+		}
+		if err := circuits.CompileTemporalCircuit(); err != nil {
+			return fmt.Errorf("failed to compile temporal disclosure circuit: %w", err)
+		}
+		commitmentTree := zkp.NewCommitmentTree(zkp.NewInMemoryTreeStore(), zkp.TreeDepth)
+		if err := commitmentTree.Initialize(ctx); err != nil {
+			return fmt.Errorf("failed to initialize commitment tree: %w", err)
+		}
+		nullifierSet = zkp.NewNullifierSet(store, nil)
+		anchors := zkp.NewAnchorWindow(store, zkp.DefaultAnchorWindow)
+		if err := anchors.Initialize(ctx); err != nil {
+			return fmt.Errorf("failed to load anchor history: %w", err)
+		}
+		sanctionsList := sanctions.NewRegistry(store)
+		if err := sanctionsList.Initialize(ctx); err != nil {
+			return fmt.Errorf("failed to load sanctions list: %w", err)
+		}
+		credentialRegistry := credentials.NewRegistry(store)
+		if err := credentialRegistry.Initialize(ctx); err != nil {
+			return fmt.Errorf("failed to load credential authorities: %w", err)
+		}
+		policy = zkp.NewPolicyEngine(cfg.Network, store)
+		if err := policy.Initialize(ctx); err != nil {
+			return fmt.Errorf("failed to load disclosure policy: %w", err)
+		}
+		disclosures = zkp.NewDisclosureManager(circuits)
+		disclosures.SetSanctionsList(sanctionsList)
+		disclosures.SetCredentialRegistry(credentialRegistry)
+		disclosures.SetPolicy(policy)
+		disclosures.SetSpendSource(zkp.NewChainSpends(store, blockDAG))
+		shieldedPool = zkp.NewShieldedPool(commitmentTree, nullifierSet, circuits, disclosures)
+		shieldedPool.SetAnchorWindow(anchors)
+		txPool.SetAnchorTracker(shieldedPool)
+		txPool.SetDisclosurePolicy(disclosures)
+	}
+
+	// Restore pending transactions, dropping any spent while the node was down
+	if cfg.PersistMempool {
+		journalPath := filepath.Join(cfg.DataDir, mempool.DefaultJournalFile)
+		restored, err := txPool.OpenJournal(journalPath, func(tx *types.Transaction) error {
+			if nullifierSet == nil {
+				return nil
+			}
+			for _, nullifier := range tx.Nullifiers {
+				spent, err := nullifierSet.IsSpent(ctx, nullifier)
+				if err != nil {
+					return err
+				}
+				if spent {
+					return mempool.ErrDoubleSpend
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to open mempool journal: %w", err)
+		}
+		nodeLog.Info("mempool restored", "pending", restored)
+	}
+	services.Add(lifecycle.Service{
+		Name: "mempool",
+		Start: func(ctx context.Context) error {
+			return sup.Go(ctx, "mempool.expiry", txPool.Run)
+		},
+		Stop: func(context.Context) error { return txPool.CloseJournal() },
+	})
+
+	// Start P2P networking
+	node, err := newP2PNode(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	node.SetSupervisor(sup)
+	roles := nodeRoles(cfg)
+	node.SetRoles(roles)
+
+	// The block explorer API pushes blocks and transactions as they arrive
+	var blockExplorer *explorer.Explorer
+	explorerBackends := &explorer.Backends{DAG: blockDAG, Transactions: store, Mempool: txPool}
+	if cfg.ExplorerAddr != "" {
+		explorerCfg := explorer.DefaultConfig()
+		explorerCfg.ListenAddr = cfg.ExplorerAddr
+		explorerCfg.AllowOrigin = cfg.ExplorerOrigin
+		blockExplorer = explorer.NewExplorer(explorerCfg, explorerBackends)
+	}
+
+	node.SetTransactionHandler(func(ctx context.Context, msg *pubsub.Message) error {
+		tx, err := p2p.TransactionFromMessage(msg)
+		if err != nil {
+			return err
+		}
+		if nullifierSet != nil {
+			spent, err := nullifierSet.PreCheck(ctx, tx.Nullifiers)
+			if err != nil {
+				return err
+			}
+			if slices.Contains(spent, true) {
+				return mempool.ErrDoubleSpend
+			}
+		}
+		if err := txPool.Add(tx); err != nil {
+			if errors.Is(err, mempool.ErrTxAlreadyExists) {
+				return p2p.ErrDuplicateMessage
+			}
+			return err
+		}
+		if blockExplorer != nil {
+			blockExplorer.NotifyTransaction(tx)
+		}
+		return nil
+	})
+
+	// Load the wallet if one has been created (it starts locked). Its
+	// scanner picks up notes sent to it from new blocks.
+	var walletBackend rpc.WalletBackend
+	var nodeWallet *wallet.Wallet
+	var scanner *wallet.Scanner
+	if wallet.Exists(cfg.DataDir) && !cfg.ShieldedEnabled {
+		nodeLog.Warn("wallet not loaded: shielded pool processing is disabled")
+	} else if wallet.Exists(cfg.DataDir) {
+		walletConfig := wallet.DefaultConfig()
+		walletConfig.DataDir = cfg.DataDir
+		walletConfig.AtRest = cfg.atRest
+		w, err := wallet.Open(walletConfig)
+		if err != nil {
+			return fmt.Errorf("failed to open wallet: %w", err)
+		}
+		walletBackend = w
+		nodeWallet = w
+		scanner = wallet.NewScanner(w, shieldedPool)
+
+		// A send evicted for its anchor never confirms; hand its notes
+		// back so the user can rebuild it
+		txPool.SetRemovalHandler(func(e mempool.RemovalEvent) {
+			if e.Reason != mempool.RemovalStaleAnchor {
+				return
+			}
+			restored, err := w.RestoreNullifiers(e.Tx.Nullifiers)
+			if err != nil {
+				nodeLog.Warn("failed to restore notes", "tx", e.Tx.TxHash.String(), "err", err)
+			} else if len(restored) > 0 {
+				nodeLog.Warn("transaction dropped for a stale anchor; notes returned to the wallet, resend to rebuild it",
+					"tx", e.Tx.TxHash.String(), "notes", len(restored))
+			}
+		})
+		nodeLog.Info("wallet loaded (locked)")
+	}
+
+	// Block sync: serve /ccoin/sync requests and catch up with peers
+	validator := dag.NewBlockValidator(blockDAG)
+	if disclosures != nil {
+		validator.SetDisclosurePolicy(disclosures)
+	}
+	validator.SetPoUWVerifier(circuits)
+
+	// Blocks carry the difficulty target retargeted from main chain block
+	// times, which miners compute the same way. Only the engine's
+	// retargeting is used, so it needs no miner store.
+	difficulty := consensus.NewConsensus(blockDAG, nil, nil)
+	validator.SetDifficultyTargets(difficulty)
+
+	// Committee selections are recorded per epoch and committed to by the
+	// blocks opening it
+	committees := committee.NewAuditLog(committee.NewChainBeacon(blockDAG), store)
+	validator.SetCommitteeRoots(committees)
+
+	// Headers commit to the nullifiers spent along their chain so that
+	// double spends can be proven to light clients, and to the chain
+	// state, so balances and miner accounts can be proven against a
+	// single header, and to their transaction receipts
+	stateRoots := state.NewManager(blockDAG, 0)
+	nullifierRoots := stateRoots.NullifierRoots()
+	validator.SetNullifierRoots(nullifierRoots)
+	validator.SetStateRoots(stateRoots)
+	validator.SetReceiptsRoots(stateRoots)
+	if disclosures != nil {
+		disclosures.SetTemporalAnchors(zkp.NewChainAnchors(blockDAG, stateRoots.CommitmentRoots()))
+	}
 
-	// Wait for shutdown
+	// Invalid blocks signed by their miner are evidence against its stake.
+	// Evidence is checked as peers would check it before it is submitted.
+	stakes := reputation.NewSlashingManager(store, nil)
+	if err := stakes.LoadDelegations(ctx); err != nil {
+		return fmt.Errorf("failed to load stake delegations: %w", err)
+	}
+	validator.SetInvalidBlockHandler(func(ctx context.Context, header *types.BlockHeader, reason error) {
+		evidence, err := reputation.BlockEvidence(header, reason)
+		if err == nil {
+			_, err = reputation.VerifyBlockEvidence(evidence)
+		}
+		if err == nil {
+			err = stakes.SubmitEvidence(ctx, evidence)
+		}
+		if err != nil {
+			nodeLog.Warn("no slashing evidence for invalid block", "block", header.Hash.String(), "err", err)
+		}
+	})
+	syncConfig := p2p.DefaultSyncConfig()
+	syncConfig.FastSync = cfg.FastSync
+	syncer := p2p.NewSyncManager(node, blockDAG, validator, syncConfig)
+
+	// Light clients check the proofs and note data served to them
+	// against headers
+	syncer.SetLightServer(stateRoots)
+
+	// Each sealed epoch's nullifiers go into a Bloom filter, so gossiped
+	// transactions are screened for double spends without a storage
+	// lookup per nullifier. Filters are gossiped and served to light
+	// clients, which screen their sends the same way. Building them reads
+	// every main chain block, which pruned and fast-synced nodes lack.
+	var filters *zkp.NullifierFilters
+	if cfg.Prune == 0 && !cfg.FastSync {
+		filters = zkp.NewNullifierFilters(blockDAG, nil)
+		filters.SetOnSeal(func(f *zkp.NullifierFilter) {
+			if err := node.BroadcastFilter(f); err != nil {
+				nodeLog.Warn("failed to broadcast nullifier filter", "epoch", f.Epoch, "err", err)
+			}
+		})
+		node.SetFilterVerifier(filters.Verify)
+		syncer.SetFilters(filters)
+		if nullifierSet != nil {
+			nullifierSet.SetFilters(filters)
+		}
+
+		// The commitment tree is rebuilt from the same blocks, and only
+		// then can blocks be checked against it
+		if shieldedPool != nil {
+			if err := shieldedPool.Rebuild(ctx, blockDAG); err != nil {
+				return fmt.Errorf("failed to rebuild commitment tree: %w", err)
+			}
+			validator.SetShieldedState(shieldedPool)
+		}
+	}
+
+	// State snapshots let new nodes start without every block
+	var snapshots *state.Snapshotter
+	if cfg.SnapshotInterval > 0 {
+		snapshotConfig := state.DefaultSnapshotConfig()
+		snapshotConfig.Dir = filepath.Join(cfg.DataDir, "snapshots")
+		snapshotConfig.Interval = cfg.SnapshotInterval
+		snapshots, err = state.NewSnapshotter(stateRoots, blockDAG, snapshotConfig)
+		if err != nil {
+			return fmt.Errorf("failed to open snapshots: %w", err)
+		}
+		syncer.SetSnapshots(snapshots)
+	} else if cfg.FastSync {
+		return errors.New("-fast-sync requires -snapshot-interval")
+	}
+
+	// Pruning discards old block bodies, never past the latest snapshot.
+	// Pruned blocks are no longer served, and the pruned height is
+	// advertised so peers sync them from archival nodes.
+	var pruner *storage.Pruner
+	if cfg.Prune > 0 {
+		if snapshots == nil {
+			return errors.New("-prune requires -snapshot-interval")
+		}
+		pruneConfig := storage.DefaultPruneConfig()
+		pruneConfig.Depth = cfg.Prune
+		pruner, err = storage.NewPruner(ctx, store, blockDAG, pruneConfig)
+		if err != nil {
+			return fmt.Errorf("failed to start pruning: %w", err)
+		}
+		pruner.SetSnapshots(snapshots)
+		syncer.SetPruned(pruner)
+	}
+
+	// Archive nodes checkpoint stakes and governance along the main chain
+	// and serve state at past heights
+	var historyIndex *history.Index
+	if cfg.Archive {
+		historyConfig := history.DefaultConfig()
+		historyConfig.Dir = filepath.Join(cfg.DataDir, "history")
+		historyConfig.Interval = cfg.ArchiveInterval
+		historyIndex, err = history.NewIndex(historyConfig, blockDAG, stateRoots)
+		if err != nil {
+			return fmt.Errorf("failed to open history index: %w", err)
+		}
+		historyIndex.SetStakes(stakes)
+		explorerBackends.History = historyIndex
+	}
+
+	// updateSnapshots runs off the block path since a snapshot walks the
+	// whole chain
+	updateSnapshots := func() {
+		if err := snapshots.Update(ctx); err != nil {
+			nodeLog.Warn("state snapshot failed", "err", err)
+		}
+	}
+
+	// updateFilters likewise, since the first update builds the filters
+	// of every epoch
+	updateFilters := func() {
+		if err := filters.Update(ctx); err != nil {
+			nodeLog.Warn("nullifier filter update failed", "err", err)
+		}
+	}
+
+	// applyBlock updates fee estimates, the mempool, the wallet, stake
+	// delegations, history checkpoints, state snapshots, nullifier
+	// filters and the commitment tree for a block added to the DAG,
+	// whether received or mined
+	applyBlock := func(ctx context.Context, block *types.Block) {
+		feeEstimator.AddBlock(block)
+		if err := stakes.ApplyBlock(ctx, block); err != nil {
+			nodeLog.Warn("stake delegations failed", "block", block.Header.Hash.String(), "err", err)
+		}
+		if historyIndex != nil {
+			if err := historyIndex.Update(ctx); err != nil {
+				nodeLog.Warn("history checkpoint failed", "err", err)
+			}
+		}
+		if snapshots != nil {
+			go updateSnapshots()
+		}
+		if filters != nil {
+			filters.Add(block.Header.Height, dag.BlockNullifiers(block.Transactions))
+			go updateFilters()
+		}
+		if shieldedPool != nil {
+			if err := shieldedPool.ApplyBlock(ctx, block); err != nil {
+				nodeLog.Warn("commitment tree update failed", "block", block.Header.Hash.String(), "err", err)
+			}
+		}
+		if n := txPool.RevalidateAnchors(); n > 0 {
+			nodeLog.Info("evicted transactions with stale anchors", "count", n)
+		}
+		if scanner != nil {
+			if _, err := scanner.ScanBlock(ctx, block); err != nil {
+				nodeLog.Warn("wallet scan failed", "block", block.Header.Hash.String(), "err", err)
+			}
+		}
+		if blockExplorer != nil {
+			blockExplorer.NotifyBlock(block)
+		}
+	}
+
+	// Miners train on model weights kept on IPFS
+	var modelStore *ipfs.ModelStore
+	if cfg.MinerEnabled {
+		modelStore, err = ipfs.NewModelStore(ipfsConfig(cfg), nil)
+		if err != nil {
+			return fmt.Errorf("failed to open model store: %w", err)
+		}
+	}
+
+	// Block production: the PoUW engine trains on tasks and the miner
+	// turns each result into a block signed with the miner key. Mining is
+	// started over RPC, once the wallet is unlocked if it holds the key.
+	// The miner exists before the node starts so received blocks can
+	// refresh the template external miners work on.
+	var blockMiner *miner.Miner
+	if cfg.MinerEnabled {
+		minerConfig := miner.DefaultConfig()
+		var keySource miner.KeySource
+		minerKey, err := loadMinerKey(ctx, cfg)
+		switch {
+		case err != nil:
+			return fmt.Errorf("failed to load miner key: %w", err)
+		case minerKey != nil:
+			keySource = minerKey
+			minerConfig.Address = minerKey.Address()
+		case nodeWallet != nil:
+			keySource = nodeWallet
+			minerConfig.Address = nodeWallet.Address()
+		default:
+			return errors.New("mining requires a wallet, -miner-key or a miner key in -key-provider; create a wallet with ccoind init-miner")
+		}
+		if cfg.MinerAddress != "" {
+			b, err := common.HexToBytes(cfg.MinerAddress)
+			if err != nil || len(b) != types.AddressSize {
+				return fmt.Errorf("invalid miner address %q", cfg.MinerAddress)
+			}
+			copy(minerConfig.Address[:], b)
+		}
+		engine := pouw.NewEngine(pouw.NewTaskQueue(nil), modelStore, nil)
+		engine.SetSupervisor(sup)
+		engine.SetCircuits(circuits)
+		blockMiner = miner.NewMiner(blockDAG, validator, txPool, engine, keySource, minerConfig)
+		blockMiner.SetBroadcaster(node)
+		blockMiner.SetDifficultySource(difficulty)
+		blockMiner.SetCommitteeRoots(committees)
+		blockMiner.SetNullifierRoots(nullifierRoots)
+		blockMiner.SetStateRoots(stateRoots)
+		blockMiner.SetReceiptsRoots(stateRoots)
+		blockMiner.SetBlockHandler(applyBlock)
+		services.Add(lifecycle.Service{
+			Name:      "miner",
+			DependsOn: []string{"mempool", "p2p"},
+			Stop: func(context.Context) error {
+				blockMiner.Stop()
+				return nil
+			},
+		})
+	}
+
+	node.SetBlockHandler(func(ctx context.Context, msg *pubsub.Message) error {
+		block, err := p2p.BlockFromMessage(msg)
+		if err != nil {
+			return err
+		}
+		if err := syncer.HandleBlock(ctx, block); err != nil {
+			if errors.Is(err, dag.ErrDuplicateBlock) {
+				return p2p.ErrDuplicateMessage
+			}
+			return err
+		}
+		applyBlock(ctx, block)
+		if blockMiner != nil {
+			blockMiner.NotifyBlock(ctx, block)
+		}
+		return nil
+	})
+	services.Add(lifecycle.Service{
+		Name:      "p2p",
+		DependsOn: []string{"mempool"},
+		Start: func(ctx context.Context) error {
+			node.Start()
+			nodeLog.Info("P2P node listening", "peer_id", node.ID().String(), "addr", cfg.ListenAddr)
+			if err := sup.Go(ctx, "p2p.sync", syncer.Run); err != nil {
+				return fmt.Errorf("failed to start sync: %w", err)
+			}
+			if filters != nil {
+				go updateFilters()
+			}
+			if pruner != nil {
+				if err := sup.Go(ctx, "storage.prune", pruner.Run); err != nil {
+					return fmt.Errorf("failed to start pruning: %w", err)
+				}
+				nodeLog.Info("pruning block bodies", "keep_heights", cfg.Prune)
+			}
+			return nil
+		},
+		Stop: func(context.Context) error { return node.Close() },
+	})
+
+	// Initialize supply tracking
+	supply := economics.NewSupplyManager(nil)
+
+	// The block explorer API
+	if blockExplorer != nil {
+		explorerBackends.Stakes = stakes
+		explorerBackends.Supply = supply
+		services.Add(lifecycle.Service{
+			Name:      "explorer",
+			DependsOn: []string{"mempool", "p2p"},
+			Start: func(context.Context) error {
+				if err := blockExplorer.Start(); err != nil {
+					return err
+				}
+				nodeLog.Info("block explorer API listening", "addr", blockExplorer.Addr().String())
+				return nil
+			},
+			Stop: func(context.Context) error {
+				blockExplorer.Stop()
+				return nil
+			},
+		})
+	}
+
+	// The watchtower alerts delegators by webhook to slashing evidence
+	// against their miners
+	if cfg.WatchtowerAddr != "" {
+		towerCfg := watchtower.DefaultConfig()
+		towerCfg.ListenAddr = cfg.WatchtowerAddr
+		towerCfg.Path = filepath.Join(cfg.DataDir, "watchtower.json")
+		tower, err := watchtower.NewWatchtower(towerCfg)
+		if err != nil {
+			return fmt.Errorf("failed to open watchtower: %w", err)
+		}
+		tower.SetDelegations(stakes)
+		stakes.SetEvidenceHandler(tower.NotifyEvidence)
+		services.Add(lifecycle.Service{
+			Name: "watchtower",
+			Start: func(context.Context) error {
+				if err := tower.Start(); err != nil {
+					return err
+				}
+				nodeLog.Info("watchtower listening", "addr", cfg.WatchtowerAddr)
+				return nil
+			},
+			Stop: func(context.Context) error {
+				tower.Stop()
+				return nil
+			},
+		})
+	}
+
+	// Telemetry; the report is built even when disabled so operators can
+	// preview it
+	telemetryCfg := telemetry.DefaultConfig()
+	telemetryCfg.Endpoint = cfg.TelemetryEndpoint
+	telemetryCfg.Interval = cfg.TelemetryInterval
+	telemetryCfg.DataDir = cfg.DataDir
+	telemetryCfg.Version = version
+	telemetryCfg.Network = cfg.Network
+	gpus, _ := detectGPUs()
+	telemetryCfg.Hardware = telemetry.HardwareClass(runtime.NumCPU(), systemMemoryMB(), len(gpus))
+	reporter, err := telemetry.NewReporter(telemetryCfg, telemetry.Sources{
+		Height: blockDAG.GetHeight,
+		Peers:  node.PeerCount,
+		Proofs: func() map[string]telemetry.ProofTiming {
+			timings := make(map[string]telemetry.ProofTiming)
+			for pt, t := range circuits.ProofTimings() {
+				timings[pt.String()] = telemetry.NewProofTiming(t.Proofs, t.ProveTime, t.Verified, t.VerifyTime)
+			}
+			return timings
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to configure telemetry: %w", err)
+	}
+	if reporter.Enabled() {
+		services.Add(lifecycle.Service{
+			Name:      "telemetry",
+			DependsOn: []string{"p2p"},
+			Start: func(ctx context.Context) error {
+				if err := sup.Go(ctx, "telemetry", reporter.Run); err != nil {
+					return err
+				}
+				nodeLog.Info("telemetry enabled", "endpoint", telemetryCfg.Endpoint, "interval", telemetryCfg.Interval,
+					"report_log", filepath.Join(cfg.DataDir, telemetry.LogFileName))
+				return nil
+			},
+		})
+	}
+
+	// Diagnostics endpoint
+	diagConfig := diagnostics.DefaultConfig()
+	diagConfig.ListenAddr = cfg.AdminAddr
+	diagConfig.AuthToken = cfg.AdminToken
+	diagConfig.DataDir = cfg.DataDir
+	diagConfig.LogFile = cfg.LogFile
+	diag := diagnostics.NewDiagnostics(diagConfig)
+	diag.RegisterMetrics("subsystems", func() interface{} { return sup.Status() })
+	diag.RegisterMetrics("services", func() interface{} { return services.Status() })
+	diag.RegisterMetrics("mempool", func() interface{} {
+		return map[string]interface{}{"size": txPool.Size(), "total_fees": txPool.TotalFees(), "min_fee_rate": txPool.MinFeeRate()}
+	})
+	diag.RegisterMetrics("dag", func() interface{} {
+		return map[string]interface{}{"height": blockDAG.GetHeight(), "tips": len(blockDAG.GetTips())}
+	})
+	if modelStore != nil {
+		diag.RegisterMetrics("ipfs", func() interface{} { return modelStore.Stats() })
+	}
+	if blockMiner != nil {
+		diag.RegisterMetrics("miner", func() interface{} { return blockMiner.Status() })
+	}
+	diag.RegisterMetrics("telemetry", func() interface{} {
+		return map[string]interface{}{"enabled": reporter.Enabled(), "report": reporter.Collect()}
+	})
+	services.Add(lifecycle.Service{
+		Name:  "diagnostics",
+		Start: func(context.Context) error { return diag.Start() },
+		Stop: func(context.Context) error {
+			diag.Stop()
+			return nil
+		},
+	})
+
+	// RPC server
+	if cfg.RPCAddr != "" {
+		rpcConfig := rpc.DefaultConfig()
+		rpcConfig.ListenAddr = cfg.RPCAddr
+		rpcConfig.JSONRPCAddr = cfg.JSONRPCAddr
+		rpcConfig.Version = version
+		rpcConfig.Roles = roles.Strings()
+		backends := &rpc.Backends{
+			DAG:         blockDAG,
+			Mempool:     txPool,
+			Supply:      supply,
+			Fees:        feeEstimator,
+			Diagnostics: diag,
+			Committees:  committees,
+			Wallet:      walletBackend,
+			Supervisor:  sup,
+			Peers:       node,
+			Bans:        node.BanManager(),
+			Stakes:      stakes,
+			Broadcaster: node,
+			Relay:       &syncAvoidingRelay{node: node, syncer: syncer},
+
+			Transactions: store,
+			Receipts:     stateRoots,
+		}
+		if historyIndex != nil {
+			backends.History = historyIndex
+		}
+		if cfg.IndexerEnabled {
+			if analytics, ok := store.(rpc.AnalyticsBackend); ok {
+				backends.Analytics = analytics
+			} else {
+				nodeLog.Warn("backend does not serve analytics queries", "backend", cfg.DBBackend)
+			}
+		}
+		if blockMiner != nil {
+			backends.Miner = blockMiner
+		}
+		if shieldedPool != nil {
+			backends.Shielded = shieldedPool
+			backends.Circuits = circuits
+			backends.Policy = policy
+		}
+		rpcServer := rpc.NewServer(rpcConfig, backends)
+		rpcDeps := []string{"mempool", "p2p", "diagnostics"}
+		if blockMiner != nil {
+			rpcDeps = append(rpcDeps, "miner")
+		}
+		services.Add(lifecycle.Service{
+			Name:      "rpc",
+			DependsOn: rpcDeps,
+			Start: func(context.Context) error {
+				if err := rpcServer.Start(); err != nil {
+					return err
+				}
+				nodeLog.Info("RPC server listening", "addr", rpcServer.Addr().String())
+				if cfg.JSONRPCAddr != "" {
+					nodeLog.Info("JSON-RPC gateway listening", "addr", cfg.JSONRPCAddr)
+				}
+				return nil
+			},
+			// In-flight calls finish before the server stops
+			Stop: func(context.Context) error {
+				rpcServer.Stop()
+				return nil
+			},
+		})
+
+		// The faucet pays from the node wallet, which must be unlocked
+		if faucetCfg != nil {
+			if walletBackend == nil {
+				return errors.New("faucet requires a wallet")
+			}
+			coinFaucet := faucet.NewFaucet(faucetCfg, rpcServer)
+			services.Add(lifecycle.Service{
+				Name:      "faucet",
+				DependsOn: []string{"rpc"},
+				Start: func(context.Context) error {
+					if err := coinFaucet.Start(); err != nil {
+						return err
+					}
+					nodeLog.Info("faucet listening", "addr", faucetCfg.ListenAddr, "amount", economics.FormatAmount(faucetCfg.Amount))
+					return nil
+				},
+				Stop: func(context.Context) error {
+					coinFaucet.Stop()
+					return nil
+				},
+			})
+		}
+	}
+
+	if err := services.Start(ctx); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return nil // interrupted while starting
+		}
+		return err
+	}
+	nodeLog.Info("node started", "roles", roles.String())
+	fmt.Println("CCoin node started. Press Ctrl+C to stop.")
+
+	// Wait for shutdown, then drain the services in reverse order
 	<-ctx.Done()
+	nodeLog.Info("stopping services", "timeout", cfg.ShutdownTimeout)
+	stopCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := services.Stop(stopCtx); err != nil {
+		nodeLog.Warn("services did not stop cleanly", "err", err)
+	}
+
+	if sup.Degraded() {
+		for _, st := range sup.Status() {
+			if st.Degraded {
+				nodeLog.Error("subsystem degraded", "name", st.Name, "crashes", st.Restarts, "last_panic", st.LastPanic)
+			}
+		}
+	}
 
-	fmt.Println("Node stopped.")
+	nodeLog.Info("node stopped")
 	return nil
 }
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ccoin/core/internal/storage"
+)
+
+// runMigrate implements `ccoind migrate [--online] [--status] [name...]`.
+// With --online the backfill is rate limited so the node can keep serving
+// while dual-write triggers keep the shadow tables current.
+func runMigrate(args []string) error {
+	cfg := &Config{}
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	cfg.RegisterDBFlags(fs)
+
+	online := fs.Bool("online", false, "Run without downtime: dual-write and rate-limited backfill")
+	showStatus := fs.Bool("status", false, "Show migration progress and exit")
+	noCutover := fs.Bool("no-cutover", false, "Stop after backfill, leaving dual-write enabled")
+	batch := fs.Int("batch", storage.DefaultMigratorConfig().BatchSize, "Rows copied per batch")
+	rate := fs.Int("rate", storage.DefaultMigratorConfig().RowsPerSecond, "Backfill rate limit in rows per second (--online only)")
+
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: ccoind migrate [flags] [migration...]")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Registered migrations:")
+		for _, m := range storage.OnlineMigrations {
+			fmt.Fprintf(os.Stderr, "  %s (%s)\n", m.Name, m.Table)
+		}
+		fmt.Fprintln(os.Stderr, "")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	if cfg.DBBackend != storage.BackendPostgres {
+		return fmt.Errorf("online migrations only apply to the %s backend", storage.BackendPostgres)
+	}
+	store, err := storage.NewPostgresStore(ctx, storageConfig(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer store.Close()
+
+	migCfg := storage.DefaultMigratorConfig()
+	migCfg.BatchSize = *batch
+	migCfg.RowsPerSecond = *rate
+	if !*online {
+		// Offline runs assume the node is stopped, so copy as fast as possible
+		migCfg.RowsPerSecond = 0
+	}
+	migCfg.OnProgress = func(st storage.MigrationStatus) {
+		fmt.Printf("\r  %s: %d rows copied", st.Name, st.RowsCopied)
+	}
+	migrator := storage.NewMigrator(store, migCfg)
+
+	if *showStatus {
+		statuses, err := migrator.StatusAll(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range statuses {
+			fmt.Printf("%-36s %-14s %-11s %d rows\n", st.Name, st.Table, st.Phase, st.RowsCopied)
+		}
+		return nil
+	}
+
+	migrations := storage.OnlineMigrations
+	if fs.NArg() > 0 {
+		migrations = nil
+		for _, name := range fs.Args() {
+			m, err := storage.FindOnlineMigration(name)
+			if err != nil {
+				return err
+			}
+			migrations = append(migrations, m)
+		}
+	}
+
+	if !*online {
+		fmt.Println("Running offline; make sure the node is stopped (use --online otherwise).")
+	}
+
+	for _, m := range migrations {
+		st, err := migrator.Status(ctx, m.Name)
+		if err != nil {
+			return err
+		}
+		if st.Phase == storage.PhaseComplete {
+			fmt.Printf("%s: already complete\n", m.Name)
+			continue
+		}
+
+		fmt.Printf("%s: migrating %s\n", m.Name, m.Table)
+		if st.Phase == storage.PhasePending {
+			if err := migrator.EnableDualWrite(ctx, m); err != nil {
+				return fmt.Errorf("%s: %w", m.Name, err)
+			}
+		}
+
+		if err := migrator.Backfill(ctx, m); err != nil {
+			fmt.Println()
+			if errors.Is(err, context.Canceled) {
+				fmt.Printf("%s: interrupted; rerun to resume the backfill\n", m.Name)
+				return nil
+			}
+			return fmt.Errorf("%s: %w", m.Name, err)
+		}
+		fmt.Println()
+
+		if *noCutover {
+			fmt.Printf("%s: backfill complete, dual-write still active\n", m.Name)
+			continue
+		}
+
+		if err := migrator.Cutover(ctx, m); err != nil {
+			return fmt.Errorf("%s: %w", m.Name, err)
+		}
+		fmt.Printf("%s: cut over; previous table kept as %s_pre_%s\n", m.Name, m.Table, m.Name)
+	}
+
+	return nil
+}
+
+// runMigrateOnly implements `ccoind --migrate-only`. Opening the store
+// applies pending schema migrations; --migrate-down then reverts the
+// schema to an earlier version for development.
+func runMigrateOnly(ctx context.Context, cfg *Config) error {
+	if cfg.DBBackend != storage.BackendPostgres {
+		fmt.Printf("The %s backend has no schema to migrate.\n", cfg.DBBackend)
+		return nil
+	}
+	store, err := storage.NewPostgresStore(ctx, storageConfig(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+	defer store.Close()
+
+	if cfg.MigrateDown >= 0 {
+		n, err := store.RollbackSchema(ctx, cfg.MigrateDown)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reverted %d schema migration(s).\n", n)
+	}
+
+	version, err := store.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Database schema at version %d.\n", version)
+	return nil
+}
@@ -3,10 +3,15 @@ module github.com/ccoin/core
 go 1.21
 
 require (
+	filippo.io/edwards25519 v1.1.0
+	github.com/cockroachdb/pebble v1.1.0
 	github.com/consensys/gnark v0.10.0
 	github.com/consensys/gnark-crypto v0.13.0
+	github.com/gorilla/websocket v1.5.1
 	github.com/jackc/pgx/v5 v5.5.5
 	github.com/libp2p/go-libp2p v0.33.0
 	github.com/libp2p/go-libp2p-pubsub v0.10.0
 	golang.org/x/crypto v0.21.0
+	golang.org/x/text v0.14.0
+	google.golang.org/grpc v1.62.1
 )
@@ -0,0 +1,131 @@
+package aicommons
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Registration bond errors
+var (
+	ErrBondNotFound          = errors.New("registration bond not found")
+	ErrBondLocked            = errors.New("registration bond still locked")
+	ErrBondReleased          = errors.New("registration bond already refunded or forfeited")
+	ErrNotForfeitureProposal = errors.New("not a bond forfeiture proposal")
+)
+
+// BondStatus represents the state of a registration bond
+type BondStatus uint8
+
+const (
+	// BondLocked is held while governance can still forfeit it
+	BondLocked BondStatus = iota
+
+	// BondRefunded was returned to the proposer
+	BondRefunded
+
+	// BondForfeited was taken by a governance forfeiture proposal
+	BondForfeited
+)
+
+// RegistrationBond is the deposit locked by a model registration
+type RegistrationBond struct {
+	ModelID    types.Hash
+	Proposer   types.Address
+	Amount     uint64
+	PostedAt   uint64
+	Status     BondStatus
+	ProposalID types.Hash // Forfeiture proposal, if forfeited
+}
+
+// allowLocked reports whether proposer is under the registration rate
+// limit at currentBlock, dropping heights outside the window
+func (r *ModelRegistry) allowLocked(proposer types.Address, currentBlock uint64) bool {
+	heights := r.recent[proposer]
+	kept := heights[:0]
+	for _, h := range heights {
+		if h+r.config.RateWindow > currentBlock {
+			kept = append(kept, h)
+		}
+	}
+	if len(kept) == 0 {
+		delete(r.recent, proposer)
+	} else {
+		r.recent[proposer] = kept
+	}
+	return len(kept) < r.config.RateLimit
+}
+
+// GetBond returns the registration bond for a model
+func (r *ModelRegistry) GetBond(modelID types.Hash) (*RegistrationBond, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	bond, exists := r.bonds[modelID]
+	if !exists {
+		return nil, ErrBondNotFound
+	}
+	return bond, nil
+}
+
+// RefundBond releases a registration bond to its proposer once the lock
+// period has passed without forfeiture. It returns the amount refunded.
+func (r *ModelRegistry) RefundBond(modelID types.Hash, proposer types.Address, currentBlock uint64) (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	bond, exists := r.bonds[modelID]
+	if !exists {
+		return 0, ErrBondNotFound
+	}
+	if bond.Proposer != proposer {
+		return 0, ErrUnauthorized
+	}
+	if bond.Status != BondLocked {
+		return 0, ErrBondReleased
+	}
+	if currentBlock < bond.PostedAt+r.config.BondLockPeriod {
+		return 0, ErrBondLocked
+	}
+
+	bond.Status = BondRefunded
+	return bond.Amount, nil
+}
+
+// ApplyProposal applies a passed bond forfeiture proposal: the bond is
+// forfeited and the model deprecated. It returns the forfeited bond so
+// the caller can credit the treasury.
+func (r *ModelRegistry) ApplyProposal(ctx context.Context, proposal *types.Proposal) (*RegistrationBond, error) {
+	if proposal.Type != types.ProposalBondForfeiture {
+		return nil, ErrNotForfeitureProposal
+	}
+	if proposal.Status != types.ProposalStatusPassed && proposal.Status != types.ProposalStatusExecuted {
+		return nil, ErrProposalNotPassed
+	}
+	data, ok := proposal.Data.(*types.BondForfeitureData)
+	if !ok {
+		return nil, ErrNotForfeitureProposal
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	bond, exists := r.bonds[data.ModelID]
+	if !exists {
+		return nil, ErrBondNotFound
+	}
+	if bond.Status != BondLocked {
+		return nil, ErrBondReleased
+	}
+
+	bond.Status = BondForfeited
+	bond.ProposalID = proposal.ProposalID
+
+	model, exists := r.models[data.ModelID]
+	if !exists {
+		return bond, nil
+	}
+	model.Status = types.ModelStatusDeprecated
+	return bond, r.store.SaveModel(ctx, model)
+}
@@ -0,0 +1,130 @@
+package aicommons
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ccoin/core/internal/dag"
+	"github.com/ccoin/core/internal/state"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Checkpoint errors
+var (
+	// ErrNoCheckpoint is returned for epochs the registry holds no
+	// checkpoint of; validators skip the registry root of such blocks
+	ErrNoCheckpoint = dag.ErrNoRegistryCheckpoint
+
+	ErrInvalidModelProof = errors.New("model proof does not match registry root")
+)
+
+// CheckpointRetention is the number of most recent epoch checkpoints the
+// registry keeps to prove models against
+const CheckpointRetention = 16
+
+// registryCheckpoint is the registry state frozen for an epoch
+type registryCheckpoint struct {
+	tree   *state.Tree
+	models map[types.Hash]*types.ModelCheckpoint
+}
+
+// ModelProof proves a model's checkpoint, or that the model was not
+// registered, against the registry root committed for an epoch
+type ModelProof struct {
+	ModelID types.Hash
+	Epoch   uint64
+	Root    types.Hash
+
+	// Checkpoint is nil when the proof shows the model's absence
+	Checkpoint *types.ModelCheckpoint
+	Proof      *state.Proof
+}
+
+// RegistryRoot returns the registry root blocks opening epoch commit to.
+// Checkpoints are taken automatically: the first request for an epoch
+// newer than every checkpoint, made when the node mines or validates the
+// epoch's first block, freezes the registry as it stands. Earlier epochs
+// that were never checkpointed, or whose checkpoints were dropped, return
+// ErrNoCheckpoint.
+func (r *ModelRegistry) RegistryRoot(ctx context.Context, epoch uint64) (types.Hash, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cp, err := r.checkpointLocked(epoch)
+	if err != nil {
+		return types.Hash{}, err
+	}
+	return cp.tree.Root(), nil
+}
+
+// checkpointLocked returns the checkpoint of epoch, taking it if epoch is
+// newer than every checkpoint
+func (r *ModelRegistry) checkpointLocked(epoch uint64) (*registryCheckpoint, error) {
+	if cp, ok := r.checkpoints[epoch]; ok {
+		return cp, nil
+	}
+	if epoch <= r.lastCheckpoint {
+		return nil, ErrNoCheckpoint
+	}
+
+	cp := &registryCheckpoint{
+		tree:   state.NewTree(),
+		models: make(map[types.Hash]*types.ModelCheckpoint, len(r.models)),
+	}
+	for id, model := range r.models {
+		c := types.NewModelCheckpoint(model, r.versions[id])
+		cp.models[id] = c
+		cp.tree.Set(id, c.Hash())
+	}
+	// Settle the root now so readers holding the read lock never hash
+	cp.tree.Root()
+
+	r.checkpoints[epoch] = cp
+	r.lastCheckpoint = epoch
+	for e := range r.checkpoints {
+		if e+CheckpointRetention <= epoch {
+			delete(r.checkpoints, e)
+		}
+	}
+	return cp, nil
+}
+
+// ProveModel proves a model's checkpoint at epoch, or at the latest
+// checkpoint if epoch is zero. A model that was not registered at the
+// checkpoint gets a proof of absence.
+func (r *ModelRegistry) ProveModel(modelID types.Hash, epoch uint64) (*ModelProof, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if epoch == 0 {
+		epoch = r.lastCheckpoint
+	}
+	cp, ok := r.checkpoints[epoch]
+	if !ok {
+		return nil, ErrNoCheckpoint
+	}
+	return &ModelProof{
+		ModelID:    modelID,
+		Epoch:      epoch,
+		Root:       cp.tree.Root(),
+		Checkpoint: cp.models[modelID],
+		Proof:      cp.tree.Prove(modelID),
+	}, nil
+}
+
+// Verify checks the proof against a registry root taken from a block
+// header. Light clients use it to check a model's status, weights and
+// contributor shares without trusting the node that served the proof.
+func (p *ModelProof) Verify(root types.Hash) error {
+	var value types.Hash
+	if p.Checkpoint != nil {
+		if p.Checkpoint.ModelID != p.ModelID {
+			return ErrInvalidModelProof
+		}
+		value = p.Checkpoint.Hash()
+	}
+	if !state.VerifyProof(root, p.ModelID, value, p.Proof) {
+		return ErrInvalidModelProof
+	}
+	return nil
+}
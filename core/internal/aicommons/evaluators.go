@@ -0,0 +1,676 @@
+// Package aicommons implements the permissioned evaluator role for model benchmarks.
+package aicommons
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/ccoin/core/internal/committee"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Evaluator errors
+var (
+	ErrEvaluatorExists      = errors.New("evaluator already registered")
+	ErrEvaluatorNotFound    = errors.New("evaluator not found")
+	ErrEvaluatorNotApproved = errors.New("evaluator not approved by governance")
+	ErrInsufficientBond     = errors.New("insufficient evaluator bond")
+	ErrInvalidEvaluatorKey  = errors.New("invalid evaluator public key")
+	ErrNotAdmissionProposal = errors.New("not an evaluator admission proposal")
+	ErrProposalNotPassed    = errors.New("admission proposal has not passed")
+	ErrNoEvaluators         = errors.New("no active evaluators")
+	ErrJobNotFound          = errors.New("evaluation job not found")
+	ErrJobFinalized         = errors.New("evaluation job already finalized")
+	ErrInvalidJob           = errors.New("evaluation job committee mismatch")
+	ErrNotAssigned          = errors.New("evaluator not assigned to job")
+	ErrDuplicateAttestation = errors.New("attestation already submitted")
+	ErrInvalidAttestation   = errors.New("invalid attestation")
+	ErrNoQuorum             = errors.New("not enough attestations to finalize")
+)
+
+// EvaluatorStatus represents an evaluator's admission state
+type EvaluatorStatus uint8
+
+const (
+	// EvaluatorPending is bonded and awaiting governance approval
+	EvaluatorPending EvaluatorStatus = iota
+
+	// EvaluatorApproved is eligible for rotation into the active set
+	EvaluatorApproved
+
+	// EvaluatorRemoved was removed by governance or slashed below the bond
+	EvaluatorRemoved
+)
+
+// Evaluator is a registered benchmark evaluator
+type Evaluator struct {
+	Address      types.Address
+	PublicKey    ed25519.PublicKey
+	Bond         uint64
+	Slashed      uint64
+	Status       EvaluatorStatus
+	ProposalID   types.Hash // Admission proposal
+	RegisteredAt uint64
+	Attestations uint64 // Accepted attestations
+	Faults       uint64 // Dishonest or missed attestations
+}
+
+// EvaluatorConfig holds evaluator role parameters
+type EvaluatorConfig struct {
+	// MinBond is the stake an evaluator must bond to register
+	MinBond uint64
+
+	// CommitteeSize is the number of evaluators assigned per job
+	CommitteeSize int
+
+	// ActiveSetSize is the number of evaluators active per rotation
+	ActiveSetSize int
+
+	// RotationPeriod is the number of epochs between rotations
+	RotationPeriod uint64
+
+	// Tolerance is the maximum distance from the median accuracy for an
+	// attestation to count as honest
+	Tolerance float64
+
+	// SlashRate is the fraction of bond slashed per dishonest attestation
+	SlashRate float64
+}
+
+// DefaultEvaluatorConfig returns default evaluator configuration
+func DefaultEvaluatorConfig() *EvaluatorConfig {
+	return &EvaluatorConfig{
+		MinBond:        500000, // 500K CCoin
+		CommitteeSize:  5,
+		ActiveSetSize:  21,
+		RotationPeriod: 1,
+		Tolerance:      0.02, // 2 accuracy points
+		SlashRate:      0.10, // 10%
+	}
+}
+
+// EvaluatorStore defines persistence for evaluators
+type EvaluatorStore interface {
+	SaveEvaluator(ctx context.Context, evaluator *Evaluator) error
+	SaveAttestation(ctx context.Context, attestation *types.AccuracyAttestation) error
+}
+
+// EvaluationResult is the outcome of a finalized evaluation job
+type EvaluationResult struct {
+	JobID        types.Hash
+	ModelID      types.Hash
+	Accuracy     float64 // Median of the submitted attestations
+	Loss         float64
+	Attestations int
+	Dishonest    []types.Address // Slashed for straying from the median
+	Missing      []types.Address // Assigned but never attested
+}
+
+// evaluationRound tracks attestations for one job
+type evaluationRound struct {
+	job          *types.EvaluationJob
+	attestations map[types.Address]*types.AccuracyAttestation
+	finalized    bool
+}
+
+// EvaluatorRegistry manages the permissioned evaluator set: bonding,
+// governance admission, per-epoch rotation, job assignment and slashing
+// of dishonest attestations
+type EvaluatorRegistry struct {
+	mu sync.RWMutex
+
+	config *EvaluatorConfig
+
+	evaluators map[types.Address]*Evaluator
+
+	// Current rotation
+	active        []types.Address
+	rotationEpoch uint64
+	rotated       bool
+
+	// Open and finalized jobs
+	jobs map[types.Hash]*evaluationRound
+
+	// Beacon-driven rotation; nil shuffles by rotation epoch
+	committees CommitteeLog
+
+	// Storage backend
+	store EvaluatorStore
+}
+
+// CommitteeLog selects and records committees from a random beacon
+type CommitteeLog interface {
+	Register(kind types.CommitteeKind, size int, period uint64, source committee.CandidateSource)
+	Committee(ctx context.Context, kind types.CommitteeKind, epoch uint64) (*types.CommitteeRecord, error)
+}
+
+// NewEvaluatorRegistry creates a new evaluator registry
+func NewEvaluatorRegistry(store EvaluatorStore, config *EvaluatorConfig) *EvaluatorRegistry {
+	if config == nil {
+		config = DefaultEvaluatorConfig()
+	}
+
+	return &EvaluatorRegistry{
+		config:     config,
+		evaluators: make(map[types.Address]*Evaluator),
+		jobs:       make(map[types.Hash]*evaluationRound),
+		store:      store,
+	}
+}
+
+// Register bonds stake for a prospective evaluator. The evaluator stays
+// pending until an admission proposal passes.
+func (r *EvaluatorRegistry) Register(ctx context.Context, addr types.Address, pubKey ed25519.PublicKey, bond uint64, currentBlock uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if e, exists := r.evaluators[addr]; exists && e.Status != EvaluatorRemoved {
+		return ErrEvaluatorExists
+	}
+	if len(pubKey) != ed25519.PublicKeySize {
+		return ErrInvalidEvaluatorKey
+	}
+	if bond < r.config.MinBond {
+		return ErrInsufficientBond
+	}
+
+	e := &Evaluator{
+		Address:      addr,
+		PublicKey:    pubKey,
+		Bond:         bond,
+		Status:       EvaluatorPending,
+		RegisteredAt: currentBlock,
+	}
+	r.evaluators[addr] = e
+
+	return r.save(ctx, e)
+}
+
+// ApplyProposal applies a passed evaluator admission proposal
+func (r *EvaluatorRegistry) ApplyProposal(ctx context.Context, proposal *types.Proposal) error {
+	if proposal.Type != types.ProposalEvaluatorAdmission {
+		return ErrNotAdmissionProposal
+	}
+	if proposal.Status != types.ProposalStatusPassed && proposal.Status != types.ProposalStatusExecuted {
+		return ErrProposalNotPassed
+	}
+	data, ok := proposal.Data.(*types.EvaluatorAdmissionData)
+	if !ok {
+		return ErrNotAdmissionProposal
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, exists := r.evaluators[data.Evaluator]
+	if !exists {
+		return ErrEvaluatorNotFound
+	}
+
+	if data.Remove {
+		e.Status = EvaluatorRemoved
+		r.dropActive(e.Address)
+		return r.save(ctx, e)
+	}
+
+	// The key voted on must be the key that was bonded
+	if !e.PublicKey.Equal(ed25519.PublicKey(data.PublicKey)) {
+		return ErrInvalidEvaluatorKey
+	}
+	if e.Status != EvaluatorPending {
+		return nil // Already approved or removed
+	}
+
+	e.Status = EvaluatorApproved
+	e.ProposalID = proposal.ProposalID
+
+	// Recompute the rotation so the new evaluator can fill a free seat
+	if len(r.active) < r.config.ActiveSetSize {
+		r.rotated = false
+	}
+	return r.save(ctx, e)
+}
+
+// SetCommitteeLog draws the active set from log's beacon, weighted by
+// bond and attestation record, instead of shuffling by rotation epoch.
+// Each rotation is recorded so it can be audited.
+func (r *EvaluatorRegistry) SetCommitteeLog(log CommitteeLog) {
+	log.Register(types.CommitteeEvaluator, r.config.ActiveSetSize, r.config.RotationPeriod, r)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.committees = log
+	r.rotated = false
+}
+
+// CommitteeCandidates returns the approved evaluators with their bond as
+// stake and the share of their attestations that were accepted as
+// reputation
+func (r *EvaluatorRegistry) CommitteeCandidates(ctx context.Context, epoch uint64) ([]types.CommitteeCandidate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	candidates := make([]types.CommitteeCandidate, 0, len(r.evaluators))
+	for addr, e := range r.evaluators {
+		if e.Status != EvaluatorApproved {
+			continue
+		}
+		candidates = append(candidates, types.CommitteeCandidate{
+			Address:    addr,
+			Stake:      e.Bond,
+			Reputation: float64(e.Attestations+1) / float64(e.Attestations+e.Faults+1),
+		})
+	}
+	return candidates, nil
+}
+
+// Rotate selects the active evaluator set for epoch. The set changes only
+// every RotationPeriod epochs; each rotation reshuffles approved
+// evaluators by a hash of the rotation epoch, or draws them from the
+// committee log if one is set.
+func (r *EvaluatorRegistry) Rotate(epoch uint64) []types.Address {
+	record, err := r.committeeRecord(epoch)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err == nil {
+		r.rotateLocked(epoch, record)
+	}
+	return append([]types.Address(nil), r.active...)
+}
+
+// committeeRecord returns the logged selection serving at epoch, or nil
+// without a committee log. It must be called without holding the lock,
+// as the log reads the candidates back from the registry.
+func (r *EvaluatorRegistry) committeeRecord(epoch uint64) (*types.CommitteeRecord, error) {
+	r.mu.RLock()
+	log := r.committees
+	r.mu.RUnlock()
+
+	if log == nil {
+		return nil, nil
+	}
+	return log.Committee(context.Background(), types.CommitteeEvaluator, epoch)
+}
+
+// rotateLocked updates the active set if a new rotation has started.
+// record is the logged selection for the rotation, if any.
+func (r *EvaluatorRegistry) rotateLocked(epoch uint64, record *types.CommitteeRecord) {
+	period := r.config.RotationPeriod
+	if period == 0 {
+		period = 1
+	}
+	start := epoch - epoch%period
+	if r.rotated && start == r.rotationEpoch {
+		return
+	}
+
+	var approved []types.Address
+	if record != nil {
+		// Members slashed out since the draw lose their seat
+		for _, addr := range record.Members {
+			if e, exists := r.evaluators[addr]; exists && e.Status == EvaluatorApproved {
+				approved = append(approved, addr)
+			}
+		}
+	} else {
+		for addr, e := range r.evaluators {
+			if e.Status == EvaluatorApproved {
+				approved = append(approved, addr)
+			}
+		}
+
+		seed := make([]byte, 8)
+		binary.BigEndian.PutUint64(seed, start)
+		sortBySeed(approved, seed)
+
+		if len(approved) > r.config.ActiveSetSize {
+			approved = approved[:r.config.ActiveSetSize]
+		}
+	}
+
+	r.active = approved
+	r.rotationEpoch = start
+	r.rotated = true
+}
+
+// Active returns the current active evaluator set
+func (r *EvaluatorRegistry) Active() []types.Address {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]types.Address(nil), r.active...)
+}
+
+// CreateJob opens an evaluation job and assigns a committee from the
+// epoch's active set
+func (r *EvaluatorRegistry) CreateJob(modelID types.Hash, weightsCID string, benchmarkID types.Hash, epoch, deadline uint64) (*types.EvaluationJob, error) {
+	record, err := r.committeeRecord(epoch)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job := &types.EvaluationJob{
+		ModelID:     modelID,
+		WeightsCID:  weightsCID,
+		BenchmarkID: benchmarkID,
+		Epoch:       epoch,
+		Deadline:    deadline,
+	}
+	job.JobID = job.ComputeID()
+
+	if round, exists := r.jobs[job.JobID]; exists {
+		return round.job, nil
+	}
+
+	r.rotateLocked(epoch, record)
+	job.Evaluators = r.committeeLocked(job.JobID)
+	if len(job.Evaluators) == 0 {
+		return nil, ErrNoEvaluators
+	}
+
+	r.jobs[job.JobID] = &evaluationRound{
+		job:          job,
+		attestations: make(map[types.Address]*types.AccuracyAttestation),
+	}
+	return job, nil
+}
+
+// AddJob tracks a job created by another node after checking that its
+// committee matches the local assignment
+func (r *EvaluatorRegistry) AddJob(job *types.EvaluationJob) error {
+	record, err := r.committeeRecord(job.Epoch)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if job.JobID != job.ComputeID() {
+		return ErrInvalidJob
+	}
+	if _, exists := r.jobs[job.JobID]; exists {
+		return nil
+	}
+
+	r.rotateLocked(job.Epoch, record)
+	committee := r.committeeLocked(job.JobID)
+	if len(committee) != len(job.Evaluators) {
+		return ErrInvalidJob
+	}
+	for i := range committee {
+		if committee[i] != job.Evaluators[i] {
+			return ErrInvalidJob
+		}
+	}
+
+	r.jobs[job.JobID] = &evaluationRound{
+		job:          job,
+		attestations: make(map[types.Address]*types.AccuracyAttestation),
+	}
+	return nil
+}
+
+// committeeLocked picks CommitteeSize evaluators from the active set,
+// ordered by a hash of the job ID
+func (r *EvaluatorRegistry) committeeLocked(jobID types.Hash) []types.Address {
+	committee := append([]types.Address(nil), r.active...)
+	sortBySeed(committee, jobID[:])
+	if len(committee) > r.config.CommitteeSize {
+		committee = committee[:r.config.CommitteeSize]
+	}
+	return committee
+}
+
+// SubmitAttestation records a signed attestation from an assigned evaluator
+func (r *EvaluatorRegistry) SubmitAttestation(ctx context.Context, att *types.AccuracyAttestation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	round, exists := r.jobs[att.JobID]
+	if !exists {
+		return ErrJobNotFound
+	}
+	if round.finalized {
+		return ErrJobFinalized
+	}
+	if !containsEvaluator(round.job.Evaluators, att.Evaluator) {
+		return ErrNotAssigned
+	}
+	if _, exists := round.attestations[att.Evaluator]; exists {
+		return ErrDuplicateAttestation
+	}
+
+	e, exists := r.evaluators[att.Evaluator]
+	if !exists {
+		return ErrEvaluatorNotFound
+	}
+	if e.Status != EvaluatorApproved {
+		return ErrEvaluatorNotApproved
+	}
+
+	if math.IsNaN(att.Accuracy) || att.Accuracy < 0 || att.Accuracy > 1 {
+		return ErrInvalidAttestation
+	}
+	if !att.Verify(e.PublicKey) {
+		return ErrInvalidAttestation
+	}
+
+	round.attestations[att.Evaluator] = att
+	if r.store != nil {
+		return r.store.SaveAttestation(ctx, att)
+	}
+	return nil
+}
+
+// Finalize closes a job, takes the median attested accuracy and slashes
+// evaluators whose attestation strays from it by more than Tolerance.
+// Assigned evaluators that never attested are recorded as faults.
+func (r *EvaluatorRegistry) Finalize(ctx context.Context, jobID types.Hash) (*EvaluationResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	round, exists := r.jobs[jobID]
+	if !exists {
+		return nil, ErrJobNotFound
+	}
+	if round.finalized {
+		return nil, ErrJobFinalized
+	}
+	round.finalized = true
+
+	result := &EvaluationResult{
+		JobID:        jobID,
+		ModelID:      round.job.ModelID,
+		Attestations: len(round.attestations),
+	}
+
+	for _, addr := range round.job.Evaluators {
+		if _, attested := round.attestations[addr]; !attested {
+			result.Missing = append(result.Missing, addr)
+			if e, exists := r.evaluators[addr]; exists {
+				e.Faults++
+				if err := r.save(ctx, e); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// A strict majority of the committee must attest
+	if len(round.attestations)*2 <= len(round.job.Evaluators) {
+		return result, ErrNoQuorum
+	}
+
+	accuracies := make([]float64, 0, len(round.attestations))
+	losses := make([]float64, 0, len(round.attestations))
+	for _, att := range round.attestations {
+		accuracies = append(accuracies, att.Accuracy)
+		losses = append(losses, att.Loss)
+	}
+	result.Accuracy = median(accuracies)
+	result.Loss = median(losses)
+
+	for _, addr := range round.job.Evaluators {
+		att, attested := round.attestations[addr]
+		if !attested {
+			continue
+		}
+		e := r.evaluators[addr]
+		if math.Abs(att.Accuracy-result.Accuracy) > r.config.Tolerance {
+			result.Dishonest = append(result.Dishonest, addr)
+			r.slashLocked(e)
+		} else {
+			e.Attestations++
+		}
+		if err := r.save(ctx, e); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// slashLocked slashes an evaluator's bond, removing it once the bond
+// falls below the minimum
+func (r *EvaluatorRegistry) slashLocked(e *Evaluator) {
+	amount := uint64(float64(e.Bond) * r.config.SlashRate)
+	e.Bond -= amount
+	e.Slashed += amount
+	e.Faults++
+
+	if e.Bond < r.config.MinBond {
+		e.Status = EvaluatorRemoved
+		r.dropActive(e.Address)
+	}
+}
+
+// dropActive removes an evaluator from the current rotation
+func (r *EvaluatorRegistry) dropActive(addr types.Address) {
+	for i, a := range r.active {
+		if a == addr {
+			r.active = append(r.active[:i], r.active[i+1:]...)
+			return
+		}
+	}
+}
+
+// GetEvaluator returns an evaluator by address
+func (r *EvaluatorRegistry) GetEvaluator(addr types.Address) *Evaluator {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.evaluators[addr]
+}
+
+// Attestations returns a job and its attestations in committee order
+func (r *EvaluatorRegistry) Attestations(jobID types.Hash) (*types.EvaluationJob, []*types.AccuracyAttestation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	round, exists := r.jobs[jobID]
+	if !exists {
+		return nil, nil, ErrJobNotFound
+	}
+
+	atts := make([]*types.AccuracyAttestation, 0, len(round.attestations))
+	for _, addr := range round.job.Evaluators {
+		if att, attested := round.attestations[addr]; attested {
+			atts = append(atts, att)
+		}
+	}
+	return round.job, atts, nil
+}
+
+// save persists an evaluator when a store is configured
+func (r *EvaluatorRegistry) save(ctx context.Context, e *Evaluator) error {
+	if r.store == nil {
+		return nil
+	}
+	return r.store.SaveEvaluator(ctx, e)
+}
+
+// Benchmarker runs a model benchmark
+type Benchmarker interface {
+	Benchmark(ctx context.Context, weightsCID string, benchmarkID types.Hash) (accuracy, loss float64, err error)
+}
+
+// EvaluatorNode runs the jobs assigned to a local evaluator key
+type EvaluatorNode struct {
+	address types.Address
+	key     ed25519.PrivateKey
+	bench   Benchmarker
+}
+
+// NewEvaluatorNode creates an evaluator node signing with key
+func NewEvaluatorNode(addr types.Address, key ed25519.PrivateKey, bench Benchmarker) *EvaluatorNode {
+	return &EvaluatorNode{address: addr, key: key, bench: bench}
+}
+
+// Assigned reports whether the job's committee includes this evaluator
+func (n *EvaluatorNode) Assigned(job *types.EvaluationJob) bool {
+	return containsEvaluator(job.Evaluators, n.address)
+}
+
+// Evaluate benchmarks the job's weights and returns a signed attestation
+func (n *EvaluatorNode) Evaluate(ctx context.Context, job *types.EvaluationJob) (*types.AccuracyAttestation, error) {
+	if !n.Assigned(job) {
+		return nil, ErrNotAssigned
+	}
+
+	accuracy, loss, err := n.bench.Benchmark(ctx, job.WeightsCID, job.BenchmarkID)
+	if err != nil {
+		return nil, err
+	}
+
+	att := &types.AccuracyAttestation{
+		JobID:     job.JobID,
+		Evaluator: n.address,
+		Accuracy:  accuracy,
+		Loss:      loss,
+	}
+	digest := att.SigningHash()
+	att.Signature = ed25519.Sign(n.key, digest[:])
+	return att, nil
+}
+
+// sortBySeed orders addresses by H(seed || address)
+func sortBySeed(addrs []types.Address, seed []byte) {
+	keys := make(map[types.Address][32]byte, len(addrs))
+	for _, a := range addrs {
+		keys[a] = sha256.Sum256(append(append([]byte{}, seed...), a[:]...))
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		ki, kj := keys[addrs[i]], keys[addrs[j]]
+		return string(ki[:]) < string(kj[:])
+	})
+}
+
+// containsEvaluator reports whether addr is in list
+func containsEvaluator(list []types.Address, addr types.Address) bool {
+	for _, a := range list {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// median returns the median of values, sorting them in place
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
@@ -0,0 +1,449 @@
+package aicommons
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"sync"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Inference errors
+var (
+	ErrRequestExists       = errors.New("inference request already exists")
+	ErrRequestNotFound     = errors.New("inference request not found")
+	ErrInvalidRequest      = errors.New("invalid inference request")
+	ErrInsufficientEscrow  = errors.New("inference escrow below minimum")
+	ErrRequestClosed       = errors.New("inference request no longer accepts this operation")
+	ErrRequestTimedOut     = errors.New("inference request timed out")
+	ErrNodeNotFound        = errors.New("inference node not found")
+	ErrInvalidNodeKey      = errors.New("invalid inference node public key")
+	ErrNodeCannotServe     = errors.New("inference node cannot serve model")
+	ErrInvalidResult       = errors.New("invalid inference result signature")
+	ErrInvalidDispute      = errors.New("invalid inference dispute signature")
+	ErrDisputeWindowClosed = errors.New("dispute window closed")
+	ErrInvalidInferenceOp  = errors.New("invalid inference operation")
+)
+
+// InferenceStatus represents the state of an escrowed inference request
+type InferenceStatus uint8
+
+const (
+	// InferencePending is waiting for a serving node's result
+	InferencePending InferenceStatus = iota
+
+	// InferenceAnswered has a result inside its dispute window
+	InferenceAnswered
+
+	// InferenceDisputed is awaiting evaluator re-execution
+	InferenceDisputed
+
+	// InferenceSettled paid the escrow to the serving node
+	InferenceSettled
+
+	// InferenceRefunded returned the escrow to the requester
+	InferenceRefunded
+)
+
+// InferenceConfig holds on-chain inference parameters
+type InferenceConfig struct {
+	// MinEscrow is the smallest fee a request may escrow
+	MinEscrow uint64
+
+	// MaxTimeout is the longest a request may wait for a result, in blocks
+	MaxTimeout uint64
+
+	// DisputeWindow is the number of blocks after a result during which
+	// the requester may dispute it
+	DisputeWindow uint64
+
+	// DisputePeriod is the number of blocks evaluators have to re-execute
+	// a disputed request
+	DisputePeriod uint64
+}
+
+// DefaultInferenceConfig returns default inference configuration
+func DefaultInferenceConfig() *InferenceConfig {
+	return &InferenceConfig{
+		MinEscrow:     1000,
+		MaxTimeout:    8640, // ~1 day at 10s blocks
+		DisputeWindow: 360,  // ~1 hour
+		DisputePeriod: 360,
+	}
+}
+
+// LicenseVerifier checks and meters the license a node serves under
+type LicenseVerifier interface {
+	VerifyLicense(ctx context.Context, licenseID, modelID types.Hash, licensee types.Address, currentBlock uint64) error
+	RecordInference(ctx context.Context, licenseID types.Hash) error
+}
+
+// InferenceStore defines persistence for escrowed requests
+type InferenceStore interface {
+	SaveInferenceEscrow(ctx context.Context, escrow *InferenceEscrow) error
+}
+
+// InferenceEscrow is the on-chain state of one inference request
+type InferenceEscrow struct {
+	Request    *types.InferenceRequest
+	Result     *types.InferenceResult
+	Status     InferenceStatus
+	WeightsCID string // Weights the request is served from
+	OpenedAt   uint64
+	AnsweredAt uint64
+	DisputeJob *types.EvaluationJob
+}
+
+// InferencePayout releases escrow to a node or back to the requester
+type InferencePayout struct {
+	RequestID types.Hash
+	Recipient types.Address
+	Amount    uint64
+	Refund    bool
+}
+
+// inferenceNode is a registered serving node and its signing key
+type inferenceNode struct {
+	node   *types.InferenceNode
+	pubKey ed25519.PublicKey
+}
+
+// InferenceMarket runs the optional on-chain inference flow: requests
+// escrow a fee against a committed input, serving nodes post result
+// commitments under a model license, disputed results are re-executed by
+// an evaluator committee, and unanswered requests are refunded on timeout
+type InferenceMarket struct {
+	mu sync.Mutex
+
+	config *InferenceConfig
+
+	nodes   map[types.Address]*inferenceNode
+	escrows map[types.Hash]*InferenceEscrow
+
+	models     *ModelRegistry
+	evaluators *EvaluatorRegistry
+	licenses   LicenseVerifier
+
+	// Storage backend
+	store InferenceStore
+}
+
+// NewInferenceMarket creates a new inference market
+func NewInferenceMarket(models *ModelRegistry, evaluators *EvaluatorRegistry, licenses LicenseVerifier, store InferenceStore, config *InferenceConfig) *InferenceMarket {
+	if config == nil {
+		config = DefaultInferenceConfig()
+	}
+
+	return &InferenceMarket{
+		config:     config,
+		nodes:      make(map[types.Address]*inferenceNode),
+		escrows:    make(map[types.Hash]*InferenceEscrow),
+		models:     models,
+		evaluators: evaluators,
+		licenses:   licenses,
+		store:      store,
+	}
+}
+
+// RegisterNode registers a serving node and the key it signs results with
+func (m *InferenceMarket) RegisterNode(node *types.InferenceNode, pubKey ed25519.PublicKey) error {
+	if len(pubKey) != ed25519.PublicKeySize {
+		return ErrInvalidNodeKey
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nodes[node.Address] = &inferenceNode{node: node, pubKey: pubKey}
+	return nil
+}
+
+// GetNode returns a registered serving node
+func (m *InferenceMarket) GetNode(addr types.Address) *types.InferenceNode {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if n, exists := m.nodes[addr]; exists {
+		return n.node
+	}
+	return nil
+}
+
+// Apply applies a transaction's inference operation at height
+func (m *InferenceMarket) Apply(ctx context.Context, op *types.InferenceOp, height, epoch uint64) error {
+	switch {
+	case op.Type == types.InferenceOpRequest && op.Request != nil:
+		return m.SubmitRequest(ctx, op.Request, height)
+	case op.Type == types.InferenceOpResult && op.Result != nil:
+		return m.PostResult(ctx, op.Result, height)
+	case op.Type == types.InferenceOpDispute && op.Dispute != nil:
+		return m.Dispute(ctx, op.Dispute, height, epoch)
+	default:
+		return ErrInvalidInferenceOp
+	}
+}
+
+// SubmitRequest escrows a request's fee. The request is served from the
+// model's weights at the time of submission.
+func (m *InferenceMarket) SubmitRequest(ctx context.Context, req *types.InferenceRequest, height uint64) error {
+	if req.RequestID != req.ComputeID() || len(req.RequesterKey) != ed25519.PublicKeySize {
+		return ErrInvalidRequest
+	}
+	if req.Timeout == 0 || req.Timeout > m.config.MaxTimeout {
+		return ErrInvalidRequest
+	}
+	if req.Escrow < m.config.MinEscrow {
+		return ErrInsufficientEscrow
+	}
+
+	model, err := m.models.GetModel(ctx, req.ModelID)
+	if err != nil {
+		return err
+	}
+	if model.CurrentWeights == "" {
+		return ErrInvalidRequest
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.escrows[req.RequestID]; exists {
+		return ErrRequestExists
+	}
+
+	escrow := &InferenceEscrow{
+		Request:    req,
+		Status:     InferencePending,
+		WeightsCID: model.CurrentWeights,
+		OpenedAt:   height,
+	}
+	m.escrows[req.RequestID] = escrow
+	return m.save(ctx, escrow)
+}
+
+// PostResult records a serving node's result commitment. The node must be
+// able to serve the model, hold a valid license for it and sign the result.
+func (m *InferenceMarket) PostResult(ctx context.Context, res *types.InferenceResult, height uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	escrow, exists := m.escrows[res.RequestID]
+	if !exists {
+		return ErrRequestNotFound
+	}
+	if escrow.Status != InferencePending {
+		return ErrRequestClosed
+	}
+	if height > escrow.OpenedAt+escrow.Request.Timeout {
+		return ErrRequestTimedOut
+	}
+
+	n, exists := m.nodes[res.Node]
+	if !exists {
+		return ErrNodeNotFound
+	}
+	if !n.node.CanServe() || !hostsModel(n.node, escrow.Request.ModelID) {
+		return ErrNodeCannotServe
+	}
+	if !res.Verify(n.pubKey) {
+		return ErrInvalidResult
+	}
+	if err := m.licenses.VerifyLicense(ctx, res.LicenseID, escrow.Request.ModelID, res.Node, height); err != nil {
+		return err
+	}
+
+	escrow.Result = res
+	escrow.Status = InferenceAnswered
+	escrow.AnsweredAt = height
+	n.node.LastActiveBlock = height
+	return m.save(ctx, escrow)
+}
+
+// Dispute challenges a posted result inside the dispute window and opens
+// an evaluation job for re-execution. The job benchmarks the request's
+// weights with the request ID as its benchmark; see DisputeBenchmarker.
+func (m *InferenceMarket) Dispute(ctx context.Context, d *types.InferenceDispute, height, epoch uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	escrow, exists := m.escrows[d.RequestID]
+	if !exists {
+		return ErrRequestNotFound
+	}
+	if escrow.Status != InferenceAnswered {
+		return ErrRequestClosed
+	}
+	if height > escrow.AnsweredAt+m.config.DisputeWindow {
+		return ErrDisputeWindowClosed
+	}
+	if !d.Verify(escrow.Request.RequesterKey) {
+		return ErrInvalidDispute
+	}
+
+	job, err := m.evaluators.CreateJob(escrow.Request.ModelID, escrow.WeightsCID, d.RequestID, epoch, height+m.config.DisputePeriod)
+	if err != nil {
+		return err
+	}
+
+	escrow.Status = InferenceDisputed
+	escrow.DisputeJob = job
+	return m.save(ctx, escrow)
+}
+
+// ProcessBlock settles requests whose deadlines have passed at height:
+// unanswered requests are refunded, undisputed results are paid and
+// disputed results are resolved by the evaluator committee
+func (m *InferenceMarket) ProcessBlock(ctx context.Context, height uint64) ([]*InferencePayout, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var payouts []*InferencePayout
+	for _, escrow := range m.escrows {
+		var payout *InferencePayout
+		var err error
+
+		switch escrow.Status {
+		case InferencePending:
+			if height > escrow.OpenedAt+escrow.Request.Timeout {
+				payout = m.refundLocked(escrow)
+			}
+		case InferenceAnswered:
+			if height > escrow.AnsweredAt+m.config.DisputeWindow {
+				payout, err = m.payNodeLocked(ctx, escrow)
+			}
+		case InferenceDisputed:
+			if height > escrow.DisputeJob.Deadline {
+				payout, err = m.resolveLocked(ctx, escrow)
+			}
+		}
+		if err != nil {
+			return payouts, err
+		}
+		if payout == nil {
+			continue
+		}
+		if err := m.save(ctx, escrow); err != nil {
+			return payouts, err
+		}
+		payouts = append(payouts, payout)
+	}
+	return payouts, nil
+}
+
+// resolveLocked finalizes a dispute job. Evaluators attest accuracy 1
+// when their re-execution reproduces the committed result, so a median
+// of at least one half upholds the node. Without a quorum the requester
+// is refunded.
+func (m *InferenceMarket) resolveLocked(ctx context.Context, escrow *InferenceEscrow) (*InferencePayout, error) {
+	result, err := m.evaluators.Finalize(ctx, escrow.DisputeJob.JobID)
+	if err != nil && !errors.Is(err, ErrNoQuorum) {
+		return nil, err
+	}
+
+	if err == nil && result.Accuracy >= 0.5 {
+		return m.payNodeLocked(ctx, escrow)
+	}
+
+	if n, exists := m.nodes[escrow.Result.Node]; exists {
+		n.node.TotalQueries++
+	}
+	return m.refundLocked(escrow), nil
+}
+
+// payNodeLocked releases escrow to the serving node
+func (m *InferenceMarket) payNodeLocked(ctx context.Context, escrow *InferenceEscrow) (*InferencePayout, error) {
+	if err := m.licenses.RecordInference(ctx, escrow.Result.LicenseID); err != nil {
+		return nil, err
+	}
+	if n, exists := m.nodes[escrow.Result.Node]; exists {
+		n.node.TotalQueries++
+		n.node.CorrectResults++
+	}
+
+	escrow.Status = InferenceSettled
+	return &InferencePayout{
+		RequestID: escrow.Request.RequestID,
+		Recipient: escrow.Result.Node,
+		Amount:    escrow.Request.Escrow,
+	}, nil
+}
+
+// refundLocked returns escrow to the requester
+func (m *InferenceMarket) refundLocked(escrow *InferenceEscrow) *InferencePayout {
+	escrow.Status = InferenceRefunded
+	return &InferencePayout{
+		RequestID: escrow.Request.RequestID,
+		Recipient: escrow.Request.Refund,
+		Amount:    escrow.Request.Escrow,
+		Refund:    true,
+	}
+}
+
+// GetEscrow returns the escrow state of a request
+func (m *InferenceMarket) GetEscrow(requestID types.Hash) (*InferenceEscrow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	escrow, exists := m.escrows[requestID]
+	if !exists {
+		return nil, ErrRequestNotFound
+	}
+	return escrow, nil
+}
+
+// save persists an escrow when a store is configured
+func (m *InferenceMarket) save(ctx context.Context, escrow *InferenceEscrow) error {
+	if m.store == nil {
+		return nil
+	}
+	return m.store.SaveInferenceEscrow(ctx, escrow)
+}
+
+// hostsModel reports whether a node serves modelID
+func hostsModel(node *types.InferenceNode, modelID types.Hash) bool {
+	for _, id := range node.HostedModels {
+		if id == modelID {
+			return true
+		}
+	}
+	return false
+}
+
+// Reexecutor runs a model on the input behind a committed input hash
+type Reexecutor interface {
+	Infer(ctx context.Context, weightsCID string, inputHash types.Hash) (resultHash types.Hash, err error)
+}
+
+// DisputeBenchmarker is the Benchmarker evaluators use to re-execute
+// disputed requests. Jobs whose benchmark ID names a disputed request are
+// re-run and scored 1 if the output matches the committed result and 0
+// otherwise; all other jobs go to the fallback benchmarker.
+type DisputeBenchmarker struct {
+	market   *InferenceMarket
+	exec     Reexecutor
+	fallback Benchmarker
+}
+
+// NewDisputeBenchmarker creates a dispute benchmarker
+func NewDisputeBenchmarker(market *InferenceMarket, exec Reexecutor, fallback Benchmarker) *DisputeBenchmarker {
+	return &DisputeBenchmarker{market: market, exec: exec, fallback: fallback}
+}
+
+// Benchmark implements Benchmarker
+func (b *DisputeBenchmarker) Benchmark(ctx context.Context, weightsCID string, benchmarkID types.Hash) (float64, float64, error) {
+	escrow, err := b.market.GetEscrow(benchmarkID)
+	if err != nil || escrow.Status != InferenceDisputed {
+		if b.fallback == nil {
+			return 0, 0, ErrRequestNotFound
+		}
+		return b.fallback.Benchmark(ctx, weightsCID, benchmarkID)
+	}
+
+	resultHash, err := b.exec.Infer(ctx, weightsCID, escrow.Request.InputHash)
+	if err != nil {
+		return 0, 0, err
+	}
+	if resultHash == escrow.Result.ResultHash {
+		return 1, 0, nil
+	}
+	return 0, 1, nil
+}
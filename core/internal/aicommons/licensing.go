@@ -15,6 +15,8 @@ var (
 	ErrLicenseExpired      = errors.New("license expired")
 	ErrInsufficientPayment = errors.New("insufficient payment for license")
 	ErrCommercialNotAllowed = errors.New("commercial use not allowed")
+	ErrLicenseMismatch     = errors.New("license does not cover model and licensee")
+	ErrLicenseRevoked      = errors.New("license revoked")
 )
 
 // LicenseManager manages model licensing
@@ -30,6 +32,9 @@ type LicenseManager struct {
 	// Revenue tracking
 	revenue map[types.Hash]uint64 // modelID -> total revenue
 
+	// onRevoke is called when a license is revoked
+	onRevoke func(licenseID types.Hash, reason string)
+
 	// Storage
 	store LicenseStore
 }
@@ -46,6 +51,10 @@ type License struct {
 	UsedInferences uint64
 	CommercialUse bool
 	PaymentAmount uint64
+
+	// Revoked licenses no longer permit use; RevokedReason says why
+	Revoked       bool
+	RevokedReason string
 }
 
 // LicenseTemplate defines terms for a license type
@@ -186,19 +195,74 @@ func (lm *LicenseManager) generateLicenseID(modelID types.Hash, licensee types.A
 	return id
 }
 
-// CheckLicense verifies a license is valid for use
-func (lm *LicenseManager) CheckLicense(ctx context.Context, licenseID types.Hash, currentBlock uint64) error {
+// GetLicense returns a license by ID
+func (lm *LicenseManager) GetLicense(ctx context.Context, licenseID types.Hash) (*License, error) {
 	lm.mu.RLock()
 	license, exists := lm.licenses[licenseID]
 	lm.mu.RUnlock()
+	if exists {
+		return license, nil
+	}
 
-	if !exists {
-		// Try to load from store
-		var err error
-		license, err = lm.store.GetLicense(ctx, licenseID)
-		if err != nil || license == nil {
-			return ErrLicenseNotFound
-		}
+	license, err := lm.store.GetLicense(ctx, licenseID)
+	if err != nil || license == nil {
+		return nil, ErrLicenseNotFound
+	}
+	return license, nil
+}
+
+// Licensee returns the address holding a license
+func (lm *LicenseManager) Licensee(ctx context.Context, licenseID types.Hash) (types.Address, error) {
+	license, err := lm.GetLicense(ctx, licenseID)
+	if err != nil {
+		return types.Address{}, err
+	}
+	return license.LicenseeAddr, nil
+}
+
+// SetRevocationHandler registers fn to be called when a license is
+// revoked. It is called without the manager's lock held.
+func (lm *LicenseManager) SetRevocationHandler(fn func(licenseID types.Hash, reason string)) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	lm.onRevoke = fn
+}
+
+// RevokeLicense revokes a license, such as one whose terms were breached
+// or whose model was withdrawn
+func (lm *LicenseManager) RevokeLicense(ctx context.Context, licenseID types.Hash, reason string) error {
+	license, err := lm.GetLicense(ctx, licenseID)
+	if err != nil {
+		return err
+	}
+
+	lm.mu.Lock()
+	if license.Revoked {
+		lm.mu.Unlock()
+		return nil
+	}
+	license.Revoked = true
+	license.RevokedReason = reason
+	lm.licenses[licenseID] = license
+	err = lm.store.SaveLicense(ctx, license)
+	handler := lm.onRevoke
+	lm.mu.Unlock()
+
+	if err == nil && handler != nil {
+		handler(licenseID, reason)
+	}
+	return err
+}
+
+// CheckLicense verifies a license is valid for use
+func (lm *LicenseManager) CheckLicense(ctx context.Context, licenseID types.Hash, currentBlock uint64) error {
+	license, err := lm.GetLicense(ctx, licenseID)
+	if err != nil {
+		return err
+	}
+
+	if license.Revoked {
+		return ErrLicenseRevoked
 	}
 
 	// Check expiry
@@ -214,6 +278,28 @@ func (lm *LicenseManager) CheckLicense(ctx context.Context, licenseID types.Hash
 	return nil
 }
 
+// VerifyLicense checks that a valid license for modelID is held by licensee
+func (lm *LicenseManager) VerifyLicense(ctx context.Context, licenseID, modelID types.Hash, licensee types.Address, currentBlock uint64) error {
+	if err := lm.CheckLicense(ctx, licenseID, currentBlock); err != nil {
+		return err
+	}
+
+	lm.mu.RLock()
+	license, exists := lm.licenses[licenseID]
+	lm.mu.RUnlock()
+	if !exists {
+		var err error
+		if license, err = lm.store.GetLicense(ctx, licenseID); err != nil || license == nil {
+			return ErrLicenseNotFound
+		}
+	}
+
+	if license.ModelID != modelID || license.LicenseeAddr != licensee {
+		return ErrLicenseMismatch
+	}
+	return nil
+}
+
 // RecordInference records an inference against a license
 func (lm *LicenseManager) RecordInference(ctx context.Context, licenseID types.Hash) error {
 	lm.mu.Lock()
@@ -307,6 +393,9 @@ func (lm *LicenseManager) GetActiveLicenses(ctx context.Context, addr types.Addr
 
 	active := make([]*License, 0)
 	for _, lic := range all {
+		if lic.Revoked {
+			continue
+		}
 		if lic.ExpiresAt == 0 || currentBlock <= lic.ExpiresAt {
 			active = append(active, lic)
 		}
@@ -0,0 +1,306 @@
+package aicommons
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Pipeline errors
+var (
+	ErrInvalidPipeline    = errors.New("pipeline stages must depend only on earlier stages")
+	ErrPipelineExists     = errors.New("pipeline already exists")
+	ErrPipelineNotFound   = errors.New("pipeline not found")
+	ErrUnknownDependency  = errors.New("unknown task dependency")
+	ErrAssignmentNotFound = errors.New("assignment not found")
+	ErrResultNotSubmitted = errors.New("task result not submitted")
+)
+
+// TaskSpec describes one stage of a pipeline
+type TaskSpec struct {
+	ModelID    types.Hash
+	DatasetCID string
+	BatchStart uint32
+	BatchEnd   uint32
+	Reward     uint64
+
+	// DependsOn indexes the earlier stages this stage consumes
+	DependsOn []int
+}
+
+// Pipeline is a DAG of training tasks, possibly spanning several models,
+// scheduled for one round (e.g. preprocess -> train -> distill)
+type Pipeline struct {
+	PipelineID  types.Hash
+	Round       uint64
+	Tasks       []types.Hash // In stage order
+	Verified    int
+	CreatedAt   uint64
+	CompletedAt uint64 // Block the last stage was verified, 0 while running
+}
+
+// Complete reports whether every stage has been verified
+func (p *Pipeline) Complete() bool {
+	return p.Verified == len(p.Tasks)
+}
+
+// RoundProgress summarizes pipeline completion for a round
+type RoundProgress struct {
+	Round         uint64
+	Pipelines     int
+	Completed     int
+	Tasks         int
+	VerifiedTasks int
+}
+
+// CreatePipeline creates the tasks of a pipeline for round. A stage may
+// depend only on earlier stages, so the pipeline is acyclic by
+// construction. Stages without dependencies are released immediately;
+// the rest wait until all their prerequisites are verified.
+func (ta *TaskAssigner) CreatePipeline(ctx context.Context, round uint64, specs []TaskSpec, currentBlock uint64) (*Pipeline, error) {
+	if len(specs) == 0 {
+		return nil, ErrInvalidPipeline
+	}
+	for i, spec := range specs {
+		for _, dep := range spec.DependsOn {
+			if dep < 0 || dep >= i {
+				return nil, ErrInvalidPipeline
+			}
+		}
+		if _, err := ta.registry.GetModel(ctx, spec.ModelID); err != nil {
+			return nil, err
+		}
+	}
+
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+
+	tasks := make([]*TrainingTask, len(specs))
+	for i, spec := range specs {
+		task := &TrainingTask{
+			ModelID:    spec.ModelID,
+			DatasetCID: spec.DatasetCID,
+			BatchStart: spec.BatchStart,
+			BatchEnd:   spec.BatchEnd,
+			CreatedAt:  currentBlock,
+			Deadline:   currentBlock + ta.config.DefaultDeadline,
+			Reward:     spec.Reward,
+		}
+		for _, dep := range spec.DependsOn {
+			task.Dependencies = append(task.Dependencies, tasks[dep].TaskID)
+		}
+		task.TaskID = ta.generateTaskID(task)
+		task.Objective = ta.generateObjective(task)
+		tasks[i] = task
+	}
+
+	pipeline := &Pipeline{
+		Round:     round,
+		Tasks:     make([]types.Hash, len(tasks)),
+		CreatedAt: currentBlock,
+	}
+	data := uint64ToBytes(round)
+	for i, task := range tasks {
+		pipeline.Tasks[i] = task.TaskID
+		data = append(data, task.TaskID[:]...)
+	}
+	pipeline.PipelineID = sha256.Sum256(data)
+
+	if _, exists := ta.pipelines[pipeline.PipelineID]; exists {
+		return nil, ErrPipelineExists
+	}
+
+	for _, task := range tasks {
+		task.PipelineID = pipeline.PipelineID
+		ta.scheduleLocked(task)
+	}
+	ta.pipelines[pipeline.PipelineID] = pipeline
+	ta.rounds[round] = append(ta.rounds[round], pipeline.PipelineID)
+
+	return pipeline, nil
+}
+
+// CreateDependentTask creates a standalone task that is released once
+// every task in deps has been verified
+func (ta *TaskAssigner) CreateDependentTask(
+	ctx context.Context,
+	modelID types.Hash,
+	datasetCID string,
+	batchStart, batchEnd uint32,
+	reward uint64,
+	currentBlock uint64,
+	deps []types.Hash,
+) (*TrainingTask, error) {
+	if _, err := ta.registry.GetModel(ctx, modelID); err != nil {
+		return nil, err
+	}
+
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+
+	for _, dep := range deps {
+		if !ta.knownLocked(dep) {
+			return nil, ErrUnknownDependency
+		}
+	}
+
+	task := &TrainingTask{
+		ModelID:      modelID,
+		DatasetCID:   datasetCID,
+		BatchStart:   batchStart,
+		BatchEnd:     batchEnd,
+		CreatedAt:    currentBlock,
+		Deadline:     currentBlock + ta.config.DefaultDeadline,
+		Reward:       reward,
+		Dependencies: deps,
+	}
+	task.TaskID = ta.generateTaskID(task)
+	task.Objective = ta.generateObjective(task)
+
+	ta.scheduleLocked(task)
+	return task, nil
+}
+
+// scheduleLocked queues a task for assignment, or parks it until its
+// prerequisites are verified
+func (ta *TaskAssigner) scheduleLocked(task *TrainingTask) {
+	waiting := false
+	for _, dep := range task.Dependencies {
+		if !ta.verifiedLocked(dep) {
+			ta.dependents[dep] = append(ta.dependents[dep], task.TaskID)
+			waiting = true
+		}
+	}
+
+	if waiting {
+		ta.blocked[task.TaskID] = task
+		return
+	}
+	ta.tasks[task.ModelID] = append(ta.tasks[task.ModelID], task)
+}
+
+// VerifyResult records the outcome of verifying a submitted result. A
+// verified task counts toward its pipeline and releases dependents whose
+// prerequisites are now all verified; a rejected task returns to the pool
+// for reassignment. It returns the released tasks.
+func (ta *TaskAssigner) VerifyResult(ctx context.Context, taskID types.Hash, valid bool, currentBlock uint64) ([]*TrainingTask, error) {
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+
+	assignment, exists := ta.assignments[taskID]
+	if !exists {
+		return nil, ErrAssignmentNotFound
+	}
+	if assignment.Status != StatusCompleted {
+		return nil, ErrResultNotSubmitted
+	}
+
+	task := assignment.Task
+	if !valid {
+		assignment.Status = StatusFailed
+		delete(ta.assignments, taskID)
+		ta.tasks[task.ModelID] = append(ta.tasks[task.ModelID], task)
+		return nil, nil
+	}
+
+	assignment.Status = StatusVerified
+	if pipeline, exists := ta.pipelines[task.PipelineID]; exists {
+		pipeline.Verified++
+		if pipeline.Complete() {
+			pipeline.CompletedAt = currentBlock
+		}
+	}
+
+	var released []*TrainingTask
+	for _, id := range ta.dependents[taskID] {
+		dependent, exists := ta.blocked[id]
+		if !exists || !ta.readyLocked(dependent) {
+			continue
+		}
+		delete(ta.blocked, id)
+		dependent.Deadline = currentBlock + ta.config.DefaultDeadline
+		ta.tasks[dependent.ModelID] = append(ta.tasks[dependent.ModelID], dependent)
+		released = append(released, dependent)
+	}
+	delete(ta.dependents, taskID)
+
+	return released, nil
+}
+
+// readyLocked reports whether all of a task's prerequisites are verified
+func (ta *TaskAssigner) readyLocked(task *TrainingTask) bool {
+	for _, dep := range task.Dependencies {
+		if !ta.verifiedLocked(dep) {
+			return false
+		}
+	}
+	return true
+}
+
+// verifiedLocked reports whether a task's result has been verified
+func (ta *TaskAssigner) verifiedLocked(taskID types.Hash) bool {
+	assignment, exists := ta.assignments[taskID]
+	return exists && assignment.Status == StatusVerified
+}
+
+// knownLocked reports whether a task is pending, blocked or assigned
+func (ta *TaskAssigner) knownLocked(taskID types.Hash) bool {
+	if _, exists := ta.assignments[taskID]; exists {
+		return true
+	}
+	if _, exists := ta.blocked[taskID]; exists {
+		return true
+	}
+	for _, tasks := range ta.tasks {
+		for _, t := range tasks {
+			if t.TaskID == taskID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetPipeline returns a pipeline by ID
+func (ta *TaskAssigner) GetPipeline(pipelineID types.Hash) (*Pipeline, error) {
+	ta.mu.RLock()
+	defer ta.mu.RUnlock()
+
+	pipeline, exists := ta.pipelines[pipelineID]
+	if !exists {
+		return nil, ErrPipelineNotFound
+	}
+	return pipeline, nil
+}
+
+// GetBlockedTasks returns tasks waiting on unverified prerequisites
+func (ta *TaskAssigner) GetBlockedTasks() []*TrainingTask {
+	ta.mu.RLock()
+	defer ta.mu.RUnlock()
+
+	tasks := make([]*TrainingTask, 0, len(ta.blocked))
+	for _, task := range ta.blocked {
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
+// RoundProgress returns pipeline completion for a round
+func (ta *TaskAssigner) RoundProgress(round uint64) *RoundProgress {
+	ta.mu.RLock()
+	defer ta.mu.RUnlock()
+
+	progress := &RoundProgress{Round: round}
+	for _, id := range ta.rounds[round] {
+		pipeline := ta.pipelines[id]
+		progress.Pipelines++
+		progress.Tasks += len(pipeline.Tasks)
+		progress.VerifiedTasks += pipeline.Verified
+		if pipeline.Complete() {
+			progress.Completed++
+		}
+	}
+	return progress
+}
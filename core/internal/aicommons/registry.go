@@ -16,6 +16,9 @@ var (
 	ErrModelExists         = errors.New("model already exists")
 	ErrInvalidContribution = errors.New("invalid contribution")
 	ErrUnauthorized        = errors.New("unauthorized operation")
+	ErrInvalidRegistration = errors.New("model registration not signed by proposer")
+	ErrRegistrationBond    = errors.New("registration bond below minimum")
+	ErrRateLimited         = errors.New("too many model registrations from proposer")
 )
 
 // ModelRegistry manages the AI Commons model registry
@@ -31,10 +34,51 @@ type ModelRegistry struct {
 	// Contribution records
 	contributions map[types.Hash][]*Contribution
 
+	// Published weight versions, oldest first
+	versions map[types.Hash][]*types.ModelVersion
+
+	// Registration bonds and recent registration heights per proposer
+	bonds  map[types.Hash]*RegistrationBond
+	recent map[types.Address][]uint64
+
+	// Registry checkpoints by epoch, and the newest epoch checkpointed
+	checkpoints    map[uint64]*registryCheckpoint
+	lastCheckpoint uint64
+
+	config *RegistryConfig
+
+	// onDeprecate is called when a model is deprecated
+	onDeprecate func(modelID types.Hash, reason string)
+
 	// Storage backend
 	store ModelStore
 }
 
+// RegistryConfig holds model registration parameters
+type RegistryConfig struct {
+	// MinBond is the refundable deposit required to register a model
+	MinBond uint64
+
+	// RateLimit is the number of registrations a proposer may make per
+	// RateWindow blocks
+	RateLimit  int
+	RateWindow uint64
+
+	// BondLockPeriod is the number of blocks a bond stays locked, long
+	// enough for a forfeiture proposal to pass
+	BondLockPeriod uint64
+}
+
+// DefaultRegistryConfig returns default registry configuration
+func DefaultRegistryConfig() *RegistryConfig {
+	return &RegistryConfig{
+		MinBond:        10000, // 10K CCoin
+		RateLimit:      3,
+		RateWindow:     8640,   // ~1 day at 10s blocks
+		BondLockPeriod: 100800, // ~14 days
+	}
+}
+
 // Contribution records a contribution to a model
 type Contribution struct {
 	Contributor types.Address
@@ -56,28 +100,50 @@ type ModelStore interface {
 }
 
 // NewModelRegistry creates a new model registry
-func NewModelRegistry(store ModelStore) *ModelRegistry {
+func NewModelRegistry(store ModelStore, config *RegistryConfig) *ModelRegistry {
+	if config == nil {
+		config = DefaultRegistryConfig()
+	}
+
 	return &ModelRegistry{
 		models:        make(map[types.Hash]*types.ModelEntry),
 		modelsByTask:  make(map[types.TaskType][]types.Hash),
 		contributions: make(map[types.Hash][]*Contribution),
+		versions:      make(map[types.Hash][]*types.ModelVersion),
+		bonds:         make(map[types.Hash]*RegistrationBond),
+		recent:        make(map[types.Address][]uint64),
+		checkpoints:   make(map[uint64]*registryCheckpoint),
+		config:        config,
 		store:         store,
 	}
 }
 
-// RegisterModel registers a new model in the commons
-func (r *ModelRegistry) RegisterModel(ctx context.Context, model *types.ModelEntry) error {
+// RegisterModel registers a new model in the commons. The registration
+// must be signed by the model's proposer and lock at least MinBond, and
+// each proposer is limited to RateLimit registrations per RateWindow.
+func (r *ModelRegistry) RegisterModel(ctx context.Context, reg *types.ModelRegistration, currentBlock uint64) error {
+	if !reg.Verify() {
+		return ErrInvalidRegistration
+	}
+	if reg.Bond < r.config.MinBond {
+		return ErrRegistrationBond
+	}
+	model := reg.Model
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.models[model.ModelID]; exists {
-		return ErrModelExists
+	if !r.allowLocked(model.ProposerAddress, currentBlock) {
+		return ErrRateLimited
 	}
 
 	// Generate model ID if not set
 	if model.ModelID == (types.Hash{}) {
 		model.ModelID = r.generateModelID(model)
 	}
+	if _, exists := r.models[model.ModelID]; exists {
+		return ErrModelExists
+	}
 
 	model.Status = types.ModelStatusActive
 	model.Contributors = make(map[types.Address]uint64)
@@ -85,6 +151,14 @@ func (r *ModelRegistry) RegisterModel(ctx context.Context, model *types.ModelEnt
 	r.models[model.ModelID] = model
 	r.modelsByTask[model.TaskType] = append(r.modelsByTask[model.TaskType], model.ModelID)
 
+	r.recent[model.ProposerAddress] = append(r.recent[model.ProposerAddress], currentBlock)
+	r.bonds[model.ModelID] = &RegistrationBond{
+		ModelID:  model.ModelID,
+		Proposer: model.ProposerAddress,
+		Amount:   reg.Bond,
+		PostedAt: currentBlock,
+	}
+
 	return r.store.SaveModel(ctx, model)
 }
 
@@ -236,6 +310,14 @@ func (r *ModelRegistry) UpdateModelWeights(ctx context.Context, modelID types.Ha
 		return ErrModelNotFound
 	}
 
+	r.versions[modelID] = append(r.versions[modelID], &types.ModelVersion{
+		Version:    uint32(len(r.versions[modelID]) + 1),
+		WeightsCID: newWeightsCID,
+		ParentCID:  model.CurrentWeights,
+		Accuracy:   newAccuracy,
+		CreatedAt:  model.LastUpdatedAt,
+	})
+
 	model.CurrentWeights = newWeightsCID
 	model.Accuracy = newAccuracy
 	// Update LastUpdatedAt would be done here
@@ -243,18 +325,33 @@ func (r *ModelRegistry) UpdateModelWeights(ctx context.Context, modelID types.Ha
 	return r.store.SaveModel(ctx, model)
 }
 
-// DeprecateModel marks a model as deprecated
-func (r *ModelRegistry) DeprecateModel(ctx context.Context, modelID types.Hash, reason string) error {
+// SetDeprecationHandler registers fn to be called when a model is
+// deprecated. It is called without the registry lock held.
+func (r *ModelRegistry) SetDeprecationHandler(fn func(modelID types.Hash, reason string)) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	r.onDeprecate = fn
+}
 
+// DeprecateModel marks a model as deprecated
+func (r *ModelRegistry) DeprecateModel(ctx context.Context, modelID types.Hash, reason string) error {
+	r.mu.Lock()
 	model, exists := r.models[modelID]
 	if !exists {
+		r.mu.Unlock()
 		return ErrModelNotFound
 	}
 
+	deprecated := model.Status != types.ModelStatusDeprecated
 	model.Status = types.ModelStatusDeprecated
-	return r.store.SaveModel(ctx, model)
+	err := r.store.SaveModel(ctx, model)
+	handler := r.onDeprecate
+	r.mu.Unlock()
+
+	if err == nil && deprecated && handler != nil {
+		handler(modelID, reason)
+	}
+	return err
 }
 
 // GetModelStats returns statistics for a model
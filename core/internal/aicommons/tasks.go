@@ -3,10 +3,12 @@ package aicommons
 
 import (
 	"context"
+	"crypto/ed25519"
 	"crypto/sha256"
 	"errors"
 	"sync"
 
+	"github.com/ccoin/core/internal/vrf"
 	"github.com/ccoin/core/pkg/types"
 )
 
@@ -15,6 +17,8 @@ var (
 	ErrNoTasksAvailable = errors.New("no tasks available")
 	ErrTaskAlreadyAssigned = errors.New("task already assigned")
 	ErrInvalidGradient = errors.New("invalid gradient result")
+	ErrStaleVRFSeed    = errors.New("VRF seed is not the current one")
+	ErrInvalidVRFProof = errors.New("invalid VRF proof")
 )
 
 // TaskAssigner manages task assignment for AI training
@@ -30,7 +34,17 @@ type TaskAssigner struct {
 	// Active assignments
 	assignments map[types.Hash]*TaskAssignment
 
-	// VRF seed for fair assignment
+	// Tasks waiting on unverified prerequisites
+	blocked map[types.Hash]*TrainingTask
+
+	// Prerequisite task ID -> dependent task IDs
+	dependents map[types.Hash][]types.Hash
+
+	// Pipelines by ID and by round
+	pipelines map[types.Hash]*Pipeline
+	rounds    map[uint64][]types.Hash
+
+	// VRF seed for fair assignment: the latest block hash
 	vrfSeed types.Hash
 
 	// Config
@@ -49,6 +63,13 @@ type TrainingTask struct {
 	CreatedAt   uint64
 	Deadline    uint64
 	Reward      uint64
+
+	// Dependencies are tasks that must be verified before this one is
+	// released for assignment
+	Dependencies []types.Hash
+
+	// PipelineID links the task to its pipeline, zero for standalone tasks
+	PipelineID types.Hash
 }
 
 // TaskAssignment tracks an active task assignment
@@ -61,6 +82,10 @@ type TaskAssignment struct {
 	GradientHash  types.Hash
 	QualityScore  float64
 	CompletedAt   uint64
+
+	// VRFSeed and VRFProof drew the task, for anyone to re-check
+	VRFSeed  types.Hash
+	VRFProof []byte
 }
 
 // AssignmentStatus tracks task progress
@@ -72,6 +97,7 @@ const (
 	StatusCompleted
 	StatusFailed
 	StatusExpired
+	StatusVerified
 )
 
 // TaskConfig holds task assignment configuration
@@ -100,6 +126,10 @@ func NewTaskAssigner(registry *ModelRegistry, config *TaskConfig) *TaskAssigner
 		registry:    registry,
 		tasks:       make(map[types.Hash][]*TrainingTask),
 		assignments: make(map[types.Hash]*TaskAssignment),
+		blocked:     make(map[types.Hash]*TrainingTask),
+		dependents:  make(map[types.Hash][]types.Hash),
+		pipelines:   make(map[types.Hash]*Pipeline),
+		rounds:      make(map[uint64][]types.Hash),
 		config:      config,
 	}
 }
@@ -147,6 +177,9 @@ func (ta *TaskAssigner) generateTaskID(task *TrainingTask) types.Hash {
 	data = append(data, uint32ToBytes(task.BatchStart)...)
 	data = append(data, uint32ToBytes(task.BatchEnd)...)
 	data = append(data, uint64ToBytes(task.CreatedAt)...)
+	for _, dep := range task.Dependencies {
+		data = append(data, dep[:]...)
+	}
 
 	hash := sha256.Sum256(data)
 	var id types.Hash
@@ -163,10 +196,13 @@ func (ta *TaskAssigner) generateObjective(task *TrainingTask) types.Hash {
 	return obj
 }
 
-// AssignTask assigns a task to a miner using VRF selection
+// AssignTask assigns a task to the miner with key pub, drawn by the
+// output of its VRF proof over the current seed
 func (ta *TaskAssigner) AssignTask(
 	ctx context.Context,
-	minerAddr types.Address,
+	pub ed25519.PublicKey,
+	seed types.Hash,
+	vrfProof []byte,
 	minerReputation float64,
 	currentBlock uint64,
 ) (*TaskAssignment, error) {
@@ -178,8 +214,15 @@ func (ta *TaskAssigner) AssignTask(
 		return nil, errors.New("reputation too low for task assignment")
 	}
 
-	// Select task using VRF-like selection
-	task := ta.selectTask(minerAddr)
+	if seed != ta.vrfSeed {
+		return nil, ErrStaleVRFSeed
+	}
+	output, err := vrf.Verify(pub, vrfProof, vrf.TaskInput(seed))
+	if err != nil {
+		return nil, ErrInvalidVRFProof
+	}
+
+	task := ta.selectTask(output)
 	if task == nil {
 		return nil, ErrNoTasksAvailable
 	}
@@ -191,10 +234,12 @@ func (ta *TaskAssigner) AssignTask(
 
 	assignment := &TaskAssignment{
 		Task:       task,
-		AssignedTo: minerAddr,
+		AssignedTo: types.AddressFromPublicKey(pub),
 		AssignedAt: currentBlock,
 		Deadline:   currentBlock + ta.config.DefaultDeadline,
 		Status:     StatusAssigned,
+		VRFSeed:    seed,
+		VRFProof:   append([]byte(nil), vrfProof...),
 	}
 
 	ta.assignments[task.TaskID] = assignment
@@ -205,17 +250,12 @@ func (ta *TaskAssigner) AssignTask(
 	return assignment, nil
 }
 
-// selectTask selects a task using VRF-based selection
-func (ta *TaskAssigner) selectTask(minerAddr types.Address) *TrainingTask {
-	// Combine VRF seed with miner address for deterministic selection
-	data := append(ta.vrfSeed[:], minerAddr[:]...)
-	hash := sha256.Sum256(data)
-	index := int(hash[0])
-
+// selectTask selects a task by a miner's VRF output
+func (ta *TaskAssigner) selectTask(output []byte) *TrainingTask {
 	// Find available tasks
 	for _, tasks := range ta.tasks {
 		if len(tasks) > 0 {
-			return tasks[index%len(tasks)]
+			return tasks[vrf.Index(output, len(tasks))]
 		}
 	}
 
@@ -282,14 +322,18 @@ func (ta *TaskAssigner) SubmitResult(
 	return ta.registry.RecordContribution(ctx, contrib)
 }
 
-// UpdateVRFSeed updates the VRF seed for task selection
+// UpdateVRFSeed sets the VRF seed for task selection to a new block's hash
 func (ta *TaskAssigner) UpdateVRFSeed(blockHash types.Hash) {
 	ta.mu.Lock()
 	defer ta.mu.Unlock()
+	ta.vrfSeed = blockHash
+}
 
-	data := append(ta.vrfSeed[:], blockHash[:]...)
-	hash := sha256.Sum256(data)
-	copy(ta.vrfSeed[:], hash[:])
+// VRFSeed returns the seed miners prove over to get a task
+func (ta *TaskAssigner) VRFSeed() types.Hash {
+	ta.mu.RLock()
+	defer ta.mu.RUnlock()
+	return ta.vrfSeed
 }
 
 // CleanupExpired cleans up expired assignments
@@ -0,0 +1,122 @@
+package aicommons
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"math"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Version errors
+var (
+	ErrVersionNotFound    = errors.New("model version not found")
+	ErrBrokenVersionChain = errors.New("model version does not extend its parent")
+	ErrUnattestedVersion  = errors.New("model version has no evaluation")
+	ErrAccuracyMismatch   = errors.New("recorded accuracy differs from attestations")
+)
+
+// AttestVersion attaches a finalized evaluation to the version whose
+// weights the job benchmarked
+func (r *ModelRegistry) AttestVersion(modelID types.Hash, job *types.EvaluationJob, atts []*types.AccuracyAttestation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if job.ModelID != modelID {
+		return ErrInvalidJob
+	}
+	for _, v := range r.versions[modelID] {
+		if v.WeightsCID == job.WeightsCID {
+			v.Job = job
+			v.Attestations = atts
+			return nil
+		}
+	}
+	return ErrVersionNotFound
+}
+
+// GetModelVersion returns a version of a model's weights; version 0
+// selects the latest
+func (r *ModelRegistry) GetModelVersion(ctx context.Context, modelID types.Hash, version uint32) (*types.ModelVersion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions := r.versions[modelID]
+	if version == 0 {
+		version = uint32(len(versions))
+	}
+	if version == 0 || int(version) > len(versions) {
+		return nil, ErrVersionNotFound
+	}
+	return versions[version-1], nil
+}
+
+// ModelVersions returns versions 1 through upTo of a model; upTo 0
+// returns every version
+func (r *ModelRegistry) ModelVersions(modelID types.Hash, upTo uint32) ([]*types.ModelVersion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions := r.versions[modelID]
+	if upTo == 0 {
+		upTo = uint32(len(versions))
+	}
+	if upTo == 0 || int(upTo) > len(versions) {
+		return nil, ErrVersionNotFound
+	}
+	return append([]*types.ModelVersion(nil), versions[:upTo]...), nil
+}
+
+// VerifyVersionChain checks a model's versions from the first onwards:
+// each version must extend its parent's weights, carry an evaluation job
+// for exactly those weights, and be attested by a strict majority of the
+// job's committee with valid signatures. The recorded accuracy must match
+// the median attestation within tolerance. keys maps evaluators to their
+// registered public keys.
+func VerifyVersionChain(modelID types.Hash, versions []*types.ModelVersion, keys map[types.Address]ed25519.PublicKey, tolerance float64) error {
+	for i, v := range versions {
+		if v.Version != uint32(i+1) {
+			return ErrBrokenVersionChain
+		}
+		if i > 0 && v.ParentCID != versions[i-1].WeightsCID {
+			return ErrBrokenVersionChain
+		}
+		if err := verifyVersion(modelID, v, keys, tolerance); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyVersion checks a single version's evaluation
+func verifyVersion(modelID types.Hash, v *types.ModelVersion, keys map[types.Address]ed25519.PublicKey, tolerance float64) error {
+	job := v.Job
+	if job == nil || len(v.Attestations) == 0 {
+		return ErrUnattestedVersion
+	}
+	if job.ModelID != modelID || job.WeightsCID != v.WeightsCID || job.JobID != job.ComputeID() {
+		return ErrInvalidJob
+	}
+
+	seen := make(map[types.Address]bool, len(v.Attestations))
+	accuracies := make([]float64, 0, len(v.Attestations))
+	for _, att := range v.Attestations {
+		if att.JobID != job.JobID || seen[att.Evaluator] || !containsEvaluator(job.Evaluators, att.Evaluator) {
+			return ErrInvalidAttestation
+		}
+		if !att.Verify(keys[att.Evaluator]) {
+			return ErrInvalidAttestation
+		}
+		seen[att.Evaluator] = true
+		accuracies = append(accuracies, att.Accuracy)
+	}
+
+	if len(accuracies)*2 <= len(job.Evaluators) {
+		return ErrNoQuorum
+	}
+	if math.Abs(median(accuracies)-v.Accuracy) > tolerance {
+		return ErrAccuracyMismatch
+	}
+	return nil
+}
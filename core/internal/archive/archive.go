@@ -0,0 +1,294 @@
+// Package archive exports the public record of the shielded pool for
+// compliance escrow.
+//
+// An archive holds the disclosure proofs and public metadata of every
+// main chain transaction in a date range. It is threshold-encrypted to a
+// set of custodians appointed by the DAO, so it can only be read when
+// enough custodians agree. Archives are built from on-chain data alone and
+// never contain notes, memos or spending keys.
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/ccoin/core/internal/threshold"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Archive errors
+var (
+	ErrInvalidRange   = errors.New("archive range end must be after start")
+	ErrNotAnArchive   = errors.New("not a ccoin archive")
+	ErrUnknownVersion = errors.New("unsupported archive version")
+	ErrWrongCustodian = errors.New("archive sealed to different custodians")
+)
+
+// Version is the archive format version
+const Version = 1
+
+var magic = []byte("CCARCHV1")
+
+// maxHeaderSize bounds the clear header read from a file
+const maxHeaderSize = 1 << 16
+
+// Record is the public part of one archived transaction
+type Record struct {
+	BlockHash types.Hash
+	Height    uint64
+	Timestamp uint64
+
+	TxHash      types.Hash
+	Fee         uint64
+	Anchor      types.Hash
+	Nullifiers  []types.Hash
+	Commitments []types.Hash
+	ProofType   uint8
+
+	DisclosureFlags uint32
+	Disclosures     []types.Disclosure
+}
+
+// Header describes an archive. It is stored in the clear so custodians
+// can see what they are asked to open, and authenticated with the
+// encrypted records.
+type Header struct {
+	Version      int       `json:"version"`
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	Created      time.Time `json:"created"`
+	Blocks       int       `json:"blocks"`
+	Transactions int       `json:"transactions"`
+
+	// Custodians is the number of custodians and Threshold how many must
+	// cooperate to open the archive
+	Custodians int `json:"custodians"`
+	Threshold  int `json:"threshold"`
+
+	// CustodianKey fingerprints the key the records are sealed to
+	CustodianKey string `json:"custodian_key"`
+}
+
+// Archive is an encrypted export
+type Archive struct {
+	Header Header
+	Sealed *types.SealedPayload
+}
+
+// BlockSource provides main chain blocks by timestamp
+type BlockSource interface {
+	GetMainChainByTime(ctx context.Context, from, to int64) ([]*types.Block, error)
+}
+
+// Export archives main chain transactions with timestamps in [from, to),
+// sealed to the custodian key
+func Export(ctx context.Context, src BlockSource, from, to time.Time, custodians *threshold.PublicKey) (*Archive, error) {
+	if !to.After(from) {
+		return nil, ErrInvalidRange
+	}
+
+	blocks, err := src.GetMainChainByTime(ctx, from.Unix(), to.Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	for _, block := range blocks {
+		for _, tx := range block.Transactions {
+			records = append(records, newRecord(block.Header, tx))
+		}
+	}
+
+	fingerprint, err := keyFingerprint(custodians)
+	if err != nil {
+		return nil, err
+	}
+	a := &Archive{Header: Header{
+		Version:      Version,
+		From:         from.UTC(),
+		To:           to.UTC(),
+		Created:      time.Now().UTC().Truncate(time.Second),
+		Blocks:       len(blocks),
+		Transactions: len(records),
+		Custodians:   len(custodians.Verification),
+		Threshold:    custodians.Threshold,
+		CustodianKey: fingerprint,
+	}}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := gob.NewEncoder(zw).Encode(records); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	label, err := a.label()
+	if err != nil {
+		return nil, err
+	}
+	if a.Sealed, err = threshold.Seal(custodians, 0, buf.Bytes(), label); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// newRecord copies the public fields of a transaction. Memos and
+// encrypted notes are left out.
+func newRecord(header *types.BlockHeader, tx *types.Transaction) Record {
+	r := Record{
+		BlockHash:       header.Hash,
+		Height:          header.Height,
+		Timestamp:       header.Timestamp,
+		TxHash:          tx.TxHash,
+		Fee:             tx.Fee,
+		Anchor:          tx.Anchor,
+		Nullifiers:      tx.Nullifiers,
+		ProofType:       tx.Proof.ProofType,
+		DisclosureFlags: tx.DisclosureFlags,
+		Disclosures:     tx.Disclosures,
+	}
+	for _, c := range tx.Commitments {
+		r.Commitments = append(r.Commitments, c.Value)
+	}
+	return r
+}
+
+// Share computes a custodian's decryption share for the archive
+func (a *Archive) Share(ks *threshold.KeyShare) (*threshold.DecryptionShare, error) {
+	return ks.DecryptShare(a.Sealed)
+}
+
+// Open decrypts the archive with shares from Threshold custodians
+func (a *Archive) Open(custodians *threshold.PublicKey, shares []*threshold.DecryptionShare) ([]Record, error) {
+	fingerprint, err := keyFingerprint(custodians)
+	if err != nil {
+		return nil, err
+	}
+	if fingerprint != a.Header.CustodianKey {
+		return nil, ErrWrongCustodian
+	}
+
+	label, err := a.label()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := custodians.Open(a.Sealed, label, shares)
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(plaintext))
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	if err := gob.NewDecoder(zr).Decode(&records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// WriteTo writes the archive as magic, a length-prefixed JSON header and
+// the sealed records
+func (a *Archive) WriteTo(w io.Writer) (int64, error) {
+	header, err := json.Marshal(&a.Header)
+	if err != nil {
+		return 0, err
+	}
+
+	buf := append([]byte{}, magic...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(header)))
+	buf = append(buf, header...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(a.Sealed.Ephemeral)))
+	buf = append(buf, a.Sealed.Ephemeral...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(a.Sealed.Proof)))
+	buf = append(buf, a.Sealed.Proof...)
+	buf = append(buf, a.Sealed.Ciphertext...)
+
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// Read reads an archive written by WriteTo
+func Read(r io.Reader) (*Archive, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, magic) {
+		return nil, ErrNotAnArchive
+	}
+	data = data[len(magic):]
+
+	next := func(limit int) ([]byte, error) {
+		if len(data) < 4 {
+			return nil, ErrNotAnArchive
+		}
+		n := int(binary.BigEndian.Uint32(data))
+		data = data[4:]
+		if n > limit || n > len(data) {
+			return nil, ErrNotAnArchive
+		}
+		b := data[:n]
+		data = data[n:]
+		return b, nil
+	}
+
+	a := &Archive{Sealed: &types.SealedPayload{}}
+	header, err := next(maxHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(header, &a.Header); err != nil {
+		return nil, ErrNotAnArchive
+	}
+	if a.Header.Version != Version {
+		return nil, ErrUnknownVersion
+	}
+	if a.Sealed.Ephemeral, err = next(maxHeaderSize); err != nil {
+		return nil, err
+	}
+	if a.Sealed.Proof, err = next(maxHeaderSize); err != nil {
+		return nil, err
+	}
+	a.Sealed.Ciphertext = data
+
+	label, err := a.label()
+	if err != nil {
+		return nil, err
+	}
+	if err := threshold.Verify(a.Sealed, label); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// label binds the sealed records to the header
+func (a *Archive) label() ([]byte, error) {
+	header, err := json.Marshal(&a.Header)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte("ccoin-archive"), header...), nil
+}
+
+// keyFingerprint hashes the custodian public key
+func keyFingerprint(pk *threshold.PublicKey) (string, error) {
+	data, err := pk.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,316 @@
+// Package atrest encrypts node data at rest. Every file and store gets
+// its own AES-256-GCM key, derived from a master key that comes from an
+// operator passphrase or an external key command such as a KMS client.
+package atrest
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Encryption errors
+var (
+	ErrWrongKey     = errors.New("wrong at-rest encryption key or corrupted data")
+	ErrEncrypted    = errors.New("data is encrypted at rest but no key is configured")
+	ErrNotEncrypted = errors.New("data was written unencrypted; it cannot be encrypted in place")
+	ErrUnknownMode  = errors.New("unknown at-rest encryption mode")
+	ErrNoKey        = errors.New("at-rest encryption needs a passphrase or a key command")
+)
+
+// Mode selects what is encrypted
+type Mode string
+
+// Encryption modes
+const (
+	// ModeOff stores everything in the clear
+	ModeOff Mode = "off"
+
+	// ModeKeys encrypts key material only, the wallet keystore. Chain
+	// state, notes and the mempool journal stay in the clear, so the
+	// node pays nothing on the block and transaction paths.
+	ModeKeys Mode = "keys"
+
+	// ModeFull encrypts the wallet, the mempool journal and the chain
+	// state of the embedded store
+	ModeFull Mode = "full"
+)
+
+// ParamsFileName is the name of the file in the data directory that
+// records how the master key is derived
+const ParamsFileName = "atrest.json"
+
+// fileMagic starts every file written encrypted
+var fileMagic = []byte("CCOINENC\x01")
+
+// checkValue is sealed into the params file to tell a wrong key from
+// corrupted data before anything is read
+var checkValue = []byte("CCOIN_ATREST_CHECK")
+
+// Key derivation functions recorded in the params file
+const (
+	kdfScrypt  = "scrypt"
+	kdfCommand = "command"
+)
+
+// Config configures at-rest encryption
+type Config struct {
+	Mode Mode
+
+	// Passphrase derives the master key with scrypt
+	Passphrase string
+
+	// KeyCommand is run with sh -c and prints the hex 32-byte master key,
+	// for keys held in a KMS. It takes precedence over Passphrase.
+	KeyCommand string
+}
+
+// params is the content of the params file
+type params struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Salt    string `json:"salt,omitempty"`
+	N       int    `json:"n,omitempty"`
+	R       int    `json:"r,omitempty"`
+	P       int    `json:"p,omitempty"`
+	Check   string `json:"check"`
+}
+
+// Cipher seals data under a key derived for one file or store. A nil
+// Cipher means encryption is off: files are written in the clear, and
+// reading an encrypted one fails with ErrEncrypted.
+type Cipher struct {
+	mode   Mode
+	master []byte
+	aead   cipher.AEAD
+}
+
+// Open derives the master key cfg describes, creating the params file in
+// dataDir on first use and verifying the key against it afterwards. It
+// returns nil when encryption is off.
+func Open(dataDir string, cfg *Config) (*Cipher, error) {
+	if cfg == nil || cfg.Mode == "" || cfg.Mode == ModeOff {
+		return nil, nil
+	}
+	if cfg.Mode != ModeKeys && cfg.Mode != ModeFull {
+		return nil, fmt.Errorf("%w %q", ErrUnknownMode, cfg.Mode)
+	}
+
+	path := filepath.Join(dataDir, ParamsFileName)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return create(path, cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var p params
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	master, err := deriveKey(&p, cfg)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newCipher(cfg.Mode, master)
+	if err != nil {
+		return nil, err
+	}
+
+	check, err := hex.DecodeString(p.Check)
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+	if plain, err := c.Open(check, []byte(ParamsFileName)); err != nil || !bytes.Equal(plain, checkValue) {
+		return nil, ErrWrongKey
+	}
+	return c, nil
+}
+
+// create derives a new master key and writes the params file
+func create(path string, cfg *Config) (*Cipher, error) {
+	p := params{Version: 1, KDF: kdfCommand}
+	if cfg.KeyCommand == "" {
+		salt := make([]byte, 32)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		p = params{Version: 1, KDF: kdfScrypt, Salt: hex.EncodeToString(salt), N: 1 << 17, R: 8, P: 1}
+	}
+
+	master, err := deriveKey(&p, cfg)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newCipher(cfg.Mode, master)
+	if err != nil {
+		return nil, err
+	}
+	p.Check = hex.EncodeToString(c.Seal(checkValue, []byte(ParamsFileName)))
+
+	data, err := json.MarshalIndent(&p, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// deriveKey derives the master key the way the params file records
+func deriveKey(p *params, cfg *Config) ([]byte, error) {
+	switch p.KDF {
+	case kdfCommand:
+		if cfg.KeyCommand == "" {
+			return nil, fmt.Errorf("%w: the data directory's key comes from a key command", ErrNoKey)
+		}
+		out, err := exec.Command("sh", "-c", cfg.KeyCommand).Output()
+		if err != nil {
+			return nil, fmt.Errorf("at-rest key command: %w", err)
+		}
+		key, err := hex.DecodeString(strings.TrimSpace(string(out)))
+		if err != nil || len(key) != 32 {
+			return nil, errors.New("at-rest key command must print a hex 32-byte key")
+		}
+		return key, nil
+
+	case kdfScrypt:
+		if cfg.Passphrase == "" {
+			return nil, fmt.Errorf("%w: the data directory's key comes from a passphrase", ErrNoKey)
+		}
+		salt, err := hex.DecodeString(p.Salt)
+		if err != nil {
+			return nil, ErrWrongKey
+		}
+		return scrypt.Key([]byte(cfg.Passphrase), salt, p.N, p.R, p.P, 32)
+
+	default:
+		return nil, fmt.Errorf("unsupported at-rest kdf %q", p.KDF)
+	}
+}
+
+// newCipher creates a cipher whose own key is the master key
+func newCipher(mode Mode, master []byte) (*Cipher, error) {
+	block, err := aes.NewCipher(master)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{mode: mode, master: master, aead: aead}, nil
+}
+
+// Mode returns what the cipher encrypts
+func (c *Cipher) Mode() Mode {
+	if c == nil {
+		return ModeOff
+	}
+	return c.mode
+}
+
+// Keys returns the cipher for key material, which every mode but off
+// encrypts
+func (c *Cipher) Keys() *Cipher {
+	return c
+}
+
+// Data returns the cipher for chain state, notes and the mempool
+// journal, or nil unless the mode is full
+func (c *Cipher) Data() *Cipher {
+	if c == nil || c.mode != ModeFull {
+		return nil
+	}
+	return c
+}
+
+// Sub returns a cipher keyed for label alone, so that data under
+// different labels never shares a key
+func (c *Cipher) Sub(label string) *Cipher {
+	if c == nil {
+		return nil
+	}
+	mac := hmac.New(sha256.New, c.master)
+	mac.Write([]byte("CCOIN_ATREST_KEY"))
+	mac.Write([]byte(label))
+	sub, err := newCipher(c.mode, mac.Sum(nil))
+	if err != nil {
+		// A 32-byte key always makes an AES cipher
+		panic(err)
+	}
+	return sub
+}
+
+// Seal encrypts plaintext bound to ad, returning nonce || ciphertext
+func (c *Cipher) Seal(plaintext, ad []byte) []byte {
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(plaintext)+c.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err)
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, ad)
+}
+
+// Open decrypts what Seal returned for the same ad
+func (c *Cipher) Open(sealed, ad []byte) ([]byte, error) {
+	n := c.aead.NonceSize()
+	if len(sealed) < n+c.aead.Overhead() {
+		return nil, ErrWrongKey
+	}
+	plain, err := c.aead.Open(nil, sealed[:n], sealed[n:], ad)
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+	return plain, nil
+}
+
+// WriteFile atomically writes data to path with perm, encrypted under
+// the file's own key unless c is nil
+func (c *Cipher) WriteFile(path string, data []byte, perm os.FileMode) error {
+	if c != nil {
+		name := filepath.Base(path)
+		data = append(append([]byte{}, fileMagic...), c.Sub(name).Seal(data, []byte(name))...)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ReadFile reads a file WriteFile wrote. Files written in the clear are
+// returned as they are, so existing data keeps working after encryption
+// is turned on and is encrypted the next time it is written.
+func (c *Cipher) ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || !bytes.HasPrefix(data, fileMagic) {
+		return data, err
+	}
+	if c == nil {
+		return nil, fmt.Errorf("%s: %w", path, ErrEncrypted)
+	}
+	name := filepath.Base(path)
+	plain, err := c.Sub(name).Open(data[len(fileMagic):], []byte(name))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return plain, nil
+}
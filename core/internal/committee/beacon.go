@@ -0,0 +1,42 @@
+package committee
+
+import (
+	"context"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// HeaderSource returns the headers of every block at a height
+type HeaderSource interface {
+	GetHeadersAtHeight(ctx context.Context, height uint64) ([]*types.BlockHeader, error)
+}
+
+// ChainBeacon derives each epoch's beacon from the blocks opening the
+// epoch before it. Those blocks are a full epoch deep when the beacon is
+// needed, so every node sees the same set, and no miner knows in advance
+// which committee its block will help pick.
+type ChainBeacon struct {
+	headers HeaderSource
+}
+
+// NewChainBeacon creates a beacon over the block DAG
+func NewChainBeacon(headers HeaderSource) *ChainBeacon {
+	return &ChainBeacon{headers: headers}
+}
+
+// Beacon returns the beacon of epoch
+func (b *ChainBeacon) Beacon(ctx context.Context, epoch uint64) (types.Hash, error) {
+	if epoch == 0 {
+		return DeriveBeacon(0, nil), nil
+	}
+
+	headers, err := b.headers.GetHeadersAtHeight(ctx, (epoch-1)*types.EpochLength)
+	if err != nil {
+		return types.Hash{}, err
+	}
+	hashes := make([]types.Hash, len(headers))
+	for i, h := range headers {
+		hashes[i] = h.Hash
+	}
+	return DeriveBeacon(epoch, hashes), nil
+}
@@ -0,0 +1,333 @@
+// Package committee draws the per-epoch committees from a random beacon,
+// weighting candidates by stake and reputation, and keeps an audit log of
+// every selection so anyone can re-verify it.
+package committee
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Committee errors
+var (
+	ErrSelectionMismatch = errors.New("committee members do not follow from the recorded inputs")
+	ErrUnknownCommittee  = errors.New("no committee recorded for that epoch")
+)
+
+// Select draws size members from candidates. Each seat is drawn with
+// probability proportional to stake times reputation among the
+// candidates still unseated, using randomness expanded from beacon.
+// Candidates with no weight are never drawn.
+func Select(kind types.CommitteeKind, epoch uint64, beacon types.Hash, candidates []types.CommitteeCandidate, size int) *types.CommitteeRecord {
+	record := &types.CommitteeRecord{
+		Kind:       kind,
+		Epoch:      epoch,
+		Beacon:     beacon,
+		Size:       uint32(size),
+		Candidates: append([]types.CommitteeCandidate(nil), candidates...),
+	}
+	sort.Slice(record.Candidates, func(i, j int) bool {
+		return bytes.Compare(record.Candidates[i].Address[:], record.Candidates[j].Address[:]) < 0
+	})
+
+	weights := make([]*big.Int, len(record.Candidates))
+	total := new(big.Int)
+	for i, c := range record.Candidates {
+		weights[i] = new(big.Int).Mul(
+			new(big.Int).SetUint64(c.Stake),
+			new(big.Int).SetUint64(types.ReputationFixed(c.Reputation)),
+		)
+		total.Add(total, weights[i])
+	}
+
+	for seat := 0; seat < size && total.Sign() > 0; seat++ {
+		point := new(big.Int).Mod(draw(record, seat), total)
+		for i, w := range weights {
+			if point.Cmp(w) < 0 {
+				record.Members = append(record.Members, record.Candidates[i].Address)
+				total.Sub(total, w)
+				weights[i] = new(big.Int)
+				break
+			}
+			point.Sub(point, w)
+		}
+	}
+	return record
+}
+
+// draw returns the random value for a seat
+func draw(record *types.CommitteeRecord, seat int) *big.Int {
+	buf := []byte("CCOIN_COMMITTEE_DRAW")
+	buf = append(buf, byte(record.Kind))
+	buf = binary.BigEndian.AppendUint64(buf, record.Epoch)
+	buf = append(buf, record.Beacon[:]...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(seat))
+	h := sha256.Sum256(buf)
+	return new(big.Int).SetBytes(h[:])
+}
+
+// Verify re-runs a recorded selection and checks it produced the
+// recorded members
+func Verify(record *types.CommitteeRecord) error {
+	redo := Select(record.Kind, record.Epoch, record.Beacon, record.Candidates, int(record.Size))
+	if redo.Hash() != record.Hash() {
+		return ErrSelectionMismatch
+	}
+	return nil
+}
+
+// DeriveBeacon returns the beacon for epoch from the hashes of blocks
+// settled before it, in any order
+func DeriveBeacon(epoch uint64, blocks []types.Hash) types.Hash {
+	sorted := append([]types.Hash(nil), blocks...)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i][:], sorted[j][:]) < 0 })
+
+	buf := binary.BigEndian.AppendUint64([]byte("CCOIN_BEACON"), epoch)
+	for _, h := range sorted {
+		buf = append(buf, h[:]...)
+	}
+	return sha256.Sum256(buf)
+}
+
+// BeaconSource provides the beacon of an epoch
+type BeaconSource interface {
+	Beacon(ctx context.Context, epoch uint64) (types.Hash, error)
+}
+
+// CandidateSource provides the snapshot a committee is drawn from
+type CandidateSource interface {
+	CommitteeCandidates(ctx context.Context, epoch uint64) ([]types.CommitteeCandidate, error)
+}
+
+// Store persists committee records
+type Store interface {
+	SaveCommitteeRecords(ctx context.Context, records []*types.CommitteeRecord) error
+	LoadCommitteeRecords(ctx context.Context, epoch uint64) ([]*types.CommitteeRecord, error)
+}
+
+// committee is a registered committee
+type committee struct {
+	size   int
+	period uint64
+	source CandidateSource
+}
+
+// AuditLog selects the registered committees at each epoch and records
+// the selections. Records are made once per epoch and never change, so
+// the committee root of an epoch is the same whenever it is asked for.
+type AuditLog struct {
+	mu sync.Mutex
+
+	beacon     BeaconSource
+	committees map[types.CommitteeKind]*committee
+
+	// Records by epoch
+	records map[uint64][]*types.CommitteeRecord
+
+	store Store
+}
+
+// NewAuditLog creates an audit log drawing from beacon
+func NewAuditLog(beacon BeaconSource, store Store) *AuditLog {
+	return &AuditLog{
+		beacon:     beacon,
+		committees: make(map[types.CommitteeKind]*committee),
+		records:    make(map[uint64][]*types.CommitteeRecord),
+		store:      store,
+	}
+}
+
+// Register adds a committee of size seats, rotated every period epochs
+// and drawn from source
+func (l *AuditLog) Register(kind types.CommitteeKind, size int, period uint64, source CandidateSource) {
+	if period == 0 {
+		period = 1
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.committees[kind] = &committee{size: size, period: period, source: source}
+}
+
+// Rotate selects the committees rotating at epoch, or returns the records
+// already made
+func (l *AuditLog) Rotate(ctx context.Context, epoch uint64) ([]*types.CommitteeRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rotateLocked(ctx, epoch)
+}
+
+// rotateLocked returns the records of epoch, selecting them if needed
+func (l *AuditLog) rotateLocked(ctx context.Context, epoch uint64) ([]*types.CommitteeRecord, error) {
+	if records, exists := l.records[epoch]; exists {
+		return records, nil
+	}
+
+	records, err := l.store.LoadCommitteeRecords(ctx, epoch)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) > 0 {
+		l.records[epoch] = records
+		return records, nil
+	}
+
+	kinds := make([]types.CommitteeKind, 0, len(l.committees))
+	for kind, c := range l.committees {
+		if epoch%c.period == 0 {
+			kinds = append(kinds, kind)
+		}
+	}
+	if len(kinds) > 0 {
+		sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+
+		beacon, err := l.beacon.Beacon(ctx, epoch)
+		if err != nil {
+			return nil, err
+		}
+		for _, kind := range kinds {
+			c := l.committees[kind]
+			candidates, err := c.source.CommitteeCandidates(ctx, epoch)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, Select(kind, epoch, beacon, candidates, c.size))
+		}
+		if err := l.store.SaveCommitteeRecords(ctx, records); err != nil {
+			return nil, err
+		}
+	}
+	l.records[epoch] = records
+	return records, nil
+}
+
+// Root returns the committee root the first blocks of epoch commit to
+func (l *AuditLog) Root(ctx context.Context, epoch uint64) (types.Hash, error) {
+	records, err := l.Rotate(ctx, epoch)
+	if err != nil {
+		return types.Hash{}, err
+	}
+	return types.CommitteeRoot(records), nil
+}
+
+// Committee returns the record of the kind's committee serving at epoch:
+// the one selected at its last rotation
+func (l *AuditLog) Committee(ctx context.Context, kind types.CommitteeKind, epoch uint64) (*types.CommitteeRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c, exists := l.committees[kind]
+	if !exists {
+		return nil, ErrUnknownCommittee
+	}
+	records, err := l.rotateLocked(ctx, epoch-epoch%c.period)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		if r.Kind == kind {
+			return r, nil
+		}
+	}
+	return nil, ErrUnknownCommittee
+}
+
+// Records returns what was recorded at epoch, without selecting
+func (l *AuditLog) Records(ctx context.Context, epoch uint64) ([]*types.CommitteeRecord, error) {
+	l.mu.Lock()
+	records, exists := l.records[epoch]
+	l.mu.Unlock()
+	if exists {
+		return records, nil
+	}
+	return l.store.LoadCommitteeRecords(ctx, epoch)
+}
+
+// Audit is the outcome of re-verifying a recorded selection
+type Audit struct {
+	Record     *types.CommitteeRecord
+	RecordHash types.Hash
+
+	// Root is the committee root of the rotation epoch
+	Root types.Hash
+
+	// BeaconValid reports whether the recorded beacon matches the one
+	// derived again from the chain
+	BeaconValid bool
+
+	// SelectionValid reports whether the members follow from the
+	// recorded beacon and snapshot
+	SelectionValid bool
+}
+
+// Audit re-verifies the recorded selection of the kind's committee
+// serving at epoch. It never selects: epochs not yet rotated return
+// ErrUnknownCommittee.
+func (l *AuditLog) Audit(ctx context.Context, kind types.CommitteeKind, epoch uint64) (*Audit, error) {
+	l.mu.Lock()
+	period := uint64(1)
+	if c, exists := l.committees[kind]; exists {
+		period = c.period
+	}
+	l.mu.Unlock()
+
+	start := epoch - epoch%period
+	records, err := l.Records(ctx, start)
+	if err != nil {
+		return nil, err
+	}
+
+	audit := &Audit{Root: types.CommitteeRoot(records)}
+	for _, r := range records {
+		if r.Kind == kind {
+			audit.Record = r
+		}
+	}
+	if audit.Record == nil {
+		return nil, ErrUnknownCommittee
+	}
+	audit.RecordHash = audit.Record.Hash()
+	audit.SelectionValid = Verify(audit.Record) == nil
+
+	beacon, err := l.beacon.Beacon(ctx, start)
+	if err != nil {
+		return nil, err
+	}
+	audit.BeaconValid = beacon == audit.Record.Beacon
+	return audit, nil
+}
+
+// InMemoryStore is a simple in-memory Store for testing
+type InMemoryStore struct {
+	mu      sync.Mutex
+	records map[uint64][]*types.CommitteeRecord
+}
+
+// NewInMemoryStore creates an empty in-memory store
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{records: make(map[uint64][]*types.CommitteeRecord)}
+}
+
+// SaveCommitteeRecords stores records
+func (s *InMemoryStore) SaveCommitteeRecords(ctx context.Context, records []*types.CommitteeRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range records {
+		s.records[r.Epoch] = append(s.records[r.Epoch], r)
+	}
+	return nil
+}
+
+// LoadCommitteeRecords returns the records of epoch
+func (s *InMemoryStore) LoadCommitteeRecords(ctx context.Context, epoch uint64) ([]*types.CommitteeRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records[epoch], nil
+}
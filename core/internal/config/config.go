@@ -0,0 +1,184 @@
+// Package config loads node settings from a config file and the
+// environment. Settings are named after the node's flags and applied
+// through them, so a value is parsed and checked the same way wherever it
+// comes from. Flags given on the command line take precedence over
+// CCOIN_* environment variables, which take precedence over the config
+// file, which takes precedence over the defaults.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Config errors
+var (
+	ErrSyntax         = errors.New("syntax error")
+	ErrUnknownSetting = errors.New("unknown setting")
+	ErrInvalidConfig  = errors.New("invalid configuration")
+)
+
+const (
+	// DefaultFile is the config file looked for in the data directory
+	// when none is given
+	DefaultFile = "ccoin.toml"
+
+	// EnvPrefix starts the environment variable of every setting:
+	// db-host is CCOIN_DB_HOST
+	EnvPrefix = "CCOIN_"
+)
+
+// Secrets are read from the environment only, never from settings
+const (
+	StoragePassphraseEnv = "CCOIN_STORAGE_PASSPHRASE"
+	KeyProviderTokenEnv  = "CCOIN_KEY_PROVIDER_TOKEN"
+)
+
+// Format is a config file syntax
+type Format string
+
+const (
+	// FormatTOML is the TOML subset of flat keys, [tables] and scalar or
+	// array values. Plain "name = value" lines are accepted too.
+	FormatTOML Format = "toml"
+
+	// FormatYAML is the YAML subset of scalar mappings nested one level
+	// and sequences
+	FormatYAML Format = "yaml"
+)
+
+// FormatOf returns the format of a config file from its extension;
+// anything but .yaml or .yml is read as TOML
+func FormatOf(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	default:
+		return FormatTOML
+	}
+}
+
+// Setting is a named value from a config file. Tables and nested
+// mappings join their name to the key's with a hyphen, so [db] host is
+// db-host; underscores in names read as hyphens.
+type Setting struct {
+	Name  string
+	Value string
+
+	// Line is the line the setting was read from, zero if not from a
+	// file
+	Line int
+}
+
+// EnvName returns the environment variable that sets a flag
+func EnvName(name string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// Find returns the config file to load: path when given, else the
+// default file in the data directory, as ccoin.toml, ccoin.yaml or
+// ccoin.yml. It returns "" when there is none.
+func Find(path, dataDir string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	base := strings.TrimSuffix(DefaultFile, filepath.Ext(DefaultFile))
+	for _, ext := range []string{".toml", ".yaml", ".yml"} {
+		candidate := filepath.Join(dataDir, base+ext)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// ApplyEnv sets each flag not given on the command line from its
+// environment variable, if set. lookup is normally os.LookupEnv.
+func ApplyEnv(fs *flag.FlagSet, lookup func(string) (string, bool)) error {
+	set := setFlags(fs)
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || set[f.Name] {
+			return
+		}
+		value, ok := lookup(EnvName(f.Name))
+		if !ok {
+			return
+		}
+		if serr := fs.Set(f.Name, value); serr != nil {
+			err = fmt.Errorf("%w: %s: %v", ErrInvalidConfig, EnvName(f.Name), serr)
+		}
+	})
+	return err
+}
+
+// ApplyFile sets each flag not already set, by the command line or
+// ApplyEnv, from a config file. Every setting in the file must name a
+// flag; the config flag itself cannot be set from a file.
+func ApplyFile(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	settings, err := Parse(data, FormatOf(path))
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	set := setFlags(fs)
+	for _, s := range settings {
+		if fs.Lookup(s.Name) == nil || s.Name == "config" {
+			return fmt.Errorf("%s:%d: %w %q", path, s.Line, ErrUnknownSetting, s.Name)
+		}
+		if set[s.Name] {
+			continue
+		}
+		if err := fs.Set(s.Name, s.Value); err != nil {
+			return fmt.Errorf("%s:%d: %w: %s: %v", path, s.Line, ErrInvalidConfig, s.Name, err)
+		}
+	}
+	return nil
+}
+
+// Load applies the environment and then the config file to a parsed
+// flag set, and then the ports of the network selected (see
+// ApplyNetwork). The file is the one named by the config flag, else the
+// default file in the directory named by the data-dir flag, if any. It
+// returns the file loaded, or "".
+func Load(fs *flag.FlagSet) (string, error) {
+	if err := ApplyEnv(fs, os.LookupEnv); err != nil {
+		return "", err
+	}
+
+	var path, dataDir string
+	if f := fs.Lookup("config"); f != nil {
+		path = f.Value.String()
+	}
+	if f := fs.Lookup("data-dir"); f != nil {
+		dataDir = f.Value.String()
+	}
+	path, err := Find(path, dataDir)
+	if err != nil {
+		return "", err
+	}
+	if path != "" {
+		if err := ApplyFile(fs, path); err != nil {
+			return "", err
+		}
+	}
+	return path, ApplyNetwork(fs)
+}
+
+// setFlags returns the names of the flags set so far
+func setFlags(fs *flag.FlagSet) map[string]bool {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	return set
+}
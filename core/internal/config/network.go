@@ -0,0 +1,127 @@
+package config
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/ccoin/core/internal/p2p"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Networks selected by the network flag. A custom network takes its ID
+// from the network-id flag, so operators can run isolated networks
+// without a release naming them.
+const (
+	NetworkMainnet = "mainnet"
+	NetworkTestnet = "testnet"
+	NetworkDevnet  = "devnet"
+	NetworkCustom  = "custom"
+)
+
+// Network holds the parameters a network preset selects
+type Network struct {
+	Name string
+
+	// ID is the network ID peers exchange in the handshake and stamp
+	// stream messages with
+	ID uint32
+
+	// Genesis pins the genesis block hash; zero accepts the genesis of
+	// the first chain synced
+	Genesis types.Hash
+
+	// Default ports of the listen, rpc and jsonrpc flags
+	P2PPort     int
+	RPCPort     int
+	JSONRPCPort int
+}
+
+// networks are the presets by name. Their IDs derive from their names,
+// so nodes that predate presets stay on the same networks.
+var networks = map[string]Network{
+	NetworkMainnet: {Name: NetworkMainnet, ID: p2p.NetworkIDFor(NetworkMainnet), P2PPort: 8000, RPCPort: 8001, JSONRPCPort: 8002},
+	NetworkTestnet: {Name: NetworkTestnet, ID: p2p.NetworkIDFor(NetworkTestnet), P2PPort: 9000, RPCPort: 9001, JSONRPCPort: 9002},
+	NetworkDevnet:  {Name: NetworkDevnet, ID: p2p.NetworkIDFor(NetworkDevnet), P2PPort: 19000, RPCPort: 19001, JSONRPCPort: 19002},
+	NetworkCustom:  {Name: NetworkCustom, P2PPort: 29000, RPCPort: 29001, JSONRPCPort: 29002},
+}
+
+// LookupNetwork returns the preset of a network by name
+func LookupNetwork(name string) (Network, bool) {
+	n, ok := networks[name]
+	return n, ok
+}
+
+// NetworkParams returns the parameters of the network the settings
+// select: its preset, with the ID and genesis hash given by flags in
+// place of the preset's. Settings must be valid.
+func (n *Node) NetworkParams() Network {
+	params := networks[n.Network]
+	if n.NetworkID != 0 {
+		params.ID = uint32(n.NetworkID)
+	}
+	if n.GenesisHash != "" {
+		params.Genesis, _ = ParseHash(n.GenesisHash)
+	}
+	return params
+}
+
+// ParseHash parses a hex block hash, with or without a 0x prefix
+func ParseHash(s string) (types.Hash, error) {
+	var h types.Hash
+	data, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return h, err
+	}
+	if len(data) != types.HashSize {
+		return h, fmt.Errorf("%d bytes, not %d", len(data), types.HashSize)
+	}
+	copy(h[:], data)
+	return h, nil
+}
+
+// ApplyNetwork sets the listen, rpc and jsonrpc flags not set so far,
+// by the command line, environment or config file, to the default
+// ports of the network named by the network flag. Their hosts keep their
+// defaults. Unknown networks are left to Validate.
+func ApplyNetwork(fs *flag.FlagSet) error {
+	f := fs.Lookup("network")
+	if f == nil {
+		return nil
+	}
+	params, ok := networks[f.Value.String()]
+	if !ok {
+		return nil
+	}
+
+	set := setFlags(fs)
+	for name, port := range map[string]int{
+		"listen":  params.P2PPort,
+		"rpc":     params.RPCPort,
+		"jsonrpc": params.JSONRPCPort,
+	} {
+		f := fs.Lookup(name)
+		if f == nil || set[name] || f.DefValue == "" {
+			continue
+		}
+		if err := fs.Set(name, withPort(f.DefValue, port)); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidConfig, name, err)
+		}
+	}
+	return nil
+}
+
+// withPort replaces the port of a host:port address or of a multiaddr
+// ending in one
+func withPort(addr string, port int) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return net.JoinHostPort(host, strconv.Itoa(port))
+	}
+	if i := strings.LastIndex(addr, "/"); i >= 0 {
+		return addr[:i+1] + strconv.Itoa(port)
+	}
+	return addr
+}
@@ -0,0 +1,372 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ccoin/core/internal/atrest"
+	"github.com/ccoin/core/internal/history"
+	"github.com/ccoin/core/internal/ipfs"
+	"github.com/ccoin/core/internal/logging"
+	"github.com/ccoin/core/internal/p2p"
+	"github.com/ccoin/core/internal/storage"
+	"github.com/ccoin/core/internal/telemetry"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Node holds the settings of a ccoind node
+type Node struct {
+	// Database
+	DBBackend  string
+	DBHost     string
+	DBPort     int
+	DBUser     string
+	DBPassword string
+	DBName     string
+
+	// KeyProvider holds the node and miner keys in place of key files
+	KeyProvider string
+
+	// Network
+	NodeKey        string
+	ListenAddr     string
+	BootstrapPeers string
+	RPCAddr        string
+	JSONRPCAddr    string
+	GossipProfile  string
+
+	// Network selects a preset (see LookupNetwork); NetworkID and
+	// GenesisHash set a custom network's, and PSK is the private network
+	// key file
+	Network     string
+	NetworkID   uint
+	GenesisHash string
+	PSK         string
+
+	// Community ban feed, off unless a URL is set
+	BanFeed         string
+	BanFeedKey      string
+	BanFeedInterval time.Duration
+
+	// Misbehaving peers are banned when their score falls to the threshold
+	PeerBanThreshold float64
+	PeerBanDuration  time.Duration
+
+	// Outbound peers discovery connects to per network
+	MaxPeersPerNetwork int
+
+	// NAT traversal
+	Relays       string
+	HolePunching bool
+	NATPortMap   bool
+	RelayService bool
+
+	// State snapshots and pruning
+	SnapshotInterval uint64
+	FastSync         bool
+	Prune            uint64
+
+	// Archive nodes serve state at past heights
+	Archive         bool
+	ArchiveInterval uint64
+
+	// Mempool
+	PersistMempool bool
+	MempoolExpiry  time.Duration
+	ReplaceFeeBump uint64
+	FloorHalfLife  time.Duration
+
+	// Proofs
+	ProofSystem string
+	PLONKSRS    string
+	ZKKeys      string
+
+	// Mining
+	MinerEnabled bool
+	MinerAddress string
+	MinerKey     string
+
+	// Model weights on IPFS
+	IPFSGateway   string
+	IPFSAPI       string
+	IPFSPin       bool
+	IPFSCacheSize int64
+	IPFSMaxSize   int64
+
+	// Subsystems; a node with all of them and RPC disabled only relays
+	ShieldedEnabled bool
+	IndexerEnabled  bool
+
+	// Testnet faucet
+	FaucetAddr       string
+	FaucetAmount     string
+	FaucetTrustProxy bool
+
+	// Block explorer API, off unless an address is set
+	ExplorerAddr   string
+	ExplorerOrigin string
+
+	// Watchtower, off unless an address is set
+	WatchtowerAddr string
+
+	// Diagnostics (admin-only)
+	AdminAddr  string
+	AdminToken string
+
+	// Telemetry, off unless an endpoint is set
+	TelemetryEndpoint string
+	TelemetryInterval time.Duration
+
+	// Logging: the level spec, such as "info,p2p=debug", the log file
+	// with its rotation size in MB and backups kept, and JSON output
+	LogLevel      string
+	LogFile       string
+	LogMaxSize    int64
+	LogMaxBackups int
+	LogJSON       bool
+
+	// Light mode follows headers only, fetching proofs from full nodes
+	Light         bool
+	LightScanFrom uint64
+
+	// Data
+	DataDir string
+
+	// ShutdownTimeout bounds the time services have to drain on shutdown
+	ShutdownTimeout time.Duration
+
+	// Encryption at rest: the mode and the command printing the key if
+	// it comes from a KMS
+	EncryptAtRest string
+	EncryptKeyCmd string
+}
+
+// RegisterFlags registers a flag for every node setting, set to its
+// default
+func (n *Node) RegisterFlags(fs *flag.FlagSet) {
+	// Database flags
+	n.RegisterDBFlags(fs)
+
+	// Network flags
+	fs.StringVar(&n.NodeKey, "node-key", "", "Node identity key file (default: <data-dir>/node.key if present, else a new identity each start)")
+	fs.StringVar(&n.ListenAddr, "listen", "/ip4/0.0.0.0/tcp/9000", "P2P listen address")
+	fs.StringVar(&n.BootstrapPeers, "bootstrap", "", "Comma-separated bootstrap peer multiaddrs")
+	fs.StringVar(&n.RPCAddr, "rpc", "127.0.0.1:9001", "RPC server address (empty to disable)")
+	fs.StringVar(&n.JSONRPCAddr, "jsonrpc", "127.0.0.1:9002", "JSON-RPC HTTP gateway address (empty to disable)")
+	fs.StringVar(&n.GossipProfile, "gossip-profile", p2p.GossipProfileHome, "Gossip tuning profile: datacenter, home, or mobile")
+	fs.StringVar(&n.Network, "network", NetworkTestnet, "Network to join: mainnet, testnet, devnet, or custom with -network-id; selects the default ports, and disclosure policy proposals must name it")
+	fs.UintVar(&n.NetworkID, "network-id", 0, "Network ID of a -network=custom network; peers and messages with another are refused")
+	fs.StringVar(&n.GenesisHash, "genesis-hash", "", "Hex genesis block hash the network is pinned to; peers with another genesis are refused (default: the network's, else the first chain synced)")
+	fs.StringVar(&n.PSK, "psk", "", "Private network key file in the libp2p swarm.key format; only nodes holding the same key can connect (default: <data-dir>/"+p2p.DefaultPSKFile+" if present)")
+	fs.StringVar(&n.BanFeed, "ban-feed", "", "URL of a signed community ban list merged into <data-dir>/bans.json (empty to disable)")
+	fs.StringVar(&n.BanFeedKey, "ban-feed-key", "", "Hex Ed25519 public key the -ban-feed list must be signed with")
+	fs.DurationVar(&n.BanFeedInterval, "ban-feed-interval", time.Hour, "Interval between -ban-feed downloads")
+	fs.Float64Var(&n.PeerBanThreshold, "peer-ban-threshold", p2p.DefaultScoringConfig().BanThreshold, "Peer score at which a misbehaving peer is banned")
+	fs.DurationVar(&n.PeerBanDuration, "peer-ban-duration", p2p.DefaultScoringConfig().BanDuration, "How long misbehaving peers are banned (0 for good)")
+	fs.IntVar(&n.MaxPeersPerNetwork, "max-peers-per-network", p2p.DefaultDiversityConfig().MaxOutbound, "Outbound peers discovery connects to in one IPv4 /16, IPv6 /32 or autonomous system (0 for no limit)")
+	fs.StringVar(&n.Relays, "relay", "", "Comma-separated circuit relay multiaddrs ending in /p2p/<id>, used while the node is unreachable behind NAT")
+	fs.BoolVar(&n.HolePunching, "hole-punching", p2p.DefaultNATConfig().HolePunching, "Upgrade relayed connections to direct ones by hole punching")
+	fs.BoolVar(&n.NATPortMap, "nat-port-map", p2p.DefaultNATConfig().PortMapping, "Ask the gateway to forward the P2P port over UPnP or NAT-PMP")
+	fs.BoolVar(&n.RelayService, "relay-service", p2p.DefaultNATConfig().RelayService, "Relay for peers behind NAT while this node is publicly reachable")
+
+	// Snapshot flags
+	fs.Uint64Var(&n.SnapshotInterval, "snapshot-interval", types.EpochLength, "Heights between state snapshots written to <data-dir>/snapshots and served to peers (0 to disable)")
+	fs.BoolVar(&n.FastSync, "fast-sync", false, "Start an empty node from a peer's state snapshot instead of validating every block")
+	fs.Uint64Var(&n.Prune, "prune", 0, "Keep transaction bodies only for this many heights below the chain height; older proofs, disclosures, memos and off-main-chain transactions are discarded (0 keeps everything)")
+	fs.BoolVar(&n.Archive, "archive", false, "Run an archive node: checkpoint stakes and governance to <data-dir>/history and serve state at past heights over RPC and the explorer")
+	fs.Uint64Var(&n.ArchiveInterval, "archive-interval", history.DefaultInterval, "Heights between -archive checkpoints; stake and governance queries are answered as of the latest checkpoint at or below the height asked for")
+	fs.BoolVar(&n.Light, "light", false, "Run a light node: sync headers only and serve the wallet with proofs and note data from full nodes")
+	fs.Uint64Var(&n.LightScanFrom, "light-scan-from", 0, "First height a light node scans for wallet notes")
+
+	// Mempool flags
+	fs.BoolVar(&n.PersistMempool, "persist-mempool", true, "Journal pending transactions to <data-dir>/mempool.dat and replay them on startup")
+	fs.DurationVar(&n.MempoolExpiry, "mempool-expiry", 72*time.Hour, "Evict pending transactions older than this (0 to keep)")
+	fs.Uint64Var(&n.ReplaceFeeBump, "mempool-replace-bump", 10, "Percentage fee increase required to replace a conflicting pending transaction")
+	fs.DurationVar(&n.FloorHalfLife, "mempool-floor-halflife", 12*time.Hour, "Half-life of the adaptive mempool fee floor once the backlog clears (0 to disable)")
+
+	// Proof flags
+	fs.StringVar(&n.ProofSystem, "proof-system", "groth16", "Transaction proof system: groth16, plonk, or plonk-unsafe (development only)")
+	fs.StringVar(&n.PLONKSRS, "plonk-srs", "", "Universal KZG SRS file for -proof-system=plonk")
+	fs.StringVar(&n.ZKKeys, "zk-keys", "", "Directory of circuit keys written by ccoin-cli zkp setup (default: embedded verifying keys, else local setup)")
+
+	// Mining flags
+	fs.BoolVar(&n.MinerEnabled, "mine", false, "Enable mining")
+	fs.StringVar(&n.MinerAddress, "miner-address", "", "Miner reward address")
+	fs.StringVar(&n.KeyProvider, "key-provider", "", "Provider of the node and miner keys instead of -node-key and -miner-key: file:<dir>, or the URL of a signing service in front of a KMS or HSM (token in "+KeyProviderTokenEnv+")")
+	fs.StringVar(&n.MinerKey, "miner-key", "", "Miner identity key file, created if missing (default: <data-dir>/miner.key if present, else the wallet key)")
+
+	// IPFS flags
+	ipfsDefaults := ipfs.DefaultConfig()
+	fs.StringVar(&n.IPFSGateway, "ipfs-gateway", ipfsDefaults.Gateway, "IPFS gateway model weights are fetched and verified through")
+	fs.StringVar(&n.IPFSAPI, "ipfs-api", ipfsDefaults.API, "Kubo RPC API model weights are added and pinned through (empty for read-only)")
+	fs.BoolVar(&n.IPFSPin, "ipfs-pin", ipfsDefaults.Pin, "Pin fetched model weights on the IPFS node")
+	fs.Int64Var(&n.IPFSCacheSize, "ipfs-cache-size", ipfsDefaults.MaxCacheSize, "Bytes of model weights cached under <data-dir>/ipfs")
+	fs.Int64Var(&n.IPFSMaxSize, "ipfs-max-size", ipfsDefaults.MaxObjectSize, "Largest model weights fetched or added, in bytes")
+
+	// Subsystem flags
+	fs.BoolVar(&n.ShieldedEnabled, "shielded", true, "Process the shielded pool: verify transaction proofs and disclosures, track notes, and serve wallet and send RPCs")
+	fs.BoolVar(&n.IndexerEnabled, "indexer", true, "Serve history and analytics queries over RPC")
+
+	// Faucet flags
+	fs.StringVar(&n.FaucetAddr, "faucet", "", "Public testnet faucet HTTP address, paying from the node wallet (empty to disable)")
+	fs.StringVar(&n.FaucetAmount, "faucet-amount", "10", "CCoin paid per faucet request")
+	fs.BoolVar(&n.FaucetTrustProxy, "faucet-trust-proxy", false, "Rate-limit faucet clients by X-Forwarded-For (behind a reverse proxy)")
+
+	// Explorer flags
+	fs.StringVar(&n.ExplorerAddr, "explorer-addr", "", "Block explorer REST and WebSocket API address (empty to disable)")
+	fs.StringVar(&n.ExplorerOrigin, "explorer-origin", "*", "Web origin allowed to call the explorer API from a browser")
+
+	// Watchtower flags
+	fs.StringVar(&n.WatchtowerAddr, "watchtower", "", "Watchtower HTTP address taking signed subscriptions for webhook alerts on slashing (empty to disable)")
+
+	// Diagnostics flags
+	fs.StringVar(&n.AdminAddr, "admin", "127.0.0.1:6060", "Admin diagnostics (pprof) address (empty to disable)")
+	fs.StringVar(&n.AdminToken, "admin-token", "", "Bearer token required by the admin endpoint")
+
+	// Telemetry flags
+	fs.StringVar(&n.TelemetryEndpoint, "telemetry", "", "Aggregation endpoint anonymized node statistics are reported to (empty to disable; preview at /debug/metrics)")
+	fs.DurationVar(&n.TelemetryInterval, "telemetry-interval", telemetry.DefaultConfig().Interval, "Interval between telemetry reports")
+
+	// Logging flags
+	fs.StringVar(&n.LogLevel, "log-level", "info", "Log level (debug, info, warn, error), optionally followed by per-module levels, e.g. info,p2p=debug,mempool=warn")
+	fs.StringVar(&n.LogFile, "log-file", "", "Log file path (empty for stdout)")
+	fs.Int64Var(&n.LogMaxSize, "log-max-size", logging.DefaultConfig().MaxSize>>20, "Size in MB at which the log file is rotated (0 to disable rotation)")
+	fs.IntVar(&n.LogMaxBackups, "log-max-backups", logging.DefaultConfig().MaxBackups, "Rotated log files to keep")
+	fs.BoolVar(&n.LogJSON, "log-json", false, "Write logs as JSON lines")
+
+	// Data flags
+	fs.StringVar(&n.DataDir, "data-dir", "./data", "Data directory")
+
+	// Shutdown flags
+	fs.DurationVar(&n.ShutdownTimeout, "shutdown-timeout", 30*time.Second, "Time services have to drain in-flight work on shutdown before the node exits anyway")
+}
+
+// RegisterDBFlags registers the database and at-rest encryption flags,
+// which subcommands opening the database share with the node
+func (n *Node) RegisterDBFlags(fs *flag.FlagSet) {
+	fs.StringVar(&n.DBBackend, "db-backend", storage.BackendPostgres, "Storage backend: postgres, or pebble to embed the database under the data directory")
+	fs.StringVar(&n.DBHost, "db-host", "localhost", "PostgreSQL host")
+	fs.IntVar(&n.DBPort, "db-port", 5432, "PostgreSQL port")
+	fs.StringVar(&n.DBUser, "db-user", "ccoin", "PostgreSQL user")
+	fs.StringVar(&n.DBPassword, "db-password", "", "PostgreSQL password")
+	fs.StringVar(&n.DBName, "db-name", "ccoin", "PostgreSQL database name")
+	fs.StringVar(&n.EncryptAtRest, "encrypt-at-rest", string(atrest.ModeOff), "Encrypt data at rest: off, keys (wallet keystore only), or full (also notes, the mempool journal and the pebble chain state); the key comes from -encrypt-key-cmd or "+StoragePassphraseEnv)
+	fs.StringVar(&n.EncryptKeyCmd, "encrypt-key-cmd", "", "Command printing the hex 32-byte at-rest key, e.g. a KMS client, instead of a passphrase")
+}
+
+// Validate checks settings a flag's type cannot, reporting every problem
+// found
+func (n *Node) Validate() error {
+	var errs []error
+	invalid := func(name, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%w: %s: %s", ErrInvalidConfig, name, fmt.Sprintf(format, args...)))
+	}
+
+	switch n.DBBackend {
+	case storage.BackendPostgres, storage.BackendPebble:
+	default:
+		invalid("db-backend", "unknown backend %q", n.DBBackend)
+	}
+	if n.DBPort <= 0 || n.DBPort > 65535 {
+		invalid("db-port", "%d is not a port", n.DBPort)
+	}
+	switch atrest.Mode(n.EncryptAtRest) {
+	case "", atrest.ModeOff, atrest.ModeKeys, atrest.ModeFull:
+	default:
+		invalid("encrypt-at-rest", "unknown mode %q", n.EncryptAtRest)
+	}
+
+	if _, err := p2p.GossipProfile(n.GossipProfile); err != nil {
+		invalid("gossip-profile", "%v", err)
+	}
+	if _, ok := LookupNetwork(n.Network); !ok {
+		invalid("network", "unknown network %q", n.Network)
+	}
+	switch {
+	case n.Network == NetworkCustom && n.NetworkID == 0:
+		invalid("network-id", "required with -network=custom")
+	case n.Network != NetworkCustom && n.NetworkID != 0:
+		invalid("network-id", "only for -network=custom")
+	case n.NetworkID > math.MaxUint32:
+		invalid("network-id", "%d does not fit in 32 bits", n.NetworkID)
+	}
+	if n.GenesisHash != "" {
+		if _, err := ParseHash(n.GenesisHash); err != nil {
+			invalid("genesis-hash", "%v", err)
+		}
+	}
+	for name, addr := range map[string]string{
+		"rpc":           n.RPCAddr,
+		"jsonrpc":       n.JSONRPCAddr,
+		"faucet":        n.FaucetAddr,
+		"explorer-addr": n.ExplorerAddr,
+		"watchtower":    n.WatchtowerAddr,
+		"admin":         n.AdminAddr,
+	} {
+		if addr == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			invalid(name, "%v", err)
+		}
+	}
+	if n.BanFeed != "" && n.BanFeedKey == "" {
+		invalid("ban-feed-key", "required with -ban-feed")
+	}
+	if n.PeerBanThreshold >= 0 {
+		invalid("peer-ban-threshold", "must be negative")
+	}
+	if n.PeerBanDuration < 0 {
+		invalid("peer-ban-duration", "must not be negative")
+	}
+	if n.MaxPeersPerNetwork < 0 {
+		invalid("max-peers-per-network", "must not be negative")
+	}
+	if n.Relays != "" {
+		if _, err := p2p.ParseRelays(strings.Split(n.Relays, ",")); err != nil {
+			invalid("relay", "%v", err)
+		}
+	}
+
+	switch n.ProofSystem {
+	case "groth16", "plonk-unsafe":
+	case "plonk":
+		if n.PLONKSRS == "" {
+			invalid("plonk-srs", "required with -proof-system=plonk")
+		}
+	default:
+		invalid("proof-system", "unknown proof system %q", n.ProofSystem)
+	}
+
+	if _, _, err := logging.ParseLevels(n.LogLevel); err != nil {
+		invalid("log-level", "%v", err)
+	}
+	if n.LogMaxSize < 0 {
+		invalid("log-max-size", "cannot be negative")
+	}
+	if n.LogMaxBackups < 0 {
+		invalid("log-max-backups", "cannot be negative")
+	}
+
+	if n.Light && (n.MinerEnabled || n.FaucetAddr != "" || n.WatchtowerAddr != "") {
+		invalid("light", "a light node cannot mine or run a faucet or watchtower")
+	}
+	if n.Archive && (n.Light || n.Prune > 0) {
+		invalid("archive", "an archive node cannot be a light node or prune blocks")
+	}
+	if n.Archive && n.ArchiveInterval == 0 {
+		invalid("archive-interval", "must be positive")
+	}
+	if n.DataDir == "" {
+		invalid("data-dir", "required")
+	}
+	if n.ShutdownTimeout <= 0 {
+		invalid("shutdown-timeout", "must be positive")
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,316 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Parse reads the settings of a config file. Values are returned as the
+// text a flag is set from: strings unquoted, arrays joined with commas.
+func Parse(data []byte, format Format) ([]Setting, error) {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	if format == FormatYAML {
+		return parseYAML(lines)
+	}
+	return parseTOML(lines)
+}
+
+// parseTOML reads "key = value" lines under optional [table] headers
+func parseTOML(lines []string) ([]Setting, error) {
+	var settings []Setting
+	table := ""
+	for i, line := range lines {
+		n := i + 1
+		line = strings.TrimSpace(stripComment(line))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") || strings.HasPrefix(line, "[[") {
+				return nil, syntaxError(n, "expected [table]")
+			}
+			name, err := parseKey(line[1 : len(line)-1])
+			if err != nil {
+				return nil, syntaxError(n, err.Error())
+			}
+			table = name
+			continue
+		}
+
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, syntaxError(n, "expected key = value")
+		}
+		name, err := parseKey(key)
+		if err != nil {
+			return nil, syntaxError(n, err.Error())
+		}
+		value, err := parseValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, syntaxError(n, err.Error())
+		}
+		settings = append(settings, Setting{Name: join(table, name), Value: value, Line: n})
+	}
+	return settings, nil
+}
+
+// parseYAML reads "key: value" mappings, one level of nested mappings,
+// and sequences given inline or as "- item" lines
+func parseYAML(lines []string) ([]Setting, error) {
+	var settings []Setting
+	var (
+		section       string // key of the open nested mapping
+		sectionIndent int    // indentation of its keys, -1 until seen
+		list          *Setting
+		listIndent    int
+		items         []string
+	)
+	endList := func() {
+		if list != nil {
+			list.Value = strings.Join(items, ",")
+			settings = append(settings, *list)
+			list, items = nil, nil
+		}
+	}
+
+	for i, line := range lines {
+		n := i + 1
+		if leading := line[:len(line)-len(strings.TrimLeft(line, " \t"))]; strings.Contains(leading, "\t") {
+			return nil, syntaxError(n, "tabs cannot indent YAML")
+		}
+		text := strings.TrimSpace(stripComment(line))
+		if text == "" || text == "---" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+
+		if strings.HasPrefix(text, "- ") || text == "-" {
+			if list == nil || indent < listIndent {
+				return nil, syntaxError(n, "sequence item outside a sequence")
+			}
+			item, err := parseValue(strings.TrimSpace(strings.TrimPrefix(text, "-")))
+			if err != nil {
+				return nil, syntaxError(n, err.Error())
+			}
+			items = append(items, item)
+			continue
+		}
+		endList()
+
+		key, raw, ok := strings.Cut(text, ":")
+		if !ok {
+			return nil, syntaxError(n, "expected key: value")
+		}
+		name, err := parseKey(key)
+		if err != nil {
+			return nil, syntaxError(n, err.Error())
+		}
+		raw = strings.TrimSpace(raw)
+
+		switch {
+		case indent == 0:
+			section = ""
+		case section == "":
+			return nil, syntaxError(n, "unexpected indentation")
+		case sectionIndent < 0:
+			sectionIndent = indent
+		case indent != sectionIndent:
+			return nil, syntaxError(n, "inconsistent indentation")
+		}
+		if section != "" {
+			name = join(section, name)
+		}
+
+		if raw == "" {
+			// Opens a nested mapping or a sequence, told apart by the
+			// next line
+			if indent == 0 && nextIsMapping(lines[i+1:]) {
+				section, sectionIndent = name, -1
+				continue
+			}
+			list = &Setting{Name: name, Line: n}
+			listIndent = indent
+			continue
+		}
+
+		value, err := parseValue(raw)
+		if err != nil {
+			return nil, syntaxError(n, err.Error())
+		}
+		settings = append(settings, Setting{Name: name, Value: value, Line: n})
+	}
+	endList()
+	return settings, nil
+}
+
+// nextIsMapping reports whether the next non-blank line is an indented
+// "key: value" rather than a sequence item
+func nextIsMapping(lines []string) bool {
+	for _, line := range lines {
+		text := strings.TrimSpace(stripComment(line))
+		if text == "" {
+			continue
+		}
+		return line[0] == ' ' && !strings.HasPrefix(text, "-")
+	}
+	return false
+}
+
+// parseKey returns a bare or quoted key, underscores and dots read as
+// hyphens
+func parseKey(key string) (string, error) {
+	key = strings.TrimSpace(key)
+	if len(key) >= 2 && (key[0] == '"' || key[0] == '\'') {
+		unquoted, err := parseValue(key)
+		if err != nil {
+			return "", err
+		}
+		key = unquoted
+	}
+	if key == "" {
+		return "", fmt.Errorf("empty key")
+	}
+	for _, c := range key {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return "", fmt.Errorf("invalid key %q", key)
+		}
+	}
+	return strings.ToLower(strings.NewReplacer("_", "-", ".", "-").Replace(key)), nil
+}
+
+// parseValue returns the flag text of a scalar or an array. Unquoted
+// scalars are taken as written.
+func parseValue(raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+	switch raw[0] {
+	case '"':
+		return unquoteBasic(raw)
+	case '\'':
+		if len(raw) < 2 || raw[len(raw)-1] != '\'' {
+			return "", fmt.Errorf("unterminated string")
+		}
+		return strings.ReplaceAll(raw[1:len(raw)-1], "''", "'"), nil
+	case '[':
+		if raw[len(raw)-1] != ']' {
+			return "", fmt.Errorf("unterminated array")
+		}
+		var items []string
+		for _, item := range splitArray(raw[1 : len(raw)-1]) {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			value, err := parseValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, value)
+		}
+		return strings.Join(items, ","), nil
+	}
+	return raw, nil
+}
+
+// unquoteBasic decodes a double-quoted string
+func unquoteBasic(raw string) (string, error) {
+	if len(raw) < 2 || raw[len(raw)-1] != '"' {
+		return "", fmt.Errorf("unterminated string")
+	}
+	var b strings.Builder
+	body := raw[1 : len(raw)-1]
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if i == len(body) {
+			return "", fmt.Errorf("unterminated escape")
+		}
+		switch body[i] {
+		case '"', '\\', '/':
+			b.WriteByte(body[i])
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		default:
+			return "", fmt.Errorf("unsupported escape \\%c", body[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// splitArray splits array items on commas outside quotes
+func splitArray(s string) []string {
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && opensQuote(s, i):
+			quote = c
+		case c == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, s[start:])
+}
+
+// stripComment removes a '#' comment outside quotes. In YAML and TOML
+// alike a comment must start the line or follow whitespace.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && opensQuote(line, i):
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// opensQuote reports whether the quote at s[i] starts a quoted string
+// rather than sitting inside an unquoted value, as in don't
+func opensQuote(s string, i int) bool {
+	if i == 0 {
+		return true
+	}
+	switch s[i-1] {
+	case ' ', '\t', '=', ':', '[', ',', '-':
+		return true
+	}
+	return false
+}
+
+// join names a key inside a table or nested mapping
+func join(table, key string) string {
+	if table == "" {
+		return key
+	}
+	return table + "-" + key
+}
+
+// syntaxError reports a malformed line
+func syntaxError(line int, msg string) error {
+	return fmt.Errorf("line %d: %w: %s", line, ErrSyntax, msg)
+}
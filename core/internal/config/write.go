@@ -0,0 +1,95 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// WriteDefaults writes a config file setting every flag of fs to its
+// default, each preceded by its usage as a comment
+func WriteDefaults(w io.Writer, fs *flag.FlagSet, format Format, header string) error {
+	var settings []Setting
+	usage := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) {
+		settings = append(settings, Setting{Name: f.Name, Value: f.DefValue})
+		usage[f.Name] = f.Usage
+	})
+	return write(w, format, header, settings, usage)
+}
+
+// WriteSettings writes the given settings as a config file
+func WriteSettings(w io.Writer, format Format, header string, settings []Setting) error {
+	return write(w, format, header, settings, nil)
+}
+
+// WriteFile writes settings to a new config file in the format its
+// extension selects. The file may hold the database password, so only
+// the owner can read it.
+func WriteFile(path, header string, settings []Setting) error {
+	var b strings.Builder
+	if err := WriteSettings(&b, FormatOf(path), header, settings); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(b.String()), 0600)
+}
+
+// write writes a header comment, then each setting after its usage
+func write(w io.Writer, format Format, header string, settings []Setting, usage map[string]string) error {
+	var b strings.Builder
+	if header != "" {
+		for _, line := range strings.Split(header, "\n") {
+			comment(&b, line)
+		}
+		b.WriteString("\n")
+	}
+
+	sep := " = "
+	if format == FormatYAML {
+		sep = ": "
+	}
+	for _, s := range settings {
+		if text := usage[s.Name]; text != "" {
+			comment(&b, text)
+		}
+		b.WriteString(s.Name + sep + quote(s.Value) + "\n")
+		if usage != nil {
+			b.WriteString("\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// comment writes text as a comment line
+func comment(b *strings.Builder, text string) {
+	if text == "" {
+		b.WriteString("#\n")
+		return
+	}
+	fmt.Fprintf(b, "# %s\n", text)
+}
+
+// quote writes a value as a scalar both formats read back unchanged:
+// booleans and numbers bare, anything else as a double-quoted string
+func quote(value string) string {
+	if _, err := strconv.ParseBool(value); err == nil && strings.ToLower(value) == value {
+		return value
+	}
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return value
+	}
+	if _, err := strconv.ParseUint(value, 10, 64); err == nil {
+		return value
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil && !strings.ContainsAny(value, "xXpPiInN_") {
+		return value
+	}
+	return `"` + quoteEscaper.Replace(value) + `"`
+}
+
+// quoteEscaper escapes what unquoteBasic decodes
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
@@ -3,13 +3,23 @@ package consensus
 
 import (
 	"context"
+	"errors"
+	"log/slog"
 	"math/big"
 	"sync"
 
 	"github.com/ccoin/core/internal/dag"
+	"github.com/ccoin/core/internal/logging"
 	"github.com/ccoin/core/pkg/types"
 )
 
+// Consensus errors
+var (
+	ErrPayoutMismatch      = errors.New("block payout address does not match miner's registered payout")
+	ErrInvalidPayoutChange = errors.New("invalid payout change signature")
+	ErrStalePayoutChange   = errors.New("payout change nonce not above previous change")
+)
+
 // Consensus implements the CCoin reputation-weighted consensus algorithm
 type Consensus struct {
 	mu sync.RWMutex
@@ -26,6 +36,8 @@ type Consensus struct {
 	// Difficulty adjustment parameters
 	targetBlockTime uint64 // Target seconds between blocks
 	difficultyWindow uint64 // Number of blocks to consider for adjustment
+
+	log *slog.Logger
 }
 
 // MinerStore defines the interface for miner data storage
@@ -46,6 +58,10 @@ type Config struct {
 
 	// EpochLength is the number of blocks per epoch
 	EpochLength uint64
+
+	// Logger receives the engine's logs; nil uses the consensus module
+	// logger
+	Logger *slog.Logger
 }
 
 // DefaultConfig returns default consensus configuration
@@ -62,12 +78,17 @@ func NewConsensus(d *dag.DAG, minerStore MinerStore, config *Config) *Consensus
 	if config == nil {
 		config = DefaultConfig()
 	}
+	logger := config.Logger
+	if logger == nil {
+		logger = logging.Module("consensus")
+	}
 
 	return &Consensus{
 		dag:              d,
 		minerStore:       minerStore,
 		targetBlockTime:  config.TargetBlockTime,
 		difficultyWindow: config.DifficultyWindow,
+		log:              logger,
 	}
 }
 
@@ -127,6 +148,11 @@ func (c *Consensus) ProcessBlock(ctx context.Context, block *types.Block) error
 		miner = types.NewMiner(header.MinerAddress)
 	}
 
+	// The reward must go where the miner registered it
+	if header.PayoutAddress != miner.PayoutAt(header.Height) {
+		return ErrPayoutMismatch
+	}
+
 	// Record the block
 	epoch := header.Height / types.EpochLength
 	miner.RecordBlock(header.QualityScore, epoch)
@@ -140,6 +166,10 @@ func (c *Consensus) ProcessBlock(ctx context.Context, block *types.Block) error
 		return err
 	}
 
+	if err := c.applyPayoutChanges(ctx, block); err != nil {
+		return err
+	}
+
 	// Check for epoch transition
 	newEpoch := header.Height / types.EpochLength
 	if newEpoch > c.epoch {
@@ -152,12 +182,78 @@ func (c *Consensus) ProcessBlock(ctx context.Context, block *types.Block) error
 	return nil
 }
 
+// PayoutAddress returns the address a block mined by miner at height must
+// pay its reward to
+func (c *Consensus) PayoutAddress(ctx context.Context, miner types.Address, height uint64) types.Address {
+	record, err := c.minerStore.GetMiner(ctx, miner)
+	if err != nil {
+		return miner
+	}
+	return record.PayoutAt(height)
+}
+
+// ValidatePayoutChange checks a payout change's signature and that its
+// nonce advances past the miner's last accepted change
+func (c *Consensus) ValidatePayoutChange(ctx context.Context, change *types.PayoutChange) error {
+	if !change.Verify() {
+		return ErrInvalidPayoutChange
+	}
+	if miner, err := c.minerStore.GetMiner(ctx, change.Miner()); err == nil && change.Nonce <= miner.PayoutNonce {
+		return ErrStalePayoutChange
+	}
+	return nil
+}
+
+// applyPayoutChanges schedules the payout changes carried by a block's
+// transactions. Each takes effect PayoutChangeDelay blocks later.
+func (c *Consensus) applyPayoutChanges(ctx context.Context, block *types.Block) error {
+	for _, tx := range block.Transactions {
+		change := tx.PayoutChange
+		if change == nil {
+			continue
+		}
+		if err := c.ValidatePayoutChange(ctx, change); err != nil {
+			return err
+		}
+
+		miner, err := c.minerStore.GetMiner(ctx, change.Miner())
+		if err != nil {
+			miner = types.NewMiner(change.Miner())
+		}
+		miner.SchedulePayout(change.Payout, block.Header.Height)
+		miner.PayoutNonce = change.Nonce
+		if err := c.minerStore.SaveMiner(ctx, miner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StakingRecorder is implemented by miner stores that keep per-epoch
+// staking history for analytics
+type StakingRecorder interface {
+	RecordStakingSnapshot(ctx context.Context, epoch uint64) error
+}
+
 // processEpochTransition handles reputation updates at epoch boundaries
 func (c *Consensus) processEpochTransition(ctx context.Context, newEpoch uint64) error {
-	return c.minerStore.UpdateReputations(ctx, newEpoch)
+	if recorder, ok := c.minerStore.(StakingRecorder); ok {
+		if err := recorder.RecordStakingSnapshot(ctx, newEpoch-1); err != nil {
+			return err
+		}
+	}
+
+	if err := c.minerStore.UpdateReputations(ctx, newEpoch); err != nil {
+		return err
+	}
+	c.log.Info("epoch transition", "epoch", newEpoch)
+	return nil
 }
 
-// CalculateDifficulty computes the difficulty for the next block
+// CalculateDifficulty computes the difficulty target for the next block.
+// Blocks inherit the reference parent's target, which is retargeted once
+// per difficulty window from the main chain's average block time over the
+// window: slow blocks raise the target and fast blocks lower it.
 func (c *Consensus) CalculateDifficulty(ctx context.Context, parentHeaders []*types.BlockHeader) *big.Int {
 	if len(parentHeaders) == 0 {
 		return c.getGenesisDifficulty()
@@ -174,6 +270,12 @@ func (c *Consensus) CalculateDifficulty(ctx context.Context, parentHeaders []*ty
 		}
 	}
 
+	// Retarget only at window boundaries
+	currentDifficulty := refHeader.Difficulty
+	if c.difficultyWindow == 0 || (refHeader.Height+1)%c.difficultyWindow != 0 {
+		return new(big.Int).Set(currentDifficulty)
+	}
+
 	// Get blocks for difficulty window
 	startHeight := uint64(0)
 	if refHeader.Height > c.difficultyWindow {
@@ -181,15 +283,12 @@ func (c *Consensus) CalculateDifficulty(ctx context.Context, parentHeaders []*ty
 	}
 
 	// Calculate average block time in the window
-	// (Simplified - in production, would query all blocks in range)
-	avgBlockTime := c.targetBlockTime // Default to target
+	avgBlockTime := c.averageBlockTime(ctx, startHeight, refHeader.Height)
 
 	// Adjust difficulty
 	// If blocks are too fast, increase difficulty (lower target)
 	// If blocks are too slow, decrease difficulty (higher target)
-	currentDifficulty := refHeader.Difficulty
-
-	ratio := float64(avgBlockTime) / float64(c.targetBlockTime)
+	ratio := avgBlockTime / float64(c.targetBlockTime)
 
 	// Clamp adjustment to ±4x per window
 	if ratio < 0.25 {
@@ -207,12 +306,39 @@ func (c *Consensus) CalculateDifficulty(ctx context.Context, parentHeaders []*ty
 	// Ensure minimum difficulty
 	minDifficulty := c.getMinDifficulty()
 	if result.Cmp(minDifficulty) < 0 {
-		return minDifficulty
+		result = minDifficulty
+	}
+	if maxTarget := c.getMaxTarget(); result.Cmp(maxTarget) > 0 {
+		result = maxTarget
 	}
 
+	c.log.Debug("difficulty retargeted", "height", refHeader.Height+1,
+		"avg_block_time", avgBlockTime, "ratio", ratio, "target_bits", result.BitLen())
 	return result
 }
 
+// averageBlockTime returns the mean seconds between main chain blocks from
+// fromHeight to toHeight. Without enough stored blocks, or timestamps that
+// do not advance, it returns the target so the difficulty holds.
+func (c *Consensus) averageBlockTime(ctx context.Context, fromHeight, toHeight uint64) float64 {
+	target := float64(c.targetBlockTime)
+	if c.dag == nil {
+		return target
+	}
+
+	headers, err := c.dag.GetMainChain(ctx, fromHeight, toHeight)
+	if err != nil || len(headers) < 2 {
+		return target
+	}
+
+	first := headers[0]
+	last := headers[len(headers)-1]
+	if last.Timestamp <= first.Timestamp || last.Height <= first.Height {
+		return target
+	}
+	return float64(last.Timestamp-first.Timestamp) / float64(last.Height-first.Height)
+}
+
 // getGenesisDifficulty returns the initial difficulty
 func (c *Consensus) getGenesisDifficulty() *big.Int {
 	// Start with a moderate difficulty
@@ -225,6 +351,12 @@ func (c *Consensus) getMinDifficulty() *big.Int {
 	return new(big.Int).Exp(big.NewInt(2), big.NewInt(100), nil)
 }
 
+// getMaxTarget returns the easiest target a hash can be held to
+func (c *Consensus) getMaxTarget() *big.Int {
+	max := new(big.Int).Lsh(big.NewInt(1), 256)
+	return max.Sub(max, big.NewInt(1))
+}
+
 // ValidateDifficulty checks if a block meets its difficulty target
 func (c *Consensus) ValidateDifficulty(block *types.Block) bool {
 	header := block.Header
@@ -0,0 +1,280 @@
+// Package credentials maintains the governance-registered credential
+// authorities and the credential revocation list identity disclosures are
+// checked against.
+package credentials
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Registry errors
+var (
+	ErrNotCredentialProposal = errors.New("not an identity authority or credential revocation proposal")
+	ErrProposalNotPassed     = errors.New("credential proposal has not passed")
+	ErrRootMismatch          = errors.New("revocation does not produce the proposed root")
+)
+
+// Store persists authorities and revocations
+type Store interface {
+	// LoadAuthorities returns every registered authority
+	LoadAuthorities(ctx context.Context) ([]zkp.Authority, error)
+
+	// SaveAuthority registers an authority, or removes it
+	SaveAuthority(ctx context.Context, authority zkp.Authority, remove bool, proposalID types.Hash) error
+
+	// LoadRevocations returns the serial of every revoked credential
+	LoadRevocations(ctx context.Context) ([]types.Address, error)
+
+	// SaveRevocations records a revocation change made by a proposal
+	SaveRevocations(ctx context.Context, revoke, reinstate []types.Address, proposalID types.Hash) error
+}
+
+// Registry holds the credential authorities and a sparse Merkle tree of
+// revoked credential serials. Both only change through passed proposals.
+type Registry struct {
+	mu sync.RWMutex
+
+	authorities map[types.Hash]zkp.Authority
+	revoked     *zkp.SanctionsTree
+
+	// Proposals already applied
+	applied map[types.Hash]bool
+
+	store Store
+}
+
+// NewRegistry creates an empty credential registry
+func NewRegistry(store Store) *Registry {
+	return &Registry{
+		authorities: make(map[types.Hash]zkp.Authority),
+		revoked:     zkp.NewSanctionsTree(),
+		applied:     make(map[types.Hash]bool),
+		store:       store,
+	}
+}
+
+// Initialize loads authorities and revocations from the store
+func (r *Registry) Initialize(ctx context.Context) error {
+	authorities, err := r.store.LoadAuthorities(ctx)
+	if err != nil {
+		return err
+	}
+	serials, err := r.store.LoadRevocations(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.authorities = make(map[types.Hash]zkp.Authority, len(authorities))
+	for _, a := range authorities {
+		r.authorities[a.PublicKey] = a
+	}
+	r.revoked = zkp.NewSanctionsTree(serials...)
+	return nil
+}
+
+// Authority implements zkp.CredentialRegistry
+func (r *Registry) Authority(key types.Hash) (zkp.Authority, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	a, ok := r.authorities[key]
+	return a, ok
+}
+
+// Authorities returns the registered authorities ordered by key
+func (r *Registry) Authorities() []zkp.Authority {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	authorities := make([]zkp.Authority, 0, len(r.authorities))
+	for _, a := range r.authorities {
+		authorities = append(authorities, a)
+	}
+	sort.Slice(authorities, func(i, j int) bool {
+		return authorities[i].PublicKey.String() < authorities[j].PublicKey.String()
+	})
+	return authorities
+}
+
+// RevocationRoot implements zkp.CredentialRegistry
+func (r *Registry) RevocationRoot() types.Hash {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.revoked.Root()
+}
+
+// IsRevoked reports whether the credential with serial is revoked
+func (r *Registry) IsRevoked(serial types.Address) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.revoked.Contains(serial)
+}
+
+// Prove returns the current revocation root and a non-membership path for
+// serial, for building an identity disclosure. It fails with
+// zkp.ErrCredentialRevoked for revoked credentials.
+func (r *Registry) Prove(serial types.Address) (types.Hash, *zkp.SanctionsPath, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	path, err := r.revoked.Prove(serial)
+	if err == zkp.ErrAddressSanctioned {
+		return types.Hash{}, nil, zkp.ErrCredentialRevoked
+	}
+	if err != nil {
+		return types.Hash{}, nil, err
+	}
+	return r.revoked.Root(), path, nil
+}
+
+// PreviewRevocation returns the root the revocation list would have after
+// a change, for filling in a credential revocation proposal
+func (r *Registry) PreviewRevocation(revoke, reinstate []types.Address) types.Hash {
+	r.mu.RLock()
+	next := r.revoked.Clone()
+	r.mu.RUnlock()
+
+	next.Update(revoke, reinstate)
+	return next.Root()
+}
+
+// ApplyProposal applies a passed identity authority or credential
+// revocation proposal; each proposal applies once
+func (r *Registry) ApplyProposal(ctx context.Context, proposal *types.Proposal) error {
+	if proposal.Type != types.ProposalIdentityAuthority && proposal.Type != types.ProposalCredentialRevocation {
+		return ErrNotCredentialProposal
+	}
+	if proposal.Status != types.ProposalStatusPassed && proposal.Status != types.ProposalStatusExecuted {
+		return ErrProposalNotPassed
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.applied[proposal.ProposalID] {
+		return nil
+	}
+
+	switch data := proposal.Data.(type) {
+	case *types.IdentityAuthorityData:
+		if err := r.applyAuthorityLocked(ctx, data, proposal.ProposalID); err != nil {
+			return err
+		}
+	case *types.CredentialRevocationData:
+		if err := r.applyRevocationLocked(ctx, data, proposal.ProposalID); err != nil {
+			return err
+		}
+	default:
+		return ErrNotCredentialProposal
+	}
+
+	r.applied[proposal.ProposalID] = true
+	return nil
+}
+
+// applyAuthorityLocked registers or removes an authority
+func (r *Registry) applyAuthorityLocked(ctx context.Context, data *types.IdentityAuthorityData, proposalID types.Hash) error {
+	authority := zkp.Authority{PublicKey: data.PublicKey, Name: data.Name, Domain: data.Domain}
+	if !data.Remove && !zkp.ValidAuthorityKey(data.PublicKey) {
+		return zkp.ErrInvalidAuthorityKey
+	}
+
+	if err := r.store.SaveAuthority(ctx, authority, data.Remove, proposalID); err != nil {
+		return err
+	}
+	if data.Remove {
+		delete(r.authorities, data.PublicKey)
+	} else {
+		r.authorities[data.PublicKey] = authority
+	}
+	return nil
+}
+
+// applyRevocationLocked revokes and reinstates credentials. The change
+// must produce the root voters approved.
+func (r *Registry) applyRevocationLocked(ctx context.Context, data *types.CredentialRevocationData, proposalID types.Hash) error {
+	next := r.revoked.Clone()
+	next.Update(data.Revoke, data.Reinstate)
+	if next.Root() != data.Root {
+		return ErrRootMismatch
+	}
+
+	if err := r.store.SaveRevocations(ctx, data.Revoke, data.Reinstate, proposalID); err != nil {
+		return err
+	}
+	r.revoked = next
+	return nil
+}
+
+// InMemoryStore is a simple in-memory Store for testing
+type InMemoryStore struct {
+	mu          sync.Mutex
+	authorities map[types.Hash]zkp.Authority
+	revoked     map[types.Address]struct{}
+}
+
+// NewInMemoryStore creates an empty in-memory store
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{
+		authorities: make(map[types.Hash]zkp.Authority),
+		revoked:     make(map[types.Address]struct{}),
+	}
+}
+
+// LoadAuthorities returns every registered authority
+func (s *InMemoryStore) LoadAuthorities(ctx context.Context) ([]zkp.Authority, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	authorities := make([]zkp.Authority, 0, len(s.authorities))
+	for _, a := range s.authorities {
+		authorities = append(authorities, a)
+	}
+	return authorities, nil
+}
+
+// SaveAuthority registers an authority, or removes it
+func (s *InMemoryStore) SaveAuthority(ctx context.Context, authority zkp.Authority, remove bool, proposalID types.Hash) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if remove {
+		delete(s.authorities, authority.PublicKey)
+	} else {
+		s.authorities[authority.PublicKey] = authority
+	}
+	return nil
+}
+
+// LoadRevocations returns the serial of every revoked credential
+func (s *InMemoryStore) LoadRevocations(ctx context.Context) ([]types.Address, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	serials := make([]types.Address, 0, len(s.revoked))
+	for serial := range s.revoked {
+		serials = append(serials, serial)
+	}
+	return serials, nil
+}
+
+// SaveRevocations records a revocation change
+func (s *InMemoryStore) SaveRevocations(ctx context.Context, revoke, reinstate []types.Address, proposalID types.Hash) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, serial := range revoke {
+		s.revoked[serial] = struct{}{}
+	}
+	for _, serial := range reinstate {
+		delete(s.revoked, serial)
+	}
+	return nil
+}
@@ -4,9 +4,11 @@ package dag
 import (
 	"context"
 	"errors"
+	"log/slog"
 	"math/big"
 	"sync"
 
+	"github.com/ccoin/core/internal/logging"
 	"github.com/ccoin/core/pkg/types"
 )
 
@@ -29,9 +31,6 @@ type DAG struct {
 	// Cache for recently accessed blocks
 	cache *BlockCache
 
-	// Index of block children for efficient traversal
-	children map[types.Hash][]types.Hash
-
 	// Genesis block hash
 	genesisHash types.Hash
 
@@ -46,6 +45,13 @@ type DAG struct {
 
 	// Current epoch
 	epoch uint64
+
+	// GHOSTDAG coloring, guarded by gdMu
+	gdMu     sync.Mutex
+	ghostdag map[types.Hash]*GhostdagData
+	k        int
+
+	log *slog.Logger
 }
 
 // Store defines the interface for DAG persistent storage
@@ -75,6 +81,13 @@ type Store interface {
 	GetTips(ctx context.Context) ([]types.Hash, error)
 }
 
+// BatchStore is implemented by stores that save many blocks in one
+// write
+type BatchStore interface {
+	// BatchSaveBlocks saves blocks, parents first, atomically
+	BatchSaveBlocks(ctx context.Context, blocks []*types.Block) error
+}
+
 // Config holds DAG configuration
 type Config struct {
 	// CacheSize is the number of blocks to cache in memory
@@ -82,6 +95,12 @@ type Config struct {
 
 	// MaxParents is the maximum number of parents per block
 	MaxParents int
+
+	// GhostdagK is the maximum anticone size of a blue block
+	GhostdagK int
+
+	// Logger receives the DAG's logs; nil uses the dag module logger
+	Logger *slog.Logger
 }
 
 // DefaultConfig returns the default DAG configuration
@@ -89,6 +108,7 @@ func DefaultConfig() *Config {
 	return &Config{
 		CacheSize:  10000,
 		MaxParents: types.MaxParents,
+		GhostdagK:  DefaultGhostdagK,
 	}
 }
 
@@ -97,12 +117,22 @@ func NewDAG(store Store, config *Config) *DAG {
 	if config == nil {
 		config = DefaultConfig()
 	}
+	k := config.GhostdagK
+	if k <= 0 {
+		k = DefaultGhostdagK
+	}
+	logger := config.Logger
+	if logger == nil {
+		logger = logging.Module("dag")
+	}
 
 	return &DAG{
 		store:    store,
 		cache:    NewBlockCache(config.CacheSize),
-		children: make(map[types.Hash][]types.Hash),
 		tips:     make(map[types.Hash]struct{}),
+		ghostdag: make(map[types.Hash]*GhostdagData),
+		k:        k,
+		log:      logger,
 	}
 }
 
@@ -121,7 +151,8 @@ func (d *DAG) Initialize(ctx context.Context) error {
 		d.tips[tip] = struct{}{}
 	}
 
-	// Find main chain tip (highest cumulative score)
+	// Find main chain tip (highest cumulative score, ties to the larger
+	// hash so a restart picks the same tip)
 	var maxScore *big.Float
 	for tip := range d.tips {
 		header, err := d.store.GetBlockHeader(ctx, tip)
@@ -129,7 +160,7 @@ func (d *DAG) Initialize(ctx context.Context) error {
 			continue
 		}
 
-		if maxScore == nil || header.CumulativeScore.Cmp(maxScore) > 0 {
+		if maxScore == nil || heavier(header.CumulativeScore, tip, maxScore, d.mainChainTip) {
 			maxScore = header.CumulativeScore
 			d.mainChainTip = tip
 			d.height = header.Height
@@ -158,68 +189,138 @@ func (d *DAG) AddBlock(ctx context.Context, block *types.Block) error {
 		}
 	}
 
-	// Calculate cumulative score
-	block.Header.CumulativeScore = d.calculateCumulativeScore(ctx, block.Header)
+	// Color the block's merge set; its blue work is the cumulative score
+	d.gdMu.Lock()
+	data, err := d.computeGhostdagLocked(ctx, block.Header)
+	d.gdMu.Unlock()
+	if err != nil {
+		return err
+	}
+	block.Header.CumulativeScore = data.BlueWork
 
 	// Save block
 	if err := d.store.SaveBlock(ctx, block); err != nil {
 		return err
 	}
 
+	d.gdMu.Lock()
+	d.ghostdag[block.Header.Hash] = data
+	d.gdMu.Unlock()
+
 	// Update cache
 	d.cache.Add(block)
 
-	// Update children index
-	for _, parentHash := range block.Header.Parents {
-		d.children[parentHash] = append(d.children[parentHash], block.Header.Hash)
-
-		// Parent is no longer a tip
-		delete(d.tips, parentHash)
-	}
-
-	// New block is a tip
-	d.tips[block.Header.Hash] = struct{}{}
+	d.linkLocked(block)
+	d.log.Debug("block added", "hash", block.Header.Hash.String(), "height", block.Header.Height,
+		"parents", len(block.Header.Parents), "txs", len(block.Transactions))
 
 	// Update main chain if necessary
-	if block.Header.CumulativeScore.Cmp(d.getMainChainScore(ctx)) > 0 {
+	if heavier(block.Header.CumulativeScore, block.Header.Hash, d.getMainChainScore(ctx), d.mainChainTip) {
 		if err := d.updateMainChain(ctx, block.Header.Hash); err != nil {
 			return err
 		}
 	}
 
-	// Update height if necessary
-	if block.Header.Height > d.height {
-		d.height = block.Header.Height
-		d.epoch = d.height / types.EpochLength
-	}
-
 	return nil
 }
 
-// calculateCumulativeScore computes S(B) = Work(B) * Rep(m) + sum(S(parents))
-func (d *DAG) calculateCumulativeScore(ctx context.Context, header *types.BlockHeader) *big.Float {
-	// Work * Reputation
-	work := new(big.Float).SetInt(header.Work())
-	rep := big.NewFloat(header.ReputationScore)
-	score := new(big.Float).Mul(work, rep)
-
-	// Add parent scores (we use max parent score for simplicity in DAG)
-	var maxParentScore *big.Float
-	for _, parentHash := range header.Parents {
-		parentHeader, err := d.getBlockHeader(ctx, parentHash)
-		if err != nil {
+// AddBlocks adds blocks ordered parents first, such as a run of blocks
+// downloaded during sync. With a BatchStore they are saved in one write
+// and none is added if it fails. Blocks already in the DAG are skipped.
+func (d *DAG) AddBlocks(ctx context.Context, blocks []*types.Block) error {
+	batcher, ok := d.store.(BatchStore)
+	if !ok {
+		for _, block := range blocks {
+			if err := d.AddBlock(ctx, block); err != nil && !errors.Is(err, ErrDuplicateBlock) {
+				return err
+			}
+		}
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Blocks are cached and colored before they are saved, so later
+	// blocks of the batch find their parents
+	var added []*types.Block
+	discard := func() {
+		d.gdMu.Lock()
+		for _, block := range added {
+			delete(d.ghostdag, block.Header.Hash)
+		}
+		d.gdMu.Unlock()
+		for _, block := range added {
+			d.cache.Remove(block.Header.Hash)
+		}
+	}
+
+	for _, block := range blocks {
+		if _, err := d.getBlockHeader(ctx, block.Header.Hash); err == nil {
 			continue
 		}
-		if maxParentScore == nil || parentHeader.CumulativeScore.Cmp(maxParentScore) > 0 {
-			maxParentScore = parentHeader.CumulativeScore
+		for _, parentHash := range block.Header.Parents {
+			if _, err := d.getBlockHeader(ctx, parentHash); err != nil {
+				discard()
+				return ErrOrphanBlock
+			}
+		}
+
+		d.gdMu.Lock()
+		data, err := d.computeGhostdagLocked(ctx, block.Header)
+		if err == nil {
+			d.ghostdag[block.Header.Hash] = data
+		}
+		d.gdMu.Unlock()
+		if err != nil {
+			discard()
+			return err
+		}
+		block.Header.CumulativeScore = data.BlueWork
+
+		d.cache.Add(block)
+		added = append(added, block)
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	if err := batcher.BatchSaveBlocks(ctx, added); err != nil {
+		discard()
+		return err
+	}
+	d.log.Debug("blocks added", "count", len(added),
+		"from", added[0].Header.Height, "to", added[len(added)-1].Header.Height)
+
+	best, bestScore := d.mainChainTip, d.getMainChainScore(ctx)
+	for _, block := range added {
+		d.linkLocked(block)
+		if heavier(block.Header.CumulativeScore, block.Header.Hash, bestScore, best) {
+			best, bestScore = block.Header.Hash, block.Header.CumulativeScore
 		}
 	}
+	if best != d.mainChainTip {
+		return d.updateMainChain(ctx, best)
+	}
+	return nil
+}
 
-	if maxParentScore != nil {
-		score.Add(score, maxParentScore)
+// linkLocked records a saved block as the newest tip in place of its
+// parents, and raises the height
+func (d *DAG) linkLocked(block *types.Block) {
+	for _, parentHash := range block.Header.Parents {
+		// Parent is no longer a tip
+		delete(d.tips, parentHash)
 	}
 
-	return score
+	// New block is a tip
+	d.tips[block.Header.Hash] = struct{}{}
+
+	// Update height if necessary
+	if block.Header.Height > d.height {
+		d.height = block.Header.Height
+		d.epoch = d.height / types.EpochLength
+	}
 }
 
 // getMainChainScore returns the current main chain tip's cumulative score
@@ -270,39 +371,27 @@ func (d *DAG) updateMainChain(ctx context.Context, newTip types.Hash) error {
 		return err
 	}
 
+	if len(offChain) > 0 {
+		d.log.Info("main chain reorganized", "tip", newTip.String(), "connected", len(onChain), "disconnected", len(offChain))
+	}
 	d.mainChainTip = newTip
 	return nil
 }
 
-// getPathToGenesis returns the path from a block to genesis following highest-score parents
+// getPathToGenesis returns the selected-parent chain from a block to genesis
 func (d *DAG) getPathToGenesis(ctx context.Context, hash types.Hash) []types.Hash {
-	path := []types.Hash{}
-	current := hash
+	d.gdMu.Lock()
+	defer d.gdMu.Unlock()
 
-	for !current.IsEmpty() {
+	path := []types.Hash{}
+	for current := hash; !current.IsEmpty(); {
 		path = append(path, current)
 
-		header, err := d.getBlockHeader(ctx, current)
-		if err != nil || len(header.Parents) == 0 {
+		data, err := d.ghostdagLocked(ctx, current)
+		if err != nil {
 			break
 		}
-
-		// Follow the highest-score parent
-		var bestParent types.Hash
-		var bestScore *big.Float
-
-		for _, parentHash := range header.Parents {
-			parentHeader, err := d.getBlockHeader(ctx, parentHash)
-			if err != nil {
-				continue
-			}
-			if bestScore == nil || parentHeader.CumulativeScore.Cmp(bestScore) > 0 {
-				bestScore = parentHeader.CumulativeScore
-				bestParent = parentHash
-			}
-		}
-
-		current = bestParent
+		current = data.SelectedParent
 	}
 
 	return path
@@ -332,6 +421,31 @@ func (d *DAG) GetBlock(ctx context.Context, hash types.Hash) (*types.Block, erro
 	return d.store.GetBlock(ctx, hash)
 }
 
+// HasBlock reports whether a block is already in the DAG
+func (d *DAG) HasBlock(ctx context.Context, hash types.Hash) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	_, err := d.getBlockHeader(ctx, hash)
+	return err == nil
+}
+
+// GetHeadersAtHeight returns the headers of all blocks at a height,
+// including blocks off the main chain
+func (d *DAG) GetHeadersAtHeight(ctx context.Context, height uint64) ([]*types.BlockHeader, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.store.GetBlocksByHeight(ctx, height)
+}
+
+// GetMainChain returns the main chain headers from fromHeight to
+// toHeight inclusive, oldest first
+func (d *DAG) GetMainChain(ctx context.Context, fromHeight, toHeight uint64) ([]*types.BlockHeader, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.store.GetMainChain(ctx, fromHeight, toHeight)
+}
+
 // GetTips returns the current DAG tips
 func (d *DAG) GetTips() []types.Hash {
 	d.mu.RLock()
@@ -344,6 +458,12 @@ func (d *DAG) GetTips() []types.Hash {
 	return tips
 }
 
+// GetChildren returns the blocks that name hash as a parent, from the
+// store's edge index
+func (d *DAG) GetChildren(ctx context.Context, hash types.Hash) ([]types.Hash, error) {
+	return d.store.GetChildren(ctx, hash)
+}
+
 // GetMainChainTip returns the current main chain tip
 func (d *DAG) GetMainChainTip() types.Hash {
 	d.mu.RLock()
@@ -447,6 +567,23 @@ func (c *BlockCache) Add(block *types.Block) {
 	c.order = append(c.order, hash)
 }
 
+// Remove drops a block from the cache
+func (c *BlockCache) Remove(hash types.Hash) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.blocks[hash]; !exists {
+		return
+	}
+	delete(c.blocks, hash)
+	for i, h := range c.order {
+		if h == hash {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+}
+
 // Get retrieves a block from the cache
 func (c *BlockCache) Get(hash types.Hash) *types.Block {
 	c.mu.RLock()
@@ -0,0 +1,423 @@
+package dag
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"sort"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// DefaultGhostdagK is the maximum anticone size of a blue block. It bounds
+// how many blocks may be created in parallel while all still count toward
+// the blue set.
+const DefaultGhostdagK = 18
+
+// GhostdagData is the GHOSTDAG coloring of a block's past. Blocks that
+// were well connected when they were mined are blue and add their work to
+// the block's score; the rest are red but still ordered.
+type GhostdagData struct {
+	// SelectedParent is the parent with the highest blue work
+	SelectedParent types.Hash
+
+	// BlueScore is the number of blue blocks in the block's past
+	BlueScore uint64
+
+	// BlueWork is the reputation-weighted work of the blue blocks in the
+	// block's past plus the block itself; it is the cumulative score
+	BlueWork *big.Float
+
+	// MergeSet is the block's past outside its selected parent's past,
+	// excluding the selected parent, in topological order
+	MergeSet []types.Hash
+
+	// MergeSetBlues are the blue blocks of the merge set, selected
+	// parent first
+	MergeSetBlues []types.Hash
+
+	// MergeSetReds are the red blocks of the merge set
+	MergeSetReds []types.Hash
+
+	// BluesAnticoneSizes is the number of blues in the anticone of each
+	// blue added by this block, as seen from this block
+	BluesAnticoneSizes map[types.Hash]int
+
+	// OrderIndex is the block's position in the total ordering
+	OrderIndex uint64
+}
+
+// GetGhostdagData returns the GHOSTDAG coloring of a block
+func (d *DAG) GetGhostdagData(ctx context.Context, hash types.Hash) (*GhostdagData, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	d.gdMu.Lock()
+	defer d.gdMu.Unlock()
+	return d.ghostdagLocked(ctx, hash)
+}
+
+// GetOrderedBlocks returns the blocks at positions [from, to] of the
+// total ordering induced by the main chain tip. The ordering walks the
+// selected-parent chain from genesis; each chain block is preceded by its
+// merge set in topological order, so every block comes after its parents
+// and all nodes with the same tip agree on the order.
+func (d *DAG) GetOrderedBlocks(ctx context.Context, from, to uint64) ([]types.Hash, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.mainChainTip.IsEmpty() || to < from {
+		return nil, nil
+	}
+
+	d.gdMu.Lock()
+	defer d.gdMu.Unlock()
+
+	// Chain blocks whose merge set reaches position from, tip first
+	var chain []types.Hash
+	for hash := d.mainChainTip; !hash.IsEmpty(); {
+		data, err := d.ghostdagLocked(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		if data.OrderIndex < from {
+			break
+		}
+		chain = append(chain, hash)
+		hash = data.SelectedParent
+	}
+
+	var ordered []types.Hash
+	for i := len(chain) - 1; i >= 0; i-- {
+		data := d.ghostdag[chain[i]]
+		pos := data.OrderIndex - uint64(len(data.MergeSet))
+		for _, hash := range append(append([]types.Hash{}, data.MergeSet...), chain[i]) {
+			if pos > to {
+				return ordered, nil
+			}
+			if pos >= from {
+				ordered = append(ordered, hash)
+			}
+			pos++
+		}
+	}
+
+	return ordered, nil
+}
+
+// SelectedParent returns the parent a block with the given parents would
+// select: the one with the most blue work, ties broken by hash
+func (d *DAG) SelectedParent(ctx context.Context, parents []types.Hash) (types.Hash, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	d.gdMu.Lock()
+	defer d.gdMu.Unlock()
+
+	var selected types.Hash
+	var selectedData *GhostdagData
+	for _, parent := range parents {
+		data, err := d.ghostdagLocked(ctx, parent)
+		if err != nil {
+			return types.Hash{}, err
+		}
+		if selectedData == nil || heavier(data.BlueWork, parent, selectedData.BlueWork, selected) {
+			selected, selectedData = parent, data
+		}
+	}
+	return selected, nil
+}
+
+// ghostdagLocked returns a block's GHOSTDAG data, computing it and any
+// missing ancestors' data in height order. Data is not persisted, so after
+// a restart it is rebuilt from stored headers on first use.
+func (d *DAG) ghostdagLocked(ctx context.Context, hash types.Hash) (*GhostdagData, error) {
+	if data, exists := d.ghostdag[hash]; exists {
+		return data, nil
+	}
+
+	var missing []*types.BlockHeader
+	seen := map[types.Hash]bool{hash: true}
+	stack := []types.Hash{hash}
+	for len(stack) > 0 {
+		h := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		header, err := d.getBlockHeader(ctx, h)
+		if err != nil {
+			return nil, err
+		}
+		missing = append(missing, header)
+
+		for _, parent := range header.Parents {
+			if _, exists := d.ghostdag[parent]; !exists && !seen[parent] {
+				seen[parent] = true
+				stack = append(stack, parent)
+			}
+		}
+	}
+
+	sort.Slice(missing, func(i, j int) bool { return missing[i].Height < missing[j].Height })
+	for _, header := range missing {
+		data, err := d.computeGhostdagLocked(ctx, header)
+		if err != nil {
+			return nil, err
+		}
+		d.ghostdag[header.Hash] = data
+	}
+
+	return d.ghostdag[hash], nil
+}
+
+// computeGhostdagLocked colors the merge set of a block whose parents'
+// data is already known
+func (d *DAG) computeGhostdagLocked(ctx context.Context, header *types.BlockHeader) (*GhostdagData, error) {
+	if header.IsGenesis() {
+		return &GhostdagData{
+			BlueWork:           blockWork(header),
+			BluesAnticoneSizes: make(map[types.Hash]int),
+		}, nil
+	}
+
+	var selected types.Hash
+	var selectedData *GhostdagData
+	for _, parent := range header.Parents {
+		data, err := d.ghostdagLocked(ctx, parent)
+		if err != nil {
+			return nil, err
+		}
+		if selectedData == nil || heavier(data.BlueWork, parent, selectedData.BlueWork, selected) {
+			selected, selectedData = parent, data
+		}
+	}
+
+	mergeSet, err := d.mergeSetLocked(ctx, selected, header.Parents)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &GhostdagData{
+		SelectedParent:     selected,
+		MergeSet:           mergeSet,
+		MergeSetBlues:      []types.Hash{selected},
+		BluesAnticoneSizes: map[types.Hash]int{selected: 0},
+	}
+
+	blueWork := new(big.Float).Add(selectedData.BlueWork, blockWork(header))
+	for _, candidate := range mergeSet {
+		blue, anticoneSize, sizes, err := d.checkBlueCandidateLocked(ctx, data, candidate)
+		if err != nil {
+			return nil, err
+		}
+		if !blue {
+			data.MergeSetReds = append(data.MergeSetReds, candidate)
+			continue
+		}
+
+		data.MergeSetBlues = append(data.MergeSetBlues, candidate)
+		data.BluesAnticoneSizes[candidate] = anticoneSize
+		for b, size := range sizes {
+			data.BluesAnticoneSizes[b] = size + 1
+		}
+
+		candidateHeader, err := d.getBlockHeader(ctx, candidate)
+		if err != nil {
+			return nil, err
+		}
+		blueWork.Add(blueWork, blockWork(candidateHeader))
+	}
+
+	data.BlueWork = blueWork
+	data.BlueScore = selectedData.BlueScore + uint64(len(data.MergeSetBlues))
+	data.OrderIndex = selectedData.OrderIndex + uint64(len(mergeSet)) + 1
+	return data, nil
+}
+
+// mergeSetLocked returns the past of parents that is not in the past of
+// the selected parent, ordered by blue work, height and hash. Parents
+// always sort before their children.
+func (d *DAG) mergeSetLocked(ctx context.Context, selected types.Hash, parents []types.Hash) ([]types.Hash, error) {
+	seen := map[types.Hash]bool{selected: true}
+	var mergeSet []types.Hash
+
+	queue := append([]types.Hash{}, parents...)
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+
+		inPast, err := d.isAncestor(ctx, hash, selected)
+		if err != nil {
+			return nil, err
+		}
+		if inPast {
+			continue
+		}
+		mergeSet = append(mergeSet, hash)
+
+		header, err := d.getBlockHeader(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, header.Parents...)
+	}
+
+	type entry struct {
+		hash   types.Hash
+		work   *big.Float
+		height uint64
+	}
+	entries := make([]entry, len(mergeSet))
+	for i, hash := range mergeSet {
+		data, err := d.ghostdagLocked(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		header, err := d.getBlockHeader(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = entry{hash: hash, work: data.BlueWork, height: header.Height}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if c := entries[i].work.Cmp(entries[j].work); c != 0 {
+			return c < 0
+		}
+		if entries[i].height != entries[j].height {
+			return entries[i].height < entries[j].height
+		}
+		return bytes.Compare(entries[i].hash[:], entries[j].hash[:]) < 0
+	})
+
+	for i, e := range entries {
+		mergeSet[i] = e.hash
+	}
+	return mergeSet, nil
+}
+
+// checkBlueCandidateLocked decides whether candidate can join the blue set
+// of the block described by data: it may have at most K blues in its
+// anticone, and no blue may end up with more than K. It walks down the
+// selected chain until it reaches a chain block in the candidate's past.
+func (d *DAG) checkBlueCandidateLocked(ctx context.Context, data *GhostdagData, candidate types.Hash) (bool, int, map[types.Hash]int, error) {
+	if len(data.MergeSetBlues) >= d.k+1 {
+		return false, 0, nil, nil
+	}
+
+	sizes := make(map[types.Hash]int)
+	anticoneSize := 0
+
+	chain := data
+	var chainHash types.Hash
+	for {
+		if !chainHash.IsEmpty() {
+			inPast, err := d.isAncestor(ctx, chainHash, candidate)
+			if err != nil {
+				return false, 0, nil, err
+			}
+			if inPast {
+				break
+			}
+		}
+
+		for _, blue := range chain.MergeSetBlues {
+			inPast, err := d.isAncestor(ctx, blue, candidate)
+			if err != nil {
+				return false, 0, nil, err
+			}
+			if inPast {
+				continue
+			}
+
+			size := d.blueAnticoneSizeLocked(data, blue)
+			sizes[blue] = size
+			anticoneSize++
+			if anticoneSize > d.k || size >= d.k {
+				return false, 0, nil, nil
+			}
+		}
+
+		if chain.SelectedParent.IsEmpty() {
+			break
+		}
+		chainHash = chain.SelectedParent
+		chain = d.ghostdag[chainHash]
+	}
+
+	return true, anticoneSize, sizes, nil
+}
+
+// blueAnticoneSizeLocked returns a blue's anticone size as last recorded
+// on the selected chain starting at data
+func (d *DAG) blueAnticoneSizeLocked(data *GhostdagData, blue types.Hash) int {
+	for data != nil {
+		if size, exists := data.BluesAnticoneSizes[blue]; exists {
+			return size
+		}
+		if data.SelectedParent.IsEmpty() {
+			break
+		}
+		data = d.ghostdag[data.SelectedParent]
+	}
+	return 0
+}
+
+// isAncestor reports whether a is in the past of b. Heights strictly
+// increase from parent to child, so the search stops below a's height.
+func (d *DAG) isAncestor(ctx context.Context, a, b types.Hash) (bool, error) {
+	if a == b {
+		return false, nil
+	}
+	aHeader, err := d.getBlockHeader(ctx, a)
+	if err != nil {
+		return false, err
+	}
+
+	visited := make(map[types.Hash]bool)
+	stack := []types.Hash{b}
+	for len(stack) > 0 {
+		h := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		header, err := d.getBlockHeader(ctx, h)
+		if err != nil {
+			return false, err
+		}
+		for _, parent := range header.Parents {
+			if parent == a {
+				return true, nil
+			}
+			if visited[parent] {
+				continue
+			}
+			visited[parent] = true
+
+			parentHeader, err := d.getBlockHeader(ctx, parent)
+			if err != nil {
+				return false, err
+			}
+			if parentHeader.Height > aHeader.Height {
+				stack = append(stack, parent)
+			}
+		}
+	}
+	return false, nil
+}
+
+// blockWork returns a block's reputation-weighted work, Work(B) × Rep(m)
+func blockWork(header *types.BlockHeader) *big.Float {
+	work := new(big.Float).SetInt(header.Work())
+	return work.Mul(work, big.NewFloat(header.ReputationScore))
+}
+
+// heavier reports whether block a outweighs block b: higher blue work
+// wins, and ties go to the larger hash so every node picks the same block
+func heavier(aWork *big.Float, a types.Hash, bWork *big.Float, b types.Hash) bool {
+	if c := aWork.Cmp(bWork); c != 0 {
+		return c > 0
+	}
+	return bytes.Compare(a[:], b[:]) > 0
+}
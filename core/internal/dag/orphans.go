@@ -0,0 +1,284 @@
+package dag
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// ParentRequester asks peers for blocks the orphan pool is waiting on
+type ParentRequester func(ctx context.Context, hashes []types.Hash)
+
+// OrphanConfig holds orphan pool configuration
+type OrphanConfig struct {
+	// MaxOrphans bounds the number of buffered orphans; the oldest is
+	// evicted to make room
+	MaxOrphans int
+
+	// Expiry is how long an orphan waits for its parents
+	Expiry time.Duration
+}
+
+// DefaultOrphanConfig returns default orphan pool configuration
+func DefaultOrphanConfig() *OrphanConfig {
+	return &OrphanConfig{
+		MaxOrphans: 1000,
+		Expiry:     10 * time.Minute,
+	}
+}
+
+// orphan is a buffered block and when it arrived
+type orphan struct {
+	block    *types.Block
+	received time.Time
+}
+
+// OrphanManager adds blocks to the DAG, holding back blocks whose parents
+// are missing until the parents arrive. Orphans are indexed by the parent
+// they wait on so each new block only re-examines its own children.
+type OrphanManager struct {
+	mu sync.Mutex
+
+	dag       *DAG
+	validator *BlockValidator
+	config    *OrphanConfig
+	requester ParentRequester
+
+	orphans map[types.Hash]*orphan
+
+	// Missing parent -> orphans waiting on it
+	waiting map[types.Hash][]types.Hash
+}
+
+// NewOrphanManager creates an orphan manager. Blocks are validated with
+// validator before they are added; a nil validator skips validation.
+func NewOrphanManager(d *DAG, validator *BlockValidator, cfg *OrphanConfig) *OrphanManager {
+	if cfg == nil {
+		cfg = DefaultOrphanConfig()
+	}
+
+	return &OrphanManager{
+		dag:       d,
+		validator: validator,
+		config:    cfg,
+		orphans:   make(map[types.Hash]*orphan),
+		waiting:   make(map[types.Hash][]types.Hash),
+	}
+}
+
+// SetParentRequester sets the callback used to fetch missing parents
+func (m *OrphanManager) SetParentRequester(fn ParentRequester) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requester = fn
+}
+
+// ProcessBlock validates and adds a block. If its parents are missing the
+// block is buffered, its parents are requested and ErrOrphanBlock is
+// returned. On success it returns the block followed by every buffered
+// descendant that could now be connected, in the order they were added.
+func (m *OrphanManager) ProcessBlock(ctx context.Context, block *types.Block) ([]*types.Block, error) {
+	if m.dag.HasBlock(ctx, block.Header.Hash) {
+		return nil, ErrDuplicateBlock
+	}
+
+	if err := m.connect(ctx, block); err != nil {
+		if errors.Is(err, ErrOrphanBlock) {
+			m.add(ctx, block)
+		}
+		return nil, err
+	}
+
+	return append([]*types.Block{block}, m.resolve(ctx, block.Header.Hash)...), nil
+}
+
+// connect validates a block and adds it to the DAG
+func (m *OrphanManager) connect(ctx context.Context, block *types.Block) error {
+	if m.validator != nil {
+		if err := m.validator.ValidateBlock(ctx, block); err != nil {
+			return err
+		}
+	}
+	return m.dag.AddBlock(ctx, block)
+}
+
+// add buffers an orphan and requests the parents no one has sent yet
+func (m *OrphanManager) add(ctx context.Context, block *types.Block) {
+	hash := block.Header.Hash
+
+	var missing []types.Hash
+	for _, parent := range block.Header.Parents {
+		if !m.dag.HasBlock(ctx, parent) {
+			missing = append(missing, parent)
+		}
+	}
+
+	m.mu.Lock()
+	if _, exists := m.orphans[hash]; exists {
+		m.mu.Unlock()
+		return
+	}
+	if len(m.orphans) >= m.config.MaxOrphans {
+		m.evictOldestLocked()
+	}
+
+	m.orphans[hash] = &orphan{block: block, received: time.Now()}
+
+	var request []types.Hash
+	for _, parent := range missing {
+		m.waiting[parent] = append(m.waiting[parent], hash)
+		if _, buffered := m.orphans[parent]; !buffered {
+			request = append(request, parent)
+		}
+	}
+	requester := m.requester
+	m.mu.Unlock()
+
+	if requester != nil && len(request) > 0 {
+		requester(ctx, request)
+	}
+}
+
+// resolve connects orphans that were waiting on parent, and then those
+// waiting on the blocks it connected
+func (m *OrphanManager) resolve(ctx context.Context, parent types.Hash) []*types.Block {
+	var added []*types.Block
+
+	queue := []types.Hash{parent}
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+
+		m.mu.Lock()
+		children := m.waiting[hash]
+		delete(m.waiting, hash)
+		m.mu.Unlock()
+
+		for _, child := range children {
+			m.mu.Lock()
+			o, exists := m.orphans[child]
+			m.mu.Unlock()
+			if !exists || !m.parentsKnown(ctx, o.block) {
+				continue // Already handled, or still waiting on another parent
+			}
+
+			m.mu.Lock()
+			m.removeLocked(child)
+			m.mu.Unlock()
+
+			// Orphans were buffered before full validation; one that fails
+			// now is dropped and its own orphans expire
+			if err := m.connect(ctx, o.block); err != nil {
+				continue
+			}
+			added = append(added, o.block)
+			queue = append(queue, child)
+		}
+	}
+
+	return added
+}
+
+// parentsKnown reports whether every parent of block is in the DAG
+func (m *OrphanManager) parentsKnown(ctx context.Context, block *types.Block) bool {
+	for _, parent := range block.Header.Parents {
+		if !m.dag.HasBlock(ctx, parent) {
+			return false
+		}
+	}
+	return true
+}
+
+// Expire drops orphans that have waited longer than Expiry and returns
+// how many were dropped
+func (m *OrphanManager) Expire(now time.Time) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cutoff := now.Add(-m.config.Expiry)
+	expired := 0
+	for hash, o := range m.orphans {
+		if o.received.Before(cutoff) {
+			m.removeLocked(hash)
+			expired++
+		}
+	}
+	return expired
+}
+
+// Missing returns the parents orphans are waiting on that are neither in
+// the DAG nor buffered themselves
+func (m *OrphanManager) Missing(ctx context.Context) []types.Hash {
+	m.mu.Lock()
+	parents := make([]types.Hash, 0, len(m.waiting))
+	for parent := range m.waiting {
+		if _, buffered := m.orphans[parent]; !buffered {
+			parents = append(parents, parent)
+		}
+	}
+	m.mu.Unlock()
+
+	missing := parents[:0]
+	for _, parent := range parents {
+		if !m.dag.HasBlock(ctx, parent) {
+			missing = append(missing, parent)
+		}
+	}
+	return missing
+}
+
+// Has reports whether a block is buffered as an orphan
+func (m *OrphanManager) Has(hash types.Hash) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, exists := m.orphans[hash]
+	return exists
+}
+
+// Len returns the number of buffered orphans
+func (m *OrphanManager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.orphans)
+}
+
+// evictOldestLocked drops the orphan that has waited longest
+func (m *OrphanManager) evictOldestLocked() {
+	var oldest types.Hash
+	var oldestTime time.Time
+	for hash, o := range m.orphans {
+		if oldestTime.IsZero() || o.received.Before(oldestTime) {
+			oldest, oldestTime = hash, o.received
+		}
+	}
+	if !oldestTime.IsZero() {
+		m.removeLocked(oldest)
+	}
+}
+
+// removeLocked drops an orphan and its entries in the waiting index
+func (m *OrphanManager) removeLocked(hash types.Hash) {
+	o, exists := m.orphans[hash]
+	if !exists {
+		return
+	}
+	delete(m.orphans, hash)
+
+	for _, parent := range o.block.Header.Parents {
+		list := m.waiting[parent]
+		for i, h := range list {
+			if h == hash {
+				list = append(list[:i], list[i+1:]...)
+				break
+			}
+		}
+		if len(list) == 0 {
+			delete(m.waiting, parent)
+		} else {
+			m.waiting[parent] = list
+		}
+	}
+}
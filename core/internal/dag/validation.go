@@ -3,9 +3,13 @@ package dag
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
+	"fmt"
+	"math/big"
 	"time"
 
+	"github.com/ccoin/core/internal/vrf"
 	"github.com/ccoin/core/pkg/types"
 )
 
@@ -17,18 +21,121 @@ var (
 	ErrFutureTimestamp      = errors.New("block timestamp is in the future")
 	ErrInvalidHeight        = errors.New("invalid block height")
 	ErrInvalidDifficulty    = errors.New("block does not meet difficulty target")
+	ErrWrongDifficulty      = errors.New("block difficulty is not the retargeted target")
+	ErrMissingHeader        = errors.New("block has no header")
+	ErrBlockHashMismatch    = errors.New("block hash mismatch")
+	ErrTooManyTransactions  = errors.New("too many transactions in block")
+	ErrTxHashMismatch       = errors.New("transaction hash mismatch")
 	ErrInvalidTxRoot        = errors.New("invalid transaction root")
 	ErrInvalidPoUW          = errors.New("invalid proof of useful work")
 	ErrInvalidReputation    = errors.New("invalid reputation score")
 	ErrInvalidQualityScore  = errors.New("invalid quality score")
 	ErrMinerBanned          = errors.New("miner is banned")
 	ErrParentTimestamp      = errors.New("block timestamp before parent")
+	ErrInvalidCommitteeRoot = errors.New("invalid committee root")
+	ErrInvalidVRF           = errors.New("invalid VRF proof")
+	ErrInvalidSignature     = errors.New("invalid miner signature")
+	ErrInvalidNullifierRoot = errors.New("invalid nullifier root")
+	ErrInvalidStateRoot     = errors.New("invalid state root")
+	ErrInvalidRegistryRoot  = errors.New("invalid model registry root")
+	ErrInvalidReceiptsRoot  = errors.New("invalid receipts root")
+	ErrDuplicateNullifier   = errors.New("nullifier listed twice in transaction")
+	ErrNullifierSpent       = errors.New("nullifier already spent")
+	ErrInvalidAnchor        = errors.New("transaction anchor is not a recent commitment tree root")
+	ErrInvalidTxProof       = errors.New("invalid transaction proof")
+
+	// ErrNoRegistryCheckpoint is returned by RegistryRoots that hold no
+	// checkpoint for an epoch; the registry root is then not checked
+	ErrNoRegistryCheckpoint = errors.New("no model registry checkpoint for epoch")
+
+	// ErrValidationUnavailable wraps failures to read the chain state a
+	// check needs, such as store and context errors
+	ErrValidationUnavailable = errors.New("validation state unavailable")
 )
 
+// attributableErrors are the failures a signed block's own content
+// causes. Others depend on this node's view: missing parents, clock skew,
+// unreadable state, or spends and anchors it saw in another order.
+var attributableErrors = []error{
+	ErrInvalidBlockVersion,
+	ErrTooManyParents,
+	ErrNoParents,
+	ErrInvalidHeight,
+	ErrInvalidDifficulty,
+	ErrTooManyTransactions,
+	ErrTxHashMismatch,
+	ErrInvalidTxRoot,
+	ErrInvalidPoUW,
+	ErrInvalidReputation,
+	ErrInvalidQualityScore,
+	ErrParentTimestamp,
+	ErrInvalidCommitteeRoot,
+	ErrInvalidVRF,
+	ErrInvalidSignature,
+	ErrInvalidNullifierRoot,
+	ErrInvalidStateRoot,
+	ErrInvalidRegistryRoot,
+	ErrInvalidReceiptsRoot,
+	ErrDuplicateNullifier,
+	ErrInvalidTxProof,
+}
+
+// unavailable marks err as a failure to read validation state
+func unavailable(err error) error {
+	return fmt.Errorf("%w: %w", ErrValidationUnavailable, err)
+}
+
 // BlockValidator validates blocks before adding to the DAG
 type BlockValidator struct {
 	dag          *DAG
 	maxFutureSec uint64 // Maximum seconds a timestamp can be in the future
+
+	// Disclosure policy; nil skips disclosure checks
+	disclosures DisclosurePolicy
+
+	// Committee selections; nil skips committee root checks
+	committees CommitteeRoots
+
+	// Model registry checkpoints; nil skips registry root checks
+	registry RegistryRoots
+
+	// Gradient proof verifier; nil skips PoUW proof checks
+	pouw PoUWVerifier
+
+	// Nullifier accumulator; nil skips nullifier root checks
+	nullifiers NullifierRoots
+
+	// State commitment; nil skips state root checks
+	states StateRoots
+
+	// Transaction receipts; nil skips receipts root checks
+	receipts ReceiptsRoots
+
+	// Difficulty retargeting; nil skips difficulty target checks
+	difficulty DifficultyTargets
+
+	// Shielded pool; nil skips nullifier, anchor and proof checks
+	shielded ShieldedState
+
+	// Reject non-genesis blocks without a VRF proof
+	requireVRF bool
+
+	// Reject non-genesis blocks without a miner signature
+	requireSignature bool
+
+	// Receives signed blocks that fail validation; nil drops them
+	onInvalid InvalidBlockHandler
+}
+
+// InvalidBlockHandler receives a block that carries a valid signature but
+// fails validation, and why. The signature attributes the block to its
+// signer, so it can serve as slashing evidence.
+type InvalidBlockHandler func(ctx context.Context, header *types.BlockHeader, reason error)
+
+// DisclosurePolicy checks that a transaction carries the disclosures the
+// network's policy requires of it
+type DisclosurePolicy interface {
+	CheckDisclosures(ctx context.Context, tx *types.Transaction) error
 }
 
 // NewBlockValidator creates a new block validator
@@ -39,8 +146,163 @@ func NewBlockValidator(dag *DAG) *BlockValidator {
 	}
 }
 
+// CommitteeRoots provides the committee root each epoch's first blocks
+// must carry
+type CommitteeRoots interface {
+	Root(ctx context.Context, epoch uint64) (types.Hash, error)
+}
+
+// SetCommitteeRoots makes blocks opening an epoch commit to the
+// committees selected for it
+func (v *BlockValidator) SetCommitteeRoots(committees CommitteeRoots) {
+	v.committees = committees
+}
+
+// RegistryRoots provides the model registry root each epoch's first
+// blocks must carry
+type RegistryRoots interface {
+	RegistryRoot(ctx context.Context, epoch uint64) (types.Hash, error)
+}
+
+// SetRegistryRoots makes blocks opening an epoch commit to the model
+// registry checkpointed for it
+func (v *BlockValidator) SetRegistryRoots(registry RegistryRoots) {
+	v.registry = registry
+}
+
+// NullifierRoots computes the nullifier accumulator root a block commits
+// to: its selected parent's accumulator extended with the nullifiers the
+// block's transactions spend, in order
+type NullifierRoots interface {
+	NextRoot(ctx context.Context, parent types.Hash, nullifiers []types.Hash) (types.Hash, error)
+}
+
+// SetNullifierRoots makes blocks commit to the nullifier accumulator
+func (v *BlockValidator) SetNullifierRoots(nullifiers NullifierRoots) {
+	v.nullifiers = nullifiers
+}
+
+// StateRoots computes the state root a block commits to: the state its
+// selected parent left, with the block applied
+type StateRoots interface {
+	NextStateRoot(ctx context.Context, parent types.Hash, block *types.Block) (types.Hash, error)
+}
+
+// SetStateRoots makes blocks commit to the chain state
+func (v *BlockValidator) SetStateRoots(states StateRoots) {
+	v.states = states
+}
+
+// ReceiptsRoots computes the receipts root a block commits to: the root
+// of its transactions' receipts when applied to the state its selected
+// parent left
+type ReceiptsRoots interface {
+	NextReceiptsRoot(ctx context.Context, parent types.Hash, block *types.Block) (types.Hash, error)
+}
+
+// SetReceiptsRoots makes blocks commit to their transaction receipts
+func (v *BlockValidator) SetReceiptsRoots(receipts ReceiptsRoots) {
+	v.receipts = receipts
+}
+
+// ShieldedState checks transactions against the shielded pool: which
+// nullifiers are spent, which commitment tree roots are recent enough to
+// anchor to, and the transaction proofs
+type ShieldedState interface {
+	HasNullifiers(ctx context.Context, nullifiers []types.Hash) ([]bool, error)
+	AnchorAge(root types.Hash) (int, bool)
+	VerifyTransaction(ctx context.Context, tx *types.Transaction) error
+}
+
+// SetShieldedState makes blocks with transactions that spend spent
+// nullifiers, use a stale anchor or carry an invalid proof invalid
+func (v *BlockValidator) SetShieldedState(shielded ShieldedState) {
+	v.shielded = shielded
+}
+
+// DifficultyTargets computes the difficulty target a block on parents
+// must carry. Retargets follow this node's main chain, so a block with
+// another target is rejected but not attributed to its signer.
+type DifficultyTargets interface {
+	CalculateDifficulty(ctx context.Context, parentHeaders []*types.BlockHeader) *big.Int
+}
+
+// SetDifficultyTargets makes blocks whose difficulty target differs from
+// the one computed from their parents invalid
+func (v *BlockValidator) SetDifficultyTargets(difficulty DifficultyTargets) {
+	v.difficulty = difficulty
+}
+
+// PoUWVerifier verifies the gradient proof a block header carries
+type PoUWVerifier interface {
+	VerifyPoUW(ctx context.Context, header *types.BlockHeader) error
+}
+
+// SetPoUWVerifier makes blocks whose gradient proof does not establish
+// their PoUW result and quality score invalid
+func (v *BlockValidator) SetPoUWVerifier(verifier PoUWVerifier) {
+	v.pouw = verifier
+}
+
+// SetVRFRequired makes non-genesis blocks without a VRF proof invalid.
+// Proofs that are present are always checked.
+func (v *BlockValidator) SetVRFRequired(required bool) {
+	v.requireVRF = required
+}
+
+// SetSignatureRequired makes non-genesis blocks without a miner signature
+// invalid. Signatures that are present are always checked.
+func (v *BlockValidator) SetSignatureRequired(required bool) {
+	v.requireSignature = required
+}
+
+// SetInvalidBlockHandler sets where signed blocks that fail validation are
+// reported
+func (v *BlockValidator) SetInvalidBlockHandler(fn InvalidBlockHandler) {
+	v.onInvalid = fn
+}
+
+// SetDisclosurePolicy makes blocks with transactions that do not meet
+// the disclosure policy invalid
+func (v *BlockValidator) SetDisclosurePolicy(policy DisclosurePolicy) {
+	v.disclosures = policy
+}
+
 // ValidateBlock performs full block validation
 func (v *BlockValidator) ValidateBlock(ctx context.Context, block *types.Block) error {
+	err := v.validateBlock(ctx, block)
+	if err != nil && v.onInvalid != nil && attributable(block.Header, err) {
+		v.onInvalid(ctx, block.Header, err)
+	}
+	return err
+}
+
+// attributable reports whether a validation failure is the signer's
+// fault: the header must hash to what was signed, and the failure must be
+// one of attributableErrors
+func attributable(header *types.BlockHeader, err error) bool {
+	if errors.Is(err, ErrValidationUnavailable) {
+		return false
+	}
+	fault := false
+	for _, target := range attributableErrors {
+		if errors.Is(err, target) {
+			fault = true
+			break
+		}
+	}
+	if !fault {
+		return false
+	}
+	if header.ComputeHash() != header.Hash {
+		return false
+	}
+	_, signed := header.Signer()
+	return signed
+}
+
+// validateBlock runs every check on a block
+func (v *BlockValidator) validateBlock(ctx context.Context, block *types.Block) error {
 	header := block.Header
 
 	// Validate header
@@ -48,11 +310,46 @@ func (v *BlockValidator) ValidateBlock(ctx context.Context, block *types.Block)
 		return err
 	}
 
+	// Validate the miner's signature
+	if err := v.validateSignature(header); err != nil {
+		return err
+	}
+
+	// Validate committee root
+	if err := v.validateCommitteeRoot(ctx, header); err != nil {
+		return err
+	}
+
+	// Validate model registry root
+	if err := v.validateRegistryRoot(ctx, header); err != nil {
+		return err
+	}
+
+	// Validate the miner's VRF proof
+	if err := v.validateVRF(ctx, header); err != nil {
+		return err
+	}
+
 	// Validate transactions
 	if err := v.validateTransactions(ctx, block); err != nil {
 		return err
 	}
 
+	// Validate the committed nullifier accumulator
+	if err := v.validateNullifierRoot(ctx, block); err != nil {
+		return err
+	}
+
+	// Validate the committed receipts
+	if err := v.validateReceiptsRoot(ctx, block); err != nil {
+		return err
+	}
+
+	// Validate the committed state
+	if err := v.validateStateRoot(ctx, block); err != nil {
+		return err
+	}
+
 	// Validate PoUW
 	if err := v.validatePoUW(ctx, block); err != nil {
 		return err
@@ -61,8 +358,194 @@ func (v *BlockValidator) ValidateBlock(ctx context.Context, block *types.Block)
 	return nil
 }
 
-// validateHeader validates the block header
-func (v *BlockValidator) validateHeader(ctx context.Context, header *types.BlockHeader) error {
+// validateCommitteeRoot checks that blocks opening an epoch commit to
+// its committees and other blocks leave the root empty
+func (v *BlockValidator) validateCommitteeRoot(ctx context.Context, header *types.BlockHeader) error {
+	if header.Height == 0 || header.Height%types.EpochLength != 0 {
+		if !header.CommitteeRoot.IsEmpty() {
+			return ErrInvalidCommitteeRoot
+		}
+		return nil
+	}
+	if v.committees == nil {
+		return nil
+	}
+
+	root, err := v.committees.Root(ctx, header.Height/types.EpochLength)
+	if err != nil {
+		return unavailable(err)
+	}
+	if header.CommitteeRoot != root {
+		return ErrInvalidCommitteeRoot
+	}
+	return nil
+}
+
+// validateRegistryRoot checks that blocks opening an epoch commit to the
+// registry checkpointed for it and other blocks leave the root empty
+func (v *BlockValidator) validateRegistryRoot(ctx context.Context, header *types.BlockHeader) error {
+	if header.Height == 0 || header.Height%types.EpochLength != 0 {
+		if !header.RegistryRoot.IsEmpty() {
+			return ErrInvalidRegistryRoot
+		}
+		return nil
+	}
+	if v.registry == nil {
+		return nil
+	}
+
+	root, err := v.registry.RegistryRoot(ctx, header.Height/types.EpochLength)
+	if errors.Is(err, ErrNoRegistryCheckpoint) {
+		return nil
+	}
+	if err != nil {
+		return unavailable(err)
+	}
+	if header.RegistryRoot != root {
+		return ErrInvalidRegistryRoot
+	}
+	return nil
+}
+
+// validateNullifierRoot checks that a block commits to the nullifier
+// accumulator reached after its spends
+func (v *BlockValidator) validateNullifierRoot(ctx context.Context, block *types.Block) error {
+	header := block.Header
+	if v.nullifiers == nil || header.IsGenesis() {
+		return nil
+	}
+
+	parent, err := v.dag.SelectedParent(ctx, header.Parents)
+	if err != nil {
+		return unavailable(err)
+	}
+	root, err := v.nullifiers.NextRoot(ctx, parent, BlockNullifiers(block.Transactions))
+	if err != nil {
+		return unavailable(err)
+	}
+	if header.NullifierRoot != root {
+		return ErrInvalidNullifierRoot
+	}
+	return nil
+}
+
+// validateStateRoot checks that a block commits to the state reached
+// after applying it
+func (v *BlockValidator) validateStateRoot(ctx context.Context, block *types.Block) error {
+	header := block.Header
+	if v.states == nil || header.IsGenesis() {
+		return nil
+	}
+
+	parent, err := v.dag.SelectedParent(ctx, header.Parents)
+	if err != nil {
+		return unavailable(err)
+	}
+	root, err := v.states.NextStateRoot(ctx, parent, block)
+	if err != nil {
+		return unavailable(err)
+	}
+	if header.StateRoot != root {
+		return ErrInvalidStateRoot
+	}
+	return nil
+}
+
+// validateReceiptsRoot checks that a block commits to the receipts of
+// its transactions
+func (v *BlockValidator) validateReceiptsRoot(ctx context.Context, block *types.Block) error {
+	header := block.Header
+	if v.receipts == nil || header.IsGenesis() {
+		return nil
+	}
+
+	parent, err := v.dag.SelectedParent(ctx, header.Parents)
+	if err != nil {
+		return unavailable(err)
+	}
+	root, err := v.receipts.NextReceiptsRoot(ctx, parent, block)
+	if err != nil {
+		return unavailable(err)
+	}
+	if header.ReceiptsRoot != root {
+		return ErrInvalidReceiptsRoot
+	}
+	return nil
+}
+
+// validateSignature checks that the header is signed by the miner's key
+func (v *BlockValidator) validateSignature(header *types.BlockHeader) error {
+	if len(header.Signature) == 0 {
+		if v.requireSignature && !header.IsGenesis() {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+
+	if !header.VerifySignature() {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// validateVRF checks that the VRF key is the miner's, that the seed is
+// the hash of a block at most types.VRFSeedWindow below the header, and
+// that the proof over it verifies
+func (v *BlockValidator) validateVRF(ctx context.Context, header *types.BlockHeader) error {
+	if len(header.VRFProof) == 0 {
+		if v.requireVRF && !header.IsGenesis() {
+			return ErrInvalidVRF
+		}
+		return nil
+	}
+
+	if types.AddressFromPublicKey(header.MinerPublicKey) != header.MinerAddress {
+		return ErrInvalidVRF
+	}
+	seedHeader, err := v.dag.getBlockHeader(ctx, header.VRFSeed)
+	if err != nil {
+		return ErrInvalidVRF
+	}
+	if seedHeader.Height >= header.Height || header.Height-seedHeader.Height > types.VRFSeedWindow {
+		return ErrInvalidVRF
+	}
+	if _, err := vrf.Verify(header.MinerPublicKey, header.VRFProof, vrf.TaskInput(header.VRFSeed)); err != nil {
+		return ErrInvalidVRF
+	}
+	return nil
+}
+
+// CheckBlock runs the checks on a block that need no chain state: the
+// header's version, hash, parent count, score bounds and work against its
+// own difficulty target, and the transaction count, hashes and root.
+// Gossip runs it before relaying a block, so it must stay cheap.
+func CheckBlock(block *types.Block) error {
+	if block == nil || block.Header == nil {
+		return ErrMissingHeader
+	}
+	if err := checkHeader(block.Header); err != nil {
+		return err
+	}
+	return checkTransactions(block)
+}
+
+// CheckHeader runs CheckBlock's header checks alone, so a bad header can
+// be refused before the block's transactions are decoded
+func CheckHeader(header *types.BlockHeader) error {
+	if header == nil {
+		return ErrMissingHeader
+	}
+	return checkHeader(header)
+}
+
+// CheckBody runs CheckBlock's transaction checks alone, for a block whose
+// header passed CheckHeader
+func CheckBody(block *types.Block) error {
+	return checkTransactions(block)
+}
+
+// checkHeader runs the stateless header checks
+func checkHeader(header *types.BlockHeader) error {
 	// Version check
 	if header.Version != 1 {
 		return ErrInvalidBlockVersion
@@ -71,10 +554,10 @@ func (v *BlockValidator) validateHeader(ctx context.Context, header *types.Block
 	// Check hash is correctly computed
 	computedHash := header.ComputeHash()
 	if computedHash != header.Hash {
-		return errors.New("block hash mismatch")
+		return ErrBlockHashMismatch
 	}
 
-	// Parent validation
+	// Parent count
 	if !header.IsGenesis() {
 		if len(header.Parents) == 0 {
 			return ErrNoParents
@@ -82,16 +565,43 @@ func (v *BlockValidator) validateHeader(ctx context.Context, header *types.Block
 		if len(header.Parents) > types.MaxParents {
 			return ErrTooManyParents
 		}
+	}
 
+	// Reputation bounds
+	if header.ReputationScore < types.MinReputation || header.ReputationScore > types.MaxReputation {
+		return ErrInvalidReputation
+	}
+
+	// Quality score bounds (if present)
+	if header.QualityScore != 0 {
+		if header.QualityScore <= 0 || header.QualityScore > 1 {
+			return ErrInvalidQualityScore
+		}
+	}
+
+	// Difficulty validation
+	return checkDifficulty(header)
+}
+
+// validateHeader validates the block header
+func (v *BlockValidator) validateHeader(ctx context.Context, header *types.BlockHeader) error {
+	if err := checkHeader(header); err != nil {
+		return err
+	}
+
+	// Parent validation
+	if !header.IsGenesis() {
 		// Validate each parent exists
 		var maxParentHeight uint64
 		var maxParentTime uint64
+		parentHeaders := make([]*types.BlockHeader, 0, len(header.Parents))
 
 		for _, parentHash := range header.Parents {
 			parentHeader, err := v.dag.getBlockHeader(ctx, parentHash)
 			if err != nil {
 				return ErrOrphanBlock
 			}
+			parentHeaders = append(parentHeaders, parentHeader)
 
 			if parentHeader.Height > maxParentHeight {
 				maxParentHeight = parentHeader.Height
@@ -110,6 +620,11 @@ func (v *BlockValidator) validateHeader(ctx context.Context, header *types.Block
 		if header.Timestamp < maxParentTime {
 			return ErrParentTimestamp
 		}
+
+		// Difficulty must be the target retargeted from the parents
+		if v.difficulty != nil && header.Difficulty.Cmp(v.difficulty.CalculateDifficulty(ctx, parentHeaders)) != 0 {
+			return ErrWrongDifficulty
+		}
 	}
 
 	// Timestamp not too far in the future
@@ -118,28 +633,11 @@ func (v *BlockValidator) validateHeader(ctx context.Context, header *types.Block
 		return ErrFutureTimestamp
 	}
 
-	// Reputation bounds
-	if header.ReputationScore < types.MinReputation || header.ReputationScore > types.MaxReputation {
-		return ErrInvalidReputation
-	}
-
-	// Quality score bounds (if present)
-	if header.QualityScore != 0 {
-		if header.QualityScore <= 0 || header.QualityScore > 1 {
-			return ErrInvalidQualityScore
-		}
-	}
-
-	// Difficulty validation
-	if err := v.validateDifficulty(header); err != nil {
-		return err
-	}
-
 	return nil
 }
 
-// validateDifficulty checks that the block meets the difficulty target
-func (v *BlockValidator) validateDifficulty(header *types.BlockHeader) error {
+// checkDifficulty checks that the block meets the difficulty target
+func checkDifficulty(header *types.BlockHeader) error {
 	if header.Difficulty == nil || header.Difficulty.Sign() <= 0 {
 		return ErrInvalidDifficulty
 	}
@@ -155,10 +653,10 @@ func (v *BlockValidator) validateDifficulty(header *types.BlockHeader) error {
 	return nil
 }
 
-// validateTransactions validates all transactions in the block
-func (v *BlockValidator) validateTransactions(ctx context.Context, block *types.Block) error {
+// checkTransactions runs the stateless checks on a block's transactions
+func checkTransactions(block *types.Block) error {
 	if len(block.Transactions) > types.MaxTransactionsPerBlock {
-		return errors.New("too many transactions in block")
+		return ErrTooManyTransactions
 	}
 
 	// Compute transaction root
@@ -167,6 +665,39 @@ func (v *BlockValidator) validateTransactions(ctx context.Context, block *types.
 		return ErrInvalidTxRoot
 	}
 
+	// Verify each transaction hash
+	for _, tx := range block.Transactions {
+		if err := CheckTransaction(tx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CheckTransaction runs the checks on a transaction that need no chain
+// state. Transactions in a block may conflict with each other, which
+// their receipts record, but one transaction may not spend a note twice.
+func CheckTransaction(tx *types.Transaction) error {
+	if tx == nil || tx.ComputeHash() != tx.TxHash {
+		return ErrTxHashMismatch
+	}
+	seen := make(map[types.Hash]struct{}, len(tx.Nullifiers))
+	for _, nullifier := range tx.Nullifiers {
+		if _, exists := seen[nullifier]; exists {
+			return ErrDuplicateNullifier
+		}
+		seen[nullifier] = struct{}{}
+	}
+	return nil
+}
+
+// validateTransactions validates all transactions in the block
+func (v *BlockValidator) validateTransactions(ctx context.Context, block *types.Block) error {
+	if err := checkTransactions(block); err != nil {
+		return err
+	}
+
 	// Validate each transaction
 	for _, tx := range block.Transactions {
 		if err := v.validateTransaction(ctx, tx); err != nil {
@@ -179,17 +710,35 @@ func (v *BlockValidator) validateTransactions(ctx context.Context, block *types.
 
 // validateTransaction validates a single transaction
 func (v *BlockValidator) validateTransaction(ctx context.Context, tx *types.Transaction) error {
-	// Verify transaction hash
-	computedHash := tx.ComputeHash()
-	if computedHash != tx.TxHash {
-		return errors.New("transaction hash mismatch")
-	}
+	if v.shielded != nil && (len(tx.Nullifiers) > 0 || len(tx.Commitments) > 0) {
+		// Verify nullifiers are not already spent
+		spent, err := v.shielded.HasNullifiers(ctx, tx.Nullifiers)
+		if err != nil {
+			return unavailable(err)
+		}
+		for _, s := range spent {
+			if s {
+				return ErrNullifierSpent
+			}
+		}
 
-	// Verify nullifiers are not already spent
-	// (This would check the nullifier set in production)
+		// Verify the anchor is the current or a recent root
+		if _, ok := v.shielded.AnchorAge(tx.Anchor); !ok {
+			return ErrInvalidAnchor
+		}
 
-	// Verify zk-SNARK proof
-	// (This would call the ZKP verifier in production)
+		// Verify zk-SNARK proof
+		if err := v.shielded.VerifyTransaction(ctx, tx); err != nil {
+			return ErrInvalidTxProof
+		}
+	}
+
+	// Verify required disclosures
+	if v.disclosures != nil {
+		if err := v.disclosures.CheckDisclosures(ctx, tx); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -213,11 +762,17 @@ func (v *BlockValidator) validatePoUW(ctx context.Context, block *types.Block) e
 		return ErrInvalidQualityScore
 	}
 
-	// In production, this would verify:
-	// 1. The gradient computation is valid
-	// 2. Loss(W_t + α·∇L) < Loss(W_t) (Improvement Gate)
-	// 3. The verification subset matches the VRF selection
-	// 4. Gradient diversity check
+	// The gradient proof shows Loss(W_t + α·∇L) < Loss(W_t) (Improvement
+	// Gate) and that the quality score follows from the committed weights
+	// and batch
+	if v.pouw != nil {
+		if err := v.pouw.VerifyPoUW(ctx, header); err != nil {
+			return ErrInvalidPoUW
+		}
+	}
+
+	// The verification subset is seeded by the header's VRF output (see
+	// validateVRF) and re-executed by pouw.Verifier
 
 	return nil
 }
@@ -238,6 +793,27 @@ func ComputeTxRoot(txs []*types.Transaction) types.Hash {
 	return computeMerkleRoot(hashes)
 }
 
+// BlockNullifiers returns the nullifiers spent by txs, in block order
+func BlockNullifiers(txs []*types.Transaction) []types.Hash {
+	var nullifiers []types.Hash
+	for _, tx := range txs {
+		nullifiers = append(nullifiers, tx.Nullifiers...)
+	}
+	return nullifiers
+}
+
+// BlockCommitments returns the note commitments created by txs, in block
+// order
+func BlockCommitments(txs []*types.Transaction) []types.Hash {
+	var commitments []types.Hash
+	for _, tx := range txs {
+		for _, commitment := range tx.Commitments {
+			commitments = append(commitments, commitment.Value)
+		}
+	}
+	return commitments
+}
+
 // computeMerkleRoot computes the Merkle root from a list of hashes
 func computeMerkleRoot(hashes []types.Hash) types.Hash {
 	if len(hashes) == 0 {
@@ -261,22 +837,105 @@ func computeMerkleRoot(hashes []types.Hash) types.Hash {
 	return computeMerkleRoot(nextLevel)
 }
 
+// TxPath returns the sibling hashes proving that the transaction at index
+// is included under ComputeTxRoot(txs), leaf first
+func TxPath(txs []*types.Transaction, index int) []types.Hash {
+	if index < 0 || index >= len(txs) {
+		return nil
+	}
+
+	hashes := make([]types.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.TxHash
+	}
+	return merklePath(hashes, index)
+}
+
+// merklePath returns the sibling hashes proving the leaf at index under
+// computeMerkleRoot(hashes), leaf first
+func merklePath(hashes []types.Hash, index int) []types.Hash {
+	var path []types.Hash
+	for len(hashes) > 1 {
+		if len(hashes)%2 != 0 {
+			hashes = append(hashes, hashes[len(hashes)-1])
+		}
+		path = append(path, hashes[index^1])
+
+		nextLevel := make([]types.Hash, len(hashes)/2)
+		for i := 0; i < len(hashes); i += 2 {
+			nextLevel[i/2] = hashPair(hashes[i], hashes[i+1])
+		}
+		hashes = nextLevel
+		index /= 2
+	}
+	return path
+}
+
+// VerifyTxPath checks a path returned by TxPath against a transaction root
+func VerifyTxPath(root, txHash types.Hash, index int, path []types.Hash) bool {
+	return verifyMerklePath(root, txHash, index, path)
+}
+
+// verifyMerklePath checks a path returned by merklePath against a root
+func verifyMerklePath(root, leaf types.Hash, index int, path []types.Hash) bool {
+	if index < 0 || index >= 1<<uint(len(path)) {
+		return false
+	}
+
+	current := leaf
+	for _, sibling := range path {
+		if index%2 == 0 {
+			current = hashPair(current, sibling)
+		} else {
+			current = hashPair(sibling, current)
+		}
+		index /= 2
+	}
+	return current == root
+}
+
+// ComputeReceiptsRoot computes the Merkle root of a block's receipts
+func ComputeReceiptsRoot(receipts []*types.Receipt) types.Hash {
+	if len(receipts) == 0 {
+		return types.EmptyHash
+	}
+
+	hashes := make([]types.Hash, len(receipts))
+	for i, r := range receipts {
+		hashes[i] = r.Hash()
+	}
+	return computeMerkleRoot(hashes)
+}
+
+// ReceiptPath returns the sibling hashes proving that the receipt at
+// index is included under ComputeReceiptsRoot(receipts), leaf first
+func ReceiptPath(receipts []*types.Receipt, index int) []types.Hash {
+	if index < 0 || index >= len(receipts) {
+		return nil
+	}
+
+	hashes := make([]types.Hash, len(receipts))
+	for i, r := range receipts {
+		hashes[i] = r.Hash()
+	}
+	return merklePath(hashes, index)
+}
+
+// VerifyReceiptPath checks a path returned by ReceiptPath against a
+// block's receipts root
+func VerifyReceiptPath(root types.Hash, receipt *types.Receipt, index int, path []types.Hash) bool {
+	return verifyMerklePath(root, receipt.Hash(), index, path)
+}
+
 // hashPair hashes two hashes together
 func hashPair(a, b types.Hash) types.Hash {
 	data := make([]byte, types.HashSize*2)
 	copy(data[:types.HashSize], a[:])
 	copy(data[types.HashSize:], b[:])
-
-	result := types.Hash{}
-	// In production, use SHA3-256
-	copy(result[:], data[:types.HashSize]) // Simplified for now
-	return result
+	return sha256.Sum256(data)
 }
 
 // hashToBigInt converts a hash to a big.Int for comparison
 func hashToBigInt(h types.Hash) *big.Int {
 	return new(big.Int).SetBytes(h[:])
 }
-
-// Required import
-import "math/big"
@@ -0,0 +1,408 @@
+// Package diagnostics exposes runtime profiling and trace bundles for operators.
+package diagnostics
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	rpprof "runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Diagnostics errors
+var (
+	ErrCaptureInProgress = errors.New("diagnostics capture already in progress")
+	ErrDurationTooLong   = errors.New("capture duration exceeds maximum")
+)
+
+// BundleDirName is the data-dir subdirectory holding captured bundles
+const BundleDirName = "diagnostics"
+
+// MetricsSource contributes a named snapshot to the metrics file of a bundle
+type MetricsSource func() interface{}
+
+// Config holds diagnostics configuration
+type Config struct {
+	// ListenAddr is the admin-only HTTP address (empty disables the server)
+	ListenAddr string
+
+	// AuthToken, if set, must be sent as a bearer token on every request
+	AuthToken string
+
+	// DataDir is where bundles are written
+	DataDir string
+
+	// LogFile is included (tail) in bundles when set
+	LogFile string
+
+	// Capture limits
+	DefaultDuration time.Duration
+	MaxDuration     time.Duration
+	MaxLogBytes     int64
+}
+
+// DefaultConfig returns default diagnostics configuration
+func DefaultConfig() *Config {
+	return &Config{
+		ListenAddr:      "127.0.0.1:6060",
+		DefaultDuration: 30 * time.Second,
+		MaxDuration:     5 * time.Minute,
+		MaxLogBytes:     8 * 1024 * 1024,
+	}
+}
+
+// Diagnostics serves pprof endpoints and captures trace bundles
+type Diagnostics struct {
+	mu sync.Mutex
+
+	config  *Config
+	metrics map[string]MetricsSource
+
+	// Only one CPU profile / execution trace may run at a time
+	capturing bool
+
+	server *http.Server
+}
+
+// NewDiagnostics creates a new diagnostics service
+func NewDiagnostics(cfg *Config) *Diagnostics {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+
+	return &Diagnostics{
+		config:  cfg,
+		metrics: make(map[string]MetricsSource),
+	}
+}
+
+// RegisterMetrics adds a named metrics source included in every bundle
+func (d *Diagnostics) RegisterMetrics(name string, source MetricsSource) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.metrics[name] = source
+}
+
+// Handler returns the admin HTTP handler
+func (d *Diagnostics) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/goroutines", d.handleGoroutines)
+	mux.HandleFunc("/debug/metrics", d.handleMetrics)
+	mux.HandleFunc("/debug/bundle", d.handleBundle)
+
+	return d.authenticate(mux)
+}
+
+// Start starts the admin HTTP server
+func (d *Diagnostics) Start() error {
+	if d.config.ListenAddr == "" {
+		return nil
+	}
+
+	lis, err := net.Listen("tcp", d.config.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", d.config.ListenAddr, err)
+	}
+
+	d.mu.Lock()
+	d.server = &http.Server{Handler: d.Handler()}
+	server := d.server
+	d.mu.Unlock()
+
+	go func() {
+		if err := server.Serve(lis); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("Diagnostics server error: %v\n", err)
+		}
+	}()
+
+	return nil
+}
+
+// Stop stops the admin HTTP server
+func (d *Diagnostics) Stop() {
+	d.mu.Lock()
+	server := d.server
+	d.mu.Unlock()
+
+	if server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}
+}
+
+// authenticate enforces the bearer token when configured
+func (d *Diagnostics) authenticate(next http.Handler) http.Handler {
+	if d.config.AuthToken == "" {
+		return next
+	}
+
+	expected := []byte("Bearer " + d.config.AuthToken)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, expected) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleGoroutines writes a full goroutine dump
+func (d *Diagnostics) handleGoroutines(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	rpprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+// handleMetrics writes the metrics snapshot as JSON
+func (d *Diagnostics) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(d.MetricsSnapshot())
+}
+
+// handleBundle captures a bundle and streams it back
+func (d *Diagnostics) handleBundle(w http.ResponseWriter, r *http.Request) {
+	duration := d.config.DefaultDuration
+	if s := r.URL.Query().Get("seconds"); s != "" {
+		secs, err := strconv.Atoi(s)
+		if err != nil || secs <= 0 {
+			http.Error(w, "invalid seconds", http.StatusBadRequest)
+			return
+		}
+		duration = time.Duration(secs) * time.Second
+	}
+
+	var buf bytes.Buffer
+	if err := d.WriteBundle(r.Context(), &buf, duration); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", bundleName(time.Now())))
+	w.Write(buf.Bytes())
+}
+
+// MetricsSnapshot returns runtime statistics plus all registered sources
+func (d *Diagnostics) MetricsSnapshot() map[string]interface{} {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	snapshot := map[string]interface{}{
+		"time": time.Now().UTC(),
+		"runtime": map[string]interface{}{
+			"go_version":   runtime.Version(),
+			"goroutines":   runtime.NumGoroutine(),
+			"num_cpu":      runtime.NumCPU(),
+			"heap_alloc":   mem.HeapAlloc,
+			"heap_inuse":   mem.HeapInuse,
+			"heap_objects": mem.HeapObjects,
+			"sys":          mem.Sys,
+			"num_gc":       mem.NumGC,
+			"pause_total":  mem.PauseTotalNs,
+		},
+	}
+
+	d.mu.Lock()
+	sources := make(map[string]MetricsSource, len(d.metrics))
+	for name, source := range d.metrics {
+		sources[name] = source
+	}
+	d.mu.Unlock()
+
+	for name, source := range sources {
+		snapshot[name] = source()
+	}
+
+	return snapshot
+}
+
+// CaptureBundle records a trace bundle for the given duration and writes it
+// under DataDir/diagnostics, returning the file path
+func (d *Diagnostics) CaptureBundle(ctx context.Context, duration time.Duration) (string, error) {
+	dir := filepath.Join(d.config.DataDir, BundleDirName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, bundleName(time.Now()))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	if err := d.WriteBundle(ctx, f, duration); err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", err
+	}
+
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// WriteBundle records an execution trace and CPU profile for the given
+// duration, then writes a tar.gz with profiles, goroutines, metrics and logs
+func (d *Diagnostics) WriteBundle(ctx context.Context, w io.Writer, duration time.Duration) error {
+	if duration <= 0 {
+		duration = d.config.DefaultDuration
+	}
+	if d.config.MaxDuration > 0 && duration > d.config.MaxDuration {
+		return ErrDurationTooLong
+	}
+
+	d.mu.Lock()
+	if d.capturing {
+		d.mu.Unlock()
+		return ErrCaptureInProgress
+	}
+	d.capturing = true
+	d.mu.Unlock()
+
+	defer func() {
+		d.mu.Lock()
+		d.capturing = false
+		d.mu.Unlock()
+	}()
+
+	// Record CPU profile and execution trace concurrently
+	var cpuBuf, traceBuf bytes.Buffer
+	if err := rpprof.StartCPUProfile(&cpuBuf); err != nil {
+		return fmt.Errorf("failed to start cpu profile: %w", err)
+	}
+	if err := trace.Start(&traceBuf); err != nil {
+		rpprof.StopCPUProfile()
+		return fmt.Errorf("failed to start trace: %w", err)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(duration):
+	}
+
+	trace.Stop()
+	rpprof.StopCPUProfile()
+
+	files := map[string][]byte{
+		"cpu.pprof": cpuBuf.Bytes(),
+		"trace.out": traceBuf.Bytes(),
+	}
+
+	for _, name := range []string{"heap", "allocs", "goroutine", "block", "mutex"} {
+		var buf bytes.Buffer
+		if p := rpprof.Lookup(name); p != nil {
+			p.WriteTo(&buf, 0)
+			files[name+".pprof"] = buf.Bytes()
+		}
+	}
+
+	var goroutines bytes.Buffer
+	rpprof.Lookup("goroutine").WriteTo(&goroutines, 2)
+	files["goroutines.txt"] = goroutines.Bytes()
+
+	metrics, err := json.MarshalIndent(d.MetricsSnapshot(), "", "  ")
+	if err != nil {
+		return err
+	}
+	files["metrics.json"] = metrics
+
+	if logs, err := d.tailLog(); err == nil && logs != nil {
+		files["node.log"] = logs
+	}
+
+	return writeTarGz(w, files)
+}
+
+// tailLog returns the last MaxLogBytes of the log file
+func (d *Diagnostics) tailLog() ([]byte, error) {
+	if d.config.LogFile == "" {
+		return nil, nil
+	}
+
+	f, err := os.Open(d.config.LogFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	offset := info.Size() - d.config.MaxLogBytes
+	if offset < 0 {
+		offset = 0
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(f)
+}
+
+// writeTarGz writes files as a gzipped tarball in a stable order
+func writeTarGz(w io.Writer, files map[string][]byte) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	names := []string{
+		"metrics.json", "goroutines.txt", "trace.out", "cpu.pprof",
+		"heap.pprof", "allocs.pprof", "goroutine.pprof", "block.pprof", "mutex.pprof",
+		"node.log",
+	}
+	now := time.Now()
+
+	for _, name := range names {
+		data, exists := files[name]
+		if !exists {
+			continue
+		}
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    0600,
+			Size:    int64(len(data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// bundleName returns the bundle filename for a capture time
+func bundleName(t time.Time) string {
+	return fmt.Sprintf("ccoin-diag-%s.tar.gz", t.UTC().Format("20060102T150405Z"))
+}
@@ -0,0 +1,194 @@
+package economics
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Fee estimation errors
+var (
+	ErrInvalidTarget = errors.New("confirmation target out of range")
+)
+
+// StandardSendGas is the gas of a typical shielded send (two notes in,
+// payment and change out, Groth16 proof), used when the caller does not
+// know the size of the transaction it will build
+const StandardSendGas uint64 = 21000 + 2*1000 + 2*1000 + 192*10
+
+// PendingSource provides the transactions waiting for inclusion
+type PendingSource interface {
+	Pending() []*types.Transaction
+}
+
+// FloorSource is implemented by pending sources that enforce an
+// adaptive minimum fee rate
+type FloorSource interface {
+	MinFeeRate() uint64
+}
+
+// FeeEstimatorConfig holds fee estimator configuration
+type FeeEstimatorConfig struct {
+	// HistoryBlocks is the number of recent blocks sampled
+	HistoryBlocks int
+
+	// MaxTarget is the largest confirmation target accepted
+	MaxTarget int
+
+	// BlockGas is the gas assumed available to pending transactions per
+	// block when measuring mempool congestion
+	BlockGas uint64
+}
+
+// DefaultFeeEstimatorConfig returns default configuration
+func DefaultFeeEstimatorConfig() *FeeEstimatorConfig {
+	return &FeeEstimatorConfig{
+		HistoryBlocks: 100,
+		MaxTarget:     144,
+		BlockGas:      TargetBlockGas,
+	}
+}
+
+// FeeEstimate is a suggested fee rate for confirmation within
+// TargetBlocks blocks. Rates are per unit of gas.
+type FeeEstimate struct {
+	TargetBlocks int
+	BaseFee      uint64
+	MempoolRate  uint64 // Rate needed to outbid the pending backlog
+	HistoryRate  uint64 // Percentile of recently included rates
+	MinFeeRate   uint64 // Mempool's adaptive floor, below which it rejects
+	FeeRate      uint64 // Highest of the above
+}
+
+// Fee returns the total fee for a transaction using gas
+func (e *FeeEstimate) Fee(gas uint64) uint64 {
+	return e.FeeRate * gas
+}
+
+// FeeEstimator suggests fees from the fee market's base fee, the rates
+// of pending transactions and the rates included in recent blocks
+type FeeEstimator struct {
+	mu sync.RWMutex
+
+	market  *FeeMarket
+	pending PendingSource
+	config  *FeeEstimatorConfig
+
+	// Sorted fee rates of each recent block, oldest first
+	history [][]uint64
+}
+
+// NewFeeEstimator creates a new fee estimator
+func NewFeeEstimator(market *FeeMarket, pending PendingSource, config *FeeEstimatorConfig) *FeeEstimator {
+	if config == nil {
+		config = DefaultFeeEstimatorConfig()
+	}
+
+	return &FeeEstimator{
+		market:  market,
+		pending: pending,
+		config:  config,
+		history: make([][]uint64, 0, config.HistoryBlocks),
+	}
+}
+
+// AddBlock records the fee rates of a block's transactions and advances
+// the market's base fee by the block's gas usage
+func (fe *FeeEstimator) AddBlock(block *types.Block) {
+	rates := make([]uint64, 0, len(block.Transactions))
+	var gasUsed uint64
+	for _, tx := range block.Transactions {
+		gas := EstimateGas(tx)
+		gasUsed += gas
+		rates = append(rates, tx.Fee/gas)
+	}
+	sort.Slice(rates, func(i, j int) bool { return rates[i] < rates[j] })
+
+	fe.mu.Lock()
+	fe.history = append(fe.history, rates)
+	if len(fe.history) > fe.config.HistoryBlocks {
+		fe.history = fe.history[1:]
+	}
+	fe.mu.Unlock()
+
+	fe.market.UpdateBaseFee(gasUsed)
+}
+
+// Estimate returns the fee rate likely to confirm within targetBlocks
+func (fe *FeeEstimator) Estimate(targetBlocks int) (*FeeEstimate, error) {
+	if targetBlocks < 1 || targetBlocks > fe.config.MaxTarget {
+		return nil, ErrInvalidTarget
+	}
+
+	est := &FeeEstimate{
+		TargetBlocks: targetBlocks,
+		BaseFee:      fe.market.GetBaseFee(),
+	}
+	if fe.pending != nil {
+		est.MempoolRate = fe.mempoolRate(fe.pending.Pending(), targetBlocks)
+	}
+	if floor, ok := fe.pending.(FloorSource); ok {
+		est.MinFeeRate = floor.MinFeeRate()
+	}
+	est.HistoryRate = fe.historyRate(targetBlocks)
+
+	est.FeeRate = est.BaseFee
+	if est.MempoolRate > est.FeeRate {
+		est.FeeRate = est.MempoolRate
+	}
+	if est.HistoryRate > est.FeeRate {
+		est.FeeRate = est.HistoryRate
+	}
+	if est.MinFeeRate > est.FeeRate {
+		est.FeeRate = est.MinFeeRate
+	}
+	return est, nil
+}
+
+// mempoolRate returns the rate that places a transaction within the
+// first targetBlocks blocks' worth of pending gas, or 0 if the backlog
+// already fits
+func (fe *FeeEstimator) mempoolRate(pending []*types.Transaction, targetBlocks int) uint64 {
+	type entry struct {
+		rate, gas uint64
+	}
+	entries := make([]entry, len(pending))
+	for i, tx := range pending {
+		gas := EstimateGas(tx)
+		entries[i] = entry{rate: tx.Fee / gas, gas: gas}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].rate > entries[j].rate })
+
+	capacity := fe.config.BlockGas * uint64(targetBlocks)
+	var used uint64
+	for _, e := range entries {
+		used += e.gas
+		if used >= capacity {
+			return e.rate + 1
+		}
+	}
+	return 0
+}
+
+// historyRate returns a percentile of recently included fee rates. Near
+// targets use a high percentile (90th for the next block), falling
+// toward the median for distant ones.
+func (fe *FeeEstimator) historyRate(targetBlocks int) uint64 {
+	fe.mu.RLock()
+	defer fe.mu.RUnlock()
+
+	var rates []uint64
+	for _, block := range fe.history {
+		rates = append(rates, block...)
+	}
+	if len(rates) == 0 {
+		return 0
+	}
+	sort.Slice(rates, func(i, j int) bool { return rates[i] < rates[j] })
+
+	percentile := 0.5 + 0.4/float64(targetBlocks)
+	idx := int(percentile * float64(len(rates)-1))
+	return rates[idx]
+}
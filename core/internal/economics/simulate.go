@@ -0,0 +1,213 @@
+package economics
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Simulation errors
+var (
+	ErrInvalidParams     = errors.New("invalid economic parameters")
+	ErrInvalidSimulation = errors.New("simulation must cover 1 to MaxSimulationEpochs epochs")
+)
+
+// MaxSimulationEpochs bounds the length of a simulation
+const MaxSimulationEpochs = 1000
+
+// EconomicParams are the governance-managed economic parameters
+type EconomicParams struct {
+	Fees    *FeeDistribution
+	Rewards *RewardDistribution
+
+	// EmissionScale multiplies the scheduled block reward
+	EmissionScale float64
+}
+
+// DefaultEconomicParams returns the parameters the chain launched with
+func DefaultEconomicParams() *EconomicParams {
+	return &EconomicParams{
+		Fees:          DefaultFeeDistribution(),
+		Rewards:       DefaultRewardDistribution(),
+		EmissionScale: 1,
+	}
+}
+
+// EconomicParamsFromProposal returns the parameters a proposal would set
+func EconomicParamsFromProposal(d *types.EconomicParameterData) *EconomicParams {
+	return &EconomicParams{
+		Fees: &FeeDistribution{
+			MinerShare:    d.FeeMinerShare,
+			BurnShare:     d.FeeBurnShare,
+			TreasuryShare: d.FeeTreasuryShare,
+		},
+		Rewards: &RewardDistribution{
+			MinerShare:    d.RewardMinerShare,
+			StakerShare:   d.RewardStakerShare,
+			TreasuryShare: d.RewardTreasuryShare,
+			ProposerShare: d.RewardProposerShare,
+			BurnShare:     d.RewardBurnShare,
+		},
+		EmissionScale: d.EmissionScale,
+	}
+}
+
+// SimulationConfig holds the inputs of a simulation besides the parameters
+type SimulationConfig struct {
+	// StartHeight is the first block simulated; it is rounded down to the
+	// start of its epoch
+	StartHeight uint64
+
+	// Epochs is the number of epochs simulated
+	Epochs int
+
+	// FeesPerBlock is the fee revenue assumed for every block
+	FeesPerBlock uint64
+}
+
+// EpochProjection is the projected outcome of one epoch
+type EpochProjection struct {
+	Epoch       uint64
+	StartHeight uint64
+
+	Emission uint64
+	Fees     uint64
+
+	MinerRewards    uint64
+	StakerRewards   uint64
+	TreasuryInflow  uint64
+	ProposerRewards uint64
+	Burned          uint64
+
+	// Supply is the circulating supply at the end of the epoch
+	Supply uint64
+}
+
+// Simulation is a deterministic projection of the chain economics. Hash
+// commits to the parameters, the inputs and every projected epoch, so two
+// nodes agree on a projection exactly when their hashes match.
+type Simulation struct {
+	Params      *EconomicParams
+	Config      SimulationConfig
+	Projections []EpochProjection
+	Hash        types.Hash
+}
+
+// Validate checks that each distribution sums to one and the emission
+// scale is positive
+func (p *EconomicParams) Validate() error {
+	if p.Fees == nil || p.Rewards == nil {
+		return ErrInvalidParams
+	}
+	if !validShares(p.Fees.MinerShare, p.Fees.BurnShare, p.Fees.TreasuryShare) {
+		return ErrInvalidParams
+	}
+	if !validShares(p.Rewards.MinerShare, p.Rewards.StakerShare, p.Rewards.TreasuryShare,
+		p.Rewards.ProposerShare, p.Rewards.BurnShare) {
+		return ErrInvalidParams
+	}
+	if !(p.EmissionScale > 0) || p.EmissionScale > 10 {
+		return ErrInvalidParams
+	}
+	return nil
+}
+
+// validShares reports whether every share is in [0, 1] and they sum to one
+func validShares(shares ...float64) bool {
+	var sum float64
+	for _, s := range shares {
+		if !(s >= 0 && s <= 1) {
+			return false
+		}
+		sum += s
+	}
+	return math.Abs(sum-1) < 1e-9
+}
+
+// Simulate projects emission, fee revenue and their distribution over the
+// configured epochs under params, starting from the scheduled supply
+func Simulate(params *EconomicParams, cfg SimulationConfig) (*Simulation, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Epochs < 1 || cfg.Epochs > MaxSimulationEpochs {
+		return nil, ErrInvalidSimulation
+	}
+
+	epoch := cfg.StartHeight / types.EpochLength
+	cfg.StartHeight = epoch * types.EpochLength
+	supply := ProjectedSupplyAtHeight(cfg.StartHeight)
+
+	sim := &Simulation{
+		Params:      params,
+		Config:      cfg,
+		Projections: make([]EpochProjection, 0, cfg.Epochs),
+	}
+	for i := 0; i < cfg.Epochs; i++ {
+		height := (epoch + uint64(i)) * types.EpochLength
+
+		// Halvings fall on epoch boundaries, so the reward is constant
+		// across the epoch
+		emission := uint64(float64(CalculateBlockReward(height)) * params.EmissionScale * types.EpochLength)
+		if supply+emission > MaxSupply {
+			emission = MaxSupply - supply
+		}
+		fees := cfg.FeesPerBlock * types.EpochLength
+
+		p := EpochProjection{
+			Epoch:       epoch + uint64(i),
+			StartHeight: height,
+			Emission:    emission,
+			Fees:        fees,
+		}
+		var rewardBurn uint64
+		p.MinerRewards, p.StakerRewards, p.TreasuryInflow, p.ProposerRewards, rewardBurn =
+			params.Rewards.CalculateDistribution(emission)
+		feeMiner, feeBurn, feeTreasury := DistributeFees(fees, params.Fees)
+		p.MinerRewards += feeMiner
+		p.TreasuryInflow += feeTreasury
+		p.Burned = rewardBurn + feeBurn
+
+		supply += emission
+		if p.Burned > supply {
+			supply = 0
+		} else {
+			supply -= p.Burned
+		}
+		p.Supply = supply
+
+		sim.Projections = append(sim.Projections, p)
+	}
+
+	sim.Hash = sim.computeHash()
+	return sim, nil
+}
+
+// computeHash hashes the parameters, inputs and projections
+func (s *Simulation) computeHash() types.Hash {
+	buf := []byte("CCOIN_ECON_SIMULATION")
+	for _, f := range []float64{
+		s.Params.Fees.MinerShare, s.Params.Fees.BurnShare, s.Params.Fees.TreasuryShare,
+		s.Params.Rewards.MinerShare, s.Params.Rewards.StakerShare, s.Params.Rewards.TreasuryShare,
+		s.Params.Rewards.ProposerShare, s.Params.Rewards.BurnShare,
+		s.Params.EmissionScale,
+	} {
+		buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(f))
+	}
+	buf = binary.BigEndian.AppendUint64(buf, s.Config.StartHeight)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(s.Config.Epochs))
+	buf = binary.BigEndian.AppendUint64(buf, s.Config.FeesPerBlock)
+
+	for _, p := range s.Projections {
+		for _, v := range []uint64{
+			p.Epoch, p.StartHeight, p.Emission, p.Fees, p.MinerRewards, p.StakerRewards,
+			p.TreasuryInflow, p.ProposerRewards, p.Burned, p.Supply,
+		} {
+			buf = binary.BigEndian.AppendUint64(buf, v)
+		}
+	}
+	return types.Hash(sha256.Sum256(buf))
+}
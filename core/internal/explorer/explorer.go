@@ -0,0 +1,1019 @@
+// Package explorer serves a read-only REST and WebSocket API for block
+// explorers.
+package explorer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ccoin/core/internal/history"
+	"github.com/ccoin/core/internal/reputation"
+	"github.com/ccoin/core/internal/state"
+	"github.com/ccoin/core/internal/storage"
+	"github.com/ccoin/core/pkg/common"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Explorer errors
+var (
+	ErrServerRunning  = errors.New("explorer server already running")
+	ErrInvalidHash    = errors.New("invalid hash")
+	ErrInvalidAddress = errors.New("invalid address")
+)
+
+// APIPrefix is the path every explorer endpoint is served under
+const APIPrefix = "/api/v1"
+
+// Config holds explorer API configuration
+type Config struct {
+	// ListenAddr is the HTTP address (empty disables the explorer)
+	ListenAddr string
+
+	// PageSize is the default and MaxPageSize the largest number of
+	// blocks a listing returns
+	PageSize    int
+	MaxPageSize int
+
+	// AllowOrigin is sent as Access-Control-Allow-Origin and checked
+	// against the Origin of WebSocket clients; "*" allows any site
+	AllowOrigin string
+
+	// StreamBuffer is the number of events queued for a WebSocket
+	// client; a client that falls further behind is disconnected
+	StreamBuffer int
+}
+
+// DefaultConfig returns default explorer configuration
+func DefaultConfig() *Config {
+	return &Config{
+		PageSize:     20,
+		MaxPageSize:  100,
+		AllowOrigin:  "*",
+		StreamBuffer: 256,
+	}
+}
+
+// DAGBackend is the view of the BlockDAG the explorer reads
+type DAGBackend interface {
+	GetBlock(ctx context.Context, hash types.Hash) (*types.Block, error)
+	GetHeadersAtHeight(ctx context.Context, height uint64) ([]*types.BlockHeader, error)
+	GetMainChain(ctx context.Context, fromHeight, toHeight uint64) ([]*types.BlockHeader, error)
+	GetTips() []types.Hash
+	GetMainChainTip() types.Hash
+	GetHeight() uint64
+	GetEpoch() uint64
+}
+
+// TxPool looks up pending transactions
+type TxPool interface {
+	Get(txHash types.Hash) *types.Transaction
+	Size() int
+}
+
+// StakeBackend serves the stakes and delegations of transparent
+// addresses
+type StakeBackend interface {
+	GetStakeInfo(addr types.Address) *reputation.StakeInfo
+	DelegatedStake(miner types.Address) uint64
+	Delegations(miner types.Address) []*reputation.Delegation
+	DelegationsBy(delegator types.Address) []*reputation.Delegation
+}
+
+// ModelBackend serves the AI Commons model registry
+type ModelBackend interface {
+	GetModel(ctx context.Context, modelID types.Hash) (*types.ModelEntry, error)
+	ModelVersions(modelID types.Hash, upTo uint32) ([]*types.ModelVersion, error)
+}
+
+// GovernanceBackend serves governance proposals
+type GovernanceBackend interface {
+	GetProposal(proposalID types.Hash) *types.Proposal
+	GetActiveProposals() []*types.Proposal
+	ProposerHistory(proposer types.Address) []*types.Proposal
+}
+
+// SupplyBackend reports coin supply figures
+type SupplyBackend interface {
+	GetCirculatingSupply() uint64
+	GetTotalMinted() uint64
+	GetTotalBurned() uint64
+}
+
+// HistoryBackend serves state as of past main chain heights on archive
+// nodes
+type HistoryBackend interface {
+	State(ctx context.Context, height uint64) (*types.BlockHeader, *state.State, error)
+	Checkpoint(height uint64) (*history.Checkpoint, error)
+}
+
+// Backends bundles the node components the explorer reads. Nil members
+// make the corresponding endpoints return 501 Not Implemented; without a
+// History, so do queries with a height.
+type Backends struct {
+	DAG          DAGBackend
+	Transactions storage.ExplorerStore
+	Mempool      TxPool
+	Stakes       StakeBackend
+	Models       ModelBackend
+	Governance   GovernanceBackend
+	Supply       SupplyBackend
+	History      HistoryBackend
+}
+
+// Explorer serves chain data to block explorers over REST and pushes new
+// blocks and transactions to WebSocket subscribers
+type Explorer struct {
+	mu sync.Mutex
+
+	config   *Config
+	backends *Backends
+	stream   *stream
+
+	server *http.Server
+	addr   net.Addr
+}
+
+// NewExplorer creates an explorer over backends
+func NewExplorer(cfg *Config, backends *Backends) *Explorer {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	if backends == nil {
+		backends = &Backends{}
+	}
+
+	return &Explorer{
+		config:   cfg,
+		backends: backends,
+		stream:   newStream(cfg),
+	}
+}
+
+// Handler returns the explorer HTTP handler
+func (e *Explorer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(APIPrefix+"/status", e.handleStatus)
+	mux.HandleFunc(APIPrefix+"/blocks", e.handleBlocks)
+	mux.HandleFunc(APIPrefix+"/blocks/", e.handleBlock)
+	mux.HandleFunc(APIPrefix+"/heights/", e.handleHeight)
+	mux.HandleFunc(APIPrefix+"/txs/", e.handleTransaction)
+	mux.HandleFunc(APIPrefix+"/nullifiers/", e.handleNullifier)
+	mux.HandleFunc(APIPrefix+"/addresses/", e.handleAddress)
+	mux.HandleFunc(APIPrefix+"/models/", e.handleModel)
+	mux.HandleFunc(APIPrefix+"/proposals", e.handleProposals)
+	mux.HandleFunc(APIPrefix+"/proposals/", e.handleProposal)
+	mux.HandleFunc(APIPrefix+"/supply", e.handleSupply)
+	mux.HandleFunc(APIPrefix+"/state", e.handleState)
+	mux.HandleFunc(APIPrefix+"/ws", e.handleStream)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if e.config.AllowOrigin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", e.config.AllowOrigin)
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// Start starts the explorer HTTP server
+func (e *Explorer) Start() error {
+	if e.config.ListenAddr == "" {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.server != nil {
+		return ErrServerRunning
+	}
+
+	lis, err := net.Listen("tcp", e.config.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", e.config.ListenAddr, err)
+	}
+	e.addr = lis.Addr()
+	e.server = &http.Server{Handler: e.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	server := e.server
+
+	go func() {
+		if err := server.Serve(lis); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("Explorer server error: %v\n", err)
+		}
+	}()
+
+	return nil
+}
+
+// Stop disconnects WebSocket subscribers and stops the HTTP server
+func (e *Explorer) Stop() {
+	e.mu.Lock()
+	server := e.server
+	e.server = nil
+	e.mu.Unlock()
+
+	e.stream.close()
+	if server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}
+}
+
+// Addr returns the address the server listens on, nil if not started
+func (e *Explorer) Addr() net.Addr {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.addr
+}
+
+// NotifyBlock pushes a block added to the DAG to subscribers
+func (e *Explorer) NotifyBlock(block *types.Block) {
+	e.stream.publish(&Event{Type: EventBlock, Block: newBlockView(block.Header, block.Transactions)})
+}
+
+// NotifyTransaction pushes a transaction accepted into the mempool to
+// subscribers
+func (e *Explorer) NotifyTransaction(tx *types.Transaction) {
+	view := newTxView(tx)
+	view.Pending = true
+	e.stream.publish(&Event{Type: EventTransaction, Transaction: view})
+}
+
+// ============================================================================
+// Views
+// ============================================================================
+
+// StatusView summarizes the chain
+type StatusView struct {
+	Height       uint64   `json:"height"`
+	Epoch        uint64   `json:"epoch"`
+	MainChainTip string   `json:"main_chain_tip"`
+	Tips         []string `json:"tips"`
+	PendingTxs   int      `json:"pending_txs"`
+}
+
+// BlockView describes a block. Transactions is only listed for a single
+// block.
+type BlockView struct {
+	Hash          string   `json:"hash"`
+	Height        uint64   `json:"height"`
+	Parents       []string `json:"parents"`
+	Timestamp     uint64   `json:"timestamp"`
+	Miner         string   `json:"miner"`
+	Payout        string   `json:"payout"`
+	TaskID        string   `json:"task_id"`
+	QualityScore  float64  `json:"quality_score"`
+	Difficulty    string   `json:"difficulty"`
+	TxRoot        string   `json:"tx_root"`
+	StateRoot     string   `json:"state_root"`
+	CommitteeRoot string   `json:"committee_root,omitempty"`
+	RegistryRoot  string   `json:"registry_root,omitempty"`
+	TxCount       int      `json:"tx_count"`
+	Transactions  []string `json:"transactions,omitempty"`
+}
+
+// BlockListView is a page of main chain blocks, newest first. Next is the
+// height to pass as before for the following page.
+type BlockListView struct {
+	Blocks []*BlockView `json:"blocks"`
+	Next   uint64       `json:"next,omitempty"`
+}
+
+// TxView describes a transaction and where it was included. Pending
+// transactions have no block.
+type TxView struct {
+	Hash        string   `json:"hash"`
+	Kind        string   `json:"kind"`
+	Fee         uint64   `json:"fee"`
+	Nullifiers  []string `json:"nullifiers"`
+	Commitments []string `json:"commitments"`
+	Disclosures int      `json:"disclosures"`
+	Pending     bool     `json:"pending,omitempty"`
+	BlockHash   string   `json:"block_hash,omitempty"`
+	BlockHeight uint64   `json:"block_height,omitempty"`
+	Index       int      `json:"index"`
+}
+
+// DelegationView is stake delegated by a holder to a miner
+type DelegationView struct {
+	Delegator      string `json:"delegator"`
+	Miner          string `json:"miner"`
+	Bonded         uint64 `json:"bonded"`
+	Unbonding      uint64 `json:"unbonding"`
+	UnbondingUntil uint64 `json:"unbonding_until,omitempty"`
+	Rewards        uint64 `json:"rewards"`
+	Slashed        uint64 `json:"slashed"`
+}
+
+// AddressView describes what is public about an address: its stake as a
+// miner, the delegations it made and received and its proposals. Shielded
+// balances are never visible.
+//
+// Queried at a past Height, stakes, delegations and proposals are as of
+// the history checkpoint at AsOf, and Miner is the address's miner
+// account exactly at Height.
+type AddressView struct {
+	Address        string            `json:"address"`
+	Height         uint64            `json:"height,omitempty"`
+	AsOf           uint64            `json:"as_of,omitempty"`
+	Staked         uint64            `json:"staked"`
+	AvailableStake uint64            `json:"available_stake"`
+	LockedStake    uint64            `json:"locked_stake"`
+	Slashed        uint64            `json:"slashed"`
+	DelegatedStake uint64            `json:"delegated_stake"`
+	DelegationsTo  []*DelegationView `json:"delegations_to"`
+	DelegationsBy  []*DelegationView `json:"delegations_by"`
+	Proposals      []*ProposalView   `json:"proposals,omitempty"`
+	Miner          *MinerView        `json:"miner,omitempty"`
+}
+
+// MinerView is a miner's account in the chain state
+type MinerView struct {
+	Blocks     uint64  `json:"blocks"`
+	Rewards    uint64  `json:"rewards"`
+	Reputation float64 `json:"reputation"`
+}
+
+// ModelView describes a registry entry and its versions
+type ModelView struct {
+	ModelID        string                `json:"model_id"`
+	Architecture   string                `json:"architecture"`
+	Domain         string                `json:"domain"`
+	TaskType       types.TaskType        `json:"task_type"`
+	Status         types.ModelStatus     `json:"status"`
+	License        string                `json:"license"`
+	CurrentWeights string                `json:"current_weights"`
+	Accuracy       float64               `json:"accuracy"`
+	TotalCompute   uint64                `json:"total_compute"`
+	Proposer       string                `json:"proposer"`
+	Contributors   map[string]uint64     `json:"contributors"`
+	Versions       []*types.ModelVersion `json:"versions"`
+}
+
+// ProposalView describes a governance proposal
+type ProposalView struct {
+	ProposalID   string             `json:"proposal_id"`
+	Type         types.ProposalType `json:"type"`
+	Proposer     string             `json:"proposer"`
+	Title        string             `json:"title"`
+	Description  string             `json:"description,omitempty"`
+	Status       string             `json:"status"`
+	VotesFor     uint64             `json:"votes_for"`
+	VotesAgainst uint64             `json:"votes_against"`
+	VotingStart  uint64             `json:"voting_start"`
+	VotingEnd    uint64             `json:"voting_end"`
+}
+
+// SupplyView reports coin supply in raw units. At a past height it is
+// the supply the emission schedule had issued, from the chain state,
+// which does not track burned fees.
+type SupplyView struct {
+	Height      uint64 `json:"height"`
+	Circulating uint64 `json:"circulating"`
+	Minted      uint64 `json:"minted"`
+	Burned      uint64 `json:"burned"`
+}
+
+// StateView is the chain state after a main chain block
+type StateView struct {
+	Height         uint64 `json:"height"`
+	BlockHash      string `json:"block_hash"`
+	StateRoot      string `json:"state_root"`
+	NullifierRoot  string `json:"nullifier_root"`
+	CommitmentRoot string `json:"commitment_root"`
+	Supply         uint64 `json:"supply"`
+	Treasury       uint64 `json:"treasury"`
+	Miners         int    `json:"miners"`
+}
+
+// newBlockView describes a header; txs are listed when given
+func newBlockView(h *types.BlockHeader, txs []*types.Transaction) *BlockView {
+	v := &BlockView{
+		Hash:         h.Hash.String(),
+		Height:       h.Height,
+		Parents:      hashStrings(h.Parents),
+		Timestamp:    h.Timestamp,
+		Miner:        common.BytesToHex(h.MinerAddress[:]),
+		Payout:       common.BytesToHex(h.PayoutAddress[:]),
+		TaskID:       h.TaskID.String(),
+		QualityScore: h.QualityScore,
+		TxRoot:       h.TxRoot.String(),
+		StateRoot:    h.StateRoot.String(),
+		TxCount:      len(txs),
+	}
+	if h.Difficulty != nil {
+		v.Difficulty = h.Difficulty.String()
+	}
+	if !h.CommitteeRoot.IsEmpty() {
+		v.CommitteeRoot = h.CommitteeRoot.String()
+	}
+	if !h.RegistryRoot.IsEmpty() {
+		v.RegistryRoot = h.RegistryRoot.String()
+	}
+	for _, tx := range txs {
+		v.Transactions = append(v.Transactions, tx.TxHash.String())
+	}
+	return v
+}
+
+// newTxView describes a transaction's public fields
+func newTxView(tx *types.Transaction) *TxView {
+	v := &TxView{
+		Hash:        tx.TxHash.String(),
+		Kind:        txKind(tx),
+		Fee:         tx.Fee,
+		Nullifiers:  hashStrings(tx.Nullifiers),
+		Commitments: make([]string, len(tx.Commitments)),
+		Disclosures: len(tx.Disclosures),
+	}
+	for i, c := range tx.Commitments {
+		v.Commitments[i] = c.Value.String()
+	}
+	return v
+}
+
+// txKind names the operation a transaction carries besides its transfer
+func txKind(tx *types.Transaction) string {
+	switch {
+	case tx.Sealed != nil:
+		return "sealed"
+	case tx.Inference != nil:
+		return "inference"
+	case tx.PayoutChange != nil:
+		return "payout_change"
+	case tx.Delegation != nil:
+		return "delegation"
+	default:
+		return "transfer"
+	}
+}
+
+// newDelegationViews describes delegations
+func newDelegationViews(delegations []*reputation.Delegation) []*DelegationView {
+	views := make([]*DelegationView, len(delegations))
+	for i, d := range delegations {
+		views[i] = &DelegationView{
+			Delegator:      common.BytesToHex(d.Delegator[:]),
+			Miner:          common.BytesToHex(d.Miner[:]),
+			Bonded:         d.Bonded,
+			Unbonding:      d.Unbonding,
+			UnbondingUntil: d.UnbondingUntil,
+			Rewards:        d.Rewards,
+			Slashed:        d.Slashed,
+		}
+	}
+	return views
+}
+
+// newProposalView describes a proposal, with its text if full
+func newProposalView(p *types.Proposal, full bool) *ProposalView {
+	v := &ProposalView{
+		ProposalID:   p.ProposalID.String(),
+		Type:         p.Type,
+		Proposer:     common.BytesToHex(p.ProposerAddress[:]),
+		Title:        p.Title,
+		Status:       p.Status.String(),
+		VotesFor:     p.VotesFor,
+		VotesAgainst: p.VotesAgainst,
+		VotingStart:  p.VotingStartBlock,
+		VotingEnd:    p.VotingEndBlock,
+	}
+	if full {
+		v.Description = p.Description
+	}
+	return v
+}
+
+// hashStrings formats hashes
+func hashStrings(hashes []types.Hash) []string {
+	s := make([]string, len(hashes))
+	for i, h := range hashes {
+		s[i] = h.String()
+	}
+	return s
+}
+
+// ============================================================================
+// Handlers
+// ============================================================================
+
+// errorBody is the JSON body of a failed request
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, &errorBody{Error: msg})
+}
+
+// writeLookupError reports a failed lookup, as 404 for missing records
+func writeLookupError(w http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+// unavailable reports an endpoint whose backend the node does not run
+func unavailable(w http.ResponseWriter, what string) {
+	writeError(w, http.StatusNotImplemented, what+" not available")
+}
+
+// pathParam returns the path segment after prefix
+func pathParam(r *http.Request, prefix string) string {
+	return strings.Trim(strings.TrimPrefix(r.URL.Path, APIPrefix+prefix), "/")
+}
+
+// parseHash decodes a hex hash
+func parseHash(s string) (types.Hash, error) {
+	b, err := common.HexToBytes(s)
+	if err != nil || len(b) != types.HashSize {
+		return types.Hash{}, ErrInvalidHash
+	}
+	return types.HashFromBytes(b), nil
+}
+
+// parseAddress decodes a hex address
+func parseAddress(s string) (types.Address, error) {
+	b, err := common.HexToBytes(s)
+	if err != nil || len(b) != types.AddressSize {
+		return types.Address{}, ErrInvalidAddress
+	}
+	var addr types.Address
+	copy(addr[:], b)
+	return addr, nil
+}
+
+// queryUint reads an unsigned query parameter, def if absent
+func queryUint(r *http.Request, name string, def uint64) (uint64, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s", name)
+	}
+	return n, nil
+}
+
+// queryHeight reads the height a historical query is for; ok is false
+// without one
+func queryHeight(r *http.Request) (height uint64, ok bool, err error) {
+	if !r.URL.Query().Has("height") {
+		return 0, false, nil
+	}
+	height, err = queryUint(r, "height", 0)
+	return height, true, err
+}
+
+// writeHistoryError reports a failed historical query, as 404 for heights
+// the history index holds nothing for
+func writeHistoryError(w http.ResponseWriter, err error) {
+	if errors.Is(err, history.ErrHeightAhead) || errors.Is(err, history.ErrNoCheckpoint) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+// checkpoint returns the history checkpoint serving a query at height,
+// writing the error response if there is none
+func (e *Explorer) checkpoint(w http.ResponseWriter, height uint64) (*history.Checkpoint, bool) {
+	if e.backends.History == nil {
+		unavailable(w, "historical queries")
+		return nil, false
+	}
+	cp, err := e.backends.History.Checkpoint(height)
+	if err != nil {
+		writeHistoryError(w, err)
+		return nil, false
+	}
+	return cp, true
+}
+
+// handleStatus serves GET /status
+func (e *Explorer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if e.backends.DAG == nil {
+		unavailable(w, "chain")
+		return
+	}
+	dag := e.backends.DAG
+	status := &StatusView{
+		Height:       dag.GetHeight(),
+		Epoch:        dag.GetEpoch(),
+		MainChainTip: dag.GetMainChainTip().String(),
+		Tips:         hashStrings(dag.GetTips()),
+	}
+	if e.backends.Mempool != nil {
+		status.PendingTxs = e.backends.Mempool.Size()
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// handleBlocks serves GET /blocks?before=<height>&limit=<n>, main chain
+// blocks below a height, newest first
+func (e *Explorer) handleBlocks(w http.ResponseWriter, r *http.Request) {
+	if e.backends.DAG == nil {
+		unavailable(w, "chain")
+		return
+	}
+	height := e.backends.DAG.GetHeight()
+	before, err := queryUint(r, "before", height+1)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, err := queryUint(r, "limit", uint64(e.config.PageSize))
+	if err != nil || limit == 0 {
+		writeError(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+	limit = min(limit, uint64(e.config.MaxPageSize))
+
+	page := &BlockListView{Blocks: []*BlockView{}}
+	before = min(before, height+1)
+	if before == 0 {
+		writeJSON(w, http.StatusOK, page)
+		return
+	}
+	from := uint64(0)
+	if before > limit {
+		from = before - limit
+	}
+	headers, err := e.backends.DAG.GetMainChain(r.Context(), from, before-1)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for i := len(headers) - 1; i >= 0; i-- {
+		page.Blocks = append(page.Blocks, e.blockView(r.Context(), headers[i]))
+	}
+	page.Next = from
+	writeJSON(w, http.StatusOK, page)
+}
+
+// blockView describes a header with its transaction count, read from
+// the stored block
+func (e *Explorer) blockView(ctx context.Context, h *types.BlockHeader) *BlockView {
+	v := newBlockView(h, nil)
+	if block, err := e.backends.DAG.GetBlock(ctx, h.Hash); err == nil {
+		v.TxCount = len(block.Transactions)
+	}
+	return v
+}
+
+// handleBlock serves GET /blocks/<hash>
+func (e *Explorer) handleBlock(w http.ResponseWriter, r *http.Request) {
+	if e.backends.DAG == nil {
+		unavailable(w, "chain")
+		return
+	}
+	hash, err := parseHash(pathParam(r, "/blocks/"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	block, err := e.backends.DAG.GetBlock(r.Context(), hash)
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newBlockView(block.Header, block.Transactions))
+}
+
+// handleHeight serves GET /heights/<height>, every block at a height
+func (e *Explorer) handleHeight(w http.ResponseWriter, r *http.Request) {
+	if e.backends.DAG == nil {
+		unavailable(w, "chain")
+		return
+	}
+	height, err := strconv.ParseUint(pathParam(r, "/heights/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid height")
+		return
+	}
+	headers, err := e.backends.DAG.GetHeadersAtHeight(r.Context(), height)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	blocks := make([]*BlockView, len(headers))
+	for i, h := range headers {
+		blocks[i] = e.blockView(r.Context(), h)
+	}
+	writeJSON(w, http.StatusOK, blocks)
+}
+
+// handleTransaction serves GET /txs/<hash>, from the chain or the mempool
+func (e *Explorer) handleTransaction(w http.ResponseWriter, r *http.Request) {
+	hash, err := parseHash(pathParam(r, "/txs/"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if e.backends.Mempool != nil {
+		if tx := e.backends.Mempool.Get(hash); tx != nil {
+			view := newTxView(tx)
+			view.Pending = true
+			writeJSON(w, http.StatusOK, view)
+			return
+		}
+	}
+	if e.backends.Transactions == nil {
+		unavailable(w, "transaction index")
+		return
+	}
+	record, err := e.backends.Transactions.GetTransaction(r.Context(), hash)
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newTxRecordView(record))
+}
+
+// handleNullifier serves GET /nullifiers/<nullifier>?height=<h>, the
+// transaction that spent it, by height h if given
+func (e *Explorer) handleNullifier(w http.ResponseWriter, r *http.Request) {
+	if e.backends.Transactions == nil {
+		unavailable(w, "transaction index")
+		return
+	}
+	nullifier, err := parseHash(pathParam(r, "/nullifiers/"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	height, historical, err := queryHeight(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	record, err := e.backends.Transactions.GetTransactionByNullifier(r.Context(), nullifier)
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	if historical && record.BlockHeight > height {
+		writeError(w, http.StatusNotFound, "not spent by height")
+		return
+	}
+	writeJSON(w, http.StatusOK, newTxRecordView(record))
+}
+
+// newTxRecordView describes an included transaction
+func newTxRecordView(record *storage.TxRecord) *TxView {
+	view := newTxView(record.Tx)
+	view.BlockHash = record.BlockHash.String()
+	view.BlockHeight = record.BlockHeight
+	view.Index = record.Index
+	return view
+}
+
+// handleAddress serves GET /addresses/<address>?height=<h>
+func (e *Explorer) handleAddress(w http.ResponseWriter, r *http.Request) {
+	height, historical, err := queryHeight(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	stakes, governance := e.backends.Stakes, e.backends.Governance
+	var cp *history.Checkpoint
+	if historical {
+		var ok bool
+		if cp, ok = e.checkpoint(w, height); !ok {
+			return
+		}
+		stakes, governance = cp, cp
+	}
+	if stakes == nil && governance == nil {
+		unavailable(w, "address data")
+		return
+	}
+	addr, err := parseAddress(pathParam(r, "/addresses/"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	view := &AddressView{
+		Address:       common.BytesToHex(addr[:]),
+		DelegationsTo: []*DelegationView{},
+		DelegationsBy: []*DelegationView{},
+	}
+	if historical {
+		_, st, err := e.backends.History.State(r.Context(), height)
+		if err != nil {
+			writeHistoryError(w, err)
+			return
+		}
+		view.Height = height
+		view.AsOf = cp.Height
+		if account, exists := st.Miners[addr]; exists {
+			view.Miner = &MinerView{
+				Blocks:     account.Blocks,
+				Rewards:    account.Rewards,
+				Reputation: account.Reputation,
+			}
+		}
+	}
+	if stakes != nil {
+		if info := stakes.GetStakeInfo(addr); info != nil {
+			view.Staked = info.TotalStaked
+			view.AvailableStake = info.AvailableStake
+			view.LockedStake = info.LockedStake
+			view.Slashed = info.TotalSlashed
+		}
+		view.DelegatedStake = stakes.DelegatedStake(addr)
+		view.DelegationsTo = newDelegationViews(stakes.Delegations(addr))
+		view.DelegationsBy = newDelegationViews(stakes.DelegationsBy(addr))
+	}
+	if governance != nil {
+		for _, p := range governance.ProposerHistory(addr) {
+			view.Proposals = append(view.Proposals, newProposalView(p, false))
+		}
+	}
+	writeJSON(w, http.StatusOK, view)
+}
+
+// handleModel serves GET /models/<id>
+func (e *Explorer) handleModel(w http.ResponseWriter, r *http.Request) {
+	if e.backends.Models == nil {
+		unavailable(w, "model registry")
+		return
+	}
+	modelID, err := parseHash(pathParam(r, "/models/"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	model, err := e.backends.Models.GetModel(r.Context(), modelID)
+	if err != nil || model == nil {
+		writeError(w, http.StatusNotFound, "model not found")
+		return
+	}
+	versions, err := e.backends.Models.ModelVersions(modelID, 0)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	view := &ModelView{
+		ModelID:        model.ModelID.String(),
+		Architecture:   model.Architecture,
+		Domain:         model.Domain,
+		TaskType:       model.TaskType,
+		Status:         model.Status,
+		License:        model.License.String(),
+		CurrentWeights: model.CurrentWeights,
+		Accuracy:       model.Accuracy,
+		TotalCompute:   model.TotalCompute,
+		Proposer:       common.BytesToHex(model.ProposerAddress[:]),
+		Contributors:   make(map[string]uint64, len(model.Contributors)),
+		Versions:       versions,
+	}
+	for addr, n := range model.Contributors {
+		view.Contributors[common.BytesToHex(addr[:])] = n
+	}
+	writeJSON(w, http.StatusOK, view)
+}
+
+// governance returns the governance state a request is for: the live
+// state, or a history checkpoint for one with a height. It writes the
+// error response if there is none.
+func (e *Explorer) governance(w http.ResponseWriter, r *http.Request) (GovernanceBackend, bool) {
+	height, historical, err := queryHeight(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	if historical {
+		return e.checkpoint(w, height)
+	}
+	if e.backends.Governance == nil {
+		unavailable(w, "governance")
+		return nil, false
+	}
+	return e.backends.Governance, true
+}
+
+// handleProposals serves GET /proposals?height=<h>, the proposals open
+// for voting
+func (e *Explorer) handleProposals(w http.ResponseWriter, r *http.Request) {
+	governance, ok := e.governance(w, r)
+	if !ok {
+		return
+	}
+	proposals := []*ProposalView{}
+	for _, p := range governance.GetActiveProposals() {
+		proposals = append(proposals, newProposalView(p, false))
+	}
+	writeJSON(w, http.StatusOK, proposals)
+}
+
+// handleProposal serves GET /proposals/<id>?height=<h>
+func (e *Explorer) handleProposal(w http.ResponseWriter, r *http.Request) {
+	governance, ok := e.governance(w, r)
+	if !ok {
+		return
+	}
+	id, err := parseHash(pathParam(r, "/proposals/"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	p := governance.GetProposal(id)
+	if p == nil {
+		writeError(w, http.StatusNotFound, "proposal not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, newProposalView(p, true))
+}
+
+// handleSupply serves GET /supply?height=<h>
+func (e *Explorer) handleSupply(w http.ResponseWriter, r *http.Request) {
+	height, historical, err := queryHeight(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if historical {
+		if e.backends.History == nil {
+			unavailable(w, "historical queries")
+			return
+		}
+		_, st, err := e.backends.History.State(r.Context(), height)
+		if err != nil {
+			writeHistoryError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, &SupplyView{Height: height, Circulating: st.Supply, Minted: st.Supply})
+		return
+	}
+	if e.backends.Supply == nil {
+		unavailable(w, "supply")
+		return
+	}
+	view := &SupplyView{
+		Circulating: e.backends.Supply.GetCirculatingSupply(),
+		Minted:      e.backends.Supply.GetTotalMinted(),
+		Burned:      e.backends.Supply.GetTotalBurned(),
+	}
+	if e.backends.DAG != nil {
+		view.Height = e.backends.DAG.GetHeight()
+	}
+	writeJSON(w, http.StatusOK, view)
+}
+
+// handleState serves GET /state?height=<h>, the chain state after the
+// main chain block at h, by default the chain height
+func (e *Explorer) handleState(w http.ResponseWriter, r *http.Request) {
+	if e.backends.History == nil {
+		unavailable(w, "historical queries")
+		return
+	}
+	height, historical, err := queryHeight(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !historical && e.backends.DAG != nil {
+		height = e.backends.DAG.GetHeight()
+	}
+	header, st, err := e.backends.History.State(r.Context(), height)
+	if err != nil {
+		writeHistoryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &StateView{
+		Height:         header.Height,
+		BlockHash:      header.Hash.String(),
+		StateRoot:      header.StateRoot.String(),
+		NullifierRoot:  st.NullifierRoot.String(),
+		CommitmentRoot: st.CommitmentRoot.String(),
+		Supply:         st.Supply,
+		Treasury:       st.Treasury,
+		Miners:         len(st.Miners),
+	})
+}
@@ -0,0 +1,200 @@
+package explorer
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Event types pushed to WebSocket subscribers
+const (
+	EventBlock       = "block"
+	EventTransaction = "tx"
+)
+
+// writeTimeout bounds writing one event to a subscriber
+const writeTimeout = 10 * time.Second
+
+// pingInterval is how often idle subscribers are pinged; one that does
+// not answer within two intervals is disconnected
+const pingInterval = 30 * time.Second
+
+// Event is a message pushed to WebSocket subscribers: a block added to
+// the DAG or a transaction accepted into the mempool
+type Event struct {
+	Type        string     `json:"type"`
+	Block       *BlockView `json:"block,omitempty"`
+	Transaction *TxView    `json:"tx,omitempty"`
+}
+
+// stream fans events out to WebSocket subscribers
+type stream struct {
+	mu sync.Mutex
+
+	config      *Config
+	upgrader    websocket.Upgrader
+	subscribers map[*subscriber]struct{}
+	closed      bool
+}
+
+// subscriber is one WebSocket client and the event types it wants
+type subscriber struct {
+	conn   *websocket.Conn
+	events chan *Event
+	topics map[string]bool
+}
+
+// newStream creates an event stream
+func newStream(cfg *Config) *stream {
+	s := &stream{
+		config:      cfg,
+		subscribers: make(map[*subscriber]struct{}),
+	}
+	s.upgrader = websocket.Upgrader{CheckOrigin: s.checkOrigin}
+	return s
+}
+
+// checkOrigin accepts clients from the allowed origin, and clients that
+// send none such as command-line tools
+func (s *stream) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return origin == "" || s.config.AllowOrigin == "*" || origin == s.config.AllowOrigin
+}
+
+// handleStream serves GET /ws?topics=blocks,txs. With no topics the
+// client receives both.
+func (e *Explorer) handleStream(w http.ResponseWriter, r *http.Request) {
+	topics := make(map[string]bool)
+	for _, t := range strings.Split(r.URL.Query().Get("topics"), ",") {
+		switch strings.TrimSpace(t) {
+		case "":
+		case "blocks":
+			topics[EventBlock] = true
+		case "txs":
+			topics[EventTransaction] = true
+		default:
+			writeError(w, http.StatusBadRequest, "unknown topic "+t)
+			return
+		}
+	}
+	if len(topics) == 0 {
+		topics[EventBlock] = true
+		topics[EventTransaction] = true
+	}
+
+	conn, err := e.stream.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has replied
+		return
+	}
+	sub := &subscriber{
+		conn:   conn,
+		events: make(chan *Event, e.config.StreamBuffer),
+		topics: topics,
+	}
+	if !e.stream.add(sub) {
+		conn.Close()
+		return
+	}
+	go e.stream.read(sub)
+	e.stream.write(sub)
+}
+
+// add registers a subscriber unless the stream is closed
+func (s *stream) add(sub *subscriber) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.subscribers[sub] = struct{}{}
+	return true
+}
+
+// remove unregisters a subscriber and ends its writer
+func (s *stream) remove(sub *subscriber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subscribers[sub]; ok {
+		delete(s.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// publish queues an event for its subscribers, dropping those too far
+// behind to catch up
+func (s *stream) publish(event *Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sub := range s.subscribers {
+		if !sub.topics[event.Type] {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			delete(s.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// close disconnects every subscriber and refuses new ones
+func (s *stream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for sub := range s.subscribers {
+		delete(s.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// read discards client messages, which keeps control frames flowing,
+// until the connection fails
+func (s *stream) read(sub *subscriber) {
+	defer s.remove(sub)
+	sub.conn.SetReadLimit(512)
+	sub.conn.SetReadDeadline(time.Now().Add(2 * pingInterval))
+	sub.conn.SetPongHandler(func(string) error {
+		return sub.conn.SetReadDeadline(time.Now().Add(2 * pingInterval))
+	})
+	for {
+		if _, _, err := sub.conn.NextReader(); err != nil {
+			return
+		}
+	}
+}
+
+// write sends queued events and pings until the subscriber is removed or
+// a write fails
+func (s *stream) write(sub *subscriber) {
+	ticker := time.NewTicker(pingInterval)
+	defer func() {
+		ticker.Stop()
+		sub.conn.Close()
+		s.remove(sub)
+	}()
+
+	for {
+		select {
+		case event, ok := <-sub.events:
+			sub.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if !ok {
+				sub.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+				return
+			}
+			if err := sub.conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			sub.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := sub.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,348 @@
+// Package faucet hands out test coins over HTTP on testnet.
+package faucet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ccoin/core/internal/rpc"
+	"github.com/ccoin/core/pkg/common"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Faucet errors
+var (
+	ErrRateLimited    = errors.New("faucet request rate limited")
+	ErrInvalidAddress = errors.New("invalid payment address")
+)
+
+// Network is the only network a faucet may run on
+const Network = "testnet"
+
+// RequestPath is the HTTP path coins are requested on
+const RequestPath = "/request"
+
+// Config holds faucet configuration
+type Config struct {
+	// ListenAddr is the public HTTP address (empty disables the faucet)
+	ListenAddr string
+
+	// Amount and Fee of each payment, in raw units
+	Amount uint64
+	Fee    uint64
+
+	// Minimum time between payments to one address and to one client IP
+	AddressInterval time.Duration
+	IPInterval      time.Duration
+
+	// TrustProxy takes the client IP from X-Forwarded-For, for faucets
+	// behind a reverse proxy
+	TrustProxy bool
+}
+
+// DefaultConfig returns default faucet configuration
+func DefaultConfig() *Config {
+	return &Config{
+		Amount:          10 * 1e8, // 10 CCoin
+		Fee:             1000,
+		AddressInterval: 24 * time.Hour,
+		IPInterval:      time.Hour,
+	}
+}
+
+// Sender builds and broadcasts shielded payments from the faucet account
+type Sender interface {
+	SendTransaction(ctx context.Context, req *rpc.SendTransactionRequest) (*rpc.SendTransactionResponse, error)
+}
+
+// Grant is a payment made by the faucet
+type Grant struct {
+	TxHash string `json:"tx_hash"`
+	Amount uint64 `json:"amount"`
+}
+
+// RateLimitError reports when a rate-limited requester may ask again
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%v: retry in %s", ErrRateLimited, e.RetryAfter.Round(time.Second))
+}
+
+// Is makes RateLimitError match ErrRateLimited
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// Faucet pays test coins from the node's wallet, at most once per
+// interval to each address and each client IP
+type Faucet struct {
+	mu sync.Mutex
+
+	config *Config
+	sender Sender
+
+	// Time of the last payment to each address and IP
+	byAddress map[string]time.Time
+	byIP      map[string]time.Time
+
+	server *http.Server
+}
+
+// NewFaucet creates a faucet paying through sender
+func NewFaucet(cfg *Config, sender Sender) *Faucet {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+
+	return &Faucet{
+		config:    cfg,
+		sender:    sender,
+		byAddress: make(map[string]time.Time),
+		byIP:      make(map[string]time.Time),
+	}
+}
+
+// Request pays the faucet amount to a payment address for a client IP.
+// Both are charged before the payment is built so concurrent requests
+// cannot pass the limit; a failed payment refunds them.
+func (f *Faucet) Request(ctx context.Context, address, ip string) (*Grant, error) {
+	address = strings.TrimSpace(address)
+	b, err := common.HexToBytes(address)
+	if err != nil || len(b) < types.AddressSize {
+		return nil, ErrInvalidAddress
+	}
+	// Limit by the address alone, whatever note key comes with it
+	addrKey := common.BytesToHex(b[:types.AddressSize])
+
+	now := time.Now()
+	f.mu.Lock()
+	f.pruneLocked(now)
+	wait := f.waitLocked(f.byAddress[addrKey], f.config.AddressInterval, now)
+	if w := f.waitLocked(f.byIP[ip], f.config.IPInterval, now); w > wait {
+		wait = w
+	}
+	if wait > 0 {
+		f.mu.Unlock()
+		return nil, &RateLimitError{RetryAfter: wait}
+	}
+	prevAddr, hadAddr := f.byAddress[addrKey]
+	prevIP, hadIP := f.byIP[ip]
+	f.byAddress[addrKey] = now
+	f.byIP[ip] = now
+	f.mu.Unlock()
+
+	resp, err := f.sender.SendTransaction(ctx, &rpc.SendTransactionRequest{
+		To:     address,
+		Amount: f.config.Amount,
+		Fee:    f.config.Fee,
+		Memo:   "ccoin testnet faucet",
+	})
+	if err != nil {
+		f.mu.Lock()
+		restore(f.byAddress, addrKey, prevAddr, hadAddr, now)
+		restore(f.byIP, ip, prevIP, hadIP, now)
+		f.mu.Unlock()
+		if status.Code(err) == codes.InvalidArgument {
+			return nil, ErrInvalidAddress
+		}
+		return nil, err
+	}
+
+	return &Grant{TxHash: resp.TxHash, Amount: f.config.Amount}, nil
+}
+
+// waitLocked returns how long until a requester last paid at last may
+// be paid again
+func (f *Faucet) waitLocked(last time.Time, interval time.Duration, now time.Time) time.Duration {
+	if last.IsZero() {
+		return 0
+	}
+	if wait := last.Add(interval).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// pruneLocked forgets requesters whose interval has passed
+func (f *Faucet) pruneLocked(now time.Time) {
+	for addr, last := range f.byAddress {
+		if now.Sub(last) >= f.config.AddressInterval {
+			delete(f.byAddress, addr)
+		}
+	}
+	for ip, last := range f.byIP {
+		if now.Sub(last) >= f.config.IPInterval {
+			delete(f.byIP, ip)
+		}
+	}
+}
+
+// restore undoes a charge made at now unless a later one replaced it
+func restore(m map[string]time.Time, key string, prev time.Time, had bool, now time.Time) {
+	if !m[key].Equal(now) {
+		return
+	}
+	if had {
+		m[key] = prev
+	} else {
+		delete(m, key)
+	}
+}
+
+// requestBody is the JSON body of a coin request
+type requestBody struct {
+	Address string `json:"address"`
+}
+
+// errorBody is the JSON body of a failed request
+type errorBody struct {
+	Error      string `json:"error"`
+	RetryAfter int64  `json:"retry_after,omitempty"`
+}
+
+// Handler returns the faucet HTTP handler
+func (f *Faucet) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(RequestPath, f.handleRequest)
+	return mux
+}
+
+// handleRequest pays the address in the JSON body
+func (f *Faucet) handleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSON(w, http.StatusMethodNotAllowed, &errorBody{Error: "method not allowed"})
+		return
+	}
+
+	var body requestBody
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, &errorBody{Error: "invalid request body"})
+		return
+	}
+
+	grant, err := f.Request(r.Context(), body.Address, f.clientIP(r))
+	var limited *RateLimitError
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, grant)
+	case errors.As(err, &limited):
+		seconds := int64(limited.RetryAfter.Round(time.Second) / time.Second)
+		w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+		writeJSON(w, http.StatusTooManyRequests, &errorBody{Error: ErrRateLimited.Error(), RetryAfter: seconds})
+	case errors.Is(err, ErrInvalidAddress):
+		writeJSON(w, http.StatusBadRequest, &errorBody{Error: err.Error()})
+	default:
+		fmt.Printf("Warning: faucet payment failed: %v\n", err)
+		writeJSON(w, http.StatusServiceUnavailable, &errorBody{Error: "faucet unavailable"})
+	}
+}
+
+// clientIP returns the requesting client's IP
+func (f *Faucet) clientIP(r *http.Request) string {
+	if f.config.TrustProxy {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			return strings.TrimSpace(strings.Split(fwd, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Start starts the faucet HTTP server
+func (f *Faucet) Start() error {
+	if f.config.ListenAddr == "" {
+		return nil
+	}
+
+	lis, err := net.Listen("tcp", f.config.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", f.config.ListenAddr, err)
+	}
+
+	f.mu.Lock()
+	f.server = &http.Server{Handler: f.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	server := f.server
+	f.mu.Unlock()
+
+	go func() {
+		if err := server.Serve(lis); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("Faucet server error: %v\n", err)
+		}
+	}()
+
+	return nil
+}
+
+// Stop stops the faucet HTTP server
+func (f *Faucet) Stop() {
+	f.mu.Lock()
+	server := f.server
+	f.mu.Unlock()
+
+	if server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}
+}
+
+// RequestCoins asks the faucet at baseURL to pay address
+func RequestCoins(ctx context.Context, baseURL, address string) (*Grant, error) {
+	data, err := json.Marshal(&requestBody{Address: address})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(baseURL, "/")+RequestPath, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var body errorBody
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Error == "" {
+			return nil, fmt.Errorf("faucet returned %s", resp.Status)
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, &RateLimitError{RetryAfter: time.Duration(body.RetryAfter) * time.Second}
+		}
+		return nil, errors.New(body.Error)
+	}
+
+	var grant Grant
+	if err := json.NewDecoder(resp.Body).Decode(&grant); err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
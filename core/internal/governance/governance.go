@@ -2,11 +2,14 @@
 package governance
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"errors"
+	"sort"
 	"sync"
 
+	"github.com/ccoin/core/internal/params"
 	"github.com/ccoin/core/pkg/types"
 )
 
@@ -30,6 +33,10 @@ type GovernanceManager struct {
 	// Vote records
 	votes map[types.Hash]map[types.Address]*Vote
 
+	// Per-address history indexes, in submission order
+	byVoter    map[types.Address][]*Vote
+	byProposer map[types.Address][]types.Hash
+
 	// Proposal queue for execution
 	executionQueue []*types.Proposal
 
@@ -38,6 +45,9 @@ type GovernanceManager struct {
 
 	// Storage
 	store GovernanceStore
+
+	// Consensus parameters proposals are checked against (optional)
+	params *params.Registry
 }
 
 // Vote represents a vote on a proposal
@@ -69,6 +79,10 @@ type GovernanceConfig struct {
 
 	// Proposal type specific thresholds
 	Thresholds map[types.ProposalType]ThresholdConfig
+
+	// SimulationEpochs is how many epochs economic parameter proposals
+	// are simulated for when they don't say
+	SimulationEpochs int
 }
 
 // ThresholdConfig holds threshold settings for a proposal type
@@ -85,20 +99,21 @@ func DefaultGovernanceConfig() *GovernanceConfig {
 		ExecutionDelay:   1000,  // ~2.7 hours
 		DefaultQuorum:    0.2,   // 20%
 		DefaultThreshold: 0.5,   // 50%
+		SimulationEpochs: 30,
 		Thresholds: map[types.ProposalType]ThresholdConfig{
-			types.ProposalTypeModelArchitecture: {
+			types.ProposalNewModel: {
 				QuorumRequired:    0.3,
 				ApprovalThreshold: 0.6,
 			},
-			types.ProposalTypeParameterChange: {
+			types.ProposalParameterAdjust: {
 				QuorumRequired:    0.2,
 				ApprovalThreshold: 0.5,
 			},
-			types.ProposalTypeFundingAllocation: {
+			types.ProposalTreasurySpend: {
 				QuorumRequired:    0.25,
 				ApprovalThreshold: 0.6,
 			},
-			types.ProposalTypeEmergencyAction: {
+			types.ProposalProtocolUpgrade: {
 				QuorumRequired:    0.4,
 				ApprovalThreshold: 0.75,
 			},
@@ -123,12 +138,22 @@ func NewGovernanceManager(store GovernanceStore, config *GovernanceConfig) *Gove
 	return &GovernanceManager{
 		proposals:      make(map[types.Hash]*types.Proposal),
 		votes:          make(map[types.Hash]map[types.Address]*Vote),
+		byVoter:        make(map[types.Address][]*Vote),
+		byProposer:     make(map[types.Address][]types.Hash),
 		executionQueue: make([]*types.Proposal, 0),
 		config:         config,
 		store:          store,
 	}
 }
 
+// SetParams sets the consensus parameter registry, so parameter proposals
+// outside their bounds or activating before voting ends are refused
+func (gm *GovernanceManager) SetParams(r *params.Registry) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	gm.params = r
+}
+
 // CreateProposal creates a new governance proposal
 func (gm *GovernanceManager) CreateProposal(
 	ctx context.Context,
@@ -141,6 +166,17 @@ func (gm *GovernanceManager) CreateProposal(
 	gm.mu.Lock()
 	defer gm.mu.Unlock()
 
+	if econ, ok := data.(*types.EconomicParameterData); ok {
+		if err := gm.attachSimulationLocked(econ, currentBlock); err != nil {
+			return nil, err
+		}
+	}
+	if cp, ok := data.(*types.ConsensusParameterData); ok && gm.params != nil {
+		if err := gm.params.Validate(cp, currentBlock+gm.config.VotingPeriod); err != nil {
+			return nil, err
+		}
+	}
+
 	// Get thresholds for proposal type
 	threshold, exists := gm.config.Thresholds[proposalType]
 	if !exists {
@@ -170,6 +206,7 @@ func (gm *GovernanceManager) CreateProposal(
 
 	gm.proposals[proposal.ProposalID] = proposal
 	gm.votes[proposal.ProposalID] = make(map[types.Address]*Vote)
+	gm.indexProposalLocked(proposal)
 
 	return proposal, gm.store.SaveProposal(ctx, proposal)
 }
@@ -225,6 +262,7 @@ func (gm *GovernanceManager) CastVote(
 	}
 
 	gm.votes[proposalID][voter] = vote
+	gm.indexVoteLocked(vote)
 
 	// Update proposal tallies
 	if support {
@@ -264,6 +302,12 @@ func (gm *GovernanceManager) FinalizeProposal(
 		return errors.New("proposal already finalized")
 	}
 
+	return gm.finalizeLocked(ctx, proposal, totalStake)
+}
+
+// finalizeLocked tallies a proposal whose voting has ended against
+// totalStake and queues it for execution if it passed
+func (gm *GovernanceManager) finalizeLocked(ctx context.Context, proposal *types.Proposal, totalStake uint64) error {
 	// Calculate results
 	totalVotes := proposal.VotesFor + proposal.VotesAgainst
 	quorumReached := float64(totalVotes) >= float64(totalStake)*proposal.QuorumRequired
@@ -286,7 +330,9 @@ func (gm *GovernanceManager) FinalizeProposal(
 	return gm.store.SaveProposal(ctx, proposal)
 }
 
-// ExecuteProposal executes an approved proposal
+// ExecuteProposal executes an approved proposal once its timelock has
+// expired. Like ProcessBlock, it records the execution at the timelock's
+// end rather than at currentBlock.
 func (gm *GovernanceManager) ExecuteProposal(
 	ctx context.Context,
 	proposalID types.Hash,
@@ -305,48 +351,136 @@ func (gm *GovernanceManager) ExecuteProposal(
 	}
 
 	// Check timelock
-	timelockEnd := proposal.VotingEndBlock + gm.config.ExecutionDelay
-	if currentBlock < timelockEnd {
+	if currentBlock < gm.timelockEnd(proposal) {
 		return errors.New("timelock not expired")
 	}
 
+	if err := gm.checkSimulationLocked(proposal); err != nil {
+		return err
+	}
+
+	return gm.executeLocked(ctx, proposal)
+}
+
+// executeLocked carries out a passed proposal and removes it from the
+// execution queue
+func (gm *GovernanceManager) executeLocked(ctx context.Context, proposal *types.Proposal) error {
 	// Execute based on proposal type
 	if err := gm.executeProposalAction(ctx, proposal); err != nil {
 		return err
 	}
 
 	proposal.Status = types.ProposalStatusExecuted
-	proposal.ExecutedAt = currentBlock
+	proposal.ExecutedAt = gm.timelockEnd(proposal)
+	gm.dequeueLocked(proposal.ProposalID)
+
+	return gm.store.SaveProposal(ctx, proposal)
+}
+
+// ProcessBlock applies the governance outcomes due at height, as part of
+// applying the block: proposals whose voting ended before height are
+// finalized against totalStake, the stake at height, and passed proposals
+// whose timelock has expired are executed. Proposals are handled in
+// deadline order, then by ID, and executions are recorded at their
+// timelock's end, so a node replaying the chain from genesis reaches the
+// same governance state as one that followed it live. A passed economic
+// proposal whose simulation no longer matches is cancelled. It returns the
+// proposals executed.
+func (gm *GovernanceManager) ProcessBlock(ctx context.Context, height, totalStake uint64) ([]*types.Proposal, error) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	var ended []*types.Proposal
+	for _, p := range gm.proposals {
+		if p.Status == types.ProposalStatusActive && height > p.VotingEndBlock {
+			ended = append(ended, p)
+		}
+	}
+	sortByDeadline(ended, func(p *types.Proposal) uint64 { return p.VotingEndBlock })
+	for _, p := range ended {
+		if err := gm.finalizeLocked(ctx, p, totalStake); err != nil {
+			return nil, err
+		}
+	}
+
+	var due []*types.Proposal
+	for _, p := range gm.executionQueue {
+		if height >= gm.timelockEnd(p) {
+			due = append(due, p)
+		}
+	}
+	sortByDeadline(due, gm.timelockEnd)
+
+	var executed []*types.Proposal
+	for _, p := range due {
+		if err := gm.checkSimulationLocked(p); err != nil {
+			p.Status = types.ProposalStatusCancelled
+			gm.dequeueLocked(p.ProposalID)
+			if err := gm.store.SaveProposal(ctx, p); err != nil {
+				return executed, err
+			}
+			continue
+		}
+		if err := gm.executeLocked(ctx, p); err != nil {
+			return executed, err
+		}
+		executed = append(executed, p)
+	}
+	return executed, nil
+}
 
-	// Remove from queue
+// timelockEnd returns the first height a passed proposal can execute at
+func (gm *GovernanceManager) timelockEnd(proposal *types.Proposal) uint64 {
+	return proposal.VotingEndBlock + gm.config.ExecutionDelay
+}
+
+// dequeueLocked removes a proposal from the execution queue
+func (gm *GovernanceManager) dequeueLocked(proposalID types.Hash) {
 	for i, p := range gm.executionQueue {
 		if p.ProposalID == proposalID {
 			gm.executionQueue = append(gm.executionQueue[:i], gm.executionQueue[i+1:]...)
-			break
+			return
 		}
 	}
+}
 
-	return gm.store.SaveProposal(ctx, proposal)
+// sortByDeadline orders proposals by deadline, breaking ties by ID
+func sortByDeadline(proposals []*types.Proposal, deadline func(*types.Proposal) uint64) {
+	sort.Slice(proposals, func(i, j int) bool {
+		di, dj := deadline(proposals[i]), deadline(proposals[j])
+		if di != dj {
+			return di < dj
+		}
+		return bytes.Compare(proposals[i].ProposalID[:], proposals[j].ProposalID[:]) < 0
+	})
 }
 
 // executeProposalAction executes the action for a proposal
 func (gm *GovernanceManager) executeProposalAction(ctx context.Context, proposal *types.Proposal) error {
 	switch proposal.Type {
-	case types.ProposalTypeModelArchitecture:
-		// Execute model architecture change
-		// This would call into the model registry
+	case types.ProposalParameterAdjust:
+		// Consensus parameter changes are scheduled for their activation
+		// height
+		if _, ok := proposal.Data.(*types.ConsensusParameterData); ok && gm.params != nil {
+			return gm.params.ApplyProposal(ctx, proposal)
+		}
 		return nil
 
-	case types.ProposalTypeParameterChange:
-		// Execute parameter change
+	case types.ProposalNewModel, types.ProposalTaskPriority, types.ProposalLicenseChange,
+		types.ProposalTreasurySpend, types.ProposalProtocolUpgrade, types.ProposalBondForfeiture:
+		// Returned to the caller of ProcessBlock to carry out
 		return nil
 
-	case types.ProposalTypeFundingAllocation:
-		// Execute funding allocation
+	case types.ProposalEvaluatorAdmission:
+		// Applied by the evaluator registry once the proposal passes
 		return nil
 
-	case types.ProposalTypeEmergencyAction:
-		// Execute emergency action
+	case types.ProposalSanctionsUpdate:
+		// Applied by the sanctions registry once the proposal passes
+		return nil
+
+	case types.ProposalIdentityAuthority, types.ProposalCredentialRevocation:
+		// Applied by the credential registry once the proposal passes
 		return nil
 
 	default:
@@ -375,6 +509,26 @@ func (gm *GovernanceManager) GetActiveProposals() []*types.Proposal {
 	return active
 }
 
+// Proposals returns copies of every proposal, oldest voting start first.
+// Their type-specific data is shared, not copied.
+func (gm *GovernanceManager) Proposals() []*types.Proposal {
+	gm.mu.RLock()
+	defer gm.mu.RUnlock()
+
+	out := make([]*types.Proposal, 0, len(gm.proposals))
+	for _, p := range gm.proposals {
+		copied := *p
+		out = append(out, &copied)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].VotingStartBlock != out[j].VotingStartBlock {
+			return out[i].VotingStartBlock < out[j].VotingStartBlock
+		}
+		return bytes.Compare(out[i].ProposalID[:], out[j].ProposalID[:]) < 0
+	})
+	return out
+}
+
 // GetVoteCount returns the vote count for a proposal
 func (gm *GovernanceManager) GetVoteCount(proposalID types.Hash) (uint64, uint64) {
 	gm.mu.RLock()
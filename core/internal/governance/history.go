@@ -0,0 +1,74 @@
+package governance
+
+import (
+	"github.com/ccoin/core/pkg/types"
+)
+
+// VoteRecord is a vote together with the current state of its proposal
+type VoteRecord struct {
+	Vote    *Vote
+	Title   string
+	Type    types.ProposalType
+	Outcome types.ProposalStatus
+}
+
+// Decided reports whether the proposal has been finalized
+func (r *VoteRecord) Decided() bool {
+	return r.Outcome != types.ProposalStatusActive
+}
+
+// WithOutcome reports whether the vote agreed with the final outcome of
+// a decided proposal
+func (r *VoteRecord) WithOutcome() bool {
+	if !r.Decided() || r.Outcome == types.ProposalStatusCancelled {
+		return false
+	}
+	passed := r.Outcome == types.ProposalStatusPassed || r.Outcome == types.ProposalStatusExecuted
+	return r.Vote.Support == passed
+}
+
+// indexProposalLocked records a new proposal under its proposer
+func (gm *GovernanceManager) indexProposalLocked(proposal *types.Proposal) {
+	gm.byProposer[proposal.ProposerAddress] = append(gm.byProposer[proposal.ProposerAddress], proposal.ProposalID)
+}
+
+// indexVoteLocked records a vote under its voter
+func (gm *GovernanceManager) indexVoteLocked(vote *Vote) {
+	gm.byVoter[vote.VoterAddress] = append(gm.byVoter[vote.VoterAddress], vote)
+}
+
+// VoterHistory returns every vote cast by voter, oldest first, with the
+// current outcome of each proposal
+func (gm *GovernanceManager) VoterHistory(voter types.Address) []*VoteRecord {
+	gm.mu.RLock()
+	defer gm.mu.RUnlock()
+
+	votes := gm.byVoter[voter]
+	records := make([]*VoteRecord, 0, len(votes))
+	for _, vote := range votes {
+		record := &VoteRecord{Vote: vote}
+		if proposal, exists := gm.proposals[vote.ProposalID]; exists {
+			record.Title = proposal.Title
+			record.Type = proposal.Type
+			record.Outcome = proposal.Status
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// ProposerHistory returns every proposal submitted by proposer, oldest
+// first
+func (gm *GovernanceManager) ProposerHistory(proposer types.Address) []*types.Proposal {
+	gm.mu.RLock()
+	defer gm.mu.RUnlock()
+
+	ids := gm.byProposer[proposer]
+	proposals := make([]*types.Proposal, 0, len(ids))
+	for _, id := range ids {
+		if proposal, exists := gm.proposals[id]; exists {
+			proposals = append(proposals, proposal)
+		}
+	}
+	return proposals
+}
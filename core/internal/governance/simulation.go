@@ -0,0 +1,96 @@
+package governance
+
+import (
+	"errors"
+
+	"github.com/ccoin/core/internal/economics"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Simulation errors
+var (
+	ErrSimulationMismatch  = errors.New("economic simulation does not match the proposal")
+	ErrNotEconomicProposal = errors.New("proposal does not change economic parameters")
+)
+
+// PreviewEconomicChange simulates the epochs after data would take effect,
+// filling in the start and length a proposal created at currentBlock
+// would use. The returned hash is what CreateProposal attaches.
+func (gm *GovernanceManager) PreviewEconomicChange(data *types.EconomicParameterData, currentBlock uint64) (*economics.Simulation, error) {
+	gm.mu.RLock()
+	defer gm.mu.RUnlock()
+	return gm.simulateLocked(data, currentBlock)
+}
+
+// ProposalSimulation re-runs the simulation attached to an economic
+// parameter proposal; its hash matches the attached one unless the
+// simulation rules changed since the proposal was made
+func (gm *GovernanceManager) ProposalSimulation(proposalID types.Hash) (*economics.Simulation, *types.EconomicParameterData, error) {
+	gm.mu.RLock()
+	defer gm.mu.RUnlock()
+
+	proposal, exists := gm.proposals[proposalID]
+	if !exists {
+		return nil, nil, ErrProposalNotFound
+	}
+	data, ok := proposal.Data.(*types.EconomicParameterData)
+	if !ok {
+		return nil, nil, ErrNotEconomicProposal
+	}
+	sim, err := gm.simulateLocked(data, proposal.VotingStartBlock)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sim, data, nil
+}
+
+// simulateLocked runs the simulation for data. A proposal's changes take
+// effect once voting and the timelock are over, so that is where the
+// simulation starts unless data names a start.
+func (gm *GovernanceManager) simulateLocked(data *types.EconomicParameterData, currentBlock uint64) (*economics.Simulation, error) {
+	cfg := economics.SimulationConfig{
+		StartHeight:  data.SimulationStart,
+		Epochs:       int(data.SimulationEpochs),
+		FeesPerBlock: data.FeesPerBlock,
+	}
+	if cfg.StartHeight == 0 {
+		cfg.StartHeight = currentBlock + gm.config.VotingPeriod + gm.config.ExecutionDelay
+	}
+	if cfg.Epochs == 0 {
+		cfg.Epochs = gm.config.SimulationEpochs
+	}
+	return economics.Simulate(economics.EconomicParamsFromProposal(data), cfg)
+}
+
+// attachSimulationLocked simulates a new economic proposal and records the
+// simulation on it. A hash supplied by the proposer must match.
+func (gm *GovernanceManager) attachSimulationLocked(data *types.EconomicParameterData, currentBlock uint64) error {
+	sim, err := gm.simulateLocked(data, currentBlock)
+	if err != nil {
+		return err
+	}
+	if data.SimulationHash != (types.Hash{}) && data.SimulationHash != sim.Hash {
+		return ErrSimulationMismatch
+	}
+	data.SimulationStart = sim.Config.StartHeight
+	data.SimulationEpochs = uint32(sim.Config.Epochs)
+	data.SimulationHash = sim.Hash
+	return nil
+}
+
+// checkSimulationLocked verifies before execution that an economic
+// proposal still carries the simulation voters were shown
+func (gm *GovernanceManager) checkSimulationLocked(proposal *types.Proposal) error {
+	data, ok := proposal.Data.(*types.EconomicParameterData)
+	if !ok {
+		return nil
+	}
+	sim, err := gm.simulateLocked(data, proposal.VotingStartBlock)
+	if err != nil {
+		return err
+	}
+	if sim.Hash != data.SimulationHash {
+		return ErrSimulationMismatch
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package history
+
+import (
+	"github.com/ccoin/core/internal/reputation"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Checkpoint is the stake, delegation and governance state as of a main
+// chain block. It serves the same lookups as the live managers.
+type Checkpoint struct {
+	Height uint64
+	Hash   types.Hash
+
+	// Stakes ordered by miner, delegations by miner then delegator
+	Stakes         []*reputation.StakeInfo
+	AllDelegations []*reputation.Delegation
+
+	// Proposals without their type-specific data, oldest voting start
+	// first
+	Proposals []*types.Proposal
+}
+
+// GetStakeInfo returns a miner's stake, nil if it had none
+func (c *Checkpoint) GetStakeInfo(addr types.Address) *reputation.StakeInfo {
+	for _, s := range c.Stakes {
+		if s.Address == addr {
+			return s
+		}
+	}
+	return nil
+}
+
+// DelegatedStake returns the stake bonded to a miner by delegators
+func (c *Checkpoint) DelegatedStake(miner types.Address) uint64 {
+	var total uint64
+	for _, d := range c.AllDelegations {
+		if d.Miner == miner {
+			total += d.Bonded
+		}
+	}
+	return total
+}
+
+// Delegations returns the delegations to a miner, ordered by delegator
+func (c *Checkpoint) Delegations(miner types.Address) []*reputation.Delegation {
+	var out []*reputation.Delegation
+	for _, d := range c.AllDelegations {
+		if d.Miner == miner {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// DelegationsBy returns a delegator's delegations, ordered by miner
+func (c *Checkpoint) DelegationsBy(delegator types.Address) []*reputation.Delegation {
+	var out []*reputation.Delegation
+	for _, d := range c.AllDelegations {
+		if d.Delegator == delegator {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// GetProposal returns a proposal by ID, nil if it had not been made
+func (c *Checkpoint) GetProposal(proposalID types.Hash) *types.Proposal {
+	for _, p := range c.Proposals {
+		if p.ProposalID == proposalID {
+			return p
+		}
+	}
+	return nil
+}
+
+// GetActiveProposals returns the proposals open for voting
+func (c *Checkpoint) GetActiveProposals() []*types.Proposal {
+	active := make([]*types.Proposal, 0)
+	for _, p := range c.Proposals {
+		if p.Status == types.ProposalStatusActive {
+			active = append(active, p)
+		}
+	}
+	return active
+}
+
+// ProposerHistory returns every proposal submitted by proposer
+func (c *Checkpoint) ProposerHistory(proposer types.Address) []*types.Proposal {
+	var out []*types.Proposal
+	for _, p := range c.Proposals {
+		if p.ProposerAddress == proposer {
+			out = append(out, p)
+		}
+	}
+	return out
+}
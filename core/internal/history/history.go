@@ -0,0 +1,318 @@
+// Package history answers queries about the chain as of a past main chain
+// height, for archive nodes.
+//
+// The state headers commit to (the nullifier and commitment roots, the
+// supply and treasury and each miner's account) is recomputed exactly for
+// any height by the state manager, in which the index pins a state every
+// Interval heights so no query replays more than Interval blocks. Stakes,
+// delegations and governance proposals are kept outside the state tree,
+// so the index records a checkpoint of them every Interval heights;
+// queries for those are answered as of the latest checkpoint at or below
+// the height asked for.
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ccoin/core/internal/reputation"
+	"github.com/ccoin/core/internal/state"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// History errors
+var (
+	ErrHeightAhead  = errors.New("height is above the main chain")
+	ErrNoCheckpoint = errors.New("no checkpoint at or below height")
+)
+
+// DefaultInterval is the default number of heights between checkpoints
+const DefaultInterval = 100
+
+// Config holds history index configuration
+type Config struct {
+	// Dir is where checkpoints are written
+	Dir string
+
+	// Interval is the number of heights between checkpoints
+	Interval uint64
+}
+
+// DefaultConfig returns default history index configuration
+func DefaultConfig() *Config {
+	return &Config{Interval: DefaultInterval}
+}
+
+// StateSource computes and pins the state after main chain blocks
+type StateSource interface {
+	State(ctx context.Context, hash types.Hash) (*state.State, error)
+	Pin(ctx context.Context, hash types.Hash) error
+}
+
+// StakeSource serves the stakes and delegations checkpoints record
+type StakeSource interface {
+	Stakes() []*reputation.StakeInfo
+	AllDelegations() []*reputation.Delegation
+}
+
+// ProposalSource serves the governance proposals checkpoints record
+type ProposalSource interface {
+	Proposals() []*types.Proposal
+}
+
+// Index records checkpoints along the main chain and serves state as of
+// past heights
+type Index struct {
+	mu sync.Mutex
+
+	config *Config
+	chain  state.MainChain
+	states StateSource
+
+	stakes    StakeSource
+	proposals ProposalSource
+
+	// Heights of the checkpoints on disk, ascending
+	heights []uint64
+
+	// Heights whose state has been pinned since startup
+	pinned map[uint64]bool
+
+	// Latest checkpoint read or written
+	latest *Checkpoint
+}
+
+// NewIndex creates an index over the main chain and the states of its
+// blocks, loading the checkpoints already written
+func NewIndex(cfg *Config, chain state.MainChain, states StateSource) (*Index, error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	if cfg.Interval == 0 {
+		return nil, errors.New("history interval must be positive")
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	a := &Index{
+		config: cfg,
+		chain:  chain,
+		states: states,
+		pinned: make(map[uint64]bool),
+	}
+	heights, err := a.readHeights()
+	if err != nil {
+		return nil, err
+	}
+	a.heights = heights
+	return a, nil
+}
+
+// SetStakes sets where checkpoints read stakes and delegations from
+func (a *Index) SetStakes(stakes StakeSource) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stakes = stakes
+}
+
+// SetProposals sets where checkpoints read governance proposals from
+func (a *Index) SetProposals(proposals ProposalSource) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.proposals = proposals
+}
+
+// Update records a checkpoint at the chain height once the chain has
+// entered a new interval since the last one. It is called as blocks are
+// added, after stakes and governance have applied them.
+func (a *Index) Update(ctx context.Context) error {
+	height := a.chain.GetHeight()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if n := len(a.heights); n > 0 && height/a.config.Interval <= a.heights[n-1]/a.config.Interval {
+		return nil
+	}
+
+	header, err := a.header(ctx, height)
+	if err != nil {
+		return err
+	}
+	cp := &Checkpoint{Height: height, Hash: header.Hash}
+	if a.stakes != nil {
+		cp.Stakes = a.stakes.Stakes()
+		cp.AllDelegations = a.stakes.AllDelegations()
+	}
+	if a.proposals != nil {
+		for _, p := range a.proposals.Proposals() {
+			// Type-specific data is not recorded
+			p.Data = nil
+			cp.Proposals = append(cp.Proposals, p)
+		}
+	}
+	if err := a.saveLocked(cp); err != nil {
+		return err
+	}
+
+	// Later states are rebuilt from this one
+	if err := a.states.Pin(ctx, header.Hash); err != nil {
+		return err
+	}
+	a.pinned[height] = true
+	return nil
+}
+
+// Header returns the main chain header at height
+func (a *Index) Header(ctx context.Context, height uint64) (*types.BlockHeader, error) {
+	return a.header(ctx, height)
+}
+
+// State returns the main chain header at height and the state after it
+func (a *Index) State(ctx context.Context, height uint64) (*types.BlockHeader, *state.State, error) {
+	header, err := a.header(ctx, height)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := a.pinUpTo(ctx, height); err != nil {
+		return nil, nil, err
+	}
+	st, err := a.states.State(ctx, header.Hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	return header, st, nil
+}
+
+// Checkpoint returns the latest checkpoint at or below height
+func (a *Index) Checkpoint(height uint64) (*Checkpoint, error) {
+	if height > a.chain.GetHeight() {
+		return nil, ErrHeightAhead
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	i := sort.Search(len(a.heights), func(i int) bool { return a.heights[i] > height })
+	if i == 0 {
+		return nil, ErrNoCheckpoint
+	}
+	at := a.heights[i-1]
+	if a.latest != nil && a.latest.Height == at {
+		return a.latest, nil
+	}
+	cp, err := a.readLocked(at)
+	if err != nil {
+		return nil, err
+	}
+	a.latest = cp
+	return cp, nil
+}
+
+// header returns the main chain header at height
+func (a *Index) header(ctx context.Context, height uint64) (*types.BlockHeader, error) {
+	if height > a.chain.GetHeight() {
+		return nil, ErrHeightAhead
+	}
+	headers, err := a.chain.GetMainChain(ctx, height, height)
+	if err != nil {
+		return nil, err
+	}
+	if len(headers) == 0 {
+		return nil, fmt.Errorf("no main chain block at height %d", height)
+	}
+	return headers[0], nil
+}
+
+// pinUpTo pins the states at checkpoints up to height not pinned since
+// startup, oldest first, so each is rebuilt from the one before
+func (a *Index) pinUpTo(ctx context.Context, height uint64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, at := range a.heights {
+		if at > height {
+			break
+		}
+		if a.pinned[at] {
+			continue
+		}
+		header, err := a.header(ctx, at)
+		if err != nil {
+			return err
+		}
+		if err := a.states.Pin(ctx, header.Hash); err != nil {
+			return err
+		}
+		a.pinned[at] = true
+	}
+	return nil
+}
+
+// saveLocked writes a checkpoint
+func (a *Index) saveLocked(cp *Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	path := a.path(cp.Height)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	a.heights = append(a.heights, cp.Height)
+	a.latest = cp
+	return nil
+}
+
+// readLocked reads the checkpoint at height
+func (a *Index) readLocked(height uint64) (*Checkpoint, error) {
+	data, err := os.ReadFile(a.path(height))
+	if err != nil {
+		return nil, err
+	}
+	cp := &Checkpoint{}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("checkpoint %d: %w", height, err)
+	}
+	return cp, nil
+}
+
+// readHeights returns the heights of the checkpoints on disk, ascending
+func (a *Index) readHeights() ([]uint64, error) {
+	entries, err := os.ReadDir(a.config.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var heights []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		height, err := strconv.ParseUint(strings.TrimSuffix(name, ".json"), 10, 64)
+		if err != nil {
+			continue
+		}
+		heights = append(heights, height)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	return heights, nil
+}
+
+// path returns the file of the checkpoint at height
+func (a *Index) path(height uint64) string {
+	return filepath.Join(a.config.Dir, fmt.Sprintf("%d.json", height))
+}
@@ -0,0 +1,216 @@
+package ipfs
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// CAR errors
+var (
+	ErrTruncatedCAR    = errors.New("CAR stream ended before the DAG was complete")
+	ErrUnexpectedBlock = errors.New("CAR block out of DFS order")
+	ErrBlockTooLarge   = errors.New("CAR block exceeds size limit")
+	ErrInvalidNode     = errors.New("invalid dag-pb node")
+	ErrNotAFile        = errors.New("UnixFS node is not a file")
+)
+
+// MaxBlockSize bounds a single CAR section. Gateways and bitswap cap
+// blocks well below this.
+const MaxBlockSize = 4 << 20
+
+// UnixFS data types accepted as file content
+const (
+	unixfsRaw  = 0
+	unixfsFile = 2
+)
+
+// ExtractCAR reads a CARv1 stream whose blocks are in depth-first order
+// with duplicates included, checks every block against the DAG rooted at
+// root and writes the reassembled file to w. Content is only written after
+// the block carrying it has been verified.
+func ExtractCAR(r io.Reader, root CID, w io.Writer) (int64, error) {
+	br := bufio.NewReader(r)
+
+	// The dag-cbor header only repeats the roots; the caller already
+	// knows which root it asked for
+	headerLen, err := binary.ReadUvarint(br)
+	if err != nil {
+		return 0, ErrTruncatedCAR
+	}
+	if headerLen > MaxBlockSize {
+		return 0, ErrBlockTooLarge
+	}
+	if _, err := br.Discard(int(headerLen)); err != nil {
+		return 0, ErrTruncatedCAR
+	}
+
+	var written int64
+	pending := []CID{root}
+	for len(pending) > 0 {
+		c, block, err := readSection(br)
+		if err != nil {
+			return written, err
+		}
+
+		expected := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if !c.Equal(expected) {
+			return written, ErrUnexpectedBlock
+		}
+		if err := c.Verify(block); err != nil {
+			return written, err
+		}
+
+		var content []byte
+		switch c.Codec {
+		case CodecRaw:
+			content = block
+		case CodecDagPB:
+			links, data, err := decodeFileNode(block)
+			if err != nil {
+				return written, err
+			}
+			content = data
+			for i := len(links) - 1; i >= 0; i-- {
+				pending = append(pending, links[i])
+			}
+		default:
+			return written, ErrUnsupportedCID
+		}
+
+		n, err := w.Write(content)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// readSection reads one length-prefixed CID and block
+func readSection(br *bufio.Reader) (CID, []byte, error) {
+	size, err := binary.ReadUvarint(br)
+	if err != nil {
+		return CID{}, nil, ErrTruncatedCAR
+	}
+	if size > MaxBlockSize {
+		return CID{}, nil, ErrBlockTooLarge
+	}
+
+	section := make([]byte, size)
+	if _, err := io.ReadFull(br, section); err != nil {
+		return CID{}, nil, ErrTruncatedCAR
+	}
+
+	c, n, err := decodeCID(section)
+	if err != nil {
+		return CID{}, nil, err
+	}
+	return c, section[n:], nil
+}
+
+// decodeFileNode decodes a dag-pb UnixFS file node into its child links
+// and the content it carries inline
+func decodeFileNode(block []byte) ([]CID, []byte, error) {
+	var links []CID
+	var unixfs []byte
+	err := protoFields(block, func(field int, value []byte) error {
+		switch field {
+		case 1: // Data
+			unixfs = value
+		case 2: // Links
+			return protoFields(value, func(field int, value []byte) error {
+				if field != 1 { // Hash
+					return nil
+				}
+				c, n, err := decodeCID(value)
+				if err != nil {
+					return err
+				}
+				if n != len(value) {
+					return ErrInvalidNode
+				}
+				links = append(links, c)
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	kind := uint64(unixfsRaw)
+	var content []byte
+	err = protoFields(unixfs, func(field int, value []byte) error {
+		switch field {
+		case 1: // Type
+			v, n := binary.Uvarint(value)
+			if n <= 0 {
+				return ErrInvalidNode
+			}
+			kind = v
+		case 2: // Data
+			content = value
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if unixfs == nil || (kind != unixfsFile && kind != unixfsRaw) {
+		return nil, nil, ErrNotAFile
+	}
+
+	return links, content, nil
+}
+
+// protoFields walks the fields of a protobuf message. Varint values are
+// passed in their encoded form; fixed-width fields are skipped.
+func protoFields(b []byte, fn func(field int, value []byte) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return ErrInvalidNode
+		}
+		b = b[n:]
+
+		var value []byte
+		switch key & 7 {
+		case 0: // varint
+			_, n := binary.Uvarint(b)
+			if n <= 0 {
+				return ErrInvalidNode
+			}
+			value, b = b[:n], b[n:]
+		case 1: // 64-bit
+			if len(b) < 8 {
+				return ErrInvalidNode
+			}
+			b = b[8:]
+			continue
+		case 2: // length-delimited
+			size, n := binary.Uvarint(b)
+			if n <= 0 || size > uint64(len(b)-n) {
+				return ErrInvalidNode
+			}
+			value, b = b[n:n+int(size)], b[n+int(size):]
+		case 5: // 32-bit
+			if len(b) < 4 {
+				return ErrInvalidNode
+			}
+			b = b[4:]
+			continue
+		default:
+			return ErrInvalidNode
+		}
+
+		if err := fn(int(key>>3), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
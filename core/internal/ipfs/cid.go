@@ -0,0 +1,191 @@
+// Package ipfs fetches and verifies content-addressed data from IPFS gateways.
+package ipfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// Multicodec codes
+const (
+	CodecRaw   = 0x55
+	CodecDagPB = 0x70
+
+	// HashSHA2256 is the only multihash accepted; CIDs using other hash
+	// functions are rejected rather than trusted
+	HashSHA2256 = 0x12
+)
+
+// CID errors
+var (
+	ErrInvalidCID     = errors.New("invalid CID")
+	ErrUnsupportedCID = errors.New("unsupported CID codec or hash function")
+	ErrHashMismatch   = errors.New("block does not match CID")
+)
+
+// base32Lower is the multibase 'b' encoding used by CIDv1 strings
+var base32Lower = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// base58Alphabet is the bitcoin alphabet used by CIDv0 strings
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// CID is a sha2-256 content identifier
+type CID struct {
+	Version uint64
+	Codec   uint64
+	Digest  []byte
+}
+
+// ParseCID parses a CIDv0 ("Qm...") or base32 CIDv1 ("b...") string
+func ParseCID(s string) (CID, error) {
+	var raw []byte
+	switch {
+	case len(s) == 46 && strings.HasPrefix(s, "Qm"):
+		b, err := base58Decode(s)
+		if err != nil {
+			return CID{}, err
+		}
+		raw = b
+	case strings.HasPrefix(s, "b"):
+		b, err := base32Lower.DecodeString(s[1:])
+		if err != nil {
+			return CID{}, ErrInvalidCID
+		}
+		raw = b
+	default:
+		return CID{}, ErrUnsupportedCID
+	}
+
+	c, n, err := decodeCID(raw)
+	if err != nil {
+		return CID{}, err
+	}
+	if n != len(raw) {
+		return CID{}, ErrInvalidCID
+	}
+	return c, nil
+}
+
+// RawCID returns the CIDv1 of data stored as a single raw block
+func RawCID(data []byte) CID {
+	digest := sha256.Sum256(data)
+	return CID{Version: 1, Codec: CodecRaw, Digest: digest[:]}
+}
+
+// decodeCID decodes a binary CID and returns the number of bytes read
+func decodeCID(b []byte) (CID, int, error) {
+	// CIDv0 is a bare sha2-256 multihash of a dag-pb node
+	if len(b) >= 2 && b[0] == HashSHA2256 && b[1] == sha256.Size {
+		if len(b) < 2+sha256.Size {
+			return CID{}, 0, ErrInvalidCID
+		}
+		digest := append([]byte(nil), b[2:2+sha256.Size]...)
+		return CID{Version: 0, Codec: CodecDagPB, Digest: digest}, 2 + sha256.Size, nil
+	}
+
+	var fields [4]uint64
+	off := 0
+	for i := range fields {
+		v, n := binary.Uvarint(b[off:])
+		if n <= 0 {
+			return CID{}, 0, ErrInvalidCID
+		}
+		fields[i] = v
+		off += n
+	}
+	version, codec, hashCode, size := fields[0], fields[1], fields[2], fields[3]
+	if version != 1 {
+		return CID{}, 0, ErrInvalidCID
+	}
+	if hashCode != HashSHA2256 || size != sha256.Size {
+		return CID{}, 0, ErrUnsupportedCID
+	}
+	if len(b) < off+sha256.Size {
+		return CID{}, 0, ErrInvalidCID
+	}
+
+	digest := append([]byte(nil), b[off:off+sha256.Size]...)
+	return CID{Version: 1, Codec: codec, Digest: digest}, off + sha256.Size, nil
+}
+
+// Bytes returns the binary form of the CID
+func (c CID) Bytes() []byte {
+	mh := append([]byte{HashSHA2256, sha256.Size}, c.Digest...)
+	if c.Version == 0 {
+		return mh
+	}
+	buf := binary.AppendUvarint(nil, c.Version)
+	buf = binary.AppendUvarint(buf, c.Codec)
+	return append(buf, mh...)
+}
+
+// String returns the canonical string form of the CID
+func (c CID) String() string {
+	if c.Version == 0 {
+		return base58Encode(c.Bytes())
+	}
+	return "b" + base32Lower.EncodeToString(c.Bytes())
+}
+
+// Equal reports whether two CIDs are identical
+func (c CID) Equal(other CID) bool {
+	return c.Version == other.Version && c.Codec == other.Codec && bytes.Equal(c.Digest, other.Digest)
+}
+
+// Verify checks that block hashes to the CID's digest
+func (c CID) Verify(block []byte) error {
+	digest := sha256.Sum256(block)
+	if !bytes.Equal(digest[:], c.Digest) {
+		return ErrHashMismatch
+	}
+	return nil
+}
+
+// base58Decode decodes a base58btc string
+func base58Decode(s string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for _, r := range s {
+		i := strings.IndexRune(base58Alphabet, r)
+		if i < 0 {
+			return nil, ErrInvalidCID
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(i)))
+	}
+
+	zeros := 0
+	for zeros < len(s) && s[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
+
+// base58Encode encodes bytes as base58btc
+func base58Encode(b []byte) string {
+	n := new(big.Int).SetBytes(b)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, c := range b {
+		if c != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
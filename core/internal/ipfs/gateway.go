@@ -0,0 +1,97 @@
+package ipfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// carAccept requests a verifiable CAR in the order ExtractCAR consumes
+const carAccept = "application/vnd.ipld.car; version=1; order=dfs; dups=y"
+
+// Config holds gateway client configuration
+type Config struct {
+	// Gateway is the base URL of an IPFS HTTP gateway that supports
+	// trustless CAR responses
+	Gateway string
+
+	// Timeout bounds a whole fetch, including the body
+	Timeout time.Duration
+
+	// API is the Kubo RPC API content is added and pinned through
+	// (empty makes the model store read-only)
+	API string
+
+	// Pin pins content the model store fetches on the API node as well
+	Pin bool
+
+	// CacheDir holds verified content by CID (empty disables caching)
+	CacheDir string
+
+	// MaxCacheSize bounds the cache; least recently used content is
+	// evicted first
+	MaxCacheSize int64
+
+	// MaxObjectSize bounds content the model store fetches or adds
+	MaxObjectSize int64
+}
+
+// DefaultConfig returns default gateway configuration (a local Kubo node)
+func DefaultConfig() *Config {
+	return &Config{
+		Gateway:       "http://127.0.0.1:8080",
+		Timeout:       30 * time.Minute,
+		API:           "http://127.0.0.1:5001",
+		Pin:           true,
+		MaxCacheSize:  16 << 30,
+		MaxObjectSize: 2 << 30,
+	}
+}
+
+// Client fetches content from a gateway without trusting it: every block
+// is checked against the requested CID before its content is written
+type Client struct {
+	config *Config
+	http   *http.Client
+}
+
+// NewClient creates a gateway client
+func NewClient(cfg *Config) *Client {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	return &Client{
+		config: cfg,
+		http:   &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// Fetch downloads the file identified by cid and writes its verified
+// content to w, returning the number of bytes written
+func (c *Client) Fetch(ctx context.Context, cid string, w io.Writer) (int64, error) {
+	root, err := ParseCID(cid)
+	if err != nil {
+		return 0, err
+	}
+
+	url := strings.TrimRight(c.config.Gateway, "/") + "/ipfs/" + root.String() + "?dag-scope=all"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", carAccept)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("gateway returned %s for %s", resp.Status, root)
+	}
+	return ExtractCAR(resp.Body, root, w)
+}
@@ -0,0 +1,88 @@
+package ipfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrNoAPI is returned when adding or pinning without a Kubo RPC API
+var ErrNoAPI = errors.New("no IPFS RPC API configured")
+
+// Add stores data on the Kubo node behind the RPC API, pinned, and
+// returns its CID. Content is chunked into raw leaves under a CIDv1 root.
+func (c *Client) Add(ctx context.Context, data []byte) (CID, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "weights")
+	if err != nil {
+		return CID{}, err
+	}
+	if _, err := part.Write(data); err != nil {
+		return CID{}, err
+	}
+	if err := form.Close(); err != nil {
+		return CID{}, err
+	}
+
+	params := url.Values{
+		"cid-version": {"1"},
+		"raw-leaves":  {"true"},
+		"pin":         {"true"},
+		"quieter":     {"true"},
+	}
+	var added struct {
+		Hash string
+	}
+	if err := c.call(ctx, "add", params, form.FormDataContentType(), &body, &added); err != nil {
+		return CID{}, err
+	}
+	return ParseCID(added.Hash)
+}
+
+// Pin pins cid on the Kubo node behind the RPC API, fetching it there if
+// needed
+func (c *Client) Pin(ctx context.Context, cid CID) error {
+	return c.call(ctx, "pin/add", url.Values{"arg": {cid.String()}}, "", nil, nil)
+}
+
+// call invokes a Kubo RPC command and decodes its JSON response into out
+func (c *Client) call(ctx context.Context, command string, params url.Values, contentType string, body io.Reader, out interface{}) error {
+	if c.config.API == "" {
+		return ErrNoAPI
+	}
+
+	endpoint := strings.TrimRight(c.config.API, "/") + "/api/v0/" + command + "?" + params.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var kuboErr struct {
+			Message string
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&kuboErr)
+		return fmt.Errorf("ipfs %s failed: %s %s", command, resp.Status, kuboErr.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
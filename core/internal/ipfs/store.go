@@ -0,0 +1,307 @@
+package ipfs
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Model store errors
+var (
+	ErrObjectTooLarge = errors.New("content exceeds size limit")
+	ErrUnknownModel   = errors.New("no weights known for model")
+)
+
+// modelIndexFile records the CIDs of weights saved through the store
+const modelIndexFile = "models.json"
+
+// CIDResolver looks up the CID of a model's current weights, typically in
+// the model registry
+type CIDResolver interface {
+	WeightsCID(ctx context.Context, modelID types.Hash) (string, error)
+}
+
+// ModelStore keeps model weights on IPFS. Weights are read through the
+// gateway and verified against their CID, added and pinned through the
+// Kubo RPC API, and cached locally by CID.
+type ModelStore struct {
+	mu sync.Mutex
+
+	client   *Client
+	config   *Config
+	resolver CIDResolver
+
+	// CIDs of weights saved through this store, by model
+	index map[types.Hash]string
+}
+
+// CacheStats describes the local content cache
+type CacheStats struct {
+	Entries int   `json:"entries"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// NewModelStore creates a model store. Models without weights saved
+// through the store are looked up with resolver, which may be nil.
+func NewModelStore(cfg *Config, resolver CIDResolver) (*ModelStore, error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+
+	s := &ModelStore{
+		client:   NewClient(cfg),
+		config:   cfg,
+		resolver: resolver,
+		index:    make(map[types.Hash]string),
+	}
+	if cfg.CacheDir == "" {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(cfg.CacheDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create IPFS cache: %w", err)
+	}
+	data, err := os.ReadFile(filepath.Join(cfg.CacheDir, modelIndexFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		var index map[string]string
+		if err := json.Unmarshal(data, &index); err != nil {
+			return nil, fmt.Errorf("corrupt model index: %w", err)
+		}
+		for id, cid := range index {
+			b, err := hex.DecodeString(id)
+			if err != nil || len(b) != types.HashSize {
+				return nil, fmt.Errorf("corrupt model index entry %q", id)
+			}
+			s.index[types.HashFromBytes(b)] = cid
+		}
+	}
+	return s, nil
+}
+
+// GetWeights returns the verified weights of a model
+func (s *ModelStore) GetWeights(ctx context.Context, modelID types.Hash) ([]byte, error) {
+	cid, err := s.GetWeightsCID(ctx, modelID)
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, cid)
+}
+
+// GetWeightsCID returns the CID of a model's weights. Weights saved
+// through the store take precedence over the resolver.
+func (s *ModelStore) GetWeightsCID(ctx context.Context, modelID types.Hash) (string, error) {
+	s.mu.Lock()
+	cid, ok := s.index[modelID]
+	s.mu.Unlock()
+	if ok {
+		return cid, nil
+	}
+	if s.resolver == nil {
+		return "", ErrUnknownModel
+	}
+	cid, err := s.resolver.WeightsCID(ctx, modelID)
+	if err != nil {
+		return "", err
+	}
+	if cid == "" {
+		return "", ErrUnknownModel
+	}
+	return cid, nil
+}
+
+// SaveWeights adds and pins a model's weights and records their CID
+func (s *ModelStore) SaveWeights(ctx context.Context, modelID types.Hash, weights []byte) error {
+	if s.config.MaxObjectSize > 0 && int64(len(weights)) > s.config.MaxObjectSize {
+		return ErrObjectTooLarge
+	}
+
+	cid, err := s.client.Add(ctx, weights)
+	if err != nil {
+		return err
+	}
+	if err := s.cachePut(cid, weights); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.index[modelID] = cid.String()
+	return s.saveIndexLocked()
+}
+
+// Get returns the verified content of cid, from the cache if present
+func (s *ModelStore) Get(ctx context.Context, cid string) ([]byte, error) {
+	root, err := ParseCID(cid)
+	if err != nil {
+		return nil, err
+	}
+	if data, ok := s.cacheGet(root); ok {
+		return data, nil
+	}
+
+	buf := &limitedBuffer{limit: s.config.MaxObjectSize}
+	if _, err := s.client.Fetch(ctx, root.String(), buf); err != nil {
+		return nil, err
+	}
+	if s.config.Pin && s.config.API != "" {
+		if err := s.client.Pin(ctx, root); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.cachePut(root, buf.data); err != nil {
+		return nil, err
+	}
+	return buf.data, nil
+}
+
+// Stats reports the size of the local cache
+func (s *ModelStore) Stats() CacheStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var stats CacheStats
+	for _, entry := range s.cacheEntriesLocked() {
+		stats.Entries++
+		stats.Bytes += entry.Size()
+	}
+	return stats
+}
+
+// cacheGet reads cached content and marks it recently used
+func (s *ModelStore) cacheGet(cid CID) ([]byte, bool) {
+	if s.config.CacheDir == "" {
+		return nil, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := filepath.Join(s.config.CacheDir, cid.String())
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return data, true
+}
+
+// cachePut writes content to the cache and evicts the least recently
+// used entries beyond MaxCacheSize
+func (s *ModelStore) cachePut(cid CID, data []byte) error {
+	if s.config.CacheDir == "" {
+		return nil
+	}
+	if s.config.MaxCacheSize > 0 && int64(len(data)) > s.config.MaxCacheSize {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := writeFileAtomic(filepath.Join(s.config.CacheDir, cid.String()), data); err != nil {
+		return err
+	}
+	if s.config.MaxCacheSize <= 0 {
+		return nil
+	}
+
+	entries := s.cacheEntriesLocked()
+	var total int64
+	for _, entry := range entries {
+		total += entry.Size()
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ModTime().Before(entries[j].ModTime()) })
+	for _, entry := range entries {
+		if total <= s.config.MaxCacheSize {
+			break
+		}
+		if entry.Name() == cid.String() {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.config.CacheDir, entry.Name())); err == nil {
+			total -= entry.Size()
+		}
+	}
+	return nil
+}
+
+// cacheEntriesLocked lists the cached content files
+func (s *ModelStore) cacheEntriesLocked() []os.FileInfo {
+	if s.config.CacheDir == "" {
+		return nil
+	}
+	dirEntries, err := os.ReadDir(s.config.CacheDir)
+	if err != nil {
+		return nil
+	}
+
+	var entries []os.FileInfo
+	for _, de := range dirEntries {
+		name := de.Name()
+		if de.IsDir() || name == modelIndexFile || strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+		if info, err := de.Info(); err == nil {
+			entries = append(entries, info)
+		}
+	}
+	return entries
+}
+
+// saveIndexLocked persists the model index
+func (s *ModelStore) saveIndexLocked() error {
+	if s.config.CacheDir == "" {
+		return nil
+	}
+
+	index := make(map[string]string, len(s.index))
+	for id, cid := range s.index {
+		index[id.String()] = cid
+	}
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.config.CacheDir, modelIndexFile), data)
+}
+
+// writeFileAtomic writes data to path through a temporary file
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// limitedBuffer collects fetched content up to a size limit
+type limitedBuffer struct {
+	data  []byte
+	limit int64
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.limit > 0 && int64(len(b.data)+len(p)) > b.limit {
+		return 0, ErrObjectTooLarge
+	}
+	b.data = append(b.data, p...)
+	return len(p), nil
+}
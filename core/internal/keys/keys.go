@@ -0,0 +1,196 @@
+// Package keys provides the node's signing keys through key providers,
+// so private keys can stay in a cloud KMS or PKCS#11 HSM instead of on
+// disk. Providers hold named Ed25519 keys, sign with them in place and
+// rotate them.
+package keys
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ccoin/core/internal/vrf"
+)
+
+// Key provider errors
+var (
+	ErrKeyNotFound    = errors.New("key not found")
+	ErrInvalidKeyName = errors.New("invalid key name")
+	ErrInvalidKeyFile = errors.New("invalid key file")
+	ErrNotExportable  = errors.New("private key cannot be exported from its provider")
+	ErrVRFUnsupported = errors.New("key provider cannot prove VRF outputs")
+	ErrBadSignature   = errors.New("key provider returned an invalid signature")
+)
+
+// Names of the node's keys
+const (
+	// KeyNode is the P2P identity key; the peer ID derives from it
+	KeyNode = "node"
+
+	// KeyMiner is the miner identity key. It signs block headers and
+	// draws tasks with VRF proofs, and the miner address derives from it.
+	KeyMiner = "miner"
+)
+
+// Signer is an Ed25519 key whose private half may never leave its
+// provider
+type Signer interface {
+	// Public returns the public key
+	Public() ed25519.PublicKey
+
+	// Sign signs message
+	Sign(message []byte) ([]byte, error)
+
+	// ProveVRF proves the VRF output for alpha under the key. Providers
+	// that only sign fail with ErrVRFUnsupported.
+	ProveVRF(alpha []byte) ([]byte, error)
+}
+
+// Provider holds named keys
+type Provider interface {
+	// Signer returns the current version of the named key
+	Signer(ctx context.Context, name string) (Signer, error)
+
+	// Rotate replaces the named key with a new one, creating it if
+	// missing, and returns it. What happens to the old key is up to the
+	// provider; none of them destroy it.
+	Rotate(ctx context.Context, name string) (Signer, error)
+}
+
+// localSigner signs with a private key in memory
+type localSigner struct {
+	key ed25519.PrivateKey
+}
+
+// Local returns a signer for a private key held in memory
+func Local(key ed25519.PrivateKey) Signer {
+	return &localSigner{key: key}
+}
+
+func (s *localSigner) Public() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+func (s *localSigner) Sign(message []byte) ([]byte, error) {
+	return ed25519.Sign(s.key, message), nil
+}
+
+func (s *localSigner) ProveVRF(alpha []byte) ([]byte, error) {
+	return vrf.Prove(s.key, alpha)
+}
+
+// validName reports whether a key name is safe to use in file names
+// and URLs
+func validName(name string) bool {
+	if name == "" || len(name) > 64 {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// FileProvider keeps keys in <dir>/<name>.key, readable only by the
+// owner. It reads the miner key's 32-byte seed and the node key's
+// libp2p encoding, so a data directory's existing keys work unchanged.
+// Rotated keys are kept as <name>.key.<unix time>.
+type FileProvider struct {
+	dir string
+}
+
+// NewFileProvider creates a provider over the key files in dir
+func NewFileProvider(dir string) *FileProvider {
+	return &FileProvider{dir: dir}
+}
+
+// libp2pEd25519Prefix starts a libp2p-encoded Ed25519 private key: the
+// key type field, then a 64-byte private and public key
+var libp2pEd25519Prefix = []byte{0x08, 0x01, 0x12, 0x40}
+
+// path returns the file of the named key
+func (p *FileProvider) path(name string) (string, error) {
+	if !validName(name) {
+		return "", fmt.Errorf("%w %q", ErrInvalidKeyName, name)
+	}
+	return filepath.Join(p.dir, name+".key"), nil
+}
+
+// Signer returns the named key
+func (p *FileProvider) Signer(ctx context.Context, name string) (Signer, error) {
+	path, err := p.path(name)
+	if err != nil {
+		return nil, err
+	}
+	key, _, err := readKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Local(key), nil
+}
+
+// Rotate moves the named key aside and writes a new one in the same
+// encoding
+func (p *FileProvider) Rotate(ctx context.Context, name string) (Signer, error) {
+	path, err := p.path(name)
+	if err != nil {
+		return nil, err
+	}
+	_, libp2p, err := readKeyFile(path)
+	switch {
+	case errors.Is(err, ErrKeyNotFound):
+		// The miner key's encoding, unless it is the node key
+		libp2p = name == KeyNode
+	case err != nil:
+		return nil, err
+	default:
+		old := fmt.Sprintf("%s.%d", path, time.Now().Unix())
+		if err := os.Rename(path, old); err != nil {
+			return nil, err
+		}
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	data := key.Seed()
+	if libp2p {
+		data = append(append([]byte{}, libp2pEd25519Prefix...), key...)
+	}
+	if err := os.MkdirAll(p.dir, 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, err
+	}
+	return Local(key), nil
+}
+
+// readKeyFile reads a key file and reports whether it is libp2p encoded
+func readKeyFile(path string) (ed25519.PrivateKey, bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, fmt.Errorf("%w: %s", ErrKeyNotFound, path)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	switch {
+	case len(data) == ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(data), false, nil
+	case len(data) == len(libp2pEd25519Prefix)+ed25519.PrivateKeySize && bytes.HasPrefix(data, libp2pEd25519Prefix):
+		seed := data[len(libp2pEd25519Prefix):][:ed25519.SeedSize]
+		return ed25519.NewKeyFromSeed(seed), true, nil
+	default:
+		return nil, false, fmt.Errorf("%w: %s", ErrInvalidKeyFile, path)
+	}
+}
@@ -0,0 +1,278 @@
+package keys
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ccoin/core/internal/vrf"
+)
+
+// remoteTimeout bounds a single request to a signing service
+const remoteTimeout = 10 * time.Second
+
+// maxRemoteResponse bounds the body of a signing service response
+const maxRemoteResponse = 64 * 1024
+
+// Signing service protocol. Every request carries the bearer token and
+// names a key:
+//
+//	GET  /keys/<name>         -> keyResponse
+//	POST /keys/<name>/sign    signRequest -> signResponse
+//	POST /keys/<name>/vrf     vrfRequest -> vrfResponse, 501 if unsupported
+//	POST /keys/<name>/rotate  -> keyResponse
+//
+// A missing key is 404. Byte fields are base64, as encoding/json writes
+// them.
+type (
+	keyResponse struct {
+		PublicKey []byte `json:"public_key"`
+	}
+	signRequest struct {
+		Message []byte `json:"message"`
+	}
+	signResponse struct {
+		Signature []byte `json:"signature"`
+	}
+	vrfRequest struct {
+		Alpha []byte `json:"alpha"`
+	}
+	vrfResponse struct {
+		Proof []byte `json:"proof"`
+	}
+)
+
+// RemoteProvider uses keys held by a signing service, typically a
+// sidecar in front of a cloud KMS or a PKCS#11 HSM, or another node's
+// Handler. Private keys never reach this process; every signature is
+// checked against the key's public key before it is used.
+type RemoteProvider struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewRemoteProvider creates a provider for the signing service at url
+func NewRemoteProvider(url, token string) *RemoteProvider {
+	return &RemoteProvider{
+		url:    strings.TrimSuffix(url, "/"),
+		token:  token,
+		client: &http.Client{Timeout: remoteTimeout},
+	}
+}
+
+// Signer returns the service's current version of the named key
+func (p *RemoteProvider) Signer(ctx context.Context, name string) (Signer, error) {
+	var resp keyResponse
+	if err := p.call(ctx, http.MethodGet, name, "", nil, &resp); err != nil {
+		return nil, err
+	}
+	return p.signer(name, resp.PublicKey)
+}
+
+// Rotate asks the service to replace the named key
+func (p *RemoteProvider) Rotate(ctx context.Context, name string) (Signer, error) {
+	var resp keyResponse
+	if err := p.call(ctx, http.MethodPost, name, "/rotate", struct{}{}, &resp); err != nil {
+		return nil, err
+	}
+	return p.signer(name, resp.PublicKey)
+}
+
+// signer returns the signer of a key the service reported
+func (p *RemoteProvider) signer(name string, public []byte) (Signer, error) {
+	if len(public) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("signing service returned an invalid public key for %q", name)
+	}
+	return &remoteSigner{provider: p, name: name, public: public}, nil
+}
+
+// call sends a request for the named key and decodes the response
+func (p *RemoteProvider) call(ctx context.Context, method, name, op string, in, out interface{}) error {
+	if !validName(name) {
+		return fmt.Errorf("%w %q", ErrInvalidKeyName, name)
+	}
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.url+"/keys/"+name+op, body)
+	if err != nil {
+		return err
+	}
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteResponse))
+	if err != nil {
+		return err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return json.Unmarshal(data, out)
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %q", ErrKeyNotFound, name)
+	case http.StatusNotImplemented:
+		return ErrVRFUnsupported
+	default:
+		return fmt.Errorf("signing service: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+}
+
+// remoteSigner signs through a signing service
+type remoteSigner struct {
+	provider *RemoteProvider
+	name     string
+	public   ed25519.PublicKey
+}
+
+func (s *remoteSigner) Public() ed25519.PublicKey {
+	return s.public
+}
+
+// Sign fails with ErrBadSignature if the key was rotated since the
+// signer was created
+func (s *remoteSigner) Sign(message []byte) ([]byte, error) {
+	var resp signResponse
+	err := s.provider.call(context.Background(), http.MethodPost, s.name, "/sign", &signRequest{Message: message}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Signature) != ed25519.SignatureSize || !ed25519.Verify(s.public, message, resp.Signature) {
+		return nil, ErrBadSignature
+	}
+	return resp.Signature, nil
+}
+
+func (s *remoteSigner) ProveVRF(alpha []byte) ([]byte, error) {
+	var resp vrfResponse
+	err := s.provider.call(context.Background(), http.MethodPost, s.name, "/vrf", &vrfRequest{Alpha: alpha}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := vrf.Verify(s.public, resp.Proof, alpha); err != nil {
+		return nil, ErrBadSignature
+	}
+	return resp.Proof, nil
+}
+
+// Handler serves a provider's keys over the signing service protocol,
+// so one host holding the keys can sign for nodes that hold none.
+// Requests must carry token as a bearer token.
+func Handler(p Provider, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expected := []byte("Bearer " + token)
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		path, ok := strings.CutPrefix(r.URL.Path, "/keys/")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		name, op, _ := strings.Cut(path, "/")
+		if !validName(name) {
+			http.Error(w, ErrInvalidKeyName.Error(), http.StatusBadRequest)
+			return
+		}
+
+		out, err := serveKey(r, p, name, op)
+		switch {
+		case errors.Is(err, ErrKeyNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, ErrVRFUnsupported):
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+		case errors.Is(err, errUnknownOperation):
+			http.Error(w, err.Error(), http.StatusMethodNotAllowed)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(out)
+		}
+	})
+}
+
+// errUnknownOperation rejects requests outside the protocol
+var errUnknownOperation = errors.New("unknown operation")
+
+// serveKey performs one signing service operation on the named key
+func serveKey(r *http.Request, p Provider, name, op string) (interface{}, error) {
+	switch {
+	case op == "" && r.Method == http.MethodGet:
+		s, err := p.Signer(r.Context(), name)
+		if err != nil {
+			return nil, err
+		}
+		return &keyResponse{PublicKey: s.Public()}, nil
+
+	case op == "rotate" && r.Method == http.MethodPost:
+		s, err := p.Rotate(r.Context(), name)
+		if err != nil {
+			return nil, err
+		}
+		return &keyResponse{PublicKey: s.Public()}, nil
+
+	case op == "sign" && r.Method == http.MethodPost:
+		var req signRequest
+		if err := decodeRequest(r, &req); err != nil {
+			return nil, err
+		}
+		s, err := p.Signer(r.Context(), name)
+		if err != nil {
+			return nil, err
+		}
+		sig, err := s.Sign(req.Message)
+		if err != nil {
+			return nil, err
+		}
+		return &signResponse{Signature: sig}, nil
+
+	case op == "vrf" && r.Method == http.MethodPost:
+		var req vrfRequest
+		if err := decodeRequest(r, &req); err != nil {
+			return nil, err
+		}
+		s, err := p.Signer(r.Context(), name)
+		if err != nil {
+			return nil, err
+		}
+		proof, err := s.ProveVRF(req.Alpha)
+		if err != nil {
+			return nil, err
+		}
+		return &vrfResponse{Proof: proof}, nil
+
+	default:
+		return nil, errUnknownOperation
+	}
+}
+
+// decodeRequest decodes a bounded JSON request body
+func decodeRequest(r *http.Request, v interface{}) error {
+	return json.NewDecoder(io.LimitReader(r.Body, maxRemoteResponse)).Decode(v)
+}
@@ -0,0 +1,305 @@
+// Package lifecycle starts node services in dependency order and stops
+// them in reverse, so each service drains while the services it depends
+// on are still running.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ccoin/core/internal/logging"
+)
+
+// Lifecycle errors
+var (
+	ErrDuplicateService  = errors.New("service already registered")
+	ErrUnknownDependency = errors.New("service depends on an unregistered service")
+	ErrDependencyCycle   = errors.New("service dependency cycle")
+	ErrAlreadyStarted    = errors.New("services already started")
+	ErrStopTimeout       = errors.New("service did not stop in time")
+)
+
+// State describes where a service is in its lifecycle
+type State string
+
+const (
+	StateRegistered State = "REGISTERED"
+	StateStarting   State = "STARTING"
+	StateRunning    State = "RUNNING"
+	StateStopping   State = "STOPPING"
+	StateStopped    State = "STOPPED"
+	StateFailed     State = "FAILED"
+)
+
+// Service is a component the manager starts and stops
+type Service struct {
+	// Name identifies the service to others' DependsOn
+	Name string
+
+	// DependsOn names the services started before this one and stopped
+	// after it
+	DependsOn []string
+
+	// Start starts the service without blocking. Its context lives until
+	// the service is stopped, so goroutines started with it end before
+	// the services it depends on stop. Nil starts nothing.
+	Start func(ctx context.Context) error
+
+	// Stop drains and stops the service by the context's deadline, e.g.
+	// finishing in-flight requests or flushing state to disk. Nil only
+	// cancels the context Start was given.
+	Stop func(ctx context.Context) error
+}
+
+// Status is a snapshot of one service's state
+type Status struct {
+	Name  string
+	State State
+	Error string
+}
+
+// Config holds lifecycle manager configuration
+type Config struct {
+	// StopTimeout bounds each service's Stop; a service still stopping
+	// after it is abandoned so the rest can shut down
+	StopTimeout time.Duration
+
+	// Logger receives start and stop events (nil logs as module
+	// "lifecycle")
+	Logger *slog.Logger
+}
+
+// DefaultConfig returns default lifecycle manager configuration
+func DefaultConfig() *Config {
+	return &Config{
+		StopTimeout: 10 * time.Second,
+	}
+}
+
+// Manager starts and stops a set of services
+type Manager struct {
+	mu sync.Mutex
+
+	config   *Config
+	log      *slog.Logger
+	services []*service
+
+	// started holds the services started, in start order
+	started []*service
+	running bool
+}
+
+// service tracks a registered service
+type service struct {
+	Service
+	state  State
+	err    error
+	cancel context.CancelFunc
+}
+
+// NewManager creates a lifecycle manager
+func NewManager(cfg *Config) *Manager {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	log := cfg.Logger
+	if log == nil {
+		log = logging.Module("lifecycle")
+	}
+	return &Manager{
+		config: cfg,
+		log:    log,
+	}
+}
+
+// Add registers a service to be started by Start. Its dependencies may
+// be added in any order; Start reports missing or duplicate services.
+func (m *Manager) Add(s Service) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.services = append(m.services, &service{Service: s, state: StateRegistered})
+}
+
+// order returns the services with each after its dependencies, otherwise
+// in the order they were added
+func (m *Manager) order() ([]*service, error) {
+	byName := make(map[string]*service, len(m.services))
+	for _, svc := range m.services {
+		if svc.Name == "" {
+			return nil, errors.New("service name is required")
+		}
+		if _, exists := byName[svc.Name]; exists {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateService, svc.Name)
+		}
+		byName[svc.Name] = svc
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make(map[*service]int, len(m.services))
+	ordered := make([]*service, 0, len(m.services))
+
+	var visit func(svc *service, path []string) error
+	visit = func(svc *service, path []string) error {
+		switch marks[svc] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("%w: %v", ErrDependencyCycle, append(path, svc.Name))
+		}
+		marks[svc] = visiting
+		for _, name := range svc.DependsOn {
+			dep, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("%w: %s needs %s", ErrUnknownDependency, svc.Name, name)
+			}
+			if err := visit(dep, append(path, svc.Name)); err != nil {
+				return err
+			}
+		}
+		marks[svc] = visited
+		ordered = append(ordered, svc)
+		return nil
+	}
+
+	for _, svc := range m.services {
+		if err := visit(svc, nil); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// Start starts every service in dependency order. If one fails, or ctx
+// is cancelled first, the services already started are stopped again
+// and the error returned. Service contexts carry ctx's values but not
+// its cancellation: services are cancelled one by one by Stop.
+func (m *Manager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	if m.running {
+		m.mu.Unlock()
+		return ErrAlreadyStarted
+	}
+	ordered, err := m.order()
+	if err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	m.running = true
+	m.mu.Unlock()
+
+	base := context.WithoutCancel(ctx)
+	for _, svc := range ordered {
+		if err := ctx.Err(); err != nil {
+			m.abort()
+			return err
+		}
+
+		m.setState(svc, StateStarting, nil)
+		svcCtx, cancel := context.WithCancel(base)
+		if svc.Start != nil {
+			if err := svc.Start(svcCtx); err != nil {
+				cancel()
+				m.setState(svc, StateFailed, err)
+				m.log.Error("service failed to start", "service", svc.Name, "err", err)
+				m.abort()
+				return fmt.Errorf("failed to start %s: %w", svc.Name, err)
+			}
+		}
+
+		m.mu.Lock()
+		svc.cancel = cancel
+		m.started = append(m.started, svc)
+		m.mu.Unlock()
+		m.setState(svc, StateRunning, nil)
+		m.log.Debug("service started", "service", svc.Name)
+	}
+	return nil
+}
+
+// abort stops the services a failed Start had started
+func (m *Manager) abort() {
+	m.Stop(context.Background())
+}
+
+// Stop stops the started services in the reverse of their start order.
+// Each service's context is cancelled and its Stop given until
+// StopTimeout or ctx's deadline, whichever is sooner; a service failing
+// or timing out is logged and does not hold up the rest. Stop returns
+// every service's error, and stopping again does nothing.
+func (m *Manager) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	started := m.started
+	m.started = nil
+	m.mu.Unlock()
+
+	var errs []error
+	for i := len(started) - 1; i >= 0; i-- {
+		svc := started[i]
+		m.setState(svc, StateStopping, nil)
+		begun := time.Now()
+
+		svc.cancel()
+		err := m.stopService(ctx, svc)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop %s: %w", svc.Name, err))
+			m.setState(svc, StateFailed, err)
+			m.log.Warn("service failed to stop", "service", svc.Name, "err", err)
+			continue
+		}
+		m.setState(svc, StateStopped, nil)
+		m.log.Debug("service stopped", "service", svc.Name, "took", time.Since(begun))
+	}
+	return errors.Join(errs...)
+}
+
+// stopService runs a service's Stop, abandoning it at the timeout
+func (m *Manager) stopService(ctx context.Context, svc *service) error {
+	if svc.Stop == nil {
+		return nil
+	}
+	if m.config.StopTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.config.StopTimeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- svc.Stop(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ErrStopTimeout
+	}
+}
+
+// setState records a service's state and the error that caused it
+func (m *Manager) setState(svc *service, state State, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	svc.state = state
+	svc.err = err
+}
+
+// Status returns a snapshot of every service, in the order added
+func (m *Manager) Status() []Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	statuses := make([]Status, len(m.services))
+	for i, svc := range m.services {
+		statuses[i] = Status{Name: svc.Name, State: svc.state}
+		if svc.err != nil {
+			statuses[i].Error = svc.err.Error()
+		}
+	}
+	return statuses
+}
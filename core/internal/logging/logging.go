@@ -0,0 +1,234 @@
+// Package logging provides the node's leveled, structured loggers. Each
+// subsystem logs through a module logger whose level can be set on its
+// own; output goes to stdout or a rotating file, as text or JSON.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Logging errors
+var (
+	ErrInvalidLevel = errors.New("invalid log level")
+)
+
+// Config holds logging configuration
+type Config struct {
+	// Level is the default level, optionally followed by per-module
+	// levels: "info" or "info,p2p=debug,mempool=warn". Levels are debug,
+	// info, warn and error.
+	Level string
+
+	// File is the log file path; empty writes to stdout
+	File string
+
+	// MaxSize is the size in bytes at which the log file is rotated
+	MaxSize int64
+
+	// MaxBackups is the number of rotated files kept, File.1 newest
+	MaxBackups int
+
+	// JSON writes one JSON object per record instead of key=value text
+	JSON bool
+}
+
+// DefaultConfig returns default logging configuration
+func DefaultConfig() *Config {
+	return &Config{
+		Level:      "info",
+		MaxSize:    100 << 20,
+		MaxBackups: 5,
+	}
+}
+
+// root holds the output every module logger writes to and the level of
+// each module
+var root = &rootLogger{
+	handler: slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}),
+	levels:  make(map[string]*slog.LevelVar),
+}
+
+// rootLogger is the process's logging configuration
+type rootLogger struct {
+	mu sync.RWMutex
+
+	handler   slog.Handler
+	level     slog.Level
+	overrides map[string]slog.Level
+	levels    map[string]*slog.LevelVar
+}
+
+// Setup directs every module logger to the configured output at the
+// configured levels. Loggers obtained before Setup follow it. The
+// returned closer closes the log file, if any.
+func Setup(cfg *Config) (io.Closer, error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	level, overrides, err := ParseLevels(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	var out io.WriteCloser = nopCloser{os.Stdout}
+	if cfg.File != "" {
+		if out, err = OpenRotatingFile(cfg.File, cfg.MaxSize, cfg.MaxBackups); err != nil {
+			return nil, err
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	var handler slog.Handler = slog.NewTextHandler(out, opts)
+	if cfg.JSON {
+		handler = slog.NewJSONHandler(out, opts)
+	}
+
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	root.handler = handler
+	root.level = level
+	root.overrides = overrides
+	for module, v := range root.levels {
+		v.Set(root.levelLocked(module))
+	}
+	return out, nil
+}
+
+// Module returns the logger of a subsystem. Records carry a module
+// attribute and are dropped below the module's level.
+func Module(name string) *slog.Logger {
+	root.mu.Lock()
+	v, ok := root.levels[name]
+	if !ok {
+		v = new(slog.LevelVar)
+		v.Set(root.levelLocked(name))
+		root.levels[name] = v
+	}
+	root.mu.Unlock()
+
+	h := &moduleHandler{level: v}
+	return slog.New(h).With("module", name)
+}
+
+// SetLevel changes a module's level at runtime
+func SetLevel(module string, level slog.Level) {
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	if root.overrides == nil {
+		root.overrides = make(map[string]slog.Level)
+	}
+	root.overrides[module] = level
+	if v, ok := root.levels[module]; ok {
+		v.Set(level)
+	}
+}
+
+// levelLocked returns a module's configured level
+func (r *rootLogger) levelLocked(module string) slog.Level {
+	if level, ok := r.overrides[module]; ok {
+		return level
+	}
+	return r.level
+}
+
+// current returns the handler records are written through
+func (r *rootLogger) current() slog.Handler {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.handler
+}
+
+// ParseLevels parses a level spec such as "info,p2p=debug" into the
+// default level and per-module overrides
+func ParseLevels(spec string) (slog.Level, map[string]slog.Level, error) {
+	level := slog.LevelInfo
+	overrides := make(map[string]slog.Level)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		module, name, scoped := strings.Cut(part, "=")
+		if !scoped {
+			name = module
+		}
+		l, err := ParseLevel(name)
+		if err != nil {
+			return 0, nil, err
+		}
+		if scoped {
+			overrides[strings.TrimSpace(module)] = l
+		} else {
+			level = l
+		}
+	}
+	return level, overrides, nil
+}
+
+// ParseLevel parses one of debug, info, warn or error
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrInvalidLevel, name)
+	}
+}
+
+// moduleHandler filters records by its module's level and writes them
+// through the root handler current when they are logged, so loggers
+// created before Setup follow the configured output
+type moduleHandler struct {
+	level *slog.LevelVar
+
+	// wrap applies the attributes and groups added by With and
+	// WithGroup, in order
+	wrap []func(slog.Handler) slog.Handler
+}
+
+func (h *moduleHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *moduleHandler) Handle(ctx context.Context, r slog.Record) error {
+	handler := root.current()
+	for _, w := range h.wrap {
+		handler = w(handler)
+	}
+	return handler.Handle(ctx, r)
+}
+
+func (h *moduleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+func (h *moduleHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
+
+// with returns a copy of the handler with w applied after its wrappers
+func (h *moduleHandler) with(w func(slog.Handler) slog.Handler) slog.Handler {
+	wrap := make([]func(slog.Handler) slog.Handler, len(h.wrap), len(h.wrap)+1)
+	copy(wrap, h.wrap)
+	return &moduleHandler{level: h.level, wrap: append(wrap, w)}
+}
+
+// nopCloser is a WriteCloser whose Close does nothing, for stdout
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
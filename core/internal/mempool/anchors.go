@@ -0,0 +1,79 @@
+package mempool
+
+import (
+	"errors"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// ErrStaleAnchor is returned for a transaction anchored to a commitment
+// tree root outside the accepted window
+var ErrStaleAnchor = errors.New("transaction anchor outside the accepted window")
+
+// AnchorTracker reports how recent a commitment tree root is
+type AnchorTracker interface {
+	// AnchorAge returns how many roots were added after root, or false
+	// if transactions anchored to it are no longer accepted
+	AnchorAge(root types.Hash) (int, bool)
+}
+
+// SetAnchorTracker enables anchor checks: transactions with stale anchors
+// are rejected, and RevalidateAnchors evicts pending ones as the window
+// moves on
+func (m *Mempool) SetAnchorTracker(anchors AnchorTracker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.anchors = anchors
+}
+
+// checkAnchorLocked returns the age of tx's anchor
+func (m *Mempool) checkAnchorLocked(tx *types.Transaction) (int, error) {
+	if m.anchors == nil {
+		return 0, nil
+	}
+	age, ok := m.anchors.AnchorAge(tx.Anchor)
+	if !ok {
+		return 0, ErrStaleAnchor
+	}
+	return age, nil
+}
+
+// RevalidateAnchors refreshes the anchor age of every pending transaction
+// and evicts those whose anchor has left the window. Call it after each
+// block is applied. It returns the number evicted.
+func (m *Mempool) RevalidateAnchors() int {
+	defer m.notifyRemovals()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.anchors == nil {
+		return 0
+	}
+
+	var stale []types.Hash
+	for txHash, mpt := range m.txs {
+		age, ok := m.anchors.AnchorAge(mpt.Tx.Anchor)
+		if !ok {
+			stale = append(stale, txHash)
+			continue
+		}
+		mpt.AnchorAge = age
+	}
+	for _, txHash := range stale {
+		m.removeLocked(txHash, RemovalStaleAnchor, types.Hash{})
+	}
+	return len(stale)
+}
+
+// AnchorAge returns the anchor age of a pending transaction as of the
+// last check
+func (m *Mempool) AnchorAge(txHash types.Hash) (int, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	mpt, exists := m.txs[txHash]
+	if !exists {
+		return 0, false
+	}
+	return mpt.AnchorAge, true
+}
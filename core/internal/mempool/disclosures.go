@@ -0,0 +1,72 @@
+package mempool
+
+import (
+	"context"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// DisclosurePolicy checks that a transaction carries the disclosures the
+// network's policy requires of it
+type DisclosurePolicy interface {
+	CheckDisclosures(ctx context.Context, tx *types.Transaction) error
+}
+
+// SetDisclosurePolicy enables disclosure checks on admission.
+// RevalidateDisclosures evicts pending transactions after the policy
+// changes.
+func (m *Mempool) SetDisclosurePolicy(policy DisclosurePolicy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.disclosures = policy
+}
+
+// checkDisclosures checks tx against the disclosure policy. It verifies
+// proofs, so it runs without holding the pool lock.
+func (m *Mempool) checkDisclosures(tx *types.Transaction) error {
+	m.mu.RLock()
+	policy := m.disclosures
+	m.mu.RUnlock()
+
+	if policy == nil {
+		return nil
+	}
+	return policy.CheckDisclosures(context.Background(), tx)
+}
+
+// RevalidateDisclosures evicts pending transactions that no longer meet
+// the disclosure policy. Call it after the policy or the sanctions list
+// changes. It returns the number evicted.
+func (m *Mempool) RevalidateDisclosures() int {
+	m.mu.RLock()
+	policy := m.disclosures
+	pending := make([]*types.Transaction, 0, len(m.txs))
+	for _, mpt := range m.txs {
+		pending = append(pending, mpt.Tx)
+	}
+	m.mu.RUnlock()
+
+	if policy == nil {
+		return 0
+	}
+
+	var failed []types.Hash
+	for _, tx := range pending {
+		if err := policy.CheckDisclosures(context.Background(), tx); err != nil {
+			failed = append(failed, tx.TxHash)
+		}
+	}
+
+	defer m.notifyRemovals()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	evicted := 0
+	for _, txHash := range failed {
+		if _, exists := m.txs[txHash]; exists {
+			m.removeLocked(txHash, RemovalPolicy, types.Hash{})
+			evicted++
+		}
+	}
+	return evicted
+}
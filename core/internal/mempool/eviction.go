@@ -0,0 +1,168 @@
+package mempool
+
+import (
+	"context"
+	"time"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// RemovalReason explains why a transaction left the mempool
+type RemovalReason uint8
+
+const (
+	// RemovalConfirmed means the transaction was included in a block
+	RemovalConfirmed RemovalReason = iota
+
+	// RemovalConflict means a block spent one of its nullifiers
+	RemovalConflict
+
+	// RemovalReplaced means a higher-fee transaction replaced it
+	RemovalReplaced
+
+	// RemovalExpired means it stayed pending longer than the TTL
+	RemovalExpired
+
+	// RemovalEvicted means it was pushed out of a full pool
+	RemovalEvicted
+
+	// RemovalDropped means it was removed explicitly
+	RemovalDropped
+
+	// RemovalStaleAnchor means its anchor left the accepted window; the
+	// sender must rebuild it against a recent root
+	RemovalStaleAnchor
+
+	// RemovalPolicy means it no longer carries the disclosures the
+	// network's policy requires
+	RemovalPolicy
+)
+
+// String returns the reason name
+func (r RemovalReason) String() string {
+	switch r {
+	case RemovalConfirmed:
+		return "confirmed"
+	case RemovalConflict:
+		return "conflict"
+	case RemovalReplaced:
+		return "replaced"
+	case RemovalExpired:
+		return "expired"
+	case RemovalEvicted:
+		return "evicted"
+	case RemovalDropped:
+		return "dropped"
+	case RemovalStaleAnchor:
+		return "stale-anchor"
+	case RemovalPolicy:
+		return "policy"
+	default:
+		return "unknown"
+	}
+}
+
+// RemovalEvent reports a transaction leaving the mempool
+type RemovalEvent struct {
+	Tx     *types.Transaction
+	Reason RemovalReason
+
+	// ReplacedBy is the replacing transaction when Reason is RemovalReplaced
+	ReplacedBy types.Hash
+}
+
+// SetRemovalHandler registers fn to be called for every transaction that
+// leaves the pool. It is called without the pool lock held, so it may
+// call back into the mempool.
+func (m *Mempool) SetRemovalHandler(fn func(RemovalEvent)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onRemove = fn
+}
+
+// notifyRemovals delivers queued removal events. Callers defer it before
+// taking the lock so it runs after the lock is released.
+func (m *Mempool) notifyRemovals() {
+	m.mu.Lock()
+	events := m.pendingRemovals
+	m.pendingRemovals = nil
+	handler := m.onRemove
+	m.mu.Unlock()
+
+	if handler == nil {
+		return
+	}
+	for _, event := range events {
+		handler(event)
+	}
+}
+
+// replaceableLocked returns the pending transactions tx conflicts with.
+// A conflicting transaction may only be replaced if tx's fee exceeds the
+// conflicts' combined fees by at least ReplaceFeeBump percent.
+func (m *Mempool) replaceableLocked(tx *types.Transaction) ([]types.Hash, error) {
+	var conflicts []types.Hash
+	seen := make(map[types.Hash]bool)
+	var conflictFees uint64
+	for _, nullifier := range tx.Nullifiers {
+		txHash, exists := m.nullifiers[nullifier]
+		if !exists || seen[txHash] {
+			continue
+		}
+		seen[txHash] = true
+		conflicts = append(conflicts, txHash)
+		conflictFees += m.txs[txHash].Tx.Fee
+	}
+	if len(conflicts) == 0 {
+		return nil, nil
+	}
+
+	required := conflictFees + conflictFees*m.replaceFeeBump/100
+	if tx.Fee <= conflictFees || tx.Fee < required {
+		return nil, ErrReplacementUnderpriced
+	}
+	return conflicts, nil
+}
+
+// Expire evicts transactions that have been pending longer than the TTL
+// as of now. It returns the number evicted.
+func (m *Mempool) Expire(now time.Time) int {
+	defer m.notifyRemovals()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.txTTL <= 0 {
+		return 0
+	}
+
+	cutoff := now.Add(-m.txTTL).Unix()
+	var expired []types.Hash
+	for txHash, mpt := range m.txs {
+		if int64(mpt.AddedAt) <= cutoff {
+			expired = append(expired, txHash)
+		}
+	}
+	for _, txHash := range expired {
+		m.removeLocked(txHash, RemovalExpired, types.Hash{})
+	}
+	return len(expired)
+}
+
+// Run evicts expired transactions every ExpiryInterval until ctx is done
+func (m *Mempool) Run(ctx context.Context) error {
+	interval := m.expiryInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			m.Expire(now)
+		}
+	}
+}
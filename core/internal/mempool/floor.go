@@ -0,0 +1,116 @@
+package mempool
+
+import (
+	"math"
+	"time"
+
+	"github.com/ccoin/core/internal/economics"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// The adaptive fee floor is a minimum fee rate, per unit of gas, that
+// rises under pressure and decays once it passes, as Bitcoin Core's
+// rolling minimum fee does. Evicting a transaction from a full pool
+// raises it above the evicted rate, so spam cannot cycle the pool at the
+// same price; a pool that stays backlogged across FloorBacklogBlocks
+// blocks raises it to the lowest pending rate. It halves every
+// FloorHalfLife (faster while the pool is mostly empty), counted from
+// the first block after the last rise, and drops to zero below half of
+// FloorIncrement.
+
+// feeRate returns a transaction's fee per unit of gas
+func feeRate(tx *types.Transaction) float64 {
+	return float64(tx.Fee) / float64(economics.EstimateGas(tx))
+}
+
+// MinFeeRate returns the fee rate per gas a transaction must pay to enter
+// the pool now, rounded up; zero when the floor is inactive
+func (m *Mempool) MinFeeRate() uint64 {
+	return m.MinFeeRateAt(time.Now())
+}
+
+// MinFeeRateAt returns the fee floor as decayed to now
+func (m *Mempool) MinFeeRateAt(now time.Time) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return uint64(math.Ceil(m.floorLocked(now)))
+}
+
+// floorLocked decays the floor to now and returns it
+func (m *Mempool) floorLocked(now time.Time) float64 {
+	if m.floor == 0 || !m.floorBlockSeen {
+		return m.floor
+	}
+	elapsed := now.Sub(m.floorUpdated)
+	if elapsed <= 0 {
+		return m.floor
+	}
+
+	halfLife := m.floorHalfLife
+	switch {
+	case len(m.txs) < m.maxSize/4:
+		halfLife /= 4
+	case len(m.txs) < m.maxSize/2:
+		halfLife /= 2
+	}
+	m.floor *= math.Exp2(-elapsed.Seconds() / halfLife.Seconds())
+	m.floorUpdated = now
+	if m.floor < float64(m.floorIncrement)/2 {
+		m.floor = 0
+	}
+	return m.floor
+}
+
+// raiseFloorLocked raises the floor to at least rate. It holds until the
+// next block.
+func (m *Mempool) raiseFloorLocked(rate float64, now time.Time) {
+	if m.floorHalfLife <= 0 {
+		return
+	}
+	if rate > m.floorLocked(now) {
+		m.floor = rate
+		m.log.Info("fee floor raised", "min_fee_rate", uint64(math.Ceil(rate)), "pending", len(m.txs))
+	}
+	m.floorUpdated = now
+	m.floorBlockSeen = false
+}
+
+// checkFloorLocked rejects a transaction paying below the floor
+func (m *Mempool) checkFloorLocked(tx *types.Transaction, now time.Time) error {
+	if m.floorHalfLife <= 0 {
+		return nil
+	}
+	if floor := m.floorLocked(now); floor > 0 && feeRate(tx) < floor {
+		return ErrInsufficientFee
+	}
+	return nil
+}
+
+// blockFloorLocked lets the floor decay after a block and raises it when
+// the pool has stayed backlogged through enough blocks
+func (m *Mempool) blockFloorLocked(now time.Time) {
+	if m.floorHalfLife <= 0 {
+		return
+	}
+	if m.floorBlockSeen {
+		m.floorLocked(now)
+	} else {
+		// Decay runs from the first block after a rise
+		m.floorUpdated = now
+		m.floorBlockSeen = true
+	}
+
+	if len(m.txs) == 0 || float64(len(m.txs)) < m.floorBacklog*float64(m.maxSize) {
+		m.backlogBlocks = 0
+		return
+	}
+	m.backlogBlocks++
+	if m.backlogBlocks < m.floorBacklogBlocks {
+		return
+	}
+	lowest := math.Inf(1)
+	for _, mpt := range m.txs {
+		lowest = math.Min(lowest, feeRate(mpt.Tx))
+	}
+	m.raiseFloorLocked(lowest, now)
+}
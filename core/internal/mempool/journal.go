@@ -0,0 +1,333 @@
+package mempool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ccoin/core/internal/atrest"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Journal errors
+var (
+	ErrJournalOpen    = errors.New("mempool journal already open")
+	ErrCorruptJournal = errors.New("corrupt mempool journal record")
+)
+
+// DefaultJournalFile is the journal's file name within the data directory
+const DefaultJournalFile = "mempool.dat"
+
+// Journal record types
+const (
+	recordAdd    byte = 1
+	recordRemove byte = 2
+
+	// recordSealed marks a record whose payload is encrypted at rest
+	recordSealed byte = 0x80
+)
+
+// maxRecordSize bounds a single journal record
+const maxRecordSize = 16 * 1024 * 1024
+
+// compactThreshold is the number of dead records tolerated before the
+// journal is rewritten
+const compactThreshold = 1000
+
+// journalEntry is a transaction recovered from the journal
+type journalEntry struct {
+	tx      *types.Transaction
+	addedAt int64
+}
+
+// journal is an append-only log of mempool additions and removals. Each
+// record is [type][length][payload][crc32]; a torn record at the tail,
+// left by a crash mid-write, ends replay. With a cipher, payloads are
+// sealed and their type carries recordSealed.
+type journal struct {
+	path    string
+	file    *os.File
+	cipher  *atrest.Cipher
+	live    map[types.Hash]int64 // tx hash -> unix time added
+	records int
+}
+
+// OpenJournal replays the journal at path into the pool, then journals
+// every later change. Entries older than the configured expiry are
+// dropped, and accept, if set, re-validates each recovered transaction
+// against current chain state (e.g. nullifiers spent while the node was
+// down). The journal is compacted to the recovered transactions. It
+// returns the number of transactions restored.
+func (m *Mempool) OpenJournal(path string, accept func(tx *types.Transaction) error) (int, error) {
+	m.mu.Lock()
+	if m.journal != nil {
+		m.mu.Unlock()
+		return 0, ErrJournalOpen
+	}
+	m.mu.Unlock()
+
+	c := m.journalCipher.Sub(DefaultJournalFile)
+	entries, err := replayJournal(path, c)
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-m.journalExpiry).Unix()
+	j := &journal{path: path, cipher: c, live: make(map[types.Hash]int64)}
+	restored := make([]journalEntry, 0, len(entries))
+	for _, e := range entries {
+		if m.journalExpiry > 0 && e.addedAt < cutoff {
+			continue
+		}
+		if accept != nil && accept(e.tx) != nil {
+			continue
+		}
+		if err := m.add(e.tx, time.Unix(e.addedAt, 0)); err != nil {
+			continue
+		}
+		restored = append(restored, e)
+		j.live[e.tx.TxHash] = e.addedAt
+	}
+
+	if err := j.rewrite(restored); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	m.journal = j
+	m.mu.Unlock()
+
+	return len(restored), nil
+}
+
+// CloseJournal stops journaling, syncing the journal to disk so every
+// change made before it survives a restart, and closes the journal file
+func (m *Mempool) CloseJournal() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.journal == nil {
+		return nil
+	}
+	err := m.journal.file.Sync()
+	if closeErr := m.journal.file.Close(); err == nil {
+		err = closeErr
+	}
+	m.journal = nil
+	return err
+}
+
+// replayJournal reads the live transactions from a journal in the order
+// they were added, opening sealed records with c. A missing journal is
+// empty.
+func replayJournal(path string, c *atrest.Cipher) ([]journalEntry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	live := make(map[types.Hash]journalEntry)
+	r := bufio.NewReader(f)
+	for {
+		kind, payload, err := readRecord(r)
+		if err != nil {
+			// io.EOF, or a torn record left by a crash
+			break
+		}
+		if kind&recordSealed != 0 {
+			if c == nil {
+				return nil, fmt.Errorf("%s: %w", path, atrest.ErrEncrypted)
+			}
+			kind &^= recordSealed
+			if payload, err = c.Open(payload, []byte{kind}); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+
+		switch kind {
+		case recordAdd:
+			if len(payload) < 8 {
+				continue
+			}
+			var tx types.Transaction
+			if err := gob.NewDecoder(bytes.NewReader(payload[8:])).Decode(&tx); err != nil {
+				continue
+			}
+			live[tx.TxHash] = journalEntry{tx: &tx, addedAt: int64(binary.BigEndian.Uint64(payload))}
+		case recordRemove:
+			var hash types.Hash
+			copy(hash[:], payload)
+			delete(live, hash)
+		}
+	}
+
+	entries := make([]journalEntry, 0, len(live))
+	for _, e := range live {
+		entries = append(entries, e)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].addedAt < entries[j].addedAt
+	})
+	return entries, nil
+}
+
+// readRecord reads one record, verifying its checksum
+func readRecord(r io.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxRecordSize {
+		return 0, nil, ErrCorruptJournal
+	}
+
+	body := make([]byte, size+4)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, ErrCorruptJournal
+	}
+	payload := body[:size]
+	sum := crc32.NewIEEE()
+	sum.Write(header[:1])
+	sum.Write(payload)
+	if sum.Sum32() != binary.BigEndian.Uint32(body[size:]) {
+		return 0, nil, ErrCorruptJournal
+	}
+	return header[0], payload, nil
+}
+
+// encodeRecord frames a record for the journal, sealing its payload
+// with c if set
+func encodeRecord(c *atrest.Cipher, kind byte, payload []byte) []byte {
+	if c != nil {
+		payload = c.Seal(payload, []byte{kind})
+		kind |= recordSealed
+	}
+	buf := make([]byte, 0, len(payload)+9)
+	buf = append(buf, kind)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(payload)))
+	buf = append(buf, payload...)
+
+	sum := crc32.NewIEEE()
+	sum.Write(buf[:1])
+	sum.Write(payload)
+	return binary.BigEndian.AppendUint32(buf, sum.Sum32())
+}
+
+// encodeAdd frames an addition record
+func encodeAdd(c *atrest.Cipher, tx *types.Transaction, addedAt int64) ([]byte, error) {
+	var payload bytes.Buffer
+	payload.Write(binary.BigEndian.AppendUint64(nil, uint64(addedAt)))
+	if err := gob.NewEncoder(&payload).Encode(tx); err != nil {
+		return nil, err
+	}
+	return encodeRecord(c, recordAdd, payload.Bytes()), nil
+}
+
+// add journals an accepted transaction
+func (j *journal) add(tx *types.Transaction, addedAt int64) error {
+	record, err := encodeAdd(j.cipher, tx, addedAt)
+	if err != nil {
+		return err
+	}
+	if err := j.append(record); err != nil {
+		return err
+	}
+	j.live[tx.TxHash] = addedAt
+	return nil
+}
+
+// remove journals a removal. A lost removal is harmless: replay
+// re-validates every transaction.
+func (j *journal) remove(txHash types.Hash) error {
+	if _, exists := j.live[txHash]; !exists {
+		return nil
+	}
+	delete(j.live, txHash)
+	return j.append(encodeRecord(j.cipher, recordRemove, txHash[:]))
+}
+
+// append writes a record, compacting the journal once dead records
+// outnumber live ones by compactThreshold
+func (j *journal) append(record []byte) error {
+	if _, err := j.file.Write(record); err != nil {
+		return err
+	}
+	j.records++
+	if j.records > 2*len(j.live)+compactThreshold {
+		return j.compact()
+	}
+	return nil
+}
+
+// compact rewrites the journal with only its live transactions
+func (j *journal) compact() error {
+	entries, err := replayJournal(j.path, j.cipher)
+	if err != nil {
+		return err
+	}
+	if err := j.file.Close(); err != nil {
+		return err
+	}
+
+	live := entries[:0]
+	for _, e := range entries {
+		if _, exists := j.live[e.tx.TxHash]; exists {
+			live = append(live, e)
+		}
+	}
+	return j.rewrite(live)
+}
+
+// rewrite atomically replaces the journal with entries and reopens it
+// for appending
+func (j *journal) rewrite(entries []journalEntry) error {
+	tmp := j.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	for _, e := range entries {
+		record, err := encodeAdd(j.cipher, e.tx, e.addedAt)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		if _, err := w.Write(record); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		return err
+	}
+
+	j.file, err = os.OpenFile(j.path, os.O_APPEND|os.O_WRONLY, 0600)
+	j.records = len(entries)
+	return err
+}
@@ -4,20 +4,27 @@ package mempool
 import (
 	"context"
 	"errors"
+	"log/slog"
 	"sort"
 	"sync"
+	"time"
 
+	"github.com/ccoin/core/internal/atrest"
+	"github.com/ccoin/core/internal/logging"
+	"github.com/ccoin/core/internal/threshold"
 	"github.com/ccoin/core/pkg/types"
 )
 
 // Mempool errors
 var (
-	ErrPoolFull         = errors.New("mempool is full")
-	ErrTxAlreadyExists  = errors.New("transaction already in mempool")
-	ErrTxExpired        = errors.New("transaction expired")
-	ErrInsufficientFee  = errors.New("insufficient transaction fee")
-	ErrDoubleSpend      = errors.New("nullifier already spent")
-	ErrInvalidProof     = errors.New("invalid zk-SNARK proof")
+	ErrPoolFull        = errors.New("mempool is full")
+	ErrTxAlreadyExists = errors.New("transaction already in mempool")
+	ErrTxExpired       = errors.New("transaction expired")
+	ErrInsufficientFee = errors.New("insufficient transaction fee")
+	ErrDoubleSpend     = errors.New("nullifier already spent")
+	ErrInvalidProof    = errors.New("invalid zk-SNARK proof")
+
+	ErrReplacementUnderpriced = errors.New("replacement transaction does not pay the required fee bump")
 )
 
 // Mempool manages pending transactions
@@ -34,9 +41,49 @@ type Mempool struct {
 	nullifiers map[types.Hash]types.Hash // nullifier -> tx hash
 
 	// Config
-	maxSize     int
-	minFee      uint64
-	maxTxPerBlock int
+	maxSize        int
+	minFee         uint64
+	maxTxPerBlock  int
+	txTTL          time.Duration
+	replaceFeeBump uint64
+	expiryInterval time.Duration
+
+	// Adaptive fee floor in fee per gas; see floor.go
+	floor              float64
+	floorUpdated       time.Time
+	floorBlockSeen     bool
+	floorHalfLife      time.Duration
+	floorIncrement     uint64
+	floorBacklog       float64
+	floorBacklogBlocks int
+	backlogBlocks      int
+
+	// Removal notification; events queue under mu and are delivered
+	// after it is released
+	onRemove        func(RemovalEvent)
+	pendingRemovals []RemovalEvent
+
+	// Recent submissions keyed by client request ID
+	submissions *SubmissionCache
+	submitMu    sync.Mutex
+
+	// Crash-recovery journal, nil until OpenJournal
+	journal       *journal
+	journalExpiry time.Duration
+	journalCipher *atrest.Cipher
+
+	// Encrypted mempool mode; sealKey is nil until SetCommitteeKey
+	requireSealed bool
+	sealEpoch     uint64
+	sealKey       *threshold.PublicKey
+
+	// Anchor freshness; nil disables anchor checks
+	anchors AnchorTracker
+
+	// Disclosure policy; nil disables disclosure checks
+	disclosures DisclosurePolicy
+
+	log *slog.Logger
 }
 
 // MempoolTx wraps a transaction with mempool metadata
@@ -46,6 +93,10 @@ type MempoolTx struct {
 	Priority  float64 // fee / size
 	Size      int
 	Validated bool
+
+	// AnchorAge is how many commitment tree roots were added after the
+	// transaction's anchor, as of the last check
+	AnchorAge int
 }
 
 // Config holds mempool configuration
@@ -53,6 +104,52 @@ type Config struct {
 	MaxSize       int
 	MinFee        uint64
 	MaxTxPerBlock int
+
+	// SubmissionRetention is how long request IDs are remembered
+	SubmissionRetention time.Duration
+
+	// MaxSubmissions bounds the number of remembered request IDs
+	MaxSubmissions int
+
+	// JournalExpiry drops journaled transactions older than this on
+	// replay; zero keeps them indefinitely
+	JournalExpiry time.Duration
+
+	// JournalCipher encrypts the journal at rest; nil writes it in the
+	// clear
+	JournalCipher *atrest.Cipher
+
+	// TxTTL is how long a transaction may stay pending before it is
+	// evicted; zero disables expiry
+	TxTTL time.Duration
+
+	// ExpiryInterval is how often Run evicts expired transactions
+	ExpiryInterval time.Duration
+
+	// ReplaceFeeBump is the percentage by which a replacement must
+	// exceed the combined fees of the transactions it conflicts with
+	ReplaceFeeBump uint64
+
+	// RequireSealed rejects transactions carrying operations in the
+	// clear, so every operation is threshold-encrypted until inclusion
+	RequireSealed bool
+
+	// FloorHalfLife is how long the adaptive fee floor takes to halve
+	// once blocks resume; zero disables the floor
+	FloorHalfLife time.Duration
+
+	// FloorIncrement is the fee per gas an eviction adds to the evicted
+	// transaction's rate; floors below half of it reset to zero
+	FloorIncrement uint64
+
+	// FloorBacklog is the fraction of MaxSize the pool must stay above,
+	// for FloorBacklogBlocks consecutive blocks, before the floor rises
+	// to the lowest pending rate
+	FloorBacklog       float64
+	FloorBacklogBlocks int
+
+	// Logger receives the pool's logs; nil uses the mempool module logger
+	Logger *slog.Logger
 }
 
 // DefaultConfig returns default mempool configuration
@@ -61,6 +158,20 @@ func DefaultConfig() *Config {
 		MaxSize:       10000,
 		MinFee:        1,
 		MaxTxPerBlock: 1000,
+
+		SubmissionRetention: 24 * time.Hour,
+		MaxSubmissions:      100000,
+
+		JournalExpiry: 72 * time.Hour,
+
+		TxTTL:          72 * time.Hour,
+		ExpiryInterval: time.Minute,
+		ReplaceFeeBump: 10,
+
+		FloorHalfLife:      12 * time.Hour,
+		FloorIncrement:     100,
+		FloorBacklog:       0.5,
+		FloorBacklogBlocks: 6,
 	}
 }
 
@@ -70,18 +181,50 @@ func NewMempool(cfg *Config) *Mempool {
 		cfg = DefaultConfig()
 	}
 
+	logger := cfg.Logger
+	if logger == nil {
+		logger = logging.Module("mempool")
+	}
+
 	return &Mempool{
-		txs:         make(map[types.Hash]*MempoolTx),
-		queue:       make([]*MempoolTx, 0),
-		nullifiers:  make(map[types.Hash]types.Hash),
-		maxSize:     cfg.MaxSize,
-		minFee:      cfg.MinFee,
-		maxTxPerBlock: cfg.MaxTxPerBlock,
+		txs:            make(map[types.Hash]*MempoolTx),
+		queue:          make([]*MempoolTx, 0),
+		nullifiers:     make(map[types.Hash]types.Hash),
+		maxSize:        cfg.MaxSize,
+		minFee:         cfg.MinFee,
+		maxTxPerBlock:  cfg.MaxTxPerBlock,
+		submissions:    NewSubmissionCache(cfg.SubmissionRetention, cfg.MaxSubmissions),
+		journalExpiry:  cfg.JournalExpiry,
+		journalCipher:  cfg.JournalCipher,
+		txTTL:          cfg.TxTTL,
+		replaceFeeBump: cfg.ReplaceFeeBump,
+		expiryInterval: cfg.ExpiryInterval,
+		requireSealed:  cfg.RequireSealed,
+
+		floorHalfLife:      cfg.FloorHalfLife,
+		floorIncrement:     cfg.FloorIncrement,
+		floorBacklog:       cfg.FloorBacklog,
+		floorBacklogBlocks: cfg.FloorBacklogBlocks,
+
+		log: logger,
 	}
 }
 
-// Add adds a transaction to the mempool
+// Add adds a transaction to the mempool. A transaction spending
+// nullifiers already claimed by pending transactions replaces them if its
+// fee exceeds their combined fees by the configured bump.
 func (m *Mempool) Add(tx *types.Transaction) error {
+	return m.add(tx, time.Now())
+}
+
+// add inserts a transaction first seen at addedAt
+func (m *Mempool) add(tx *types.Transaction, addedAt time.Time) error {
+	// Check required disclosures before taking the lock
+	if err := m.checkDisclosures(tx); err != nil {
+		return err
+	}
+
+	defer m.notifyRemovals()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -90,23 +233,36 @@ func (m *Mempool) Add(tx *types.Transaction) error {
 		return ErrTxAlreadyExists
 	}
 
-	// Check mempool size
-	if len(m.txs) >= m.maxSize {
-		// Try to evict low-priority transactions
-		if !m.evictLowestPriority(tx.Fee) {
-			return ErrPoolFull
-		}
-	}
-
 	// Check minimum fee
 	if tx.Fee < m.minFee {
 		return ErrInsufficientFee
 	}
+	if err := m.checkFloorLocked(tx, time.Now()); err != nil {
+		return err
+	}
+
+	// Check sealed payload
+	if err := m.checkSealedLocked(tx); err != nil {
+		return err
+	}
+
+	// Check the anchor is still accepted
+	anchorAge, err := m.checkAnchorLocked(tx)
+	if err != nil {
+		return err
+	}
 
 	// Check for double-spend (nullifier already in pool)
-	for _, nullifier := range tx.Nullifiers {
-		if existingTx, exists := m.nullifiers[nullifier]; exists {
-			return errors.New("nullifier conflicts with tx " + existingTx.String())
+	conflicts, err := m.replaceableLocked(tx)
+	if err != nil {
+		return err
+	}
+
+	// Check mempool size
+	if len(m.txs)-len(conflicts) >= m.maxSize {
+		// Try to evict low-priority transactions
+		if !m.evictLowestPriority(tx.Fee) {
+			return ErrPoolFull
 		}
 	}
 
@@ -114,12 +270,24 @@ func (m *Mempool) Add(tx *types.Transaction) error {
 	size := estimateTxSize(tx)
 	priority := float64(tx.Fee) / float64(size)
 
+	// Persist before accepting so a restart does not lose the transaction
+	if m.journal != nil {
+		if err := m.journal.add(tx, addedAt.Unix()); err != nil {
+			return err
+		}
+	}
+
+	for _, txHash := range conflicts {
+		m.removeLocked(txHash, RemovalReplaced, tx.TxHash)
+	}
+
 	mpt := &MempoolTx{
 		Tx:        tx,
-		AddedAt:   uint64(currentTimestamp()),
+		AddedAt:   uint64(addedAt.Unix()),
 		Priority:  priority,
 		Size:      size,
 		Validated: false,
+		AnchorAge: anchorAge,
 	}
 
 	// Add to index
@@ -138,9 +306,15 @@ func (m *Mempool) Add(tx *types.Transaction) error {
 
 // Remove removes a transaction from the mempool
 func (m *Mempool) Remove(txHash types.Hash) {
+	defer m.notifyRemovals()
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.removeLocked(txHash, RemovalDropped, types.Hash{})
+}
 
+// removeLocked removes a transaction, journals the removal and queues a
+// removal event
+func (m *Mempool) removeLocked(txHash types.Hash, reason RemovalReason, replacedBy types.Hash) {
 	mpt, exists := m.txs[txHash]
 	if !exists {
 		return
@@ -156,6 +330,19 @@ func (m *Mempool) Remove(txHash types.Hash) {
 
 	// Remove from queue
 	m.removeFromQueue(txHash)
+
+	if m.journal != nil {
+		_ = m.journal.remove(txHash)
+	}
+	m.log.Debug("transaction removed", "tx", txHash.String(), "reason", reason.String())
+
+	if m.onRemove != nil {
+		m.pendingRemovals = append(m.pendingRemovals, RemovalEvent{
+			Tx:         mpt.Tx,
+			Reason:     reason,
+			ReplacedBy: replacedBy,
+		})
+	}
 }
 
 // Get retrieves a transaction from the mempool
@@ -233,36 +420,21 @@ func (m *Mempool) SelectTransactions(maxCount int, maxSize int) []*types.Transac
 
 // RemoveConfirmed removes transactions that have been confirmed in a block
 func (m *Mempool) RemoveConfirmed(block *types.Block) {
+	defer m.notifyRemovals()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	for _, tx := range block.Transactions {
-		if mpt, exists := m.txs[tx.TxHash]; exists {
-			// Remove from index
-			delete(m.txs, tx.TxHash)
-
-			// Remove nullifiers
-			for _, nullifier := range mpt.Tx.Nullifiers {
-				delete(m.nullifiers, nullifier)
-			}
-
-			// Remove from queue
-			m.removeFromQueue(tx.TxHash)
-		}
+		m.removeLocked(tx.TxHash, RemovalConfirmed, types.Hash{})
 
 		// Also remove any conflicting transactions
 		for _, nullifier := range tx.Nullifiers {
 			if conflictingTxHash, exists := m.nullifiers[nullifier]; exists {
-				if mpt, exists := m.txs[conflictingTxHash]; exists {
-					delete(m.txs, conflictingTxHash)
-					for _, n := range mpt.Tx.Nullifiers {
-						delete(m.nullifiers, n)
-					}
-					m.removeFromQueue(conflictingTxHash)
-				}
+				m.removeLocked(conflictingTxHash, RemovalConflict, types.Hash{})
 			}
 		}
 	}
+	m.blockFloorLocked(time.Now())
 }
 
 // Size returns the number of transactions in the mempool
@@ -327,7 +499,8 @@ func (m *Mempool) evictLowestPriority(newFee uint64) bool {
 
 	lowest := m.queue[len(m.queue)-1]
 	if newFee > lowest.Tx.Fee {
-		m.Remove(lowest.Tx.TxHash)
+		m.removeLocked(lowest.Tx.TxHash, RemovalEvicted, types.Hash{})
+		m.raiseFloorLocked(feeRate(lowest.Tx)+float64(m.floorIncrement), time.Now())
 		return true
 	}
 	return false
@@ -350,12 +523,12 @@ func estimateTxSize(tx *types.Transaction) int {
 	// Memo
 	size += len(tx.Memo)
 
-	return size
-}
+	// Sealed operations
+	if tx.Sealed != nil {
+		size += len(tx.Sealed.Serialize())
+	}
 
-// currentTimestamp returns current unix timestamp
-func currentTimestamp() int64 {
-	return int64(0) // Placeholder - use time.Now().Unix() in production
+	return size
 }
 
 // Validate validates a transaction's zk-SNARK proof
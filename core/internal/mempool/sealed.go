@@ -0,0 +1,54 @@
+package mempool
+
+import (
+	"errors"
+
+	"github.com/ccoin/core/internal/threshold"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Sealed transaction errors
+var (
+	ErrNoCommitteeKey     = errors.New("no decryption committee key for sealed transactions")
+	ErrWrongSealEpoch     = errors.New("sealed payload encrypted to a different committee epoch")
+	ErrInvalidSealed      = errors.New("invalid sealed payload")
+	ErrUnsealedOperations = errors.New("operations must be sealed to the committee")
+)
+
+// SetCommitteeKey sets the committee key sealed transactions must be
+// encrypted to. Pending transactions sealed to an older epoch stay in the
+// pool; the committee keeps old shares until they are included.
+func (m *Mempool) SetCommitteeKey(epoch uint64, pk *threshold.PublicKey) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sealEpoch = epoch
+	m.sealKey = pk
+}
+
+// checkSealedLocked enforces the encrypted mempool rules. Sealed payloads
+// must be well formed and encrypted to the current committee; in sealed
+// mode operations may not travel in the clear.
+func (m *Mempool) checkSealedLocked(tx *types.Transaction) error {
+	if tx.Sealed == nil {
+		if m.requireSealed && (tx.Inference != nil || tx.PayoutChange != nil || tx.Delegation != nil) {
+			return ErrUnsealedOperations
+		}
+		return nil
+	}
+
+	// Clear operations alongside a sealed payload are not covered by the
+	// transaction hash
+	if tx.Inference != nil || tx.PayoutChange != nil || tx.Delegation != nil {
+		return ErrInvalidSealed
+	}
+	if m.sealKey == nil {
+		return ErrNoCommitteeKey
+	}
+	if tx.Sealed.Epoch != m.sealEpoch {
+		return ErrWrongSealEpoch
+	}
+	if threshold.Verify(tx.Sealed, tx.SealLabel()) != nil {
+		return ErrInvalidSealed
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package mempool
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Submission errors
+var (
+	ErrRequestIDTooLong  = errors.New("request id too long")
+	ErrRequestIDConflict = errors.New("request id already used for a different transaction")
+)
+
+// MaxRequestIDLength bounds the size of client-provided request IDs
+const MaxRequestIDLength = 128
+
+// SubmissionResult is the outcome of a transaction submission, remembered
+// so that retries carrying the same request ID get the original answer
+type SubmissionResult struct {
+	RequestID   string
+	TxHash      types.Hash
+	Err         error
+	SubmittedAt time.Time
+}
+
+// SubmissionCache remembers recent submission results keyed by request ID
+type SubmissionCache struct {
+	mu sync.Mutex
+
+	// Results indexed by request ID
+	results map[string]*SubmissionResult
+
+	// Insertion order for expiry
+	order []string
+
+	// How long results are retained
+	retention time.Duration
+
+	// Upper bound on remembered results
+	maxEntries int
+
+	// Clock (overridable for tests)
+	now func() time.Time
+}
+
+// NewSubmissionCache creates a new submission cache
+func NewSubmissionCache(retention time.Duration, maxEntries int) *SubmissionCache {
+	return &SubmissionCache{
+		results:    make(map[string]*SubmissionResult),
+		order:      make([]string, 0),
+		retention:  retention,
+		maxEntries: maxEntries,
+		now:        time.Now,
+	}
+}
+
+// Get returns the remembered result for a request ID, if still retained
+func (c *SubmissionCache) Get(requestID string) (*SubmissionResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pruneLocked()
+	result, exists := c.results[requestID]
+	return result, exists
+}
+
+// Put records the result for a request ID
+func (c *SubmissionCache) Put(result *SubmissionResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.results[result.RequestID]; !exists {
+		c.order = append(c.order, result.RequestID)
+	}
+	c.results[result.RequestID] = result
+
+	c.pruneLocked()
+}
+
+// Len returns the number of retained results
+func (c *SubmissionCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.results)
+}
+
+// pruneLocked drops expired entries and enforces the size bound
+func (c *SubmissionCache) pruneLocked() {
+	cutoff := c.now().Add(-c.retention)
+
+	drop := 0
+	for drop < len(c.order) {
+		id := c.order[drop]
+		result := c.results[id]
+		expired := result == nil || result.SubmittedAt.Before(cutoff)
+		overflow := c.maxEntries > 0 && len(c.order)-drop > c.maxEntries
+		if !expired && !overflow {
+			break
+		}
+		delete(c.results, id)
+		drop++
+	}
+
+	if drop > 0 {
+		c.order = append(c.order[:0], c.order[drop:]...)
+	}
+}
+
+// Submit adds a transaction to the mempool under a client-provided request ID.
+// If the same request ID was seen within the retention window, the original
+// result is returned and the transaction is not re-admitted. An empty request
+// ID behaves exactly like Add.
+func (m *Mempool) Submit(requestID string, tx *types.Transaction) (types.Hash, error) {
+	if requestID == "" {
+		return tx.TxHash, m.Add(tx)
+	}
+	if len(requestID) > MaxRequestIDLength {
+		return types.Hash{}, ErrRequestIDTooLong
+	}
+
+	// Serialize submissions so that concurrent retries cannot both admit
+	m.submitMu.Lock()
+	defer m.submitMu.Unlock()
+
+	if prev, exists := m.submissions.Get(requestID); exists {
+		if prev.TxHash != tx.TxHash {
+			return prev.TxHash, ErrRequestIDConflict
+		}
+		return prev.TxHash, prev.Err
+	}
+
+	err := m.Add(tx)
+
+	// Only remember outcomes that a retry would not be able to change
+	if err == nil || errors.Is(err, ErrTxAlreadyExists) {
+		m.submissions.Put(&SubmissionResult{
+			RequestID:   requestID,
+			TxHash:      tx.TxHash,
+			Err:         err,
+			SubmittedAt: m.submissions.now(),
+		})
+	}
+
+	return tx.TxHash, err
+}
+
+// LookupSubmission returns the remembered result for a request ID
+func (m *Mempool) LookupSubmission(requestID string) (*SubmissionResult, bool) {
+	return m.submissions.Get(requestID)
+}
@@ -0,0 +1,104 @@
+package miner
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"os"
+
+	"github.com/ccoin/core/internal/keys"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// DefaultKeyFile is the miner key's file name in the data directory
+const DefaultKeyFile = "miner.key"
+
+// Identity key errors
+var (
+	ErrKeyExists      = errors.New("miner key already exists")
+	ErrInvalidKeyFile = errors.New("invalid miner key file")
+)
+
+// LoadKey reads a miner identity key saved by CreateKey
+func LoadKey(path string) (ed25519.PrivateKey, error) {
+	seed, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, ErrInvalidKeyFile
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// CreateKey generates a miner identity key and writes its seed to path,
+// readable only by the owner. The miner address derives from the key;
+// rewards can still be paid elsewhere with a payout change.
+func CreateKey(path string) (ed25519.PrivateKey, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, ErrKeyExists
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, key.Seed(), 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// StaticKey is a KeySource holding a single identity key, such as one
+// from LoadKey, for miners whose key is kept outside the wallet
+type StaticKey struct {
+	key ed25519.PrivateKey
+}
+
+// NewStaticKey creates a key source for key
+func NewStaticKey(key ed25519.PrivateKey) *StaticKey {
+	return &StaticKey{key: key}
+}
+
+// Address returns the miner address the key derives
+func (k *StaticKey) Address() types.Address {
+	return types.AddressFromPublicKey(k.key.Public().(ed25519.PublicKey))
+}
+
+// SigningKey returns the key if addr is its address
+func (k *StaticKey) SigningKey(addr types.Address) (ed25519.PrivateKey, error) {
+	if addr != k.Address() {
+		return nil, ErrKeyMismatch
+	}
+	return k.key, nil
+}
+
+// ProviderKey is a KeySource over a key held by a key provider, such as
+// a KMS or HSM. The private key never leaves the provider, so the miner
+// signs through MiningSigner.
+type ProviderKey struct {
+	signer keys.Signer
+}
+
+// NewProviderKey creates a key source for a provider's key
+func NewProviderKey(signer keys.Signer) *ProviderKey {
+	return &ProviderKey{signer: signer}
+}
+
+// Address returns the miner address the key derives
+func (k *ProviderKey) Address() types.Address {
+	return types.AddressFromPublicKey(k.signer.Public())
+}
+
+// SigningKey fails: the private key cannot be exported
+func (k *ProviderKey) SigningKey(addr types.Address) (ed25519.PrivateKey, error) {
+	return nil, keys.ErrNotExportable
+}
+
+// MiningSigner returns the key's signer if addr is its address
+func (k *ProviderKey) MiningSigner(addr types.Address) (keys.Signer, error) {
+	if addr != k.Address() {
+		return nil, ErrKeyMismatch
+	}
+	return k.signer, nil
+}
@@ -0,0 +1,553 @@
+// Package miner assembles blocks from PoUW results: it selects parents,
+// fills the block from the mempool, embeds the result and the miner's VRF
+// proof, meets the difficulty target, signs the header with the miner's
+// identity key and submits the block to the DAG and the network.
+package miner
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ccoin/core/internal/dag"
+	"github.com/ccoin/core/internal/keys"
+	"github.com/ccoin/core/internal/mempool"
+	"github.com/ccoin/core/internal/p2p"
+	"github.com/ccoin/core/internal/pouw"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Miner errors
+var (
+	ErrAlreadyRunning   = errors.New("miner already running")
+	ErrNoParents        = errors.New("no blocks to build on")
+	ErrDifficultyNotMet = errors.New("no nonce meets the difficulty target")
+	ErrKeyMismatch      = errors.New("mining key does not match the miner address")
+)
+
+// KeySource provides the identity key a miner proves VRF outputs and
+// signs headers with
+type KeySource interface {
+	SigningKey(addr types.Address) (ed25519.PrivateKey, error)
+}
+
+// SignerSource is a KeySource whose key signs in place, such as one held
+// by a KMS or HSM. The miner uses MiningSigner when its KeySource
+// implements it.
+type SignerSource interface {
+	MiningSigner(addr types.Address) (keys.Signer, error)
+}
+
+// Broadcaster relays mined blocks to peers
+type Broadcaster interface {
+	BroadcastBlock(data []byte) error
+}
+
+// ReputationSource reports the miner's current reputation
+type ReputationSource interface {
+	GetMinerReputation(addr types.Address) float64
+}
+
+// PayoutSource resolves the payout address registered for a miner
+type PayoutSource interface {
+	PayoutAddress(ctx context.Context, miner types.Address, height uint64) types.Address
+}
+
+// DifficultySource computes the difficulty target of a block on parents
+type DifficultySource interface {
+	CalculateDifficulty(ctx context.Context, parentHeaders []*types.BlockHeader) *big.Int
+}
+
+// Config holds miner configuration
+type Config struct {
+	// Address is the miner's identity address; its key must be available
+	// from the KeySource
+	Address types.Address
+
+	// MaxParents bounds the tips a block references
+	MaxParents int
+
+	// Block limits for transactions pulled from the mempool
+	MaxBlockTxs  int
+	MaxBlockSize int
+
+	// MaxNonceAttempts bounds the search for a header meeting the target
+	MaxNonceAttempts uint64
+
+	// ExtraData is copied into every header (at most 32 bytes)
+	ExtraData []byte
+}
+
+// DefaultConfig returns default miner configuration
+func DefaultConfig() *Config {
+	return &Config{
+		MaxParents:       8,
+		MaxBlockTxs:      types.MaxTransactionsPerBlock,
+		MaxBlockSize:     2 * 1024 * 1024,
+		MaxNonceAttempts: 1 << 24,
+	}
+}
+
+// Status reports the miner's state and output
+type Status struct {
+	Running     bool
+	Address     types.Address
+	Reputation  float64
+	CurrentTask types.Hash
+
+	BlocksMined     uint64
+	LastBlock       types.Hash
+	LastBlockHeight uint64
+	LastBlockTime   time.Time
+
+	// LastError is the most recent failure to produce a block
+	LastError string
+}
+
+// Miner coordinates block production: the PoUW engine trains on tasks and
+// the miner turns each result into a block
+type Miner struct {
+	mu sync.Mutex
+
+	config    *Config
+	dag       *dag.DAG
+	validator *dag.BlockValidator
+	pool      *mempool.Mempool
+	engine    *pouw.Engine
+	keys      KeySource
+
+	// Optional collaborators
+	broadcaster Broadcaster
+	reputation  ReputationSource
+	payouts     PayoutSource
+	difficulty  DifficultySource
+	committees  dag.CommitteeRoots
+	registry    dag.RegistryRoots
+	nullifiers  dag.NullifierRoots
+	states      dag.StateRoots
+	receipts    dag.ReceiptsRoots
+	onBlock     func(ctx context.Context, block *types.Block)
+
+	// Running state
+	cancel context.CancelFunc
+	status Status
+
+	// Template subscribers and the template they were last notified of
+	notifyMu    sync.Mutex
+	subscribers map[chan Notification]struct{}
+	template    *Template
+}
+
+// NewMiner creates a miner. Blocks are validated with validator before
+// they are added; a nil validator skips validation.
+func NewMiner(d *dag.DAG, validator *dag.BlockValidator, pool *mempool.Mempool, engine *pouw.Engine, keys KeySource, cfg *Config) *Miner {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+
+	m := &Miner{
+		config:    cfg,
+		dag:       d,
+		validator: validator,
+		pool:      pool,
+		engine:    engine,
+		keys:      keys,
+		status:    Status{Address: cfg.Address},
+
+		subscribers: make(map[chan Notification]struct{}),
+	}
+	engine.SetResultHandler(m.handleResult)
+	engine.SetTaskHandler(func(ctx context.Context, task *types.Task) {
+		m.refreshTemplate(ctx)
+	})
+	return m
+}
+
+// SetBroadcaster sets where mined blocks are relayed
+func (m *Miner) SetBroadcaster(b Broadcaster) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.broadcaster = b
+}
+
+// SetReputationSource sets where header reputation scores come from.
+// Without one the initial reputation is used.
+func (m *Miner) SetReputationSource(r ReputationSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reputation = r
+}
+
+// SetPayoutSource sets where the registered payout address comes from.
+// Without one rewards go to the miner address.
+func (m *Miner) SetPayoutSource(p PayoutSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.payouts = p
+}
+
+// SetDifficultySource sets how block targets are computed. Without one a
+// block keeps the highest target among its parents.
+func (m *Miner) SetDifficultySource(d DifficultySource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.difficulty = d
+}
+
+// SetCommitteeRoots sets the committee roots blocks opening an epoch
+// commit to
+func (m *Miner) SetCommitteeRoots(c dag.CommitteeRoots) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.committees = c
+}
+
+// SetRegistryRoots sets the model registry checkpoints blocks opening an
+// epoch commit to
+func (m *Miner) SetRegistryRoots(r dag.RegistryRoots) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.registry = r
+}
+
+// SetNullifierRoots sets the nullifier accumulator blocks commit to
+func (m *Miner) SetNullifierRoots(n dag.NullifierRoots) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nullifiers = n
+}
+
+// SetStateRoots sets the state commitment blocks commit to
+func (m *Miner) SetStateRoots(s dag.StateRoots) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states = s
+}
+
+// SetReceiptsRoots sets the transaction receipts blocks commit to
+func (m *Miner) SetReceiptsRoots(r dag.ReceiptsRoots) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.receipts = r
+}
+
+// SetBlockHandler sets a callback run after each mined block is added
+func (m *Miner) SetBlockHandler(fn func(ctx context.Context, block *types.Block)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onBlock = fn
+}
+
+// Start begins mining under the configured address
+func (m *Miner) Start() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.cancel != nil {
+		return ErrAlreadyRunning
+	}
+	key, err := m.signer()
+	if err != nil {
+		return err
+	}
+	if types.AddressFromPublicKey(key.Public()) != m.config.Address {
+		return ErrKeyMismatch
+	}
+	// Tasks are drawn by VRF, which not every key provider can prove
+	if _, err := key.ProveVRF([]byte("CCOIN_VRF_CHECK")); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := m.engine.StartMining(ctx, key); err != nil {
+		cancel()
+		return err
+	}
+	m.cancel = cancel
+	m.status.Running = true
+	return nil
+}
+
+// Stop stops mining; the task in progress is abandoned
+func (m *Miner) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.cancel == nil {
+		return
+	}
+	m.engine.StopMining()
+	m.cancel()
+	m.cancel = nil
+	m.status.Running = false
+}
+
+// Status returns the miner's state
+func (m *Miner) Status() Status {
+	m.mu.Lock()
+	status := m.status
+	reputation := m.reputation
+	m.mu.Unlock()
+
+	status.Reputation = types.InitialReputation
+	if reputation != nil {
+		status.Reputation = reputation.GetMinerReputation(status.Address)
+	}
+	if task := m.engine.CurrentTask(); task != nil && status.Running {
+		status.CurrentTask = task.TaskID
+	}
+	return status
+}
+
+// handleResult builds, submits and relays a block for a PoUW result
+func (m *Miner) handleResult(ctx context.Context, task *types.Task, result *pouw.PoUWResult) error {
+	block, err := m.BuildBlock(ctx, task, result)
+	if err == nil {
+		err = m.SubmitBlock(ctx, block)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.status.LastError = err.Error()
+		return err
+	}
+	m.status.BlocksMined++
+	m.status.LastBlock = block.Header.Hash
+	m.status.LastBlockHeight = block.Header.Height
+	m.status.LastBlockTime = time.Now()
+	m.status.LastError = ""
+	return nil
+}
+
+// BuildBlock assembles a block for a PoUW result on the current tips and
+// searches for a nonce meeting its difficulty target
+func (m *Miner) BuildBlock(ctx context.Context, task *types.Task, result *pouw.PoUWResult) (*types.Block, error) {
+	m.mu.Lock()
+	reputation, payouts, difficulty, committees := m.reputation, m.payouts, m.difficulty, m.committees
+	registry, nullifiers, states, receipts := m.registry, m.nullifiers, m.states, m.receipts
+	m.mu.Unlock()
+
+	key, err := m.signer()
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := m.prepare(ctx, difficulty)
+	if err != nil {
+		return nil, err
+	}
+	parents, height, txs := base.parents, base.height, base.txs
+
+	header := &types.BlockHeader{
+		Version:         1,
+		Parents:         parents,
+		TxRoot:          dag.ComputeTxRoot(txs),
+		PoUWResult:      result.GradientHash,
+		PoUWProof:       result.Proof,
+		TaskID:          task.TaskID,
+		QualityScore:    result.QualityScore,
+		MinerAddress:    m.config.Address,
+		PayoutAddress:   m.config.Address,
+		ReputationScore: types.InitialReputation,
+		Difficulty:      base.difficulty,
+		Timestamp:       base.timestamp,
+		Height:          height,
+		ExtraData:       m.config.ExtraData,
+		MinerPublicKey:  append([]byte(nil), key.Public()...),
+		VRFSeed:         task.VRFSeed,
+		VRFProof:        task.VRFProof,
+	}
+	if payouts != nil {
+		header.PayoutAddress = payouts.PayoutAddress(ctx, m.config.Address, height)
+	}
+	if reputation != nil {
+		header.ReputationScore = clampReputation(reputation.GetMinerReputation(m.config.Address))
+	}
+	if committees != nil && height%types.EpochLength == 0 {
+		if header.CommitteeRoot, err = committees.Root(ctx, height/types.EpochLength); err != nil {
+			return nil, err
+		}
+	}
+	if registry != nil && height > 0 && height%types.EpochLength == 0 {
+		header.RegistryRoot, err = registry.RegistryRoot(ctx, height/types.EpochLength)
+		if err != nil && !errors.Is(err, dag.ErrNoRegistryCheckpoint) {
+			return nil, err
+		}
+	}
+	if nullifiers != nil || states != nil || receipts != nil {
+		parent, err := m.dag.SelectedParent(ctx, parents)
+		if err != nil {
+			return nil, err
+		}
+		if nullifiers != nil {
+			if header.NullifierRoot, err = nullifiers.NextRoot(ctx, parent, dag.BlockNullifiers(txs)); err != nil {
+				return nil, err
+			}
+		}
+		if receipts != nil {
+			if header.ReceiptsRoot, err = receipts.NextReceiptsRoot(ctx, parent, types.NewBlock(header, txs)); err != nil {
+				return nil, err
+			}
+		}
+		// The state root covers the rest of the header, so it is set last
+		if states != nil {
+			if header.StateRoot, err = states.NextStateRoot(ctx, parent, types.NewBlock(header, txs)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := m.solve(ctx, header); err != nil {
+		return nil, err
+	}
+	if header.Signature, err = key.Sign(header.Hash[:]); err != nil {
+		return nil, err
+	}
+	return types.NewBlock(header, txs), nil
+}
+
+// signer returns the signer of the miner key
+func (m *Miner) signer() (keys.Signer, error) {
+	if s, ok := m.keys.(SignerSource); ok {
+		return s.MiningSigner(m.config.Address)
+	}
+	key, err := m.keys.SigningKey(m.config.Address)
+	if err != nil {
+		return nil, err
+	}
+	return keys.Local(key), nil
+}
+
+// blockBase is the part of a block fixed before the PoUW result is known
+type blockBase struct {
+	parents    []types.Hash
+	height     uint64
+	timestamp  uint64
+	difficulty *big.Int
+	txs        []*types.Transaction
+}
+
+// prepare selects the parents and transactions of a block built now and
+// computes its height, timestamp and difficulty target
+func (m *Miner) prepare(ctx context.Context, difficulty DifficultySource) (*blockBase, error) {
+	parents, err := m.dag.SelectParents(ctx, m.config.MaxParents)
+	if err != nil {
+		return nil, err
+	}
+	if len(parents) == 0 {
+		return nil, ErrNoParents
+	}
+
+	base := &blockBase{parents: parents}
+	parentHeaders := make([]*types.BlockHeader, len(parents))
+	for i, hash := range parents {
+		parent, err := m.dag.GetBlock(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		parentHeaders[i] = parent.Header
+		if parent.Header.Height+1 > base.height {
+			base.height = parent.Header.Height + 1
+		}
+		if parent.Header.Timestamp > base.timestamp {
+			base.timestamp = parent.Header.Timestamp
+		}
+	}
+	if now := uint64(time.Now().Unix()); now > base.timestamp {
+		base.timestamp = now
+	}
+
+	if difficulty != nil {
+		base.difficulty = difficulty.CalculateDifficulty(ctx, parentHeaders)
+	} else {
+		base.difficulty = maxDifficulty(parentHeaders)
+	}
+	base.txs = m.pool.SelectTransactions(m.config.MaxBlockTxs, m.config.MaxBlockSize)
+	return base, nil
+}
+
+// SubmitBlock validates a block, adds it to the DAG, drops its
+// transactions from the mempool, notifies template subscribers and relays
+// it
+func (m *Miner) SubmitBlock(ctx context.Context, block *types.Block) error {
+	if m.validator != nil {
+		if err := m.validator.ValidateBlock(ctx, block); err != nil {
+			return err
+		}
+	}
+	if err := m.dag.AddBlock(ctx, block); err != nil {
+		return err
+	}
+	m.pool.RemoveConfirmed(block)
+	m.engine.TaskQueue().UpdateVRFSeed(block.Header.Hash)
+	m.refreshTemplate(ctx)
+
+	m.mu.Lock()
+	broadcaster, onBlock := m.broadcaster, m.onBlock
+	m.mu.Unlock()
+
+	if onBlock != nil {
+		onBlock(ctx, block)
+	}
+	if broadcaster == nil {
+		return nil
+	}
+	data, err := p2p.EncodeBlock(block)
+	if err != nil {
+		return err
+	}
+	return broadcaster.BroadcastBlock(data)
+}
+
+// solve searches for a nonce whose header hash is below the target
+func (m *Miner) solve(ctx context.Context, header *types.BlockHeader) error {
+	if header.Difficulty == nil || header.Difficulty.Sign() <= 0 {
+		return ErrDifficultyNotMet
+	}
+
+	hashInt := new(big.Int)
+	for nonce := uint64(0); nonce < m.config.MaxNonceAttempts; nonce++ {
+		if nonce%4096 == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		header.Nonce = nonce
+		hash := header.ComputeHash()
+		if hashInt.SetBytes(hash[:]).Cmp(header.Difficulty) < 0 {
+			header.Hash = hash
+			return nil
+		}
+	}
+	return ErrDifficultyNotMet
+}
+
+// maxDifficulty returns the highest target among the parents
+func maxDifficulty(headers []*types.BlockHeader) *big.Int {
+	var max *big.Int
+	for _, h := range headers {
+		if h.Difficulty != nil && (max == nil || h.Difficulty.Cmp(max) > 0) {
+			max = h.Difficulty
+		}
+	}
+	if max == nil {
+		return nil
+	}
+	return new(big.Int).Set(max)
+}
+
+// clampReputation keeps a reputation within the bounds headers may carry
+func clampReputation(r float64) float64 {
+	if r < types.MinReputation {
+		return types.MinReputation
+	}
+	if r > types.MaxReputation {
+		return types.MaxReputation
+	}
+	return r
+}
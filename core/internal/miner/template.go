@@ -0,0 +1,204 @@
+package miner
+
+import (
+	"context"
+	"crypto/sha256"
+	"math/big"
+
+	"github.com/ccoin/core/internal/dag"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// notificationBuffer is how many notifications a subscriber may fall
+// behind before further ones are dropped
+const notificationBuffer = 16
+
+// Template is the work a block built now commits to. External miners
+// build on it and switch to a new one when it changes.
+type Template struct {
+	// ID changes whenever the parents, target or task change, but not
+	// with the timestamp or pending transactions
+	ID types.Hash
+
+	Parents    []types.Hash
+	Height     uint64
+	Timestamp  uint64
+	Difficulty *big.Int
+
+	// TaskID is the PoUW task the node's engine is training on, if any,
+	// and VRFSeed the seed tasks are drawn with
+	TaskID  types.Hash
+	VRFSeed types.Hash
+
+	TxRoot  types.Hash
+	TxCount int
+}
+
+// NotificationKind says why the template changed
+type NotificationKind uint8
+
+// Notification kinds
+const (
+	// NotifyNewTips means a block arrived and the template builds on
+	// different parents
+	NotifyNewTips NotificationKind = iota + 1
+
+	// NotifyTaskReassigned means the engine moved to another task
+	NotifyTaskReassigned
+
+	// NotifyDifficultyChanged means the target of the next block changed
+	NotifyDifficultyChanged
+)
+
+// String returns the kind's wire name
+func (k NotificationKind) String() string {
+	switch k {
+	case NotifyNewTips:
+		return "new_tips"
+	case NotifyTaskReassigned:
+		return "task_reassigned"
+	case NotifyDifficultyChanged:
+		return "difficulty_changed"
+	default:
+		return "unknown"
+	}
+}
+
+// Notification tells subscribers that work on earlier templates is stale
+type Notification struct {
+	Kind     NotificationKind
+	Template *Template
+}
+
+// BlockTemplate returns the template of a block built now
+func (m *Miner) BlockTemplate(ctx context.Context) (*Template, error) {
+	m.mu.Lock()
+	difficulty := m.difficulty
+	m.mu.Unlock()
+
+	base, err := m.prepare(ctx, difficulty)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Template{
+		Parents:    base.parents,
+		Height:     base.height,
+		Timestamp:  base.timestamp,
+		Difficulty: base.difficulty,
+		VRFSeed:    m.engine.TaskQueue().VRFSeed(),
+		TxRoot:     dag.ComputeTxRoot(base.txs),
+		TxCount:    len(base.txs),
+	}
+	if task := m.engine.CurrentTask(); task != nil {
+		t.TaskID = task.TaskID
+	}
+	t.ID = t.computeID()
+	return t, nil
+}
+
+// computeID hashes the fields that make earlier work stale
+func (t *Template) computeID() types.Hash {
+	buf := []byte("CCOIN_TEMPLATE")
+	for _, p := range t.Parents {
+		buf = append(buf, p[:]...)
+	}
+	if t.Difficulty != nil {
+		buf = append(buf, t.Difficulty.Bytes()...)
+	}
+	buf = append(buf, t.TaskID[:]...)
+	buf = append(buf, t.VRFSeed[:]...)
+	return sha256.Sum256(buf)
+}
+
+// Subscribe returns a channel of template changes and a function that
+// ends the subscription. A subscriber that falls behind misses
+// notifications and should refetch the template.
+func (m *Miner) Subscribe() (<-chan Notification, func()) {
+	ch := make(chan Notification, notificationBuffer)
+
+	m.notifyMu.Lock()
+	m.subscribers[ch] = struct{}{}
+	if m.template == nil {
+		// The first change is reported against the template now
+		m.template, _ = m.BlockTemplate(context.Background())
+	}
+	m.notifyMu.Unlock()
+
+	return ch, func() {
+		m.notifyMu.Lock()
+		defer m.notifyMu.Unlock()
+		if _, ok := m.subscribers[ch]; ok {
+			delete(m.subscribers, ch)
+			if len(m.subscribers) == 0 {
+				m.template = nil
+			}
+			close(ch)
+		}
+	}
+}
+
+// NotifyBlock tells subscribers of the template change a block added to
+// the DAG causes
+func (m *Miner) NotifyBlock(ctx context.Context, block *types.Block) {
+	m.refreshTemplate(ctx)
+}
+
+// refreshTemplate rebuilds the template and notifies subscribers of what
+// changed since the last one
+func (m *Miner) refreshTemplate(ctx context.Context) {
+	m.notifyMu.Lock()
+	defer m.notifyMu.Unlock()
+
+	if len(m.subscribers) == 0 {
+		return
+	}
+	t, err := m.BlockTemplate(ctx)
+	if err != nil {
+		return
+	}
+
+	prev := m.template
+	m.template = t
+	var kinds []NotificationKind
+	switch {
+	case prev == nil:
+		// The template could not be built when they subscribed
+		kinds = []NotificationKind{NotifyNewTips}
+	case prev.ID == t.ID:
+		return
+	default:
+		if !equalHashes(prev.Parents, t.Parents) {
+			kinds = append(kinds, NotifyNewTips)
+		}
+		if (prev.Difficulty == nil) != (t.Difficulty == nil) || (t.Difficulty != nil && prev.Difficulty.Cmp(t.Difficulty) != 0) {
+			kinds = append(kinds, NotifyDifficultyChanged)
+		}
+		if prev.TaskID != t.TaskID {
+			kinds = append(kinds, NotifyTaskReassigned)
+		}
+	}
+
+	for _, kind := range kinds {
+		n := Notification{Kind: kind, Template: t}
+		for ch := range m.subscribers {
+			select {
+			case ch <- n:
+			default:
+			}
+		}
+	}
+}
+
+// equalHashes reports whether two hash lists are the same
+func equalHashes(a, b []types.Hash) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,527 @@
+package p2p
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// Ban errors
+var (
+	ErrInvalidBanTarget  = errors.New("ban target must be a peer ID, IP address or CIDR subnet")
+	ErrBanFeedSignature  = errors.New("ban feed signature is invalid")
+	ErrBanFeedStale      = errors.New("ban feed is not newer than the one already ingested")
+	ErrBanFeedOversized  = errors.New("ban feed exceeds the size limit")
+	ErrBanFeedBadRequest = errors.New("ban feed request failed")
+)
+
+// BanSourceLocal is the source of bans added on this node
+const BanSourceLocal = "local"
+
+// maxBanFeedSize bounds a downloaded ban feed
+const maxBanFeedSize = 8 << 20
+
+// Ban keeps a peer, an address or a subnet off the node
+type Ban struct {
+	// Target is a peer ID, an IP address or a CIDR subnet
+	Target string `json:"target"`
+	Reason string `json:"reason,omitempty"`
+
+	// Source is BanSourceLocal or the feed the ban was ingested from
+	Source string `json:"source,omitempty"`
+
+	// Created and Expires are Unix times; a zero Expires never lifts
+	Created int64 `json:"created,omitempty"`
+	Expires int64 `json:"expires,omitempty"`
+}
+
+// expired reports whether the ban has lifted at now
+func (b *Ban) expired(now time.Time) bool {
+	return b.Expires != 0 && now.Unix() >= b.Expires
+}
+
+// banTarget is a parsed ban target
+type banTarget struct {
+	peer   peer.ID
+	prefix netip.Prefix
+}
+
+// parseBanTarget parses a peer ID, an IP address or a CIDR subnet and
+// returns its canonical form
+func parseBanTarget(target string) (banTarget, string, error) {
+	target = strings.TrimSpace(target)
+	if prefix, err := netip.ParsePrefix(target); err == nil {
+		prefix = prefix.Masked()
+		return banTarget{prefix: prefix}, prefix.String(), nil
+	}
+	if addr, err := netip.ParseAddr(target); err == nil {
+		addr = addr.Unmap()
+		prefix := netip.PrefixFrom(addr, addr.BitLen())
+		return banTarget{prefix: prefix}, addr.String(), nil
+	}
+	if id, err := peer.Decode(target); err == nil {
+		return banTarget{peer: id}, id.String(), nil
+	}
+	return banTarget{}, "", fmt.Errorf("%w: %q", ErrInvalidBanTarget, target)
+}
+
+// banFile is the on-disk form of the ban list
+type banFile struct {
+	Bans []Ban `json:"bans"`
+
+	// Feeds holds the issue time of the last feed ingested per source
+	Feeds map[string]int64 `json:"feeds,omitempty"`
+}
+
+// BanManager holds the node's bans and persists them to a file. Peers are
+// banned by ID, addresses by IP or subnet; expired bans are dropped.
+type BanManager struct {
+	mu sync.RWMutex
+
+	path     string
+	bans     map[string]*Ban
+	peers    map[peer.ID]*Ban
+	prefixes map[netip.Prefix]*Ban
+	feeds    map[string]int64
+
+	// onBan is called when bans are added, to drop banned peers
+	onBan func()
+	now   func() time.Time
+}
+
+// NewBanManager creates a ban manager persisting to path, loading the
+// bans saved there. An empty path keeps bans in memory only.
+func NewBanManager(path string) (*BanManager, error) {
+	m := &BanManager{
+		path:     path,
+		bans:     make(map[string]*Ban),
+		peers:    make(map[peer.ID]*Ban),
+		prefixes: make(map[netip.Prefix]*Ban),
+		feeds:    make(map[string]int64),
+		now:      time.Now,
+	}
+	if path == "" {
+		return m, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file banFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to read ban list %s: %w", path, err)
+	}
+	for i := range file.Bans {
+		if err := m.addLocked(file.Bans[i]); err != nil {
+			return nil, err
+		}
+	}
+	for source, issued := range file.Feeds {
+		m.feeds[source] = issued
+	}
+	return m, nil
+}
+
+// SetBanHandler registers fn to be called, without the lock held, after
+// bans are added
+func (m *BanManager) SetBanHandler(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onBan = fn
+}
+
+// Ban adds bans and returns how many it added. A ban without a source is
+// local and replaces any existing ban of the same target.
+func (m *BanManager) Ban(bans ...Ban) (int, error) {
+	m.mu.Lock()
+	now := m.now().Unix()
+	added := 0
+	for _, b := range bans {
+		if b.Source == "" {
+			b.Source = BanSourceLocal
+		}
+		if b.Created == 0 {
+			b.Created = now
+		}
+		if err := m.addLocked(b); err != nil {
+			m.mu.Unlock()
+			return added, err
+		}
+		added++
+	}
+	err := m.saveLocked()
+	handler := m.onBan
+	m.mu.Unlock()
+
+	if handler != nil && added > 0 {
+		handler()
+	}
+	return added, err
+}
+
+// Unban lifts the ban of a target and reports whether there was one
+func (m *BanManager) Unban(target string) (bool, error) {
+	_, key, err := parseBanTarget(target)
+	if err != nil {
+		return false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.bans[key]; !ok {
+		return false, nil
+	}
+	m.removeLocked(key)
+	return true, m.saveLocked()
+}
+
+// Bans returns the bans in force, ordered by target
+func (m *BanManager) Bans() []Ban {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := m.now()
+	bans := make([]Ban, 0, len(m.bans))
+	for _, b := range m.bans {
+		if !b.expired(now) {
+			bans = append(bans, *b)
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Target < bans[j].Target })
+	return bans
+}
+
+// IsPeerBanned reports whether a peer ID is banned
+func (m *BanManager) IsPeerBanned(id peer.ID) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, ok := m.peers[id]
+	return ok && !b.expired(m.now())
+}
+
+// IsAddrBanned reports whether an address falls in a banned subnet.
+// Addresses without an IP, such as DNS names, are never banned.
+func (m *BanManager) IsAddrBanned(addr multiaddr.Multiaddr) bool {
+	ip, ok := multiaddrIP(addr)
+	if !ok {
+		return false
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.prefixes) == 0 {
+		return false
+	}
+	now := m.now()
+	for bits := ip.BitLen(); bits >= 0; bits-- {
+		prefix, _ := ip.Prefix(bits)
+		if b, ok := m.prefixes[prefix]; ok && !b.expired(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// multiaddrIP returns the IP address an address dials
+func multiaddrIP(addr multiaddr.Multiaddr) (netip.Addr, bool) {
+	for _, code := range []int{multiaddr.P_IP4, multiaddr.P_IP6} {
+		if v, err := addr.ValueForProtocol(code); err == nil {
+			ip, err := netip.ParseAddr(v)
+			return ip.Unmap(), err == nil
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// WriteBanList writes bans in the format ReadBanList reads
+func WriteBanList(w io.Writer, bans []Ban) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(banFile{Bans: bans})
+}
+
+// ReadBanList reads a ban list written by WriteBanList, checking every
+// target
+func ReadBanList(r io.Reader) ([]Ban, error) {
+	var file banFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, err
+	}
+	for _, b := range file.Bans {
+		if _, _, err := parseBanTarget(b.Target); err != nil {
+			return nil, err
+		}
+	}
+	return file.Bans, nil
+}
+
+// addLocked adds a ban unless it expired; a local ban is never replaced
+// by a feed's
+func (m *BanManager) addLocked(b Ban) error {
+	t, key, err := parseBanTarget(b.Target)
+	if err != nil {
+		return err
+	}
+	if b.expired(m.now()) {
+		return nil
+	}
+	if old, ok := m.bans[key]; ok && old.Source == BanSourceLocal && b.Source != BanSourceLocal {
+		return nil
+	}
+
+	b.Target = key
+	m.bans[key] = &b
+	if t.peer != "" {
+		m.peers[t.peer] = &b
+	} else {
+		m.prefixes[t.prefix] = &b
+	}
+	return nil
+}
+
+// removeLocked removes the ban of a canonical target
+func (m *BanManager) removeLocked(key string) {
+	t, _, _ := parseBanTarget(key)
+	delete(m.bans, key)
+	if t.peer != "" {
+		delete(m.peers, t.peer)
+	} else {
+		delete(m.prefixes, t.prefix)
+	}
+}
+
+// saveLocked writes the bans in force to the ban file
+func (m *BanManager) saveLocked() error {
+	if m.path == "" {
+		return nil
+	}
+
+	now := m.now()
+	file := banFile{Feeds: m.feeds}
+	for key, b := range m.bans {
+		if b.expired(now) {
+			m.removeLocked(key)
+			continue
+		}
+		file.Bans = append(file.Bans, *b)
+	}
+	sort.Slice(file.Bans, func(i, j int) bool { return file.Bans[i].Target < file.Bans[j].Target })
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
+
+// ============================================
+// Community Ban Feeds
+// ============================================
+
+// BanFeed is a ban list published for other nodes, signed by its
+// maintainer. Issued orders feeds so an old one cannot be replayed.
+type BanFeed struct {
+	Issued    int64  `json:"issued"`
+	Bans      []Ban  `json:"bans"`
+	Signature []byte `json:"signature"`
+}
+
+// signingHash returns the hash the feed's signature covers
+func (f *BanFeed) signingHash() []byte {
+	payload, _ := json.Marshal(struct {
+		Issued int64 `json:"issued"`
+		Bans   []Ban `json:"bans"`
+	}{f.Issued, f.Bans})
+	h := sha256.Sum256(append([]byte("CCOIN_BANFEED"), payload...))
+	return h[:]
+}
+
+// Sign signs the feed with its maintainer's key
+func (f *BanFeed) Sign(key ed25519.PrivateKey) {
+	f.Signature = ed25519.Sign(key, f.signingHash())
+}
+
+// Verify checks the feed's signature against its maintainer's key
+func (f *BanFeed) Verify(key ed25519.PublicKey) error {
+	if len(key) != ed25519.PublicKeySize || !ed25519.Verify(key, f.signingHash(), f.Signature) {
+		return ErrBanFeedSignature
+	}
+	return nil
+}
+
+// IngestFeed verifies a feed signed by key and replaces the bans earlier
+// feeds from source added. It returns how many bans the feed holds.
+func (m *BanManager) IngestFeed(source string, key ed25519.PublicKey, feed *BanFeed) (int, error) {
+	if err := feed.Verify(key); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	if feed.Issued <= m.feeds[source] {
+		m.mu.Unlock()
+		return 0, ErrBanFeedStale
+	}
+
+	bans := make([]Ban, 0, len(feed.Bans))
+	for _, b := range feed.Bans {
+		if _, _, err := parseBanTarget(b.Target); err != nil {
+			m.mu.Unlock()
+			return 0, err
+		}
+		b.Source = source
+		if b.Created == 0 {
+			b.Created = feed.Issued
+		}
+		bans = append(bans, b)
+	}
+
+	for key, b := range m.bans {
+		if b.Source == source {
+			m.removeLocked(key)
+		}
+	}
+	for _, b := range bans {
+		m.addLocked(b)
+	}
+	m.feeds[source] = feed.Issued
+	err := m.saveLocked()
+	handler := m.onBan
+	m.mu.Unlock()
+
+	if handler != nil {
+		handler()
+	}
+	return len(bans), err
+}
+
+// BanFeedConfig names a community ban feed and its maintainer's key
+type BanFeedConfig struct {
+	URL       string
+	PublicKey ed25519.PublicKey
+	Interval  time.Duration
+}
+
+// FetchBanFeed downloads a ban feed
+func FetchBanFeed(ctx context.Context, url string) (*BanFeed, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s", ErrBanFeedBadRequest, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBanFeedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxBanFeedSize {
+		return nil, ErrBanFeedOversized
+	}
+	feed := &BanFeed{}
+	if err := json.Unmarshal(data, feed); err != nil {
+		return nil, err
+	}
+	return feed, nil
+}
+
+// RunFeed ingests the feed at cfg.URL every cfg.Interval until ctx is
+// done. Failures are reported to onError and retried at the next
+// interval; an unchanged feed is not an error.
+func (m *BanManager) RunFeed(ctx context.Context, cfg BanFeedConfig, onError func(error)) {
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		feed, err := FetchBanFeed(ctx, cfg.URL)
+		if err == nil {
+			_, err = m.IngestFeed(cfg.URL, cfg.PublicKey, feed)
+		}
+		if err != nil && !errors.Is(err, ErrBanFeedStale) && ctx.Err() == nil && onError != nil {
+			onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ============================================
+// Connection Gating
+// ============================================
+
+// banGater refuses connections to and from banned peers and subnets
+type banGater struct {
+	bans *BanManager
+}
+
+// InterceptPeerDial refuses to dial banned peers
+func (g *banGater) InterceptPeerDial(p peer.ID) bool {
+	return !g.bans.IsPeerBanned(p)
+}
+
+// InterceptAddrDial refuses to dial banned addresses
+func (g *banGater) InterceptAddrDial(p peer.ID, addr multiaddr.Multiaddr) bool {
+	return !g.bans.IsAddrBanned(addr)
+}
+
+// InterceptAccept refuses connections from banned addresses
+func (g *banGater) InterceptAccept(addrs network.ConnMultiaddrs) bool {
+	return !g.bans.IsAddrBanned(addrs.RemoteMultiaddr())
+}
+
+// InterceptSecured refuses banned peers once their identity is known
+func (g *banGater) InterceptSecured(dir network.Direction, p peer.ID, addrs network.ConnMultiaddrs) bool {
+	return !g.bans.IsPeerBanned(p)
+}
+
+// InterceptUpgraded accepts every connection that got this far
+func (g *banGater) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
+
+// BanManager returns the node's ban list, nil if it bans no one
+func (n *Node) BanManager() *BanManager {
+	return n.bans
+}
+
+// dropBanned closes the connections of peers banned since they connected
+func (n *Node) dropBanned() {
+	for _, conn := range n.host.Network().Conns() {
+		if n.bans.IsPeerBanned(conn.RemotePeer()) || n.bans.IsAddrBanned(conn.RemoteMultiaddr()) {
+			conn.Close()
+		}
+	}
+}
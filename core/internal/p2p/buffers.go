@@ -0,0 +1,106 @@
+package p2p
+
+import (
+	"sync"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Buffer pooling
+const (
+	// pooledBufferSize is the capacity pooled buffers start with
+	pooledBufferSize = 4096
+
+	// maxPooledBuffer is the capacity past which a buffer is left to the
+	// garbage collector rather than pooled, so one large block response
+	// does not pin its memory
+	maxPooledBuffer = 1024 * 1024
+
+	// encodedBlockBytes bounds the encodings of recent blocks kept for
+	// relaying and serving them, oldest evicted first
+	encodedBlockBytes = 32 * 1024 * 1024
+)
+
+// bufferPool holds buffers for encodings that only live until they are
+// written to a stream
+var bufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, pooledBufferSize)
+		return &buf
+	},
+}
+
+// getBuffer takes an empty buffer from the pool
+func getBuffer() *[]byte {
+	buf := bufferPool.Get().(*[]byte)
+	*buf = (*buf)[:0]
+	return buf
+}
+
+// putBuffer returns a buffer to the pool. Nothing may use its contents
+// afterwards.
+func putBuffer(buf *[]byte) {
+	if cap(*buf) > maxPooledBuffer {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// peekHash reads the hash at offset in an encoded message without
+// decoding it
+func peekHash(data []byte, offset int) (types.Hash, bool) {
+	var h types.Hash
+	if len(data) < offset+types.HashSize {
+		return h, false
+	}
+	copy(h[:], data[offset:])
+	return h, true
+}
+
+// encodingCache keeps the encodings of recent blocks or transactions by
+// hash, evicting the oldest past a total size
+type encodingCache struct {
+	mu    sync.Mutex
+	data  map[types.Hash][]byte
+	order []types.Hash // oldest first
+	size  int
+	max   int
+}
+
+// newEncodingCache creates a cache holding up to max bytes
+func newEncodingCache(max int) *encodingCache {
+	return &encodingCache{data: make(map[types.Hash][]byte), max: max}
+}
+
+// add keeps an encoding
+func (c *encodingCache) add(h types.Hash, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.data[h]; exists {
+		return
+	}
+	c.data[h] = data
+	c.order = append(c.order, h)
+	c.size += len(data)
+	for c.size > c.max && len(c.order) > 1 {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		c.size -= len(c.data[oldest])
+		delete(c.data, oldest)
+	}
+}
+
+// get returns an encoding
+func (c *encodingCache) get(h types.Hash) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, exists := c.data[h]
+	return data, exists
+}
+
+// has reports whether the cache holds an encoding
+func (c *encodingCache) has(h types.Hash) bool {
+	_, exists := c.get(h)
+	return exists
+}
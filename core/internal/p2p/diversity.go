@@ -0,0 +1,199 @@
+package p2p
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	asnutil "github.com/libp2p/go-libp2p-asn-util"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// LocalNetwork is the network of loopback, private and link-local
+// addresses. It is exempt from the diversity limits, so local and test
+// networks are unaffected.
+const LocalNetwork = "local"
+
+// DiversityConfig limits how many outbound peers the node keeps in one
+// network, so an attacker holding addresses in a single network cannot
+// take all of the node's outbound slots and eclipse it. Peers are grouped
+// by NetworkOf.
+type DiversityConfig struct {
+	// MaxOutbound is the most outbound peers discovery connects to in one
+	// network; 0 disables the limit. Bootstrap peers are always dialed.
+	MaxOutbound int
+
+	// WarnShare is the share of peers in one network above which the
+	// node's connectivity is reported as concentrated, once it has at
+	// least WarnMinPeers peers
+	WarnShare    float64
+	WarnMinPeers int
+}
+
+// DefaultDiversityConfig returns the default peer diversity limits
+func DefaultDiversityConfig() *DiversityConfig {
+	return &DiversityConfig{
+		MaxOutbound:  2,
+		WarnShare:    0.5,
+		WarnMinPeers: 4,
+	}
+}
+
+// DiversityStats breaks the connected peers down by network
+type DiversityStats struct {
+	Peers    int
+	Outbound int
+
+	// Networks holds the peers per network, most peers first
+	Networks []NetworkPeers
+
+	// Warnings describe networks holding more than the configured share
+	// of all or of the outbound peers
+	Warnings []string
+}
+
+// NetworkPeers counts the peers in one network
+type NetworkPeers struct {
+	Network  string
+	Peers    int
+	Outbound int
+}
+
+// NetworkOf names the network an address is in: its autonomous system for
+// IPv6 addresses in libp2p's embedded ASN table, otherwise its /16 (IPv4)
+// or /32 (IPv6) prefix. Loopback, private and link-local addresses are in
+// LocalNetwork. Addresses without an IP, such as DNS addresses, return "".
+func NetworkOf(addr multiaddr.Multiaddr) string {
+	ip, ok := multiaddrIP(addr)
+	if !ok {
+		return ""
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+		return LocalNetwork
+	}
+	if ip.Is4() {
+		prefix, _ := ip.Prefix(16)
+		return prefix.String()
+	}
+	if asn := asnutil.AsnForIPv6(net.IP(ip.AsSlice())); asn != 0 {
+		return fmt.Sprintf("AS%d", asn)
+	}
+	prefix, _ := ip.Prefix(32)
+	return prefix.String()
+}
+
+// networkOfAddrs names the network of a peer from its advertised
+// addresses, preferring public ones
+func networkOfAddrs(addrs []multiaddr.Multiaddr) string {
+	network := ""
+	for _, addr := range addrs {
+		switch n := NetworkOf(addr); n {
+		case "":
+		case LocalNetwork:
+			network = n
+		default:
+			return n
+		}
+	}
+	return network
+}
+
+// limited reports whether a network counts towards the diversity limits
+func limited(network string) bool {
+	return network != "" && network != LocalNetwork
+}
+
+// outboundByNetwork counts the outbound peers in each network
+func (n *Node) outboundByNetwork() map[string]int {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, p := range n.peers {
+		if p.Outbound {
+			counts[p.Network]++
+		}
+	}
+	return counts
+}
+
+// networkFull reports whether the node has its limit of outbound peers in
+// a network
+func (n *Node) networkFull(network string) bool {
+	if n.diversity.MaxOutbound <= 0 || !limited(network) {
+		return false
+	}
+	return n.outboundByNetwork()[network] >= n.diversity.MaxOutbound
+}
+
+// preferDiverse orders discovered peers so those in the networks the node
+// has the fewest outbound peers in come first
+func (n *Node) preferDiverse(candidates []peer.AddrInfo) {
+	counts := n.outboundByNetwork()
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return counts[networkOfAddrs(candidates[i].Addrs)] < counts[networkOfAddrs(candidates[j].Addrs)]
+	})
+}
+
+// Diversity breaks the connected peers down by network
+func (n *Node) Diversity() *DiversityStats {
+	n.mu.RLock()
+	peers := make([]*PeerInfo, 0, len(n.peers))
+	for _, p := range n.peers {
+		peers = append(peers, p)
+	}
+	stats := ComputeDiversity(peers, n.diversity)
+	n.mu.RUnlock()
+	return stats
+}
+
+// ComputeDiversity breaks peers down by network and warns of networks
+// holding more than cfg's share of them. A nil cfg uses
+// DefaultDiversityConfig.
+func ComputeDiversity(peers []*PeerInfo, cfg *DiversityConfig) *DiversityStats {
+	if cfg == nil {
+		cfg = DefaultDiversityConfig()
+	}
+
+	stats := &DiversityStats{Peers: len(peers)}
+	byNetwork := make(map[string]*NetworkPeers)
+	for _, p := range peers {
+		np, exists := byNetwork[p.Network]
+		if !exists {
+			np = &NetworkPeers{Network: p.Network}
+			byNetwork[p.Network] = np
+		}
+		np.Peers++
+		if p.Outbound {
+			np.Outbound++
+			stats.Outbound++
+		}
+	}
+	for _, np := range byNetwork {
+		stats.Networks = append(stats.Networks, *np)
+	}
+	sort.Slice(stats.Networks, func(i, j int) bool {
+		a, b := stats.Networks[i], stats.Networks[j]
+		if a.Peers != b.Peers {
+			return a.Peers > b.Peers
+		}
+		return a.Network < b.Network
+	})
+
+	concentrated := func(count, total int) bool {
+		return total >= cfg.WarnMinPeers && float64(count) > cfg.WarnShare*float64(total)
+	}
+	for _, np := range stats.Networks {
+		if !limited(np.Network) {
+			continue
+		}
+		if concentrated(np.Peers, stats.Peers) {
+			stats.Warnings = append(stats.Warnings, fmt.Sprintf("%d of %d peers are in %s", np.Peers, stats.Peers, np.Network))
+		}
+		if concentrated(np.Outbound, stats.Outbound) {
+			stats.Warnings = append(stats.Warnings, fmt.Sprintf("%d of %d outbound peers are in %s", np.Outbound, stats.Outbound, np.Network))
+		}
+	}
+	return stats
+}
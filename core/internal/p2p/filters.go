@@ -0,0 +1,245 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Full nodes gossip each epoch's nullifier filter on NullifierFilterTopic
+// as they seal it, and serve sealed filters and proofs of spent
+// nullifiers to light clients over LightProtocolID
+const NullifierFilterTopic = "ccoin/nullifier-filters"
+
+// Nullifier filter limits
+const (
+	// MaxFilterMessageSize is the size of a filter at zkp.MaxFilterSize
+	MaxFilterMessageSize = zkp.MaxFilterSize + 64
+
+	// MaxFiltersPerRequest caps the filters in one light client request
+	MaxFiltersPerRequest = 16
+
+	filterSeenSize = 256
+)
+
+// filterIDOffset is the offset of the closing block hash filters lead
+// with
+const filterIDOffset = 0
+
+// Filters provides the sealed nullifier filters served to light clients
+type Filters interface {
+	// Filter returns the filter of a sealed epoch
+	Filter(epoch uint64) (*zkp.NullifierFilter, bool)
+}
+
+// SpentProver is implemented by light servers that prove nullifiers
+// spent against the nullifier roots in headers
+type SpentProver interface {
+	// NullifierRoots returns the accumulator of the nullifier roots
+	NullifierRoots() *zkp.ChainAccumulator
+}
+
+// SetFilterVerifier sets the function nullifier filters gossiped by peers
+// are checked with, which may also keep them. Filters it returns
+// zkp.ErrFilterUnknown for are dropped without being forwarded; other
+// errors are charged to the sender. Without a verifier no filter is
+// forwarded.
+func (n *Node) SetFilterVerifier(verify func(f *zkp.NullifierFilter) error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.filterVerifier = verify
+}
+
+// BroadcastFilter publishes a sealed nullifier filter
+func (n *Node) BroadcastFilter(f *zkp.NullifierFilter) error {
+	data, err := EncodeNullifierFilter(f)
+	if err != nil {
+		return err
+	}
+	return n.filterTopic.Publish(n.ctx, data)
+}
+
+// checkFilterMessage decodes a nullifier filter and checks it with the
+// filter verifier
+func (n *Node) checkFilterMessage(data []byte) (types.Hash, any, error) {
+	f, err := DecodeNullifierFilter(data)
+	if err == nil {
+		err = f.Validate()
+	}
+	if err != nil {
+		return types.Hash{}, nil, fmt.Errorf("undecodable nullifier filter: %w", err)
+	}
+
+	n.mu.RLock()
+	verify := n.filterVerifier
+	n.mu.RUnlock()
+	if verify == nil {
+		return types.Hash{}, nil, errUncheckable
+	}
+	if err := verify(f); errors.Is(err, zkp.ErrFilterUnknown) {
+		return types.Hash{}, nil, errUncheckable
+	} else if err != nil {
+		return types.Hash{}, nil, fmt.Errorf("invalid nullifier filter: %w", err)
+	}
+	return f.Hash, f, nil
+}
+
+// SetFilters serves sealed nullifier filters to light clients. They are
+// served over LightProtocolID, registered by SetLightServer.
+func (sm *SyncManager) SetFilters(filters Filters) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.filters = filters
+}
+
+// serveFilters returns the sealed filters requested, stopping at the
+// first epoch not sealed
+func (sm *SyncManager) serveFilters(req *GetFiltersMessage) (*Message, error) {
+	sm.mu.RLock()
+	filters := sm.filters
+	sm.mu.RUnlock()
+	if filters == nil {
+		return nil, ErrInvalidMessageType
+	}
+
+	var list []*zkp.NullifierFilter
+	for i := uint32(0); i < min(req.Count, MaxFiltersPerRequest); i++ {
+		f, ok := filters.Filter(req.FromEpoch + uint64(i))
+		if !ok {
+			break
+		}
+		list = append(list, f)
+	}
+	payload, err := EncodeNullifierFilters(list)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Type: MsgTypeFilters, Payload: payload}, nil
+}
+
+// serveSpent proves a nullifier spent along a block's chain, or reports
+// it unspent
+func serveSpent(ctx context.Context, server LightServer, req *GetSpentMessage) (*Message, error) {
+	prover, ok := server.(SpentProver)
+	if !ok {
+		return nil, ErrInvalidMessageType
+	}
+
+	msg := &SpentMessage{}
+	path, err := prover.NullifierRoots().ProveSpent(ctx, req.Hash, req.Nullifier)
+	switch {
+	case err == nil:
+		msg.Spent, msg.Path = true, path
+	case !errors.Is(err, zkp.ErrNullifierNotCommitted):
+		return nil, err
+	}
+	payload, err := EncodeSpent(msg)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Type: MsgTypeSpent, Payload: payload}, nil
+}
+
+// AddFilter keeps a sealed nullifier filter once the block closing its
+// epoch is a synced header at the epoch's last height. The bits cannot be
+// checked without the epoch's blocks, so as with note data the client
+// trusts its peers for them: a bad filter only sends nullifiers to
+// CheckSpent's proofs or lets them skip the pre-screen, and peers still
+// refuse double spends.
+func (lc *LightClient) AddFilter(f *zkp.NullifierFilter) error {
+	if f.Epoch < lc.filters.Sealed() {
+		return lc.filters.Verify(f)
+	}
+
+	lc.mu.RLock()
+	header, exists := lc.headers[f.Hash]
+	lc.mu.RUnlock()
+	if !exists || header.Height != f.LastHeight() {
+		return zkp.ErrFilterUnknown
+	}
+	return lc.filters.AddFilter(f)
+}
+
+// syncFilters downloads the sealed nullifier filters the client lacks
+func (lc *LightClient) syncFilters(ctx context.Context, peerID peer.ID) error {
+	for {
+		payload, err := EncodeGetFilters(&GetFiltersMessage{FromEpoch: lc.filters.Sealed(), Count: MaxFiltersPerRequest})
+		if err != nil {
+			return err
+		}
+		resp, err := sendRequest(ctx, lc.node, lc.timeout, peerID, LightProtocolID, &Message{Type: MsgTypeGetFilters, Payload: payload}, MsgTypeFilters)
+		if err != nil {
+			return err
+		}
+		filters, err := DecodeNullifierFilters(resp.Payload)
+		if err != nil {
+			return err
+		}
+		if len(filters) == 0 {
+			return nil
+		}
+		for _, f := range filters {
+			if err := lc.AddFilter(f); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// CheckSpent reports, for each nullifier, whether it was spent along the
+// tip's chain. Nullifiers the filters rule out are not looked up; the
+// rest are spent only if the sync peer proves them against the tip's
+// nullifier root. Like note data, a peer can withhold such a proof but
+// not forge one.
+func (lc *LightClient) CheckSpent(ctx context.Context, nullifiers []types.Hash) ([]bool, error) {
+	lc.mu.RLock()
+	tip, peerID := lc.tip, lc.peer
+	lc.mu.RUnlock()
+	if tip == nil {
+		return nil, ErrLightNotSynced
+	}
+
+	spent := make([]bool, len(nullifiers))
+	for i, nullifier := range nullifiers {
+		if !lc.filters.MaybeSpent(nullifier) {
+			continue
+		}
+		proven, err := lc.proveSpent(ctx, peerID, tip, nullifier)
+		if err != nil {
+			return nil, err
+		}
+		spent[i] = proven
+	}
+	return spent, nil
+}
+
+// proveSpent asks a peer to prove a nullifier spent along a block's chain
+// and checks the proof against the block's nullifier root
+func (lc *LightClient) proveSpent(ctx context.Context, peerID peer.ID, header *types.BlockHeader, nullifier types.Hash) (bool, error) {
+	payload, err := EncodeGetSpent(&GetSpentMessage{Hash: header.Hash, Nullifier: nullifier})
+	if err != nil {
+		return false, err
+	}
+	resp, err := sendRequest(ctx, lc.node, lc.timeout, peerID, LightProtocolID, &Message{Type: MsgTypeGetSpent, Payload: payload}, MsgTypeSpent)
+	if err != nil {
+		return false, err
+	}
+	msg, err := DecodeSpent(resp.Payload)
+	if err != nil {
+		return false, err
+	}
+	if !msg.Spent {
+		return false, nil
+	}
+	if header.NullifierRoot.IsEmpty() || len(msg.Path.Siblings) != zkp.TreeDepth ||
+		!zkp.VerifyMerklePath(nullifier, msg.Path, header.NullifierRoot) {
+		lc.reportFailure(peerID, ErrInvalidLightProof)
+		return false, ErrInvalidLightProof
+	}
+	return true, nil
+}
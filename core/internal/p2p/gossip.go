@@ -0,0 +1,267 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Gossip profile names
+const (
+	GossipProfileDatacenter = "datacenter"
+	GossipProfileHome       = "home"
+	GossipProfileMobile     = "mobile"
+)
+
+// GossipConfig holds GossipSub tuning
+type GossipConfig struct {
+	// Mesh degree: target, low and high watermarks
+	D   int
+	Dlo int
+	Dhi int
+
+	// HeartbeatInterval is the mesh maintenance period
+	HeartbeatInterval time.Duration
+
+	// ValidateThrottle bounds messages being validated at once, and
+	// ValidateWorkers the goroutines validating them (0 for the default)
+	ValidateThrottle int
+	ValidateWorkers  int
+
+	// ValidationTimeouts bounds the time a topic's handler may take per
+	// message. Topics not listed are unbounded.
+	ValidationTimeouts map[string]time.Duration
+
+	// TaskRateLimit is the sustained task messages per second accepted
+	// from one peer, with bursts up to TaskBurst. Messages over the limit
+	// are rejected and count against the peer's score.
+	TaskRateLimit float64
+	TaskBurst     int
+}
+
+// GossipProfile returns the tuning for a named deployment profile
+func GossipProfile(name string) (*GossipConfig, error) {
+	switch name {
+	case GossipProfileDatacenter:
+		// Well-connected, fast nodes: wider mesh, faster heartbeat
+		return &GossipConfig{
+			D:                 8,
+			Dlo:               6,
+			Dhi:               12,
+			HeartbeatInterval: 700 * time.Millisecond,
+			ValidateThrottle:  8192,
+			ValidationTimeouts: map[string]time.Duration{
+				BlockTopic:       10 * time.Second,
+				TransactionTopic: 2 * time.Second,
+				TaskTopic:        2 * time.Second,
+				EvaluationTopic:  5 * time.Second,
+			},
+			TaskRateLimit: 20,
+			TaskBurst:     100,
+		}, nil
+
+	case GossipProfileHome:
+		// The GossipSub defaults
+		return &GossipConfig{
+			D:                 6,
+			Dlo:               5,
+			Dhi:               12,
+			HeartbeatInterval: time.Second,
+			ValidateThrottle:  4096,
+			ValidationTimeouts: map[string]time.Duration{
+				BlockTopic:       20 * time.Second,
+				TransactionTopic: 5 * time.Second,
+				TaskTopic:        5 * time.Second,
+				EvaluationTopic:  10 * time.Second,
+			},
+			TaskRateLimit: 5,
+			TaskBurst:     20,
+		}, nil
+
+	case GossipProfileMobile:
+		// Metered, slow links: narrow mesh, less frequent heartbeats
+		return &GossipConfig{
+			D:                 4,
+			Dlo:               3,
+			Dhi:               6,
+			HeartbeatInterval: 2 * time.Second,
+			ValidateThrottle:  1024,
+			ValidateWorkers:   2,
+			ValidationTimeouts: map[string]time.Duration{
+				BlockTopic:       60 * time.Second,
+				TransactionTopic: 10 * time.Second,
+				TaskTopic:        10 * time.Second,
+				EvaluationTopic:  20 * time.Second,
+			},
+			TaskRateLimit: 2,
+			TaskBurst:     10,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown gossip profile %q", name)
+	}
+}
+
+// gossipInspectInterval is how often GossipSub's peer scores are read
+// back for display
+const gossipInspectInterval = 10 * time.Second
+
+// options returns the pubsub options for the configuration on a network,
+// adding the scorer's scores to GossipSub's
+func (g *GossipConfig) options(scorer *PeerScorer, networkID uint32) ([]pubsub.Option, error) {
+	if g.Dlo < 1 || g.Dlo > g.D || g.D > g.Dhi {
+		return nil, fmt.Errorf("invalid gossip degree: need 1 <= Dlo <= D <= Dhi, got %d/%d/%d", g.Dlo, g.D, g.Dhi)
+	}
+
+	params := pubsub.DefaultGossipSubParams()
+	params.D = g.D
+	params.Dlo = g.Dlo
+	params.Dhi = g.Dhi
+	params.Dlazy = g.D
+	params.Dscore = g.D * 2 / 3
+	params.Dout = g.D / 2
+	if params.Dout >= g.Dlo {
+		params.Dout = g.Dlo - 1
+	}
+	if g.HeartbeatInterval > 0 {
+		params.HeartbeatInterval = g.HeartbeatInterval
+	}
+
+	opts := []pubsub.Option{
+		pubsub.WithGossipSubParams(params),
+		pubsub.WithPeerScore(g.scoreParams(scorer, networkID), scoreThresholds()),
+		pubsub.WithPeerScoreInspect(scorer.inspectGossip, gossipInspectInterval),
+		pubsub.WithMaxMessageSize(MaxBlockMessageSize),
+	}
+	if g.ValidateThrottle > 0 {
+		opts = append(opts, pubsub.WithValidateThrottle(g.ValidateThrottle))
+	}
+	if g.ValidateWorkers > 0 {
+		opts = append(opts, pubsub.WithValidateWorkers(g.ValidateWorkers))
+	}
+	return opts, nil
+}
+
+// scoreParams returns the peer scoring parameters. Invalid messages on
+// any topic are penalized; on the task topic, which carries the largest
+// and most expensive messages, more heavily. The scorer's score is the
+// application-specific component.
+func (g *GossipConfig) scoreParams(scorer *PeerScorer, networkID uint32) *pubsub.PeerScoreParams {
+	topics := map[string]*pubsub.TopicScoreParams{
+		TopicName(BlockTopic, networkID):           topicScoreParams(1, -10),
+		TopicName(TransactionTopic, networkID):     topicScoreParams(0.5, -10),
+		TopicName(TxAnnounceTopic, networkID):      topicScoreParams(0.5, -10),
+		TopicName(TaskTopic, networkID):            topicScoreParams(0.5, -100),
+		TopicName(EvaluationTopic, networkID):      topicScoreParams(0.5, -10),
+		TopicName(NullifierFilterTopic, networkID): topicScoreParams(0.5, -10),
+	}
+
+	return &pubsub.PeerScoreParams{
+		Topics:                      topics,
+		TopicScoreCap:               100,
+		AppSpecificScore:            scorer.Score,
+		AppSpecificWeight:           scorer.config.GossipWeight,
+		IPColocationFactorWeight:    -10,
+		IPColocationFactorThreshold: 3,
+		BehaviourPenaltyWeight:      -10,
+		BehaviourPenaltyThreshold:   6,
+		BehaviourPenaltyDecay:       pubsub.ScoreParameterDecay(10 * time.Minute),
+		DecayInterval:               time.Second,
+		DecayToZero:                 0.01,
+		RetainScore:                 time.Hour,
+	}
+}
+
+// topicScoreParams rewards first deliveries and penalizes invalid
+// messages, which decay over an hour
+func topicScoreParams(weight, invalidWeight float64) *pubsub.TopicScoreParams {
+	return &pubsub.TopicScoreParams{
+		TopicWeight:                    weight,
+		TimeInMeshWeight:               0.01,
+		TimeInMeshQuantum:              time.Second,
+		TimeInMeshCap:                  3600,
+		FirstMessageDeliveriesWeight:   1,
+		FirstMessageDeliveriesDecay:    pubsub.ScoreParameterDecay(10 * time.Minute),
+		FirstMessageDeliveriesCap:      50,
+		InvalidMessageDeliveriesWeight: invalidWeight,
+		InvalidMessageDeliveriesDecay:  pubsub.ScoreParameterDecay(time.Hour),
+	}
+}
+
+// scoreThresholds returns when peers lose gossip, publishing and finally
+// all message processing
+func scoreThresholds() *pubsub.PeerScoreThresholds {
+	return &pubsub.PeerScoreThresholds{
+		GossipThreshold:             -100,
+		PublishThreshold:            -500,
+		GraylistThreshold:           -1000,
+		AcceptPXThreshold:           10,
+		OpportunisticGraftThreshold: 5,
+	}
+}
+
+// rateLimiter is a per-peer token bucket
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[peer.ID]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter creates a limiter allowing rate messages per second per
+// peer, with bursts up to burst
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[peer.ID]*bucket),
+	}
+}
+
+// allow takes a token from the peer's bucket, if there is one
+func (rl *rateLimiter) allow(id peer.ID, now time.Time) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	b, exists := rl.buckets[id]
+	if !exists {
+		b = &bucket{tokens: rl.burst, last: now}
+		rl.buckets[id] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * rl.rate
+	if b.tokens > rl.burst {
+		b.tokens = rl.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// forget drops a disconnected peer's bucket
+func (rl *rateLimiter) forget(id peer.ID) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	delete(rl.buckets, id)
+}
+
+// handlerContext bounds a handler by its topic's validation timeout
+func (n *Node) handlerContext(topic string) (context.Context, context.CancelFunc) {
+	if timeout := n.validationTimeouts[topic]; timeout > 0 {
+		return context.WithTimeout(n.ctx, timeout)
+	}
+	return context.WithCancel(n.ctx)
+}
@@ -0,0 +1,259 @@
+package p2p
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// StatusProtocolID carries the status handshake. The side that dialed a
+// connection sends its status and the other answers with its own.
+const StatusProtocolID = "/ccoin/status/2.0.0"
+
+// Handshake timing
+const (
+	// HandshakeTimeout is how long a peer has to complete the handshake
+	// before it is disconnected
+	HandshakeTimeout = 15 * time.Second
+
+	// incompatibleFor is how long discovery skips a peer on another
+	// network, chain or protocol version
+	incompatibleFor = time.Hour
+)
+
+// Handshake errors
+var (
+	ErrWrongNetwork = errors.New("peer is on another network")
+	ErrWrongGenesis = errors.New("peer has another genesis block")
+	ErrNoHandshake  = errors.New("peer did not complete the status handshake")
+)
+
+// NetworkIDFor derives the network ID carried in status messages from a
+// network name
+func NetworkIDFor(name string) uint32 {
+	sum := sha256.Sum256([]byte("ccoin-network:" + name))
+	return binary.BigEndian.Uint32(sum[:4])
+}
+
+// writeMessage writes a message stamped with the node's network ID
+func (n *Node) writeMessage(w io.Writer, m *Message) error {
+	m.NetworkID = n.networkID
+	return m.Encode(w)
+}
+
+// readMessage reads a message, refusing one stamped with another
+// network's ID with ErrWrongNetwork
+func (n *Node) readMessage(r io.Reader, m *Message) error {
+	if err := m.Decode(r); err != nil {
+		return err
+	}
+	if m.NetworkID != n.networkID {
+		return ErrWrongNetwork
+	}
+	return nil
+}
+
+// CheckStatus checks that a peer's status is compatible with the local
+// one: the same protocol version, network ID and, once both sides know
+// it, genesis block
+func CheckStatus(local, remote *StatusMessage) error {
+	if remote.Version != SyncProtocolVersion {
+		return ErrSyncProtocolVersion
+	}
+	if remote.NetworkID != local.NetworkID {
+		return ErrWrongNetwork
+	}
+	if !local.GenesisHash.IsEmpty() && !remote.GenesisHash.IsEmpty() && remote.GenesisHash != local.GenesisHash {
+		return ErrWrongGenesis
+	}
+	return nil
+}
+
+// SetStatusSource sets the function reporting the node's chain status
+// for the handshake. Without one, the node reports no chain and accepts
+// any genesis block.
+func (n *Node) SetStatusSource(fn func() *StatusMessage) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.statusSource = fn
+}
+
+// localStatus returns the status the node sends peers
+func (n *Node) localStatus() *StatusMessage {
+	n.mu.RLock()
+	source := n.statusSource
+	n.mu.RUnlock()
+
+	status := &StatusMessage{}
+	if source != nil {
+		status = source()
+	}
+	status.Version = SyncProtocolVersion
+	status.NetworkID = n.networkID
+	if status.GenesisHash.IsEmpty() {
+		status.GenesisHash = n.genesis
+	}
+	status.Roles = n.Roles()
+	return status
+}
+
+// checkStatus checks a peer's status against the node's
+func (n *Node) checkStatus(remote *StatusMessage) error {
+	return CheckStatus(n.localStatus(), remote)
+}
+
+// recordStatus records a compatible peer's status
+func (n *Node) recordStatus(id peer.ID, status *StatusMessage) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if p, exists := n.peers[id]; exists {
+		p.Version = fmt.Sprintf("ccoin/%d", status.Version)
+		p.Height = status.Height
+		p.Roles = status.Roles
+		p.PrunedHeight = status.PrunedHeight
+		p.Verified = true
+		p.LastSeen = time.Now()
+	}
+}
+
+// verified reports whether a peer has completed the handshake
+func (n *Node) verified(id peer.ID) bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	p, exists := n.peers[id]
+	return exists && p.Verified
+}
+
+// handshake exchanges status with a newly connected peer. The dialing
+// side sends first; the other waits HandshakeTimeout for it.
+func (n *Node) handshake(conn network.Conn) {
+	id := conn.RemotePeer()
+	if n.verified(id) {
+		return
+	}
+
+	if conn.Stat().Direction == network.DirOutbound {
+		status, err := n.requestHandshake(id)
+		n.completeHandshake(id, status, err)
+		return
+	}
+
+	timer := time.NewTimer(HandshakeTimeout)
+	defer timer.Stop()
+	select {
+	case <-n.ctx.Done():
+	case <-timer.C:
+		if !n.verified(id) && n.host.Network().Connectedness(id) == network.Connected {
+			n.completeHandshake(id, nil, ErrNoHandshake)
+		}
+	}
+}
+
+// requestHandshake sends the node's status to a peer and reads back the
+// peer's
+func (n *Node) requestHandshake(id peer.ID) (*StatusMessage, error) {
+	ctx, cancel := context.WithTimeout(n.ctx, HandshakeTimeout)
+	defer cancel()
+
+	s, err := n.OpenStream(ctx, id, StatusProtocolID)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(HandshakeTimeout))
+
+	payload, err := EncodeStatus(n.localStatus())
+	if err != nil {
+		return nil, err
+	}
+	if err := n.writeMessage(s, &Message{Type: MsgTypeStatus, Payload: payload}); err != nil {
+		s.Reset()
+		return nil, err
+	}
+
+	var resp Message
+	if err := n.readMessage(s, &resp); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	if resp.Type != MsgTypeStatus {
+		return nil, ErrUnexpectedResponse
+	}
+	return DecodeStatus(resp.Payload)
+}
+
+// handleStatus answers a peer's handshake with the node's status. It
+// answers incompatible peers too, so they learn why they are dropped.
+func (n *Node) handleStatus(s network.Stream) {
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(HandshakeTimeout))
+	id := s.Conn().RemotePeer()
+
+	var req Message
+	err := n.readMessage(s, &req)
+	if err != nil && !errors.Is(err, ErrWrongNetwork) {
+		s.Reset()
+		n.completeHandshake(id, nil, err)
+		return
+	}
+	if req.Type != MsgTypeStatus {
+		s.Reset()
+		n.completeHandshake(id, nil, ErrInvalidMessageType)
+		return
+	}
+	var status *StatusMessage
+	if err == nil {
+		status, err = DecodeStatus(req.Payload)
+	}
+
+	payload, encErr := EncodeStatus(n.localStatus())
+	if encErr == nil {
+		encErr = n.writeMessage(s, &Message{Type: MsgTypeStatus, Payload: payload})
+	}
+	if encErr != nil {
+		s.Reset()
+	}
+	n.completeHandshake(id, status, err)
+}
+
+// completeHandshake records a peer's status, or disconnects it if the
+// handshake failed or the peer is incompatible
+func (n *Node) completeHandshake(id peer.ID, status *StatusMessage, err error) {
+	if err == nil {
+		err = n.checkStatus(status)
+	}
+	if err == nil {
+		n.recordStatus(id, status)
+		return
+	}
+
+	if errors.Is(err, ErrWrongNetwork) || errors.Is(err, ErrWrongGenesis) || errors.Is(err, ErrSyncProtocolVersion) {
+		n.mu.Lock()
+		n.incompatible[id] = time.Now().Add(incompatibleFor)
+		n.mu.Unlock()
+	}
+	n.log.Debug("disconnecting peer after failed handshake", "peer", id, "err", err)
+	n.host.Network().ClosePeer(id)
+}
+
+// isIncompatible reports whether a peer recently failed the handshake as
+// incompatible
+func (n *Node) isIncompatible(id peer.ID) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	until, exists := n.incompatible[id]
+	if exists && time.Now().After(until) {
+		delete(n.incompatible, id)
+		return false
+	}
+	return exists
+}
@@ -0,0 +1,87 @@
+package p2p
+
+import (
+	"crypto/rand"
+	"errors"
+	"os"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/crypto/pb"
+
+	"github.com/ccoin/core/internal/keys"
+)
+
+// DefaultIdentityFile is the node key's file name in the data directory
+const DefaultIdentityFile = "node.key"
+
+// ErrIdentityExists is returned when creating over an existing node key
+var ErrIdentityExists = errors.New("node identity already exists")
+
+// LoadIdentity reads the node's private key. Without a saved key a node
+// gets a new peer ID on every start.
+func LoadIdentity(path string) (crypto.PrivKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.UnmarshalPrivateKey(data)
+}
+
+// CreateIdentity generates an Ed25519 node key and writes it to path,
+// readable only by the owner
+func CreateIdentity(path string) (crypto.PrivKey, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, ErrIdentityExists
+	}
+
+	key, _, err := crypto.GenerateKeyPairWithReader(crypto.Ed25519, -1, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	data, err := crypto.MarshalPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// SignerIdentity returns a node key that signs through s, for identities
+// held by a key provider. The peer ID derives from s's public key; the
+// private key cannot be exported.
+func SignerIdentity(s keys.Signer) (crypto.PrivKey, error) {
+	pub, err := crypto.UnmarshalEd25519PublicKey(s.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &signerKey{signer: s, pub: pub}, nil
+}
+
+// signerKey is a libp2p private key whose signatures come from a Signer
+type signerKey struct {
+	signer keys.Signer
+	pub    crypto.PubKey
+}
+
+func (k *signerKey) Equals(other crypto.Key) bool {
+	o, ok := other.(crypto.PrivKey)
+	return ok && k.pub.Equals(o.GetPublic())
+}
+
+func (k *signerKey) Raw() ([]byte, error) {
+	return nil, keys.ErrNotExportable
+}
+
+func (k *signerKey) Type() pb.KeyType {
+	return pb.KeyType_Ed25519
+}
+
+func (k *signerKey) Sign(data []byte) ([]byte, error) {
+	return k.signer.Sign(data)
+}
+
+func (k *signerKey) GetPublic() crypto.PubKey {
+	return k.pub
+}
@@ -0,0 +1,344 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/ccoin/core/internal/dag"
+	"github.com/ccoin/core/internal/supervisor"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Transactions are announced by hash on TxAnnounceTopic and their bodies
+// fetched over TxFetchProtocolID. A node accepts, and so forwards, an
+// announcement only once it holds every transaction in it, so any peer an
+// announcement arrives from can serve it. TransactionTopic still carries
+// full transactions from nodes predating announcements.
+const (
+	TxAnnounceTopic   = "ccoin/tx-announcements"
+	TxFetchProtocolID = "/ccoin/txfetch/2.0.0"
+)
+
+// Transaction inventory limits
+const (
+	// MaxAnnouncedTxs is the most hashes in an announcement or request
+	MaxAnnouncedTxs = 256
+
+	// MaxTxsInFlight is the most transactions fetched from one peer at
+	// once; announcements past it are ignored until fetches complete
+	MaxTxsInFlight = 1024
+
+	// txFetchBatch is the most transactions requested at once, so a
+	// response of transactions at MaxTransactionMessageSize stays under
+	// MaxMessageSize
+	txFetchBatch = 16
+
+	// txFetchTimeout bounds one request and announceTimeout the fetches
+	// for one announcement
+	txFetchTimeout  = 10 * time.Second
+	announceTimeout = 30 * time.Second
+
+	// txInventoryBytes bounds the transaction bodies kept for peers to
+	// fetch, oldest evicted first, and txKnownSize the hashes remembered
+	// as handled, so they are not fetched again
+	txInventoryBytes = 64 * 1024 * 1024
+	txKnownSize      = 65536
+)
+
+// Inventory errors
+var (
+	ErrTxNotServed = errors.New("peer did not serve an announced transaction")
+	ErrTxUnasked   = errors.New("peer sent a transaction that was not requested")
+	ErrTxInvalid   = errors.New("peer sent an invalid transaction")
+)
+
+// maxAnnouncementSize is the size of an announcement of MaxAnnouncedTxs
+const maxAnnouncementSize = 2 + MaxAnnouncedTxs*types.HashSize
+
+// AnnounceTransactions announces transactions the node holds in its
+// inventory
+func (n *Node) AnnounceTransactions(hashes []types.Hash) error {
+	for len(hashes) > 0 {
+		batch := hashes[:min(len(hashes), MaxAnnouncedTxs)]
+		hashes = hashes[len(batch):]
+
+		data, err := EncodeInventory(&InventoryMessage{Hashes: batch})
+		if err != nil {
+			return err
+		}
+		if err := n.txAnnounceTopic.Publish(n.ctx, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateAnnouncement fetches the transactions in an announcement the
+// node has not handled from the peer it came from, and accepts the
+// announcement once the node holds all of them. Announcing transactions
+// the peer cannot serve or sending invalid ones is charged to the peer;
+// transactions the node's handler refuses, such as ones conflicting with
+// its mempool, only stop the announcement here.
+func (n *Node) validateAnnouncement(ctx context.Context, from peer.ID, msg *pubsub.Message) pubsub.ValidationResult {
+	if from == n.host.ID() {
+		return pubsub.ValidationAccept
+	}
+	if len(msg.Data) > maxAnnouncementSize {
+		n.ReportMisbehavior(from, TxAnnounceTopic, fmt.Sprintf("%d byte announcement over the %d byte limit", len(msg.Data), maxAnnouncementSize))
+		return pubsub.ValidationReject
+	}
+	inv, err := DecodeInventory(msg.Data)
+	if err == nil && len(inv.Hashes) == 0 {
+		err = errors.New("empty")
+	}
+	if err != nil {
+		n.ReportMisbehavior(from, TxAnnounceTopic, "undecodable announcement: "+err.Error())
+		return pubsub.ValidationReject
+	}
+
+	// Transactions another peer is already sending are waited for, and
+	// fetched from this one only if that fails
+	missing := n.unknownTxs(inv.Hashes)
+	for attempt := 0; attempt < 2 && len(missing) > 0; attempt++ {
+		mine, pending, ok := n.txFetches.claim(from, missing)
+		if !ok {
+			return pubsub.ValidationIgnore
+		}
+		if len(mine) > 0 {
+			err := n.fetchTransactions(ctx, from, mine)
+			n.txFetches.release(from, mine)
+			if errors.Is(err, ErrTxNotServed) || errors.Is(err, ErrTxUnasked) || errors.Is(err, ErrTxInvalid) {
+				n.ReportMisbehavior(from, TxAnnounceTopic, err.Error())
+				return pubsub.ValidationReject
+			}
+			if err != nil {
+				return pubsub.ValidationIgnore
+			}
+		}
+		for _, done := range pending {
+			select {
+			case <-done:
+			case <-ctx.Done():
+				return pubsub.ValidationIgnore
+			}
+		}
+		missing = n.unknownTxs(missing)
+	}
+
+	for _, h := range inv.Hashes {
+		if !n.txInventory.has(h) {
+			return pubsub.ValidationIgnore
+		}
+	}
+	return pubsub.ValidationAccept
+}
+
+// unknownTxs returns the hashes the node has not handled
+func (n *Node) unknownTxs(hashes []types.Hash) []types.Hash {
+	var unknown []types.Hash
+	for i, h := range hashes {
+		if !n.txKnown.contains(h) && !containsHash(hashes[:i], h) {
+			unknown = append(unknown, h)
+		}
+	}
+	return unknown
+}
+
+// fetchTransactions requests transactions from a peer in batches and
+// hands each to the transaction handler
+func (n *Node) fetchTransactions(ctx context.Context, id peer.ID, hashes []types.Hash) error {
+	for len(hashes) > 0 {
+		batch := hashes[:min(len(hashes), txFetchBatch)]
+		hashes = hashes[len(batch):]
+		if err := n.fetchBatch(ctx, id, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchBatch requests one batch of transactions from a peer
+func (n *Node) fetchBatch(ctx context.Context, id peer.ID, hashes []types.Hash) error {
+	ctx, cancel := context.WithTimeout(ctx, txFetchTimeout)
+	defer cancel()
+
+	payload, err := EncodeInventory(&InventoryMessage{Hashes: hashes})
+	if err != nil {
+		return err
+	}
+	s, err := n.OpenStream(ctx, id, TxFetchProtocolID)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		s.SetDeadline(deadline)
+	}
+
+	if err := n.writeMessage(s, &Message{Type: MsgTypeGetTxs, Payload: payload}); err != nil {
+		s.Reset()
+		return err
+	}
+	var resp Message
+	if err := n.readMessage(s, &resp); err != nil {
+		s.Reset()
+		return err
+	}
+	if resp.Type != MsgTypeTxs {
+		return ErrUnexpectedResponse
+	}
+
+	received := make(map[types.Hash]bool, len(hashes))
+	err = decodeList(resp.Payload, func(data []byte) error {
+		tx, err := DecodeTransaction(data)
+		if err == nil {
+			err = dag.CheckTransaction(tx)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrTxInvalid, err)
+		}
+		if !containsHash(hashes, tx.TxHash) || received[tx.TxHash] {
+			return ErrTxUnasked
+		}
+		received[tx.TxHash] = true
+		n.handleFetched(id, tx, data)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(received) < len(hashes) {
+		return ErrTxNotServed
+	}
+	return nil
+}
+
+// handleFetched runs the transaction handler on a fetched transaction and
+// adds it to the inventory if accepted
+func (n *Node) handleFetched(from peer.ID, tx *types.Transaction, data []byte) {
+	hash := tx.TxHash
+	n.txKnown.add(hash)
+
+	if handler := n.txHandler; handler != nil {
+		ctx, cancel := n.handlerContext(TransactionTopic)
+		start := time.Now()
+		fetched := &pubsub.Message{Message: &pb.Message{Data: data}, ReceivedFrom: from, ValidatorData: tx}
+		err := supervisor.Protect("p2p.txfetch", func() error { return handler(ctx, fetched) })
+		cancel()
+		n.recordMessage(from, TransactionTopic, time.Since(start), err)
+		if err != nil && !errors.Is(err, ErrDuplicateMessage) {
+			n.log.Debug("fetched transaction refused", "tx", hash.String(), "peer", from, "err", err)
+			return
+		}
+	}
+	n.txInventory.add(hash, data)
+}
+
+// handleTxFetch serves the requested transactions the node holds.
+// Transactions it does not hold are left out of the response.
+func (n *Node) handleTxFetch(s network.Stream) {
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(txFetchTimeout))
+	from := s.Conn().RemotePeer()
+
+	var req Message
+	if err := n.readMessage(s, &req); err != nil || req.Type != MsgTypeGetTxs {
+		s.Reset()
+		return
+	}
+	inv, err := DecodeInventory(req.Payload)
+	if err == nil && len(inv.Hashes) > txFetchBatch {
+		err = ErrMessageTooLarge
+	}
+	if err != nil {
+		s.Reset()
+		n.ReportMisbehavior(from, TxFetchProtocolID, "invalid transaction request: "+err.Error())
+		return
+	}
+
+	var items [][]byte
+	for _, h := range inv.Hashes {
+		if data, ok := n.txInventory.get(h); ok {
+			items = append(items, data)
+		}
+	}
+	buf := getBuffer()
+	*buf = appendList(*buf, items)
+	resp := &Message{Type: MsgTypeTxs, Payload: *buf, pooled: buf}
+	if err := n.writeMessage(s, resp); err != nil {
+		s.Reset()
+	}
+	resp.release()
+}
+
+func containsHash(list []types.Hash, h types.Hash) bool {
+	for _, x := range list {
+		if x == h {
+			return true
+		}
+	}
+	return false
+}
+
+// txFetcher tracks the transactions being fetched, so each is fetched
+// from one peer at a time, and bounds those fetched from each peer
+type txFetcher struct {
+	mu       sync.Mutex
+	inFlight map[types.Hash]chan struct{} // closed when the fetch ends
+	perPeer  map[peer.ID]int
+}
+
+func newTxFetcher() *txFetcher {
+	return &txFetcher{
+		inFlight: make(map[types.Hash]chan struct{}),
+		perPeer:  make(map[peer.ID]int),
+	}
+}
+
+// claim marks the hashes not in flight as fetched from id and returns
+// them, with the channels closed when the others' fetches end. It claims
+// nothing if that would put id over MaxTxsInFlight.
+func (f *txFetcher) claim(id peer.ID, hashes []types.Hash) (mine []types.Hash, pending []chan struct{}, ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, h := range hashes {
+		if done, exists := f.inFlight[h]; exists {
+			pending = append(pending, done)
+		} else {
+			mine = append(mine, h)
+		}
+	}
+	if f.perPeer[id]+len(mine) > MaxTxsInFlight {
+		return nil, nil, false
+	}
+	for _, h := range mine {
+		f.inFlight[h] = make(chan struct{})
+	}
+	f.perPeer[id] += len(mine)
+	return mine, pending, true
+}
+
+// release ends fetches claimed from id
+func (f *txFetcher) release(id peer.ID, hashes []types.Hash) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, h := range hashes {
+		if done, exists := f.inFlight[h]; exists {
+			close(done)
+			delete(f.inFlight, h)
+		}
+	}
+	if f.perPeer[id] -= len(hashes); f.perPeer[id] <= 0 {
+		delete(f.perPeer, id)
+	}
+}
@@ -0,0 +1,643 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/ccoin/core/internal/state"
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// LightProtocolID serves light clients the state proofs, Merkle paths and
+// note data they check against block headers, one request per stream
+const LightProtocolID = "/ccoin/light/2.0.0"
+
+// MaxNoteHeights caps the heights in one note data request
+const MaxNoteHeights = 100
+
+// Light client errors
+var (
+	ErrInvalidLightProof = errors.New("peer sent an invalid light client proof")
+	ErrUnknownHeader     = errors.New("peer sent data for an unknown header")
+	ErrLightNotSynced    = errors.New("light client has no verified tip yet")
+	ErrUnscannedNote     = errors.New("note was not found by the light client scan")
+)
+
+// LightServer provides the proofs full nodes serve to light clients
+type LightServer interface {
+	// Prove returns the value at key in the state after a block and its
+	// proof against the block's state root
+	Prove(ctx context.Context, hash, key types.Hash) (types.Hash, *state.Proof, error)
+
+	// CommitmentRoots returns the accumulator of the commitment roots in
+	// block states
+	CommitmentRoots() *zkp.ChainAccumulator
+}
+
+// SetLightServer serves light clients over LightProtocolID
+func (sm *SyncManager) SetLightServer(server LightServer) {
+	sm.mu.Lock()
+	sm.light = server
+	sm.mu.Unlock()
+	sm.node.RegisterProtocol(LightProtocolID, sm.handleLightStream)
+}
+
+// handleLightStream serves one light client request per stream
+func (sm *SyncManager) handleLightStream(s network.Stream) {
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(sm.requestTimeout))
+
+	ctx, cancel := context.WithTimeout(sm.node.ctx, sm.requestTimeout)
+	defer cancel()
+
+	var req Message
+	if err := sm.node.readMessage(s, &req); err != nil {
+		s.Reset()
+		return
+	}
+
+	resp, err := sm.serveLight(ctx, &req)
+	if err != nil {
+		s.Reset()
+		return
+	}
+	if err := sm.node.writeMessage(s, resp); err != nil {
+		s.Reset()
+	}
+}
+
+// serveLight builds the response to a light client request
+func (sm *SyncManager) serveLight(ctx context.Context, req *Message) (*Message, error) {
+	sm.mu.RLock()
+	server := sm.light
+	sm.mu.RUnlock()
+
+	switch req.Type {
+	case MsgTypeGetNotes:
+		msg, err := DecodeGetNotes(req.Payload)
+		if err != nil {
+			return nil, err
+		}
+		notes, err := sm.collectNotes(ctx, server, msg.FromHeight, msg.Count)
+		if err != nil {
+			return nil, err
+		}
+		payload, err := EncodeBlockNotes(notes)
+		if err != nil {
+			return nil, err
+		}
+		return &Message{Type: MsgTypeNotes, Payload: payload}, nil
+
+	case MsgTypeGetProof:
+		msg, err := DecodeGetProof(req.Payload)
+		if err != nil {
+			return nil, err
+		}
+		value, proof, err := server.Prove(ctx, msg.Hash, msg.Key)
+		if err != nil {
+			return nil, err
+		}
+		payload, err := EncodeProof(&ProofMessage{Value: value, Proof: proof})
+		if err != nil {
+			return nil, err
+		}
+		return &Message{Type: MsgTypeProof, Payload: payload}, nil
+
+	case MsgTypeGetPath:
+		msg, err := DecodeGetPath(req.Payload)
+		if err != nil {
+			return nil, err
+		}
+		path, err := server.CommitmentRoots().Path(ctx, msg.Hash, msg.Position)
+		if err != nil {
+			return nil, err
+		}
+		payload, err := EncodePath(path)
+		if err != nil {
+			return nil, err
+		}
+		return &Message{Type: MsgTypePath, Payload: payload}, nil
+
+	case MsgTypeGetFilters:
+		msg, err := DecodeGetFilters(req.Payload)
+		if err != nil {
+			return nil, err
+		}
+		return sm.serveFilters(msg)
+
+	case MsgTypeGetSpent:
+		msg, err := DecodeGetSpent(req.Payload)
+		if err != nil {
+			return nil, err
+		}
+		return serveSpent(ctx, server, msg)
+
+	default:
+		return nil, ErrInvalidMessageType
+	}
+}
+
+// collectNotes gathers the note data of the main chain blocks at count
+// heights from fromHeight
+func (sm *SyncManager) collectNotes(ctx context.Context, server LightServer, fromHeight uint64, count uint32) ([]*BlockNotes, error) {
+	if count == 0 || fromHeight > sm.dag.GetHeight() {
+		return nil, nil
+	}
+	if count > MaxNoteHeights {
+		count = MaxNoteHeights
+	}
+
+	headers, err := sm.dag.GetMainChain(ctx, fromHeight, fromHeight+uint64(count)-1)
+	if err != nil {
+		return nil, err
+	}
+
+	notes := make([]*BlockNotes, 0, len(headers))
+	for _, h := range headers {
+		block, err := sm.dag.GetBlock(ctx, h.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		// A block's commitments follow those of its selected parent
+		b := &BlockNotes{Hash: h.Hash, Height: h.Height}
+		if !h.IsGenesis() {
+			parent, err := sm.dag.SelectedParent(ctx, h.Parents)
+			if err != nil {
+				return nil, err
+			}
+			frontier, err := server.CommitmentRoots().Frontier(ctx, parent)
+			if err != nil {
+				return nil, err
+			}
+			b.Offset = frontier.Size
+		}
+		for _, tx := range block.Transactions {
+			b.Commitments = append(b.Commitments, tx.Commitments...)
+			b.Nullifiers = append(b.Nullifiers, tx.Nullifiers...)
+		}
+		notes = append(notes, b)
+	}
+	return notes, nil
+}
+
+// LightConfig holds light client configuration
+type LightConfig struct {
+	// BatchSize is the number of heights requested per headers or note
+	// data request
+	BatchSize      int
+	RequestTimeout time.Duration
+
+	// Interval is how often Run syncs with the best peer
+	Interval time.Duration
+
+	// ScanFrom is the first height scanned for wallet notes
+	ScanFrom uint64
+}
+
+// DefaultLightConfig returns default light client configuration
+func DefaultLightConfig() *LightConfig {
+	return &LightConfig{
+		BatchSize:      100,
+		RequestTimeout: 30 * time.Second,
+		Interval:       15 * time.Second,
+	}
+}
+
+// LightClient follows the chain without downloading blocks. It syncs
+// headers from its best peer, checking that each hashes correctly and
+// builds on headers already held, and takes the peer's best block as its
+// tip. Everything else is requested from full nodes and checked against
+// those headers: the commitment root after the tip is proven against the
+// tip's state root, and wallet notes found in the note data must have a
+// Merkle path to the commitment root of the block creating them.
+//
+// A peer can withhold note data but not forge it, so the light client
+// trusts its peers to show it every note and spend, as usual for SPV.
+// Headers are kept in memory, as are the sealed epochs' nullifier filters
+// and the nullifiers scanned above them, which CheckSpent screens with.
+type LightClient struct {
+	mu sync.RWMutex
+
+	node      *Node
+	timeout   time.Duration
+	interval  time.Duration
+	batchSize int
+
+	// Headers synced so far, up to height, and whether they include
+	// genesis
+	headers map[types.Hash]*types.BlockHeader
+	height  uint64
+	genesis bool
+
+	// Best peer's tip and the commitment root proven after it
+	tip    *types.BlockHeader
+	anchor types.Hash
+	peer   peer.ID
+
+	// Note scanning: the next height to scan, the block being scanned and
+	// the commitments of wallet notes with verified positions
+	scanned  uint64
+	scanning *BlockNotes
+	notes    map[uint64]types.Hash
+	handler  func(ctx context.Context, block *types.Block)
+
+	filters *zkp.NullifierFilters
+}
+
+// NewLightClient creates a light client on a node
+func NewLightClient(node *Node, cfg *LightConfig) *LightClient {
+	if cfg == nil {
+		cfg = DefaultLightConfig()
+	}
+
+	return &LightClient{
+		node:      node,
+		timeout:   cfg.RequestTimeout,
+		interval:  cfg.Interval,
+		batchSize: cfg.BatchSize,
+		headers:   make(map[types.Hash]*types.BlockHeader),
+		scanned:   cfg.ScanFrom,
+		notes:     make(map[uint64]types.Hash),
+		filters:   zkp.NewNullifierFilters(nil, nil),
+	}
+}
+
+// SetBlockHandler sets the function each scanned block's note data is
+// passed to, as a block of one transaction holding the block's outputs
+// and nullifiers. It runs before the next block is scanned.
+func (lc *LightClient) SetBlockHandler(handler func(ctx context.Context, block *types.Block)) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.handler = handler
+}
+
+// Run keeps the light client in sync, syncing with the best peer every
+// Interval until ctx is done
+func (lc *LightClient) Run(ctx context.Context) error {
+	ticker := time.NewTicker(lc.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := lc.Sync(ctx); err != nil && !errors.Is(err, ErrNoSyncPeers) {
+			lc.node.log.Warn("light sync failed", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync downloads new headers from the best peer, proves the commitment
+// root after its tip, scans the note data up to the tip and downloads
+// new nullifier filters
+func (lc *LightClient) Sync(ctx context.Context) error {
+	for _, p := range lc.node.Peers() {
+		requestStatus(ctx, lc.node, lc.timeout, p.ID) // Unresponsive peers keep their last height
+	}
+	peerID, status := lc.bestPeer(ctx)
+	if peerID == "" {
+		return ErrNoSyncPeers
+	}
+
+	if err := lc.syncHeaders(ctx, peerID, status.Height); err != nil {
+		lc.reportFailure(peerID, err)
+		return err
+	}
+	if err := lc.syncTip(ctx, peerID, status.BestHash); err != nil {
+		lc.reportFailure(peerID, err)
+		return err
+	}
+	if err := lc.scanNotes(ctx, peerID); err != nil {
+		lc.reportFailure(peerID, err)
+		return err
+	}
+
+	// Peers predating filters do not serve them
+	if err := lc.syncFilters(ctx, peerID); err != nil {
+		lc.reportFailure(peerID, err)
+		lc.node.log.Debug("nullifier filter sync failed", "peer", peerID, "err", err)
+	}
+	return nil
+}
+
+// bestPeer returns the peer with the highest block height and its status
+func (lc *LightClient) bestPeer(ctx context.Context) (peer.ID, *StatusMessage) {
+	var best peer.ID
+	var bestHeight uint64
+	for _, p := range lc.node.Peers() {
+		if !p.Verified {
+			continue
+		}
+		if best == "" || p.Height > bestHeight {
+			best, bestHeight = p.ID, p.Height
+		}
+	}
+	if best == "" {
+		return "", nil
+	}
+	status, err := requestStatus(ctx, lc.node, lc.timeout, best)
+	if err != nil {
+		return "", nil
+	}
+	return best, status
+}
+
+// syncHeaders downloads the headers up to target. It starts at the
+// synced height so sibling blocks at the previous tip are picked up too.
+func (lc *LightClient) syncHeaders(ctx context.Context, peerID peer.ID, target uint64) error {
+	lc.mu.RLock()
+	current := lc.height
+	lc.mu.RUnlock()
+
+	for current <= target {
+		headers, err := requestHeaders(ctx, lc.node, lc.timeout, peerID, current, uint32(lc.batchSize))
+		if err != nil {
+			return err
+		}
+		if len(headers) == 0 {
+			return nil
+		}
+
+		lc.mu.Lock()
+		for _, h := range headers {
+			if _, exists := lc.headers[h.Hash]; exists {
+				continue
+			}
+			if h.IsGenesis() {
+				if lc.genesis {
+					lc.mu.Unlock()
+					return ErrInvalidBlock
+				}
+			} else {
+				for _, parent := range h.Parents {
+					if _, exists := lc.headers[parent]; !exists {
+						lc.mu.Unlock()
+						return ErrInvalidBlock
+					}
+				}
+			}
+			lc.headers[h.Hash] = h
+			lc.genesis = true
+			if h.Height > lc.height {
+				lc.height = h.Height
+			}
+		}
+		lc.mu.Unlock()
+
+		current = headers[len(headers)-1].Height + 1
+	}
+	return nil
+}
+
+// syncTip takes the peer's best block as the tip and proves the
+// commitment root after it against its state root
+func (lc *LightClient) syncTip(ctx context.Context, peerID peer.ID, hash types.Hash) error {
+	lc.mu.RLock()
+	tip, exists := lc.headers[hash]
+	lc.mu.RUnlock()
+	if !exists {
+		return ErrUnknownHeader
+	}
+
+	root, err := lc.commitmentRoot(ctx, peerID, tip)
+	if err != nil {
+		return err
+	}
+
+	lc.mu.Lock()
+	lc.tip = tip
+	lc.anchor = root
+	lc.peer = peerID
+	lc.mu.Unlock()
+	return nil
+}
+
+// commitmentRoot requests the commitment root after a block and checks
+// its proof against the block's state root
+func (lc *LightClient) commitmentRoot(ctx context.Context, peerID peer.ID, header *types.BlockHeader) (types.Hash, error) {
+	payload, err := EncodeGetProof(&GetProofMessage{Hash: header.Hash, Key: state.CommitmentsKey})
+	if err != nil {
+		return types.Hash{}, err
+	}
+	resp, err := sendRequest(ctx, lc.node, lc.timeout, peerID, LightProtocolID, &Message{Type: MsgTypeGetProof, Payload: payload}, MsgTypeProof)
+	if err != nil {
+		return types.Hash{}, err
+	}
+	msg, err := DecodeProof(resp.Payload)
+	if err != nil {
+		return types.Hash{}, err
+	}
+	if !state.VerifyProof(header.StateRoot, state.CommitmentsKey, msg.Value, msg.Proof) {
+		return types.Hash{}, ErrInvalidLightProof
+	}
+	return msg.Value, nil
+}
+
+// path requests the Merkle path of the commitment at position in the
+// commitment tree after a block
+func (lc *LightClient) path(ctx context.Context, peerID peer.ID, hash types.Hash, position uint64) (*zkp.MerklePath, error) {
+	payload, err := EncodeGetPath(&GetPathMessage{Hash: hash, Position: position})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := sendRequest(ctx, lc.node, lc.timeout, peerID, LightProtocolID, &Message{Type: MsgTypeGetPath, Payload: payload}, MsgTypePath)
+	if err != nil {
+		return nil, err
+	}
+	path, err := DecodePath(resp.Payload)
+	if err != nil {
+		return nil, err
+	}
+	if path.LeafPosition != position || len(path.Siblings) != zkp.TreeDepth {
+		return nil, ErrInvalidLightProof
+	}
+	return path, nil
+}
+
+// scanNotes passes the note data of the main chain blocks up to the tip
+// to the block handler, and their nullifiers to the filters
+func (lc *LightClient) scanNotes(ctx context.Context, peerID peer.ID) error {
+	lc.mu.RLock()
+	from, target, handler := lc.scanned, lc.tip.Height, lc.handler
+	lc.mu.RUnlock()
+	if handler == nil {
+		return nil
+	}
+	lc.filters.Track(from)
+
+	for from <= target {
+		payload, err := EncodeGetNotes(&GetNotesMessage{FromHeight: from, Count: uint32(lc.batchSize)})
+		if err != nil {
+			return err
+		}
+		resp, err := sendRequest(ctx, lc.node, lc.timeout, peerID, LightProtocolID, &Message{Type: MsgTypeGetNotes, Payload: payload}, MsgTypeNotes)
+		if err != nil {
+			return err
+		}
+		blocks, err := DecodeBlockNotes(resp.Payload)
+		if err != nil {
+			return err
+		}
+		if len(blocks) == 0 {
+			return nil
+		}
+
+		for _, b := range blocks {
+			if b.Height < from || b.Height > target {
+				return ErrHeadersOutOfRange
+			}
+			lc.mu.Lock()
+			header, exists := lc.headers[b.Hash]
+			if exists && header.Height == b.Height {
+				lc.scanning = b
+			}
+			lc.mu.Unlock()
+			if !exists || header.Height != b.Height {
+				return ErrUnknownHeader
+			}
+
+			tx := &types.Transaction{Commitments: b.Commitments, Nullifiers: b.Nullifiers}
+			handler(ctx, types.NewBlock(header, []*types.Transaction{tx}))
+			lc.filters.Add(b.Height, b.Nullifiers)
+
+			lc.mu.Lock()
+			lc.scanning = nil
+			lc.scanned = b.Height + 1
+			lc.mu.Unlock()
+			from = b.Height + 1
+		}
+	}
+	return nil
+}
+
+// reportFailure logs a failed sync against the peer when it sent data
+// that did not check out
+func (lc *LightClient) reportFailure(peerID peer.ID, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidBlock),
+		errors.Is(err, ErrInvalidLightProof),
+		errors.Is(err, ErrUnknownHeader),
+		errors.Is(err, ErrHeadersOutOfRange):
+		lc.node.ReportMisbehavior(peerID, LightProtocolID, err.Error())
+	}
+}
+
+// CommitmentPosition returns the position of a commitment created by the
+// block being scanned, once its Merkle path checks out against the
+// commitment root after that block. Wallet scanners call it for the notes
+// they find.
+func (lc *LightClient) CommitmentPosition(ctx context.Context, commitment types.Hash) (uint64, error) {
+	lc.mu.RLock()
+	b, peerID := lc.scanning, lc.peer
+	lc.mu.RUnlock()
+	if b == nil {
+		return 0, zkp.ErrLeafNotFound
+	}
+
+	index := -1
+	for i, c := range b.Commitments {
+		if c.Value == commitment {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return 0, zkp.ErrLeafNotFound
+	}
+	position := b.Offset + uint64(index)
+
+	lc.mu.RLock()
+	header := lc.headers[b.Hash]
+	lc.mu.RUnlock()
+	root, err := lc.commitmentRoot(ctx, peerID, header)
+	if err != nil {
+		return 0, err
+	}
+	path, err := lc.path(ctx, peerID, b.Hash, position)
+	if err != nil {
+		return 0, err
+	}
+	if !zkp.VerifyMerklePath(commitment, path, root) {
+		return 0, ErrInvalidLightProof
+	}
+
+	lc.mu.Lock()
+	lc.notes[position] = commitment
+	lc.mu.Unlock()
+	return position, nil
+}
+
+// GetCurrentAnchor returns the commitment root after the tip
+func (lc *LightClient) GetCurrentAnchor() types.Hash {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	return lc.anchor
+}
+
+// GetMerklePath requests the path of a scanned wallet note to the
+// commitment root after the tip and checks it
+func (lc *LightClient) GetMerklePath(ctx context.Context, position uint64) (*zkp.MerklePath, error) {
+	lc.mu.RLock()
+	tip, anchor, peerID := lc.tip, lc.anchor, lc.peer
+	commitment, exists := lc.notes[position]
+	lc.mu.RUnlock()
+	if tip == nil {
+		return nil, ErrLightNotSynced
+	}
+	if !exists {
+		return nil, ErrUnscannedNote
+	}
+
+	path, err := lc.path(ctx, peerID, tip.Hash, position)
+	if err != nil {
+		return nil, err
+	}
+	if !zkp.VerifyMerklePath(commitment, path, anchor) {
+		return nil, ErrInvalidLightProof
+	}
+	return path, nil
+}
+
+// SyncPeer returns the peer the light client last synced from
+func (lc *LightClient) SyncPeer() peer.ID {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	return lc.peer
+}
+
+// Height returns the height of the light client's tip
+func (lc *LightClient) Height() uint64 {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	if lc.tip == nil {
+		return 0
+	}
+	return lc.tip.Height
+}
+
+// Tip returns the hash of the light client's tip
+func (lc *LightClient) Tip() types.Hash {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	if lc.tip == nil {
+		return types.Hash{}
+	}
+	return lc.tip.Hash
+}
+
+// Scanned returns the next height to scan for wallet notes
+func (lc *LightClient) Scanned() uint64 {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	return lc.scanned
+}
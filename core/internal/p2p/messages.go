@@ -1,11 +1,17 @@
 // Package p2p provides message serialization for network communication.
+// Decoded messages share memory with the bytes they were decoded from, so
+// those must not be modified afterwards.
 package p2p
 
 import (
 	"encoding/binary"
 	"errors"
 	"io"
+	"math"
+	"math/big"
 
+	"github.com/ccoin/core/internal/state"
+	"github.com/ccoin/core/internal/zkp"
 	"github.com/ccoin/core/pkg/types"
 )
 
@@ -14,9 +20,27 @@ const (
 	MsgTypeBlock       uint8 = 0x01
 	MsgTypeTransaction uint8 = 0x02
 	MsgTypeTask        uint8 = 0x03
+	MsgTypeEvalJob     uint8 = 0x04
+	MsgTypeAttestation uint8 = 0x05
 	MsgTypeGetBlocks   uint8 = 0x10
 	MsgTypeGetTxs      uint8 = 0x11
+	MsgTypeGetHeaders  uint8 = 0x12
+	MsgTypeHeaders     uint8 = 0x13
+	MsgTypeBlocks      uint8 = 0x14
+	MsgTypeGetSnapshot uint8 = 0x15
+	MsgTypeSnapshot    uint8 = 0x16
+	MsgTypeGetNotes    uint8 = 0x17
+	MsgTypeNotes       uint8 = 0x18
+	MsgTypeGetProof    uint8 = 0x19
+	MsgTypeProof       uint8 = 0x1a
+	MsgTypeGetPath     uint8 = 0x1b
+	MsgTypePath        uint8 = 0x1c
+	MsgTypeTxs         uint8 = 0x1d
+	MsgTypeGetFilters  uint8 = 0x1e
+	MsgTypeFilters     uint8 = 0x1f
 	MsgTypeStatus      uint8 = 0x20
+	MsgTypeGetSpent    uint8 = 0x21
+	MsgTypeSpent       uint8 = 0x22
 	MsgTypePing        uint8 = 0x30
 	MsgTypePong        uint8 = 0x31
 )
@@ -26,6 +50,8 @@ var (
 	ErrInvalidMessageType = errors.New("invalid message type")
 	ErrMessageTooLarge    = errors.New("message too large")
 	ErrInvalidChecksum    = errors.New("invalid checksum")
+	ErrTruncatedMessage   = errors.New("truncated message")
+	ErrInvalidInferenceOp = errors.New("invalid inference operation")
 )
 
 // MaxMessageSize is the maximum size of a network message
@@ -35,6 +61,14 @@ const MaxMessageSize = 32 * 1024 * 1024 // 32 MB
 type Message struct {
 	Type    uint8
 	Payload []byte
+
+	// NetworkID is the sender's network, see NetworkIDFor. Nodes stamp
+	// the messages they write with theirs and drop those stamped with
+	// another.
+	NetworkID uint32
+
+	// pooled is the pooled buffer holding Payload, if any
+	pooled *[]byte
 }
 
 // BlockMessage wraps a block for network transmission
@@ -52,12 +86,101 @@ type TaskMessage struct {
 	Task *types.Task
 }
 
-// GetBlocksMessage requests blocks by hash
+// GetBlocksMessage requests full blocks by hash
 type GetBlocksMessage struct {
-	StartHash types.Hash
+	Hashes []types.Hash
+}
+
+// InventoryMessage announces transactions by hash, or requests their
+// bodies
+type InventoryMessage struct {
+	Hashes []types.Hash
+}
+
+// GetHeadersMessage requests the headers of all blocks at Count heights
+// starting from FromHeight
+type GetHeadersMessage struct {
+	FromHeight uint64
+	Count      uint32
+}
+
+// GetSnapshotMessage requests the chunk at Offset of the snapshot of
+// block Hash, or of the latest snapshot if Hash is empty
+type GetSnapshotMessage struct {
+	Hash   types.Hash
+	Offset uint64
+}
+
+// SnapshotChunkMessage carries part of an encoded state snapshot
+type SnapshotChunkMessage struct {
+	Height uint64
+	Hash   types.Hash
+
+	// Total is the size of the whole encoded snapshot
+	Total uint64
+	Data  []byte
+}
+
+// GetNotesMessage requests the note data of the main chain blocks at
+// Count heights starting from FromHeight
+type GetNotesMessage struct {
+	FromHeight uint64
+	Count      uint32
+}
+
+// BlockNotes is the note data light clients scan for wallet notes: the
+// outputs a block creates, from commitment tree position Offset on, and
+// the nullifiers it spends
+type BlockNotes struct {
+	Hash   types.Hash
+	Height uint64
+	Offset uint64
+
+	Commitments []types.Commitment
+	Nullifiers  []types.Hash
+}
+
+// GetProofMessage requests the value at Key in the state after block Hash
+type GetProofMessage struct {
+	Hash types.Hash
+	Key  types.Hash
+}
+
+// ProofMessage carries a state value and its proof against the block's
+// state root
+type ProofMessage struct {
+	Value types.Hash
+	Proof *state.Proof
+}
+
+// GetPathMessage requests the Merkle path of the commitment at Position
+// in the commitment tree after block Hash
+type GetPathMessage struct {
+	Hash     types.Hash
+	Position uint64
+}
+
+// GetFiltersMessage requests the nullifier filters of Count sealed
+// epochs starting from FromEpoch
+type GetFiltersMessage struct {
+	FromEpoch uint64
 	Count     uint32
 }
 
+// GetSpentMessage requests proof that Nullifier was spent along the chain
+// of block Hash
+type GetSpentMessage struct {
+	Hash      types.Hash
+	Nullifier types.Hash
+}
+
+// SpentMessage answers a GetSpentMessage. Path proves the nullifier is in
+// the block's nullifier accumulator and is only set if Spent.
+type SpentMessage struct {
+	Spent bool
+	Path  *zkp.MerklePath
+}
+
 // StatusMessage exchanges node status information
 type StatusMessage struct {
 	Version     uint32
@@ -65,41 +188,54 @@ type StatusMessage struct {
 	Height      uint64
 	BestHash    types.Hash
 	GenesisHash types.Hash
+
+	// Roles are the services the sender runs; zero from nodes that
+	// predate role reporting
+	Roles Roles
+
+	// PrunedHeight is the height below which the sender no longer serves
+	// blocks; zero from nodes that keep every block
+	PrunedHeight uint64
 }
 
 // Encode serializes a message for network transmission
 func (m *Message) Encode(w io.Writer) error {
-	// Write message type
-	if err := binary.Write(w, binary.BigEndian, m.Type); err != nil {
-		return err
-	}
+	buf := getBuffer()
+	defer putBuffer(buf)
 
-	// Write payload length
-	payloadLen := uint32(len(m.Payload))
-	if err := binary.Write(w, binary.BigEndian, payloadLen); err != nil {
-		return err
-	}
+	// Message type, network ID and payload length
+	data := append(*buf, m.Type)
+	data = binary.BigEndian.AppendUint32(data, m.NetworkID)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(m.Payload)))
 
-	// Write payload
-	if _, err := w.Write(m.Payload); err != nil {
+	// Payloads small enough to pool go out in the same write
+	large := len(m.Payload) > maxPooledBuffer
+	if !large {
+		data = append(data, m.Payload...)
+	}
+	*buf = data
+	if _, err := w.Write(data); err != nil {
 		return err
 	}
+	if large {
+		if _, err := w.Write(m.Payload); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
 // Decode deserializes a message from network data
 func (m *Message) Decode(r io.Reader) error {
-	// Read message type
-	if err := binary.Read(r, binary.BigEndian, &m.Type); err != nil {
-		return err
-	}
-
-	// Read payload length
-	var payloadLen uint32
-	if err := binary.Read(r, binary.BigEndian, &payloadLen); err != nil {
+	// Message type, network ID and payload length
+	var head [9]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
 		return err
 	}
+	m.Type = head[0]
+	m.NetworkID = binary.BigEndian.Uint32(head[1:])
+	payloadLen := binary.BigEndian.Uint32(head[5:])
 
 	if payloadLen > MaxMessageSize {
 		return ErrMessageTooLarge
@@ -114,12 +250,54 @@ func (m *Message) Decode(r io.Reader) error {
 	return nil
 }
 
+// release returns a message's pooled payload buffer to the pool once the
+// message is written
+func (m *Message) release() {
+	if m.pooled != nil {
+		*m.pooled = m.Payload[:0]
+		putBuffer(m.pooled)
+		m.pooled, m.Payload = nil, nil
+	}
+}
+
 // EncodeBlock serializes a block message
 func EncodeBlock(block *types.Block) ([]byte, error) {
-	// Serialize block header
-	header := block.Header
-	buf := make([]byte, 0, 1024)
+	return appendBlock(make([]byte, 0, 1024), block), nil
+}
+
+// appendBlock appends the wire encoding of a block to buf
+func appendBlock(buf []byte, block *types.Block) []byte {
+	buf = appendHeader(buf, block.Header)
+
+	// Transaction count
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(block.Transactions)))
+
+	// Transactions, each length-prefixed
+	for _, tx := range block.Transactions {
+		buf = appendLengthPrefixed(buf, func(buf []byte) []byte {
+			return appendTransaction(buf, tx)
+		})
+	}
+
+	return buf
+}
+
+// appendLengthPrefixed appends what fn appends to buf, preceded by its
+// length
+func appendLengthPrefixed(buf []byte, fn func(buf []byte) []byte) []byte {
+	start := len(buf)
+	buf = fn(binary.BigEndian.AppendUint32(buf, 0))
+	binary.BigEndian.PutUint32(buf[start:], uint32(len(buf)-start-4))
+	return buf
+}
+
+// EncodeHeader serializes a block header
+func EncodeHeader(header *types.BlockHeader) ([]byte, error) {
+	return appendHeader(make([]byte, 0, 512), header), nil
+}
 
+// appendHeader appends the wire encoding of a header to buf
+func appendHeader(buf []byte, header *types.BlockHeader) []byte {
 	// Version
 	buf = binary.BigEndian.AppendUint32(buf, header.Version)
 
@@ -148,6 +326,7 @@ func EncodeBlock(block *types.Block) ([]byte, error) {
 
 	// Miner info
 	buf = append(buf, header.MinerAddress[:]...)
+	buf = append(buf, header.PayoutAddress[:]...)
 	repFixed := uint64(header.ReputationScore * 1e9)
 	buf = binary.BigEndian.AppendUint64(buf, repFixed)
 
@@ -174,26 +353,620 @@ func EncodeBlock(block *types.Block) ([]byte, error) {
 	buf = binary.BigEndian.AppendUint16(buf, uint16(len(header.ExtraData)))
 	buf = append(buf, header.ExtraData...)
 
-	// Transaction count
-	buf = binary.BigEndian.AppendUint32(buf, uint32(len(block.Transactions)))
+	// Committee root
+	buf = append(buf, header.CommitteeRoot[:]...)
 
-	// Transactions (simplified - just hashes for now)
-	for _, tx := range block.Transactions {
-		txData, err := EncodeTransaction(tx)
+	// VRF task selection
+	buf = append(buf, byte(len(header.MinerPublicKey)))
+	buf = append(buf, header.MinerPublicKey...)
+	buf = append(buf, header.VRFSeed[:]...)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(header.VRFProof)))
+	buf = append(buf, header.VRFProof...)
+
+	// Miner signature
+	buf = append(buf, byte(len(header.Signature)))
+	buf = append(buf, header.Signature...)
+
+	// Nullifier accumulator
+	buf = append(buf, header.NullifierRoot[:]...)
+
+	// Model registry checkpoint
+	buf = append(buf, header.RegistryRoot[:]...)
+
+	// Transaction receipts
+	buf = append(buf, header.ReceiptsRoot[:]...)
+
+	return buf
+}
+
+// DecodeBlock deserializes a block encoded by EncodeBlock
+func DecodeBlock(data []byte) (*types.Block, error) {
+	r := &reader{data: data}
+	block := &types.Block{Header: decodeHeader(r)}
+	if err := decodeBody(r, block); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// decodeBody reads the transactions following a block's header
+func decodeBody(r *reader, block *types.Block) error {
+	count := r.uint32()
+	if r.err == nil && uint64(count)*4 > uint64(len(r.data)) {
+		return ErrTruncatedMessage
+	}
+	block.Transactions = make([]*types.Transaction, 0, count)
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		txData := r.bytes(int(r.uint32()))
+		if r.err != nil {
+			break
+		}
+		tx, err := DecodeTransaction(txData)
+		if err != nil {
+			return err
+		}
+		block.Transactions = append(block.Transactions, tx)
+	}
+	return r.err
+}
+
+// DecodeHeader deserializes a header encoded by EncodeHeader
+func DecodeHeader(data []byte) (*types.BlockHeader, error) {
+	r := &reader{data: data}
+	header := decodeHeader(r)
+	if r.err != nil {
+		return nil, r.err
+	}
+	return header, nil
+}
+
+// decodeHeader reads a header written by appendHeader
+func decodeHeader(r *reader) *types.BlockHeader {
+	h := &types.BlockHeader{}
+
+	h.Version = r.uint32()
+	copy(h.Hash[:], r.bytes(types.HashSize))
+
+	h.Parents = make([]types.Hash, r.uint8())
+	for i := range h.Parents {
+		copy(h.Parents[i][:], r.bytes(types.HashSize))
+	}
+
+	copy(h.TxRoot[:], r.bytes(types.HashSize))
+	copy(h.StateRoot[:], r.bytes(types.HashSize))
+
+	copy(h.PoUWResult[:], r.bytes(types.HashSize))
+	h.PoUWProof = r.bytes(int(r.uint32()))
+	copy(h.TaskID[:], r.bytes(types.HashSize))
+	h.QualityScore = float64(r.uint64()) / 1e9
+
+	copy(h.MinerAddress[:], r.bytes(types.AddressSize))
+	copy(h.PayoutAddress[:], r.bytes(types.AddressSize))
+	h.ReputationScore = float64(r.uint64()) / 1e9
+
+	h.Difficulty = new(big.Int).SetBytes(r.bytes(int(r.uint16())))
+	h.Nonce = r.uint64()
+	h.Timestamp = r.uint64()
+	h.Height = r.uint64()
+
+	if score := r.bytes(int(r.uint16())); len(score) > 0 {
+		var ok bool
+		if h.CumulativeScore, ok = new(big.Float).SetString(string(score)); !ok && r.err == nil {
+			r.err = ErrInvalidBlock
+		}
+	}
+
+	h.ExtraData = r.bytes(int(r.uint16()))
+	copy(h.CommitteeRoot[:], r.bytes(types.HashSize))
+
+	h.MinerPublicKey = r.bytes(int(r.uint8()))
+	copy(h.VRFSeed[:], r.bytes(types.HashSize))
+	h.VRFProof = r.bytes(int(r.uint16()))
+	h.Signature = r.bytes(int(r.uint8()))
+	copy(h.NullifierRoot[:], r.bytes(types.HashSize))
+	copy(h.RegistryRoot[:], r.bytes(types.HashSize))
+	copy(h.ReceiptsRoot[:], r.bytes(types.HashSize))
+	return h
+}
+
+// EncodeHeaders serializes a headers response
+func EncodeHeaders(headers []*types.BlockHeader) ([]byte, error) {
+	items := make([][]byte, len(headers))
+	for i, h := range headers {
+		items[i], _ = EncodeHeader(h)
+	}
+	return encodeList(items), nil
+}
+
+// DecodeHeaders deserializes a headers response
+func DecodeHeaders(data []byte) ([]*types.BlockHeader, error) {
+	var headers []*types.BlockHeader
+	err := decodeList(data, func(item []byte) error {
+		h, err := DecodeHeader(item)
+		if err != nil {
+			return err
+		}
+		headers = append(headers, h)
+		return nil
+	})
+	return headers, err
+}
+
+// EncodeBlocks serializes a blocks response
+func EncodeBlocks(blocks []*types.Block) ([]byte, error) {
+	buf := binary.BigEndian.AppendUint32(make([]byte, 0, 1024*len(blocks)+4), uint32(len(blocks)))
+	for _, b := range blocks {
+		buf = appendLengthPrefixed(buf, func(buf []byte) []byte {
+			return appendBlock(buf, b)
+		})
+	}
+	return buf, nil
+}
+
+// DecodeBlocks deserializes a blocks response
+func DecodeBlocks(data []byte) ([]*types.Block, error) {
+	var blocks []*types.Block
+	err := decodeList(data, func(item []byte) error {
+		b, err := DecodeBlock(item)
+		if err != nil {
+			return err
+		}
+		blocks = append(blocks, b)
+		return nil
+	})
+	return blocks, err
+}
+
+// EncodeGetHeaders serializes a headers request
+func EncodeGetHeaders(msg *GetHeadersMessage) ([]byte, error) {
+	buf := make([]byte, 0, 12)
+	buf = binary.BigEndian.AppendUint64(buf, msg.FromHeight)
+	buf = binary.BigEndian.AppendUint32(buf, msg.Count)
+	return buf, nil
+}
+
+// DecodeGetHeaders deserializes a headers request
+func DecodeGetHeaders(data []byte) (*GetHeadersMessage, error) {
+	r := &reader{data: data}
+	msg := &GetHeadersMessage{FromHeight: r.uint64(), Count: r.uint32()}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return msg, nil
+}
+
+// EncodeGetBlocks serializes a blocks request
+func EncodeGetBlocks(msg *GetBlocksMessage) ([]byte, error) {
+	buf := make([]byte, 0, 2+len(msg.Hashes)*types.HashSize)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(msg.Hashes)))
+	for _, h := range msg.Hashes {
+		buf = append(buf, h[:]...)
+	}
+	return buf, nil
+}
+
+// DecodeGetBlocks deserializes a blocks request
+func DecodeGetBlocks(data []byte) (*GetBlocksMessage, error) {
+	r := &reader{data: data}
+	msg := &GetBlocksMessage{Hashes: make([]types.Hash, r.uint16())}
+	for i := range msg.Hashes {
+		copy(msg.Hashes[i][:], r.bytes(types.HashSize))
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return msg, nil
+}
+
+// EncodeInventory serializes a transaction announcement or request
+func EncodeInventory(msg *InventoryMessage) ([]byte, error) {
+	if len(msg.Hashes) > MaxAnnouncedTxs {
+		return nil, ErrMessageTooLarge
+	}
+	return EncodeGetBlocks(&GetBlocksMessage{Hashes: msg.Hashes})
+}
+
+// DecodeInventory deserializes a transaction announcement or request,
+// rejecting more than MaxAnnouncedTxs hashes
+func DecodeInventory(data []byte) (*InventoryMessage, error) {
+	r := &reader{data: data}
+	count := int(r.uint16())
+	if count > MaxAnnouncedTxs {
+		return nil, ErrMessageTooLarge
+	}
+	msg := &InventoryMessage{Hashes: make([]types.Hash, count)}
+	for i := range msg.Hashes {
+		copy(msg.Hashes[i][:], r.bytes(types.HashSize))
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return msg, nil
+}
+
+// EncodeGetSnapshot serializes a snapshot request
+func EncodeGetSnapshot(msg *GetSnapshotMessage) ([]byte, error) {
+	buf := make([]byte, 0, types.HashSize+8)
+	buf = append(buf, msg.Hash[:]...)
+	buf = binary.BigEndian.AppendUint64(buf, msg.Offset)
+	return buf, nil
+}
+
+// DecodeGetSnapshot deserializes a snapshot request
+func DecodeGetSnapshot(data []byte) (*GetSnapshotMessage, error) {
+	r := &reader{data: data}
+	msg := &GetSnapshotMessage{}
+	copy(msg.Hash[:], r.bytes(types.HashSize))
+	msg.Offset = r.uint64()
+	if r.err != nil {
+		return nil, r.err
+	}
+	return msg, nil
+}
+
+// EncodeSnapshotChunk serializes a snapshot chunk
+func EncodeSnapshotChunk(msg *SnapshotChunkMessage) ([]byte, error) {
+	buf := make([]byte, 0, 8+types.HashSize+8+len(msg.Data))
+	buf = binary.BigEndian.AppendUint64(buf, msg.Height)
+	buf = append(buf, msg.Hash[:]...)
+	buf = binary.BigEndian.AppendUint64(buf, msg.Total)
+	buf = append(buf, msg.Data...)
+	return buf, nil
+}
+
+// DecodeSnapshotChunk deserializes a snapshot chunk
+func DecodeSnapshotChunk(data []byte) (*SnapshotChunkMessage, error) {
+	r := &reader{data: data}
+	msg := &SnapshotChunkMessage{Height: r.uint64()}
+	copy(msg.Hash[:], r.bytes(types.HashSize))
+	msg.Total = r.uint64()
+	if r.err != nil {
+		return nil, r.err
+	}
+	msg.Data = r.data
+	return msg, nil
+}
+
+// EncodeGetNotes serializes a note data request
+func EncodeGetNotes(msg *GetNotesMessage) ([]byte, error) {
+	buf := make([]byte, 0, 12)
+	buf = binary.BigEndian.AppendUint64(buf, msg.FromHeight)
+	buf = binary.BigEndian.AppendUint32(buf, msg.Count)
+	return buf, nil
+}
+
+// DecodeGetNotes deserializes a note data request
+func DecodeGetNotes(data []byte) (*GetNotesMessage, error) {
+	r := &reader{data: data}
+	msg := &GetNotesMessage{FromHeight: r.uint64(), Count: r.uint32()}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return msg, nil
+}
+
+// EncodeBlockNotes serializes a note data response
+func EncodeBlockNotes(blocks []*BlockNotes) ([]byte, error) {
+	items := make([][]byte, len(blocks))
+	for i, b := range blocks {
+		buf := make([]byte, 0, types.HashSize+24)
+		buf = append(buf, b.Hash[:]...)
+		buf = binary.BigEndian.AppendUint64(buf, b.Height)
+		buf = binary.BigEndian.AppendUint64(buf, b.Offset)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(b.Commitments)))
+		for _, c := range b.Commitments {
+			buf = append(buf, c.Value[:]...)
+			buf = binary.BigEndian.AppendUint32(buf, uint32(len(c.EncryptedNote)))
+			buf = append(buf, c.EncryptedNote...)
+		}
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(b.Nullifiers)))
+		for _, n := range b.Nullifiers {
+			buf = append(buf, n[:]...)
+		}
+		items[i] = buf
+	}
+	return encodeList(items), nil
+}
+
+// DecodeBlockNotes deserializes a note data response
+func DecodeBlockNotes(data []byte) ([]*BlockNotes, error) {
+	var blocks []*BlockNotes
+	err := decodeList(data, func(item []byte) error {
+		r := &reader{data: item}
+		b := &BlockNotes{}
+		copy(b.Hash[:], r.bytes(types.HashSize))
+		b.Height = r.uint64()
+		b.Offset = r.uint64()
+		for n := r.uint32(); n > 0 && r.err == nil; n-- {
+			var c types.Commitment
+			copy(c.Value[:], r.bytes(types.HashSize))
+			c.EncryptedNote = r.bytes(int(r.uint32()))
+			b.Commitments = append(b.Commitments, c)
+		}
+		for n := r.uint32(); n > 0 && r.err == nil; n-- {
+			var nullifier types.Hash
+			copy(nullifier[:], r.bytes(types.HashSize))
+			b.Nullifiers = append(b.Nullifiers, nullifier)
+		}
+		if r.err != nil {
+			return r.err
+		}
+		blocks = append(blocks, b)
+		return nil
+	})
+	return blocks, err
+}
+
+// EncodeGetProof serializes a state proof request
+func EncodeGetProof(msg *GetProofMessage) ([]byte, error) {
+	buf := make([]byte, 0, 2*types.HashSize)
+	buf = append(buf, msg.Hash[:]...)
+	buf = append(buf, msg.Key[:]...)
+	return buf, nil
+}
+
+// DecodeGetProof deserializes a state proof request
+func DecodeGetProof(data []byte) (*GetProofMessage, error) {
+	r := &reader{data: data}
+	msg := &GetProofMessage{}
+	copy(msg.Hash[:], r.bytes(types.HashSize))
+	copy(msg.Key[:], r.bytes(types.HashSize))
+	if r.err != nil {
+		return nil, r.err
+	}
+	return msg, nil
+}
+
+// EncodeProof serializes a state proof
+func EncodeProof(msg *ProofMessage) ([]byte, error) {
+	proof := msg.Proof
+	if proof == nil {
+		proof = &state.Proof{}
+	}
+	buf := make([]byte, 0, types.HashSize+len(proof.Empty)+2+len(proof.Siblings)*types.HashSize)
+	buf = append(buf, msg.Value[:]...)
+	buf = append(buf, proof.Empty[:]...)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(proof.Siblings)))
+	for _, sibling := range proof.Siblings {
+		buf = append(buf, sibling[:]...)
+	}
+	return buf, nil
+}
+
+// DecodeProof deserializes a state proof
+func DecodeProof(data []byte) (*ProofMessage, error) {
+	r := &reader{data: data}
+	msg := &ProofMessage{Proof: &state.Proof{}}
+	copy(msg.Value[:], r.bytes(types.HashSize))
+	copy(msg.Proof.Empty[:], r.bytes(len(msg.Proof.Empty)))
+	msg.Proof.Siblings = make([]types.Hash, r.uint16())
+	for i := range msg.Proof.Siblings {
+		copy(msg.Proof.Siblings[i][:], r.bytes(types.HashSize))
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return msg, nil
+}
+
+// EncodeGetPath serializes a Merkle path request
+func EncodeGetPath(msg *GetPathMessage) ([]byte, error) {
+	buf := make([]byte, 0, types.HashSize+8)
+	buf = append(buf, msg.Hash[:]...)
+	buf = binary.BigEndian.AppendUint64(buf, msg.Position)
+	return buf, nil
+}
+
+// DecodeGetPath deserializes a Merkle path request
+func DecodeGetPath(data []byte) (*GetPathMessage, error) {
+	r := &reader{data: data}
+	msg := &GetPathMessage{}
+	copy(msg.Hash[:], r.bytes(types.HashSize))
+	msg.Position = r.uint64()
+	if r.err != nil {
+		return nil, r.err
+	}
+	return msg, nil
+}
+
+// EncodePath serializes a Merkle path
+func EncodePath(path *zkp.MerklePath) ([]byte, error) {
+	buf := make([]byte, 0, 9+len(path.Siblings)*(types.HashSize+1))
+	buf = binary.BigEndian.AppendUint64(buf, path.LeafPosition)
+	buf = append(buf, byte(len(path.Siblings)))
+	for i, sibling := range path.Siblings {
+		buf = append(buf, sibling[:]...)
+		if i < len(path.PathBits) && path.PathBits[i] {
+			buf = append(buf, 1)
+		} else {
+			buf = append(buf, 0)
+		}
+	}
+	return buf, nil
+}
+
+// DecodePath deserializes a Merkle path
+func DecodePath(data []byte) (*zkp.MerklePath, error) {
+	r := &reader{data: data}
+	path := &zkp.MerklePath{LeafPosition: r.uint64()}
+	n := int(r.uint8())
+	path.Siblings = make([]types.Hash, n)
+	path.PathBits = make([]bool, n)
+	for i := 0; i < n; i++ {
+		copy(path.Siblings[i][:], r.bytes(types.HashSize))
+		path.PathBits[i] = r.uint8() == 1
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return path, nil
+}
+
+// EncodeNullifierFilter serializes a nullifier filter. It leads with the
+// hash of the block closing the epoch, which identifies it on the
+// nullifier filter topic.
+func EncodeNullifierFilter(f *zkp.NullifierFilter) ([]byte, error) {
+	buf := make([]byte, 0, types.HashSize+17+len(f.Bits))
+	buf = append(buf, f.Hash[:]...)
+	buf = binary.BigEndian.AppendUint64(buf, f.Epoch)
+	buf = binary.BigEndian.AppendUint32(buf, f.Count)
+	buf = append(buf, f.Hashes)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(f.Bits)))
+	buf = append(buf, f.Bits...)
+	return buf, nil
+}
+
+// DecodeNullifierFilter deserializes a nullifier filter
+func DecodeNullifierFilter(data []byte) (*zkp.NullifierFilter, error) {
+	r := &reader{data: data}
+	f := &zkp.NullifierFilter{}
+	copy(f.Hash[:], r.bytes(types.HashSize))
+	f.Epoch = r.uint64()
+	f.Count = r.uint32()
+	f.Hashes = r.uint8()
+	f.Bits = r.bytes(int(r.uint32()))
+	if r.err != nil {
+		return nil, r.err
+	}
+	return f, nil
+}
+
+// EncodeGetFilters serializes a nullifier filter request
+func EncodeGetFilters(msg *GetFiltersMessage) ([]byte, error) {
+	buf := make([]byte, 0, 12)
+	buf = binary.BigEndian.AppendUint64(buf, msg.FromEpoch)
+	buf = binary.BigEndian.AppendUint32(buf, msg.Count)
+	return buf, nil
+}
+
+// DecodeGetFilters deserializes a nullifier filter request
+func DecodeGetFilters(data []byte) (*GetFiltersMessage, error) {
+	r := &reader{data: data}
+	msg := &GetFiltersMessage{FromEpoch: r.uint64(), Count: r.uint32()}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return msg, nil
+}
+
+// EncodeNullifierFilters serializes a nullifier filter response
+func EncodeNullifierFilters(filters []*zkp.NullifierFilter) ([]byte, error) {
+	items := make([][]byte, len(filters))
+	for i, f := range filters {
+		item, err := EncodeNullifierFilter(f)
 		if err != nil {
 			return nil, err
 		}
-		buf = binary.BigEndian.AppendUint32(buf, uint32(len(txData)))
-		buf = append(buf, txData...)
+		items[i] = item
 	}
+	return encodeList(items), nil
+}
 
+// DecodeNullifierFilters deserializes a nullifier filter response
+func DecodeNullifierFilters(data []byte) ([]*zkp.NullifierFilter, error) {
+	var filters []*zkp.NullifierFilter
+	err := decodeList(data, func(item []byte) error {
+		f, err := DecodeNullifierFilter(item)
+		if err != nil {
+			return err
+		}
+		filters = append(filters, f)
+		return nil
+	})
+	return filters, err
+}
+
+// EncodeGetSpent serializes a spent nullifier proof request
+func EncodeGetSpent(msg *GetSpentMessage) ([]byte, error) {
+	buf := make([]byte, 0, 2*types.HashSize)
+	buf = append(buf, msg.Hash[:]...)
+	buf = append(buf, msg.Nullifier[:]...)
 	return buf, nil
 }
 
+// DecodeGetSpent deserializes a spent nullifier proof request
+func DecodeGetSpent(data []byte) (*GetSpentMessage, error) {
+	r := &reader{data: data}
+	msg := &GetSpentMessage{}
+	copy(msg.Hash[:], r.bytes(types.HashSize))
+	copy(msg.Nullifier[:], r.bytes(types.HashSize))
+	if r.err != nil {
+		return nil, r.err
+	}
+	return msg, nil
+}
+
+// EncodeSpent serializes a spent nullifier proof
+func EncodeSpent(msg *SpentMessage) ([]byte, error) {
+	if !msg.Spent || msg.Path == nil {
+		return []byte{0}, nil
+	}
+	path, err := EncodePath(msg.Path)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{1}, path...), nil
+}
+
+// DecodeSpent deserializes a spent nullifier proof
+func DecodeSpent(data []byte) (*SpentMessage, error) {
+	r := &reader{data: data}
+	msg := &SpentMessage{Spent: r.uint8() == 1}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if msg.Spent {
+		path, err := DecodePath(r.data)
+		if err != nil {
+			return nil, err
+		}
+		msg.Path = path
+	}
+	return msg, nil
+}
+
+// encodeList writes a count followed by length-prefixed items
+func encodeList(items [][]byte) []byte {
+	size := 4
+	for _, item := range items {
+		size += 4 + len(item)
+	}
+	return appendList(make([]byte, 0, size), items)
+}
+
+// appendList appends a list written as by encodeList to buf
+func appendList(buf []byte, items [][]byte) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(items)))
+	for _, item := range items {
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(item)))
+		buf = append(buf, item...)
+	}
+	return buf
+}
+
+// decodeList reads items written by encodeList
+func decodeList(data []byte, fn func(item []byte) error) error {
+	r := &reader{data: data}
+	count := r.uint32()
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		item := r.bytes(int(r.uint32()))
+		if r.err != nil {
+			break
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return r.err
+}
+
 // EncodeTransaction serializes a transaction
 func EncodeTransaction(tx *types.Transaction) ([]byte, error) {
-	buf := make([]byte, 0, 512)
+	return appendTransaction(make([]byte, 0, 512), tx), nil
+}
 
+// appendTransaction appends the wire encoding of a transaction to buf
+func appendTransaction(buf []byte, tx *types.Transaction) []byte {
 	// Version and hash
 	buf = binary.BigEndian.AppendUint32(buf, tx.Version)
 	buf = append(buf, tx.TxHash[:]...)
@@ -228,7 +1001,328 @@ func EncodeTransaction(tx *types.Transaction) ([]byte, error) {
 	buf = binary.BigEndian.AppendUint16(buf, uint16(len(tx.Memo)))
 	buf = append(buf, tx.Memo...)
 
-	return buf, nil
+	// Disclosures, so peers can check them against their policy
+	buf = appendDisclosures(buf, tx.Disclosures)
+
+	// Sealed operations replace the clear ones on the wire
+	if tx.Sealed != nil {
+		return appendSealedPayload(buf, tx.Sealed)
+	}
+
+	return AppendOperations(buf, tx)
+}
+
+// AppendOperations serializes a transaction's optional operations. It is
+// also the plaintext format of a sealed payload.
+func AppendOperations(buf []byte, tx *types.Transaction) []byte {
+	// Optional inference operation
+	if tx.Inference != nil {
+		buf = appendInferenceOp(buf, tx.Inference)
+	}
+
+	// Optional payout change
+	if tx.PayoutChange != nil {
+		buf = appendPayoutChange(buf, tx.PayoutChange)
+	}
+
+	// Optional stake delegation
+	if tx.Delegation != nil {
+		buf = appendDelegation(buf, tx.Delegation)
+	}
+
+	return buf
+}
+
+// DecodeOperations reads operations written by AppendOperations into tx
+func DecodeOperations(data []byte, tx *types.Transaction) error {
+	r := &reader{data: data}
+	readOperations(r, tx)
+	return r.err
+}
+
+// DecodeTransaction deserializes a transaction encoded by EncodeTransaction
+func DecodeTransaction(data []byte) (*types.Transaction, error) {
+	r := &reader{data: data}
+	tx := &types.Transaction{}
+
+	tx.Version = r.uint32()
+	copy(tx.TxHash[:], r.bytes(types.HashSize))
+
+	tx.Nullifiers = make([]types.Hash, r.uint8())
+	for i := range tx.Nullifiers {
+		copy(tx.Nullifiers[i][:], r.bytes(types.HashSize))
+	}
+
+	tx.Commitments = make([]types.Commitment, r.uint8())
+	for i := range tx.Commitments {
+		copy(tx.Commitments[i].Value[:], r.bytes(types.HashSize))
+	}
+
+	tx.Proof.ProofType = r.uint8()
+	tx.Proof.ProofData = r.bytes(int(r.uint32()))
+	tx.DisclosureFlags = r.uint32()
+	copy(tx.Anchor[:], r.bytes(types.HashSize))
+	tx.Fee = r.uint64()
+	tx.Memo = r.bytes(int(r.uint16()))
+	tx.Disclosures = readDisclosures(r)
+	if len(r.data) > 0 && r.data[0] == sealedPayloadTag {
+		tx.Sealed = readSealedPayload(r)
+	} else {
+		readOperations(r, tx)
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	return tx, nil
+}
+
+// appendDisclosures serializes a transaction's disclosures
+func appendDisclosures(buf []byte, disclosures []types.Disclosure) []byte {
+	buf = append(buf, byte(len(disclosures)))
+	for _, d := range disclosures {
+		buf = append(buf, byte(d.Type), d.Proof.ProofType)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(d.Proof.ProofData)))
+		buf = append(buf, d.Proof.ProofData...)
+		buf = append(buf, byte(len(d.Proof.PublicInputs)))
+		for _, input := range d.Proof.PublicInputs {
+			buf = append(buf, input[:]...)
+		}
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(d.PublicData)))
+		buf = append(buf, d.PublicData...)
+	}
+	return buf
+}
+
+// readDisclosures reads disclosures written by appendDisclosures
+func readDisclosures(r *reader) []types.Disclosure {
+	count := int(r.uint8())
+	if count == 0 {
+		return nil
+	}
+	disclosures := make([]types.Disclosure, count)
+	for i := range disclosures {
+		d := &disclosures[i]
+		d.Type = types.DisclosureType(r.uint8())
+		d.Proof.ProofType = r.uint8()
+		d.Proof.ProofData = r.bytes(int(r.uint32()))
+		d.Proof.PublicInputs = make([]types.Hash, r.uint8())
+		for j := range d.Proof.PublicInputs {
+			copy(d.Proof.PublicInputs[j][:], r.bytes(types.HashSize))
+		}
+		d.PublicData = r.bytes(int(r.uint16()))
+	}
+	return disclosures
+}
+
+// readOperations reads trailing operations into tx
+func readOperations(r *reader, tx *types.Transaction) {
+	for r.err == nil && len(r.data) > 0 {
+		switch r.data[0] {
+		case payoutChangeTag:
+			tx.PayoutChange = readPayoutChange(r)
+		case delegationTag:
+			tx.Delegation = readDelegation(r)
+		default:
+			tx.Inference = readInferenceOp(r)
+		}
+	}
+}
+
+// appendInferenceOp serializes a transaction's inference operation
+func appendInferenceOp(buf []byte, op *types.InferenceOp) []byte {
+	buf = append(buf, byte(op.Type))
+	switch op.Type {
+	case types.InferenceOpRequest:
+		req := op.Request
+		buf = append(buf, req.RequestID[:]...)
+		buf = append(buf, req.ModelID[:]...)
+		buf = append(buf, req.InputHash[:]...)
+		buf = append(buf, req.Refund[:]...)
+		buf = append(buf, byte(len(req.RequesterKey)))
+		buf = append(buf, req.RequesterKey...)
+		buf = binary.BigEndian.AppendUint64(buf, req.Escrow)
+		buf = binary.BigEndian.AppendUint64(buf, req.Timeout)
+		buf = binary.BigEndian.AppendUint64(buf, req.Nonce)
+	case types.InferenceOpResult:
+		res := op.Result
+		buf = append(buf, res.RequestID[:]...)
+		buf = append(buf, res.Node[:]...)
+		buf = append(buf, res.ResultHash[:]...)
+		buf = append(buf, res.LicenseID[:]...)
+		buf = append(buf, byte(len(res.Signature)))
+		buf = append(buf, res.Signature...)
+	case types.InferenceOpDispute:
+		d := op.Dispute
+		buf = append(buf, d.RequestID[:]...)
+		buf = append(buf, byte(len(d.Signature)))
+		buf = append(buf, d.Signature...)
+	}
+	return buf
+}
+
+// payoutChangeTag marks a payout change among a transaction's trailing
+// operations. It is outside the range of inference operation types.
+const payoutChangeTag = 0x80
+
+// appendPayoutChange serializes a transaction's payout change
+func appendPayoutChange(buf []byte, c *types.PayoutChange) []byte {
+	buf = append(buf, payoutChangeTag)
+	buf = append(buf, byte(len(c.MinerKey)))
+	buf = append(buf, c.MinerKey...)
+	buf = append(buf, c.Payout[:]...)
+	buf = binary.BigEndian.AppendUint64(buf, c.Nonce)
+	buf = append(buf, byte(len(c.Signature)))
+	buf = append(buf, c.Signature...)
+	return buf
+}
+
+// readPayoutChange deserializes a change written by appendPayoutChange
+func readPayoutChange(r *reader) *types.PayoutChange {
+	r.uint8() // tag
+	c := &types.PayoutChange{}
+	c.MinerKey = r.bytes(int(r.uint8()))
+	copy(c.Payout[:], r.bytes(types.AddressSize))
+	c.Nonce = r.uint64()
+	c.Signature = r.bytes(int(r.uint8()))
+	return c
+}
+
+// delegationTag marks a stake delegation among a transaction's trailing
+// operations
+const delegationTag = 0x82
+
+// appendDelegation serializes a transaction's stake delegation
+func appendDelegation(buf []byte, d *types.StakeDelegation) []byte {
+	buf = append(buf, delegationTag)
+	buf = append(buf, byte(len(d.DelegatorKey)))
+	buf = append(buf, d.DelegatorKey...)
+	buf = append(buf, d.Miner[:]...)
+	buf = append(buf, byte(d.Action))
+	buf = binary.BigEndian.AppendUint64(buf, d.Amount)
+	buf = binary.BigEndian.AppendUint64(buf, d.Nonce)
+	buf = append(buf, byte(len(d.Signature)))
+	buf = append(buf, d.Signature...)
+	return buf
+}
+
+// readDelegation deserializes a delegation written by appendDelegation
+func readDelegation(r *reader) *types.StakeDelegation {
+	r.uint8() // tag
+	d := &types.StakeDelegation{}
+	d.DelegatorKey = r.bytes(int(r.uint8()))
+	copy(d.Miner[:], r.bytes(types.AddressSize))
+	d.Action = types.DelegationAction(r.uint8())
+	d.Amount = r.uint64()
+	d.Nonce = r.uint64()
+	d.Signature = r.bytes(int(r.uint8()))
+	return d
+}
+
+// sealedPayloadTag marks a transaction whose operations are sealed
+const sealedPayloadTag = 0x81
+
+// appendSealedPayload serializes a transaction's sealed payload
+func appendSealedPayload(buf []byte, p *types.SealedPayload) []byte {
+	buf = append(buf, sealedPayloadTag)
+	buf = binary.BigEndian.AppendUint64(buf, p.Epoch)
+	buf = append(buf, byte(len(p.Ephemeral)))
+	buf = append(buf, p.Ephemeral...)
+	buf = append(buf, byte(len(p.Proof)))
+	buf = append(buf, p.Proof...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(p.Ciphertext)))
+	buf = append(buf, p.Ciphertext...)
+	return buf
+}
+
+// readSealedPayload deserializes a payload written by appendSealedPayload
+func readSealedPayload(r *reader) *types.SealedPayload {
+	r.uint8() // tag
+	p := &types.SealedPayload{}
+	p.Epoch = r.uint64()
+	p.Ephemeral = r.bytes(int(r.uint8()))
+	p.Proof = r.bytes(int(r.uint8()))
+	p.Ciphertext = r.bytes(int(r.uint32()))
+	return p
+}
+
+// readInferenceOp deserializes an operation written by appendInferenceOp
+func readInferenceOp(r *reader) *types.InferenceOp {
+	op := &types.InferenceOp{Type: types.InferenceOpType(r.uint8())}
+	switch op.Type {
+	case types.InferenceOpRequest:
+		req := &types.InferenceRequest{}
+		copy(req.RequestID[:], r.bytes(types.HashSize))
+		copy(req.ModelID[:], r.bytes(types.HashSize))
+		copy(req.InputHash[:], r.bytes(types.HashSize))
+		copy(req.Refund[:], r.bytes(types.AddressSize))
+		req.RequesterKey = r.bytes(int(r.uint8()))
+		req.Escrow = r.uint64()
+		req.Timeout = r.uint64()
+		req.Nonce = r.uint64()
+		op.Request = req
+	case types.InferenceOpResult:
+		res := &types.InferenceResult{}
+		copy(res.RequestID[:], r.bytes(types.HashSize))
+		copy(res.Node[:], r.bytes(types.AddressSize))
+		copy(res.ResultHash[:], r.bytes(types.HashSize))
+		copy(res.LicenseID[:], r.bytes(types.HashSize))
+		res.Signature = r.bytes(int(r.uint8()))
+		op.Result = res
+	case types.InferenceOpDispute:
+		d := &types.InferenceDispute{}
+		copy(d.RequestID[:], r.bytes(types.HashSize))
+		d.Signature = r.bytes(int(r.uint8()))
+		op.Dispute = d
+	default:
+		r.err = ErrInvalidInferenceOp
+	}
+	return op
+}
+
+// reader decodes big-endian fields, latching the first short read. The
+// slices it returns point into data rather than copying it.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil || n > len(r.data) {
+		r.err = ErrTruncatedMessage
+		return nil
+	}
+	out := r.data[:n:n]
+	r.data = r.data[n:]
+	return out
+}
+
+func (r *reader) uint8() uint8 {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) uint16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *reader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *reader) uint64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
 }
 
 // EncodeTask serializes a task assignment
@@ -265,15 +1359,125 @@ func EncodeTask(task *types.Task) ([]byte, error) {
 	return buf, nil
 }
 
+// DecodeTask deserializes a task assignment encoded by EncodeTask
+func DecodeTask(data []byte) (*types.Task, error) {
+	r := &reader{data: data}
+	task := &types.Task{}
+
+	copy(task.TaskID[:], r.bytes(types.HashSize))
+	copy(task.ModelID[:], r.bytes(types.HashSize))
+	task.DatasetCID = string(r.bytes(int(r.uint16())))
+	task.BatchStart = r.uint32()
+	task.BatchSize = r.uint32()
+	copy(task.Objective[:], r.bytes(types.HashSize))
+	task.Status = types.TaskStatus(r.uint8())
+	copy(task.AssignedMiner[:], r.bytes(types.AddressSize))
+	task.AssignedAt = r.uint64()
+	task.Deadline = r.uint64()
+	task.CompletedAt = r.uint64()
+	task.Reward = r.uint64()
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	return task, nil
+}
+
+// EncodeEvaluationJob serializes an evaluation job for the evaluation
+// topic. The first byte is MsgTypeEvalJob.
+func EncodeEvaluationJob(job *types.EvaluationJob) ([]byte, error) {
+	buf := make([]byte, 0, 128+len(job.Evaluators)*types.AddressSize)
+
+	buf = append(buf, MsgTypeEvalJob)
+	buf = append(buf, job.JobID[:]...)
+	buf = append(buf, job.ModelID[:]...)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(job.WeightsCID)))
+	buf = append(buf, []byte(job.WeightsCID)...)
+	buf = append(buf, job.BenchmarkID[:]...)
+	buf = binary.BigEndian.AppendUint64(buf, job.Epoch)
+	buf = binary.BigEndian.AppendUint64(buf, job.Deadline)
+
+	buf = append(buf, byte(len(job.Evaluators)))
+	for _, e := range job.Evaluators {
+		buf = append(buf, e[:]...)
+	}
+
+	return buf, nil
+}
+
+// DecodeEvaluationJob deserializes an evaluation job
+func DecodeEvaluationJob(data []byte) (*types.EvaluationJob, error) {
+	r := &reader{data: data}
+	if r.uint8() != MsgTypeEvalJob && r.err == nil {
+		return nil, ErrInvalidMessageType
+	}
+
+	job := &types.EvaluationJob{}
+	copy(job.JobID[:], r.bytes(types.HashSize))
+	copy(job.ModelID[:], r.bytes(types.HashSize))
+	job.WeightsCID = string(r.bytes(int(r.uint16())))
+	copy(job.BenchmarkID[:], r.bytes(types.HashSize))
+	job.Epoch = r.uint64()
+	job.Deadline = r.uint64()
+
+	job.Evaluators = make([]types.Address, r.uint8())
+	for i := range job.Evaluators {
+		copy(job.Evaluators[i][:], r.bytes(types.AddressSize))
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	return job, nil
+}
+
+// EncodeAttestation serializes an accuracy attestation for the evaluation
+// topic. The first byte is MsgTypeAttestation.
+func EncodeAttestation(att *types.AccuracyAttestation) ([]byte, error) {
+	buf := make([]byte, 0, 1+types.HashSize+types.AddressSize+18+len(att.Signature))
+
+	buf = append(buf, MsgTypeAttestation)
+	buf = append(buf, att.JobID[:]...)
+	buf = append(buf, att.Evaluator[:]...)
+	buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(att.Accuracy))
+	buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(att.Loss))
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(att.Signature)))
+	buf = append(buf, att.Signature...)
+
+	return buf, nil
+}
+
+// DecodeAttestation deserializes an accuracy attestation
+func DecodeAttestation(data []byte) (*types.AccuracyAttestation, error) {
+	r := &reader{data: data}
+	if r.uint8() != MsgTypeAttestation && r.err == nil {
+		return nil, ErrInvalidMessageType
+	}
+
+	att := &types.AccuracyAttestation{}
+	copy(att.JobID[:], r.bytes(types.HashSize))
+	copy(att.Evaluator[:], r.bytes(types.AddressSize))
+	att.Accuracy = math.Float64frombits(r.uint64())
+	att.Loss = math.Float64frombits(r.uint64())
+	att.Signature = r.bytes(int(r.uint16()))
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	return att, nil
+}
+
 // EncodeStatus serializes a status message
 func EncodeStatus(status *StatusMessage) ([]byte, error) {
-	buf := make([]byte, 0, 80)
+	buf := make([]byte, 0, 92)
 
 	buf = binary.BigEndian.AppendUint32(buf, status.Version)
 	buf = binary.BigEndian.AppendUint32(buf, status.NetworkID)
 	buf = binary.BigEndian.AppendUint64(buf, status.Height)
 	buf = append(buf, status.BestHash[:]...)
 	buf = append(buf, status.GenesisHash[:]...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(status.Roles))
+	buf = binary.BigEndian.AppendUint64(buf, status.PrunedHeight)
 
 	return buf, nil
 }
@@ -291,6 +1495,12 @@ func DecodeStatus(data []byte) (*StatusMessage, error) {
 	}
 	copy(status.BestHash[:], data[16:48])
 	copy(status.GenesisHash[:], data[48:80])
+	if len(data) >= 84 {
+		status.Roles = Roles(binary.BigEndian.Uint32(data[80:84]))
+	}
+	if len(data) >= 92 {
+		status.PrunedHeight = binary.BigEndian.Uint64(data[84:92])
+	}
 
 	return status, nil
 }
@@ -0,0 +1,179 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/holepunch"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// NATConfig sets how a node behind NAT stays reachable. AutoNAT runs
+// regardless: peers tell the node whether they could dial it back, and
+// the node answers their dial-back requests in turn.
+type NATConfig struct {
+	// StaticRelays are circuit relay v2 relays, as multiaddrs ending in
+	// /p2p/<id>. While AutoNAT finds the node unreachable it reserves a
+	// slot on them and advertises the relayed addresses.
+	StaticRelays []string
+
+	// HolePunching upgrades relayed connections to direct ones with DCUtR
+	HolePunching bool
+
+	// PortMapping asks the gateway for a port mapping over UPnP or NAT-PMP
+	PortMapping bool
+
+	// RelayService relays for peers behind NAT while AutoNAT finds the
+	// node publicly reachable
+	RelayService bool
+}
+
+// DefaultNATConfig returns the default NAT traversal settings
+func DefaultNATConfig() *NATConfig {
+	return &NATConfig{
+		HolePunching: true,
+		PortMapping:  true,
+		RelayService: true,
+	}
+}
+
+// NATStatus reports whether peers can reach the node
+type NATStatus struct {
+	// Reachability is what AutoNAT found, unknown until enough peers
+	// tried to dial the node back
+	Reachability network.Reachability
+
+	// Addrs are the direct addresses the node advertises and RelayAddrs
+	// the relayed ones
+	Addrs      []multiaddr.Multiaddr
+	RelayAddrs []multiaddr.Multiaddr
+
+	StaticRelays []peer.ID
+	HolePunching bool
+	RelayService bool
+
+	// Hole punches finished since the node started
+	HolePunchesSucceeded int
+	HolePunchesFailed    int
+}
+
+// ParseRelays parses relay multiaddrs, which must end in /p2p/<id>
+func ParseRelays(addrs []string) ([]peer.AddrInfo, error) {
+	maddrs := make([]multiaddr.Multiaddr, 0, len(addrs))
+	for _, addr := range addrs {
+		ma, err := multiaddr.NewMultiaddr(strings.TrimSpace(addr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid relay %q: %w", addr, err)
+		}
+		if _, err := peer.AddrInfoFromP2pAddr(ma); err != nil {
+			return nil, fmt.Errorf("invalid relay %q: %w", addr, err)
+		}
+		maddrs = append(maddrs, ma)
+	}
+	return peer.AddrInfosFromP2pAddrs(maddrs...)
+}
+
+// options returns the host options for the configuration, tracing hole
+// punches to state
+func (c *NATConfig) options(state *natState) ([]libp2p.Option, error) {
+	relays, err := ParseRelays(c.StaticRelays)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []libp2p.Option{libp2p.EnableNATService()}
+	if len(relays) > 0 {
+		opts = append(opts, libp2p.EnableAutoRelayWithStaticRelays(relays))
+		for _, r := range relays {
+			state.relays = append(state.relays, r.ID)
+		}
+	}
+	if c.HolePunching {
+		opts = append(opts, libp2p.EnableHolePunching(holepunch.WithTracer(state)))
+	}
+	if c.PortMapping {
+		opts = append(opts, libp2p.NATPortMap())
+	}
+	if c.RelayService {
+		opts = append(opts, libp2p.EnableRelayService())
+	}
+	state.holePunching = c.HolePunching
+	state.relayService = c.RelayService
+	return opts, nil
+}
+
+// natState tracks the node's reachability and hole punch outcomes
+type natState struct {
+	// Fixed at startup
+	relays       []peer.ID
+	holePunching bool
+	relayService bool
+
+	mu           sync.Mutex
+	reachability network.Reachability
+	punched      int
+	punchFailed  int
+}
+
+// Trace counts finished hole punches
+func (s *natState) Trace(evt *holepunch.Event) {
+	end, ok := evt.Evt.(*holepunch.EndHolePunchEvt)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if end.Success {
+		s.punched++
+	} else {
+		s.punchFailed++
+	}
+}
+
+// watch records AutoNAT's reachability changes until ctx is done
+func (s *natState) watch(ctx context.Context, sub event.Subscription) {
+	defer sub.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case evt, ok := <-sub.Out():
+			if !ok {
+				return
+			}
+			changed := evt.(event.EvtLocalReachabilityChanged)
+			s.mu.Lock()
+			s.reachability = changed.Reachability
+			s.mu.Unlock()
+		}
+	}
+}
+
+// NATStatus reports whether peers can reach the node, and how
+func (n *Node) NATStatus() *NATStatus {
+	n.nat.mu.Lock()
+	status := &NATStatus{
+		Reachability:         n.nat.reachability,
+		StaticRelays:         n.nat.relays,
+		HolePunching:         n.nat.holePunching,
+		RelayService:         n.nat.relayService,
+		HolePunchesSucceeded: n.nat.punched,
+		HolePunchesFailed:    n.nat.punchFailed,
+	}
+	n.nat.mu.Unlock()
+
+	for _, addr := range n.host.Addrs() {
+		if _, err := addr.ValueForProtocol(multiaddr.P_CIRCUIT); err == nil {
+			status.RelayAddrs = append(status.RelayAddrs, addr)
+		} else {
+			status.Addrs = append(status.Addrs, addr)
+		}
+	}
+	return status
+}
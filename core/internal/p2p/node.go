@@ -4,7 +4,9 @@ package p2p
 import (
 	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
+	"log/slog"
 	"sync"
 	"time"
 
@@ -12,23 +14,42 @@ import (
 	dht "github.com/libp2p/go-libp2p-kad-dht"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/metrics"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/pnet"
 	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/libp2p/go-libp2p/p2p/discovery/mdns"
 	drouting "github.com/libp2p/go-libp2p/p2p/discovery/routing"
 	"github.com/multiformats/go-multiaddr"
+
+	"github.com/ccoin/core/internal/logging"
+	"github.com/ccoin/core/internal/supervisor"
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
 )
 
-// Protocol IDs
+// Protocol IDs. Stream protocols are at 2.0.0 since stream messages
+// carry the sender's network ID; nodes speaking 1.0.0 fail to negotiate
+// them instead of misreading the header.
 const (
-	ProtocolID       = "/ccoin/1.0.0"
+	ProtocolID       = "/ccoin/2.0.0"
+	SyncProtocolID   = "/ccoin/sync/2.0.0"
 	BlockTopic       = "ccoin/blocks"
 	TransactionTopic = "ccoin/transactions"
 	TaskTopic        = "ccoin/tasks"
+	EvaluationTopic  = "ccoin/evaluations"
 )
 
+// TopicName returns the name a gossip topic is joined under on a network.
+// Each network gossips on its own topics, so nodes on different networks
+// sharing a peer never relay each other's messages.
+func TopicName(topic string, networkID uint32) string {
+	return fmt.Sprintf("%s/%08x", topic, networkID)
+}
+
 // Node represents a CCoin P2P network node
 type Node struct {
 	mu sync.RWMutex
@@ -39,23 +60,77 @@ type Node struct {
 	discovery *drouting.RoutingDiscovery
 
 	// Topics
-	blockTopic *pubsub.Topic
-	txTopic    *pubsub.Topic
-	taskTopic  *pubsub.Topic
+	blockTopic      *pubsub.Topic
+	txTopic         *pubsub.Topic
+	txAnnounceTopic *pubsub.Topic
+	taskTopic       *pubsub.Topic
+	evalTopic       *pubsub.Topic
+	filterTopic     *pubsub.Topic
 
 	// Subscriptions
-	blockSub *pubsub.Subscription
-	txSub    *pubsub.Subscription
-	taskSub  *pubsub.Subscription
+	blockSub      *pubsub.Subscription
+	txSub         *pubsub.Subscription
+	txAnnounceSub *pubsub.Subscription
+	taskSub       *pubsub.Subscription
+	evalSub       *pubsub.Subscription
+	filterSub     *pubsub.Subscription
 
 	// Handlers
 	blockHandler MessageHandler
 	txHandler    MessageHandler
 	taskHandler  MessageHandler
+	evalHandler  MessageHandler
+
+	// Checks gossiped nullifier filters (optional)
+	filterVerifier func(f *zkp.NullifierFilter) error
 
 	// Peer management
-	peers    map[peer.ID]*PeerInfo
-	maxPeers int
+	peers     map[peer.ID]*PeerInfo
+	maxPeers  int
+	diversity *DiversityConfig
+
+	// Status handshake: the network ID and genesis block peers must
+	// share, the chain status reported to them and peers recently found
+	// incompatible
+	networkID    uint32
+	genesis      types.Hash
+	statusSource func() *StatusMessage
+	incompatible map[peer.ID]time.Time
+
+	// Per-peer traffic totals and misbehavior notification
+	bandwidth     *metrics.BandwidthCounter
+	onMisbehavior func(peer.ID, Misbehavior)
+
+	// Gossip validation limits
+	validationTimeouts map[string]time.Duration
+	taskLimiter        *rateLimiter
+
+	// Transaction inventory: bodies served to peers, hashes already
+	// handled and fetches in flight
+	txInventory *encodingCache
+	txKnown     *seenCache
+	txFetches   *txFetcher
+
+	// Encodings of blocks recently broadcast or accepted from gossip,
+	// reused when relaying and serving them
+	encodedBlocks *encodingCache
+
+	// Reachability and hole punching, see NATStatus
+	nat *natState
+
+	// Panic supervision (optional)
+	supervisor *supervisor.Supervisor
+
+	// Services advertised to peers
+	roles Roles
+
+	// Banned peers and subnets (optional)
+	bans *BanManager
+
+	// Peer scores
+	scorer *PeerScorer
+
+	log *slog.Logger
 
 	// State
 	ctx    context.Context
@@ -70,6 +145,27 @@ type PeerInfo struct {
 	LastSeen    time.Time
 	Version     string
 	Height      uint64
+	Roles       Roles
+
+	// Outbound is set for peers the node dialed, and Network is the
+	// network the peer's address is in (see NetworkOf)
+	Outbound bool
+	Network  string
+
+	// Verified is set once the peer completes the status handshake, which
+	// also fills in Version, Height, Roles and PrunedHeight
+	Verified bool
+
+	// PrunedHeight is the height below which the peer serves no blocks
+	PrunedHeight uint64
+
+	// Stats holds the peer's protocol counters
+	Stats PeerStats
+
+	// Score is the node's own score of the peer and GossipScore
+	// GossipSub's, which includes it
+	Score       float64
+	GossipScore float64
 }
 
 // MessageHandler defines the interface for handling incoming messages
@@ -82,6 +178,40 @@ type Config struct {
 	PrivateKey    crypto.PrivKey
 	MaxPeers      int
 	EnableMDNS    bool
+
+	// Gossip tunes GossipSub; nil uses the home profile
+	Gossip *GossipConfig
+
+	// Bans keeps banned peers and subnets from connecting; nil bans none
+	Bans *BanManager
+
+	// Scoring tunes peer scoring and when misbehaving peers are banned;
+	// nil uses DefaultScoringConfig
+	Scoring *ScoringConfig
+
+	// Diversity limits outbound peers per network; nil uses
+	// DefaultDiversityConfig
+	Diversity *DiversityConfig
+
+	// NetworkID is the network peers must be on, see NetworkIDFor
+	NetworkID uint32
+
+	// GenesisHash pins the network's genesis block: peers on another are
+	// refused even before the node has a chain. Zero accepts the genesis
+	// of the first chain synced.
+	GenesisHash types.Hash
+
+	// PSK makes the node part of a private network, only reachable by
+	// nodes holding the same key (see LoadPSK); nil joins the public one
+	PSK pnet.PSK
+
+	// NAT sets relays, hole punching and port mapping; nil uses
+	// DefaultNATConfig
+	NAT *NATConfig
+
+	// Logger receives the node's and its sync managers' logs; nil uses
+	// the p2p module logger
+	Logger *slog.Logger
 }
 
 // DefaultConfig returns default P2P configuration
@@ -123,17 +253,44 @@ func NewNode(ctx context.Context, cfg *Config) (*Node, error) {
 		listenAddrs[i] = ma
 	}
 
+	natCfg := cfg.NAT
+	if natCfg == nil {
+		natCfg = DefaultNATConfig()
+	}
+	nat := &natState{}
+	natOpts, err := natCfg.options(nat)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
 	// Create libp2p host
-	h, err := libp2p.New(
+	bandwidth := metrics.NewBandwidthCounter()
+	hostOpts := []libp2p.Option{
 		libp2p.Identity(privKey),
 		libp2p.ListenAddrs(listenAddrs...),
-		libp2p.EnableNATService(),
 		libp2p.EnableRelay(),
-	)
+		libp2p.BandwidthReporter(bandwidth),
+	}
+	hostOpts = append(hostOpts, natOpts...)
+	if cfg.PSK != nil {
+		hostOpts = append(hostOpts, libp2p.PrivateNetwork(cfg.PSK))
+	}
+	if cfg.Bans != nil {
+		hostOpts = append(hostOpts, libp2p.ConnectionGater(&banGater{bans: cfg.Bans}))
+	}
+	h, err := libp2p.New(hostOpts...)
 	if err != nil {
 		cancel()
 		return nil, fmt.Errorf("failed to create host: %w", err)
 	}
+	reachability, err := h.EventBus().Subscribe(new(event.EvtLocalReachabilityChanged))
+	if err != nil {
+		h.Close()
+		cancel()
+		return nil, fmt.Errorf("failed to watch reachability: %w", err)
+	}
+	go nat.watch(nodeCtx, reachability)
 
 	// Create DHT for peer discovery
 	kadDHT, err := dht.New(nodeCtx, h, dht.Mode(dht.ModeAuto))
@@ -144,7 +301,23 @@ func NewNode(ctx context.Context, cfg *Config) (*Node, error) {
 	}
 
 	// Create pubsub with GossipSub
-	ps, err := pubsub.NewGossipSub(nodeCtx, h)
+	gossip := cfg.Gossip
+	if gossip == nil {
+		gossip, _ = GossipProfile(GossipProfileHome)
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = logging.Module("p2p")
+	}
+	scorer := NewPeerScorer(cfg.Scoring, cfg.Bans, logger)
+	gossipOpts, err := gossip.options(scorer, cfg.NetworkID)
+	if err != nil {
+		kadDHT.Close()
+		h.Close()
+		cancel()
+		return nil, err
+	}
+	ps, err := pubsub.NewGossipSub(nodeCtx, h, gossipOpts...)
 	if err != nil {
 		kadDHT.Close()
 		h.Close()
@@ -152,14 +325,41 @@ func NewNode(ctx context.Context, cfg *Config) (*Node, error) {
 		return nil, fmt.Errorf("failed to create pubsub: %w", err)
 	}
 
+	diversity := cfg.Diversity
+	if diversity == nil {
+		diversity = DefaultDiversityConfig()
+	}
+
 	node := &Node{
-		host:     h,
-		dht:      kadDHT,
-		pubsub:   ps,
-		peers:    make(map[peer.ID]*PeerInfo),
-		maxPeers: cfg.MaxPeers,
-		ctx:      nodeCtx,
-		cancel:   cancel,
+		host:      h,
+		dht:       kadDHT,
+		pubsub:    ps,
+		peers:     make(map[peer.ID]*PeerInfo),
+		maxPeers:  cfg.MaxPeers,
+		diversity: diversity,
+		networkID: cfg.NetworkID,
+		genesis:   cfg.GenesisHash,
+		bandwidth: bandwidth,
+		roles:     RoleRelay,
+		bans:      cfg.Bans,
+		scorer:    scorer,
+		log:       logger,
+		ctx:       nodeCtx,
+		cancel:    cancel,
+
+		validationTimeouts: gossip.ValidationTimeouts,
+		incompatible:       make(map[peer.ID]time.Time),
+		txInventory:        newEncodingCache(txInventoryBytes),
+		txKnown:            newSeenCache(txKnownSize),
+		txFetches:          newTxFetcher(),
+		encodedBlocks:      newEncodingCache(encodedBlockBytes),
+		nat:                nat,
+	}
+	h.SetStreamHandler(StatusProtocolID, node.handleStatus)
+
+	// Drop peers as soon as they are banned
+	if cfg.Bans != nil {
+		cfg.Bans.SetBanHandler(node.dropBanned)
 	}
 
 	// Set up connection handler
@@ -178,14 +378,14 @@ func NewNode(ctx context.Context, cfg *Config) (*Node, error) {
 	for _, peerAddr := range cfg.BootstrapPeers {
 		if err := node.connectToPeer(peerAddr); err != nil {
 			// Log but don't fail on bootstrap connection errors
-			fmt.Printf("Warning: failed to connect to bootstrap peer %s: %v\n", peerAddr, err)
+			node.log.Warn("failed to connect to bootstrap peer", "peer", peerAddr, "err", err)
 		}
 	}
 
 	// Set up mDNS for local peer discovery
 	if cfg.EnableMDNS {
 		if err := node.setupMDNS(); err != nil {
-			fmt.Printf("Warning: mDNS setup failed: %v\n", err)
+			node.log.Warn("mDNS setup failed", "err", err)
 		}
 	}
 
@@ -193,10 +393,16 @@ func NewNode(ctx context.Context, cfg *Config) (*Node, error) {
 	node.discovery = drouting.NewRoutingDiscovery(kadDHT)
 
 	// Join topics
+	if err := node.registerValidators(gossip); err != nil {
+		node.Close()
+		return nil, fmt.Errorf("failed to register gossip validators: %w", err)
+	}
 	if err := node.joinTopics(); err != nil {
 		node.Close()
 		return nil, fmt.Errorf("failed to join topics: %w", err)
 	}
+	h.SetStreamHandler(RelayProtocolID, node.handleRelay)
+	h.SetStreamHandler(TxFetchProtocolID, node.handleTxFetch)
 
 	return node, nil
 }
@@ -206,7 +412,7 @@ func (n *Node) joinTopics() error {
 	var err error
 
 	// Block topic
-	n.blockTopic, err = n.pubsub.Join(BlockTopic)
+	n.blockTopic, err = n.pubsub.Join(TopicName(BlockTopic, n.networkID))
 	if err != nil {
 		return fmt.Errorf("failed to join block topic: %w", err)
 	}
@@ -216,7 +422,7 @@ func (n *Node) joinTopics() error {
 	}
 
 	// Transaction topic
-	n.txTopic, err = n.pubsub.Join(TransactionTopic)
+	n.txTopic, err = n.pubsub.Join(TopicName(TransactionTopic, n.networkID))
 	if err != nil {
 		return fmt.Errorf("failed to join tx topic: %w", err)
 	}
@@ -225,8 +431,19 @@ func (n *Node) joinTopics() error {
 		return fmt.Errorf("failed to subscribe to transactions: %w", err)
 	}
 
+	// Transaction announcement topic; announcements are handled by
+	// their validator
+	n.txAnnounceTopic, err = n.pubsub.Join(TopicName(TxAnnounceTopic, n.networkID))
+	if err != nil {
+		return fmt.Errorf("failed to join tx announcement topic: %w", err)
+	}
+	n.txAnnounceSub, err = n.txAnnounceTopic.Subscribe()
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to tx announcements: %w", err)
+	}
+
 	// Task topic
-	n.taskTopic, err = n.pubsub.Join(TaskTopic)
+	n.taskTopic, err = n.pubsub.Join(TopicName(TaskTopic, n.networkID))
 	if err != nil {
 		return fmt.Errorf("failed to join task topic: %w", err)
 	}
@@ -235,19 +452,58 @@ func (n *Node) joinTopics() error {
 		return fmt.Errorf("failed to subscribe to tasks: %w", err)
 	}
 
+	// Evaluation topic (jobs and evaluator attestations)
+	n.evalTopic, err = n.pubsub.Join(TopicName(EvaluationTopic, n.networkID))
+	if err != nil {
+		return fmt.Errorf("failed to join evaluation topic: %w", err)
+	}
+	n.evalSub, err = n.evalTopic.Subscribe()
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to evaluations: %w", err)
+	}
+
+	// Nullifier filter topic; filters are handled by their validator
+	n.filterTopic, err = n.pubsub.Join(TopicName(NullifierFilterTopic, n.networkID))
+	if err != nil {
+		return fmt.Errorf("failed to join nullifier filter topic: %w", err)
+	}
+	n.filterSub, err = n.filterTopic.Subscribe()
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to nullifier filters: %w", err)
+	}
+
 	return nil
 }
 
 // Start begins processing messages
 func (n *Node) Start() {
-	go n.processMessages(n.blockSub, n.blockHandler)
-	go n.processMessages(n.txSub, n.txHandler)
-	go n.processMessages(n.taskSub, n.taskHandler)
-	go n.maintainPeers()
+	n.spawn("p2p.blocks", func() { n.processMessages("p2p.blocks", BlockTopic, n.blockSub, n.blockHandler) })
+	n.spawn("p2p.transactions", func() { n.processMessages("p2p.transactions", TransactionTopic, n.txSub, n.txHandler) })
+	n.spawn("p2p.announcements", func() { n.processMessages("p2p.announcements", TxAnnounceTopic, n.txAnnounceSub, nil) })
+	n.spawn("p2p.tasks", func() { n.processMessages("p2p.tasks", TaskTopic, n.taskSub, n.taskHandler) })
+	n.spawn("p2p.evaluations", func() { n.processMessages("p2p.evaluations", EvaluationTopic, n.evalSub, n.evalHandler) })
+	n.spawn("p2p.filters", func() { n.processMessages("p2p.filters", NullifierFilterTopic, n.filterSub, nil) })
+	n.spawn("p2p.peers", n.maintainPeers)
+}
+
+// spawn runs a node goroutine, under the supervisor if one is set
+func (n *Node) spawn(name string, fn func()) {
+	if n.supervisor == nil {
+		go fn()
+		return
+	}
+
+	err := n.supervisor.Go(n.ctx, name, func(ctx context.Context) error {
+		fn()
+		return nil
+	})
+	if err != nil {
+		n.log.Warn("failed to start goroutine", "name", name, "err", err)
+	}
 }
 
-// processMessages handles incoming messages on a subscription
-func (n *Node) processMessages(sub *pubsub.Subscription, handler MessageHandler) {
+// processMessages handles incoming messages on a subscription to topic
+func (n *Node) processMessages(name, topic string, sub *pubsub.Subscription, handler MessageHandler) {
 	for {
 		msg, err := sub.Next(n.ctx)
 		if err != nil {
@@ -270,9 +526,18 @@ func (n *Node) processMessages(sub *pubsub.Subscription, handler MessageHandler)
 		n.mu.Unlock()
 
 		// Call handler if set
+		// A panicking handler drops the message, not the node
 		if handler != nil {
-			if err := handler(n.ctx, msg); err != nil {
-				fmt.Printf("Message handler error: %v\n", err)
+			ctx, cancel := n.handlerContext(topic)
+			start := time.Now()
+			err := supervisor.Protect(name, func() error { return handler(ctx, msg) })
+			cancel()
+			n.recordMessage(msg.ReceivedFrom, topic, time.Since(start), err)
+			if err != nil && !errors.Is(err, ErrDuplicateMessage) {
+				if n.supervisor != nil {
+					n.supervisor.ReportPanic(err)
+				}
+				n.log.Debug("message handler error", "topic", topic, "peer", msg.ReceivedFrom, "err", err)
 			}
 		}
 	}
@@ -312,22 +577,36 @@ func (n *Node) discoverPeers() {
 		return
 	}
 
+	var candidates []peer.AddrInfo
 	for p := range peerChan {
 		if p.ID == n.host.ID() {
 			continue
 		}
-		if len(p.Addrs) == 0 {
+		if len(p.Addrs) == 0 || n.isIncompatible(p.ID) {
 			continue
 		}
+		candidates = append(candidates, p)
+	}
+	n.preferDiverse(candidates)
 
+	for _, p := range candidates {
 		n.mu.RLock()
 		_, exists := n.peers[p.ID]
+		full := len(n.peers) >= n.maxPeers
 		n.mu.RUnlock()
 
-		if !exists && len(n.peers) < n.maxPeers {
-			if err := n.host.Connect(ctx, p); err == nil {
-				n.addPeer(p.ID, p.Addrs)
-			}
+		if full {
+			return
+		}
+		if exists {
+			continue
+		}
+		if network := networkOfAddrs(p.Addrs); n.networkFull(network) {
+			n.log.Debug("skipping peer in a full network", "peer", p.ID, "network", network)
+			continue
+		}
+		if err := n.host.Connect(ctx, p); err == nil {
+			n.addPeer(p.ID, p.Addrs, true)
 		}
 	}
 }
@@ -344,6 +623,19 @@ func (n *Node) pruneStale() {
 			delete(n.peers, id)
 		}
 	}
+
+	now := time.Now()
+	for id, until := range n.incompatible {
+		if now.After(until) {
+			delete(n.incompatible, id)
+		}
+	}
+}
+
+// SetSupervisor enables panic supervision for the node's goroutines.
+// Must be called before Start.
+func (n *Node) SetSupervisor(s *supervisor.Supervisor) {
+	n.supervisor = s
 }
 
 // SetBlockHandler sets the handler for incoming blocks
@@ -361,14 +653,40 @@ func (n *Node) SetTaskHandler(handler MessageHandler) {
 	n.taskHandler = handler
 }
 
-// BroadcastBlock broadcasts a block to the network
+// SetEvaluationHandler sets the handler for evaluation jobs and attestations
+func (n *Node) SetEvaluationHandler(handler MessageHandler) {
+	n.evalHandler = handler
+}
+
+// BroadcastBlock broadcasts a block to the network and keeps its
+// encoding for EncodeBlock
 func (n *Node) BroadcastBlock(data []byte) error {
+	if hash, ok := peekHash(data, blockIDOffset); ok {
+		n.encodedBlocks.add(hash, data)
+	}
 	return n.blockTopic.Publish(n.ctx, data)
 }
 
-// BroadcastTransaction broadcasts a transaction to the network
+// EncodeBlock encodes a block, reusing the encoding of a block the node
+// recently broadcast or accepted from gossip. The result must not be
+// modified.
+func (n *Node) EncodeBlock(block *types.Block) ([]byte, error) {
+	if data, ok := n.encodedBlocks.get(block.Header.Hash); ok {
+		return data, nil
+	}
+	return EncodeBlock(block)
+}
+
+// BroadcastTransaction announces a transaction to the network and keeps
+// it in the node's inventory for peers to fetch
 func (n *Node) BroadcastTransaction(data []byte) error {
-	return n.txTopic.Publish(n.ctx, data)
+	tx, err := DecodeTransaction(data)
+	if err != nil {
+		return err
+	}
+	n.txKnown.add(tx.TxHash)
+	n.txInventory.add(tx.TxHash, data)
+	return n.AnnounceTransactions([]types.Hash{tx.TxHash})
 }
 
 // BroadcastTask broadcasts a task to the network
@@ -376,6 +694,11 @@ func (n *Node) BroadcastTask(data []byte) error {
 	return n.taskTopic.Publish(n.ctx, data)
 }
 
+// BroadcastEvaluation broadcasts an evaluation job or attestation
+func (n *Node) BroadcastEvaluation(data []byte) error {
+	return n.evalTopic.Publish(n.ctx, data)
+}
+
 // connectToPeer connects to a peer given its multiaddress
 func (n *Node) connectToPeer(addr string) error {
 	ma, err := multiaddr.NewMultiaddr(addr)
@@ -395,27 +718,36 @@ func (n *Node) connectToPeer(addr string) error {
 		return err
 	}
 
-	n.addPeer(peerInfo.ID, peerInfo.Addrs)
+	n.addPeer(peerInfo.ID, peerInfo.Addrs, true)
 	return nil
 }
 
-// addPeer adds a peer to the peer list
-func (n *Node) addPeer(id peer.ID, addrs []multiaddr.Multiaddr) {
+// addPeer adds a peer to the peer list. A peer already listed, from its
+// connection notification, is kept.
+func (n *Node) addPeer(id peer.ID, addrs []multiaddr.Multiaddr, outbound bool) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
+	if p, exists := n.peers[id]; exists {
+		p.Outbound = p.Outbound || outbound
+		return
+	}
 	n.peers[id] = &PeerInfo{
 		ID:          id,
 		Addrs:       addrs,
 		ConnectedAt: time.Now(),
 		LastSeen:    time.Now(),
+		Outbound:    outbound,
+		Network:     networkOfAddrs(addrs),
 	}
 }
 
 // onPeerConnected handles new peer connections
 func (n *Node) onPeerConnected(_ network.Network, conn network.Conn) {
 	id := conn.RemotePeer()
-	n.addPeer(id, []multiaddr.Multiaddr{conn.RemoteMultiaddr()})
+	outbound := conn.Stat().Direction == network.DirOutbound
+	n.addPeer(id, []multiaddr.Multiaddr{conn.RemoteMultiaddr()}, outbound)
+	go n.handshake(conn)
 }
 
 // onPeerDisconnected handles peer disconnections
@@ -424,6 +756,10 @@ func (n *Node) onPeerDisconnected(_ network.Network, conn network.Conn) {
 	n.mu.Lock()
 	delete(n.peers, id)
 	n.mu.Unlock()
+
+	if n.taskLimiter != nil {
+		n.taskLimiter.forget(id)
+	}
 }
 
 // setupMDNS sets up mDNS for local network peer discovery
@@ -462,18 +798,40 @@ func (n *Node) PeerCount() int {
 	return len(n.peers)
 }
 
-// Peers returns information about connected peers
+// Peers returns snapshots of the connected peers and their statistics
 func (n *Node) Peers() []*PeerInfo {
 	n.mu.RLock()
 	defer n.mu.RUnlock()
 
 	peers := make([]*PeerInfo, 0, len(n.peers))
 	for _, p := range n.peers {
-		peers = append(peers, p)
+		peers = append(peers, n.snapshot(p))
 	}
 	return peers
 }
 
+// SetPeerHeight records the DAG height reported by a peer
+func (n *Node) SetPeerHeight(id peer.ID, height uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if p, exists := n.peers[id]; exists {
+		p.Height = height
+		p.LastSeen = time.Now()
+	}
+}
+
+// SetPeerPrunedHeight records the height below which a peer serves no
+// blocks
+func (n *Node) SetPeerPrunedHeight(id peer.ID, height uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if p, exists := n.peers[id]; exists {
+		p.PrunedHeight = height
+	}
+}
+
 // Close shuts down the node
 func (n *Node) Close() error {
 	n.cancel()
@@ -487,6 +845,12 @@ func (n *Node) Close() error {
 	if n.taskSub != nil {
 		n.taskSub.Cancel()
 	}
+	if n.evalSub != nil {
+		n.evalSub.Cancel()
+	}
+	if n.filterSub != nil {
+		n.filterSub.Cancel()
+	}
 
 	if n.dht != nil {
 		n.dht.Close()
@@ -499,3 +863,8 @@ func (n *Node) Close() error {
 func (n *Node) RegisterProtocol(protoID protocol.ID, handler network.StreamHandler) {
 	n.host.SetStreamHandler(protoID, handler)
 }
+
+// OpenStream opens a stream to a peer on a custom protocol
+func (n *Node) OpenStream(ctx context.Context, id peer.ID, protoID protocol.ID) (network.Stream, error) {
+	return n.host.NewStream(ctx, id, protoID)
+}
@@ -0,0 +1,142 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/ccoin/core/internal/supervisor"
+)
+
+// ErrDuplicateMessage is returned by message handlers for messages that
+// were already processed. Duplicates are counted but not logged as
+// misbehavior.
+var ErrDuplicateMessage = errors.New("duplicate message")
+
+// maxMisbehaviorLog bounds the misbehavior reasons kept per peer
+const maxMisbehaviorLog = 32
+
+// Misbehavior records one protocol violation by a peer
+type Misbehavior struct {
+	Time   time.Time
+	Topic  string
+	Reason string
+}
+
+// PeerStats holds a peer's protocol counters
+type PeerStats struct {
+	// BlocksRelayed counts valid blocks first received from the peer
+	BlocksRelayed uint64
+
+	// InvalidMessages counts messages its handler rejected
+	InvalidMessages uint64
+
+	// DuplicateMessages counts messages already processed
+	DuplicateMessages uint64
+
+	// BytesIn and BytesOut are totals over all streams to the peer
+	BytesIn  uint64
+	BytesOut uint64
+
+	// AvgValidationLatency is the mean time taken to validate the
+	// peer's blocks
+	AvgValidationLatency time.Duration
+
+	// Misbehavior holds the most recent violations, oldest first
+	Misbehavior []Misbehavior
+
+	validationTotal time.Duration
+}
+
+// recordValidation adds a block validation time to the average
+func (s *PeerStats) recordValidation(d time.Duration) {
+	s.validationTotal += d
+	s.AvgValidationLatency = s.validationTotal / time.Duration(s.BlocksRelayed)
+}
+
+// recordMisbehavior appends to the rolling misbehavior log
+func (s *PeerStats) recordMisbehavior(m Misbehavior) {
+	if len(s.Misbehavior) >= maxMisbehaviorLog {
+		copy(s.Misbehavior, s.Misbehavior[1:])
+		s.Misbehavior = s.Misbehavior[:len(s.Misbehavior)-1]
+	}
+	s.Misbehavior = append(s.Misbehavior, m)
+}
+
+// SetMisbehaviorHandler registers fn to be called for every misbehavior
+// recorded, for example to feed peer scoring. It is called without the
+// node lock held.
+func (n *Node) SetMisbehaviorHandler(fn func(id peer.ID, m Misbehavior)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.onMisbehavior = fn
+}
+
+// ReportMisbehavior records a protocol violation by a peer and charges
+// it to the peer's score
+func (n *Node) ReportMisbehavior(id peer.ID, topic, reason string) {
+	m := Misbehavior{Time: time.Now(), Topic: topic, Reason: reason}
+	n.scorer.Penalize(id, m)
+
+	n.mu.Lock()
+	if p, exists := n.peers[id]; exists {
+		p.Stats.recordMisbehavior(m)
+	}
+	handler := n.onMisbehavior
+	n.mu.Unlock()
+
+	if handler != nil {
+		handler(id, m)
+	}
+}
+
+// recordMessage updates a peer's counters with a handler's outcome. A
+// panicking or timed out handler is a local problem, not the peer's fault.
+func (n *Node) recordMessage(id peer.ID, topic string, elapsed time.Duration, err error) {
+	var perr *supervisor.PanicError
+	if errors.As(err, &perr) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+
+	n.mu.Lock()
+	p, exists := n.peers[id]
+	if !exists {
+		n.mu.Unlock()
+		return
+	}
+	switch {
+	case err == nil:
+		if topic == BlockTopic {
+			p.Stats.BlocksRelayed++
+			p.Stats.recordValidation(elapsed)
+		}
+	case errors.Is(err, ErrDuplicateMessage):
+		p.Stats.DuplicateMessages++
+	default:
+		p.Stats.InvalidMessages++
+	}
+	n.mu.Unlock()
+
+	switch {
+	case err == nil && topic == BlockTopic:
+		n.scorer.Reward(id)
+	case err != nil && !errors.Is(err, ErrDuplicateMessage):
+		n.ReportMisbehavior(id, topic, err.Error())
+	}
+}
+
+// snapshot copies a peer's info, filling in bandwidth totals and scores
+func (n *Node) snapshot(p *PeerInfo) *PeerInfo {
+	copied := *p
+	copied.Stats.Misbehavior = append([]Misbehavior(nil), p.Stats.Misbehavior...)
+	copied.Score = n.scorer.Score(p.ID)
+	copied.GossipScore = n.scorer.GossipScore(p.ID)
+	if n.bandwidth != nil {
+		bw := n.bandwidth.GetBandwidthForPeer(p.ID)
+		copied.Stats.BytesIn = uint64(bw.TotalIn)
+		copied.Stats.BytesOut = uint64(bw.TotalOut)
+	}
+	return &copied
+}
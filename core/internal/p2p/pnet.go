@@ -0,0 +1,62 @@
+package p2p
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/libp2p/go-libp2p/core/pnet"
+)
+
+// DefaultPSKFile is the private network key's file name in the data
+// directory, in the swarm.key format other libp2p nodes read
+const DefaultPSKFile = "swarm.key"
+
+// pskSize is the size of a private network key
+const pskSize = 32
+
+// ErrPSKExists is returned when creating over an existing private network
+// key
+var ErrPSKExists = errors.New("private network key already exists")
+
+// LoadPSK reads a private network key. Nodes with one only connect to
+// nodes holding the same key, which encrypts every connection before the
+// handshake; nodes without one cannot reach them at all.
+func LoadPSK(path string) (pnet.PSK, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	psk, err := pnet.DecodeV1PSK(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid private network key %s: %w", path, err)
+	}
+	return psk, nil
+}
+
+// CreatePSK generates a private network key and writes it to path,
+// readable only by the owner
+func CreatePSK(path string) (pnet.PSK, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, ErrPSKExists
+	}
+
+	psk := make(pnet.PSK, pskSize)
+	if _, err := rand.Read(psk); err != nil {
+		return nil, err
+	}
+	if err := WritePSK(path, psk); err != nil {
+		return nil, err
+	}
+	return psk, nil
+}
+
+// WritePSK writes a private network key to path in the swarm.key format,
+// readable only by the owner
+func WritePSK(path string, psk pnet.PSK) error {
+	data := "/key/swarm/psk/1.0.0/\n/base16/\n" + hex.EncodeToString(psk) + "\n"
+	return os.WriteFile(path, []byte(data), 0600)
+}
@@ -0,0 +1,103 @@
+package p2p
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/ccoin/core/internal/supervisor"
+)
+
+// RelayProtocolID carries a transaction to one peer, which gossips it
+// as its own. A sender using it is not the first gossip source.
+const RelayProtocolID = "/ccoin/relay/2.0.0"
+
+// relayTimeout bounds handing a transaction to a relay peer
+const relayTimeout = 10 * time.Second
+
+// ErrNoRelayPeer is returned when no peer is eligible to relay
+var ErrNoRelayPeer = errors.New("no peer available to relay")
+
+// RelayTransaction hands an encoded transaction to a random connected
+// peer other than those excluded, and returns the peer chosen
+func (n *Node) RelayTransaction(ctx context.Context, data []byte, exclude ...peer.ID) (peer.ID, error) {
+	n.mu.RLock()
+	candidates := make([]peer.ID, 0, len(n.peers))
+	for id := range n.peers {
+		if !containsPeer(exclude, id) {
+			candidates = append(candidates, id)
+		}
+	}
+	n.mu.RUnlock()
+
+	if len(candidates) == 0 {
+		return "", ErrNoRelayPeer
+	}
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(len(candidates))))
+	if err != nil {
+		return "", err
+	}
+	id := candidates[i.Int64()]
+
+	ctx, cancel := context.WithTimeout(ctx, relayTimeout)
+	defer cancel()
+
+	s, err := n.OpenStream(ctx, id, RelayProtocolID)
+	if err != nil {
+		return "", err
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(relayTimeout))
+
+	msg := &Message{Type: MsgTypeTransaction, Payload: data}
+	if err := n.writeMessage(s, msg); err != nil {
+		s.Reset()
+		return "", err
+	}
+	return id, nil
+}
+
+// handleRelay accepts a relayed transaction, runs the transaction
+// handler on it and gossips it if accepted
+func (n *Node) handleRelay(s network.Stream) {
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(relayTimeout))
+
+	var msg Message
+	if err := n.readMessage(s, &msg); err != nil || msg.Type != MsgTypeTransaction {
+		s.Reset()
+		return
+	}
+
+	from := s.Conn().RemotePeer()
+	handler := n.txHandler
+	if handler != nil {
+		ctx, cancel := n.handlerContext(TransactionTopic)
+		start := time.Now()
+		relayed := &pubsub.Message{Message: &pb.Message{Data: msg.Payload}, ReceivedFrom: from}
+		err := supervisor.Protect("p2p.relay", func() error { return handler(ctx, relayed) })
+		cancel()
+		n.recordMessage(from, TransactionTopic, time.Since(start), err)
+		if err != nil {
+			return
+		}
+	}
+
+	n.BroadcastTransaction(msg.Payload)
+}
+
+func containsPeer(list []peer.ID, id peer.ID) bool {
+	for _, p := range list {
+		if p == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package p2p
+
+import (
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Roles is the set of services a node runs. Nodes advertise their roles in
+// sync status messages so peers can tell relays from miners and RPC nodes.
+type Roles uint32
+
+// Node roles. Every node relays blocks and transactions; a zero Roles
+// means a peer that did not report its roles.
+const (
+	RoleRelay Roles = 1 << iota
+	RoleMiner
+	RoleShielded
+	RoleIndexer
+	RoleRPC
+)
+
+// roleNames lists the name of each role in report order
+var roleNames = []struct {
+	role Roles
+	name string
+}{
+	{RoleRelay, "relay"},
+	{RoleMiner, "miner"},
+	{RoleShielded, "shielded"},
+	{RoleIndexer, "indexer"},
+	{RoleRPC, "rpc"},
+}
+
+// Has reports whether every role in role is set
+func (r Roles) Has(role Roles) bool {
+	return r&role == role
+}
+
+// Strings returns the names of the roles, or nil if none are known
+func (r Roles) Strings() []string {
+	var names []string
+	for _, rn := range roleNames {
+		if r.Has(rn.role) {
+			names = append(names, rn.name)
+		}
+	}
+	return names
+}
+
+// String returns the role names separated by commas
+func (r Roles) String() string {
+	if r == 0 {
+		return "unknown"
+	}
+	return strings.Join(r.Strings(), ",")
+}
+
+// SetRoles sets the roles the node advertises. Relaying is always
+// included.
+func (n *Node) SetRoles(roles Roles) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.roles = roles | RoleRelay
+}
+
+// Roles returns the roles the node advertises
+func (n *Node) Roles() Roles {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.roles
+}
+
+// SetPeerRoles records the roles reported by a peer
+func (n *Node) SetPeerRoles(id peer.ID, roles Roles) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if p, exists := n.peers[id]; exists {
+		p.Roles = roles
+	}
+}
@@ -0,0 +1,224 @@
+package p2p
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/ccoin/core/internal/logging"
+)
+
+// maxScoredPeers is the number of peer scores kept before those that
+// have decayed to nothing are dropped
+const maxScoredPeers = 4096
+
+// ScoringConfig tunes the node's own peer scoring. Misbehavior costs a
+// peer points, valid blocks earn some back, and scores decay towards
+// zero. A peer whose score falls to BanThreshold is banned for
+// BanDuration.
+type ScoringConfig struct {
+	// Penalties are the points a misbehavior costs by the topic or
+	// protocol it happened on; DefaultPenalty covers the rest
+	Penalties      map[string]float64
+	DefaultPenalty float64
+
+	// BlockReward is the points a valid relayed block earns, up to
+	// MaxScore
+	BlockReward float64
+	MaxScore    float64
+
+	// HalfLife is the time a score takes to decay halfway to zero
+	HalfLife time.Duration
+
+	// BanThreshold is the score at which a peer is banned, for
+	// BanDuration. Bans are kept by the node's BanManager, so they
+	// persist across restarts; without one peers are only scored.
+	BanThreshold float64
+	BanDuration  time.Duration
+
+	// GossipWeight scales the score into GossipSub's application-specific
+	// score, so gossip stops forwarding to and graylists the same peers
+	GossipWeight float64
+}
+
+// DefaultScoringConfig returns the default peer scoring. At GossipWeight
+// 10 a peer at the ban threshold is also at GossipSub's graylist
+// threshold.
+func DefaultScoringConfig() *ScoringConfig {
+	return &ScoringConfig{
+		Penalties: map[string]float64{
+			BlockTopic:           25,
+			TransactionTopic:     5,
+			TxAnnounceTopic:      5,
+			TxFetchProtocolID:    5,
+			TaskTopic:            2, // mostly rate limiting, one per message
+			EvaluationTopic:      5,
+			NullifierFilterTopic: 5,
+			SyncProtocolID:       10,
+			LightProtocolID:      10,
+		},
+		DefaultPenalty: 5,
+		BlockReward:    1,
+		MaxScore:       20,
+		HalfLife:       10 * time.Minute,
+		BanThreshold:   -100,
+		BanDuration:    24 * time.Hour,
+		GossipWeight:   10,
+	}
+}
+
+// PeerScorer keeps the node's score of each peer it has dealt with,
+// including peers since disconnected, so reconnecting does not clear a
+// peer's record
+type PeerScorer struct {
+	mu sync.Mutex
+
+	config *ScoringConfig
+	bans   *BanManager
+	log    *slog.Logger
+
+	scores map[peer.ID]*peerScore
+
+	// gossip holds GossipSub's last computed scores
+	gossip map[peer.ID]float64
+
+	now func() time.Time
+}
+
+// peerScore is a score as of a time
+type peerScore struct {
+	value   float64
+	updated time.Time
+}
+
+// NewPeerScorer creates a peer scorer banning through bans, which may be
+// nil. A nil config uses DefaultScoringConfig.
+func NewPeerScorer(cfg *ScoringConfig, bans *BanManager, log *slog.Logger) *PeerScorer {
+	if cfg == nil {
+		cfg = DefaultScoringConfig()
+	}
+	if log == nil {
+		log = logging.Module("p2p")
+	}
+	return &PeerScorer{
+		config: cfg,
+		bans:   bans,
+		log:    log,
+		scores: make(map[peer.ID]*peerScore),
+		gossip: make(map[peer.ID]float64),
+		now:    time.Now,
+	}
+}
+
+// decayed returns a score decayed to now
+func (s *PeerScorer) decayed(ps *peerScore, now time.Time) float64 {
+	if s.config.HalfLife <= 0 {
+		return ps.value
+	}
+	elapsed := now.Sub(ps.updated)
+	if elapsed <= 0 {
+		return ps.value
+	}
+	return ps.value * math.Exp2(-float64(elapsed)/float64(s.config.HalfLife))
+}
+
+// adjustLocked adds delta to a peer's decayed score, capped at MaxScore,
+// and returns the new score
+func (s *PeerScorer) adjustLocked(id peer.ID, delta float64) float64 {
+	now := s.now()
+	ps, exists := s.scores[id]
+	if !exists {
+		if len(s.scores) >= maxScoredPeers {
+			s.pruneLocked(now)
+		}
+		ps = &peerScore{}
+		s.scores[id] = ps
+	}
+	ps.value = s.decayed(ps, now) + delta
+	if s.config.MaxScore > 0 && ps.value > s.config.MaxScore {
+		ps.value = s.config.MaxScore
+	}
+	ps.updated = now
+	return ps.value
+}
+
+// pruneLocked drops scores that have decayed to nothing
+func (s *PeerScorer) pruneLocked(now time.Time) {
+	for id, ps := range s.scores {
+		if math.Abs(s.decayed(ps, now)) < 0.5 {
+			delete(s.scores, id)
+		}
+	}
+}
+
+// Penalize charges a peer for a misbehavior and bans it if its score
+// falls to the ban threshold. A banned peer's score is reset, so it
+// starts afresh when the ban lifts.
+func (s *PeerScorer) Penalize(id peer.ID, m Misbehavior) {
+	penalty, ok := s.config.Penalties[m.Topic]
+	if !ok {
+		penalty = s.config.DefaultPenalty
+	}
+
+	s.mu.Lock()
+	score := s.adjustLocked(id, -penalty)
+	banned := s.bans != nil && score <= s.config.BanThreshold
+	if banned {
+		delete(s.scores, id)
+	}
+	s.mu.Unlock()
+
+	if !banned {
+		return
+	}
+	ban := Ban{
+		Target: id.String(),
+		Reason: fmt.Sprintf("peer score %.0f, last: %s", score, m.Reason),
+	}
+	if s.config.BanDuration > 0 {
+		ban.Expires = s.now().Add(s.config.BanDuration).Unix()
+	}
+	if _, err := s.bans.Ban(ban); err != nil {
+		s.log.Warn("failed to save peer ban", "peer", id, "err", err)
+	}
+	s.log.Warn("banned peer for misbehavior", "peer", id, "score", score,
+		"duration", s.config.BanDuration, "last", m.Reason)
+}
+
+// Reward credits a peer for a valid block
+func (s *PeerScorer) Reward(id peer.ID) {
+	if s.config.BlockReward <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.adjustLocked(id, s.config.BlockReward)
+}
+
+// Score returns a peer's current score; peers never scored are at zero
+func (s *PeerScorer) Score(id peer.ID) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ps, exists := s.scores[id]; exists {
+		return s.decayed(ps, s.now())
+	}
+	return 0
+}
+
+// GossipScore returns GossipSub's last computed score of a peer
+func (s *PeerScorer) GossipScore(id peer.ID) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gossip[id]
+}
+
+// inspectGossip records GossipSub's scores
+func (s *PeerScorer) inspectGossip(scores map[peer.ID]float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gossip = scores
+}
@@ -0,0 +1,258 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/ccoin/core/internal/dag"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// SnapshotProtocolID serves state snapshots to fast-syncing nodes in
+// chunks, one chunk per stream
+const SnapshotProtocolID = "/ccoin/snapshot/2.0.0"
+
+// Snapshot transfer limits
+const (
+	// SnapshotChunkSize is the snapshot data carried per response
+	SnapshotChunkSize = 4 * 1024 * 1024
+
+	// MaxSnapshotSize caps the snapshot a node downloads
+	MaxSnapshotSize = 1 << 30
+)
+
+// ErrInvalidSnapshot is returned for a snapshot that does not match the
+// peer's headers
+var ErrInvalidSnapshot = errors.New("peer sent an invalid snapshot")
+
+// Snapshots stores the state snapshots a node serves and restores
+type Snapshots interface {
+	// LatestSnapshot returns the height, block hash and encoding of the
+	// latest snapshot
+	LatestSnapshot(ctx context.Context) (uint64, types.Hash, []byte, error)
+
+	// RestoreSnapshot checks an encoded snapshot against its block
+	// header and restores the chain state from it
+	RestoreSnapshot(ctx context.Context, header *types.BlockHeader, data []byte) error
+}
+
+// SetSnapshots serves snapshots over SnapshotProtocolID and, if fast
+// sync is enabled, starts a node with an empty DAG from a peer's snapshot
+func (sm *SyncManager) SetSnapshots(snapshots Snapshots) {
+	sm.mu.Lock()
+	sm.snapshots = snapshots
+	sm.mu.Unlock()
+	sm.node.RegisterProtocol(SnapshotProtocolID, sm.handleSnapshotStream)
+}
+
+// handleSnapshotStream serves one snapshot chunk per stream
+func (sm *SyncManager) handleSnapshotStream(s network.Stream) {
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(sm.requestTimeout))
+
+	ctx, cancel := context.WithTimeout(sm.node.ctx, sm.requestTimeout)
+	defer cancel()
+
+	var req Message
+	if err := sm.node.readMessage(s, &req); err != nil || req.Type != MsgTypeGetSnapshot {
+		s.Reset()
+		return
+	}
+	msg, err := DecodeGetSnapshot(req.Payload)
+	if err != nil {
+		s.Reset()
+		return
+	}
+
+	sm.mu.RLock()
+	snapshots := sm.snapshots
+	sm.mu.RUnlock()
+	height, hash, data, err := snapshots.LatestSnapshot(ctx)
+	// A snapshot replaced mid-transfer cannot be resumed
+	if err != nil || (!msg.Hash.IsEmpty() && msg.Hash != hash) || msg.Offset > uint64(len(data)) {
+		s.Reset()
+		return
+	}
+
+	end := msg.Offset + SnapshotChunkSize
+	if end > uint64(len(data)) {
+		end = uint64(len(data))
+	}
+	payload, err := EncodeSnapshotChunk(&SnapshotChunkMessage{
+		Height: height,
+		Hash:   hash,
+		Total:  uint64(len(data)),
+		Data:   data[msg.Offset:end],
+	})
+	if err != nil {
+		s.Reset()
+		return
+	}
+	resp := &Message{Type: MsgTypeSnapshot, Payload: payload}
+	if err := sm.node.writeMessage(s, resp); err != nil {
+		s.Reset()
+	}
+}
+
+// fetchSnapshot downloads a peer's latest snapshot chunk by chunk
+func (sm *SyncManager) fetchSnapshot(ctx context.Context, peerID peer.ID) (uint64, types.Hash, []byte, error) {
+	var (
+		height uint64
+		hash   types.Hash
+		data   []byte
+	)
+	for {
+		payload, err := EncodeGetSnapshot(&GetSnapshotMessage{Hash: hash, Offset: uint64(len(data))})
+		if err != nil {
+			return 0, types.Hash{}, nil, err
+		}
+		resp, err := sm.requestOn(ctx, peerID, SnapshotProtocolID, &Message{Type: MsgTypeGetSnapshot, Payload: payload}, MsgTypeSnapshot)
+		if err != nil {
+			return 0, types.Hash{}, nil, err
+		}
+		chunk, err := DecodeSnapshotChunk(resp.Payload)
+		if err != nil {
+			return 0, types.Hash{}, nil, err
+		}
+
+		if data == nil {
+			if chunk.Total > MaxSnapshotSize {
+				return 0, types.Hash{}, nil, ErrInvalidSnapshot
+			}
+			height, hash = chunk.Height, chunk.Hash
+			data = make([]byte, 0, chunk.Total)
+		}
+		if chunk.Hash != hash || chunk.Height != height || chunk.Total != uint64(cap(data)) {
+			return 0, types.Hash{}, nil, ErrUnexpectedResponse
+		}
+		if len(chunk.Data) == 0 || uint64(len(data)+len(chunk.Data)) > chunk.Total {
+			return 0, types.Hash{}, nil, ErrUnexpectedResponse
+		}
+		data = append(data, chunk.Data...)
+		if len(data) == cap(data) {
+			return height, hash, data, nil
+		}
+	}
+}
+
+// syncFromSnapshot restores the state from a peer's latest snapshot and
+// adds the blocks in the snapshot block's past to the DAG without
+// validating them; the snapshot's state root vouches for them. Other
+// blocks up to the snapshot height are validated as usual. It returns
+// the height to continue syncing from.
+func (sm *SyncManager) syncFromSnapshot(ctx context.Context, peerID peer.ID, snapshots Snapshots) (uint64, error) {
+	height, hash, data, err := sm.fetchSnapshot(ctx, peerID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Headers up to the snapshot height, in height order
+	var headers []*types.BlockHeader
+	byHash := make(map[types.Hash]*types.BlockHeader)
+	for from := uint64(0); from <= height; {
+		batch, err := sm.fetchHeaders(ctx, peerID, from, uint32(sm.batchSize))
+		if err != nil {
+			return 0, err
+		}
+		if len(batch) == 0 {
+			return 0, ErrInvalidSnapshot
+		}
+		for _, h := range batch {
+			if h.Height <= height {
+				headers = append(headers, h)
+				byHash[h.Hash] = h
+			}
+		}
+		from = batch[len(batch)-1].Height + 1
+	}
+
+	header, exists := byHash[hash]
+	if !exists {
+		return 0, ErrInvalidSnapshot
+	}
+	if err := snapshots.RestoreSnapshot(ctx, header, data); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+
+	// The snapshot block's past, down to genesis
+	past := map[types.Hash]bool{hash: true}
+	for stack := []*types.BlockHeader{header}; len(stack) > 0; {
+		h := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, parent := range h.Parents {
+			if past[parent] {
+				continue
+			}
+			p, exists := byHash[parent]
+			if !exists {
+				return 0, ErrInvalidSnapshot
+			}
+			past[parent] = true
+			stack = append(stack, p)
+		}
+	}
+
+	var missing []types.Hash
+	for _, h := range headers {
+		if !sm.dag.HasBlock(ctx, h.Hash) {
+			missing = append(missing, h.Hash)
+		}
+	}
+	for len(missing) > 0 {
+		n := len(missing)
+		if n > MaxBlocksPerRequest {
+			n = MaxBlocksPerRequest
+		}
+		blocks, err := sm.fetchBlocks(ctx, peerID, missing[:n])
+		if err != nil {
+			return 0, err
+		}
+
+		// Blocks in the snapshot block's past only need to match their
+		// headers and are written in one batch
+		var trusted []*types.Block
+		for _, block := range blocks {
+			if !past[block.Header.Hash] {
+				continue
+			}
+			if block.Header.ComputeHash() != block.Header.Hash || dag.ComputeTxRoot(block.Transactions) != block.Header.TxRoot {
+				return 0, &invalidBlockError{hash: block.Header.Hash, err: ErrInvalidBlock}
+			}
+			trusted = append(trusted, block)
+		}
+		if len(trusted) > 0 {
+			if err := sm.dag.AddBlocks(ctx, trusted); err != nil {
+				return 0, &invalidBlockError{hash: trusted[0].Header.Hash, err: err}
+			}
+		}
+		for _, block := range blocks {
+			if past[block.Header.Hash] {
+				continue
+			}
+			if err := sm.addSnapshotBlock(ctx, block); err != nil {
+				return 0, &invalidBlockError{hash: block.Header.Hash, err: err}
+			}
+		}
+		missing = missing[n:]
+	}
+
+	sm.mu.Lock()
+	sm.syncProgress = height
+	sm.mu.Unlock()
+	return height + 1, nil
+}
+
+// addSnapshotBlock adds a block fetched during fast sync outside the
+// snapshot block's past, which is validated in full
+func (sm *SyncManager) addSnapshotBlock(ctx context.Context, block *types.Block) error {
+	_, err := sm.orphans.ProcessBlock(ctx, block)
+	if err != nil && !errors.Is(err, dag.ErrDuplicateBlock) && !errors.Is(err, dag.ErrOrphanBlock) {
+		return err
+	}
+	return nil
+}
@@ -4,23 +4,49 @@ package p2p
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net"
 	"sync"
 	"time"
 
 	"github.com/ccoin/core/internal/dag"
 	"github.com/ccoin/core/pkg/types"
+	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
 )
 
 // Sync errors
 var (
-	ErrNoSyncPeers    = errors.New("no peers available for sync")
-	ErrSyncTimeout    = errors.New("sync timeout")
-	ErrInvalidBlock   = errors.New("received invalid block")
-	ErrOrphanReceived = errors.New("received orphan block")
+	ErrNoSyncPeers         = errors.New("no peers available for sync")
+	ErrSyncTimeout         = errors.New("sync timeout")
+	ErrInvalidBlock        = errors.New("received invalid block")
+	ErrOrphanReceived      = errors.New("received orphan block")
+	ErrUnexpectedResponse  = errors.New("unexpected sync response")
+	ErrUnrequestedBlock    = errors.New("peer sent a block that was not requested")
+	ErrHeadersOutOfRange   = errors.New("peer sent headers outside the requested range")
+	ErrSyncProtocolVersion = errors.New("unsupported sync protocol version")
 )
 
-// SyncManager handles blockchain synchronization
+// SyncProtocolVersion is carried in status messages on the sync protocol.
+// Version 3 tags the optional header fields in block hashes, so peers
+// still hashing them untagged are refused.
+const SyncProtocolVersion = 3
+
+// Sync request limits
+const (
+	// MaxHeadersPerRequest caps a headers response. Responses only end on
+	// a height boundary, so a single crowded height may exceed it.
+	MaxHeadersPerRequest = 2000
+
+	// MaxBlocksPerRequest caps the hashes in one GetBlocks request
+	MaxBlocksPerRequest = 128
+)
+
+// SyncManager handles blockchain synchronization. New nodes download
+// headers height by height from the best peer over SyncProtocolID, fetch
+// the blocks they are missing, and buffer orphans until their parents
+// arrive.
 type SyncManager struct {
 	mu sync.RWMutex
 
@@ -29,13 +55,28 @@ type SyncManager struct {
 	validator *dag.BlockValidator
 
 	// Sync state
-	syncing       bool
-	syncTarget    uint64
-	syncProgress  uint64
-	lastSyncPeer  peer.ID
+	syncing      bool
+	syncTarget   uint64
+	syncProgress uint64
+	lastSyncPeer peer.ID
+
+	// Blocks awaiting parents
+	orphans *dag.OrphanManager
+
+	// State snapshots served to peers; nil serves none
+	snapshots Snapshots
+	fastSync  bool
+
+	// Proofs and nullifier filters served to light clients; nil serves
+	// none
+	light   LightServer
+	filters Filters
+
+	// Height below which blocks are pruned; nil serves every block
+	pruned Pruned
 
-	// Pending blocks awaiting parents
-	pending map[types.Hash]*types.Block
+	// Main chain block at height zero, once known
+	genesis types.Hash
 
 	// Request tracking
 	pendingRequests map[types.Hash]time.Time
@@ -43,12 +84,32 @@ type SyncManager struct {
 
 	// Config
 	batchSize int
+	interval  time.Duration
+}
+
+// Pruned reports the height below which a node has pruned block bodies
+type Pruned interface {
+	PrunedHeight() uint64
 }
 
 // SyncConfig holds synchronization configuration
 type SyncConfig struct {
+	// BatchSize is the number of heights requested per headers request
 	BatchSize      int
 	RequestTimeout time.Duration
+
+	// MaxPending bounds the number of buffered orphan blocks
+	MaxPending int
+
+	// OrphanExpiry is how long an orphan waits for its parents
+	OrphanExpiry time.Duration
+
+	// Interval is how often Run checks for peers ahead of us
+	Interval time.Duration
+
+	// FastSync starts a node with an empty DAG from a peer's state
+	// snapshot, see SetSnapshots
+	FastSync bool
 }
 
 // DefaultSyncConfig returns default sync configuration
@@ -56,40 +117,139 @@ func DefaultSyncConfig() *SyncConfig {
 	return &SyncConfig{
 		BatchSize:      100,
 		RequestTimeout: 30 * time.Second,
+		MaxPending:     1000,
+		OrphanExpiry:   10 * time.Minute,
+		Interval:       15 * time.Second,
 	}
 }
 
-// NewSyncManager creates a new sync manager
+// NewSyncManager creates a new sync manager and registers the sync
+// protocol handler on the node
 func NewSyncManager(node *Node, d *dag.DAG, validator *dag.BlockValidator, cfg *SyncConfig) *SyncManager {
 	if cfg == nil {
 		cfg = DefaultSyncConfig()
 	}
 
-	return &SyncManager{
+	orphans := dag.NewOrphanManager(d, validator, &dag.OrphanConfig{
+		MaxOrphans: cfg.MaxPending,
+		Expiry:     cfg.OrphanExpiry,
+	})
+
+	sm := &SyncManager{
 		node:            node,
 		dag:             d,
 		validator:       validator,
-		pending:         make(map[types.Hash]*types.Block),
+		orphans:         orphans,
 		pendingRequests: make(map[types.Hash]time.Time),
 		requestTimeout:  cfg.RequestTimeout,
 		batchSize:       cfg.BatchSize,
+		interval:        cfg.Interval,
+		fastSync:        cfg.FastSync,
+	}
+
+	sm.orphans.SetParentRequester(sm.requestParents)
+	node.RegisterProtocol(SyncProtocolID, sm.handleStream)
+	node.SetStatusSource(sm.status)
+	return sm
+}
+
+// status reports the node's chain for the status handshake
+func (sm *SyncManager) status() *StatusMessage {
+	return &StatusMessage{
+		Height:       sm.dag.GetHeight(),
+		BestHash:     sm.dag.GetMainChainTip(),
+		GenesisHash:  sm.genesisHash(),
+		PrunedHeight: sm.prunedHeight(),
+	}
+}
+
+// genesisHash returns the hash of the main chain block at height zero,
+// or the empty hash while the node has none
+func (sm *SyncManager) genesisHash() types.Hash {
+	sm.mu.RLock()
+	genesis := sm.genesis
+	sm.mu.RUnlock()
+	if !genesis.IsEmpty() {
+		return genesis
+	}
+
+	headers, err := sm.dag.GetMainChain(sm.node.ctx, 0, 0)
+	if err != nil || len(headers) == 0 {
+		return types.Hash{}
+	}
+	sm.mu.Lock()
+	sm.genesis = headers[0].Hash
+	sm.mu.Unlock()
+	return headers[0].Hash
+}
+
+// SetPruned stops serving the blocks below the pruned height and
+// advertises it, so peers fetch those blocks elsewhere
+func (sm *SyncManager) SetPruned(pruned Pruned) {
+	sm.mu.Lock()
+	sm.pruned = pruned
+	sm.mu.Unlock()
+}
+
+// prunedHeight returns the height below which blocks are not served
+func (sm *SyncManager) prunedHeight() uint64 {
+	sm.mu.RLock()
+	pruned := sm.pruned
+	sm.mu.RUnlock()
+	if pruned == nil {
+		return 0
+	}
+	return pruned.PrunedHeight()
+}
+
+// Run keeps the node in sync, checking for peers ahead of us every
+// Interval until ctx is done
+func (sm *SyncManager) Run(ctx context.Context) error {
+	ticker := time.NewTicker(sm.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := sm.Start(ctx); err != nil && !errors.Is(err, ErrNoSyncPeers) {
+			sm.node.log.Warn("sync failed", "err", err)
+		}
+		sm.CleanupStale()
+
+		// Drop orphans that never connected and retry parents whose
+		// requests timed out
+		sm.orphans.Expire(time.Now())
+		sm.requestParents(ctx, sm.orphans.Missing(ctx))
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
 	}
 }
 
 // Start begins the sync process
 func (sm *SyncManager) Start(ctx context.Context) error {
-	// Find best peer to sync from
-	bestPeer, bestHeight := sm.findBestPeer()
+	if sm.IsSyncing() {
+		return nil
+	}
+
+	// Refresh peer heights, then find best peer to sync from
+	sm.refreshPeers(ctx)
+	localHeight := sm.dag.GetHeight()
+	bestPeer, bestHeight := sm.findBestPeer(localHeight)
 	if bestPeer == "" {
 		return ErrNoSyncPeers
 	}
 
-	localHeight := sm.dag.GetHeight()
 	if bestHeight <= localHeight {
 		return nil // Already synced
 	}
 
 	sm.mu.Lock()
+	if sm.syncing {
+		sm.mu.Unlock()
+		return nil
+	}
 	sm.syncing = true
 	sm.syncTarget = bestHeight
 	sm.syncProgress = localHeight
@@ -102,7 +262,8 @@ func (sm *SyncManager) Start(ctx context.Context) error {
 	return nil
 }
 
-// syncLoop performs the main synchronization
+// syncLoop performs the main synchronization. It starts at the local
+// height so sibling blocks at the current tip are picked up too.
 func (sm *SyncManager) syncLoop(ctx context.Context, peerID peer.ID, start, target uint64) {
 	defer func() {
 		sm.mu.Lock()
@@ -111,33 +272,124 @@ func (sm *SyncManager) syncLoop(ctx context.Context, peerID peer.ID, start, targ
 	}()
 
 	current := start
-	for current < target {
+	sm.mu.RLock()
+	snapshots := sm.snapshots
+	sm.mu.RUnlock()
+	if start == 0 && sm.fastSync && snapshots != nil {
+		next, err := sm.syncFromSnapshot(ctx, peerID, snapshots)
+		if err != nil {
+			// Fall back to syncing every block
+			sm.node.log.Warn("fast sync failed", "peer", peerID, "err", err)
+			sm.reportFailure(peerID, err)
+		} else {
+			current = next
+		}
+	}
+
+	for current <= target {
 		select {
 		case <-ctx.Done():
 			return
 		default:
 		}
 
-		// Request next batch
-		endHeight := current + uint64(sm.batchSize)
-		if endHeight > target {
-			endHeight = target
+		// Headers first, then the blocks we don't have yet
+		headers, err := sm.fetchHeaders(ctx, peerID, current, uint32(sm.batchSize))
+		if err != nil {
+			sm.node.log.Warn("sync failed", "peer", peerID, "height", current, "err", err)
+			sm.reportFailure(peerID, err)
+			return
+		}
+		if len(headers) == 0 {
+			return
 		}
 
-		// In production, this would send GetBlocks request and wait for response
-		// For now, we simulate the flow
-		time.Sleep(100 * time.Millisecond)
+		if err := sm.downloadBlocks(ctx, peerID, headers); err != nil {
+			sm.node.log.Warn("sync failed", "peer", peerID, "height", current, "err", err)
+			sm.reportFailure(peerID, err)
+			return
+		}
+
+		current = headers[len(headers)-1].Height + 1
 
 		sm.mu.Lock()
-		sm.syncProgress = endHeight
+		sm.syncProgress = current - 1
 		sm.mu.Unlock()
+	}
+}
+
+// downloadBlocks fetches the blocks behind headers that are not yet in
+// the DAG and hands them to the DAG in height order
+func (sm *SyncManager) downloadBlocks(ctx context.Context, peerID peer.ID, headers []*types.BlockHeader) error {
+	var missing []types.Hash
+	for _, h := range headers {
+		if !sm.dag.HasBlock(ctx, h.Hash) {
+			missing = append(missing, h.Hash)
+		}
+	}
+
+	for len(missing) > 0 {
+		n := len(missing)
+		if n > MaxBlocksPerRequest {
+			n = MaxBlocksPerRequest
+		}
+
+		blocks, err := sm.fetchBlocks(ctx, peerID, missing[:n])
+		if err != nil {
+			return err
+		}
+		for _, block := range blocks {
+			_, err := sm.orphans.ProcessBlock(ctx, block)
+			if err != nil && !errors.Is(err, dag.ErrDuplicateBlock) && !errors.Is(err, dag.ErrOrphanBlock) {
+				return &invalidBlockError{hash: block.Header.Hash, err: err}
+			}
+		}
+
+		missing = missing[n:]
+	}
+
+	return nil
+}
+
+// reportFailure logs a sync failure against the peer when the peer broke
+// the protocol rather than, say, timing out
+func (sm *SyncManager) reportFailure(peerID peer.ID, err error) {
+	var invalid *invalidBlockError
+	switch {
+	case errors.Is(err, ErrInvalidBlock),
+		errors.Is(err, ErrHeadersOutOfRange),
+		errors.Is(err, ErrUnrequestedBlock),
+		errors.Is(err, ErrUnexpectedResponse),
+		errors.Is(err, ErrInvalidSnapshot),
+		errors.As(err, &invalid):
+		sm.node.ReportMisbehavior(peerID, SyncProtocolID, err.Error())
+	}
+}
+
+// invalidBlockError is a downloaded block the DAG rejected
+type invalidBlockError struct {
+	hash types.Hash
+	err  error
+}
+
+func (e *invalidBlockError) Error() string {
+	return fmt.Sprintf("block %s: %v", e.hash, e.err)
+}
+
+func (e *invalidBlockError) Unwrap() error {
+	return e.err
+}
 
-		current = endHeight
+// refreshPeers asks every connected peer for its status
+func (sm *SyncManager) refreshPeers(ctx context.Context) {
+	for _, p := range sm.node.Peers() {
+		sm.fetchStatus(ctx, p.ID) // Unresponsive peers keep their last height
 	}
 }
 
-// findBestPeer finds the peer with the highest block height
-func (sm *SyncManager) findBestPeer() (peer.ID, uint64) {
+// findBestPeer finds the peer with the highest block height among those
+// still serving the blocks from height from
+func (sm *SyncManager) findBestPeer(from uint64) (peer.ID, uint64) {
 	peers := sm.node.Peers()
 	if len(peers) == 0 {
 		return "", 0
@@ -147,7 +399,7 @@ func (sm *SyncManager) findBestPeer() (peer.ID, uint64) {
 	var bestHeight uint64
 
 	for _, p := range peers {
-		if p.Height > bestHeight {
+		if p.Verified && p.Height > bestHeight && p.PrunedHeight <= from {
 			bestHeight = p.Height
 			bestPeer = p.ID
 		}
@@ -156,97 +408,338 @@ func (sm *SyncManager) findBestPeer() (peer.ID, uint64) {
 	return bestPeer, bestHeight
 }
 
-// HandleBlock processes an incoming block
+// HandleBlock processes an incoming block and relays it, along with any
+// orphans it connected, once it is in the DAG
 func (sm *SyncManager) HandleBlock(ctx context.Context, block *types.Block) error {
-	// Validate block
-	if err := sm.validator.ValidateBlock(ctx, block); err != nil {
-		return err
-	}
-
-	// Try to add to DAG
-	if err := sm.dag.AddBlock(ctx, block); err != nil {
+	added, err := sm.orphans.ProcessBlock(ctx, block)
+	if err != nil {
+		// Orphans are relayed once they connect, not before
 		if errors.Is(err, dag.ErrOrphanBlock) {
-			// Store in pending and request parents
-			sm.addPending(block)
-			sm.requestParents(ctx, block.Header.Parents)
 			return nil
 		}
 		return err
 	}
 
-	// Block added successfully, check if any pending blocks can now be added
-	sm.processPending(ctx)
-
 	// Broadcast to peers
-	data, err := EncodeBlock(block)
-	if err != nil {
-		return err
+	for _, b := range added {
+		data, err := sm.node.EncodeBlock(b)
+		if err != nil {
+			return err
+		}
+		if err := sm.node.BroadcastBlock(data); err != nil {
+			return err
+		}
 	}
-	return sm.node.BroadcastBlock(data)
+	return nil
 }
 
-// addPending adds a block to the pending queue
-func (sm *SyncManager) addPending(block *types.Block) {
+// requestParents requests missing parent blocks
+func (sm *SyncManager) requestParents(ctx context.Context, parents []types.Hash) {
 	sm.mu.Lock()
-	defer sm.mu.Unlock()
-	sm.pending[block.Header.Hash] = block
+
+	var missing []types.Hash
+	for _, parent := range parents {
+		// Check if already requested or buffered
+		if _, exists := sm.pendingRequests[parent]; exists {
+			continue
+		}
+		if sm.orphans.Has(parent) {
+			continue
+		}
+
+		// Check if already in DAG
+		if sm.dag.HasBlock(ctx, parent) {
+			continue
+		}
+
+		// Mark as requested
+		sm.pendingRequests[parent] = time.Now()
+		missing = append(missing, parent)
+	}
+
+	peerID := sm.lastSyncPeer
+	sm.mu.Unlock()
+
+	if len(missing) == 0 {
+		return
+	}
+	if peerID == "" {
+		peerID, _ = sm.findBestPeer(sm.dag.GetHeight())
+	}
+	if peerID == "" {
+		return
+	}
+
+	go sm.fetchParents(ctx, peerID, missing)
 }
 
-// processPending tries to add pending blocks to the DAG
-func (sm *SyncManager) processPending(ctx context.Context) {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
+// fetchParents downloads requested parents and hands them to the orphan
+// manager, which connects any orphans waiting on them
+func (sm *SyncManager) fetchParents(ctx context.Context, peerID peer.ID, hashes []types.Hash) {
+	blocks, err := sm.fetchBlocks(ctx, peerID, hashes)
+	if err != nil {
+		return
+	}
 
-	// Keep trying until no progress is made
-	for {
-		progress := false
-
-		for hash, block := range sm.pending {
-			// Check if all parents are now in DAG
-			allParentsExist := true
-			for _, parent := range block.Header.Parents {
-				if _, err := sm.dag.GetBlock(ctx, parent); err != nil {
-					allParentsExist = false
-					break
-				}
-			}
+	for _, block := range blocks {
+		sm.mu.Lock()
+		delete(sm.pendingRequests, block.Header.Hash)
+		sm.mu.Unlock()
+
+		sm.orphans.ProcessBlock(ctx, block)
+	}
+}
+
+// ============================================
+// Sync Protocol
+// ============================================
 
-			if allParentsExist {
-				if err := sm.dag.AddBlock(ctx, block); err == nil {
-					delete(sm.pending, hash)
-					progress = true
-				}
+// handleStream serves one sync request per stream
+func (sm *SyncManager) handleStream(s network.Stream) {
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(sm.requestTimeout))
+
+	ctx, cancel := context.WithTimeout(sm.node.ctx, sm.requestTimeout)
+	defer cancel()
+
+	var req Message
+	if err := sm.node.readMessage(s, &req); err != nil {
+		s.Reset()
+		return
+	}
+
+	resp, err := sm.serve(ctx, &req)
+	if err != nil {
+		s.Reset()
+		return
+	}
+	if err := sm.node.writeMessage(s, resp); err != nil {
+		s.Reset()
+	}
+	resp.release()
+}
+
+// serve builds the response to a sync request
+func (sm *SyncManager) serve(ctx context.Context, req *Message) (*Message, error) {
+	switch req.Type {
+	case MsgTypeStatus:
+		payload, err := EncodeStatus(sm.node.localStatus())
+		if err != nil {
+			return nil, err
+		}
+		return &Message{Type: MsgTypeStatus, Payload: payload}, nil
+
+	case MsgTypeGetHeaders:
+		msg, err := DecodeGetHeaders(req.Payload)
+		if err != nil {
+			return nil, err
+		}
+		headers, err := sm.collectHeaders(ctx, msg.FromHeight, msg.Count)
+		if err != nil {
+			return nil, err
+		}
+		payload, err := EncodeHeaders(headers)
+		if err != nil {
+			return nil, err
+		}
+		return &Message{Type: MsgTypeHeaders, Payload: payload}, nil
+
+	case MsgTypeGetBlocks:
+		msg, err := DecodeGetBlocks(req.Payload)
+		if err != nil {
+			return nil, err
+		}
+		hashes := msg.Hashes
+		if len(hashes) > MaxBlocksPerRequest {
+			hashes = hashes[:MaxBlocksPerRequest]
+		}
+
+		pruned := sm.prunedHeight()
+		items := make([][]byte, 0, len(hashes))
+		for _, hash := range hashes {
+			block, err := sm.dag.GetBlock(ctx, hash)
+			if err != nil || block.Header.Height < pruned {
+				continue // Unknown and pruned blocks are left out
 			}
+			data, err := sm.node.EncodeBlock(block)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, data)
 		}
+		buf := getBuffer()
+		*buf = appendList(*buf, items)
+		return &Message{Type: MsgTypeBlocks, Payload: *buf, pooled: buf}, nil
+
+	default:
+		return nil, ErrInvalidMessageType
+	}
+}
 
-		if !progress {
+// collectHeaders gathers the headers of all blocks at count heights from
+// fromHeight. Whole heights are returned so the caller can resume at the
+// height after the last header.
+func (sm *SyncManager) collectHeaders(ctx context.Context, fromHeight uint64, count uint32) ([]*types.BlockHeader, error) {
+	top := sm.dag.GetHeight()
+
+	var headers []*types.BlockHeader
+	for h := fromHeight; h <= top && h-fromHeight < uint64(count); h++ {
+		atHeight, err := sm.dag.GetHeadersAtHeight(ctx, h)
+		if err != nil {
+			return nil, err
+		}
+		if len(headers) > 0 && len(headers)+len(atHeight) > MaxHeadersPerRequest {
 			break
 		}
+		headers = append(headers, atHeight...)
 	}
+
+	return headers, nil
 }
 
-// requestParents requests missing parent blocks
-func (sm *SyncManager) requestParents(ctx context.Context, parents []types.Hash) {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
+// request sends one sync request to a peer and reads the response
+func (sm *SyncManager) request(ctx context.Context, peerID peer.ID, req *Message, want uint8) (*Message, error) {
+	return sm.requestOn(ctx, peerID, SyncProtocolID, req, want)
+}
 
-	for _, parent := range parents {
-		// Check if already requested
-		if _, exists := sm.pendingRequests[parent]; exists {
-			continue
+// requestOn sends one request to a peer over protoID and reads the
+// response
+func (sm *SyncManager) requestOn(ctx context.Context, peerID peer.ID, protoID protocol.ID, req *Message, want uint8) (*Message, error) {
+	return sendRequest(ctx, sm.node, sm.requestTimeout, peerID, protoID, req, want)
+}
+
+// sendRequest opens a stream to a peer over protoID, sends one request
+// and reads the response, all within timeout
+func sendRequest(ctx context.Context, node *Node, timeout time.Duration, peerID peer.ID, protoID protocol.ID, req *Message, want uint8) (*Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	s, err := node.OpenStream(ctx, peerID, protoID)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(timeout))
+
+	if err := node.writeMessage(s, req); err != nil {
+		s.Reset()
+		return nil, err
+	}
+
+	var resp Message
+	if err := node.readMessage(s, &resp); err != nil {
+		s.Reset()
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, ErrSyncTimeout
 		}
+		return nil, err
+	}
+	if resp.Type != want {
+		return nil, ErrUnexpectedResponse
+	}
 
-		// Check if already in DAG
-		if _, err := sm.dag.GetBlock(ctx, parent); err == nil {
-			continue
+	return &resp, nil
+}
+
+// fetchStatus asks a peer for its status and records its height
+func (sm *SyncManager) fetchStatus(ctx context.Context, peerID peer.ID) (*StatusMessage, error) {
+	return requestStatus(ctx, sm.node, sm.requestTimeout, peerID)
+}
+
+// requestStatus asks a peer for its status over SyncProtocolID, checks
+// it as the handshake does and records its height, roles and pruned
+// height
+func requestStatus(ctx context.Context, node *Node, timeout time.Duration, peerID peer.ID) (*StatusMessage, error) {
+	resp, err := sendRequest(ctx, node, timeout, peerID, SyncProtocolID, &Message{Type: MsgTypeStatus}, MsgTypeStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := DecodeStatus(resp.Payload)
+	if err != nil {
+		return nil, err
+	}
+	if err := node.checkStatus(status); err != nil {
+		return nil, err
+	}
+
+	node.recordStatus(peerID, status)
+	return status, nil
+}
+
+// fetchHeaders downloads headers for count heights from fromHeight and
+// checks that each header hashes to its claimed hash
+func (sm *SyncManager) fetchHeaders(ctx context.Context, peerID peer.ID, fromHeight uint64, count uint32) ([]*types.BlockHeader, error) {
+	return requestHeaders(ctx, sm.node, sm.requestTimeout, peerID, fromHeight, count)
+}
+
+// requestHeaders downloads headers over SyncProtocolID, see fetchHeaders
+func requestHeaders(ctx context.Context, node *Node, timeout time.Duration, peerID peer.ID, fromHeight uint64, count uint32) ([]*types.BlockHeader, error) {
+	payload, err := EncodeGetHeaders(&GetHeadersMessage{FromHeight: fromHeight, Count: count})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := sendRequest(ctx, node, timeout, peerID, SyncProtocolID, &Message{Type: MsgTypeGetHeaders, Payload: payload}, MsgTypeHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	headers, err := DecodeHeaders(resp.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	last := fromHeight
+	for _, h := range headers {
+		if h.Height < last || h.Height >= fromHeight+uint64(count) {
+			return nil, ErrHeadersOutOfRange
+		}
+		if h.ComputeHash() != h.Hash {
+			return nil, ErrInvalidBlock
 		}
+		last = h.Height
+	}
 
-		// Mark as requested
-		sm.pendingRequests[parent] = time.Now()
+	return headers, nil
+}
+
+// fetchBlocks downloads blocks by hash, rejecting any not requested
+func (sm *SyncManager) fetchBlocks(ctx context.Context, peerID peer.ID, hashes []types.Hash) ([]*types.Block, error) {
+	payload, err := EncodeGetBlocks(&GetBlocksMessage{Hashes: hashes})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := sm.request(ctx, peerID, &Message{Type: MsgTypeGetBlocks, Payload: payload}, MsgTypeBlocks)
+	if err != nil {
+		return nil, err
+	}
 
-		// In production, send GetBlock request to peers
-		// For now, just mark it
+	blocks, err := DecodeBlocks(resp.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	requested := make(map[types.Hash]bool, len(hashes))
+	for _, h := range hashes {
+		requested[h] = true
+	}
+	for _, b := range blocks {
+		if !requested[b.Header.Hash] {
+			return nil, ErrUnrequestedBlock
+		}
 	}
+
+	return blocks, nil
+}
+
+// SyncPeer returns the peer the node last synced from
+func (sm *SyncManager) SyncPeer() peer.ID {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.lastSyncPeer
 }
 
 // IsSyncing returns whether sync is in progress
@@ -263,11 +756,9 @@ func (sm *SyncManager) Progress() (current, target uint64) {
 	return sm.syncProgress, sm.syncTarget
 }
 
-// PendingCount returns the number of pending blocks
+// PendingCount returns the number of buffered orphan blocks
 func (sm *SyncManager) PendingCount() int {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
-	return len(sm.pending)
+	return sm.orphans.Len()
 }
 
 // CleanupStale removes stale pending requests
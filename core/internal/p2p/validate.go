@@ -0,0 +1,230 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/ccoin/core/internal/dag"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Gossip message size limits. Larger messages are rejected before they
+// are decoded.
+const (
+	MaxBlockMessageSize       = 4 * 1024 * 1024 // twice the miner's block size cap
+	MaxTransactionMessageSize = 1024 * 1024
+	MaxTaskMessageSize        = 64 * 1024
+)
+
+// Content hashes remembered per topic for duplicate suppression
+const (
+	blockSeenSize = 4096
+	txSeenSize    = 65536
+	taskSeenSize  = 16384
+)
+
+// Offsets of the content hash in encoded gossip messages, so content
+// already seen is dropped before it is decoded. Blocks and transactions
+// lead with their version, tasks with their ID.
+const (
+	blockIDOffset = 4
+	txIDOffset    = 4
+	taskIDOffset  = 0
+)
+
+// Gossip check errors
+var (
+	// errTaskWithoutID is returned for a gossiped task with no task ID
+	errTaskWithoutID = errors.New("task has no ID")
+
+	// errUncheckable is returned for a message the node cannot check yet,
+	// which is dropped without being charged to the sender
+	errUncheckable = errors.New("message cannot be checked yet")
+)
+
+// contentCheck decodes a gossip message and runs the checks on it that
+// need no chain state, returning the hash identifying its content and the
+// decoded value, which is handed to the topic handler
+type contentCheck func(data []byte) (types.Hash, any, error)
+
+// registerValidators registers the block, transaction, announcement, task
+// and nullifier filter topic validators. GossipSub only forwards messages their validator accepts,
+// so malformed messages stop at the first honest peer.
+func (n *Node) registerValidators(g *GossipConfig) error {
+	if g.TaskRateLimit > 0 {
+		n.taskLimiter = newRateLimiter(g.TaskRateLimit, g.TaskBurst)
+	}
+
+	if err := n.registerValidator(BlockTopic, MaxBlockMessageSize, blockIDOffset, newSeenCache(blockSeenSize), nil, n.checkBlockMessage); err != nil {
+		return err
+	}
+	if err := n.registerValidator(TransactionTopic, MaxTransactionMessageSize, txIDOffset, newSeenCache(txSeenSize), nil, checkTransactionMessage); err != nil {
+		return err
+	}
+	if err := n.pubsub.RegisterTopicValidator(TopicName(TxAnnounceTopic, n.networkID), n.validateAnnouncement, pubsub.WithValidatorTimeout(announceTimeout)); err != nil {
+		return err
+	}
+	if err := n.registerValidator(TaskTopic, MaxTaskMessageSize, taskIDOffset, newSeenCache(taskSeenSize), n.taskLimiter, checkTaskMessage); err != nil {
+		return err
+	}
+	return n.registerValidator(NullifierFilterTopic, MaxFilterMessageSize, filterIDOffset, newSeenCache(filterSeenSize), nil, n.checkFilterMessage)
+}
+
+// registerValidator registers a topic validator that rejects messages
+// from peers over the rate limit, oversized messages and messages failing
+// check, charging them to the sender, and ignores content already seen,
+// before decoding it when the hash at idOffset was seen. The node's own
+// messages are accepted unchecked.
+func (n *Node) registerValidator(topic string, maxSize, idOffset int, seen *seenCache, limiter *rateLimiter, check contentCheck) error {
+	return n.pubsub.RegisterTopicValidator(TopicName(topic, n.networkID), func(ctx context.Context, from peer.ID, msg *pubsub.Message) pubsub.ValidationResult {
+		if from == n.host.ID() {
+			return pubsub.ValidationAccept
+		}
+		if limiter != nil && !limiter.allow(from, time.Now()) {
+			n.ReportMisbehavior(from, topic, "rate limit exceeded")
+			return pubsub.ValidationReject
+		}
+		if len(msg.Data) > maxSize {
+			n.ReportMisbehavior(from, topic, fmt.Sprintf("%d byte message over the %d byte limit", len(msg.Data), maxSize))
+			return pubsub.ValidationReject
+		}
+		if id, ok := peekHash(msg.Data, idOffset); ok && seen.contains(id) {
+			return pubsub.ValidationIgnore
+		}
+
+		id, decoded, err := check(msg.Data)
+		if errors.Is(err, errUncheckable) {
+			return pubsub.ValidationIgnore
+		}
+		if err != nil {
+			n.ReportMisbehavior(from, topic, err.Error())
+			return pubsub.ValidationReject
+		}
+		if !seen.add(id) {
+			return pubsub.ValidationIgnore
+		}
+		msg.ValidatorData = decoded
+		return pubsub.ValidationAccept
+	})
+}
+
+// checkBlockMessage decodes a block's header and checks it before
+// decoding the transactions, then checks those against the header. A
+// block encoded exactly as EncodeBlock would is kept for EncodeBlock.
+func (n *Node) checkBlockMessage(data []byte) (types.Hash, any, error) {
+	r := &reader{data: data}
+	header := decodeHeader(r)
+	if r.err != nil {
+		return types.Hash{}, nil, fmt.Errorf("undecodable block: %w", r.err)
+	}
+	if err := dag.CheckHeader(header); err != nil {
+		return types.Hash{}, nil, fmt.Errorf("invalid block: %w", err)
+	}
+
+	block := &types.Block{Header: header}
+	if err := decodeBody(r, block); err != nil {
+		return types.Hash{}, nil, fmt.Errorf("undecodable block: %w", err)
+	}
+	if err := dag.CheckBody(block); err != nil {
+		return types.Hash{}, nil, fmt.Errorf("invalid block: %w", err)
+	}
+	if len(r.data) == 0 {
+		n.encodedBlocks.add(header.Hash, data)
+	}
+	return header.Hash, block, nil
+}
+
+// checkTransactionMessage decodes a transaction and checks its hash
+func checkTransactionMessage(data []byte) (types.Hash, any, error) {
+	tx, err := DecodeTransaction(data)
+	if err != nil {
+		return types.Hash{}, nil, fmt.Errorf("undecodable transaction: %w", err)
+	}
+	if err := dag.CheckTransaction(tx); err != nil {
+		return types.Hash{}, nil, fmt.Errorf("invalid transaction: %w", err)
+	}
+	return tx.TxHash, tx, nil
+}
+
+// checkTaskMessage decodes a task
+func checkTaskMessage(data []byte) (types.Hash, any, error) {
+	task, err := DecodeTask(data)
+	if err != nil {
+		return types.Hash{}, nil, fmt.Errorf("undecodable task: %w", err)
+	}
+	if task.TaskID.IsEmpty() {
+		return types.Hash{}, nil, errTaskWithoutID
+	}
+	return task.TaskID, task, nil
+}
+
+// BlockFromMessage returns the block in a block topic message, reusing
+// the one decoded when the message was validated
+func BlockFromMessage(msg *pubsub.Message) (*types.Block, error) {
+	if block, ok := msg.ValidatorData.(*types.Block); ok {
+		return block, nil
+	}
+	return DecodeBlock(msg.Data)
+}
+
+// TransactionFromMessage returns the transaction in a transaction topic
+// message, reusing the one decoded when the message was validated
+func TransactionFromMessage(msg *pubsub.Message) (*types.Transaction, error) {
+	if tx, ok := msg.ValidatorData.(*types.Transaction); ok {
+		return tx, nil
+	}
+	return DecodeTransaction(msg.Data)
+}
+
+// seenCache remembers the most recent content hashes seen on a topic.
+// GossipSub's own cache is keyed by sender and sequence number, so it
+// does not catch the same block or transaction published by several
+// peers.
+type seenCache struct {
+	mu    sync.Mutex
+	set   map[types.Hash]struct{}
+	order []types.Hash // ring of hashes, oldest at next once full
+	next  int
+}
+
+// newSeenCache creates a cache remembering up to size hashes
+func newSeenCache(size int) *seenCache {
+	return &seenCache{
+		set:   make(map[types.Hash]struct{}, size),
+		order: make([]types.Hash, 0, size),
+	}
+}
+
+// contains reports whether a hash was seen
+func (c *seenCache) contains(h types.Hash) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, exists := c.set[h]
+	return exists
+}
+
+// add records a hash, evicting the oldest when full, and reports whether
+// it is new
+func (c *seenCache) add(h types.Hash) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.set[h]; exists {
+		return false
+	}
+	if len(c.order) < cap(c.order) {
+		c.order = append(c.order, h)
+	} else {
+		delete(c.set, c.order[c.next])
+		c.order[c.next] = h
+		c.next = (c.next + 1) % len(c.order)
+	}
+	c.set[h] = struct{}{}
+	return true
+}
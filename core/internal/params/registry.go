@@ -0,0 +1,274 @@
+// Package params is the consensus parameter registry: values the Research
+// DAO tunes through parameter adjustment proposals instead of releases.
+// Each parameter has a default and bounds, and every change takes effect
+// at an activation height so all nodes switch at the same block.
+package params
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Registry errors
+var (
+	ErrUnknownParam       = errors.New("unknown consensus parameter")
+	ErrParamConflict      = errors.New("consensus parameter registered with a different definition")
+	ErrParamOutOfBounds   = errors.New("consensus parameter out of bounds")
+	ErrNoChanges          = errors.New("proposal changes no parameters")
+	ErrActivationTooEarly = errors.New("activation height must be after voting ends")
+	ErrNotParamProposal   = errors.New("not a consensus parameter proposal")
+	ErrProposalNotPassed  = errors.New("consensus parameter proposal has not passed")
+)
+
+// Definition describes a tunable parameter
+type Definition struct {
+	Name        string
+	Description string
+
+	Default float64
+	Min     float64
+	Max     float64
+
+	// Integer parameters only take whole values
+	Integer bool
+}
+
+// check validates a proposed value
+func (d *Definition) check(v float64) error {
+	if math.IsNaN(v) || v < d.Min || v > d.Max {
+		return fmt.Errorf("%w: %s must be in [%g, %g]", ErrParamOutOfBounds, d.Name, d.Min, d.Max)
+	}
+	if d.Integer && v != math.Trunc(v) {
+		return fmt.Errorf("%w: %s must be a whole number", ErrParamOutOfBounds, d.Name)
+	}
+	return nil
+}
+
+// Change sets a parameter from ActivationHeight on
+type Change struct {
+	Name             string
+	Value            float64
+	ActivationHeight uint64
+	ProposalID       types.Hash
+}
+
+// Store persists applied parameter changes
+type Store interface {
+	// LoadParamChanges returns every applied change
+	LoadParamChanges(ctx context.Context) ([]Change, error)
+
+	// SaveParamChanges records the changes made by one proposal
+	SaveParamChanges(ctx context.Context, changes []Change) error
+}
+
+// Registry holds parameter definitions and the schedule of changes to
+// them. Subsystems register their parameters at startup and read the value
+// in force at the height they are processing.
+type Registry struct {
+	mu sync.RWMutex
+
+	defs map[string]Definition
+
+	// Changes per parameter, in activation order
+	schedule map[string][]Change
+
+	// Proposals already applied
+	applied map[types.Hash]bool
+
+	store Store
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry(store Store) *Registry {
+	return &Registry{
+		defs:     make(map[string]Definition),
+		schedule: make(map[string][]Change),
+		applied:  make(map[types.Hash]bool),
+		store:    store,
+	}
+}
+
+// Register adds parameter definitions. Registering an identical
+// definition again is a no-op, so subsystems may register independently.
+func (r *Registry) Register(defs ...Definition) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, d := range defs {
+		if existing, ok := r.defs[d.Name]; ok {
+			if existing != d {
+				return fmt.Errorf("%w: %s", ErrParamConflict, d.Name)
+			}
+			continue
+		}
+		if d.Min > d.Max || d.check(d.Default) != nil {
+			return fmt.Errorf("%w: default of %s", ErrParamOutOfBounds, d.Name)
+		}
+		r.defs[d.Name] = d
+	}
+	return nil
+}
+
+// Initialize loads applied changes from the store. Parameters must be
+// registered first.
+func (r *Registry) Initialize(ctx context.Context) error {
+	changes, err := r.store.LoadParamChanges(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range changes {
+		if _, ok := r.defs[c.Name]; !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownParam, c.Name)
+		}
+		r.scheduleLocked(c)
+		r.applied[c.ProposalID] = true
+	}
+	return nil
+}
+
+// Definitions returns the registered parameters ordered by name
+func (r *Registry) Definitions() []Definition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	defs := make([]Definition, 0, len(r.defs))
+	for _, d := range r.defs {
+		defs = append(defs, d)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+// Value returns the value of a parameter in force at height. Unregistered
+// parameters read as zero.
+func (r *Registry) Value(name string, height uint64) float64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	changes := r.schedule[name]
+	i := sort.Search(len(changes), func(i int) bool { return changes[i].ActivationHeight > height })
+	if i > 0 {
+		return changes[i-1].Value
+	}
+	return r.defs[name].Default
+}
+
+// Schedule returns the applied changes to a parameter in activation order
+func (r *Registry) Schedule(name string) []Change {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Change(nil), r.schedule[name]...)
+}
+
+// Validate checks a proposed change against the parameters' bounds and
+// requires it to activate after votingEnd
+func (r *Registry) Validate(data *types.ConsensusParameterData, votingEnd uint64) error {
+	if len(data.Changes) == 0 {
+		return ErrNoChanges
+	}
+	if data.ActivationHeight <= votingEnd {
+		return ErrActivationTooEarly
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, c := range data.Changes {
+		d, ok := r.defs[c.Name]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownParam, c.Name)
+		}
+		if err := d.check(c.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyProposal schedules the changes of a passed consensus parameter
+// proposal. Each proposal applies once.
+func (r *Registry) ApplyProposal(ctx context.Context, proposal *types.Proposal) error {
+	if proposal.Type != types.ProposalParameterAdjust {
+		return ErrNotParamProposal
+	}
+	if proposal.Status != types.ProposalStatusPassed && proposal.Status != types.ProposalStatusExecuted {
+		return ErrProposalNotPassed
+	}
+	data, ok := proposal.Data.(*types.ConsensusParameterData)
+	if !ok {
+		return ErrNotParamProposal
+	}
+	if err := r.Validate(data, proposal.VotingEndBlock); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.applied[proposal.ProposalID] {
+		return nil
+	}
+
+	changes := make([]Change, len(data.Changes))
+	for i, c := range data.Changes {
+		changes[i] = Change{
+			Name:             c.Name,
+			Value:            c.Value,
+			ActivationHeight: data.ActivationHeight,
+			ProposalID:       proposal.ProposalID,
+		}
+	}
+	if err := r.store.SaveParamChanges(ctx, changes); err != nil {
+		return err
+	}
+	for _, c := range changes {
+		r.scheduleLocked(c)
+	}
+	r.applied[proposal.ProposalID] = true
+	return nil
+}
+
+// scheduleLocked inserts a change after those activating at or before it,
+// so the later of two changes at one height wins
+func (r *Registry) scheduleLocked(c Change) {
+	changes := r.schedule[c.Name]
+	i := sort.Search(len(changes), func(i int) bool { return changes[i].ActivationHeight > c.ActivationHeight })
+	changes = append(changes, Change{})
+	copy(changes[i+1:], changes[i:])
+	changes[i] = c
+	r.schedule[c.Name] = changes
+}
+
+// InMemoryStore is a simple in-memory Store for testing
+type InMemoryStore struct {
+	mu      sync.Mutex
+	changes []Change
+}
+
+// NewInMemoryStore creates an empty in-memory store
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{}
+}
+
+// LoadParamChanges returns every saved change
+func (s *InMemoryStore) LoadParamChanges(ctx context.Context) ([]Change, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Change(nil), s.changes...), nil
+}
+
+// SaveParamChanges records changes
+func (s *InMemoryStore) SaveParamChanges(ctx context.Context, changes []Change) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changes = append(s.changes, changes...)
+	return nil
+}
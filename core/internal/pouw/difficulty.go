@@ -2,13 +2,24 @@
 package pouw
 
 import (
+	"context"
+	"errors"
 	"math/big"
 	"sync"
 
 	"github.com/ccoin/core/pkg/types"
 )
 
-// DifficultyManager handles difficulty adjustment
+// ErrNoChain is returned when block times are loaded without a chain
+var ErrNoChain = errors.New("no chain to load block times from")
+
+// ChainSource provides main chain headers for difficulty adjustment
+type ChainSource interface {
+	GetMainChain(ctx context.Context, fromHeight, toHeight uint64) ([]*types.BlockHeader, error)
+}
+
+// DifficultyManager handles difficulty adjustment. The difficulty is the
+// target header hashes must fall below, so a higher value is easier.
 type DifficultyManager struct {
 	mu sync.RWMutex
 
@@ -27,6 +38,9 @@ type DifficultyManager struct {
 	// Bounds
 	minDifficulty *big.Int
 	maxDifficulty *big.Int
+
+	// Main chain block times are loaded from (optional)
+	chain ChainSource
 }
 
 // DifficultyConfig holds difficulty adjustment configuration
@@ -70,6 +84,44 @@ func NewDifficultyManager(cfg *DifficultyConfig) *DifficultyManager {
 	}
 }
 
+// SetChain sets the main chain LoadBlockTimes reads timestamps from
+func (dm *DifficultyManager) SetChain(chain ChainSource) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	dm.chain = chain
+}
+
+// LoadBlockTimes replaces the recorded block times with the timestamps of
+// the main chain blocks in the adjustment window ending at height
+func (dm *DifficultyManager) LoadBlockTimes(ctx context.Context, height uint64) error {
+	dm.mu.RLock()
+	chain := dm.chain
+	window := dm.adjustmentWindow
+	dm.mu.RUnlock()
+	if chain == nil {
+		return ErrNoChain
+	}
+
+	from := uint64(0)
+	if height >= window {
+		from = height - window + 1
+	}
+	headers, err := chain.GetMainChain(ctx, from, height)
+	if err != nil {
+		return err
+	}
+
+	times := make([]uint64, 0, window)
+	for _, h := range headers {
+		times = append(times, h.Timestamp)
+	}
+
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	dm.blockTimes = times
+	return nil
+}
+
 // RecordBlock records a new block timestamp
 func (dm *DifficultyManager) RecordBlock(timestamp uint64) {
 	dm.mu.Lock()
@@ -92,22 +144,31 @@ func (dm *DifficultyManager) AdjustDifficulty() *big.Int {
 		return dm.currentDifficulty
 	}
 
-	// Calculate average block time over the window
-	first := dm.blockTimes[0]
-	last := dm.blockTimes[len(dm.blockTimes)-1]
-	elapsed := last - first
-
-	if elapsed == 0 {
+	newDifficulty, ok := dm.retarget(dm.currentDifficulty, dm.blockTimes)
+	if !ok {
 		return dm.currentDifficulty
 	}
 
-	numBlocks := uint64(len(dm.blockTimes) - 1)
-	avgBlockTime := elapsed / numBlocks
+	dm.currentDifficulty = newDifficulty
+	return newDifficulty
+}
+
+// retarget scales difficulty by the ratio of the average block time over
+// times to the target. It reports false if the times do not advance.
+func (dm *DifficultyManager) retarget(difficulty *big.Int, times []uint64) (*big.Int, bool) {
+	first := times[0]
+	last := times[len(times)-1]
+	if last <= first {
+		return nil, false
+	}
+
+	// Calculate average block time over the window
+	avgBlockTime := float64(last-first) / float64(len(times)-1)
 
 	// Calculate adjustment ratio
-	// If blocks are too fast (low avg time), increase difficulty (make target smaller)
-	// If blocks are too slow (high avg time), decrease difficulty (make target larger)
-	ratio := float64(avgBlockTime) / float64(dm.targetBlockTime)
+	// If blocks are too fast (low avg time), make the target smaller
+	// If blocks are too slow (high avg time), make the target larger
+	ratio := avgBlockTime / float64(dm.targetBlockTime)
 
 	// Clamp adjustment to ±4x per window
 	if ratio < 0.25 {
@@ -118,11 +179,8 @@ func (dm *DifficultyManager) AdjustDifficulty() *big.Int {
 	}
 
 	// Apply adjustment
-	// Difficulty is inversely related to target: higher difficulty = lower target = harder to find
-	// If ratio > 1 (blocks too slow), we need LOWER difficulty (higher target)
-	// If ratio < 1 (blocks too fast), we need HIGHER difficulty (lower target)
-	adjustedDiff := new(big.Float).SetInt(dm.currentDifficulty)
-	adjustedDiff.Quo(adjustedDiff, big.NewFloat(ratio))
+	adjustedDiff := new(big.Float).SetInt(difficulty)
+	adjustedDiff.Mul(adjustedDiff, big.NewFloat(ratio))
 
 	newDifficulty, _ := adjustedDiff.Int(nil)
 
@@ -134,8 +192,7 @@ func (dm *DifficultyManager) AdjustDifficulty() *big.Int {
 		newDifficulty = new(big.Int).Set(dm.maxDifficulty)
 	}
 
-	dm.currentDifficulty = newDifficulty
-	return newDifficulty
+	return newDifficulty, true
 }
 
 // GetDifficulty returns the current difficulty
@@ -170,31 +227,13 @@ func (dm *DifficultyManager) CalculateNextDifficulty(parentTimestamps []uint64)
 		return dm.GetDifficulty()
 	}
 
-	elapsed := recentTimes[len(recentTimes)-1] - recentTimes[0]
-	numBlocks := uint64(len(recentTimes) - 1)
-	avgBlockTime := elapsed / numBlocks
-
-	ratio := float64(avgBlockTime) / float64(dm.targetBlockTime)
-	if ratio < 0.25 {
-		ratio = 0.25
-	}
-	if ratio > 4.0 {
-		ratio = 4.0
-	}
-
-	currentDiff := dm.GetDifficulty()
-	adjustedDiff := new(big.Float).SetInt(currentDiff)
-	adjustedDiff.Quo(adjustedDiff, big.NewFloat(ratio))
-
-	newDifficulty, _ := adjustedDiff.Int(nil)
+	dm.mu.RLock()
+	defer dm.mu.RUnlock()
 
-	if newDifficulty.Cmp(dm.minDifficulty) < 0 {
-		return new(big.Int).Set(dm.minDifficulty)
+	newDifficulty, ok := dm.retarget(dm.currentDifficulty, recentTimes)
+	if !ok {
+		return new(big.Int).Set(dm.currentDifficulty)
 	}
-	if newDifficulty.Cmp(dm.maxDifficulty) > 0 {
-		return new(big.Int).Set(dm.maxDifficulty)
-	}
-
 	return newDifficulty
 }
 
@@ -3,11 +3,17 @@ package pouw
 
 import (
 	"context"
-	"crypto/sha256"
 	"errors"
+	"log/slog"
 	"math/big"
 	"sync"
+	"time"
 
+	"github.com/ccoin/core/internal/keys"
+	"github.com/ccoin/core/internal/logging"
+	"github.com/ccoin/core/internal/supervisor"
+	"github.com/ccoin/core/internal/vrf"
+	"github.com/ccoin/core/internal/zkp"
 	"github.com/ccoin/core/pkg/types"
 )
 
@@ -19,8 +25,20 @@ var (
 	ErrImprovementFailed = errors.New("loss improvement check failed")
 	ErrQualityTooLow     = errors.New("quality score too low")
 	ErrDifficultyNotMet  = errors.New("difficulty target not met")
+	ErrNoCircuits        = errors.New("gradient circuit not configured")
 )
 
+// taskPollInterval is how long the mining loop waits when no task can be
+// drawn
+const taskPollInterval = time.Second
+
+// ResultHandler turns a PoUW result into a block. An error returns the
+// task to the queue.
+type ResultHandler func(ctx context.Context, task *types.Task, result *PoUWResult) error
+
+// TaskHandler is told each time the engine moves to another task
+type TaskHandler func(ctx context.Context, task *types.Task)
+
 // Engine implements the Proof-of-Useful-Work mining engine
 type Engine struct {
 	mu sync.RWMutex
@@ -34,10 +52,24 @@ type Engine struct {
 	// Current mining state
 	mining        bool
 	currentTask   *types.Task
-	minerAddress  types.Address
+	minerKey      keys.Signer // draws tasks by VRF
+
+	// Panic supervision (optional)
+	supervisor *supervisor.Supervisor
+
+	// Gradient circuit for proving and verifying work
+	circuits *zkp.CircuitManager
+
+	// Training computation
+	executor TrainingExecutor
 
-	// Verification parameters
-	verificationSubsetSize float64 // Percentage of batch to verify
+	// Receives each result; nil completes tasks without a block
+	onResult ResultHandler
+
+	// Told of each task drawn (optional)
+	onTask TaskHandler
+
+	log *slog.Logger
 }
 
 // ModelStore defines the interface for model weight storage
@@ -49,14 +81,13 @@ type ModelStore interface {
 
 // Config holds PoUW engine configuration
 type Config struct {
-	VerificationSubsetSize float64
+	// Logger receives the engine's logs; nil uses the pouw module logger
+	Logger *slog.Logger
 }
 
 // DefaultConfig returns default PoUW configuration
 func DefaultConfig() *Config {
-	return &Config{
-		VerificationSubsetSize: 0.01, // 1% of batch
-	}
+	return &Config{}
 }
 
 // NewEngine creates a new PoUW engine
@@ -64,27 +95,88 @@ func NewEngine(taskQueue *TaskQueue, modelStore ModelStore, cfg *Config) *Engine
 	if cfg == nil {
 		cfg = DefaultConfig()
 	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = logging.Module("pouw")
+	}
 
 	return &Engine{
-		taskQueue:              taskQueue,
-		modelStore:             modelStore,
-		verificationSubsetSize: cfg.VerificationSubsetSize,
+		taskQueue:  taskQueue,
+		modelStore: modelStore,
+		executor:   NewToyExecutor(),
+		log:        logger,
 	}
 }
 
-// StartMining begins the PoUW mining process
-func (e *Engine) StartMining(ctx context.Context, minerAddr types.Address) error {
+// StartMining begins the PoUW mining process. Tasks are drawn with VRF
+// proofs under the miner's identity key.
+func (e *Engine) StartMining(ctx context.Context, minerKey keys.Signer) error {
 	e.mu.Lock()
 	if e.mining {
 		e.mu.Unlock()
 		return nil
 	}
 	e.mining = true
-	e.minerAddress = minerAddr
+	e.minerKey = minerKey
+	sup := e.supervisor
 	e.mu.Unlock()
 
-	go e.miningLoop(ctx)
-	return nil
+	if sup == nil {
+		go e.miningLoop(ctx)
+		return nil
+	}
+
+	return sup.Go(ctx, "pouw.mining", func(ctx context.Context) error {
+		e.miningLoop(ctx)
+		return nil
+	})
+}
+
+// SetSupervisor enables panic supervision for the mining loop
+func (e *Engine) SetSupervisor(s *supervisor.Supervisor) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.supervisor = s
+}
+
+// SetCircuits sets the circuit manager gradient proofs are generated and
+// verified with; it must have the gradient circuit compiled
+func (e *Engine) SetCircuits(cm *zkp.CircuitManager) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.circuits = cm
+}
+
+// SetExecutor sets the executor training runs on, such as an external
+// worker from DialExecutor. A nil executor restores the built-in toy
+// executor.
+func (e *Engine) SetExecutor(x TrainingExecutor) {
+	if x == nil {
+		x = NewToyExecutor()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.executor = x
+}
+
+// SetResultHandler sets the handler each PoUW result is passed to
+func (e *Engine) SetResultHandler(fn ResultHandler) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onResult = fn
+}
+
+// SetTaskHandler sets the handler told of each task the engine draws
+func (e *Engine) SetTaskHandler(fn TaskHandler) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onTask = fn
+}
+
+// TaskQueue returns the queue tasks are drawn from
+func (e *Engine) TaskQueue() *TaskQueue {
+	return e.taskQueue
 }
 
 // StopMining stops the mining process
@@ -108,28 +200,53 @@ func (e *Engine) miningLoop(ctx context.Context) {
 			e.mu.RUnlock()
 			return
 		}
+		key := e.minerKey
 		e.mu.RUnlock()
 
-		// Get next task
-		task, err := e.taskQueue.GetNextTask(ctx, e.minerAddress)
+		// Draw the next task with a VRF proof over the current seed
+		seed := e.taskQueue.VRFSeed()
+		proof, err := key.ProveVRF(vrf.TaskInput(seed))
 		if err != nil {
+			e.log.Error("mining stopped: VRF proof failed", "err", err)
+			return
+		}
+		task, err := e.taskQueue.GetNextTask(ctx, key.Public(), seed, proof)
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(taskPollInterval):
+			}
 			continue
 		}
 
 		e.mu.Lock()
 		e.currentTask = task
+		onTask := e.onTask
 		e.mu.Unlock()
+		if onTask != nil {
+			onTask(ctx, task)
+		}
+		e.log.Debug("task drawn", "task", task.TaskID.String())
 
 		// Perform useful work
 		result, err := e.performWork(ctx, task)
+		if err == nil {
+			e.mu.RLock()
+			onResult := e.onResult
+			e.mu.RUnlock()
+			if onResult != nil {
+				err = onResult(ctx, task, result)
+			}
+		}
 		if err != nil {
+			e.log.Warn("task failed", "task", task.TaskID.String(), "err", err)
 			e.taskQueue.FailTask(ctx, task.TaskID, err.Error())
-			continue
+		} else {
+			e.log.Info("task completed", "task", task.TaskID.String(), "quality", result.QualityScore)
+			e.taskQueue.CompleteTask(ctx, task.TaskID, result.GradientResult(task.TaskID))
 		}
 
-		// Submit result
-		_ = result // Result would be used to create a new block
-
 		e.mu.Lock()
 		e.currentTask = nil
 		e.mu.Unlock()
@@ -142,117 +259,99 @@ type PoUWResult struct {
 	QualityScore   float64
 	LossBefore     float64
 	LossAfter      float64
-	Nonce          uint64
 	Proof          []byte
+
+	// Weights are the model weights after the step
+	Weights []byte
+
+	// VRFSeed selects the verification subset and Subset is the gradient
+	// over it, encoded by EncodeGradient, which verifiers re-execute
+	VRFSeed types.Hash
+	Subset  []byte
+}
+
+// GradientResult converts the result for the task queue and verifiers
+func (r *PoUWResult) GradientResult(taskID types.Hash) *types.GradientResult {
+	return &types.GradientResult{
+		TaskID:             taskID,
+		GradientHash:       r.GradientHash,
+		OldLoss:            r.LossBefore,
+		NewLoss:            r.LossAfter,
+		QualityScore:       r.QualityScore,
+		Proof:              r.Proof,
+		VerificationSubset: r.Subset,
+		VRFSeed:            r.VRFSeed,
+	}
 }
 
 // performWork performs the useful work computation
 func (e *Engine) performWork(ctx context.Context, task *types.Task) (*PoUWResult, error) {
-	// In production, this would:
-	// 1. Load model weights from IPFS
-	// 2. Load batch data
-	// 3. Compute gradients (actual AI training)
-	// 4. Calculate loss improvement
-	// 5. Generate zk-SNARK proof of correct computation
-
-	// Simulated computation
-	result := &PoUWResult{}
-
-	// Simulate gradient computation
-	gradientHash := e.simulateGradientComputation(task)
-	result.GradientHash = gradientHash
-
-	// Simulate loss calculation
-	result.LossBefore = 0.5 // Simulated
-	result.LossAfter = 0.45  // 10% improvement
-
-	// Calculate quality score
-	result.QualityScore = (result.LossBefore - result.LossAfter) / result.LossBefore
-	if result.QualityScore <= 0 {
-		return nil, ErrImprovementFailed
+	e.mu.RLock()
+	circuits, executor := e.circuits, e.executor
+	e.mu.RUnlock()
+	if circuits == nil {
+		return nil, ErrNoCircuits
 	}
 
-	// Find nonce that meets difficulty
-	nonce, err := e.findValidNonce(ctx, task, gradientHash)
+	// Train one step of the task's model
+	weights, err := e.modelStore.GetWeights(ctx, task.ModelID)
+	if err != nil {
+		return nil, err
+	}
+	work, updated, err := trainStep(ctx, executor, task, weights)
 	if err != nil {
 		return nil, err
 	}
-	result.Nonce = nonce
-
-	// Generate proof (simulated)
-	result.Proof = e.simulateProofGeneration(gradientHash, nonce)
-
-	return result, nil
-}
-
-// simulateGradientComputation simulates gradient computation
-func (e *Engine) simulateGradientComputation(task *types.Task) types.Hash {
-	// In production: actual gradient computation using PyTorch/TensorFlow
-	// For simulation, we hash the task parameters
-	data := append(task.TaskID[:], task.ModelID[:]...)
-	hash := sha256.Sum256(data)
-	
-	var result types.Hash
-	copy(result[:], hash[:])
-	return result
-}
-
-// findValidNonce searches for a nonce that meets difficulty target
-func (e *Engine) findValidNonce(ctx context.Context, task *types.Task, gradientHash types.Hash) (uint64, error) {
-	// H(Header || nonce || Hash(R)) < Difficulty
-	// For simulation, we just iterate until we find a valid nonce
-
-	difficulty := big.NewInt(1)
-	difficulty.Lsh(difficulty, 200) // Simulated difficulty
-
-	var nonce uint64
-	for nonce = 0; nonce < 1000000; nonce++ {
-		select {
-		case <-ctx.Done():
-			return 0, ctx.Err()
-		default:
-		}
-
-		hash := computePoUWHash(task.TaskID, nonce, gradientHash)
-		hashInt := new(big.Int).SetBytes(hash[:])
 
-		if hashInt.Cmp(difficulty) < 0 {
-			return nonce, nil
-		}
+	// Prove the losses and quality score follow from the step
+	proof, err := circuits.ProveGradient(ctx, work)
+	if err != nil {
+		return nil, err
 	}
 
-	return 0, ErrDifficultyNotMet
-}
+	result := &PoUWResult{
+		GradientHash: proof.Result,
+		QualityScore: zkp.QualityScore(proof.Quality),
+		LossBefore:   float64(proof.LossBefore),
+		LossAfter:    float64(proof.LossAfter),
+		Proof:        proof.Bytes(),
+		Weights:      EncodeWeights(updated),
+	}
+	if result.QualityScore <= 0 {
+		return nil, ErrQualityTooLow
+	}
 
-// computePoUWHash computes H(Header || nonce || Hash(R))
-func computePoUWHash(taskID types.Hash, nonce uint64, gradientHash types.Hash) types.Hash {
-	data := make([]byte, 0, 72)
-	data = append(data, taskID[:]...)
-	data = append(data, uint64ToBytes(nonce)...)
-	data = append(data, gradientHash[:]...)
-
-	hash := sha256.Sum256(data)
-	var result types.Hash
-	copy(result[:], hash[:])
-	return result
-}
+	// Compute the gradient over the verification subset, which is only
+	// known once the gradient is committed to
+	output, err := vrf.ProofToHash(task.VRFProof)
+	if err != nil {
+		return nil, err
+	}
+	result.VRFSeed = SubsetSeed(output, result.GradientHash)
+	batchSize, err := executor.BatchSize(ctx, task)
+	if err != nil {
+		return nil, err
+	}
+	if batchSize == 0 {
+		return nil, ErrInvalidSubset
+	}
+	samples := SelectVerificationSubset(batchSize, VerificationSubsetFraction, result.VRFSeed)
+	subset, err := executor.ComputeSampleGradients(ctx, task, samples)
+	if err != nil {
+		return nil, err
+	}
+	result.Subset = EncodeGradient(subset)
 
-// simulateProofGeneration simulates zk-SNARK proof generation
-func (e *Engine) simulateProofGeneration(gradientHash types.Hash, nonce uint64) []byte {
-	// In production: actual zk-SNARK proof using gnark
-	// For simulation, we just return a hash
-	data := append(gradientHash[:], uint64ToBytes(nonce)...)
-	hash := sha256.Sum256(data)
-	return hash[:]
+	return result, nil
 }
 
 // ValidatePoUW validates a PoUW result
 func (e *Engine) ValidatePoUW(ctx context.Context, block *types.Block) error {
 	header := block.Header
 
-	// Check difficulty target
-	hash := computePoUWHash(header.TaskID, header.Nonce, header.PoUWResult)
-	hashInt := new(big.Int).SetBytes(hash[:])
+	// Check difficulty target: the header hash commits to the nonce and
+	// the PoUW result
+	hashInt := new(big.Int).SetBytes(header.Hash[:])
 
 	if header.Difficulty == nil || hashInt.Cmp(header.Difficulty) >= 0 {
 		return ErrDifficultyNotMet
@@ -263,11 +362,18 @@ func (e *Engine) ValidatePoUW(ctx context.Context, block *types.Block) error {
 		return ErrQualityTooLow
 	}
 
-	// In production: verify the zk-SNARK proof
-	// This would validate:
-	// 1. Gradient was computed correctly
-	// 2. Loss improvement is genuine
-	// 3. Correct verification subset was used
+	e.mu.RLock()
+	circuits := e.circuits
+	e.mu.RUnlock()
+	if circuits == nil {
+		return ErrNoCircuits
+	}
+
+	// The gradient proof shows the loss improvement and quality score
+	// follow from the weights and sample committed to by PoUWResult
+	if err := circuits.VerifyPoUW(ctx, header); err != nil {
+		return ErrInvalidGradient
+	}
 
 	return nil
 }
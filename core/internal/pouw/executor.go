@@ -0,0 +1,150 @@
+package pouw
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Executor errors
+var (
+	ErrModelNotLoaded    = errors.New("model not loaded by executor")
+	ErrGradientsMismatch = errors.New("executor returned a gradient of the wrong size")
+	ErrInvalidTaskHash   = errors.New("invalid hash in executor request")
+	ErrSampleOutOfRange  = errors.New("sample index outside the task's batch")
+)
+
+// TrainingExecutor runs the training computation behind PoUW. The engine
+// loads the task's model, asks for its gradient and searches step sizes by
+// evaluating candidate weights; the step it keeps is then proven over the
+// task's verification sample. Weights and gradients are fixed-point
+// integers in the scale of the gradient circuit.
+type TrainingExecutor interface {
+	// LoadModel prepares a model with the given weights for training
+	LoadModel(ctx context.Context, modelID types.Hash, weights []int16) error
+
+	// ComputeGradients returns the loss gradient of the task's loaded
+	// model over its batch, one value per weight
+	ComputeGradients(ctx context.Context, task *types.Task) ([]int64, error)
+
+	// EvaluateLoss returns the loss of candidate weights for the task's
+	// model over its batch
+	EvaluateLoss(ctx context.Context, task *types.Task, weights []int16) (uint64, error)
+
+	// BatchSize returns the number of samples in the task's batch
+	BatchSize(ctx context.Context, task *types.Task) (uint32, error)
+
+	// ComputeSampleGradients returns the loss gradient of the task's loaded
+	// model summed over the given samples of its batch. Verifiers re-run
+	// it over a random subset to check a miner's claimed gradient.
+	ComputeSampleGradients(ctx context.Context, task *types.Task, samples []uint32) ([]int64, error)
+}
+
+// ToyExecutor is a built-in deterministic executor for tests and devnets.
+// It trains the linear model of the gradient circuit over the task's
+// verification sample, so its losses are exactly the proven ones.
+type ToyExecutor struct {
+	mu     sync.Mutex
+	models map[types.Hash][]int16
+}
+
+// NewToyExecutor creates a toy executor
+func NewToyExecutor() *ToyExecutor {
+	return &ToyExecutor{models: make(map[types.Hash][]int16)}
+}
+
+// LoadModel implements TrainingExecutor
+func (x *ToyExecutor) LoadModel(ctx context.Context, modelID types.Hash, weights []int16) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.models[modelID] = append([]int16(nil), weights...)
+	return nil
+}
+
+// ComputeGradients implements TrainingExecutor. The gradient is that of
+// the squared error, halved; weights past the circuit's features get none.
+func (x *ToyExecutor) ComputeGradients(ctx context.Context, task *types.Task) ([]int64, error) {
+	samples := make([]uint32, zkp.GradientSamples)
+	for i := range samples {
+		samples[i] = uint32(i)
+	}
+	return x.ComputeSampleGradients(ctx, task, samples)
+}
+
+// BatchSize implements TrainingExecutor. The toy batch is the task's
+// verification sample.
+func (x *ToyExecutor) BatchSize(ctx context.Context, task *types.Task) (uint32, error) {
+	return zkp.GradientSamples, nil
+}
+
+// ComputeSampleGradients implements TrainingExecutor
+func (x *ToyExecutor) ComputeSampleGradients(ctx context.Context, task *types.Task, samples []uint32) ([]int64, error) {
+	x.mu.Lock()
+	weights, ok := x.models[task.ModelID]
+	x.mu.Unlock()
+	if !ok {
+		return nil, ErrModelNotLoaded
+	}
+
+	lead := leadingWeights(weights)
+	features, labels := sampleBatch(task.DataHash)
+	gradient := make([]int64, len(weights))
+	for _, i := range samples {
+		if i >= zkp.GradientSamples {
+			return nil, ErrSampleOutOfRange
+		}
+		e := -int64(labels[i])
+		for j := range lead {
+			e += int64(lead[j]) * int64(features[i][j])
+		}
+		for j := range lead {
+			if j < len(gradient) {
+				gradient[j] += e * int64(features[i][j])
+			}
+		}
+	}
+	return gradient, nil
+}
+
+// EvaluateLoss implements TrainingExecutor
+func (x *ToyExecutor) EvaluateLoss(ctx context.Context, task *types.Task, weights []int16) (uint64, error) {
+	work := &zkp.GradientWork{}
+	work.Features, work.Labels = sampleBatch(task.DataHash)
+	return work.Loss(leadingWeights(weights)), nil
+}
+
+// DecodeWeights reads model weights stored as big-endian int16 values,
+// clamped to the circuit's range. Models shorter than the circuit's
+// features are padded with zeros.
+func DecodeWeights(data []byte) []int16 {
+	n := len(data) / 2
+	if n < zkp.GradientFeatures {
+		n = zkp.GradientFeatures
+	}
+
+	weights := make([]int16, n)
+	for j := 0; j < len(data)/2; j++ {
+		weights[j] = clampWeight(int64(int16(binary.BigEndian.Uint16(data[2*j:]))))
+	}
+	return weights
+}
+
+// EncodeWeights stores model weights as big-endian int16 values
+func EncodeWeights(weights []int16) []byte {
+	data := make([]byte, 2*len(weights))
+	for j, w := range weights {
+		binary.BigEndian.PutUint16(data[2*j:], uint16(w))
+	}
+	return data
+}
+
+// leadingWeights returns the weights covered by the gradient circuit
+func leadingWeights(weights []int16) [zkp.GradientFeatures]int16 {
+	var lead [zkp.GradientFeatures]int16
+	copy(lead[:], weights)
+	return lead
+}
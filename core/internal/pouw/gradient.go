@@ -0,0 +1,94 @@
+package pouw
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// maxStepShift bounds the line search over step sizes g >> shift
+const maxStepShift = 48
+
+// trainStep runs one gradient descent step through the executor. Step
+// sizes are searched from large to small, keeping the one with the lowest
+// executor loss among those that also lower the loss over the task's
+// verification sample, which is what the gradient circuit proves.
+func trainStep(ctx context.Context, exec TrainingExecutor, task *types.Task, data []byte) (*zkp.GradientWork, []int16, error) {
+	weights := DecodeWeights(data)
+	if err := exec.LoadModel(ctx, task.ModelID, weights); err != nil {
+		return nil, nil, err
+	}
+	gradient, err := exec.ComputeGradients(ctx, task)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(gradient) != len(weights) {
+		return nil, nil, ErrGradientsMismatch
+	}
+
+	work := &zkp.GradientWork{Task: task.TaskID, Weights: leadingWeights(weights)}
+	work.Features, work.Labels = sampleBatch(task.DataHash)
+	sampleLoss := work.Loss(work.Weights)
+
+	best, err := exec.EvaluateLoss(ctx, task, weights)
+	if err != nil {
+		return nil, nil, err
+	}
+	var updated []int16
+	for shift := 0; shift <= maxStepShift; shift++ {
+		candidate := make([]int16, len(weights))
+		for j := range candidate {
+			candidate[j] = clampWeight(int64(weights[j]) - gradient[j]>>shift)
+		}
+		lead := leadingWeights(candidate)
+		if work.Loss(lead) >= sampleLoss {
+			continue
+		}
+
+		loss, err := exec.EvaluateLoss(ctx, task, candidate)
+		if err != nil {
+			return nil, nil, err
+		}
+		if loss < best {
+			best, updated, work.Updated = loss, candidate, lead
+		}
+	}
+	if updated == nil {
+		return nil, nil, ErrImprovementFailed
+	}
+	return work, updated, nil
+}
+
+// sampleBatch derives the verification sample from the batch hash
+func sampleBatch(dataHash types.Hash) ([zkp.GradientSamples][zkp.GradientFeatures]int16, [zkp.GradientSamples]int16) {
+	var features [zkp.GradientSamples][zkp.GradientFeatures]int16
+	var labels [zkp.GradientSamples]int16
+
+	for i := range features {
+		seed := sha256.Sum256(append(dataHash[:], uint64ToBytes(uint64(i))...))
+		for j := range features[i] {
+			features[i][j] = sampleValue(seed[2*j:])
+		}
+		labels[i] = sampleValue(seed[2*zkp.GradientFeatures:])
+	}
+	return features, labels
+}
+
+// sampleValue maps two bytes to a value in the circuit's range
+func sampleValue(b []byte) int16 {
+	return int16(binary.BigEndian.Uint16(b)%(zkp.GradientValueMax-zkp.GradientValueMin+1)) + zkp.GradientValueMin
+}
+
+// clampWeight limits a weight to the circuit's range
+func clampWeight(v int64) int16 {
+	if v < zkp.GradientValueMin {
+		return zkp.GradientValueMin
+	}
+	if v > zkp.GradientValueMax {
+		return zkp.GradientValueMax
+	}
+	return int16(v)
+}
@@ -0,0 +1,265 @@
+package pouw
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/ccoin/core/pkg/common"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// ExecutorServiceName is the gRPC service an external training executor
+// serves. Messages are JSON encoded (content-subtype "json") like the node
+// API, so PyTorch or TensorFlow workers need no generated code.
+const ExecutorServiceName = "ccoin.pouw.TrainingExecutor"
+
+// ExecutorTask describes a task to an external executor. Hashes are hex.
+type ExecutorTask struct {
+	TaskID       string  `json:"task_id"`
+	ModelID      string  `json:"model_id"`
+	BatchIndex   uint64  `json:"batch_index"`
+	DataHash     string  `json:"data_hash"`
+	LearningRate float64 `json:"learning_rate"`
+}
+
+// LoadModelRequest loads a model's fixed-point weights
+type LoadModelRequest struct {
+	ModelID string  `json:"model_id"`
+	Weights []int16 `json:"weights"`
+}
+
+// LoadModelResponse confirms the model is loaded
+type LoadModelResponse struct{}
+
+// ComputeGradientsRequest asks for the gradient of a task's loaded model
+type ComputeGradientsRequest struct {
+	Task ExecutorTask `json:"task"`
+}
+
+// ComputeGradientsResponse returns one gradient value per weight
+type ComputeGradientsResponse struct {
+	Gradients []int64 `json:"gradients"`
+}
+
+// EvaluateLossRequest asks for the loss of candidate weights
+type EvaluateLossRequest struct {
+	Task    ExecutorTask `json:"task"`
+	Weights []int16      `json:"weights"`
+}
+
+// EvaluateLossResponse returns the loss in the circuit's fixed-point scale
+type EvaluateLossResponse struct {
+	Loss uint64 `json:"loss"`
+}
+
+// BatchSizeRequest asks for the number of samples in a task's batch
+type BatchSizeRequest struct {
+	Task ExecutorTask `json:"task"`
+}
+
+// BatchSizeResponse returns the batch size
+type BatchSizeResponse struct {
+	BatchSize uint32 `json:"batch_size"`
+}
+
+// ComputeSampleGradientsRequest asks for the gradient of a task's loaded
+// model over some samples of its batch
+type ComputeSampleGradientsRequest struct {
+	Task    ExecutorTask `json:"task"`
+	Samples []uint32     `json:"samples"`
+}
+
+// GRPCExecutor is a TrainingExecutor served by an external worker
+type GRPCExecutor struct {
+	conn *grpc.ClientConn
+}
+
+// DialExecutor connects to an external training executor
+func DialExecutor(ctx context.Context, addr string) (*GRPCExecutor, error) {
+	conn, err := grpc.DialContext(ctx, addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(executorCodec{})),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &GRPCExecutor{conn: conn}, nil
+}
+
+// Close closes the connection
+func (x *GRPCExecutor) Close() error {
+	return x.conn.Close()
+}
+
+// LoadModel implements TrainingExecutor
+func (x *GRPCExecutor) LoadModel(ctx context.Context, modelID types.Hash, weights []int16) error {
+	req := &LoadModelRequest{ModelID: modelID.String(), Weights: weights}
+	return x.invoke(ctx, "LoadModel", req, &LoadModelResponse{})
+}
+
+// ComputeGradients implements TrainingExecutor
+func (x *GRPCExecutor) ComputeGradients(ctx context.Context, task *types.Task) ([]int64, error) {
+	resp := &ComputeGradientsResponse{}
+	if err := x.invoke(ctx, "ComputeGradients", &ComputeGradientsRequest{Task: executorTask(task)}, resp); err != nil {
+		return nil, err
+	}
+	return resp.Gradients, nil
+}
+
+// EvaluateLoss implements TrainingExecutor
+func (x *GRPCExecutor) EvaluateLoss(ctx context.Context, task *types.Task, weights []int16) (uint64, error) {
+	resp := &EvaluateLossResponse{}
+	req := &EvaluateLossRequest{Task: executorTask(task), Weights: weights}
+	if err := x.invoke(ctx, "EvaluateLoss", req, resp); err != nil {
+		return 0, err
+	}
+	return resp.Loss, nil
+}
+
+// BatchSize implements TrainingExecutor
+func (x *GRPCExecutor) BatchSize(ctx context.Context, task *types.Task) (uint32, error) {
+	resp := &BatchSizeResponse{}
+	if err := x.invoke(ctx, "BatchSize", &BatchSizeRequest{Task: executorTask(task)}, resp); err != nil {
+		return 0, err
+	}
+	return resp.BatchSize, nil
+}
+
+// ComputeSampleGradients implements TrainingExecutor
+func (x *GRPCExecutor) ComputeSampleGradients(ctx context.Context, task *types.Task, samples []uint32) ([]int64, error) {
+	resp := &ComputeGradientsResponse{}
+	req := &ComputeSampleGradientsRequest{Task: executorTask(task), Samples: samples}
+	if err := x.invoke(ctx, "ComputeSampleGradients", req, resp); err != nil {
+		return nil, err
+	}
+	return resp.Gradients, nil
+}
+
+// invoke performs a unary call
+func (x *GRPCExecutor) invoke(ctx context.Context, method string, req, resp interface{}) error {
+	return x.conn.Invoke(ctx, "/"+ExecutorServiceName+"/"+method, req, resp)
+}
+
+// executorTask converts a task for the wire
+func executorTask(task *types.Task) ExecutorTask {
+	return ExecutorTask{
+		TaskID:       task.TaskID.String(),
+		ModelID:      task.ModelID.String(),
+		BatchIndex:   task.BatchIndex,
+		DataHash:     task.DataHash.String(),
+		LearningRate: task.LearningRate,
+	}
+}
+
+// parseExecutorTask converts a task from the wire
+func parseExecutorTask(t *ExecutorTask) (*types.Task, error) {
+	task := &types.Task{BatchIndex: t.BatchIndex, LearningRate: t.LearningRate}
+	for _, f := range []struct {
+		hex string
+		dst *types.Hash
+	}{{t.TaskID, &task.TaskID}, {t.ModelID, &task.ModelID}, {t.DataHash, &task.DataHash}} {
+		h, err := parseHash(f.hex)
+		if err != nil {
+			return nil, err
+		}
+		*f.dst = h
+	}
+	return task, nil
+}
+
+// parseHash decodes a hex hash
+func parseHash(s string) (types.Hash, error) {
+	b, err := common.HexToBytes(s)
+	if err != nil || len(b) != types.HashSize {
+		return types.Hash{}, ErrInvalidTaskHash
+	}
+	return types.HashFromBytes(b), nil
+}
+
+// NewExecutorServer returns a gRPC server serving x as a training
+// executor, for Go workers and tests
+func NewExecutorServer(x TrainingExecutor) *grpc.Server {
+	s := grpc.NewServer(grpc.ForceServerCodec(executorCodec{}))
+	s.RegisterService(&executorServiceDesc, x)
+	return s
+}
+
+var executorServiceDesc = grpc.ServiceDesc{
+	ServiceName: ExecutorServiceName,
+	HandlerType: (*TrainingExecutor)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "LoadModel", Handler: executorHandler(func(x TrainingExecutor, ctx context.Context, req *LoadModelRequest) (*LoadModelResponse, error) {
+			modelID, err := parseHash(req.ModelID)
+			if err != nil {
+				return nil, err
+			}
+			return &LoadModelResponse{}, x.LoadModel(ctx, modelID, req.Weights)
+		})},
+		{MethodName: "ComputeGradients", Handler: executorHandler(func(x TrainingExecutor, ctx context.Context, req *ComputeGradientsRequest) (*ComputeGradientsResponse, error) {
+			task, err := parseExecutorTask(&req.Task)
+			if err != nil {
+				return nil, err
+			}
+			gradients, err := x.ComputeGradients(ctx, task)
+			return &ComputeGradientsResponse{Gradients: gradients}, err
+		})},
+		{MethodName: "EvaluateLoss", Handler: executorHandler(func(x TrainingExecutor, ctx context.Context, req *EvaluateLossRequest) (*EvaluateLossResponse, error) {
+			task, err := parseExecutorTask(&req.Task)
+			if err != nil {
+				return nil, err
+			}
+			loss, err := x.EvaluateLoss(ctx, task, req.Weights)
+			return &EvaluateLossResponse{Loss: loss}, err
+		})},
+		{MethodName: "BatchSize", Handler: executorHandler(func(x TrainingExecutor, ctx context.Context, req *BatchSizeRequest) (*BatchSizeResponse, error) {
+			task, err := parseExecutorTask(&req.Task)
+			if err != nil {
+				return nil, err
+			}
+			size, err := x.BatchSize(ctx, task)
+			return &BatchSizeResponse{BatchSize: size}, err
+		})},
+		{MethodName: "ComputeSampleGradients", Handler: executorHandler(func(x TrainingExecutor, ctx context.Context, req *ComputeSampleGradientsRequest) (*ComputeGradientsResponse, error) {
+			task, err := parseExecutorTask(&req.Task)
+			if err != nil {
+				return nil, err
+			}
+			gradients, err := x.ComputeSampleGradients(ctx, task, req.Samples)
+			return &ComputeGradientsResponse{Gradients: gradients}, err
+		})},
+	},
+}
+
+// executorHandler adapts a typed executor call to a gRPC method handler
+func executorHandler[Req any, Resp any](
+	call func(TrainingExecutor, context.Context, *Req) (*Resp, error),
+) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		req := new(Req)
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		return call(srv.(TrainingExecutor), ctx, req)
+	}
+}
+
+// executorCodec encodes executor messages as JSON
+type executorCodec struct{}
+
+// Marshal implements encoding.Codec
+func (executorCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal implements encoding.Codec
+func (executorCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// Name implements encoding.Codec
+func (executorCodec) Name() string {
+	return "json"
+}
@@ -3,11 +3,13 @@ package pouw
 
 import (
 	"context"
+	"crypto/ed25519"
 	"crypto/sha256"
 	"errors"
 	"sync"
 	"time"
 
+	"github.com/ccoin/core/internal/vrf"
 	"github.com/ccoin/core/pkg/types"
 )
 
@@ -17,6 +19,8 @@ var (
 	ErrTaskNotFound     = errors.New("task not found")
 	ErrTaskAlreadyAssigned = errors.New("task already assigned")
 	ErrMinerNotEligible = errors.New("miner not eligible for task")
+	ErrStaleVRFSeed     = errors.New("VRF seed is not the current one")
+	ErrInvalidVRFProof  = errors.New("invalid VRF proof")
 )
 
 // TaskQueue manages the on-chain task assignment queue
@@ -34,7 +38,7 @@ type TaskQueue struct {
 	active    map[types.Hash]*types.Task
 	completed map[types.Hash]*types.Task
 
-	// VRF seed for deterministic task assignment
+	// VRF seed for task assignment: the latest block hash
 	vrfSeed types.Hash
 
 	// Task parameters
@@ -86,26 +90,33 @@ func (q *TaskQueue) AddTask(ctx context.Context, task *types.Task) error {
 	return nil
 }
 
-// GetNextTask assigns and returns the next available task for a miner
-func (q *TaskQueue) GetNextTask(ctx context.Context, minerAddr types.Address) (*types.Task, error) {
+// GetNextTask assigns the next task to the miner with key pub. The task is
+// drawn by the output of the miner's VRF proof over the current seed, so
+// neither the miner nor anyone else can steer which task it gets.
+func (q *TaskQueue) GetNextTask(ctx context.Context, pub ed25519.PublicKey, seed types.Hash, proof []byte) (*types.Task, error) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
 	if len(q.available) == 0 {
 		return nil, ErrNoAvailableTasks
 	}
-
-	// Use VRF-like selection based on miner address
-	taskIndex := q.selectTaskForMiner(minerAddr)
-	if taskIndex >= len(q.available) {
-		taskIndex = 0
+	if seed != q.vrfSeed {
+		return nil, ErrStaleVRFSeed
+	}
+	output, err := vrf.Verify(pub, proof, vrf.TaskInput(seed))
+	if err != nil {
+		return nil, ErrInvalidVRFProof
 	}
 
+	taskIndex := vrf.Index(output, len(q.available))
 	task := q.available[taskIndex]
 
 	// Assign task
 	task.Status = types.TaskStatusAssigned
-	task.AssignedMiner = minerAddr
+	task.AssignedMiner = types.AddressFromPublicKey(pub)
+	task.MinerPublicKey = append([]byte(nil), pub...)
+	task.VRFSeed = seed
+	task.VRFProof = append([]byte(nil), proof...)
 	task.AssignedAt = uint64(time.Now().Unix())
 	task.Deadline = task.AssignedAt + uint64(q.taskTimeout.Seconds())
 
@@ -117,17 +128,6 @@ func (q *TaskQueue) GetNextTask(ctx context.Context, minerAddr types.Address) (*
 	return task, nil
 }
 
-// selectTaskForMiner uses deterministic selection based on miner address
-func (q *TaskQueue) selectTaskForMiner(minerAddr types.Address) int {
-	// VRF-like selection: H(seed || miner_address) mod len(available)
-	data := append(q.vrfSeed[:], minerAddr[:]...)
-	hash := sha256.Sum256(data)
-
-	// Use first 8 bytes as index
-	index := uint64(hash[0]) | uint64(hash[1])<<8 | uint64(hash[2])<<16 | uint64(hash[3])<<24
-	return int(index % uint64(len(q.available)))
-}
-
 // CompleteTask marks a task as completed
 func (q *TaskQueue) CompleteTask(ctx context.Context, taskID types.Hash, result *types.GradientResult) error {
 	q.mu.Lock()
@@ -243,15 +243,19 @@ func (q *TaskQueue) GetStats() *QueueStats {
 	}
 }
 
-// UpdateVRFSeed updates the VRF seed (called at each block)
+// UpdateVRFSeed sets the VRF seed to a new block's hash (called at each
+// block). Headers commit to the seed, so validators can check it.
 func (q *TaskQueue) UpdateVRFSeed(blockHash types.Hash) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
+	q.vrfSeed = blockHash
+}
 
-	// Chain VRF seed with block hash
-	data := append(q.vrfSeed[:], blockHash[:]...)
-	hash := sha256.Sum256(data)
-	copy(q.vrfSeed[:], hash[:])
+// VRFSeed returns the seed miners prove over to get a task
+func (q *TaskQueue) VRFSeed() types.Hash {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.vrfSeed
 }
 
 // CreateTask creates a new task for a model
@@ -3,28 +3,60 @@ package pouw
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
 	"errors"
+	"math"
+	"sync"
+	"time"
 
+	"github.com/ccoin/core/internal/vrf"
 	"github.com/ccoin/core/pkg/types"
 )
 
 // Verification errors
 var (
-	ErrVerificationFailed = errors.New("gradient verification failed")
-	ErrInvalidSubset      = errors.New("verification subset is invalid")
-	ErrProofInvalid       = errors.New("gradient proof is invalid")
+	ErrVerificationFailed  = errors.New("gradient verification failed")
+	ErrInvalidSubset       = errors.New("verification subset is invalid")
+	ErrProofInvalid        = errors.New("gradient proof is invalid")
+	ErrNoTaskSource        = errors.New("verifier has no task source")
+	ErrNoExecutor          = errors.New("verifier has no executor")
+	ErrVerificationTimeout = errors.New("subset re-execution timed out")
 )
 
+// VerificationSubsetFraction is the fraction of a task's batch whose
+// gradient a miner commits to and verifiers re-execute. It is a consensus
+// parameter: a verifier selecting a different subset rejects honest
+// results.
+const VerificationSubsetFraction = 0.01
+
 // Verifier handles gradient result verification
 type Verifier struct {
-	// subsetSize is the percentage of batch to verify
-	subsetSize float64
+	mu sync.RWMutex
+
+	// timeout bounds subset re-execution
+	timeout time.Duration
+
+	// tolerance is the allowed gradient deviation, relative to the
+	// largest recomputed component
+	tolerance float64
 
 	// modelStore provides access to model weights
 	modelStore ModelStore
 
 	// proofVerifier verifies zk-SNARK proofs
 	proofVerifier GradientProofVerifier
+
+	// executor re-runs the verification subset
+	executor TrainingExecutor
+
+	// tasks looks up the task a result is for
+	tasks TaskSource
+}
+
+// TaskSource looks up tasks by ID; TaskQueue implements it
+type TaskSource interface {
+	GetTask(taskID types.Hash) (*types.Task, error)
 }
 
 // GradientProofVerifier verifies zk-SNARK proofs of gradient computation
@@ -56,29 +88,53 @@ type GradientPublicInputs struct {
 
 // VerifierConfig holds verifier configuration
 type VerifierConfig struct {
-	SubsetSize float64
+	// Timeout bounds subset re-execution
+	Timeout time.Duration
+
+	// GradientTolerance is the allowed deviation of each claimed gradient
+	// component, relative to the largest recomputed one
+	GradientTolerance float64
 }
 
 // DefaultVerifierConfig returns default configuration
 func DefaultVerifierConfig() *VerifierConfig {
 	return &VerifierConfig{
-		SubsetSize: 0.01, // 1%
+		Timeout:           30 * time.Second,
+		GradientTolerance: 0.001,
 	}
 }
 
-// NewVerifier creates a new gradient verifier
-func NewVerifier(modelStore ModelStore, proofVerifier GradientProofVerifier, cfg *VerifierConfig) *Verifier {
+// NewVerifier creates a new gradient verifier re-executing subsets on
+// executor, which must run the same model as miners: the toy executor
+// only checks results computed by the toy executor
+func NewVerifier(modelStore ModelStore, proofVerifier GradientProofVerifier, executor TrainingExecutor, cfg *VerifierConfig) *Verifier {
 	if cfg == nil {
 		cfg = DefaultVerifierConfig()
 	}
 
 	return &Verifier{
-		subsetSize:    cfg.SubsetSize,
+		timeout:       cfg.Timeout,
+		tolerance:     cfg.GradientTolerance,
 		modelStore:    modelStore,
 		proofVerifier: proofVerifier,
+		executor:      executor,
 	}
 }
 
+// SetExecutor replaces the executor subsets are re-executed on
+func (v *Verifier) SetExecutor(x TrainingExecutor) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.executor = x
+}
+
+// SetTaskSource sets where the tasks of verified results are looked up
+func (v *Verifier) SetTaskSource(ts TaskSource) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.tasks = ts
+}
+
 // VerifyGradientResult verifies a gradient computation result
 func (v *Verifier) VerifyGradientResult(ctx context.Context, result *types.GradientResult) error {
 	// 1. Verify quality score is valid
@@ -88,13 +144,13 @@ func (v *Verifier) VerifyGradientResult(ctx context.Context, result *types.Gradi
 
 	// 2. Verify loss improvement (the Improvement Gate)
 	//    Loss(W_t + α·∇L) < Loss(W_t)
-	if result.LossAfter >= result.LossBefore {
+	if result.NewLoss >= result.OldLoss {
 		return ErrVerificationFailed
 	}
 
 	// 3. Verify quality score calculation
 	//    Q(B) = (Loss_before - Loss_after) / Loss_before
-	expectedQuality := (result.LossBefore - result.LossAfter) / result.LossBefore
+	expectedQuality := (result.OldLoss - result.NewLoss) / result.OldLoss
 	if !floatNearEqual(result.QualityScore, expectedQuality, 0.001) {
 		return errors.New("quality score mismatch")
 	}
@@ -103,8 +159,8 @@ func (v *Verifier) VerifyGradientResult(ctx context.Context, result *types.Gradi
 	if v.proofVerifier != nil {
 		publicInputs := &GradientPublicInputs{
 			GradientHash: result.GradientHash,
-			LossBefore:   result.LossBefore,
-			LossAfter:    result.LossAfter,
+			LossBefore:   result.OldLoss,
+			LossAfter:    result.NewLoss,
 			QualityScore: result.QualityScore,
 		}
 
@@ -113,8 +169,7 @@ func (v *Verifier) VerifyGradientResult(ctx context.Context, result *types.Gradi
 		}
 	}
 
-	// 5. Re-compute gradient on verification subset
-	//    In production, this would actually run inference on the subset
+	// 5. Re-compute the gradient on the verification subset
 	if err := v.verifySubset(ctx, result); err != nil {
 		return err
 	}
@@ -122,23 +177,132 @@ func (v *Verifier) VerifyGradientResult(ctx context.Context, result *types.Gradi
 	return nil
 }
 
-// verifySubset re-computes gradients on a random subset for verification
+// verifySubset re-runs the forward/backward pass over the samples the
+// result's seed selects and compares the gradient with the claimed one
 func (v *Verifier) verifySubset(ctx context.Context, result *types.GradientResult) error {
-	// In production:
-	// 1. Use VRF to select random samples from the batch
-	// 2. Load model weights
-	// 3. Run forward/backward pass on subset
-	// 4. Compare generated gradients with claimed gradients
+	v.mu.RLock()
+	executor, tasks := v.executor, v.tasks
+	v.mu.RUnlock()
+	if tasks == nil {
+		return ErrNoTaskSource
+	}
+	if executor == nil {
+		return ErrNoExecutor
+	}
 
-	// For simulation, we just validate the proof exists
-	if len(result.Proof) == 0 {
-		return ErrProofInvalid
+	task, err := tasks.GetTask(result.TaskID)
+	if err != nil {
+		return err
+	}
+
+	// The seed is the miner's VRF output for the task bound to the
+	// committed gradient, so the miner cannot choose which samples get
+	// checked
+	output, err := vrf.Verify(task.MinerPublicKey, task.VRFProof, vrf.TaskInput(task.VRFSeed))
+	if err != nil {
+		return ErrInvalidSubset
+	}
+	if result.VRFSeed != SubsetSeed(output, result.GradientHash) {
+		return ErrInvalidSubset
+	}
+	claimed, err := DecodeGradient(result.VerificationSubset)
+	if err != nil {
+		return err
+	}
+
+	if v.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.timeout)
+		defer cancel()
+	}
+	actual, err := v.reexecute(ctx, executor, task, result.VRFSeed)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ErrVerificationTimeout
+		}
+		return err
 	}
 
+	if !gradientsWithinTolerance(claimed, actual, v.tolerance) {
+		return ErrVerificationFailed
+	}
 	return nil
 }
 
-// SelectVerificationSubset uses VRF to select samples for verification
+// reexecute computes the gradient of the task's current model over the
+// samples selected by seed
+func (v *Verifier) reexecute(ctx context.Context, executor TrainingExecutor, task *types.Task, seed types.Hash) ([]int64, error) {
+	data, err := v.modelStore.GetWeights(ctx, task.ModelID)
+	if err != nil {
+		return nil, err
+	}
+	if err := executor.LoadModel(ctx, task.ModelID, DecodeWeights(data)); err != nil {
+		return nil, err
+	}
+	batchSize, err := executor.BatchSize(ctx, task)
+	if err != nil {
+		return nil, err
+	}
+	if batchSize == 0 {
+		return nil, ErrInvalidSubset
+	}
+
+	samples := SelectVerificationSubset(batchSize, VerificationSubsetFraction, seed)
+	return executor.ComputeSampleGradients(ctx, task, samples)
+}
+
+// SubsetSeed derives the seed selecting a result's verification subset
+// from the VRF output its task was drawn with and the committed gradient
+// hash
+func SubsetSeed(vrfOutput []byte, gradientHash types.Hash) types.Hash {
+	return types.Hash(sha256.Sum256(append(append([]byte(nil), vrfOutput...), gradientHash[:]...)))
+}
+
+// EncodeGradient stores gradient values as big-endian int64 values
+func EncodeGradient(gradient []int64) []byte {
+	data := make([]byte, 8*len(gradient))
+	for j, g := range gradient {
+		binary.BigEndian.PutUint64(data[8*j:], uint64(g))
+	}
+	return data
+}
+
+// DecodeGradient reads gradient values stored by EncodeGradient
+func DecodeGradient(data []byte) ([]int64, error) {
+	if len(data) == 0 || len(data)%8 != 0 {
+		return nil, ErrInvalidSubset
+	}
+
+	gradient := make([]int64, len(data)/8)
+	for j := range gradient {
+		gradient[j] = int64(binary.BigEndian.Uint64(data[8*j:]))
+	}
+	return gradient, nil
+}
+
+// gradientsWithinTolerance reports whether every claimed component is
+// within tolerance of the recomputed one, scaled by the largest recomputed
+// component. A deviation of one is always allowed for rounding.
+func gradientsWithinTolerance(claimed, actual []int64, tolerance float64) bool {
+	if len(claimed) != len(actual) {
+		return false
+	}
+
+	var scale float64
+	for _, a := range actual {
+		scale = math.Max(scale, math.Abs(float64(a)))
+	}
+	allowed := math.Max(tolerance*scale, 1)
+	for j := range actual {
+		if math.Abs(float64(claimed[j])-float64(actual[j])) > allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// SelectVerificationSubset selects samples for verification from a seed
+// derived from the miner's VRF output (see SubsetSeed)
 func SelectVerificationSubset(batchSize uint32, subsetPercentage float64, vrfSeed types.Hash) []uint32 {
 	subsetSize := int(float64(batchSize) * subsetPercentage)
 	if subsetSize < 1 {
@@ -162,20 +326,7 @@ func SelectVerificationSubset(batchSize uint32, subsetPercentage float64, vrfSee
 // hashForVRF combines seed with iteration for VRF-like selection
 func hashForVRF(seed types.Hash, iter uint32) types.Hash {
 	data := append(seed[:], uint32ToBytes(iter)...)
-	// Use SHA256 (would use actual VRF in production)
-	var result types.Hash
-	hashBytes := sha256Sum(data)
-	copy(result[:], hashBytes[:])
-	return result
-}
-
-func sha256Sum(data []byte) [32]byte {
-	var result [32]byte
-	// Simplified - use crypto/sha256 in production
-	for i := 0; i < 32 && i < len(data); i++ {
-		result[i] = data[i] ^ 0xAB
-	}
-	return result
+	return types.Hash(sha256.Sum256(data))
 }
 
 func bytesToUint32(b []byte) uint32 {
@@ -0,0 +1,312 @@
+package reputation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/ccoin/core/internal/economics"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Delegation errors
+var (
+	ErrInvalidDelegation = errors.New("invalid delegation signature")
+	ErrStaleDelegation   = errors.New("delegation nonce not above previous delegation")
+	ErrZeroDelegation    = errors.New("delegation amount must be positive")
+)
+
+// Delegation is a holder's stake bonded to a miner. Delegated stake
+// counts towards the miner's minimum stake, earns a proportional share of
+// the staker rewards of the miner's blocks and is slashed at the same
+// rate as the miner's own stake.
+type Delegation struct {
+	Delegator types.Address
+	Miner     types.Address
+
+	// Bonded is the stake backing the miner
+	Bonded uint64
+
+	// Unbonding is stake being undelegated. It is released at
+	// UnbondingUntil and slashable until then.
+	Unbonding      uint64
+	UnbondingUntil uint64
+
+	// Rewards and Slashed are the delegator's cumulative share of the
+	// miner's staker rewards and slashings
+	Rewards uint64
+	Slashed uint64
+
+	// Nonce of the delegator's last delegation to the miner
+	Nonce uint64
+}
+
+// DelegationStore is implemented by slashing stores that persist
+// delegations
+type DelegationStore interface {
+	SaveDelegation(ctx context.Context, d *Delegation) error
+	GetDelegations(ctx context.Context) ([]*Delegation, error)
+}
+
+// LoadDelegations reads every delegation from the store, replacing the
+// cached ones
+func (sm *SlashingManager) LoadDelegations(ctx context.Context) error {
+	store, ok := sm.store.(DelegationStore)
+	if !ok {
+		return nil
+	}
+	delegations, err := store.GetDelegations(ctx)
+	if err != nil {
+		return err
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.delegations = make(map[types.Address]map[types.Address]*Delegation)
+	for _, d := range delegations {
+		sm.delegationsTo(d.Miner)[d.Delegator] = d
+	}
+	return nil
+}
+
+// ValidateDelegation checks a delegation's signature, that its nonce
+// advances past the delegator's last delegation to the miner and that an
+// unbonding does not exceed the bonded stake
+func (sm *SlashingManager) ValidateDelegation(d *types.StakeDelegation) error {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.validateDelegationLocked(d)
+}
+
+func (sm *SlashingManager) validateDelegationLocked(d *types.StakeDelegation) error {
+	if !d.Verify() {
+		return ErrInvalidDelegation
+	}
+	if d.Amount == 0 {
+		return ErrZeroDelegation
+	}
+
+	record := sm.delegations[d.Miner][d.Delegator()]
+	if record != nil && d.Nonce <= record.Nonce {
+		return ErrStaleDelegation
+	}
+	switch d.Action {
+	case types.DelegationBond:
+		return nil
+	case types.DelegationUnbond:
+		if record == nil || d.Amount > record.Bonded {
+			return ErrInsufficientStake
+		}
+		return nil
+	default:
+		return ErrInvalidDelegation
+	}
+}
+
+// ApplyBlock updates stakes and delegations for a block: unbonding stake
+// due by its height is released, the valid delegations its transactions
+// carry are applied, and the staker share of its reward is split between
+// the miner's own stake and its delegations in proportion to their size
+func (sm *SlashingManager) ApplyBlock(ctx context.Context, block *types.Block) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	header := block.Header
+	if header.Height > sm.height {
+		sm.height = header.Height
+	}
+	if err := sm.releaseUnbondingLocked(ctx, header.Height); err != nil {
+		return err
+	}
+
+	changed := make(map[*Delegation]struct{})
+	for _, byDelegator := range sm.delegations {
+		for _, d := range byDelegator {
+			if d.Unbonding > 0 && d.UnbondingUntil <= header.Height {
+				// In production, this would release the stake to the delegator
+				d.Unbonding = 0
+				changed[d] = struct{}{}
+			}
+		}
+	}
+
+	for _, tx := range block.Transactions {
+		op := tx.Delegation
+		if op == nil {
+			continue
+		}
+		if sm.validateDelegationLocked(op) != nil {
+			continue
+		}
+
+		byDelegator := sm.delegationsTo(op.Miner)
+		d, exists := byDelegator[op.Delegator()]
+		if !exists {
+			d = &Delegation{Delegator: op.Delegator(), Miner: op.Miner}
+			byDelegator[d.Delegator] = d
+		}
+		if op.Action == types.DelegationBond {
+			d.Bonded += op.Amount
+		} else {
+			d.Bonded -= op.Amount
+			d.Unbonding += op.Amount
+			d.UnbondingUntil = header.Height + types.UnbondingPeriod
+		}
+		d.Nonce = op.Nonce
+		changed[d] = struct{}{}
+	}
+
+	reward := economics.CalculateMinerReward(header.Height, header.ReputationScore)
+	_, stakerShare, _, _, _ := sm.distribution.CalculateDistribution(reward)
+	for _, d := range sm.shareRewardsLocked(header.MinerAddress, stakerShare) {
+		changed[d] = struct{}{}
+	}
+
+	return sm.saveDelegationsLocked(ctx, changed)
+}
+
+// shareRewardsLocked credits each of a miner's delegations its share of
+// amount, in proportion to its bonded stake against the miner's total.
+// The miner keeps the share of its own stake.
+func (sm *SlashingManager) shareRewardsLocked(miner types.Address, amount uint64) []*Delegation {
+	var own uint64
+	if stake, exists := sm.stakes[miner]; exists {
+		own = stake.AvailableStake
+	}
+	total := own + sm.delegatedLocked(miner)
+	if total == 0 || amount == 0 {
+		return nil
+	}
+
+	var credited []*Delegation
+	for _, d := range sm.delegations[miner] {
+		share := uint64(float64(amount) * float64(d.Bonded) / float64(total))
+		if share == 0 {
+			continue
+		}
+		d.Rewards += share
+		credited = append(credited, d)
+	}
+	return credited
+}
+
+// slashDelegationsLocked slashes a miner's delegations at rate, returning
+// the total taken. Unbonding stake is slashed with bonded stake. On a
+// forced exit the remaining bonded stake starts unbonding.
+func (sm *SlashingManager) slashDelegationsLocked(miner types.Address, rate float64, exit bool) (uint64, map[*Delegation]struct{}) {
+	var total uint64
+	changed := make(map[*Delegation]struct{})
+	for _, d := range sm.delegations[miner] {
+		bonded := uint64(float64(d.Bonded) * rate)
+		unbonding := uint64(float64(d.Unbonding) * rate)
+		d.Bonded -= bonded
+		d.Unbonding -= unbonding
+		d.Slashed += bonded + unbonding
+		total += bonded + unbonding
+
+		if exit && d.Bonded > 0 {
+			d.Unbonding += d.Bonded
+			d.Bonded = 0
+			d.UnbondingUntil = sm.height + types.UnbondingPeriod
+		}
+		changed[d] = struct{}{}
+	}
+	return total, changed
+}
+
+// saveDelegationsLocked persists changed delegations if the store keeps
+// them
+func (sm *SlashingManager) saveDelegationsLocked(ctx context.Context, changed map[*Delegation]struct{}) error {
+	store, ok := sm.store.(DelegationStore)
+	if !ok {
+		return nil
+	}
+	for d := range changed {
+		if err := store.SaveDelegation(ctx, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// delegationsTo returns a miner's delegations by delegator, creating the
+// map if needed
+func (sm *SlashingManager) delegationsTo(miner types.Address) map[types.Address]*Delegation {
+	byDelegator, exists := sm.delegations[miner]
+	if !exists {
+		byDelegator = make(map[types.Address]*Delegation)
+		sm.delegations[miner] = byDelegator
+	}
+	return byDelegator
+}
+
+// delegatedLocked returns the stake bonded to a miner by delegators
+func (sm *SlashingManager) delegatedLocked(miner types.Address) uint64 {
+	var total uint64
+	for _, d := range sm.delegations[miner] {
+		total += d.Bonded
+	}
+	return total
+}
+
+// DelegatedStake returns the stake bonded to a miner by delegators
+func (sm *SlashingManager) DelegatedStake(miner types.Address) uint64 {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.delegatedLocked(miner)
+}
+
+// Delegations returns copies of the delegations to a miner, ordered by
+// delegator
+func (sm *SlashingManager) Delegations(miner types.Address) []*Delegation {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	var out []*Delegation
+	for _, d := range sm.delegations[miner] {
+		copied := *d
+		out = append(out, &copied)
+	}
+	sort.Slice(out, func(i, j int) bool { return bytes.Compare(out[i].Delegator[:], out[j].Delegator[:]) < 0 })
+	return out
+}
+
+// DelegationsBy returns copies of a delegator's delegations, ordered by
+// miner
+func (sm *SlashingManager) DelegationsBy(delegator types.Address) []*Delegation {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	var out []*Delegation
+	for _, byDelegator := range sm.delegations {
+		if d, exists := byDelegator[delegator]; exists {
+			copied := *d
+			out = append(out, &copied)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return bytes.Compare(out[i].Miner[:], out[j].Miner[:]) < 0 })
+	return out
+}
+
+// AllDelegations returns copies of every delegation, ordered by miner
+// then delegator
+func (sm *SlashingManager) AllDelegations() []*Delegation {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	var out []*Delegation
+	for _, byDelegator := range sm.delegations {
+		for _, d := range byDelegator {
+			copied := *d
+			out = append(out, &copied)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if c := bytes.Compare(out[i].Miner[:], out[j].Miner[:]); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(out[i].Delegator[:], out[j].Delegator[:]) < 0
+	})
+	return out
+}
@@ -2,11 +2,16 @@
 package reputation
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
 	"errors"
 	"math"
+	"sort"
 	"sync"
 
+	"github.com/ccoin/core/internal/params"
 	"github.com/ccoin/core/pkg/types"
 )
 
@@ -17,7 +22,9 @@ var (
 	ErrMinerBanned      = errors.New("miner is banned")
 )
 
-// Constants for reputation system
+// Constants for reputation system. The EWMA, penalty and ban constants
+// are defaults that governance can change through the consensus parameter
+// registry; see ParamDefinitions.
 const (
 	// Epoch length in blocks
 	EpochLength = 1000
@@ -54,11 +61,17 @@ type EpochManager struct {
 	// Miner data
 	miners map[types.Address]*MinerReputation
 
+	// Miners changed since they were last saved
+	dirty map[types.Address]bool
+
 	// Storage backend
 	store ReputationStore
 
 	// Current block height
 	currentHeight uint64
+
+	// Tunable parameters; nil uses the defaults
+	params *params.Registry
 }
 
 // Epoch represents a single epoch's statistics
@@ -73,6 +86,10 @@ type Epoch struct {
 	TotalQuality     float64
 	ParticipantCount int
 
+	// StateRoot commits to every miner's reputation once the epoch is
+	// finalized, so nodes can compare checkpoints
+	StateRoot types.Hash
+
 	// Per-miner statistics
 	MinerStats map[types.Address]*EpochMinerStats
 }
@@ -125,16 +142,33 @@ func NewEpochManager(store ReputationStore) *EpochManager {
 	return &EpochManager{
 		epochs: make(map[uint64]*Epoch),
 		miners: make(map[types.Address]*MinerReputation),
+		dirty:  make(map[types.Address]bool),
 		store:  store,
 	}
 }
 
-// ProcessBlock processes a new block for reputation updates
+// ProcessBlock processes a new block for reputation updates and saves the
+// miners it changed. Use a Pipeline to keep this off the block path.
 func (em *EpochManager) ProcessBlock(ctx context.Context, block *types.Block) error {
 	em.mu.Lock()
 	defer em.mu.Unlock()
 
-	header := block.Header
+	if err := em.applyBlockLocked(ctx, block.Header); err != nil {
+		return err
+	}
+	return em.persistLocked(ctx)
+}
+
+// applyBlock updates reputation for a block without saving miners,
+// except at epoch boundaries
+func (em *EpochManager) applyBlock(ctx context.Context, header *types.BlockHeader) error {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	return em.applyBlockLocked(ctx, header)
+}
+
+// applyBlockLocked updates epoch and miner statistics for a block
+func (em *EpochManager) applyBlockLocked(ctx context.Context, header *types.BlockHeader) error {
 	em.currentHeight = header.Height
 
 	// Check if we need to start a new epoch
@@ -146,6 +180,9 @@ func (em *EpochManager) ProcessBlock(ctx context.Context, block *types.Block) er
 		em.currentEpoch = newEpoch
 		em.startEpoch(newEpoch, header.Height)
 	}
+	if _, exists := em.epochs[em.currentEpoch]; !exists {
+		em.startEpoch(em.currentEpoch, em.currentEpoch*EpochLength)
+	}
 
 	// Get or create miner
 	miner := em.getOrCreateMiner(header.MinerAddress)
@@ -182,12 +219,76 @@ func (em *EpochManager) ProcessBlock(ctx context.Context, block *types.Block) er
 	// Clamp to bounds
 	miner.Score = clamp(miner.Score, MinReputation, MaxReputation)
 
-	return em.store.SaveMiner(ctx, miner)
+	em.dirty[miner.Address] = true
+	return nil
+}
+
+// Persist saves the miners changed since the last save
+func (em *EpochManager) Persist(ctx context.Context) error {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	return em.persistLocked(ctx)
+}
+
+// persistLocked saves dirty miners in address order
+func (em *EpochManager) persistLocked(ctx context.Context) error {
+	addrs := make([]types.Address, 0, len(em.dirty))
+	for addr := range em.dirty {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return bytes.Compare(addrs[i][:], addrs[j][:]) < 0 })
+
+	for _, addr := range addrs {
+		if err := em.store.SaveMiner(ctx, em.miners[addr]); err != nil {
+			return err
+		}
+		delete(em.dirty, addr)
+	}
+	return nil
+}
+
+// StateRoot hashes the reputation state of every miner in address order
+func (em *EpochManager) StateRoot() types.Hash {
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+	return em.stateRootLocked()
+}
+
+// stateRootLocked computes StateRoot
+func (em *EpochManager) stateRootLocked() types.Hash {
+	miners := make([]*MinerReputation, 0, len(em.miners))
+	for _, m := range em.miners {
+		miners = append(miners, m)
+	}
+	sort.Slice(miners, func(i, j int) bool { return bytes.Compare(miners[i].Address[:], miners[j].Address[:]) < 0 })
+
+	h := sha256.New()
+	for _, m := range miners {
+		h.Write(m.Address[:])
+		for _, v := range []uint64{
+			math.Float64bits(m.Score), m.TotalBlocks, math.Float64bits(m.TotalQuality),
+			m.EpochsActive, m.LastActiveEpoch, m.LastActiveBlock,
+			m.SlashingCount, m.TotalSlashed, boolToUint64(m.IsBanned), m.BanExpiresBlock,
+			m.StakedAmount, m.LockedUntilBlock,
+		} {
+			binary.Write(h, binary.BigEndian, v)
+		}
+	}
+	return types.HashFromBytes(h.Sum(nil))
+}
+
+// boolToUint64 encodes a flag for hashing
+func boolToUint64(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
 }
 
 // calculateEWMA computes exponentially weighted moving average
 func (em *EpochManager) calculateEWMA(current, newValue float64) float64 {
-	return EWMAAlpha*newValue + (1-EWMAAlpha)*current
+	alpha := em.param(ParamEWMAAlpha)
+	return alpha*newValue + (1-alpha)*current
 }
 
 // startEpoch initializes a new epoch
@@ -215,30 +316,32 @@ func (em *EpochManager) finalizeEpoch(ctx context.Context, epochNum uint64) erro
 	epoch.ParticipantCount = len(epoch.MinerStats)
 
 	// Apply decay to inactive miners
+	offlinePenalty := em.param(ParamOfflinePenalty)
+	banThreshold := em.param(ParamBanThreshold)
+	banDuration := uint64(em.param(ParamBanDurationBlocks))
 	for addr, miner := range em.miners {
 		if miner.LastActiveEpoch < epochNum {
 			// Apply inactivity penalty
 			inactiveEpochs := epochNum - miner.LastActiveEpoch
-			decayFactor := math.Pow(1-OfflinePenalty, float64(inactiveEpochs))
+			decayFactor := math.Pow(1-offlinePenalty, float64(inactiveEpochs))
 			miner.Score *= decayFactor
 			miner.Score = clamp(miner.Score, MinReputation, MaxReputation)
-
-			if err := em.store.SaveMiner(ctx, miner); err != nil {
-				return err
-			}
+			em.dirty[addr] = true
 		}
 
 		// Check for automatic banning
-		if miner.Score < BanThreshold && !miner.IsBanned {
+		if miner.Score < banThreshold && !miner.IsBanned {
 			miner.IsBanned = true
-			miner.BanExpiresBlock = em.currentHeight + BanDurationBlocks
-			if err := em.store.SaveMiner(ctx, miner); err != nil {
-				return err
-			}
+			miner.BanExpiresBlock = em.currentHeight + banDuration
+			em.dirty[addr] = true
 		}
+	}
 
-		_ = addr // unused
+	// Checkpoint: every miner is saved with the state the root commits to
+	if err := em.persistLocked(ctx); err != nil {
+		return err
 	}
+	epoch.StateRoot = em.stateRootLocked()
 
 	return em.store.SaveEpoch(ctx, epoch)
 }
@@ -267,11 +370,11 @@ func (em *EpochManager) ApplyPenalty(ctx context.Context, addr types.Address, pe
 	var penalty float64
 	switch penaltyType {
 	case PenaltyInvalidBlock:
-		penalty = InvalidBlockPenalty
+		penalty = em.param(ParamInvalidBlockPenalty)
 	case PenaltyDoubleVote:
-		penalty = DoubleVotePenalty
+		penalty = em.param(ParamDoubleVotePenalty)
 	case PenaltyOffline:
-		penalty = OfflinePenalty
+		penalty = em.param(ParamOfflinePenalty)
 	default:
 		penalty = 0.1
 	}
@@ -311,6 +414,16 @@ func (em *EpochManager) GetMinerReputation(addr types.Address) float64 {
 	return InitialReputation
 }
 
+// NeedsSlashingReview reports whether a miner's reputation is below the
+// slashing review threshold
+func (em *EpochManager) NeedsSlashingReview(addr types.Address) bool {
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+
+	miner, exists := em.miners[addr]
+	return exists && miner.Score < em.param(ParamSlashThreshold)
+}
+
 // GetTopMiners returns the top miners by reputation
 func (em *EpochManager) GetTopMiners(limit int) []*MinerReputation {
 	em.mu.RLock()
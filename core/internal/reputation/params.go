@@ -0,0 +1,59 @@
+package reputation
+
+import (
+	"github.com/ccoin/core/internal/params"
+)
+
+// Reputation parameters in the consensus parameter registry
+const (
+	ParamEWMAAlpha           = "reputation.ewma_alpha"
+	ParamInvalidBlockPenalty = "reputation.invalid_block_penalty"
+	ParamDoubleVotePenalty   = "reputation.double_vote_penalty"
+	ParamOfflinePenalty      = "reputation.offline_penalty"
+	ParamSlashThreshold      = "reputation.slash_threshold"
+	ParamBanThreshold        = "reputation.ban_threshold"
+	ParamBanDurationBlocks   = "reputation.ban_duration_blocks"
+)
+
+// ParamDefinitions are the reputation parameters governance can tune. The
+// defaults are the package constants.
+var ParamDefinitions = []params.Definition{
+	{Name: ParamEWMAAlpha, Description: "weight of a block's quality in the reputation EWMA", Default: EWMAAlpha, Min: 0.01, Max: 0.5},
+	{Name: ParamInvalidBlockPenalty, Description: "reputation fraction lost per invalid block", Default: InvalidBlockPenalty, Min: 0, Max: 0.9},
+	{Name: ParamDoubleVotePenalty, Description: "reputation fraction lost per double vote", Default: DoubleVotePenalty, Min: 0, Max: 0.9},
+	{Name: ParamOfflinePenalty, Description: "reputation fraction lost per inactive epoch", Default: OfflinePenalty, Min: 0, Max: 0.5},
+	{Name: ParamSlashThreshold, Description: "reputation below which miners are reviewed for slashing", Default: SlashThreshold, Min: MinReputation, Max: InitialReputation},
+	{Name: ParamBanThreshold, Description: "reputation below which miners are banned", Default: BanThreshold, Min: 0, Max: InitialReputation},
+	{Name: ParamBanDurationBlocks, Description: "blocks an automatic ban lasts", Default: BanDurationBlocks, Min: EpochLength, Max: 100 * BanDurationBlocks, Integer: true},
+}
+
+// paramDefaults maps each parameter to its default
+var paramDefaults = func() map[string]float64 {
+	m := make(map[string]float64, len(ParamDefinitions))
+	for _, d := range ParamDefinitions {
+		m[d.Name] = d.Default
+	}
+	return m
+}()
+
+// SetParams makes the manager read its tunable parameters from a
+// registry, registering them there. Without one the defaults apply.
+func (em *EpochManager) SetParams(r *params.Registry) error {
+	if err := r.Register(ParamDefinitions...); err != nil {
+		return err
+	}
+
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	em.params = r
+	return nil
+}
+
+// param returns a parameter's value at the current height. Callers hold
+// em.mu.
+func (em *EpochManager) param(name string) float64 {
+	if em.params == nil {
+		return paramDefaults[name]
+	}
+	return em.params.Value(name, em.currentHeight)
+}
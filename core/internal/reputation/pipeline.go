@@ -0,0 +1,191 @@
+package reputation
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// ErrPipelineStopped is returned when submitting to a stopped pipeline
+var ErrPipelineStopped = errors.New("reputation pipeline stopped")
+
+// PipelineConfig holds reputation pipeline parameters
+type PipelineConfig struct {
+	// QueueSize is the number of blocks buffered ahead of processing;
+	// Submit blocks once it is full
+	QueueSize int
+
+	// BatchBlocks is the number of blocks between miner saves. Epoch
+	// boundaries always save, before the epoch's checkpoint.
+	BatchBlocks uint64
+}
+
+// DefaultPipelineConfig returns default pipeline parameters
+func DefaultPipelineConfig() *PipelineConfig {
+	return &PipelineConfig{
+		QueueSize:   1024,
+		BatchBlocks: 100,
+	}
+}
+
+// Pipeline applies blocks to an EpochManager in the background, in the
+// order they were submitted, so reputation bookkeeping stays off the block
+// application path. Changed miners are saved every BatchBlocks blocks.
+type Pipeline struct {
+	em     *EpochManager
+	config *PipelineConfig
+
+	mu      sync.RWMutex
+	queue   chan pipelineItem
+	done    chan struct{}
+	started bool
+	stopped bool
+
+	errMu sync.Mutex
+	err   error
+}
+
+// pipelineItem is a block to apply or, if flushed is set, a flush request
+type pipelineItem struct {
+	header  *types.BlockHeader
+	flushed chan error
+}
+
+// NewPipeline creates a pipeline feeding em
+func NewPipeline(em *EpochManager, cfg *PipelineConfig) *Pipeline {
+	if cfg == nil {
+		cfg = DefaultPipelineConfig()
+	}
+
+	return &Pipeline{
+		em:     em,
+		config: cfg,
+		queue:  make(chan pipelineItem, cfg.QueueSize),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start runs the pipeline until Stop
+func (p *Pipeline) Start(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.started || p.stopped {
+		return
+	}
+	p.started = true
+	go p.run(ctx)
+}
+
+// Submit queues a block. Blocks are applied in submission order; a store
+// failure stops processing and is returned by later calls.
+func (p *Pipeline) Submit(ctx context.Context, block *types.Block) error {
+	header := *block.Header
+	return p.enqueue(ctx, pipelineItem{header: &header})
+}
+
+// Flush waits until every block submitted so far is applied and saved
+func (p *Pipeline) Flush(ctx context.Context) error {
+	flushed := make(chan error, 1)
+	if err := p.enqueue(ctx, pipelineItem{flushed: flushed}); err != nil {
+		return err
+	}
+	select {
+	case err := <-flushed:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stop applies and saves the queued blocks, then stops the pipeline
+func (p *Pipeline) Stop() error {
+	p.mu.Lock()
+	if !p.stopped {
+		p.stopped = true
+		close(p.queue)
+		if !p.started {
+			close(p.done)
+		}
+	}
+	p.mu.Unlock()
+
+	<-p.done
+	return p.Err()
+}
+
+// Err returns the failure that stopped processing, if any
+func (p *Pipeline) Err() error {
+	p.errMu.Lock()
+	defer p.errMu.Unlock()
+	return p.err
+}
+
+// enqueue adds an item unless the pipeline is stopped or failed
+func (p *Pipeline) enqueue(ctx context.Context, item pipelineItem) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.stopped {
+		return ErrPipelineStopped
+	}
+	if err := p.Err(); err != nil {
+		return err
+	}
+	select {
+	case p.queue <- item:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run applies queued blocks, saving miners in batches
+func (p *Pipeline) run(ctx context.Context) {
+	defer close(p.done)
+
+	var pending uint64
+	for item := range p.queue {
+		if p.Err() != nil {
+			if item.flushed != nil {
+				item.flushed <- p.Err()
+			}
+			continue
+		}
+
+		if item.flushed != nil {
+			p.fail(p.em.Persist(ctx))
+			pending = 0
+			item.flushed <- p.Err()
+			continue
+		}
+
+		// A banned miner's block earns nothing but is not a failure
+		if err := p.em.applyBlock(ctx, item.header); err != nil && !errors.Is(err, ErrMinerBanned) {
+			p.fail(err)
+			continue
+		}
+		if pending++; pending >= p.config.BatchBlocks {
+			p.fail(p.em.Persist(ctx))
+			pending = 0
+		}
+	}
+
+	if p.Err() == nil {
+		p.fail(p.em.Persist(ctx))
+	}
+}
+
+// fail records the first processing failure
+func (p *Pipeline) fail(err error) {
+	if err == nil {
+		return
+	}
+	p.errMu.Lock()
+	defer p.errMu.Unlock()
+	if p.err == nil {
+		p.err = err
+	}
+}
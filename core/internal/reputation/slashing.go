@@ -2,10 +2,15 @@
 package reputation
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"errors"
+	"sort"
 	"sync"
 
+	"github.com/ccoin/core/internal/economics"
 	"github.com/ccoin/core/pkg/types"
 )
 
@@ -15,6 +20,7 @@ var (
 	ErrStakeLocked          = errors.New("stake is locked")
 	ErrSlashingLimitReached = errors.New("slashing limit reached")
 	ErrNotSlashable         = errors.New("violation not slashable")
+	ErrInvalidEvidence      = errors.New("evidence does not prove the offense")
 )
 
 // SlashingType defines types of slashable offenses
@@ -28,6 +34,24 @@ const (
 	SlashTypeFraudulentPoUW
 )
 
+// String returns the offense's name
+func (t SlashingType) String() string {
+	switch t {
+	case SlashTypeInvalidBlock:
+		return "invalid block"
+	case SlashTypeDoubleSign:
+		return "double sign"
+	case SlashTypeEquivocation:
+		return "equivocation"
+	case SlashTypeCensorship:
+		return "censorship"
+	case SlashTypeFraudulentPoUW:
+		return "fraudulent PoUW"
+	default:
+		return "unknown"
+	}
+}
+
 // SlashingConfig holds slashing parameters
 type SlashingConfig struct {
 	// Percentage of stake to slash for each offense type
@@ -39,6 +63,9 @@ type SlashingConfig struct {
 	// Lock period for stake (in blocks)
 	StakeLockPeriod uint64
 
+	// Blocks an unstaked amount waits in the unbonding queue
+	UnbondingPeriod uint64
+
 	// Maximum cumulative slashing before forced exit
 	MaxCumulativeSlash float64
 }
@@ -55,6 +82,7 @@ func DefaultSlashingConfig() *SlashingConfig {
 		},
 		MinimumStake:       1000000, // 1M CCoin minimum
 		StakeLockPeriod:    10000,   // ~1 day at 10s blocks
+		UnbondingPeriod:    types.UnbondingPeriod,
 		MaxCumulativeSlash: 0.75,    // Force exit at 75% total slashed
 	}
 }
@@ -68,9 +96,22 @@ type SlashingManager struct {
 	// Staking state per miner
 	stakes map[types.Address]*StakeInfo
 
+	// Stake delegated to each miner, by delegator
+	delegations map[types.Address]map[types.Address]*Delegation
+
+	// Reward split the staker share of block rewards comes from
+	distribution *economics.RewardDistribution
+
+	// Height of the latest block applied
+	height uint64
+
 	// Slashing evidence
 	evidence map[types.Hash]*SlashingEvidence
 
+	// onEvidence is called for new evidence and again once it is
+	// processed
+	onEvidence func(*SlashingEvidence)
+
 	// Storage
 	store SlashingStore
 }
@@ -85,6 +126,10 @@ type StakeInfo struct {
 	TotalSlashed     uint64
 	SlashingRatio    float64 // Cumulative slashing percentage
 	BondedAt         uint64
+
+	// Unbonding is the queue of withdrawals LockedStake is waiting on,
+	// in completion order
+	Unbonding []*Unbonding
 }
 
 // SlashingEvidence represents evidence of a slashable offense
@@ -99,6 +144,62 @@ type SlashingEvidence struct {
 	SlashAmount  uint64
 }
 
+// BlockEvidence builds evidence against the signer of a block that
+// failed validation. The signed header is the proof; blocks without a
+// valid signature cannot be attributed and are not slashable. A header
+// signed for another miner's address is evidence against the signer.
+func BlockEvidence(header *types.BlockHeader, reason error) (*SlashingEvidence, error) {
+	signer, ok := header.Signer()
+	if !ok || header.ComputeHash() != header.Hash {
+		return nil, ErrNotSlashable
+	}
+
+	proof, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	return &SlashingEvidence{
+		EvidenceHash: blockEvidenceHash(header),
+		Type:         SlashTypeInvalidBlock,
+		MinerAddress: signer,
+		BlockHeight:  header.Height,
+		Description:  reason.Error(),
+		ProofData:    proof,
+	}, nil
+}
+
+// VerifyBlockEvidence checks that block evidence carries a header signed
+// by the accused miner and returns the header, so the offense can be
+// re-validated
+func VerifyBlockEvidence(evidence *SlashingEvidence) (*types.BlockHeader, error) {
+	if evidence.Type != SlashTypeInvalidBlock {
+		return nil, ErrInvalidEvidence
+	}
+
+	var header types.BlockHeader
+	if err := json.Unmarshal(evidence.ProofData, &header); err != nil {
+		return nil, ErrInvalidEvidence
+	}
+	signer, ok := header.Signer()
+	if !ok || signer != evidence.MinerAddress || header.ComputeHash() != header.Hash {
+		return nil, ErrInvalidEvidence
+	}
+	if evidence.EvidenceHash != blockEvidenceHash(&header) {
+		return nil, ErrInvalidEvidence
+	}
+	return &header, nil
+}
+
+// blockEvidenceHash identifies evidence for a signed block, so reports of
+// the same block from many peers collapse into one
+func blockEvidenceHash(header *types.BlockHeader) types.Hash {
+	buf := make([]byte, 0, 13+types.HashSize+len(header.Signature))
+	buf = append(buf, "invalid-block"...)
+	buf = append(buf, header.Hash[:]...)
+	buf = append(buf, header.Signature...)
+	return sha256.Sum256(buf)
+}
+
 // SlashingStore defines persistence for slashing
 type SlashingStore interface {
 	SaveStake(ctx context.Context, stake *StakeInfo) error
@@ -114,10 +215,12 @@ func NewSlashingManager(store SlashingStore, config *SlashingConfig) *SlashingMa
 	}
 
 	return &SlashingManager{
-		config:   config,
-		stakes:   make(map[types.Address]*StakeInfo),
-		evidence: make(map[types.Hash]*SlashingEvidence),
-		store:    store,
+		config:       config,
+		stakes:       make(map[types.Address]*StakeInfo),
+		delegations:  make(map[types.Address]map[types.Address]*Delegation),
+		distribution: economics.DefaultRewardDistribution(),
+		evidence:     make(map[types.Hash]*SlashingEvidence),
+		store:        store,
 	}
 }
 
@@ -138,7 +241,9 @@ func (sm *SlashingManager) Stake(ctx context.Context, addr types.Address, amount
 	return sm.store.SaveStake(ctx, stake)
 }
 
-// Unstake removes stake for a miner (after lock period)
+// Unstake starts withdrawing part of a miner's stake (after lock
+// period). The amount joins the miner's unbonding queue and is released
+// UnbondingPeriod blocks later as blocks are applied.
 func (sm *SlashingManager) Unstake(ctx context.Context, addr types.Address, amount uint64, currentBlock uint64) error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
@@ -147,6 +252,9 @@ func (sm *SlashingManager) Unstake(ctx context.Context, addr types.Address, amou
 	if !exists {
 		return ErrInsufficientStake
 	}
+	if amount == 0 {
+		return ErrZeroUnstake
+	}
 
 	if currentBlock < stake.LockedUntilBlock {
 		return ErrStakeLocked
@@ -156,31 +264,58 @@ func (sm *SlashingManager) Unstake(ctx context.Context, addr types.Address, amou
 		return ErrInsufficientStake
 	}
 
-	stake.TotalStaked -= amount
-	stake.AvailableStake -= amount
-
+	sm.queueUnbondingLocked(stake, amount, currentBlock)
 	return sm.store.SaveStake(ctx, stake)
 }
 
+// SetEvidenceHandler registers fn to be called when new evidence is
+// submitted and again when it has been processed, with Processed and
+// SlashAmount set. It is called without the manager's lock held.
+func (sm *SlashingManager) SetEvidenceHandler(fn func(*SlashingEvidence)) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.onEvidence = fn
+}
+
 // SubmitEvidence submits slashing evidence
 func (sm *SlashingManager) SubmitEvidence(ctx context.Context, evidence *SlashingEvidence) error {
 	sm.mu.Lock()
-	defer sm.mu.Unlock()
 
 	// Check if evidence already exists
 	if _, exists := sm.evidence[evidence.EvidenceHash]; exists {
+		sm.mu.Unlock()
 		return nil // Already submitted
 	}
 
 	sm.evidence[evidence.EvidenceHash] = evidence
-	return sm.store.SaveEvidence(ctx, evidence)
+	err := sm.store.SaveEvidence(ctx, evidence)
+	handler := sm.onEvidence
+	sm.mu.Unlock()
+
+	if err == nil && handler != nil {
+		handler(evidence)
+	}
+	return err
 }
 
 // ProcessSlashing processes a slashing event
 func (sm *SlashingManager) ProcessSlashing(ctx context.Context, evidenceHash types.Hash) error {
 	sm.mu.Lock()
-	defer sm.mu.Unlock()
+	evidence := sm.evidence[evidenceHash]
+	pending := evidence != nil && !evidence.Processed
+	err := sm.processSlashingLocked(ctx, evidenceHash)
+	handler := sm.onEvidence
+	sm.mu.Unlock()
+
+	if err == nil && pending && handler != nil {
+		handler(evidence)
+	}
+	return err
+}
 
+// processSlashingLocked slashes the miner and its delegators for
+// unprocessed evidence
+func (sm *SlashingManager) processSlashingLocked(ctx context.Context, evidenceHash types.Hash) error {
 	evidence, exists := sm.evidence[evidenceHash]
 	if !exists {
 		return errors.New("evidence not found")
@@ -198,13 +333,16 @@ func (sm *SlashingManager) ProcessSlashing(ctx context.Context, evidenceHash typ
 		return ErrNotSlashable
 	}
 
-	slashAmount := uint64(float64(stake.TotalStaked) * slashRate)
+	// Unbonding stake is slashed separately below
+	slashAmount := uint64(float64(stake.TotalStaked-stake.LockedStake) * slashRate)
 	if slashAmount > stake.AvailableStake {
 		slashAmount = stake.AvailableStake
 	}
 
-	// Apply slashing
+	// Apply slashing. Withdrawals requested after the offense cannot
+	// escape it.
 	stake.AvailableStake -= slashAmount
+	slashAmount += slashUnbonding(stake, evidence.BlockHeight, slashRate)
 	stake.TotalSlashed += slashAmount
 	stake.SlashingRatio = float64(stake.TotalSlashed) / float64(stake.TotalStaked+stake.TotalSlashed)
 
@@ -212,15 +350,23 @@ func (sm *SlashingManager) ProcessSlashing(ctx context.Context, evidenceHash typ
 	evidence.Processed = true
 
 	// Check if forced exit
-	if stake.SlashingRatio >= sm.config.MaxCumulativeSlash {
+	exit := stake.SlashingRatio >= sm.config.MaxCumulativeSlash
+	if exit {
 		// Force exit - miner loses all remaining stake
 		stake.TotalSlashed += stake.AvailableStake
 		stake.AvailableStake = 0
 	}
 
+	// Delegators share the miner's offense at the same rate
+	delegated, changed := sm.slashDelegationsLocked(evidence.MinerAddress, slashRate, exit)
+	evidence.SlashAmount += delegated
+
 	if err := sm.store.SaveStake(ctx, stake); err != nil {
 		return err
 	}
+	if err := sm.saveDelegationsLocked(ctx, changed); err != nil {
+		return err
+	}
 
 	return sm.store.SaveEvidence(ctx, evidence)
 }
@@ -236,7 +382,8 @@ func (sm *SlashingManager) getOrCreateStake(addr types.Address) *StakeInfo {
 	return stake
 }
 
-// IsEligibleToMine checks if a miner has sufficient stake to mine
+// IsEligibleToMine checks if a miner has sufficient stake to mine.
+// Stake delegated to the miner counts towards the minimum.
 func (sm *SlashingManager) IsEligibleToMine(addr types.Address) bool {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
@@ -246,7 +393,33 @@ func (sm *SlashingManager) IsEligibleToMine(addr types.Address) bool {
 		return false
 	}
 
-	return stake.AvailableStake >= sm.config.MinimumStake
+	return stake.AvailableStake+sm.delegatedLocked(addr) >= sm.config.MinimumStake
+}
+
+// LoadStake reads a miner's stake from the store, replacing any cached
+// copy. It returns nil if the miner has never staked.
+func (sm *SlashingManager) LoadStake(ctx context.Context, addr types.Address) (*StakeInfo, error) {
+	stake, err := sm.store.GetStake(ctx, addr)
+	if err != nil || stake == nil {
+		return nil, err
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.stakes[addr] = stake
+
+	// Withdrawals that matured while the stake was not cached
+	if completeUnbonding(stake, sm.height) {
+		if err := sm.store.SaveStake(ctx, stake); err != nil {
+			return nil, err
+		}
+	}
+	return stake, nil
+}
+
+// MinimumStake returns the stake required to mine
+func (sm *SlashingManager) MinimumStake() uint64 {
+	return sm.config.MinimumStake
 }
 
 // GetStakeInfo returns stake information for a miner
@@ -260,7 +433,27 @@ func (sm *SlashingManager) GetStakeInfo(addr types.Address) *StakeInfo {
 	return nil
 }
 
-// GetTotalStaked returns the total staked amount across all miners
+// Stakes returns copies of every miner's stake, ordered by address
+func (sm *SlashingManager) Stakes() []*StakeInfo {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	out := make([]*StakeInfo, 0, len(sm.stakes))
+	for _, stake := range sm.stakes {
+		copied := *stake
+		copied.Unbonding = make([]*Unbonding, len(stake.Unbonding))
+		for i, u := range stake.Unbonding {
+			queued := *u
+			copied.Unbonding[i] = &queued
+		}
+		out = append(out, &copied)
+	}
+	sort.Slice(out, func(i, j int) bool { return bytes.Compare(out[i].Address[:], out[j].Address[:]) < 0 })
+	return out
+}
+
+// GetTotalStaked returns the total staked amount across all miners,
+// including delegated stake
 func (sm *SlashingManager) GetTotalStaked() uint64 {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
@@ -269,6 +462,9 @@ func (sm *SlashingManager) GetTotalStaked() uint64 {
 	for _, stake := range sm.stakes {
 		total += stake.AvailableStake
 	}
+	for miner := range sm.delegations {
+		total += sm.delegatedLocked(miner)
+	}
 	return total
 }
 
@@ -0,0 +1,105 @@
+package reputation
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// ErrZeroUnstake is returned for an unstake of nothing
+var ErrZeroUnstake = errors.New("unstake amount must be positive")
+
+// Unbonding is a withdrawal of part of a miner's own stake waiting out
+// its unbonding period. It stays slashable for offenses committed before
+// it was requested.
+type Unbonding struct {
+	Amount uint64
+
+	// RequestedAt is the height the withdrawal was requested at
+	RequestedAt uint64
+
+	// CompletesAt is the height the stake is released at, fixed when the
+	// withdrawal is requested
+	CompletesAt uint64
+}
+
+// queueUnbondingLocked moves amount of a miner's available stake into its
+// unbonding queue, which is kept ordered by completion height
+func (sm *SlashingManager) queueUnbondingLocked(stake *StakeInfo, amount, height uint64) {
+	stake.AvailableStake -= amount
+	stake.LockedStake += amount
+	stake.Unbonding = append(stake.Unbonding, &Unbonding{
+		Amount:      amount,
+		RequestedAt: height,
+		CompletesAt: height + sm.config.UnbondingPeriod,
+	})
+	sort.SliceStable(stake.Unbonding, func(i, j int) bool {
+		return stake.Unbonding[i].CompletesAt < stake.Unbonding[j].CompletesAt
+	})
+}
+
+// completeUnbonding releases the withdrawals in a stake's unbonding queue
+// that have matured by height, reporting whether any did
+func completeUnbonding(stake *StakeInfo, height uint64) bool {
+	n := 0
+	for n < len(stake.Unbonding) && stake.Unbonding[n].CompletesAt <= height {
+		// In production, this would transfer the stake to the miner
+		amount := stake.Unbonding[n].Amount
+		stake.LockedStake -= amount
+		stake.TotalStaked -= amount
+		n++
+	}
+	stake.Unbonding = stake.Unbonding[n:]
+	return n > 0
+}
+
+// releaseUnbondingLocked completes the matured withdrawals of every cached
+// stake and saves the stakes that changed
+func (sm *SlashingManager) releaseUnbondingLocked(ctx context.Context, height uint64) error {
+	for _, stake := range sm.stakes {
+		if !completeUnbonding(stake, height) {
+			continue
+		}
+		if err := sm.store.SaveStake(ctx, stake); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// slashUnbonding slashes at rate the withdrawals requested after an
+// offense at height, returning the total taken. Withdrawals requested
+// before the offense were no longer bonded when it was committed.
+func slashUnbonding(stake *StakeInfo, offense uint64, rate float64) uint64 {
+	var total uint64
+	for _, u := range stake.Unbonding {
+		if u.RequestedAt <= offense {
+			continue
+		}
+		cut := uint64(float64(u.Amount) * rate)
+		u.Amount -= cut
+		stake.LockedStake -= cut
+		total += cut
+	}
+	return total
+}
+
+// Unbonding returns copies of a miner's pending withdrawals, in
+// completion order
+func (sm *SlashingManager) Unbonding(addr types.Address) []*Unbonding {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	stake, exists := sm.stakes[addr]
+	if !exists {
+		return nil
+	}
+	out := make([]*Unbonding, len(stake.Unbonding))
+	for i, u := range stake.Unbonding {
+		copied := *u
+		out[i] = &copied
+	}
+	return out
+}
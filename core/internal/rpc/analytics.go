@@ -0,0 +1,169 @@
+package rpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ccoin/core/internal/storage"
+)
+
+// Series limits
+const (
+	// DefaultMaxPoints is the series length when a request leaves it unset
+	DefaultMaxPoints = 500
+
+	// MaxSeriesPoints caps the series length of any analytics response
+	MaxSeriesPoints = 5000
+
+	// DefaultHistogramBins and MaxHistogramBins bound reputation histograms
+	DefaultHistogramBins = 10
+	MaxHistogramBins     = 100
+)
+
+// SeriesStep returns the sampling step that fits [from, to] into at most
+// maxPoints points
+func SeriesStep(from, to uint64, maxPoints int) uint64 {
+	if maxPoints <= 0 {
+		maxPoints = DefaultMaxPoints
+	}
+	if maxPoints > MaxSeriesPoints {
+		maxPoints = MaxSeriesPoints
+	}
+
+	span := to - from + 1
+	step := span / uint64(maxPoints)
+	if span%uint64(maxPoints) != 0 {
+		step++
+	}
+	if step == 0 {
+		step = 1
+	}
+	return step
+}
+
+// GetDifficultyHistory returns difficulty sampled over a height range
+func (s *Server) GetDifficultyHistory(ctx context.Context, req *GetDifficultyHistoryRequest) (*GetDifficultyHistoryResponse, error) {
+	if s.backends.Analytics == nil {
+		return nil, status.Error(codes.Unimplemented, "analytics not enabled")
+	}
+
+	to := req.ToHeight
+	if to == 0 && s.backends.DAG != nil {
+		to = s.backends.DAG.GetHeight()
+	}
+	if to < req.FromHeight {
+		return nil, status.Error(codes.InvalidArgument, "to_height is before from_height")
+	}
+
+	step := SeriesStep(req.FromHeight, to, req.MaxPoints)
+	points, err := s.backends.Analytics.DifficultyHistory(ctx, req.FromHeight, to, step)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &GetDifficultyHistoryResponse{Step: step, Points: make([]DifficultyPoint, len(points))}
+	for i, p := range points {
+		resp.Points[i] = DifficultyPoint{
+			Height:     p.Height,
+			Timestamp:  p.Timestamp,
+			Difficulty: p.Difficulty.String(),
+		}
+	}
+	return resp, nil
+}
+
+// GetQualityHistory returns average quality per epoch bucket
+func (s *Server) GetQualityHistory(ctx context.Context, req *GetQualityHistoryRequest) (*GetQualityHistoryResponse, error) {
+	if s.backends.Analytics == nil {
+		return nil, status.Error(codes.Unimplemented, "analytics not enabled")
+	}
+
+	from, to, step, err := s.epochRange(&req.EpochRangeRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	points, err := s.backends.Analytics.QualityHistory(ctx, from, to, step)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &GetQualityHistoryResponse{Step: step, Points: make([]QualityPoint, len(points))}
+	for i, p := range points {
+		resp.Points[i] = QualityPoint(p)
+	}
+	return resp, nil
+}
+
+// GetReputationHistogram returns miner reputation distributions per epoch bucket
+func (s *Server) GetReputationHistogram(ctx context.Context, req *GetReputationHistogramRequest) (*GetReputationHistogramResponse, error) {
+	if s.backends.Analytics == nil {
+		return nil, status.Error(codes.Unimplemented, "analytics not enabled")
+	}
+
+	from, to, step, err := s.epochRange(&req.EpochRangeRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	bins := req.Bins
+	if bins <= 0 {
+		bins = DefaultHistogramBins
+	}
+	if bins > MaxHistogramBins {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d bins", MaxHistogramBins)
+	}
+
+	histograms, err := s.backends.Analytics.ReputationHistograms(ctx, from, to, step, bins)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &GetReputationHistogramResponse{
+		Step:       step,
+		BinEdges:   storage.ReputationBinEdges(bins),
+		Histograms: make([]ReputationHistogram, len(histograms)),
+	}
+	for i, h := range histograms {
+		resp.Histograms[i] = ReputationHistogram(h)
+	}
+	return resp, nil
+}
+
+// GetStakingHistory returns staking totals per epoch bucket
+func (s *Server) GetStakingHistory(ctx context.Context, req *GetStakingHistoryRequest) (*GetStakingHistoryResponse, error) {
+	if s.backends.Analytics == nil {
+		return nil, status.Error(codes.Unimplemented, "analytics not enabled")
+	}
+
+	from, to, step, err := s.epochRange(&req.EpochRangeRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	points, err := s.backends.Analytics.StakingHistory(ctx, from, to, step)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &GetStakingHistoryResponse{Step: step, Points: make([]StakingPoint, len(points))}
+	for i, p := range points {
+		resp.Points[i] = StakingPoint(p)
+	}
+	return resp, nil
+}
+
+// epochRange resolves an epoch range request against the current epoch
+func (s *Server) epochRange(req *EpochRangeRequest) (from, to, step uint64, err error) {
+	to = req.ToEpoch
+	if to == 0 && s.backends.DAG != nil {
+		to = s.backends.DAG.GetEpoch()
+	}
+	if to < req.FromEpoch {
+		return 0, 0, 0, status.Error(codes.InvalidArgument, "to_epoch is before from_epoch")
+	}
+
+	return req.FromEpoch, to, SeriesStep(req.FromEpoch, to, req.MaxPoints), nil
+}
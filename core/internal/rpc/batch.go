@@ -0,0 +1,208 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ccoin/core/internal/wallet"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// MaxBatchWithdrawals bounds the withdrawals in one SendBatch request
+const MaxBatchWithdrawals = 1000
+
+// batchChunk is one transaction of a batch
+type batchChunk struct {
+	requestID string
+	payments  []payment
+	ids       []string
+
+	notes  []*wallet.Note
+	change uint64
+	spent  []types.Hash
+
+	tx  *types.Transaction
+	err error
+}
+
+// SendBatch pays many withdrawals from wallet notes. Withdrawals are
+// packed into as few transactions as the transaction circuit's output
+// count allows, and their proofs are generated in parallel. Each
+// withdrawal is reported with the transaction and output index paying it.
+func (s *Server) SendBatch(ctx context.Context, req *SendBatchRequest) (*SendBatchResponse, error) {
+	w := s.backends.Wallet
+	if w == nil || s.backends.Shielded == nil || s.backends.Circuits == nil {
+		return nil, status.Error(codes.Unimplemented, "shielded sends not enabled")
+	}
+	if s.backends.Mempool == nil {
+		return nil, status.Error(codes.Unimplemented, "mempool not available")
+	}
+	if len(req.Withdrawals) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no withdrawals")
+	}
+	if len(req.Withdrawals) > MaxBatchWithdrawals {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d withdrawals per batch", MaxBatchWithdrawals)
+	}
+
+	_, outputs, err := s.backends.Circuits.TransactionShape()
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	chunks, err := planBatch(req, outputs)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Notes are reserved one transaction at a time so later chunks never
+	// select notes already promised to earlier ones
+	for _, c := range chunks {
+		if s.lookupBatchChunk(c) {
+			continue
+		}
+		c.notes, c.change, c.spent, c.err = selectNotes(w, c.payments, req.FeePerTx)
+	}
+
+	// One proof per chunk, in parallel
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, runtime.NumCPU())
+	for _, c := range chunks {
+		if c.notes == nil || c.err != nil {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(c *batchChunk) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			c.tx, c.err = s.proveSend(ctx, w, c.notes, c.change, c.payments, req.FeePerTx, nil, nil)
+			if c.err != nil {
+				w.Release(c.spent...)
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	resp := &SendBatchResponse{}
+	for _, c := range chunks {
+		resp.add(s.submitBatchChunk(w, c))
+	}
+	return resp, nil
+}
+
+// planBatch validates the withdrawals and splits them into transactions.
+// One output of each transaction is kept for change.
+func planBatch(req *SendBatchRequest, outputs int) ([]*batchChunk, error) {
+	perTx := outputs - 1
+	if perTx < 1 {
+		perTx = 1
+	}
+
+	seen := make(map[string]bool, len(req.Withdrawals))
+	var chunks []*batchChunk
+	for i, wd := range req.Withdrawals {
+		if wd.ID == "" || seen[wd.ID] {
+			return nil, fmt.Errorf("withdrawal %d: missing or duplicate id", i)
+		}
+		seen[wd.ID] = true
+
+		to, key, err := parsePaymentAddress(wd.To)
+		if err != nil {
+			return nil, fmt.Errorf("withdrawal %s: %w", wd.ID, err)
+		}
+		if wd.Amount == 0 {
+			return nil, fmt.Errorf("withdrawal %s: amount must be positive", wd.ID)
+		}
+		memo, err := requestMemo(wd.Memo, wd.StructuredMemo)
+		if err != nil {
+			return nil, fmt.Errorf("withdrawal %s: %w", wd.ID, err)
+		}
+
+		if len(chunks) == 0 || len(chunks[len(chunks)-1].payments) == perTx {
+			c := &batchChunk{}
+			if req.BatchID != "" {
+				c.requestID = fmt.Sprintf("%s/%d", req.BatchID, len(chunks))
+			}
+			chunks = append(chunks, c)
+		}
+		c := chunks[len(chunks)-1]
+
+		if wd.Amount > math.MaxUint64-req.FeePerTx || sumPayments(c.payments) > math.MaxUint64-req.FeePerTx-wd.Amount {
+			return nil, fmt.Errorf("withdrawal %s: amount overflows", wd.ID)
+		}
+		c.payments = append(c.payments, payment{to: to, key: key, amount: wd.Amount, memo: memo})
+		c.ids = append(c.ids, wd.ID)
+	}
+	return chunks, nil
+}
+
+// lookupBatchChunk reports whether an earlier attempt of the same batch
+// already submitted the chunk, recording that attempt's outcome
+func (s *Server) lookupBatchChunk(c *batchChunk) bool {
+	if c.requestID == "" {
+		return false
+	}
+	res, ok := s.backends.Mempool.LookupSubmission(c.requestID)
+	if !ok {
+		return false
+	}
+	if res.Err != nil {
+		c.err = res.Err
+		return true
+	}
+	c.tx = &types.Transaction{TxHash: res.TxHash}
+	c.err = errDuplicateChunk
+	return true
+}
+
+// errDuplicateChunk marks a chunk already admitted by an earlier attempt
+var errDuplicateChunk = errors.New("duplicate")
+
+// submitBatchChunk admits and gossips a proven chunk
+func (s *Server) submitBatchChunk(w WalletBackend, c *batchChunk) *BatchTransaction {
+	bt := &BatchTransaction{}
+
+	switch {
+	case errors.Is(c.err, errDuplicateChunk):
+		bt.TxHash = c.tx.TxHash.String()
+		bt.Status = SendStatusDuplicate
+	case c.err != nil:
+		bt.Error = c.err.Error()
+	default:
+		txHash, err := s.backends.Mempool.Submit(c.requestID, c.tx)
+		if err != nil {
+			w.Release(c.spent...)
+			bt.Error = err.Error()
+			break
+		}
+		if err := w.MarkSpent(c.spent...); err != nil {
+			fmt.Printf("Warning: failed to mark notes spent for %s: %v\n", txHash, err)
+		}
+		bt.TxHash = txHash.String()
+		bt.Status = SendStatusAccepted
+		bt.Broadcast, bt.BroadcastError = s.broadcast(c.tx)
+	}
+
+	for i, id := range c.ids {
+		bt.Outputs = append(bt.Outputs, BatchOutput{ID: id, TxHash: bt.TxHash, OutputIndex: uint32(i)})
+	}
+	return bt
+}
+
+// add records a chunk's outcome and maps its withdrawals
+func (r *SendBatchResponse) add(bt *BatchTransaction) {
+	r.Transactions = append(r.Transactions, bt)
+	for _, out := range bt.Outputs {
+		if bt.Error != "" {
+			r.Failed = append(r.Failed, BatchFailure{ID: out.ID, Error: bt.Error})
+			continue
+		}
+		r.Outputs = append(r.Outputs, out)
+	}
+}
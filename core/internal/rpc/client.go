@@ -0,0 +1,463 @@
+package rpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Client is a gRPC client for the node API
+type Client struct {
+	conn *grpc.ClientConn
+}
+
+// Dial connects to a node's RPC server
+func Dial(ctx context.Context, addr string) (*Client, error) {
+	conn, err := grpc.DialContext(ctx, addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(jsonCodec{})),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{conn: conn}, nil
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// invoke performs a unary call
+func (c *Client) invoke(ctx context.Context, service, method string, req, resp interface{}) error {
+	return c.conn.Invoke(ctx, "/"+service+"/"+method, req, resp)
+}
+
+// GetStatus returns the node status
+func (c *Client) GetStatus(ctx context.Context) (*GetStatusResponse, error) {
+	resp := &GetStatusResponse{}
+	if err := c.invoke(ctx, NodeServiceName, "GetStatus", &GetStatusRequest{}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetSupply returns coin supply figures
+func (c *Client) GetSupply(ctx context.Context) (*GetSupplyResponse, error) {
+	resp := &GetSupplyResponse{}
+	if err := c.invoke(ctx, NodeServiceName, "GetSupply", &GetSupplyRequest{}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetState returns the chain state at height, 0 for the current height,
+// with miner's account if miner is set
+func (c *Client) GetState(ctx context.Context, height uint64, miner string) (*GetStateResponse, error) {
+	resp := &GetStateResponse{}
+	if err := c.invoke(ctx, NodeServiceName, "GetState", &GetStateRequest{Height: height, Miner: miner}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// CaptureDiagnostics asks the node to record a diagnostics bundle
+func (c *Client) CaptureDiagnostics(ctx context.Context, seconds int) (*CaptureDiagnosticsResponse, error) {
+	resp := &CaptureDiagnosticsResponse{}
+	if err := c.invoke(ctx, NodeServiceName, "CaptureDiagnostics", &CaptureDiagnosticsRequest{Seconds: seconds}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetPeers returns the node's connected peers
+func (c *Client) GetPeers(ctx context.Context, verbose bool) (*GetPeersResponse, error) {
+	resp := &GetPeersResponse{}
+	if err := c.invoke(ctx, NodeServiceName, "GetPeers", &GetPeersRequest{Verbose: verbose}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetNetInfo returns the node's peers by direction and by network
+func (c *Client) GetNetInfo(ctx context.Context) (*GetNetInfoResponse, error) {
+	resp := &GetNetInfoResponse{}
+	if err := c.invoke(ctx, NodeServiceName, "GetNetInfo", &GetNetInfoRequest{}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetReachability returns the node's NAT traversal status
+func (c *Client) GetReachability(ctx context.Context) (*GetReachabilityResponse, error) {
+	resp := &GetReachabilityResponse{}
+	if err := c.invoke(ctx, NodeServiceName, "GetReachability", &GetReachabilityRequest{}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ListBans returns the node's peer bans
+func (c *Client) ListBans(ctx context.Context) (*ListBansResponse, error) {
+	resp := &ListBansResponse{}
+	if err := c.invoke(ctx, NodeServiceName, "ListBans", &ListBansRequest{}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// AddBans bans peers, addresses or subnets on the node
+func (c *Client) AddBans(ctx context.Context, bans []BanEntry) (*AddBansResponse, error) {
+	resp := &AddBansResponse{}
+	if err := c.invoke(ctx, NodeServiceName, "AddBans", &AddBansRequest{Bans: bans}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// RemoveBan lifts a ban on the node
+func (c *Client) RemoveBan(ctx context.Context, target string) (*RemoveBanResponse, error) {
+	resp := &RemoveBanResponse{}
+	if err := c.invoke(ctx, NodeServiceName, "RemoveBan", &RemoveBanRequest{Target: target}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetBlock returns a block by hex hash
+func (c *Client) GetBlock(ctx context.Context, hash string) (*GetBlockResponse, error) {
+	resp := &GetBlockResponse{}
+	if err := c.invoke(ctx, DAGServiceName, "GetBlock", &GetBlockRequest{Hash: hash}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetTips returns the current DAG tips
+func (c *Client) GetTips(ctx context.Context) (*GetTipsResponse, error) {
+	resp := &GetTipsResponse{}
+	if err := c.invoke(ctx, DAGServiceName, "GetTips", &GetTipsRequest{}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// AuditCommittee returns the selection record of a committee and whether
+// it re-verifies
+func (c *Client) AuditCommittee(ctx context.Context, kind string, epoch uint64) (*AuditCommitteeResponse, error) {
+	resp := &AuditCommitteeResponse{}
+	req := &AuditCommitteeRequest{Kind: kind, Epoch: epoch}
+	if err := c.invoke(ctx, DAGServiceName, "AuditCommittee", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// SubmitTransaction submits a transaction to the node's mempool
+func (c *Client) SubmitTransaction(ctx context.Context, req *SubmitTransactionRequest) (*SubmitTransactionResponse, error) {
+	resp := &SubmitTransactionResponse{}
+	if err := c.invoke(ctx, TxServiceName, "SubmitTransaction", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetTransaction returns a transaction by hex hash
+func (c *Client) GetTransaction(ctx context.Context, txHash string) (*GetTransactionResponse, error) {
+	resp := &GetTransactionResponse{}
+	if err := c.invoke(ctx, TxServiceName, "GetTransaction", &GetTransactionRequest{TxHash: txHash}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetReceipt returns the receipt of an included transaction
+func (c *Client) GetReceipt(ctx context.Context, txHash string) (*GetReceiptResponse, error) {
+	resp := &GetReceiptResponse{}
+	if err := c.invoke(ctx, TxServiceName, "GetReceipt", &GetReceiptRequest{TxHash: txHash}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetBlockReceipts returns the receipts of a block's transactions
+func (c *Client) GetBlockReceipts(ctx context.Context, hash string) (*GetBlockReceiptsResponse, error) {
+	resp := &GetBlockReceiptsResponse{}
+	if err := c.invoke(ctx, DAGServiceName, "GetBlockReceipts", &GetBlockReceiptsRequest{Hash: hash}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetBalance returns the wallet balance
+func (c *Client) GetBalance(ctx context.Context) (*GetBalanceResponse, error) {
+	resp := &GetBalanceResponse{}
+	if err := c.invoke(ctx, WalletServiceName, "GetBalance", &GetBalanceRequest{}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ListAddresses returns the wallet's addresses
+func (c *Client) ListAddresses(ctx context.Context) (*ListAddressesResponse, error) {
+	resp := &ListAddressesResponse{}
+	if err := c.invoke(ctx, WalletServiceName, "ListAddresses", &ListAddressesRequest{}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// UnlockWallet unlocks the node wallet (seconds = 0 keeps it unlocked)
+func (c *Client) UnlockWallet(ctx context.Context, password string, seconds int) (*UnlockWalletResponse, error) {
+	resp := &UnlockWalletResponse{}
+	req := &UnlockWalletRequest{Password: password, Seconds: seconds}
+	if err := c.invoke(ctx, WalletServiceName, "UnlockWallet", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// NewAddress derives a new wallet address
+func (c *Client) NewAddress(ctx context.Context, shielded bool) (*NewAddressResponse, error) {
+	resp := &NewAddressResponse{}
+	if err := c.invoke(ctx, WalletServiceName, "NewAddress", &NewAddressRequest{Shielded: shielded}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// DisclosePayment exports a payment disclosure for a wallet output;
+// blockHash locates a confirmed transaction
+func (c *Client) DisclosePayment(ctx context.Context, txHash string, outputIndex uint32, blockHash string) (*DisclosePaymentResponse, error) {
+	resp := &DisclosePaymentResponse{}
+	req := &DisclosePaymentRequest{TxHash: txHash, OutputIndex: outputIndex, BlockHash: blockHash}
+	if err := c.invoke(ctx, WalletServiceName, "DisclosePayment", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ListPayments returns the payments the wallet received matching req
+func (c *Client) ListPayments(ctx context.Context, req *ListPaymentsRequest) (*ListPaymentsResponse, error) {
+	resp := &ListPaymentsResponse{}
+	if err := c.invoke(ctx, WalletServiceName, "ListPayments", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetDifficultyHistory returns a difficulty series
+func (c *Client) GetDifficultyHistory(ctx context.Context, req *GetDifficultyHistoryRequest) (*GetDifficultyHistoryResponse, error) {
+	resp := &GetDifficultyHistoryResponse{}
+	if err := c.invoke(ctx, AnalyticsServiceName, "GetDifficultyHistory", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetQualityHistory returns an average quality series
+func (c *Client) GetQualityHistory(ctx context.Context, req *GetQualityHistoryRequest) (*GetQualityHistoryResponse, error) {
+	resp := &GetQualityHistoryResponse{}
+	if err := c.invoke(ctx, AnalyticsServiceName, "GetQualityHistory", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetReputationHistogram returns reputation histograms
+func (c *Client) GetReputationHistogram(ctx context.Context, req *GetReputationHistogramRequest) (*GetReputationHistogramResponse, error) {
+	resp := &GetReputationHistogramResponse{}
+	if err := c.invoke(ctx, AnalyticsServiceName, "GetReputationHistogram", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetStakingHistory returns a staking totals series
+func (c *Client) GetStakingHistory(ctx context.Context, req *GetStakingHistoryRequest) (*GetStakingHistoryResponse, error) {
+	resp := &GetStakingHistoryResponse{}
+	if err := c.invoke(ctx, AnalyticsServiceName, "GetStakingHistory", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// SendTransaction pays from the node wallet
+func (c *Client) SendTransaction(ctx context.Context, req *SendTransactionRequest) (*SendTransactionResponse, error) {
+	resp := &SendTransactionResponse{}
+	if err := c.invoke(ctx, TxServiceName, "SendTransaction", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// SendBatch pays many withdrawals from the node wallet
+func (c *Client) SendBatch(ctx context.Context, req *SendBatchRequest) (*SendBatchResponse, error) {
+	resp := &SendBatchResponse{}
+	if err := c.invoke(ctx, TxServiceName, "SendBatch", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// VerifyPaymentDisclosure checks a payment disclosure; blockHash locates
+// a confirmed transaction
+func (c *Client) VerifyPaymentDisclosure(ctx context.Context, disclosure, blockHash string) (*VerifyPaymentDisclosureResponse, error) {
+	resp := &VerifyPaymentDisclosureResponse{}
+	req := &VerifyPaymentDisclosureRequest{Disclosure: disclosure, BlockHash: blockHash}
+	if err := c.invoke(ctx, TxServiceName, "VerifyPaymentDisclosure", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ExplainDisclosures returns the disclosures the node's policy requires of
+// a planned transaction, and why
+func (c *Client) ExplainDisclosures(ctx context.Context, req *ExplainDisclosuresRequest) (*ExplainDisclosuresResponse, error) {
+	resp := &ExplainDisclosuresResponse{}
+	if err := c.invoke(ctx, TxServiceName, "ExplainDisclosures", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// EstimateFee suggests a fee for confirmation within targetBlocks; gas 0
+// assumes a standard shielded send
+func (c *Client) EstimateFee(ctx context.Context, targetBlocks int, gas uint64) (*EstimateFeeResponse, error) {
+	resp := &EstimateFeeResponse{}
+	req := &EstimateFeeRequest{TargetBlocks: targetBlocks, Gas: gas}
+	if err := c.invoke(ctx, TxServiceName, "EstimateFee", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetVoterHistory returns every vote cast by addr
+func (c *Client) GetVoterHistory(ctx context.Context, addr string) (*GetVoterHistoryResponse, error) {
+	resp := &GetVoterHistoryResponse{}
+	if err := c.invoke(ctx, GovernanceServiceName, "GetVoterHistory", &GetVoterHistoryRequest{Address: addr}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetProposerHistory returns every proposal submitted by addr
+func (c *Client) GetProposerHistory(ctx context.Context, addr string) (*GetProposerHistoryResponse, error) {
+	resp := &GetProposerHistoryResponse{}
+	if err := c.invoke(ctx, GovernanceServiceName, "GetProposerHistory", &GetProposerHistoryRequest{Address: addr}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// PreviewParameterChange simulates an economic parameter proposal, or a
+// change not yet proposed when proposalID is empty
+func (c *Client) PreviewParameterChange(ctx context.Context, proposalID string, change *EconomicChange) (*PreviewParameterChangeResponse, error) {
+	resp := &PreviewParameterChangeResponse{}
+	req := &PreviewParameterChangeRequest{ProposalID: proposalID, Change: change}
+	if err := c.invoke(ctx, GovernanceServiceName, "PreviewParameterChange", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// StartMining starts block production on the node
+func (c *Client) StartMining(ctx context.Context) (*MiningStatusResponse, error) {
+	resp := &MiningStatusResponse{}
+	if err := c.invoke(ctx, MinerServiceName, "StartMining", &StartMiningRequest{}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// StopMining stops block production on the node
+func (c *Client) StopMining(ctx context.Context) (*MiningStatusResponse, error) {
+	resp := &MiningStatusResponse{}
+	if err := c.invoke(ctx, MinerServiceName, "StopMining", &StopMiningRequest{}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetMiningStatus returns the node miner's state
+func (c *Client) GetMiningStatus(ctx context.Context) (*MiningStatusResponse, error) {
+	resp := &MiningStatusResponse{}
+	if err := c.invoke(ctx, MinerServiceName, "GetMiningStatus", &GetMiningStatusRequest{}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetDelegations returns the delegations to miner, or by delegator if
+// miner is empty
+func (c *Client) GetDelegations(ctx context.Context, miner, delegator string) (*GetDelegationsResponse, error) {
+	resp := &GetDelegationsResponse{}
+	req := &GetDelegationsRequest{Miner: miner, Delegator: delegator}
+	if err := c.invoke(ctx, MinerServiceName, "GetDelegations", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetBlockTemplate returns the node's block template. With the long-poll
+// ID of a template already held, it returns once the template changes.
+func (c *Client) GetBlockTemplate(ctx context.Context, longPollID string) (*BlockTemplateResponse, error) {
+	resp := &BlockTemplateResponse{}
+	req := &GetBlockTemplateRequest{LongPollID: longPollID}
+	if err := c.invoke(ctx, MinerServiceName, "GetBlockTemplate", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// MiningNotifications calls fn with each block template change until ctx
+// is done or fn returns an error
+func (c *Client) MiningNotifications(ctx context.Context, fn func(*MiningNotification) error) error {
+	desc := &grpc.StreamDesc{StreamName: "MiningNotifications", ServerStreams: true}
+	stream, err := c.conn.NewStream(ctx, desc, "/"+MinerServiceName+"/MiningNotifications")
+	if err != nil {
+		return err
+	}
+	if err := stream.SendMsg(&MiningNotificationsRequest{}); err != nil {
+		return err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+
+	for {
+		n := &MiningNotification{}
+		if err := stream.RecvMsg(n); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if err := fn(n); err != nil {
+			return err
+		}
+	}
+}
+
+// GetModel returns a registry entry and its version chain
+func (c *Client) GetModel(ctx context.Context, modelID string, version uint32) (*GetModelResponse, error) {
+	resp := &GetModelResponse{}
+	req := &GetModelRequest{ModelID: modelID, Version: version}
+	if err := c.invoke(ctx, ModelServiceName, "GetModel", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetModelProof returns a proof of a model's registry checkpoint at an
+// epoch, or at the latest checkpoint if epoch is zero
+func (c *Client) GetModelProof(ctx context.Context, modelID string, epoch uint64) (*GetModelProofResponse, error) {
+	resp := &GetModelProofResponse{}
+	req := &GetModelProofRequest{ModelID: modelID, Epoch: epoch}
+	if err := c.invoke(ctx, ModelServiceName, "GetModelProof", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,28 @@
+// Package rpc implements the node's gRPC API.
+package rpc
+
+import (
+	"encoding/json"
+)
+
+// CodecName is the gRPC content-subtype used by the node API
+const CodecName = "json"
+
+// jsonCodec encodes gRPC messages as JSON. The API messages are plain Go
+// structs, so no generated protobuf code is required on either side.
+type jsonCodec struct{}
+
+// Marshal implements encoding.Codec
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal implements encoding.Codec
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// Name implements encoding.Codec
+func (jsonCodec) Name() string {
+	return CodecName
+}
@@ -0,0 +1,81 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ccoin/core/internal/committee"
+	"github.com/ccoin/core/pkg/common"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// AuditCommittee returns the recorded selection of a committee with the
+// outcome of re-deriving its beacon and re-running the draw, and counts
+// the blocks that committed to it on-chain
+func (s *Server) AuditCommittee(ctx context.Context, req *AuditCommitteeRequest) (*AuditCommitteeResponse, error) {
+	if s.backends.Committees == nil {
+		return nil, status.Error(codes.Unimplemented, "committee log not available")
+	}
+
+	kind, ok := types.ParseCommitteeKind(req.Kind)
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "unknown committee kind")
+	}
+
+	audit, err := s.backends.Committees.Audit(ctx, kind, req.Epoch)
+	if err != nil {
+		if errors.Is(err, committee.ErrUnknownCommittee) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	record := audit.Record
+
+	resp := &AuditCommitteeResponse{
+		Kind:           record.Kind.String(),
+		Epoch:          record.Epoch,
+		Beacon:         record.Beacon.String(),
+		Size:           record.Size,
+		Candidates:     make([]CommitteeCandidateEntry, len(record.Candidates)),
+		Members:        make([]string, len(record.Members)),
+		RecordHash:     audit.RecordHash.String(),
+		Root:           audit.Root.String(),
+		BeaconValid:    audit.BeaconValid,
+		SelectionValid: audit.SelectionValid,
+	}
+
+	seats := make(map[types.Address]int, len(record.Members))
+	for i, m := range record.Members {
+		seats[m] = i
+		resp.Members[i] = common.BytesToHex(m[:])
+	}
+	for i, c := range record.Candidates {
+		seat, selected := seats[c.Address]
+		if !selected {
+			seat = -1
+		}
+		resp.Candidates[i] = CommitteeCandidateEntry{
+			Address:    common.BytesToHex(c.Address[:]),
+			Stake:      c.Stake,
+			Reputation: c.Reputation,
+			Seat:       seat,
+		}
+	}
+
+	if s.backends.DAG != nil && record.Epoch > 0 {
+		headers, err := s.backends.DAG.GetHeadersAtHeight(ctx, record.Epoch*types.EpochLength)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		for _, h := range headers {
+			if h.CommitteeRoot == audit.Root {
+				resp.CommittedBlocks++
+			}
+		}
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,164 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ccoin/core/internal/wallet"
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/common"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// DisclosePayment exports a payment disclosure for an output the wallet
+// sent, which an auditor can check with VerifyPaymentDisclosure
+func (s *Server) DisclosePayment(ctx context.Context, req *DisclosePaymentRequest) (*DisclosePaymentResponse, error) {
+	w := s.backends.Wallet
+	if w == nil {
+		return nil, status.Error(codes.Unimplemented, "wallet not enabled")
+	}
+
+	hash, err := parseHash(req.TxHash)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	tx, err := s.findTransaction(ctx, hash, req.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := w.DisclosePayment(tx, int(req.OutputIndex))
+	if err != nil {
+		switch {
+		case errors.Is(err, wallet.ErrLocked), errors.Is(err, wallet.ErrNotSentByWallet):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		default:
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return &DisclosePaymentResponse{
+		Disclosure: common.BytesToHex(d.Encode()),
+		Value:      d.Value,
+		Recipient:  common.BytesToHex(d.Recipient[:]),
+	}, nil
+}
+
+// VerifyPaymentDisclosure checks an exported payment disclosure against
+// the transaction it names
+func (s *Server) VerifyPaymentDisclosure(ctx context.Context, req *VerifyPaymentDisclosureRequest) (*VerifyPaymentDisclosureResponse, error) {
+	data, err := common.HexToBytes(req.Disclosure)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, zkp.ErrInvalidPaymentDisclosure.Error())
+	}
+	d, err := zkp.DecodePaymentDisclosure(data)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	tx, err := s.findTransaction(ctx, d.TxHash, req.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &VerifyPaymentDisclosureResponse{
+		TxHash:      d.TxHash.String(),
+		OutputIndex: d.OutputIndex,
+		Value:       d.Value,
+		Recipient:   common.BytesToHex(d.Recipient[:]),
+	}
+	resp.Memo, resp.StructuredMemo = memoFields(d.Memo)
+	if err := zkp.VerifyPaymentDisclosure(tx, d); err != nil {
+		resp.Error = err.Error()
+		return resp, nil
+	}
+	resp.Valid = true
+	return resp, nil
+}
+
+// ExplainDisclosures evaluates the disclosure policy against a planned
+// transaction so a wallet can attach what it needs before sending
+func (s *Server) ExplainDisclosures(ctx context.Context, req *ExplainDisclosuresRequest) (*ExplainDisclosuresResponse, error) {
+	if s.backends.Policy == nil {
+		return nil, status.Error(codes.Unimplemented, "disclosure policy not available")
+	}
+
+	recipient, ok := zkp.ParseRecipientClass(req.Recipient)
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "unknown recipient class")
+	}
+	attrs := &zkp.TxAttributes{
+		MinValue:      req.MinValue,
+		MaxValue:      req.MaxValue,
+		Recipient:     recipient,
+		Jurisdictions: req.Jurisdictions,
+	}
+	if req.MaxValue == 0 {
+		attrs.MinValue, attrs.MaxValue = 0, math.MaxUint64
+	} else if req.MinValue > req.MaxValue {
+		return nil, status.Error(codes.InvalidArgument, "min_value above max_value")
+	}
+
+	attached := zkp.FlagNone
+	for _, name := range req.Attached {
+		flag, ok := zkp.ParseDisclosureFlag(name)
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown disclosure %q", name)
+		}
+		attached |= flag
+	}
+
+	e := s.backends.Policy.Explain(attrs)
+	resp := &ExplainDisclosuresResponse{
+		Network:  e.Network,
+		Required: e.Required.Names(),
+		Missing:  (e.Required &^ attached).Names(),
+		Rules:    make([]PolicyRuleResult, len(e.Rules)),
+	}
+	for i, r := range e.Rules {
+		resp.Rules[i] = PolicyRuleResult{
+			Name:    r.Rule.Name,
+			Rule:    r.Rule.String(),
+			Matched: r.Matched,
+			Reason:  r.Reason,
+			Require: r.Rule.Require.Names(),
+		}
+	}
+	return resp, nil
+}
+
+// findTransaction looks a transaction up in the given block, or in the
+// mempool when no block is given
+func (s *Server) findTransaction(ctx context.Context, hash types.Hash, blockHash string) (*types.Transaction, error) {
+	if blockHash == "" {
+		if s.backends.Mempool == nil {
+			return nil, status.Error(codes.Unimplemented, "mempool not available")
+		}
+		if tx := s.backends.Mempool.Get(hash); tx != nil {
+			return tx, nil
+		}
+		return nil, status.Error(codes.NotFound, "transaction not pending; give the block containing it")
+	}
+
+	if s.backends.DAG == nil {
+		return nil, status.Error(codes.Unimplemented, "DAG not available")
+	}
+	bh, err := parseHash(blockHash)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	block, err := s.backends.DAG.GetBlock(ctx, bh)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	for _, tx := range block.Transactions {
+		if tx.TxHash == hash {
+			return tx, nil
+		}
+	}
+	return nil, status.Error(codes.NotFound, "transaction not in block")
+}
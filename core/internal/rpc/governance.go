@@ -0,0 +1,192 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ccoin/core/internal/economics"
+	"github.com/ccoin/core/internal/governance"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// GetVoterHistory returns every vote cast by an address with the outcome
+// of each proposal, so delegates can be judged on their record
+func (s *Server) GetVoterHistory(ctx context.Context, req *GetVoterHistoryRequest) (*GetVoterHistoryResponse, error) {
+	if s.backends.Governance == nil {
+		return nil, status.Error(codes.Unimplemented, "governance not enabled")
+	}
+
+	addr, err := parseAddress(req.Address)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	records := s.backends.Governance.VoterHistory(addr)
+	resp := &GetVoterHistoryResponse{
+		Address: req.Address,
+		Votes:   make([]VoteHistoryEntry, 0, len(records)),
+	}
+	for _, r := range records {
+		entry := VoteHistoryEntry{
+			ProposalID:  r.Vote.ProposalID.String(),
+			Title:       r.Title,
+			Type:        r.Type,
+			Support:     r.Vote.Support,
+			VotePower:   r.Vote.VotePower,
+			Reason:      r.Vote.Reason,
+			CastAt:      r.Vote.CastAt,
+			Outcome:     r.Outcome.String(),
+			WithOutcome: r.WithOutcome(),
+		}
+		if r.Decided() {
+			resp.Decided++
+		}
+		if entry.WithOutcome {
+			resp.WithOutcome++
+		}
+		resp.Votes = append(resp.Votes, entry)
+	}
+
+	return resp, nil
+}
+
+// GetProposerHistory returns every proposal submitted by an address
+func (s *Server) GetProposerHistory(ctx context.Context, req *GetProposerHistoryRequest) (*GetProposerHistoryResponse, error) {
+	if s.backends.Governance == nil {
+		return nil, status.Error(codes.Unimplemented, "governance not enabled")
+	}
+
+	addr, err := parseAddress(req.Address)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	proposals := s.backends.Governance.ProposerHistory(addr)
+	resp := &GetProposerHistoryResponse{
+		Address:   req.Address,
+		Proposals: make([]ProposalHistoryEntry, 0, len(proposals)),
+	}
+	for _, p := range proposals {
+		switch p.Status {
+		case types.ProposalStatusPassed, types.ProposalStatusExecuted:
+			resp.Passed++
+		case types.ProposalStatusRejected:
+			resp.Rejected++
+		}
+		resp.Proposals = append(resp.Proposals, ProposalHistoryEntry{
+			ProposalID:     p.ProposalID.String(),
+			Title:          p.Title,
+			Type:           p.Type,
+			VotesFor:       p.VotesFor,
+			VotesAgainst:   p.VotesAgainst,
+			VotingEndBlock: p.VotingEndBlock,
+			Status:         p.Status.String(),
+			ExecutedAt:     p.ExecutedAt,
+		})
+	}
+
+	return resp, nil
+}
+
+// PreviewParameterChange simulates the epochs after an economic parameter
+// change takes effect. Voters previewing a proposal get the same hash the
+// proposer attached, so they know they are looking at the same projection.
+func (s *Server) PreviewParameterChange(ctx context.Context, req *PreviewParameterChangeRequest) (*PreviewParameterChangeResponse, error) {
+	g := s.backends.Governance
+	if g == nil {
+		return nil, status.Error(codes.Unimplemented, "governance not enabled")
+	}
+
+	var (
+		sim      *economics.Simulation
+		attached *types.EconomicParameterData
+		err      error
+	)
+	switch {
+	case req.ProposalID != "":
+		id, perr := parseHash(req.ProposalID)
+		if perr != nil {
+			return nil, status.Error(codes.InvalidArgument, perr.Error())
+		}
+		sim, attached, err = g.ProposalSimulation(id)
+
+	case req.Change != nil:
+		var height uint64
+		if s.backends.DAG != nil {
+			height = s.backends.DAG.GetHeight()
+		}
+		sim, err = g.PreviewEconomicChange(req.Change.proposalData(), height)
+
+	default:
+		return nil, status.Error(codes.InvalidArgument, "proposal_id or change required")
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, governance.ErrProposalNotFound):
+			return nil, status.Error(codes.NotFound, err.Error())
+		default:
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	baseline, err := economics.Simulate(economics.DefaultEconomicParams(), sim.Config)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &PreviewParameterChangeResponse{
+		ProposalID:     req.ProposalID,
+		StartHeight:    sim.Config.StartHeight,
+		Epochs:         sim.Config.Epochs,
+		FeesPerBlock:   sim.Config.FeesPerBlock,
+		SimulationHash: sim.Hash.String(),
+		Projections:    projectionEntries(sim.Projections),
+		Baseline:       projectionEntries(baseline.Projections),
+	}
+	if attached != nil {
+		resp.AttachedHash = attached.SimulationHash.String()
+		resp.Matches = attached.SimulationHash == sim.Hash
+	}
+	return resp, nil
+}
+
+// proposalData converts the request form of a change to proposal data
+func (c *EconomicChange) proposalData() *types.EconomicParameterData {
+	return &types.EconomicParameterData{
+		FeeMinerShare:       c.FeeMinerShare,
+		FeeBurnShare:        c.FeeBurnShare,
+		FeeTreasuryShare:    c.FeeTreasuryShare,
+		RewardMinerShare:    c.RewardMinerShare,
+		RewardStakerShare:   c.RewardStakerShare,
+		RewardTreasuryShare: c.RewardTreasuryShare,
+		RewardProposerShare: c.RewardProposerShare,
+		RewardBurnShare:     c.RewardBurnShare,
+		EmissionScale:       c.EmissionScale,
+		SimulationStart:     c.SimulationStart,
+		SimulationEpochs:    c.SimulationEpochs,
+		FeesPerBlock:        c.FeesPerBlock,
+	}
+}
+
+// projectionEntries converts simulated epochs to their RPC form
+func projectionEntries(projections []economics.EpochProjection) []EpochProjectionEntry {
+	entries := make([]EpochProjectionEntry, 0, len(projections))
+	for _, p := range projections {
+		entries = append(entries, EpochProjectionEntry{
+			Epoch:           p.Epoch,
+			StartHeight:     p.StartHeight,
+			Emission:        p.Emission,
+			Fees:            p.Fees,
+			MinerRewards:    p.MinerRewards,
+			StakerRewards:   p.StakerRewards,
+			TreasuryInflow:  p.TreasuryInflow,
+			ProposerRewards: p.ProposerRewards,
+			Burned:          p.Burned,
+			Supply:          p.Supply,
+		})
+	}
+	return entries
+}
@@ -0,0 +1,84 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ccoin/core/internal/history"
+	"github.com/ccoin/core/internal/state"
+	"github.com/ccoin/core/pkg/common"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// GetState returns the chain state after the main chain block at a
+// height, as archive nodes keep it for every height
+func (s *Server) GetState(ctx context.Context, req *GetStateRequest) (*GetStateResponse, error) {
+	height := req.Height
+	if height == 0 && s.backends.DAG != nil {
+		height = s.backends.DAG.GetHeight()
+	}
+	header, st, err := s.historicalState(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &GetStateResponse{
+		Height:         header.Height,
+		BlockHash:      header.Hash.String(),
+		StateRoot:      header.StateRoot.String(),
+		NullifierRoot:  st.NullifierRoot.String(),
+		CommitmentRoot: st.CommitmentRoot.String(),
+		Supply:         st.Supply,
+		Treasury:       st.Treasury,
+		Miners:         len(st.Miners),
+	}
+	if req.Miner != "" {
+		miner, err := parseAddress(req.Miner)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		resp.Miner = &MinerAccountEntry{Address: common.BytesToHex(miner[:])}
+		if account, exists := st.Miners[miner]; exists {
+			resp.Miner.Blocks = account.Blocks
+			resp.Miner.Rewards = account.Rewards
+			resp.Miner.Reputation = account.Reputation
+		}
+	}
+	return resp, nil
+}
+
+// historicalState returns the main chain header at height and the state
+// after it
+func (s *Server) historicalState(ctx context.Context, height uint64) (*types.BlockHeader, *state.State, error) {
+	if s.backends.History == nil {
+		return nil, nil, status.Error(codes.Unimplemented, "historical queries not available")
+	}
+	header, st, err := s.backends.History.State(ctx, height)
+	if err != nil {
+		return nil, nil, historyError(err)
+	}
+	return header, st, nil
+}
+
+// checkpoint returns the history checkpoint serving a query at height
+func (s *Server) checkpoint(height uint64) (*history.Checkpoint, error) {
+	if s.backends.History == nil {
+		return nil, status.Error(codes.Unimplemented, "historical queries not available")
+	}
+	cp, err := s.backends.History.Checkpoint(height)
+	if err != nil {
+		return nil, historyError(err)
+	}
+	return cp, nil
+}
+
+// historyError maps a failed historical query to a status
+func historyError(err error) error {
+	if errors.Is(err, history.ErrHeightAhead) || errors.Is(err, history.ErrNoCheckpoint) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,490 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	JSONRPCParseError     = -32700
+	JSONRPCInvalidRequest = -32600
+	JSONRPCMethodNotFound = -32601
+	JSONRPCInvalidParams  = -32602
+	JSONRPCInternalError  = -32603
+
+	// Server-defined errors
+	JSONRPCNotFound      = -32001
+	JSONRPCAlreadyExists = -32002
+	JSONRPCUnavailable   = -32003
+	JSONRPCRejected      = -32004
+)
+
+// maxJSONRPCBody bounds a single HTTP request body
+const maxJSONRPCBody = 16 * 1024 * 1024
+
+// jsonrpcRequest is a JSON-RPC 2.0 request
+type jsonrpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// jsonrpcResponse is a JSON-RPC 2.0 response. It has either a result,
+// null if the method returned none, or an error, never both.
+type jsonrpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result"`
+	Error   *JSONRPCError   `json:"error,omitempty"`
+}
+
+// MarshalJSON leaves the result member out of error responses
+func (r jsonrpcResponse) MarshalJSON() ([]byte, error) {
+	if r.Error == nil {
+		type response jsonrpcResponse
+		return json.Marshal(response(r))
+	}
+	return json.Marshal(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Error   *JSONRPCError   `json:"error"`
+	}{r.JSONRPC, r.ID, r.Error})
+}
+
+// JSONRPCError is a JSON-RPC 2.0 error object
+type JSONRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *JSONRPCError) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+// jsonrpcMethod handles one JSON-RPC method with positional params
+type jsonrpcMethod func(ctx context.Context, params []json.RawMessage) (interface{}, error)
+
+// jsonrpcMethods returns the method table. Every method delegates to the
+// same handler used by the gRPC services.
+func (s *Server) jsonrpcMethods() map[string]jsonrpcMethod {
+	return map[string]jsonrpcMethod{
+		"ccoin_getStatus": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			return s.GetStatus(ctx, &GetStatusRequest{})
+		},
+		"ccoin_getSupply": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &GetSupplyRequest{}
+			if err := positional(params, 0, &req.Height); err != nil {
+				return nil, err
+			}
+			return s.GetSupply(ctx, req)
+		},
+		"ccoin_getState": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &GetStateRequest{}
+			if err := positional(params, 0, &req.Height, &req.Miner); err != nil {
+				return nil, err
+			}
+			return s.GetState(ctx, req)
+		},
+		"ccoin_getPeers": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &GetPeersRequest{}
+			if err := positional(params, 0, &req.Verbose); err != nil {
+				return nil, err
+			}
+			return s.GetPeers(ctx, req)
+		},
+		"ccoin_getNetInfo": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			return s.GetNetInfo(ctx, &GetNetInfoRequest{})
+		},
+		"ccoin_getReachability": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			return s.GetReachability(ctx, &GetReachabilityRequest{})
+		},
+		"ccoin_listBans": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			return s.ListBans(ctx, &ListBansRequest{})
+		},
+		"ccoin_getTips": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			return s.GetTips(ctx, &GetTipsRequest{})
+		},
+		"ccoin_auditCommittee": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &AuditCommitteeRequest{}
+			if err := positional(params, 2, &req.Kind, &req.Epoch); err != nil {
+				return nil, err
+			}
+			return s.AuditCommittee(ctx, req)
+		},
+		"ccoin_getBlock": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &GetBlockRequest{}
+			if err := positional(params, 1, &req.Hash); err != nil {
+				return nil, err
+			}
+			resp, err := s.GetBlock(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+			return resp.Block, nil
+		},
+		"ccoin_getTransaction": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &GetTransactionRequest{}
+			if err := positional(params, 1, &req.TxHash); err != nil {
+				return nil, err
+			}
+			return s.GetTransaction(ctx, req)
+		},
+		"ccoin_getReceipt": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &GetReceiptRequest{}
+			if err := positional(params, 1, &req.TxHash); err != nil {
+				return nil, err
+			}
+			return s.GetReceipt(ctx, req)
+		},
+		"ccoin_getBlockReceipts": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &GetBlockReceiptsRequest{}
+			if err := positional(params, 1, &req.Hash); err != nil {
+				return nil, err
+			}
+			return s.GetBlockReceipts(ctx, req)
+		},
+		"ccoin_sendRawTransaction": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &SubmitTransactionRequest{Transaction: &types.Transaction{}}
+			if err := positional(params, 1, req.Transaction, &req.RequestID); err != nil {
+				return nil, err
+			}
+			resp, err := s.SubmitTransaction(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+			return resp.TxHash, nil
+		},
+		"ccoin_sendTransaction": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &SendTransactionRequest{}
+			if err := positional(params, 1, req); err != nil {
+				return nil, err
+			}
+			return s.SendTransaction(ctx, req)
+		},
+		"ccoin_sendBatch": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &SendBatchRequest{}
+			if err := positional(params, 1, req); err != nil {
+				return nil, err
+			}
+			return s.SendBatch(ctx, req)
+		},
+		"ccoin_verifyPaymentDisclosure": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &VerifyPaymentDisclosureRequest{}
+			if err := positional(params, 1, &req.Disclosure, &req.BlockHash); err != nil {
+				return nil, err
+			}
+			return s.VerifyPaymentDisclosure(ctx, req)
+		},
+		"ccoin_explainDisclosures": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &ExplainDisclosuresRequest{}
+			if err := positional(params, 1, &req.Recipient, &req.MinValue, &req.MaxValue, &req.Jurisdictions, &req.Attached); err != nil {
+				return nil, err
+			}
+			return s.ExplainDisclosures(ctx, req)
+		},
+		"ccoin_estimateFee": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &EstimateFeeRequest{}
+			if err := positional(params, 1, &req.TargetBlocks, &req.Gas); err != nil {
+				return nil, err
+			}
+			return s.EstimateFee(ctx, req)
+		},
+		"ccoin_getBalance": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			return s.GetBalance(ctx, &GetBalanceRequest{})
+		},
+		"ccoin_disclosePayment": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &DisclosePaymentRequest{}
+			if err := positional(params, 2, &req.TxHash, &req.OutputIndex, &req.BlockHash); err != nil {
+				return nil, err
+			}
+			return s.DisclosePayment(ctx, req)
+		},
+		"ccoin_listPayments": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &ListPaymentsRequest{}
+			if err := positional(params, 0, req); err != nil {
+				return nil, err
+			}
+			return s.ListPayments(ctx, req)
+		},
+		"ccoin_getDifficultyHistory": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &GetDifficultyHistoryRequest{}
+			if err := positional(params, 1, &req.FromHeight, &req.ToHeight, &req.MaxPoints); err != nil {
+				return nil, err
+			}
+			return s.GetDifficultyHistory(ctx, req)
+		},
+		"ccoin_getQualityHistory": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &GetQualityHistoryRequest{}
+			if err := positional(params, 1, &req.FromEpoch, &req.ToEpoch, &req.MaxPoints); err != nil {
+				return nil, err
+			}
+			return s.GetQualityHistory(ctx, req)
+		},
+		"ccoin_getReputationHistogram": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &GetReputationHistogramRequest{}
+			if err := positional(params, 1, &req.FromEpoch, &req.ToEpoch, &req.MaxPoints, &req.Bins); err != nil {
+				return nil, err
+			}
+			return s.GetReputationHistogram(ctx, req)
+		},
+		"ccoin_getStakingHistory": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &GetStakingHistoryRequest{}
+			if err := positional(params, 1, &req.FromEpoch, &req.ToEpoch, &req.MaxPoints); err != nil {
+				return nil, err
+			}
+			return s.GetStakingHistory(ctx, req)
+		},
+		"ccoin_getModel": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &GetModelRequest{}
+			if err := positional(params, 1, &req.ModelID, &req.Version); err != nil {
+				return nil, err
+			}
+			return s.GetModel(ctx, req)
+		},
+		"ccoin_getModelProof": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &GetModelProofRequest{}
+			if err := positional(params, 1, &req.ModelID, &req.Epoch); err != nil {
+				return nil, err
+			}
+			return s.GetModelProof(ctx, req)
+		},
+		"ccoin_getVoterHistory": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &GetVoterHistoryRequest{}
+			if err := positional(params, 1, &req.Address); err != nil {
+				return nil, err
+			}
+			return s.GetVoterHistory(ctx, req)
+		},
+		"ccoin_getProposerHistory": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &GetProposerHistoryRequest{}
+			if err := positional(params, 1, &req.Address); err != nil {
+				return nil, err
+			}
+			return s.GetProposerHistory(ctx, req)
+		},
+		"ccoin_previewParameterChange": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &PreviewParameterChangeRequest{}
+			if err := positional(params, 1, req); err != nil {
+				return nil, err
+			}
+			return s.PreviewParameterChange(ctx, req)
+		},
+		"ccoin_startMining": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			return s.StartMining(ctx, &StartMiningRequest{})
+		},
+		"ccoin_stopMining": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			return s.StopMining(ctx, &StopMiningRequest{})
+		},
+		"ccoin_getMiningStatus": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			return s.GetMiningStatus(ctx, &GetMiningStatusRequest{})
+		},
+		"ccoin_getDelegations": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &GetDelegationsRequest{}
+			if err := positional(params, 1, req); err != nil {
+				return nil, err
+			}
+			return s.GetDelegations(ctx, req)
+		},
+		"ccoin_getBlockTemplate": func(ctx context.Context, params []json.RawMessage) (interface{}, error) {
+			req := &GetBlockTemplateRequest{}
+			if err := positional(params, 0, &req.LongPollID); err != nil {
+				return nil, err
+			}
+			return s.GetBlockTemplate(ctx, req)
+		},
+	}
+}
+
+// positional decodes positional params into dst; the first `required`
+// entries must be present
+func positional(params []json.RawMessage, required int, dst ...interface{}) error {
+	if len(params) < required || len(params) > len(dst) {
+		return &JSONRPCError{
+			Code:    JSONRPCInvalidParams,
+			Message: fmt.Sprintf("expected %d to %d params, got %d", required, len(dst), len(params)),
+		}
+	}
+	for i, raw := range params {
+		if err := json.Unmarshal(raw, dst[i]); err != nil {
+			return &JSONRPCError{
+				Code:    JSONRPCInvalidParams,
+				Message: fmt.Sprintf("param %d: %v", i, err),
+			}
+		}
+	}
+	return nil
+}
+
+// startJSONRPC starts the HTTP JSON-RPC gateway
+func (s *Server) startJSONRPC() error {
+	lis, err := net.Listen("tcp", s.config.JSONRPCAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.config.JSONRPCAddr, err)
+	}
+
+	s.http = &http.Server{Handler: s.JSONRPCHandler()}
+
+	go func() {
+		if err := s.http.Serve(lis); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("JSON-RPC server error: %v\n", err)
+		}
+	}()
+
+	return nil
+}
+
+// JSONRPCHandler returns an http.Handler serving JSON-RPC 2.0 requests,
+// including batches
+func (s *Server) JSONRPCHandler() http.Handler {
+	methods := s.jsonrpcMethods()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxJSONRPCBody))
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		body = bytes.TrimSpace(body)
+		if !json.Valid(body) {
+			writeJSON(w, errorResponse(nil, JSONRPCParseError, "parse error"))
+			return
+		}
+		if body[0] == '[' {
+			var batch []json.RawMessage
+			if err := json.Unmarshal(body, &batch); err != nil || len(batch) == 0 {
+				writeJSON(w, errorResponse(nil, JSONRPCInvalidRequest, "invalid batch"))
+				return
+			}
+			// Each entry is answered on its own, malformed ones included
+			responses := make([]*jsonrpcResponse, 0, len(batch))
+			for _, raw := range batch {
+				var req jsonrpcRequest
+				if err := json.Unmarshal(raw, &req); err != nil {
+					responses = append(responses, errorResponse(nil, JSONRPCInvalidRequest, "invalid request"))
+					continue
+				}
+				if resp := s.dispatch(r.Context(), methods, &req); resp != nil {
+					responses = append(responses, resp)
+				}
+			}
+			if len(responses) == 0 {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			writeJSON(w, responses)
+			return
+		}
+
+		var req jsonrpcRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeJSON(w, errorResponse(nil, JSONRPCInvalidRequest, "invalid request"))
+			return
+		}
+		resp := s.dispatch(r.Context(), methods, &req)
+		if resp == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSON(w, resp)
+	})
+}
+
+// dispatch executes a single request; notifications (no id) return nil
+func (s *Server) dispatch(ctx context.Context, methods map[string]jsonrpcMethod, req *jsonrpcRequest) *jsonrpcResponse {
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return errorResponse(req.ID, JSONRPCInvalidRequest, "invalid request")
+	}
+
+	method, exists := methods[req.Method]
+	if !exists {
+		if req.ID == nil {
+			return nil
+		}
+		return errorResponse(req.ID, JSONRPCMethodNotFound, "method not found: "+req.Method)
+	}
+
+	var params []json.RawMessage
+	if len(req.Params) > 0 && string(req.Params) != "null" {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			if req.ID == nil {
+				return nil
+			}
+			return errorResponse(req.ID, JSONRPCInvalidParams, "params must be an array")
+		}
+	}
+
+	result, err := method(ctx, params)
+	if req.ID == nil {
+		return nil
+	}
+	if err != nil {
+		code, msg := jsonrpcErrorFor(err)
+		return errorResponse(req.ID, code, msg)
+	}
+
+	return &jsonrpcResponse{JSONRPC: "2.0", ID: req.ID, Result: result}
+}
+
+// jsonrpcErrorFor maps handler errors (gRPC status errors) to JSON-RPC codes
+func jsonrpcErrorFor(err error) (int, string) {
+	if jerr, ok := err.(*JSONRPCError); ok {
+		return jerr.Code, jerr.Message
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return JSONRPCInternalError, err.Error()
+	}
+
+	switch st.Code() {
+	case codes.InvalidArgument:
+		return JSONRPCInvalidParams, st.Message()
+	case codes.NotFound:
+		return JSONRPCNotFound, st.Message()
+	case codes.AlreadyExists:
+		return JSONRPCAlreadyExists, st.Message()
+	case codes.Unimplemented, codes.Unavailable:
+		return JSONRPCUnavailable, st.Message()
+	case codes.FailedPrecondition, codes.ResourceExhausted:
+		return JSONRPCRejected, st.Message()
+	default:
+		return JSONRPCInternalError, st.Message()
+	}
+}
+
+// errorResponse builds an error response
+func errorResponse(id json.RawMessage, code int, message string) *jsonrpcResponse {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &jsonrpcResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   &JSONRPCError{Code: code, Message: message},
+	}
+}
+
+// writeJSON writes v as the response body
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,935 @@
+package rpc
+
+import (
+	"github.com/ccoin/core/internal/state"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// ============================================================================
+// NodeService
+// ============================================================================
+
+// GetStatusRequest requests the node status
+type GetStatusRequest struct{}
+
+// GetStatusResponse describes the node status
+type GetStatusResponse struct {
+	Version      string            `json:"version"`
+	Network      string            `json:"network"`
+	Roles        []string          `json:"roles,omitempty"`
+	Height       uint64            `json:"height"`
+	Epoch        uint64            `json:"epoch"`
+	MainChainTip string            `json:"main_chain_tip"`
+	Tips         int               `json:"tips"`
+	Peers        int               `json:"peers"`
+	MempoolSize  int               `json:"mempool_size"`
+	Syncing      bool              `json:"syncing"`
+	Degraded     bool              `json:"degraded"`
+	Subsystems   []SubsystemHealth `json:"subsystems,omitempty"`
+}
+
+// GetSupplyRequest requests coin supply figures, at Height if set
+type GetSupplyRequest struct {
+	Height uint64 `json:"height,omitempty"`
+}
+
+// GetSupplyResponse returns coin supply figures in base units. At a past
+// height they are what the emission schedule had issued; burned fees are
+// not tracked by height.
+type GetSupplyResponse struct {
+	Height      uint64 `json:"height,omitempty"`
+	Circulating uint64 `json:"circulating"`
+	TotalMinted uint64 `json:"total_minted"`
+	TotalBurned uint64 `json:"total_burned"`
+}
+
+// GetStateRequest requests the chain state after the main chain block at
+// Height, 0 for the current height, with Miner's account if set
+type GetStateRequest struct {
+	Height uint64 `json:"height,omitempty"`
+	Miner  string `json:"miner,omitempty"`
+}
+
+// MinerAccountEntry is a miner's account in the chain state
+type MinerAccountEntry struct {
+	Address    string  `json:"address"`
+	Blocks     uint64  `json:"blocks"`
+	Rewards    uint64  `json:"rewards"`
+	Reputation float64 `json:"reputation"`
+}
+
+// GetStateResponse returns the chain state a block commits to
+type GetStateResponse struct {
+	Height         uint64             `json:"height"`
+	BlockHash      string             `json:"block_hash"`
+	StateRoot      string             `json:"state_root"`
+	NullifierRoot  string             `json:"nullifier_root"`
+	CommitmentRoot string             `json:"commitment_root"`
+	Supply         uint64             `json:"supply"`
+	Treasury       uint64             `json:"treasury"`
+	Miners         int                `json:"miners"`
+	Miner          *MinerAccountEntry `json:"miner,omitempty"`
+}
+
+// CaptureDiagnosticsRequest requests a diagnostics bundle
+type CaptureDiagnosticsRequest struct {
+	Seconds int `json:"seconds,omitempty"`
+}
+
+// CaptureDiagnosticsResponse returns the bundle path on the node
+type CaptureDiagnosticsResponse struct {
+	Path string `json:"path"`
+}
+
+// GetPeersRequest requests the connected peers
+type GetPeersRequest struct {
+	// Verbose includes each peer's misbehavior log
+	Verbose bool `json:"verbose,omitempty"`
+}
+
+// GetPeersResponse lists the connected peers
+type GetPeersResponse struct {
+	Peers []PeerStatus `json:"peers"`
+}
+
+// PeerStatus describes a connected peer and its protocol statistics
+type PeerStatus struct {
+	ID                  string             `json:"id"`
+	Addrs               []string           `json:"addrs"`
+	ConnectedAt         int64              `json:"connected_at"`
+	LastSeen            int64              `json:"last_seen"`
+	Version             string             `json:"version,omitempty"`
+	Roles               []string           `json:"roles,omitempty"`
+	Height              uint64             `json:"height"`
+	BlocksRelayed       uint64             `json:"blocks_relayed"`
+	InvalidMessages     uint64             `json:"invalid_messages"`
+	DuplicateMessages   uint64             `json:"duplicate_messages"`
+	BytesIn             uint64             `json:"bytes_in"`
+	BytesOut            uint64             `json:"bytes_out"`
+	AvgValidationMillis float64            `json:"avg_validation_ms"`
+	Misbehaviors        int                `json:"misbehaviors"`
+	Misbehavior         []MisbehaviorEntry `json:"misbehavior,omitempty"`
+
+	// Outbound is set for peers the node dialed, and Network is the
+	// network the peer's address is in
+	Outbound bool   `json:"outbound"`
+	Network  string `json:"network,omitempty"`
+
+	// Score is the node's score of the peer, banned at the node's
+	// threshold, and GossipScore GossipSub's, which includes it
+	Score       float64 `json:"score"`
+	GossipScore float64 `json:"gossip_score"`
+}
+
+// GetNetInfoRequest requests the node's connectivity summary
+type GetNetInfoRequest struct{}
+
+// GetNetInfoResponse breaks the connected peers down by direction and by
+// network, with warnings when they are concentrated in one network
+type GetNetInfoResponse struct {
+	Peers    int            `json:"peers"`
+	Inbound  int            `json:"inbound"`
+	Outbound int            `json:"outbound"`
+	Networks []NetworkEntry `json:"networks"`
+	Warnings []string       `json:"warnings,omitempty"`
+}
+
+// NetworkEntry counts the peers in one network
+type NetworkEntry struct {
+	Network  string `json:"network"`
+	Peers    int    `json:"peers"`
+	Outbound int    `json:"outbound"`
+}
+
+// GetReachabilityRequest requests the node's NAT traversal status
+type GetReachabilityRequest struct{}
+
+// GetReachabilityResponse reports whether peers can reach the node
+type GetReachabilityResponse struct {
+	// Reachability is "public", "private" or "unknown", as found by
+	// AutoNAT
+	Reachability string   `json:"reachability"`
+	Addrs        []string `json:"addrs"`
+	RelayAddrs   []string `json:"relay_addrs,omitempty"`
+
+	StaticRelays []string `json:"static_relays,omitempty"`
+	HolePunching bool     `json:"hole_punching"`
+	RelayService bool     `json:"relay_service"`
+
+	HolePunchesSucceeded int `json:"hole_punches_succeeded"`
+	HolePunchesFailed    int `json:"hole_punches_failed"`
+}
+
+// MisbehaviorEntry is one logged protocol violation
+type MisbehaviorEntry struct {
+	Time   int64  `json:"time"`
+	Topic  string `json:"topic"`
+	Reason string `json:"reason"`
+}
+
+// ListBansRequest requests the peer bans in force
+type ListBansRequest struct{}
+
+// ListBansResponse lists the peer bans in force
+type ListBansResponse struct {
+	Bans []BanEntry `json:"bans"`
+}
+
+// AddBansRequest bans peers, addresses or subnets
+type AddBansRequest struct {
+	Bans []BanEntry `json:"bans"`
+}
+
+// AddBansResponse reports how many bans were added
+type AddBansResponse struct {
+	Added int `json:"added"`
+}
+
+// RemoveBanRequest lifts the ban of a target
+type RemoveBanRequest struct {
+	Target string `json:"target"`
+}
+
+// RemoveBanResponse reports whether the target was banned
+type RemoveBanResponse struct {
+	Removed bool `json:"removed"`
+}
+
+// BanEntry is a ban of a peer ID, an IP address or a CIDR subnet
+type BanEntry struct {
+	Target  string `json:"target"`
+	Reason  string `json:"reason,omitempty"`
+	Source  string `json:"source,omitempty"`
+	Created int64  `json:"created,omitempty"`
+	Expires int64  `json:"expires,omitempty"` // 0 never expires
+}
+
+// SubsystemHealth is the health of a supervised subsystem
+type SubsystemHealth struct {
+	Name      string `json:"name"`
+	State     string `json:"state"`
+	Restarts  int    `json:"restarts"`
+	LastPanic string `json:"last_panic,omitempty"`
+}
+
+// ============================================================================
+// DAGService
+// ============================================================================
+
+// GetBlockRequest requests a block by hash
+type GetBlockRequest struct {
+	Hash string `json:"hash"`
+}
+
+// GetBlockResponse returns a block
+type GetBlockResponse struct {
+	Block *types.Block `json:"block"`
+}
+
+// GetBlockReceiptsRequest requests the receipts of a block's transactions
+type GetBlockReceiptsRequest struct {
+	Hash string `json:"hash"`
+}
+
+// GetBlockReceiptsResponse returns a block's receipts in transaction order
+type GetBlockReceiptsResponse struct {
+	Hash         string           `json:"hash"`
+	ReceiptsRoot string           `json:"receipts_root"`
+	Receipts     []*types.Receipt `json:"receipts"`
+}
+
+// GetTipsRequest requests the current DAG tips
+type GetTipsRequest struct{}
+
+// GetTipsResponse returns the current DAG tips
+type GetTipsResponse struct {
+	Tips         []string `json:"tips"`
+	MainChainTip string   `json:"main_chain_tip"`
+	Height       uint64   `json:"height"`
+}
+
+// AuditCommitteeRequest requests the selection record of the committee of
+// a kind (pre-confirmation, decryption or evaluator) serving at an epoch
+type AuditCommitteeRequest struct {
+	Kind  string `json:"kind"`
+	Epoch uint64 `json:"epoch"`
+}
+
+// CommitteeCandidateEntry is one entry of a selection snapshot. Seat is
+// the draw position of selected candidates and -1 otherwise.
+type CommitteeCandidateEntry struct {
+	Address    string  `json:"address"`
+	Stake      uint64  `json:"stake"`
+	Reputation float64 `json:"reputation"`
+	Seat       int     `json:"seat"`
+}
+
+// AuditCommitteeResponse returns a recorded selection and the outcome of
+// re-verifying it. CommittedBlocks counts the blocks opening the rotation
+// epoch whose header carries Root.
+type AuditCommitteeResponse struct {
+	Kind            string                    `json:"kind"`
+	Epoch           uint64                    `json:"epoch"`
+	Beacon          string                    `json:"beacon"`
+	Size            uint32                    `json:"size"`
+	Candidates      []CommitteeCandidateEntry `json:"candidates"`
+	Members         []string                  `json:"members"`
+	RecordHash      string                    `json:"record_hash"`
+	Root            string                    `json:"root"`
+	BeaconValid     bool                      `json:"beacon_valid"`
+	SelectionValid  bool                      `json:"selection_valid"`
+	CommittedBlocks int                       `json:"committed_blocks"`
+}
+
+// ============================================================================
+// TxService
+// ============================================================================
+
+// SubmitTransactionRequest submits a transaction to the mempool.
+// RequestID is an optional client idempotency key.
+type SubmitTransactionRequest struct {
+	RequestID   string             `json:"request_id,omitempty"`
+	Transaction *types.Transaction `json:"transaction"`
+}
+
+// SubmitTransactionResponse returns the accepted transaction hash
+type SubmitTransactionResponse struct {
+	TxHash string `json:"tx_hash"`
+}
+
+// SendTransactionRequest pays Amount (base units) from wallet notes to To
+type SendTransactionRequest struct {
+	RequestID string `json:"request_id,omitempty"`
+	To        string `json:"to"`
+	Amount    uint64 `json:"amount"`
+	Fee       uint64 `json:"fee"`
+	Memo      string `json:"memo,omitempty"`
+
+	// StructuredMemo replaces Memo with a typed memo
+	StructuredMemo *StructuredMemo `json:"structured_memo,omitempty"`
+
+	// Privacy hardens this send; nil sends as usual
+	Privacy *SendPrivacy `json:"privacy,omitempty"`
+}
+
+// StructuredMemo is a typed note memo that recipients can filter on.
+// Type is "payment_ref" (Reference), "license" (Subject is the model,
+// Reference the license ID) or "governance" (Subject is the proposal,
+// Text the note).
+type StructuredMemo struct {
+	Type      string `json:"type"`
+	Reference string `json:"reference,omitempty"`
+	Subject   string `json:"subject,omitempty"`
+	Text      string `json:"text,omitempty"`
+}
+
+// SendPrivacy selects wallet privacy options for one send
+type SendPrivacy struct {
+	// MaxBroadcastDelaySeconds delays gossip by a random time up to this
+	MaxBroadcastDelaySeconds uint32 `json:"max_broadcast_delay_seconds,omitempty"`
+
+	// DecoyOutputs splits change across all free output slots
+	DecoyOutputs bool `json:"decoy_outputs,omitempty"`
+
+	// RoundChange rounds change down to a multiple of this (base units),
+	// paying the remainder as fee
+	RoundChange uint64 `json:"round_change,omitempty"`
+
+	// AvoidSyncPeer relays through a peer other than the sync peer
+	AvoidSyncPeer bool `json:"avoid_sync_peer,omitempty"`
+}
+
+// SendTransactionResponse reports the mempool and gossip outcome of a
+// send. A delayed broadcast is reported by BroadcastAt (unix seconds)
+// instead of Broadcast.
+type SendTransactionResponse struct {
+	TxHash         string `json:"tx_hash"`
+	Status         string `json:"status"`
+	Broadcast      bool   `json:"broadcast"`
+	BroadcastAt    int64  `json:"broadcast_at,omitempty"`
+	RelayPeer      string `json:"relay_peer,omitempty"`
+	BroadcastError string `json:"broadcast_error,omitempty"`
+}
+
+// SendBatchRequest pays many withdrawals from wallet notes. FeePerTx is
+// paid by each transaction the batch is split into. A retried request
+// with the same BatchID reports the original transactions.
+type SendBatchRequest struct {
+	BatchID     string       `json:"batch_id,omitempty"`
+	Withdrawals []Withdrawal `json:"withdrawals"`
+	FeePerTx    uint64       `json:"fee_per_tx"`
+}
+
+// Withdrawal is one payment of a batch. ID is the caller's tracking ID.
+type Withdrawal struct {
+	ID     string `json:"id"`
+	To     string `json:"to"`
+	Amount uint64 `json:"amount"`
+	Memo   string `json:"memo,omitempty"`
+
+	StructuredMemo *StructuredMemo `json:"structured_memo,omitempty"`
+}
+
+// SendBatchResponse maps each withdrawal to the transaction output paying
+// it, or to the error that stopped it
+type SendBatchResponse struct {
+	Transactions []*BatchTransaction `json:"transactions"`
+	Outputs      []BatchOutput       `json:"outputs"`
+	Failed       []BatchFailure      `json:"failed,omitempty"`
+}
+
+// BatchTransaction reports one transaction of a batch
+type BatchTransaction struct {
+	TxHash         string        `json:"tx_hash,omitempty"`
+	Status         string        `json:"status,omitempty"`
+	Broadcast      bool          `json:"broadcast"`
+	BroadcastError string        `json:"broadcast_error,omitempty"`
+	Error          string        `json:"error,omitempty"`
+	Outputs        []BatchOutput `json:"outputs"`
+}
+
+// BatchOutput locates a withdrawal's output
+type BatchOutput struct {
+	ID          string `json:"id"`
+	TxHash      string `json:"tx_hash,omitempty"`
+	OutputIndex uint32 `json:"output_index"`
+}
+
+// BatchFailure reports a withdrawal that was not sent
+type BatchFailure struct {
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// GetTransactionRequest requests a transaction by hash
+type GetTransactionRequest struct {
+	TxHash string `json:"tx_hash"`
+}
+
+// GetTransactionResponse returns a transaction and where it was found
+type GetTransactionResponse struct {
+	Transaction *types.Transaction `json:"transaction"`
+	Pending     bool               `json:"pending"`
+}
+
+// GetReceiptRequest requests the receipt of an included transaction
+type GetReceiptRequest struct {
+	TxHash string `json:"tx_hash"`
+}
+
+// GetReceiptResponse returns a transaction's receipt and the block that
+// included it. Path proves the receipt at Index under the block header's
+// ReceiptsRoot.
+type GetReceiptResponse struct {
+	Receipt     *types.Receipt `json:"receipt"`
+	Status      string         `json:"status"`
+	BlockHash   string         `json:"block_hash"`
+	BlockHeight uint64         `json:"block_height"`
+	Index       int            `json:"index"`
+	Path        []types.Hash   `json:"path"`
+}
+
+// EstimateFeeRequest asks for a fee likely to confirm within TargetBlocks.
+// Gas defaults to a standard shielded send.
+type EstimateFeeRequest struct {
+	TargetBlocks int    `json:"target_blocks"`
+	Gas          uint64 `json:"gas,omitempty"`
+}
+
+// EstimateFeeResponse returns the suggested fee rate (per gas) and total
+// fee in base units, with the components it was derived from
+type EstimateFeeResponse struct {
+	TargetBlocks int    `json:"target_blocks"`
+	Gas          uint64 `json:"gas"`
+	Fee          uint64 `json:"fee"`
+	FeeRate      uint64 `json:"fee_rate"`
+	BaseFee      uint64 `json:"base_fee"`
+	MempoolRate  uint64 `json:"mempool_rate"`
+	HistoryRate  uint64 `json:"history_rate"`
+	MinFeeRate   uint64 `json:"min_fee_rate"`
+}
+
+// ============================================================================
+// WalletService
+// ============================================================================
+
+// GetBalanceRequest requests the wallet balance
+type GetBalanceRequest struct{}
+
+// GetBalanceResponse returns the wallet balance in base units
+type GetBalanceResponse struct {
+	Confirmed uint64 `json:"confirmed"`
+	Pending   uint64 `json:"pending"`
+	Shielded  uint64 `json:"shielded"`
+}
+
+// ListAddressesRequest requests the wallet's addresses
+type ListAddressesRequest struct{}
+
+// ListAddressesResponse returns the wallet's addresses
+type ListAddressesResponse struct {
+	Transparent []string `json:"transparent"`
+	Shielded    []string `json:"shielded"`
+}
+
+// UnlockWalletRequest unlocks the wallet, optionally only for a while
+type UnlockWalletRequest struct {
+	Password string `json:"password"`
+	Seconds  int    `json:"seconds,omitempty"`
+}
+
+// UnlockWalletResponse confirms the wallet is unlocked
+type UnlockWalletResponse struct {
+	Locked bool `json:"locked"`
+}
+
+// NewAddressRequest derives a new wallet address
+type NewAddressRequest struct {
+	Shielded bool `json:"shielded,omitempty"`
+}
+
+// NewAddressResponse returns the new address
+type NewAddressResponse struct {
+	Address string `json:"address"`
+}
+
+// DisclosePaymentRequest asks for a payment disclosure of a wallet
+// output. BlockHash locates a confirmed transaction; without it the
+// mempool is searched.
+type DisclosePaymentRequest struct {
+	TxHash      string `json:"tx_hash"`
+	OutputIndex uint32 `json:"output_index"`
+	BlockHash   string `json:"block_hash,omitempty"`
+}
+
+// DisclosePaymentResponse returns the hex-encoded disclosure
+type DisclosePaymentResponse struct {
+	Disclosure string `json:"disclosure"`
+	Value      uint64 `json:"value"`
+	Recipient  string `json:"recipient"`
+}
+
+// ListPaymentsRequest filters the notes the wallet received. Empty fields
+// match everything; MemoType, Reference and Subject look inside memos.
+type ListPaymentsRequest struct {
+	Address      string `json:"address,omitempty"`
+	FromHeight   uint64 `json:"from_height,omitempty"`
+	IncludeSpent bool   `json:"include_spent,omitempty"`
+	MemoType     string `json:"memo_type,omitempty"`
+	Reference    string `json:"reference,omitempty"`
+	Subject      string `json:"subject,omitempty"`
+}
+
+// ListPaymentsResponse returns the matching payments by tree position
+type ListPaymentsResponse struct {
+	Payments []ReceivedPayment `json:"payments"`
+}
+
+// ReceivedPayment is one note received by the wallet. Memo holds text
+// memos and memos that do not parse; StructuredMemo the rest.
+type ReceivedPayment struct {
+	Commitment     string          `json:"commitment"`
+	Address        string          `json:"address"`
+	Value          uint64          `json:"value"`
+	Height         uint64          `json:"height"`
+	Position       uint64          `json:"position"`
+	Spent          bool            `json:"spent"`
+	Memo           string          `json:"memo,omitempty"`
+	StructuredMemo *StructuredMemo `json:"structured_memo,omitempty"`
+}
+
+// VerifyPaymentDisclosureRequest checks a hex-encoded payment disclosure.
+// BlockHash locates a confirmed transaction.
+type VerifyPaymentDisclosureRequest struct {
+	Disclosure string `json:"disclosure"`
+	BlockHash  string `json:"block_hash,omitempty"`
+}
+
+// VerifyPaymentDisclosureResponse reports what the disclosure claims and
+// whether the transaction backs it
+type VerifyPaymentDisclosureResponse struct {
+	Valid       bool   `json:"valid"`
+	TxHash      string `json:"tx_hash"`
+	OutputIndex uint32 `json:"output_index"`
+	Value       uint64 `json:"value"`
+	Recipient   string `json:"recipient"`
+	Memo        string `json:"memo,omitempty"`
+	Error       string `json:"error,omitempty"`
+
+	StructuredMemo *StructuredMemo `json:"structured_memo,omitempty"`
+}
+
+// ExplainDisclosuresRequest describes a planned transaction. MaxValue 0
+// means no range disclosure will be attached, so the value is unbounded.
+type ExplainDisclosuresRequest struct {
+	Recipient     string   `json:"recipient"`
+	MinValue      uint64   `json:"min_value,omitempty"`
+	MaxValue      uint64   `json:"max_value,omitempty"`
+	Jurisdictions []string `json:"jurisdictions,omitempty"`
+	Attached      []string `json:"attached,omitempty"`
+}
+
+// PolicyRuleResult is the outcome of one policy rule
+type PolicyRuleResult struct {
+	Name    string   `json:"name"`
+	Rule    string   `json:"rule"`
+	Matched bool     `json:"matched"`
+	Reason  string   `json:"reason"`
+	Require []string `json:"require"`
+}
+
+// ExplainDisclosuresResponse lists the disclosures the network's policy
+// requires of the transaction, those not yet attached, and why
+type ExplainDisclosuresResponse struct {
+	Network  string             `json:"network"`
+	Required []string           `json:"required"`
+	Missing  []string           `json:"missing"`
+	Rules    []PolicyRuleResult `json:"rules"`
+}
+
+// GetDifficultyHistoryRequest requests difficulty samples over a height range.
+// ToHeight 0 means the current height; MaxPoints bounds the series length.
+type GetDifficultyHistoryRequest struct {
+	FromHeight uint64 `json:"from_height"`
+	ToHeight   uint64 `json:"to_height,omitempty"`
+	MaxPoints  int    `json:"max_points,omitempty"`
+}
+
+// DifficultyPoint is a difficulty sample
+type DifficultyPoint struct {
+	Height     uint64 `json:"height"`
+	Timestamp  int64  `json:"timestamp"`
+	Difficulty string `json:"difficulty"`
+}
+
+// GetDifficultyHistoryResponse returns a downsampled difficulty series
+type GetDifficultyHistoryResponse struct {
+	Step   uint64            `json:"step"`
+	Points []DifficultyPoint `json:"points"`
+}
+
+// EpochRangeRequest selects epochs [FromEpoch, ToEpoch]. ToEpoch 0 means
+// the current epoch; MaxPoints bounds the series length.
+type EpochRangeRequest struct {
+	FromEpoch uint64 `json:"from_epoch"`
+	ToEpoch   uint64 `json:"to_epoch,omitempty"`
+	MaxPoints int    `json:"max_points,omitempty"`
+}
+
+// GetQualityHistoryRequest requests average quality per epoch
+type GetQualityHistoryRequest struct {
+	EpochRangeRequest
+}
+
+// QualityPoint is the average quality over an epoch range
+type QualityPoint struct {
+	FromEpoch      uint64  `json:"from_epoch"`
+	ToEpoch        uint64  `json:"to_epoch"`
+	AverageQuality float64 `json:"average_quality"`
+	Blocks         int64   `json:"blocks"`
+}
+
+// GetQualityHistoryResponse returns a downsampled quality series
+type GetQualityHistoryResponse struct {
+	Step   uint64         `json:"step"`
+	Points []QualityPoint `json:"points"`
+}
+
+// GetReputationHistogramRequest requests reputation distributions per epoch
+type GetReputationHistogramRequest struct {
+	EpochRangeRequest
+	Bins int `json:"bins,omitempty"`
+}
+
+// ReputationHistogram counts miners per reputation bin over an epoch range
+type ReputationHistogram struct {
+	FromEpoch uint64  `json:"from_epoch"`
+	ToEpoch   uint64  `json:"to_epoch"`
+	Counts    []int64 `json:"counts"`
+}
+
+// GetReputationHistogramResponse returns histograms sharing BinEdges
+type GetReputationHistogramResponse struct {
+	Step       uint64                `json:"step"`
+	BinEdges   []float64             `json:"bin_edges"`
+	Histograms []ReputationHistogram `json:"histograms"`
+}
+
+// GetStakingHistoryRequest requests staking totals per epoch
+type GetStakingHistoryRequest struct {
+	EpochRangeRequest
+}
+
+// StakingPoint is the staking total at the end of an epoch
+type StakingPoint struct {
+	Epoch       uint64 `json:"epoch"`
+	TotalStaked uint64 `json:"total_staked"`
+	Stakers     int64  `json:"stakers"`
+}
+
+// GetStakingHistoryResponse returns a downsampled staking series
+type GetStakingHistoryResponse struct {
+	Step   uint64         `json:"step"`
+	Points []StakingPoint `json:"points"`
+}
+
+// ============================================================================
+// ModelService
+// ============================================================================
+
+// GetModelRequest requests a registry entry and a version of its weights
+type GetModelRequest struct {
+	ModelID string `json:"model_id"`
+	Version uint32 `json:"version,omitempty"` // 0 selects the latest
+}
+
+// ModelContributor is a contributor's share of a model
+type ModelContributor struct {
+	Address       string  `json:"address"`
+	Contributions uint64  `json:"contributions"`
+	Share         float64 `json:"share"`
+}
+
+// EvaluatorKey is an evaluator's registered public key
+type EvaluatorKey struct {
+	Address   string `json:"address"`
+	PublicKey string `json:"public_key"`
+}
+
+// GetModelResponse describes a model. Versions runs from the first
+// version up to the requested one so the caller can verify the chain;
+// Evaluators holds the keys of every attesting evaluator.
+type GetModelResponse struct {
+	ModelID      string                `json:"model_id"`
+	Architecture string                `json:"architecture"`
+	Domain       string                `json:"domain"`
+	TaskType     types.TaskType        `json:"task_type"`
+	Status       types.ModelStatus     `json:"status"`
+	License      string                `json:"license"`
+	LicenseTerms string                `json:"license_terms"`
+	Contributors []ModelContributor    `json:"contributors"`
+	Versions     []*types.ModelVersion `json:"versions"`
+	Evaluators   []EvaluatorKey        `json:"evaluators"`
+}
+
+// GetModelProofRequest requests a proof of a model's registry checkpoint
+type GetModelProofRequest struct {
+	ModelID string `json:"model_id"`
+	Epoch   uint64 `json:"epoch,omitempty"` // 0 selects the latest checkpoint
+}
+
+// GetModelProofResponse proves a model's checkpoint, or its absence when
+// Checkpoint is nil, against Root. Blocks lists the blocks opening the
+// epoch whose headers commit to Root, so a light client can check the
+// proof against headers it already trusts.
+type GetModelProofResponse struct {
+	ModelID    string                 `json:"model_id"`
+	Epoch      uint64                 `json:"epoch"`
+	Root       string                 `json:"root"`
+	Checkpoint *types.ModelCheckpoint `json:"checkpoint,omitempty"`
+	Proof      *state.Proof           `json:"proof"`
+	Blocks     []string               `json:"blocks"`
+}
+
+// ============================================================================
+// GovernanceService
+// ============================================================================
+
+// GetVoterHistoryRequest requests every vote cast by an address
+type GetVoterHistoryRequest struct {
+	Address string `json:"address"`
+}
+
+// VoteHistoryEntry is one vote and the current outcome of its proposal.
+// WithOutcome is set when the vote agreed with a decided outcome.
+type VoteHistoryEntry struct {
+	ProposalID  string             `json:"proposal_id"`
+	Title       string             `json:"title"`
+	Type        types.ProposalType `json:"type"`
+	Support     bool               `json:"support"`
+	VotePower   uint64             `json:"vote_power"`
+	Reason      string             `json:"reason,omitempty"`
+	CastAt      uint64             `json:"cast_at"`
+	Outcome     string             `json:"outcome"`
+	WithOutcome bool               `json:"with_outcome"`
+}
+
+// GetVoterHistoryResponse returns an address's votes, oldest first, with
+// how many were on decided proposals and how many agreed with the outcome
+type GetVoterHistoryResponse struct {
+	Address     string             `json:"address"`
+	Votes       []VoteHistoryEntry `json:"votes"`
+	Decided     int                `json:"decided"`
+	WithOutcome int                `json:"with_outcome"`
+}
+
+// GetProposerHistoryRequest requests every proposal submitted by an address
+type GetProposerHistoryRequest struct {
+	Address string `json:"address"`
+}
+
+// ProposalHistoryEntry is one proposal and its outcome
+type ProposalHistoryEntry struct {
+	ProposalID     string             `json:"proposal_id"`
+	Title          string             `json:"title"`
+	Type           types.ProposalType `json:"type"`
+	VotesFor       uint64             `json:"votes_for"`
+	VotesAgainst   uint64             `json:"votes_against"`
+	VotingEndBlock uint64             `json:"voting_end_block"`
+	Status         string             `json:"status"`
+	ExecutedAt     uint64             `json:"executed_at,omitempty"`
+}
+
+// GetProposerHistoryResponse returns an address's proposals, oldest first
+type GetProposerHistoryResponse struct {
+	Address   string                 `json:"address"`
+	Proposals []ProposalHistoryEntry `json:"proposals"`
+	Passed    int                    `json:"passed"`
+	Rejected  int                    `json:"rejected"`
+}
+
+// EconomicChange is a proposed change to the fee and reward splits and
+// the emission scale, with the simulation inputs. Zero simulation inputs
+// take the defaults a new proposal would get.
+type EconomicChange struct {
+	FeeMinerShare    float64 `json:"fee_miner_share"`
+	FeeBurnShare     float64 `json:"fee_burn_share"`
+	FeeTreasuryShare float64 `json:"fee_treasury_share"`
+
+	RewardMinerShare    float64 `json:"reward_miner_share"`
+	RewardStakerShare   float64 `json:"reward_staker_share"`
+	RewardTreasuryShare float64 `json:"reward_treasury_share"`
+	RewardProposerShare float64 `json:"reward_proposer_share"`
+	RewardBurnShare     float64 `json:"reward_burn_share"`
+
+	EmissionScale float64 `json:"emission_scale"`
+
+	SimulationStart  uint64 `json:"simulation_start,omitempty"`
+	SimulationEpochs uint32 `json:"simulation_epochs,omitempty"`
+	FeesPerBlock     uint64 `json:"fees_per_block,omitempty"`
+}
+
+// PreviewParameterChangeRequest simulates either an existing proposal or
+// a change not yet proposed
+type PreviewParameterChangeRequest struct {
+	ProposalID string          `json:"proposal_id,omitempty"`
+	Change     *EconomicChange `json:"change,omitempty"`
+}
+
+// EpochProjectionEntry is the projected outcome of one epoch
+type EpochProjectionEntry struct {
+	Epoch           uint64 `json:"epoch"`
+	StartHeight     uint64 `json:"start_height"`
+	Emission        uint64 `json:"emission"`
+	Fees            uint64 `json:"fees"`
+	MinerRewards    uint64 `json:"miner_rewards"`
+	StakerRewards   uint64 `json:"staker_rewards"`
+	TreasuryInflow  uint64 `json:"treasury_inflow"`
+	ProposerRewards uint64 `json:"proposer_rewards"`
+	Burned          uint64 `json:"burned"`
+	Supply          uint64 `json:"supply"`
+}
+
+// PreviewParameterChangeResponse returns the projection under the
+// proposed parameters and, for comparison, under the current ones. For a
+// proposal, Matches reports whether SimulationHash equals the hash
+// attached when it was created.
+type PreviewParameterChangeResponse struct {
+	ProposalID     string                 `json:"proposal_id,omitempty"`
+	StartHeight    uint64                 `json:"start_height"`
+	Epochs         int                    `json:"epochs"`
+	FeesPerBlock   uint64                 `json:"fees_per_block"`
+	SimulationHash string                 `json:"simulation_hash"`
+	AttachedHash   string                 `json:"attached_hash,omitempty"`
+	Matches        bool                   `json:"matches,omitempty"`
+	Projections    []EpochProjectionEntry `json:"projections"`
+	Baseline       []EpochProjectionEntry `json:"baseline"`
+}
+
+// ============================================================================
+// MinerService
+// ============================================================================
+
+// StartMiningRequest starts block production
+type StartMiningRequest struct{}
+
+// StopMiningRequest stops block production
+type StopMiningRequest struct{}
+
+// GetMiningStatusRequest requests the miner's state
+type GetMiningStatusRequest struct{}
+
+// MiningStatusResponse describes the miner's state and output
+type MiningStatusResponse struct {
+	Running         bool    `json:"running"`
+	Address         string  `json:"address"`
+	Reputation      float64 `json:"reputation"`
+	CurrentTask     string  `json:"current_task,omitempty"`
+	BlocksMined     uint64  `json:"blocks_mined"`
+	LastBlock       string  `json:"last_block,omitempty"`
+	LastBlockHeight uint64  `json:"last_block_height,omitempty"`
+	LastBlockTime   int64   `json:"last_block_time,omitempty"`
+	LastError       string  `json:"last_error,omitempty"`
+}
+
+// GetBlockTemplateRequest requests the template of a block built now.
+// With LongPollID set to the ID of the caller's template, the call waits
+// until the template changes or the long-poll timeout passes.
+type GetBlockTemplateRequest struct {
+	LongPollID string `json:"longpoll_id,omitempty"`
+}
+
+// BlockTemplateResponse describes the work a block built now commits to.
+// LongPollID changes whenever work on the template becomes stale.
+type BlockTemplateResponse struct {
+	LongPollID string   `json:"longpoll_id"`
+	Parents    []string `json:"parents"`
+	Height     uint64   `json:"height"`
+	Timestamp  uint64   `json:"timestamp"`
+	Difficulty string   `json:"difficulty"`
+	TaskID     string   `json:"task_id,omitempty"`
+	VRFSeed    string   `json:"vrf_seed"`
+	TxRoot     string   `json:"tx_root"`
+	TxCount    int      `json:"tx_count"`
+}
+
+// MiningNotificationsRequest subscribes to block template changes
+type MiningNotificationsRequest struct{}
+
+// MiningNotification reports why the block template changed (new_tips,
+// task_reassigned or difficulty_changed) and the new template
+type MiningNotification struct {
+	Kind     string                 `json:"kind"`
+	Template *BlockTemplateResponse `json:"template"`
+}
+
+// GetDelegationsRequest requests the delegations to a miner or by a
+// delegator; exactly one is set. Height 0 means the current height.
+type GetDelegationsRequest struct {
+	Miner     string `json:"miner,omitempty"`
+	Delegator string `json:"delegator,omitempty"`
+	Height    uint64 `json:"height,omitempty"`
+}
+
+// DelegationEntry is stake a delegator bonded to a miner
+type DelegationEntry struct {
+	Delegator      string `json:"delegator"`
+	Miner          string `json:"miner"`
+	Bonded         uint64 `json:"bonded"`
+	Unbonding      uint64 `json:"unbonding"`
+	UnbondingUntil uint64 `json:"unbonding_until,omitempty"`
+	Rewards        uint64 `json:"rewards"`
+	Slashed        uint64 `json:"slashed"`
+}
+
+// GetDelegationsResponse returns delegations. For a miner it also gives
+// the miner's own stake and the total delegated to it. Requested at a
+// past height, they are as of the checkpoint at AsOf.
+type GetDelegationsResponse struct {
+	Miner          string            `json:"miner,omitempty"`
+	Delegator      string            `json:"delegator,omitempty"`
+	AsOf           uint64            `json:"as_of,omitempty"`
+	OwnStake       uint64            `json:"own_stake,omitempty"`
+	DelegatedStake uint64            `json:"delegated_stake,omitempty"`
+	Delegations    []DelegationEntry `json:"delegations"`
+}
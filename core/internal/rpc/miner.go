@@ -0,0 +1,171 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ccoin/core/internal/miner"
+	"github.com/ccoin/core/internal/wallet"
+	"github.com/ccoin/core/pkg/common"
+)
+
+// StartMining starts block production under the node's miner address.
+// The wallet holding the miner key must be unlocked.
+func (s *Server) StartMining(ctx context.Context, req *StartMiningRequest) (*MiningStatusResponse, error) {
+	if s.backends.Miner == nil {
+		return nil, status.Error(codes.Unimplemented, "mining not enabled")
+	}
+
+	if err := s.backends.Miner.Start(); err != nil {
+		switch {
+		case errors.Is(err, miner.ErrAlreadyRunning):
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		case errors.Is(err, wallet.ErrLocked), errors.Is(err, wallet.ErrUnknownAddress), errors.Is(err, miner.ErrKeyMismatch):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return miningStatus(s.backends.Miner.Status()), nil
+}
+
+// StopMining stops block production; the task in progress is abandoned
+func (s *Server) StopMining(ctx context.Context, req *StopMiningRequest) (*MiningStatusResponse, error) {
+	if s.backends.Miner == nil {
+		return nil, status.Error(codes.Unimplemented, "mining not enabled")
+	}
+
+	s.backends.Miner.Stop()
+	return miningStatus(s.backends.Miner.Status()), nil
+}
+
+// GetMiningStatus returns the miner's state and the blocks it produced
+func (s *Server) GetMiningStatus(ctx context.Context, req *GetMiningStatusRequest) (*MiningStatusResponse, error) {
+	if s.backends.Miner == nil {
+		return nil, status.Error(codes.Unimplemented, "mining not enabled")
+	}
+
+	return miningStatus(s.backends.Miner.Status()), nil
+}
+
+// longPollTimeout bounds how long GetBlockTemplate waits for the template
+// to change; callers then poll again
+const longPollTimeout = time.Minute
+
+// GetBlockTemplate returns the template of a block built now. With a
+// long-poll ID it waits until the template differs from that one, so
+// external miners learn at once that their work is stale.
+func (s *Server) GetBlockTemplate(ctx context.Context, req *GetBlockTemplateRequest) (*BlockTemplateResponse, error) {
+	if s.backends.Miner == nil {
+		return nil, status.Error(codes.Unimplemented, "mining not enabled")
+	}
+	if req.LongPollID == "" {
+		return s.blockTemplate(ctx)
+	}
+
+	updates, cancel := s.backends.Miner.Subscribe()
+	defer cancel()
+
+	// Fetched after subscribing so no change is missed
+	resp, err := s.blockTemplate(ctx)
+	if err != nil || resp.LongPollID != req.LongPollID {
+		return resp, err
+	}
+
+	timeout := time.NewTimer(longPollTimeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case n := <-updates:
+			if t := templateResponse(n.Template); t.LongPollID != req.LongPollID {
+				return t, nil
+			}
+		case <-timeout.C:
+			return s.blockTemplate(ctx)
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+// MiningNotifications streams block template changes until the client
+// goes away
+func (s *Server) MiningNotifications(req *MiningNotificationsRequest, stream MiningNotificationsStream) error {
+	if s.backends.Miner == nil {
+		return status.Error(codes.Unimplemented, "mining not enabled")
+	}
+
+	updates, cancel := s.backends.Miner.Subscribe()
+	defer cancel()
+
+	ctx := stream.Context()
+	for {
+		select {
+		case n := <-updates:
+			msg := &MiningNotification{Kind: n.Kind.String(), Template: templateResponse(n.Template)}
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// blockTemplate builds the current template for the wire
+func (s *Server) blockTemplate(ctx context.Context) (*BlockTemplateResponse, error) {
+	t, err := s.backends.Miner.BlockTemplate(ctx)
+	if err != nil {
+		if errors.Is(err, miner.ErrNoParents) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return templateResponse(t), nil
+}
+
+// templateResponse converts a block template for the wire
+func templateResponse(t *miner.Template) *BlockTemplateResponse {
+	resp := &BlockTemplateResponse{
+		LongPollID: t.ID.String(),
+		Parents:    make([]string, len(t.Parents)),
+		Height:     t.Height,
+		Timestamp:  t.Timestamp,
+		VRFSeed:    t.VRFSeed.String(),
+		TxRoot:     t.TxRoot.String(),
+		TxCount:    t.TxCount,
+	}
+	for i, p := range t.Parents {
+		resp.Parents[i] = p.String()
+	}
+	if t.Difficulty != nil {
+		resp.Difficulty = t.Difficulty.String()
+	}
+	if !t.TaskID.IsEmpty() {
+		resp.TaskID = t.TaskID.String()
+	}
+	return resp
+}
+
+// miningStatus converts a miner status for the wire
+func miningStatus(st miner.Status) *MiningStatusResponse {
+	resp := &MiningStatusResponse{
+		Running:     st.Running,
+		Address:     common.BytesToHex(st.Address[:]),
+		Reputation:  st.Reputation,
+		BlocksMined: st.BlocksMined,
+		LastError:   st.LastError,
+	}
+	if !st.CurrentTask.IsEmpty() {
+		resp.CurrentTask = st.CurrentTask.String()
+	}
+	if st.BlocksMined > 0 {
+		resp.LastBlock = st.LastBlock.String()
+		resp.LastBlockHeight = st.LastBlockHeight
+		resp.LastBlockTime = st.LastBlockTime.Unix()
+	}
+	return resp
+}
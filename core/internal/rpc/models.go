@@ -0,0 +1,129 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ccoin/core/internal/aicommons"
+	"github.com/ccoin/core/pkg/common"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// GetModel returns a registry entry with its version chain and the keys
+// needed to check the chain's attestations
+func (s *Server) GetModel(ctx context.Context, req *GetModelRequest) (*GetModelResponse, error) {
+	if s.backends.Models == nil {
+		return nil, status.Error(codes.Unimplemented, "model registry not enabled")
+	}
+
+	modelID, err := parseHash(req.ModelID)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	model, err := s.backends.Models.GetModel(ctx, modelID)
+	if err != nil || model == nil {
+		return nil, status.Error(codes.NotFound, "model not found")
+	}
+
+	versions, err := s.backends.Models.ModelVersions(modelID, req.Version)
+	if err != nil {
+		if errors.Is(err, aicommons.ErrVersionNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &GetModelResponse{
+		ModelID:      modelID.String(),
+		Architecture: model.Architecture,
+		Domain:       model.Domain,
+		TaskType:     model.TaskType,
+		Status:       model.Status,
+		License:      model.License.String(),
+		LicenseTerms: model.License.Terms(),
+		Versions:     versions,
+	}
+
+	for addr, contributions := range model.Contributors {
+		resp.Contributors = append(resp.Contributors, ModelContributor{
+			Address:       common.BytesToHex(addr[:]),
+			Contributions: contributions,
+			Share:         model.ContributorShare(addr),
+		})
+	}
+	sort.Slice(resp.Contributors, func(i, j int) bool {
+		if resp.Contributors[i].Contributions != resp.Contributors[j].Contributions {
+			return resp.Contributors[i].Contributions > resp.Contributors[j].Contributions
+		}
+		return resp.Contributors[i].Address < resp.Contributors[j].Address
+	})
+
+	if s.backends.Evaluators != nil {
+		seen := make(map[types.Address]bool)
+		for _, v := range versions {
+			for _, att := range v.Attestations {
+				if seen[att.Evaluator] {
+					continue
+				}
+				seen[att.Evaluator] = true
+				if e := s.backends.Evaluators.GetEvaluator(att.Evaluator); e != nil {
+					resp.Evaluators = append(resp.Evaluators, EvaluatorKey{
+						Address:   common.BytesToHex(att.Evaluator[:]),
+						PublicKey: common.BytesToHex(e.PublicKey),
+					})
+				}
+			}
+		}
+	}
+
+	return resp, nil
+}
+
+// GetModelProof proves a model's registry checkpoint against the root
+// committed by the blocks opening the checkpoint's epoch
+func (s *Server) GetModelProof(ctx context.Context, req *GetModelProofRequest) (*GetModelProofResponse, error) {
+	if s.backends.Models == nil {
+		return nil, status.Error(codes.Unimplemented, "model registry not enabled")
+	}
+
+	modelID, err := parseHash(req.ModelID)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	proof, err := s.backends.Models.ProveModel(modelID, req.Epoch)
+	if err != nil {
+		if errors.Is(err, aicommons.ErrNoCheckpoint) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &GetModelProofResponse{
+		ModelID:    modelID.String(),
+		Epoch:      proof.Epoch,
+		Root:       proof.Root.String(),
+		Checkpoint: proof.Checkpoint,
+		Proof:      proof.Proof,
+		Blocks:     []string{},
+	}
+
+	if s.backends.DAG != nil {
+		headers, err := s.backends.DAG.GetHeadersAtHeight(ctx, proof.Epoch*types.EpochLength)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		for _, h := range headers {
+			if h.RegistryRoot == proof.Root {
+				resp.Blocks = append(resp.Blocks, h.Hash.String())
+			}
+		}
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,103 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ccoin/core/internal/wallet"
+	"github.com/ccoin/core/pkg/common"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// errMemoConflict is returned when a send sets both memo forms
+var errMemoConflict = errors.New("memo and structured_memo are mutually exclusive")
+
+// ListPayments returns the notes the wallet received, filtered by address,
+// height and memo, so merchants can match payments to their references
+func (s *Server) ListPayments(ctx context.Context, req *ListPaymentsRequest) (*ListPaymentsResponse, error) {
+	w := s.backends.Wallet
+	if w == nil {
+		return nil, status.Error(codes.Unimplemented, "wallet not enabled")
+	}
+
+	filter := &wallet.NoteFilter{
+		FromHeight:   req.FromHeight,
+		IncludeSpent: req.IncludeSpent,
+		Reference:    req.Reference,
+	}
+	if req.Address != "" {
+		addr, _, err := parsePaymentAddress(req.Address)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		filter.Address = addr
+	}
+	if req.MemoType != "" {
+		t, err := wallet.ParseMemoType(req.MemoType)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		filter.Types = []wallet.MemoType{t}
+	}
+	if req.Subject != "" {
+		subject, err := parseHash(req.Subject)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		filter.Subject = subject
+	}
+
+	notes := w.FindNotes(filter)
+	resp := &ListPaymentsResponse{Payments: make([]ReceivedPayment, len(notes))}
+	for i, n := range notes {
+		p := &resp.Payments[i]
+		p.Commitment = n.Commitment.String()
+		p.Address = common.BytesToHex(n.Address[:])
+		p.Value, p.Height, p.Position, p.Spent = n.Value, n.Height, n.Position, n.Spent
+		p.Memo, p.StructuredMemo = memoFields(n.Memo)
+	}
+	return resp, nil
+}
+
+// requestMemo encodes the memo of a send or withdrawal
+func requestMemo(text string, sm *StructuredMemo) ([]byte, error) {
+	if sm == nil {
+		return wallet.EncodeMemo(&wallet.Memo{Type: wallet.MemoText, Text: text})
+	}
+	if text != "" {
+		return nil, errMemoConflict
+	}
+
+	m := &wallet.Memo{Reference: sm.Reference, Text: sm.Text}
+	var err error
+	if m.Type, err = wallet.ParseMemoType(sm.Type); err != nil {
+		return nil, err
+	}
+	if sm.Subject != "" {
+		if m.Subject, err = parseHash(sm.Subject); err != nil {
+			return nil, err
+		}
+	}
+	return wallet.EncodeMemo(m)
+}
+
+// memoFields splits a memo into its text or structured form. Memos that
+// do not parse are returned as text.
+func memoFields(data []byte) (string, *StructuredMemo) {
+	m, err := wallet.ParseMemo(data)
+	if err != nil {
+		return string(data), nil
+	}
+	if m.Type == wallet.MemoText {
+		return m.Text, nil
+	}
+
+	sm := &StructuredMemo{Type: m.Type.String(), Reference: m.Reference, Text: m.Text}
+	if m.Subject != (types.Hash{}) {
+		sm.Subject = m.Subject.String()
+	}
+	return "", sm
+}
@@ -0,0 +1,91 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ccoin/core/internal/dag"
+	"github.com/ccoin/core/internal/storage"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// GetReceipt returns the receipt of an included transaction with the
+// path proving it against the including block's receipts root
+func (s *Server) GetReceipt(ctx context.Context, req *GetReceiptRequest) (*GetReceiptResponse, error) {
+	if s.backends.Transactions == nil || s.backends.Receipts == nil {
+		return nil, status.Error(codes.Unimplemented, "receipts not available")
+	}
+
+	hash, err := parseHash(req.TxHash)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	record, err := s.backends.Transactions.GetTransaction(ctx, hash)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, status.Error(codes.NotFound, "transaction not included")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	receipts, err := s.blockReceipts(ctx, record.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+	if record.Index >= len(receipts) || receipts[record.Index].TxHash != hash {
+		return nil, status.Error(codes.Internal, "receipt does not match transaction index")
+	}
+
+	receipt := receipts[record.Index]
+	return &GetReceiptResponse{
+		Receipt:     receipt,
+		Status:      receipt.Status.String(),
+		BlockHash:   record.BlockHash.String(),
+		BlockHeight: record.BlockHeight,
+		Index:       record.Index,
+		Path:        dag.ReceiptPath(receipts, record.Index),
+	}, nil
+}
+
+// GetBlockReceipts returns the receipts of a block's transactions
+func (s *Server) GetBlockReceipts(ctx context.Context, req *GetBlockReceiptsRequest) (*GetBlockReceiptsResponse, error) {
+	if s.backends.Receipts == nil {
+		return nil, status.Error(codes.Unimplemented, "receipts not available")
+	}
+
+	hash, err := parseHash(req.Hash)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	receipts, err := s.blockReceipts(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if receipts == nil {
+		receipts = []*types.Receipt{}
+	}
+
+	return &GetBlockReceiptsResponse{
+		Hash:         hash.String(),
+		ReceiptsRoot: dag.ComputeReceiptsRoot(receipts).String(),
+		Receipts:     receipts,
+	}, nil
+}
+
+// blockReceipts derives a block's receipts, mapping failures to status
+// errors
+func (s *Server) blockReceipts(ctx context.Context, hash types.Hash) ([]*types.Receipt, error) {
+	receipts, err := s.backends.Receipts.Receipts(ctx, hash)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, status.Error(codes.NotFound, "block not found")
+	}
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return receipts, nil
+}
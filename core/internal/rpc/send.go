@@ -0,0 +1,312 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ccoin/core/internal/p2p"
+	"github.com/ccoin/core/internal/wallet"
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Send statuses
+const (
+	SendStatusAccepted  = "accepted"
+	SendStatusDuplicate = "duplicate"
+)
+
+// ShieldedState is the view of the commitment tree needed to spend notes
+type ShieldedState interface {
+	GetCurrentAnchor() types.Hash
+	GetMerklePath(ctx context.Context, position uint64) (*zkp.MerklePath, error)
+}
+
+// TxBroadcaster gossips encoded transactions to peers
+type TxBroadcaster interface {
+	BroadcastTransaction(data []byte) error
+}
+
+// TxRelay hands an encoded transaction to a peer other than the sync
+// peer, returning the peer chosen
+type TxRelay interface {
+	RelayTransaction(ctx context.Context, data []byte) (string, error)
+}
+
+// SendTransaction builds a shielded payment from wallet notes, admits it
+// to the mempool and gossips it to peers
+func (s *Server) SendTransaction(ctx context.Context, req *SendTransactionRequest) (*SendTransactionResponse, error) {
+	w := s.backends.Wallet
+	if w == nil || s.backends.Shielded == nil || s.backends.Circuits == nil {
+		return nil, status.Error(codes.Unimplemented, "shielded sends not enabled")
+	}
+	if s.backends.Mempool == nil {
+		return nil, status.Error(codes.Unimplemented, "mempool not available")
+	}
+
+	// A retried request reports the original outcome instead of spending
+	// a fresh set of notes
+	if req.RequestID != "" {
+		if res, ok := s.backends.Mempool.LookupSubmission(req.RequestID); ok {
+			if res.Err != nil {
+				return nil, status.Error(submitErrorCode(res.Err), res.Err.Error())
+			}
+			return &SendTransactionResponse{TxHash: res.TxHash.String(), Status: SendStatusDuplicate}, nil
+		}
+	}
+
+	to, key, err := parsePaymentAddress(req.To)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if req.Amount == 0 {
+		return nil, status.Error(codes.InvalidArgument, "amount must be positive")
+	}
+	if req.Amount > math.MaxUint64-req.Fee {
+		return nil, status.Error(codes.InvalidArgument, "amount overflows")
+	}
+	privacy := privacyOptions(req.Privacy)
+	if privacy != nil && privacy.AvoidSyncPeer && s.backends.Relay == nil {
+		return nil, status.Error(codes.FailedPrecondition, "peer relay not available")
+	}
+
+	memo, err := requestMemo(req.Memo, req.StructuredMemo)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	payments := []payment{{to: to, key: key, amount: req.Amount, memo: memo}}
+	tx, spent, err := s.buildSend(ctx, w, payments, req.Fee, memo, privacy)
+	if err != nil {
+		switch {
+		case errors.Is(err, wallet.ErrLocked), errors.Is(err, wallet.ErrInsufficientFunds):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		default:
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	txHash, err := s.backends.Mempool.Submit(req.RequestID, tx)
+	if err != nil {
+		w.Release(spent...)
+		return nil, status.Error(submitErrorCode(err), err.Error())
+	}
+
+	// The transaction is already admitted; a failed note update only
+	// means the wallet may offer these notes again until rescanned
+	if err := w.MarkSpent(spent...); err != nil {
+		fmt.Printf("Warning: failed to mark notes spent for %s: %v\n", txHash, err)
+	}
+
+	resp := &SendTransactionResponse{TxHash: txHash.String(), Status: SendStatusAccepted}
+	if privacy == nil {
+		resp.Broadcast, resp.BroadcastError = s.broadcast(tx)
+	} else {
+		s.broadcastPrivately(tx, privacy, resp)
+	}
+	return resp, nil
+}
+
+// privacyOptions converts request privacy options for the wallet
+func privacyOptions(p *SendPrivacy) *wallet.PrivacyOptions {
+	if p == nil {
+		return nil
+	}
+	return &wallet.PrivacyOptions{
+		MaxBroadcastDelay: time.Duration(p.MaxBroadcastDelaySeconds) * time.Second,
+		DecoyOutputs:      p.DecoyOutputs,
+		RoundChange:       p.RoundChange,
+		AvoidSyncPeer:     p.AvoidSyncPeer,
+	}
+}
+
+// broadcastPrivately gossips an admitted transaction after a random
+// delay, through a relay peer if asked. A delayed broadcast runs in the
+// background and only logs failures.
+func (s *Server) broadcastPrivately(tx *types.Transaction, privacy *wallet.PrivacyOptions, resp *SendTransactionResponse) {
+	data, err := p2p.EncodeTransaction(tx)
+	if err != nil {
+		resp.BroadcastError = err.Error()
+		return
+	}
+	delay, err := privacy.BroadcastDelay()
+	if err != nil {
+		resp.BroadcastError = err.Error()
+		return
+	}
+
+	send := func() (string, error) {
+		if privacy.AvoidSyncPeer {
+			return s.backends.Relay.RelayTransaction(context.Background(), data)
+		}
+		if s.backends.Broadcaster == nil {
+			return "", nil
+		}
+		return "", s.backends.Broadcaster.BroadcastTransaction(data)
+	}
+
+	if delay == 0 {
+		relayPeer, err := send()
+		if err != nil {
+			resp.BroadcastError = err.Error()
+			return
+		}
+		resp.RelayPeer = relayPeer
+		resp.Broadcast = s.backends.Broadcaster != nil || privacy.AvoidSyncPeer
+		return
+	}
+
+	resp.BroadcastAt = time.Now().Add(delay).Unix()
+	time.AfterFunc(delay, func() {
+		if _, err := send(); err != nil {
+			fmt.Printf("Warning: delayed broadcast of %s failed: %v\n", tx.TxHash, err)
+		}
+	})
+}
+
+// broadcast gossips an admitted transaction, reporting any failure
+func (s *Server) broadcast(tx *types.Transaction) (bool, string) {
+	if s.backends.Broadcaster == nil {
+		return false, ""
+	}
+	data, err := p2p.EncodeTransaction(tx)
+	if err == nil {
+		err = s.backends.Broadcaster.BroadcastTransaction(data)
+	}
+	if err != nil {
+		return false, err.Error()
+	}
+	return true, ""
+}
+
+// payment is one output paid by a send
+type payment struct {
+	to     types.Address
+	key    []byte
+	amount uint64
+	memo   []byte
+}
+
+// buildSend selects notes and runs the transaction builder. The returned
+// commitments stay reserved in the wallet; on error they are released.
+func (s *Server) buildSend(
+	ctx context.Context,
+	w WalletBackend,
+	payments []payment,
+	fee uint64,
+	memo []byte,
+	privacy *wallet.PrivacyOptions,
+) (*types.Transaction, []types.Hash, error) {
+	notes, change, spent, err := selectNotes(w, payments, fee)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := s.proveSend(ctx, w, notes, change, payments, fee, memo, privacy)
+	if err != nil {
+		w.Release(spent...)
+		return nil, nil, err
+	}
+
+	return tx, spent, nil
+}
+
+// selectNotes reserves wallet notes covering the payments and fee
+func selectNotes(w WalletBackend, payments []payment, fee uint64) ([]*wallet.Note, uint64, []types.Hash, error) {
+	notes, change, err := w.SelectNotes(sumPayments(payments) + fee)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	spent := make([]types.Hash, len(notes))
+	for i, n := range notes {
+		spent[i] = n.Commitment
+	}
+	return notes, change, spent, nil
+}
+
+// sumPayments totals payment amounts; callers check for overflow
+func sumPayments(payments []payment) uint64 {
+	var total uint64
+	for _, p := range payments {
+		total += p.amount
+	}
+	return total
+}
+
+// proveSend assembles inputs and outputs and generates the proof
+func (s *Server) proveSend(
+	ctx context.Context,
+	w WalletBackend,
+	notes []*wallet.Note,
+	change uint64,
+	payments []payment,
+	fee uint64,
+	memo []byte,
+	privacy *wallet.PrivacyOptions,
+) (*types.Transaction, error) {
+	state := s.backends.Shielded
+	builder := zkp.NewTransactionBuilder(s.backends.Circuits)
+
+	for _, n := range notes {
+		spendingKey, err := w.SpendingKey(n.Address)
+		if err != nil {
+			return nil, err
+		}
+
+		path, err := state.GetMerklePath(ctx, n.Position)
+		if err != nil {
+			return nil, fmt.Errorf("merkle path for note %d: %w", n.Position, err)
+		}
+
+		note := &zkp.Note{
+			Value:      n.Value,
+			Address:    n.Address,
+			Blinder:    n.Blinder,
+			Commitment: n.Commitment,
+			Position:   n.Position,
+			MerklePath: path,
+			CreatedAt:  n.Height,
+		}
+		if err := builder.AddInput(note, spendingKey, path); err != nil {
+			return nil, err
+		}
+	}
+
+	_, outputs, err := s.backends.Circuits.TransactionShape()
+	if err != nil {
+		return nil, err
+	}
+	changeOutputs, fee, err := privacy.ChangeOutputs(change, fee, outputs-len(payments))
+	if err != nil {
+		return nil, err
+	}
+
+	changeAddr := w.ShieldedAddress()
+	changeKey, err := w.TransmissionKey(changeAddr)
+	if err != nil {
+		return nil, err
+	}
+	ovk, err := w.OutgoingViewingKey(changeAddr)
+	if err != nil {
+		return nil, err
+	}
+	builder.SetOutgoingViewingKey(ovk)
+
+	for _, p := range payments {
+		builder.AddOutputTo(p.amount, p.to, p.key, p.memo)
+	}
+	for _, value := range changeOutputs {
+		builder.AddOutputTo(value, changeAddr, changeKey, nil)
+	}
+	builder.SetFee(fee)
+	builder.SetMemo(memo)
+
+	return builder.Build(ctx, state.GetCurrentAnchor())
+}
@@ -0,0 +1,888 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ccoin/core/internal/aicommons"
+	"github.com/ccoin/core/internal/committee"
+	"github.com/ccoin/core/internal/economics"
+	"github.com/ccoin/core/internal/governance"
+	"github.com/ccoin/core/internal/history"
+	"github.com/ccoin/core/internal/mempool"
+	"github.com/ccoin/core/internal/miner"
+	"github.com/ccoin/core/internal/p2p"
+	"github.com/ccoin/core/internal/reputation"
+	"github.com/ccoin/core/internal/state"
+	"github.com/ccoin/core/internal/storage"
+	"github.com/ccoin/core/internal/supervisor"
+	"github.com/ccoin/core/internal/wallet"
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/common"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Service names
+const (
+	NodeServiceName       = "ccoin.rpc.v1.NodeService"
+	DAGServiceName        = "ccoin.rpc.v1.DAGService"
+	TxServiceName         = "ccoin.rpc.v1.TxService"
+	WalletServiceName     = "ccoin.rpc.v1.WalletService"
+	AnalyticsServiceName  = "ccoin.rpc.v1.AnalyticsService"
+	ModelServiceName      = "ccoin.rpc.v1.ModelService"
+	GovernanceServiceName = "ccoin.rpc.v1.GovernanceService"
+	MinerServiceName      = "ccoin.rpc.v1.MinerService"
+)
+
+// Server errors
+var (
+	ErrServerRunning  = errors.New("rpc server already running")
+	ErrInvalidHash    = errors.New("invalid hash")
+	ErrInvalidAddress = errors.New("invalid address")
+)
+
+// DAGBackend is the view of the BlockDAG exposed over RPC
+type DAGBackend interface {
+	GetBlock(ctx context.Context, hash types.Hash) (*types.Block, error)
+	GetTips() []types.Hash
+	GetMainChainTip() types.Hash
+	GetHeight() uint64
+	GetEpoch() uint64
+	GetHeadersAtHeight(ctx context.Context, height uint64) ([]*types.BlockHeader, error)
+}
+
+// TxPool is the view of the mempool exposed over RPC
+type TxPool interface {
+	Submit(requestID string, tx *types.Transaction) (types.Hash, error)
+	LookupSubmission(requestID string) (*mempool.SubmissionResult, bool)
+	Get(txHash types.Hash) *types.Transaction
+	Size() int
+}
+
+// WalletBackend is the view of the node wallet exposed over RPC
+type WalletBackend interface {
+	Balance(ctx context.Context) (*wallet.Balance, error)
+	Addresses() []types.Address
+	ShieldedAddresses() []types.Address
+	NewAddress() (types.Address, error)
+	NewShieldedAddress() (types.Address, error)
+	Unlock(password string) error
+	Lock()
+	IsLocked() bool
+
+	// Spending
+	ShieldedAddress() types.Address
+	TransmissionKey(addr types.Address) ([]byte, error)
+	OutgoingViewingKey(addr types.Address) ([]byte, error)
+	SpendingKey(addr types.Address) ([]byte, error)
+	SelectNotes(target uint64) ([]*wallet.Note, uint64, error)
+	Release(commitments ...types.Hash)
+	MarkSpent(commitments ...types.Hash) error
+
+	// Received payments
+	FindNotes(filter *wallet.NoteFilter) []*wallet.Note
+
+	// Auditing
+	DisclosePayment(tx *types.Transaction, outputIndex int) (*zkp.PaymentDisclosure, error)
+}
+
+// SupplyBackend reports coin supply figures
+type SupplyBackend interface {
+	GetCirculatingSupply() uint64
+	GetTotalMinted() uint64
+	GetTotalBurned() uint64
+}
+
+// FeeBackend estimates transaction fees
+type FeeBackend interface {
+	Estimate(targetBlocks int) (*economics.FeeEstimate, error)
+}
+
+// ReceiptBackend derives the receipts of a stored block's transactions
+type ReceiptBackend interface {
+	Receipts(ctx context.Context, blockHash types.Hash) ([]*types.Receipt, error)
+}
+
+// HistoryBackend serves state as of past main chain heights on archive
+// nodes
+type HistoryBackend interface {
+	State(ctx context.Context, height uint64) (*types.BlockHeader, *state.State, error)
+	Checkpoint(height uint64) (*history.Checkpoint, error)
+}
+
+// DiagnosticsBackend captures runtime diagnostics bundles
+type DiagnosticsBackend interface {
+	CaptureBundle(ctx context.Context, duration time.Duration) (string, error)
+}
+
+// AnalyticsBackend serves historical chain statistics
+type AnalyticsBackend interface {
+	DifficultyHistory(ctx context.Context, fromHeight, toHeight, step uint64) ([]storage.DifficultyPoint, error)
+	QualityHistory(ctx context.Context, fromEpoch, toEpoch, step uint64) ([]storage.QualityPoint, error)
+	ReputationHistograms(ctx context.Context, fromEpoch, toEpoch, step uint64, bins int) ([]storage.ReputationHistogram, error)
+	StakingHistory(ctx context.Context, fromEpoch, toEpoch, step uint64) ([]storage.StakingPoint, error)
+}
+
+// ModelBackend serves the AI Commons model registry
+type ModelBackend interface {
+	GetModel(ctx context.Context, modelID types.Hash) (*types.ModelEntry, error)
+	ModelVersions(modelID types.Hash, upTo uint32) ([]*types.ModelVersion, error)
+	ProveModel(modelID types.Hash, epoch uint64) (*aicommons.ModelProof, error)
+}
+
+// EvaluatorBackend resolves registered evaluators
+type EvaluatorBackend interface {
+	GetEvaluator(addr types.Address) *aicommons.Evaluator
+}
+
+// GovernanceBackend serves per-address governance history and economic
+// parameter simulations
+type GovernanceBackend interface {
+	VoterHistory(voter types.Address) []*governance.VoteRecord
+	ProposerHistory(proposer types.Address) []*types.Proposal
+	PreviewEconomicChange(data *types.EconomicParameterData, currentBlock uint64) (*economics.Simulation, error)
+	ProposalSimulation(proposalID types.Hash) (*economics.Simulation, *types.EconomicParameterData, error)
+}
+
+// CommitteeBackend audits recorded committee selections
+type CommitteeBackend interface {
+	Audit(ctx context.Context, kind types.CommitteeKind, epoch uint64) (*committee.Audit, error)
+}
+
+// MinerBackend controls block production and serves block templates to
+// external miners
+type MinerBackend interface {
+	Start() error
+	Stop()
+	Status() miner.Status
+	BlockTemplate(ctx context.Context) (*miner.Template, error)
+	Subscribe() (<-chan miner.Notification, func())
+}
+
+// StakeBackend serves miner stakes and the delegations backing them
+type StakeBackend interface {
+	GetStakeInfo(addr types.Address) *reputation.StakeInfo
+	DelegatedStake(miner types.Address) uint64
+	Delegations(miner types.Address) []*reputation.Delegation
+	DelegationsBy(delegator types.Address) []*reputation.Delegation
+}
+
+// PeerBackend reports the connected peers
+type PeerBackend interface {
+	PeerCount() int
+	Peers() []*p2p.PeerInfo
+}
+
+// DiversityBackend is implemented by peer backends that group peers by
+// network
+type DiversityBackend interface {
+	Diversity() *p2p.DiversityStats
+}
+
+// NATBackend is implemented by peer backends that report NAT traversal
+type NATBackend interface {
+	NATStatus() *p2p.NATStatus
+}
+
+// BanBackend manages the peer ban list
+type BanBackend interface {
+	Bans() []p2p.Ban
+	Ban(bans ...p2p.Ban) (int, error)
+	Unban(target string) (bool, error)
+}
+
+// Backends bundles the node components served over RPC. Nil members
+// cause the corresponding calls to return Unimplemented.
+type Backends struct {
+	DAG         DAGBackend
+	Mempool     TxPool
+	Wallet      WalletBackend
+	Peers       PeerBackend
+	Bans        BanBackend
+	Supply      SupplyBackend
+	Fees        FeeBackend
+	Diagnostics DiagnosticsBackend
+	Analytics   AnalyticsBackend
+	Committees  CommitteeBackend
+	Supervisor  *supervisor.Supervisor
+
+	// Included transactions and their receipts
+	Transactions storage.ExplorerStore
+	Receipts     ReceiptBackend
+
+	// State at past heights, on archive nodes
+	History HistoryBackend
+
+	// AI Commons
+	Models     ModelBackend
+	Evaluators EvaluatorBackend
+
+	// Research DAO
+	Governance GovernanceBackend
+
+	// Block production
+	Miner  MinerBackend
+	Stakes StakeBackend
+
+	// Shielded sends
+	Shielded    ShieldedState
+	Circuits    *zkp.CircuitManager
+	Policy      *zkp.PolicyEngine
+	Broadcaster TxBroadcaster
+	Relay       TxRelay
+}
+
+// Config holds RPC server configuration
+type Config struct {
+	ListenAddr string
+	Version    string
+	Network    string
+
+	// Roles are the services the node runs, as reported by GetStatus
+	Roles []string
+
+	// JSONRPCAddr is the HTTP JSON-RPC 2.0 gateway address (empty disables)
+	JSONRPCAddr string
+
+	// MaxRecvMsgSize bounds request size (transactions carry proofs)
+	MaxRecvMsgSize int
+}
+
+// DefaultConfig returns default RPC server configuration
+func DefaultConfig() *Config {
+	return &Config{
+		ListenAddr:     "127.0.0.1:9001",
+		JSONRPCAddr:    "127.0.0.1:9002",
+		Network:        "testnet",
+		MaxRecvMsgSize: 16 * 1024 * 1024,
+	}
+}
+
+// Server is the node's gRPC server
+type Server struct {
+	mu sync.Mutex
+
+	config   *Config
+	backends *Backends
+
+	grpc     *grpc.Server
+	listener net.Listener
+
+	// JSON-RPC gateway
+	http *http.Server
+}
+
+// NewServer creates a new RPC server
+func NewServer(cfg *Config, backends *Backends) *Server {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	if backends == nil {
+		backends = &Backends{}
+	}
+
+	s := &Server{
+		config:   cfg,
+		backends: backends,
+	}
+
+	s.grpc = grpc.NewServer(
+		grpc.ForceServerCodec(jsonCodec{}),
+		grpc.MaxRecvMsgSize(cfg.MaxRecvMsgSize),
+	)
+	s.grpc.RegisterService(&nodeServiceDesc, s)
+	s.grpc.RegisterService(&dagServiceDesc, s)
+	s.grpc.RegisterService(&txServiceDesc, s)
+	s.grpc.RegisterService(&walletServiceDesc, s)
+	s.grpc.RegisterService(&analyticsServiceDesc, s)
+	s.grpc.RegisterService(&modelServiceDesc, s)
+	s.grpc.RegisterService(&governanceServiceDesc, s)
+	s.grpc.RegisterService(&minerServiceDesc, s)
+
+	return s
+}
+
+// Start binds the listen address and begins serving in the background
+func (s *Server) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener != nil {
+		return ErrServerRunning
+	}
+
+	lis, err := net.Listen("tcp", s.config.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.config.ListenAddr, err)
+	}
+	s.listener = lis
+
+	go func() {
+		if err := s.grpc.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			fmt.Printf("RPC server error: %v\n", err)
+		}
+	}()
+
+	if s.config.JSONRPCAddr != "" {
+		if err := s.startJSONRPC(); err != nil {
+			s.grpc.Stop()
+			s.listener = nil
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Addr returns the bound listen address
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Stop gracefully stops the server
+func (s *Server) Stop() {
+	s.mu.Lock()
+	httpServer := s.http
+	s.mu.Unlock()
+
+	if httpServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(ctx)
+	}
+	s.grpc.GracefulStop()
+}
+
+// ============================================================================
+// NodeService
+// ============================================================================
+
+// GetStatus returns the node status
+func (s *Server) GetStatus(ctx context.Context, req *GetStatusRequest) (*GetStatusResponse, error) {
+	resp := &GetStatusResponse{
+		Version: s.config.Version,
+		Network: s.config.Network,
+		Roles:   s.config.Roles,
+	}
+
+	if d := s.backends.DAG; d != nil {
+		resp.Height = d.GetHeight()
+		resp.Epoch = d.GetEpoch()
+		resp.MainChainTip = d.GetMainChainTip().String()
+		resp.Tips = len(d.GetTips())
+	}
+	if s.backends.Peers != nil {
+		resp.Peers = s.backends.Peers.PeerCount()
+	}
+	if s.backends.Mempool != nil {
+		resp.MempoolSize = s.backends.Mempool.Size()
+	}
+	if sup := s.backends.Supervisor; sup != nil {
+		resp.Degraded = sup.Degraded()
+		for _, st := range sup.Status() {
+			resp.Subsystems = append(resp.Subsystems, SubsystemHealth{
+				Name:      st.Name,
+				State:     string(st.State),
+				Restarts:  st.Restarts,
+				LastPanic: st.LastPanic,
+			})
+		}
+	}
+
+	return resp, nil
+}
+
+// GetSupply returns coin supply figures, at a past height from the chain
+// state on archive nodes
+func (s *Server) GetSupply(ctx context.Context, req *GetSupplyRequest) (*GetSupplyResponse, error) {
+	if req.Height > 0 {
+		_, st, err := s.historicalState(ctx, req.Height)
+		if err != nil {
+			return nil, err
+		}
+		return &GetSupplyResponse{Height: req.Height, Circulating: st.Supply, TotalMinted: st.Supply}, nil
+	}
+	if s.backends.Supply == nil {
+		return nil, status.Error(codes.Unimplemented, "supply not available")
+	}
+
+	return &GetSupplyResponse{
+		Circulating: s.backends.Supply.GetCirculatingSupply(),
+		TotalMinted: s.backends.Supply.GetTotalMinted(),
+		TotalBurned: s.backends.Supply.GetTotalBurned(),
+	}, nil
+}
+
+// CaptureDiagnostics records a diagnostics bundle on the node. The call
+// blocks for the capture duration.
+func (s *Server) CaptureDiagnostics(ctx context.Context, req *CaptureDiagnosticsRequest) (*CaptureDiagnosticsResponse, error) {
+	if s.backends.Diagnostics == nil {
+		return nil, status.Error(codes.Unimplemented, "diagnostics not enabled")
+	}
+	if req.Seconds < 0 {
+		return nil, status.Error(codes.InvalidArgument, "seconds must be positive")
+	}
+
+	path, err := s.backends.Diagnostics.CaptureBundle(ctx, time.Duration(req.Seconds)*time.Second)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	return &CaptureDiagnosticsResponse{Path: path}, nil
+}
+
+// GetPeers returns the connected peers and their protocol statistics.
+// The misbehavior log is only included when Verbose is set.
+func (s *Server) GetPeers(ctx context.Context, req *GetPeersRequest) (*GetPeersResponse, error) {
+	if s.backends.Peers == nil {
+		return nil, status.Error(codes.Unimplemented, "p2p not available")
+	}
+
+	resp := &GetPeersResponse{}
+	for _, p := range s.backends.Peers.Peers() {
+		ps := PeerStatus{
+			ID:                  p.ID.String(),
+			ConnectedAt:         p.ConnectedAt.Unix(),
+			LastSeen:            p.LastSeen.Unix(),
+			Version:             p.Version,
+			Roles:               p.Roles.Strings(),
+			Height:              p.Height,
+			BlocksRelayed:       p.Stats.BlocksRelayed,
+			InvalidMessages:     p.Stats.InvalidMessages,
+			DuplicateMessages:   p.Stats.DuplicateMessages,
+			BytesIn:             p.Stats.BytesIn,
+			BytesOut:            p.Stats.BytesOut,
+			AvgValidationMillis: float64(p.Stats.AvgValidationLatency) / float64(time.Millisecond),
+			Misbehaviors:        len(p.Stats.Misbehavior),
+			Score:               p.Score,
+			GossipScore:         p.GossipScore,
+			Outbound:            p.Outbound,
+			Network:             p.Network,
+		}
+		for _, addr := range p.Addrs {
+			ps.Addrs = append(ps.Addrs, addr.String())
+		}
+		if req.Verbose {
+			for _, m := range p.Stats.Misbehavior {
+				ps.Misbehavior = append(ps.Misbehavior, MisbehaviorEntry{
+					Time:   m.Time.Unix(),
+					Topic:  m.Topic,
+					Reason: m.Reason,
+				})
+			}
+		}
+		resp.Peers = append(resp.Peers, ps)
+	}
+	sort.Slice(resp.Peers, func(i, j int) bool { return resp.Peers[i].ConnectedAt < resp.Peers[j].ConnectedAt })
+
+	return resp, nil
+}
+
+// GetNetInfo breaks the connected peers down by direction and by network,
+// warning when they are concentrated in one network
+func (s *Server) GetNetInfo(ctx context.Context, req *GetNetInfoRequest) (*GetNetInfoResponse, error) {
+	if s.backends.Peers == nil {
+		return nil, status.Error(codes.Unimplemented, "p2p not available")
+	}
+	diversity, ok := s.backends.Peers.(DiversityBackend)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "peer networks not available")
+	}
+
+	stats := diversity.Diversity()
+	resp := &GetNetInfoResponse{
+		Peers:    stats.Peers,
+		Inbound:  stats.Peers - stats.Outbound,
+		Outbound: stats.Outbound,
+		Networks: []NetworkEntry{},
+		Warnings: stats.Warnings,
+	}
+	for _, np := range stats.Networks {
+		resp.Networks = append(resp.Networks, NetworkEntry(np))
+	}
+	return resp, nil
+}
+
+// GetReachability reports whether peers can reach the node, its direct
+// and relayed addresses and how hole punching is faring
+func (s *Server) GetReachability(ctx context.Context, req *GetReachabilityRequest) (*GetReachabilityResponse, error) {
+	if s.backends.Peers == nil {
+		return nil, status.Error(codes.Unimplemented, "p2p not available")
+	}
+	nat, ok := s.backends.Peers.(NATBackend)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "NAT status not available")
+	}
+
+	st := nat.NATStatus()
+	resp := &GetReachabilityResponse{
+		Reachability:         strings.ToLower(st.Reachability.String()),
+		Addrs:                []string{},
+		HolePunching:         st.HolePunching,
+		RelayService:         st.RelayService,
+		HolePunchesSucceeded: st.HolePunchesSucceeded,
+		HolePunchesFailed:    st.HolePunchesFailed,
+	}
+	for _, addr := range st.Addrs {
+		resp.Addrs = append(resp.Addrs, addr.String())
+	}
+	for _, addr := range st.RelayAddrs {
+		resp.RelayAddrs = append(resp.RelayAddrs, addr.String())
+	}
+	for _, id := range st.StaticRelays {
+		resp.StaticRelays = append(resp.StaticRelays, id.String())
+	}
+	return resp, nil
+}
+
+// ListBans returns the peer bans in force
+func (s *Server) ListBans(ctx context.Context, req *ListBansRequest) (*ListBansResponse, error) {
+	if s.backends.Bans == nil {
+		return nil, status.Error(codes.Unimplemented, "ban list not available")
+	}
+
+	resp := &ListBansResponse{Bans: []BanEntry{}}
+	for _, b := range s.backends.Bans.Bans() {
+		resp.Bans = append(resp.Bans, BanEntry(b))
+	}
+	return resp, nil
+}
+
+// AddBans bans peers, addresses or subnets, disconnecting matching peers.
+// Bans keep their source, so an exported list imports unchanged.
+func (s *Server) AddBans(ctx context.Context, req *AddBansRequest) (*AddBansResponse, error) {
+	if s.backends.Bans == nil {
+		return nil, status.Error(codes.Unimplemented, "ban list not available")
+	}
+
+	bans := make([]p2p.Ban, len(req.Bans))
+	for i, b := range req.Bans {
+		bans[i] = p2p.Ban(b)
+	}
+	added, err := s.backends.Bans.Ban(bans...)
+	if errors.Is(err, p2p.ErrInvalidBanTarget) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &AddBansResponse{Added: added}, nil
+}
+
+// RemoveBan lifts the ban of a target
+func (s *Server) RemoveBan(ctx context.Context, req *RemoveBanRequest) (*RemoveBanResponse, error) {
+	if s.backends.Bans == nil {
+		return nil, status.Error(codes.Unimplemented, "ban list not available")
+	}
+
+	removed, err := s.backends.Bans.Unban(req.Target)
+	if errors.Is(err, p2p.ErrInvalidBanTarget) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &RemoveBanResponse{Removed: removed}, nil
+}
+
+// ============================================================================
+// DAGService
+// ============================================================================
+
+// GetBlock returns a block by hash
+func (s *Server) GetBlock(ctx context.Context, req *GetBlockRequest) (*GetBlockResponse, error) {
+	if s.backends.DAG == nil {
+		return nil, status.Error(codes.Unimplemented, "dag not available")
+	}
+
+	hash, err := parseHash(req.Hash)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	block, err := s.backends.DAG.GetBlock(ctx, hash)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	return &GetBlockResponse{Block: block}, nil
+}
+
+// GetTips returns the current DAG tips
+func (s *Server) GetTips(ctx context.Context, req *GetTipsRequest) (*GetTipsResponse, error) {
+	if s.backends.DAG == nil {
+		return nil, status.Error(codes.Unimplemented, "dag not available")
+	}
+
+	tips := s.backends.DAG.GetTips()
+	resp := &GetTipsResponse{
+		Tips:         make([]string, len(tips)),
+		MainChainTip: s.backends.DAG.GetMainChainTip().String(),
+		Height:       s.backends.DAG.GetHeight(),
+	}
+	for i, tip := range tips {
+		resp.Tips[i] = tip.String()
+	}
+
+	return resp, nil
+}
+
+// ============================================================================
+// TxService
+// ============================================================================
+
+// SubmitTransaction adds a transaction to the mempool
+func (s *Server) SubmitTransaction(ctx context.Context, req *SubmitTransactionRequest) (*SubmitTransactionResponse, error) {
+	if s.backends.Mempool == nil {
+		return nil, status.Error(codes.Unimplemented, "mempool not available")
+	}
+	if req.Transaction == nil {
+		return nil, status.Error(codes.InvalidArgument, "missing transaction")
+	}
+
+	tx := req.Transaction
+	tx.TxHash = tx.ComputeHash()
+
+	txHash, err := s.backends.Mempool.Submit(req.RequestID, tx)
+	if err != nil {
+		return nil, status.Error(submitErrorCode(err), err.Error())
+	}
+
+	return &SubmitTransactionResponse{TxHash: txHash.String()}, nil
+}
+
+// GetTransaction returns a pending transaction by hash
+func (s *Server) GetTransaction(ctx context.Context, req *GetTransactionRequest) (*GetTransactionResponse, error) {
+	if s.backends.Mempool == nil {
+		return nil, status.Error(codes.Unimplemented, "mempool not available")
+	}
+
+	hash, err := parseHash(req.TxHash)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	tx := s.backends.Mempool.Get(hash)
+	if tx == nil {
+		return nil, status.Error(codes.NotFound, "transaction not found")
+	}
+
+	return &GetTransactionResponse{Transaction: tx, Pending: true}, nil
+}
+
+// EstimateFee suggests a fee for confirmation within the target number
+// of blocks
+func (s *Server) EstimateFee(ctx context.Context, req *EstimateFeeRequest) (*EstimateFeeResponse, error) {
+	if s.backends.Fees == nil {
+		return nil, status.Error(codes.Unimplemented, "fee estimation not available")
+	}
+
+	est, err := s.backends.Fees.Estimate(req.TargetBlocks)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	gas := req.Gas
+	if gas == 0 {
+		gas = economics.StandardSendGas
+	}
+	return &EstimateFeeResponse{
+		TargetBlocks: est.TargetBlocks,
+		Gas:          gas,
+		Fee:          est.Fee(gas),
+		FeeRate:      est.FeeRate,
+		BaseFee:      est.BaseFee,
+		MempoolRate:  est.MempoolRate,
+		HistoryRate:  est.HistoryRate,
+		MinFeeRate:   est.MinFeeRate,
+	}, nil
+}
+
+// submitErrorCode maps mempool errors to gRPC status codes
+func submitErrorCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, mempool.ErrTxAlreadyExists):
+		return codes.AlreadyExists
+	case errors.Is(err, mempool.ErrPoolFull):
+		return codes.ResourceExhausted
+	case errors.Is(err, mempool.ErrRequestIDConflict), errors.Is(err, mempool.ErrReplacementUnderpriced):
+		return codes.FailedPrecondition
+	default:
+		return codes.InvalidArgument
+	}
+}
+
+// ============================================================================
+// WalletService
+// ============================================================================
+
+// GetBalance returns the wallet balance
+func (s *Server) GetBalance(ctx context.Context, req *GetBalanceRequest) (*GetBalanceResponse, error) {
+	if s.backends.Wallet == nil {
+		return nil, status.Error(codes.Unimplemented, "wallet not enabled")
+	}
+
+	bal, err := s.backends.Wallet.Balance(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &GetBalanceResponse{
+		Confirmed: bal.Confirmed,
+		Pending:   bal.Pending,
+		Shielded:  bal.Shielded,
+	}, nil
+}
+
+// ListAddresses returns the wallet's addresses
+func (s *Server) ListAddresses(ctx context.Context, req *ListAddressesRequest) (*ListAddressesResponse, error) {
+	if s.backends.Wallet == nil {
+		return nil, status.Error(codes.Unimplemented, "wallet not enabled")
+	}
+
+	shielded, err := paymentAddressStrings(s.backends.Wallet, s.backends.Wallet.ShieldedAddresses())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &ListAddressesResponse{
+		Transparent: addressStrings(s.backends.Wallet.Addresses()),
+		Shielded:    shielded,
+	}, nil
+}
+
+// UnlockWallet unlocks the wallet, re-locking after Seconds if set
+func (s *Server) UnlockWallet(ctx context.Context, req *UnlockWalletRequest) (*UnlockWalletResponse, error) {
+	w := s.backends.Wallet
+	if w == nil {
+		return nil, status.Error(codes.Unimplemented, "wallet not enabled")
+	}
+
+	if err := w.Unlock(req.Password); err != nil {
+		if errors.Is(err, wallet.ErrWrongPassword) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if req.Seconds > 0 {
+		time.AfterFunc(time.Duration(req.Seconds)*time.Second, w.Lock)
+	}
+
+	return &UnlockWalletResponse{Locked: w.IsLocked()}, nil
+}
+
+// NewAddress derives a new wallet address
+func (s *Server) NewAddress(ctx context.Context, req *NewAddressRequest) (*NewAddressResponse, error) {
+	w := s.backends.Wallet
+	if w == nil {
+		return nil, status.Error(codes.Unimplemented, "wallet not enabled")
+	}
+
+	if !req.Shielded {
+		addr, err := w.NewAddress()
+		if err != nil {
+			return nil, walletError(err)
+		}
+		return &NewAddressResponse{Address: common.BytesToHex(addr[:])}, nil
+	}
+
+	addr, err := w.NewShieldedAddress()
+	if err != nil {
+		return nil, walletError(err)
+	}
+	shielded, err := paymentAddressStrings(w, []types.Address{addr})
+	if err != nil {
+		return nil, walletError(err)
+	}
+	return &NewAddressResponse{Address: shielded[0]}, nil
+}
+
+// walletError maps wallet errors to gRPC status errors
+func walletError(err error) error {
+	if errors.Is(err, wallet.ErrLocked) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// paymentAddressStrings hex-encodes shielded addresses with their
+// transmission keys, the form senders need to encrypt notes
+func paymentAddressStrings(w WalletBackend, addrs []types.Address) ([]string, error) {
+	out := make([]string, len(addrs))
+	for i, a := range addrs {
+		key, err := w.TransmissionKey(a)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = common.BytesToHex(append(a[:], key...))
+	}
+	return out, nil
+}
+
+// addressStrings hex-encodes addresses
+func addressStrings(addrs []types.Address) []string {
+	out := make([]string, len(addrs))
+	for i, a := range addrs {
+		out[i] = common.BytesToHex(a[:])
+	}
+	return out
+}
+
+// parseAddress decodes a hex address string
+func parseAddress(s string) (types.Address, error) {
+	b, err := common.HexToBytes(s)
+	if err != nil || len(b) != types.AddressSize {
+		return types.Address{}, ErrInvalidAddress
+	}
+	var addr types.Address
+	copy(addr[:], b)
+	return addr, nil
+}
+
+// parsePaymentAddress decodes a recipient: a shielded payment address
+// (address followed by transmission key) or a bare address, whose notes
+// the recipient's wallet cannot scan for
+func parsePaymentAddress(s string) (types.Address, []byte, error) {
+	b, err := common.HexToBytes(s)
+	if err != nil {
+		return types.Address{}, nil, ErrInvalidAddress
+	}
+	var addr types.Address
+	switch len(b) {
+	case types.AddressSize:
+		copy(addr[:], b)
+		return addr, nil, nil
+	case types.AddressSize + zkp.NoteKeySize:
+		copy(addr[:], b)
+		return addr, b[types.AddressSize:], nil
+	default:
+		return types.Address{}, nil, ErrInvalidAddress
+	}
+}
+
+// parseHash decodes a hex hash string
+func parseHash(s string) (types.Hash, error) {
+	b, err := common.HexToBytes(s)
+	if err != nil || len(b) != types.HashSize {
+		return types.Hash{}, ErrInvalidHash
+	}
+	return types.HashFromBytes(b), nil
+}
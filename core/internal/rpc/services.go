@@ -0,0 +1,237 @@
+package rpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// NodeServiceServer is the server API for NodeService
+type NodeServiceServer interface {
+	GetStatus(context.Context, *GetStatusRequest) (*GetStatusResponse, error)
+	GetSupply(context.Context, *GetSupplyRequest) (*GetSupplyResponse, error)
+	GetState(context.Context, *GetStateRequest) (*GetStateResponse, error)
+	CaptureDiagnostics(context.Context, *CaptureDiagnosticsRequest) (*CaptureDiagnosticsResponse, error)
+	GetPeers(context.Context, *GetPeersRequest) (*GetPeersResponse, error)
+	GetNetInfo(context.Context, *GetNetInfoRequest) (*GetNetInfoResponse, error)
+	GetReachability(context.Context, *GetReachabilityRequest) (*GetReachabilityResponse, error)
+	ListBans(context.Context, *ListBansRequest) (*ListBansResponse, error)
+	AddBans(context.Context, *AddBansRequest) (*AddBansResponse, error)
+	RemoveBan(context.Context, *RemoveBanRequest) (*RemoveBanResponse, error)
+}
+
+// DAGServiceServer is the server API for DAGService
+type DAGServiceServer interface {
+	GetBlock(context.Context, *GetBlockRequest) (*GetBlockResponse, error)
+	GetTips(context.Context, *GetTipsRequest) (*GetTipsResponse, error)
+	AuditCommittee(context.Context, *AuditCommitteeRequest) (*AuditCommitteeResponse, error)
+	GetBlockReceipts(context.Context, *GetBlockReceiptsRequest) (*GetBlockReceiptsResponse, error)
+}
+
+// TxServiceServer is the server API for TxService
+type TxServiceServer interface {
+	SubmitTransaction(context.Context, *SubmitTransactionRequest) (*SubmitTransactionResponse, error)
+	GetTransaction(context.Context, *GetTransactionRequest) (*GetTransactionResponse, error)
+	GetReceipt(context.Context, *GetReceiptRequest) (*GetReceiptResponse, error)
+	SendTransaction(context.Context, *SendTransactionRequest) (*SendTransactionResponse, error)
+	SendBatch(context.Context, *SendBatchRequest) (*SendBatchResponse, error)
+	VerifyPaymentDisclosure(context.Context, *VerifyPaymentDisclosureRequest) (*VerifyPaymentDisclosureResponse, error)
+	ExplainDisclosures(context.Context, *ExplainDisclosuresRequest) (*ExplainDisclosuresResponse, error)
+	EstimateFee(context.Context, *EstimateFeeRequest) (*EstimateFeeResponse, error)
+}
+
+// WalletServiceServer is the server API for WalletService
+type WalletServiceServer interface {
+	GetBalance(context.Context, *GetBalanceRequest) (*GetBalanceResponse, error)
+	ListAddresses(context.Context, *ListAddressesRequest) (*ListAddressesResponse, error)
+	UnlockWallet(context.Context, *UnlockWalletRequest) (*UnlockWalletResponse, error)
+	NewAddress(context.Context, *NewAddressRequest) (*NewAddressResponse, error)
+	DisclosePayment(context.Context, *DisclosePaymentRequest) (*DisclosePaymentResponse, error)
+	ListPayments(context.Context, *ListPaymentsRequest) (*ListPaymentsResponse, error)
+}
+
+// AnalyticsServiceServer is the server API for AnalyticsService
+type AnalyticsServiceServer interface {
+	GetDifficultyHistory(context.Context, *GetDifficultyHistoryRequest) (*GetDifficultyHistoryResponse, error)
+	GetQualityHistory(context.Context, *GetQualityHistoryRequest) (*GetQualityHistoryResponse, error)
+	GetReputationHistogram(context.Context, *GetReputationHistogramRequest) (*GetReputationHistogramResponse, error)
+	GetStakingHistory(context.Context, *GetStakingHistoryRequest) (*GetStakingHistoryResponse, error)
+}
+
+// ModelServiceServer is the server API for ModelService
+type ModelServiceServer interface {
+	GetModel(context.Context, *GetModelRequest) (*GetModelResponse, error)
+	GetModelProof(context.Context, *GetModelProofRequest) (*GetModelProofResponse, error)
+}
+
+// GovernanceServiceServer is the server API for GovernanceService
+type GovernanceServiceServer interface {
+	GetVoterHistory(context.Context, *GetVoterHistoryRequest) (*GetVoterHistoryResponse, error)
+	GetProposerHistory(context.Context, *GetProposerHistoryRequest) (*GetProposerHistoryResponse, error)
+	PreviewParameterChange(context.Context, *PreviewParameterChangeRequest) (*PreviewParameterChangeResponse, error)
+}
+
+// MinerServiceServer is the server API for MinerService
+type MinerServiceServer interface {
+	StartMining(context.Context, *StartMiningRequest) (*MiningStatusResponse, error)
+	StopMining(context.Context, *StopMiningRequest) (*MiningStatusResponse, error)
+	GetMiningStatus(context.Context, *GetMiningStatusRequest) (*MiningStatusResponse, error)
+	GetDelegations(context.Context, *GetDelegationsRequest) (*GetDelegationsResponse, error)
+	GetBlockTemplate(context.Context, *GetBlockTemplateRequest) (*BlockTemplateResponse, error)
+	MiningNotifications(*MiningNotificationsRequest, MiningNotificationsStream) error
+}
+
+// MiningNotificationsStream is the server side of a MiningNotifications
+// stream
+type MiningNotificationsStream interface {
+	Send(*MiningNotification) error
+	Context() context.Context
+}
+
+// miningNotificationsStream sends notifications over a gRPC stream
+type miningNotificationsStream struct {
+	grpc.ServerStream
+}
+
+func (x *miningNotificationsStream) Send(n *MiningNotification) error {
+	return x.ServerStream.SendMsg(n)
+}
+
+var nodeServiceDesc = grpc.ServiceDesc{
+	ServiceName: NodeServiceName,
+	HandlerType: (*NodeServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "GetStatus", Handler: unary(NodeServiceName, "GetStatus", NodeServiceServer.GetStatus)},
+		{MethodName: "GetSupply", Handler: unary(NodeServiceName, "GetSupply", NodeServiceServer.GetSupply)},
+		{MethodName: "GetState", Handler: unary(NodeServiceName, "GetState", NodeServiceServer.GetState)},
+		{MethodName: "CaptureDiagnostics", Handler: unary(NodeServiceName, "CaptureDiagnostics", NodeServiceServer.CaptureDiagnostics)},
+		{MethodName: "GetPeers", Handler: unary(NodeServiceName, "GetPeers", NodeServiceServer.GetPeers)},
+		{MethodName: "GetNetInfo", Handler: unary(NodeServiceName, "GetNetInfo", NodeServiceServer.GetNetInfo)},
+		{MethodName: "GetReachability", Handler: unary(NodeServiceName, "GetReachability", NodeServiceServer.GetReachability)},
+		{MethodName: "ListBans", Handler: unary(NodeServiceName, "ListBans", NodeServiceServer.ListBans)},
+		{MethodName: "AddBans", Handler: unary(NodeServiceName, "AddBans", NodeServiceServer.AddBans)},
+		{MethodName: "RemoveBan", Handler: unary(NodeServiceName, "RemoveBan", NodeServiceServer.RemoveBan)},
+	},
+}
+
+var dagServiceDesc = grpc.ServiceDesc{
+	ServiceName: DAGServiceName,
+	HandlerType: (*DAGServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "GetBlock", Handler: unary(DAGServiceName, "GetBlock", DAGServiceServer.GetBlock)},
+		{MethodName: "GetTips", Handler: unary(DAGServiceName, "GetTips", DAGServiceServer.GetTips)},
+		{MethodName: "AuditCommittee", Handler: unary(DAGServiceName, "AuditCommittee", DAGServiceServer.AuditCommittee)},
+		{MethodName: "GetBlockReceipts", Handler: unary(DAGServiceName, "GetBlockReceipts", DAGServiceServer.GetBlockReceipts)},
+	},
+}
+
+var txServiceDesc = grpc.ServiceDesc{
+	ServiceName: TxServiceName,
+	HandlerType: (*TxServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "SubmitTransaction", Handler: unary(TxServiceName, "SubmitTransaction", TxServiceServer.SubmitTransaction)},
+		{MethodName: "GetTransaction", Handler: unary(TxServiceName, "GetTransaction", TxServiceServer.GetTransaction)},
+		{MethodName: "GetReceipt", Handler: unary(TxServiceName, "GetReceipt", TxServiceServer.GetReceipt)},
+		{MethodName: "SendTransaction", Handler: unary(TxServiceName, "SendTransaction", TxServiceServer.SendTransaction)},
+		{MethodName: "SendBatch", Handler: unary(TxServiceName, "SendBatch", TxServiceServer.SendBatch)},
+		{MethodName: "VerifyPaymentDisclosure", Handler: unary(TxServiceName, "VerifyPaymentDisclosure", TxServiceServer.VerifyPaymentDisclosure)},
+		{MethodName: "ExplainDisclosures", Handler: unary(TxServiceName, "ExplainDisclosures", TxServiceServer.ExplainDisclosures)},
+		{MethodName: "EstimateFee", Handler: unary(TxServiceName, "EstimateFee", TxServiceServer.EstimateFee)},
+	},
+}
+
+var walletServiceDesc = grpc.ServiceDesc{
+	ServiceName: WalletServiceName,
+	HandlerType: (*WalletServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "GetBalance", Handler: unary(WalletServiceName, "GetBalance", WalletServiceServer.GetBalance)},
+		{MethodName: "ListAddresses", Handler: unary(WalletServiceName, "ListAddresses", WalletServiceServer.ListAddresses)},
+		{MethodName: "UnlockWallet", Handler: unary(WalletServiceName, "UnlockWallet", WalletServiceServer.UnlockWallet)},
+		{MethodName: "NewAddress", Handler: unary(WalletServiceName, "NewAddress", WalletServiceServer.NewAddress)},
+		{MethodName: "DisclosePayment", Handler: unary(WalletServiceName, "DisclosePayment", WalletServiceServer.DisclosePayment)},
+		{MethodName: "ListPayments", Handler: unary(WalletServiceName, "ListPayments", WalletServiceServer.ListPayments)},
+	},
+}
+
+var analyticsServiceDesc = grpc.ServiceDesc{
+	ServiceName: AnalyticsServiceName,
+	HandlerType: (*AnalyticsServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "GetDifficultyHistory", Handler: unary(AnalyticsServiceName, "GetDifficultyHistory", AnalyticsServiceServer.GetDifficultyHistory)},
+		{MethodName: "GetQualityHistory", Handler: unary(AnalyticsServiceName, "GetQualityHistory", AnalyticsServiceServer.GetQualityHistory)},
+		{MethodName: "GetReputationHistogram", Handler: unary(AnalyticsServiceName, "GetReputationHistogram", AnalyticsServiceServer.GetReputationHistogram)},
+		{MethodName: "GetStakingHistory", Handler: unary(AnalyticsServiceName, "GetStakingHistory", AnalyticsServiceServer.GetStakingHistory)},
+	},
+}
+
+var modelServiceDesc = grpc.ServiceDesc{
+	ServiceName: ModelServiceName,
+	HandlerType: (*ModelServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "GetModel", Handler: unary(ModelServiceName, "GetModel", ModelServiceServer.GetModel)},
+		{MethodName: "GetModelProof", Handler: unary(ModelServiceName, "GetModelProof", ModelServiceServer.GetModelProof)},
+	},
+}
+
+var governanceServiceDesc = grpc.ServiceDesc{
+	ServiceName: GovernanceServiceName,
+	HandlerType: (*GovernanceServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "GetVoterHistory", Handler: unary(GovernanceServiceName, "GetVoterHistory", GovernanceServiceServer.GetVoterHistory)},
+		{MethodName: "GetProposerHistory", Handler: unary(GovernanceServiceName, "GetProposerHistory", GovernanceServiceServer.GetProposerHistory)},
+		{MethodName: "PreviewParameterChange", Handler: unary(GovernanceServiceName, "PreviewParameterChange", GovernanceServiceServer.PreviewParameterChange)},
+	},
+}
+
+var minerServiceDesc = grpc.ServiceDesc{
+	ServiceName: MinerServiceName,
+	HandlerType: (*MinerServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "StartMining", Handler: unary(MinerServiceName, "StartMining", MinerServiceServer.StartMining)},
+		{MethodName: "StopMining", Handler: unary(MinerServiceName, "StopMining", MinerServiceServer.StopMining)},
+		{MethodName: "GetMiningStatus", Handler: unary(MinerServiceName, "GetMiningStatus", MinerServiceServer.GetMiningStatus)},
+		{MethodName: "GetDelegations", Handler: unary(MinerServiceName, "GetDelegations", MinerServiceServer.GetDelegations)},
+		{MethodName: "GetBlockTemplate", Handler: unary(MinerServiceName, "GetBlockTemplate", MinerServiceServer.GetBlockTemplate)},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "MiningNotifications",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				req := new(MiningNotificationsRequest)
+				if err := stream.RecvMsg(req); err != nil {
+					return err
+				}
+				return srv.(MinerServiceServer).MiningNotifications(req, &miningNotificationsStream{stream})
+			},
+			ServerStreams: true,
+		},
+	},
+}
+
+// unary adapts a typed service method to a gRPC method handler
+func unary[S any, Req any, Resp any](
+	service, method string,
+	call func(S, context.Context, *Req) (*Resp, error),
+) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	fullMethod := "/" + service + "/" + method
+
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		req := new(Req)
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+
+		if interceptor == nil {
+			return call(srv.(S), ctx, req)
+		}
+
+		info := &grpc.UnaryServerInfo{
+			Server:     srv,
+			FullMethod: fullMethod,
+		}
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return call(srv.(S), ctx, req.(*Req))
+		}
+		return interceptor(ctx, req, info, handler)
+	}
+}
@@ -0,0 +1,67 @@
+package rpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ccoin/core/internal/reputation"
+	"github.com/ccoin/core/pkg/common"
+)
+
+// GetDelegations returns the stake delegated to a miner, with the miner's
+// own stake, or every delegation made by a delegator. At a past height
+// they come from the archive's checkpoint.
+func (s *Server) GetDelegations(ctx context.Context, req *GetDelegationsRequest) (*GetDelegationsResponse, error) {
+	if (req.Miner == "") == (req.Delegator == "") {
+		return nil, status.Error(codes.InvalidArgument, "exactly one of miner and delegator is required")
+	}
+
+	stakes := s.backends.Stakes
+	resp := &GetDelegationsResponse{Miner: req.Miner, Delegator: req.Delegator}
+	if req.Height > 0 {
+		cp, err := s.checkpoint(req.Height)
+		if err != nil {
+			return nil, err
+		}
+		stakes = cp
+		resp.AsOf = cp.Height
+	}
+	if stakes == nil {
+		return nil, status.Error(codes.Unimplemented, "staking not available")
+	}
+
+	var delegations []*reputation.Delegation
+	if req.Miner != "" {
+		miner, err := parseAddress(req.Miner)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if stake := stakes.GetStakeInfo(miner); stake != nil {
+			resp.OwnStake = stake.AvailableStake
+		}
+		resp.DelegatedStake = stakes.DelegatedStake(miner)
+		delegations = stakes.Delegations(miner)
+	} else {
+		delegator, err := parseAddress(req.Delegator)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		delegations = stakes.DelegationsBy(delegator)
+	}
+
+	resp.Delegations = make([]DelegationEntry, 0, len(delegations))
+	for _, d := range delegations {
+		resp.Delegations = append(resp.Delegations, DelegationEntry{
+			Delegator:      common.BytesToHex(d.Delegator[:]),
+			Miner:          common.BytesToHex(d.Miner[:]),
+			Bonded:         d.Bonded,
+			Unbonding:      d.Unbonding,
+			UnbondingUntil: d.UnbondingUntil,
+			Rewards:        d.Rewards,
+			Slashed:        d.Slashed,
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,187 @@
+// Package sanctions maintains the governance-managed sanctions list that
+// sanctions compliance disclosures prove non-membership in.
+package sanctions
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Registry errors
+var (
+	ErrNotSanctionsProposal = errors.New("not a sanctions update proposal")
+	ErrProposalNotPassed    = errors.New("sanctions update proposal has not passed")
+	ErrRootMismatch         = errors.New("sanctions update does not produce the proposed root")
+)
+
+// Store persists the sanctions list
+type Store interface {
+	// LoadSanctions returns every listed address
+	LoadSanctions(ctx context.Context) ([]types.Address, error)
+
+	// SaveSanctions records a list change made by a proposal
+	SaveSanctions(ctx context.Context, add, remove []types.Address, proposalID types.Hash) error
+}
+
+// Registry holds the sanctions list as a sparse Merkle tree. The list only
+// changes through passed sanctions update proposals, and its root is what
+// sanctions disclosures are verified against.
+type Registry struct {
+	mu sync.RWMutex
+
+	tree *zkp.SanctionsTree
+
+	// Proposals already applied
+	applied map[types.Hash]bool
+
+	store Store
+}
+
+// NewRegistry creates an empty sanctions registry
+func NewRegistry(store Store) *Registry {
+	return &Registry{
+		tree:    zkp.NewSanctionsTree(),
+		applied: make(map[types.Hash]bool),
+		store:   store,
+	}
+}
+
+// Initialize loads the list from the store
+func (r *Registry) Initialize(ctx context.Context) error {
+	addrs, err := r.store.LoadSanctions(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tree = zkp.NewSanctionsTree(addrs...)
+	return nil
+}
+
+// Root returns the current sanctions tree root
+func (r *Registry) Root() types.Hash {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.tree.Root()
+}
+
+// SanctionsRoot implements zkp.SanctionsList
+func (r *Registry) SanctionsRoot() types.Hash {
+	return r.Root()
+}
+
+// IsSanctioned reports whether addr is listed
+func (r *Registry) IsSanctioned(addr types.Address) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.tree.Contains(addr)
+}
+
+// Members returns the listed addresses in ascending order
+func (r *Registry) Members() []types.Address {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.tree.Members()
+}
+
+// Prove returns the current root and a non-membership path for addr, for
+// building a sanctions disclosure. It fails with zkp.ErrAddressSanctioned
+// for listed addresses.
+func (r *Registry) Prove(addr types.Address) (types.Hash, *zkp.SanctionsPath, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	path, err := r.tree.Prove(addr)
+	if err != nil {
+		return types.Hash{}, nil, err
+	}
+	return r.tree.Root(), path, nil
+}
+
+// PreviewUpdate returns the root the list would have after a change, for
+// filling in a sanctions update proposal
+func (r *Registry) PreviewUpdate(add, remove []types.Address) types.Hash {
+	r.mu.RLock()
+	next := r.tree.Clone()
+	r.mu.RUnlock()
+
+	next.Update(add, remove)
+	return next.Root()
+}
+
+// ApplyProposal applies a passed sanctions update proposal. The change
+// must produce the root voters approved; each proposal applies once.
+func (r *Registry) ApplyProposal(ctx context.Context, proposal *types.Proposal) error {
+	if proposal.Type != types.ProposalSanctionsUpdate {
+		return ErrNotSanctionsProposal
+	}
+	if proposal.Status != types.ProposalStatusPassed && proposal.Status != types.ProposalStatusExecuted {
+		return ErrProposalNotPassed
+	}
+	data, ok := proposal.Data.(*types.SanctionsUpdateData)
+	if !ok {
+		return ErrNotSanctionsProposal
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.applied[proposal.ProposalID] {
+		return nil
+	}
+
+	next := r.tree.Clone()
+	next.Update(data.Add, data.Remove)
+	if next.Root() != data.Root {
+		return ErrRootMismatch
+	}
+
+	if err := r.store.SaveSanctions(ctx, data.Add, data.Remove, proposal.ProposalID); err != nil {
+		return err
+	}
+	r.tree = next
+	r.applied[proposal.ProposalID] = true
+	return nil
+}
+
+// InMemoryStore is a simple in-memory Store for testing
+type InMemoryStore struct {
+	mu      sync.Mutex
+	members map[types.Address]struct{}
+}
+
+// NewInMemoryStore creates an empty in-memory store
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{members: make(map[types.Address]struct{})}
+}
+
+// LoadSanctions returns every listed address
+func (s *InMemoryStore) LoadSanctions(ctx context.Context) ([]types.Address, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	addrs := make([]types.Address, 0, len(s.members))
+	for addr := range s.members {
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// SaveSanctions records a list change
+func (s *InMemoryStore) SaveSanctions(ctx context.Context, add, remove []types.Address, proposalID types.Hash) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, addr := range add {
+		s.members[addr] = struct{}{}
+	}
+	for _, addr := range remove {
+		delete(s.members, addr)
+	}
+	return nil
+}
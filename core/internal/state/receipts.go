@@ -0,0 +1,83 @@
+package state
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ccoin/core/internal/dag"
+	"github.com/ccoin/core/internal/economics"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// ErrNoReceipts is returned for blocks whose receipts cannot be derived:
+// the block a snapshot was restored at, whose transactions were not
+// applied locally
+var ErrNoReceipts = errors.New("receipts not available for block")
+
+// NextReceiptsRoot returns the receipts root a block with the given
+// selected parent must commit to
+func (m *Manager) NextReceiptsRoot(ctx context.Context, parent types.Hash, block *types.Block) (types.Hash, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.stateLocked(ctx, parent)
+	if err != nil {
+		return types.Hash{}, err
+	}
+	next, err := m.apply(ctx, state, parent, block)
+	if err != nil {
+		return types.Hash{}, err
+	}
+	return dag.ComputeReceiptsRoot(next.Receipts), nil
+}
+
+// Receipts returns the receipts of a stored block's transactions, in
+// block order. Receipts not in the cache are rebuilt from stored blocks.
+func (m *Manager) Receipts(ctx context.Context, hash types.Hash) ([]*types.Receipt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, pinned := m.pinned[hash]; pinned {
+		return nil, ErrNoReceipts
+	}
+	state, err := m.stateLocked(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	return state.Receipts, nil
+}
+
+// blockReceipts derives the receipts of a block's transactions. The
+// block's first output commitment takes position firstCommitment in the
+// note commitment tree.
+func blockReceipts(txs []*types.Transaction, firstCommitment uint64) []*types.Receipt {
+	if len(txs) == 0 {
+		return nil
+	}
+
+	receipts := make([]*types.Receipt, len(txs))
+	spent := make(map[types.Hash]bool)
+	position := firstCommitment
+	for i, tx := range txs {
+		r := &types.Receipt{
+			TxHash:  tx.TxHash,
+			Status:  types.ReceiptAccepted,
+			GasUsed: economics.EstimateGas(tx),
+		}
+		for _, nullifier := range tx.Nullifiers {
+			if spent[nullifier] {
+				r.Status = types.ReceiptConflict
+			}
+			spent[nullifier] = true
+		}
+		for _, d := range tx.Disclosures {
+			r.VerifiedDisclosures = append(r.VerifiedDisclosures, d.Type)
+		}
+		for range tx.Commitments {
+			r.CommitmentPositions = append(r.CommitmentPositions, position)
+			position++
+		}
+		receipts[i] = r
+	}
+	return receipts
+}
@@ -0,0 +1,480 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Snapshot errors
+var (
+	ErrInvalidSnapshot = errors.New("invalid state snapshot")
+	ErrNoSnapshot      = errors.New("no state snapshot available")
+)
+
+// snapshotMagic opens every encoded snapshot
+var snapshotMagic = []byte("CCSS")
+
+// SnapshotVersion is the snapshot encoding version
+const SnapshotVersion = 1
+
+// Snapshot is the chain state after a block in a form a node can start
+// from without the blocks before it: the state tree's leaves, the note
+// commitment tree frontier and every nullifier spent. It is checked
+// against the block header's nullifier and state roots.
+type Snapshot struct {
+	Hash   types.Hash
+	Height uint64
+
+	Supply   uint64
+	Treasury uint64
+	Miners   map[types.Address]*MinerAccount
+
+	Commitments *zkp.Frontier
+	Nullifiers  []types.Hash
+}
+
+// NullifierRoots returns the nullifier accumulator the manager keeps,
+// which computes the nullifier roots headers commit to
+func (m *Manager) NullifierRoots() *zkp.ChainAccumulator {
+	return m.nullifiers
+}
+
+// CommitmentRoots returns the commitment accumulator the manager keeps,
+// whose roots are the commitment roots in block states
+func (m *Manager) CommitmentRoots() *zkp.ChainAccumulator {
+	return m.commitments
+}
+
+// Snapshot returns a snapshot of the state after a stored block
+func (m *Manager) Snapshot(ctx context.Context, hash types.Hash) (*Snapshot, error) {
+	block, err := m.source.GetBlock(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	state, err := m.State(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	commitments, err := m.commitments.Frontier(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	nullifiers, err := m.nullifiers.Leaves(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{
+		Hash:        hash,
+		Height:      block.Header.Height,
+		Supply:      state.Supply,
+		Treasury:    state.Treasury,
+		Miners:      state.Miners,
+		Commitments: commitments,
+		Nullifiers:  nullifiers,
+	}, nil
+}
+
+// Restore checks a snapshot against its block header and makes the
+// snapshotted state the state after that block, so blocks built on it
+// are validated without the blocks before it
+func (m *Manager) Restore(header *types.BlockHeader, snap *Snapshot) error {
+	if header.ComputeHash() != header.Hash || snap.Hash != header.Hash || snap.Height != header.Height {
+		return ErrInvalidSnapshot
+	}
+
+	nullifiers, err := m.nullifiers.BuildFrontier(snap.Nullifiers)
+	if err != nil {
+		return ErrInvalidSnapshot
+	}
+	if root, err := m.nullifiers.FrontierRoot(nullifiers); err != nil || root != header.NullifierRoot {
+		return ErrInvalidSnapshot
+	}
+	commitmentRoot, err := m.commitments.FrontierRoot(snap.Commitments)
+	if err != nil {
+		return ErrInvalidSnapshot
+	}
+
+	state := &State{
+		NullifierRoot:  header.NullifierRoot,
+		CommitmentRoot: commitmentRoot,
+		Supply:         snap.Supply,
+		Treasury:       snap.Treasury,
+		Miners:         snap.Miners,
+	}
+	if state.Miners == nil {
+		state.Miners = make(map[types.Address]*MinerAccount)
+	}
+	if state.Root() != header.StateRoot {
+		return ErrInvalidSnapshot
+	}
+
+	if err := m.nullifiers.Restore(header.Hash, nullifiers); err != nil {
+		return err
+	}
+	if err := m.commitments.Restore(header.Hash, snap.Commitments); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.pinned[header.Hash] = state
+	m.mu.Unlock()
+	return nil
+}
+
+// EncodeSnapshot serializes a snapshot. Miners are written in address
+// order so equal snapshots encode identically.
+func EncodeSnapshot(snap *Snapshot) ([]byte, error) {
+	if snap.Commitments == nil || len(snap.Commitments.Nodes) != zkp.TreeDepth {
+		return nil, zkp.ErrInvalidFrontier
+	}
+
+	size := 4 + 1 + types.HashSize + 8*4 + 1 + len(snap.Commitments.Nodes)*types.HashSize +
+		4 + len(snap.Miners)*(types.AddressSize+24) + 8 + len(snap.Nullifiers)*types.HashSize
+	buf := make([]byte, 0, size)
+
+	buf = append(buf, snapshotMagic...)
+	buf = append(buf, SnapshotVersion)
+	buf = append(buf, snap.Hash[:]...)
+	buf = binary.BigEndian.AppendUint64(buf, snap.Height)
+	buf = binary.BigEndian.AppendUint64(buf, snap.Supply)
+	buf = binary.BigEndian.AppendUint64(buf, snap.Treasury)
+
+	buf = binary.BigEndian.AppendUint64(buf, snap.Commitments.Size)
+	buf = append(buf, uint8(len(snap.Commitments.Nodes)))
+	for _, node := range snap.Commitments.Nodes {
+		buf = append(buf, node[:]...)
+	}
+
+	addrs := make([]types.Address, 0, len(snap.Miners))
+	for addr := range snap.Miners {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return bytes.Compare(addrs[i][:], addrs[j][:]) < 0 })
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(addrs)))
+	for _, addr := range addrs {
+		account := snap.Miners[addr]
+		buf = append(buf, addr[:]...)
+		buf = binary.BigEndian.AppendUint64(buf, account.Blocks)
+		buf = binary.BigEndian.AppendUint64(buf, account.Rewards)
+		buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(account.Reputation))
+	}
+
+	buf = binary.BigEndian.AppendUint64(buf, uint64(len(snap.Nullifiers)))
+	for _, nullifier := range snap.Nullifiers {
+		buf = append(buf, nullifier[:]...)
+	}
+
+	return buf, nil
+}
+
+// DecodeSnapshot deserializes a snapshot
+func DecodeSnapshot(data []byte) (*Snapshot, error) {
+	r := &snapshotReader{data: data}
+	if !bytes.Equal(r.bytes(len(snapshotMagic)), snapshotMagic) || r.uint8() != SnapshotVersion {
+		return nil, ErrInvalidSnapshot
+	}
+
+	snap := &Snapshot{
+		Hash:        r.hash(),
+		Height:      r.uint64(),
+		Supply:      r.uint64(),
+		Treasury:    r.uint64(),
+		Commitments: &zkp.Frontier{Size: r.uint64()},
+		Miners:      make(map[types.Address]*MinerAccount),
+	}
+
+	nodes := int(r.uint8())
+	if nodes != zkp.TreeDepth {
+		return nil, ErrInvalidSnapshot
+	}
+	for i := 0; i < nodes; i++ {
+		snap.Commitments.Nodes = append(snap.Commitments.Nodes, r.hash())
+	}
+
+	miners := uint64(r.uint32())
+	if miners > uint64(len(r.data))/(types.AddressSize+24) {
+		return nil, ErrInvalidSnapshot
+	}
+	for i := uint64(0); i < miners; i++ {
+		var addr types.Address
+		copy(addr[:], r.bytes(types.AddressSize))
+		snap.Miners[addr] = &MinerAccount{
+			Blocks:     r.uint64(),
+			Rewards:    r.uint64(),
+			Reputation: math.Float64frombits(r.uint64()),
+		}
+	}
+
+	nullifiers := r.uint64()
+	if nullifiers > uint64(len(r.data))/types.HashSize {
+		return nil, ErrInvalidSnapshot
+	}
+	snap.Nullifiers = make([]types.Hash, nullifiers)
+	for i := range snap.Nullifiers {
+		snap.Nullifiers[i] = r.hash()
+	}
+
+	if r.err != nil || len(r.data) != 0 {
+		return nil, ErrInvalidSnapshot
+	}
+	return snap, nil
+}
+
+// snapshotReader decodes big-endian fields, latching the first short read
+type snapshotReader struct {
+	data []byte
+	err  error
+}
+
+func (r *snapshotReader) bytes(n int) []byte {
+	if r.err != nil || n > len(r.data) {
+		r.err = ErrInvalidSnapshot
+		return nil
+	}
+	out := r.data[:n]
+	r.data = r.data[n:]
+	return out
+}
+
+func (r *snapshotReader) uint8() uint8 {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *snapshotReader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *snapshotReader) uint64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *snapshotReader) hash() types.Hash {
+	var h types.Hash
+	copy(h[:], r.bytes(types.HashSize))
+	return h
+}
+
+// MainChain provides the main chain blocks are snapshotted along
+type MainChain interface {
+	GetHeight() uint64
+	GetMainChain(ctx context.Context, fromHeight, toHeight uint64) ([]*types.BlockHeader, error)
+}
+
+// SnapshotConfig holds snapshot configuration
+type SnapshotConfig struct {
+	// Dir is where snapshots are written
+	Dir string
+
+	// Interval is the number of heights between snapshots
+	Interval uint64
+
+	// Depth is how far below the chain height a block must be before it
+	// is snapshotted, so snapshots stay on the main chain
+	Depth uint64
+
+	// Keep is the number of snapshots kept on disk
+	Keep int
+}
+
+// DefaultSnapshotConfig returns default snapshot configuration
+func DefaultSnapshotConfig() *SnapshotConfig {
+	return &SnapshotConfig{
+		Interval: types.EpochLength,
+		Depth:    100,
+		Keep:     2,
+	}
+}
+
+// Snapshotter writes a snapshot every Interval heights of the main chain
+// and restores snapshots fetched from peers
+type Snapshotter struct {
+	mu sync.Mutex
+
+	manager *Manager
+	chain   MainChain
+	config  *SnapshotConfig
+
+	// Height of the last snapshot written
+	last uint64
+}
+
+// NewSnapshotter creates a snapshotter over the states of manager
+func NewSnapshotter(manager *Manager, chain MainChain, cfg *SnapshotConfig) (*Snapshotter, error) {
+	if cfg == nil {
+		cfg = DefaultSnapshotConfig()
+	}
+	if cfg.Interval == 0 {
+		return nil, errors.New("snapshot interval must be positive")
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &Snapshotter{manager: manager, chain: chain, config: cfg}
+	if heights, err := s.heights(); err == nil && len(heights) > 0 {
+		s.last = heights[len(heights)-1]
+	}
+	return s, nil
+}
+
+// Update snapshots the latest main chain block due for one. It is called
+// as blocks are added.
+func (s *Snapshotter) Update(ctx context.Context) error {
+	height := s.chain.GetHeight()
+	if height < s.config.Depth {
+		return nil
+	}
+	target := (height - s.config.Depth) / s.config.Interval * s.config.Interval
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if target == 0 || target <= s.last {
+		return nil
+	}
+
+	headers, err := s.chain.GetMainChain(ctx, target, target)
+	if err != nil {
+		return err
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+	snap, err := s.manager.Snapshot(ctx, headers[0].Hash)
+	if err != nil {
+		return err
+	}
+	data, err := EncodeSnapshot(snap)
+	if err != nil {
+		return err
+	}
+	if err := s.saveLocked(target, data); err != nil {
+		return err
+	}
+	s.last = target
+	return nil
+}
+
+// LatestSnapshot returns the height, block hash and encoding of the
+// latest snapshot written
+func (s *Snapshotter) LatestSnapshot(ctx context.Context) (uint64, types.Hash, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.last == 0 {
+		return 0, types.Hash{}, nil, ErrNoSnapshot
+	}
+	data, err := os.ReadFile(s.path(s.last))
+	if err != nil {
+		return 0, types.Hash{}, nil, err
+	}
+	snap, err := DecodeSnapshot(data)
+	if err != nil {
+		return 0, types.Hash{}, nil, err
+	}
+	return snap.Height, snap.Hash, data, nil
+}
+
+// LatestHeight returns the height of the latest snapshot written, zero
+// if there is none
+func (s *Snapshotter) LatestHeight() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+// RestoreSnapshot restores the state from an encoded snapshot of the
+// block with header, and keeps the snapshot to serve to other nodes
+func (s *Snapshotter) RestoreSnapshot(ctx context.Context, header *types.BlockHeader, data []byte) error {
+	snap, err := DecodeSnapshot(data)
+	if err != nil {
+		return err
+	}
+	if err := s.manager.Restore(header, snap); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if snap.Height <= s.last {
+		return nil
+	}
+	if err := s.saveLocked(snap.Height, data); err != nil {
+		return err
+	}
+	s.last = snap.Height
+	return nil
+}
+
+// saveLocked writes a snapshot and prunes all but the latest Keep
+func (s *Snapshotter) saveLocked(height uint64, data []byte) error {
+	path := s.path(height)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	heights, err := s.heights()
+	if err != nil {
+		return err
+	}
+	for len(heights) > s.config.Keep && s.config.Keep > 0 {
+		if err := os.Remove(s.path(heights[0])); err != nil {
+			return err
+		}
+		heights = heights[1:]
+	}
+	return nil
+}
+
+// heights returns the heights of the snapshots on disk, ascending
+func (s *Snapshotter) heights() ([]uint64, error) {
+	entries, err := os.ReadDir(s.config.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var heights []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, ".snap") {
+			continue
+		}
+		height, err := strconv.ParseUint(strings.TrimSuffix(name, ".snap"), 10, 64)
+		if err != nil {
+			continue
+		}
+		heights = append(heights, height)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	return heights, nil
+}
+
+// path returns the file of the snapshot at height
+func (s *Snapshotter) path(height uint64) string {
+	return filepath.Join(s.config.Dir, fmt.Sprintf("%d.snap", height))
+}
@@ -0,0 +1,301 @@
+package state
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"sync"
+
+	"github.com/ccoin/core/internal/dag"
+	"github.com/ccoin/core/internal/economics"
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// DefaultCacheSize is the number of blocks whose state is kept in memory
+const DefaultCacheSize = 1024
+
+// Keys of the state tree's global leaves
+var (
+	NullifiersKey  = leafKey("nullifiers")
+	CommitmentsKey = leafKey("commitments")
+	SupplyKey      = leafKey("supply")
+	TreasuryKey    = leafKey("treasury")
+)
+
+// leafKey derives the key of a named leaf
+func leafKey(name string) types.Hash {
+	return sha256.Sum256([]byte("ccoin/state/" + name))
+}
+
+// MinerKey returns the key of a miner's account leaf
+func MinerKey(addr types.Address) types.Hash {
+	return sha256.Sum256(append([]byte("ccoin/state/miner/"), addr[:]...))
+}
+
+// AmountValue encodes an amount as a leaf value
+func AmountValue(amount uint64) types.Hash {
+	var value types.Hash
+	binary.BigEndian.PutUint64(value[types.HashSize-8:], amount)
+	return value
+}
+
+// MinerAccount is a miner's state along a chain
+type MinerAccount struct {
+	// Blocks mined and the miner's share of their rewards
+	Blocks  uint64
+	Rewards uint64
+
+	// Reputation claimed in the miner's latest block
+	Reputation float64
+}
+
+// Hash returns the account's leaf value
+func (a *MinerAccount) Hash() types.Hash {
+	var buf [24]byte
+	binary.BigEndian.PutUint64(buf[0:], a.Blocks)
+	binary.BigEndian.PutUint64(buf[8:], a.Rewards)
+	binary.BigEndian.PutUint64(buf[16:], math.Float64bits(a.Reputation))
+	return sha256.Sum256(buf[:])
+}
+
+// State is the chain state after a block: the nullifier and commitment
+// tree roots, the supply and treasury balance issued by the emission
+// schedule, and each miner's account. It follows the block's
+// selected-parent chain, so every node computes the same state for a
+// block.
+type State struct {
+	NullifierRoot  types.Hash
+	CommitmentRoot types.Hash
+	Supply         uint64
+	Treasury       uint64
+	Miners         map[types.Address]*MinerAccount
+
+	// Receipts of the block's transactions, in block order; not part of
+	// the state tree
+	Receipts []*types.Receipt
+
+	tree *Tree
+}
+
+// Tree returns the state's sparse Merkle tree
+func (s *State) Tree() *Tree {
+	if s.tree == nil {
+		s.tree = NewTree()
+		s.tree.Set(NullifiersKey, s.NullifierRoot)
+		s.tree.Set(CommitmentsKey, s.CommitmentRoot)
+		s.tree.Set(SupplyKey, AmountValue(s.Supply))
+		s.tree.Set(TreasuryKey, AmountValue(s.Treasury))
+		for addr, account := range s.Miners {
+			s.tree.Set(MinerKey(addr), account.Hash())
+		}
+	}
+	return s.tree
+}
+
+// Root returns the state root block headers commit to
+func (s *State) Root() types.Hash {
+	return s.Tree().Root()
+}
+
+// Manager computes the state each block commits to. States missing from
+// its cache, e.g. after a restart, are rebuilt from stored blocks on
+// first use.
+type Manager struct {
+	mu sync.Mutex
+
+	source       zkp.AccumulatorSource
+	nullifiers   *zkp.ChainAccumulator
+	commitments  *zkp.ChainAccumulator
+	distribution *economics.RewardDistribution
+
+	// State after each recent block, oldest first in order
+	states   map[types.Hash]*State
+	order    []types.Hash
+	capacity int
+
+	// States restored from snapshots or pinned by archive nodes, never
+	// evicted
+	pinned map[types.Hash]*State
+}
+
+// NewManager creates a state manager over the blocks of source, caching
+// up to capacity block states (0 for the default)
+func NewManager(source zkp.AccumulatorSource, capacity int) *Manager {
+	if capacity <= 0 {
+		capacity = DefaultCacheSize
+	}
+
+	return &Manager{
+		source:       source,
+		nullifiers:   zkp.NewNullifierAccumulator(source, 0),
+		commitments:  zkp.NewCommitmentAccumulator(source, 0),
+		distribution: economics.DefaultRewardDistribution(),
+		states:       make(map[types.Hash]*State),
+		capacity:     capacity,
+		pinned:       make(map[types.Hash]*State),
+	}
+}
+
+// State returns the state after a stored block. It must not be modified.
+func (m *Manager) State(ctx context.Context, hash types.Hash) (*State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stateLocked(ctx, hash)
+}
+
+// Pin computes the state after a stored block and keeps it for good, so
+// states of later blocks are rebuilt from it rather than from further
+// back
+func (m *Manager) Pin(ctx context.Context, hash types.Hash) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.pinned[hash]; exists {
+		return nil
+	}
+	state, err := m.stateLocked(ctx, hash)
+	if err != nil {
+		return err
+	}
+	m.pinned[hash] = state
+	return nil
+}
+
+// NextStateRoot returns the state root a block with the given selected
+// parent must commit to
+func (m *Manager) NextStateRoot(ctx context.Context, parent types.Hash, block *types.Block) (types.Hash, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.stateLocked(ctx, parent)
+	if err != nil {
+		return types.Hash{}, err
+	}
+	next, err := m.apply(ctx, state, parent, block)
+	if err != nil {
+		return types.Hash{}, err
+	}
+	return next.Root(), nil
+}
+
+// Prove returns the value at key in the state after a stored block and
+// the path proving it against the block's state root
+func (m *Manager) Prove(ctx context.Context, hash, key types.Hash) (types.Hash, *Proof, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.stateLocked(ctx, hash)
+	if err != nil {
+		return types.Hash{}, nil, err
+	}
+	tree := state.Tree()
+	return tree.Get(key), tree.Prove(key), nil
+}
+
+// stateLocked returns the state after a block, computing it and any
+// uncached ancestors' from stored blocks
+func (m *Manager) stateLocked(ctx context.Context, hash types.Hash) (*State, error) {
+	// Walk back along selected parents to a known state or genesis
+	var pending []*types.Block
+	var parents []types.Hash
+	var base *State
+	for {
+		if state, exists := m.states[hash]; exists {
+			base = state
+			break
+		}
+		if state, exists := m.pinned[hash]; exists {
+			base = state
+			break
+		}
+
+		block, err := m.source.GetBlock(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		if block.Header.IsGenesis() {
+			base = &State{
+				CommitmentRoot: m.commitments.EmptyRoot(),
+				Miners:         make(map[types.Address]*MinerAccount),
+			}
+			m.cacheLocked(hash, base)
+			break
+		}
+		pending = append(pending, block)
+
+		hash, err = m.source.SelectedParent(ctx, block.Header.Parents)
+		if err != nil {
+			return nil, err
+		}
+		parents = append(parents, hash)
+	}
+
+	for i := len(pending) - 1; i >= 0; i-- {
+		next, err := m.apply(ctx, base, parents[i], pending[i])
+		if err != nil {
+			return nil, err
+		}
+		m.cacheLocked(pending[i].Header.Hash, next)
+		base = next
+	}
+	return base, nil
+}
+
+// apply returns the state after block, whose selected parent left state
+func (m *Manager) apply(ctx context.Context, state *State, parent types.Hash, block *types.Block) (*State, error) {
+	header := block.Header
+
+	commitmentRoot, err := m.commitments.NextRoot(ctx, parent, dag.BlockCommitments(block.Transactions))
+	if err != nil {
+		return nil, err
+	}
+	commitments, err := m.commitments.Size(ctx, parent)
+	if err != nil {
+		return nil, err
+	}
+
+	next := &State{
+		NullifierRoot:  header.NullifierRoot,
+		CommitmentRoot: commitmentRoot,
+		Supply:         state.Supply,
+		Treasury:       state.Treasury,
+		Miners:         make(map[types.Address]*MinerAccount, len(state.Miners)+1),
+		Receipts:       blockReceipts(block.Transactions, commitments),
+	}
+	for addr, account := range state.Miners {
+		next.Miners[addr] = account
+	}
+
+	// Issue the block reward on the emission schedule; the burned share
+	// is never issued
+	reward := economics.CalculateMinerReward(header.Height, header.ReputationScore)
+	minerShare, _, treasuryShare, _, burn := m.distribution.CalculateDistribution(reward)
+	next.Supply += reward - burn
+	next.Treasury += treasuryShare
+
+	account := MinerAccount{}
+	if prev, exists := state.Miners[header.MinerAddress]; exists {
+		account = *prev
+	}
+	account.Blocks++
+	account.Rewards += minerShare
+	account.Reputation = header.ReputationScore
+	next.Miners[header.MinerAddress] = &account
+
+	return next, nil
+}
+
+// cacheLocked stores a block's state, evicting the oldest past capacity
+func (m *Manager) cacheLocked(hash types.Hash, state *State) {
+	if _, exists := m.states[hash]; exists {
+		return
+	}
+	m.states[hash] = state
+	m.order = append(m.order, hash)
+	for len(m.order) > m.capacity {
+		delete(m.states, m.order[0])
+		m.order = m.order[1:]
+	}
+}
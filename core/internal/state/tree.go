@@ -0,0 +1,174 @@
+// Package state implements the state commitment carried in block headers.
+package state
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"sort"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// TreeDepth is the depth of the state tree: one level per key bit
+const TreeDepth = types.HashSize * 8
+
+// Tree is a sparse Merkle tree over 256-bit keys. A set leaf hashes its
+// key and value; empty leaves, and nodes whose children are both empty,
+// are zero, so the tree only hashes along the paths of set keys.
+type Tree struct {
+	leaves map[types.Hash]types.Hash
+
+	// root is recomputed lazily after changes
+	root  types.Hash
+	dirty bool
+}
+
+// Proof is a path from a key's leaf to the root. Empty siblings are left
+// out: bit i of Empty is set if the sibling at level i (leaf level first)
+// is empty, and Siblings holds the others in level order.
+type Proof struct {
+	Empty    [TreeDepth / 8]byte
+	Siblings []types.Hash
+}
+
+// NewTree creates an empty tree
+func NewTree() *Tree {
+	return &Tree{leaves: make(map[types.Hash]types.Hash)}
+}
+
+// Set sets the value at key; a zero value clears it
+func (t *Tree) Set(key, value types.Hash) {
+	if value.IsEmpty() {
+		delete(t.leaves, key)
+	} else {
+		t.leaves[key] = value
+	}
+	t.dirty = true
+}
+
+// Get returns the value at key, zero if unset
+func (t *Tree) Get(key types.Hash) types.Hash {
+	return t.leaves[key]
+}
+
+// Root returns the tree root
+func (t *Tree) Root() types.Hash {
+	if t.dirty {
+		t.root = t.node(TreeDepth, t.sortedKeys())
+		t.dirty = false
+	}
+	return t.root
+}
+
+// Prove returns the path for key, proving its value (zero if unset)
+func (t *Tree) Prove(key types.Hash) *Proof {
+	proof := &Proof{}
+	siblings := make([]types.Hash, TreeDepth)
+	keys := t.sortedKeys()
+	for h := TreeDepth; h > 0; h-- {
+		zeros, ones := splitByBit(keys, h-1)
+		if keyBit(key, h-1) == 0 {
+			siblings[h-1] = t.node(h-1, ones)
+			keys = zeros
+		} else {
+			siblings[h-1] = t.node(h-1, zeros)
+			keys = ones
+		}
+	}
+
+	for level, sibling := range siblings {
+		if sibling.IsEmpty() {
+			proof.Empty[level/8] |= 1 << (level % 8)
+		} else {
+			proof.Siblings = append(proof.Siblings, sibling)
+		}
+	}
+	return proof
+}
+
+// VerifyProof checks that proof leads from key's leaf holding value (zero
+// for an unset key) to root
+func VerifyProof(root, key, value types.Hash, proof *Proof) bool {
+	if proof == nil {
+		return false
+	}
+
+	node := leafHash(key, value)
+	next := 0
+	for level := 0; level < TreeDepth; level++ {
+		var sibling types.Hash
+		if proof.Empty[level/8]&(1<<(level%8)) == 0 {
+			if next == len(proof.Siblings) {
+				return false
+			}
+			sibling = proof.Siblings[next]
+			next++
+		}
+		if keyBit(key, level) == 0 {
+			node = nodeHash(node, sibling)
+		} else {
+			node = nodeHash(sibling, node)
+		}
+	}
+	return next == len(proof.Siblings) && node == root
+}
+
+// sortedKeys returns the set keys in ascending order, which keeps the
+// subtree splits below contiguous
+func (t *Tree) sortedKeys() []types.Hash {
+	keys := make([]types.Hash, 0, len(t.leaves))
+	for key := range t.leaves {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i][:], keys[j][:]) < 0 })
+	return keys
+}
+
+// node returns the root of the subtree of height h holding keys. keys are
+// sorted and agree on every bit above h.
+func (t *Tree) node(h int, keys []types.Hash) types.Hash {
+	if len(keys) == 0 {
+		return types.Hash{}
+	}
+	if h == 0 {
+		return leafHash(keys[0], t.leaves[keys[0]])
+	}
+	zeros, ones := splitByBit(keys, h-1)
+	return nodeHash(t.node(h-1, zeros), t.node(h-1, ones))
+}
+
+// leafHash hashes a set leaf; unset leaves are zero
+func leafHash(key, value types.Hash) types.Hash {
+	if value.IsEmpty() {
+		return types.Hash{}
+	}
+	data := make([]byte, 0, 1+2*types.HashSize)
+	data = append(data, 0)
+	data = append(data, key[:]...)
+	data = append(data, value[:]...)
+	return sha256.Sum256(data)
+}
+
+// nodeHash hashes two children; a node with two empty children is empty
+func nodeHash(left, right types.Hash) types.Hash {
+	if left.IsEmpty() && right.IsEmpty() {
+		return types.Hash{}
+	}
+	data := make([]byte, 0, 1+2*types.HashSize)
+	data = append(data, 1)
+	data = append(data, left[:]...)
+	data = append(data, right[:]...)
+	return sha256.Sum256(data)
+}
+
+// splitByBit splits sorted keys that agree above bit into those with the
+// bit clear and those with it set
+func splitByBit(keys []types.Hash, bit int) (zeros, ones []types.Hash) {
+	i := sort.Search(len(keys), func(i int) bool { return keyBit(keys[i], bit) == 1 })
+	return keys[:i], keys[i:]
+}
+
+// keyBit returns bit i of the key read as a big-endian integer
+func keyBit(key types.Hash, i int) byte {
+	return (key[types.HashSize-1-i/8] >> (i % 8)) & 1
+}
@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"context"
+	"math/big"
+	"strconv"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// ============================================
+// Analytics Queries
+// ============================================
+
+// Reputation histogram range, matching the miners table bounds
+const (
+	histogramMinReputation = 0.1
+	histogramMaxReputation = 3.0
+)
+
+// DifficultyPoint is the difficulty target sampled at a height
+type DifficultyPoint struct {
+	Height     uint64
+	Timestamp  int64
+	Difficulty *big.Int
+}
+
+// QualityPoint is the average PoUW quality over a range of epochs
+type QualityPoint struct {
+	FromEpoch      uint64
+	ToEpoch        uint64
+	AverageQuality float64
+	Blocks         int64
+}
+
+// ReputationHistogram counts active miners per reputation bin over a
+// range of epochs. Each miner is placed by its average reputation.
+type ReputationHistogram struct {
+	FromEpoch uint64
+	ToEpoch   uint64
+	Counts    []int64
+}
+
+// StakingPoint is the staking total recorded at the end of an epoch
+type StakingPoint struct {
+	Epoch       uint64
+	TotalStaked uint64
+	Stakers     int64
+}
+
+// DifficultyHistory returns one main-chain difficulty sample every step
+// heights in [fromHeight, toHeight]
+func (s *PostgresStore) DifficultyHistory(ctx context.Context, fromHeight, toHeight, step uint64) ([]DifficultyPoint, error) {
+	query := `
+		SELECT DISTINCT ON (height) height, timestamp, difficulty
+		FROM blocks
+		WHERE height >= $1 AND height <= $2 AND (height - $1) % $3 = 0
+		ORDER BY height ASC, is_main_chain DESC
+	`
+
+	rows, err := s.pool.Query(ctx, query, fromHeight, toHeight, maxStep(step))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []DifficultyPoint
+	for rows.Next() {
+		var p DifficultyPoint
+		var difficulty []byte
+		if err := rows.Scan(&p.Height, &p.Timestamp, &difficulty); err != nil {
+			return nil, err
+		}
+		p.Difficulty = new(big.Int).SetBytes(difficulty)
+		points = append(points, p)
+	}
+
+	return points, rows.Err()
+}
+
+// QualityHistory returns the average quality score per bucket of step
+// epochs in [fromEpoch, toEpoch]
+func (s *PostgresStore) QualityHistory(ctx context.Context, fromEpoch, toEpoch, step uint64) ([]QualityPoint, error) {
+	step = maxStep(step)
+	query := `
+		SELECT (height / $1 - $2) / $3 AS bucket,
+			   COALESCE(AVG(quality_score), 0)::FLOAT8,
+			   COUNT(*)
+		FROM blocks
+		WHERE height >= $4 AND height < $5
+		GROUP BY bucket
+		ORDER BY bucket ASC
+	`
+
+	fromHeight, toHeight := epochHeights(fromEpoch, toEpoch)
+	rows, err := s.pool.Query(ctx, query, uint64(types.EpochLength), fromEpoch, step, fromHeight, toHeight)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []QualityPoint
+	for rows.Next() {
+		var bucket uint64
+		var p QualityPoint
+		if err := rows.Scan(&bucket, &p.AverageQuality, &p.Blocks); err != nil {
+			return nil, err
+		}
+		p.FromEpoch, p.ToEpoch = bucketEpochs(bucket, fromEpoch, toEpoch, step)
+		points = append(points, p)
+	}
+
+	return points, rows.Err()
+}
+
+// ReputationHistograms returns a histogram of miner reputation with bins
+// equal-width bins over [0.1, 3.0] per bucket of step epochs
+func (s *PostgresStore) ReputationHistograms(ctx context.Context, fromEpoch, toEpoch, step uint64, bins int) ([]ReputationHistogram, error) {
+	step = maxStep(step)
+	query := `
+		SELECT bucket, LEAST(GREATEST(width_bucket(rep, $6, $7, $8), 1), $8) AS bin, COUNT(*)
+		FROM (
+			SELECT (height / $1 - $2) / $3 AS bucket, miner_address, AVG(reputation_score)::FLOAT8 AS rep
+			FROM blocks
+			WHERE height >= $4 AND height < $5
+			GROUP BY bucket, miner_address
+		) m
+		GROUP BY bucket, bin
+		ORDER BY bucket ASC, bin ASC
+	`
+
+	fromHeight, toHeight := epochHeights(fromEpoch, toEpoch)
+	rows, err := s.pool.Query(ctx, query,
+		uint64(types.EpochLength), fromEpoch, step, fromHeight, toHeight,
+		histogramMinReputation, histogramMaxReputation, bins,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var histograms []ReputationHistogram
+	for rows.Next() {
+		var bucket uint64
+		var bin int
+		var count int64
+		if err := rows.Scan(&bucket, &bin, &count); err != nil {
+			return nil, err
+		}
+
+		from, to := bucketEpochs(bucket, fromEpoch, toEpoch, step)
+		if len(histograms) == 0 || histograms[len(histograms)-1].FromEpoch != from {
+			histograms = append(histograms, ReputationHistogram{
+				FromEpoch: from,
+				ToEpoch:   to,
+				Counts:    make([]int64, bins),
+			})
+		}
+		histograms[len(histograms)-1].Counts[bin-1] = count
+	}
+
+	return histograms, rows.Err()
+}
+
+// ReputationBinEdges returns the bins+1 edges used by ReputationHistograms
+func ReputationBinEdges(bins int) []float64 {
+	edges := make([]float64, bins+1)
+	width := (histogramMaxReputation - histogramMinReputation) / float64(bins)
+	for i := range edges {
+		edges[i] = histogramMinReputation + float64(i)*width
+	}
+	return edges
+}
+
+// StakingHistory returns the last staking snapshot in each bucket of step
+// epochs in [fromEpoch, toEpoch]
+func (s *PostgresStore) StakingHistory(ctx context.Context, fromEpoch, toEpoch, step uint64) ([]StakingPoint, error) {
+	query := `
+		SELECT DISTINCT ON ((epoch - $1) / $3) epoch, total_staked::TEXT, stakers
+		FROM staking_snapshots
+		WHERE epoch >= $1 AND epoch <= $2
+		ORDER BY (epoch - $1) / $3 ASC, epoch DESC
+	`
+
+	rows, err := s.pool.Query(ctx, query, fromEpoch, toEpoch, maxStep(step))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []StakingPoint
+	for rows.Next() {
+		var p StakingPoint
+		var total string
+		if err := rows.Scan(&p.Epoch, &total, &p.Stakers); err != nil {
+			return nil, err
+		}
+		if p.TotalStaked, err = strconv.ParseUint(total, 10, 64); err != nil {
+			return nil, ErrInvalidData
+		}
+		points = append(points, p)
+	}
+
+	return points, rows.Err()
+}
+
+// RecordStakingSnapshot stores the current staking totals for an epoch
+func (s *PostgresStore) RecordStakingSnapshot(ctx context.Context, epoch uint64) error {
+	query := `
+		INSERT INTO staking_snapshots (epoch, total_staked, stakers)
+		SELECT $1, COALESCE(SUM(staked_amount), 0), COUNT(*) FILTER (WHERE staked_amount > 0)
+		FROM miners
+		ON CONFLICT (epoch) DO UPDATE
+		SET total_staked = EXCLUDED.total_staked, stakers = EXCLUDED.stakers, recorded_at = NOW()
+	`
+
+	_, err := s.pool.Exec(ctx, query, epoch)
+	return err
+}
+
+// epochHeights returns the half-open height range covering the epochs
+func epochHeights(fromEpoch, toEpoch uint64) (uint64, uint64) {
+	return fromEpoch * types.EpochLength, (toEpoch + 1) * types.EpochLength
+}
+
+// bucketEpochs returns the epoch range covered by a bucket
+func bucketEpochs(bucket, fromEpoch, toEpoch, step uint64) (uint64, uint64) {
+	from := fromEpoch + bucket*step
+	to := from + step - 1
+	if to > toEpoch {
+		to = toEpoch
+	}
+	return from, to
+}
+
+// maxStep guards against a zero step
+func maxStep(step uint64) uint64 {
+	if step == 0 {
+		return 1
+	}
+	return step
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// ============================================
+// Archive Queries
+// ============================================
+
+// ErrMalformedDisclosures is returned when stored disclosures fail to decode
+var ErrMalformedDisclosures = errors.New("malformed disclosures")
+
+// GetMainChainByTime returns main chain blocks with timestamps in
+// [from, to), in height order
+func (s *PostgresStore) GetMainChainByTime(ctx context.Context, from, to int64) ([]*types.Block, error) {
+	query := `
+		SELECT hash FROM blocks
+		WHERE is_main_chain = TRUE AND timestamp >= $1 AND timestamp < $2
+		ORDER BY height ASC
+	`
+
+	rows, err := s.pool.Query(ctx, query, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []types.Hash
+	for rows.Next() {
+		var hashBytes []byte
+		if err := rows.Scan(&hashBytes); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		var hash types.Hash
+		copy(hash[:], hashBytes)
+		hashes = append(hashes, hash)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	blocks := make([]*types.Block, 0, len(hashes))
+	for _, hash := range hashes {
+		block, err := s.GetBlock(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+// encodeDisclosures serializes disclosures for the transactions table
+func encodeDisclosures(disclosures []types.Disclosure) []byte {
+	if len(disclosures) == 0 {
+		return nil
+	}
+
+	buf := make([]byte, 0, 256)
+	buf = append(buf, byte(len(disclosures)))
+	for _, d := range disclosures {
+		buf = append(buf, byte(d.Type), d.Proof.ProofType)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(d.Proof.ProofData)))
+		buf = append(buf, d.Proof.ProofData...)
+		buf = append(buf, byte(len(d.Proof.PublicInputs)))
+		for _, input := range d.Proof.PublicInputs {
+			buf = append(buf, input[:]...)
+		}
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(d.PublicData)))
+		buf = append(buf, d.PublicData...)
+	}
+	return buf
+}
+
+// decodeDisclosures reads disclosures written by encodeDisclosures
+func decodeDisclosures(data []byte) ([]types.Disclosure, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	next := func(n int) ([]byte, bool) {
+		if n < 0 || len(data) < n {
+			return nil, false
+		}
+		b := data[:n]
+		data = data[n:]
+		return b, true
+	}
+
+	count := int(data[0])
+	data = data[1:]
+	disclosures := make([]types.Disclosure, count)
+	for i := range disclosures {
+		d := &disclosures[i]
+
+		head, ok := next(6)
+		if !ok {
+			return nil, ErrMalformedDisclosures
+		}
+		d.Type = types.DisclosureType(head[0])
+		d.Proof.ProofType = head[1]
+		if d.Proof.ProofData, ok = next(int(binary.BigEndian.Uint32(head[2:]))); !ok {
+			return nil, ErrMalformedDisclosures
+		}
+
+		n, ok := next(1)
+		if !ok {
+			return nil, ErrMalformedDisclosures
+		}
+		d.Proof.PublicInputs = make([]types.Hash, n[0])
+		for j := range d.Proof.PublicInputs {
+			input, ok := next(types.HashSize)
+			if !ok {
+				return nil, ErrMalformedDisclosures
+			}
+			copy(d.Proof.PublicInputs[j][:], input)
+		}
+
+		size, ok := next(4)
+		if !ok {
+			return nil, ErrMalformedDisclosures
+		}
+		if d.PublicData, ok = next(int(binary.BigEndian.Uint32(size))); !ok {
+			return nil, ErrMalformedDisclosures
+		}
+	}
+	return disclosures, nil
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// SaveCommitteeRecords stores the committee selections of an epoch
+func (s *PostgresStore) SaveCommitteeRecords(ctx context.Context, records []*types.CommitteeRecord) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, r := range records {
+		hash := r.Hash()
+		query := `
+			INSERT INTO committee_records (epoch, kind, beacon, size, record_hash)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (epoch, kind) DO NOTHING`
+		if _, err := tx.Exec(ctx, query, r.Epoch, int16(r.Kind), r.Beacon[:], int64(r.Size), hash[:]); err != nil {
+			return err
+		}
+
+		members := make(map[types.Address]int, len(r.Members))
+		for i, m := range r.Members {
+			members[m] = i
+		}
+		for _, c := range r.Candidates {
+			var seat interface{}
+			if i, ok := members[c.Address]; ok {
+				seat = i
+			}
+			query := `
+				INSERT INTO committee_candidates (epoch, kind, address, stake, reputation, seat)
+				VALUES ($1, $2, $3, $4, $5, $6)
+				ON CONFLICT (epoch, kind, address) DO NOTHING`
+			if _, err := tx.Exec(ctx, query, r.Epoch, int16(r.Kind), c.Address[:], int64(c.Stake),
+				int64(types.ReputationFixed(c.Reputation)), seat); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// LoadCommitteeRecords returns the committee selections of an epoch
+func (s *PostgresStore) LoadCommitteeRecords(ctx context.Context, epoch uint64) ([]*types.CommitteeRecord, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT kind, beacon, size FROM committee_records
+		WHERE epoch = $1 ORDER BY kind`, epoch)
+	if err != nil {
+		return nil, err
+	}
+	var records []*types.CommitteeRecord
+	for rows.Next() {
+		r := &types.CommitteeRecord{Epoch: epoch}
+		var kind int16
+		var beacon []byte
+		var size int64
+		if err := rows.Scan(&kind, &beacon, &size); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		r.Kind = types.CommitteeKind(kind)
+		copy(r.Beacon[:], beacon)
+		r.Size = uint32(size)
+		records = append(records, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, r := range records {
+		if err := s.loadCommitteeCandidates(ctx, r); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// loadCommitteeCandidates fills in a record's snapshot and members
+func (s *PostgresStore) loadCommitteeCandidates(ctx context.Context, r *types.CommitteeRecord) error {
+	rows, err := s.pool.Query(ctx, `
+		SELECT address, stake, reputation, seat FROM committee_candidates
+		WHERE epoch = $1 AND kind = $2 ORDER BY address`, r.Epoch, int16(r.Kind))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	seats := make(map[int]types.Address)
+	for rows.Next() {
+		var c types.CommitteeCandidate
+		var addr []byte
+		var stake, reputation int64
+		var seat *int
+		if err := rows.Scan(&addr, &stake, &reputation, &seat); err != nil {
+			return err
+		}
+		copy(c.Address[:], addr)
+		c.Stake = uint64(stake)
+		c.Reputation = float64(reputation) / 1e9
+		r.Candidates = append(r.Candidates, c)
+		if seat != nil {
+			seats[*seat] = c.Address
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	r.Members = make([]types.Address, len(seats))
+	for i := range r.Members {
+		r.Members[i] = seats[i]
+	}
+	return nil
+}
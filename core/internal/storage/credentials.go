@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// LoadAuthorities returns every registered credential authority
+func (s *PostgresStore) LoadAuthorities(ctx context.Context) ([]zkp.Authority, error) {
+	query := `SELECT public_key, name, domain FROM credential_authorities`
+
+	rows, err := s.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var authorities []zkp.Authority
+	for rows.Next() {
+		var keyBytes []byte
+		var a zkp.Authority
+		if err := rows.Scan(&keyBytes, &a.Name, &a.Domain); err != nil {
+			return nil, err
+		}
+		copy(a.PublicKey[:], keyBytes)
+		authorities = append(authorities, a)
+	}
+	return authorities, rows.Err()
+}
+
+// SaveAuthority registers a credential authority, or removes it
+func (s *PostgresStore) SaveAuthority(ctx context.Context, authority zkp.Authority, remove bool, proposalID types.Hash) error {
+	if remove {
+		query := `DELETE FROM credential_authorities WHERE public_key = $1`
+		_, err := s.pool.Exec(ctx, query, authority.PublicKey[:])
+		return err
+	}
+
+	query := `INSERT INTO credential_authorities (public_key, name, domain, proposal_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (public_key) DO UPDATE SET name = $2, domain = $3, proposal_id = $4`
+	_, err := s.pool.Exec(ctx, query, authority.PublicKey[:], authority.Name, authority.Domain, proposalID[:])
+	return err
+}
+
+// LoadRevocations returns the serial of every revoked credential
+func (s *PostgresStore) LoadRevocations(ctx context.Context) ([]types.Address, error) {
+	query := `SELECT serial FROM revoked_credentials`
+
+	rows, err := s.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var serials []types.Address
+	for rows.Next() {
+		var serialBytes []byte
+		if err := rows.Scan(&serialBytes); err != nil {
+			return nil, err
+		}
+		var serial types.Address
+		copy(serial[:], serialBytes)
+		serials = append(serials, serial)
+	}
+	return serials, rows.Err()
+}
+
+// SaveRevocations applies a revocation list change atomically
+func (s *PostgresStore) SaveRevocations(ctx context.Context, revoke, reinstate []types.Address, proposalID types.Hash) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, serial := range revoke {
+		query := `INSERT INTO revoked_credentials (serial, proposal_id) VALUES ($1, $2)
+			ON CONFLICT (serial) DO NOTHING`
+		if _, err := tx.Exec(ctx, query, serial[:], proposalID[:]); err != nil {
+			return err
+		}
+	}
+	for _, serial := range reinstate {
+		query := `DELETE FROM revoked_credentials WHERE serial = $1`
+		if _, err := tx.Exec(ctx, query, serial[:]); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// ============================================
+// Explorer Indexes
+// ============================================
+
+// TxRecord is a stored transaction and where it was included. A
+// transaction included by parallel blocks is recorded under the block
+// saved last.
+type TxRecord struct {
+	Tx          *types.Transaction
+	BlockHash   types.Hash
+	BlockHeight uint64
+	Index       int
+}
+
+// CommitmentRecord is an output commitment and the transaction that
+// created it. A commitment is recorded under the first block saved with
+// it.
+type CommitmentRecord struct {
+	Commitment  types.Commitment
+	TxHash      types.Hash
+	BlockHash   types.Hash
+	BlockHeight uint64
+
+	// Output is the commitment's position among the transaction's outputs
+	Output int
+}
+
+// ExplorerStore looks transactions and outputs up by hash, nullifier and
+// height, as the RPC layer and block explorers do
+type ExplorerStore interface {
+	// GetTransaction returns a transaction by hash, whichever block
+	// included it, or ErrNotFound
+	GetTransaction(ctx context.Context, txHash types.Hash) (*TxRecord, error)
+
+	// GetTransactionByNullifier returns the transaction that spent a
+	// nullifier, or ErrNotFound if it is unspent
+	GetTransactionByNullifier(ctx context.Context, nullifier types.Hash) (*TxRecord, error)
+
+	// GetCommitments returns the commitments created by blocks with
+	// heights in [fromHeight, toHeight], in height, block, transaction and
+	// output order
+	GetCommitments(ctx context.Context, fromHeight, toHeight uint64) ([]*CommitmentRecord, error)
+}
+
+// GetTransaction returns a transaction by hash, whichever block included
+// it
+func (s *PostgresStore) GetTransaction(ctx context.Context, txHash types.Hash) (*TxRecord, error) {
+	query := `
+		SELECT ` + txColumns + `, t.block_hash, b.height, t.tx_index
+		FROM transactions t JOIN blocks b ON b.hash = t.block_hash
+		WHERE t.tx_hash = $1
+	`
+	return s.queryTxRecord(ctx, query, txHash[:])
+}
+
+// GetTransactionByNullifier returns the transaction that spent a
+// nullifier
+func (s *PostgresStore) GetTransactionByNullifier(ctx context.Context, nullifier types.Hash) (*TxRecord, error) {
+	query := `
+		SELECT ` + txColumns + `, t.block_hash, b.height, t.tx_index
+		FROM nullifiers n
+		JOIN transactions t ON t.tx_hash = n.tx_hash
+		JOIN blocks b ON b.hash = t.block_hash
+		WHERE n.nullifier = $1
+	`
+	return s.queryTxRecord(ctx, query, nullifier[:])
+}
+
+// queryTxRecord reads the transaction record a query selects
+func (s *PostgresStore) queryTxRecord(ctx context.Context, query string, args ...interface{}) (*TxRecord, error) {
+	var blockHash []byte
+	var index *int32
+	record := &TxRecord{}
+
+	tx, err := scanTransaction(s.pool.QueryRow(ctx, query, args...), &blockHash, &record.BlockHeight, &index)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction: %w", err)
+	}
+
+	record.Tx = tx
+	copy(record.BlockHash[:], blockHash)
+	if index != nil {
+		record.Index = int(*index)
+	}
+	return record, nil
+}
+
+// GetCommitments returns the commitments created by blocks with heights
+// in [fromHeight, toHeight]
+func (s *PostgresStore) GetCommitments(ctx context.Context, fromHeight, toHeight uint64) ([]*CommitmentRecord, error) {
+	query := `
+		SELECT c.commitment, c.encrypted_note, c.tx_hash, c.block_hash, c.block_height, c.output_index
+		FROM commitments c LEFT JOIN transactions t ON t.tx_hash = c.tx_hash
+		WHERE c.block_height >= $1 AND c.block_height <= $2
+		ORDER BY c.block_height, c.block_hash, t.tx_index, c.output_index
+	`
+
+	rows, err := s.pool.Query(ctx, query, fromHeight, toHeight)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []*CommitmentRecord
+	for rows.Next() {
+		var commitment, txHash, blockHash []byte
+		record := &CommitmentRecord{}
+		if err := rows.Scan(
+			&commitment,
+			&record.Commitment.EncryptedNote,
+			&txHash,
+			&blockHash,
+			&record.BlockHeight,
+			&record.Output,
+		); err != nil {
+			return nil, err
+		}
+
+		copy(record.Commitment.Value[:], commitment)
+		copy(record.TxHash[:], txHash)
+		copy(record.BlockHash[:], blockHash)
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
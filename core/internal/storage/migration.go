@@ -0,0 +1,521 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Migration errors
+var (
+	ErrUnknownMigration  = errors.New("unknown online migration")
+	ErrMigrationComplete = errors.New("online migration already complete")
+	ErrMigrationDiverged = errors.New("shadow table row count differs from source")
+	ErrInvalidMigration  = errors.New("invalid online migration definition")
+)
+
+// MigrationPhase is the lifecycle phase of an online migration
+type MigrationPhase string
+
+// Migration phases
+const (
+	PhasePending   MigrationPhase = "pending"
+	PhaseDualWrite MigrationPhase = "dual_write"
+	PhaseBackfill  MigrationPhase = "backfill"
+	PhaseComplete  MigrationPhase = "complete"
+)
+
+// migrationNamePattern restricts names to safe SQL identifier fragments
+var migrationNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// OnlineMigration rewrites a large table into a new schema while the node
+// keeps running. A shadow table is created with the new schema and a
+// trigger on the source table dual-writes every insert, update and delete
+// into it. Existing rows are then backfilled in key order with rate
+// limiting, and finally the tables are swapped under a short lock.
+type OnlineMigration struct {
+	// Name identifies the migration (lowercase, digits and underscores)
+	Name string
+
+	// Table is the existing table being migrated
+	Table string
+
+	// Target is the shadow table holding the new schema
+	Target string
+
+	// Key is the primary key column shared by both tables
+	Key string
+
+	// Columns are copied from Table into Target on dual-write and backfill
+	Columns []string
+
+	// CreateSQL creates Target along with its indexes and triggers
+	CreateSQL []string
+
+	// CutoverSQL runs after the swap, e.g. to repoint foreign keys
+	CutoverSQL []string
+}
+
+// MigrationStatus is the persisted progress of an online migration
+type MigrationStatus struct {
+	Name       string
+	Table      string
+	Phase      MigrationPhase
+	Cursor     []byte
+	RowsCopied int64
+	UpdatedAt  time.Time
+}
+
+// OnlineMigrations lists the registered online migrations in apply order
+var OnlineMigrations = []*OnlineMigration{
+	{
+		Name:   "003_transactions_block_height",
+		Table:  "transactions",
+		Target: "transactions_v2",
+		Key:    "tx_hash",
+		Columns: []string{
+			"tx_hash", "block_hash", "version", "nullifiers", "commitments", "proof_type",
+			"proof", "anchor", "disclosure_flags", "disclosures", "fee", "memo", "tx_index", "created_at",
+		},
+		CreateSQL: []string{
+			`CREATE TABLE IF NOT EXISTS transactions_v2 (
+				LIKE transactions INCLUDING DEFAULTS INCLUDING CONSTRAINTS INCLUDING INDEXES,
+				block_height BIGINT
+			)`,
+			`ALTER TABLE transactions_v2 DROP CONSTRAINT IF EXISTS transactions_v2_block_hash_fkey`,
+			`ALTER TABLE transactions_v2 ADD CONSTRAINT transactions_v2_block_hash_fkey
+				FOREIGN KEY (block_hash) REFERENCES blocks(hash) ON DELETE SET NULL`,
+			`CREATE INDEX IF NOT EXISTS idx_transactions_v2_block_height ON transactions_v2(block_height)`,
+			`CREATE OR REPLACE FUNCTION set_transaction_block_height()
+			RETURNS TRIGGER AS $$
+			BEGIN
+				SELECT height INTO NEW.block_height FROM blocks WHERE hash = NEW.block_hash;
+				RETURN NEW;
+			END;
+			$$ LANGUAGE plpgsql`,
+			`DROP TRIGGER IF EXISTS trigger_set_transaction_block_height ON transactions_v2`,
+			`CREATE TRIGGER trigger_set_transaction_block_height
+				BEFORE INSERT OR UPDATE OF block_hash ON transactions_v2
+				FOR EACH ROW
+				EXECUTE FUNCTION set_transaction_block_height()`,
+		},
+		CutoverSQL: []string{
+			`ALTER TABLE nullifiers DROP CONSTRAINT IF EXISTS nullifiers_tx_hash_fkey`,
+			`ALTER TABLE nullifiers ADD CONSTRAINT nullifiers_tx_hash_fkey
+				FOREIGN KEY (tx_hash) REFERENCES transactions(tx_hash) NOT VALID`,
+			`ALTER TABLE commitments DROP CONSTRAINT IF EXISTS commitments_tx_hash_fkey`,
+			`ALTER TABLE commitments ADD CONSTRAINT commitments_tx_hash_fkey
+				FOREIGN KEY (tx_hash) REFERENCES transactions(tx_hash) NOT VALID`,
+		},
+	},
+}
+
+// FindOnlineMigration returns a registered migration by name
+func FindOnlineMigration(name string) (*OnlineMigration, error) {
+	for _, m := range OnlineMigrations {
+		if m.Name == name {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownMigration, name)
+}
+
+// MigratorConfig controls backfill pacing
+type MigratorConfig struct {
+	// BatchSize is the number of rows copied per statement
+	BatchSize int
+
+	// RowsPerSecond caps the backfill rate (0 = unlimited)
+	RowsPerSecond int
+
+	// OnProgress is called after every batch
+	OnProgress func(MigrationStatus)
+}
+
+// DefaultMigratorConfig returns default migrator configuration
+func DefaultMigratorConfig() *MigratorConfig {
+	return &MigratorConfig{
+		BatchSize:     1000,
+		RowsPerSecond: 5000,
+	}
+}
+
+// Migrator applies online migrations against a PostgreSQL store
+type Migrator struct {
+	store  *PostgresStore
+	config *MigratorConfig
+}
+
+// NewMigrator creates a new migrator
+func NewMigrator(store *PostgresStore, cfg *MigratorConfig) *Migrator {
+	if cfg == nil {
+		cfg = DefaultMigratorConfig()
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultMigratorConfig().BatchSize
+	}
+	return &Migrator{store: store, config: cfg}
+}
+
+// Run drives a migration through dual-write, backfill and cutover. It
+// resumes from the persisted cursor if a previous run was interrupted.
+func (m *Migrator) Run(ctx context.Context, mig *OnlineMigration) error {
+	st, err := m.Status(ctx, mig.Name)
+	if err != nil {
+		return err
+	}
+	if st.Phase == PhaseComplete {
+		return ErrMigrationComplete
+	}
+
+	if st.Phase == PhasePending {
+		if err := m.EnableDualWrite(ctx, mig); err != nil {
+			return err
+		}
+	}
+	if err := m.Backfill(ctx, mig); err != nil {
+		return err
+	}
+	return m.Cutover(ctx, mig)
+}
+
+// EnableDualWrite creates the shadow table and installs the dual-write trigger
+func (m *Migrator) EnableDualWrite(ctx context.Context, mig *OnlineMigration) error {
+	if err := validateMigration(mig); err != nil {
+		return err
+	}
+
+	tx, err := m.store.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, stmt := range mig.CreateSQL {
+		if _, err := tx.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to create %s: %w", mig.Target, err)
+		}
+	}
+
+	for _, stmt := range dualWriteSQL(mig) {
+		if _, err := tx.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to install dual-write trigger: %w", err)
+		}
+	}
+
+	if err := setPhase(ctx, tx, mig, PhaseDualWrite); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Backfill copies existing rows into the shadow table in key order. Rows
+// written concurrently are already mirrored by the trigger; the upsert
+// makes re-copying them harmless.
+func (m *Migrator) Backfill(ctx context.Context, mig *OnlineMigration) error {
+	st, err := m.Status(ctx, mig.Name)
+	if err != nil {
+		return err
+	}
+	if st.Phase == PhaseComplete {
+		return ErrMigrationComplete
+	}
+	if st.Phase == PhasePending {
+		return fmt.Errorf("%s: dual-write not enabled", mig.Name)
+	}
+
+	if err := setPhase(ctx, m.store.pool, mig, PhaseBackfill); err != nil {
+		return err
+	}
+
+	selectKeys := fmt.Sprintf("SELECT %s FROM %s WHERE %s > $1 ORDER BY %s LIMIT $2",
+		mig.Key, mig.Table, mig.Key, mig.Key)
+	copyBatch := copySQL(mig, fmt.Sprintf("%s = ANY($1)", mig.Key))
+	advance := `
+		UPDATE online_migrations
+		SET cursor = $2, rows_copied = rows_copied + $3, updated_at = NOW()
+		WHERE name = $1
+	`
+
+	cursor := st.Cursor
+	if cursor == nil {
+		cursor = []byte{}
+	}
+
+	for {
+		started := time.Now()
+
+		keys, err := queryKeys(ctx, m.store, selectKeys, cursor, m.config.BatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to read backfill batch: %w", err)
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+
+		if _, err := m.store.pool.Exec(ctx, copyBatch, keys); err != nil {
+			return fmt.Errorf("failed to copy backfill batch: %w", err)
+		}
+
+		cursor = keys[len(keys)-1]
+		if _, err := m.store.pool.Exec(ctx, advance, mig.Name, cursor, len(keys)); err != nil {
+			return err
+		}
+
+		st.Phase = PhaseBackfill
+		st.Cursor = cursor
+		st.RowsCopied += int64(len(keys))
+		st.UpdatedAt = time.Now()
+		if m.config.OnProgress != nil {
+			m.config.OnProgress(*st)
+		}
+
+		if err := m.pace(ctx, len(keys), started); err != nil {
+			return err
+		}
+	}
+}
+
+// pace sleeps so that the backfill stays under RowsPerSecond
+func (m *Migrator) pace(ctx context.Context, rows int, started time.Time) error {
+	if m.config.RowsPerSecond <= 0 {
+		return ctx.Err()
+	}
+
+	budget := time.Duration(rows) * time.Second / time.Duration(m.config.RowsPerSecond)
+	wait := budget - time.Since(started)
+	if wait <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Cutover swaps the shadow table in. The source table is locked only for
+// the final catch-up and rename; it is kept as <table>_pre_<name>.
+func (m *Migrator) Cutover(ctx context.Context, mig *OnlineMigration) error {
+	tx, err := m.store.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	lock := fmt.Sprintf("LOCK TABLE %s, %s IN ACCESS EXCLUSIVE MODE", mig.Table, mig.Target)
+	if _, err := tx.Exec(ctx, lock); err != nil {
+		return fmt.Errorf("failed to lock tables: %w", err)
+	}
+
+	// Copy anything the backfill has not reached yet
+	missing := fmt.Sprintf(
+		"%s IN (SELECT o.%s FROM %s o LEFT JOIN %s n ON n.%s = o.%s WHERE n.%s IS NULL)",
+		mig.Key, mig.Key, mig.Table, mig.Target, mig.Key, mig.Key, mig.Key,
+	)
+	if _, err := tx.Exec(ctx, copySQL(mig, missing)); err != nil {
+		return fmt.Errorf("failed to catch up: %w", err)
+	}
+
+	var diff int64
+	count := fmt.Sprintf("SELECT (SELECT count(*) FROM %s) - (SELECT count(*) FROM %s)", mig.Table, mig.Target)
+	if err := tx.QueryRow(ctx, count).Scan(&diff); err != nil {
+		return err
+	}
+	if diff != 0 {
+		return fmt.Errorf("%w: %d rows", ErrMigrationDiverged, diff)
+	}
+
+	trigger, function := dualWriteNames(mig)
+	stmts := []string{
+		fmt.Sprintf("DROP TRIGGER IF EXISTS %s ON %s", trigger, mig.Table),
+		fmt.Sprintf("DROP FUNCTION IF EXISTS %s()", function),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s_pre_%s", mig.Table, mig.Table, mig.Name),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", mig.Target, mig.Table),
+	}
+	stmts = append(stmts, mig.CutoverSQL...)
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("cutover failed: %w", err)
+		}
+	}
+
+	if err := setPhase(ctx, tx, mig, PhaseComplete); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Status returns the persisted state of a migration
+func (m *Migrator) Status(ctx context.Context, name string) (*MigrationStatus, error) {
+	if err := m.ensureStateTable(ctx); err != nil {
+		return nil, err
+	}
+
+	mig, err := FindOnlineMigration(name)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &MigrationStatus{Name: name, Table: mig.Table, Phase: PhasePending}
+	query := `SELECT phase, cursor, rows_copied, updated_at FROM online_migrations WHERE name = $1`
+
+	var phase string
+	err = m.store.pool.QueryRow(ctx, query, name).Scan(&phase, &st.Cursor, &st.RowsCopied, &st.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	st.Phase = MigrationPhase(phase)
+	return st, nil
+}
+
+// StatusAll returns the state of every registered migration
+func (m *Migrator) StatusAll(ctx context.Context) ([]*MigrationStatus, error) {
+	out := make([]*MigrationStatus, 0, len(OnlineMigrations))
+	for _, mig := range OnlineMigrations {
+		st, err := m.Status(ctx, mig.Name)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// ensureStateTable creates the progress table if migrations/002 was not applied
+func (m *Migrator) ensureStateTable(ctx context.Context) error {
+	_, err := m.store.pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS online_migrations (
+			name VARCHAR(100) PRIMARY KEY,
+			table_name VARCHAR(100) NOT NULL,
+			phase VARCHAR(20) NOT NULL,
+			cursor BYTEA,
+			rows_copied BIGINT NOT NULL DEFAULT 0,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		)
+	`)
+	return err
+}
+
+// execer is satisfied by both the pool and a transaction
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+}
+
+// setPhase records a phase transition
+func setPhase(ctx context.Context, db execer, mig *OnlineMigration, phase MigrationPhase) error {
+	query := `
+		INSERT INTO online_migrations (name, table_name, phase)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE SET phase = $3, updated_at = NOW()
+	`
+	_, err := db.Exec(ctx, query, mig.Name, mig.Table, string(phase))
+	return err
+}
+
+// queryKeys reads the next batch of keys after cursor
+func queryKeys(ctx context.Context, store *PostgresStore, query string, cursor []byte, limit int) ([][]byte, error) {
+	rows, err := store.pool.Query(ctx, query, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys [][]byte
+	for rows.Next() {
+		var key []byte
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// validateMigration checks a migration definition before it touches the schema
+func validateMigration(mig *OnlineMigration) error {
+	if !migrationNamePattern.MatchString(mig.Name) || mig.Table == "" || mig.Target == "" ||
+		mig.Key == "" || len(mig.Columns) == 0 {
+		return ErrInvalidMigration
+	}
+	return nil
+}
+
+// dualWriteNames returns the trigger and function names for a migration
+func dualWriteNames(mig *OnlineMigration) (trigger, function string) {
+	return "trigger_dual_write_" + mig.Name, "dual_write_" + mig.Name
+}
+
+// dualWriteSQL builds the trigger that mirrors source writes into the shadow table
+func dualWriteSQL(mig *OnlineMigration) []string {
+	trigger, function := dualWriteNames(mig)
+
+	values := make([]string, len(mig.Columns))
+	for i, c := range mig.Columns {
+		values[i] = "NEW." + c
+	}
+
+	body := fmt.Sprintf(`
+		CREATE OR REPLACE FUNCTION %s()
+		RETURNS TRIGGER AS $$
+		BEGIN
+			IF TG_OP = 'DELETE' THEN
+				DELETE FROM %s WHERE %s = OLD.%s;
+				RETURN OLD;
+			END IF;
+			INSERT INTO %s (%s) VALUES (%s)
+			%s;
+			RETURN NEW;
+		END;
+		$$ LANGUAGE plpgsql`,
+		function,
+		mig.Target, mig.Key, mig.Key,
+		mig.Target, strings.Join(mig.Columns, ", "), strings.Join(values, ", "),
+		upsertClause(mig),
+	)
+
+	return []string{
+		body,
+		fmt.Sprintf("DROP TRIGGER IF EXISTS %s ON %s", trigger, mig.Table),
+		fmt.Sprintf(`CREATE TRIGGER %s
+			AFTER INSERT OR UPDATE OR DELETE ON %s
+			FOR EACH ROW
+			EXECUTE FUNCTION %s()`, trigger, mig.Table, function),
+	}
+}
+
+// copySQL builds an upsert copying source rows matching where into the shadow table
+func copySQL(mig *OnlineMigration, where string) string {
+	cols := strings.Join(mig.Columns, ", ")
+	return fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s WHERE %s %s",
+		mig.Target, cols, cols, mig.Table, where, upsertClause(mig))
+}
+
+// upsertClause overwrites shadow rows with the source's current values
+func upsertClause(mig *OnlineMigration) string {
+	sets := make([]string, 0, len(mig.Columns))
+	for _, c := range mig.Columns {
+		if c != mig.Key {
+			sets = append(sets, fmt.Sprintf("%s = EXCLUDED.%s", c, c))
+		}
+	}
+	return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", mig.Key, strings.Join(sets, ", "))
+}
@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ccoin/core/internal/reputation"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// SaveStake writes a miner's stake and its unbonding queue
+func (s *PostgresStore) SaveStake(ctx context.Context, stake *reputation.StakeInfo) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO miner_stakes (
+			address, total_staked, available_stake, locked_stake, locked_until_block,
+			total_slashed, slashing_ratio, bonded_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (address) DO UPDATE SET
+			total_staked = $2, available_stake = $3, locked_stake = $4,
+			locked_until_block = $5, total_slashed = $6, slashing_ratio = $7,
+			bonded_at = $8, updated_at = NOW()
+	`
+	_, err = tx.Exec(ctx, query,
+		stake.Address[:],
+		int64(stake.TotalStaked),
+		int64(stake.AvailableStake),
+		int64(stake.LockedStake),
+		int64(stake.LockedUntilBlock),
+		int64(stake.TotalSlashed),
+		stake.SlashingRatio,
+		int64(stake.BondedAt),
+	)
+	if err != nil {
+		return err
+	}
+
+	// The queue is small, so it is rewritten whole
+	if _, err := tx.Exec(ctx, `DELETE FROM stake_unbonding WHERE address = $1`, stake.Address[:]); err != nil {
+		return err
+	}
+	for _, u := range stake.Unbonding {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO stake_unbonding (address, amount, requested_at, completes_at)
+			VALUES ($1, $2, $3, $4)
+		`, stake.Address[:], int64(u.Amount), int64(u.RequestedAt), int64(u.CompletesAt))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetStake returns a miner's stake, or nil if it has never staked
+func (s *PostgresStore) GetStake(ctx context.Context, addr types.Address) (*reputation.StakeInfo, error) {
+	query := `
+		SELECT total_staked, available_stake, locked_stake, locked_until_block,
+			total_slashed, slashing_ratio, bonded_at
+		FROM miner_stakes WHERE address = $1
+	`
+	var total, available, locked, lockedUntil, slashed, bondedAt int64
+	stake := &reputation.StakeInfo{Address: addr}
+	err := s.pool.QueryRow(ctx, query, addr[:]).Scan(
+		&total, &available, &locked, &lockedUntil, &slashed, &stake.SlashingRatio, &bondedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stake.TotalStaked = uint64(total)
+	stake.AvailableStake = uint64(available)
+	stake.LockedStake = uint64(locked)
+	stake.LockedUntilBlock = uint64(lockedUntil)
+	stake.TotalSlashed = uint64(slashed)
+	stake.BondedAt = uint64(bondedAt)
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT amount, requested_at, completes_at FROM stake_unbonding
+		WHERE address = $1 ORDER BY completes_at ASC, id ASC
+	`, addr[:])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var amount, requestedAt, completesAt int64
+		if err := rows.Scan(&amount, &requestedAt, &completesAt); err != nil {
+			return nil, err
+		}
+		stake.Unbonding = append(stake.Unbonding, &reputation.Unbonding{
+			Amount:      uint64(amount),
+			RequestedAt: uint64(requestedAt),
+			CompletesAt: uint64(completesAt),
+		})
+	}
+	return stake, rows.Err()
+}
+
+// SaveEvidence records slashing evidence
+func (s *PostgresStore) SaveEvidence(ctx context.Context, evidence *reputation.SlashingEvidence) error {
+	query := `
+		INSERT INTO slashing_evidence (
+			evidence_hash, slash_type, miner_address, block_height, description,
+			proof_data, processed, slash_amount
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (evidence_hash) DO UPDATE SET processed = $7, slash_amount = $8
+	`
+	_, err := s.pool.Exec(ctx, query,
+		evidence.EvidenceHash[:],
+		int16(evidence.Type),
+		evidence.MinerAddress[:],
+		int64(evidence.BlockHeight),
+		evidence.Description,
+		evidence.ProofData,
+		evidence.Processed,
+		int64(evidence.SlashAmount),
+	)
+	return err
+}
+
+// GetPendingEvidence returns unprocessed slashing evidence, oldest first
+func (s *PostgresStore) GetPendingEvidence(ctx context.Context) ([]*reputation.SlashingEvidence, error) {
+	query := `
+		SELECT evidence_hash, slash_type, miner_address, block_height, description, proof_data
+		FROM slashing_evidence WHERE NOT processed
+		ORDER BY created_at
+	`
+	rows, err := s.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pending []*reputation.SlashingEvidence
+	for rows.Next() {
+		var hashBytes, addrBytes []byte
+		var slashType int16
+		var height int64
+		e := &reputation.SlashingEvidence{}
+		if err := rows.Scan(&hashBytes, &slashType, &addrBytes, &height, &e.Description, &e.ProofData); err != nil {
+			return nil, err
+		}
+		copy(e.EvidenceHash[:], hashBytes)
+		copy(e.MinerAddress[:], addrBytes)
+		e.Type = reputation.SlashingType(slashType)
+		e.BlockHeight = uint64(height)
+		pending = append(pending, e)
+	}
+	return pending, rows.Err()
+}
+
+// SaveDelegation writes a delegation
+func (s *PostgresStore) SaveDelegation(ctx context.Context, d *reputation.Delegation) error {
+	query := `
+		INSERT INTO stake_delegations (
+			delegator, miner, bonded, unbonding, unbonding_until, rewards, slashed, nonce
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (miner, delegator) DO UPDATE SET
+			bonded = $3, unbonding = $4, unbonding_until = $5, rewards = $6,
+			slashed = $7, nonce = $8, updated_at = NOW()
+	`
+	_, err := s.pool.Exec(ctx, query,
+		d.Delegator[:],
+		d.Miner[:],
+		int64(d.Bonded),
+		int64(d.Unbonding),
+		int64(d.UnbondingUntil),
+		int64(d.Rewards),
+		int64(d.Slashed),
+		int64(d.Nonce),
+	)
+	return err
+}
+
+// GetDelegations returns every delegation
+func (s *PostgresStore) GetDelegations(ctx context.Context) ([]*reputation.Delegation, error) {
+	query := `
+		SELECT delegator, miner, bonded, unbonding, unbonding_until, rewards, slashed, nonce
+		FROM stake_delegations
+	`
+	rows, err := s.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var delegations []*reputation.Delegation
+	for rows.Next() {
+		var delegator, miner []byte
+		var bonded, unbonding, unbondingUntil, rewards, slashed, nonce int64
+		if err := rows.Scan(&delegator, &miner, &bonded, &unbonding, &unbondingUntil, &rewards, &slashed, &nonce); err != nil {
+			return nil, err
+		}
+		d := &reputation.Delegation{
+			Bonded:         uint64(bonded),
+			Unbonding:      uint64(unbonding),
+			UnbondingUntil: uint64(unbondingUntil),
+			Rewards:        uint64(rewards),
+			Slashed:        uint64(slashed),
+			Nonce:          uint64(nonce),
+		}
+		copy(d.Delegator[:], delegator)
+		copy(d.Miner[:], miner)
+		delegations = append(delegations, d)
+	}
+	return delegations, rows.Err()
+}
+
+// SaveCapabilities registers the compute a miner offers
+func (s *PostgresStore) SaveCapabilities(ctx context.Context, addr types.Address, caps *types.ComputeCapabilities) error {
+	query := `
+		INSERT INTO miner_capabilities (address, cpus, memory_mb, gpus, gpu_memory_mb)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (address) DO UPDATE SET
+			cpus = $2, memory_mb = $3, gpus = $4, gpu_memory_mb = $5, updated_at = NOW()
+	`
+	gpus := caps.GPUs
+	if gpus == nil {
+		gpus = []string{}
+	}
+	_, err := s.pool.Exec(ctx, query, addr[:], caps.CPUs, int64(caps.MemoryMB), gpus, int64(caps.GPUMemoryMB))
+	return err
+}
+
+// GetCapabilities returns the compute a miner registered, or nil
+func (s *PostgresStore) GetCapabilities(ctx context.Context, addr types.Address) (*types.ComputeCapabilities, error) {
+	query := `SELECT cpus, memory_mb, gpus, gpu_memory_mb FROM miner_capabilities WHERE address = $1`
+
+	var memory, gpuMemory int64
+	caps := &types.ComputeCapabilities{}
+	err := s.pool.QueryRow(ctx, query, addr[:]).Scan(&caps.CPUs, &memory, &caps.GPUs, &gpuMemory)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	caps.MemoryMB = uint64(memory)
+	caps.GPUMemoryMB = uint64(gpuMemory)
+	return caps, nil
+}
@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// ============================================
+// Nullifier Set and Anchor History
+// ============================================
+
+// HasNullifier checks if a nullifier has been spent
+func (s *PostgresStore) HasNullifier(ctx context.Context, nullifier types.Hash) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM nullifiers WHERE nullifier = $1)`
+
+	var exists bool
+	if err := s.pool.QueryRow(ctx, query, nullifier[:]).Scan(&exists); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// HasNullifiers checks many nullifiers in one query
+func (s *PostgresStore) HasNullifiers(ctx context.Context, nullifiers []types.Hash) ([]bool, error) {
+	query := `SELECT nullifier FROM nullifiers WHERE nullifier = ANY($1)`
+
+	params := make([][]byte, len(nullifiers))
+	for i := range nullifiers {
+		params[i] = nullifiers[i][:]
+	}
+
+	rows, err := s.pool.Query(ctx, query, params)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	spent := make(map[types.Hash]struct{}, len(nullifiers))
+	for rows.Next() {
+		var nullifierBytes []byte
+		if err := rows.Scan(&nullifierBytes); err != nil {
+			return nil, err
+		}
+		var nullifier types.Hash
+		copy(nullifier[:], nullifierBytes)
+		spent[nullifier] = struct{}{}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make([]bool, len(nullifiers))
+	for i, nullifier := range nullifiers {
+		_, results[i] = spent[nullifier]
+	}
+	return results, nil
+}
+
+// AddNullifier marks a nullifier as spent. The spending transaction must
+// already be stored.
+func (s *PostgresStore) AddNullifier(ctx context.Context, nullifier types.Hash, txHash types.Hash, blockHeight uint64) error {
+	query := `
+		INSERT INTO nullifiers (nullifier, tx_hash, block_height)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (nullifier) DO NOTHING
+	`
+
+	tag, err := s.pool.Exec(ctx, query, nullifier[:], txHash[:], blockHeight)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return zkp.ErrNullifierSpent
+	}
+	return nil
+}
+
+// AddSpends marks a transaction's nullifiers spent and records the
+// commitment tree root it leaves, if any, in one database transaction
+func (s *PostgresStore) AddSpends(ctx context.Context, nullifiers []types.Hash, txHash types.Hash, blockHeight uint64, anchor *types.Hash) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// A nullifier listed twice conflicts with its own first insert
+	seen := make(map[types.Hash]bool, len(nullifiers))
+	for _, nullifier := range nullifiers {
+		if seen[nullifier] {
+			return zkp.ErrNullifierRepeat
+		}
+		seen[nullifier] = true
+
+		query := `
+			INSERT INTO nullifiers (nullifier, tx_hash, block_height)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (nullifier) DO NOTHING
+		`
+		tag, err := tx.Exec(ctx, query, nullifier[:], txHash[:], blockHeight)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return zkp.ErrNullifierSpent
+		}
+	}
+	if anchor != nil {
+		query := `INSERT INTO commitment_anchors (root, block_height) VALUES ($1, $2)`
+		if _, err := tx.Exec(ctx, query, anchor[:], blockHeight); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetNullifierInfo returns information about a spent nullifier
+func (s *PostgresStore) GetNullifierInfo(ctx context.Context, nullifier types.Hash) (*zkp.NullifierInfo, error) {
+	query := `SELECT tx_hash, block_height FROM nullifiers WHERE nullifier = $1`
+
+	var txHash []byte
+	info := &zkp.NullifierInfo{Nullifier: nullifier}
+	err := s.pool.QueryRow(ctx, query, nullifier[:]).Scan(&txHash, &info.BlockHeight)
+	if err == pgx.ErrNoRows {
+		return nil, zkp.ErrNullifierInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	copy(info.TxHash[:], txHash)
+	return info, nil
+}
+
+// AddAnchor records a new commitment tree root
+func (s *PostgresStore) AddAnchor(ctx context.Context, root types.Hash, blockHeight uint64) error {
+	query := `INSERT INTO commitment_anchors (root, block_height) VALUES ($1, $2)`
+
+	_, err := s.pool.Exec(ctx, query, root[:], blockHeight)
+	return err
+}
+
+// RecentAnchors returns up to n of the latest commitment tree roots,
+// newest first
+func (s *PostgresStore) RecentAnchors(ctx context.Context, n int) ([]types.Hash, error) {
+	query := `SELECT root FROM commitment_anchors ORDER BY seq DESC LIMIT $1`
+
+	rows, err := s.pool.Query(ctx, query, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roots []types.Hash
+	for rows.Next() {
+		var rootBytes []byte
+		if err := rows.Scan(&rootBytes); err != nil {
+			return nil, err
+		}
+		var root types.Hash
+		copy(root[:], rootBytes)
+		roots = append(roots, root)
+	}
+	return roots, rows.Err()
+}
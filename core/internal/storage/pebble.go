@@ -0,0 +1,1269 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+
+	"github.com/ccoin/core/internal/atrest"
+	"github.com/ccoin/core/internal/reputation"
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// ============================================
+// Embedded Pebble Store
+// ============================================
+
+// Key prefixes of the Pebble store. Heights and sequence numbers are
+// big-endian so keys sort in numeric order.
+const (
+	prefixBlock      byte = 'b' // hash -> pebbleBlock
+	prefixTx         byte = 't' // block hash, index -> transaction
+	prefixTxIndex    byte = 'i' // tx hash -> txLocation
+	prefixHeight     byte = 'h' // height, hash
+	prefixChild      byte = 'c' // parent hash, child hash
+	prefixMainChain  byte = 'm' // height, hash
+	prefixTip        byte = 'T' // hash
+	prefixNullifier  byte = 'n' // nullifier -> zkp.NullifierInfo
+	prefixCommitment byte = 'O' // commitment -> creating tx hash
+	prefixOutput     byte = 'o' // height, block hash, tx index, output -> CommitmentRecord
+	prefixAnchor     byte = 'a' // sequence -> root
+	prefixStake      byte = 's' // address -> reputation.StakeInfo
+	prefixEvidence   byte = 'e' // evidence hash -> pebbleEvidence
+	prefixDelegation byte = 'd' // miner, delegator -> reputation.Delegation
+	prefixCaps       byte = 'k' // address -> types.ComputeCapabilities
+	prefixSanction   byte = 'x' // address -> proposal ID
+	prefixAuthority  byte = 'A' // public key -> zkp.Authority
+	prefixRevocation byte = 'r' // serial -> proposal ID
+	prefixPolicy     byte = 'p' // network -> []zkp.PolicyRule
+	prefixCommittee  byte = 'C' // epoch, kind -> types.CommitteeRecord
+	prefixMeta       byte = 'M' // name -> value
+)
+
+// Meta keys
+var (
+	// metaPrunedHeight records the height below which blocks are pruned
+	metaPrunedHeight = []byte("pruned-height")
+
+	// metaEncryption holds a sealed check value in stores encrypted at
+	// rest
+	metaEncryption = []byte("encryption")
+
+	// metaIndexed is set once the transaction and output indexes cover
+	// every stored block
+	metaIndexed = []byte("indexed")
+)
+
+// indexBatchBlocks is the number of blocks buildIndexes indexes per batch
+const indexBatchBlocks = 1000
+
+// encryptionCheck is the value sealed under metaEncryption
+var encryptionCheck = []byte("CCOIN_PEBBLE_ENCRYPTED")
+
+// pebbleBlock is a stored block header with its chain flags
+type pebbleBlock struct {
+	Header    *types.BlockHeader
+	MainChain bool
+	Pruned    bool
+}
+
+// txLocation is where the transaction index finds a transaction
+type txLocation struct {
+	BlockHash types.Hash
+	Index     int
+}
+
+// pebbleEvidence is stored slashing evidence with the time it was first
+// recorded, which orders pending evidence
+type pebbleEvidence struct {
+	Evidence   *reputation.SlashingEvidence
+	RecordedAt int64
+}
+
+// PebbleStore implements Store on an embedded Pebble database, so a node
+// runs without a database server. Values are gob encoded and, with
+// encryption at rest, sealed bound to their key; keys stay in the clear.
+type PebbleStore struct {
+	db *pebble.DB
+
+	// cipher seals values, nil when they are stored in the clear
+	cipher *atrest.Cipher
+
+	// mu serializes writes that read before they write
+	mu sync.Mutex
+
+	// Sequence number of the latest anchor
+	anchorSeq uint64
+}
+
+// NewPebbleStore opens or creates a Pebble store in dir. With c set the
+// store's values are encrypted; a store is encrypted or not from its
+// creation on.
+func NewPebbleStore(dir string, c *atrest.Cipher) (*PebbleStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	db, err := pebble.Open(dir, &pebble.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDBConnection, err)
+	}
+
+	s := &PebbleStore{db: db, cipher: c.Sub("chainstate")}
+	if err := s.checkEncryption(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := s.buildIndexes(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to build indexes: %w", err)
+	}
+	err = s.scan([]byte{prefixAnchor}, true, func(key, value []byte) bool {
+		s.anchorSeq = binary.BigEndian.Uint64(key[1:])
+		return false
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close closes the database
+func (s *PebbleStore) Close() {
+	s.db.Close()
+}
+
+// checkEncryption checks that the store is encrypted, under the
+// store's key, exactly when a cipher is set. A new store records
+// whether it is.
+func (s *PebbleStore) checkEncryption() error {
+	k := key(prefixMeta, metaEncryption)
+	data, closer, err := s.db.Get(k)
+	if errors.Is(err, pebble.ErrNotFound) {
+		if s.cipher == nil {
+			return nil
+		}
+		iter, err := s.db.NewIter(nil)
+		if err != nil {
+			return err
+		}
+		empty := !iter.First()
+		if err := iter.Close(); err != nil {
+			return err
+		}
+		if !empty {
+			return atrest.ErrNotEncrypted
+		}
+		return s.db.Set(k, s.seal(k, encryptionCheck), pebble.Sync)
+	}
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	if s.cipher == nil {
+		return atrest.ErrEncrypted
+	}
+	plain, err := s.cipher.Open(data, k)
+	if err != nil || !bytes.Equal(plain, encryptionCheck) {
+		return atrest.ErrWrongKey
+	}
+	return nil
+}
+
+// ============================================
+// Keys and Values
+// ============================================
+
+// key joins a prefix and key parts
+func key(prefix byte, parts ...[]byte) []byte {
+	k := []byte{prefix}
+	for _, p := range parts {
+		k = append(k, p...)
+	}
+	return k
+}
+
+// be64 encodes n big-endian
+func be64(n uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, n)
+}
+
+// be32 encodes n big-endian
+func be32(n int) []byte {
+	return binary.BigEndian.AppendUint32(nil, uint32(n))
+}
+
+// prefixEnd returns the first key after every key with prefix
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+func encodeValue(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeValue(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// get reads and decodes the value at k, returning ErrNotFound if there
+// is none
+func (s *PebbleStore) get(k []byte, v interface{}) error {
+	data, closer, err := s.db.Get(k)
+	if errors.Is(err, pebble.ErrNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+	data, err = s.open(k, data)
+	if err != nil {
+		return err
+	}
+	return decodeValue(data, v)
+}
+
+// has reports whether k is set
+func (s *PebbleStore) has(k []byte) (bool, error) {
+	_, closer, err := s.db.Get(k)
+	if errors.Is(err, pebble.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	closer.Close()
+	return true, nil
+}
+
+// put encodes v into a batch at k
+func (s *PebbleStore) put(batch *pebble.Batch, k []byte, v interface{}) error {
+	data, err := encodeValue(v)
+	if err != nil {
+		return err
+	}
+	return batch.Set(k, s.seal(k, data), nil)
+}
+
+// seal encrypts the value at k. Empty values, whose keys carry all
+// there is, are left empty.
+func (s *PebbleStore) seal(k, data []byte) []byte {
+	if s.cipher == nil || len(data) == 0 {
+		return data
+	}
+	return s.cipher.Seal(data, k)
+}
+
+// open decrypts what seal stored at k
+func (s *PebbleStore) open(k, data []byte) ([]byte, error) {
+	if s.cipher == nil || len(data) == 0 {
+		return data, nil
+	}
+	return s.cipher.Open(data, k)
+}
+
+// scan calls fn with every key and value under prefix, in key order or
+// in reverse, until fn returns false. Keys, and values when stored in
+// the clear, are only valid during the call.
+func (s *PebbleStore) scan(prefix []byte, reverse bool, fn func(key, value []byte) bool) error {
+	return s.scanRange(prefix, prefixEnd(prefix), reverse, fn)
+}
+
+// scanRange is scan over the keys in [lower, upper)
+func (s *PebbleStore) scanRange(lower, upper []byte, reverse bool, fn func(key, value []byte) bool) error {
+	iter, err := s.db.NewIter(&pebble.IterOptions{LowerBound: lower, UpperBound: upper})
+	if err != nil {
+		return err
+	}
+	var openErr error
+	visit := func() bool {
+		value, err := s.open(iter.Key(), iter.Value())
+		if err != nil {
+			openErr = err
+			return false
+		}
+		return fn(iter.Key(), value)
+	}
+	if reverse {
+		for iter.Last(); iter.Valid(); iter.Prev() {
+			if !visit() {
+				break
+			}
+		}
+	} else {
+		for iter.First(); iter.Valid(); iter.Next() {
+			if !visit() {
+				break
+			}
+		}
+	}
+	if err := iter.Error(); err != nil {
+		iter.Close()
+		return err
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+	return openErr
+}
+
+// hashes collects the hashes ending the keys under prefix
+func (s *PebbleStore) hashes(prefix []byte) ([]types.Hash, error) {
+	var out []types.Hash
+	err := s.scan(prefix, false, func(k, _ []byte) bool {
+		var hash types.Hash
+		copy(hash[:], k[len(k)-types.HashSize:])
+		out = append(out, hash)
+		return true
+	})
+	return out, err
+}
+
+// addresses collects the addresses ending the keys under prefix
+func (s *PebbleStore) addresses(prefix []byte) ([]types.Address, error) {
+	var out []types.Address
+	err := s.scan(prefix, false, func(k, _ []byte) bool {
+		var addr types.Address
+		copy(addr[:], k[len(k)-types.AddressSize:])
+		out = append(out, addr)
+		return true
+	})
+	return out, err
+}
+
+// ============================================
+// Block Operations
+// ============================================
+
+// SaveBlock stores a block with its transactions, the nullifiers they
+// spend and the commitments they create. A block already stored is left
+// unchanged.
+func (s *PebbleStore) SaveBlock(ctx context.Context, block *types.Block) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	header := block.Header
+	if exists, err := s.has(key(prefixBlock, header.Hash[:])); err != nil || exists {
+		return err
+	}
+
+	batch := s.db.NewBatch()
+	defer batch.Close()
+
+	if err := s.put(batch, key(prefixBlock, header.Hash[:]), &pebbleBlock{Header: header}); err != nil {
+		return fmt.Errorf("failed to save block: %w", err)
+	}
+	batch.Set(key(prefixHeight, be64(header.Height), header.Hash[:]), nil, nil)
+	for _, parent := range header.Parents {
+		batch.Set(key(prefixChild, parent[:], header.Hash[:]), nil, nil)
+		batch.Delete(key(prefixTip, parent[:]), nil)
+	}
+	children, err := s.hashes(key(prefixChild, header.Hash[:]))
+	if err != nil {
+		return err
+	}
+	if len(children) == 0 {
+		batch.Set(key(prefixTip, header.Hash[:]), nil, nil)
+	}
+
+	spent := make(map[types.Hash]bool)
+	created := make(map[types.Hash]bool)
+	for i, tx := range block.Transactions {
+		if err := s.put(batch, key(prefixTx, header.Hash[:], be32(i)), tx); err != nil {
+			return fmt.Errorf("failed to save transaction: %w", err)
+		}
+		if err := s.indexTransaction(batch, header, i, tx, created); err != nil {
+			return err
+		}
+		for _, nullifier := range tx.Nullifiers {
+			if spent[nullifier] {
+				continue
+			}
+			if exists, err := s.has(key(prefixNullifier, nullifier[:])); err != nil {
+				return err
+			} else if exists {
+				continue
+			}
+			spent[nullifier] = true
+			info := &zkp.NullifierInfo{Nullifier: nullifier, TxHash: tx.TxHash, BlockHeight: header.Height}
+			if err := s.put(batch, key(prefixNullifier, nullifier[:]), info); err != nil {
+				return err
+			}
+		}
+	}
+
+	return batch.Commit(pebble.Sync)
+}
+
+// indexTransaction adds the i-th transaction of a block to the
+// transaction index, and the commitments it creates to the output index
+// unless created or the store already holds them
+func (s *PebbleStore) indexTransaction(batch *pebble.Batch, header *types.BlockHeader, i int, tx *types.Transaction, created map[types.Hash]bool) error {
+	if err := s.put(batch, key(prefixTxIndex, tx.TxHash[:]), &txLocation{BlockHash: header.Hash, Index: i}); err != nil {
+		return err
+	}
+	for j, c := range tx.Commitments {
+		if created[c.Value] {
+			continue
+		}
+		if exists, err := s.has(key(prefixCommitment, c.Value[:])); err != nil {
+			return err
+		} else if exists {
+			continue
+		}
+		created[c.Value] = true
+		if err := s.put(batch, key(prefixCommitment, c.Value[:]), tx.TxHash); err != nil {
+			return err
+		}
+		record := &CommitmentRecord{Commitment: c, TxHash: tx.TxHash, BlockHash: header.Hash, BlockHeight: header.Height, Output: j}
+		if err := s.put(batch, key(prefixOutput, be64(header.Height), header.Hash[:], be32(i), be32(j)), record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildIndexes builds the transaction and output indexes of a store
+// written before they existed, in height order
+func (s *PebbleStore) buildIndexes() error {
+	if built, err := s.has(key(prefixMeta, metaIndexed)); err != nil || built {
+		return err
+	}
+
+	var hashes []types.Hash
+	err := s.scan([]byte{prefixHeight}, false, func(k, _ []byte) bool {
+		var hash types.Hash
+		copy(hash[:], k[9:])
+		hashes = append(hashes, hash)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	created := make(map[types.Hash]bool)
+	for start := 0; start < len(hashes); start += indexBatchBlocks {
+		batch := s.db.NewBatch()
+		for _, hash := range hashes[start:min(start+indexBatchBlocks, len(hashes))] {
+			if err := s.indexBlock(batch, hash, created); err != nil {
+				batch.Close()
+				return err
+			}
+		}
+		err := batch.Commit(pebble.Sync)
+		batch.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return s.db.Set(key(prefixMeta, metaIndexed), nil, pebble.Sync)
+}
+
+// indexBlock indexes the stored transactions of a block. Their keys carry
+// their positions, which pruning may have left with gaps.
+func (s *PebbleStore) indexBlock(batch *pebble.Batch, hash types.Hash, created map[types.Hash]bool) error {
+	b, err := s.getBlock(hash)
+	if err != nil {
+		return err
+	}
+	var indexErr error
+	err = s.scan(key(prefixTx, hash[:]), false, func(k, value []byte) bool {
+		var tx types.Transaction
+		if indexErr = decodeValue(value, &tx); indexErr != nil {
+			return false
+		}
+		i := int(binary.BigEndian.Uint32(k[1+types.HashSize:]))
+		indexErr = s.indexTransaction(batch, b.Header, i, &tx, created)
+		return indexErr == nil
+	})
+	if err == nil {
+		err = indexErr
+	}
+	return err
+}
+
+// getBlock reads a stored block record
+func (s *PebbleStore) getBlock(hash types.Hash) (*pebbleBlock, error) {
+	var b pebbleBlock
+	if err := s.get(key(prefixBlock, hash[:]), &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// GetBlock retrieves a complete block by hash
+func (s *PebbleStore) GetBlock(ctx context.Context, hash types.Hash) (*types.Block, error) {
+	header, err := s.GetBlockHeader(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	txs, err := s.getBlockTransactions(hash)
+	if err != nil {
+		return nil, err
+	}
+	return &types.Block{Header: header, Transactions: txs}, nil
+}
+
+// getBlockTransactions reads a block's transactions in order
+func (s *PebbleStore) getBlockTransactions(hash types.Hash) ([]*types.Transaction, error) {
+	var txs []*types.Transaction
+	var decodeErr error
+	err := s.scan(key(prefixTx, hash[:]), false, func(_, value []byte) bool {
+		var tx types.Transaction
+		if decodeErr = decodeValue(value, &tx); decodeErr != nil {
+			return false
+		}
+		txs = append(txs, &tx)
+		return true
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	return txs, err
+}
+
+// GetBlockHeader retrieves a block header by hash
+func (s *PebbleStore) GetBlockHeader(ctx context.Context, hash types.Hash) (*types.BlockHeader, error) {
+	b, err := s.getBlock(hash)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to get block header: %w", err)
+	}
+	return b.Header, nil
+}
+
+// headers reads the headers of hashes
+func (s *PebbleStore) headers(ctx context.Context, hashes []types.Hash) ([]*types.BlockHeader, error) {
+	headers := make([]*types.BlockHeader, 0, len(hashes))
+	for _, hash := range hashes {
+		header, err := s.GetBlockHeader(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		headers = append(headers, header)
+	}
+	return headers, nil
+}
+
+// GetBlocksByHeight returns all blocks at a given height
+func (s *PebbleStore) GetBlocksByHeight(ctx context.Context, height uint64) ([]*types.BlockHeader, error) {
+	hashes, err := s.hashes(key(prefixHeight, be64(height)))
+	if err != nil {
+		return nil, err
+	}
+	return s.headers(ctx, hashes)
+}
+
+// GetChildren returns child block hashes for a given block
+func (s *PebbleStore) GetChildren(ctx context.Context, hash types.Hash) ([]types.Hash, error) {
+	return s.hashes(key(prefixChild, hash[:]))
+}
+
+// GetMainChain returns main chain blocks in height order
+func (s *PebbleStore) GetMainChain(ctx context.Context, fromHeight, toHeight uint64) ([]*types.BlockHeader, error) {
+	if fromHeight > toHeight {
+		return nil, nil
+	}
+	upper := prefixEnd([]byte{prefixMainChain})
+	if toHeight < math.MaxUint64 {
+		upper = key(prefixMainChain, be64(toHeight+1))
+	}
+
+	var hashes []types.Hash
+	err := s.scanRange(key(prefixMainChain, be64(fromHeight)), upper, false, func(k, _ []byte) bool {
+		var hash types.Hash
+		copy(hash[:], k[9:])
+		hashes = append(hashes, hash)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.headers(ctx, hashes)
+}
+
+// GetMainChainByTime returns main chain blocks with timestamps in
+// [from, to), in height order
+func (s *PebbleStore) GetMainChainByTime(ctx context.Context, from, to int64) ([]*types.Block, error) {
+	headers, err := s.GetMainChain(ctx, 0, math.MaxUint64)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []*types.Block
+	for _, header := range headers {
+		if int64(header.Timestamp) < from || int64(header.Timestamp) >= to {
+			continue
+		}
+		block, err := s.GetBlock(ctx, header.Hash)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+// UpdateMainChain updates main chain status for blocks
+func (s *PebbleStore) UpdateMainChain(ctx context.Context, onChain, offChain []types.Hash) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated := make(map[types.Hash]*pebbleBlock)
+	mark := func(hash types.Hash, mainChain bool) error {
+		b, exists := updated[hash]
+		if !exists {
+			var err error
+			if b, err = s.getBlock(hash); errors.Is(err, ErrNotFound) {
+				return nil // Like an UPDATE matching no rows
+			} else if err != nil {
+				return err
+			}
+			updated[hash] = b
+		}
+		b.MainChain = mainChain
+		return nil
+	}
+	for _, hash := range onChain {
+		if err := mark(hash, true); err != nil {
+			return err
+		}
+	}
+	for _, hash := range offChain {
+		if err := mark(hash, false); err != nil {
+			return err
+		}
+	}
+
+	batch := s.db.NewBatch()
+	defer batch.Close()
+	for hash, b := range updated {
+		if err := s.put(batch, key(prefixBlock, hash[:]), b); err != nil {
+			return err
+		}
+		mainKey := key(prefixMainChain, be64(b.Header.Height), hash[:])
+		if b.MainChain {
+			batch.Set(mainKey, nil, nil)
+		} else {
+			batch.Delete(mainKey, nil)
+		}
+	}
+	return batch.Commit(pebble.Sync)
+}
+
+// GetTips returns current DAG tips (blocks with no children)
+func (s *PebbleStore) GetTips(ctx context.Context) ([]types.Hash, error) {
+	return s.hashes([]byte{prefixTip})
+}
+
+// ============================================
+// Block Pruning
+// ============================================
+
+// PruneBlocks discards the bodies of the blocks below height, as
+// PostgresStore.PruneBlocks does
+func (s *PebbleStore) PruneBlocks(ctx context.Context, below uint64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var hashes []types.Hash
+	err := s.scanRange([]byte{prefixHeight}, key(prefixHeight, be64(below)), false, func(k, _ []byte) bool {
+		var hash types.Hash
+		copy(hash[:], k[9:])
+		hashes = append(hashes, hash)
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	batch := s.db.NewBatch()
+	defer batch.Close()
+
+	var pruned int64
+	for _, hash := range hashes {
+		b, err := s.getBlock(hash)
+		if err != nil {
+			return 0, err
+		}
+		if b.Pruned {
+			continue
+		}
+		txs, err := s.getBlockTransactions(hash)
+		if err != nil {
+			return 0, err
+		}
+		for i, tx := range txs {
+			txKey := key(prefixTx, hash[:], be32(i))
+			referenced, err := s.referenced(tx)
+			if err != nil {
+				return 0, err
+			}
+			if !b.MainChain && !referenced {
+				batch.Delete(txKey, nil)
+				var loc txLocation
+				if err := s.get(key(prefixTxIndex, tx.TxHash[:]), &loc); err == nil && loc.BlockHash == hash && loc.Index == i {
+					batch.Delete(key(prefixTxIndex, tx.TxHash[:]), nil)
+				} else if err != nil && !errors.Is(err, ErrNotFound) {
+					return 0, err
+				}
+				continue
+			}
+			tx.Proof.ProofData = nil
+			tx.Disclosures = nil
+			tx.Memo = nil
+			if err := s.put(batch, txKey, tx); err != nil {
+				return 0, err
+			}
+		}
+		b.Pruned = true
+		if err := s.put(batch, key(prefixBlock, hash[:]), b); err != nil {
+			return 0, err
+		}
+		pruned++
+	}
+
+	var height uint64
+	if err := s.get(key(prefixMeta, metaPrunedHeight), &height); err != nil && !errors.Is(err, ErrNotFound) {
+		return 0, err
+	}
+	if below > height {
+		if err := s.put(batch, key(prefixMeta, metaPrunedHeight), below); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := batch.Commit(pebble.Sync); err != nil {
+		return 0, fmt.Errorf("failed to prune blocks: %w", err)
+	}
+	return pruned, nil
+}
+
+// referenced reports whether a recorded nullifier or commitment points
+// at tx
+func (s *PebbleStore) referenced(tx *types.Transaction) (bool, error) {
+	for _, c := range tx.Commitments {
+		var creator types.Hash
+		err := s.get(key(prefixCommitment, c.Value[:]), &creator)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if creator == tx.TxHash {
+			return true, nil
+		}
+	}
+	for _, nullifier := range tx.Nullifiers {
+		var info zkp.NullifierInfo
+		err := s.get(key(prefixNullifier, nullifier[:]), &info)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if info.TxHash == tx.TxHash {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// PrunedHeight returns the height below which block bodies have been
+// pruned, zero if none have
+func (s *PebbleStore) PrunedHeight(ctx context.Context) (uint64, error) {
+	var height uint64
+	if err := s.get(key(prefixMeta, metaPrunedHeight), &height); err != nil && !errors.Is(err, ErrNotFound) {
+		return 0, err
+	}
+	return height, nil
+}
+
+// ============================================
+// Explorer Indexes
+// ============================================
+
+// GetTransaction returns a transaction by hash, whichever block included
+// it
+func (s *PebbleStore) GetTransaction(ctx context.Context, txHash types.Hash) (*TxRecord, error) {
+	var loc txLocation
+	if err := s.get(key(prefixTxIndex, txHash[:]), &loc); err != nil {
+		return nil, err
+	}
+	var tx types.Transaction
+	if err := s.get(key(prefixTx, loc.BlockHash[:], be32(loc.Index)), &tx); err != nil {
+		return nil, err
+	}
+	b, err := s.getBlock(loc.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+	return &TxRecord{Tx: &tx, BlockHash: loc.BlockHash, BlockHeight: b.Header.Height, Index: loc.Index}, nil
+}
+
+// GetTransactionByNullifier returns the transaction that spent a
+// nullifier
+func (s *PebbleStore) GetTransactionByNullifier(ctx context.Context, nullifier types.Hash) (*TxRecord, error) {
+	var info zkp.NullifierInfo
+	if err := s.get(key(prefixNullifier, nullifier[:]), &info); err != nil {
+		return nil, err
+	}
+	return s.GetTransaction(ctx, info.TxHash)
+}
+
+// GetCommitments returns the commitments created by blocks with heights
+// in [fromHeight, toHeight]
+func (s *PebbleStore) GetCommitments(ctx context.Context, fromHeight, toHeight uint64) ([]*CommitmentRecord, error) {
+	if fromHeight > toHeight {
+		return nil, nil
+	}
+	upper := prefixEnd([]byte{prefixOutput})
+	if toHeight < math.MaxUint64 {
+		upper = key(prefixOutput, be64(toHeight+1))
+	}
+
+	var records []*CommitmentRecord
+	var decodeErr error
+	err := s.scanRange(key(prefixOutput, be64(fromHeight)), upper, false, func(_, value []byte) bool {
+		var record CommitmentRecord
+		if decodeErr = decodeValue(value, &record); decodeErr != nil {
+			return false
+		}
+		records = append(records, &record)
+		return true
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	return records, err
+}
+
+// ============================================
+// Nullifier Set and Anchor History
+// ============================================
+
+// HasNullifier checks if a nullifier has been spent
+func (s *PebbleStore) HasNullifier(ctx context.Context, nullifier types.Hash) (bool, error) {
+	return s.has(key(prefixNullifier, nullifier[:]))
+}
+
+// HasNullifiers checks many nullifiers
+func (s *PebbleStore) HasNullifiers(ctx context.Context, nullifiers []types.Hash) ([]bool, error) {
+	results := make([]bool, len(nullifiers))
+	for i, nullifier := range nullifiers {
+		spent, err := s.has(key(prefixNullifier, nullifier[:]))
+		if err != nil {
+			return nil, err
+		}
+		results[i] = spent
+	}
+	return results, nil
+}
+
+// AddNullifier marks a nullifier as spent
+func (s *PebbleStore) AddNullifier(ctx context.Context, nullifier types.Hash, txHash types.Hash, blockHeight uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key(prefixNullifier, nullifier[:])
+	if spent, err := s.has(k); err != nil {
+		return err
+	} else if spent {
+		return zkp.ErrNullifierSpent
+	}
+	data, err := encodeValue(&zkp.NullifierInfo{Nullifier: nullifier, TxHash: txHash, BlockHeight: blockHeight})
+	if err != nil {
+		return err
+	}
+	return s.db.Set(k, s.seal(k, data), pebble.Sync)
+}
+
+// AddSpends marks a transaction's nullifiers spent and records the
+// commitment tree root it leaves, if any, in one batch
+func (s *PebbleStore) AddSpends(ctx context.Context, nullifiers []types.Hash, txHash types.Hash, blockHeight uint64, anchor *types.Hash) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch := s.db.NewBatch()
+	defer batch.Close()
+
+	seen := make(map[types.Hash]bool, len(nullifiers))
+	for _, nullifier := range nullifiers {
+		if seen[nullifier] {
+			return zkp.ErrNullifierRepeat
+		}
+		seen[nullifier] = true
+
+		k := key(prefixNullifier, nullifier[:])
+		if spent, err := s.has(k); err != nil {
+			return err
+		} else if spent {
+			return zkp.ErrNullifierSpent
+		}
+		if err := s.put(batch, k, &zkp.NullifierInfo{Nullifier: nullifier, TxHash: txHash, BlockHeight: blockHeight}); err != nil {
+			return err
+		}
+	}
+	if anchor != nil {
+		k := key(prefixAnchor, be64(s.anchorSeq+1))
+		value := append(append([]byte{}, anchor[:]...), be64(blockHeight)...)
+		batch.Set(k, s.seal(k, value), nil)
+	}
+
+	if err := batch.Commit(pebble.Sync); err != nil {
+		return err
+	}
+	if anchor != nil {
+		s.anchorSeq++
+	}
+	return nil
+}
+
+// GetNullifierInfo returns information about a spent nullifier
+func (s *PebbleStore) GetNullifierInfo(ctx context.Context, nullifier types.Hash) (*zkp.NullifierInfo, error) {
+	var info zkp.NullifierInfo
+	err := s.get(key(prefixNullifier, nullifier[:]), &info)
+	if errors.Is(err, ErrNotFound) {
+		return nil, zkp.ErrNullifierInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// AddAnchor records a new commitment tree root
+func (s *PebbleStore) AddAnchor(ctx context.Context, root types.Hash, blockHeight uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key(prefixAnchor, be64(s.anchorSeq+1))
+	value := append(append([]byte{}, root[:]...), be64(blockHeight)...)
+	if err := s.db.Set(k, s.seal(k, value), pebble.Sync); err != nil {
+		return err
+	}
+	s.anchorSeq++
+	return nil
+}
+
+// RecentAnchors returns up to n of the latest commitment tree roots,
+// newest first
+func (s *PebbleStore) RecentAnchors(ctx context.Context, n int) ([]types.Hash, error) {
+	var roots []types.Hash
+	err := s.scan([]byte{prefixAnchor}, true, func(_, value []byte) bool {
+		if len(roots) >= n {
+			return false
+		}
+		var root types.Hash
+		copy(root[:], value)
+		roots = append(roots, root)
+		return true
+	})
+	return roots, err
+}
+
+// ============================================
+// Miners
+// ============================================
+
+// SaveStake writes a miner's stake and its unbonding queue
+func (s *PebbleStore) SaveStake(ctx context.Context, stake *reputation.StakeInfo) error {
+	data, err := encodeValue(stake)
+	if err != nil {
+		return err
+	}
+	k := key(prefixStake, stake.Address[:])
+	return s.db.Set(k, s.seal(k, data), pebble.Sync)
+}
+
+// GetStake returns a miner's stake, or nil if it has never staked
+func (s *PebbleStore) GetStake(ctx context.Context, addr types.Address) (*reputation.StakeInfo, error) {
+	var stake reputation.StakeInfo
+	err := s.get(key(prefixStake, addr[:]), &stake)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stake, nil
+}
+
+// SaveEvidence records slashing evidence. Evidence already recorded only
+// has its outcome updated.
+func (s *PebbleStore) SaveEvidence(ctx context.Context, evidence *reputation.SlashingEvidence) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key(prefixEvidence, evidence.EvidenceHash[:])
+	var record pebbleEvidence
+	err := s.get(k, &record)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		copied := *evidence
+		record = pebbleEvidence{Evidence: &copied, RecordedAt: time.Now().UnixNano()}
+	case err != nil:
+		return err
+	default:
+		record.Evidence.Processed = evidence.Processed
+		record.Evidence.SlashAmount = evidence.SlashAmount
+	}
+
+	data, err := encodeValue(&record)
+	if err != nil {
+		return err
+	}
+	return s.db.Set(k, s.seal(k, data), pebble.Sync)
+}
+
+// GetPendingEvidence returns unprocessed slashing evidence, oldest first
+func (s *PebbleStore) GetPendingEvidence(ctx context.Context) ([]*reputation.SlashingEvidence, error) {
+	var records []pebbleEvidence
+	var decodeErr error
+	err := s.scan([]byte{prefixEvidence}, false, func(_, value []byte) bool {
+		var record pebbleEvidence
+		if decodeErr = decodeValue(value, &record); decodeErr != nil {
+			return false
+		}
+		if !record.Evidence.Processed {
+			records = append(records, record)
+		}
+		return true
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].RecordedAt < records[j].RecordedAt })
+	pending := make([]*reputation.SlashingEvidence, len(records))
+	for i, record := range records {
+		pending[i] = record.Evidence
+	}
+	return pending, nil
+}
+
+// SaveDelegation writes a delegation
+func (s *PebbleStore) SaveDelegation(ctx context.Context, d *reputation.Delegation) error {
+	data, err := encodeValue(d)
+	if err != nil {
+		return err
+	}
+	k := key(prefixDelegation, d.Miner[:], d.Delegator[:])
+	return s.db.Set(k, s.seal(k, data), pebble.Sync)
+}
+
+// GetDelegations returns every delegation
+func (s *PebbleStore) GetDelegations(ctx context.Context) ([]*reputation.Delegation, error) {
+	var delegations []*reputation.Delegation
+	var decodeErr error
+	err := s.scan([]byte{prefixDelegation}, false, func(_, value []byte) bool {
+		var d reputation.Delegation
+		if decodeErr = decodeValue(value, &d); decodeErr != nil {
+			return false
+		}
+		delegations = append(delegations, &d)
+		return true
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	return delegations, err
+}
+
+// SaveCapabilities registers the compute a miner offers
+func (s *PebbleStore) SaveCapabilities(ctx context.Context, addr types.Address, caps *types.ComputeCapabilities) error {
+	data, err := encodeValue(caps)
+	if err != nil {
+		return err
+	}
+	k := key(prefixCaps, addr[:])
+	return s.db.Set(k, s.seal(k, data), pebble.Sync)
+}
+
+// GetCapabilities returns the compute a miner registered, or nil
+func (s *PebbleStore) GetCapabilities(ctx context.Context, addr types.Address) (*types.ComputeCapabilities, error) {
+	var caps types.ComputeCapabilities
+	err := s.get(key(prefixCaps, addr[:]), &caps)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &caps, nil
+}
+
+// ============================================
+// Governance
+// ============================================
+
+// LoadSanctions returns every sanctioned address
+func (s *PebbleStore) LoadSanctions(ctx context.Context) ([]types.Address, error) {
+	return s.addresses([]byte{prefixSanction})
+}
+
+// SaveSanctions applies a sanctions list change atomically
+func (s *PebbleStore) SaveSanctions(ctx context.Context, add, remove []types.Address, proposalID types.Hash) error {
+	return s.saveAddressSet(prefixSanction, add, remove, proposalID)
+}
+
+// LoadAuthorities returns every registered credential authority
+func (s *PebbleStore) LoadAuthorities(ctx context.Context) ([]zkp.Authority, error) {
+	var authorities []zkp.Authority
+	var decodeErr error
+	err := s.scan([]byte{prefixAuthority}, false, func(_, value []byte) bool {
+		var a zkp.Authority
+		if decodeErr = decodeValue(value, &a); decodeErr != nil {
+			return false
+		}
+		authorities = append(authorities, a)
+		return true
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	return authorities, err
+}
+
+// SaveAuthority registers a credential authority, or removes it
+func (s *PebbleStore) SaveAuthority(ctx context.Context, authority zkp.Authority, remove bool, proposalID types.Hash) error {
+	k := key(prefixAuthority, authority.PublicKey[:])
+	if remove {
+		return s.db.Delete(k, pebble.Sync)
+	}
+	data, err := encodeValue(&authority)
+	if err != nil {
+		return err
+	}
+	return s.db.Set(k, s.seal(k, data), pebble.Sync)
+}
+
+// LoadRevocations returns the serial of every revoked credential
+func (s *PebbleStore) LoadRevocations(ctx context.Context) ([]types.Address, error) {
+	return s.addresses([]byte{prefixRevocation})
+}
+
+// SaveRevocations applies a revocation list change atomically
+func (s *PebbleStore) SaveRevocations(ctx context.Context, revoke, reinstate []types.Address, proposalID types.Hash) error {
+	return s.saveAddressSet(prefixRevocation, revoke, reinstate, proposalID)
+}
+
+// saveAddressSet adds and removes addresses of the set under prefix in
+// one batch. Addresses already in the set keep the proposal that added
+// them.
+func (s *PebbleStore) saveAddressSet(prefix byte, add, remove []types.Address, proposalID types.Hash) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch := s.db.NewBatch()
+	defer batch.Close()
+
+	for _, addr := range add {
+		k := key(prefix, addr[:])
+		if exists, err := s.has(k); err != nil {
+			return err
+		} else if !exists {
+			batch.Set(k, s.seal(k, proposalID[:]), nil)
+		}
+	}
+	for _, addr := range remove {
+		batch.Delete(key(prefix, addr[:]), nil)
+	}
+	return batch.Commit(pebble.Sync)
+}
+
+// LoadPolicyRules returns the disclosure policy rules of a network in
+// order
+func (s *PebbleStore) LoadPolicyRules(ctx context.Context, network string) ([]zkp.PolicyRule, error) {
+	var rules []zkp.PolicyRule
+	err := s.get(key(prefixPolicy, []byte(network)), &rules)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	return rules, err
+}
+
+// SavePolicyRules replaces the disclosure policy rules of a network
+func (s *PebbleStore) SavePolicyRules(ctx context.Context, network string, rules []zkp.PolicyRule, proposalID types.Hash) error {
+	k := key(prefixPolicy, []byte(network))
+	if len(rules) == 0 {
+		return s.db.Delete(k, pebble.Sync)
+	}
+	data, err := encodeValue(rules)
+	if err != nil {
+		return err
+	}
+	return s.db.Set(k, s.seal(k, data), pebble.Sync)
+}
+
+// SaveCommitteeRecords stores the committee selections of an epoch.
+// Records already stored are left unchanged.
+func (s *PebbleStore) SaveCommitteeRecords(ctx context.Context, records []*types.CommitteeRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch := s.db.NewBatch()
+	defer batch.Close()
+
+	for _, r := range records {
+		k := key(prefixCommittee, be64(r.Epoch), []byte{byte(r.Kind)})
+		if exists, err := s.has(k); err != nil {
+			return err
+		} else if exists {
+			continue
+		}
+		if err := s.put(batch, k, r); err != nil {
+			return err
+		}
+	}
+	return batch.Commit(pebble.Sync)
+}
+
+// LoadCommitteeRecords returns the committee selections of an epoch
+func (s *PebbleStore) LoadCommitteeRecords(ctx context.Context, epoch uint64) ([]*types.CommitteeRecord, error) {
+	var records []*types.CommitteeRecord
+	var decodeErr error
+	err := s.scan(key(prefixCommittee, be64(epoch)), false, func(_, value []byte) bool {
+		var r types.CommitteeRecord
+		if decodeErr = decodeValue(value, &r); decodeErr != nil {
+			return false
+		}
+		records = append(records, &r)
+		return true
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	return records, err
+}
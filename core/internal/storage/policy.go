@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// LoadPolicyRules returns the disclosure policy rules of a network in
+// order
+func (s *PostgresStore) LoadPolicyRules(ctx context.Context, network string) ([]zkp.PolicyRule, error) {
+	query := `
+		SELECT name, min_value, recipients, jurisdictions, required_flags
+		FROM disclosure_policy_rules
+		WHERE network = $1
+		ORDER BY position`
+
+	rows, err := s.pool.Query(ctx, query, network)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []zkp.PolicyRule
+	for rows.Next() {
+		var rule zkp.PolicyRule
+		var minValue int64
+		var recipients []int16
+		var required int64
+		if err := rows.Scan(&rule.Name, &minValue, &recipients, &rule.Jurisdictions, &required); err != nil {
+			return nil, err
+		}
+		rule.MinValue = uint64(minValue)
+		for _, c := range recipients {
+			rule.Recipients = append(rule.Recipients, zkp.RecipientClass(c))
+		}
+		rule.Require = zkp.DisclosureFlags(required)
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// SavePolicyRules replaces the disclosure policy rules of a network
+// atomically
+func (s *PostgresStore) SavePolicyRules(ctx context.Context, network string, rules []zkp.PolicyRule, proposalID types.Hash) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM disclosure_policy_rules WHERE network = $1`, network); err != nil {
+		return err
+	}
+	for i, rule := range rules {
+		recipients := make([]int16, len(rule.Recipients))
+		for j, c := range rule.Recipients {
+			recipients[j] = int16(c)
+		}
+		jurisdictions := rule.Jurisdictions
+		if jurisdictions == nil {
+			jurisdictions = []string{}
+		}
+		query := `
+			INSERT INTO disclosure_policy_rules (
+				network, position, name, min_value, recipients, jurisdictions,
+				required_flags, proposal_id
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+		if _, err := tx.Exec(ctx, query, network, i, rule.Name, int64(rule.MinValue),
+			recipients, jurisdictions, int64(rule.Require), proposalID[:]); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/ccoin/core/internal/atrest"
 	"github.com/ccoin/core/pkg/types"
 )
 
@@ -27,6 +28,16 @@ type PostgresStore struct {
 
 // Config holds database configuration
 type Config struct {
+	// Backend selects the store Open returns, BackendPostgres if empty
+	Backend string
+
+	// Dir holds the embedded database of BackendPebble
+	Dir string
+
+	// Cipher encrypts the values of BackendPebble at rest; nil stores
+	// them in the clear
+	Cipher *atrest.Cipher
+
 	Host     string
 	Port     int
 	User     string
@@ -39,6 +50,7 @@ type Config struct {
 // DefaultConfig returns default database configuration
 func DefaultConfig() *Config {
 	return &Config{
+		Backend:  BackendPostgres,
 		Host:     "localhost",
 		Port:     5432,
 		User:     "ccoin",
@@ -66,7 +78,12 @@ func NewPostgresStore(ctx context.Context, cfg *Config) (*PostgresStore, error)
 		return nil, fmt.Errorf("%w: %v", ErrDBConnection, err)
 	}
 
-	return &PostgresStore{pool: pool}, nil
+	store := &PostgresStore{pool: pool}
+	if _, err := store.MigrateSchema(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return store, nil
 }
 
 // Close closes the database connection pool
@@ -78,16 +95,53 @@ func (s *PostgresStore) Close() {
 // Block Operations
 // ============================================
 
-// SaveBlock saves a block to the database
+// SaveBlock saves a block with its transactions and the nullifiers they
+// spend in one database transaction
 func (s *PostgresStore) SaveBlock(ctx context.Context, block *types.Block) error {
+	return s.BatchSaveBlocks(ctx, []*types.Block{block})
+}
+
+// BatchSaveBlocks saves blocks, parents first, in one database
+// transaction sent as a single batch. Sync uses it to write runs of
+// downloaded blocks without a round trip per row.
+func (s *PostgresStore) BatchSaveBlocks(ctx context.Context, blocks []*types.Block) error {
+	batch := &pgx.Batch{}
+	for _, block := range blocks {
+		queueBlock(batch, block)
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	results := tx.SendBatch(ctx, batch)
+	for i := 0; i < batch.Len(); i++ {
+		if _, err := results.Exec(); err != nil {
+			results.Close()
+			return fmt.Errorf("failed to save block: %w", err)
+		}
+	}
+	if err := results.Close(); err != nil {
+		return fmt.Errorf("failed to save block: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// queueBlock queues the inserts of a block, its transactions and the
+// nullifiers they spend
+func queueBlock(batch *pgx.Batch, block *types.Block) {
 	header := block.Header
 
 	query := `
 		INSERT INTO blocks (
 			hash, version, parents, tx_root, state_root, pouw_result, pouw_proof,
 			task_id, quality_score, miner_address, reputation_score, difficulty,
-			nonce, timestamp, height, cumulative_score, is_main_chain, extra_data
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+			nonce, timestamp, height, cumulative_score, is_main_chain, extra_data,
+			payout_address, committee_root, miner_public_key, vrf_seed, vrf_proof,
+			signature, nullifier_root, registry_root, receipts_root
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27)
 		ON CONFLICT (hash) DO NOTHING
 	`
 
@@ -105,7 +159,7 @@ func (s *PostgresStore) SaveBlock(ctx context.Context, block *types.Block) error
 		scoreStr = "0"
 	}
 
-	_, err := s.pool.Exec(ctx, query,
+	batch.Queue(query,
 		header.Hash[:],
 		header.Version,
 		parents,
@@ -124,20 +178,35 @@ func (s *PostgresStore) SaveBlock(ctx context.Context, block *types.Block) error
 		scoreStr,
 		false, // is_main_chain
 		header.ExtraData,
+		header.PayoutAddress[:],
+		nullIfEmpty(header.CommitteeRoot[:]),
+		nullIfEmpty(header.MinerPublicKey),
+		nullIfEmpty(header.VRFSeed[:]),
+		nullIfEmpty(header.VRFProof),
+		nullIfEmpty(header.Signature),
+		nullIfEmpty(header.NullifierRoot[:]),
+		nullIfEmpty(header.RegistryRoot[:]),
+		nullIfEmpty(header.ReceiptsRoot[:]),
 	)
 
-	if err != nil {
-		return fmt.Errorf("failed to save block: %w", err)
-	}
+	// The block replaces its parents in the tip set, unless a child saved
+	// before it already references it
+	batch.Queue(`
+		INSERT INTO block_edges (parent, child)
+		SELECT unnest($1::bytea[]), $2::bytea
+		ON CONFLICT DO NOTHING
+	`, parents, header.Hash[:])
+	batch.Queue(`DELETE FROM block_tips WHERE hash = ANY($1)`, parents)
+	batch.Queue(`
+		INSERT INTO block_tips (hash, height)
+		SELECT $1::bytea, $2::bigint
+		WHERE NOT EXISTS (SELECT 1 FROM block_edges WHERE parent = $1)
+		ON CONFLICT (hash) DO NOTHING
+	`, header.Hash[:], header.Height)
 
-	// Save transactions
 	for i, tx := range block.Transactions {
-		if err := s.saveTransaction(ctx, tx, header.Hash, i); err != nil {
-			return fmt.Errorf("failed to save transaction: %w", err)
-		}
+		queueTransaction(batch, tx, header, i)
 	}
-
-	return nil
 }
 
 // GetBlock retrieves a complete block by hash
@@ -163,12 +232,14 @@ func (s *PostgresStore) GetBlockHeader(ctx context.Context, hash types.Hash) (*t
 	query := `
 		SELECT hash, version, parents, tx_root, state_root, pouw_result, pouw_proof,
 			   task_id, quality_score, miner_address, reputation_score, difficulty,
-			   nonce, timestamp, height, cumulative_score, extra_data
+			   nonce, timestamp, height, cumulative_score, extra_data, payout_address,
+			   committee_root, miner_public_key, vrf_seed, vrf_proof, signature,
+			   nullifier_root, registry_root, receipts_root
 		FROM blocks WHERE hash = $1
 	`
 
 	var header types.BlockHeader
-	var hashBytes, txRoot, stateRoot, pouwResult, taskID, minerAddr, difficulty, extraData []byte
+	var hashBytes, txRoot, stateRoot, pouwResult, taskID, minerAddr, difficulty, extraData, payoutAddr, committeeRoot, vrfSeed, nullifierRoot, registryRoot, receiptsRoot []byte
 	var parents [][]byte
 	var scoreStr string
 
@@ -190,6 +261,15 @@ func (s *PostgresStore) GetBlockHeader(ctx context.Context, hash types.Hash) (*t
 		&header.Height,
 		&scoreStr,
 		&extraData,
+		&payoutAddr,
+		&committeeRoot,
+		&header.MinerPublicKey,
+		&vrfSeed,
+		&header.VRFProof,
+		&header.Signature,
+		&nullifierRoot,
+		&registryRoot,
+		&receiptsRoot,
 	)
 
 	if err == pgx.ErrNoRows {
@@ -210,8 +290,24 @@ func (s *PostgresStore) GetBlockHeader(ctx context.Context, hash types.Hash) (*t
 		copy(header.TaskID[:], taskID)
 	}
 	copy(header.MinerAddress[:], minerAddr)
+	copy(header.PayoutAddress[:], payoutAddr)
 	header.Difficulty = new(big.Int).SetBytes(difficulty)
 	header.ExtraData = extraData
+	if committeeRoot != nil {
+		copy(header.CommitteeRoot[:], committeeRoot)
+	}
+	if vrfSeed != nil {
+		copy(header.VRFSeed[:], vrfSeed)
+	}
+	if nullifierRoot != nil {
+		copy(header.NullifierRoot[:], nullifierRoot)
+	}
+	if registryRoot != nil {
+		copy(header.RegistryRoot[:], registryRoot)
+	}
+	if receiptsRoot != nil {
+		copy(header.ReceiptsRoot[:], receiptsRoot)
+	}
 
 	// Convert parents
 	header.Parents = make([]types.Hash, len(parents))
@@ -258,7 +354,7 @@ func (s *PostgresStore) GetBlocksByHeight(ctx context.Context, height uint64) ([
 
 // GetChildren returns child block hashes for a given block
 func (s *PostgresStore) GetChildren(ctx context.Context, hash types.Hash) ([]types.Hash, error) {
-	query := `SELECT hash FROM blocks WHERE $1 = ANY(parents)`
+	query := `SELECT child FROM block_edges WHERE parent = $1`
 
 	rows, err := s.pool.Query(ctx, query, hash[:])
 	if err != nil {
@@ -344,12 +440,7 @@ func (s *PostgresStore) UpdateMainChain(ctx context.Context, onChain, offChain [
 
 // GetTips returns current DAG tips (blocks with no children)
 func (s *PostgresStore) GetTips(ctx context.Context) ([]types.Hash, error) {
-	query := `
-		SELECT b.hash FROM blocks b
-		WHERE NOT EXISTS (
-			SELECT 1 FROM blocks c WHERE b.hash = ANY(c.parents)
-		)
-	`
+	query := `SELECT hash FROM block_tips`
 
 	rows, err := s.pool.Query(ctx, query)
 	if err != nil {
@@ -376,7 +467,9 @@ func (s *PostgresStore) GetTips(ctx context.Context) ([]types.Hash, error) {
 // Transaction Operations
 // ============================================
 
-func (s *PostgresStore) saveTransaction(ctx context.Context, tx *types.Transaction, blockHash types.Hash, index int) error {
+// queueTransaction queues the insert of a block's transaction and the
+// nullifiers it spends
+func queueTransaction(batch *pgx.Batch, tx *types.Transaction, header *types.BlockHeader, index int) {
 	query := `
 		INSERT INTO transactions (
 			tx_hash, block_hash, version, nullifiers, commitments, proof_type,
@@ -397,9 +490,9 @@ func (s *PostgresStore) saveTransaction(ctx context.Context, tx *types.Transacti
 		commitments[i] = c.Value[:]
 	}
 
-	_, err := s.pool.Exec(ctx, query,
+	batch.Queue(query,
 		tx.TxHash[:],
-		blockHash[:],
+		header.Hash[:],
 		tx.Version,
 		nullifiers,
 		commitments,
@@ -407,45 +500,81 @@ func (s *PostgresStore) saveTransaction(ctx context.Context, tx *types.Transacti
 		tx.Proof.ProofData,
 		tx.Anchor[:],
 		tx.DisclosureFlags,
-		nil, // disclosures serialization
+		encodeDisclosures(tx.Disclosures),
 		tx.Fee,
 		tx.Memo,
 		index,
 	)
 
-	if err != nil {
-		return err
-	}
-
-	// Save nullifiers
 	for _, nullifier := range tx.Nullifiers {
-		if err := s.saveNullifier(ctx, nullifier, tx.TxHash); err != nil {
-			return err
-		}
+		batch.Queue(`
+			INSERT INTO nullifiers (nullifier, tx_hash, block_height)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (nullifier) DO NOTHING
+		`, nullifier[:], tx.TxHash[:], header.Height)
 	}
 
-	return nil
+	for i, c := range tx.Commitments {
+		batch.Queue(`
+			INSERT INTO commitments (commitment, block_height, tx_hash, block_hash, output_index, encrypted_note)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (commitment) DO NOTHING
+		`, c.Value[:], header.Height, tx.TxHash[:], header.Hash[:], i, c.EncryptedNote)
+	}
 }
 
-func (s *PostgresStore) saveNullifier(ctx context.Context, nullifier types.Hash, txHash types.Hash) error {
-	query := `
-		INSERT INTO nullifiers (nullifier, tx_hash, block_height)
-		SELECT $1, $2, b.height FROM blocks b
-		JOIN transactions t ON t.block_hash = b.hash
-		WHERE t.tx_hash = $2
-		ON CONFLICT (nullifier) DO NOTHING
-	`
+// txColumns are the transaction columns scanTransaction reads
+const txColumns = `t.tx_hash, t.version, t.nullifiers, t.commitments, t.proof_type, t.proof,
+	t.anchor, t.disclosure_flags, t.disclosures, t.fee, t.memo`
+
+// scanTransaction scans txColumns, followed by the columns of extra
+func scanTransaction(row pgx.Row, extra ...interface{}) (*types.Transaction, error) {
+	var tx types.Transaction
+	var txHash, anchor, disclosures []byte
+	var nullifiers, commitments [][]byte
+
+	dest := append([]interface{}{
+		&txHash,
+		&tx.Version,
+		&nullifiers,
+		&commitments,
+		&tx.Proof.ProofType,
+		&tx.Proof.ProofData,
+		&anchor,
+		&tx.DisclosureFlags,
+		&disclosures,
+		&tx.Fee,
+		&tx.Memo,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	copy(tx.TxHash[:], txHash)
+	copy(tx.Anchor[:], anchor)
+
+	tx.Nullifiers = make([]types.Hash, len(nullifiers))
+	for i, n := range nullifiers {
+		copy(tx.Nullifiers[i][:], n)
+	}
+
+	tx.Commitments = make([]types.Commitment, len(commitments))
+	for i, c := range commitments {
+		copy(tx.Commitments[i].Value[:], c)
+	}
 
-	_, err := s.pool.Exec(ctx, query, nullifier[:], txHash[:])
-	return err
+	var err error
+	if tx.Disclosures, err = decodeDisclosures(disclosures); err != nil {
+		return nil, err
+	}
+	return &tx, nil
 }
 
 func (s *PostgresStore) getBlockTransactions(ctx context.Context, blockHash types.Hash) ([]*types.Transaction, error) {
 	query := `
-		SELECT tx_hash, version, nullifiers, commitments, proof_type, proof,
-			   anchor, disclosure_flags, fee, memo
-		FROM transactions WHERE block_hash = $1
-		ORDER BY tx_index ASC
+		SELECT ` + txColumns + `
+		FROM transactions t WHERE t.block_hash = $1
+		ORDER BY t.tx_index ASC
 	`
 
 	rows, err := s.pool.Query(ctx, query, blockHash[:])
@@ -456,39 +585,11 @@ func (s *PostgresStore) getBlockTransactions(ctx context.Context, blockHash type
 
 	var transactions []*types.Transaction
 	for rows.Next() {
-		var tx types.Transaction
-		var txHash, anchor []byte
-		var nullifiers, commitments [][]byte
-
-		if err := rows.Scan(
-			&txHash,
-			&tx.Version,
-			&nullifiers,
-			&commitments,
-			&tx.Proof.ProofType,
-			&tx.Proof.ProofData,
-			&anchor,
-			&tx.DisclosureFlags,
-			&tx.Fee,
-			&tx.Memo,
-		); err != nil {
+		tx, err := scanTransaction(rows)
+		if err != nil {
 			return nil, err
 		}
-
-		copy(tx.TxHash[:], txHash)
-		copy(tx.Anchor[:], anchor)
-
-		tx.Nullifiers = make([]types.Hash, len(nullifiers))
-		for i, n := range nullifiers {
-			copy(tx.Nullifiers[i][:], n)
-		}
-
-		tx.Commitments = make([]types.Commitment, len(commitments))
-		for i, c := range commitments {
-			copy(tx.Commitments[i].Value[:], c)
-		}
-
-		transactions = append(transactions, &tx)
+		transactions = append(transactions, tx)
 	}
 
 	return transactions, nil
@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ============================================
+// Block Pruning
+// ============================================
+
+// MinPruneDepth is the smallest pruning depth allowed, well past the
+// depth reorgs and state snapshots reach
+const MinPruneDepth = 1000
+
+// PruneBlocks discards the bodies of the blocks below height. Their
+// transactions lose their proofs, disclosures and memos, and the
+// transactions of off-main-chain blocks are deleted unless a recorded
+// nullifier or commitment still references them. Headers, nullifiers and commitments
+// are kept, so the chain state can still be rebuilt. It returns the
+// number of blocks pruned.
+func (s *PostgresStore) PruneBlocks(ctx context.Context, below uint64) (int64, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM transactions t USING blocks b
+		WHERE t.block_hash = b.hash AND b.height < $1 AND NOT b.pruned
+		  AND NOT b.is_main_chain
+		  AND NOT EXISTS (SELECT 1 FROM nullifiers n WHERE n.tx_hash = t.tx_hash)
+		  AND NOT EXISTS (SELECT 1 FROM commitments c WHERE c.tx_hash = t.tx_hash)
+	`, below)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete off-chain transactions: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE transactions t SET proof = '', disclosures = NULL, memo = NULL
+		FROM blocks b
+		WHERE t.block_hash = b.hash AND b.height < $1 AND NOT b.pruned
+	`, below)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune transactions: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx, `UPDATE blocks SET pruned = TRUE WHERE height < $1 AND NOT pruned`, below)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark blocks pruned: %w", err)
+	}
+
+	return tag.RowsAffected(), tx.Commit(ctx)
+}
+
+// PrunedHeight returns the height below which block bodies have been
+// pruned, zero if none have
+func (s *PostgresStore) PrunedHeight(ctx context.Context) (uint64, error) {
+	query := `SELECT COALESCE(MAX(height) + 1, 0) FROM blocks WHERE pruned`
+
+	var height uint64
+	if err := s.pool.QueryRow(ctx, query).Scan(&height); err != nil {
+		return 0, err
+	}
+	return height, nil
+}
+
+// PruneStore discards block bodies below a height
+type PruneStore interface {
+	PruneBlocks(ctx context.Context, below uint64) (int64, error)
+	PrunedHeight(ctx context.Context) (uint64, error)
+}
+
+// ChainHeight reports the height of the chain being pruned
+type ChainHeight interface {
+	GetHeight() uint64
+}
+
+// SnapshotHeight reports the height of the latest state snapshot
+type SnapshotHeight interface {
+	LatestHeight() uint64
+}
+
+// PruneConfig holds pruning configuration
+type PruneConfig struct {
+	// Depth is how many heights below the chain height block bodies are
+	// kept
+	Depth uint64
+
+	// BatchHeights bounds the heights pruned in one database transaction
+	BatchHeights uint64
+
+	// Interval is how often Run prunes
+	Interval time.Duration
+}
+
+// DefaultPruneConfig returns default pruning configuration
+func DefaultPruneConfig() *PruneConfig {
+	return &PruneConfig{
+		Depth:        10000,
+		BatchHeights: 500,
+		Interval:     10 * time.Minute,
+	}
+}
+
+// Pruner keeps block bodies only for the top Depth heights of the chain.
+// With snapshots set it never prunes past the latest snapshot, so the
+// state at the pruned height can be restored from a snapshot rather
+// than rebuilt.
+type Pruner struct {
+	mu sync.Mutex
+
+	// running serializes Prune calls without holding mu over queries
+	running sync.Mutex
+
+	store     PruneStore
+	chain     ChainHeight
+	snapshots SnapshotHeight
+	config    *PruneConfig
+
+	// Height below which bodies are pruned
+	pruned uint64
+}
+
+// NewPruner creates a pruner, resuming from the height already pruned
+func NewPruner(ctx context.Context, store PruneStore, chain ChainHeight, cfg *PruneConfig) (*Pruner, error) {
+	if cfg == nil {
+		cfg = DefaultPruneConfig()
+	}
+	if cfg.Depth < MinPruneDepth {
+		return nil, fmt.Errorf("pruning depth must be at least %d", MinPruneDepth)
+	}
+	if cfg.BatchHeights == 0 {
+		return nil, errors.New("pruning batch must be positive")
+	}
+
+	pruned, err := store.PrunedHeight(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Pruner{store: store, chain: chain, config: cfg, pruned: pruned}, nil
+}
+
+// SetSnapshots bounds pruning by the latest state snapshot
+func (p *Pruner) SetSnapshots(snapshots SnapshotHeight) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.snapshots = snapshots
+}
+
+// Run prunes every Interval until ctx is done
+func (p *Pruner) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Prune(ctx); err != nil && ctx.Err() == nil {
+			fmt.Printf("Warning: pruning failed: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Prune discards the block bodies that have fallen Depth heights below
+// the chain height, BatchHeights at a time
+func (p *Pruner) Prune(ctx context.Context) error {
+	height := p.chain.GetHeight()
+	if height < p.config.Depth {
+		return nil
+	}
+	target := height - p.config.Depth
+
+	p.running.Lock()
+	defer p.running.Unlock()
+
+	p.mu.Lock()
+	if p.snapshots != nil {
+		if latest := p.snapshots.LatestHeight(); latest < target {
+			target = latest
+		}
+	}
+	pruned := p.pruned
+	p.mu.Unlock()
+
+	for pruned < target {
+		below := pruned + p.config.BatchHeights
+		if below > target {
+			below = target
+		}
+		// Stop serving the bodies before they are discarded
+		p.mu.Lock()
+		p.pruned = below
+		p.mu.Unlock()
+
+		if _, err := p.store.PruneBlocks(ctx, below); err != nil {
+			return err
+		}
+		pruned = below
+	}
+	return nil
+}
+
+// PrunedHeight returns the height below which block bodies are pruned
+func (p *Pruner) PrunedHeight() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pruned
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// LoadSanctions returns every sanctioned address
+func (s *PostgresStore) LoadSanctions(ctx context.Context) ([]types.Address, error) {
+	query := `SELECT address FROM sanctioned_addresses`
+
+	rows, err := s.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var addrs []types.Address
+	for rows.Next() {
+		var addrBytes []byte
+		if err := rows.Scan(&addrBytes); err != nil {
+			return nil, err
+		}
+		var addr types.Address
+		copy(addr[:], addrBytes)
+		addrs = append(addrs, addr)
+	}
+	return addrs, rows.Err()
+}
+
+// SaveSanctions applies a sanctions list change atomically
+func (s *PostgresStore) SaveSanctions(ctx context.Context, add, remove []types.Address, proposalID types.Hash) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, addr := range add {
+		query := `INSERT INTO sanctioned_addresses (address, proposal_id) VALUES ($1, $2)
+			ON CONFLICT (address) DO NOTHING`
+		if _, err := tx.Exec(ctx, query, addr[:], proposalID[:]); err != nil {
+			return err
+		}
+	}
+	for _, addr := range remove {
+		query := `DELETE FROM sanctioned_addresses WHERE address = $1`
+		if _, err := tx.Exec(ctx, query, addr[:]); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ccoin/core/migrations"
+)
+
+// Schema migration errors
+var (
+	ErrSchemaTooNew       = errors.New("database schema is newer than this binary")
+	ErrNoDownMigration    = errors.New("schema migration has no down migration")
+	ErrInvalidSchemaFiles = errors.New("invalid schema migration files")
+)
+
+// schemaLockID is the advisory lock serializing nodes migrating the same
+// database
+const schemaLockID = 0x63636f696e // "ccoin"
+
+// SchemaMigration is one versioned step of the PostgreSQL schema
+type SchemaMigration struct {
+	Version int
+	Name    string
+
+	// Up moves the schema to Version; Down moves it back and is empty
+	// when the step cannot be reverted
+	Up   string
+	Down string
+}
+
+// SchemaMigrations returns the migrations compiled into the binary in
+// version order
+func SchemaMigrations() ([]*SchemaMigration, error) {
+	files, err := fs.Glob(migrations.FS, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*SchemaMigration)
+	for _, file := range files {
+		base, down := strings.CutSuffix(strings.TrimSuffix(file, ".sql"), ".down")
+		prefix, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSchemaFiles, file)
+		}
+		data, err := migrations.FS.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &SchemaMigration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("%w: version %d is both %s and %s", ErrInvalidSchemaFiles, version, m.Name, name)
+		}
+		if down {
+			m.Down = string(data)
+		} else {
+			m.Up = string(data)
+		}
+	}
+
+	list := make([]*SchemaMigration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("%w: %03d_%s has no up migration", ErrInvalidSchemaFiles, m.Version, m.Name)
+		}
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// SchemaVersion returns the version of the last schema migration applied,
+// 0 for an empty database
+func (s *PostgresStore) SchemaVersion(ctx context.Context) (int, error) {
+	if err := s.ensureSchemaTable(ctx); err != nil {
+		return 0, err
+	}
+
+	var version int
+	err := s.pool.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+// MigrateSchema applies the pending schema migrations, each in its own
+// transaction, and returns how many it applied. Databases set up by hand
+// before the schema was versioned start at version 0; every migration is
+// idempotent, so they are brought under version control by replaying
+// them all.
+func (s *PostgresStore) MigrateSchema(ctx context.Context) (int, error) {
+	list, err := SchemaMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if err := s.ensureSchemaTable(ctx); err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, m := range list {
+		ok, err := s.stepSchema(ctx, m, true)
+		if err != nil {
+			return applied, fmt.Errorf("schema migration %03d_%s: %w", m.Version, m.Name, err)
+		}
+		if ok {
+			applied++
+		}
+	}
+
+	// Refuse to run against a schema written by a newer binary
+	version, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return applied, err
+	}
+	if len(list) > 0 && version > list[len(list)-1].Version {
+		return applied, fmt.Errorf("%w: version %d, latest known %d", ErrSchemaTooNew, version, list[len(list)-1].Version)
+	}
+	return applied, nil
+}
+
+// RollbackSchema reverts schema migrations down to version, newest first,
+// and returns how many it reverted. It destroys data and is meant for
+// development.
+func (s *PostgresStore) RollbackSchema(ctx context.Context, version int) (int, error) {
+	list, err := SchemaMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if err := s.ensureSchemaTable(ctx); err != nil {
+		return 0, err
+	}
+
+	reverted := 0
+	for i := len(list) - 1; i >= 0 && list[i].Version > version; i-- {
+		m := list[i]
+		ok, err := s.stepSchema(ctx, m, false)
+		if err != nil {
+			return reverted, fmt.Errorf("schema migration %03d_%s: %w", m.Version, m.Name, err)
+		}
+		if ok {
+			reverted++
+		}
+	}
+	return reverted, nil
+}
+
+// stepSchema applies m, or reverts it, unless another node already did.
+// The advisory lock holds off concurrent runs until the step commits.
+func (s *PostgresStore) stepSchema(ctx context.Context, m *SchemaMigration, up bool) (bool, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, int64(schemaLockID)); err != nil {
+		return false, err
+	}
+
+	var applied bool
+	if err := tx.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, m.Version,
+	).Scan(&applied); err != nil {
+		return false, err
+	}
+	if applied == up {
+		return false, nil
+	}
+
+	if up {
+		if _, err := tx.Exec(ctx, m.Up); err != nil {
+			return false, err
+		}
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name,
+		); err != nil {
+			return false, err
+		}
+	} else {
+		if m.Down == "" {
+			return false, ErrNoDownMigration
+		}
+		if _, err := tx.Exec(ctx, m.Down); err != nil {
+			return false, err
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit(ctx)
+}
+
+// ensureSchemaTable creates the table recording applied migrations
+func (s *PostgresStore) ensureSchemaTable(ctx context.Context) error {
+	_, err := s.pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		)`)
+	return err
+}
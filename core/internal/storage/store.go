@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ccoin/core/internal/reputation"
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// ============================================
+// Storage Backends
+// ============================================
+
+// Storage backends selectable in Config
+const (
+	// BackendPostgres keeps everything in PostgreSQL
+	BackendPostgres = "postgres"
+
+	// BackendPebble keeps everything in an embedded Pebble database
+	// under Config.Dir, for single-binary deployments
+	BackendPebble = "pebble"
+)
+
+// Store is the storage a node runs on. Both backends implement it; the
+// PostgreSQL store additionally serves analytics, archive exports and
+// online schema migrations.
+type Store interface {
+	// Blocks
+	SaveBlock(ctx context.Context, block *types.Block) error
+	GetBlock(ctx context.Context, hash types.Hash) (*types.Block, error)
+	GetBlockHeader(ctx context.Context, hash types.Hash) (*types.BlockHeader, error)
+	GetBlocksByHeight(ctx context.Context, height uint64) ([]*types.BlockHeader, error)
+	GetChildren(ctx context.Context, hash types.Hash) ([]types.Hash, error)
+	GetMainChain(ctx context.Context, fromHeight, toHeight uint64) ([]*types.BlockHeader, error)
+	GetMainChainByTime(ctx context.Context, from, to int64) ([]*types.Block, error)
+	UpdateMainChain(ctx context.Context, onChain, offChain []types.Hash) error
+	GetTips(ctx context.Context) ([]types.Hash, error)
+
+	// Pruning
+	PruneStore
+
+	// Transaction, nullifier and commitment lookups
+	ExplorerStore
+
+	// Nullifiers and anchors
+	HasNullifier(ctx context.Context, nullifier types.Hash) (bool, error)
+	HasNullifiers(ctx context.Context, nullifiers []types.Hash) ([]bool, error)
+	AddNullifier(ctx context.Context, nullifier types.Hash, txHash types.Hash, blockHeight uint64) error
+	GetNullifierInfo(ctx context.Context, nullifier types.Hash) (*zkp.NullifierInfo, error)
+	AddAnchor(ctx context.Context, root types.Hash, blockHeight uint64) error
+	RecentAnchors(ctx context.Context, n int) ([]types.Hash, error)
+	AddSpends(ctx context.Context, nullifiers []types.Hash, txHash types.Hash, blockHeight uint64, anchor *types.Hash) error
+
+	// Miners
+	SaveStake(ctx context.Context, stake *reputation.StakeInfo) error
+	GetStake(ctx context.Context, addr types.Address) (*reputation.StakeInfo, error)
+	SaveEvidence(ctx context.Context, evidence *reputation.SlashingEvidence) error
+	GetPendingEvidence(ctx context.Context) ([]*reputation.SlashingEvidence, error)
+	SaveDelegation(ctx context.Context, d *reputation.Delegation) error
+	GetDelegations(ctx context.Context) ([]*reputation.Delegation, error)
+	SaveCapabilities(ctx context.Context, addr types.Address, caps *types.ComputeCapabilities) error
+	GetCapabilities(ctx context.Context, addr types.Address) (*types.ComputeCapabilities, error)
+
+	// Governance
+	LoadSanctions(ctx context.Context) ([]types.Address, error)
+	SaveSanctions(ctx context.Context, add, remove []types.Address, proposalID types.Hash) error
+	LoadAuthorities(ctx context.Context) ([]zkp.Authority, error)
+	SaveAuthority(ctx context.Context, authority zkp.Authority, remove bool, proposalID types.Hash) error
+	LoadRevocations(ctx context.Context) ([]types.Address, error)
+	SaveRevocations(ctx context.Context, revoke, reinstate []types.Address, proposalID types.Hash) error
+	LoadPolicyRules(ctx context.Context, network string) ([]zkp.PolicyRule, error)
+	SavePolicyRules(ctx context.Context, network string, rules []zkp.PolicyRule, proposalID types.Hash) error
+	SaveCommitteeRecords(ctx context.Context, records []*types.CommitteeRecord) error
+	LoadCommitteeRecords(ctx context.Context, epoch uint64) ([]*types.CommitteeRecord, error)
+
+	Close()
+}
+
+// Open opens the store of the backend cfg selects
+func Open(ctx context.Context, cfg *Config) (Store, error) {
+	switch cfg.Backend {
+	case "", BackendPostgres:
+		return NewPostgresStore(ctx, cfg)
+	case BackendPebble:
+		return NewPebbleStore(cfg.Dir, cfg.Cipher)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
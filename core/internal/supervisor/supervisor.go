@@ -0,0 +1,338 @@
+// Package supervisor provides panic isolation and restart supervision for node subsystems.
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Supervisor errors
+var (
+	ErrAlreadyRunning = errors.New("subsystem already running")
+	ErrGaveUp         = errors.New("subsystem exceeded restart limit")
+)
+
+// CrashDirName is the data-dir subdirectory holding crash reports
+const CrashDirName = "crashes"
+
+// State describes the health of a supervised subsystem
+type State string
+
+const (
+	StateRunning    State = "RUNNING"
+	StateRestarting State = "RESTARTING"
+	StateStopped    State = "STOPPED"
+	StateFailed     State = "FAILED"
+)
+
+// PanicError wraps a recovered panic value
+type PanicError struct {
+	Subsystem string
+	Value     interface{}
+	Stack     []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in %s: %v", e.Subsystem, e.Value)
+}
+
+// CrashReport is the structured record persisted for every recovered panic
+type CrashReport struct {
+	Subsystem string    `json:"subsystem"`
+	Panic     string    `json:"panic"`
+	Stack     string    `json:"stack"`
+	Restarts  int       `json:"restarts"`
+	Time      time.Time `json:"time"`
+	Version   string    `json:"version,omitempty"`
+}
+
+// SubsystemStatus is a snapshot of one subsystem's health
+type SubsystemStatus struct {
+	Name      string
+	State     State
+	Restarts  int
+	LastPanic string
+	LastCrash time.Time
+	Degraded  bool
+}
+
+// Config holds supervisor configuration
+type Config struct {
+	// DataDir is where crash reports are written (empty disables persistence)
+	DataDir string
+
+	// Backoff between restarts, doubled after each crash
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// MaxRestarts within ResetWindow before giving up (0 = unlimited)
+	MaxRestarts int
+
+	// ResetWindow after which a healthy subsystem's restart count resets
+	// and it is no longer reported degraded (0 = never)
+	ResetWindow time.Duration
+
+	// Version is recorded in crash reports
+	Version string
+}
+
+// DefaultConfig returns default supervisor configuration
+func DefaultConfig() *Config {
+	return &Config{
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     time.Minute,
+		MaxRestarts:    10,
+		ResetWindow:    10 * time.Minute,
+	}
+}
+
+// Supervisor runs subsystem goroutines, recovering panics and restarting
+// them with exponential backoff
+type Supervisor struct {
+	mu sync.RWMutex
+
+	config     *Config
+	subsystems map[string]*subsystem
+
+	wg sync.WaitGroup
+}
+
+// subsystem tracks a single supervised goroutine
+type subsystem struct {
+	status SubsystemStatus
+}
+
+// NewSupervisor creates a new supervisor
+func NewSupervisor(cfg *Config) *Supervisor {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+
+	return &Supervisor{
+		config:     cfg,
+		subsystems: make(map[string]*subsystem),
+	}
+}
+
+// Go runs fn under supervision. A panic is recovered, reported and the
+// function restarted after a backoff. A normal return (or context
+// cancellation) stops the subsystem without restart.
+func (s *Supervisor) Go(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	s.mu.Lock()
+	if sub, exists := s.subsystems[name]; exists && sub.status.State != StateStopped && sub.status.State != StateFailed {
+		s.mu.Unlock()
+		return ErrAlreadyRunning
+	}
+	s.subsystems[name] = &subsystem{
+		status: SubsystemStatus{Name: name, State: StateRunning},
+	}
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run(ctx, name, fn)
+	return nil
+}
+
+// run is the supervision loop for a single subsystem
+func (s *Supervisor) run(ctx context.Context, name string, fn func(ctx context.Context) error) {
+	defer s.wg.Done()
+
+	backoff := s.config.InitialBackoff
+	restarts := 0
+	lastCrash := time.Time{}
+
+	for {
+		s.setState(name, StateRunning)
+
+		err := Protect(name, func() error { return fn(ctx) })
+
+		var perr *PanicError
+		if !errors.As(err, &perr) {
+			s.setState(name, StateStopped)
+			return
+		}
+
+		// Reset the budget if the subsystem was healthy for long enough
+		now := time.Now()
+		if s.config.ResetWindow > 0 && !lastCrash.IsZero() && now.Sub(lastCrash) > s.config.ResetWindow {
+			restarts = 0
+			backoff = s.config.InitialBackoff
+		}
+		lastCrash = now
+		restarts++
+
+		s.recordCrash(name, perr, restarts)
+
+		if s.config.MaxRestarts > 0 && restarts > s.config.MaxRestarts {
+			s.setState(name, StateFailed)
+			return
+		}
+
+		s.setState(name, StateRestarting)
+		select {
+		case <-ctx.Done():
+			s.setState(name, StateStopped)
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > s.config.MaxBackoff {
+			backoff = s.config.MaxBackoff
+		}
+	}
+}
+
+// Wait blocks until all supervised subsystems have stopped
+func (s *Supervisor) Wait() {
+	s.wg.Wait()
+}
+
+// Protect calls fn and converts a panic into a *PanicError
+func Protect(name string, fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{
+				Subsystem: name,
+				Value:     r,
+				Stack:     debug.Stack(),
+			}
+		}
+	}()
+	return fn()
+}
+
+// Status returns a snapshot of all subsystems, sorted by name
+func (s *Supervisor) Status() []SubsystemStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	statuses := make([]SubsystemStatus, 0, len(s.subsystems))
+	for _, sub := range s.subsystems {
+		statuses = append(statuses, sub.status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// Degraded reports whether any subsystem has failed, or crashed within
+// the reset window
+func (s *Supervisor) Degraded() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, sub := range s.subsystems {
+		if sub.status.Degraded {
+			return true
+		}
+	}
+	return false
+}
+
+// ReportPanic records a panic recovered outside of Go (e.g. in a message
+// handler) and marks the subsystem degraded without restarting anything
+func (s *Supervisor) ReportPanic(err error) {
+	var perr *PanicError
+	if !errors.As(err, &perr) {
+		return
+	}
+
+	s.mu.Lock()
+	sub, exists := s.subsystems[perr.Subsystem]
+	if !exists {
+		sub = &subsystem{status: SubsystemStatus{Name: perr.Subsystem, State: StateRunning}}
+		s.subsystems[perr.Subsystem] = sub
+	}
+	sub.status.Restarts++
+	restarts := sub.status.Restarts
+	s.mu.Unlock()
+
+	s.recordCrash(perr.Subsystem, perr, restarts)
+}
+
+// setState updates a subsystem's state
+func (s *Supervisor) setState(name string, state State) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sub, exists := s.subsystems[name]; exists {
+		sub.status.State = state
+		if state == StateFailed {
+			sub.status.Degraded = true
+		}
+	}
+}
+
+// recordCrash updates status and persists a crash report
+func (s *Supervisor) recordCrash(name string, perr *PanicError, restarts int) {
+	report := &CrashReport{
+		Subsystem: name,
+		Panic:     fmt.Sprint(perr.Value),
+		Stack:     string(perr.Stack),
+		Restarts:  restarts,
+		Time:      time.Now().UTC(),
+		Version:   s.config.Version,
+	}
+
+	s.mu.Lock()
+	if sub, exists := s.subsystems[name]; exists {
+		sub.status.Restarts = restarts
+		sub.status.LastPanic = report.Panic
+		sub.status.LastCrash = report.Time
+		sub.status.Degraded = true
+	}
+	s.mu.Unlock()
+
+	if s.config.ResetWindow > 0 {
+		time.AfterFunc(s.config.ResetWindow, func() { s.recovered(name, report.Time) })
+	}
+
+	if err := s.writeReport(report); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write crash report: %v\n", err)
+	}
+	fmt.Fprintf(os.Stderr, "Subsystem %s crashed (restart %d): %s\n", name, restarts, report.Panic)
+}
+
+// recovered clears a subsystem's degraded flag once it has run for the
+// reset window since the crash at crashed without crashing again. A
+// subsystem that failed or stopped stays degraded.
+func (s *Supervisor) recovered(name string, crashed time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, exists := s.subsystems[name]
+	if !exists || sub.status.State != StateRunning || !sub.status.LastCrash.Equal(crashed) {
+		return
+	}
+	sub.status.Degraded = false
+}
+
+// writeReport persists a crash report as JSON under DataDir/crashes
+func (s *Supervisor) writeReport(report *CrashReport) error {
+	if s.config.DataDir == "" {
+		return nil
+	}
+
+	dir := filepath.Join(s.config.DataDir, CrashDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	filename := fmt.Sprintf("%s-%d.json", report.Subsystem, report.Time.UnixNano())
+	return os.WriteFile(filepath.Join(dir, filename), data, 0644)
+}
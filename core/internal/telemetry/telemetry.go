@@ -0,0 +1,279 @@
+// Package telemetry reports anonymized node statistics to an aggregation
+// endpoint. Reporting is opt-in: nothing is sent unless an endpoint is
+// configured, and every report is logged locally before it is sent.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Telemetry errors
+var (
+	ErrInsecureEndpoint = errors.New("telemetry endpoint must use https")
+	ErrReportRejected   = errors.New("telemetry endpoint rejected report")
+)
+
+// SchemaVersion is the version of the report format
+const SchemaVersion = 1
+
+// LogFileName is the data-dir file every sent report is appended to
+const LogFileName = "telemetry.log"
+
+// Config holds telemetry configuration
+type Config struct {
+	// Endpoint is the aggregation endpoint reports are posted to (empty
+	// disables reporting)
+	Endpoint string
+
+	// Interval between reports
+	Interval time.Duration
+
+	// DataDir is where sent reports are logged
+	DataDir string
+
+	// Version and Network of the node
+	Version string
+	Network string
+
+	// Hardware is the node's hardware class, see HardwareClass
+	Hardware string
+}
+
+// DefaultConfig returns default telemetry configuration. Reporting stays
+// off until an endpoint is set.
+func DefaultConfig() *Config {
+	return &Config{
+		Interval: 6 * time.Hour,
+	}
+}
+
+// Sources provide the live statistics a report carries; nil sources are
+// left out
+type Sources struct {
+	Height func() uint64
+	Peers  func() int
+	Proofs func() map[string]ProofTiming
+}
+
+// ProofTiming is the mean time to generate and verify one kind of proof
+type ProofTiming struct {
+	Proofs       uint64 `json:"proofs"`
+	MeanProveMs  uint64 `json:"mean_prove_ms"`
+	Verified     uint64 `json:"verified"`
+	MeanVerifyMs uint64 `json:"mean_verify_ms"`
+}
+
+// NewProofTiming summarizes total proof times as means in milliseconds
+func NewProofTiming(proofs uint64, prove time.Duration, verified uint64, verify time.Duration) ProofTiming {
+	t := ProofTiming{Proofs: proofs, Verified: verified}
+	if proofs > 0 {
+		t.MeanProveMs = uint64(prove.Milliseconds()) / proofs
+	}
+	if verified > 0 {
+		t.MeanVerifyMs = uint64(verify.Milliseconds()) / verified
+	}
+	return t
+}
+
+// Report is everything a node sends. It carries no identity: no peer ID,
+// address or IP, and the peer count is bucketed.
+type Report struct {
+	Schema   int    `json:"schema"`
+	Version  string `json:"version"`
+	Network  string `json:"network"`
+	OS       string `json:"os"`
+	Arch     string `json:"arch"`
+	Hardware string `json:"hardware_class"`
+	Height   uint64 `json:"height"`
+	Peers    string `json:"peers"`
+
+	Proofs map[string]ProofTiming `json:"proofs,omitempty"`
+}
+
+// HardwareClass buckets a machine's hardware into a coarse class
+func HardwareClass(cpus int, memoryMB uint64, gpus int) string {
+	switch {
+	case gpus > 0:
+		return "gpu"
+	case cpus >= 16 && memoryMB >= 32*1024:
+		return "large"
+	case cpus >= 4 && memoryMB >= 8*1024:
+		return "medium"
+	default:
+		return "small"
+	}
+}
+
+// peerBucket buckets a peer count
+func peerBucket(peers int) string {
+	switch {
+	case peers == 0:
+		return "0"
+	case peers < 8:
+		return "1-7"
+	case peers < 32:
+		return "8-31"
+	default:
+		return "32+"
+	}
+}
+
+// Reporter builds reports and, if enabled, posts them periodically
+type Reporter struct {
+	mu sync.Mutex
+
+	config  *Config
+	sources Sources
+	client  *http.Client
+}
+
+// NewReporter creates a reporter. An endpoint must use https unless it
+// is on the loopback interface.
+func NewReporter(cfg *Config, sources Sources) (*Reporter, error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	if cfg.Endpoint != "" {
+		u, err := url.Parse(cfg.Endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid telemetry endpoint: %w", err)
+		}
+		if u.Scheme != "https" && !(u.Scheme == "http" && isLoopback(u.Hostname())) {
+			return nil, ErrInsecureEndpoint
+		}
+	}
+
+	return &Reporter{
+		config:  cfg,
+		sources: sources,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// isLoopback reports whether host names the local machine
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Enabled returns whether reports are sent
+func (r *Reporter) Enabled() bool {
+	return r.config.Endpoint != ""
+}
+
+// Collect builds the report the node would send now
+func (r *Reporter) Collect() *Report {
+	report := &Report{
+		Schema:   SchemaVersion,
+		Version:  r.config.Version,
+		Network:  r.config.Network,
+		OS:       runtime.GOOS,
+		Arch:     runtime.GOARCH,
+		Hardware: r.config.Hardware,
+	}
+	if r.sources.Height != nil {
+		report.Height = r.sources.Height()
+	}
+	if r.sources.Peers != nil {
+		report.Peers = peerBucket(r.sources.Peers())
+	}
+	if r.sources.Proofs != nil {
+		report.Proofs = r.sources.Proofs()
+	}
+	return report
+}
+
+// Preview returns the exact bytes the next report would send
+func (r *Reporter) Preview() ([]byte, error) {
+	return json.Marshal(r.Collect())
+}
+
+// Run sends a report every Interval until ctx is done. It returns at
+// once if reporting is disabled.
+func (r *Reporter) Run(ctx context.Context) error {
+	if !r.Enabled() {
+		return nil
+	}
+
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if err := r.Send(ctx); err != nil {
+			fmt.Printf("Warning: telemetry report failed: %v\n", err)
+		}
+	}
+}
+
+// Send logs a report and posts it to the endpoint
+func (r *Reporter) Send(ctx context.Context) error {
+	if !r.Enabled() {
+		return nil
+	}
+
+	data, err := r.Preview()
+	if err != nil {
+		return err
+	}
+	if err := r.log(data); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.config.Endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%w: %s", ErrReportRejected, resp.Status)
+	}
+	return nil
+}
+
+// log appends a report to the telemetry log so operators can see what
+// was sent
+func (r *Reporter) log(data []byte) error {
+	if r.config.DataDir == "" {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, err := os.OpenFile(filepath.Join(r.config.DataDir, LogFileName), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	line := fmt.Sprintf("%s %s\n", time.Now().UTC().Format(time.RFC3339), data)
+	_, err = f.WriteString(line)
+	return err
+}
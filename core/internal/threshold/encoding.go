@@ -0,0 +1,94 @@
+package threshold
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+// ErrMalformedKey is returned when decoding a key or share fails
+var ErrMalformedKey = errors.New("malformed threshold key")
+
+// MarshalBinary encodes the public key as threshold, member count, the
+// committee point and each verification point
+func (pk *PublicKey) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 0, 8+pointSize*(1+len(pk.Verification)))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(pk.Threshold))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(pk.Verification)))
+	buf = append(buf, pointBytes(&pk.Point)...)
+	for i := range pk.Verification {
+		buf = append(buf, pointBytes(&pk.Verification[i])...)
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a key written by MarshalBinary
+func (pk *PublicKey) UnmarshalBinary(data []byte) error {
+	if len(data) < 8+pointSize {
+		return ErrMalformedKey
+	}
+	t := int(binary.BigEndian.Uint32(data))
+	n := int(binary.BigEndian.Uint32(data[4:]))
+	data = data[8:]
+	if t < 1 || t > n || len(data) != pointSize*(1+n) {
+		return ErrMalformedKey
+	}
+
+	pk.Threshold = t
+	if _, err := pk.Point.SetBytes(data[:pointSize]); err != nil {
+		return ErrMalformedKey
+	}
+	pk.Verification = make([]bn254.G1Affine, n)
+	for i := range pk.Verification {
+		data = data[pointSize:]
+		if _, err := pk.Verification[i].SetBytes(data[:pointSize]); err != nil {
+			return ErrMalformedKey
+		}
+	}
+	return nil
+}
+
+// MarshalBinary encodes the share as its index and secret
+func (ks *KeyShare) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 4+fr.Bytes)
+	binary.BigEndian.PutUint32(buf, uint32(ks.Index))
+	ks.secret.FillBytes(buf[4:])
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a share written by MarshalBinary
+func (ks *KeyShare) UnmarshalBinary(data []byte) error {
+	if len(data) != 4+fr.Bytes {
+		return ErrMalformedKey
+	}
+	secret := new(big.Int).SetBytes(data[4:])
+	if secret.Sign() == 0 || secret.Cmp(fr.Modulus()) >= 0 {
+		return ErrMalformedKey
+	}
+	ks.Index = int(binary.BigEndian.Uint32(data))
+	ks.secret = secret
+	return nil
+}
+
+// MarshalBinary encodes the decryption share
+func (s *DecryptionShare) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 0, 4+len(s.Point)+len(s.Proof))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(s.Index))
+	buf = append(buf, s.Point...)
+	buf = append(buf, s.Proof...)
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a share written by MarshalBinary
+func (s *DecryptionShare) UnmarshalBinary(data []byte) error {
+	if len(data) != 4+pointSize+proofSize {
+		return ErrMalformedKey
+	}
+	s.Index = int(binary.BigEndian.Uint32(data))
+	s.Point = append([]byte{}, data[4:4+pointSize]...)
+	s.Proof = append([]byte{}, data[4+pointSize:]...)
+	return nil
+}
@@ -0,0 +1,139 @@
+package threshold
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Reveal errors
+var (
+	ErrUnknownTransaction = errors.New("sealed transaction not included")
+	ErrUnknownEpoch       = errors.New("no committee key for epoch")
+)
+
+// RevealShare is a decryption share for one included transaction, as
+// gossiped between committee members
+type RevealShare struct {
+	TxHash types.Hash
+	Share  *DecryptionShare
+}
+
+// Revealer opens sealed transactions after a block includes them. Members
+// publish shares only for included transactions, so a payload is never
+// decrypted while a producer could still act on it.
+type Revealer struct {
+	mu sync.Mutex
+
+	// Committee keys by epoch, and this node's shares if it is a member
+	keys   map[uint64]*PublicKey
+	shares map[uint64]*KeyShare
+
+	// decode fills a transaction's operations from opened plaintext
+	decode func(plaintext []byte, tx *types.Transaction) error
+
+	pending map[types.Hash]*pendingReveal
+}
+
+// pendingReveal collects shares for one included transaction
+type pendingReveal struct {
+	tx     *types.Transaction
+	shares []*DecryptionShare
+	seen   map[int]bool
+}
+
+// NewRevealer creates a revealer that decodes opened payloads with decode
+func NewRevealer(decode func(plaintext []byte, tx *types.Transaction) error) *Revealer {
+	return &Revealer{
+		keys:    make(map[uint64]*PublicKey),
+		shares:  make(map[uint64]*KeyShare),
+		decode:  decode,
+		pending: make(map[types.Hash]*pendingReveal),
+	}
+}
+
+// SetKey registers the committee key for an epoch. share is nil unless
+// this node is a committee member.
+func (r *Revealer) SetKey(epoch uint64, pk *PublicKey, share *KeyShare) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys[epoch] = pk
+	if share != nil {
+		r.shares[epoch] = share
+	}
+}
+
+// Include registers the sealed transactions of an included block and
+// returns this node's shares for them, to be gossiped to the committee
+func (r *Revealer) Include(block *types.Block) ([]*RevealShare, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var out []*RevealShare
+	for _, tx := range block.Transactions {
+		if tx.Sealed == nil {
+			continue
+		}
+		if _, ok := r.keys[tx.Sealed.Epoch]; !ok {
+			return nil, ErrUnknownEpoch
+		}
+		if _, ok := r.pending[tx.TxHash]; !ok {
+			r.pending[tx.TxHash] = &pendingReveal{tx: tx, seen: make(map[int]bool)}
+		}
+
+		ks, ok := r.shares[tx.Sealed.Epoch]
+		if !ok {
+			continue
+		}
+		share, err := ks.DecryptShare(tx.Sealed)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, &RevealShare{TxHash: tx.TxHash, Share: share})
+	}
+	return out, nil
+}
+
+// AddShare records a decryption share. Once enough shares arrive the
+// transaction's operations are decrypted into it and it is returned;
+// until then AddShare returns nil.
+func (r *Revealer) AddShare(rs *RevealShare) (*types.Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.pending[rs.TxHash]
+	if !ok {
+		return nil, ErrUnknownTransaction
+	}
+	pk := r.keys[p.tx.Sealed.Epoch]
+	if p.seen[rs.Share.Index] {
+		return nil, nil
+	}
+	if err := pk.VerifyShare(p.tx.Sealed, rs.Share); err != nil {
+		return nil, err
+	}
+	p.seen[rs.Share.Index] = true
+	p.shares = append(p.shares, rs.Share)
+	if len(p.shares) < pk.Threshold {
+		return nil, nil
+	}
+
+	plaintext, err := pk.Open(p.tx.Sealed, p.tx.SealLabel(), p.shares)
+	if err != nil {
+		return nil, err
+	}
+	delete(r.pending, rs.TxHash)
+
+	if err := r.decode(plaintext, p.tx); err != nil {
+		return nil, err
+	}
+	return p.tx, nil
+}
+
+// Pending returns the number of included transactions awaiting shares
+func (r *Revealer) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.pending)
+}
@@ -0,0 +1,386 @@
+// Package threshold implements threshold encryption to a committee.
+//
+// Payloads are encrypted with hashed ElGamal over BN254 G1 to a public key
+// whose secret is Shamir-shared among the committee. Any Threshold members
+// can jointly recover a ciphertext's key by publishing decryption shares,
+// each carrying a proof that it was computed with the member's share.
+// Fewer members learn nothing, so a payload stays hidden until the
+// committee chooses to open it.
+package threshold
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Threshold encryption errors
+var (
+	ErrInvalidThreshold  = errors.New("threshold must be between 1 and committee size")
+	ErrInvalidCiphertext = errors.New("invalid sealed payload")
+	ErrInvalidShare      = errors.New("invalid decryption share")
+	ErrNotEnoughShares   = errors.New("not enough decryption shares")
+	ErrDecryptionFailed  = errors.New("decryption failed")
+)
+
+const (
+	pointSize = bn254.SizeOfG1AffineCompressed
+	proofSize = 2 * fr.Bytes
+)
+
+// PublicKey is a committee's encryption key
+type PublicKey struct {
+	// Threshold is the number of shares needed to decrypt
+	Threshold int
+
+	// Point is the committee public key s·G
+	Point bn254.G1Affine
+
+	// Verification holds each member's share commitment s_i·G, indexed
+	// from member 1
+	Verification []bn254.G1Affine
+}
+
+// KeyShare is one member's share of the committee secret
+type KeyShare struct {
+	// Index is the member's 1-based position in the committee
+	Index int
+
+	secret *big.Int
+}
+
+// DecryptionShare is a member's contribution to opening one payload
+type DecryptionShare struct {
+	Index int
+	Point []byte // s_i·U, compressed
+	Proof []byte // Chaum-Pedersen proof that Point uses share Index
+}
+
+// Deal generates a committee key split into n shares, any t of which can
+// decrypt. The dealer learns the secret and must discard it.
+func Deal(n, t int) (*PublicKey, []*KeyShare, error) {
+	if t < 1 || t > n {
+		return nil, nil, ErrInvalidThreshold
+	}
+
+	// f(x) = a_0 + a_1 x + ... + a_{t-1} x^{t-1}, secret a_0
+	coeffs := make([]*big.Int, t)
+	for i := range coeffs {
+		c, err := randomScalar()
+		if err != nil {
+			return nil, nil, err
+		}
+		coeffs[i] = c
+	}
+
+	pk := &PublicKey{
+		Threshold:    t,
+		Verification: make([]bn254.G1Affine, n),
+	}
+	pk.Point.ScalarMultiplication(generator(), coeffs[0])
+
+	shares := make([]*KeyShare, n)
+	order := fr.Modulus()
+	for i := 1; i <= n; i++ {
+		x := big.NewInt(int64(i))
+		y := new(big.Int)
+		for j := t - 1; j >= 0; j-- {
+			y.Mul(y, x)
+			y.Add(y, coeffs[j])
+			y.Mod(y, order)
+		}
+		shares[i-1] = &KeyShare{Index: i, secret: y}
+		pk.Verification[i-1].ScalarMultiplication(generator(), y)
+	}
+
+	return pk, shares, nil
+}
+
+// Seal encrypts plaintext to the committee. The label is authenticated
+// but not encrypted; it binds the payload to the transaction carrying it
+// so the payload cannot be replayed elsewhere.
+func Seal(pk *PublicKey, epoch uint64, plaintext, label []byte) (*types.SealedPayload, error) {
+	r, err := randomScalar()
+	if err != nil {
+		return nil, err
+	}
+
+	var u, shared bn254.G1Affine
+	u.ScalarMultiplication(generator(), r)
+	shared.ScalarMultiplication(&pk.Point, r)
+
+	ubytes := u.Bytes()
+	ciphertext, err := seal(deriveKey(ubytes[:], &shared), plaintext, label)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prove knowledge of r so U cannot be lifted into another payload to
+	// get it decrypted early
+	w, err := randomScalar()
+	if err != nil {
+		return nil, err
+	}
+	var commit bn254.G1Affine
+	commit.ScalarMultiplication(generator(), w)
+	c := challenge(ubytes[:], pointBytes(&commit), label, ciphertext)
+	z := response(w, c, r)
+
+	return &types.SealedPayload{
+		Epoch:      epoch,
+		Ephemeral:  ubytes[:],
+		Proof:      encodeProof(c, z),
+		Ciphertext: ciphertext,
+	}, nil
+}
+
+// Verify checks that a sealed payload is well formed and that its sender
+// knows the encryption randomness
+func Verify(sp *types.SealedPayload, label []byte) error {
+	var u bn254.G1Affine
+	if _, err := u.SetBytes(sp.Ephemeral); err != nil || u.IsInfinity() {
+		return ErrInvalidCiphertext
+	}
+	c, z, ok := decodeProof(sp.Proof)
+	if !ok {
+		return ErrInvalidCiphertext
+	}
+
+	// R = z·G - c·U
+	commit := linear(z, generator(), c, &u)
+	if challenge(sp.Ephemeral, pointBytes(commit), label, sp.Ciphertext).Cmp(c) != 0 {
+		return ErrInvalidCiphertext
+	}
+	return nil
+}
+
+// DecryptShare computes this member's decryption share for a payload
+func (ks *KeyShare) DecryptShare(sp *types.SealedPayload) (*DecryptionShare, error) {
+	var u bn254.G1Affine
+	if _, err := u.SetBytes(sp.Ephemeral); err != nil || u.IsInfinity() {
+		return nil, ErrInvalidCiphertext
+	}
+
+	var d, vk bn254.G1Affine
+	d.ScalarMultiplication(&u, ks.secret)
+	vk.ScalarMultiplication(generator(), ks.secret)
+
+	// Chaum-Pedersen: log_G(vk) == log_U(d)
+	w, err := randomScalar()
+	if err != nil {
+		return nil, err
+	}
+	var a, b bn254.G1Affine
+	a.ScalarMultiplication(generator(), w)
+	b.ScalarMultiplication(&u, w)
+	c := challenge(pointBytes(&vk), sp.Ephemeral, pointBytes(&d), pointBytes(&a), pointBytes(&b))
+	z := response(w, c, ks.secret)
+
+	return &DecryptionShare{
+		Index: ks.Index,
+		Point: pointBytes(&d),
+		Proof: encodeProof(c, z),
+	}, nil
+}
+
+// VerifyShare checks a decryption share against the member's
+// verification key
+func (pk *PublicKey) VerifyShare(sp *types.SealedPayload, share *DecryptionShare) error {
+	if share.Index < 1 || share.Index > len(pk.Verification) {
+		return ErrInvalidShare
+	}
+	var u, d bn254.G1Affine
+	if _, err := u.SetBytes(sp.Ephemeral); err != nil {
+		return ErrInvalidCiphertext
+	}
+	if _, err := d.SetBytes(share.Point); err != nil {
+		return ErrInvalidShare
+	}
+	c, z, ok := decodeProof(share.Proof)
+	if !ok {
+		return ErrInvalidShare
+	}
+
+	vk := &pk.Verification[share.Index-1]
+	a := linear(z, generator(), c, vk)
+	b := linear(z, &u, c, &d)
+	if challenge(pointBytes(vk), sp.Ephemeral, share.Point, pointBytes(a), pointBytes(b)).Cmp(c) != 0 {
+		return ErrInvalidShare
+	}
+	return nil
+}
+
+// Open combines Threshold valid shares and decrypts the payload
+func (pk *PublicKey) Open(sp *types.SealedPayload, label []byte, shares []*DecryptionShare) ([]byte, error) {
+	// Use the first Threshold distinct, valid shares
+	selected := make([]*DecryptionShare, 0, pk.Threshold)
+	seen := make(map[int]bool)
+	for _, share := range shares {
+		if len(selected) == pk.Threshold {
+			break
+		}
+		if seen[share.Index] || pk.VerifyShare(sp, share) != nil {
+			continue
+		}
+		seen[share.Index] = true
+		selected = append(selected, share)
+	}
+	if len(selected) < pk.Threshold {
+		return nil, ErrNotEnoughShares
+	}
+
+	// r·s·G = Σ λ_i · s_i·U
+	order := fr.Modulus()
+	var acc bn254.G1Jac
+	for _, share := range selected {
+		lambda := big.NewInt(1)
+		xi := big.NewInt(int64(share.Index))
+		for _, other := range selected {
+			if other.Index == share.Index {
+				continue
+			}
+			xj := big.NewInt(int64(other.Index))
+			den := new(big.Int).Sub(xj, xi)
+			den.Mod(den, order).ModInverse(den, order)
+			lambda.Mul(lambda, xj).Mul(lambda, den).Mod(lambda, order)
+		}
+
+		var d, term bn254.G1Affine
+		d.SetBytes(share.Point)
+		term.ScalarMultiplication(&d, lambda)
+		acc.AddMixed(&term)
+	}
+	var shared bn254.G1Affine
+	shared.FromJacobian(&acc)
+
+	plaintext, err := open(deriveKey(sp.Ephemeral, &shared), sp.Ciphertext, label)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return plaintext, nil
+}
+
+// generator returns the BN254 G1 generator
+func generator() *bn254.G1Affine {
+	_, _, g, _ := bn254.Generators()
+	return &g
+}
+
+// randomScalar returns a uniformly random non-zero scalar
+func randomScalar() (*big.Int, error) {
+	for {
+		k, err := rand.Int(rand.Reader, fr.Modulus())
+		if err != nil {
+			return nil, err
+		}
+		if k.Sign() != 0 {
+			return k, nil
+		}
+	}
+}
+
+// pointBytes returns the compressed encoding of p
+func pointBytes(p *bn254.G1Affine) []byte {
+	b := p.Bytes()
+	return b[:]
+}
+
+// linear returns a·P - b·Q
+func linear(a *big.Int, p *bn254.G1Affine, b *big.Int, q *bn254.G1Affine) *bn254.G1Affine {
+	var ap, bq bn254.G1Affine
+	ap.ScalarMultiplication(p, a)
+	bq.ScalarMultiplication(q, b)
+	bq.Neg(&bq)
+
+	var acc bn254.G1Jac
+	acc.FromAffine(&ap)
+	acc.AddMixed(&bq)
+
+	var result bn254.G1Affine
+	result.FromJacobian(&acc)
+	return &result
+}
+
+// challenge hashes proof transcripts to a scalar
+func challenge(parts ...[]byte) *big.Int {
+	h := sha256.New()
+	h.Write([]byte("CCOIN_THRESHOLD_PROOF"))
+	for _, part := range parts {
+		h.Write(part)
+	}
+	c := new(big.Int).SetBytes(h.Sum(nil))
+	return c.Mod(c, fr.Modulus())
+}
+
+// response returns w + c·x mod r
+func response(w, c, x *big.Int) *big.Int {
+	z := new(big.Int).Mul(c, x)
+	z.Add(z, w)
+	return z.Mod(z, fr.Modulus())
+}
+
+// encodeProof serializes a (challenge, response) pair
+func encodeProof(c, z *big.Int) []byte {
+	proof := make([]byte, proofSize)
+	c.FillBytes(proof[:fr.Bytes])
+	z.FillBytes(proof[fr.Bytes:])
+	return proof
+}
+
+// decodeProof parses a proof written by encodeProof
+func decodeProof(proof []byte) (c, z *big.Int, ok bool) {
+	if len(proof) != proofSize {
+		return nil, nil, false
+	}
+	c = new(big.Int).SetBytes(proof[:fr.Bytes])
+	z = new(big.Int).SetBytes(proof[fr.Bytes:])
+	order := fr.Modulus()
+	return c, z, c.Cmp(order) < 0 && z.Cmp(order) < 0
+}
+
+// deriveKey derives the symmetric key from the ephemeral point and the
+// shared point r·s·G
+func deriveKey(ephemeral []byte, shared *bn254.G1Affine) []byte {
+	h := sha256.New()
+	h.Write([]byte("CCOIN_THRESHOLD_KEY"))
+	h.Write(ephemeral)
+	h.Write(pointBytes(shared))
+	return h.Sum(nil)
+}
+
+// seal encrypts with AES-256-GCM. Every key encrypts a single message, so
+// a fixed nonce is safe.
+func seal(key, plaintext, label []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	return aead.Seal(nil, nonce, plaintext, label), nil
+}
+
+// open decrypts a message from seal
+func open(key, ciphertext, label []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	return aead.Open(nil, nonce, ciphertext, label)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,228 @@
+// Package vrf implements ECVRF-EDWARDS25519-SHA512-TAI (RFC 9381), a
+// verifiable random function over the ed25519 keys miners already hold.
+// Its output cannot be predicted without the private key, yet anyone can
+// check it against the public key, so miners cannot grind the tasks or
+// verification subsets it selects.
+package vrf
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+
+	"filippo.io/edwards25519"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// VRF sizes
+const (
+	// ProofSize is the size of a proof: Gamma, c and s
+	ProofSize = 32 + challengeSize + 32
+
+	// OutputSize is the size of the VRF output (beta)
+	OutputSize = sha512.Size
+
+	challengeSize = 16
+)
+
+// suiteString identifies ECVRF-EDWARDS25519-SHA512-TAI
+const suiteString = 0x03
+
+// VRF errors
+var (
+	ErrInvalidPrivateKey = errors.New("invalid VRF private key")
+	ErrInvalidPublicKey  = errors.New("invalid VRF public key")
+	ErrInvalidProof      = errors.New("invalid VRF proof")
+	ErrHashToCurve       = errors.New("VRF input does not map to a curve point")
+)
+
+// Prove returns the proof of the VRF output for alpha under sk. The key
+// is expanded as in ed25519, so a miner's identity key is its VRF key.
+func Prove(sk ed25519.PrivateKey, alpha []byte) ([]byte, error) {
+	if len(sk) != ed25519.PrivateKeySize {
+		return nil, ErrInvalidPrivateKey
+	}
+	pk := sk[32:]
+
+	expanded := sha512.Sum512(sk.Seed())
+	x, err := edwards25519.NewScalar().SetBytesWithClamping(expanded[:32])
+	if err != nil {
+		return nil, err
+	}
+	y, err := decodePoint(pk)
+	if err != nil {
+		return nil, ErrInvalidPrivateKey
+	}
+
+	h, err := encodeToCurve(pk, alpha)
+	if err != nil {
+		return nil, err
+	}
+	gamma := new(edwards25519.Point).ScalarMult(x, h)
+
+	// Nonce as in ed25519 signing
+	nonce := sha512.New()
+	nonce.Write(expanded[32:])
+	nonce.Write(h.Bytes())
+	k, err := edwards25519.NewScalar().SetUniformBytes(nonce.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	c := challenge(y, h, gamma,
+		new(edwards25519.Point).ScalarBaseMult(k),
+		new(edwards25519.Point).ScalarMult(k, h))
+	s := edwards25519.NewScalar().MultiplyAdd(challengeScalar(c), x, k)
+
+	proof := make([]byte, 0, ProofSize)
+	proof = append(proof, gamma.Bytes()...)
+	proof = append(proof, c...)
+	return append(proof, s.Bytes()...), nil
+}
+
+// Verify checks a proof for alpha under pk and returns the VRF output.
+// Keys of small order are rejected.
+func Verify(pk ed25519.PublicKey, proof, alpha []byte) ([]byte, error) {
+	if len(pk) != ed25519.PublicKeySize {
+		return nil, ErrInvalidPublicKey
+	}
+	y, err := decodePoint(pk)
+	if err != nil || isIdentity(mulByCofactor(y)) {
+		return nil, ErrInvalidPublicKey
+	}
+	gamma, c, s, err := decodeProof(proof)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := encodeToCurve(pk, alpha)
+	if err != nil {
+		return nil, err
+	}
+
+	// U = s*B - c*Y, V = s*H - c*Gamma
+	negC := edwards25519.NewScalar().Negate(challengeScalar(c))
+	u := new(edwards25519.Point).VarTimeDoubleScalarBaseMult(negC, y, s)
+	v := new(edwards25519.Point).Add(
+		new(edwards25519.Point).ScalarMult(s, h),
+		new(edwards25519.Point).ScalarMult(negC, gamma))
+
+	if subtle.ConstantTimeCompare(challenge(y, h, gamma, u, v), c) != 1 {
+		return nil, ErrInvalidProof
+	}
+	return gammaToHash(gamma), nil
+}
+
+// ProofToHash returns the VRF output of a proof without verifying it.
+// Only use it on proofs already verified.
+func ProofToHash(proof []byte) ([]byte, error) {
+	gamma, _, _, err := decodeProof(proof)
+	if err != nil {
+		return nil, err
+	}
+	return gammaToHash(gamma), nil
+}
+
+// TaskInput is the VRF input task assignment is drawn from: the hash of a
+// recent block, which BlockValidator checks headers commit to
+func TaskInput(seed types.Hash) []byte {
+	return append([]byte("ccoin/vrf/task/"), seed[:]...)
+}
+
+// Index maps a VRF output to an index below n
+func Index(output []byte, n int) int {
+	if n <= 0 || len(output) < 8 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint64(output[:8]) % uint64(n))
+}
+
+// encodeToCurve hashes alpha to a point of the prime-order subgroup by
+// try-and-increment, salted with the public key
+func encodeToCurve(pk, alpha []byte) (*edwards25519.Point, error) {
+	for ctr := 0; ctr < 256; ctr++ {
+		h := sha512.New()
+		h.Write([]byte{suiteString, 0x01})
+		h.Write(pk)
+		h.Write(alpha)
+		h.Write([]byte{byte(ctr), 0x00})
+
+		if p, err := decodePoint(h.Sum(nil)[:32]); err == nil {
+			return mulByCofactor(p), nil
+		}
+	}
+	return nil, ErrHashToCurve
+}
+
+// challenge hashes the points of a proof to its 16-byte challenge
+func challenge(points ...*edwards25519.Point) []byte {
+	h := sha512.New()
+	h.Write([]byte{suiteString, 0x02})
+	for _, p := range points {
+		h.Write(p.Bytes())
+	}
+	h.Write([]byte{0x00})
+	return h.Sum(nil)[:challengeSize]
+}
+
+// challengeScalar reads a little-endian challenge as a scalar
+func challengeScalar(c []byte) *edwards25519.Scalar {
+	var buf [32]byte
+	copy(buf[:], c)
+	s, _ := edwards25519.NewScalar().SetCanonicalBytes(buf[:])
+	return s
+}
+
+// gammaToHash derives the VRF output from Gamma
+func gammaToHash(gamma *edwards25519.Point) []byte {
+	h := sha512.New()
+	h.Write([]byte{suiteString, 0x03})
+	h.Write(mulByCofactor(gamma).Bytes())
+	h.Write([]byte{0x00})
+	return h.Sum(nil)
+}
+
+// decodeProof splits a proof into Gamma, c and s
+func decodeProof(proof []byte) (*edwards25519.Point, []byte, *edwards25519.Scalar, error) {
+	if len(proof) != ProofSize {
+		return nil, nil, nil, ErrInvalidProof
+	}
+	gamma, err := decodePoint(proof[:32])
+	if err != nil {
+		return nil, nil, nil, ErrInvalidProof
+	}
+	s, err := edwards25519.NewScalar().SetCanonicalBytes(proof[32+challengeSize:])
+	if err != nil {
+		return nil, nil, nil, ErrInvalidProof
+	}
+	return gamma, proof[32 : 32+challengeSize], s, nil
+}
+
+// decodePoint decodes a point, rejecting the non-canonical encodings
+// RFC 8032 rejects
+func decodePoint(b []byte) (*edwards25519.Point, error) {
+	p, err := new(edwards25519.Point).SetBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(p.Bytes(), b) {
+		return nil, ErrInvalidProof
+	}
+	return p, nil
+}
+
+// mulByCofactor multiplies a point by the curve's cofactor, 8
+func mulByCofactor(p *edwards25519.Point) *edwards25519.Point {
+	r := new(edwards25519.Point).Add(p, p)
+	r.Add(r, r)
+	return r.Add(r, r)
+}
+
+// isIdentity reports whether p is the identity point
+func isIdentity(p *edwards25519.Point) bool {
+	return p.Equal(edwards25519.NewIdentityPoint()) == 1
+}
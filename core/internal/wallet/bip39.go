@@ -0,0 +1,291 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"embed"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Mnemonic errors
+var (
+	ErrInvalidEntropy  = errors.New("entropy must be 128-256 bits in multiples of 32")
+	ErrInvalidMnemonic = errors.New("invalid mnemonic")
+	ErrInvalidChecksum = errors.New("mnemonic checksum mismatch")
+	ErrUnknownWord     = errors.New("mnemonic contains unknown word")
+	ErrUnknownLanguage = errors.New("unknown mnemonic language")
+)
+
+// Mnemonic strengths in bits
+const (
+	Entropy128 = 128 // 12 words
+	Entropy256 = 256 // 24 words
+)
+
+// LanguageEnglish is the language of new mnemonics unless configured
+// otherwise
+const LanguageEnglish = "english"
+
+// wordlistFiles holds the BIP-39 wordlists, one word per line, named
+// after their language. Dropping another official list into wordlists/
+// makes it available.
+//
+//go:embed wordlists/*.txt
+var wordlistFiles embed.FS
+
+// Wordlist is a BIP-39 wordlist of 2048 words
+type Wordlist struct {
+	Language string
+
+	words []string
+	index map[string]int
+
+	// separator joins the words of a mnemonic
+	separator string
+}
+
+// wordlists maps languages to their wordlists
+var wordlists = loadWordlists()
+
+// loadWordlists reads the embedded wordlists. Words are indexed
+// NFKD-normalized so mnemonics match whichever form they were typed in.
+func loadWordlists() map[string]*Wordlist {
+	entries, err := wordlistFiles.ReadDir("wordlists")
+	if err != nil {
+		panic(err)
+	}
+
+	lists := make(map[string]*Wordlist, len(entries))
+	for _, e := range entries {
+		name := path.Join("wordlists", e.Name())
+		data, err := wordlistFiles.ReadFile(name)
+		if err != nil {
+			panic(err)
+		}
+
+		words := strings.Fields(string(data))
+		if len(words) != 2048 {
+			panic(name + " does not hold 2048 words")
+		}
+
+		wl := &Wordlist{
+			Language:  strings.TrimSuffix(e.Name(), path.Ext(e.Name())),
+			words:     words,
+			index:     make(map[string]int, len(words)),
+			separator: " ",
+		}
+		if wl.Language == "japanese" {
+			wl.separator = "\u3000" // ideographic space
+		}
+		for i, w := range words {
+			wl.index[norm.NFKD.String(w)] = i
+		}
+		lists[wl.Language] = wl
+	}
+	return lists
+}
+
+// Languages returns the languages mnemonics can be written in
+func Languages() []string {
+	langs := make([]string, 0, len(wordlists))
+	for lang := range wordlists {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// LookupWordlist returns the wordlist of a language; the empty language
+// is English
+func LookupWordlist(language string) (*Wordlist, error) {
+	if language == "" {
+		language = LanguageEnglish
+	}
+	wl, ok := wordlists[strings.ToLower(language)]
+	if !ok {
+		return nil, fmt.Errorf("%w %q (available: %s)", ErrUnknownLanguage, language, strings.Join(Languages(), ", "))
+	}
+	return wl, nil
+}
+
+// DetectWordlist returns the wordlist a mnemonic is written in. Some words
+// appear in more than one list, so the first list that decodes it wins,
+// English first. Otherwise the error is the one from the list holding
+// the most of its words.
+func DetectWordlist(mnemonic string) (*Wordlist, error) {
+	words := mnemonicWords(mnemonic)
+
+	var (
+		closestErr error = ErrUnknownLanguage
+		known            = -1
+	)
+	for _, wl := range detectionOrder() {
+		_, err := wl.decode(words)
+		if err == nil {
+			return wl, nil
+		}
+		n := 0
+		for _, w := range words {
+			if _, ok := wl.index[w]; ok {
+				n++
+			}
+		}
+		if n > known {
+			closestErr, known = err, n
+		}
+	}
+	return nil, closestErr
+}
+
+// detectionOrder returns the wordlists English first, then by language
+func detectionOrder() []*Wordlist {
+	lists := []*Wordlist{wordlists[LanguageEnglish]}
+	for _, lang := range Languages() {
+		if lang != LanguageEnglish {
+			lists = append(lists, wordlists[lang])
+		}
+	}
+	return lists
+}
+
+// NewMnemonic generates a random English BIP-39 mnemonic with the given
+// entropy size
+func NewMnemonic(bits int) (string, error) {
+	return wordlists[LanguageEnglish].NewMnemonic(bits)
+}
+
+// EntropyToMnemonic encodes entropy as an English BIP-39 mnemonic
+func EntropyToMnemonic(entropy []byte) (string, error) {
+	return wordlists[LanguageEnglish].EntropyToMnemonic(entropy)
+}
+
+// MnemonicToEntropy decodes a mnemonic in any known language and verifies
+// its checksum
+func MnemonicToEntropy(mnemonic string) ([]byte, error) {
+	wl, err := DetectWordlist(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	return wl.MnemonicToEntropy(mnemonic)
+}
+
+// ValidateMnemonic reports whether a mnemonic is well-formed
+func ValidateMnemonic(mnemonic string) bool {
+	_, err := MnemonicToEntropy(mnemonic)
+	return err == nil
+}
+
+// NewMnemonic generates a random mnemonic from the wordlist with the given
+// entropy size
+func (wl *Wordlist) NewMnemonic(bits int) (string, error) {
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", ErrInvalidEntropy
+	}
+
+	entropy := make([]byte, bits/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", err
+	}
+
+	return wl.EntropyToMnemonic(entropy)
+}
+
+// EntropyToMnemonic encodes entropy as a mnemonic from the wordlist
+func (wl *Wordlist) EntropyToMnemonic(entropy []byte) (string, error) {
+	bits := len(entropy) * 8
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", ErrInvalidEntropy
+	}
+
+	checksumBits := bits / 32
+	hash := sha256.Sum256(entropy)
+
+	// entropy || checksum, read 11 bits at a time
+	data := append(append([]byte{}, entropy...), hash[0])
+	words := make([]string, (bits+checksumBits)/11)
+	for i := range words {
+		words[i] = wl.words[readBits(data, i*11, 11)]
+	}
+
+	return strings.Join(words, wl.separator), nil
+}
+
+// MnemonicToEntropy decodes a mnemonic from the wordlist and verifies its
+// checksum
+func (wl *Wordlist) MnemonicToEntropy(mnemonic string) ([]byte, error) {
+	return wl.decode(mnemonicWords(mnemonic))
+}
+
+// decode decodes NFKD-normalized mnemonic words
+func (wl *Wordlist) decode(words []string) ([]byte, error) {
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, fmt.Errorf("%w: %d words, expected 12, 15, 18, 21 or 24", ErrInvalidMnemonic, len(words))
+	}
+
+	totalBits := len(words) * 11
+	checksumBits := totalBits / 33
+	entropyBits := totalBits - checksumBits
+
+	data := make([]byte, (totalBits+7)/8)
+	for i, w := range words {
+		idx, ok := wl.index[w]
+		if !ok {
+			return nil, fmt.Errorf("%w %q (word %d) for the %s wordlist", ErrUnknownWord, norm.NFC.String(w), i+1, wl.Language)
+		}
+		writeBits(data, i*11, 11, idx)
+	}
+
+	entropy := data[:entropyBits/8]
+	hash := sha256.Sum256(entropy)
+	if readBits(data, entropyBits, checksumBits) != readBits(hash[:], 0, checksumBits) {
+		return nil, ErrInvalidChecksum
+	}
+
+	return append([]byte{}, entropy...), nil
+}
+
+// mnemonicWords splits a mnemonic into NFKD-normalized words
+func mnemonicWords(mnemonic string) []string {
+	return strings.Fields(norm.NFKD.String(mnemonic))
+}
+
+// MnemonicToSeed derives the 64-byte BIP-39 seed. The mnemonic and the
+// passphrase, the optional "25th word", are NFKD-normalized first, so a
+// passphrase typed with composed or decomposed accents gives the same
+// seed.
+func MnemonicToSeed(mnemonic, passphrase string) []byte {
+	m := strings.Join(mnemonicWords(mnemonic), " ")
+	salt := norm.NFKD.String("mnemonic" + passphrase)
+	return pbkdf2.Key([]byte(m), []byte(salt), 2048, 64, sha512.New)
+}
+
+// readBits reads n bits (n <= 11) starting at bit offset off, MSB first
+func readBits(data []byte, off, n int) int {
+	v := 0
+	for i := 0; i < n; i++ {
+		bit := off + i
+		v <<= 1
+		if data[bit/8]&(0x80>>(bit%8)) != 0 {
+			v |= 1
+		}
+	}
+	return v
+}
+
+// writeBits writes the low n bits of v starting at bit offset off, MSB first
+func writeBits(data []byte, off, n, v int) {
+	for i := 0; i < n; i++ {
+		bit := off + i
+		if v&(1<<(n-1-i)) != 0 {
+			data[bit/8] |= 0x80 >> (bit % 8)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package wallet
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Derivation constants (SLIP-10 over ed25519, hardened-only)
+const (
+	// HardenedOffset marks a hardened child index
+	HardenedOffset uint32 = 0x80000000
+
+	// CoinType is the SLIP-44 coin type used in derivation paths
+	CoinType uint32 = 7331
+
+	// Purposes for transparent and shielded key trees
+	PurposeTransparent uint32 = 44
+	PurposeShielded    uint32 = 32
+)
+
+// slip10Curve is the HMAC key for the ed25519 master node
+var slip10Curve = []byte("ed25519 seed")
+
+// extendedKey is a SLIP-10 node
+type extendedKey struct {
+	key       [32]byte
+	chainCode [32]byte
+}
+
+// masterKey derives the SLIP-10 master node from a BIP-39 seed
+func masterKey(seed []byte) *extendedKey {
+	mac := hmac.New(sha512.New, slip10Curve)
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	k := &extendedKey{}
+	copy(k.key[:], sum[:32])
+	copy(k.chainCode[:], sum[32:])
+	return k
+}
+
+// child derives a hardened child node
+func (k *extendedKey) child(index uint32) *extendedKey {
+	var data [37]byte
+	copy(data[1:33], k.key[:])
+	binary.BigEndian.PutUint32(data[33:], index|HardenedOffset)
+
+	mac := hmac.New(sha512.New, k.chainCode[:])
+	mac.Write(data[:])
+	sum := mac.Sum(nil)
+
+	c := &extendedKey{}
+	copy(c.key[:], sum[:32])
+	copy(c.chainCode[:], sum[32:])
+	return c
+}
+
+// derivePath derives a node along a hardened path from the seed
+func derivePath(seed []byte, path ...uint32) *extendedKey {
+	k := masterKey(seed)
+	for _, index := range path {
+		k = k.child(index)
+	}
+	return k
+}
+
+// TransparentKey is a signing key for a transparent address
+type TransparentKey struct {
+	Index      uint32
+	PrivateKey ed25519.PrivateKey
+	PublicKey  ed25519.PublicKey
+	Address    types.Address
+}
+
+// deriveTransparentKey derives m/44'/coin'/account'/0'/index'
+func deriveTransparentKey(seed []byte, account, index uint32) *TransparentKey {
+	node := derivePath(seed, PurposeTransparent, CoinType, account, 0, index)
+	priv := ed25519.NewKeyFromSeed(node.key[:])
+	pub := priv.Public().(ed25519.PublicKey)
+
+	return &TransparentKey{
+		Index:      index,
+		PrivateKey: priv,
+		PublicKey:  pub,
+		Address:    AddressFromPublicKey(pub),
+	}
+}
+
+// AddressFromPublicKey derives an address: the first 20 bytes of SHA-256(pubkey)
+func AddressFromPublicKey(pub ed25519.PublicKey) types.Address {
+	return types.AddressFromPublicKey(pub)
+}
+
+// ShieldedKey holds the keys for a shielded address
+type ShieldedKey struct {
+	Index uint32
+
+	// SpendingKey authorizes spends and derives nullifiers
+	SpendingKey [32]byte
+
+	// ViewingKey is the incoming viewing key: it decrypts notes sent to
+	// the address without spend authority
+	ViewingKey [32]byte
+
+	// OutgoingViewingKey recovers the notes this address sends
+	OutgoingViewingKey [32]byte
+
+	// TransmissionKey is the public key senders encrypt notes to
+	TransmissionKey [32]byte
+
+	// Address receives shielded notes; it is zkp.ShieldedAddress of the
+	// spending key
+	Address types.Address
+}
+
+// deriveShieldedKey derives m/32'/coin'/account'/index'
+func deriveShieldedKey(seed []byte, account, index uint32) *ShieldedKey {
+	node := derivePath(seed, PurposeShielded, CoinType, account, index)
+
+	sk := &ShieldedKey{Index: index, SpendingKey: node.key}
+	sk.ViewingKey = sha256.Sum256(append([]byte("CCOIN_VIEWING_KEY"), sk.SpendingKey[:]...))
+	sk.OutgoingViewingKey = sha256.Sum256(append([]byte("CCOIN_OUTGOING_VIEWING_KEY"), sk.SpendingKey[:]...))
+
+	// Any 32 bytes are a valid X25519 private key
+	tk, _ := zkp.TransmissionKey(sk.ViewingKey[:])
+	copy(sk.TransmissionKey[:], tk)
+
+	// The transaction circuit checks this derivation when a note is spent
+	sk.Address = zkp.ShieldedAddress(sk.SpendingKey[:])
+
+	return sk
+}
+
+// SignatureSchemeEd25519 tags ed25519 signatures in the last byte of a Signature
+const SignatureSchemeEd25519 byte = 1
+
+// signWith signs data with an ed25519 key, packing it into a types.Signature
+func signWith(priv ed25519.PrivateKey, data []byte) types.Signature {
+	var sig types.Signature
+	copy(sig[:ed25519.SignatureSize], ed25519.Sign(priv, data))
+	sig[ed25519.SignatureSize] = SignatureSchemeEd25519
+	return sig
+}
+
+// VerifySignature verifies a signature produced by Wallet.Sign
+func VerifySignature(pub ed25519.PublicKey, data []byte, sig types.Signature) bool {
+	if len(pub) != ed25519.PublicKeySize || sig[ed25519.SignatureSize] != SignatureSchemeEd25519 {
+		return false
+	}
+	return ed25519.Verify(pub, data, sig[:ed25519.SignatureSize])
+}
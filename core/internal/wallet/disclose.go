@@ -0,0 +1,66 @@
+package wallet
+
+import (
+	"errors"
+
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// ErrNotSentByWallet is returned when disclosing an output the wallet did
+// not create
+var ErrNotSentByWallet = errors.New("output not sent by this wallet")
+
+// OutgoingViewingKey returns the outgoing viewing key for a shielded address
+func (w *Wallet) OutgoingViewingKey(addr types.Address) ([]byte, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.seed == nil {
+		return nil, ErrLocked
+	}
+	k, exists := w.shielded[addr]
+	if !exists {
+		return nil, ErrUnknownAddress
+	}
+
+	out := make([]byte, len(k.OutgoingViewingKey))
+	copy(out, k.OutgoingViewingKey[:])
+	return out, nil
+}
+
+// DisclosePayment builds a payment disclosure for an output the wallet
+// sent, recovering the note with the wallet's outgoing viewing keys
+func (w *Wallet) DisclosePayment(tx *types.Transaction, outputIndex int) (*zkp.PaymentDisclosure, error) {
+	if outputIndex < 0 || outputIndex >= len(tx.Commitments) {
+		return nil, ErrNotSentByWallet
+	}
+	c := tx.Commitments[outputIndex]
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.seed == nil {
+		return nil, ErrLocked
+	}
+	for _, k := range w.shielded {
+		note, esk, tk, err := zkp.RecoverNote(k.OutgoingViewingKey[:], c.EncryptedNote)
+		if err != nil {
+			continue
+		}
+		if zkp.NoteCommitment(note.Value, note.Address, note.Blinder) != c.Value {
+			continue
+		}
+		return &zkp.PaymentDisclosure{
+			TxHash:          tx.TxHash,
+			OutputIndex:     uint32(outputIndex),
+			Value:           note.Value,
+			Recipient:       note.Address,
+			TransmissionKey: tk,
+			Memo:            note.Memo,
+			Blinder:         note.Blinder,
+			EphemeralSecret: esk,
+		}, nil
+	}
+	return nil, ErrNotSentByWallet
+}
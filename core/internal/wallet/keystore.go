@@ -0,0 +1,201 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/ccoin/core/internal/atrest"
+)
+
+// Keystore errors
+var (
+	ErrKeystoreExists   = errors.New("keystore already exists")
+	ErrKeystoreNotFound = errors.New("keystore not found")
+	ErrWrongPassword    = errors.New("wrong password or corrupted keystore")
+	ErrUnsupportedKDF   = errors.New("unsupported keystore kdf or cipher")
+)
+
+// Keystore file layout
+const (
+	KeystoreDirName  = "wallet"
+	KeystoreFileName = "keystore.json"
+	KeystoreVersion  = 1
+)
+
+// scryptParams are the KDF parameters stored alongside the ciphertext
+type scryptParams struct {
+	N      int    `json:"n"`
+	R      int    `json:"r"`
+	P      int    `json:"p"`
+	KeyLen int    `json:"dklen"`
+	Salt   string `json:"salt"`
+}
+
+// cryptoSection holds the encrypted wallet secret
+type cryptoSection struct {
+	Cipher     string       `json:"cipher"`
+	Nonce      string       `json:"nonce"`
+	Ciphertext string       `json:"ciphertext"`
+	KDF        string       `json:"kdf"`
+	KDFParams  scryptParams `json:"kdfparams"`
+}
+
+// keystoreFile is the on-disk keystore. Only public data is stored in the
+// clear; the mnemonic and its passphrase are sealed with AES-256-GCM under
+// a scrypt-derived key.
+type keystoreFile struct {
+	Version int           `json:"version"`
+	Crypto  cryptoSection `json:"crypto"`
+
+	// Public derivation state
+	Account           uint32   `json:"account"`
+	Transparent       []string `json:"transparent"`
+	Shielded          []string `json:"shielded"`
+	TransparentPubKey []string `json:"transparent_pubkeys"`
+
+	// Viewing keys of the shielded addresses, so notes can be scanned
+	// for while the wallet is locked
+	ShieldedViewingKey []string `json:"shielded_viewing_keys,omitempty"`
+}
+
+// secret is the plaintext sealed in the keystore
+type secret struct {
+	Mnemonic   string `json:"mnemonic"`
+	Passphrase string `json:"passphrase,omitempty"`
+}
+
+// keystorePath returns the keystore file path under dataDir
+func keystorePath(dataDir string) string {
+	return filepath.Join(dataDir, KeystoreDirName, KeystoreFileName)
+}
+
+// sealSecret encrypts the secret with a password
+func sealSecret(s *secret, password string, cfg *Config) (cryptoSection, error) {
+	plaintext, err := json.Marshal(s)
+	if err != nil {
+		return cryptoSection{}, err
+	}
+
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return cryptoSection{}, err
+	}
+
+	params := scryptParams{N: cfg.ScryptN, R: cfg.ScryptR, P: cfg.ScryptP, KeyLen: 32, Salt: hex.EncodeToString(salt)}
+	key, err := scrypt.Key([]byte(password), salt, params.N, params.R, params.P, params.KeyLen)
+	if err != nil {
+		return cryptoSection{}, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return cryptoSection{}, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return cryptoSection{}, err
+	}
+
+	return cryptoSection{
+		Cipher:     "aes-256-gcm",
+		Nonce:      hex.EncodeToString(nonce),
+		Ciphertext: hex.EncodeToString(gcm.Seal(nil, nonce, plaintext, nil)),
+		KDF:        "scrypt",
+		KDFParams:  params,
+	}, nil
+}
+
+// openSecret decrypts the secret with a password
+func openSecret(c *cryptoSection, password string) (*secret, error) {
+	if c.KDF != "scrypt" || c.Cipher != "aes-256-gcm" {
+		return nil, ErrUnsupportedKDF
+	}
+
+	salt, err := hex.DecodeString(c.KDFParams.Salt)
+	if err != nil {
+		return nil, ErrWrongPassword
+	}
+	nonce, err := hex.DecodeString(c.Nonce)
+	if err != nil {
+		return nil, ErrWrongPassword
+	}
+	ciphertext, err := hex.DecodeString(c.Ciphertext)
+	if err != nil {
+		return nil, ErrWrongPassword
+	}
+
+	key, err := scrypt.Key([]byte(password), salt, c.KDFParams.N, c.KDFParams.R, c.KDFParams.P, c.KDFParams.KeyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, ErrWrongPassword
+	}
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrWrongPassword
+	}
+
+	s := &secret{}
+	if err := json.Unmarshal(plaintext, s); err != nil {
+		return nil, ErrWrongPassword
+	}
+	return s, nil
+}
+
+// newGCM creates an AES-GCM AEAD for a 32-byte key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// loadKeystore reads the keystore file, decrypting it with c if it is
+// encrypted at rest
+func loadKeystore(c *atrest.Cipher, path string) (*keystoreFile, error) {
+	data, err := c.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrKeystoreNotFound
+		}
+		return nil, err
+	}
+
+	ks := &keystoreFile{}
+	if err := json.Unmarshal(data, ks); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// saveKeystore atomically writes the keystore file with owner-only
+// permissions, encrypted at rest with c if set
+func saveKeystore(c *atrest.Cipher, path string, ks *keystoreFile) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(ks, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return c.WriteFile(path, data, 0600)
+}
@@ -0,0 +1,231 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Memo errors
+var (
+	ErrMalformedMemo   = errors.New("malformed memo")
+	ErrUnknownMemoType = errors.New("unknown memo type")
+)
+
+// MemoType identifies the format of a note memo. Structured memos start
+// with their type byte; any other memo is free text.
+type MemoType byte
+
+// Memo types. Type bytes up to memoTypeReserved are reserved for
+// structured memos, so free text may not start with them.
+const (
+	MemoText       MemoType = 0x00
+	MemoPaymentRef MemoType = 0x01
+	MemoLicense    MemoType = 0x02
+	MemoGovernance MemoType = 0x03
+
+	memoTypeReserved = 0x08
+)
+
+// MaxMemoReference is the longest payment reference or license ID
+const MaxMemoReference = 64
+
+// memoTypeNames names each memo type
+var memoTypeNames = map[MemoType]string{
+	MemoText:       "text",
+	MemoPaymentRef: "payment_ref",
+	MemoLicense:    "license",
+	MemoGovernance: "governance",
+}
+
+// String returns the memo type name
+func (t MemoType) String() string {
+	if name, ok := memoTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("type_%d", byte(t))
+}
+
+// ParseMemoType parses a memo type name
+func ParseMemoType(name string) (MemoType, error) {
+	for t, n := range memoTypeNames {
+		if n == name {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("%w %q", ErrUnknownMemoType, name)
+}
+
+// Memo is a decoded note memo. Which fields are used depends on Type:
+//
+//	text         Text
+//	payment_ref  Reference, the merchant's invoice or order ID
+//	license      Subject, the licensed model, and Reference, the license ID
+//	governance   Subject, the proposal, and Text, the note
+type Memo struct {
+	Type      MemoType
+	Reference string
+	Subject   types.Hash
+	Text      string
+}
+
+// EncodeMemo encodes a memo as a type byte followed by its payload. Text
+// memos are encoded as the bare text.
+func EncodeMemo(m *Memo) ([]byte, error) {
+	switch m.Type {
+	case MemoText:
+		if m.Text != "" && m.Text[0] <= memoTypeReserved {
+			return nil, fmt.Errorf("%w: text starts with a reserved byte", ErrMalformedMemo)
+		}
+		return []byte(m.Text), nil
+
+	case MemoPaymentRef:
+		if err := checkReference(m.Reference); err != nil {
+			return nil, err
+		}
+		return append([]byte{byte(m.Type)}, m.Reference...), nil
+
+	case MemoLicense:
+		if err := checkReference(m.Reference); err != nil {
+			return nil, err
+		}
+		buf := append([]byte{byte(m.Type)}, m.Subject[:]...)
+		return append(buf, m.Reference...), nil
+
+	case MemoGovernance:
+		if !utf8.ValidString(m.Text) {
+			return nil, fmt.Errorf("%w: note is not UTF-8", ErrMalformedMemo)
+		}
+		buf := append([]byte{byte(m.Type)}, m.Subject[:]...)
+		return append(buf, m.Text...), nil
+	}
+	return nil, ErrUnknownMemoType
+}
+
+// ParseMemo decodes a note memo. Memos not starting with a reserved type
+// byte are text.
+func ParseMemo(data []byte) (*Memo, error) {
+	if len(data) == 0 || data[0] > memoTypeReserved {
+		return &Memo{Type: MemoText, Text: string(data)}, nil
+	}
+
+	m := &Memo{Type: MemoType(data[0])}
+	payload := data[1:]
+	switch m.Type {
+	case MemoPaymentRef:
+		m.Reference = string(payload)
+		if err := checkReference(m.Reference); err != nil {
+			return nil, err
+		}
+
+	case MemoLicense:
+		if len(payload) < types.HashSize {
+			return nil, ErrMalformedMemo
+		}
+		m.Subject = types.HashFromBytes(payload[:types.HashSize])
+		m.Reference = string(payload[types.HashSize:])
+		if err := checkReference(m.Reference); err != nil {
+			return nil, err
+		}
+
+	case MemoGovernance:
+		if len(payload) < types.HashSize || !utf8.Valid(payload[types.HashSize:]) {
+			return nil, ErrMalformedMemo
+		}
+		m.Subject = types.HashFromBytes(payload[:types.HashSize])
+		m.Text = string(payload[types.HashSize:])
+
+	default:
+		return nil, ErrUnknownMemoType
+	}
+	return m, nil
+}
+
+// checkReference validates a payment reference or license ID
+func checkReference(ref string) error {
+	if ref == "" || len(ref) > MaxMemoReference || !utf8.ValidString(ref) {
+		return fmt.Errorf("%w: reference must be 1 to %d bytes of UTF-8", ErrMalformedMemo, MaxMemoReference)
+	}
+	return nil
+}
+
+// NoteFilter selects received notes. Zero fields match everything.
+type NoteFilter struct {
+	Address    types.Address
+	FromHeight uint64
+
+	// IncludeSpent also returns notes already spent
+	IncludeSpent bool
+
+	// Memo criteria; notes whose memo does not parse never match them
+	Types     []MemoType
+	Reference string
+	Subject   types.Hash
+}
+
+// hasMemoCriteria reports whether the filter looks inside memos
+func (f *NoteFilter) hasMemoCriteria() bool {
+	return len(f.Types) > 0 || f.Reference != "" || f.Subject != (types.Hash{})
+}
+
+// match reports whether a note passes the filter
+func (f *NoteFilter) match(n *Note) bool {
+	if n.Spent && !f.IncludeSpent {
+		return false
+	}
+	if f.Address != (types.Address{}) && n.Address != f.Address {
+		return false
+	}
+	if n.Height < f.FromHeight {
+		return false
+	}
+	if !f.hasMemoCriteria() {
+		return true
+	}
+
+	m, err := ParseMemo(n.Memo)
+	if err != nil {
+		return false
+	}
+	if len(f.Types) > 0 && !containsMemoType(f.Types, m.Type) {
+		return false
+	}
+	if f.Reference != "" && m.Reference != f.Reference {
+		return false
+	}
+	return f.Subject == (types.Hash{}) || m.Subject == f.Subject
+}
+
+// containsMemoType reports whether t is in list
+func containsMemoType(list []MemoType, t MemoType) bool {
+	for _, x := range list {
+		if x == t {
+			return true
+		}
+	}
+	return false
+}
+
+// FindNotes returns the received notes passing the filter ordered by tree
+// position, so payments can be matched to the references in their memos
+func (w *Wallet) FindNotes(f *NoteFilter) []*Note {
+	if f == nil {
+		f = &NoteFilter{}
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var out []*Note
+	for _, n := range w.notes {
+		if f.match(n) {
+			c := *n
+			out = append(out, &c)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Position < out[j].Position })
+	return out
+}
@@ -0,0 +1,316 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ccoin/core/internal/atrest"
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Note errors
+var (
+	ErrInsufficientFunds = errors.New("insufficient shielded funds")
+	ErrUnknownNote       = errors.New("note not in wallet")
+)
+
+// NotesFileName is the wallet's note file, next to the keystore
+const NotesFileName = "notes.json"
+
+// Note is a shielded output owned by one of the wallet's addresses
+type Note struct {
+	Commitment types.Hash    `json:"commitment"`
+	Value      uint64        `json:"value"`
+	Address    types.Address `json:"address"`
+	Blinder    []byte        `json:"blinder"`
+	Position   uint64        `json:"position"`
+	Height     uint64        `json:"height"`
+	Memo       []byte        `json:"memo,omitempty"`
+
+	// Nullifier is revealed when the note is spent. It needs the spending
+	// key, so notes found while locked get it on the next unlock.
+	Nullifier types.Hash `json:"nullifier"`
+
+	// Spent is set once a transaction spending the note is accepted
+	Spent bool `json:"spent"`
+}
+
+// notesPath returns the note file path under dataDir
+func notesPath(dataDir string) string {
+	return filepath.Join(dataDir, KeystoreDirName, NotesFileName)
+}
+
+// loadNotes reads the note file, decrypting it with c if it is
+// encrypted at rest; a missing file means no notes
+func loadNotes(c *atrest.Cipher, path string) (map[types.Hash]*Note, error) {
+	notes := make(map[types.Hash]*Note)
+
+	data, err := c.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return notes, nil
+		}
+		return nil, err
+	}
+
+	var list []*Note
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	for _, n := range list {
+		notes[n.Commitment] = n
+	}
+	return notes, nil
+}
+
+// saveNotesLocked atomically writes the note file
+func (w *Wallet) saveNotesLocked() error {
+	list := make([]*Note, 0, len(w.notes))
+	for _, n := range w.notes {
+		list = append(list, n)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Position < list[j].Position })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := notesPath(w.config.DataDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return w.config.AtRest.Data().WriteFile(path, data, 0600)
+}
+
+// AddNote records a note received by one of the wallet's shielded addresses
+func (w *Wallet) AddNote(note *Note) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !containsAddress(w.ks.Shielded, note.Address) {
+		return ErrUnknownAddress
+	}
+	if _, exists := w.notes[note.Commitment]; exists {
+		return nil
+	}
+
+	n := *note
+	w.notes[n.Commitment] = &n
+	w.fillNullifiersLocked()
+	return w.saveNotesLocked()
+}
+
+// TrialDecrypt tries the wallet's viewing keys on an output and returns
+// the note it opens if it was sent to one of the wallet's addresses. The
+// note's position is left for the caller to fill in.
+func (w *Wallet) TrialDecrypt(c types.Commitment) (*Note, bool) {
+	if len(c.EncryptedNote) == 0 {
+		return nil, false
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	for i, s := range w.ks.ShieldedViewingKey {
+		vk, err := hex.DecodeString(s)
+		if err != nil {
+			continue
+		}
+		pt, err := zkp.DecryptNote(vk, c.EncryptedNote)
+		if err != nil {
+			continue
+		}
+
+		// A note opening to another commitment or address is not ours
+		addr := parseAddress(w.ks.Shielded[i])
+		if pt.Address != addr || zkp.NoteCommitment(pt.Value, pt.Address, pt.Blinder) != c.Value {
+			continue
+		}
+		return &Note{
+			Commitment: c.Value,
+			Value:      pt.Value,
+			Address:    addr,
+			Blinder:    pt.Blinder,
+			Memo:       pt.Memo,
+		}, true
+	}
+	return nil, false
+}
+
+// MarkNullifiersSpent marks the notes revealing any of the nullifiers as
+// spent and returns their commitments
+func (w *Wallet) MarkNullifiersSpent(nullifiers []types.Hash) ([]types.Hash, error) {
+	if len(nullifiers) == 0 {
+		return nil, nil
+	}
+	revealed := make(map[types.Hash]bool, len(nullifiers))
+	for _, nf := range nullifiers {
+		revealed[nf] = true
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var spent []types.Hash
+	for _, n := range w.notes {
+		if !n.Spent && n.Nullifier != (types.Hash{}) && revealed[n.Nullifier] {
+			n.Spent = true
+			delete(w.reserved, n.Commitment)
+			spent = append(spent, n.Commitment)
+		}
+	}
+	if len(spent) == 0 {
+		return nil, nil
+	}
+	return spent, w.saveNotesLocked()
+}
+
+// RestoreNullifiers returns the notes revealing any of the nullifiers to
+// the spendable set, for a spend dropped before confirmation, and returns
+// their commitments
+func (w *Wallet) RestoreNullifiers(nullifiers []types.Hash) ([]types.Hash, error) {
+	if len(nullifiers) == 0 {
+		return nil, nil
+	}
+	dropped := make(map[types.Hash]bool, len(nullifiers))
+	for _, nf := range nullifiers {
+		dropped[nf] = true
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var restored []types.Hash
+	for _, n := range w.notes {
+		if n.Spent && n.Nullifier != (types.Hash{}) && dropped[n.Nullifier] {
+			n.Spent = false
+			restored = append(restored, n.Commitment)
+		}
+	}
+	if len(restored) == 0 {
+		return nil, nil
+	}
+	return restored, w.saveNotesLocked()
+}
+
+// fillNullifiersLocked derives missing note nullifiers while unlocked and
+// reports whether any were added
+func (w *Wallet) fillNullifiersLocked() bool {
+	if w.seed == nil {
+		return false
+	}
+
+	filled := false
+	for _, n := range w.notes {
+		if n.Nullifier != (types.Hash{}) {
+			continue
+		}
+		k, exists := w.shielded[n.Address]
+		if !exists {
+			continue
+		}
+		n.Nullifier = zkp.DeriveNullifier(k.SpendingKey[:], n.Commitment, n.Position)
+		filled = true
+	}
+	return filled
+}
+
+// Notes returns the wallet's unspent notes ordered by tree position
+func (w *Wallet) Notes() []*Note {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	out := make([]*Note, 0, len(w.notes))
+	for _, n := range w.notes {
+		if !n.Spent && !w.reserved[n.Commitment] {
+			c := *n
+			out = append(out, &c)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Position < out[j].Position })
+	return out
+}
+
+// SelectNotes picks unspent notes covering target, largest first, and
+// reserves them until MarkSpent or Release. It returns the change left
+// over after target.
+func (w *Wallet) SelectNotes(target uint64) ([]*Note, uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	candidates := make([]*Note, 0, len(w.notes))
+	for _, n := range w.notes {
+		if !n.Spent && !w.reserved[n.Commitment] {
+			candidates = append(candidates, n)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Value != candidates[j].Value {
+			return candidates[i].Value > candidates[j].Value
+		}
+		return candidates[i].Position < candidates[j].Position
+	})
+
+	var selected []*Note
+	var total uint64
+	for _, n := range candidates {
+		if total >= target && len(selected) > 0 {
+			break
+		}
+		selected = append(selected, n)
+		total += n.Value
+	}
+	if total < target || len(selected) == 0 {
+		return nil, 0, ErrInsufficientFunds
+	}
+
+	out := make([]*Note, len(selected))
+	for i, n := range selected {
+		w.reserved[n.Commitment] = true
+		c := *n
+		out[i] = &c
+	}
+	return out, total - target, nil
+}
+
+// Release returns reserved notes to the spendable set
+func (w *Wallet) Release(commitments ...types.Hash) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, c := range commitments {
+		delete(w.reserved, c)
+	}
+}
+
+// MarkSpent marks notes as spent once their spending transaction is accepted
+func (w *Wallet) MarkSpent(commitments ...types.Hash) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, c := range commitments {
+		n, exists := w.notes[c]
+		if !exists {
+			return ErrUnknownNote
+		}
+		n.Spent = true
+		delete(w.reserved, c)
+	}
+	return w.saveNotesLocked()
+}
+
+// containsAddress reports whether a hex address list contains addr
+func containsAddress(list []string, addr types.Address) bool {
+	for _, s := range list {
+		if parseAddress(s) == addr {
+			return true
+		}
+	}
+	return false
+}
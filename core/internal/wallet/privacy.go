@@ -0,0 +1,113 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// ErrNoOutputSlots is returned when a send has no room for change
+var ErrNoOutputSlots = errors.New("no output slots left for change")
+
+// PrivacyOptions harden a single send against amount and timing analysis.
+// The zero value sends as usual.
+type PrivacyOptions struct {
+	// MaxBroadcastDelay holds the transaction back for a random time up
+	// to this before it is gossiped, decorrelating it from the request
+	MaxBroadcastDelay time.Duration
+
+	// DecoyOutputs splits change across every output slot the payment
+	// leaves free, so change cannot be told apart by count or size
+	DecoyOutputs bool
+
+	// RoundChange rounds change down to a multiple of this, paying the
+	// remainder as fee, so change notes fall on uniform values
+	RoundChange uint64
+
+	// AvoidSyncPeer hands the transaction to a random peer other than
+	// the one the node syncs from, instead of gossiping it directly
+	AvoidSyncPeer bool
+}
+
+// BroadcastDelay draws a random delay up to MaxBroadcastDelay
+func (o *PrivacyOptions) BroadcastDelay() (time.Duration, error) {
+	if o == nil || o.MaxBroadcastDelay <= 0 {
+		return 0, nil
+	}
+	d, err := randomUint64(uint64(o.MaxBroadcastDelay))
+	return time.Duration(d), err
+}
+
+// ChangeOutputs returns the change note values and the fee to pay, given
+// the change left after the payments and the output slots free for it
+func (o *PrivacyOptions) ChangeOutputs(change, fee uint64, slots int) ([]uint64, uint64, error) {
+	if o != nil && o.RoundChange > 1 {
+		remainder := change % o.RoundChange
+		change -= remainder
+		fee += remainder
+	}
+
+	if o == nil || !o.DecoyOutputs {
+		if change == 0 {
+			return nil, fee, nil
+		}
+		if slots < 1 {
+			return nil, 0, ErrNoOutputSlots
+		}
+		return []uint64{change}, fee, nil
+	}
+
+	if slots < 1 {
+		if change == 0 {
+			return nil, fee, nil
+		}
+		return nil, 0, ErrNoOutputSlots
+	}
+	parts, err := splitValue(change, slots, o.RoundChange)
+	if err != nil {
+		return nil, 0, err
+	}
+	return parts, fee, nil
+}
+
+// splitValue splits v into n random parts at random cut points, each a
+// multiple of unit when unit divides v. Parts may be zero.
+func splitValue(v uint64, n int, unit uint64) ([]uint64, error) {
+	if unit < 1 || v%unit != 0 {
+		unit = 1
+	}
+	units := v / unit
+
+	cuts := make([]uint64, n-1)
+	for i := range cuts {
+		cut, err := randomUint64(units + 1)
+		if err != nil {
+			return nil, err
+		}
+		cuts[i] = cut
+	}
+	sort.Slice(cuts, func(i, j int) bool { return cuts[i] < cuts[j] })
+
+	parts := make([]uint64, n)
+	var prev uint64
+	for i, cut := range cuts {
+		parts[i] = (cut - prev) * unit
+		prev = cut
+	}
+	parts[n-1] = (units - prev) * unit
+	return parts, nil
+}
+
+// randomUint64 returns a uniform value in [0, n)
+func randomUint64(n uint64) (uint64, error) {
+	if n == 0 {
+		return 0, nil
+	}
+	v, err := rand.Int(rand.Reader, new(big.Int).SetUint64(n))
+	if err != nil {
+		return 0, err
+	}
+	return v.Uint64(), nil
+}
@@ -0,0 +1,121 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// CommitmentLocator finds a commitment's position in the commitment tree
+type CommitmentLocator interface {
+	// CommitmentPosition returns the position, or zkp.ErrLeafNotFound
+	CommitmentPosition(ctx context.Context, commitment types.Hash) (uint64, error)
+}
+
+// ScanResult is the wallet activity found in one block
+type ScanResult struct {
+	// Received are notes added to the wallet
+	Received []*Note
+
+	// Spent are the commitments of wallet notes spent by the block
+	Spent []types.Hash
+}
+
+// Scanner discovers wallet notes in new blocks by trial-decrypting every
+// output with the wallet's viewing keys, and marks notes spent when a
+// block reveals their nullifiers
+type Scanner struct {
+	mu sync.Mutex
+
+	wallet  *Wallet
+	locator CommitmentLocator
+
+	// Notes found before their commitment reached the tree; they have no
+	// position yet and cannot be spent
+	pending []*Note
+
+	height uint64
+}
+
+// NewScanner creates a scanner for a wallet
+func NewScanner(w *Wallet, locator CommitmentLocator) *Scanner {
+	return &Scanner{
+		wallet:  w,
+		locator: locator,
+	}
+}
+
+// ScanBlock scans a block's transactions for the wallet
+func (s *Scanner) ScanBlock(ctx context.Context, block *types.Block) (*ScanResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var nullifiers []types.Hash
+	for _, tx := range block.Transactions {
+		nullifiers = append(nullifiers, tx.Nullifiers...)
+		for _, c := range tx.Commitments {
+			if note, ok := s.wallet.TrialDecrypt(c); ok {
+				note.Height = block.Header.Height
+				s.pending = append(s.pending, note)
+			}
+		}
+	}
+
+	result := &ScanResult{}
+	received, err := s.resolvePendingLocked(ctx)
+	result.Received = received
+	if err != nil {
+		return result, err
+	}
+	if result.Spent, err = s.wallet.MarkNullifiersSpent(nullifiers); err != nil {
+		return result, err
+	}
+
+	if block.Header.Height > s.height {
+		s.height = block.Header.Height
+	}
+	return result, nil
+}
+
+// resolvePendingLocked adds pending notes whose commitments are now in
+// the tree to the wallet
+func (s *Scanner) resolvePendingLocked(ctx context.Context) ([]*Note, error) {
+	var received []*Note
+	remaining := s.pending[:0]
+	for i, note := range s.pending {
+		position, err := s.locator.CommitmentPosition(ctx, note.Commitment)
+		if errors.Is(err, zkp.ErrLeafNotFound) {
+			remaining = append(remaining, note)
+			continue
+		}
+		if err == nil {
+			note.Position = position
+			err = s.wallet.AddNote(note)
+		}
+		if err != nil {
+			s.pending = append(remaining, s.pending[i:]...)
+			return received, err
+		}
+		received = append(received, note)
+	}
+	s.pending = remaining
+	return received, nil
+}
+
+// Pending returns the number of notes waiting for their commitment to
+// reach the tree
+func (s *Scanner) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+// Height returns the highest block height scanned
+func (s *Scanner) Height() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.height
+}
@@ -0,0 +1,453 @@
+// Package wallet implements the node wallet: BIP-39 seeds, key derivation and an encrypted keystore.
+package wallet
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"sync"
+
+	"github.com/ccoin/core/internal/atrest"
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Wallet errors
+var (
+	ErrLocked         = errors.New("wallet is locked")
+	ErrUnknownAddress = errors.New("address not in wallet")
+	ErrEmptyPassword  = errors.New("password must not be empty")
+)
+
+// Config holds wallet configuration
+type Config struct {
+	// DataDir is the node data directory; the keystore lives in DataDir/wallet
+	DataDir string
+
+	// Account is the derivation account index
+	Account uint32
+
+	// MnemonicBits is the entropy size for new wallets
+	MnemonicBits int
+
+	// Language is the wordlist of new wallets' mnemonics; restored
+	// mnemonics are accepted in any known language
+	Language string
+
+	// Keystore KDF parameters
+	ScryptN int
+	ScryptR int
+	ScryptP int
+
+	// AtRest encrypts the keystore, and in full mode the note file, at
+	// rest on top of the keystore's own password encryption; nil writes
+	// them as they are
+	AtRest *atrest.Cipher
+}
+
+// DefaultConfig returns default wallet configuration
+func DefaultConfig() *Config {
+	return &Config{
+		DataDir:      "./data",
+		MnemonicBits: Entropy256,
+		Language:     LanguageEnglish,
+		ScryptN:      1 << 18,
+		ScryptR:      8,
+		ScryptP:      1,
+	}
+}
+
+// Balance is the wallet balance in base units
+type Balance struct {
+	Confirmed uint64
+	Pending   uint64
+	Shielded  uint64
+}
+
+// Wallet manages keys derived from a single BIP-39 seed
+type Wallet struct {
+	mu sync.RWMutex
+
+	config *Config
+	path   string
+	ks     *keystoreFile
+
+	// Unlocked state (nil when locked)
+	seed        []byte
+	transparent map[types.Address]*TransparentKey
+	shielded    map[types.Address]*ShieldedKey
+
+	// Shielded notes and those reserved by an in-flight send
+	notes    map[types.Hash]*Note
+	reserved map[types.Hash]bool
+}
+
+// New creates a wallet with a fresh mnemonic, encrypts it under password
+// and writes the keystore. The mnemonic is returned once for backup.
+func New(cfg *Config, password, passphrase string) (*Wallet, string, error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+
+	wl, err := LookupWordlist(cfg.Language)
+	if err != nil {
+		return nil, "", err
+	}
+	mnemonic, err := wl.NewMnemonic(cfg.MnemonicBits)
+	if err != nil {
+		return nil, "", err
+	}
+
+	w, err := Restore(cfg, mnemonic, password, passphrase)
+	if err != nil {
+		return nil, "", err
+	}
+	return w, mnemonic, nil
+}
+
+// Restore creates a wallet from an existing mnemonic. passphrase is the
+// optional BIP-39 passphrase; a different one derives a different wallet.
+func Restore(cfg *Config, mnemonic, password, passphrase string) (*Wallet, error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	if password == "" {
+		return nil, ErrEmptyPassword
+	}
+	if _, err := MnemonicToEntropy(mnemonic); err != nil {
+		return nil, err
+	}
+
+	path := keystorePath(cfg.DataDir)
+	if Exists(cfg.DataDir) {
+		return nil, ErrKeystoreExists
+	}
+
+	sealed, err := sealSecret(&secret{Mnemonic: mnemonic, Passphrase: passphrase}, password, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Wallet{
+		config: cfg,
+		path:   path,
+		ks: &keystoreFile{
+			Version: KeystoreVersion,
+			Crypto:  sealed,
+			Account: cfg.Account,
+		},
+		notes:    make(map[types.Hash]*Note),
+		reserved: make(map[types.Hash]bool),
+	}
+	w.unlockWithSeed(MnemonicToSeed(mnemonic, passphrase))
+
+	// Every wallet starts with one address of each kind
+	if _, err := w.newTransparentLocked(); err != nil {
+		return nil, err
+	}
+	if _, err := w.newShieldedLocked(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Open loads an existing keystore in the locked state
+func Open(cfg *Config) (*Wallet, error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+
+	path := keystorePath(cfg.DataDir)
+	ks, err := loadKeystore(cfg.AtRest.Keys(), path)
+	if err != nil {
+		return nil, err
+	}
+
+	notes, err := loadNotes(cfg.AtRest.Data(), notesPath(cfg.DataDir))
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Wallet{
+		config:   cfg,
+		path:     path,
+		ks:       ks,
+		notes:    notes,
+		reserved: make(map[types.Hash]bool),
+	}
+
+	// Files written before encryption at rest was turned on are
+	// encrypted now rather than on their next change
+	if cfg.AtRest != nil {
+		if err := saveKeystore(cfg.AtRest.Keys(), path, ks); err != nil {
+			return nil, err
+		}
+		if err := w.saveNotesLocked(); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Exists reports whether a keystore is present in the data directory,
+// including one encrypted at rest
+func Exists(dataDir string) bool {
+	_, err := loadKeystore(nil, keystorePath(dataDir))
+	return err == nil || errors.Is(err, atrest.ErrEncrypted)
+}
+
+// Unlock decrypts the keystore and derives all known keys
+func (w *Wallet) Unlock(password string) error {
+	s, err := openSecret(&w.ks.Crypto, password)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.unlockWithSeed(MnemonicToSeed(s.Mnemonic, s.Passphrase))
+
+	// Keystores written before note scanning lack viewing keys, and notes
+	// found while locked lack nullifiers
+	if len(w.ks.ShieldedViewingKey) < len(w.ks.Shielded) {
+		for i := len(w.ks.ShieldedViewingKey); i < len(w.ks.Shielded); i++ {
+			k := w.shielded[parseAddress(w.ks.Shielded[i])]
+			w.ks.ShieldedViewingKey = append(w.ks.ShieldedViewingKey, hex.EncodeToString(k.ViewingKey[:]))
+		}
+		if err := saveKeystore(w.config.AtRest.Keys(), w.path, w.ks); err != nil {
+			return err
+		}
+	}
+	if w.fillNullifiersLocked() {
+		return w.saveNotesLocked()
+	}
+	return nil
+}
+
+// Lock wipes the decrypted seed and keys from memory
+func (w *Wallet) Lock() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i := range w.seed {
+		w.seed[i] = 0
+	}
+	w.seed = nil
+	w.transparent = nil
+	w.shielded = nil
+}
+
+// IsLocked reports whether the wallet is locked
+func (w *Wallet) IsLocked() bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.seed == nil
+}
+
+// unlockWithSeed installs the seed and re-derives the stored addresses
+func (w *Wallet) unlockWithSeed(seed []byte) {
+	w.seed = seed
+	w.transparent = make(map[types.Address]*TransparentKey)
+	w.shielded = make(map[types.Address]*ShieldedKey)
+
+	for i := range w.ks.Transparent {
+		k := deriveTransparentKey(seed, w.ks.Account, uint32(i))
+		w.transparent[k.Address] = k
+	}
+	for i := range w.ks.Shielded {
+		k := deriveShieldedKey(seed, w.ks.Account, uint32(i))
+		w.shielded[k.Address] = k
+	}
+}
+
+// NewAddress derives and persists the next transparent address
+func (w *Wallet) NewAddress() (types.Address, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.newTransparentLocked()
+}
+
+// NewShieldedAddress derives and persists the next shielded address
+func (w *Wallet) NewShieldedAddress() (types.Address, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.newShieldedLocked()
+}
+
+func (w *Wallet) newTransparentLocked() (types.Address, error) {
+	if w.seed == nil {
+		return types.Address{}, ErrLocked
+	}
+
+	k := deriveTransparentKey(w.seed, w.ks.Account, uint32(len(w.ks.Transparent)))
+	w.transparent[k.Address] = k
+	w.ks.Transparent = append(w.ks.Transparent, hex.EncodeToString(k.Address[:]))
+	w.ks.TransparentPubKey = append(w.ks.TransparentPubKey, hex.EncodeToString(k.PublicKey))
+
+	return k.Address, saveKeystore(w.config.AtRest.Keys(), w.path, w.ks)
+}
+
+func (w *Wallet) newShieldedLocked() (types.Address, error) {
+	if w.seed == nil {
+		return types.Address{}, ErrLocked
+	}
+
+	k := deriveShieldedKey(w.seed, w.ks.Account, uint32(len(w.ks.Shielded)))
+	w.shielded[k.Address] = k
+	w.ks.Shielded = append(w.ks.Shielded, hex.EncodeToString(k.Address[:]))
+	w.ks.ShieldedViewingKey = append(w.ks.ShieldedViewingKey, hex.EncodeToString(k.ViewingKey[:]))
+
+	return k.Address, saveKeystore(w.config.AtRest.Keys(), w.path, w.ks)
+}
+
+// Address returns the primary transparent address (available while locked)
+func (w *Wallet) Address() types.Address {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return parseAddress(w.ks.Transparent[0])
+}
+
+// Addresses returns all transparent addresses
+func (w *Wallet) Addresses() []types.Address {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return parseAddresses(w.ks.Transparent)
+}
+
+// ShieldedAddress returns the primary shielded address (available while locked)
+func (w *Wallet) ShieldedAddress() types.Address {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return parseAddress(w.ks.Shielded[0])
+}
+
+// ShieldedAddresses returns all shielded addresses
+func (w *Wallet) ShieldedAddresses() []types.Address {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return parseAddresses(w.ks.Shielded)
+}
+
+// TransmissionKey returns the key senders encrypt notes to for a shielded
+// address (available while locked)
+func (w *Wallet) TransmissionKey(addr types.Address) ([]byte, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	for i, a := range w.ks.Shielded {
+		if parseAddress(a) != addr {
+			continue
+		}
+		if i >= len(w.ks.ShieldedViewingKey) {
+			return nil, ErrLocked
+		}
+		vk, err := hex.DecodeString(w.ks.ShieldedViewingKey[i])
+		if err != nil {
+			return nil, err
+		}
+		return zkp.TransmissionKey(vk)
+	}
+	return nil, ErrUnknownAddress
+}
+
+// PublicKey returns the public key for a transparent address
+func (w *Wallet) PublicKey(addr types.Address) (ed25519.PublicKey, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	for i, a := range w.ks.Transparent {
+		if parseAddress(a) == addr {
+			return hex.DecodeString(w.ks.TransparentPubKey[i])
+		}
+	}
+	return nil, ErrUnknownAddress
+}
+
+// Sign signs data with the key for a transparent address
+func (w *Wallet) Sign(addr types.Address, data []byte) (types.Signature, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.seed == nil {
+		return types.Signature{}, ErrLocked
+	}
+	k, exists := w.transparent[addr]
+	if !exists {
+		return types.Signature{}, ErrUnknownAddress
+	}
+
+	return signWith(k.PrivateKey, data), nil
+}
+
+// SigningKey returns the private key for a transparent address, for
+// miners that prove VRF outputs with their identity key
+func (w *Wallet) SigningKey(addr types.Address) (ed25519.PrivateKey, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.seed == nil {
+		return nil, ErrLocked
+	}
+	k, exists := w.transparent[addr]
+	if !exists {
+		return nil, ErrUnknownAddress
+	}
+
+	return append(ed25519.PrivateKey(nil), k.PrivateKey...), nil
+}
+
+// SpendingKey returns the spending key for a shielded address
+func (w *Wallet) SpendingKey(addr types.Address) ([]byte, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.seed == nil {
+		return nil, ErrLocked
+	}
+	k, exists := w.shielded[addr]
+	if !exists {
+		return nil, ErrUnknownAddress
+	}
+
+	out := make([]byte, len(k.SpendingKey))
+	copy(out, k.SpendingKey[:])
+	return out, nil
+}
+
+// Balance returns the wallet balance. Shielded is the sum of unspent notes;
+// notes reserved by an in-flight send count as pending.
+func (w *Wallet) Balance(ctx context.Context) (*Balance, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	bal := &Balance{}
+	for _, n := range w.notes {
+		switch {
+		case n.Spent:
+		case w.reserved[n.Commitment]:
+			bal.Pending += n.Value
+		default:
+			bal.Shielded += n.Value
+		}
+	}
+	return bal, nil
+}
+
+// parseAddress decodes a hex address stored in the keystore
+func parseAddress(s string) types.Address {
+	var addr types.Address
+	b, _ := hex.DecodeString(s)
+	copy(addr[:], b)
+	return addr
+}
+
+// parseAddresses decodes a list of hex addresses
+func parseAddresses(list []string) []types.Address {
+	out := make([]types.Address, len(list))
+	for i, s := range list {
+		out[i] = parseAddress(s)
+	}
+	return out
+}
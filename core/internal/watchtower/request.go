@@ -0,0 +1,121 @@
+package watchtower
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/ccoin/core/pkg/common"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Kind is what a subscription watches
+type Kind string
+
+const (
+	// KindMiner watches a miner address for slashing evidence and
+	// slashings, for its delegators or the miner itself
+	KindMiner Kind = "miner"
+
+	// KindModel watches an AI Commons model ID for deprecation
+	KindModel Kind = "model"
+
+	// KindLicense watches a license ID for revocation, for its licensee
+	KindLicense Kind = "license"
+)
+
+// Request actions
+const (
+	ActionSubscribe   = "subscribe"
+	ActionUnsubscribe = "unsubscribe"
+)
+
+// Request asks the watchtower to start or stop watching a target for
+// the holder of PublicKey. Nonce must exceed the last nonce the
+// watchtower accepted from the same key, so a request cannot be
+// replayed; clients use the current time in nanoseconds.
+type Request struct {
+	Action  string `json:"action"`
+	Kind    Kind   `json:"kind"`
+	Target  string `json:"target"`
+	Webhook string `json:"webhook"`
+	Nonce   uint64 `json:"nonce"`
+
+	PublicKey []byte `json:"public_key"`
+	Signature []byte `json:"signature"`
+}
+
+// signingHash returns the hash the request's signature covers
+func (r *Request) signingHash() []byte {
+	payload, _ := json.Marshal(struct {
+		Action    string `json:"action"`
+		Kind      Kind   `json:"kind"`
+		Target    string `json:"target"`
+		Webhook   string `json:"webhook"`
+		Nonce     uint64 `json:"nonce"`
+		PublicKey []byte `json:"public_key"`
+	}{r.Action, r.Kind, r.Target, r.Webhook, r.Nonce, r.PublicKey})
+	h := sha256.Sum256(append([]byte("CCOIN_WATCHTOWER"), payload...))
+	return h[:]
+}
+
+// Sign signs the request with the subscriber's key
+func (r *Request) Sign(key ed25519.PrivateKey) {
+	r.PublicKey = key.Public().(ed25519.PublicKey)
+	r.Signature = ed25519.Sign(key, r.signingHash())
+}
+
+// Verify checks the request's signature against its public key
+func (r *Request) Verify() error {
+	if len(r.PublicKey) != ed25519.PublicKeySize || !ed25519.Verify(r.PublicKey, r.signingHash(), r.Signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Subscriber returns the address of the key that signed the request
+func (r *Request) Subscriber() types.Address {
+	return types.AddressFromPublicKey(r.PublicKey)
+}
+
+// validate checks the request's fields and returns its canonical target
+func (r *Request) validate() (string, error) {
+	if r.Action != ActionSubscribe && r.Action != ActionUnsubscribe {
+		return "", fmt.Errorf("%w: unknown action %q", ErrInvalidRequest, r.Action)
+	}
+
+	size := types.HashSize
+	switch r.Kind {
+	case KindMiner:
+		size = types.AddressSize
+	case KindModel, KindLicense:
+	default:
+		return "", fmt.Errorf("%w: unknown kind %q", ErrInvalidRequest, r.Kind)
+	}
+	b, err := common.HexToBytes(r.Target)
+	if err != nil || len(b) != size {
+		return "", fmt.Errorf("%w: target must be a %d-byte hex %s", ErrInvalidRequest, size, r.Kind)
+	}
+
+	u, err := url.Parse(r.Webhook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("%w: webhook must be an http or https URL", ErrInvalidRequest)
+	}
+	return common.BytesToHex(b), nil
+}
+
+// subscriptionID identifies a subscriber's webhook for a target, so
+// subscribing twice replaces rather than duplicates
+func subscriptionID(subscriber types.Address, kind Kind, target, webhook string) string {
+	buf := make([]byte, 0, types.AddressSize+len(kind)+len(target)+len(webhook)+2)
+	buf = append(buf, subscriber[:]...)
+	buf = append(buf, kind...)
+	buf = append(buf, 0)
+	buf = append(buf, target...)
+	buf = append(buf, 0)
+	buf = append(buf, webhook...)
+	h := sha256.Sum256(buf)
+	return common.BytesToHex(h[:16])
+}
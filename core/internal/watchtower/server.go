@@ -0,0 +1,230 @@
+package watchtower
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SubscriptionsPath is the HTTP path signed requests are posted to
+const SubscriptionsPath = "/subscriptions"
+
+// delivery is an alert on its way to a webhook
+type delivery struct {
+	webhook string
+	alert   *Alert
+}
+
+// errorBody is the JSON body of a failed request
+type errorBody struct {
+	Error      string `json:"error"`
+	RetryAfter int64  `json:"retry_after,omitempty"`
+}
+
+// Start starts delivering alerts and, if a listen address is set, the
+// HTTP server taking subscription requests
+func (w *Watchtower) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	w.mu.Lock()
+	w.cancel = cancel
+	w.mu.Unlock()
+	for i := 0; i < w.config.Workers; i++ {
+		w.wg.Add(1)
+		go w.deliverLoop(ctx)
+	}
+
+	if w.config.ListenAddr == "" {
+		return nil
+	}
+	lis, err := net.Listen("tcp", w.config.ListenAddr)
+	if err != nil {
+		w.Stop()
+		return fmt.Errorf("failed to listen on %s: %w", w.config.ListenAddr, err)
+	}
+
+	w.mu.Lock()
+	w.server = &http.Server{Handler: w.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	server := w.server
+	w.mu.Unlock()
+
+	go func() {
+		if err := server.Serve(lis); err != nil && err != http.ErrServerClosed {
+			w.log.Error("watchtower server failed", "err", err)
+		}
+	}()
+	return nil
+}
+
+// Stop stops the HTTP server and alert delivery. Alerts still queued
+// are dropped.
+func (w *Watchtower) Stop() {
+	w.mu.Lock()
+	server, cancel := w.server, w.cancel
+	w.server, w.cancel = nil, nil
+	w.mu.Unlock()
+
+	if server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}
+	if cancel != nil {
+		cancel()
+		w.wg.Wait()
+	}
+}
+
+// deliverLoop posts queued alerts until ctx is done
+func (w *Watchtower) deliverLoop(ctx context.Context) {
+	defer w.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d := <-w.queue:
+			if err := w.deliver(ctx, d); err != nil && ctx.Err() == nil {
+				w.log.Warn("webhook delivery failed", "subscription", d.alert.Subscription, "event", d.alert.Event, "err", err)
+			}
+		}
+	}
+}
+
+// deliver posts an alert to its webhook, retrying with backoff until it
+// is accepted or the attempts run out. Client errors other than 429 are
+// not retried.
+func (w *Watchtower) deliver(ctx context.Context, d *delivery) error {
+	body, err := json.Marshal(d.alert)
+	if err != nil {
+		return err
+	}
+
+	delay := w.config.RetryDelay
+	for attempt := 1; ; attempt++ {
+		retry, err := w.post(ctx, d.webhook, body)
+		if err == nil || !retry || attempt >= w.config.DeliveryAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// post makes one delivery attempt, reporting whether a failure may be
+// retried
+func (w *Watchtower) post(ctx context.Context, webhook string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("webhook returned %s", resp.Status)
+}
+
+// Handler returns the watchtower HTTP handler
+func (w *Watchtower) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(SubscriptionsPath, w.handleRequest)
+	return mux
+}
+
+// handleRequest carries out the signed request in the JSON body
+func (w *Watchtower) handleRequest(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		writeJSON(rw, http.StatusMethodNotAllowed, &errorBody{Error: "method not allowed"})
+		return
+	}
+
+	var req Request
+	if err := json.NewDecoder(http.MaxBytesReader(rw, r.Body, 8192)).Decode(&req); err != nil {
+		writeJSON(rw, http.StatusBadRequest, &errorBody{Error: "invalid request body"})
+		return
+	}
+
+	sub, err := w.Handle(r.Context(), &req)
+	var limited *RateLimitError
+	switch {
+	case err == nil:
+		writeJSON(rw, http.StatusOK, sub)
+	case errors.As(err, &limited):
+		seconds := int64(limited.RetryAfter.Round(time.Second) / time.Second)
+		rw.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+		writeJSON(rw, http.StatusTooManyRequests, &errorBody{Error: ErrRateLimited.Error(), RetryAfter: seconds})
+	case errors.Is(err, ErrInvalidRequest), errors.Is(err, ErrInvalidSignature), errors.Is(err, ErrStaleNonce):
+		writeJSON(rw, http.StatusBadRequest, &errorBody{Error: err.Error()})
+	case errors.Is(err, ErrNotAuthorized):
+		writeJSON(rw, http.StatusForbidden, &errorBody{Error: err.Error()})
+	case errors.Is(err, ErrNotSubscribed):
+		writeJSON(rw, http.StatusNotFound, &errorBody{Error: err.Error()})
+	case errors.Is(err, ErrTooManySubscriptions):
+		writeJSON(rw, http.StatusConflict, &errorBody{Error: err.Error()})
+	default:
+		w.log.Error("watchtower request failed", "err", err)
+		writeJSON(rw, http.StatusInternalServerError, &errorBody{Error: "watchtower unavailable"})
+	}
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Submit posts a signed request to the watchtower at baseURL
+func Submit(ctx context.Context, baseURL string, req *Request) (*Subscription, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(baseURL, "/")+SubscriptionsPath, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var body errorBody
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Error == "" {
+			return nil, fmt.Errorf("watchtower returned %s", resp.Status)
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, &RateLimitError{RetryAfter: time.Duration(body.RetryAfter) * time.Second}
+		}
+		return nil, errors.New(body.Error)
+	}
+
+	var sub Subscription
+	if err := json.NewDecoder(resp.Body).Decode(&sub); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
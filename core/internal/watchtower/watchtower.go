@@ -0,0 +1,530 @@
+// Package watchtower watches the chain on behalf of registered clients
+// and alerts them by webhook: delegators watching their miner for
+// slashable behavior, and licensees watching for model deprecation or
+// license revocation. Clients subscribe with requests signed by their
+// key, and both requests and alerts are rate limited.
+package watchtower
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ccoin/core/internal/logging"
+	"github.com/ccoin/core/internal/reputation"
+	"github.com/ccoin/core/pkg/common"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Watchtower errors
+var (
+	ErrInvalidRequest       = errors.New("invalid watchtower request")
+	ErrInvalidSignature     = errors.New("watchtower request signature is invalid")
+	ErrStaleNonce           = errors.New("request nonce not above the subscriber's previous request")
+	ErrNotAuthorized        = errors.New("subscriber may not watch this target")
+	ErrNotSubscribed        = errors.New("no such subscription")
+	ErrTooManySubscriptions = errors.New("subscription limit reached")
+	ErrRateLimited          = errors.New("watchtower request rate limited")
+)
+
+// Alert events
+const (
+	// EventEvidence is slashing evidence submitted against a miner
+	EventEvidence = "slashing_evidence"
+
+	// EventSlashed is a miner, and its delegators, slashed for evidence
+	EventSlashed = "slashed"
+
+	// EventModelDeprecated is a model deprecated from the AI Commons
+	EventModelDeprecated = "model_deprecated"
+
+	// EventLicenseRevoked is a license revoked
+	EventLicenseRevoked = "license_revoked"
+)
+
+// Config holds watchtower configuration
+type Config struct {
+	// ListenAddr is the HTTP address subscription requests are taken on
+	// (empty serves none; Handler can be mounted elsewhere)
+	ListenAddr string
+
+	// Path is the file subscriptions persist to (empty keeps them in
+	// memory only)
+	Path string
+
+	// MaxSubscriptions bounds the subscriptions held in all, and
+	// MaxPerSubscriber those held by one key
+	MaxSubscriptions int
+	MaxPerSubscriber int
+
+	// RequestLimit is the number of requests a subscriber may make per
+	// RequestWindow
+	RequestLimit  int
+	RequestWindow time.Duration
+
+	// AlertLimit is the number of alerts a subscription is sent per
+	// AlertWindow; further alerts are dropped and counted in the next
+	AlertLimit  int
+	AlertWindow time.Duration
+
+	// Webhook delivery: attempts per alert, the delay before the first
+	// retry, doubling after each, and the timeout of each attempt
+	DeliveryAttempts int
+	RetryDelay       time.Duration
+	DeliveryTimeout  time.Duration
+
+	// QueueSize bounds the alerts waiting for delivery, and Workers is
+	// the number delivered at once
+	QueueSize int
+	Workers   int
+
+	// Logger receives the watchtower's logs; nil uses the watchtower
+	// module logger
+	Logger *slog.Logger
+}
+
+// DefaultConfig returns default watchtower configuration
+func DefaultConfig() *Config {
+	return &Config{
+		MaxSubscriptions: 100000,
+		MaxPerSubscriber: 32,
+		RequestLimit:     10,
+		RequestWindow:    time.Minute,
+		AlertLimit:       10,
+		AlertWindow:      time.Hour,
+		DeliveryAttempts: 5,
+		RetryDelay:       5 * time.Second,
+		DeliveryTimeout:  10 * time.Second,
+		QueueSize:        1024,
+		Workers:          4,
+	}
+}
+
+// DelegationBackend lists the delegations a subscriber has made, so
+// only a miner's delegators may watch it
+type DelegationBackend interface {
+	DelegationsBy(delegator types.Address) []*reputation.Delegation
+}
+
+// LicenseBackend looks up who holds a license, so only its licensee may
+// watch it
+type LicenseBackend interface {
+	Licensee(ctx context.Context, licenseID types.Hash) (types.Address, error)
+}
+
+// Subscription is a subscriber's webhook for a target
+type Subscription struct {
+	ID         string `json:"id"`
+	Subscriber string `json:"subscriber"`
+	Kind       Kind   `json:"kind"`
+	Target     string `json:"target"`
+	Webhook    string `json:"webhook"`
+	Created    int64  `json:"created"`
+}
+
+// Alert is the JSON body posted to a subscription's webhook
+type Alert struct {
+	Subscription string `json:"subscription"`
+	Kind         Kind   `json:"kind"`
+	Target       string `json:"target"`
+	Event        string `json:"event"`
+
+	// Offense, Height and Amount describe slashing events: the offense,
+	// the height it was committed at and, once slashed, the amount
+	// slashed from the miner and its delegators
+	Offense string `json:"offense,omitempty"`
+	Height  uint64 `json:"height,omitempty"`
+	Amount  uint64 `json:"amount,omitempty"`
+
+	Reason string `json:"reason,omitempty"`
+	Time   int64  `json:"time"`
+
+	// Suppressed counts the alerts dropped by the rate limit since the
+	// subscription's last alert
+	Suppressed int `json:"suppressed,omitempty"`
+}
+
+// RateLimitError reports when a rate-limited subscriber may ask again
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%v: retry in %s", ErrRateLimited, e.RetryAfter.Round(time.Second))
+}
+
+// Is makes RateLimitError match ErrRateLimited
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// window counts events in a fixed rate-limit window
+type window struct {
+	start      time.Time
+	count      int
+	suppressed int
+}
+
+// allow counts an event at now against limit per period, reporting
+// whether it is within the limit
+func (w *window) allow(now time.Time, limit int, period time.Duration) bool {
+	if now.Sub(w.start) >= period {
+		w.start, w.count = now, 0
+	}
+	if w.count >= limit {
+		return false
+	}
+	w.count++
+	return true
+}
+
+// subscriptionFile is the on-disk form of the subscriptions
+type subscriptionFile struct {
+	Subscriptions []*Subscription `json:"subscriptions"`
+
+	// Nonces holds the last request nonce accepted per subscriber
+	Nonces map[string]uint64 `json:"nonces,omitempty"`
+}
+
+// Watchtower holds subscriptions and alerts their webhooks of the
+// events it is notified of
+type Watchtower struct {
+	mu sync.Mutex
+
+	config *Config
+	log    *slog.Logger
+
+	// Subscriptions by ID and by kind and target
+	subs     map[string]*Subscription
+	byTarget map[string]map[string]*Subscription
+
+	// Last nonce and request window per subscriber, and alert window
+	// per subscription
+	nonces   map[string]uint64
+	requests map[string]*window
+	alerts   map[string]*window
+
+	delegations DelegationBackend
+	licenses    LicenseBackend
+
+	queue  chan *delivery
+	client *http.Client
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	server *http.Server
+
+	now func() time.Time
+}
+
+// NewWatchtower creates a watchtower, loading the subscriptions saved
+// at cfg.Path
+func NewWatchtower(cfg *Config) (*Watchtower, error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = logging.Module("watchtower")
+	}
+
+	w := &Watchtower{
+		config:   cfg,
+		log:      logger,
+		subs:     make(map[string]*Subscription),
+		byTarget: make(map[string]map[string]*Subscription),
+		nonces:   make(map[string]uint64),
+		requests: make(map[string]*window),
+		alerts:   make(map[string]*window),
+		queue:    make(chan *delivery, cfg.QueueSize),
+		client:   &http.Client{Timeout: cfg.DeliveryTimeout},
+		now:      time.Now,
+	}
+	if cfg.Path == "" {
+		return w, nil
+	}
+
+	data, err := os.ReadFile(cfg.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return w, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file subscriptionFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to read subscriptions %s: %w", cfg.Path, err)
+	}
+	for _, sub := range file.Subscriptions {
+		w.addLocked(sub)
+	}
+	for subscriber, nonce := range file.Nonces {
+		w.nonces[subscriber] = nonce
+	}
+	return w, nil
+}
+
+// SetDelegations restricts miner subscriptions to the miner and its
+// delegators. Without it any subscriber may watch any miner.
+func (w *Watchtower) SetDelegations(backend DelegationBackend) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.delegations = backend
+}
+
+// SetLicenses restricts license subscriptions to the licensee. Without
+// it any subscriber may watch any license.
+func (w *Watchtower) SetLicenses(backend LicenseBackend) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.licenses = backend
+}
+
+// Handle verifies and carries out a signed request, returning the
+// subscription made or removed
+func (w *Watchtower) Handle(ctx context.Context, req *Request) (*Subscription, error) {
+	target, err := req.validate()
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Verify(); err != nil {
+		return nil, err
+	}
+	subscriber := req.Subscriber()
+	if req.Action == ActionSubscribe {
+		if err := w.authorize(ctx, subscriber, req.Kind, target); err != nil {
+			return nil, err
+		}
+	}
+
+	key := common.BytesToHex(subscriber[:])
+	now := w.now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Replays are turned away before they count against the limit
+	if req.Nonce <= w.nonces[key] {
+		return nil, ErrStaleNonce
+	}
+	w.pruneLocked(now)
+	limit, ok := w.requests[key]
+	if !ok {
+		limit = &window{}
+		w.requests[key] = limit
+	}
+	if !limit.allow(now, w.config.RequestLimit, w.config.RequestWindow) {
+		return nil, &RateLimitError{RetryAfter: limit.start.Add(w.config.RequestWindow).Sub(now)}
+	}
+
+	id := subscriptionID(subscriber, req.Kind, target, req.Webhook)
+	sub, exists := w.subs[id]
+	switch {
+	case req.Action == ActionUnsubscribe && !exists:
+		return nil, ErrNotSubscribed
+	case req.Action == ActionUnsubscribe:
+		w.removeLocked(sub)
+	case !exists:
+		if len(w.subs) >= w.config.MaxSubscriptions || w.countLocked(key) >= w.config.MaxPerSubscriber {
+			return nil, ErrTooManySubscriptions
+		}
+		sub = &Subscription{
+			ID:         id,
+			Subscriber: key,
+			Kind:       req.Kind,
+			Target:     target,
+			Webhook:    req.Webhook,
+			Created:    now.Unix(),
+		}
+		w.addLocked(sub)
+	}
+	w.nonces[key] = req.Nonce
+
+	if err := w.saveLocked(); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// authorize checks that a subscriber may watch a target. Miners may be
+// watched by themselves and their delegators, licenses by their
+// licensee and models by anyone.
+func (w *Watchtower) authorize(ctx context.Context, subscriber types.Address, kind Kind, target string) error {
+	w.mu.Lock()
+	delegations, licenses := w.delegations, w.licenses
+	w.mu.Unlock()
+
+	b, _ := common.HexToBytes(target)
+	switch kind {
+	case KindMiner:
+		var miner types.Address
+		copy(miner[:], b)
+		if delegations == nil || subscriber == miner {
+			return nil
+		}
+		for _, d := range delegations.DelegationsBy(subscriber) {
+			if d.Miner == miner && d.Bonded+d.Unbonding > 0 {
+				return nil
+			}
+		}
+		return ErrNotAuthorized
+
+	case KindLicense:
+		if licenses == nil {
+			return nil
+		}
+		licensee, err := licenses.Licensee(ctx, types.HashFromBytes(b))
+		if err != nil || licensee != subscriber {
+			return ErrNotAuthorized
+		}
+	}
+	return nil
+}
+
+// Subscriptions returns the subscriptions held by a subscriber, or every
+// subscription for the zero address
+func (w *Watchtower) Subscriptions(subscriber types.Address) []*Subscription {
+	key := common.BytesToHex(subscriber[:])
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	subs := make([]*Subscription, 0)
+	for _, sub := range w.subs {
+		if subscriber == types.EmptyAddress || sub.Subscriber == key {
+			subs = append(subs, sub)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+	return subs
+}
+
+// NotifyEvidence alerts the watchers of a miner to slashing evidence
+// against it, and again once the evidence is processed. It has the
+// signature of reputation.SlashingManager's evidence handler.
+func (w *Watchtower) NotifyEvidence(evidence *reputation.SlashingEvidence) {
+	alert := Alert{
+		Event:   EventEvidence,
+		Offense: evidence.Type.String(),
+		Height:  evidence.BlockHeight,
+		Reason:  evidence.Description,
+	}
+	if evidence.Processed {
+		alert.Event = EventSlashed
+		alert.Amount = evidence.SlashAmount
+	}
+	w.notify(KindMiner, common.BytesToHex(evidence.MinerAddress[:]), alert)
+}
+
+// NotifyModelDeprecated alerts the watchers of a deprecated model
+func (w *Watchtower) NotifyModelDeprecated(modelID types.Hash, reason string) {
+	w.notify(KindModel, common.BytesToHex(modelID[:]), Alert{Event: EventModelDeprecated, Reason: reason})
+}
+
+// NotifyLicenseRevoked alerts the licensee watching a revoked license
+func (w *Watchtower) NotifyLicenseRevoked(licenseID types.Hash, reason string) {
+	w.notify(KindLicense, common.BytesToHex(licenseID[:]), Alert{Event: EventLicenseRevoked, Reason: reason})
+}
+
+// notify queues an alert to every subscription watching a target,
+// within each subscription's alert limit
+func (w *Watchtower) notify(kind Kind, target string, alert Alert) {
+	now := w.now()
+	alert.Kind, alert.Target, alert.Time = kind, target, now.Unix()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for id, sub := range w.byTarget[targetKey(kind, target)] {
+		limit, ok := w.alerts[id]
+		if !ok {
+			limit = &window{}
+			w.alerts[id] = limit
+		}
+		if !limit.allow(now, w.config.AlertLimit, w.config.AlertWindow) {
+			limit.suppressed++
+			continue
+		}
+
+		a := alert
+		a.Subscription, a.Suppressed = id, limit.suppressed
+		limit.suppressed = 0
+		select {
+		case w.queue <- &delivery{webhook: sub.Webhook, alert: &a}:
+		default:
+			w.log.Warn("alert queue full, dropping alert", "subscription", id, "event", a.Event)
+		}
+	}
+}
+
+// targetKey indexes subscriptions by what they watch
+func targetKey(kind Kind, target string) string {
+	return string(kind) + ":" + target
+}
+
+// addLocked indexes a subscription
+func (w *Watchtower) addLocked(sub *Subscription) {
+	w.subs[sub.ID] = sub
+	key := targetKey(sub.Kind, sub.Target)
+	if w.byTarget[key] == nil {
+		w.byTarget[key] = make(map[string]*Subscription)
+	}
+	w.byTarget[key][sub.ID] = sub
+}
+
+// removeLocked drops a subscription and its alert limit
+func (w *Watchtower) removeLocked(sub *Subscription) {
+	delete(w.subs, sub.ID)
+	delete(w.alerts, sub.ID)
+	key := targetKey(sub.Kind, sub.Target)
+	delete(w.byTarget[key], sub.ID)
+	if len(w.byTarget[key]) == 0 {
+		delete(w.byTarget, key)
+	}
+}
+
+// countLocked returns the number of subscriptions a subscriber holds
+func (w *Watchtower) countLocked(subscriber string) int {
+	n := 0
+	for _, sub := range w.subs {
+		if sub.Subscriber == subscriber {
+			n++
+		}
+	}
+	return n
+}
+
+// pruneLocked forgets request windows that have passed
+func (w *Watchtower) pruneLocked(now time.Time) {
+	for key, limit := range w.requests {
+		if now.Sub(limit.start) >= w.config.RequestWindow {
+			delete(w.requests, key)
+		}
+	}
+}
+
+// saveLocked writes the subscriptions to the subscription file
+func (w *Watchtower) saveLocked() error {
+	if w.config.Path == "" {
+		return nil
+	}
+
+	file := subscriptionFile{Nonces: w.nonces}
+	for _, sub := range w.subs {
+		file.Subscriptions = append(file.Subscriptions, sub)
+	}
+	sort.Slice(file.Subscriptions, func(i, j int) bool { return file.Subscriptions[i].ID < file.Subscriptions[j].ID })
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := w.config.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.config.Path)
+}
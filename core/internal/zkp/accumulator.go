@@ -0,0 +1,467 @@
+// Package zkp implements the chain accumulators block headers commit to.
+package zkp
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/ccoin/core/internal/dag"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Accumulator errors
+var (
+	ErrInvalidFraudProof     = errors.New("invalid double-spend proof")
+	ErrNullifierNotCommitted = errors.New("nullifier not in accumulator")
+	ErrInvalidFrontier       = errors.New("invalid accumulator frontier")
+)
+
+// DefaultAccumulatorCache is the number of blocks whose accumulator state
+// is kept in memory
+const DefaultAccumulatorCache = 4096
+
+// AccumulatorSource provides the blocks the accumulator is computed over
+type AccumulatorSource interface {
+	// GetBlock returns a block with its transactions
+	GetBlock(ctx context.Context, hash types.Hash) (*types.Block, error)
+
+	// SelectedParent returns the parent a block with these parents selects
+	SelectedParent(ctx context.Context, parents []types.Hash) (types.Hash, error)
+}
+
+// ChainAccumulator tracks an append-only Merkle tree per block. A block's
+// tree is its selected parent's with leaves taken from the block's
+// transactions appended in order, so it holds the leaves of every block
+// along the block's selected-parent chain. The genesis tree is empty.
+//
+// Only the right-most frontier of each tree is kept. States missing from
+// the cache, e.g. after a restart, are rebuilt from stored blocks on first
+// use.
+type ChainAccumulator struct {
+	mu sync.Mutex
+
+	source AccumulatorSource
+
+	// leaves returns the leaves a block appends
+	leaves func(txs []*types.Transaction) []types.Hash
+
+	// Accumulator state after each recent block, oldest first in order
+	states   map[types.Hash]*accumulatorState
+	order    []types.Hash
+	capacity int
+
+	// States restored from snapshots, never evicted since the blocks
+	// before them may not be stored
+	pinned map[types.Hash]*accumulatorState
+
+	// zeros[level] is the root of an empty subtree of height level
+	zeros []types.Hash
+}
+
+// accumulatorState is the frontier of an accumulator tree
+type accumulatorState struct {
+	frontier []types.Hash
+	size     uint64
+	root     types.Hash
+}
+
+// NewNullifierAccumulator creates an accumulator of the nullifiers spent
+// in the blocks of source, caching up to capacity block states (0 for the
+// default). Its roots are the nullifier roots block headers commit to.
+func NewNullifierAccumulator(source AccumulatorSource, capacity int) *ChainAccumulator {
+	return newChainAccumulator(source, capacity, dag.BlockNullifiers)
+}
+
+// NewCommitmentAccumulator creates an accumulator of the note commitments
+// created in the blocks of source. Its roots are commitment tree roots.
+func NewCommitmentAccumulator(source AccumulatorSource, capacity int) *ChainAccumulator {
+	return newChainAccumulator(source, capacity, dag.BlockCommitments)
+}
+
+// newChainAccumulator creates an accumulator of the leaves of each block
+func newChainAccumulator(source AccumulatorSource, capacity int, leaves func([]*types.Transaction) []types.Hash) *ChainAccumulator {
+	if capacity <= 0 {
+		capacity = DefaultAccumulatorCache
+	}
+
+	zeros := make([]types.Hash, TreeDepth+1)
+	zeros[0] = types.EmptyHash
+	for level := 1; level <= TreeDepth; level++ {
+		zeros[level] = hashPair(zeros[level-1], zeros[level-1])
+	}
+
+	return &ChainAccumulator{
+		source:   source,
+		leaves:   leaves,
+		states:   make(map[types.Hash]*accumulatorState),
+		capacity: capacity,
+		pinned:   make(map[types.Hash]*accumulatorState),
+		zeros:    zeros,
+	}
+}
+
+// EmptyRoot returns the root of an accumulator with no leaves
+func (a *ChainAccumulator) EmptyRoot() types.Hash {
+	return a.zeros[TreeDepth]
+}
+
+// Root returns the accumulator root after a stored block
+func (a *ChainAccumulator) Root(ctx context.Context, hash types.Hash) (types.Hash, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state, err := a.stateLocked(ctx, hash)
+	if err != nil {
+		return types.Hash{}, err
+	}
+	return state.root, nil
+}
+
+// Size returns the number of leaves in the accumulator after a stored
+// block, which is the position the next block's first leaf takes
+func (a *ChainAccumulator) Size(ctx context.Context, hash types.Hash) (uint64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state, err := a.stateLocked(ctx, hash)
+	if err != nil {
+		return 0, err
+	}
+	return state.size, nil
+}
+
+// NextRoot returns the root after a block with the given selected parent
+// whose transactions append leaves
+func (a *ChainAccumulator) NextRoot(ctx context.Context, parent types.Hash, leaves []types.Hash) (types.Hash, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state, err := a.stateLocked(ctx, parent)
+	if err != nil {
+		return types.Hash{}, err
+	}
+	next, err := a.extend(state, leaves)
+	if err != nil {
+		return types.Hash{}, err
+	}
+	return next.root, nil
+}
+
+// stateLocked returns the accumulator state after a block, computing it
+// and any uncached ancestors' from stored blocks
+func (a *ChainAccumulator) stateLocked(ctx context.Context, hash types.Hash) (*accumulatorState, error) {
+	// Walk back along selected parents to a known state or genesis
+	var pending []*types.Block
+	var base *accumulatorState
+	for {
+		if state, exists := a.states[hash]; exists {
+			base = state
+			break
+		}
+		if state, exists := a.pinned[hash]; exists {
+			base = state
+			break
+		}
+
+		block, err := a.source.GetBlock(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		if block.Header.IsGenesis() {
+			base = &accumulatorState{
+				frontier: make([]types.Hash, TreeDepth),
+				root:     a.EmptyRoot(),
+			}
+			a.cacheLocked(hash, base)
+			break
+		}
+		pending = append(pending, block)
+
+		hash, err = a.source.SelectedParent(ctx, block.Header.Parents)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i := len(pending) - 1; i >= 0; i-- {
+		next, err := a.extend(base, a.leaves(pending[i].Transactions))
+		if err != nil {
+			return nil, err
+		}
+		a.cacheLocked(pending[i].Header.Hash, next)
+		base = next
+	}
+	return base, nil
+}
+
+// extend returns the state after appending leaves to state
+func (a *ChainAccumulator) extend(state *accumulatorState, leaves []types.Hash) (*accumulatorState, error) {
+	if uint64(len(leaves)) > (uint64(1)<<TreeDepth)-state.size {
+		return nil, ErrTreeFull
+	}
+
+	next := &accumulatorState{
+		frontier: append([]types.Hash(nil), state.frontier...),
+		size:     state.size,
+		root:     state.root,
+	}
+	for _, leaf := range leaves {
+		next.root = appendLeaf(next.frontier, a.zeros, next.size, leaf, nil)
+		next.size++
+	}
+	return next, nil
+}
+
+// cacheLocked stores a block's state, evicting the oldest past capacity
+func (a *ChainAccumulator) cacheLocked(hash types.Hash, state *accumulatorState) {
+	if _, exists := a.states[hash]; exists {
+		return
+	}
+	a.states[hash] = state
+	a.order = append(a.order, hash)
+	for len(a.order) > a.capacity {
+		delete(a.states, a.order[0])
+		a.order = a.order[1:]
+	}
+}
+
+// Frontier is the right-most edge of an accumulator tree: its size and,
+// per level, the last left child written. It is enough to extend the tree
+// and to compute its root.
+type Frontier struct {
+	Size  uint64
+	Nodes []types.Hash
+}
+
+// Frontier returns the accumulator frontier after a stored block
+func (a *ChainAccumulator) Frontier(ctx context.Context, hash types.Hash) (*Frontier, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state, err := a.stateLocked(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	return &Frontier{Size: state.size, Nodes: append([]types.Hash(nil), state.frontier...)}, nil
+}
+
+// BuildFrontier returns the frontier of an accumulator holding leaves
+func (a *ChainAccumulator) BuildFrontier(leaves []types.Hash) (*Frontier, error) {
+	state, err := a.extend(&accumulatorState{frontier: make([]types.Hash, TreeDepth)}, leaves)
+	if err != nil {
+		return nil, err
+	}
+	return &Frontier{Size: state.size, Nodes: state.frontier}, nil
+}
+
+// FrontierRoot computes the root of the tree a frontier belongs to by
+// hashing the empty leaf at the next position up its right edge
+func (a *ChainAccumulator) FrontierRoot(f *Frontier) (types.Hash, error) {
+	if f == nil || len(f.Nodes) != TreeDepth || f.Size >= uint64(1)<<TreeDepth {
+		return types.Hash{}, ErrInvalidFrontier
+	}
+
+	node := a.zeros[0]
+	index := f.Size
+	for level := 0; level < TreeDepth; level++ {
+		if index%2 == 0 {
+			node = hashPair(node, a.zeros[level])
+		} else {
+			node = hashPair(f.Nodes[level], node)
+		}
+		index /= 2
+	}
+	return node, nil
+}
+
+// Restore sets the accumulator state after a block from its frontier,
+// e.g. from a state snapshot, so later blocks extend it without the
+// blocks before it
+func (a *ChainAccumulator) Restore(hash types.Hash, f *Frontier) error {
+	root, err := a.FrontierRoot(f)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pinned[hash] = &accumulatorState{
+		frontier: append([]types.Hash(nil), f.Nodes...),
+		size:     f.Size,
+		root:     root,
+	}
+	return nil
+}
+
+// Leaves returns every leaf in the accumulator after a block, in order
+func (a *ChainAccumulator) Leaves(ctx context.Context, hash types.Hash) ([]types.Hash, error) {
+	var blocks []*types.Block
+	for {
+		block, err := a.source.GetBlock(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		if block.Header.IsGenesis() {
+			break
+		}
+		blocks = append(blocks, block)
+
+		hash, err = a.source.SelectedParent(ctx, block.Header.Parents)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var leaves []types.Hash
+	for i := len(blocks) - 1; i >= 0; i-- {
+		leaves = append(leaves, a.leaves(blocks[i].Transactions)...)
+	}
+	return leaves, nil
+}
+
+// Path returns the Merkle path of the leaf at position in the accumulator
+// tree after a stored block. It rebuilds the tree from every leaf.
+func (a *ChainAccumulator) Path(ctx context.Context, hash types.Hash, position uint64) (*MerklePath, error) {
+	leaves, err := a.Leaves(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if position >= uint64(len(leaves)) {
+		return nil, ErrInvalidPosition
+	}
+	return leafPath(ctx, leaves, position)
+}
+
+// leafPath builds the tree holding leaves and returns the path of the
+// leaf at position
+func leafPath(ctx context.Context, leaves []types.Hash, position uint64) (*MerklePath, error) {
+	tree := NewCommitmentTree(NewInMemoryTreeStore(), TreeDepth)
+	for _, leaf := range leaves {
+		if _, err := tree.AddCommitment(ctx, leaf); err != nil {
+			return nil, err
+		}
+	}
+	return tree.GetPath(ctx, position)
+}
+
+// ProveSpent returns the Merkle path of a nullifier in the accumulator
+// tree after a stored block, proving it spent along the block's chain.
+// a must be a nullifier accumulator.
+func (a *ChainAccumulator) ProveSpent(ctx context.Context, hash, nullifier types.Hash) (*MerklePath, error) {
+	leaves, err := a.Leaves(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	for i, leaf := range leaves {
+		if leaf == nullifier {
+			return leafPath(ctx, leaves, uint64(i))
+		}
+	}
+	return nil, ErrNullifierNotCommitted
+}
+
+// DoubleSpendProof shows that a block spends a nullifier already committed
+// by an earlier header. It can be checked with the two headers and the
+// spending transaction alone, without the chain state.
+type DoubleSpendProof struct {
+	// Earlier commits to an accumulator already holding the nullifier
+	Earlier *types.BlockHeader
+
+	// Block is the header of the block spending it again
+	Block *types.BlockHeader
+
+	// Tx is the spending transaction, at TxIndex in Block
+	Tx      *types.Transaction
+	TxIndex int
+
+	// TxPath proves Tx is under Block's transaction root
+	TxPath []types.Hash
+
+	// Nullifier is the nullifier spent twice
+	Nullifier types.Hash
+
+	// Path proves Nullifier is in Earlier's accumulator
+	Path *MerklePath
+}
+
+// ProveDoubleSpend builds a proof that the transaction at txIndex in block
+// spends nullifier although the stored block earlier already committed
+// it. a must be a nullifier accumulator, and both blocks are assumed to
+// be on the caller's chain.
+func (a *ChainAccumulator) ProveDoubleSpend(ctx context.Context, earlier types.Hash, block *types.Block, txIndex int, nullifier types.Hash) (*DoubleSpendProof, error) {
+	if txIndex < 0 || txIndex >= len(block.Transactions) {
+		return nil, ErrInvalidPosition
+	}
+
+	earlierBlock, err := a.source.GetBlock(ctx, earlier)
+	if err != nil {
+		return nil, err
+	}
+	leaves, err := a.Leaves(ctx, earlier)
+	if err != nil {
+		return nil, err
+	}
+
+	position := -1
+	for i, leaf := range leaves {
+		if leaf == nullifier {
+			position = i
+			break
+		}
+	}
+	if position < 0 {
+		return nil, ErrNullifierNotCommitted
+	}
+	path, err := leafPath(ctx, leaves, uint64(position))
+	if err != nil {
+		return nil, err
+	}
+
+	return &DoubleSpendProof{
+		Earlier:   earlierBlock.Header,
+		Block:     block.Header,
+		Tx:        block.Transactions[txIndex],
+		TxIndex:   txIndex,
+		TxPath:    dag.TxPath(block.Transactions, txIndex),
+		Nullifier: nullifier,
+		Path:      path,
+	}, nil
+}
+
+// Verify checks the proof: both headers hash correctly, Earlier comes
+// first, the nullifier is in Earlier's accumulator and the transaction
+// spending it again is in Block. The verifier must separately check that
+// both headers are on the chain it follows.
+func (p *DoubleSpendProof) Verify() error {
+	if p.Earlier == nil || p.Block == nil || p.Tx == nil || p.Path == nil {
+		return ErrInvalidFraudProof
+	}
+	if p.Earlier.ComputeHash() != p.Earlier.Hash || p.Block.ComputeHash() != p.Block.Hash {
+		return ErrInvalidFraudProof
+	}
+	if p.Earlier.Height >= p.Block.Height || p.Earlier.NullifierRoot.IsEmpty() {
+		return ErrInvalidFraudProof
+	}
+
+	// The transaction spends the nullifier and is in the block
+	if p.Tx.ComputeHash() != p.Tx.TxHash {
+		return ErrInvalidFraudProof
+	}
+	spends := false
+	for _, nullifier := range p.Tx.Nullifiers {
+		if nullifier == p.Nullifier {
+			spends = true
+			break
+		}
+	}
+	if !spends || !dag.VerifyTxPath(p.Block.TxRoot, p.Tx.TxHash, p.TxIndex, p.TxPath) {
+		return ErrInvalidFraudProof
+	}
+
+	// The nullifier was already committed
+	if len(p.Path.Siblings) != TreeDepth || !VerifyMerklePath(p.Nullifier, p.Path, p.Earlier.NullifierRoot) {
+		return ErrInvalidFraudProof
+	}
+	return nil
+}
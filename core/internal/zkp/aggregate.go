@@ -0,0 +1,445 @@
+package zkp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/hash/mimc"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Aggregate disclosure errors
+var (
+	ErrAggregateTooLarge       = errors.New("more notes than the aggregate disclosure circuit supports")
+	ErrAggregateOwnerMismatch  = errors.New("aggregated notes have different owners")
+	ErrAggregateMalformed      = errors.New("malformed aggregate disclosure")
+	ErrSpendSourceUnavailable  = errors.New("no chain to check aggregate disclosures against")
+	ErrAggregateNotOnChain     = errors.New("aggregated note is not spent on-chain")
+	ErrAggregateOutsideWindow  = errors.New("aggregated transaction is outside the disclosed heights")
+	ErrAggregateIncomplete     = errors.New("aggregate disclosure omits inputs of a covered transaction")
+	ErrAggregateChangeMismatch = errors.New("change output is not in a covered transaction")
+)
+
+// Aggregate disclosure circuit arity. Smaller sets are padded with zero
+// nullifiers and commitments.
+const (
+	MaxAggregateSpends = 16
+	MaxAggregateChange = 8
+)
+
+// aggregatePublicDataSize is the encoded size of the bounds, the height
+// window and the nullifier count
+const aggregatePublicDataSize = 8*4 + 1
+
+// AggregateDisclosure proves that a set of its owner's transactions moved
+// between MinOutflow and MaxOutflow out of the owner's hands in total,
+// without revealing any single amount. Outflow is the value of the notes
+// spent less the change returned to the owner. Every covered transaction
+// must be confirmed between FromHeight and ToHeight, and all of its
+// inputs must be included.
+type AggregateDisclosure struct {
+	// Nullifiers of every note the covered transactions spent
+	Nullifiers []types.Hash
+
+	// Change are the commitments of outputs returned to the owner
+	Change []types.Hash
+
+	MinOutflow uint64
+	MaxOutflow uint64
+
+	FromHeight uint64
+	ToHeight   uint64
+
+	Proof  []byte
+	System uint8 // Proof system of Proof
+}
+
+// Disclosure returns the form attached to a transaction. The public
+// inputs are the nullifiers followed by the change commitments; the
+// public data holds the bounds, the heights and the nullifier count.
+func (d *AggregateDisclosure) Disclosure() types.Disclosure {
+	inputs := make([]types.Hash, 0, len(d.Nullifiers)+len(d.Change))
+	inputs = append(inputs, d.Nullifiers...)
+	inputs = append(inputs, d.Change...)
+
+	public := binary.BigEndian.AppendUint64(nil, d.MinOutflow)
+	public = binary.BigEndian.AppendUint64(public, d.MaxOutflow)
+	public = binary.BigEndian.AppendUint64(public, d.FromHeight)
+	public = binary.BigEndian.AppendUint64(public, d.ToHeight)
+	public = append(public, byte(len(d.Nullifiers)))
+
+	return types.Disclosure{
+		Type:       types.DisclosureAggregate,
+		Proof:      types.ZKProof{ProofType: d.System, ProofData: d.Proof, PublicInputs: inputs},
+		PublicData: public,
+	}
+}
+
+// ParseAggregateDisclosure reads an aggregate disclosure back from its
+// attached form
+func ParseAggregateDisclosure(disclosure *types.Disclosure) (*AggregateDisclosure, error) {
+	public := disclosure.PublicData
+	if disclosure.Type != types.DisclosureAggregate || len(public) != aggregatePublicDataSize {
+		return nil, ErrAggregateMalformed
+	}
+
+	spends := int(public[32])
+	inputs := disclosure.Proof.PublicInputs
+	if spends == 0 || spends > MaxAggregateSpends || len(inputs) < spends || len(inputs)-spends > MaxAggregateChange {
+		return nil, ErrAggregateMalformed
+	}
+
+	return &AggregateDisclosure{
+		Nullifiers: append([]types.Hash(nil), inputs[:spends]...),
+		Change:     append([]types.Hash(nil), inputs[spends:]...),
+		MinOutflow: binary.BigEndian.Uint64(public[0:]),
+		MaxOutflow: binary.BigEndian.Uint64(public[8:]),
+		FromHeight: binary.BigEndian.Uint64(public[16:]),
+		ToHeight:   binary.BigEndian.Uint64(public[24:]),
+		Proof:      disclosure.Proof.ProofData,
+		System:     disclosure.Proof.ProofType,
+	}, nil
+}
+
+// EpochHeights returns the first and last block heights of epoch, the
+// usual window of an aggregate disclosure
+func EpochHeights(epoch uint64) (from, to uint64) {
+	return epoch * types.EpochLength, (epoch+1)*types.EpochLength - 1
+}
+
+// AggregateDisclosureCircuit proves bounds on the outflow of a set of
+// notes spent under one key. Note openings and nullifier derivation match
+// the transaction circuit. Zero nullifiers and commitments are padding
+// and must carry no value.
+type AggregateDisclosureCircuit struct {
+	// Public inputs
+	Nullifiers [MaxAggregateSpends]frontend.Variable `gnark:",public"`
+	Change     [MaxAggregateChange]frontend.Variable `gnark:",public"`
+	MinOutflow frontend.Variable                     `gnark:",public"`
+	MaxOutflow frontend.Variable                     `gnark:",public"`
+
+	// Private inputs (witness)
+	SpendingKey frontend.Variable
+	Address     frontend.Variable
+
+	Values    [MaxAggregateSpends]frontend.Variable
+	Blinders  [MaxAggregateSpends]frontend.Variable
+	Positions [MaxAggregateSpends]frontend.Variable
+
+	ChangeValues   [MaxAggregateChange]frontend.Variable
+	ChangeBlinders [MaxAggregateChange]frontend.Variable
+}
+
+// Define implements the circuit constraints
+func (c *AggregateDisclosureCircuit) Define(api frontend.API) error {
+	h, err := mimc.NewMiMC(api)
+	if err != nil {
+		return err
+	}
+	hash := func(data ...frontend.Variable) frontend.Variable {
+		h.Reset()
+		h.Write(data...)
+		return h.Sum()
+	}
+
+	var spent, change frontend.Variable = 0, 0
+
+	for i := range c.Nullifiers {
+		api.ToBinary(c.Values[i], 64)
+		padding := api.IsZero(c.Nullifiers[i])
+		api.AssertIsEqual(api.Mul(padding, c.Values[i]), 0)
+
+		leaf := hash(c.Values[i], c.Address, c.Blinders[i])
+		nullifier := hash(c.SpendingKey, leaf, c.Positions[i])
+		api.AssertIsEqual(api.Mul(api.Sub(1, padding), api.Sub(c.Nullifiers[i], nullifier)), 0)
+
+		spent = api.Add(spent, c.Values[i])
+	}
+
+	for i := range c.Change {
+		api.ToBinary(c.ChangeValues[i], 64)
+		padding := api.IsZero(c.Change[i])
+		api.AssertIsEqual(api.Mul(padding, c.ChangeValues[i]), 0)
+
+		// Change goes back to the address the spent notes belong to
+		commitment := hash(c.ChangeValues[i], c.Address, c.ChangeBlinders[i])
+		api.AssertIsEqual(api.Mul(api.Sub(1, padding), api.Sub(c.Change[i], commitment)), 0)
+
+		change = api.Add(change, c.ChangeValues[i])
+	}
+
+	// Sums stay below 2^68, so an outflow or bound difference that went
+	// negative wraps the field and fails the bit decomposition
+	api.ToBinary(c.MinOutflow, 64)
+	api.ToBinary(c.MaxOutflow, 64)
+	outflow := api.Sub(spent, change)
+	api.ToBinary(outflow, 68)
+	api.ToBinary(api.Sub(outflow, c.MinOutflow), 68)
+	api.ToBinary(api.Sub(c.MaxOutflow, outflow), 68)
+
+	return nil
+}
+
+// newAggregateCircuit returns the circuit with the public inputs of d and
+// every private input zero
+func newAggregateCircuit(d *AggregateDisclosure) *AggregateDisclosureCircuit {
+	c := &AggregateDisclosureCircuit{
+		MinOutflow:  d.MinOutflow,
+		MaxOutflow:  d.MaxOutflow,
+		SpendingKey: 0,
+		Address:     0,
+	}
+	for i := range c.Nullifiers {
+		c.Nullifiers[i], c.Values[i], c.Blinders[i], c.Positions[i] = 0, 0, 0, 0
+		if i < len(d.Nullifiers) {
+			c.Nullifiers[i] = hashVariable(d.Nullifiers[i])
+		}
+	}
+	for i := range c.Change {
+		c.Change[i], c.ChangeValues[i], c.ChangeBlinders[i] = 0, 0, 0
+		if i < len(d.Change) {
+			c.Change[i] = hashVariable(d.Change[i])
+		}
+	}
+	return c
+}
+
+// CompileAggregateCircuit compiles the aggregate disclosure circuit
+func (cm *CircuitManager) CompileAggregateCircuit() error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	_, err := cm.compileLocked(ProofTypeAggregateDisclosure, &AggregateDisclosureCircuit{})
+	return err
+}
+
+// VerifyAggregateProof verifies the proof of an aggregate disclosure
+// against its nullifiers, change commitments and bounds
+func (cm *CircuitManager) VerifyAggregateProof(ctx context.Context, d *AggregateDisclosure) error {
+	cm.mu.RLock()
+	compiled, exists := cm.circuits[ProofTypeAggregateDisclosure]
+	cm.mu.RUnlock()
+	if !exists || !compiled.Compiled {
+		return ErrCircuitNotCompiled
+	}
+	if d.System != compiled.Backend.System() ||
+		len(d.Nullifiers) > MaxAggregateSpends || len(d.Change) > MaxAggregateChange {
+		return ErrProofFailed
+	}
+
+	publicWitness, err := frontend.NewWitness(newAggregateCircuit(d), ecc.BN254.ScalarField(), frontend.PublicOnly())
+	if err != nil {
+		return ErrInvalidPublicInputs
+	}
+
+	valid, err := cm.verify(ProofTypeAggregateDisclosure, d.Proof, publicWitness)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return ErrProofFailed
+	}
+	return nil
+}
+
+// CreateAggregateDisclosure proves that spending the notes in spent,
+// less the change notes returned to their owner, moved between
+// minOutflow and maxOutflow. spent must hold every input of the covered
+// transactions, confirmed between fromHeight and toHeight, and change
+// the outputs of those transactions that paid the owner.
+func (dm *DisclosureManager) CreateAggregateDisclosure(
+	ctx context.Context,
+	spendingKey []byte,
+	spent []*Note,
+	change []*Note,
+	minOutflow, maxOutflow uint64,
+	fromHeight, toHeight uint64,
+) (*AggregateDisclosure, error) {
+	if len(spent) == 0 {
+		return nil, ErrAggregateMalformed
+	}
+	if len(spent) > MaxAggregateSpends || len(change) > MaxAggregateChange {
+		return nil, ErrAggregateTooLarge
+	}
+
+	owner := spent[0].Address
+	var spentTotal, changeTotal uint64
+	for _, n := range spent {
+		if n.Address != owner {
+			return nil, ErrAggregateOwnerMismatch
+		}
+		spentTotal += n.Value
+	}
+	for _, n := range change {
+		if n.Address != owner {
+			return nil, ErrAggregateOwnerMismatch
+		}
+		changeTotal += n.Value
+	}
+	if changeTotal > spentTotal {
+		return nil, ErrDisclosureRequirementFailed
+	}
+	outflow := spentTotal - changeTotal
+	if outflow < minOutflow || outflow > maxOutflow {
+		return nil, ErrDisclosureRequirementFailed
+	}
+
+	d := &AggregateDisclosure{
+		MinOutflow: minOutflow,
+		MaxOutflow: maxOutflow,
+		FromHeight: fromHeight,
+		ToHeight:   toHeight,
+	}
+	for _, n := range spent {
+		d.Nullifiers = append(d.Nullifiers, DeriveNullifierFromNote(spendingKey, n.Value, n.Blinder, n.Address, n.Position))
+	}
+	for _, n := range change {
+		d.Change = append(d.Change, NoteCommitment(n.Value, n.Address, n.Blinder))
+	}
+
+	circuit := newAggregateCircuit(d)
+	circuit.SpendingKey = scalarVariable(spendingKey)
+	circuit.Address = scalarVariable(owner[:])
+	for i, n := range spent {
+		circuit.Values[i] = n.Value
+		circuit.Blinders[i] = scalarVariable(n.Blinder)
+		circuit.Positions[i] = n.Position
+	}
+	for i, n := range change {
+		circuit.ChangeValues[i] = n.Value
+		circuit.ChangeBlinders[i] = scalarVariable(n.Blinder)
+	}
+
+	proofData, err := dm.circuits.GenerateProof(ctx, ProofTypeAggregateDisclosure, circuit)
+	if err != nil {
+		return nil, err
+	}
+	d.Proof = proofData.Proof
+	d.System = proofData.System
+	return d, nil
+}
+
+// VerifyAggregateDisclosure verifies an aggregate disclosure's proof and
+// checks it against the chain: every nullifier must have been spent
+// within the disclosed heights, the transactions that spent them must be
+// covered in full, and each change commitment must be one of their
+// outputs.
+func (dm *DisclosureManager) VerifyAggregateDisclosure(ctx context.Context, d *AggregateDisclosure) error {
+	dm.mu.RLock()
+	spends := dm.spends
+	dm.mu.RUnlock()
+
+	if spends == nil {
+		return ErrSpendSourceUnavailable
+	}
+	if len(d.Nullifiers) == 0 || d.FromHeight > d.ToHeight {
+		return ErrAggregateMalformed
+	}
+
+	listed := make(map[types.Hash]bool, len(d.Nullifiers))
+	for _, n := range d.Nullifiers {
+		if n.IsEmpty() || listed[n] {
+			return ErrAggregateMalformed
+		}
+		listed[n] = true
+	}
+	claimed := make(map[types.Hash]bool, len(d.Change))
+	for _, c := range d.Change {
+		if c.IsEmpty() || claimed[c] {
+			return ErrAggregateMalformed
+		}
+		claimed[c] = true
+	}
+
+	if err := dm.circuits.VerifyAggregateProof(ctx, d); err != nil {
+		return ErrDisclosureProofInvalid
+	}
+
+	covered := make(map[types.Hash]*types.Transaction)
+	for _, n := range d.Nullifiers {
+		tx, height, err := spends.SpendingTransaction(ctx, n)
+		if err != nil {
+			return ErrAggregateNotOnChain
+		}
+		if height < d.FromHeight || height > d.ToHeight {
+			return ErrAggregateOutsideWindow
+		}
+		covered[tx.TxHash] = tx
+	}
+
+	for _, tx := range covered {
+		for _, n := range tx.Nullifiers {
+			if !listed[n] {
+				return ErrAggregateIncomplete
+			}
+		}
+		for _, c := range tx.Commitments {
+			delete(claimed, c.Value)
+		}
+	}
+	if len(claimed) > 0 {
+		return ErrAggregateChangeMismatch
+	}
+	return nil
+}
+
+// SetSpendSource sets where aggregate disclosures look up the
+// transactions that spent their notes
+func (dm *DisclosureManager) SetSpendSource(spends SpendSource) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	dm.spends = spends
+}
+
+// SpendSource finds the confirmed transaction that spent a nullifier
+type SpendSource interface {
+	SpendingTransaction(ctx context.Context, nullifier types.Hash) (*types.Transaction, uint64, error)
+}
+
+// BlockSource provides confirmed blocks by height
+type BlockSource interface {
+	GetHeadersAtHeight(ctx context.Context, height uint64) ([]*types.BlockHeader, error)
+	GetBlock(ctx context.Context, hash types.Hash) (*types.Block, error)
+}
+
+// ChainSpends finds spending transactions through the nullifier store,
+// which records where each nullifier was spent, and the block DAG
+type ChainSpends struct {
+	nullifiers NullifierStore
+	blocks     BlockSource
+}
+
+// NewChainSpends creates a spend source over the chain
+func NewChainSpends(nullifiers NullifierStore, blocks BlockSource) *ChainSpends {
+	return &ChainSpends{nullifiers: nullifiers, blocks: blocks}
+}
+
+// SpendingTransaction returns the transaction that spent nullifier and
+// the height of its block
+func (s *ChainSpends) SpendingTransaction(ctx context.Context, nullifier types.Hash) (*types.Transaction, uint64, error) {
+	info, err := s.nullifiers.GetNullifierInfo(ctx, nullifier)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	headers, err := s.blocks.GetHeadersAtHeight(ctx, info.BlockHeight)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, header := range headers {
+		block, err := s.blocks.GetBlock(ctx, header.Hash)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, tx := range block.Transactions {
+			if tx.TxHash == info.TxHash {
+				return tx, info.BlockHeight, nil
+			}
+		}
+	}
+	return nil, 0, ErrAggregateNotOnChain
+}
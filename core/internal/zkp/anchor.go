@@ -0,0 +1,164 @@
+package zkp
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// DefaultAnchorWindow is the number of recent commitment tree roots a
+// transaction may be anchored to
+const DefaultAnchorWindow = 1000
+
+// AnchorStore defines the interface for persistent anchor history
+type AnchorStore interface {
+	// AddAnchor records a new commitment tree root
+	AddAnchor(ctx context.Context, root types.Hash, blockHeight uint64) error
+
+	// RecentAnchors returns up to n of the latest roots, newest first
+	RecentAnchors(ctx context.Context, n int) ([]types.Hash, error)
+}
+
+// AnchorWindow tracks the last N commitment tree roots. Transactions are
+// built against the root their wallet last saw, so requiring the exact
+// current root would reject every transaction that races another.
+type AnchorWindow struct {
+	mu sync.RWMutex
+
+	// Roots in insertion order, oldest first
+	order []types.Hash
+
+	// Reference counts, as a root can recur (e.g. the empty root)
+	roots map[types.Hash]int
+
+	// Sequence number of each root's latest insertion, and the next one
+	latest map[types.Hash]uint64
+	next   uint64
+
+	size  int
+	store AnchorStore
+}
+
+// NewAnchorWindow creates a window of the last size roots
+func NewAnchorWindow(store AnchorStore, size int) *AnchorWindow {
+	if size <= 0 {
+		size = DefaultAnchorWindow
+	}
+
+	return &AnchorWindow{
+		roots:  make(map[types.Hash]int),
+		latest: make(map[types.Hash]uint64),
+		size:   size,
+		store:  store,
+	}
+}
+
+// Initialize loads the most recent roots from storage
+func (aw *AnchorWindow) Initialize(ctx context.Context) error {
+	recent, err := aw.store.RecentAnchors(ctx, aw.size)
+	if err != nil {
+		return err
+	}
+
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	aw.order = aw.order[:0]
+	aw.roots = make(map[types.Hash]int)
+	aw.latest = make(map[types.Hash]uint64)
+	for i := len(recent) - 1; i >= 0; i-- {
+		aw.pushLocked(recent[i])
+	}
+	return nil
+}
+
+// Add records a new root, evicting the oldest beyond the window
+func (aw *AnchorWindow) Add(ctx context.Context, root types.Hash, blockHeight uint64) error {
+	if err := aw.store.AddAnchor(ctx, root, blockHeight); err != nil {
+		return err
+	}
+
+	aw.push(root)
+	return nil
+}
+
+// push records a root already written to the store
+func (aw *AnchorWindow) push(root types.Hash) {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+	aw.pushLocked(root)
+}
+
+// Contains reports whether root is one of the recent roots
+func (aw *AnchorWindow) Contains(root types.Hash) bool {
+	aw.mu.RLock()
+	defer aw.mu.RUnlock()
+	return aw.roots[root] > 0
+}
+
+// Age returns how many roots were added after root, or false if root is
+// not in the window. The newest root has age 0.
+func (aw *AnchorWindow) Age(root types.Hash) (int, bool) {
+	aw.mu.RLock()
+	defer aw.mu.RUnlock()
+
+	if aw.roots[root] == 0 {
+		return 0, false
+	}
+	return int(aw.next - 1 - aw.latest[root]), true
+}
+
+// Len returns the number of roots in the window
+func (aw *AnchorWindow) Len() int {
+	aw.mu.RLock()
+	defer aw.mu.RUnlock()
+	return len(aw.order)
+}
+
+func (aw *AnchorWindow) pushLocked(root types.Hash) {
+	aw.order = append(aw.order, root)
+	aw.roots[root]++
+	aw.latest[root] = aw.next
+	aw.next++
+
+	if len(aw.order) > aw.size {
+		oldest := aw.order[0]
+		aw.order = aw.order[1:]
+		if aw.roots[oldest]--; aw.roots[oldest] == 0 {
+			delete(aw.roots, oldest)
+			delete(aw.latest, oldest)
+		}
+	}
+}
+
+// InMemoryAnchorStore is a simple in-memory implementation for testing
+type InMemoryAnchorStore struct {
+	mu    sync.RWMutex
+	roots []types.Hash
+}
+
+// NewInMemoryAnchorStore creates a new in-memory anchor store
+func NewInMemoryAnchorStore() *InMemoryAnchorStore {
+	return &InMemoryAnchorStore{}
+}
+
+// AddAnchor appends a root
+func (s *InMemoryAnchorStore) AddAnchor(ctx context.Context, root types.Hash, blockHeight uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roots = append(s.roots, root)
+	return nil
+}
+
+// RecentAnchors returns up to n of the latest roots, newest first
+func (s *InMemoryAnchorStore) RecentAnchors(ctx context.Context, n int) ([]types.Hash, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	recent := make([]types.Hash, 0, n)
+	for i := len(s.roots) - 1; i >= 0 && len(recent) < n; i-- {
+		recent = append(recent, s.roots[i])
+	}
+	return recent, nil
+}
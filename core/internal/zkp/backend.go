@@ -0,0 +1,223 @@
+package zkp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	kzg "github.com/consensys/gnark-crypto/ecc/bn254/kzg"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/consensys/gnark/test/unsafekzg"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Backend errors
+var (
+	ErrSRSRequired = errors.New("plonk backend requires a structured reference string")
+	ErrSRSTooSmall = errors.New("structured reference string too small for circuit")
+)
+
+// ProvingKey is a backend's serializable proving key
+type ProvingKey interface {
+	io.WriterTo
+	io.ReaderFrom
+}
+
+// VerifyingKey is a backend's serializable verifying key
+type VerifyingKey interface {
+	io.WriterTo
+	io.ReaderFrom
+}
+
+// Backend is a zk-SNARK proving system. Each proof type is compiled,
+// set up and proven with the backend selected for it.
+type Backend interface {
+	// System is the ZKProof.ProofType value of proofs from this backend
+	System() uint8
+
+	// Compile builds the constraint system for circuit
+	Compile(circuit frontend.Circuit) (constraint.ConstraintSystem, error)
+
+	// Setup generates the keys for a compiled circuit
+	Setup(ccs constraint.ConstraintSystem) (ProvingKey, VerifyingKey, error)
+
+	// Prove returns a serialized proof for a full witness
+	Prove(ccs constraint.ConstraintSystem, pk ProvingKey, w witness.Witness) ([]byte, error)
+
+	// Verify checks a serialized proof against a public witness
+	Verify(proof []byte, vk VerifyingKey, publicWitness witness.Witness) error
+
+	// NewKeys returns empty keys to read serialized keys into
+	NewKeys() (ProvingKey, VerifyingKey)
+}
+
+// Groth16Backend proves over R1CS with a per-circuit trusted setup
+type Groth16Backend struct{}
+
+// System implements Backend
+func (Groth16Backend) System() uint8 {
+	return types.ProofSystemGroth16
+}
+
+// Compile implements Backend
+func (Groth16Backend) Compile(circuit frontend.Circuit) (constraint.ConstraintSystem, error) {
+	return frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
+}
+
+// Setup implements Backend
+func (Groth16Backend) Setup(ccs constraint.ConstraintSystem) (ProvingKey, VerifyingKey, error) {
+	return groth16.Setup(ccs)
+}
+
+// Prove implements Backend
+func (Groth16Backend) Prove(ccs constraint.ConstraintSystem, pk ProvingKey, w witness.Witness) ([]byte, error) {
+	proof, err := groth16.Prove(ccs, pk.(groth16.ProvingKey), w)
+	if err != nil {
+		return nil, ErrProofGenerationFailed
+	}
+
+	var buf bytes.Buffer
+	if _, err := proof.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Verify implements Backend
+func (Groth16Backend) Verify(proofBytes []byte, vk VerifyingKey, publicWitness witness.Witness) error {
+	proof := groth16.NewProof(ecc.BN254)
+	if _, err := proof.ReadFrom(bytes.NewReader(proofBytes)); err != nil {
+		return err
+	}
+	return groth16.Verify(proof, vk.(groth16.VerifyingKey), publicWitness)
+}
+
+// NewKeys implements Backend
+func (Groth16Backend) NewKeys() (ProvingKey, VerifyingKey) {
+	return groth16.NewProvingKey(ecc.BN254), groth16.NewVerifyingKey(ecc.BN254)
+}
+
+// PLONKBackend proves over a sparse constraint system with a universal
+// KZG SRS. Any circuit that fits the SRS can be set up from it, so
+// circuits can change without a new ceremony.
+type PLONKBackend struct {
+	srs    *kzg.SRS
+	unsafe bool
+}
+
+// NewPLONKBackend creates a PLONK backend using a universal SRS in
+// canonical form, such as the output of a powers-of-tau ceremony
+func NewPLONKBackend(srs *kzg.SRS) *PLONKBackend {
+	return &PLONKBackend{srs: srs}
+}
+
+// NewUnsafePLONKBackend creates a PLONK backend that generates its own SRS
+// for each circuit. Whoever runs it knows the toxic waste, so it is only
+// fit for tests and development networks.
+func NewUnsafePLONKBackend() *PLONKBackend {
+	return &PLONKBackend{unsafe: true}
+}
+
+// LoadSRS reads a universal KZG SRS in canonical form from a file
+func LoadSRS(path string) (*kzg.SRS, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	srs := &kzg.SRS{}
+	if _, err := srs.ReadFrom(bufio.NewReader(f)); err != nil {
+		return nil, err
+	}
+	return srs, nil
+}
+
+// System implements Backend
+func (b *PLONKBackend) System() uint8 {
+	return types.ProofSystemPLONK
+}
+
+// Compile implements Backend
+func (b *PLONKBackend) Compile(circuit frontend.Circuit) (constraint.ConstraintSystem, error) {
+	return frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, circuit)
+}
+
+// Setup implements Backend
+func (b *PLONKBackend) Setup(ccs constraint.ConstraintSystem) (ProvingKey, VerifyingKey, error) {
+	canonical, lagrange, err := b.circuitSRS(ccs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plonk.Setup(ccs, canonical, lagrange)
+}
+
+// circuitSRS truncates the universal SRS to the circuit's domain and
+// derives its Lagrange form
+func (b *PLONKBackend) circuitSRS(ccs constraint.ConstraintSystem) (*kzg.SRS, *kzg.SRS, error) {
+	if b.unsafe {
+		canonical, lagrange, err := unsafekzg.NewSRS(ccs)
+		if err != nil {
+			return nil, nil, err
+		}
+		return canonical.(*kzg.SRS), lagrange.(*kzg.SRS), nil
+	}
+	if b.srs == nil {
+		return nil, nil, ErrSRSRequired
+	}
+
+	size := ecc.NextPowerOfTwo(uint64(ccs.GetNbConstraints() + ccs.GetNbPublicVariables()))
+	if uint64(len(b.srs.Pk.G1)) < size+3 {
+		return nil, nil, ErrSRSTooSmall
+	}
+
+	canonical := &kzg.SRS{Vk: b.srs.Vk}
+	canonical.Pk.G1 = b.srs.Pk.G1[:size+3]
+
+	lagrangeG1, err := kzg.ToLagrangeG1(b.srs.Pk.G1[:size])
+	if err != nil {
+		return nil, nil, err
+	}
+	lagrange := &kzg.SRS{Vk: b.srs.Vk}
+	lagrange.Pk.G1 = lagrangeG1
+
+	return canonical, lagrange, nil
+}
+
+// Prove implements Backend
+func (b *PLONKBackend) Prove(ccs constraint.ConstraintSystem, pk ProvingKey, w witness.Witness) ([]byte, error) {
+	proof, err := plonk.Prove(ccs, pk.(plonk.ProvingKey), w)
+	if err != nil {
+		return nil, ErrProofGenerationFailed
+	}
+
+	var buf bytes.Buffer
+	if _, err := proof.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Verify implements Backend
+func (b *PLONKBackend) Verify(proofBytes []byte, vk VerifyingKey, publicWitness witness.Witness) error {
+	proof := plonk.NewProof(ecc.BN254)
+	if _, err := proof.ReadFrom(bytes.NewReader(proofBytes)); err != nil {
+		return err
+	}
+	return plonk.Verify(proof, vk.(plonk.VerifyingKey), publicWitness)
+}
+
+// NewKeys implements Backend
+func (b *PLONKBackend) NewKeys() (ProvingKey, VerifyingKey) {
+	return plonk.NewProvingKey(ecc.BN254), plonk.NewVerifyingKey(ecc.BN254)
+}
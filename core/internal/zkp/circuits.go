@@ -5,11 +5,13 @@ import (
 	"context"
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/consensys/gnark-crypto/ecc"
-	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
 	"github.com/consensys/gnark/frontend"
-	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/std/hash/mimc"
 
 	"github.com/ccoin/core/pkg/types"
 )
@@ -20,6 +22,14 @@ var (
 	ErrProofGenerationFailed = errors.New("proof generation failed")
 	ErrProofVerificationFailed = errors.New("proof verification failed")
 	ErrInvalidPublicInputs = errors.New("invalid public inputs")
+	ErrTooManyNotes        = errors.New("more notes than the transaction circuit supports")
+)
+
+// Transaction circuit arity. Transactions with fewer notes are padded
+// with zero-value dummies.
+const (
+	MaxTxInputs  = 4
+	MaxTxOutputs = 2
 )
 
 // ProofType defines the type of zk-SNARK proof
@@ -31,6 +41,8 @@ const (
 	ProofTypeIdentityDisclosure
 	ProofTypeTemporalDisclosure
 	ProofTypeSanctionsCompliance
+	ProofTypeAggregateDisclosure
+	ProofTypeGradient
 )
 
 // CircuitManager manages zk-SNARK circuits
@@ -40,68 +52,199 @@ type CircuitManager struct {
 	// Compiled circuits
 	circuits map[ProofType]*CompiledCircuit
 
+	// Proving backends, Groth16 unless set
+	backends map[ProofType]Backend
+
 	// Proving keys
-	provingKeys map[ProofType]groth16.ProvingKey
+	provingKeys map[ProofType]ProvingKey
 
 	// Verifying keys
-	verifyingKeys map[ProofType]groth16.VerifyingKey
+	verifyingKeys map[ProofType]VerifyingKey
+
+	// Keys read by LoadKeys for circuits not yet compiled
+	loadedKeys map[ProofType]*keySet
+
+	// Proof generation and verification times
+	timingMu sync.Mutex
+	timings  map[ProofType]*ProofTiming
 }
 
 // CompiledCircuit holds a compiled circuit
 type CompiledCircuit struct {
-	R1CS     frontend.CompiledConstraintSystem
-	Compiled bool
+	ConstraintSystem constraint.ConstraintSystem
+	Backend          Backend
+	Compiled         bool
+
+	// Inputs and Outputs are the note counts of a transaction circuit
+	Inputs  int
+	Outputs int
 }
 
 // NewCircuitManager creates a new circuit manager
 func NewCircuitManager() *CircuitManager {
 	return &CircuitManager{
 		circuits:      make(map[ProofType]*CompiledCircuit),
-		provingKeys:   make(map[ProofType]groth16.ProvingKey),
-		verifyingKeys: make(map[ProofType]groth16.VerifyingKey),
+		backends:      make(map[ProofType]Backend),
+		provingKeys:   make(map[ProofType]ProvingKey),
+		verifyingKeys: make(map[ProofType]VerifyingKey),
+		loadedKeys:    make(map[ProofType]*keySet),
+		timings:       make(map[ProofType]*ProofTiming),
+	}
+}
+
+// SetBackend selects the proving system for a proof type. It applies to
+// circuits compiled afterwards.
+func (cm *CircuitManager) SetBackend(proofType ProofType, backend Backend) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.backends[proofType] = backend
+}
+
+// compileLocked compiles a circuit with its proof type's backend. Keys
+// come from LoadKeys or the canonical verifying keys when they match the
+// circuit; otherwise they are generated with a fresh setup.
+func (cm *CircuitManager) compileLocked(proofType ProofType, circuit frontend.Circuit) (*CompiledCircuit, error) {
+	backend := cm.backendLocked(proofType)
+
+	ccs, err := backend.Compile(circuit)
+	if err != nil {
+		return nil, err
+	}
+
+	var pk ProvingKey
+	var vk VerifyingKey
+	ks, err := cm.keysForLocked(proofType, backend, ccs)
+	if err != nil {
+		return nil, err
+	}
+	if ks != nil {
+		pk, vk = ks.pk, ks.vk
+		delete(cm.loadedKeys, proofType)
+	} else if pk, vk, err = backend.Setup(ccs); err != nil {
+		return nil, err
 	}
+
+	compiled := &CompiledCircuit{
+		ConstraintSystem: ccs,
+		Backend:          backend,
+		Compiled:         true,
+	}
+	cm.circuits[proofType] = compiled
+	cm.verifyingKeys[proofType] = vk
+	if pk != nil {
+		cm.provingKeys[proofType] = pk
+	} else {
+		delete(cm.provingKeys, proofType)
+	}
+
+	return compiled, nil
 }
 
-// TransactionCircuit is the base shielded transaction circuit
+// TransactionCircuit proves that a shielded transaction spends notes in
+// the commitment tree addressed to keys the prover knows, and creates
+// outputs whose total value plus the fee equals the inputs. Commitments,
+// nullifiers and tree nodes are MiMC hashes over BN254 so they can be
+// recomputed in-circuit.
 type TransactionCircuit struct {
 	// Public inputs
-	MerkleRoot types.Hash `gnark:",public"`
-	Nullifiers []types.Hash `gnark:",public"`
-	Commitments []types.Hash `gnark:",public"`
-	Fee frontend.Variable `gnark:",public"`
-
-	// Private inputs (witness)
-	SpendingKey frontend.Variable
-	Values []frontend.Variable
-	Blinders []frontend.Variable
-	MerklePaths [][]frontend.Variable
-	PathBits [][]frontend.Variable
+	MerkleRoot  frontend.Variable   `gnark:",public"`
+	Nullifiers  []frontend.Variable `gnark:",public"`
+	Commitments []frontend.Variable `gnark:",public"`
+	Fee         frontend.Variable   `gnark:",public"`
+
+	// Private inputs (witness) for each spent note
+	SpendingKeys   []frontend.Variable
+	InputValues    []frontend.Variable
+	InputAddresses []frontend.Variable
+	InputBlinders  []frontend.Variable
+	MerklePaths    [][]frontend.Variable
+	PathBits       [][]frontend.Variable
+
+	// Private inputs (witness) for each created note
+	OutputValues    []frontend.Variable
+	OutputAddresses []frontend.Variable
+	OutputBlinders  []frontend.Variable
+}
+
+// newTransactionCircuit allocates a circuit for the given note counts
+func newTransactionCircuit(numInputs, numOutputs int) *TransactionCircuit {
+	c := &TransactionCircuit{
+		Nullifiers:      make([]frontend.Variable, numInputs),
+		Commitments:     make([]frontend.Variable, numOutputs),
+		SpendingKeys:    make([]frontend.Variable, numInputs),
+		InputValues:     make([]frontend.Variable, numInputs),
+		InputAddresses:  make([]frontend.Variable, numInputs),
+		InputBlinders:   make([]frontend.Variable, numInputs),
+		MerklePaths:     make([][]frontend.Variable, numInputs),
+		PathBits:        make([][]frontend.Variable, numInputs),
+		OutputValues:    make([]frontend.Variable, numOutputs),
+		OutputAddresses: make([]frontend.Variable, numOutputs),
+		OutputBlinders:  make([]frontend.Variable, numOutputs),
+	}
+	for i := 0; i < numInputs; i++ {
+		c.MerklePaths[i] = make([]frontend.Variable, TreeDepth)
+		c.PathBits[i] = make([]frontend.Variable, TreeDepth)
+	}
+	return c
 }
 
 // Define implements the circuit constraints
 func (c *TransactionCircuit) Define(api frontend.API) error {
-	// This is a simplified circuit definition
-	// In production, this would include:
-	// 1. Nullifier derivation check
-	// 2. Merkle path verification
-	// 3. Commitment opening verification
-	// 4. Value conservation check
-
-	// For now, we just verify basic constraints
-	// Constraint: sum of inputs = sum of outputs + fee
+	h, err := mimc.NewMiMC(api)
+	if err != nil {
+		return err
+	}
+	hash := func(data ...frontend.Variable) frontend.Variable {
+		h.Reset()
+		h.Write(data...)
+		return h.Sum()
+	}
+
 	var inputSum, outputSum frontend.Variable = 0, 0
 
-	numInputs := len(c.Values) / 2
-	for i := 0; i < numInputs; i++ {
-		inputSum = api.Add(inputSum, c.Values[i])
+	for i := range c.InputValues {
+		// Values are 64-bit so sums cannot wrap the field
+		api.ToBinary(c.InputValues[i], 64)
+		inputSum = api.Add(inputSum, c.InputValues[i])
+
+		// The note is addressed to the spending key (see ShieldedAddress).
+		// The full-width decomposition is checked to be canonical.
+		keyBits := api.ToBinary(hash(c.SpendingKeys[i]))
+		api.AssertIsEqual(c.InputAddresses[i], api.FromBinary(keyBits[:8*types.AddressSize]...))
+
+		// Commitment opening
+		leaf := hash(c.InputValues[i], c.InputAddresses[i], c.InputBlinders[i])
+
+		// Merkle path, leaf first. The bits also give the leaf position.
+		node := leaf
+		var position frontend.Variable = 0
+		for level, sibling := range c.MerklePaths[i] {
+			bit := c.PathBits[i][level]
+			api.AssertIsBoolean(bit)
+			left := api.Select(bit, sibling, node)
+			right := api.Select(bit, node, sibling)
+			node = hash(left, right)
+			position = api.Add(position, api.Mul(bit, uint64(1)<<level))
+		}
+
+		// Zero-value padding notes need not be in the tree
+		api.AssertIsEqual(api.Mul(c.InputValues[i], api.Sub(node, c.MerkleRoot)), 0)
+
+		// Nullifier derivation
+		api.AssertIsEqual(c.Nullifiers[i], hash(c.SpendingKeys[i], leaf, position))
 	}
 
-	for i := numInputs; i < len(c.Values); i++ {
-		outputSum = api.Add(outputSum, c.Values[i])
+	for i := range c.OutputValues {
+		api.ToBinary(c.OutputValues[i], 64)
+		outputSum = api.Add(outputSum, c.OutputValues[i])
+
+		commitment := hash(c.OutputValues[i], c.OutputAddresses[i], c.OutputBlinders[i])
+		api.AssertIsEqual(c.Commitments[i], commitment)
 	}
 
-	outputPlusFee := api.Add(outputSum, c.Fee)
-	api.AssertIsEqual(inputSum, outputPlusFee)
+	// Value conservation
+	api.ToBinary(c.Fee, 64)
+	api.AssertIsEqual(inputSum, api.Add(outputSum, c.Fee))
 
 	return nil
 }
@@ -111,31 +254,67 @@ func (cm *CircuitManager) CompileTransactionCircuit(numInputs, numOutputs int) e
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
-	circuit := &TransactionCircuit{
-		Values:      make([]frontend.Variable, numInputs+numOutputs),
-		Blinders:    make([]frontend.Variable, numInputs+numOutputs),
-		Nullifiers:  make([]types.Hash, numInputs),
-		Commitments: make([]types.Hash, numOutputs),
+	compiled, err := cm.compileLocked(ProofTypeTransaction, newTransactionCircuit(numInputs, numOutputs))
+	if err != nil {
+		return err
+	}
+	compiled.Inputs = numInputs
+	compiled.Outputs = numOutputs
+
+	return nil
+}
+
+// TransactionShape returns the note counts the transaction circuit was
+// compiled for
+func (cm *CircuitManager) TransactionShape() (inputs, outputs int, err error) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	compiled, exists := cm.circuits[ProofTypeTransaction]
+	if !exists || !compiled.Compiled {
+		return 0, 0, ErrCircuitNotCompiled
+	}
+	return compiled.Inputs, compiled.Outputs, nil
+}
+
+// VerifyTransaction verifies a shielded transaction's proof. The public
+// inputs are taken from the transaction itself, not from the prover.
+func (cm *CircuitManager) VerifyTransaction(ctx context.Context, tx *types.Transaction) error {
+	cm.mu.RLock()
+	compiled, exists := cm.circuits[ProofTypeTransaction]
+	cm.mu.RUnlock()
+	if !exists || !compiled.Compiled {
+		return ErrCircuitNotCompiled
+	}
+
+	inputs, outputs := compiled.Inputs, compiled.Outputs
+	if tx.Proof.ProofType != compiled.Backend.System() ||
+		len(tx.Nullifiers) != inputs || len(tx.Commitments) != outputs {
+		return ErrProofFailed
+	}
+
+	public := newTransactionCircuit(inputs, outputs)
+	public.MerkleRoot = hashVariable(tx.Anchor)
+	public.Fee = tx.Fee
+	for i, nullifier := range tx.Nullifiers {
+		public.Nullifiers[i] = hashVariable(nullifier)
+	}
+	for i, commitment := range tx.Commitments {
+		public.Commitments[i] = hashVariable(commitment.Value)
 	}
 
-	r1cs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
+	publicWitness, err := frontend.NewWitness(public, ecc.BN254.ScalarField(), frontend.PublicOnly())
 	if err != nil {
-		return err
+		return ErrInvalidPublicInputs
 	}
 
-	// Generate proving and verifying keys
-	pk, vk, err := groth16.Setup(r1cs)
+	valid, err := cm.verify(ProofTypeTransaction, tx.Proof.ProofData, publicWitness)
 	if err != nil {
 		return err
 	}
-
-	cm.circuits[ProofTypeTransaction] = &CompiledCircuit{
-		R1CS:     r1cs,
-		Compiled: true,
+	if !valid {
+		return ErrProofFailed
 	}
-	cm.provingKeys[ProofTypeTransaction] = pk
-	cm.verifyingKeys[ProofTypeTransaction] = vk
-
 	return nil
 }
 
@@ -171,68 +350,8 @@ func (cm *CircuitManager) CompileRangeCircuit() error {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
-	circuit := &RangeDisclosureCircuit{}
-
-	r1cs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
-	if err != nil {
-		return err
-	}
-
-	pk, vk, err := groth16.Setup(r1cs)
-	if err != nil {
-		return err
-	}
-
-	cm.circuits[ProofTypeRangeDisclosure] = &CompiledCircuit{
-		R1CS:     r1cs,
-		Compiled: true,
-	}
-	cm.provingKeys[ProofTypeRangeDisclosure] = pk
-	cm.verifyingKeys[ProofTypeRangeDisclosure] = vk
-
-	return nil
-}
-
-// IdentityDisclosureCircuit proves identity ownership without revealing identity
-type IdentityDisclosureCircuit struct {
-	// Public inputs
-	AuthorityPubKey types.Hash `gnark:",public"`
-	CredentialCommitment types.Hash `gnark:",public"`
-
-	// Private inputs
-	PrivateKey frontend.Variable
-	Credential frontend.Variable
-	CredentialBlinder frontend.Variable
-}
-
-// Define implements the identity proof circuit
-func (c *IdentityDisclosureCircuit) Define(api frontend.API) error {
-	// Verify credential was signed by authority
-	// (Simplified - would use actual signature verification in production)
-	return nil
-}
-
-// TemporalDisclosureCircuit proves funds held for minimum duration
-type TemporalDisclosureCircuit struct {
-	// Public inputs
-	CurrentTime frontend.Variable `gnark:",public"`
-	MinDuration frontend.Variable `gnark:",public"`
-	Commitment types.Hash `gnark:",public"`
-
-	// Private inputs
-	CreationTime frontend.Variable
-	Value frontend.Variable
-	Blinder frontend.Variable
-}
-
-// Define implements the temporal proof circuit
-func (c *TemporalDisclosureCircuit) Define(api frontend.API) error {
-	// Verify: CurrentTime - CreationTime >= MinDuration
-	holdTime := api.Sub(c.CurrentTime, c.CreationTime)
-	diff := api.Sub(holdTime, c.MinDuration)
-	api.AssertIsLessOrEqual(0, diff)
-
-	return nil
+	_, err := cm.compileLocked(ProofTypeRangeDisclosure, &RangeDisclosureCircuit{})
+	return err
 }
 
 // ProofData holds a generated proof
@@ -240,6 +359,9 @@ type ProofData struct {
 	ProofType ProofType
 	Proof     []byte
 	PublicInputs []byte
+
+	// System is the proof system that generated Proof
+	System uint8
 }
 
 // GenerateProof generates a proof for a given circuit
@@ -258,7 +380,7 @@ func (cm *CircuitManager) GenerateProof(
 
 	pk, exists := cm.provingKeys[proofType]
 	if !exists {
-		return nil, ErrCircuitNotCompiled
+		return nil, ErrProvingKeyMissing
 	}
 
 	// Create witness
@@ -268,13 +390,12 @@ func (cm *CircuitManager) GenerateProof(
 	}
 
 	// Generate proof
-	proof, err := groth16.Prove(compiled.R1CS, pk, w)
+	start := time.Now()
+	proofBytes, err := compiled.Backend.Prove(compiled.ConstraintSystem, pk, w)
 	if err != nil {
-		return nil, ErrProofGenerationFailed
+		return nil, err
 	}
-
-	// Serialize proof
-	proofBytes := proof.MarshalBinary()
+	cm.recordProve(proofType, time.Since(start))
 
 	// Get public inputs
 	publicWitness, err := w.Public()
@@ -290,6 +411,7 @@ func (cm *CircuitManager) GenerateProof(
 		ProofType:    proofType,
 		Proof:        proofBytes,
 		PublicInputs: publicBytes,
+		System:       compiled.Backend.System(),
 	}, nil
 }
 
@@ -298,22 +420,8 @@ func (cm *CircuitManager) VerifyProof(
 	ctx context.Context,
 	proofData *ProofData,
 ) (bool, error) {
-	cm.mu.RLock()
-	defer cm.mu.RUnlock()
-
-	vk, exists := cm.verifyingKeys[proofData.ProofType]
-	if !exists {
-		return false, ErrCircuitNotCompiled
-	}
-
-	// Deserialize proof
-	proof := groth16.NewProof(ecc.BN254)
-	if err := proof.UnmarshalBinary(proofData.Proof); err != nil {
-		return false, err
-	}
-
 	// Deserialize public inputs
-	publicWitness, err := frontend.NewWitness(nil, ecc.BN254.ScalarField(), frontend.PublicOnly())
+	publicWitness, err := witness.New(ecc.BN254.ScalarField())
 	if err != nil {
 		return false, err
 	}
@@ -321,8 +429,25 @@ func (cm *CircuitManager) VerifyProof(
 		return false, err
 	}
 
-	// Verify
-	if err := groth16.Verify(proof, vk, publicWitness); err != nil {
+	return cm.verify(proofData.ProofType, proofData.Proof, publicWitness)
+}
+
+// verify checks a serialized proof against a public witness with the
+// backend the circuit was compiled for
+func (cm *CircuitManager) verify(proofType ProofType, proofBytes []byte, publicWitness witness.Witness) (bool, error) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	compiled, exists := cm.circuits[proofType]
+	if !exists || !compiled.Compiled {
+		return false, ErrCircuitNotCompiled
+	}
+	vk := cm.verifyingKeys[proofType]
+
+	start := time.Now()
+	err := compiled.Backend.Verify(proofBytes, vk, publicWitness)
+	cm.recordVerify(proofType, time.Since(start))
+	if err != nil {
 		return false, nil
 	}
 
@@ -330,7 +455,7 @@ func (cm *CircuitManager) VerifyProof(
 }
 
 // GetVerifyingKey returns the verifying key for a circuit (for on-chain verification)
-func (cm *CircuitManager) GetVerifyingKey(proofType ProofType) (groth16.VerifyingKey, error) {
+func (cm *CircuitManager) GetVerifyingKey(proofType ProofType) (VerifyingKey, error) {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
 
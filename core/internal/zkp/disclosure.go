@@ -3,6 +3,7 @@ package zkp
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
 	"sync"
 
@@ -11,9 +12,12 @@ import (
 
 // Disclosure errors
 var (
-	ErrDisclosureTypeInvalid     = errors.New("invalid disclosure type")
-	ErrDisclosureProofInvalid    = errors.New("disclosure proof is invalid")
+	ErrDisclosureTypeInvalid       = errors.New("invalid disclosure type")
+	ErrDisclosureProofInvalid      = errors.New("disclosure proof is invalid")
 	ErrDisclosureRequirementFailed = errors.New("disclosure requirement not met")
+	ErrSanctionsListUnavailable    = errors.New("no sanctions list to check against")
+	ErrSanctionsRootMismatch       = errors.New("sanctions disclosure made against a different list")
+	ErrSanctionsNotProven          = errors.New("spent note not proven off the sanctions list")
 )
 
 // DisclosureType defines the type of selective disclosure
@@ -71,25 +75,67 @@ type RangeDisclosure struct {
 	Proof      []byte
 }
 
-// IdentityDisclosure proves identity ownership from authority
+// Disclosure returns the form attached to a transaction. The public data
+// is the range, which policy rules read as the implied value.
+func (d *RangeDisclosure) Disclosure() types.Disclosure {
+	public := binary.BigEndian.AppendUint64(nil, d.MinValue)
+	public = binary.BigEndian.AppendUint64(public, d.MaxValue)
+	return types.Disclosure{
+		Type:       types.DisclosureRange,
+		Proof:      types.ZKProof{ProofData: d.Proof, PublicInputs: []types.Hash{d.Commitment}},
+		PublicData: public,
+	}
+}
+
+// IdentityDisclosure proves the spender holds an unrevoked credential
+// from an authority
 type IdentityDisclosure struct {
 	AuthorityPubKey      types.Hash
 	CredentialCommitment types.Hash
+	RevocationRoot       types.Hash // Root of the revocation list proven against
+	Binding              types.Hash // Nullifier of a note the transaction spends
 	Proof                []byte
+	System               uint8 // Proof system of Proof
 }
 
-// TemporalDisclosure proves funds held for minimum duration
-type TemporalDisclosure struct {
-	Commitment   types.Hash
-	MinDuration  uint64 // seconds
-	ProofTime    uint64 // timestamp when proof was created
-	Proof        []byte
+// Disclosure returns the form attached to a transaction
+func (d *IdentityDisclosure) Disclosure() types.Disclosure {
+	return types.Disclosure{
+		Type: types.DisclosureIdentity,
+		Proof: types.ZKProof{
+			ProofType:    d.System,
+			ProofData:    d.Proof,
+			PublicInputs: []types.Hash{d.CredentialCommitment, d.RevocationRoot, d.Binding},
+		},
+		PublicData: d.AuthorityPubKey[:],
+	}
 }
 
-// SanctionsDisclosure proves address is not on sanctions list
+// SanctionsDisclosure proves the owner of the note behind Nullifier is
+// not on the sanctions list
 type SanctionsDisclosure struct {
-	SanctionsListRoot types.Hash // Merkle root of sanctions list
+	SanctionsListRoot    types.Hash // Merkle root of sanctions list
+	Nullifier            types.Hash
 	ProofOfNonMembership []byte
+	System               uint8 // Proof system of ProofOfNonMembership
+}
+
+// Disclosure returns the form attached to a transaction
+func (d *SanctionsDisclosure) Disclosure() types.Disclosure {
+	return types.Disclosure{
+		Type: types.DisclosureSanctions,
+		Proof: types.ZKProof{
+			ProofType:    d.System,
+			ProofData:    d.ProofOfNonMembership,
+			PublicInputs: []types.Hash{d.SanctionsListRoot, d.Nullifier},
+		},
+		PublicData: d.SanctionsListRoot[:],
+	}
+}
+
+// SanctionsList provides the current sanctions tree root
+type SanctionsList interface {
+	SanctionsRoot() types.Hash
 }
 
 // DisclosureManager handles creation and verification of disclosures
@@ -102,8 +148,20 @@ type DisclosureManager struct {
 	// Known authorities for identity disclosures
 	authorities map[types.Hash]Authority
 
-	// Sanctions list root
-	sanctionsRoot types.Hash
+	// Governed authorities and credential revocations
+	credentials CredentialRegistry
+
+	// Sanctions list disclosures are checked against
+	sanctions SanctionsList
+
+	// Policy deciding which disclosures a transaction needs
+	policy *PolicyEngine
+
+	// Chain aggregate disclosures are checked against
+	spends SpendSource
+
+	// Blocks temporal disclosures are anchored to
+	temporal BlockAnchors
 }
 
 // Authority represents a credential issuer
@@ -170,80 +228,76 @@ func (dm *DisclosureManager) VerifyRangeDisclosure(
 	return dm.circuits.VerifyProof(ctx, proofData)
 }
 
-// CreateTemporalDisclosure creates a temporal disclosure proof
-func (dm *DisclosureManager) CreateTemporalDisclosure(
+// CreateSanctionsDisclosure proves that note, spent with spendingKey from
+// position, is owned by an address off the sanctions list. path is the
+// address's non-membership path under root.
+func (dm *DisclosureManager) CreateSanctionsDisclosure(
 	ctx context.Context,
-	value uint64,
-	blinder []byte,
-	commitment types.Hash,
-	creationTime uint64,
-	currentTime uint64,
-	minDuration uint64,
-) (*TemporalDisclosure, error) {
-	// Verify the duration requirement
-	if currentTime-creationTime < minDuration {
-		return nil, ErrDisclosureRequirementFailed
+	root types.Hash,
+	path *SanctionsPath,
+	note *Note,
+	spendingKey []byte,
+	position uint64,
+) (*SanctionsDisclosure, error) {
+	if !VerifyNonMembership(root, note.Address, path) {
+		return nil, ErrInvalidSanctionsPath
 	}
 
-	// Create circuit witness
-	circuit := &TemporalDisclosureCircuit{
-		CurrentTime:  currentTime,
-		MinDuration:  minDuration,
-		Commitment:   commitment,
-		CreationTime: creationTime,
-		Value:        value,
+	circuit := &SanctionsCircuit{
+		Root:        hashVariable(root),
+		SpendingKey: scalarVariable(spendingKey),
+		Value:       note.Value,
+		Address:     scalarVariable(note.Address[:]),
+		Blinder:     scalarVariable(note.Blinder),
+		Position:    position,
+	}
+	nullifier := DeriveNullifierFromNote(spendingKey, note.Value, note.Blinder, note.Address, position)
+	circuit.Nullifier = hashVariable(nullifier)
+	for i, sibling := range path.Siblings {
+		circuit.Siblings[i] = hashVariable(sibling)
 	}
 
-	// Generate proof
-	proofData, err := dm.circuits.GenerateProof(ctx, ProofTypeTemporalDisclosure, circuit)
+	proofData, err := dm.circuits.GenerateProof(ctx, ProofTypeSanctionsCompliance, circuit)
 	if err != nil {
 		return nil, err
 	}
 
-	return &TemporalDisclosure{
-		Commitment:  commitment,
-		MinDuration: minDuration,
-		ProofTime:   currentTime,
-		Proof:       proofData.Proof,
+	return &SanctionsDisclosure{
+		SanctionsListRoot:    root,
+		Nullifier:            nullifier,
+		ProofOfNonMembership: proofData.Proof,
+		System:               proofData.System,
 	}, nil
 }
 
-// CreateIdentityDisclosure creates an identity disclosure proof
-func (dm *DisclosureManager) CreateIdentityDisclosure(
-	ctx context.Context,
-	privateKey []byte,
-	credential []byte,
-	authorityPubKey types.Hash,
-) (*IdentityDisclosure, error) {
-	// Verify authority is known
+// VerifySanctionsDisclosure verifies a sanctions disclosure against the
+// current sanctions list. Proofs against an earlier list are rejected so
+// a newly listed address cannot spend with an old proof.
+func (dm *DisclosureManager) VerifySanctionsDisclosure(ctx context.Context, disclosure *SanctionsDisclosure) error {
 	dm.mu.RLock()
-	_, known := dm.authorities[authorityPubKey]
+	sanctions := dm.sanctions
 	dm.mu.RUnlock()
 
-	if !known {
-		return nil, errors.New("unknown authority")
+	if sanctions == nil {
+		return ErrSanctionsListUnavailable
 	}
-
-	// Create circuit witness
-	circuit := &IdentityDisclosureCircuit{
-		AuthorityPubKey: authorityPubKey,
-		// Fill in other fields
+	if disclosure.SanctionsListRoot != sanctions.SanctionsRoot() {
+		return ErrSanctionsRootMismatch
 	}
 
-	// Generate proof
-	proofData, err := dm.circuits.GenerateProof(ctx, ProofTypeIdentityDisclosure, circuit)
-	if err != nil {
-		return nil, err
+	proof := &types.ZKProof{ProofType: disclosure.System, ProofData: disclosure.ProofOfNonMembership}
+	if err := dm.circuits.VerifySanctionsProof(ctx, disclosure.SanctionsListRoot, disclosure.Nullifier, proof); err != nil {
+		return ErrDisclosureProofInvalid
 	}
+	return nil
+}
 
-	// Create credential commitment
-	credCommitment := hashBytes(credential)
-
-	return &IdentityDisclosure{
-		AuthorityPubKey:      authorityPubKey,
-		CredentialCommitment: credCommitment,
-		Proof:                proofData.Proof,
-	}, nil
+// SetSanctionsList sets the list sanctions disclosures are checked
+// against
+func (dm *DisclosureManager) SetSanctionsList(list SanctionsList) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	dm.sanctions = list
 }
 
 // RegisterAuthority registers a trusted credential authority
@@ -259,8 +313,9 @@ func (dm *DisclosureManager) ValidateDisclosures(
 	tx *types.Transaction,
 	requiredFlags DisclosureFlags,
 ) error {
-	// Check if required disclosures are present
-	providedFlags := DisclosureFlags(tx.DisclosureFlags)
+	// Check if required disclosures are present. A flag only counts if
+	// the matching disclosure is attached.
+	providedFlags := DisclosureFlags(tx.DisclosureFlags) & attachedFlags(tx)
 
 	if requiredFlags&FlagRangeRequired != 0 && providedFlags&FlagRangeRequired == 0 {
 		return errors.New("range disclosure required but not provided")
@@ -279,22 +334,55 @@ func (dm *DisclosureManager) ValidateDisclosures(
 	}
 
 	// Verify each provided disclosure
+	spent := make(map[types.Hash]bool, len(tx.Nullifiers))
+	for _, nullifier := range tx.Nullifiers {
+		spent[nullifier] = false
+	}
 	for _, disclosure := range tx.Disclosures {
-		if err := dm.verifyDisclosure(ctx, &disclosure); err != nil {
+		if err := dm.verifyDisclosure(ctx, &disclosure, spent); err != nil {
 			return err
 		}
 	}
 
+	// Every spent note must be proven off the list
+	if requiredFlags&FlagSanctionsRequired != 0 {
+		for _, proven := range spent {
+			if !proven {
+				return ErrSanctionsNotProven
+			}
+		}
+	}
+
 	return nil
 }
 
-// verifyDisclosure verifies a single disclosure
-func (dm *DisclosureManager) verifyDisclosure(ctx context.Context, disclosure *types.Disclosure) error {
+// attachedFlags returns the flags of the disclosures attached to tx
+func attachedFlags(tx *types.Transaction) DisclosureFlags {
+	flags := FlagNone
+	for _, d := range tx.Disclosures {
+		switch d.Type {
+		case types.DisclosureRange:
+			flags |= FlagRangeRequired
+		case types.DisclosureIdentity:
+			flags |= FlagIdentityRequired
+		case types.DisclosureTemporal:
+			flags |= FlagTemporalRequired
+		case types.DisclosureSanctions:
+			flags |= FlagSanctionsRequired
+		}
+	}
+	return flags
+}
+
+// verifyDisclosure verifies a single disclosure. Sanctions disclosures
+// must name one of the transaction's nullifiers, which is marked in spent;
+// identity and temporal disclosures must be bound to one.
+func (dm *DisclosureManager) verifyDisclosure(ctx context.Context, disclosure *types.Disclosure, spent map[types.Hash]bool) error {
 	switch disclosure.Type {
-	case uint8(DisclosureTypeRange):
+	case types.DisclosureRange:
 		// Parse and verify range disclosure
 		rangeDisc := &RangeDisclosure{
-			Proof: disclosure.Proof,
+			Proof: disclosure.Proof.ProofData,
 		}
 		valid, err := dm.VerifyRangeDisclosure(ctx, rangeDisc)
 		if err != nil {
@@ -304,15 +392,57 @@ func (dm *DisclosureManager) verifyDisclosure(ctx context.Context, disclosure *t
 			return ErrDisclosureProofInvalid
 		}
 
-	case uint8(DisclosureTypeIdentity):
-		// Verify identity disclosure
-		// (implementation similar to range)
+	case types.DisclosureIdentity:
+		identity, err := ParseIdentityDisclosure(disclosure)
+		if err != nil {
+			return err
+		}
+		if _, ok := spent[identity.Binding]; !ok {
+			return ErrDisclosureProofInvalid
+		}
+		if err := dm.VerifyIdentityDisclosure(ctx, identity); err != nil {
+			return err
+		}
 
-	case uint8(DisclosureTypeTemporal):
-		// Verify temporal disclosure
+	case types.DisclosureTemporal:
+		temporal, err := ParseTemporalDisclosure(disclosure)
+		if err != nil {
+			return err
+		}
+		if _, ok := spent[temporal.Nullifier]; !ok {
+			return ErrDisclosureProofInvalid
+		}
+		if err := dm.VerifyTemporalDisclosure(ctx, temporal); err != nil {
+			return err
+		}
+
+	case types.DisclosureSanctions:
+		inputs := disclosure.Proof.PublicInputs
+		if len(inputs) != 2 {
+			return ErrDisclosureProofInvalid
+		}
+		sanctionsDisc := &SanctionsDisclosure{
+			SanctionsListRoot:    inputs[0],
+			Nullifier:            inputs[1],
+			ProofOfNonMembership: disclosure.Proof.ProofData,
+			System:               disclosure.Proof.ProofType,
+		}
+		if _, ok := spent[sanctionsDisc.Nullifier]; !ok {
+			return ErrDisclosureProofInvalid
+		}
+		if err := dm.VerifySanctionsDisclosure(ctx, sanctionsDisc); err != nil {
+			return err
+		}
+		spent[sanctionsDisc.Nullifier] = true
 
-	case uint8(DisclosureTypeSanctions):
-		// Verify sanctions compliance
+	case types.DisclosureAggregate:
+		aggregate, err := ParseAggregateDisclosure(disclosure)
+		if err != nil {
+			return err
+		}
+		if err := dm.VerifyAggregateDisclosure(ctx, aggregate); err != nil {
+			return err
+		}
 
 	default:
 		return ErrDisclosureTypeInvalid
@@ -320,41 +450,3 @@ func (dm *DisclosureManager) verifyDisclosure(ctx context.Context, disclosure *t
 
 	return nil
 }
-
-// hashBytes is a helper to hash arbitrary bytes
-func hashBytes(data []byte) types.Hash {
-	var hash types.Hash
-	// Simple hash - use proper crypto hash in production
-	for i := 0; i < len(hash) && i < len(data); i++ {
-		hash[i] = data[i]
-	}
-	return hash
-}
-
-// DisclosureRequirement defines what disclosures are required for a transaction
-type DisclosureRequirement struct {
-	Flags        DisclosureFlags
-	RangeMin     uint64
-	RangeMax     uint64
-	MinHoldTime  uint64
-	AuthorityID  types.Hash
-}
-
-// DefaultComplianceRequirement returns standard compliance disclosure requirements
-func DefaultComplianceRequirement() *DisclosureRequirement {
-	return &DisclosureRequirement{
-		Flags:       FlagSanctionsRequired,
-		RangeMin:    0,
-		RangeMax:    0,
-		MinHoldTime: 0,
-	}
-}
-
-// HighValueRequirement returns requirements for high-value transactions
-func HighValueRequirement(threshold uint64) *DisclosureRequirement {
-	return &DisclosureRequirement{
-		Flags:    FlagRangeRequired | FlagIdentityRequired | FlagSanctionsRequired,
-		RangeMin: threshold,
-		RangeMax: ^uint64(0), // Max uint64
-	}
-}
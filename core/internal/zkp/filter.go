@@ -0,0 +1,396 @@
+package zkp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sync"
+
+	"github.com/ccoin/core/internal/dag"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Nullifier filter errors
+var (
+	ErrInvalidFilter  = errors.New("invalid nullifier filter")
+	ErrFilterMismatch = errors.New("nullifier filter does not match the sealed epoch")
+	ErrFilterUnknown  = errors.New("nullifier filter is for an epoch not sealed yet")
+)
+
+// MaxFilterSize caps the bits of a nullifier filter, in bytes. An epoch
+// spending more nullifiers than fit at the configured false positive rate
+// gets a filter at this size with a higher rate.
+const MaxFilterSize = 1024 * 1024
+
+// maxFilterHashes caps the bit positions set per nullifier
+const maxFilterHashes = 30
+
+// NullifierFilter is a Bloom filter of the nullifiers spent by the main
+// chain blocks of one epoch. A nullifier it does not contain was not spent
+// in the epoch; one it contains may have been. Hash is the main chain
+// block closing the epoch, which also salts the bit positions so a
+// nullifier colliding in one epoch's filter is unlikely to in the next.
+type NullifierFilter struct {
+	Epoch  uint64
+	Hash   types.Hash
+	Count  uint32
+	Hashes uint8
+	Bits   []byte
+}
+
+// NewNullifierFilter creates an empty filter sized for count nullifiers at
+// false positive rate fpRate
+func NewNullifierFilter(epoch uint64, hash types.Hash, count int, fpRate float64) *NullifierFilter {
+	bits := 64.0
+	hashes := 1
+	if count > 0 {
+		bits = math.Max(bits, math.Ceil(-float64(count)*math.Log(fpRate)/(math.Ln2*math.Ln2)))
+		bits = math.Min(bits, MaxFilterSize*8)
+		hashes = int(math.Round(bits / float64(count) * math.Ln2))
+		hashes = min(max(hashes, 1), maxFilterHashes)
+	}
+	return &NullifierFilter{
+		Epoch:  epoch,
+		Hash:   hash,
+		Hashes: uint8(hashes),
+		Bits:   make([]byte, (int(bits)+7)/8),
+	}
+}
+
+// LastHeight returns the height of the block closing the filter's epoch
+func (f *NullifierFilter) LastHeight() uint64 {
+	return (f.Epoch+1)*types.EpochLength - 1
+}
+
+// Add adds a nullifier to the filter
+func (f *NullifierFilter) Add(nullifier types.Hash) {
+	f.positions(nullifier, func(bit uint64) bool {
+		f.Bits[bit/8] |= 1 << (bit % 8)
+		return true
+	})
+	f.Count++
+}
+
+// MayContain reports whether the nullifier may have been added. False
+// positives occur at about the rate the filter was sized for.
+func (f *NullifierFilter) MayContain(nullifier types.Hash) bool {
+	return f.positions(nullifier, func(bit uint64) bool {
+		return f.Bits[bit/8]&(1<<(bit%8)) != 0
+	})
+}
+
+// positions calls fn with each bit position of a nullifier until it
+// returns false, reporting whether it never did. Positions come from
+// double hashing the nullifier, which is already uniformly distributed,
+// salted with the filter's hash.
+func (f *NullifierFilter) positions(nullifier types.Hash, fn func(bit uint64) bool) bool {
+	size := uint64(len(f.Bits)) * 8
+	if size == 0 {
+		return false
+	}
+	h1 := binary.BigEndian.Uint64(nullifier[0:8]) ^ binary.BigEndian.Uint64(f.Hash[0:8])
+	h2 := binary.BigEndian.Uint64(nullifier[8:16]) ^ binary.BigEndian.Uint64(f.Hash[8:16]) | 1
+	for i := uint64(0); i < uint64(f.Hashes); i++ {
+		if !fn((h1 + i*h2) % size) {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks the filter's parameters are within bounds
+func (f *NullifierFilter) Validate() error {
+	if len(f.Bits) == 0 || len(f.Bits) > MaxFilterSize || f.Hashes == 0 || f.Hashes > maxFilterHashes {
+		return ErrInvalidFilter
+	}
+	return nil
+}
+
+// Equal reports whether two filters are identical
+func (f *NullifierFilter) Equal(other *NullifierFilter) bool {
+	return f.Epoch == other.Epoch && f.Hash == other.Hash && f.Count == other.Count &&
+		f.Hashes == other.Hashes && bytes.Equal(f.Bits, other.Bits)
+}
+
+// FilterChain provides the main chain blocks filters are built from
+type FilterChain interface {
+	GetHeight() uint64
+	GetMainChain(ctx context.Context, fromHeight, toHeight uint64) ([]*types.BlockHeader, error)
+	GetBlock(ctx context.Context, hash types.Hash) (*types.Block, error)
+}
+
+// FilterConfig holds nullifier filter configuration
+type FilterConfig struct {
+	// FalsePositiveRate is the share of unspent nullifiers one sealed
+	// filter reports as possibly spent. Nullifiers are checked against
+	// every sealed filter, so the combined rate is about this times the
+	// epochs sealed.
+	FalsePositiveRate float64
+
+	// Depth is how far below the chain height the block closing an epoch
+	// must be before the epoch is sealed, so filters stay on the main
+	// chain
+	Depth uint64
+}
+
+// DefaultFilterConfig returns default nullifier filter configuration
+func DefaultFilterConfig() *FilterConfig {
+	return &FilterConfig{
+		FalsePositiveRate: 1e-6,
+		Depth:             100,
+	}
+}
+
+// NullifierFilters pre-screens nullifiers against everything spent along
+// the main chain without touching storage: a filter per sealed epoch,
+// from genesis on, and the exact nullifiers spent above the last one.
+// Nullifiers it rules out were not spent; the rest must be checked
+// against an authoritative source, since most are false positives.
+//
+// Full nodes seal epochs from their own chain. Light clients have no
+// chain and take sealed filters from peers with AddFilter.
+type NullifierFilters struct {
+	update sync.Mutex // held by Update
+	mu     sync.RWMutex
+
+	chain  FilterChain
+	config *FilterConfig
+
+	// Filters of epochs 0 to len(sealed)-1
+	sealed []*NullifierFilter
+
+	// Nullifiers spent above the sealed epochs, by height. Once tracked
+	// they include every main chain nullifier from height from on, and
+	// fed is the next main chain height Update reads them from.
+	recent  map[types.Hash]uint64
+	tracked bool
+	from    uint64
+	fed     uint64
+
+	onSeal func(f *NullifierFilter)
+}
+
+// NewNullifierFilters creates nullifier filters built from chain, or
+// received from peers if chain is nil
+func NewNullifierFilters(chain FilterChain, cfg *FilterConfig) *NullifierFilters {
+	if cfg == nil {
+		cfg = DefaultFilterConfig()
+	}
+
+	return &NullifierFilters{
+		chain:  chain,
+		config: cfg,
+		recent: make(map[types.Hash]uint64),
+	}
+}
+
+// SetOnSeal sets a function called with each filter Update seals once the
+// filters have caught up with the chain
+func (s *NullifierFilters) SetOnSeal(fn func(f *NullifierFilter)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onSeal = fn
+}
+
+// Sealed returns the number of sealed epochs
+func (s *NullifierFilters) Sealed() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return uint64(len(s.sealed))
+}
+
+// Filter returns the filter of a sealed epoch
+func (s *NullifierFilters) Filter(epoch uint64) (*NullifierFilter, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if epoch >= uint64(len(s.sealed)) {
+		return nil, false
+	}
+	return s.sealed[epoch], true
+}
+
+// Ready reports whether the filters cover the whole chain, so MaybeSpent
+// can rule nullifiers out
+func (s *NullifierFilters) Ready() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.readyLocked()
+}
+
+func (s *NullifierFilters) readyLocked() bool {
+	return s.tracked && s.from <= uint64(len(s.sealed))*types.EpochLength
+}
+
+// MaybeSpent reports whether a nullifier may have been spent. It is
+// always true until the filters are ready.
+func (s *NullifierFilters) MaybeSpent(nullifier types.Hash) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, exists := s.recent[nullifier]; exists || !s.readyLocked() {
+		return true
+	}
+	for _, f := range s.sealed {
+		if f.MayContain(nullifier) {
+			return true
+		}
+	}
+	return false
+}
+
+// Track marks the nullifiers passed to Add as complete from height on.
+// Only the first call counts.
+func (s *NullifierFilters) Track(height uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.tracked {
+		s.tracked = true
+		s.from = height
+	}
+}
+
+// Add records the nullifiers spent by a block at height. Blocks off the
+// main chain may be added too; their nullifiers only add false positives.
+func (s *NullifierFilters) Add(height uint64, nullifiers []types.Hash) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if height < uint64(len(s.sealed))*types.EpochLength {
+		return
+	}
+	for _, n := range nullifiers {
+		s.recent[n] = height
+	}
+}
+
+// AddFilter adds the filter of the epoch after the sealed ones. A filter
+// of an epoch already sealed must equal the sealed one.
+func (s *NullifierFilters) AddFilter(f *NullifierFilter) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch sealed := uint64(len(s.sealed)); {
+	case f.Epoch < sealed:
+		if !s.sealed[f.Epoch].Equal(f) {
+			return ErrFilterMismatch
+		}
+		return nil
+	case f.Epoch > sealed:
+		return ErrFilterUnknown
+	}
+
+	s.sealed = append(s.sealed, f)
+	for n, height := range s.recent {
+		if height <= f.LastHeight() {
+			delete(s.recent, n)
+		}
+	}
+	return nil
+}
+
+// Verify checks a filter received from a peer against the sealed one
+func (s *NullifierFilters) Verify(f *NullifierFilter) error {
+	sealed, ok := s.Filter(f.Epoch)
+	if !ok {
+		return ErrFilterUnknown
+	}
+	if !sealed.Equal(f) {
+		return ErrFilterMismatch
+	}
+	return nil
+}
+
+// Update seals the epochs whose closing block is Depth below the chain
+// height and reads the nullifiers spent above them. It is called as
+// blocks are added; the first call catches up with the whole chain, and
+// calls made while another runs return at once.
+func (s *NullifierFilters) Update(ctx context.Context) error {
+	if s.chain == nil || !s.update.TryLock() {
+		return nil
+	}
+	defer s.update.Unlock()
+
+	height := s.chain.GetHeight()
+	s.mu.RLock()
+	epoch, caughtUp, onSeal := uint64(len(s.sealed)), s.tracked, s.onSeal
+	s.mu.RUnlock()
+
+	for last := (epoch+1)*types.EpochLength - 1; last+s.config.Depth <= height; last += types.EpochLength {
+		f, err := s.build(ctx, epoch)
+		if err != nil {
+			return err
+		}
+		if f == nil {
+			break
+		}
+		if err := s.AddFilter(f); err != nil {
+			return err
+		}
+		if caughtUp && onSeal != nil {
+			onSeal(f)
+		}
+		epoch++
+	}
+
+	// Nullifiers above the sealed epochs, from where the last call left
+	// off
+	s.mu.RLock()
+	from := max(s.fed, epoch*types.EpochLength)
+	s.mu.RUnlock()
+	if from <= height {
+		headers, err := s.chain.GetMainChain(ctx, from, height)
+		if err != nil {
+			return err
+		}
+		for _, h := range headers {
+			block, err := s.chain.GetBlock(ctx, h.Hash)
+			if err != nil {
+				return err
+			}
+			s.Add(h.Height, dag.BlockNullifiers(block.Transactions))
+		}
+	}
+
+	s.mu.Lock()
+	s.fed = height + 1
+	if !s.tracked {
+		s.tracked = true
+		s.from = epoch * types.EpochLength
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// build builds the filter of an epoch from the main chain, or returns nil
+// if the chain does not hold all of its blocks
+func (s *NullifierFilters) build(ctx context.Context, epoch uint64) (*NullifierFilter, error) {
+	first := epoch * types.EpochLength
+	last := first + types.EpochLength - 1
+	headers, err := s.chain.GetMainChain(ctx, first, last)
+	if err != nil {
+		return nil, err
+	}
+	if len(headers) == 0 || headers[len(headers)-1].Height != last {
+		return nil, nil
+	}
+
+	var nullifiers []types.Hash
+	for _, h := range headers {
+		block, err := s.chain.GetBlock(ctx, h.Hash)
+		if err != nil {
+			return nil, err
+		}
+		nullifiers = append(nullifiers, dag.BlockNullifiers(block.Transactions)...)
+	}
+
+	f := NewNullifierFilter(epoch, headers[len(headers)-1].Hash, len(nullifiers), s.config.FalsePositiveRate)
+	for _, n := range nullifiers {
+		f.Add(n)
+	}
+	return f, nil
+}
@@ -0,0 +1,374 @@
+package zkp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/hash/mimc"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Gradient proof errors
+var (
+	ErrGradientOutOfRange    = errors.New("gradient work value out of range")
+	ErrGradientNoImprovement = errors.New("updated weights do not lower the loss")
+	ErrGradientMalformed     = errors.New("malformed gradient proof")
+	ErrGradientMismatch      = errors.New("gradient proof does not match the block header")
+)
+
+// Gradient circuit arity. The circuit proves one step of a linear model
+// over a verification sample of the task's batch.
+const (
+	GradientFeatures = 8
+	GradientSamples  = 8
+
+	// GradientValueBits bounds weights, features and labels to signed
+	// values of this many bits, which keeps both losses below 2^64
+	GradientValueBits = 12
+
+	// GradientQualityScale is the fixed-point scale of the quality score
+	GradientQualityScale = 1000000
+)
+
+// Signed value bounds of gradient work
+const (
+	GradientValueMin = -1 << (GradientValueBits - 1)
+	GradientValueMax = 1<<(GradientValueBits-1) - 1
+)
+
+// gradientProofHeaderSize is the encoded size of the losses, the quality
+// and the proof system
+const gradientProofHeaderSize = 8*3 + 1
+
+// GradientWork is a training step a miner proves: Weights moved to
+// Updated over a sample of Features and Labels from the task's batch.
+// The loss is the sum of squared errors of the linear model.
+type GradientWork struct {
+	Task types.Hash
+
+	Weights [GradientFeatures]int16
+	Updated [GradientFeatures]int16
+
+	Features [GradientSamples][GradientFeatures]int16
+	Labels   [GradientSamples]int16
+}
+
+// Validate checks every value is within the circuit's range
+func (w *GradientWork) Validate() error {
+	values := make([]int16, 0, 2*GradientFeatures+GradientSamples*(GradientFeatures+1))
+	values = append(values, w.Weights[:]...)
+	values = append(values, w.Updated[:]...)
+	for i := range w.Features {
+		values = append(values, w.Features[i][:]...)
+	}
+	values = append(values, w.Labels[:]...)
+
+	for _, v := range values {
+		if v < GradientValueMin || v > GradientValueMax {
+			return ErrGradientOutOfRange
+		}
+	}
+	return nil
+}
+
+// Loss returns the sum of squared errors of weights over the sample
+func (w *GradientWork) Loss(weights [GradientFeatures]int16) uint64 {
+	var loss uint64
+	for i := range w.Features {
+		e := -int64(w.Labels[i])
+		for j := range weights {
+			e += int64(weights[j]) * int64(w.Features[i][j])
+		}
+		loss += uint64(e * e)
+	}
+	return loss
+}
+
+// Result returns the gradient hash a block carries as its PoUW result. It
+// commits to the task, both weight vectors and the sample.
+func (w *GradientWork) Result() types.Hash {
+	batch := make([]fr.Element, 0, GradientSamples*(GradientFeatures+1))
+	for i := range w.Features {
+		batch = append(batch, signedElements(w.Features[i][:])...)
+	}
+	batch = append(batch, signedElements(w.Labels[:])...)
+
+	return mimcHash(
+		fieldElement(w.Task[:]),
+		hashElement(mimcHash(signedElements(w.Weights[:])...)),
+		hashElement(mimcHash(signedElements(w.Updated[:])...)),
+		hashElement(mimcHash(batch...)),
+	)
+}
+
+// GradientQuality returns the fixed-point quality score
+// floor((lossBefore - lossAfter) * scale / lossBefore)
+func GradientQuality(lossBefore, lossAfter uint64) uint64 {
+	if lossBefore == 0 || lossAfter >= lossBefore {
+		return 0
+	}
+	hi, lo := bits.Mul64(lossBefore-lossAfter, GradientQualityScale)
+	quality, _ := bits.Div64(hi, lo, lossBefore)
+	return quality
+}
+
+// QualityScore converts a fixed-point quality to the header's score
+func QualityScore(quality uint64) float64 {
+	return float64(quality) / GradientQualityScale
+}
+
+// signedElements returns signed values as BN254 scalars
+func signedElements(values []int16) []fr.Element {
+	elems := make([]fr.Element, len(values))
+	for i, v := range values {
+		elems[i].SetInt64(int64(v))
+	}
+	return elems
+}
+
+// hashElement returns a hash as a BN254 scalar
+func hashElement(h types.Hash) fr.Element {
+	return fieldElement(h[:])
+}
+
+// GradientProof is the PoUW proof a block carries. Task and Result are
+// the header's task and PoUW result; the rest is encoded in PoUWProof.
+type GradientProof struct {
+	Task   types.Hash
+	Result types.Hash
+
+	LossBefore uint64
+	LossAfter  uint64
+	Quality    uint64
+
+	Proof  []byte
+	System uint8 // Proof system of Proof
+}
+
+// Bytes encodes the proof for a block header's PoUWProof
+func (p *GradientProof) Bytes() []byte {
+	buf := make([]byte, 0, gradientProofHeaderSize+len(p.Proof))
+	buf = binary.BigEndian.AppendUint64(buf, p.LossBefore)
+	buf = binary.BigEndian.AppendUint64(buf, p.LossAfter)
+	buf = binary.BigEndian.AppendUint64(buf, p.Quality)
+	buf = append(buf, p.System)
+	return append(buf, p.Proof...)
+}
+
+// ParseGradientProof reads the gradient proof of a block header
+func ParseGradientProof(header *types.BlockHeader) (*GradientProof, error) {
+	data := header.PoUWProof
+	if len(data) <= gradientProofHeaderSize {
+		return nil, ErrGradientMalformed
+	}
+
+	return &GradientProof{
+		Task:       header.TaskID,
+		Result:     header.PoUWResult,
+		LossBefore: binary.BigEndian.Uint64(data[0:]),
+		LossAfter:  binary.BigEndian.Uint64(data[8:]),
+		Quality:    binary.BigEndian.Uint64(data[16:]),
+		System:     data[24],
+		Proof:      append([]byte(nil), data[gradientProofHeaderSize:]...),
+	}, nil
+}
+
+// GradientCircuit proves that the losses and quality score follow from
+// the weights, updated weights and sample committed to by Result. The
+// updated weights must lower the loss.
+type GradientCircuit struct {
+	// Public inputs
+	Task       frontend.Variable `gnark:",public"`
+	Result     frontend.Variable `gnark:",public"`
+	LossBefore frontend.Variable `gnark:",public"`
+	LossAfter  frontend.Variable `gnark:",public"`
+	Quality    frontend.Variable `gnark:",public"`
+
+	// Private inputs (witness)
+	Weights  [GradientFeatures]frontend.Variable
+	Updated  [GradientFeatures]frontend.Variable
+	Features [GradientSamples][GradientFeatures]frontend.Variable
+	Labels   [GradientSamples]frontend.Variable
+}
+
+// Define implements the circuit constraints
+func (c *GradientCircuit) Define(api frontend.API) error {
+	h, err := mimc.NewMiMC(api)
+	if err != nil {
+		return err
+	}
+	hash := func(data ...frontend.Variable) frontend.Variable {
+		h.Reset()
+		h.Write(data...)
+		return h.Sum()
+	}
+	inRange := func(v frontend.Variable) {
+		api.ToBinary(api.Add(v, 1<<(GradientValueBits-1)), GradientValueBits)
+	}
+
+	batch := make([]frontend.Variable, 0, GradientSamples*(GradientFeatures+1))
+	for i := range c.Features {
+		for j := range c.Features[i] {
+			inRange(c.Features[i][j])
+			batch = append(batch, c.Features[i][j])
+		}
+	}
+	for i := range c.Labels {
+		inRange(c.Labels[i])
+		batch = append(batch, c.Labels[i])
+	}
+	for j := range c.Weights {
+		inRange(c.Weights[j])
+		inRange(c.Updated[j])
+	}
+
+	result := hash(c.Task, hash(c.Weights[:]...), hash(c.Updated[:]...), hash(batch...))
+	api.AssertIsEqual(c.Result, result)
+
+	// Values are bounded, so products and sums stay far below the field
+	// order and negative errors square to their true value
+	loss := func(weights []frontend.Variable) frontend.Variable {
+		var total frontend.Variable = 0
+		for i := range c.Features {
+			e := api.Neg(c.Labels[i])
+			for j := range weights {
+				e = api.Add(e, api.Mul(weights[j], c.Features[i][j]))
+			}
+			total = api.Add(total, api.Mul(e, e))
+		}
+		return total
+	}
+	api.AssertIsEqual(c.LossBefore, loss(c.Weights[:]))
+	api.AssertIsEqual(c.LossAfter, loss(c.Updated[:]))
+
+	// Improvement gate: LossAfter < LossBefore
+	api.ToBinary(api.Sub(c.LossBefore, c.LossAfter, 1), 64)
+
+	// Quality = floor((LossBefore - LossAfter) * scale / LossBefore)
+	api.ToBinary(c.Quality, 32)
+	api.ToBinary(api.Sub(GradientQualityScale, c.Quality), 32)
+	remainder := api.Sub(
+		api.Mul(api.Sub(c.LossBefore, c.LossAfter), GradientQualityScale),
+		api.Mul(c.Quality, c.LossBefore),
+	)
+	api.ToBinary(remainder, 64)
+	api.ToBinary(api.Sub(c.LossBefore, remainder, 1), 64)
+
+	return nil
+}
+
+// newGradientCircuit returns the circuit with the public inputs of p and
+// every private input zero
+func newGradientCircuit(p *GradientProof) *GradientCircuit {
+	c := &GradientCircuit{
+		Task:       hashVariable(p.Task),
+		Result:     hashVariable(p.Result),
+		LossBefore: p.LossBefore,
+		LossAfter:  p.LossAfter,
+		Quality:    p.Quality,
+	}
+	for j := range c.Weights {
+		c.Weights[j], c.Updated[j] = 0, 0
+	}
+	for i := range c.Features {
+		for j := range c.Features[i] {
+			c.Features[i][j] = 0
+		}
+		c.Labels[i] = 0
+	}
+	return c
+}
+
+// CompileGradientCircuit compiles the PoUW gradient circuit
+func (cm *CircuitManager) CompileGradientCircuit() error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	_, err := cm.compileLocked(ProofTypeGradient, &GradientCircuit{})
+	return err
+}
+
+// ProveGradient proves a training step. The header built from the proof
+// carries work.Task, work.Result() and QualityScore(p.Quality).
+func (cm *CircuitManager) ProveGradient(ctx context.Context, work *GradientWork) (*GradientProof, error) {
+	if err := work.Validate(); err != nil {
+		return nil, err
+	}
+
+	p := &GradientProof{
+		Task:       work.Task,
+		Result:     work.Result(),
+		LossBefore: work.Loss(work.Weights),
+		LossAfter:  work.Loss(work.Updated),
+	}
+	if p.LossAfter >= p.LossBefore {
+		return nil, ErrGradientNoImprovement
+	}
+	p.Quality = GradientQuality(p.LossBefore, p.LossAfter)
+
+	circuit := newGradientCircuit(p)
+	for j := range work.Weights {
+		circuit.Weights[j] = work.Weights[j]
+		circuit.Updated[j] = work.Updated[j]
+	}
+	for i := range work.Features {
+		for j := range work.Features[i] {
+			circuit.Features[i][j] = work.Features[i][j]
+		}
+		circuit.Labels[i] = work.Labels[i]
+	}
+
+	proofData, err := cm.GenerateProof(ctx, ProofTypeGradient, circuit)
+	if err != nil {
+		return nil, err
+	}
+	p.Proof = proofData.Proof
+	p.System = proofData.System
+	return p, nil
+}
+
+// VerifyGradientProof verifies a gradient proof against its public inputs
+func (cm *CircuitManager) VerifyGradientProof(ctx context.Context, p *GradientProof) error {
+	cm.mu.RLock()
+	compiled, exists := cm.circuits[ProofTypeGradient]
+	cm.mu.RUnlock()
+	if !exists || !compiled.Compiled {
+		return ErrCircuitNotCompiled
+	}
+	if p.System != compiled.Backend.System() {
+		return ErrProofFailed
+	}
+
+	publicWitness, err := frontend.NewWitness(newGradientCircuit(p), ecc.BN254.ScalarField(), frontend.PublicOnly())
+	if err != nil {
+		return ErrInvalidPublicInputs
+	}
+
+	valid, err := cm.verify(ProofTypeGradient, p.Proof, publicWitness)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return ErrProofFailed
+	}
+	return nil
+}
+
+// VerifyPoUW checks that a block header's quality score is the one its
+// gradient proof establishes and verifies the proof
+func (cm *CircuitManager) VerifyPoUW(ctx context.Context, header *types.BlockHeader) error {
+	p, err := ParseGradientProof(header)
+	if err != nil {
+		return err
+	}
+	if header.QualityScore != QualityScore(p.Quality) {
+		return ErrGradientMismatch
+	}
+	return cm.VerifyGradientProof(ctx, p)
+}
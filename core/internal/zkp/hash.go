@@ -0,0 +1,51 @@
+package zkp
+
+import (
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
+	"github.com/consensys/gnark/frontend"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// fieldElement maps bytes to a BN254 scalar, reducing modulo the field
+// order
+func fieldElement(b []byte) fr.Element {
+	var e fr.Element
+	e.SetBytes(b)
+	return e
+}
+
+// uint64Element returns v as a BN254 scalar
+func uint64Element(v uint64) fr.Element {
+	var e fr.Element
+	e.SetUint64(v)
+	return e
+}
+
+// mimcHash hashes scalars with MiMC over BN254, matching the hash used
+// inside the transaction circuit
+func mimcHash(elems ...fr.Element) types.Hash {
+	h := mimc.NewMiMC()
+	for i := range elems {
+		b := elems[i].Bytes()
+		h.Write(b[:])
+	}
+
+	var result types.Hash
+	copy(result[:], h.Sum(nil))
+	return result
+}
+
+// scalarVariable returns bytes as a circuit witness value
+func scalarVariable(b []byte) frontend.Variable {
+	e := fieldElement(b)
+	return e.BigInt(new(big.Int))
+}
+
+// hashVariable returns a hash as a circuit witness value
+func hashVariable(h types.Hash) frontend.Variable {
+	return scalarVariable(h[:])
+}
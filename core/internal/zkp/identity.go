@@ -0,0 +1,353 @@
+package zkp
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	cryptomimc "github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
+	cryptoeddsa "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards/eddsa"
+	tedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/algebra/native/twistededwards"
+	"github.com/consensys/gnark/std/hash/mimc"
+	"github.com/consensys/gnark/std/signature/eddsa"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Identity disclosure errors
+var (
+	ErrUnknownAuthority          = errors.New("credential authority is not registered")
+	ErrInvalidAuthorityKey       = errors.New("invalid credential authority key")
+	ErrInvalidCredential         = errors.New("credential signature does not verify")
+	ErrCredentialRevoked         = errors.New("credential has been revoked")
+	ErrRevocationListUnavailable = errors.New("no revocation list to check against")
+	ErrRevocationRootMismatch    = errors.New("identity disclosure made against a different revocation list")
+	ErrIdentityMalformed         = errors.New("malformed identity disclosure")
+)
+
+// Credential is an authority's EdDSA signature, over BabyJubjub on BN254,
+// binding a holder to a serial. The serial is the credential's key in the
+// revocation tree.
+type Credential struct {
+	Authority types.Hash // Compressed public key of the issuer
+	Serial    types.Address
+	Holder    types.Hash // HolderID of the holder's secret
+	Signature []byte
+}
+
+// HolderID returns the identifier a credential is issued to for a
+// holder's secret
+func HolderID(secret []byte) types.Hash {
+	return mimcHash(fieldElement(secret))
+}
+
+// CredentialMessage returns the message an authority signs to issue a
+// credential
+func CredentialMessage(serial types.Address, holder types.Hash) types.Hash {
+	return mimcHash(fieldElement(serial[:]), fieldElement(holder[:]))
+}
+
+// AuthorityKey is the signing key of a credential authority
+type AuthorityKey struct {
+	key *cryptoeddsa.PrivateKey
+}
+
+// GenerateAuthorityKey creates a new authority signing key
+func GenerateAuthorityKey(r io.Reader) (*AuthorityKey, error) {
+	key, err := cryptoeddsa.GenerateKey(r)
+	if err != nil {
+		return nil, err
+	}
+	return &AuthorityKey{key: key}, nil
+}
+
+// PublicKey returns the key governance registers the authority under
+func (k *AuthorityKey) PublicKey() types.Hash {
+	var pub types.Hash
+	copy(pub[:], k.key.PublicKey.Bytes())
+	return pub
+}
+
+// Issue signs a credential with serial for holder
+func (k *AuthorityKey) Issue(serial types.Address, holder types.Hash) (*Credential, error) {
+	msg := CredentialMessage(serial, holder)
+	sig, err := k.key.Sign(msg[:], cryptomimc.NewMiMC())
+	if err != nil {
+		return nil, err
+	}
+	return &Credential{
+		Authority: k.PublicKey(),
+		Serial:    serial,
+		Holder:    holder,
+		Signature: sig,
+	}, nil
+}
+
+// ValidAuthorityKey reports whether key is a point on the curve
+// credentials are signed over
+func ValidAuthorityKey(key types.Hash) bool {
+	var pub cryptoeddsa.PublicKey
+	_, err := pub.SetBytes(key[:])
+	return err == nil
+}
+
+// VerifyCredential checks a credential's signature outside a circuit
+func VerifyCredential(cred *Credential) error {
+	var pub cryptoeddsa.PublicKey
+	if _, err := pub.SetBytes(cred.Authority[:]); err != nil {
+		return ErrInvalidAuthorityKey
+	}
+	msg := CredentialMessage(cred.Serial, cred.Holder)
+	valid, err := pub.Verify(cred.Signature, msg[:], cryptomimc.NewMiMC())
+	if err != nil || !valid {
+		return ErrInvalidCredential
+	}
+	return nil
+}
+
+// CredentialRegistry provides the authorities governance has registered
+// and the current credential revocation tree root
+type CredentialRegistry interface {
+	Authority(key types.Hash) (Authority, bool)
+	RevocationRoot() types.Hash
+}
+
+// IdentityDisclosureCircuit proves that the prover holds a credential
+// signed by Authority whose serial has an empty leaf in the revocation
+// tree. Neither the holder nor the serial is revealed; the commitment is
+// blinded and bound to one of the transaction's nullifiers so it cannot
+// be replayed on another transaction.
+type IdentityDisclosureCircuit struct {
+	// Public inputs
+	Authority            eddsa.PublicKey   `gnark:",public"`
+	CredentialCommitment frontend.Variable `gnark:",public"`
+	RevocationRoot       frontend.Variable `gnark:",public"`
+	Binding              frontend.Variable `gnark:",public"`
+
+	// Private inputs (witness)
+	Signature    eddsa.Signature
+	HolderSecret frontend.Variable
+	Serial       frontend.Variable
+	Blinder      frontend.Variable
+	Siblings     [SanctionsTreeDepth]frontend.Variable
+}
+
+// Define implements the circuit constraints
+func (c *IdentityDisclosureCircuit) Define(api frontend.API) error {
+	curve, err := twistededwards.NewEdCurve(api, tedwards.BN254)
+	if err != nil {
+		return err
+	}
+	h, err := mimc.NewMiMC(api)
+	if err != nil {
+		return err
+	}
+	hash := func(data ...frontend.Variable) frontend.Variable {
+		h.Reset()
+		h.Write(data...)
+		return h.Sum()
+	}
+
+	// The authority signed the serial for the holder of HolderSecret
+	msg := hash(c.Serial, hash(c.HolderSecret))
+	sigHash, err := mimc.NewMiMC(api)
+	if err != nil {
+		return err
+	}
+	if err := eddsa.Verify(curve, c.Signature, msg, c.Authority, &sigHash); err != nil {
+		return err
+	}
+
+	api.AssertIsEqual(c.CredentialCommitment, hash(msg, c.Blinder, c.Binding))
+
+	// Serial's leaf in the revocation tree is empty
+	bits := api.ToBinary(c.Serial, SanctionsTreeDepth)
+	var node frontend.Variable = 0
+	for level, sibling := range c.Siblings {
+		left := api.Select(bits[level], sibling, node)
+		right := api.Select(bits[level], node, sibling)
+		node = hash(left, right)
+	}
+	api.AssertIsEqual(node, c.RevocationRoot)
+
+	return nil
+}
+
+// newIdentityCircuit returns the circuit with the disclosure's public
+// inputs assigned. The authority key must be valid.
+func newIdentityCircuit(d *IdentityDisclosure) *IdentityDisclosureCircuit {
+	c := &IdentityDisclosureCircuit{
+		CredentialCommitment: hashVariable(d.CredentialCommitment),
+		RevocationRoot:       hashVariable(d.RevocationRoot),
+		Binding:              hashVariable(d.Binding),
+	}
+	c.Authority.Assign(tedwards.BN254, d.AuthorityPubKey[:])
+	return c
+}
+
+// CompileIdentityCircuit compiles the identity disclosure circuit
+func (cm *CircuitManager) CompileIdentityCircuit() error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	_, err := cm.compileLocked(ProofTypeIdentityDisclosure, &IdentityDisclosureCircuit{})
+	return err
+}
+
+// VerifyIdentityProof verifies an identity disclosure's proof against its
+// public inputs
+func (cm *CircuitManager) VerifyIdentityProof(ctx context.Context, d *IdentityDisclosure) error {
+	cm.mu.RLock()
+	compiled, exists := cm.circuits[ProofTypeIdentityDisclosure]
+	cm.mu.RUnlock()
+	if !exists || !compiled.Compiled {
+		return ErrCircuitNotCompiled
+	}
+	if d.System != compiled.Backend.System() || !ValidAuthorityKey(d.AuthorityPubKey) {
+		return ErrProofFailed
+	}
+
+	publicWitness, err := frontend.NewWitness(newIdentityCircuit(d), ecc.BN254.ScalarField(), frontend.PublicOnly())
+	if err != nil {
+		return ErrInvalidPublicInputs
+	}
+
+	valid, err := cm.verify(ProofTypeIdentityDisclosure, d.Proof, publicWitness)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return ErrProofFailed
+	}
+	return nil
+}
+
+// ParseIdentityDisclosure reads an identity disclosure attached to a
+// transaction
+func ParseIdentityDisclosure(disclosure *types.Disclosure) (*IdentityDisclosure, error) {
+	inputs := disclosure.Proof.PublicInputs
+	if len(inputs) != 3 || len(disclosure.PublicData) != types.HashSize {
+		return nil, ErrIdentityMalformed
+	}
+	d := &IdentityDisclosure{
+		CredentialCommitment: inputs[0],
+		RevocationRoot:       inputs[1],
+		Binding:              inputs[2],
+		Proof:                disclosure.Proof.ProofData,
+		System:               disclosure.Proof.ProofType,
+	}
+	copy(d.AuthorityPubKey[:], disclosure.PublicData)
+	return d, nil
+}
+
+// CreateIdentityDisclosure proves possession of cred, issued for
+// holderSecret, without revealing it. path is the serial's
+// non-membership path under the revocation root, and binding is the
+// nullifier of a note the transaction spends.
+func (dm *DisclosureManager) CreateIdentityDisclosure(
+	ctx context.Context,
+	cred *Credential,
+	holderSecret []byte,
+	root types.Hash,
+	path *SanctionsPath,
+	binding types.Hash,
+) (*IdentityDisclosure, error) {
+	if _, known := dm.Authority(cred.Authority); !known {
+		return nil, ErrUnknownAuthority
+	}
+	if HolderID(holderSecret) != cred.Holder {
+		return nil, ErrInvalidCredential
+	}
+	if err := VerifyCredential(cred); err != nil {
+		return nil, err
+	}
+	if !VerifyNonMembership(root, cred.Serial, path) {
+		return nil, ErrCredentialRevoked
+	}
+
+	var blinder [types.HashSize]byte
+	if _, err := rand.Read(blinder[:]); err != nil {
+		return nil, err
+	}
+	msg := CredentialMessage(cred.Serial, cred.Holder)
+	d := &IdentityDisclosure{
+		AuthorityPubKey:      cred.Authority,
+		CredentialCommitment: mimcHash(fieldElement(msg[:]), fieldElement(blinder[:]), fieldElement(binding[:])),
+		RevocationRoot:       root,
+		Binding:              binding,
+	}
+
+	circuit := newIdentityCircuit(d)
+	circuit.Signature.Assign(tedwards.BN254, cred.Signature)
+	circuit.HolderSecret = scalarVariable(holderSecret)
+	circuit.Serial = scalarVariable(cred.Serial[:])
+	circuit.Blinder = scalarVariable(blinder[:])
+	for i, sibling := range path.Siblings {
+		circuit.Siblings[i] = hashVariable(sibling)
+	}
+
+	proofData, err := dm.circuits.GenerateProof(ctx, ProofTypeIdentityDisclosure, circuit)
+	if err != nil {
+		return nil, err
+	}
+	d.Proof = proofData.Proof
+	d.System = proofData.System
+	return d, nil
+}
+
+// VerifyIdentityDisclosure verifies an identity disclosure against the
+// registered authorities and the current revocation list. Proofs against
+// an earlier list are rejected so a revoked credential cannot be used
+// with an old proof.
+func (dm *DisclosureManager) VerifyIdentityDisclosure(ctx context.Context, d *IdentityDisclosure) error {
+	if _, known := dm.Authority(d.AuthorityPubKey); !known {
+		return ErrUnknownAuthority
+	}
+
+	dm.mu.RLock()
+	credentials := dm.credentials
+	dm.mu.RUnlock()
+	if credentials == nil {
+		return ErrRevocationListUnavailable
+	}
+	if d.RevocationRoot != credentials.RevocationRoot() {
+		return ErrRevocationRootMismatch
+	}
+
+	if err := dm.circuits.VerifyIdentityProof(ctx, d); err != nil {
+		return ErrDisclosureProofInvalid
+	}
+	return nil
+}
+
+// Authority returns the authority registered under key, by governance or
+// locally
+func (dm *DisclosureManager) Authority(key types.Hash) (Authority, bool) {
+	dm.mu.RLock()
+	defer dm.mu.RUnlock()
+	return dm.authorityLocked(key)
+}
+
+// authorityLocked looks key up in the credential registry, then among
+// locally registered authorities. The caller holds dm.mu.
+func (dm *DisclosureManager) authorityLocked(key types.Hash) (Authority, bool) {
+	if dm.credentials != nil {
+		if authority, known := dm.credentials.Authority(key); known {
+			return authority, true
+		}
+	}
+	authority, known := dm.authorities[key]
+	return authority, known
+}
+
+// SetCredentialRegistry sets the registry identity disclosures are
+// checked against
+func (dm *DisclosureManager) SetCredentialRegistry(registry CredentialRegistry) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	dm.credentials = registry
+}
@@ -0,0 +1,256 @@
+package zkp
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/consensys/gnark/constraint"
+)
+
+// Key file errors
+var (
+	ErrKeyMismatch       = errors.New("key file does not match the compiled circuit")
+	ErrMalformedKeyFile  = errors.New("malformed key file")
+	ErrProvingKeyMissing = errors.New("no proving key for circuit")
+)
+
+// keyFileMagic starts every key file
+const keyFileMagic = "CCZKKEY1"
+
+// embeddedKeys holds the network's canonical verifying keys, generated
+// once with `ccoin-cli zkp setup` and checked in under keys/
+//
+//go:embed keys
+var embeddedKeys embed.FS
+
+// keySet is a circuit's keys as read from disk. The proving key is nil
+// for verify-only key sets.
+type keySet struct {
+	system uint8
+	digest [sha256.Size]byte
+	pk     ProvingKey
+	vk     VerifyingKey
+}
+
+// String returns the proof type's key file name
+func (pt ProofType) String() string {
+	switch pt {
+	case ProofTypeTransaction:
+		return "transaction"
+	case ProofTypeRangeDisclosure:
+		return "range"
+	case ProofTypeIdentityDisclosure:
+		return "identity"
+	case ProofTypeTemporalDisclosure:
+		return "temporal"
+	case ProofTypeSanctionsCompliance:
+		return "sanctions"
+	case ProofTypeAggregateDisclosure:
+		return "aggregate"
+	case ProofTypeGradient:
+		return "gradient"
+	default:
+		return "unknown"
+	}
+}
+
+// proofTypes lists every proof type that can have keys
+var proofTypes = []ProofType{
+	ProofTypeTransaction,
+	ProofTypeRangeDisclosure,
+	ProofTypeIdentityDisclosure,
+	ProofTypeTemporalDisclosure,
+	ProofTypeSanctionsCompliance,
+	ProofTypeAggregateDisclosure,
+	ProofTypeGradient,
+}
+
+// LoadKeys reads keys written by SaveKeys. Keys for circuits not yet
+// compiled are used when they are, instead of running setup. A directory
+// holding only verifying keys is enough for a node that does not prove.
+func (cm *CircuitManager) LoadKeys(dir string) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	for _, pt := range proofTypes {
+		ks, err := readKeySet(os.DirFS(dir), pt, cm.backendLocked(pt))
+		if err != nil {
+			return err
+		}
+		if ks == nil {
+			continue
+		}
+
+		if compiled, exists := cm.circuits[pt]; exists {
+			if err := cm.applyKeysLocked(pt, compiled, ks); err != nil {
+				return err
+			}
+			continue
+		}
+		cm.loadedKeys[pt] = ks
+	}
+	return nil
+}
+
+// SaveKeys writes the keys of every compiled circuit to dir, as
+// <name>.pk and <name>.vk
+func (cm *CircuitManager) SaveKeys(dir string) error {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for pt, compiled := range cm.circuits {
+		digest, err := circuitDigest(compiled.ConstraintSystem)
+		if err != nil {
+			return err
+		}
+		system := compiled.Backend.System()
+
+		vkPath := filepath.Join(dir, pt.String()+".vk")
+		if err := writeKeyFile(vkPath, system, digest, cm.verifyingKeys[pt]); err != nil {
+			return err
+		}
+		if pk := cm.provingKeys[pt]; pk != nil {
+			pkPath := filepath.Join(dir, pt.String()+".pk")
+			if err := writeKeyFile(pkPath, system, digest, pk); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// backendLocked returns the backend selected for a proof type
+func (cm *CircuitManager) backendLocked(pt ProofType) Backend {
+	if backend, exists := cm.backends[pt]; exists {
+		return backend
+	}
+	return Groth16Backend{}
+}
+
+// keysForLocked returns keys for a freshly compiled circuit: loaded keys
+// if any, else a matching canonical verifying key, else nil to run setup
+func (cm *CircuitManager) keysForLocked(pt ProofType, backend Backend, ccs constraint.ConstraintSystem) (*keySet, error) {
+	digest, err := circuitDigest(ccs)
+	if err != nil {
+		return nil, err
+	}
+
+	if ks, exists := cm.loadedKeys[pt]; exists {
+		if ks.system != backend.System() || ks.digest != digest {
+			return nil, ErrKeyMismatch
+		}
+		return ks, nil
+	}
+
+	// A canonical key for an older circuit is ignored so development
+	// builds with changed circuits still start
+	ks, err := readKeySet(embeddedKeys, pt, backend)
+	if err != nil || ks == nil || ks.system != backend.System() || ks.digest != digest {
+		return nil, nil
+	}
+	return ks, nil
+}
+
+// applyKeysLocked replaces a compiled circuit's keys with loaded ones
+func (cm *CircuitManager) applyKeysLocked(pt ProofType, compiled *CompiledCircuit, ks *keySet) error {
+	digest, err := circuitDigest(compiled.ConstraintSystem)
+	if err != nil {
+		return err
+	}
+	if ks.system != compiled.Backend.System() || ks.digest != digest {
+		return ErrKeyMismatch
+	}
+
+	cm.verifyingKeys[pt] = ks.vk
+	if ks.pk != nil {
+		cm.provingKeys[pt] = ks.pk
+	} else {
+		delete(cm.provingKeys, pt)
+	}
+	return nil
+}
+
+// circuitDigest fingerprints a constraint system, so keys are only used
+// with the circuit they were generated for
+func circuitDigest(ccs constraint.ConstraintSystem) ([sha256.Size]byte, error) {
+	h := sha256.New()
+	if _, err := ccs.WriteTo(h); err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	var digest [sha256.Size]byte
+	copy(digest[:], h.Sum(nil))
+	return digest, nil
+}
+
+// readKeySet reads a proof type's key files from fsys. It returns nil
+// if there is no verifying key.
+func readKeySet(fsys fs.FS, pt ProofType, backend Backend) (*keySet, error) {
+	pk, vk := backend.NewKeys()
+
+	ks := &keySet{vk: vk}
+	found, err := readKeyFile(fsys, pt.String()+".vk", ks, vk)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	pkSet := &keySet{}
+	found, err = readKeyFile(fsys, pt.String()+".pk", pkSet, pk)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		if pkSet.system != ks.system || pkSet.digest != ks.digest {
+			return nil, ErrKeyMismatch
+		}
+		ks.pk = pk
+	}
+	return ks, nil
+}
+
+// readKeyFile reads a key file's header into ks and its key into key
+func readKeyFile(fsys fs.FS, name string, ks *keySet, key io.ReaderFrom) (bool, error) {
+	f, err := fsys.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	header := make([]byte, len(keyFileMagic)+1+sha256.Size)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(keyFileMagic)]) != keyFileMagic {
+		return false, ErrMalformedKeyFile
+	}
+	ks.system = header[len(keyFileMagic)]
+	copy(ks.digest[:], header[len(keyFileMagic)+1:])
+
+	if _, err := key.ReadFrom(r); err != nil {
+		return false, ErrMalformedKeyFile
+	}
+	return true, nil
+}
+
+// writeKeyFile writes a key with its circuit header
+func writeKeyFile(path string, system uint8, digest [sha256.Size]byte, key io.WriterTo) error {
+	var buf bytes.Buffer
+	buf.WriteString(keyFileMagic)
+	buf.WriteByte(system)
+	buf.Write(digest[:])
+	if _, err := key.WriteTo(&buf); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
@@ -3,7 +3,6 @@ package zkp
 
 import (
 	"context"
-	"crypto/sha256"
 	"errors"
 	"sync"
 
@@ -21,7 +20,10 @@ var (
 // TreeDepth is the fixed depth of the commitment tree
 const TreeDepth = 32
 
-// CommitmentTree is a Merkle tree for storing note commitments
+// CommitmentTree is an incremental Merkle tree of note commitments.
+// Appends only touch the right-most frontier held in memory; the nodes
+// they change are buffered and written to the store by Flush, once per
+// block.
 type CommitmentTree struct {
 	mu sync.RWMutex
 
@@ -37,8 +39,16 @@ type CommitmentTree struct {
 	// Store for persistence
 	store TreeStore
 
-	// Cache of tree nodes
-	nodeCache map[uint64]types.Hash
+	// frontier[level] is the last complete left node at each level,
+	// waiting for its right sibling
+	frontier []types.Hash
+
+	// zeros[level] is the root of an empty subtree of height level
+	zeros []types.Hash
+
+	// Nodes and leaf positions changed since the last Flush
+	pending   map[treeNodeKey]types.Hash
+	positions map[types.Hash]uint64
 }
 
 // TreeStore defines the interface for Merkle tree persistence
@@ -62,6 +72,33 @@ type TreeStore interface {
 	SetSize(ctx context.Context, size uint64) error
 }
 
+// BatchTreeStore is implemented by stores that can write many nodes in
+// one round trip
+type BatchTreeStore interface {
+	TreeStore
+
+	// SetNodes stores nodes together
+	SetNodes(ctx context.Context, nodes []TreeNode) error
+}
+
+// LeafIndex is implemented by stores that index leaves by commitment
+type LeafIndex interface {
+	// GetLeafPosition returns the position of a leaf, or ErrLeafNotFound
+	GetLeafPosition(ctx context.Context, commitment types.Hash) (uint64, error)
+}
+
+// TreeNode is a node write
+type TreeNode struct {
+	Level uint64
+	Index uint64
+	Hash  types.Hash
+}
+
+type treeNodeKey struct {
+	level uint64
+	index uint64
+}
+
 // MerklePath represents a path from a leaf to the root
 type MerklePath struct {
 	// Siblings are the sibling hashes along the path
@@ -80,39 +117,63 @@ func NewCommitmentTree(store TreeStore, depth int) *CommitmentTree {
 		depth = TreeDepth
 	}
 
+	zeros := make([]types.Hash, depth+1)
+	zeros[0] = types.EmptyHash
+	for level := 1; level <= depth; level++ {
+		zeros[level] = hashPair(zeros[level-1], zeros[level-1])
+	}
+
 	return &CommitmentTree{
 		depth:     depth,
-		nodeCache: make(map[uint64]types.Hash),
+		root:      zeros[depth],
 		store:     store,
+		frontier:  make([]types.Hash, depth),
+		zeros:     zeros,
+		pending:   make(map[treeNodeKey]types.Hash),
+		positions: make(map[types.Hash]uint64),
 	}
 }
 
-// Initialize loads the tree state from storage
+// Initialize loads the tree state from storage, reading the frontier
+// once instead of on every append
 func (ct *CommitmentTree) Initialize(ctx context.Context) error {
 	ct.mu.Lock()
 	defer ct.mu.Unlock()
 
-	root, err := ct.store.GetRoot(ctx)
-	if err != nil {
+	size, err := ct.store.GetSize(ctx)
+	if err != nil || size == 0 {
 		// Start with empty tree
 		ct.root = ct.emptyRoot()
 		ct.size = 0
 		return nil
 	}
 
-	ct.root = root
-
-	size, err := ct.store.GetSize(ctx)
+	root, err := ct.store.GetRoot(ctx)
 	if err != nil {
-		ct.size = 0
-	} else {
-		ct.size = size
+		return err
 	}
 
+	// A level's frontier node is set when the next append there is a
+	// right child, i.e. when that bit of the size is set
+	for level := 0; level < ct.depth; level++ {
+		index := size >> uint(level)
+		if index&1 == 0 {
+			continue
+		}
+		node, err := ct.store.GetNode(ctx, uint64(level), index-1)
+		if err != nil {
+			return err
+		}
+		ct.frontier[level] = node
+	}
+
+	ct.root = root
+	ct.size = size
 	return nil
 }
 
-// AddCommitment adds a new commitment to the tree
+// AddCommitment appends a commitment. It touches no storage; call Flush
+// to persist the changed nodes.
 func (ct *CommitmentTree) AddCommitment(ctx context.Context, commitment types.Hash) (uint64, error) {
 	ct.mu.Lock()
 	defer ct.mu.Unlock()
@@ -123,54 +184,104 @@ func (ct *CommitmentTree) AddCommitment(ctx context.Context, commitment types.Ha
 	}
 
 	position := ct.size
+	ct.pending[treeNodeKey{0, position}] = commitment
+	ct.positions[commitment] = position
+
+	ct.root = appendLeaf(ct.frontier, ct.zeros, position, commitment, func(level, index uint64, hash types.Hash) {
+		ct.pending[treeNodeKey{level, index}] = hash
+	})
 	ct.size++
+	return position, nil
+}
 
-	// Insert at leaf level
-	if err := ct.store.SetNode(ctx, 0, position, commitment); err != nil {
-		ct.size--
-		return 0, err
-	}
+// rootAfter returns the root the tree would have after appending
+// commitments, leaving the tree unchanged
+func (ct *CommitmentTree) rootAfter(commitments []types.Hash) (types.Hash, error) {
+	ct.mu.RLock()
+	defer ct.mu.RUnlock()
 
-	// Update path to root
-	currentHash := commitment
-	currentIndex := position
+	if uint64(len(commitments)) > (uint64(1)<<ct.depth)-ct.size {
+		return types.Hash{}, ErrTreeFull
+	}
 
-	for level := 0; level < ct.depth; level++ {
-		siblingIndex := currentIndex ^ 1 // XOR with 1 to get sibling
-		siblingHash, err := ct.store.GetNode(ctx, uint64(level), siblingIndex)
-		if err != nil {
-			// Sibling doesn't exist, use empty hash
-			siblingHash = ct.emptyHash(level)
-		}
+	frontier := append([]types.Hash(nil), ct.frontier...)
+	root := ct.root
+	for i, commitment := range commitments {
+		root = appendLeaf(frontier, ct.zeros, ct.size+uint64(i), commitment, nil)
+	}
+	return root, nil
+}
 
-		var newHash types.Hash
+// appendLeaf adds the leaf at position to a tree's right-most frontier and
+// returns the new root. Each node on the path is passed to visit, if set.
+func appendLeaf(frontier, zeros []types.Hash, position uint64, leaf types.Hash, visit func(level, index uint64, hash types.Hash)) types.Hash {
+	// Walk up the right edge. Nodes with a right sibling still to come
+	// are hashed with the empty subtree and rewritten later.
+	currentHash := leaf
+	currentIndex := position
+	for level := 0; level < len(frontier); level++ {
 		if currentIndex%2 == 0 {
 			// Current is left child
-			newHash = hashPair(currentHash, siblingHash)
+			frontier[level] = currentHash
+			currentHash = hashPair(currentHash, zeros[level])
 		} else {
 			// Current is right child
-			newHash = hashPair(siblingHash, currentHash)
+			currentHash = hashPair(frontier[level], currentHash)
 		}
 
-		// Move to parent
 		currentIndex /= 2
-		currentHash = newHash
+		if visit != nil {
+			visit(uint64(level+1), currentIndex, currentHash)
+		}
+	}
+	return currentHash
+}
+
+// Flush writes the nodes changed since the last flush, then the root and
+// size, so a crash leaves the stored tree at the last flushed block
+func (ct *CommitmentTree) Flush(ctx context.Context) error {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
 
-		// Store the new hash at parent level
-		if err := ct.store.SetNode(ctx, uint64(level+1), currentIndex, currentHash); err != nil {
-			return 0, err
+	if len(ct.pending) == 0 {
+		return nil
+	}
+
+	nodes := make([]TreeNode, 0, len(ct.pending))
+	for key, hash := range ct.pending {
+		nodes = append(nodes, TreeNode{Level: key.level, Index: key.index, Hash: hash})
+	}
+
+	if batch, ok := ct.store.(BatchTreeStore); ok {
+		if err := batch.SetNodes(ctx, nodes); err != nil {
+			return err
+		}
+	} else {
+		for _, node := range nodes {
+			if err := ct.store.SetNode(ctx, node.Level, node.Index, node.Hash); err != nil {
+				return err
+			}
 		}
 	}
 
-	ct.root = currentHash
 	if err := ct.store.SetRoot(ctx, ct.root); err != nil {
-		return 0, err
+		return err
 	}
 	if err := ct.store.SetSize(ctx, ct.size); err != nil {
-		return 0, err
+		return err
 	}
 
-	return position, nil
+	ct.pending = make(map[treeNodeKey]types.Hash)
+	ct.positions = make(map[types.Hash]uint64)
+	return nil
+}
+
+// getNodeLocked reads a node, preferring unflushed writes
+func (ct *CommitmentTree) getNodeLocked(ctx context.Context, level, index uint64) (types.Hash, error) {
+	if hash, exists := ct.pending[treeNodeKey{level, index}]; exists {
+		return hash, nil
+	}
+	return ct.store.GetNode(ctx, level, index)
 }
 
 // GetRoot returns the current Merkle root
@@ -202,9 +313,9 @@ func (ct *CommitmentTree) GetPath(ctx context.Context, position uint64) (*Merkle
 	currentIndex := position
 	for level := 0; level < ct.depth; level++ {
 		siblingIndex := currentIndex ^ 1
-		siblingHash, err := ct.store.GetNode(ctx, uint64(level), siblingIndex)
+		siblingHash, err := ct.getNodeLocked(ctx, uint64(level), siblingIndex)
 		if err != nil {
-			siblingHash = ct.emptyHash(level)
+			siblingHash = ct.zeros[level]
 		}
 
 		siblings[level] = siblingHash
@@ -222,12 +333,21 @@ func (ct *CommitmentTree) GetPath(ctx context.Context, position uint64) (*Merkle
 
 // VerifyPath verifies a Merkle path leads to the expected root
 func (ct *CommitmentTree) VerifyPath(leaf types.Hash, path *MerklePath, expectedRoot types.Hash) bool {
-	if len(path.Siblings) != ct.depth || len(path.PathBits) != ct.depth {
+	if len(path.Siblings) != ct.depth {
+		return false
+	}
+	return VerifyMerklePath(leaf, path, expectedRoot)
+}
+
+// VerifyMerklePath verifies a Merkle path leads to the expected root
+// without a tree at hand, taking the depth from the path
+func VerifyMerklePath(leaf types.Hash, path *MerklePath, expectedRoot types.Hash) bool {
+	if path == nil || len(path.Siblings) != len(path.PathBits) {
 		return false
 	}
 
 	currentHash := leaf
-	for i := 0; i < ct.depth; i++ {
+	for i := range path.Siblings {
 		if path.PathBits[i] {
 			// Current is right child
 			currentHash = hashPair(path.Siblings[i], currentHash)
@@ -240,13 +360,29 @@ func (ct *CommitmentTree) VerifyPath(leaf types.Hash, path *MerklePath, expected
 	return currentHash == expectedRoot
 }
 
-// ContainsCommitment checks if a commitment exists in the tree
+// ContainsCommitment checks if a commitment exists in the tree. Without
+// a store leaf index this falls back to scanning every leaf.
 func (ct *CommitmentTree) ContainsCommitment(ctx context.Context, commitment types.Hash) (bool, uint64, error) {
 	ct.mu.RLock()
 	defer ct.mu.RUnlock()
 
+	if position, exists := ct.positions[commitment]; exists {
+		return true, position, nil
+	}
+
+	if index, ok := ct.store.(LeafIndex); ok {
+		position, err := index.GetLeafPosition(ctx, commitment)
+		if errors.Is(err, ErrLeafNotFound) {
+			return false, 0, nil
+		}
+		if err != nil {
+			return false, 0, err
+		}
+		return position < ct.size, position, nil
+	}
+
 	for i := uint64(0); i < ct.size; i++ {
-		leaf, err := ct.store.GetNode(ctx, 0, i)
+		leaf, err := ct.getNodeLocked(ctx, 0, i)
 		if err != nil {
 			continue
 		}
@@ -258,45 +394,31 @@ func (ct *CommitmentTree) ContainsCommitment(ctx context.Context, commitment typ
 	return false, 0, nil
 }
 
-// emptyHash returns the empty hash for a given tree level
-func (ct *CommitmentTree) emptyHash(level int) types.Hash {
-	if level == 0 {
-		return types.EmptyHash
-	}
-
-	// Recursively compute: H(empty[level-1] || empty[level-1])
-	childEmpty := ct.emptyHash(level - 1)
-	return hashPair(childEmpty, childEmpty)
-}
-
 // emptyRoot returns the root of an empty tree
 func (ct *CommitmentTree) emptyRoot() types.Hash {
-	return ct.emptyHash(ct.depth)
+	return ct.zeros[ct.depth]
 }
 
-// hashPair hashes two hashes together
+// hashPair hashes two hashes together with MiMC so that paths can be
+// checked inside the transaction circuit
 func hashPair(left, right types.Hash) types.Hash {
-	hasher := sha256.New()
-	hasher.Write(left[:])
-	hasher.Write(right[:])
-
-	var result types.Hash
-	copy(result[:], hasher.Sum(nil))
-	return result
+	return mimcHash(fieldElement(left[:]), fieldElement(right[:]))
 }
 
 // InMemoryTreeStore is a simple in-memory tree store for testing
 type InMemoryTreeStore struct {
-	mu    sync.RWMutex
-	nodes map[uint64]map[uint64]types.Hash // level -> index -> hash
-	root  types.Hash
-	size  uint64
+	mu        sync.RWMutex
+	nodes     map[uint64]map[uint64]types.Hash // level -> index -> hash
+	positions map[types.Hash]uint64
+	root      types.Hash
+	size      uint64
 }
 
 // NewInMemoryTreeStore creates a new in-memory tree store
 func NewInMemoryTreeStore() *InMemoryTreeStore {
 	return &InMemoryTreeStore{
-		nodes: make(map[uint64]map[uint64]types.Hash),
+		nodes:     make(map[uint64]map[uint64]types.Hash),
+		positions: make(map[types.Hash]uint64),
 	}
 }
 
@@ -323,11 +445,41 @@ func (s *InMemoryTreeStore) SetNode(ctx context.Context, level, index uint64, ha
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	s.setNodeLocked(level, index, hash)
+	return nil
+}
+
+// SetNodes stores nodes together
+func (s *InMemoryTreeStore) SetNodes(ctx context.Context, nodes []TreeNode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, node := range nodes {
+		s.setNodeLocked(node.Level, node.Index, node.Hash)
+	}
+	return nil
+}
+
+func (s *InMemoryTreeStore) setNodeLocked(level, index uint64, hash types.Hash) {
 	if s.nodes[level] == nil {
 		s.nodes[level] = make(map[uint64]types.Hash)
 	}
 	s.nodes[level][index] = hash
-	return nil
+	if level == 0 {
+		s.positions[hash] = index
+	}
+}
+
+// GetLeafPosition returns the position of a leaf
+func (s *InMemoryTreeStore) GetLeafPosition(ctx context.Context, commitment types.Hash) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	position, exists := s.positions[commitment]
+	if !exists {
+		return 0, ErrLeafNotFound
+	}
+	return position, nil
 }
 
 // GetRoot returns the root
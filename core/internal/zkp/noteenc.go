@@ -0,0 +1,209 @@
+package zkp
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Note encryption errors
+var (
+	ErrInvalidTransmissionKey = errors.New("invalid transmission key")
+	ErrNoteNotDecrypted       = errors.New("note not encrypted to this key")
+)
+
+// NoteKeySize is the size of viewing and transmission keys
+const NoteKeySize = 32
+
+// noteBlinderSize is the size of the blinder carried in a note
+const noteBlinderSize = 32
+
+// outCiphertextSize is the size of the sender's sealed ephemeral secret
+// and recipient key: two keys plus the GCM tag
+const outCiphertextSize = 2*NoteKeySize + 16
+
+// NotePlaintext is the note opening encrypted to its recipient in
+// Commitment.EncryptedNote
+type NotePlaintext struct {
+	Value   uint64
+	Address types.Address
+	Blinder []byte
+	Memo    []byte
+}
+
+// TransmissionKey returns the public key notes are encrypted to for the
+// holder of viewingKey
+func TransmissionKey(viewingKey []byte) ([]byte, error) {
+	priv, err := ecdh.X25519().NewPrivateKey(viewingKey)
+	if err != nil {
+		return nil, ErrInvalidTransmissionKey
+	}
+	return priv.PublicKey().Bytes(), nil
+}
+
+// EncryptNote encrypts a note opening to a transmission key. The result is
+// an ephemeral X25519 public key, the AES-GCM sealed opening, and the
+// ephemeral secret and recipient key sealed under the sender's outgoing
+// viewing key. Without one the last part is random.
+func EncryptNote(transmissionKey []byte, note *NotePlaintext, outgoingViewingKey []byte) ([]byte, error) {
+	if len(note.Blinder) != noteBlinderSize {
+		return nil, ErrInvalidNote
+	}
+	pub, err := ecdh.X25519().NewPublicKey(transmissionKey)
+	if err != nil {
+		return nil, ErrInvalidTransmissionKey
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := ephemeral.ECDH(pub)
+	if err != nil {
+		return nil, ErrInvalidTransmissionKey
+	}
+
+	epk := ephemeral.PublicKey().Bytes()
+	aead, err := noteCipher("CCOIN_NOTE_ENCRYPTION", shared, epk)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := make([]byte, 0, 8+types.AddressSize+noteBlinderSize+len(note.Memo))
+	plaintext = binary.BigEndian.AppendUint64(plaintext, note.Value)
+	plaintext = append(plaintext, note.Address[:]...)
+	plaintext = append(plaintext, note.Blinder...)
+	plaintext = append(plaintext, note.Memo...)
+
+	// Each note has a fresh key, so a fixed nonce is safe
+	nonce := make([]byte, aead.NonceSize())
+	out := aead.Seal(epk, nonce, plaintext, nil)
+
+	if outgoingViewingKey == nil {
+		filler, err := RandomBytes(outCiphertextSize)
+		if err != nil {
+			return nil, err
+		}
+		return append(out, filler...), nil
+	}
+	outAEAD, err := noteCipher("CCOIN_OUT_ENCRYPTION", outgoingViewingKey, epk)
+	if err != nil {
+		return nil, err
+	}
+	secrets := append(ephemeral.Bytes(), transmissionKey...)
+	return outAEAD.Seal(out, nonce, secrets, nil), nil
+}
+
+// DecryptNote trial-decrypts an encrypted note with a viewing key. It
+// returns ErrNoteNotDecrypted for notes sent to anyone else.
+func DecryptNote(viewingKey []byte, data []byte) (*NotePlaintext, error) {
+	priv, err := ecdh.X25519().NewPrivateKey(viewingKey)
+	if err != nil {
+		return nil, ErrInvalidTransmissionKey
+	}
+	if len(data) < NoteKeySize+outCiphertextSize {
+		return nil, ErrNoteNotDecrypted
+	}
+	pub, err := ecdh.X25519().NewPublicKey(data[:NoteKeySize])
+	if err != nil {
+		return nil, ErrNoteNotDecrypted
+	}
+	shared, err := priv.ECDH(pub)
+	if err != nil {
+		return nil, ErrNoteNotDecrypted
+	}
+	return openNote(shared, data)
+}
+
+// RecoverNote decrypts a note this sender created, using the outgoing
+// viewing key it was encrypted with. It also returns the ephemeral secret
+// and the recipient's transmission key, which let a third party decrypt
+// the note.
+func RecoverNote(outgoingViewingKey []byte, data []byte) (*NotePlaintext, []byte, []byte, error) {
+	if len(data) < NoteKeySize+outCiphertextSize {
+		return nil, nil, nil, ErrNoteNotDecrypted
+	}
+	epk := data[:NoteKeySize]
+	outAEAD, err := noteCipher("CCOIN_OUT_ENCRYPTION", outgoingViewingKey, epk)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	nonce := make([]byte, outAEAD.NonceSize())
+	secrets, err := outAEAD.Open(nil, nonce, data[len(data)-outCiphertextSize:], nil)
+	if err != nil {
+		return nil, nil, nil, ErrNoteNotDecrypted
+	}
+
+	esk, tk := secrets[:NoteKeySize], secrets[NoteKeySize:]
+	note, err := OpenNoteWithSecret(esk, tk, data)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return note, esk, tk, nil
+}
+
+// OpenNoteWithSecret decrypts a note from the sender's ephemeral secret
+// and the recipient's transmission key
+func OpenNoteWithSecret(ephemeralSecret, transmissionKey []byte, data []byte) (*NotePlaintext, error) {
+	if len(data) < NoteKeySize+outCiphertextSize {
+		return nil, ErrNoteNotDecrypted
+	}
+	ephemeral, err := ecdh.X25519().NewPrivateKey(ephemeralSecret)
+	if err != nil || !bytes.Equal(ephemeral.PublicKey().Bytes(), data[:NoteKeySize]) {
+		return nil, ErrNoteNotDecrypted
+	}
+	pub, err := ecdh.X25519().NewPublicKey(transmissionKey)
+	if err != nil {
+		return nil, ErrInvalidTransmissionKey
+	}
+	shared, err := ephemeral.ECDH(pub)
+	if err != nil {
+		return nil, ErrNoteNotDecrypted
+	}
+	return openNote(shared, data)
+}
+
+// openNote decrypts the note opening with the shared secret
+func openNote(shared, data []byte) (*NotePlaintext, error) {
+	epk := data[:NoteKeySize]
+	aead, err := noteCipher("CCOIN_NOTE_ENCRYPTION", shared, epk)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	sealed := data[NoteKeySize : len(data)-outCiphertextSize]
+	plaintext, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil || len(plaintext) < 8+types.AddressSize+noteBlinderSize {
+		return nil, ErrNoteNotDecrypted
+	}
+
+	note := &NotePlaintext{Value: binary.BigEndian.Uint64(plaintext)}
+	copy(note.Address[:], plaintext[8:])
+	rest := plaintext[8+types.AddressSize:]
+	note.Blinder = rest[:noteBlinderSize]
+	if memo := rest[noteBlinderSize:]; len(memo) > 0 {
+		note.Memo = memo
+	}
+	return note, nil
+}
+
+// noteCipher derives a note encryption cipher from a domain, a secret and
+// the ephemeral public key
+func noteCipher(domain string, secret, epk []byte) (cipher.AEAD, error) {
+	hasher := sha256.New()
+	hasher.Write([]byte(domain))
+	hasher.Write(secret)
+	hasher.Write(epk)
+
+	block, err := aes.NewCipher(hasher.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -14,6 +14,7 @@ import (
 var (
 	ErrNullifierSpent   = errors.New("nullifier already spent")
 	ErrNullifierInvalid = errors.New("invalid nullifier")
+	ErrNullifierRepeat  = errors.New("nullifier listed twice")
 )
 
 // NullifierSet tracks spent nullifiers to prevent double-spending
@@ -28,6 +29,9 @@ type NullifierSet struct {
 
 	// Cache size limit
 	maxCacheSize int
+
+	// Filters PreCheck rules nullifiers out with (optional)
+	filters *NullifierFilters
 }
 
 // NullifierStore defines the interface for persistent nullifier storage
@@ -42,6 +46,26 @@ type NullifierStore interface {
 	GetNullifierInfo(ctx context.Context, nullifier types.Hash) (*NullifierInfo, error)
 }
 
+// BatchNullifierStore is implemented by stores that can look up many
+// nullifiers in one round trip
+type BatchNullifierStore interface {
+	NullifierStore
+
+	// HasNullifiers reports, for each nullifier, whether it has been spent
+	HasNullifiers(ctx context.Context, nullifiers []types.Hash) ([]bool, error)
+}
+
+// SpendStore is implemented by stores that can record a transaction's
+// spent nullifiers and the commitment tree root it leaves in one write
+type SpendStore interface {
+	NullifierStore
+	AnchorStore
+
+	// AddSpends marks nullifiers spent by txHash and, if anchor is set,
+	// records it. Either everything is stored or nothing is.
+	AddSpends(ctx context.Context, nullifiers []types.Hash, txHash types.Hash, blockHeight uint64, anchor *types.Hash) error
+}
+
 // NullifierInfo contains information about a spent nullifier
 type NullifierInfo struct {
 	Nullifier   types.Hash
@@ -90,6 +114,47 @@ func (ns *NullifierSet) IsSpent(ctx context.Context, nullifier types.Hash) (bool
 	return ns.store.HasNullifier(ctx, nullifier)
 }
 
+// SetFilters sets the nullifier filters PreCheck uses
+func (ns *NullifierSet) SetFilters(filters *NullifierFilters) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.filters = filters
+}
+
+// PreCheck reports, for each nullifier, whether it has been spent, only
+// looking up those the filters cannot rule out. The filters follow the
+// main chain as this node last saw it, so PreCheck screens transactions
+// before they are admitted; blocks are checked with BatchCheck.
+func (ns *NullifierSet) PreCheck(ctx context.Context, nullifiers []types.Hash) ([]bool, error) {
+	ns.mu.RLock()
+	filters := ns.filters
+	ns.mu.RUnlock()
+	if filters == nil {
+		return ns.BatchCheck(ctx, nullifiers)
+	}
+
+	var maybe []types.Hash
+	var positions []int
+	for i, nullifier := range nullifiers {
+		if filters.MaybeSpent(nullifier) {
+			maybe = append(maybe, nullifier)
+			positions = append(positions, i)
+		}
+	}
+	results := make([]bool, len(nullifiers))
+	if len(maybe) == 0 {
+		return results, nil
+	}
+	spent, err := ns.BatchCheck(ctx, maybe)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range positions {
+		results[i] = spent[j]
+	}
+	return results, nil
+}
+
 // MarkSpent marks a nullifier as spent
 func (ns *NullifierSet) MarkSpent(ctx context.Context, nullifier types.Hash, txHash types.Hash, blockHeight uint64) error {
 	// Check if already spent
@@ -106,27 +171,62 @@ func (ns *NullifierSet) MarkSpent(ctx context.Context, nullifier types.Hash, txH
 		return err
 	}
 
-	// Add to cache
+	ns.addToCache(nullifier)
+	return nil
+}
+
+// addToCache caches nullifiers already written to the store
+func (ns *NullifierSet) addToCache(nullifiers ...types.Hash) {
 	ns.mu.Lock()
-	ns.cache[nullifier] = struct{}{}
-
-	// Evict if cache is too large (simple random eviction)
-	if len(ns.cache) > ns.maxCacheSize {
-		// Remove first item found (not truly random but simple)
-		for k := range ns.cache {
-			delete(ns.cache, k)
-			break
+	defer ns.mu.Unlock()
+
+	for _, nullifier := range nullifiers {
+		ns.cache[nullifier] = struct{}{}
+
+		// Evict if cache is too large (simple random eviction)
+		if len(ns.cache) > ns.maxCacheSize {
+			// Remove first item found (not truly random but simple)
+			for k := range ns.cache {
+				delete(ns.cache, k)
+				break
+			}
 		}
 	}
-	ns.mu.Unlock()
-
-	return nil
 }
 
-// BatchCheck checks multiple nullifiers at once
+// BatchCheck checks multiple nullifiers at once. Nullifiers not in the
+// cache are looked up together if the store supports it.
 func (ns *NullifierSet) BatchCheck(ctx context.Context, nullifiers []types.Hash) ([]bool, error) {
 	results := make([]bool, len(nullifiers))
 
+	if batch, ok := ns.store.(BatchNullifierStore); ok {
+		var missing []types.Hash
+		var positions []int
+
+		ns.mu.RLock()
+		for i, nullifier := range nullifiers {
+			if _, inCache := ns.cache[nullifier]; inCache {
+				results[i] = true
+				continue
+			}
+			missing = append(missing, nullifier)
+			positions = append(positions, i)
+		}
+		ns.mu.RUnlock()
+
+		if len(missing) == 0 {
+			return results, nil
+		}
+		spent, err := batch.HasNullifiers(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range positions {
+			results[i] = spent[j]
+		}
+		return results, nil
+	}
+
 	for i, nullifier := range nullifiers {
 		spent, err := ns.IsSpent(ctx, nullifier)
 		if err != nil {
@@ -139,16 +239,9 @@ func (ns *NullifierSet) BatchCheck(ctx context.Context, nullifiers []types.Hash)
 }
 
 // DeriveNullifier derives a nullifier from a spending key and note
-// nullifier = H(spending_key || commitment || position)
+// nullifier = MiMC(spending_key, commitment, position)
 func DeriveNullifier(spendingKey []byte, commitment types.Hash, position uint64) types.Hash {
-	hasher := sha256.New()
-	hasher.Write(spendingKey)
-	hasher.Write(commitment[:])
-	hasher.Write(uint64ToBytes(position))
-
-	var nullifier types.Hash
-	copy(nullifier[:], hasher.Sum(nil))
-	return nullifier
+	return mimcHash(fieldElement(spendingKey), fieldElement(commitment[:]), uint64Element(position))
 }
 
 // DeriveNullifierFromNote derives nullifier from note components
@@ -160,18 +253,23 @@ func DeriveNullifierFromNote(
 	position uint64,
 ) types.Hash {
 	// First compute the note commitment
-	hasher := sha256.New()
-	hasher.Write(uint64ToBytes(value))
-	hasher.Write(blinder)
-	hasher.Write(address[:])
-
-	var commitment types.Hash
-	copy(commitment[:], hasher.Sum(nil))
+	commitment := NoteCommitment(value, address, blinder)
 
 	// Then derive nullifier
 	return DeriveNullifier(spendingKey, commitment, position)
 }
 
+// ShieldedAddress derives the address notes for a spending key are sent
+// to: the low 20 bytes of MiMC(spending_key). The transaction circuit
+// checks it for every spent note, so only the key's holder can spend.
+func ShieldedAddress(spendingKey []byte) types.Address {
+	h := mimcHash(fieldElement(spendingKey))
+
+	var addr types.Address
+	copy(addr[:], h[types.HashSize-types.AddressSize:])
+	return addr
+}
+
 // NullifierDerivationKey derives the nullifier key from a spending key
 func NullifierDerivationKey(spendingKey []byte) []byte {
 	hasher := sha256.New()
@@ -180,16 +278,6 @@ func NullifierDerivationKey(spendingKey []byte) []byte {
 	return hasher.Sum(nil)
 }
 
-// uint64ToBytes converts uint64 to big-endian bytes
-func uint64ToBytes(v uint64) []byte {
-	b := make([]byte, 8)
-	for i := 7; i >= 0; i-- {
-		b[i] = byte(v)
-		v >>= 8
-	}
-	return b
-}
-
 // InMemoryNullifierStore is a simple in-memory implementation for testing
 type InMemoryNullifierStore struct {
 	mu         sync.RWMutex
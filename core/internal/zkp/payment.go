@@ -0,0 +1,109 @@
+package zkp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Payment disclosure errors
+var (
+	ErrInvalidPaymentDisclosure  = errors.New("malformed payment disclosure")
+	ErrPaymentDisclosureMismatch = errors.New("payment disclosure does not match the transaction")
+)
+
+// paymentDisclosureVersion is the current encoding version
+const paymentDisclosureVersion = 1
+
+// PaymentDisclosure lets a sender prove one output of a transaction paid
+// Value to Recipient. The blinder opens the output commitment, and the
+// ephemeral secret decrypts the note the recipient received, showing it
+// was encrypted to their transmission key.
+type PaymentDisclosure struct {
+	TxHash      types.Hash
+	OutputIndex uint32
+
+	Value           uint64
+	Recipient       types.Address
+	TransmissionKey []byte
+	Memo            []byte
+
+	// Proof
+	Blinder         []byte
+	EphemeralSecret []byte
+}
+
+// Encode serializes the disclosure for export
+func (d *PaymentDisclosure) Encode() []byte {
+	buf := make([]byte, 0, 1+types.HashSize+4+8+types.AddressSize+3*NoteKeySize+2+len(d.Memo))
+	buf = append(buf, paymentDisclosureVersion)
+	buf = append(buf, d.TxHash[:]...)
+	buf = binary.BigEndian.AppendUint32(buf, d.OutputIndex)
+	buf = binary.BigEndian.AppendUint64(buf, d.Value)
+	buf = append(buf, d.Recipient[:]...)
+	buf = append(buf, d.TransmissionKey...)
+	buf = append(buf, d.Blinder...)
+	buf = append(buf, d.EphemeralSecret...)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(d.Memo)))
+	return append(buf, d.Memo...)
+}
+
+// DecodePaymentDisclosure parses an exported disclosure
+func DecodePaymentDisclosure(data []byte) (*PaymentDisclosure, error) {
+	fixed := 1 + types.HashSize + 4 + 8 + types.AddressSize + 3*NoteKeySize + 2
+	if len(data) < fixed || data[0] != paymentDisclosureVersion {
+		return nil, ErrInvalidPaymentDisclosure
+	}
+
+	d := &PaymentDisclosure{}
+	r := data[1:]
+	copy(d.TxHash[:], r)
+	r = r[types.HashSize:]
+	d.OutputIndex = binary.BigEndian.Uint32(r)
+	d.Value = binary.BigEndian.Uint64(r[4:])
+	r = r[12:]
+	copy(d.Recipient[:], r)
+	r = r[types.AddressSize:]
+	d.TransmissionKey = append([]byte(nil), r[:NoteKeySize]...)
+	d.Blinder = append([]byte(nil), r[NoteKeySize:2*NoteKeySize]...)
+	d.EphemeralSecret = append([]byte(nil), r[2*NoteKeySize:3*NoteKeySize]...)
+	r = r[3*NoteKeySize:]
+
+	memoLen := int(binary.BigEndian.Uint16(r))
+	if len(r)-2 != memoLen {
+		return nil, ErrInvalidPaymentDisclosure
+	}
+	if memoLen > 0 {
+		d.Memo = append([]byte(nil), r[2:]...)
+	}
+	return d, nil
+}
+
+// VerifyPaymentDisclosure checks a disclosure against the transaction it
+// names: the output commitment must open to the disclosed value and
+// recipient, and the note encrypted to the recipient must say the same.
+func VerifyPaymentDisclosure(tx *types.Transaction, d *PaymentDisclosure) error {
+	if tx.ComputeHash() != d.TxHash || tx.TxHash != d.TxHash {
+		return ErrPaymentDisclosureMismatch
+	}
+	if int(d.OutputIndex) >= len(tx.Commitments) {
+		return ErrPaymentDisclosureMismatch
+	}
+	c := tx.Commitments[d.OutputIndex]
+
+	if NoteCommitment(d.Value, d.Recipient, d.Blinder) != c.Value {
+		return ErrPaymentDisclosureMismatch
+	}
+
+	note, err := OpenNoteWithSecret(d.EphemeralSecret, d.TransmissionKey, c.EncryptedNote)
+	if err != nil {
+		return ErrPaymentDisclosureMismatch
+	}
+	if note.Value != d.Value || note.Address != d.Recipient ||
+		!bytes.Equal(note.Blinder, d.Blinder) || !bytes.Equal(note.Memo, d.Memo) {
+		return ErrPaymentDisclosureMismatch
+	}
+	return nil
+}
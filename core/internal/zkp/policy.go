@@ -0,0 +1,396 @@
+package zkp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Policy errors
+var (
+	ErrNotPolicyProposal = errors.New("not a disclosure policy proposal")
+	ErrPolicyNotPassed   = errors.New("disclosure policy proposal has not passed")
+	ErrPolicyNetwork     = errors.New("disclosure policy proposal is for another network")
+	ErrInvalidPolicyRule = errors.New("invalid disclosure policy rule")
+)
+
+// RecipientClass classifies what a transaction pays into
+type RecipientClass uint8
+
+const (
+	// RecipientTransfer is a plain shielded transfer
+	RecipientTransfer RecipientClass = iota
+
+	// RecipientInference pays for an on-chain inference
+	RecipientInference
+
+	// RecipientPayout changes a miner's reward address
+	RecipientPayout
+
+	// RecipientSealed carries operations sealed to the decryption
+	// committee, so the recipient is not known at admission
+	RecipientSealed
+
+	// RecipientDelegation bonds or unbonds stake delegated to a miner
+	RecipientDelegation
+)
+
+// String returns the recipient class name
+func (c RecipientClass) String() string {
+	switch c {
+	case RecipientTransfer:
+		return "transfer"
+	case RecipientInference:
+		return "inference"
+	case RecipientPayout:
+		return "payout"
+	case RecipientSealed:
+		return "sealed"
+	case RecipientDelegation:
+		return "delegation"
+	default:
+		return "unknown"
+	}
+}
+
+// TxAttributes are the properties of a transaction that disclosure policy
+// rules match on. Values are hidden, so MinValue and MaxValue are the
+// range the transaction's own range disclosure implies, or the full range
+// if it has none.
+type TxAttributes struct {
+	MinValue uint64
+	MaxValue uint64
+
+	Recipient RecipientClass
+
+	// Jurisdictions are the domains of the authorities behind the
+	// transaction's identity disclosures
+	Jurisdictions []string
+}
+
+// PolicyRule requires disclosures from transactions that match it. Empty
+// Recipients or Jurisdictions match any.
+type PolicyRule struct {
+	Name string
+
+	// MinValue applies the rule when the transaction may move at least
+	// this much
+	MinValue uint64
+
+	Recipients    []RecipientClass
+	Jurisdictions []string
+
+	Require DisclosureFlags
+}
+
+// Matches reports whether the rule applies to a transaction
+func (r *PolicyRule) Matches(attrs *TxAttributes) bool {
+	matched, _ := r.explain(attrs)
+	return matched
+}
+
+// explain reports whether the rule applies to a transaction and why
+func (r *PolicyRule) explain(attrs *TxAttributes) (bool, string) {
+	if attrs.MaxValue < r.MinValue {
+		return false, fmt.Sprintf("value is at most %d, below %d", attrs.MaxValue, r.MinValue)
+	}
+	if len(r.Recipients) > 0 {
+		found := false
+		for _, c := range r.Recipients {
+			if c == attrs.Recipient {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, fmt.Sprintf("recipient is %s", attrs.Recipient)
+		}
+	}
+	if len(r.Jurisdictions) > 0 {
+		found := false
+		for _, j := range r.Jurisdictions {
+			for _, tag := range attrs.Jurisdictions {
+				if j == tag {
+					found = true
+					break
+				}
+			}
+			if found {
+				break
+			}
+		}
+		if !found {
+			return false, "no identity disclosure from a listed jurisdiction"
+		}
+	}
+
+	if r.MinValue > 0 && attrs.MaxValue == math.MaxUint64 {
+		return true, "no range disclosure bounds the value"
+	}
+	return true, "all conditions hold"
+}
+
+// PolicyStore persists each network's disclosure policy
+type PolicyStore interface {
+	// LoadPolicyRules returns the rules in force on network
+	LoadPolicyRules(ctx context.Context, network string) ([]PolicyRule, error)
+
+	// SavePolicyRules replaces the rules of network
+	SavePolicyRules(ctx context.Context, network string, rules []PolicyRule, proposalID types.Hash) error
+}
+
+// PolicyEngine decides which disclosures a transaction needs on one
+// network. Rules start empty and change only through passed parameter
+// adjustment proposals carrying a types.DisclosurePolicyData.
+type PolicyEngine struct {
+	mu sync.RWMutex
+
+	network string
+	rules   []PolicyRule
+
+	// Proposals already applied
+	applied map[types.Hash]bool
+
+	store PolicyStore
+}
+
+// NewPolicyEngine creates a policy engine for network with no rules
+func NewPolicyEngine(network string, store PolicyStore) *PolicyEngine {
+	return &PolicyEngine{
+		network: network,
+		applied: make(map[types.Hash]bool),
+		store:   store,
+	}
+}
+
+// Initialize loads the network's rules from the store
+func (pe *PolicyEngine) Initialize(ctx context.Context) error {
+	rules, err := pe.store.LoadPolicyRules(ctx, pe.network)
+	if err != nil {
+		return err
+	}
+
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+	pe.rules = rules
+	return nil
+}
+
+// Network returns the network the engine enforces policy for
+func (pe *PolicyEngine) Network() string {
+	return pe.network
+}
+
+// Rules returns the rules in force
+func (pe *PolicyEngine) Rules() []PolicyRule {
+	pe.mu.RLock()
+	defer pe.mu.RUnlock()
+	return append([]PolicyRule(nil), pe.rules...)
+}
+
+// Required returns the disclosures every matching rule requires
+func (pe *PolicyEngine) Required(attrs *TxAttributes) DisclosureFlags {
+	pe.mu.RLock()
+	defer pe.mu.RUnlock()
+
+	required := FlagNone
+	for i := range pe.rules {
+		if pe.rules[i].Matches(attrs) {
+			required |= pe.rules[i].Require
+		}
+	}
+	return required
+}
+
+// RuleExplanation is the outcome of one rule for a transaction
+type RuleExplanation struct {
+	Rule    PolicyRule
+	Matched bool
+	Reason  string
+}
+
+// PolicyExplanation shows how the policy decides the disclosures a
+// transaction needs, so wallets can tell users what to attach and why
+type PolicyExplanation struct {
+	Network    string
+	Attributes TxAttributes
+	Rules      []RuleExplanation
+	Required   DisclosureFlags
+}
+
+// Explain evaluates every rule against a transaction's attributes
+func (pe *PolicyEngine) Explain(attrs *TxAttributes) *PolicyExplanation {
+	pe.mu.RLock()
+	defer pe.mu.RUnlock()
+
+	e := &PolicyExplanation{Network: pe.network, Attributes: *attrs}
+	for _, rule := range pe.rules {
+		matched, reason := rule.explain(attrs)
+		e.Rules = append(e.Rules, RuleExplanation{Rule: rule, Matched: matched, Reason: reason})
+		if matched {
+			e.Required |= rule.Require
+		}
+	}
+	return e
+}
+
+// ApplyProposal replaces the rules with those of a passed disclosure
+// policy proposal for this network. Each proposal applies once.
+func (pe *PolicyEngine) ApplyProposal(ctx context.Context, proposal *types.Proposal) error {
+	if proposal.Type != types.ProposalParameterAdjust {
+		return ErrNotPolicyProposal
+	}
+	if proposal.Status != types.ProposalStatusPassed && proposal.Status != types.ProposalStatusExecuted {
+		return ErrPolicyNotPassed
+	}
+	data, ok := proposal.Data.(*types.DisclosurePolicyData)
+	if !ok {
+		return ErrNotPolicyProposal
+	}
+	if data.Network != pe.network {
+		return ErrPolicyNetwork
+	}
+	rules, err := PolicyRulesFromProposal(data)
+	if err != nil {
+		return err
+	}
+
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+
+	if pe.applied[proposal.ProposalID] {
+		return nil
+	}
+	if err := pe.store.SavePolicyRules(ctx, pe.network, rules, proposal.ProposalID); err != nil {
+		return err
+	}
+	pe.rules = rules
+	pe.applied[proposal.ProposalID] = true
+	return nil
+}
+
+// PolicyRulesFromProposal returns the rules a proposal would set
+func PolicyRulesFromProposal(data *types.DisclosurePolicyData) ([]PolicyRule, error) {
+	rules := make([]PolicyRule, 0, len(data.Rules))
+	for _, d := range data.Rules {
+		rule := PolicyRule{
+			Name:          d.Name,
+			MinValue:      d.MinValue,
+			Jurisdictions: d.Jurisdictions,
+			Require:       DisclosureFlags(d.Require),
+		}
+		for _, c := range d.Recipients {
+			if RecipientClass(c) > RecipientDelegation {
+				return nil, ErrInvalidPolicyRule
+			}
+			rule.Recipients = append(rule.Recipients, RecipientClass(c))
+		}
+		if rule.Require == FlagNone || rule.Require&^allDisclosureFlags != 0 {
+			return nil, ErrInvalidPolicyRule
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// allDisclosureFlags is every flag a rule may require
+const allDisclosureFlags = FlagRangeRequired | FlagIdentityRequired | FlagTemporalRequired |
+	FlagSanctionsRequired | FlagThresholdRequired | FlagSourceRequired
+
+// TxAttributes derives the attributes policy rules match on from a
+// transaction and its disclosures
+func (dm *DisclosureManager) TxAttributes(tx *types.Transaction) *TxAttributes {
+	attrs := &TxAttributes{MaxValue: math.MaxUint64}
+
+	switch {
+	case tx.Sealed != nil:
+		attrs.Recipient = RecipientSealed
+	case tx.Inference != nil:
+		attrs.Recipient = RecipientInference
+	case tx.PayoutChange != nil:
+		attrs.Recipient = RecipientPayout
+	case tx.Delegation != nil:
+		attrs.Recipient = RecipientDelegation
+	default:
+		attrs.Recipient = RecipientTransfer
+	}
+
+	dm.mu.RLock()
+	defer dm.mu.RUnlock()
+
+	for _, d := range tx.Disclosures {
+		switch d.Type {
+		case types.DisclosureRange:
+			if len(d.PublicData) != 16 {
+				continue
+			}
+			attrs.MinValue = binary.BigEndian.Uint64(d.PublicData[:8])
+			attrs.MaxValue = binary.BigEndian.Uint64(d.PublicData[8:])
+
+		case types.DisclosureIdentity:
+			var key types.Hash
+			if len(d.PublicData) != len(key) {
+				continue
+			}
+			copy(key[:], d.PublicData)
+			if authority, known := dm.authorityLocked(key); known && authority.Domain != "" {
+				attrs.Jurisdictions = append(attrs.Jurisdictions, authority.Domain)
+			}
+		}
+	}
+	return attrs
+}
+
+// SetPolicy sets the engine that decides which disclosures transactions
+// need
+func (dm *DisclosureManager) SetPolicy(policy *PolicyEngine) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	dm.policy = policy
+}
+
+// CheckDisclosures validates a transaction's disclosures against what the
+// policy requires of it. Without a policy only the attached disclosures
+// are verified.
+func (dm *DisclosureManager) CheckDisclosures(ctx context.Context, tx *types.Transaction) error {
+	dm.mu.RLock()
+	policy := dm.policy
+	dm.mu.RUnlock()
+
+	required := FlagNone
+	if policy != nil {
+		required = policy.Required(dm.TxAttributes(tx))
+	}
+	return dm.ValidateDisclosures(ctx, tx, required)
+}
+
+// InMemoryPolicyStore is a simple in-memory PolicyStore for testing
+type InMemoryPolicyStore struct {
+	mu    sync.Mutex
+	rules map[string][]PolicyRule
+}
+
+// NewInMemoryPolicyStore creates an empty in-memory policy store
+func NewInMemoryPolicyStore() *InMemoryPolicyStore {
+	return &InMemoryPolicyStore{rules: make(map[string][]PolicyRule)}
+}
+
+// LoadPolicyRules returns the rules of network
+func (s *InMemoryPolicyStore) LoadPolicyRules(ctx context.Context, network string) ([]PolicyRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]PolicyRule(nil), s.rules[network]...), nil
+}
+
+// SavePolicyRules replaces the rules of network
+func (s *InMemoryPolicyStore) SavePolicyRules(ctx context.Context, network string, rules []PolicyRule, proposalID types.Hash) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules[network] = append([]PolicyRule(nil), rules...)
+	return nil
+}
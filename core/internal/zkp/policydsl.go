@@ -0,0 +1,272 @@
+package zkp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// ErrPolicySyntax is returned for policy text that does not parse
+var ErrPolicySyntax = errors.New("disclosure policy syntax error")
+
+// disclosureFlagNames names each flag a rule may require, in bit order
+var disclosureFlagNames = []struct {
+	flag DisclosureFlags
+	name string
+}{
+	{FlagRangeRequired, "range"},
+	{FlagIdentityRequired, "identity"},
+	{FlagTemporalRequired, "temporal"},
+	{FlagSanctionsRequired, "sanctions"},
+	{FlagThresholdRequired, "threshold"},
+	{FlagSourceRequired, "source"},
+}
+
+// Names returns the names of the flags set
+func (f DisclosureFlags) Names() []string {
+	var names []string
+	for _, n := range disclosureFlagNames {
+		if f&n.flag != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return names
+}
+
+// ParseDisclosureFlag returns the flag with the given name
+func ParseDisclosureFlag(name string) (DisclosureFlags, bool) {
+	for _, n := range disclosureFlagNames {
+		if n.name == name {
+			return n.flag, true
+		}
+	}
+	return FlagNone, false
+}
+
+// ParseRecipientClass returns the recipient class with the given name
+func ParseRecipientClass(name string) (RecipientClass, bool) {
+	for c := RecipientTransfer; c <= RecipientDelegation; c++ {
+		if c.String() == name {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+// ParsePolicyRules parses disclosure policy text. Each non-blank line not
+// starting with '#' is one rule:
+//
+//	rule <name>: <conditions> => require <flag>, ...
+//
+// Conditions are "any", or one or more of the following joined by "and":
+//
+//	value >= <amount>
+//	recipient in (<transfer|inference|payout|sealed>, ...)
+//	jurisdiction in (<domain>, ...)
+func ParsePolicyRules(src string) ([]PolicyRule, error) {
+	var rules []PolicyRule
+	names := make(map[string]bool)
+	for i, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule, err := parsePolicyRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrPolicySyntax, i+1, err)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("%w: line %d: duplicate rule %q", ErrPolicySyntax, i+1, rule.Name)
+		}
+		names[rule.Name] = true
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// parsePolicyRule parses a single rule line
+func parsePolicyRule(line string) (PolicyRule, error) {
+	var rule PolicyRule
+
+	for _, sep := range []string{"(", ")", ",", ":"} {
+		line = strings.ReplaceAll(line, sep, " "+sep+" ")
+	}
+	line = strings.ReplaceAll(line, "=>", " => ")
+	line = strings.ReplaceAll(line, ">=", " >= ")
+	toks := strings.Fields(line)
+
+	next := func() string {
+		if len(toks) == 0 {
+			return ""
+		}
+		tok := toks[0]
+		toks = toks[1:]
+		return tok
+	}
+	expect := func(want string) error {
+		if got := next(); got != want {
+			return fmt.Errorf("expected %q, found %q", want, got)
+		}
+		return nil
+	}
+	list := func() ([]string, error) {
+		if err := expect("("); err != nil {
+			return nil, err
+		}
+		var items []string
+		for {
+			item := next()
+			if item == "" || item == "(" || item == ")" || item == "," {
+				return nil, fmt.Errorf("expected a name, found %q", item)
+			}
+			items = append(items, item)
+			switch sep := next(); sep {
+			case ")":
+				return items, nil
+			case ",":
+			default:
+				return nil, fmt.Errorf("expected \",\" or \")\", found %q", sep)
+			}
+		}
+	}
+
+	if err := expect("rule"); err != nil {
+		return rule, err
+	}
+	rule.Name = next()
+	if rule.Name == "" || rule.Name == ":" {
+		return rule, errors.New("missing rule name")
+	}
+	if err := expect(":"); err != nil {
+		return rule, err
+	}
+
+	if len(toks) > 0 && toks[0] == "any" {
+		next()
+	} else {
+		for {
+			switch field := next(); field {
+			case "value":
+				if err := expect(">="); err != nil {
+					return rule, err
+				}
+				amount := next()
+				v, err := strconv.ParseUint(amount, 10, 64)
+				if err != nil {
+					return rule, fmt.Errorf("invalid amount %q", amount)
+				}
+				rule.MinValue = v
+
+			case "recipient":
+				if err := expect("in"); err != nil {
+					return rule, err
+				}
+				items, err := list()
+				if err != nil {
+					return rule, err
+				}
+				for _, item := range items {
+					c, ok := ParseRecipientClass(item)
+					if !ok {
+						return rule, fmt.Errorf("unknown recipient %q", item)
+					}
+					rule.Recipients = append(rule.Recipients, c)
+				}
+
+			case "jurisdiction":
+				if err := expect("in"); err != nil {
+					return rule, err
+				}
+				items, err := list()
+				if err != nil {
+					return rule, err
+				}
+				rule.Jurisdictions = append(rule.Jurisdictions, items...)
+
+			default:
+				return rule, fmt.Errorf("unknown condition %q", field)
+			}
+
+			if len(toks) == 0 || toks[0] != "and" {
+				break
+			}
+			next()
+		}
+	}
+
+	if err := expect("=>"); err != nil {
+		return rule, err
+	}
+	if err := expect("require"); err != nil {
+		return rule, err
+	}
+	for {
+		name := next()
+		flag, ok := ParseDisclosureFlag(name)
+		if !ok {
+			return rule, fmt.Errorf("unknown disclosure %q", name)
+		}
+		rule.Require |= flag
+		if len(toks) == 0 {
+			return rule, nil
+		}
+		if err := expect(","); err != nil {
+			return rule, err
+		}
+	}
+}
+
+// String formats the rule as a line of policy text
+func (r *PolicyRule) String() string {
+	var conds []string
+	if r.MinValue > 0 {
+		conds = append(conds, fmt.Sprintf("value >= %d", r.MinValue))
+	}
+	if len(r.Recipients) > 0 {
+		names := make([]string, len(r.Recipients))
+		for i, c := range r.Recipients {
+			names[i] = c.String()
+		}
+		conds = append(conds, "recipient in ("+strings.Join(names, ", ")+")")
+	}
+	if len(r.Jurisdictions) > 0 {
+		conds = append(conds, "jurisdiction in ("+strings.Join(r.Jurisdictions, ", ")+")")
+	}
+	if len(conds) == 0 {
+		conds = []string{"any"}
+	}
+	return fmt.Sprintf("rule %s: %s => require %s", r.Name, strings.Join(conds, " and "), strings.Join(r.Require.Names(), ", "))
+}
+
+// FormatPolicyRules formats rules as policy text ParsePolicyRules reads
+// back
+func FormatPolicyRules(rules []PolicyRule) string {
+	var b strings.Builder
+	for i := range rules {
+		b.WriteString(rules[i].String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// PolicyProposalData returns the proposal data that sets rules on
+// network, the inverse of PolicyRulesFromProposal
+func PolicyProposalData(network string, rules []PolicyRule) *types.DisclosurePolicyData {
+	data := &types.DisclosurePolicyData{Network: network}
+	for _, r := range rules {
+		d := types.DisclosureRuleData{
+			Name:          r.Name,
+			MinValue:      r.MinValue,
+			Jurisdictions: r.Jurisdictions,
+			Require:       uint32(r.Require),
+		}
+		for _, c := range r.Recipients {
+			d.Recipients = append(d.Recipients, uint8(c))
+		}
+		data.Rules = append(data.Rules, d)
+	}
+	return data
+}
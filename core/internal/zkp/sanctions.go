@@ -0,0 +1,292 @@
+package zkp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/hash/mimc"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Sanctions errors
+var (
+	ErrAddressSanctioned    = errors.New("address is on the sanctions list")
+	ErrInvalidSanctionsPath = errors.New("invalid sanctions non-membership path")
+)
+
+// SanctionsTreeDepth is the depth of the sanctions tree: one level per
+// address bit, so every address has its own leaf
+const SanctionsTreeDepth = types.AddressSize * 8
+
+// sanctionedLeaf marks an address on the list; empty leaves are zero
+var sanctionedLeaf = types.Hash{types.HashSize - 1: 1}
+
+// sanctionsEmpty[h] is the root of an empty subtree of height h
+var sanctionsEmpty = func() []types.Hash {
+	empty := make([]types.Hash, SanctionsTreeDepth+1)
+	for h := 1; h <= SanctionsTreeDepth; h++ {
+		empty[h] = hashPair(empty[h-1], empty[h-1])
+	}
+	return empty
+}()
+
+// SanctionsTree is a sparse Merkle tree over the address space whose
+// leaves are set for sanctioned addresses. Leaf i belongs to the address
+// whose big-endian value is i, so a path to an empty leaf proves an
+// address is not on the list. Nodes are MiMC hashes so the path can be
+// checked in-circuit.
+type SanctionsTree struct {
+	mu sync.RWMutex
+
+	members map[types.Address]struct{}
+
+	// root is recomputed lazily after changes
+	root  types.Hash
+	dirty bool
+}
+
+// SanctionsPath is the sibling of each node on the path from an address's
+// leaf to the root, leaf level first
+type SanctionsPath struct {
+	Siblings []types.Hash
+}
+
+// NewSanctionsTree creates a tree holding addrs
+func NewSanctionsTree(addrs ...types.Address) *SanctionsTree {
+	t := &SanctionsTree{members: make(map[types.Address]struct{}), dirty: true}
+	for _, addr := range addrs {
+		t.members[addr] = struct{}{}
+	}
+	return t
+}
+
+// Update adds and removes addresses
+func (t *SanctionsTree) Update(add, remove []types.Address) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, addr := range add {
+		t.members[addr] = struct{}{}
+	}
+	for _, addr := range remove {
+		delete(t.members, addr)
+	}
+	t.dirty = true
+}
+
+// Clone returns an independent copy of the tree
+func (t *SanctionsTree) Clone() *SanctionsTree {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	c := &SanctionsTree{members: make(map[types.Address]struct{}, len(t.members)), root: t.root, dirty: t.dirty}
+	for addr := range t.members {
+		c.members[addr] = struct{}{}
+	}
+	return c
+}
+
+// Contains reports whether addr is on the list
+func (t *SanctionsTree) Contains(addr types.Address) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	_, exists := t.members[addr]
+	return exists
+}
+
+// Members returns the listed addresses in ascending order
+func (t *SanctionsTree) Members() []types.Address {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.sortedLocked()
+}
+
+// Size returns the number of listed addresses
+func (t *SanctionsTree) Size() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.members)
+}
+
+// Root returns the tree root
+func (t *SanctionsTree) Root() types.Hash {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.dirty {
+		t.root = sanctionsNode(SanctionsTreeDepth, t.sortedLocked())
+		t.dirty = false
+	}
+	return t.root
+}
+
+// Prove returns the non-membership path for addr, or ErrAddressSanctioned
+// if it is listed
+func (t *SanctionsTree) Prove(addr types.Address) (*SanctionsPath, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if _, exists := t.members[addr]; exists {
+		return nil, ErrAddressSanctioned
+	}
+
+	path := &SanctionsPath{Siblings: make([]types.Hash, SanctionsTreeDepth)}
+	keys := t.sortedLocked()
+	for h := SanctionsTreeDepth; h > 0; h-- {
+		zeros, ones := splitByBit(keys, h-1)
+		if addressBit(addr, h-1) == 0 {
+			path.Siblings[h-1] = sanctionsNode(h-1, ones)
+			keys = zeros
+		} else {
+			path.Siblings[h-1] = sanctionsNode(h-1, zeros)
+			keys = ones
+		}
+	}
+	return path, nil
+}
+
+// VerifyNonMembership checks that path leads from addr's empty leaf to root
+func VerifyNonMembership(root types.Hash, addr types.Address, path *SanctionsPath) bool {
+	if path == nil || len(path.Siblings) != SanctionsTreeDepth {
+		return false
+	}
+	var node types.Hash
+	for level, sibling := range path.Siblings {
+		if addressBit(addr, level) == 0 {
+			node = hashPair(node, sibling)
+		} else {
+			node = hashPair(sibling, node)
+		}
+	}
+	return node == root
+}
+
+// sortedLocked returns the members in ascending order, which keeps the
+// subtree splits below contiguous
+func (t *SanctionsTree) sortedLocked() []types.Address {
+	addrs := make([]types.Address, 0, len(t.members))
+	for addr := range t.members {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return bytes.Compare(addrs[i][:], addrs[j][:]) < 0 })
+	return addrs
+}
+
+// sanctionsNode returns the root of the subtree of height h holding keys.
+// keys are sorted and agree on every bit above h.
+func sanctionsNode(h int, keys []types.Address) types.Hash {
+	if len(keys) == 0 {
+		return sanctionsEmpty[h]
+	}
+	if h == 0 {
+		return sanctionedLeaf
+	}
+	zeros, ones := splitByBit(keys, h-1)
+	return hashPair(sanctionsNode(h-1, zeros), sanctionsNode(h-1, ones))
+}
+
+// splitByBit splits sorted keys that agree above bit into those with the
+// bit clear and those with it set
+func splitByBit(keys []types.Address, bit int) (zeros, ones []types.Address) {
+	i := sort.Search(len(keys), func(i int) bool { return addressBit(keys[i], bit) == 1 })
+	return keys[:i], keys[i:]
+}
+
+// addressBit returns bit i of the address read as a big-endian integer
+func addressBit(addr types.Address, i int) byte {
+	return (addr[types.AddressSize-1-i/8] >> (i % 8)) & 1
+}
+
+// SanctionsCircuit proves that the owner of the note behind a nullifier
+// has an empty leaf in the sanctions tree. The note opening and nullifier
+// derivation match the transaction circuit, which ties the proof to a
+// note the transaction spends.
+type SanctionsCircuit struct {
+	// Public inputs
+	Root      frontend.Variable `gnark:",public"`
+	Nullifier frontend.Variable `gnark:",public"`
+
+	// Private inputs (witness)
+	SpendingKey frontend.Variable
+	Value       frontend.Variable
+	Address     frontend.Variable
+	Blinder     frontend.Variable
+	Position    frontend.Variable
+	Siblings    [SanctionsTreeDepth]frontend.Variable
+}
+
+// Define implements the circuit constraints
+func (c *SanctionsCircuit) Define(api frontend.API) error {
+	h, err := mimc.NewMiMC(api)
+	if err != nil {
+		return err
+	}
+	hash := func(data ...frontend.Variable) frontend.Variable {
+		h.Reset()
+		h.Write(data...)
+		return h.Sum()
+	}
+
+	// The nullifier belongs to a note owned by Address
+	leaf := hash(c.Value, c.Address, c.Blinder)
+	api.AssertIsEqual(c.Nullifier, hash(c.SpendingKey, leaf, c.Position))
+
+	// Address's leaf is empty: walk up from zero, steered by its bits
+	bits := api.ToBinary(c.Address, SanctionsTreeDepth)
+	var node frontend.Variable = 0
+	for level, sibling := range c.Siblings {
+		left := api.Select(bits[level], sibling, node)
+		right := api.Select(bits[level], node, sibling)
+		node = hash(left, right)
+	}
+	api.AssertIsEqual(node, c.Root)
+
+	return nil
+}
+
+// CompileSanctionsCircuit compiles the sanctions compliance circuit
+func (cm *CircuitManager) CompileSanctionsCircuit() error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	_, err := cm.compileLocked(ProofTypeSanctionsCompliance, &SanctionsCircuit{})
+	return err
+}
+
+// VerifySanctionsProof verifies a sanctions compliance proof for a
+// nullifier against a sanctions tree root
+func (cm *CircuitManager) VerifySanctionsProof(ctx context.Context, root, nullifier types.Hash, proof *types.ZKProof) error {
+	cm.mu.RLock()
+	compiled, exists := cm.circuits[ProofTypeSanctionsCompliance]
+	cm.mu.RUnlock()
+	if !exists || !compiled.Compiled {
+		return ErrCircuitNotCompiled
+	}
+	if proof.ProofType != compiled.Backend.System() {
+		return ErrProofFailed
+	}
+
+	public := &SanctionsCircuit{
+		Root:      hashVariable(root),
+		Nullifier: hashVariable(nullifier),
+	}
+	publicWitness, err := frontend.NewWitness(public, ecc.BN254.ScalarField(), frontend.PublicOnly())
+	if err != nil {
+		return ErrInvalidPublicInputs
+	}
+
+	valid, err := cm.verify(ProofTypeSanctionsCompliance, proof.ProofData, publicWitness)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return ErrProofFailed
+	}
+	return nil
+}
@@ -0,0 +1,320 @@
+package zkp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/hash/mimc"
+
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Temporal disclosure errors
+var (
+	ErrTemporalMalformed          = errors.New("malformed temporal disclosure")
+	ErrTemporalAnchorsUnavailable = errors.New("no chain to check temporal disclosures against")
+	ErrTemporalNotOnChain         = errors.New("temporal disclosure block is not on-chain")
+	ErrTemporalRootMismatch       = errors.New("temporal disclosure root is not the block's commitment root")
+	ErrTemporalTooRecent          = errors.New("note was created less than the disclosed duration before the anchor block")
+)
+
+// temporalPublicDataSize is the encoded size of the creation and anchor
+// block hashes and the minimum duration
+const temporalPublicDataSize = 2*types.HashSize + 8
+
+// TemporalDisclosure proves that a note spent by the transaction was held
+// for at least MinDuration seconds. The proof shows the note's commitment
+// is in Root, the commitment tree root after CreationBlock, and is bound
+// to the note's nullifier. Verifiers compare the header timestamps of
+// CreationBlock and AnchorBlock, so nothing rests on the prover's clock.
+type TemporalDisclosure struct {
+	// Nullifier of the note held, one the transaction spends
+	Nullifier types.Hash
+
+	// Root is the commitment tree root after CreationBlock
+	Root          types.Hash
+	CreationBlock types.Hash
+
+	// AnchorBlock stands for the present; its timestamp must be at least
+	// MinDuration after CreationBlock's
+	AnchorBlock types.Hash
+	MinDuration uint64 // seconds
+
+	Proof  []byte
+	System uint8 // Proof system of Proof
+}
+
+// Disclosure returns the form attached to a transaction. The public
+// inputs are the root and the nullifier; the public data holds the block
+// hashes and the duration.
+func (d *TemporalDisclosure) Disclosure() types.Disclosure {
+	public := make([]byte, 0, temporalPublicDataSize)
+	public = append(public, d.CreationBlock[:]...)
+	public = append(public, d.AnchorBlock[:]...)
+	public = binary.BigEndian.AppendUint64(public, d.MinDuration)
+
+	return types.Disclosure{
+		Type:       types.DisclosureTemporal,
+		Proof:      types.ZKProof{ProofType: d.System, ProofData: d.Proof, PublicInputs: []types.Hash{d.Root, d.Nullifier}},
+		PublicData: public,
+	}
+}
+
+// ParseTemporalDisclosure reads a temporal disclosure back from its
+// attached form
+func ParseTemporalDisclosure(disclosure *types.Disclosure) (*TemporalDisclosure, error) {
+	public := disclosure.PublicData
+	inputs := disclosure.Proof.PublicInputs
+	if disclosure.Type != types.DisclosureTemporal || len(public) != temporalPublicDataSize || len(inputs) != 2 {
+		return nil, ErrTemporalMalformed
+	}
+
+	d := &TemporalDisclosure{
+		Root:        inputs[0],
+		Nullifier:   inputs[1],
+		MinDuration: binary.BigEndian.Uint64(public[2*types.HashSize:]),
+		Proof:       disclosure.Proof.ProofData,
+		System:      disclosure.Proof.ProofType,
+	}
+	copy(d.CreationBlock[:], public[:types.HashSize])
+	copy(d.AnchorBlock[:], public[types.HashSize:])
+	return d, nil
+}
+
+// TemporalDisclosureCircuit proves that the note behind Nullifier is a
+// leaf of the commitment tree with root Root. Note openings and nullifier
+// derivation match the transaction circuit; the leaf position is the one
+// the nullifier commits to.
+type TemporalDisclosureCircuit struct {
+	// Public inputs
+	Root      frontend.Variable `gnark:",public"`
+	Nullifier frontend.Variable `gnark:",public"`
+
+	// Private inputs (witness)
+	SpendingKey frontend.Variable
+	Value       frontend.Variable
+	Address     frontend.Variable
+	Blinder     frontend.Variable
+	Position    frontend.Variable
+	Siblings    [TreeDepth]frontend.Variable
+}
+
+// Define implements the circuit constraints
+func (c *TemporalDisclosureCircuit) Define(api frontend.API) error {
+	h, err := mimc.NewMiMC(api)
+	if err != nil {
+		return err
+	}
+	hash := func(data ...frontend.Variable) frontend.Variable {
+		h.Reset()
+		h.Write(data...)
+		return h.Sum()
+	}
+
+	api.ToBinary(c.Value, 64)
+	leaf := hash(c.Value, c.Address, c.Blinder)
+	api.AssertIsEqual(c.Nullifier, hash(c.SpendingKey, leaf, c.Position))
+
+	bits := api.ToBinary(c.Position, TreeDepth)
+	node := leaf
+	for level, sibling := range c.Siblings {
+		left := api.Select(bits[level], sibling, node)
+		right := api.Select(bits[level], node, sibling)
+		node = hash(left, right)
+	}
+	api.AssertIsEqual(node, c.Root)
+
+	return nil
+}
+
+// newTemporalCircuit returns the circuit with the public inputs of d and
+// every private input zero
+func newTemporalCircuit(d *TemporalDisclosure) *TemporalDisclosureCircuit {
+	c := &TemporalDisclosureCircuit{
+		Root:        hashVariable(d.Root),
+		Nullifier:   hashVariable(d.Nullifier),
+		SpendingKey: 0,
+		Value:       0,
+		Address:     0,
+		Blinder:     0,
+		Position:    0,
+	}
+	for i := range c.Siblings {
+		c.Siblings[i] = 0
+	}
+	return c
+}
+
+// CompileTemporalCircuit compiles the temporal disclosure circuit
+func (cm *CircuitManager) CompileTemporalCircuit() error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	_, err := cm.compileLocked(ProofTypeTemporalDisclosure, &TemporalDisclosureCircuit{})
+	return err
+}
+
+// VerifyTemporalProof verifies the proof of a temporal disclosure against
+// its root and nullifier
+func (cm *CircuitManager) VerifyTemporalProof(ctx context.Context, d *TemporalDisclosure) error {
+	cm.mu.RLock()
+	compiled, exists := cm.circuits[ProofTypeTemporalDisclosure]
+	cm.mu.RUnlock()
+	if !exists || !compiled.Compiled {
+		return ErrCircuitNotCompiled
+	}
+	if d.System != compiled.Backend.System() {
+		return ErrProofFailed
+	}
+
+	publicWitness, err := frontend.NewWitness(newTemporalCircuit(d), ecc.BN254.ScalarField(), frontend.PublicOnly())
+	if err != nil {
+		return ErrInvalidPublicInputs
+	}
+
+	valid, err := cm.verify(ProofTypeTemporalDisclosure, d.Proof, publicWitness)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return ErrProofFailed
+	}
+	return nil
+}
+
+// CreateTemporalDisclosure proves that note, spent with spendingKey from
+// position, was held from creationBlock until anchorBlock. path is the
+// note's path in the commitment tree after creationBlock, whose root
+// comes from the chain, as do both blocks' timestamps.
+func (dm *DisclosureManager) CreateTemporalDisclosure(
+	ctx context.Context,
+	spendingKey []byte,
+	note *Note,
+	position uint64,
+	path *MerklePath,
+	creationBlock, anchorBlock types.Hash,
+	minDuration uint64,
+) (*TemporalDisclosure, error) {
+	if path == nil || len(path.Siblings) != TreeDepth || path.LeafPosition != position {
+		return nil, ErrTemporalMalformed
+	}
+
+	d := &TemporalDisclosure{
+		Nullifier:     DeriveNullifierFromNote(spendingKey, note.Value, note.Blinder, note.Address, position),
+		CreationBlock: creationBlock,
+		AnchorBlock:   anchorBlock,
+		MinDuration:   minDuration,
+	}
+	if err := dm.checkTemporalAnchors(ctx, d, true); err != nil {
+		return nil, err
+	}
+	if !VerifyMerklePath(NoteCommitment(note.Value, note.Address, note.Blinder), path, d.Root) {
+		return nil, ErrTemporalRootMismatch
+	}
+
+	circuit := newTemporalCircuit(d)
+	circuit.SpendingKey = scalarVariable(spendingKey)
+	circuit.Value = note.Value
+	circuit.Address = scalarVariable(note.Address[:])
+	circuit.Blinder = scalarVariable(note.Blinder)
+	circuit.Position = position
+	for i, sibling := range path.Siblings {
+		circuit.Siblings[i] = hashVariable(sibling)
+	}
+
+	proofData, err := dm.circuits.GenerateProof(ctx, ProofTypeTemporalDisclosure, circuit)
+	if err != nil {
+		return nil, err
+	}
+	d.Proof = proofData.Proof
+	d.System = proofData.System
+	return d, nil
+}
+
+// VerifyTemporalDisclosure verifies a temporal disclosure's proof and
+// checks it against the chain: Root must be the commitment root after
+// CreationBlock, and AnchorBlock's timestamp must be at least MinDuration
+// after CreationBlock's.
+func (dm *DisclosureManager) VerifyTemporalDisclosure(ctx context.Context, d *TemporalDisclosure) error {
+	if err := dm.checkTemporalAnchors(ctx, d, false); err != nil {
+		return err
+	}
+	if err := dm.circuits.VerifyTemporalProof(ctx, d); err != nil {
+		return ErrDisclosureProofInvalid
+	}
+	return nil
+}
+
+// checkTemporalAnchors checks d's blocks against the chain. With fill set
+// it takes d.Root from the chain rather than checking it.
+func (dm *DisclosureManager) checkTemporalAnchors(ctx context.Context, d *TemporalDisclosure, fill bool) error {
+	dm.mu.RLock()
+	anchors := dm.temporal
+	dm.mu.RUnlock()
+
+	if anchors == nil {
+		return ErrTemporalAnchorsUnavailable
+	}
+
+	creation, root, err := anchors.BlockAnchor(ctx, d.CreationBlock)
+	if err != nil {
+		return ErrTemporalNotOnChain
+	}
+	if fill {
+		d.Root = root
+	} else if d.Root != root {
+		return ErrTemporalRootMismatch
+	}
+
+	anchor, _, err := anchors.BlockAnchor(ctx, d.AnchorBlock)
+	if err != nil {
+		return ErrTemporalNotOnChain
+	}
+	if anchor.Timestamp < creation.Timestamp || anchor.Timestamp-creation.Timestamp < d.MinDuration {
+		return ErrTemporalTooRecent
+	}
+	return nil
+}
+
+// SetTemporalAnchors sets where temporal disclosures look up block
+// timestamps and commitment roots
+func (dm *DisclosureManager) SetTemporalAnchors(anchors BlockAnchors) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	dm.temporal = anchors
+}
+
+// BlockAnchors provides the header of a stored block and the commitment
+// tree root after it
+type BlockAnchors interface {
+	BlockAnchor(ctx context.Context, hash types.Hash) (*types.BlockHeader, types.Hash, error)
+}
+
+// ChainAnchors reads block anchors from the block DAG and the commitment
+// accumulator
+type ChainAnchors struct {
+	blocks      AccumulatorSource
+	commitments *ChainAccumulator
+}
+
+// NewChainAnchors creates a block anchor source over the chain
+func NewChainAnchors(blocks AccumulatorSource, commitments *ChainAccumulator) *ChainAnchors {
+	return &ChainAnchors{blocks: blocks, commitments: commitments}
+}
+
+// BlockAnchor returns the header of the block with hash and the
+// commitment tree root after it
+func (a *ChainAnchors) BlockAnchor(ctx context.Context, hash types.Hash) (*types.BlockHeader, types.Hash, error) {
+	block, err := a.blocks.GetBlock(ctx, hash)
+	if err != nil {
+		return nil, types.Hash{}, err
+	}
+	root, err := a.commitments.Root(ctx, hash)
+	if err != nil {
+		return nil, types.Hash{}, err
+	}
+	return block.Header, root, nil
+}
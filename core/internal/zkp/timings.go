@@ -0,0 +1,51 @@
+package zkp
+
+import "time"
+
+// ProofTiming accumulates how long proofs of one type take to generate
+// and verify
+type ProofTiming struct {
+	Proofs     uint64
+	ProveTime  time.Duration
+	Verified   uint64
+	VerifyTime time.Duration
+}
+
+// ProofTimings returns the proof timings recorded so far by proof type
+func (cm *CircuitManager) ProofTimings() map[ProofType]ProofTiming {
+	cm.timingMu.Lock()
+	defer cm.timingMu.Unlock()
+
+	timings := make(map[ProofType]ProofTiming, len(cm.timings))
+	for pt, t := range cm.timings {
+		timings[pt] = *t
+	}
+	return timings
+}
+
+// recordProve adds a proof generation time
+func (cm *CircuitManager) recordProve(pt ProofType, d time.Duration) {
+	cm.timingMu.Lock()
+	defer cm.timingMu.Unlock()
+	t := cm.timingLocked(pt)
+	t.Proofs++
+	t.ProveTime += d
+}
+
+// recordVerify adds a proof verification time
+func (cm *CircuitManager) recordVerify(pt ProofType, d time.Duration) {
+	cm.timingMu.Lock()
+	defer cm.timingMu.Unlock()
+	t := cm.timingLocked(pt)
+	t.Verified++
+	t.VerifyTime += d
+}
+
+func (cm *CircuitManager) timingLocked(pt ProofType) *ProofTiming {
+	t, exists := cm.timings[pt]
+	if !exists {
+		t = &ProofTiming{}
+		cm.timings[pt] = t
+	}
+	return t
+}
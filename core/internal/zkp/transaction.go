@@ -6,6 +6,7 @@ import (
 	"errors"
 	"sync"
 
+	"github.com/ccoin/core/internal/dag"
 	"github.com/ccoin/core/pkg/types"
 )
 
@@ -62,6 +63,9 @@ type TransactionBuilder struct {
 	// Disclosures
 	disclosures []types.Disclosure
 
+	// Outgoing viewing key the sender can recover outputs with
+	outgoingViewingKey []byte
+
 	// Circuit manager for proof generation
 	circuits *CircuitManager
 }
@@ -78,6 +82,10 @@ type NoteOutput struct {
 	Value   uint64
 	Address types.Address
 	Memo    []byte
+
+	// TransmissionKey is the recipient key the note opening is encrypted
+	// to; nil encrypts it to a throwaway key no wallet can scan for
+	TransmissionKey []byte
 }
 
 // NewTransactionBuilder creates a new transaction builder
@@ -90,7 +98,8 @@ func NewTransactionBuilder(circuits *CircuitManager) *TransactionBuilder {
 	}
 }
 
-// AddInput adds an input note to spend
+// AddInput adds an input note to spend. The note must open its
+// commitment and path must lead from it to the anchor.
 func (tb *TransactionBuilder) AddInput(note *Note, spendingKey []byte, path *MerklePath) error {
 	if note == nil {
 		return ErrInvalidNote
@@ -98,6 +107,16 @@ func (tb *TransactionBuilder) AddInput(note *Note, spendingKey []byte, path *Mer
 	if note.Spent {
 		return ErrNoteAlreadySpent
 	}
+	if path == nil {
+		path = note.MerklePath
+	}
+	if path == nil || len(path.Siblings) != TreeDepth || len(path.PathBits) != TreeDepth ||
+		path.LeafPosition != note.Position {
+		return ErrInvalidPath
+	}
+	if NoteCommitment(note.Value, note.Address, note.Blinder) != note.Commitment {
+		return ErrInvalidNote
+	}
 
 	tb.inputs = append(tb.inputs, &NoteInput{
 		Note:        note,
@@ -117,6 +136,23 @@ func (tb *TransactionBuilder) AddOutput(value uint64, address types.Address, mem
 	})
 }
 
+// AddOutputTo adds an output note encrypted to the recipient's
+// transmission key, so their wallet can discover it
+func (tb *TransactionBuilder) AddOutputTo(value uint64, address types.Address, transmissionKey, memo []byte) {
+	tb.outputs = append(tb.outputs, &NoteOutput{
+		Value:           value,
+		Address:         address,
+		Memo:            memo,
+		TransmissionKey: transmissionKey,
+	})
+}
+
+// SetOutgoingViewingKey lets the sender recover the outputs it pays to
+// recipients, e.g. to disclose a payment later
+func (tb *TransactionBuilder) SetOutgoingViewingKey(ovk []byte) {
+	tb.outgoingViewingKey = ovk
+}
+
 // SetFee sets the transaction fee
 func (tb *TransactionBuilder) SetFee(fee uint64) {
 	tb.fee = fee
@@ -147,9 +183,30 @@ func (tb *TransactionBuilder) Build(ctx context.Context, anchor types.Hash) (*ty
 		return nil, ErrInsufficientFunds
 	}
 
+	// Pad to the circuit's arity with zero-value notes
+	numInputs, numOutputs, err := tb.circuits.TransactionShape()
+	if err != nil {
+		return nil, err
+	}
+	if len(tb.inputs) > numInputs || len(tb.outputs) > numOutputs {
+		return nil, ErrTooManyNotes
+	}
+	inputs := tb.inputs
+	for len(inputs) < numInputs {
+		dummy, err := dummyInput()
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, dummy)
+	}
+	outputs := tb.outputs
+	for len(outputs) < numOutputs {
+		outputs = append(outputs, &NoteOutput{})
+	}
+
 	// Generate nullifiers
-	nullifiers := make([]types.Hash, len(tb.inputs))
-	for i, input := range tb.inputs {
+	nullifiers := make([]types.Hash, len(inputs))
+	for i, input := range inputs {
 		nullifiers[i] = DeriveNullifier(
 			input.SpendingKey,
 			input.Note.Commitment,
@@ -158,37 +215,41 @@ func (tb *TransactionBuilder) Build(ctx context.Context, anchor types.Hash) (*ty
 	}
 
 	// Generate output commitments
-	commitments := make([]types.Commitment, len(tb.outputs))
-	blinders := make([][]byte, len(tb.outputs))
+	commitments := make([]types.Commitment, len(outputs))
+	blinders := make([][]byte, len(outputs))
 
-	for i, output := range tb.outputs {
+	for i, output := range outputs {
 		blinder, err := RandomBytes(32)
 		if err != nil {
 			return nil, err
 		}
 		blinders[i] = blinder
 
-		commitment := computeNoteCommitment(output.Value, output.Address, blinder)
-		commitments[i] = types.Commitment{Value: commitment}
+		commitment := NoteCommitment(output.Value, output.Address, blinder)
+		encrypted, err := encryptOutput(output, blinder, tb.outgoingViewingKey)
+		if err != nil {
+			return nil, err
+		}
+		commitments[i] = types.Commitment{Value: commitment, EncryptedNote: encrypted}
 	}
 
 	// Generate zk-SNARK proof
-	proof, err := tb.generateProof(ctx, anchor, nullifiers, commitments)
+	proof, err := tb.generateProof(ctx, anchor, inputs, outputs, nullifiers, commitments, blinders)
 	if err != nil {
 		return nil, err
 	}
 
 	// Build transaction
 	tx := &types.Transaction{
-		Version:        1,
-		Nullifiers:     nullifiers,
-		Commitments:    commitments,
-		Proof:          proof,
-		Anchor:         anchor,
-		Fee:            tb.fee,
-		Memo:           tb.memo,
+		Version:         1,
+		Nullifiers:      nullifiers,
+		Commitments:     commitments,
+		Proof:           proof,
+		Anchor:          anchor,
+		Fee:             tb.fee,
+		Memo:            tb.memo,
 		DisclosureFlags: tb.computeDisclosureFlags(),
-		Disclosures:    tb.disclosures,
+		Disclosures:     tb.disclosures,
 	}
 
 	// Compute transaction hash
@@ -197,32 +258,104 @@ func (tb *TransactionBuilder) Build(ctx context.Context, anchor types.Hash) (*ty
 	return tx, nil
 }
 
-// generateProof generates the zk-SNARK proof
+// encryptOutput encrypts an output's opening for its recipient. Outputs
+// without a recipient key, including padding, are encrypted to a fresh
+// key so every output carries a ciphertext.
+func encryptOutput(output *NoteOutput, blinder, ovk []byte) ([]byte, error) {
+	key := output.TransmissionKey
+	if key == nil {
+		ovk = nil
+		throwaway, err := RandomBytes(NoteKeySize)
+		if err != nil {
+			return nil, err
+		}
+		if key, err = TransmissionKey(throwaway); err != nil {
+			return nil, err
+		}
+	}
+	return EncryptNote(key, &NotePlaintext{
+		Value:   output.Value,
+		Address: output.Address,
+		Blinder: blinder,
+		Memo:    output.Memo,
+	}, ovk)
+}
+
+// generateProof assigns the transaction circuit witness and generates the
+// proof with the circuit's backend
 func (tb *TransactionBuilder) generateProof(
 	ctx context.Context,
 	anchor types.Hash,
+	inputs []*NoteInput,
+	outputs []*NoteOutput,
 	nullifiers []types.Hash,
 	commitments []types.Commitment,
+	blinders [][]byte,
 ) (types.ZKProof, error) {
-	// Build circuit witness
-	values := make([]uint64, len(tb.inputs)+len(tb.outputs))
-	for i, input := range tb.inputs {
-		values[i] = input.Note.Value
-	}
-	for i, output := range tb.outputs {
-		values[len(tb.inputs)+i] = output.Value
+	assignment := newTransactionCircuit(len(inputs), len(outputs))
+	assignment.MerkleRoot = hashVariable(anchor)
+	assignment.Fee = tb.fee
+
+	for i, input := range inputs {
+		assignment.Nullifiers[i] = hashVariable(nullifiers[i])
+		assignment.SpendingKeys[i] = scalarVariable(input.SpendingKey)
+		assignment.InputValues[i] = input.Note.Value
+		assignment.InputAddresses[i] = scalarVariable(input.Note.Address[:])
+		assignment.InputBlinders[i] = scalarVariable(input.Note.Blinder)
+
+		path := input.MerklePath
+		for level, sibling := range path.Siblings {
+			assignment.MerklePaths[i][level] = hashVariable(sibling)
+			assignment.PathBits[i][level] = 0
+			if path.PathBits[level] {
+				assignment.PathBits[i][level] = 1
+			}
+		}
 	}
 
-	// In production, this would create a proper gnark witness
-	// and generate a real Groth16 proof
+	for i, output := range outputs {
+		assignment.Commitments[i] = hashVariable(commitments[i].Value)
+		assignment.OutputValues[i] = output.Value
+		assignment.OutputAddresses[i] = scalarVariable(output.Address[:])
+		assignment.OutputBlinders[i] = scalarVariable(blinders[i])
+	}
 
-	// For now, return a simulated proof
-	proofData := make([]byte, 192) // Groth16 proof size on BN254
-	copy(proofData, "SIMULATED_PROOF")
+	proof, err := tb.circuits.GenerateProof(ctx, ProofTypeTransaction, assignment)
+	if err != nil {
+		return types.ZKProof{}, err
+	}
 
 	return types.ZKProof{
-		ProofType: 1, // Groth16
-		ProofData: proofData,
+		ProofType: proof.System,
+		ProofData: proof.Proof,
+	}, nil
+}
+
+// dummyInput returns a zero-value input used to pad a transaction. Its
+// random key makes its nullifier unique; the circuit skips the Merkle
+// check for zero-value notes.
+func dummyInput() (*NoteInput, error) {
+	spendingKey, err := RandomBytes(32)
+	if err != nil {
+		return nil, err
+	}
+	blinder, err := RandomBytes(32)
+	if err != nil {
+		return nil, err
+	}
+
+	address := ShieldedAddress(spendingKey)
+	return &NoteInput{
+		Note: &Note{
+			Address:    address,
+			Blinder:    blinder,
+			Commitment: NoteCommitment(0, address, blinder),
+		},
+		SpendingKey: spendingKey,
+		MerklePath: &MerklePath{
+			Siblings: make([]types.Hash, TreeDepth),
+			PathBits: make([]bool, TreeDepth),
+		},
 	}, nil
 }
 
@@ -244,19 +377,10 @@ func (tb *TransactionBuilder) computeDisclosureFlags() uint32 {
 	return flags
 }
 
-// computeNoteCommitment computes the commitment for a note
-func computeNoteCommitment(value uint64, address types.Address, blinder []byte) types.Hash {
-	data := make([]byte, 0, 60)
-	data = append(data, uint64ToBytes(value)...)
-	data = append(data, address[:]...)
-	data = append(data, blinder...)
-
-	var hash types.Hash
-	// Simple hash - use proper crypto in production
-	for i := 0; i < types.HashSize && i < len(data); i++ {
-		hash[i] = data[i] ^ byte(i*31)
-	}
-	return hash
+// NoteCommitment computes the commitment for a note
+// commitment = MiMC(value, address, blinder)
+func NoteCommitment(value uint64, address types.Address, blinder []byte) types.Hash {
+	return mimcHash(uint64Element(value), fieldElement(address[:]), fieldElement(blinder))
 }
 
 // ShieldedPool manages the shielded transaction pool
@@ -269,6 +393,9 @@ type ShieldedPool struct {
 	// Nullifier set
 	nullifierSet *NullifierSet
 
+	// Recent commitment tree roots accepted as anchors
+	anchors *AnchorWindow
+
 	// Circuit manager
 	circuits *CircuitManager
 
@@ -286,61 +413,205 @@ func NewShieldedPool(
 	return &ShieldedPool{
 		commitmentTree: tree,
 		nullifierSet:   nullifiers,
+		anchors:        NewAnchorWindow(NewInMemoryAnchorStore(), DefaultAnchorWindow),
 		circuits:       circuits,
 		disclosures:    disclosures,
 	}
 }
 
-// ProcessTransaction validates and processes a shielded transaction
+// SetAnchorWindow replaces the default in-memory anchor window, e.g. with
+// one persisted to storage. Must be called before processing transactions.
+func (sp *ShieldedPool) SetAnchorWindow(anchors *AnchorWindow) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.anchors = anchors
+}
+
+// ProcessTransaction validates and processes a shielded transaction.
+// Everything is checked before anything is written, so a rejected
+// transaction leaves the pool unchanged.
 func (sp *ShieldedPool) ProcessTransaction(ctx context.Context, tx *types.Transaction, blockHeight uint64) error {
 	sp.mu.Lock()
 	defer sp.mu.Unlock()
 
-	// Verify anchor is valid
-	currentRoot := sp.commitmentTree.GetRoot()
-	if tx.Anchor != currentRoot {
-		// In production, we'd check if anchor is a recent root
-		// For now, just check current
+	// Verify anchor is the current or a recent root
+	if tx.Anchor != sp.commitmentTree.GetRoot() && !sp.anchors.Contains(tx.Anchor) {
 		return ErrInvalidAnchor
 	}
 
-	// Verify nullifiers are not spent
+	// Verify nullifiers are distinct and not spent
+	seen := make(map[types.Hash]struct{}, len(tx.Nullifiers))
 	for _, nullifier := range tx.Nullifiers {
-		spent, err := sp.nullifierSet.IsSpent(ctx, nullifier)
-		if err != nil {
-			return err
+		if _, exists := seen[nullifier]; exists {
+			return ErrNullifierRepeat
 		}
-		if spent {
+		seen[nullifier] = struct{}{}
+	}
+	spent, err := sp.nullifierSet.BatchCheck(ctx, tx.Nullifiers)
+	if err != nil {
+		return err
+	}
+	for _, s := range spent {
+		if s {
 			return ErrNullifierSpent
 		}
 	}
 
 	// Verify zk-SNARK proof
-	// (In production, this would verify the actual proof)
-	if len(tx.Proof.ProofData) < 10 {
-		return ErrProofFailed
+	if err := sp.circuits.VerifyTransaction(ctx, tx); err != nil {
+		return err
 	}
 
 	// Verify disclosures if required
 	// (This would check against policy)
 
-	// Mark nullifiers as spent
-	for _, nullifier := range tx.Nullifiers {
-		if err := sp.nullifierSet.MarkSpent(ctx, nullifier, tx.TxHash, blockHeight); err != nil {
+	// Check the commitments fit, and find the root they leave
+	commitments := make([]types.Hash, len(tx.Commitments))
+	for i, commitment := range tx.Commitments {
+		commitments[i] = commitment.Value
+	}
+	root, err := sp.commitmentTree.rootAfter(commitments)
+	if err != nil {
+		return err
+	}
+
+	// Mark nullifiers as spent and record the new root as an anchor for
+	// later transactions, then add the commitments to the tree
+	var anchor *types.Hash
+	if len(commitments) > 0 {
+		anchor = &root
+	}
+	if err := sp.recordSpends(ctx, tx.Nullifiers, tx.TxHash, blockHeight, anchor); err != nil {
+		return err
+	}
+	return sp.appendCommitments(ctx, commitments)
+}
+
+// recordSpends writes a transaction's nullifiers and anchor. They go in
+// one write when both live in the same SpendStore; other stores, such as
+// the in-memory ones, are written one after the other.
+func (sp *ShieldedPool) recordSpends(ctx context.Context, nullifiers []types.Hash, txHash types.Hash, blockHeight uint64, anchor *types.Hash) error {
+	if spends, ok := sp.nullifierSet.store.(SpendStore); ok && AnchorStore(spends) == sp.anchors.store {
+		if err := spends.AddSpends(ctx, nullifiers, txHash, blockHeight, anchor); err != nil {
 			return err
 		}
+		sp.nullifierSet.addToCache(nullifiers...)
+		if anchor != nil {
+			sp.anchors.push(*anchor)
+		}
+		return nil
 	}
 
-	// Add commitments to tree
-	for _, commitment := range tx.Commitments {
-		if _, err := sp.commitmentTree.AddCommitment(ctx, commitment.Value); err != nil {
+	for _, nullifier := range nullifiers {
+		if err := sp.nullifierSet.MarkSpent(ctx, nullifier, txHash, blockHeight); err != nil {
 			return err
 		}
 	}
+	if anchor != nil {
+		return sp.anchors.Add(ctx, *anchor, blockHeight)
+	}
+	return nil
+}
 
+// appendCommitments adds commitments checked to fit with rootAfter
+func (sp *ShieldedPool) appendCommitments(ctx context.Context, commitments []types.Hash) error {
+	for _, commitment := range commitments {
+		if _, err := sp.commitmentTree.AddCommitment(ctx, commitment); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// ApplyBlock adds the commitments of a block added to the DAG to the tree
+// and records the root they leave as an anchor. The block's nullifiers
+// are recorded when the block is stored.
+func (sp *ShieldedPool) ApplyBlock(ctx context.Context, block *types.Block) error {
+	commitments := dag.BlockCommitments(block.Transactions)
+	if len(commitments) == 0 {
+		return nil
+	}
+
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	root, err := sp.commitmentTree.rootAfter(commitments)
+	if err != nil {
+		return err
+	}
+	if err := sp.anchors.Add(ctx, root, block.Header.Height); err != nil {
+		return err
+	}
+	if err := sp.appendCommitments(ctx, commitments); err != nil {
+		return err
+	}
+	return sp.commitmentTree.Flush(ctx)
+}
+
+// Rebuild appends the commitments of every main chain block to an empty
+// tree, for trees that are not persisted. The anchor window is loaded
+// from its store instead.
+func (sp *ShieldedPool) Rebuild(ctx context.Context, chain FilterChain) error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	headers, err := chain.GetMainChain(ctx, 0, chain.GetHeight())
+	if err != nil {
+		return err
+	}
+	for _, h := range headers {
+		block, err := chain.GetBlock(ctx, h.Hash)
+		if err != nil {
+			return err
+		}
+		if err := sp.appendCommitments(ctx, dag.BlockCommitments(block.Transactions)); err != nil {
+			return err
+		}
+	}
+	return sp.commitmentTree.Flush(ctx)
+}
+
+// HasNullifiers reports, for each nullifier, whether it has been spent
+func (sp *ShieldedPool) HasNullifiers(ctx context.Context, nullifiers []types.Hash) ([]bool, error) {
+	return sp.nullifierSet.BatchCheck(ctx, nullifiers)
+}
+
+// VerifyTransaction verifies a transaction's zk-SNARK proof
+func (sp *ShieldedPool) VerifyTransaction(ctx context.Context, tx *types.Transaction) error {
+	return sp.circuits.VerifyTransaction(ctx, tx)
+}
+
+// CommitBlock persists the commitment tree changes made by a block's
+// transactions
+func (sp *ShieldedPool) CommitBlock(ctx context.Context) error {
+	return sp.commitmentTree.Flush(ctx)
+}
+
+// AnchorAge returns how many roots were added after root, or false if
+// transactions anchored to it are no longer accepted
+func (sp *ShieldedPool) AnchorAge(root types.Hash) (int, bool) {
+	sp.mu.RLock()
+	defer sp.mu.RUnlock()
+
+	if root == sp.commitmentTree.GetRoot() {
+		return 0, true
+	}
+	return sp.anchors.Age(root)
+}
+
+// CommitmentPosition returns the tree position of a commitment, or
+// ErrLeafNotFound if it has not been added yet
+func (sp *ShieldedPool) CommitmentPosition(ctx context.Context, commitment types.Hash) (uint64, error) {
+	found, position, err := sp.commitmentTree.ContainsCommitment(ctx, commitment)
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, ErrLeafNotFound
+	}
+	return position, nil
+}
+
 // GetCurrentAnchor returns the current commitment tree root
 func (sp *ShieldedPool) GetCurrentAnchor() types.Hash {
 	return sp.commitmentTree.GetRoot()
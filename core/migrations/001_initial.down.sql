@@ -0,0 +1,17 @@
+-- Reverts 001_initial.sql; development only, drops every chain table
+
+DROP TRIGGER IF EXISTS trigger_update_miner_timestamp ON miners;
+DROP FUNCTION IF EXISTS update_miner_timestamp();
+
+DROP TABLE IF EXISTS chain_state CASCADE;
+DROP TABLE IF EXISTS inference_nodes CASCADE;
+DROP TABLE IF EXISTS votes CASCADE;
+DROP TABLE IF EXISTS proposals CASCADE;
+DROP TABLE IF EXISTS tasks CASCADE;
+DROP TABLE IF EXISTS model_contributors CASCADE;
+DROP TABLE IF EXISTS models CASCADE;
+DROP TABLE IF EXISTS miners CASCADE;
+DROP TABLE IF EXISTS commitments CASCADE;
+DROP TABLE IF EXISTS nullifiers CASCADE;
+DROP TABLE IF EXISTS transactions CASCADE;
+DROP TABLE IF EXISTS blocks CASCADE;
@@ -0,0 +1,3 @@
+-- Reverts 002_online_migrations.sql
+
+DROP TABLE IF EXISTS online_migrations;
@@ -0,0 +1,21 @@
+-- CCoin Database Schema v1.1
+-- Progress tracking for online (dual-write) migrations run by `ccoind migrate --online`
+
+CREATE TABLE IF NOT EXISTS online_migrations (
+    -- Registered migration name
+    name VARCHAR(100) PRIMARY KEY,
+    
+    -- Table being migrated
+    table_name VARCHAR(100) NOT NULL,
+    
+    -- pending, dual_write, backfill or complete
+    phase VARCHAR(20) NOT NULL,
+    
+    -- Last primary key copied by the backfill
+    cursor BYTEA,
+    
+    -- Rows copied so far
+    rows_copied BIGINT NOT NULL DEFAULT 0,
+    
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
@@ -0,0 +1,3 @@
+-- Reverts 003_staking_snapshots.sql
+
+DROP TABLE IF EXISTS staking_snapshots;
@@ -0,0 +1,15 @@
+-- CCoin Database Schema v1.2
+-- Per-epoch staking totals for historical analytics
+
+CREATE TABLE IF NOT EXISTS staking_snapshots (
+    -- Epoch the snapshot closes
+    epoch BIGINT PRIMARY KEY CHECK (epoch >= 0),
+    
+    -- Sum of miners.staked_amount at the epoch boundary
+    total_staked DECIMAL(30, 0) NOT NULL DEFAULT 0,
+    
+    -- Number of miners with a non-zero stake
+    stakers BIGINT NOT NULL DEFAULT 0,
+    
+    recorded_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
@@ -0,0 +1,9 @@
+-- Reverts 004_miner_payouts.sql
+
+ALTER TABLE miners DROP COLUMN IF EXISTS payout_nonce;
+ALTER TABLE miners DROP COLUMN IF EXISTS payout_activation;
+ALTER TABLE miners DROP COLUMN IF EXISTS pending_payout;
+ALTER TABLE miners DROP COLUMN IF EXISTS payout_address;
+
+DROP INDEX IF EXISTS idx_blocks_payout;
+ALTER TABLE blocks DROP COLUMN IF EXISTS payout_address;
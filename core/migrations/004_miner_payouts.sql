@@ -0,0 +1,20 @@
+-- CCoin Database Schema v1.3
+-- Miner payout addresses separate from identity addresses
+
+-- Address the block reward was paid to
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS payout_address BYTEA
+    CHECK (payout_address IS NULL OR length(payout_address) = 20);
+
+CREATE INDEX IF NOT EXISTS idx_blocks_payout ON blocks(payout_address);
+
+-- Registered payout address (NULL pays the miner's own address)
+ALTER TABLE miners ADD COLUMN IF NOT EXISTS payout_address BYTEA
+    CHECK (payout_address IS NULL OR length(payout_address) = 20);
+
+-- Payout change waiting for its activation height
+ALTER TABLE miners ADD COLUMN IF NOT EXISTS pending_payout BYTEA
+    CHECK (pending_payout IS NULL OR length(pending_payout) = 20);
+ALTER TABLE miners ADD COLUMN IF NOT EXISTS payout_activation BIGINT;
+
+-- Nonce of the last accepted payout change, rejecting replays
+ALTER TABLE miners ADD COLUMN IF NOT EXISTS payout_nonce BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+-- Reverts 005_commitment_anchors.sql
+
+DROP TABLE IF EXISTS commitment_anchors;
@@ -0,0 +1,18 @@
+-- CCoin Database Schema v1.4
+-- Commitment tree root history for the recent-anchor window
+
+CREATE TABLE IF NOT EXISTS commitment_anchors (
+    -- Insertion order
+    seq BIGSERIAL PRIMARY KEY,
+
+    -- Commitment tree root after a transaction's outputs were added
+    root BYTEA NOT NULL CHECK (length(root) = 32),
+
+    -- Block height of the transaction that produced it
+    block_height BIGINT NOT NULL,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Index for pruning old anchors by height
+CREATE INDEX IF NOT EXISTS idx_commitment_anchors_height ON commitment_anchors(block_height);
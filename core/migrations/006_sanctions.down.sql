@@ -0,0 +1,3 @@
+-- Reverts 006_sanctions.sql
+
+DROP TABLE IF EXISTS sanctioned_addresses;
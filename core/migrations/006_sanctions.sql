@@ -0,0 +1,11 @@
+-- CCoin Database Schema v1.5
+-- Governance-managed sanctions list
+
+CREATE TABLE IF NOT EXISTS sanctioned_addresses (
+    address BYTEA PRIMARY KEY CHECK (length(address) = 20),
+
+    -- Sanctions update proposal that listed the address
+    proposal_id BYTEA NOT NULL CHECK (length(proposal_id) = 32),
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
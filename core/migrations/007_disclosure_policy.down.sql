@@ -0,0 +1,3 @@
+-- Reverts 007_disclosure_policy.sql
+
+DROP TABLE IF EXISTS disclosure_policy_rules;
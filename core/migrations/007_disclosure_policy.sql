@@ -0,0 +1,26 @@
+-- CCoin Database Schema v1.6
+-- Governance-managed disclosure policy
+
+CREATE TABLE IF NOT EXISTS disclosure_policy_rules (
+    network TEXT NOT NULL,
+    position INTEGER NOT NULL CHECK (position >= 0),
+
+    name TEXT NOT NULL,
+
+    -- Rule applies when a transaction may move at least this much
+    min_value BIGINT NOT NULL CHECK (min_value >= 0),
+
+    -- Recipient classes and authority domains matched; empty matches any
+    recipients SMALLINT[] NOT NULL DEFAULT '{}',
+    jurisdictions TEXT[] NOT NULL DEFAULT '{}',
+
+    -- Disclosure flags required by the rule
+    required_flags INTEGER NOT NULL,
+
+    -- Parameter adjustment proposal that set the policy
+    proposal_id BYTEA NOT NULL CHECK (length(proposal_id) = 32),
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    PRIMARY KEY (network, position)
+);
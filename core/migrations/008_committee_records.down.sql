@@ -0,0 +1,6 @@
+-- Reverts 008_committee_records.sql
+
+DROP TABLE IF EXISTS committee_candidates;
+DROP TABLE IF EXISTS committee_records;
+
+ALTER TABLE blocks DROP COLUMN IF EXISTS committee_root;
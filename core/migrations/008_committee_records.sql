@@ -0,0 +1,38 @@
+-- CCoin Database Schema v1.7
+-- Committee selection audit log
+
+-- Root of the committees selected for the epoch a block opens
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS committee_root BYTEA
+    CHECK (committee_root IS NULL OR length(committee_root) = 32);
+
+CREATE TABLE IF NOT EXISTS committee_records (
+    epoch BIGINT NOT NULL CHECK (epoch >= 0),
+    kind SMALLINT NOT NULL,
+
+    -- Randomness the committee was drawn with
+    beacon BYTEA NOT NULL CHECK (length(beacon) = 32),
+    size INTEGER NOT NULL CHECK (size >= 0),
+
+    record_hash BYTEA NOT NULL CHECK (length(record_hash) = 32),
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    PRIMARY KEY (epoch, kind)
+);
+
+-- Stake and reputation snapshot of each selection; seat is the draw
+-- position of selected candidates
+CREATE TABLE IF NOT EXISTS committee_candidates (
+    epoch BIGINT NOT NULL,
+    kind SMALLINT NOT NULL,
+    address BYTEA NOT NULL CHECK (length(address) = 20),
+
+    stake BIGINT NOT NULL CHECK (stake >= 0),
+    reputation BIGINT NOT NULL CHECK (reputation >= 0), -- fixed point, 1e9 = 1.0
+    seat INTEGER,
+
+    PRIMARY KEY (epoch, kind, address),
+    FOREIGN KEY (epoch, kind) REFERENCES committee_records(epoch, kind)
+);
+
+CREATE INDEX IF NOT EXISTS idx_committee_candidates_address ON committee_candidates(address);
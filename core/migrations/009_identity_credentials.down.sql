@@ -0,0 +1,4 @@
+-- Reverts 009_identity_credentials.sql
+
+DROP TABLE IF EXISTS revoked_credentials;
+DROP TABLE IF EXISTS credential_authorities;
@@ -0,0 +1,23 @@
+-- CCoin Database Schema v1.8
+-- Governance-registered credential authorities and revocations
+
+CREATE TABLE IF NOT EXISTS credential_authorities (
+    -- Compressed EdDSA public key credentials are verified against
+    public_key BYTEA PRIMARY KEY CHECK (length(public_key) = 32),
+    name TEXT NOT NULL,
+    domain TEXT NOT NULL DEFAULT '',
+
+    -- Identity authority proposal that registered the key
+    proposal_id BYTEA NOT NULL CHECK (length(proposal_id) = 32),
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS revoked_credentials (
+    serial BYTEA PRIMARY KEY CHECK (length(serial) = 20),
+
+    -- Credential revocation proposal that revoked the serial
+    proposal_id BYTEA NOT NULL CHECK (length(proposal_id) = 32),
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
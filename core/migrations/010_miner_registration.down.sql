@@ -0,0 +1,5 @@
+-- Reverts 010_miner_registration.sql
+
+DROP TABLE IF EXISTS miner_capabilities;
+DROP TABLE IF EXISTS slashing_evidence;
+DROP TABLE IF EXISTS miner_stakes;
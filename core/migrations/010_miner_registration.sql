@@ -0,0 +1,49 @@
+-- CCoin Database Schema v1.9
+-- Miner stakes, slashing evidence and compute capabilities
+
+CREATE TABLE IF NOT EXISTS miner_stakes (
+    address BYTEA PRIMARY KEY CHECK (length(address) = 20),
+
+    total_staked BIGINT NOT NULL DEFAULT 0 CHECK (total_staked >= 0),
+    available_stake BIGINT NOT NULL DEFAULT 0 CHECK (available_stake >= 0),
+    locked_stake BIGINT NOT NULL DEFAULT 0 CHECK (locked_stake >= 0),
+    locked_until_block BIGINT NOT NULL DEFAULT 0,
+
+    total_slashed BIGINT NOT NULL DEFAULT 0 CHECK (total_slashed >= 0),
+    slashing_ratio DOUBLE PRECISION NOT NULL DEFAULT 0,
+
+    -- Height the first stake was posted at
+    bonded_at BIGINT NOT NULL DEFAULT 0,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS slashing_evidence (
+    evidence_hash BYTEA PRIMARY KEY CHECK (length(evidence_hash) = 32),
+    slash_type SMALLINT NOT NULL,
+    miner_address BYTEA NOT NULL CHECK (length(miner_address) = 20),
+    block_height BIGINT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    proof_data BYTEA,
+
+    processed BOOLEAN NOT NULL DEFAULT FALSE,
+    slash_amount BIGINT NOT NULL DEFAULT 0,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_slashing_evidence_pending ON slashing_evidence(created_at) WHERE NOT processed;
+
+-- Hardware a miner offers for proof-of-useful-work tasks
+CREATE TABLE IF NOT EXISTS miner_capabilities (
+    address BYTEA PRIMARY KEY CHECK (length(address) = 20),
+
+    cpus INTEGER NOT NULL CHECK (cpus > 0),
+    memory_mb BIGINT NOT NULL CHECK (memory_mb >= 0),
+    gpus TEXT[] NOT NULL DEFAULT '{}',
+    gpu_memory_mb BIGINT NOT NULL DEFAULT 0,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
@@ -0,0 +1,5 @@
+-- Reverts 011_vrf_proofs.sql
+
+ALTER TABLE blocks DROP COLUMN IF EXISTS vrf_proof;
+ALTER TABLE blocks DROP COLUMN IF EXISTS vrf_seed;
+ALTER TABLE blocks DROP COLUMN IF EXISTS miner_public_key;
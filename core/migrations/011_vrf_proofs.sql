@@ -0,0 +1,11 @@
+-- CCoin Database Schema v1.10
+-- VRF task selection proofs in block headers
+
+-- Key the miner proved with, the recent block hash it proved over and the
+-- ECVRF proof itself; all NULL for blocks mined before VRF selection
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS miner_public_key BYTEA
+    CHECK (miner_public_key IS NULL OR length(miner_public_key) = 32);
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS vrf_seed BYTEA
+    CHECK (vrf_seed IS NULL OR length(vrf_seed) = 32);
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS vrf_proof BYTEA
+    CHECK (vrf_proof IS NULL OR length(vrf_proof) = 80);
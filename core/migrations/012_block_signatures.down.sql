@@ -0,0 +1,3 @@
+-- Reverts 012_block_signatures.sql
+
+ALTER TABLE blocks DROP COLUMN IF EXISTS signature;
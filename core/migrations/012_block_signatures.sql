@@ -0,0 +1,7 @@
+-- CCoin Database Schema v1.11
+-- Miner signatures in block headers
+
+-- Ed25519 signature over the block hash by miner_public_key; NULL for
+-- blocks mined before header signing
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS signature BYTEA
+    CHECK (signature IS NULL OR length(signature) = 64);
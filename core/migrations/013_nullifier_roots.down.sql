@@ -0,0 +1,3 @@
+-- Reverts 013_nullifier_roots.sql
+
+ALTER TABLE blocks DROP COLUMN IF EXISTS nullifier_root;
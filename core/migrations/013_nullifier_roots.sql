@@ -0,0 +1,7 @@
+-- CCoin Database Schema v1.12
+-- Nullifier accumulator roots in block headers
+
+-- Root of the nullifiers spent along the block's selected-parent chain;
+-- NULL for genesis and blocks mined before the commitment
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS nullifier_root BYTEA
+    CHECK (nullifier_root IS NULL OR length(nullifier_root) = 32);
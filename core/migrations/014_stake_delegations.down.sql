@@ -0,0 +1,3 @@
+-- Reverts 014_stake_delegations.sql
+
+DROP TABLE IF EXISTS stake_delegations;
@@ -0,0 +1,27 @@
+-- CCoin Database Schema v1.13
+-- Stake delegated by holders to miners
+
+CREATE TABLE IF NOT EXISTS stake_delegations (
+    delegator BYTEA NOT NULL CHECK (length(delegator) = 20),
+    miner BYTEA NOT NULL CHECK (length(miner) = 20),
+
+    bonded BIGINT NOT NULL DEFAULT 0 CHECK (bonded >= 0),
+
+    -- Stake being undelegated, released at unbonding_until
+    unbonding BIGINT NOT NULL DEFAULT 0 CHECK (unbonding >= 0),
+    unbonding_until BIGINT NOT NULL DEFAULT 0,
+
+    -- Cumulative staker rewards and slashings shared with the miner
+    rewards BIGINT NOT NULL DEFAULT 0 CHECK (rewards >= 0),
+    slashed BIGINT NOT NULL DEFAULT 0 CHECK (slashed >= 0),
+
+    -- Nonce of the delegator's last delegation to the miner
+    nonce BIGINT NOT NULL DEFAULT 0,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    PRIMARY KEY (miner, delegator)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stake_delegations_delegator ON stake_delegations(delegator);
@@ -0,0 +1,4 @@
+-- Reverts 015_block_pruning.sql
+
+DROP INDEX IF EXISTS idx_blocks_unpruned;
+ALTER TABLE blocks DROP COLUMN IF EXISTS pruned;
@@ -0,0 +1,8 @@
+-- CCoin Database Schema v1.14
+-- Pruned block bodies
+
+-- Set once a block's transaction bodies have been discarded by pruning;
+-- its header, nullifiers and commitments are kept
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS pruned BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_blocks_unpruned ON blocks(height) WHERE pruned = FALSE;
@@ -0,0 +1,3 @@
+-- Reverts 016_stake_unbonding.sql
+
+DROP TABLE IF EXISTS stake_unbonding;
@@ -0,0 +1,22 @@
+-- CCoin Database Schema v1.15
+-- Unbonding queue for miner stake withdrawals
+
+-- Withdrawals waiting out their unbonding period; their total is the
+-- miner's locked_stake
+CREATE TABLE IF NOT EXISTS stake_unbonding (
+    id BIGSERIAL PRIMARY KEY,
+    address BYTEA NOT NULL REFERENCES miner_stakes(address) ON DELETE CASCADE,
+
+    amount BIGINT NOT NULL CHECK (amount >= 0),
+
+    -- Height the withdrawal was requested at; offenses before it are
+    -- still slashed from the amount
+    requested_at BIGINT NOT NULL,
+
+    -- Height the stake is released at
+    completes_at BIGINT NOT NULL,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stake_unbonding_address ON stake_unbonding(address, completes_at);
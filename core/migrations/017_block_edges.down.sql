@@ -0,0 +1,4 @@
+-- Reverts 017_block_edges.sql
+
+DROP TABLE IF EXISTS block_tips;
+DROP TABLE IF EXISTS block_edges;
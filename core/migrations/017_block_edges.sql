@@ -0,0 +1,31 @@
+-- CCoin Database Schema v1.16
+-- Parent-child edges and the tip set, maintained as blocks are saved
+
+-- One row per parent reference, so children are found by index rather
+-- than by scanning blocks.parents
+CREATE TABLE IF NOT EXISTS block_edges (
+    parent BYTEA NOT NULL CHECK (length(parent) = 32),
+    child BYTEA NOT NULL CHECK (length(child) = 32),
+
+    PRIMARY KEY (parent, child)
+);
+
+CREATE INDEX IF NOT EXISTS idx_block_edges_child ON block_edges(child);
+
+-- Blocks no saved block references as a parent
+CREATE TABLE IF NOT EXISTS block_tips (
+    hash BYTEA PRIMARY KEY CHECK (length(hash) = 32),
+    height BIGINT NOT NULL,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Backfill from the blocks already stored
+INSERT INTO block_edges (parent, child)
+SELECT p, b.hash FROM blocks b, unnest(b.parents) AS p
+ON CONFLICT DO NOTHING;
+
+INSERT INTO block_tips (hash, height)
+SELECT b.hash, b.height FROM blocks b
+WHERE NOT EXISTS (SELECT 1 FROM block_edges e WHERE e.parent = b.hash)
+ON CONFLICT DO NOTHING;
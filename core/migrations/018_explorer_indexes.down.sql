@@ -0,0 +1,7 @@
+-- Reverts 018_explorer_indexes.sql
+
+DROP INDEX IF EXISTS idx_commitments_tx;
+DELETE FROM commitments WHERE tree_index IS NULL;
+ALTER TABLE commitments DROP COLUMN IF EXISTS output_index;
+ALTER TABLE commitments DROP COLUMN IF EXISTS block_hash;
+ALTER TABLE commitments ALTER COLUMN tree_index SET NOT NULL;
@@ -0,0 +1,21 @@
+-- CCoin Database Schema v1.17
+-- Commitments indexed by the block that created them, for explorer queries
+
+-- Storage does not track tree positions; the commitment tree assigns them
+ALTER TABLE commitments ALTER COLUMN tree_index DROP NOT NULL;
+
+ALTER TABLE commitments ADD COLUMN IF NOT EXISTS block_hash BYTEA
+    CHECK (block_hash IS NULL OR length(block_hash) = 32);
+
+-- Position among the creating transaction's outputs
+ALTER TABLE commitments ADD COLUMN IF NOT EXISTS output_index INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_commitments_tx ON commitments(tx_hash);
+
+-- Backfill from the transactions already stored
+INSERT INTO commitments (commitment, block_height, tx_hash, block_hash, output_index)
+SELECT c.value, b.height, t.tx_hash, t.block_hash, c.ord - 1
+FROM transactions t
+JOIN blocks b ON b.hash = t.block_hash,
+     unnest(t.commitments) WITH ORDINALITY AS c(value, ord)
+ON CONFLICT (commitment) DO NOTHING;
@@ -0,0 +1,3 @@
+-- Reverts 019_registry_roots.sql
+
+ALTER TABLE blocks DROP COLUMN IF EXISTS registry_root;
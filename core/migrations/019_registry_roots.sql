@@ -0,0 +1,7 @@
+-- CCoin Database Schema v1.18
+-- Model registry checkpoints in block headers
+
+-- Root of the model registry checkpointed for the epoch a block opens;
+-- NULL in other blocks and while the registry is empty
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS registry_root BYTEA
+    CHECK (registry_root IS NULL OR length(registry_root) = 32);
@@ -0,0 +1,3 @@
+-- Reverts 020_receipts_roots.sql
+
+ALTER TABLE blocks DROP COLUMN IF EXISTS receipts_root;
@@ -0,0 +1,7 @@
+-- CCoin Database Schema v1.19
+-- Transaction receipts roots in block headers
+
+-- Merkle root of the block's transaction receipts; NULL in blocks
+-- without transactions
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS receipts_root BYTEA
+    CHECK (receipts_root IS NULL OR length(receipts_root) = 32);
@@ -0,0 +1,11 @@
+// Package migrations holds the PostgreSQL schema as versioned SQL files
+// compiled into the binary. NNN_name.sql moves the schema to version NNN
+// and the optional NNN_name.down.sql moves it back to the version before.
+package migrations
+
+import "embed"
+
+// FS holds the migration files
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,406 @@
+package common
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Canonical encoding errors
+var (
+	ErrDuplicateKey    = errors.New("duplicate object key")
+	ErrInvalidNumber   = errors.New("number out of range or not finite")
+	ErrInvalidString   = errors.New("string is not valid UTF-8")
+	ErrUnsupportedType = errors.New("type has no canonical encoding")
+	ErrTrailingData    = errors.New("trailing data after JSON value")
+)
+
+// CanonicalJSON encodes v in canonical JSON so that every node produces
+// identical bytes to sign and hash. The form follows RFC 8785 (JCS):
+// no insignificant whitespace, object keys sorted by UTF-16 code units,
+// minimal string escaping and shortest round-trip floats. It differs in
+// that integers are written exactly rather than as IEEE doubles, so
+// 64-bit amounts survive, and floats of 2^63 or more always use exponent
+// notation. Byte arrays and slices (hashes, addresses, keys) are written
+// as 0x-prefixed hex strings, struct fields use their json tag name or
+// else the field name, and omitempty is ignored. NaN and infinities are
+// rejected.
+func CanonicalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeCanonical(&buf, reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// CanonicalHash returns the SHA-256 digest of v's canonical encoding
+func CanonicalHash(v interface{}) ([32]byte, error) {
+	data, err := CanonicalJSON(v)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
+
+// Canonicalize rewrites a JSON document in canonical form. Duplicate
+// object keys, integers outside the 64-bit range and floats that
+// overflow are rejected.
+func Canonicalize(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var buf bytes.Buffer
+	if err := canonicalizeValue(&buf, dec); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, ErrTrailingData
+	}
+	return buf.Bytes(), nil
+}
+
+// IsCanonical reports whether data is already in canonical form
+func IsCanonical(data []byte) bool {
+	canonical, err := Canonicalize(data)
+	return err == nil && bytes.Equal(canonical, data)
+}
+
+var bigIntType = reflect.TypeOf(big.Int{})
+
+// maxPlainFloat is the magnitude (2^63) from which floats are written in
+// exponent notation
+const maxPlainFloat = 1 << 63
+
+// encodeCanonical writes a Go value in canonical form
+func encodeCanonical(buf *bytes.Buffer, v reflect.Value) error {
+	if !v.IsValid() {
+		buf.WriteString("null")
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		if v.Kind() == reflect.Ptr && v.Elem().Type() == bigIntType {
+			buf.WriteString(v.Interface().(*big.Int).String())
+			return nil
+		}
+		return encodeCanonical(buf, v.Elem())
+
+	case reflect.Bool:
+		buf.WriteString(strconv.FormatBool(v.Bool()))
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		buf.WriteString(strconv.FormatInt(v.Int(), 10))
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		buf.WriteString(strconv.FormatUint(v.Uint(), 10))
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		s, err := formatFloat(v.Float())
+		if err != nil {
+			return err
+		}
+		buf.WriteString(s)
+		return nil
+
+	case reflect.String:
+		return writeString(buf, v.String())
+
+	case reflect.Array, reflect.Slice:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			buf.WriteString(`"0x` + hex.EncodeToString(b) + `"`)
+			return nil
+		}
+		buf.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeCanonical(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		if v.Type().Key().Kind() != reflect.String {
+			return ErrUnsupportedType
+		}
+		members := make(map[string]reflect.Value, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			members[iter.Key().String()] = iter.Value()
+		}
+		return writeObject(buf, members)
+
+	case reflect.Struct:
+		t := v.Type()
+		if t == bigIntType {
+			b := v.Interface().(big.Int)
+			buf.WriteString(b.String())
+			return nil
+		}
+		members := make(map[string]reflect.Value, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue // unexported
+			}
+			name := field.Name
+			if tag := field.Tag.Get("json"); tag != "" {
+				if tag == "-" {
+					continue
+				}
+				if n := strings.Split(tag, ",")[0]; n != "" {
+					name = n
+				}
+			}
+			if _, exists := members[name]; exists {
+				return ErrDuplicateKey
+			}
+			members[name] = v.Field(i)
+		}
+		return writeObject(buf, members)
+	}
+
+	return ErrUnsupportedType
+}
+
+// writeObject writes members with keys in canonical order
+func writeObject(buf *bytes.Buffer, members map[string]reflect.Value) error {
+	keys := make([]string, 0, len(members))
+	for k := range members {
+		keys = append(keys, k)
+	}
+	sortKeys(keys)
+
+	buf.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := writeString(buf, k); err != nil {
+			return err
+		}
+		buf.WriteByte(':')
+		if err := encodeCanonical(buf, members[k]); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// canonicalizeValue re-encodes the next JSON value from dec
+func canonicalizeValue(buf *bytes.Buffer, dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch t := tok.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(t))
+	case string:
+		return writeString(buf, t)
+	case json.Number:
+		s, err := canonicalNumber(string(t))
+		if err != nil {
+			return err
+		}
+		buf.WriteString(s)
+	case json.Delim:
+		if t == '[' {
+			buf.WriteByte('[')
+			for i := 0; dec.More(); i++ {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				if err := canonicalizeValue(buf, dec); err != nil {
+					return err
+				}
+			}
+			buf.WriteByte(']')
+			_, err := dec.Token()
+			return err
+		}
+
+		// Object: encode each member separately, then sort
+		members := make(map[string][]byte)
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key := keyTok.(string)
+			if _, exists := members[key]; exists {
+				return ErrDuplicateKey
+			}
+			var member bytes.Buffer
+			if err := canonicalizeValue(&member, dec); err != nil {
+				return err
+			}
+			members[key] = member.Bytes()
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+
+		keys := make([]string, 0, len(members))
+		for k := range members {
+			keys = append(keys, k)
+		}
+		sortKeys(keys)
+
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeString(buf, k); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			buf.Write(members[k])
+		}
+		buf.WriteByte('}')
+	}
+	return nil
+}
+
+// canonicalNumber normalizes a JSON number literal. Integer literals stay
+// exact and must fit in 64 bits; anything else is parsed as a double.
+func canonicalNumber(lit string) (string, error) {
+	if !strings.ContainsAny(lit, ".eE") {
+		if strings.HasPrefix(lit, "-") {
+			n, err := strconv.ParseInt(lit, 10, 64)
+			if err != nil {
+				return "", ErrInvalidNumber
+			}
+			return strconv.FormatInt(n, 10), nil
+		}
+		n, err := strconv.ParseUint(lit, 10, 64)
+		if err != nil {
+			return "", ErrInvalidNumber
+		}
+		return strconv.FormatUint(n, 10), nil
+	}
+
+	f, err := strconv.ParseFloat(lit, 64)
+	if err != nil {
+		return "", ErrInvalidNumber
+	}
+	return formatFloat(f)
+}
+
+// formatFloat writes a double in the shortest form that round-trips,
+// switching to exponent notation below 1e-6 as ECMAScript does. Above
+// 2^63, where ECMAScript would print a plain integer up to 1e21, exponent
+// notation is used so the output cannot be mistaken for an out-of-range
+// integer literal.
+func formatFloat(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", ErrInvalidNumber
+	}
+	if f == 0 {
+		return "0", nil // Also normalizes -0
+	}
+
+	abs := math.Abs(f)
+	if abs >= 1e-6 && abs < maxPlainFloat {
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	}
+
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	// Go pads exponents to two digits: 1e-07 -> 1e-7
+	if n := len(s); n >= 4 && s[n-2] == '0' && (s[n-3] == '-' || s[n-3] == '+') {
+		s = s[:n-2] + s[n-1:]
+	}
+	return s, nil
+}
+
+// writeString writes s with minimal escaping: only quotes, backslashes
+// and control characters are escaped
+func writeString(buf *bytes.Buffer, s string) error {
+	if !utf8.ValidString(s) {
+		return ErrInvalidString
+	}
+
+	const hexDigits = "0123456789abcdef"
+	buf.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			buf.WriteString(`\"`)
+		case c == '\\':
+			buf.WriteString(`\\`)
+		case c == '\b':
+			buf.WriteString(`\b`)
+		case c == '\f':
+			buf.WriteString(`\f`)
+		case c == '\n':
+			buf.WriteString(`\n`)
+		case c == '\r':
+			buf.WriteString(`\r`)
+		case c == '\t':
+			buf.WriteString(`\t`)
+		case c < 0x20:
+			buf.WriteString(`\u00`)
+			buf.WriteByte(hexDigits[c>>4])
+			buf.WriteByte(hexDigits[c&0xf])
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	buf.WriteByte('"')
+	return nil
+}
+
+// sortKeys orders object keys by their UTF-16 code units
+func sortKeys(keys []string) {
+	units := make(map[string][]uint16, len(keys))
+	for _, k := range keys {
+		units[k] = utf16.Encode([]rune(k))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := units[keys[i]], units[keys[j]]
+		for n := 0; n < len(a) && n < len(b); n++ {
+			if a[n] != b[n] {
+				return a[n] < b[n]
+			}
+		}
+		return len(a) < len(b)
+	})
+}
@@ -0,0 +1,25 @@
+//go:build ignore
+
+// gen regenerates vectors.json from this repository's implementation
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/ccoin/core/pkg/testvectors"
+)
+
+func main() {
+	v, err := testvectors.Generate()
+	if err != nil {
+		log.Fatalf("Failed to generate test vectors: %v", err)
+	}
+	data, err := v.Marshal()
+	if err != nil {
+		log.Fatalf("Failed to encode test vectors: %v", err)
+	}
+	if err := os.WriteFile("vectors.json", data, 0o644); err != nil {
+		log.Fatalf("Failed to write test vectors: %v", err)
+	}
+}
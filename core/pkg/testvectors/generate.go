@@ -0,0 +1,281 @@
+package testvectors
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"github.com/ccoin/core/internal/economics"
+	"github.com/ccoin/core/pkg/common"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Generate builds the vectors from fixed inputs, computing every result
+// with Reference. It is how vectors.json is produced.
+func Generate() (*Vectors, error) {
+	v := &Vectors{
+		Version:     Version,
+		BlockHashes: blockHashInputs(),
+		TxHashes:    txHashInputs(),
+		Nullifiers:  nullifierInputs(),
+		MerkleRoots: merkleRootInputs(),
+		Rewards:     rewardInputs(),
+		Difficulty:  difficultyInputs(),
+	}
+
+	ref := Reference()
+	var err error
+	for _, vec := range v.BlockHashes {
+		if vec.Hash, err = ref.BlockHash(vec); err != nil {
+			return nil, fmt.Errorf("block hash %q: %w", vec.Name, err)
+		}
+	}
+	for _, vec := range v.TxHashes {
+		if vec.Hash, err = ref.TxHash(vec); err != nil {
+			return nil, fmt.Errorf("tx hash %q: %w", vec.Name, err)
+		}
+	}
+	for _, vec := range v.Nullifiers {
+		if vec.Commitment, vec.Nullifier, err = ref.Nullifier(vec); err != nil {
+			return nil, fmt.Errorf("nullifier %q: %w", vec.Name, err)
+		}
+	}
+	for _, vec := range v.MerkleRoots {
+		if vec.Root, err = ref.MerkleRoot(vec); err != nil {
+			return nil, fmt.Errorf("merkle root %q: %w", vec.Name, err)
+		}
+	}
+	for _, vec := range v.Rewards {
+		r, err := ref.Rewards(vec)
+		if err != nil {
+			return nil, fmt.Errorf("rewards %q: %w", vec.Name, err)
+		}
+		vec.Rewards = *r
+	}
+	for _, vec := range v.Difficulty {
+		if vec.Difficulty, err = ref.Difficulty(vec); err != nil {
+			return nil, fmt.Errorf("difficulty %q: %w", vec.Name, err)
+		}
+	}
+	return v, nil
+}
+
+// seed returns n deterministic bytes derived from label
+func seed(label string, n int) []byte {
+	var out []byte
+	for i := 0; len(out) < n; i++ {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("ccoin test vector %s %d", label, i)))
+		out = append(out, sum[:]...)
+	}
+	return out[:n]
+}
+
+func seedHex(label string, n int) string {
+	return common.BytesToHex(seed(label, n))
+}
+
+func seedHash(label string) string {
+	return seedHex(label, types.HashSize)
+}
+
+func seedAddress(label string) string {
+	return seedHex(label, types.AddressSize)
+}
+
+// zeroHash is an unset optional root
+var zeroHash = common.BytesToHex(make([]byte, types.HashSize))
+
+func pow2(n uint) string {
+	return new(big.Int).Lsh(big.NewInt(1), n).String()
+}
+
+func blockHashInputs() []*BlockHashVector {
+	header := func(name string) *BlockHashVector {
+		return &BlockHashVector{
+			Name:           name,
+			Version:        1,
+			Parents:        []string{seedHash(name + " parent")},
+			TxRoot:         seedHash(name + " tx root"),
+			StateRoot:      seedHash(name + " state root"),
+			PoUWResult:     seedHash(name + " pouw result"),
+			TaskID:         seedHash(name + " task"),
+			MinerAddress:   seedAddress(name + " miner"),
+			PayoutAddress:  seedAddress(name + " payout"),
+			Difficulty:     pow2(200),
+			Nonce:          42,
+			Timestamp:      1700000000,
+			CommitteeRoot:  zeroHash,
+			MinerPublicKey: "0x",
+			VRFSeed:        zeroHash,
+			VRFProof:       "0x",
+			NullifierRoot:  zeroHash,
+			RegistryRoot:   zeroHash,
+			ReceiptsRoot:   zeroHash,
+		}
+	}
+
+	genesis := header("genesis")
+	genesis.Version = 0
+	genesis.Parents = []string{}
+	genesis.TxRoot = zeroHash
+	genesis.Nonce = 0
+
+	minimal := header("minimal")
+
+	merge := header("multiple parents")
+	merge.Parents = []string{
+		seedHash("merge parent 1"),
+		seedHash("merge parent 2"),
+		seedHash("merge parent 3"),
+	}
+	merge.Nonce = 1<<64 - 1
+	merge.Difficulty = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1)).String()
+
+	roots := header("all roots")
+	roots.CommitteeRoot = seedHash("all roots committee")
+	roots.NullifierRoot = seedHash("all roots nullifier")
+	roots.RegistryRoot = seedHash("all roots registry")
+	roots.ReceiptsRoot = seedHash("all roots receipts")
+
+	vrf := header("vrf")
+	vrf.MinerPublicKey = seedHex("vrf public key", 32)
+	vrf.VRFSeed = seedHash("vrf seed")
+	vrf.VRFProof = seedHex("vrf proof", 80)
+
+	receipts := header("receipts root only")
+	receipts.ReceiptsRoot = seedHash("receipts root only receipts")
+
+	return []*BlockHashVector{genesis, minimal, merge, roots, vrf, receipts}
+}
+
+func txHashInputs() []*TxHashVector {
+	tx := func(name string, inputs, outputs, proofLen int, fee uint64) *TxHashVector {
+		v := &TxHashVector{
+			Name:        name,
+			Version:     1,
+			Nullifiers:  []string{},
+			Commitments: []string{},
+			ProofData:   seedHex(name+" proof", proofLen),
+			Fee:         fee,
+			Anchor:      seedHash(name + " anchor"),
+		}
+		for i := 0; i < inputs; i++ {
+			v.Nullifiers = append(v.Nullifiers, seedHash(fmt.Sprintf("%s nullifier %d", name, i)))
+		}
+		for i := 0; i < outputs; i++ {
+			v.Commitments = append(v.Commitments, seedHash(fmt.Sprintf("%s commitment %d", name, i)))
+		}
+		return v
+	}
+
+	empty := tx("empty", 0, 0, 0, 0)
+	empty.Anchor = zeroHash
+
+	return []*TxHashVector{
+		empty,
+		tx("one in one out", 1, 1, 192, 1000),
+		tx("two in two out", 2, 2, 192, 25000),
+		tx("consolidation", 4, 1, 192, 1<<64-1),
+	}
+}
+
+func nullifierInputs() []*NullifierVector {
+	note := func(name string, value, position uint64) *NullifierVector {
+		return &NullifierVector{
+			Name:        name,
+			SpendingKey: seedHex(name+" spending key", 32),
+			Value:       value,
+			Address:     seedAddress(name + " address"),
+			Blinder:     seedHex(name+" blinder", 32),
+			Position:    position,
+		}
+	}
+
+	return []*NullifierVector{
+		note("first leaf", 100000000, 0),
+		note("zero value", 0, 1),
+		note("max value", 1<<64-1, 7),
+		note("deep position", 2500000000, 1<<32-1),
+		note("max position", 1, 1<<64-1),
+	}
+}
+
+func merkleRootInputs() []*MerkleRootVector {
+	var vectors []*MerkleRootVector
+	for _, n := range []int{0, 1, 2, 3, 5, 8} {
+		v := &MerkleRootVector{
+			Name:   fmt.Sprintf("%d leaves", n),
+			Leaves: []string{},
+		}
+		for i := 0; i < n; i++ {
+			v.Leaves = append(v.Leaves, seedHash(fmt.Sprintf("leaf %d", i)))
+		}
+		vectors = append(vectors, v)
+	}
+	return vectors
+}
+
+func rewardInputs() []*RewardVector {
+	hi := economics.HalvingInterval
+	return []*RewardVector{
+		{Name: "genesis", Height: 0, Reputation: 1.0},
+		{Name: "minimum reputation", Height: 1, Reputation: 0.1},
+		{Name: "maximum reputation", Height: 1, Reputation: 3.0},
+		{Name: "before first halving", Height: hi - 1, Reputation: 1.0},
+		{Name: "first halving", Height: hi, Reputation: 1.0},
+		{Name: "second halving", Height: 2 * hi, Reputation: 1.7},
+		{Name: "last halving", Height: 31 * hi, Reputation: 1.0},
+		{Name: "tail emission", Height: 32 * hi, Reputation: 1.0},
+		{Name: "tail emission high reputation", Height: 40 * hi, Reputation: 3.0},
+	}
+}
+
+func difficultyInputs() []*DifficultyVector {
+	const (
+		target = 10
+		window = 10
+		start  = 1700000000
+	)
+	// chain returns blocks from..to spaced interval seconds apart
+	chain := func(from, to, interval uint64) []ChainBlock {
+		var blocks []ChainBlock
+		for h := from; h <= to; h++ {
+			blocks = append(blocks, ChainBlock{Height: h, Timestamp: start + h*interval})
+		}
+		return blocks
+	}
+	parent := func(height uint64, difficulty, score string) []DifficultyParent {
+		return []DifficultyParent{{Height: height, Difficulty: difficulty, Score: score}}
+	}
+	vector := func(name string, parents []DifficultyParent, mainChain []ChainBlock) *DifficultyVector {
+		if mainChain == nil {
+			mainChain = []ChainBlock{}
+		}
+		return &DifficultyVector{
+			Name:            name,
+			TargetBlockTime: target,
+			Window:          window,
+			Parents:         parents,
+			MainChain:       mainChain,
+		}
+	}
+
+	return []*DifficultyVector{
+		vector("genesis", []DifficultyParent{}, nil),
+		vector("inside window", parent(5, pow2(200), "6"), chain(0, 5, 3)),
+		vector("on target", parent(19, pow2(200), "20"), chain(9, 19, target)),
+		vector("slow blocks", parent(19, pow2(200), "20"), chain(9, 19, 15)),
+		vector("fast blocks", parent(19, pow2(200), "20"), chain(9, 19, 7)),
+		vector("first window", parent(9, pow2(200), "10"), chain(0, 9, 12)),
+		vector("clamped slow", parent(29, pow2(200), "30"), chain(19, 29, 100)),
+		vector("clamped fast", parent(29, pow2(200), "30"), chain(19, 29, 1)),
+		vector("minimum target", parent(29, pow2(101), "30"), chain(19, 29, 1)),
+		vector("maximum target", parent(29, pow2(255), "30"), chain(19, 29, 40)),
+		vector("missing chain", parent(29, pow2(200), "30"), nil),
+		vector("multiple parents", []DifficultyParent{
+			{Height: 28, Difficulty: pow2(190), Score: "28.5"},
+			{Height: 29, Difficulty: pow2(200), Score: "31.25"},
+			{Height: 29, Difficulty: pow2(210), Score: "30"},
+		}, chain(19, 29, 20)),
+	}
+}
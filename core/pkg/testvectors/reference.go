@@ -0,0 +1,229 @@
+package testvectors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ccoin/core/internal/consensus"
+	"github.com/ccoin/core/internal/dag"
+	"github.com/ccoin/core/internal/economics"
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/common"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// errNotInVector is returned for chain data a difficulty vector does not
+// hold
+var errNotInVector = errors.New("not in test vector")
+
+// reference is this repository's implementation
+type reference struct{}
+
+// Reference returns this repository's implementation, which the
+// published vectors are generated from
+func Reference() Implementation {
+	return reference{}
+}
+
+func (reference) BlockHash(v *BlockHashVector) (string, error) {
+	var p parser
+	h := &types.BlockHeader{
+		Version:        v.Version,
+		TxRoot:         p.hash(v.TxRoot),
+		StateRoot:      p.hash(v.StateRoot),
+		PoUWResult:     p.hash(v.PoUWResult),
+		TaskID:         p.hash(v.TaskID),
+		MinerAddress:   p.address(v.MinerAddress),
+		PayoutAddress:  p.address(v.PayoutAddress),
+		Difficulty:     p.bigInt(v.Difficulty),
+		Nonce:          v.Nonce,
+		Timestamp:      v.Timestamp,
+		CommitteeRoot:  p.hash(v.CommitteeRoot),
+		MinerPublicKey: p.bytes(v.MinerPublicKey),
+		VRFSeed:        p.hash(v.VRFSeed),
+		VRFProof:       p.bytes(v.VRFProof),
+		NullifierRoot:  p.hash(v.NullifierRoot),
+		RegistryRoot:   p.hash(v.RegistryRoot),
+		ReceiptsRoot:   p.hash(v.ReceiptsRoot),
+	}
+	for _, parent := range v.Parents {
+		h.Parents = append(h.Parents, p.hash(parent))
+	}
+	if p.err != nil {
+		return "", p.err
+	}
+	hash := h.ComputeHash()
+	return common.BytesToHex(hash[:]), nil
+}
+
+func (reference) TxHash(v *TxHashVector) (string, error) {
+	var p parser
+	tx := &types.Transaction{
+		Version: v.Version,
+		Proof:   types.ZKProof{ProofData: p.bytes(v.ProofData)},
+		Fee:     v.Fee,
+		Anchor:  p.hash(v.Anchor),
+	}
+	for _, n := range v.Nullifiers {
+		tx.Nullifiers = append(tx.Nullifiers, p.hash(n))
+	}
+	for _, c := range v.Commitments {
+		tx.Commitments = append(tx.Commitments, types.Commitment{Value: p.hash(c)})
+	}
+	if p.err != nil {
+		return "", p.err
+	}
+	hash := tx.ComputeHash()
+	return common.BytesToHex(hash[:]), nil
+}
+
+func (reference) Nullifier(v *NullifierVector) (string, string, error) {
+	var p parser
+	key := p.bytes(v.SpendingKey)
+	address := p.address(v.Address)
+	blinder := p.bytes(v.Blinder)
+	if p.err != nil {
+		return "", "", p.err
+	}
+	commitment := zkp.NoteCommitment(v.Value, address, blinder)
+	nullifier := zkp.DeriveNullifier(key, commitment, v.Position)
+	return common.BytesToHex(commitment[:]), common.BytesToHex(nullifier[:]), nil
+}
+
+func (reference) MerkleRoot(v *MerkleRootVector) (string, error) {
+	var p parser
+	txs := make([]*types.Transaction, len(v.Leaves))
+	for i, leaf := range v.Leaves {
+		txs[i] = &types.Transaction{TxHash: p.hash(leaf)}
+	}
+	if p.err != nil {
+		return "", p.err
+	}
+	root := dag.ComputeTxRoot(txs)
+	return common.BytesToHex(root[:]), nil
+}
+
+func (reference) Rewards(v *RewardVector) (*Rewards, error) {
+	r := &Rewards{
+		BlockReward: economics.CalculateBlockReward(v.Height),
+		MinerReward: economics.CalculateMinerReward(v.Height, v.Reputation),
+	}
+	r.Miner, r.Staker, r.Treasury, r.Proposer, r.Burn = economics.DefaultRewardDistribution().CalculateDistribution(r.MinerReward)
+	return r, nil
+}
+
+func (reference) Difficulty(v *DifficultyVector) (string, error) {
+	var p parser
+	parents := make([]*types.BlockHeader, len(v.Parents))
+	for i, parent := range v.Parents {
+		parents[i] = &types.BlockHeader{
+			Height:          parent.Height,
+			Difficulty:      p.bigInt(parent.Difficulty),
+			CumulativeScore: p.bigFloat(parent.Score),
+		}
+	}
+	if p.err != nil {
+		return "", p.err
+	}
+
+	engine := consensus.NewConsensus(dag.NewDAG(&chainStore{blocks: v.MainChain}, nil), nil, &consensus.Config{
+		TargetBlockTime:  v.TargetBlockTime,
+		DifficultyWindow: v.Window,
+		EpochLength:      types.EpochLength,
+	})
+	return engine.CalculateDifficulty(context.Background(), parents).String(), nil
+}
+
+// chainStore serves a difficulty vector's main chain
+type chainStore struct {
+	blocks []ChainBlock
+}
+
+func (s *chainStore) GetMainChain(ctx context.Context, fromHeight, toHeight uint64) ([]*types.BlockHeader, error) {
+	var headers []*types.BlockHeader
+	for _, b := range s.blocks {
+		if b.Height >= fromHeight && b.Height <= toHeight {
+			headers = append(headers, &types.BlockHeader{Height: b.Height, Timestamp: b.Timestamp})
+		}
+	}
+	return headers, nil
+}
+
+func (s *chainStore) GetBlock(ctx context.Context, hash types.Hash) (*types.Block, error) {
+	return nil, errNotInVector
+}
+
+func (s *chainStore) GetBlockHeader(ctx context.Context, hash types.Hash) (*types.BlockHeader, error) {
+	return nil, errNotInVector
+}
+
+func (s *chainStore) SaveBlock(ctx context.Context, block *types.Block) error {
+	return errNotInVector
+}
+
+func (s *chainStore) GetBlocksByHeight(ctx context.Context, height uint64) ([]*types.BlockHeader, error) {
+	return nil, errNotInVector
+}
+
+func (s *chainStore) GetChildren(ctx context.Context, hash types.Hash) ([]types.Hash, error) {
+	return nil, errNotInVector
+}
+
+func (s *chainStore) UpdateMainChain(ctx context.Context, onChain, offChain []types.Hash) error {
+	return errNotInVector
+}
+
+func (s *chainStore) GetTips(ctx context.Context) ([]types.Hash, error) {
+	return nil, nil
+}
+
+// parser decodes vector fields, keeping the first error
+type parser struct {
+	err error
+}
+
+func (p *parser) bytes(s string) []byte {
+	b, err := common.HexToBytes(s)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("invalid hex %q: %w", s, err)
+	}
+	return b
+}
+
+func (p *parser) hash(s string) types.Hash {
+	var h types.Hash
+	if b := p.bytes(s); len(b) == types.HashSize {
+		copy(h[:], b)
+	} else if p.err == nil {
+		p.err = fmt.Errorf("%q is not a %d-byte hash", s, types.HashSize)
+	}
+	return h
+}
+
+func (p *parser) address(s string) types.Address {
+	var a types.Address
+	if b := p.bytes(s); len(b) == types.AddressSize {
+		copy(a[:], b)
+	} else if p.err == nil {
+		p.err = fmt.Errorf("%q is not a %d-byte address", s, types.AddressSize)
+	}
+	return a
+}
+
+func (p *parser) bigInt(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok && p.err == nil {
+		p.err = fmt.Errorf("invalid integer %q", s)
+	}
+	return n
+}
+
+func (p *parser) bigFloat(s string) *big.Float {
+	f, ok := new(big.Float).SetString(s)
+	if !ok && p.err == nil {
+		p.err = fmt.Errorf("invalid number %q", s)
+	}
+	return f
+}
@@ -0,0 +1,189 @@
+// Package testvectors publishes canonical test vectors for the consensus
+// rules every CCoin implementation must reproduce exactly: block and
+// transaction hashing, note commitments and nullifiers, transaction
+// Merkle roots, the reward schedule and difficulty retargeting.
+//
+// The vectors are a JSON document, vectors.json in this directory and
+// returned by Canonical, so implementations and light wallets in any
+// language can check themselves against it. Go implementations can run
+// Verify directly. Hashes, addresses and byte strings are 0x-prefixed
+// hex, and integers too large for 64 bits are decimal strings.
+//
+// Vectors are regenerated from this repository's implementation with go
+// generate. A change to them is a consensus change.
+package testvectors
+
+//go:generate go run gen.go
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Version is the format version of the vectors document
+const Version = 1
+
+// canonical is the published vectors document
+//
+//go:embed vectors.json
+var canonical []byte
+
+// Vectors is a set of test vectors
+type Vectors struct {
+	Version     int                 `json:"version"`
+	BlockHashes []*BlockHashVector  `json:"block_hashes"`
+	TxHashes    []*TxHashVector     `json:"tx_hashes"`
+	Nullifiers  []*NullifierVector  `json:"nullifiers"`
+	MerkleRoots []*MerkleRootVector `json:"merkle_roots"`
+	Rewards     []*RewardVector     `json:"rewards"`
+	Difficulty  []*DifficultyVector `json:"difficulty"`
+}
+
+// BlockHashVector is a block header and its hash. Fields not hashed are
+// left out. The committee, nullifier, registry and receipts roots and the
+// VRF fields are hashed only when set, after a bitmap of those that are,
+// so vectors cover both.
+type BlockHashVector struct {
+	Name           string   `json:"name"`
+	Version        uint32   `json:"version"`
+	Parents        []string `json:"parents"`
+	TxRoot         string   `json:"tx_root"`
+	StateRoot      string   `json:"state_root"`
+	PoUWResult     string   `json:"pouw_result"`
+	TaskID         string   `json:"task_id"`
+	MinerAddress   string   `json:"miner_address"`
+	PayoutAddress  string   `json:"payout_address"`
+	Difficulty     string   `json:"difficulty"`
+	Nonce          uint64   `json:"nonce"`
+	Timestamp      uint64   `json:"timestamp"`
+	CommitteeRoot  string   `json:"committee_root"`
+	MinerPublicKey string   `json:"miner_public_key"`
+	VRFSeed        string   `json:"vrf_seed"`
+	VRFProof       string   `json:"vrf_proof"`
+	NullifierRoot  string   `json:"nullifier_root"`
+	RegistryRoot   string   `json:"registry_root"`
+	ReceiptsRoot   string   `json:"receipts_root"`
+
+	Hash string `json:"hash"`
+}
+
+// TxHashVector is a shielded transaction and its hash. Transactions
+// carrying inference, payout, delegation or sealed operations are not yet
+// covered.
+type TxHashVector struct {
+	Name        string   `json:"name"`
+	Version     uint32   `json:"version"`
+	Nullifiers  []string `json:"nullifiers"`
+	Commitments []string `json:"commitments"`
+	ProofData   string   `json:"proof_data"`
+	Fee         uint64   `json:"fee"`
+	Anchor      string   `json:"anchor"`
+
+	Hash string `json:"hash"`
+}
+
+// NullifierVector is a note, the commitment to it and the nullifier
+// spending it from a position in the commitment tree. Both are MiMC
+// hashes over BN254 scalars, as inside the transaction circuit.
+type NullifierVector struct {
+	Name        string `json:"name"`
+	SpendingKey string `json:"spending_key"`
+	Value       uint64 `json:"value"`
+	Address     string `json:"address"`
+	Blinder     string `json:"blinder"`
+	Position    uint64 `json:"position"`
+
+	Commitment string `json:"commitment"`
+	Nullifier  string `json:"nullifier"`
+}
+
+// MerkleRootVector is a list of transaction hashes and the transaction
+// root of a block holding them in that order
+type MerkleRootVector struct {
+	Name   string   `json:"name"`
+	Leaves []string `json:"leaves"`
+
+	Root string `json:"root"`
+}
+
+// RewardVector is the emission at a height for a miner of a reputation,
+// and its split under the default reward distribution
+type RewardVector struct {
+	Name       string  `json:"name"`
+	Height     uint64  `json:"height"`
+	Reputation float64 `json:"reputation"`
+
+	Rewards
+}
+
+// Rewards are the amounts, in base units, a block issues
+type Rewards struct {
+	// BlockReward is the base reward on the halving schedule and
+	// MinerReward that scaled by the miner's reputation, which is split
+	// among the others
+	BlockReward uint64 `json:"block_reward"`
+	MinerReward uint64 `json:"miner_reward"`
+
+	Miner    uint64 `json:"miner"`
+	Staker   uint64 `json:"staker"`
+	Treasury uint64 `json:"treasury"`
+	Proposer uint64 `json:"proposer"`
+	Burn     uint64 `json:"burn"`
+}
+
+// DifficultyVector is the difficulty target of a block on parents. The
+// highest-scoring parent is the reference; at each window boundary its
+// target is retargeted by the main chain's average block time from Window
+// blocks before it.
+type DifficultyVector struct {
+	Name            string             `json:"name"`
+	TargetBlockTime uint64             `json:"target_block_time"`
+	Window          uint64             `json:"window"`
+	Parents         []DifficultyParent `json:"parents"`
+	MainChain       []ChainBlock       `json:"main_chain"`
+
+	Difficulty string `json:"difficulty"`
+}
+
+// DifficultyParent is a parent header's height, target and cumulative
+// score, decimal
+type DifficultyParent struct {
+	Height     uint64 `json:"height"`
+	Difficulty string `json:"difficulty"`
+	Score      string `json:"score"`
+}
+
+// ChainBlock is a main chain block's height and timestamp
+type ChainBlock struct {
+	Height    uint64 `json:"height"`
+	Timestamp uint64 `json:"timestamp"`
+}
+
+// Canonical returns the published vectors
+func Canonical() (*Vectors, error) {
+	return Load(bytes.NewReader(canonical))
+}
+
+// Load reads a vectors document
+func Load(r io.Reader) (*Vectors, error) {
+	var v Vectors
+	if err := json.NewDecoder(r).Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid test vectors: %w", err)
+	}
+	if v.Version != Version {
+		return nil, fmt.Errorf("unsupported test vectors version %d", v.Version)
+	}
+	return &v, nil
+}
+
+// Marshal encodes vectors as an indented JSON document
+func (v *Vectors) Marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,89 @@
+package testvectors
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Implementation computes what the vectors check, from each vector's
+// inputs. Results are compared with the vector's, hex case-insensitively.
+type Implementation interface {
+	// BlockHash returns the hash of the vector's header
+	BlockHash(v *BlockHashVector) (string, error)
+
+	// TxHash returns the hash of the vector's transaction
+	TxHash(v *TxHashVector) (string, error)
+
+	// Nullifier returns the vector's note commitment and nullifier
+	Nullifier(v *NullifierVector) (commitment, nullifier string, err error)
+
+	// MerkleRoot returns the transaction root of the vector's leaves
+	MerkleRoot(v *MerkleRootVector) (string, error)
+
+	// Rewards returns the amounts issued at the vector's height
+	Rewards(v *RewardVector) (*Rewards, error)
+
+	// Difficulty returns the target of a block on the vector's parents
+	Difficulty(v *DifficultyVector) (string, error)
+}
+
+// Failure is a vector an implementation does not reproduce
+type Failure struct {
+	Kind string
+	Name string
+	Got  string
+	Want string
+	Err  error
+}
+
+func (f *Failure) Error() string {
+	if f.Err != nil {
+		return fmt.Sprintf("%s %q: %v", f.Kind, f.Name, f.Err)
+	}
+	return fmt.Sprintf("%s %q: got %s, want %s", f.Kind, f.Name, f.Got, f.Want)
+}
+
+// Verify runs every vector against impl and returns those it fails
+func Verify(v *Vectors, impl Implementation) []*Failure {
+	var failures []*Failure
+	check := func(kind, name, got, want string, err error) {
+		if err != nil {
+			failures = append(failures, &Failure{Kind: kind, Name: name, Err: err})
+		} else if !strings.EqualFold(got, want) {
+			failures = append(failures, &Failure{Kind: kind, Name: name, Got: got, Want: want})
+		}
+	}
+
+	for _, vec := range v.BlockHashes {
+		got, err := impl.BlockHash(vec)
+		check("block hash", vec.Name, got, vec.Hash, err)
+	}
+	for _, vec := range v.TxHashes {
+		got, err := impl.TxHash(vec)
+		check("tx hash", vec.Name, got, vec.Hash, err)
+	}
+	for _, vec := range v.Nullifiers {
+		commitment, nullifier, err := impl.Nullifier(vec)
+		check("note commitment", vec.Name, commitment, vec.Commitment, err)
+		if err == nil {
+			check("nullifier", vec.Name, nullifier, vec.Nullifier, nil)
+		}
+	}
+	for _, vec := range v.MerkleRoots {
+		got, err := impl.MerkleRoot(vec)
+		check("merkle root", vec.Name, got, vec.Root, err)
+	}
+	for _, vec := range v.Rewards {
+		got, err := impl.Rewards(vec)
+		if err != nil {
+			check("rewards", vec.Name, "", "", err)
+			continue
+		}
+		check("rewards", vec.Name, fmt.Sprintf("%+v", *got), fmt.Sprintf("%+v", vec.Rewards), nil)
+	}
+	for _, vec := range v.Difficulty {
+		got, err := impl.Difficulty(vec)
+		check("difficulty", vec.Name, got, vec.Difficulty, err)
+	}
+	return failures
+}
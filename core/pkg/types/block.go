@@ -3,6 +3,7 @@
 package types
 
 import (
+	"crypto/ed25519"
 	"crypto/sha256"
 	"encoding/binary"
 	"math/big"
@@ -43,6 +44,10 @@ const (
 
 	// CoinbaseMaturity is the number of confirmations before coinbase can be spent
 	CoinbaseMaturity = 100
+
+	// VRFSeedWindow is how far below a header the block its VRF seed
+	// names may be, so miners have time to finish their task
+	VRFSeedWindow = 360
 )
 
 // Hash represents a 32-byte hash (SHA3-256)
@@ -84,6 +89,14 @@ func HashFromBytes(b []byte) Hash {
 	return h
 }
 
+// AddressFromPublicKey derives an address: the first 20 bytes of SHA-256(pubkey)
+func AddressFromPublicKey(pub []byte) Address {
+	hash := sha256.Sum256(pub)
+	var addr Address
+	copy(addr[:], hash[:AddressSize])
+	return addr
+}
+
 // BlockHeader contains the metadata for a block in the DAG
 type BlockHeader struct {
 	// Hash is the SHA3-256 hash of this header (computed, not serialized)
@@ -116,6 +129,10 @@ type BlockHeader struct {
 	// MinerAddress is the address of the miner who created this block
 	MinerAddress Address
 
+	// PayoutAddress receives the block reward and must match the miner's
+	// registered payout address at this height
+	PayoutAddress Address
+
 	// ReputationScore is the miner's reputation at the time of mining
 	ReputationScore float64
 
@@ -136,6 +153,39 @@ type BlockHeader struct {
 
 	// ExtraData is arbitrary data (max 32 bytes)
 	ExtraData []byte
+
+	// CommitteeRoot commits to the committees selected for the epoch this
+	// block opens. It is zero except in the first blocks of an epoch.
+	CommitteeRoot Hash
+
+	// NullifierRoot is the root of the nullifiers spent along this block's
+	// selected-parent chain, its own spends included, so a light client
+	// can check a double-spend fraud proof against headers alone. It is
+	// zero in the genesis block.
+	NullifierRoot Hash
+
+	// RegistryRoot commits to the model registry as checkpointed for the
+	// epoch this block opens, the root of a sparse Merkle tree of
+	// ModelCheckpoint hashes keyed by model ID. It is zero except in the
+	// first blocks of an epoch, and there when the registry is empty.
+	RegistryRoot Hash
+
+	// ReceiptsRoot is the Merkle root of the receipts of this block's
+	// transactions, in block order. It is zero in blocks without
+	// transactions.
+	ReceiptsRoot Hash
+
+	// MinerPublicKey is the ed25519 key MinerAddress is derived from
+	MinerPublicKey []byte
+
+	// VRFSeed is the hash of a recent block and VRFProof the miner's ECVRF
+	// proof over it, whose output drew the block's task
+	VRFSeed  Hash
+	VRFProof []byte
+
+	// Signature is the Ed25519 signature over Hash by MinerPublicKey,
+	// proving the miner produced the block. It is not part of the hash.
+	Signature []byte
 }
 
 // Block represents a complete block including header and transactions
@@ -158,6 +208,37 @@ func (h *BlockHeader) ComputeHash() Hash {
 	return sha256.Sum256(data)
 }
 
+// Bits of the optional header fields hashed in, set for each field a
+// header carries
+const (
+	hasCommitteeRoot byte = 1 << iota
+	hasVRF
+	hasNullifierRoot
+	hasRegistryRoot
+	hasReceiptsRoot
+)
+
+// optionalFields returns the bitmap of the optional fields h carries
+func (h *BlockHeader) optionalFields() byte {
+	var present byte
+	if !h.CommitteeRoot.IsEmpty() {
+		present |= hasCommitteeRoot
+	}
+	if len(h.VRFProof) > 0 {
+		present |= hasVRF
+	}
+	if !h.NullifierRoot.IsEmpty() {
+		present |= hasNullifierRoot
+	}
+	if !h.RegistryRoot.IsEmpty() {
+		present |= hasRegistryRoot
+	}
+	if !h.ReceiptsRoot.IsEmpty() {
+		present |= hasReceiptsRoot
+	}
+	return present
+}
+
 // serializeForHash serializes header fields for hashing
 func (h *BlockHeader) serializeForHash() []byte {
 	buf := make([]byte, 0, 1024)
@@ -167,7 +248,8 @@ func (h *BlockHeader) serializeForHash() []byte {
 	binary.BigEndian.PutUint32(versionBytes, h.Version)
 	buf = append(buf, versionBytes...)
 
-	// Parents
+	// Parents, counted
+	buf = append(buf, byte(len(h.Parents)))
 	for _, parent := range h.Parents {
 		buf = append(buf, parent[:]...)
 	}
@@ -187,10 +269,16 @@ func (h *BlockHeader) serializeForHash() []byte {
 	// MinerAddress
 	buf = append(buf, h.MinerAddress[:]...)
 
-	// Difficulty
+	// PayoutAddress
+	buf = append(buf, h.PayoutAddress[:]...)
+
+	// Difficulty, length prefixed
+	var diffBytes []byte
 	if h.Difficulty != nil {
-		buf = append(buf, h.Difficulty.Bytes()...)
+		diffBytes = h.Difficulty.Bytes()
 	}
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(diffBytes)))
+	buf = append(buf, diffBytes...)
 
 	// Nonce
 	nonceBytes := make([]byte, 8)
@@ -202,9 +290,69 @@ func (h *BlockHeader) serializeForHash() []byte {
 	binary.BigEndian.PutUint64(tsBytes, h.Timestamp)
 	buf = append(buf, tsBytes...)
 
+	// Optional fields follow a bitmap of those that are set, so that
+	// one field can never be read as another
+	present := h.optionalFields()
+	buf = append(buf, present)
+
+	// CommitteeRoot
+	if present&hasCommitteeRoot != 0 {
+		buf = append(buf, h.CommitteeRoot[:]...)
+	}
+
+	// VRF fields, with the key and proof length prefixed
+	if present&hasVRF != 0 {
+		buf = append(buf, byte(len(h.MinerPublicKey)))
+		buf = append(buf, h.MinerPublicKey...)
+		buf = append(buf, h.VRFSeed[:]...)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(h.VRFProof)))
+		buf = append(buf, h.VRFProof...)
+	}
+
+	// NullifierRoot
+	if present&hasNullifierRoot != 0 {
+		buf = append(buf, h.NullifierRoot[:]...)
+	}
+
+	// RegistryRoot
+	if present&hasRegistryRoot != 0 {
+		buf = append(buf, h.RegistryRoot[:]...)
+	}
+
+	// ReceiptsRoot
+	if present&hasReceiptsRoot != 0 {
+		buf = append(buf, h.ReceiptsRoot[:]...)
+	}
+
 	return buf
 }
 
+// Sign signs the header hash with the miner's key. The hash and
+// MinerPublicKey must already be final.
+func (h *BlockHeader) Sign(key ed25519.PrivateKey) {
+	h.Signature = ed25519.Sign(key, h.Hash[:])
+}
+
+// Signer returns the address of the key that signed the header hash, and
+// false if the header carries no valid signature. The signer need not be
+// MinerAddress.
+func (h *BlockHeader) Signer() (Address, bool) {
+	if len(h.MinerPublicKey) != ed25519.PublicKeySize || len(h.Signature) != ed25519.SignatureSize {
+		return Address{}, false
+	}
+	if !ed25519.Verify(h.MinerPublicKey, h.Hash[:], h.Signature) {
+		return Address{}, false
+	}
+	return AddressFromPublicKey(h.MinerPublicKey), true
+}
+
+// VerifySignature checks that the header is signed by the key
+// MinerAddress derives from
+func (h *BlockHeader) VerifySignature() bool {
+	signer, ok := h.Signer()
+	return ok && signer == h.MinerAddress
+}
+
 // Work calculates the amount of work represented by this block
 func (h *BlockHeader) Work() *big.Int {
 	if h.Difficulty == nil || h.Difficulty.Sign() == 0 {
@@ -0,0 +1,74 @@
+package types
+
+import (
+	"github.com/ccoin/core/pkg/common"
+)
+
+// canonicalProposal is the signed content of a proposal. Tallies, status
+// and execution height change after submission and are excluded.
+type canonicalProposal struct {
+	Type              ProposalType `json:"type"`
+	Proposer          Address      `json:"proposer"`
+	Title             string       `json:"title"`
+	Description       string       `json:"description"`
+	Data              ProposalData `json:"data"`
+	QuorumRequired    float64      `json:"quorum_required"`
+	ApprovalThreshold float64      `json:"approval_threshold"`
+	VotingStartBlock  uint64       `json:"voting_start_block"`
+	VotingEndBlock    uint64       `json:"voting_end_block"`
+}
+
+// canonicalVote is the signed content of a vote
+type canonicalVote struct {
+	ProposalID Hash    `json:"proposal_id"`
+	Voter      Address `json:"voter"`
+	VotePower  uint64  `json:"vote_power"`
+	InFavor    bool    `json:"in_favor"`
+	VotedAt    uint64  `json:"voted_at"`
+}
+
+// canonicalAttestation is the signed content of an accuracy attestation
+type canonicalAttestation struct {
+	JobID     Hash    `json:"job_id"`
+	Evaluator Address `json:"evaluator"`
+	Accuracy  float64 `json:"accuracy"`
+	Loss      float64 `json:"loss"`
+}
+
+// CanonicalJSON returns the proposal's canonical JSON encoding, which is
+// identical on every node and suitable for signing and hashing
+func (p *Proposal) CanonicalJSON() ([]byte, error) {
+	return common.CanonicalJSON(&canonicalProposal{
+		Type:              p.Type,
+		Proposer:          p.ProposerAddress,
+		Title:             p.Title,
+		Description:       p.Description,
+		Data:              p.Data,
+		QuorumRequired:    p.QuorumRequired,
+		ApprovalThreshold: p.ApprovalThreshold,
+		VotingStartBlock:  p.VotingStartBlock,
+		VotingEndBlock:    p.VotingEndBlock,
+	})
+}
+
+// CanonicalJSON returns the vote's canonical JSON encoding
+func (v *Vote) CanonicalJSON() ([]byte, error) {
+	return common.CanonicalJSON(&canonicalVote{
+		ProposalID: v.ProposalID,
+		Voter:      v.VoterAddress,
+		VotePower:  v.VotePower,
+		InFavor:    v.InFavor,
+		VotedAt:    v.VotedAt,
+	})
+}
+
+// CanonicalJSON returns the attestation's canonical JSON encoding,
+// excluding the signature
+func (a *AccuracyAttestation) CanonicalJSON() ([]byte, error) {
+	return common.CanonicalJSON(&canonicalAttestation{
+		JobID:     a.JobID,
+		Evaluator: a.Evaluator,
+		Accuracy:  a.Accuracy,
+		Loss:      a.Loss,
+	})
+}
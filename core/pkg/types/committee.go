@@ -0,0 +1,125 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+// CommitteeKind identifies a committee drawn each epoch
+type CommitteeKind uint8
+
+const (
+	// CommitteePreConfirmation attests to transactions before inclusion
+	CommitteePreConfirmation CommitteeKind = iota
+
+	// CommitteeDecryption holds shares of the sealed-transaction key
+	CommitteeDecryption
+
+	// CommitteeEvaluator is the active AI Commons evaluator set
+	CommitteeEvaluator
+)
+
+// String returns the committee name
+func (k CommitteeKind) String() string {
+	switch k {
+	case CommitteePreConfirmation:
+		return "pre-confirmation"
+	case CommitteeDecryption:
+		return "decryption"
+	case CommitteeEvaluator:
+		return "evaluator"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseCommitteeKind returns the committee with the given name
+func ParseCommitteeKind(name string) (CommitteeKind, bool) {
+	for k := CommitteePreConfirmation; k <= CommitteeEvaluator; k++ {
+		if k.String() == name {
+			return k, true
+		}
+	}
+	return 0, false
+}
+
+// CommitteeCandidate is one entry of the stake and reputation snapshot a
+// committee is drawn from
+type CommitteeCandidate struct {
+	Address    Address
+	Stake      uint64
+	Reputation float64
+}
+
+// CommitteeRecord holds the inputs and outcome of one committee
+// selection, so anyone can recompute it
+type CommitteeRecord struct {
+	Kind  CommitteeKind
+	Epoch uint64
+
+	// Beacon is the randomness the selection was drawn with
+	Beacon Hash
+
+	// Size is the number of seats
+	Size uint32
+
+	// Candidates is the snapshot, ordered by address
+	Candidates []CommitteeCandidate
+
+	// Members are the selected candidates in draw order
+	Members []Address
+}
+
+// Hash returns the hash committed to on-chain
+func (r *CommitteeRecord) Hash() Hash {
+	buf := []byte("CCOIN_COMMITTEE")
+	buf = append(buf, byte(r.Kind))
+	buf = binary.BigEndian.AppendUint64(buf, r.Epoch)
+	buf = append(buf, r.Beacon[:]...)
+	buf = binary.BigEndian.AppendUint32(buf, r.Size)
+
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(r.Candidates)))
+	for _, c := range r.Candidates {
+		buf = append(buf, c.Address[:]...)
+		buf = binary.BigEndian.AppendUint64(buf, c.Stake)
+		buf = binary.BigEndian.AppendUint64(buf, ReputationFixed(c.Reputation))
+	}
+
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(r.Members)))
+	for _, m := range r.Members {
+		buf = append(buf, m[:]...)
+	}
+	return sha256.Sum256(buf)
+}
+
+// ReputationFixed converts a reputation in [0, 1] to the fixed point
+// form committee records hash and weigh. Rounding makes the conversion
+// exact for values read back from the fixed point form.
+func ReputationFixed(reputation float64) uint64 {
+	if !(reputation > 0) {
+		return 0
+	}
+	if reputation > 1 {
+		reputation = 1
+	}
+	return uint64(math.Round(reputation * 1e9))
+}
+
+// CommitteeRoot commits to the records of one epoch, ordered by kind. It
+// is zero when no committee was selected.
+func CommitteeRoot(records []*CommitteeRecord) Hash {
+	if len(records) == 0 {
+		return Hash{}
+	}
+	sorted := append([]*CommitteeRecord(nil), records...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Kind < sorted[j].Kind })
+
+	buf := []byte("CCOIN_COMMITTEE_ROOT")
+	for _, r := range sorted {
+		h := r.Hash()
+		buf = append(buf, h[:]...)
+	}
+	return sha256.Sum256(buf)
+}
@@ -0,0 +1,78 @@
+package types
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// UnbondingPeriod is the number of blocks undelegated stake stays bonded
+// before it is released. It remains slashable for the miner's offenses
+// until then, so a delegator cannot escape a slashing by leaving first.
+const UnbondingPeriod = 60480 // ~1 week at 10s blocks
+
+// DelegationAction is what a delegation does with the delegator's stake
+type DelegationAction uint8
+
+const (
+	// DelegationBond bonds stake to the miner
+	DelegationBond DelegationAction = iota
+
+	// DelegationUnbond starts unbonding stake from the miner
+	DelegationUnbond
+)
+
+// StakeDelegation is a transaction operation that bonds a holder's stake
+// to a miner, or starts unbonding it. It is signed by the delegator's key,
+// so holders below the minimum stake can back a miner without running one.
+type StakeDelegation struct {
+	// DelegatorKey is the Ed25519 key the delegator address derives from
+	DelegatorKey []byte
+
+	// Miner is the miner the stake backs
+	Miner Address
+
+	// Action and Amount of stake bonded or unbonded
+	Action DelegationAction
+	Amount uint64
+
+	// Nonce must exceed the nonce of the delegator's previous delegation
+	// to the same miner
+	Nonce uint64
+
+	// Signature is the delegator's Ed25519 signature over SigningHash
+	Signature []byte
+}
+
+// Delegator returns the address of the delegator
+func (d *StakeDelegation) Delegator() Address {
+	return AddressFromPublicKey(d.DelegatorKey)
+}
+
+// SigningHash returns the digest the delegator signs
+func (d *StakeDelegation) SigningHash() Hash {
+	buf := make([]byte, 0, 10+len(d.DelegatorKey)+AddressSize+17)
+	buf = append(buf, "delegation"...)
+	buf = append(buf, d.DelegatorKey...)
+	buf = append(buf, d.Miner[:]...)
+	buf = append(buf, byte(d.Action))
+	buf = binary.BigEndian.AppendUint64(buf, d.Amount)
+	buf = binary.BigEndian.AppendUint64(buf, d.Nonce)
+	return sha256.Sum256(buf)
+}
+
+// Verify checks the signature against the delegator key
+func (d *StakeDelegation) Verify() bool {
+	if len(d.DelegatorKey) != ed25519.PublicKeySize {
+		return false
+	}
+	digest := d.SigningHash()
+	return ed25519.Verify(d.DelegatorKey, digest[:], d.Signature)
+}
+
+// Serialize returns the delegation's canonical encoding for hashing
+func (d *StakeDelegation) Serialize() []byte {
+	h := d.SigningHash()
+	buf := append([]byte{}, h[:]...)
+	return append(buf, d.Signature...)
+}
@@ -11,7 +11,8 @@ const (
 	// ProposalTaskPriority proposes changing task queue priorities
 	ProposalTaskPriority ProposalType = 1
 
-	// ProposalParameterAdjust proposes adjusting model training parameters
+	// ProposalParameterAdjust proposes adjusting model training,
+	// economic or disclosure policy parameters
 	ProposalParameterAdjust ProposalType = 2
 
 	// ProposalLicenseChange proposes changing a model's license
@@ -22,6 +23,21 @@ const (
 
 	// ProposalProtocolUpgrade proposes a protocol upgrade
 	ProposalProtocolUpgrade ProposalType = 5
+
+	// ProposalEvaluatorAdmission admits or removes a model evaluator
+	ProposalEvaluatorAdmission ProposalType = 6
+
+	// ProposalBondForfeiture forfeits an abusive model registration's bond
+	ProposalBondForfeiture ProposalType = 7
+
+	// ProposalSanctionsUpdate adds or removes sanctioned addresses
+	ProposalSanctionsUpdate ProposalType = 8
+
+	// ProposalIdentityAuthority registers or removes a credential authority
+	ProposalIdentityAuthority ProposalType = 9
+
+	// ProposalCredentialRevocation revokes or reinstates credentials
+	ProposalCredentialRevocation ProposalType = 10
 )
 
 // ProposalStatus represents the status of a proposal
@@ -44,6 +60,24 @@ const (
 	ProposalStatusCancelled ProposalStatus = 4
 )
 
+// String returns the status name
+func (s ProposalStatus) String() string {
+	switch s {
+	case ProposalStatusActive:
+		return "active"
+	case ProposalStatusPassed:
+		return "passed"
+	case ProposalStatusRejected:
+		return "rejected"
+	case ProposalStatusExecuted:
+		return "executed"
+	case ProposalStatusCancelled:
+		return "cancelled"
+	default:
+		return "unknown"
+	}
+}
+
 // ProposalThresholds defines voting requirements for each proposal type
 var ProposalThresholds = map[ProposalType]struct {
 	Quorum            float64 // Percentage of tokens that must vote
@@ -56,6 +90,13 @@ var ProposalThresholds = map[ProposalType]struct {
 	ProposalLicenseChange:   {Quorum: 0.15, ApprovalThreshold: 0.66, VotingPeriod: 100800}, // ~14 days
 	ProposalTreasurySpend:   {Quorum: 0.10, ApprovalThreshold: 0.50, VotingPeriod: 50400},  // ~7 days
 	ProposalProtocolUpgrade: {Quorum: 0.25, ApprovalThreshold: 0.75, VotingPeriod: 201600}, // ~28 days
+
+	ProposalEvaluatorAdmission: {Quorum: 0.10, ApprovalThreshold: 0.66, VotingPeriod: 50400}, // ~7 days
+	ProposalBondForfeiture:     {Quorum: 0.05, ApprovalThreshold: 0.50, VotingPeriod: 21600}, // ~3 days
+	ProposalSanctionsUpdate:    {Quorum: 0.10, ApprovalThreshold: 0.66, VotingPeriod: 21600}, // ~3 days
+
+	ProposalIdentityAuthority:    {Quorum: 0.10, ApprovalThreshold: 0.66, VotingPeriod: 50400}, // ~7 days
+	ProposalCredentialRevocation: {Quorum: 0.05, ApprovalThreshold: 0.50, VotingPeriod: 7200},  // ~1 day
 }
 
 // Proposal represents a governance proposal in the Research DAO
@@ -134,6 +175,129 @@ type TreasurySpendData struct {
 func (d *TreasurySpendData) ProposalType() ProposalType { return ProposalTreasurySpend }
 func (d *TreasurySpendData) Validate() error           { return nil }
 
+// EvaluatorAdmissionData admits a bonded evaluator, or removes one when
+// Remove is set
+type EvaluatorAdmissionData struct {
+	Evaluator Address
+	PublicKey []byte // Ed25519 key the evaluator signs attestations with
+	Remove    bool
+}
+
+func (d *EvaluatorAdmissionData) ProposalType() ProposalType { return ProposalEvaluatorAdmission }
+func (d *EvaluatorAdmissionData) Validate() error            { return nil }
+
+// BondForfeitureData forfeits the registration bond of an abusive model
+// registration and deprecates the model
+type BondForfeitureData struct {
+	ModelID Hash
+	Reason  string
+}
+
+func (d *BondForfeitureData) ProposalType() ProposalType { return ProposalBondForfeiture }
+func (d *BondForfeitureData) Validate() error            { return nil }
+
+// SanctionsUpdateData changes the sanctions list. Root is the sanctions
+// tree root after the change, so voters approve an exact list.
+type SanctionsUpdateData struct {
+	Add    []Address
+	Remove []Address
+	Root   Hash
+	Reason string
+}
+
+func (d *SanctionsUpdateData) ProposalType() ProposalType { return ProposalSanctionsUpdate }
+func (d *SanctionsUpdateData) Validate() error            { return nil }
+
+// EconomicParameterData changes the fee and reward splits and scales
+// emission. SimulationHash commits to the projection of the first
+// SimulationEpochs epochs from SimulationStart, assuming FeesPerBlock in
+// fees, so every voter can reproduce what the proposer was shown.
+type EconomicParameterData struct {
+	FeeMinerShare    float64
+	FeeBurnShare     float64
+	FeeTreasuryShare float64
+
+	RewardMinerShare    float64
+	RewardStakerShare   float64
+	RewardTreasuryShare float64
+	RewardProposerShare float64
+	RewardBurnShare     float64
+
+	EmissionScale float64 // Multiplier on the scheduled block reward
+
+	SimulationStart  uint64
+	SimulationEpochs uint32
+	FeesPerBlock     uint64
+	SimulationHash   Hash
+}
+
+func (d *EconomicParameterData) ProposalType() ProposalType { return ProposalParameterAdjust }
+func (d *EconomicParameterData) Validate() error            { return nil }
+
+// DisclosurePolicyData replaces the disclosure policy of one network.
+// Rules are listed whole so voters approve the exact policy.
+type DisclosurePolicyData struct {
+	Network string
+	Rules   []DisclosureRuleData
+}
+
+// DisclosureRuleData requires the Require disclosure flags from
+// transactions that may move at least MinValue, pay one of Recipients and
+// carry an identity from one of Jurisdictions. Empty lists match any.
+type DisclosureRuleData struct {
+	Name          string
+	MinValue      uint64
+	Recipients    []uint8
+	Jurisdictions []string
+	Require       uint32
+}
+
+func (d *DisclosurePolicyData) ProposalType() ProposalType { return ProposalParameterAdjust }
+func (d *DisclosurePolicyData) Validate() error            { return nil }
+
+// ConsensusParameterData changes registered consensus parameters, such as
+// the reputation EWMA factor, from ActivationHeight on. Every node switches
+// at the same block, so the change cannot be applied retroactively.
+type ConsensusParameterData struct {
+	Changes          []ConsensusParamChange
+	ActivationHeight uint64
+}
+
+// ConsensusParamChange sets one parameter by name
+type ConsensusParamChange struct {
+	Name  string
+	Value float64
+}
+
+func (d *ConsensusParameterData) ProposalType() ProposalType { return ProposalParameterAdjust }
+func (d *ConsensusParameterData) Validate() error            { return nil }
+
+// IdentityAuthorityData registers a credential authority by its EdDSA
+// public key, or removes it. Domain is the jurisdiction disclosure
+// policy rules match the authority's credentials on.
+type IdentityAuthorityData struct {
+	PublicKey Hash
+	Name      string
+	Domain    string
+	Remove    bool
+}
+
+func (d *IdentityAuthorityData) ProposalType() ProposalType { return ProposalIdentityAuthority }
+func (d *IdentityAuthorityData) Validate() error            { return nil }
+
+// CredentialRevocationData revokes or reinstates credentials by serial.
+// Root is the revocation tree root after the change, so voters approve
+// an exact list.
+type CredentialRevocationData struct {
+	Revoke    []Address
+	Reinstate []Address
+	Root      Hash
+	Reason    string
+}
+
+func (d *CredentialRevocationData) ProposalType() ProposalType { return ProposalCredentialRevocation }
+func (d *CredentialRevocationData) Validate() error            { return nil }
+
 // Vote represents a single vote on a proposal
 type Vote struct {
 	// ProposalID is the proposal being voted on
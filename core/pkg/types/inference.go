@@ -0,0 +1,155 @@
+// Package types defines on-chain inference request structures.
+package types
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// InferenceOpType identifies the inference operation a transaction carries
+type InferenceOpType uint8
+
+const (
+	// InferenceOpRequest escrows a fee and commits to an inference input
+	InferenceOpRequest InferenceOpType = 1
+
+	// InferenceOpResult posts a serving node's result commitment
+	InferenceOpResult InferenceOpType = 2
+
+	// InferenceOpDispute challenges a posted result
+	InferenceOpDispute InferenceOpType = 3
+)
+
+// InferenceOp is an optional on-chain inference operation attached to a
+// transaction. Exactly one of Request, Result or Dispute is set, matching Type.
+type InferenceOp struct {
+	Type    InferenceOpType
+	Request *InferenceRequest
+	Result  *InferenceResult
+	Dispute *InferenceDispute
+}
+
+// InferenceRequest asks a serving node to run a model on a committed input.
+// Escrow is taken from the transaction's inputs alongside the fee and is
+// paid to the node once the result stands, or refunded on timeout.
+type InferenceRequest struct {
+	// RequestID is the unique identifier for this request
+	RequestID Hash
+
+	// ModelID is the model to run
+	ModelID Hash
+
+	// InputHash commits to the input, which is delivered off-chain
+	InputHash Hash
+
+	// Refund is the address escrow is returned to
+	Refund Address
+
+	// RequesterKey is the Ed25519 key authorized to dispute the result
+	RequesterKey []byte
+
+	// Escrow is the amount held for the serving node
+	Escrow uint64
+
+	// Timeout is the number of blocks to wait for a result
+	Timeout uint64
+
+	// Nonce distinguishes repeated requests for the same input
+	Nonce uint64
+}
+
+// ComputeID derives the request ID from the request's fields
+func (r *InferenceRequest) ComputeID() Hash {
+	data := make([]byte, 0, HashSize*2+AddressSize+len(r.RequesterKey)+24)
+	data = append(data, r.ModelID[:]...)
+	data = append(data, r.InputHash[:]...)
+	data = append(data, r.Refund[:]...)
+	data = append(data, r.RequesterKey...)
+	data = binary.BigEndian.AppendUint64(data, r.Escrow)
+	data = binary.BigEndian.AppendUint64(data, r.Timeout)
+	data = binary.BigEndian.AppendUint64(data, r.Nonce)
+	return sha256.Sum256(data)
+}
+
+// InferenceResult is a serving node's commitment to its output. The
+// signature binds the result to the license the node serves under, which
+// serves as its proof-of-license.
+type InferenceResult struct {
+	// RequestID is the request being answered
+	RequestID Hash
+
+	// Node is the serving node's address
+	Node Address
+
+	// ResultHash commits to the output, which is delivered off-chain
+	ResultHash Hash
+
+	// LicenseID is the node's license for the requested model
+	LicenseID Hash
+
+	// Signature is the node's Ed25519 signature over SigningHash
+	Signature []byte
+}
+
+// SigningHash returns the digest a serving node signs
+func (r *InferenceResult) SigningHash() Hash {
+	buf := make([]byte, 0, HashSize*3+AddressSize)
+	buf = append(buf, r.RequestID[:]...)
+	buf = append(buf, r.Node[:]...)
+	buf = append(buf, r.ResultHash[:]...)
+	buf = append(buf, r.LicenseID[:]...)
+	return sha256.Sum256(buf)
+}
+
+// Verify checks the result signature against the node's key
+func (r *InferenceResult) Verify(publicKey ed25519.PublicKey) bool {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return false
+	}
+	digest := r.SigningHash()
+	return ed25519.Verify(publicKey, digest[:], r.Signature)
+}
+
+// InferenceDispute challenges a posted result, sending the request to the
+// evaluator committee for re-execution
+type InferenceDispute struct {
+	// RequestID is the request whose result is disputed
+	RequestID Hash
+
+	// Signature is the requester's Ed25519 signature over SigningHash
+	Signature []byte
+}
+
+// SigningHash returns the digest a requester signs to dispute a result
+func (d *InferenceDispute) SigningHash() Hash {
+	buf := append([]byte("dispute"), d.RequestID[:]...)
+	return sha256.Sum256(buf)
+}
+
+// Verify checks the dispute signature against the requester's key
+func (d *InferenceDispute) Verify(publicKey ed25519.PublicKey) bool {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return false
+	}
+	digest := d.SigningHash()
+	return ed25519.Verify(publicKey, digest[:], d.Signature)
+}
+
+// Serialize returns the operation's canonical encoding for hashing
+func (op *InferenceOp) Serialize() []byte {
+	buf := []byte{byte(op.Type)}
+	switch {
+	case op.Request != nil:
+		id := op.Request.ComputeID()
+		buf = append(buf, id[:]...)
+	case op.Result != nil:
+		h := op.Result.SigningHash()
+		buf = append(buf, h[:]...)
+		buf = append(buf, op.Result.Signature...)
+	case op.Dispute != nil:
+		buf = append(buf, op.Dispute.RequestID[:]...)
+		buf = append(buf, op.Dispute.Signature...)
+	}
+	return buf
+}
@@ -57,6 +57,18 @@ type Miner struct {
 
 	// BanExpiresAt is the block height when the ban expires
 	BanExpiresAt uint64
+
+	// PayoutAddress receives block rewards (zero means Address)
+	PayoutAddress Address
+
+	// PendingPayout replaces PayoutAddress at PayoutActivation
+	PendingPayout Address
+
+	// PayoutActivation is the height PendingPayout takes effect (0 if none)
+	PayoutActivation uint64
+
+	// PayoutNonce is the nonce of the last accepted payout change
+	PayoutNonce uint64
 }
 
 // NewMiner creates a new miner with initial reputation
@@ -142,6 +154,27 @@ func (m *Miner) CheckBan(currentBlock uint64) bool {
 	return m.IsBanned
 }
 
+// PayoutAt returns the address rewards for a block at height are paid to
+func (m *Miner) PayoutAt(height uint64) Address {
+	if m.PayoutActivation != 0 && height >= m.PayoutActivation {
+		return m.PendingPayout
+	}
+	if m.PayoutAddress == (Address{}) {
+		return m.Address
+	}
+	return m.PayoutAddress
+}
+
+// SchedulePayout sets payout to take effect PayoutChangeDelay blocks after
+// height, replacing any change not yet in effect
+func (m *Miner) SchedulePayout(payout Address, height uint64) {
+	if m.PayoutActivation != 0 && height >= m.PayoutActivation {
+		m.PayoutAddress = m.PendingPayout
+	}
+	m.PendingPayout = payout
+	m.PayoutActivation = height + PayoutChangeDelay
+}
+
 // PenaltySeverity represents the severity of a reputation penalty
 type PenaltySeverity uint8
 
@@ -214,3 +247,16 @@ func (n *InferenceNode) SelectionWeight() float64 {
 	// Weight = stake * uptime * accuracy
 	return float64(n.StakedAmount) * n.Uptime * n.Accuracy()
 }
+
+// ComputeCapabilities describes the hardware a miner offers for
+// proof-of-useful-work tasks
+type ComputeCapabilities struct {
+	CPUs     int
+	MemoryMB uint64
+
+	// GPUs are device names, one per device
+	GPUs []string
+
+	// GPUMemoryMB is the smallest device's memory (0 without GPUs)
+	GPUMemoryMB uint64
+}
@@ -1,6 +1,13 @@
 // Package types defines AI model structures for the CCoin AI Commons.
 package types
 
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+)
+
 // ModelStatus represents the lifecycle status of a model
 type ModelStatus uint8
 
@@ -55,6 +62,34 @@ const (
 	LicenseCommercial LicenseType = 2
 )
 
+// String returns the license name
+func (l LicenseType) String() string {
+	switch l {
+	case LicenseOpen:
+		return "open"
+	case LicenseRestricted:
+		return "restricted"
+	case LicenseCommercial:
+		return "commercial"
+	default:
+		return "unknown"
+	}
+}
+
+// Terms returns a one-line summary of the license terms
+func (l LicenseType) Terms() string {
+	switch l {
+	case LicenseOpen:
+		return "Unrestricted use"
+	case LicenseRestricted:
+		return "Use requires attribution to the model's contributors"
+	case LicenseCommercial:
+		return "Commercial use requires a paid license"
+	default:
+		return ""
+	}
+}
+
 // ModelEntry represents an AI model in the AI Commons registry
 type ModelEntry struct {
 	// ModelID is the unique identifier for this model
@@ -158,6 +193,13 @@ type Task struct {
 
 	// CompletedAt is the block height when this task was completed
 	CompletedAt uint64
+
+	// MinerPublicKey is the assigned miner's key and VRFProof its proof
+	// over VRFSeed, the block hash the task was drawn with. The proof's
+	// output seeds the task's verification subset.
+	MinerPublicKey []byte
+	VRFSeed        Hash
+	VRFProof       []byte
 }
 
 // TaskStatus represents the status of a task
@@ -197,6 +239,9 @@ type GradientResult struct {
 	// QualityScore is (OldLoss - NewLoss) / OldLoss
 	QualityScore float64
 
+	// Proof is the gradient circuit proof of the losses and quality score
+	Proof []byte
+
 	// VerificationSubset contains the subset of computation for verification
 	VerificationSubset []byte
 
@@ -204,6 +249,149 @@ type GradientResult struct {
 	VRFSeed Hash
 }
 
+// EvaluationJob asks a committee of evaluators to benchmark model weights
+type EvaluationJob struct {
+	// JobID is the unique identifier for this job
+	JobID Hash
+
+	// ModelID is the model being evaluated
+	ModelID Hash
+
+	// WeightsCID is the IPFS CID of the weights to benchmark
+	WeightsCID string
+
+	// BenchmarkID identifies the validation set to run
+	BenchmarkID Hash
+
+	// Epoch is the epoch whose evaluator rotation the committee is drawn from
+	Epoch uint64
+
+	// Evaluators is the committee assigned to the job
+	Evaluators []Address
+
+	// Deadline is the block height by which attestations must be submitted
+	Deadline uint64
+}
+
+// ComputeID derives the job ID from the job's inputs
+func (j *EvaluationJob) ComputeID() Hash {
+	data := append([]byte{}, j.ModelID[:]...)
+	data = append(data, []byte(j.WeightsCID)...)
+	data = append(data, j.BenchmarkID[:]...)
+	data = binary.BigEndian.AppendUint64(data, j.Epoch)
+	data = binary.BigEndian.AppendUint64(data, j.Deadline)
+	return sha256.Sum256(data)
+}
+
+// AccuracyAttestation is an evaluator's signed benchmark result
+type AccuracyAttestation struct {
+	// JobID is the evaluation job this attests to
+	JobID Hash
+
+	// Evaluator is the address of the attesting evaluator
+	Evaluator Address
+
+	// Accuracy is the measured accuracy (0.0 - 1.0)
+	Accuracy float64
+
+	// Loss is the measured validation loss
+	Loss float64
+
+	// Signature is the evaluator's Ed25519 signature over SigningHash
+	Signature []byte
+}
+
+// SigningHash returns the digest an evaluator signs
+func (a *AccuracyAttestation) SigningHash() Hash {
+	buf := make([]byte, 0, HashSize+AddressSize+16)
+	buf = append(buf, a.JobID[:]...)
+	buf = append(buf, a.Evaluator[:]...)
+	buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(a.Accuracy))
+	buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(a.Loss))
+	return sha256.Sum256(buf)
+}
+
+// Verify checks the attestation signature against the evaluator's key
+func (a *AccuracyAttestation) Verify(publicKey ed25519.PublicKey) bool {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return false
+	}
+	digest := a.SigningHash()
+	return ed25519.Verify(publicKey, digest[:], a.Signature)
+}
+
+// ModelVersion is one published set of weights for a model together with
+// the evaluation that attested its accuracy. Each version links to the
+// weights it replaced, forming a chain back to the first version.
+type ModelVersion struct {
+	// Version numbers the model's weights from 1
+	Version uint32
+
+	// WeightsCID is the IPFS CID of the weights
+	WeightsCID string
+
+	// ParentCID is the CID of the previous version's weights, empty for
+	// the first version
+	ParentCID string
+
+	// Accuracy is the attested accuracy (0.0 - 1.0)
+	Accuracy float64
+
+	// Job is the evaluation job that benchmarked these weights
+	Job *EvaluationJob
+
+	// Attestations are the committee's signed results for Job
+	Attestations []*AccuracyAttestation
+
+	// CreatedAt is the block height when the version was published
+	CreatedAt uint64
+}
+
+// ModelRegistration is a bonded request, signed by the proposer, to add a
+// model to the registry
+type ModelRegistration struct {
+	// Model is the entry to register; ProposerAddress must match PublicKey
+	Model *ModelEntry
+
+	// Bond is the refundable deposit locked with the registration
+	Bond uint64
+
+	// PublicKey is the proposer's Ed25519 key
+	PublicKey []byte
+
+	// Signature is the proposer's signature over SigningHash
+	Signature []byte
+}
+
+// SigningHash returns the digest a proposer signs
+func (r *ModelRegistration) SigningHash() Hash {
+	m := r.Model
+	buf := make([]byte, 0, 128)
+	buf = append(buf, []byte(m.Architecture)...)
+	buf = append(buf, 0, byte(m.TaskType))
+	buf = append(buf, []byte(m.Domain)...)
+	buf = append(buf, 0)
+	buf = append(buf, []byte(m.CurrentWeights)...)
+	buf = append(buf, 0, byte(m.License))
+	buf = append(buf, m.ProposerAddress[:]...)
+	buf = append(buf, m.GovernanceID[:]...)
+	buf = binary.BigEndian.AppendUint64(buf, m.CreatedAt)
+	buf = binary.BigEndian.AppendUint64(buf, r.Bond)
+	return sha256.Sum256(buf)
+}
+
+// Verify checks that the registration is signed by the model's proposer
+func (r *ModelRegistration) Verify() bool {
+	if r.Model == nil || len(r.PublicKey) != ed25519.PublicKeySize {
+		return false
+	}
+	if AddressFromPublicKey(r.PublicKey) != r.Model.ProposerAddress {
+		return false
+	}
+	digest := r.SigningHash()
+	return ed25519.Verify(r.PublicKey, digest[:], r.Signature)
+}
+
 // NewModelEntry creates a new model entry
 func NewModelEntry() *ModelEntry {
 	return &ModelEntry{
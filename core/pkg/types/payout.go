@@ -0,0 +1,60 @@
+package types
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// PayoutChangeDelay is the number of blocks between a payout change being
+// included and taking effect, giving the miner time to notice and
+// override a change made with a stolen hot key
+const PayoutChangeDelay = 720
+
+// PayoutChange is a transaction operation that moves a miner's block
+// rewards to a different address. It is signed by the miner's identity key,
+// so rewards can go to a cold address the node never holds.
+type PayoutChange struct {
+	// MinerKey is the Ed25519 identity key the miner address derives from
+	MinerKey []byte
+
+	// Payout is the new reward address
+	Payout Address
+
+	// Nonce must exceed the nonce of the miner's previous change
+	Nonce uint64
+
+	// Signature is the miner's Ed25519 signature over SigningHash
+	Signature []byte
+}
+
+// Miner returns the address of the miner making the change
+func (c *PayoutChange) Miner() Address {
+	return AddressFromPublicKey(c.MinerKey)
+}
+
+// SigningHash returns the digest the miner signs
+func (c *PayoutChange) SigningHash() Hash {
+	buf := make([]byte, 0, 6+len(c.MinerKey)+AddressSize+8)
+	buf = append(buf, "payout"...)
+	buf = append(buf, c.MinerKey...)
+	buf = append(buf, c.Payout[:]...)
+	buf = binary.BigEndian.AppendUint64(buf, c.Nonce)
+	return sha256.Sum256(buf)
+}
+
+// Verify checks the signature against the miner key
+func (c *PayoutChange) Verify() bool {
+	if len(c.MinerKey) != ed25519.PublicKeySize {
+		return false
+	}
+	digest := c.SigningHash()
+	return ed25519.Verify(c.MinerKey, digest[:], c.Signature)
+}
+
+// Serialize returns the change's canonical encoding for hashing
+func (c *PayoutChange) Serialize() []byte {
+	h := c.SigningHash()
+	buf := append([]byte{}, h[:]...)
+	return append(buf, c.Signature...)
+}
@@ -0,0 +1,69 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// ReceiptStatus is the outcome of applying a transaction
+type ReceiptStatus uint8
+
+const (
+	// ReceiptAccepted means the transaction's spends and outputs took
+	// effect
+	ReceiptAccepted ReceiptStatus = 0
+
+	// ReceiptConflict means the transaction spends a nullifier an earlier
+	// transaction in the block spent. Its outputs are still appended to
+	// the note commitment tree but are not backed by a valid spend, and
+	// wallets must not treat them as received.
+	ReceiptConflict ReceiptStatus = 1
+)
+
+// String returns the status name
+func (s ReceiptStatus) String() string {
+	switch s {
+	case ReceiptAccepted:
+		return "accepted"
+	case ReceiptConflict:
+		return "conflict"
+	default:
+		return "unknown"
+	}
+}
+
+// Receipt records the effects of applying a transaction in a block, so
+// clients need not re-derive them. Receipts are computed identically by
+// every node along the block's selected-parent chain; block headers
+// commit to them as ReceiptsRoot.
+type Receipt struct {
+	TxHash  Hash
+	Status  ReceiptStatus
+	GasUsed uint64
+
+	// VerifiedDisclosures lists the type of each disclosure the
+	// transaction carries, in order. Blocks are only valid if every
+	// disclosure verifies.
+	VerifiedDisclosures []DisclosureType
+
+	// CommitmentPositions are the leaf positions of the transaction's
+	// output commitments in the note commitment tree, in output order
+	CommitmentPositions []uint64
+}
+
+// Hash returns the receipt's leaf in the receipts tree
+func (r *Receipt) Hash() Hash {
+	buf := []byte("CCOIN_RECEIPT")
+	buf = append(buf, r.TxHash[:]...)
+	buf = append(buf, byte(r.Status))
+	buf = binary.BigEndian.AppendUint64(buf, r.GasUsed)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(r.VerifiedDisclosures)))
+	for _, d := range r.VerifiedDisclosures {
+		buf = append(buf, byte(d))
+	}
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(r.CommitmentPositions)))
+	for _, pos := range r.CommitmentPositions {
+		buf = binary.BigEndian.AppendUint64(buf, pos)
+	}
+	return sha256.Sum256(buf)
+}
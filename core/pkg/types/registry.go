@@ -0,0 +1,115 @@
+package types
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+// ModelCheckpoint is a model's registry state as committed at an epoch
+// boundary. Checkpoints are the leaves of the model registry tree, keyed
+// by model ID, whose root blocks opening an epoch carry as RegistryRoot.
+type ModelCheckpoint struct {
+	ModelID        Hash
+	Status         ModelStatus
+	License        LicenseType
+	CurrentWeights string
+	Accuracy       float64
+	TotalCompute   uint64
+
+	// Versions is the number of published weight versions and
+	// VersionsHash commits to them in order
+	Versions     uint32
+	VersionsHash Hash
+
+	// Contributors holds each contributor's contributions, ordered by
+	// address
+	Contributors []ModelContribution
+}
+
+// ModelContribution is a contributor's total contributions to a model
+type ModelContribution struct {
+	Address       Address
+	Contributions uint64
+}
+
+// NewModelCheckpoint captures a model and its published versions
+func NewModelCheckpoint(m *ModelEntry, versions []*ModelVersion) *ModelCheckpoint {
+	c := &ModelCheckpoint{
+		ModelID:        m.ModelID,
+		Status:         m.Status,
+		License:        m.License,
+		CurrentWeights: m.CurrentWeights,
+		Accuracy:       m.Accuracy,
+		TotalCompute:   m.TotalCompute,
+		Versions:       uint32(len(versions)),
+		VersionsHash:   ModelVersionsHash(versions),
+	}
+	for addr, n := range m.Contributors {
+		c.Contributors = append(c.Contributors, ModelContribution{Address: addr, Contributions: n})
+	}
+	sort.Slice(c.Contributors, func(i, j int) bool {
+		return bytes.Compare(c.Contributors[i].Address[:], c.Contributors[j].Address[:]) < 0
+	})
+	return c
+}
+
+// Hash returns the checkpoint's leaf value in the registry tree
+func (c *ModelCheckpoint) Hash() Hash {
+	buf := []byte("CCOIN_MODEL_CHECKPOINT")
+	buf = append(buf, c.ModelID[:]...)
+	buf = append(buf, byte(c.Status), byte(c.License))
+	buf = appendString(buf, c.CurrentWeights)
+	buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(c.Accuracy))
+	buf = binary.BigEndian.AppendUint64(buf, c.TotalCompute)
+	buf = binary.BigEndian.AppendUint32(buf, c.Versions)
+	buf = append(buf, c.VersionsHash[:]...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(c.Contributors)))
+	for _, contrib := range c.Contributors {
+		buf = append(buf, contrib.Address[:]...)
+		buf = binary.BigEndian.AppendUint64(buf, contrib.Contributions)
+	}
+	return sha256.Sum256(buf)
+}
+
+// Share returns a contributor's share of the model's contributions, as
+// ModelEntry.ContributorShare computes it
+func (c *ModelCheckpoint) Share(addr Address) float64 {
+	var total, own uint64
+	for _, contrib := range c.Contributors {
+		total += contrib.Contributions
+		if contrib.Address == addr {
+			own = contrib.Contributions
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(own) / float64(total)
+}
+
+// ModelVersionsHash commits to a model's versions in order: their
+// numbers, weights, parents, accuracies and heights. It is zero when
+// there are none.
+func ModelVersionsHash(versions []*ModelVersion) Hash {
+	if len(versions) == 0 {
+		return Hash{}
+	}
+	buf := []byte("CCOIN_MODEL_VERSIONS")
+	for _, v := range versions {
+		buf = binary.BigEndian.AppendUint32(buf, v.Version)
+		buf = appendString(buf, v.WeightsCID)
+		buf = appendString(buf, v.ParentCID)
+		buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(v.Accuracy))
+		buf = binary.BigEndian.AppendUint64(buf, v.CreatedAt)
+	}
+	return sha256.Sum256(buf)
+}
+
+// appendString appends a length-prefixed string
+func appendString(buf []byte, s string) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(s)))
+	return append(buf, s...)
+}
@@ -0,0 +1,58 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// SealedPayload holds a transaction's operations threshold-encrypted to
+// the decryption committee. The operations stay hidden while the
+// transaction is pending and are revealed once a block includes it, so
+// block producers cannot front-run or selectively censor them.
+type SealedPayload struct {
+	// Epoch identifies the committee key the payload is encrypted to
+	Epoch uint64
+
+	// Ephemeral is the sender's ephemeral public point
+	Ephemeral []byte
+
+	// Proof shows the sender knows the encryption randomness
+	Proof []byte
+
+	// Ciphertext is the encrypted operations
+	Ciphertext []byte
+}
+
+// Serialize returns the payload's canonical encoding for hashing
+func (p *SealedPayload) Serialize() []byte {
+	buf := make([]byte, 0, 8+len(p.Ephemeral)+len(p.Proof)+len(p.Ciphertext))
+	buf = binary.BigEndian.AppendUint64(buf, p.Epoch)
+	buf = append(buf, p.Ephemeral...)
+	buf = append(buf, p.Proof...)
+	buf = append(buf, p.Ciphertext...)
+	return buf
+}
+
+// SealLabel returns the data a sealed payload is bound to. It covers the
+// transaction's spends, outputs and fee, so a payload copied into another
+// transaction fails to decrypt.
+func (tx *Transaction) SealLabel() []byte {
+	buf := make([]byte, 0, 6+4+len(tx.Nullifiers)*HashSize+len(tx.Commitments)*HashSize+8+HashSize)
+	buf = append(buf, "sealed"...)
+	buf = binary.BigEndian.AppendUint32(buf, tx.Version)
+	for _, nullifier := range tx.Nullifiers {
+		buf = append(buf, nullifier[:]...)
+	}
+	for _, commitment := range tx.Commitments {
+		buf = append(buf, commitment.Value[:]...)
+	}
+	buf = binary.BigEndian.AppendUint64(buf, tx.Fee)
+	buf = append(buf, tx.Anchor[:]...)
+	label := sha256.Sum256(buf)
+	return label[:]
+}
+
+// IsSealed returns true if the transaction carries a sealed payload
+func (tx *Transaction) IsSealed() bool {
+	return tx.Sealed != nil
+}
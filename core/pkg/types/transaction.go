@@ -41,6 +41,20 @@ type Transaction struct {
 
 	// Anchor is the Merkle root of the commitment tree at the time of creation
 	Anchor Hash
+
+	// Inference is an optional on-chain inference operation
+	Inference *InferenceOp
+
+	// PayoutChange optionally changes a miner's reward address
+	PayoutChange *PayoutChange
+
+	// Delegation optionally bonds or unbonds stake delegated to a miner
+	Delegation *StakeDelegation
+
+	// Sealed optionally carries the operations above encrypted to the
+	// decryption committee. When set, the hash commits to the sealed
+	// payload and the operations are filled in once it is opened.
+	Sealed *SealedPayload
 }
 
 // Commitment represents a Pedersen commitment to a transaction output
@@ -52,6 +66,12 @@ type Commitment struct {
 	EncryptedNote []byte
 }
 
+// Proof systems for ZKProof.ProofType
+const (
+	ProofSystemGroth16 uint8 = 0
+	ProofSystemPLONK   uint8 = 1
+)
+
 // ZKProof represents a zk-SNARK proof (Groth16 or PLONK)
 type ZKProof struct {
 	// ProofType indicates the proof system used (0 = Groth16, 1 = PLONK)
@@ -99,7 +119,8 @@ type Disclosure struct {
 	// For range: [min, max]
 	// For identity: authority public key
 	// For sanctions: sanctions list Merkle root
-	// For temporal: minimum duration
+	// For temporal: creation and anchor block hashes, minimum duration
+	// For aggregate: outflow bounds, height window and nullifier count
 	PublicData []byte
 }
 
@@ -109,9 +130,13 @@ type RangeDisclosureData struct {
 	Max uint64
 }
 
-// TemporalDisclosureData contains public data for a temporal disclosure
+// TemporalDisclosureData contains public data for a temporal disclosure.
+// The note's commitment was in the tree after CreationBlock, whose header
+// timestamp is at least MinDuration before AnchorBlock's.
 type TemporalDisclosureData struct {
-	MinDuration uint64 // Minimum seconds the funds must have been held
+	CreationBlock Hash
+	AnchorBlock   Hash
+	MinDuration   uint64 // Minimum seconds the funds must have been held
 }
 
 // NewTransaction creates a new transaction
@@ -160,6 +185,27 @@ func (tx *Transaction) serializeForHash() []byte {
 	// Anchor
 	buf = append(buf, tx.Anchor[:]...)
 
+	// Sealed operations, or the operations in the clear
+	if tx.Sealed != nil {
+		buf = append(buf, tx.Sealed.Serialize()...)
+		return buf
+	}
+
+	// Inference operation
+	if tx.Inference != nil {
+		buf = append(buf, tx.Inference.Serialize()...)
+	}
+
+	// Payout change
+	if tx.PayoutChange != nil {
+		buf = append(buf, tx.PayoutChange.Serialize()...)
+	}
+
+	// Stake delegation
+	if tx.Delegation != nil {
+		buf = append(buf, tx.Delegation.Serialize()...)
+	}
+
 	return buf
 }
 
@@ -203,6 +249,18 @@ func (tx *Transaction) TxSize() int {
 	size += 8 // Fee
 	size += len(tx.Memo)
 	size += HashSize // Anchor
+	if tx.Sealed != nil {
+		return size + len(tx.Sealed.Serialize())
+	}
+	if tx.Inference != nil {
+		size += len(tx.Inference.Serialize())
+	}
+	if tx.PayoutChange != nil {
+		size += len(tx.PayoutChange.Serialize())
+	}
+	if tx.Delegation != nil {
+		size += len(tx.Delegation.Serialize())
+	}
 	return size
 }
 
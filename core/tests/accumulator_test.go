@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ccoin/core/internal/dag"
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// spendTx returns a transaction spending nullifiers
+func spendTx(nullifiers ...types.Hash) *types.Transaction {
+	tx := &types.Transaction{Version: 1, Nullifiers: nullifiers}
+	tx.TxHash = tx.ComputeHash()
+	return tx
+}
+
+// nullifierRoot commits a chainBlock to the accumulator root its spends
+// reach
+func nullifierRoot(acc *zkp.ChainAccumulator) func(*types.Block) error {
+	return func(b *types.Block) error {
+		root, err := acc.NextRoot(context.Background(), b.Header.Parents[0], dag.BlockNullifiers(b.Transactions))
+		b.Header.NullifierRoot = root
+		return err
+	}
+}
+
+// Test transaction inclusion paths against the transaction root
+func TestTxPath(t *testing.T) {
+	var txs []*types.Transaction
+	for i := byte(1); i <= 5; i++ {
+		txs = append(txs, spendTx(types.Hash{i}))
+	}
+	root := dag.ComputeTxRoot(txs)
+
+	for i, tx := range txs {
+		path := dag.TxPath(txs, i)
+		if !dag.VerifyTxPath(root, tx.TxHash, i, path) {
+			t.Errorf("Path for transaction %d failed", i)
+		}
+		if dag.VerifyTxPath(root, tx.TxHash, (i+1)%len(txs), path) {
+			t.Errorf("Path for transaction %d verified at the wrong index", i)
+		}
+	}
+	if dag.VerifyTxPath(root, types.Hash{9}, 0, dag.TxPath(txs, 0)) {
+		t.Error("Path verified for a transaction not in the block")
+	}
+}
+
+// Test that block roots extend the selected parent's accumulator
+func TestNullifierAccumulator(t *testing.T) {
+	ctx := context.Background()
+	d := dag.NewDAG(newMemDAGStore(), nil)
+	acc := zkp.NewNullifierAccumulator(d, 0)
+
+	genesis := testBlock(0xff, 0)
+	if err := d.AddBlock(ctx, genesis); err != nil {
+		t.Fatalf("Failed to add genesis: %v", err)
+	}
+	root, err := acc.Root(ctx, genesis.Header.Hash)
+	if err != nil || root != acc.EmptyRoot() {
+		t.Fatalf("Genesis root = %x, %v", root, err)
+	}
+
+	b1 := chainBlock(t, 1, genesis.Header.Hash, types.Address{}, nullifierRoot(acc), spendTx(types.Hash{1}, types.Hash{2}))
+	if err := d.AddBlock(ctx, b1); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	b2 := chainBlock(t, 2, b1.Header.Hash, types.Address{}, nullifierRoot(acc), spendTx(types.Hash{3}))
+	if err := d.AddBlock(ctx, b2); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+
+	// The root is that of a commitment tree over the chain's nullifiers
+	tree := zkp.NewCommitmentTree(zkp.NewInMemoryTreeStore(), zkp.TreeDepth)
+	for i := byte(1); i <= 3; i++ {
+		tree.AddCommitment(ctx, types.Hash{i})
+	}
+	if b2.Header.NullifierRoot != tree.GetRoot() {
+		t.Error("Accumulator root differs from the commitment tree root")
+	}
+
+	// A fresh accumulator rebuilds the same states from stored blocks
+	rebuilt := zkp.NewNullifierAccumulator(d, 1)
+	for _, block := range []*types.Block{b2, b1} {
+		root, err := rebuilt.Root(ctx, block.Header.Hash)
+		if err != nil || root != block.Header.NullifierRoot {
+			t.Errorf("Rebuilt root at height %d = %x, %v", block.Header.Height, root, err)
+		}
+	}
+
+	// A sibling branch does not see b1's spends
+	side := chainBlock(t, 1, genesis.Header.Hash, types.Address{}, nullifierRoot(acc), spendTx(types.Hash{3}))
+	sideTree := zkp.NewCommitmentTree(zkp.NewInMemoryTreeStore(), zkp.TreeDepth)
+	sideTree.AddCommitment(ctx, types.Hash{3})
+	if side.Header.NullifierRoot != sideTree.GetRoot() {
+		t.Error("Sibling block root includes spends from another branch")
+	}
+
+	// The validator checks the committed root
+	validator := dag.NewBlockValidator(d)
+	validator.SetNullifierRoots(acc)
+	bad := chainBlock(t, 3, b2.Header.Hash, types.Address{}, nullifierRoot(acc), spendTx(types.Hash{4}))
+	bad.Header.NullifierRoot = b2.Header.NullifierRoot
+	bad.Header.Hash = bad.Header.ComputeHash()
+	if err := validator.ValidateBlock(ctx, bad); err != dag.ErrInvalidNullifierRoot {
+		t.Errorf("Expected ErrInvalidNullifierRoot, got %v", err)
+	}
+}
+
+// Test double-spend fraud proofs
+func TestDoubleSpendProof(t *testing.T) {
+	ctx := context.Background()
+	d := dag.NewDAG(newMemDAGStore(), nil)
+	acc := zkp.NewNullifierAccumulator(d, 0)
+
+	genesis := testBlock(0xff, 0)
+	if err := d.AddBlock(ctx, genesis); err != nil {
+		t.Fatalf("Failed to add genesis: %v", err)
+	}
+	earlier := chainBlock(t, 1, genesis.Header.Hash, types.Address{}, nullifierRoot(acc), spendTx(types.Hash{1}), spendTx(types.Hash{2}))
+	if err := d.AddBlock(ctx, earlier); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+
+	// A later block spends nullifier 2 again
+	later := chainBlock(t, 2, earlier.Header.Hash, types.Address{}, nullifierRoot(acc), spendTx(types.Hash{5}), spendTx(types.Hash{6}, types.Hash{2}))
+
+	proof, err := acc.ProveDoubleSpend(ctx, earlier.Header.Hash, later, 1, types.Hash{2})
+	if err != nil {
+		t.Fatalf("ProveDoubleSpend failed: %v", err)
+	}
+	if err := proof.Verify(); err != nil {
+		t.Fatalf("Valid proof rejected: %v", err)
+	}
+
+	if _, err := acc.ProveDoubleSpend(ctx, earlier.Header.Hash, later, 0, types.Hash{5}); err != zkp.ErrNullifierNotCommitted {
+		t.Errorf("Expected ErrNullifierNotCommitted, got %v", err)
+	}
+
+	// Tampering with any part invalidates the proof
+	tampered := *proof
+	tampered.TxIndex = 0
+	if tampered.Verify() == nil {
+		t.Error("Proof with wrong transaction index verified")
+	}
+	tampered = *proof
+	tampered.Nullifier = types.Hash{6}
+	if tampered.Verify() == nil {
+		t.Error("Proof for an uncommitted nullifier verified")
+	}
+	tampered = *proof
+	tampered.Earlier, tampered.Block = proof.Block, proof.Earlier
+	if tampered.Verify() == nil {
+		t.Error("Proof with swapped headers verified")
+	}
+	header := *proof.Earlier
+	header.NullifierRoot = types.Hash{7}
+	tampered = *proof
+	tampered.Earlier = &header
+	if tampered.Verify() == nil {
+		t.Error("Proof with a modified header verified")
+	}
+}
@@ -3,9 +3,12 @@ package tests
 
 import (
 	"context"
+	"crypto/ed25519"
+	"errors"
 	"testing"
 
 	"github.com/ccoin/core/internal/aicommons"
+	"github.com/ccoin/core/internal/vrf"
 	"github.com/ccoin/core/pkg/types"
 )
 
@@ -273,3 +276,594 @@ func TestTaskAssignment(t *testing.T) {
 		t.Error("Assignment status should be assigned")
 	}
 }
+
+// fixedBenchmark reports a fixed accuracy
+type fixedBenchmark struct {
+	accuracy float64
+}
+
+func (b *fixedBenchmark) Benchmark(ctx context.Context, weightsCID string, benchmarkID types.Hash) (float64, float64, error) {
+	return b.accuracy, 1 - b.accuracy, nil
+}
+
+// Test evaluator admission, attestation and slashing
+func TestEvaluatorAttestations(t *testing.T) {
+	ctx := context.Background()
+	cfg := aicommons.DefaultEvaluatorConfig()
+	cfg.CommitteeSize = 3
+	registry := aicommons.NewEvaluatorRegistry(nil, cfg)
+
+	// Three evaluators, one of which reports a wildly different accuracy
+	accuracies := []float64{0.91, 0.90, 0.50}
+	nodes := make(map[types.Address]*aicommons.EvaluatorNode)
+	for i, acc := range accuracies {
+		addr := types.Address{byte(i + 1)}
+		pub, priv, _ := ed25519.GenerateKey(nil)
+
+		if err := registry.Register(ctx, addr, pub, cfg.MinBond-1, 1); err != aicommons.ErrInsufficientBond {
+			t.Errorf("Expected ErrInsufficientBond, got %v", err)
+		}
+		if err := registry.Register(ctx, addr, pub, cfg.MinBond*2, 1); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+
+		proposal := &types.Proposal{
+			Type:   types.ProposalEvaluatorAdmission,
+			Status: types.ProposalStatusPassed,
+			Data:   &types.EvaluatorAdmissionData{Evaluator: addr, PublicKey: pub},
+		}
+		if err := registry.ApplyProposal(ctx, proposal); err != nil {
+			t.Fatalf("ApplyProposal failed: %v", err)
+		}
+
+		nodes[addr] = aicommons.NewEvaluatorNode(addr, priv, &fixedBenchmark{accuracy: acc})
+	}
+
+	job, err := registry.CreateJob(types.Hash{0xaa}, "bafy-weights", types.Hash{0xbb}, 1, 100)
+	if err != nil {
+		t.Fatalf("CreateJob failed: %v", err)
+	}
+	if len(job.Evaluators) != 3 {
+		t.Fatalf("Expected committee of 3, got %d", len(job.Evaluators))
+	}
+
+	for _, addr := range job.Evaluators {
+		att, err := nodes[addr].Evaluate(ctx, job)
+		if err != nil {
+			t.Fatalf("Evaluate failed: %v", err)
+		}
+		if err := registry.SubmitAttestation(ctx, att); err != nil {
+			t.Fatalf("SubmitAttestation failed: %v", err)
+		}
+
+		// Tampered attestations fail signature verification
+		forged := *att
+		forged.Accuracy = 0.99
+		if err := registry.SubmitAttestation(ctx, &forged); err == nil {
+			t.Error("Duplicate or forged attestation should be rejected")
+		}
+	}
+
+	result, err := registry.Finalize(ctx, job.JobID)
+	if err != nil {
+		t.Fatalf("Finalize failed: %v", err)
+	}
+	if result.Accuracy != 0.90 {
+		t.Errorf("Expected median accuracy 0.90, got %f", result.Accuracy)
+	}
+	if len(result.Dishonest) != 1 || result.Dishonest[0] != (types.Address{3}) {
+		t.Errorf("Expected evaluator 3 to be slashed, got %v", result.Dishonest)
+	}
+
+	slashed := registry.GetEvaluator(types.Address{3})
+	if slashed.Slashed == 0 || slashed.Bond >= cfg.MinBond*2 {
+		t.Error("Dishonest evaluator bond should be slashed")
+	}
+
+	// The finalized job verifies as the first version of the model
+	_, atts, err := registry.Attestations(job.JobID)
+	if err != nil || len(atts) != 3 {
+		t.Fatalf("Attestations failed: %d, %v", len(atts), err)
+	}
+	keys := make(map[types.Address]ed25519.PublicKey)
+	for addr := range nodes {
+		keys[addr] = registry.GetEvaluator(addr).PublicKey
+	}
+	version := &types.ModelVersion{
+		Version:      1,
+		WeightsCID:   job.WeightsCID,
+		Accuracy:     result.Accuracy,
+		Job:          job,
+		Attestations: atts,
+	}
+	chain := []*types.ModelVersion{version}
+	if err := aicommons.VerifyVersionChain(job.ModelID, chain, keys, cfg.Tolerance); err != nil {
+		t.Errorf("VerifyVersionChain failed: %v", err)
+	}
+
+	version.Accuracy = 0.99
+	if err := aicommons.VerifyVersionChain(job.ModelID, chain, keys, cfg.Tolerance); err != aicommons.ErrAccuracyMismatch {
+		t.Errorf("Expected ErrAccuracyMismatch, got %v", err)
+	}
+	version.Accuracy = result.Accuracy
+
+	next := &types.ModelVersion{Version: 2, WeightsCID: "bafy-next", ParentCID: "bafy-other"}
+	if err := aicommons.VerifyVersionChain(job.ModelID, append(chain, next), keys, cfg.Tolerance); err != aicommons.ErrBrokenVersionChain {
+		t.Errorf("Expected ErrBrokenVersionChain, got %v", err)
+	}
+}
+
+// memModelStore is an in-memory aicommons.ModelStore
+type memModelStore struct {
+	models map[types.Hash]*types.ModelEntry
+}
+
+func (s *memModelStore) SaveModel(ctx context.Context, m *types.ModelEntry) error {
+	s.models[m.ModelID] = m
+	return nil
+}
+
+func (s *memModelStore) GetModel(ctx context.Context, id types.Hash) (*types.ModelEntry, error) {
+	if m, ok := s.models[id]; ok {
+		return m, nil
+	}
+	return nil, aicommons.ErrModelNotFound
+}
+
+func (s *memModelStore) ListModels(ctx context.Context, taskType types.TaskType, limit int) ([]*types.ModelEntry, error) {
+	return nil, nil
+}
+
+func (s *memModelStore) SaveContribution(ctx context.Context, c *aicommons.Contribution) error {
+	return nil
+}
+
+func (s *memModelStore) GetContributions(ctx context.Context, modelID types.Hash) ([]*aicommons.Contribution, error) {
+	return nil, nil
+}
+
+var errUnlicensed = errors.New("unlicensed")
+
+// signRegistration builds a bonded registration signed by a fresh key
+// that becomes the model's proposer
+func signRegistration(model *types.ModelEntry, bond uint64) *types.ModelRegistration {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	model.ProposerAddress = types.AddressFromPublicKey(pub)
+	reg := &types.ModelRegistration{Model: model, Bond: bond, PublicKey: pub}
+	digest := reg.SigningHash()
+	reg.Signature = ed25519.Sign(priv, digest[:])
+	return reg
+}
+
+// staticLicenses grants every node a license for one model
+type staticLicenses struct {
+	modelID types.Hash
+	used    int
+}
+
+func (l *staticLicenses) VerifyLicense(ctx context.Context, licenseID, modelID types.Hash, licensee types.Address, currentBlock uint64) error {
+	if modelID != l.modelID || licenseID != (types.Hash{0x1c}) {
+		return errUnlicensed
+	}
+	return nil
+}
+
+func (l *staticLicenses) RecordInference(ctx context.Context, licenseID types.Hash) error {
+	l.used++
+	return nil
+}
+
+// fixedInference returns the same output for every input
+type fixedInference struct {
+	result types.Hash
+}
+
+func (f *fixedInference) Infer(ctx context.Context, weightsCID string, inputHash types.Hash) (types.Hash, error) {
+	return f.result, nil
+}
+
+// Test inference escrow, result posting, disputes and timeouts
+func TestInferenceEscrow(t *testing.T) {
+	ctx := context.Background()
+
+	models := aicommons.NewModelRegistry(&memModelStore{models: make(map[types.Hash]*types.ModelEntry)}, nil)
+	model := &types.ModelEntry{ModelID: types.Hash{0xaa}, CurrentWeights: "bafy-weights"}
+	if err := models.RegisterModel(ctx, signRegistration(model, 10000), 1); err != nil {
+		t.Fatalf("RegisterModel failed: %v", err)
+	}
+
+	evalCfg := aicommons.DefaultEvaluatorConfig()
+	evalCfg.CommitteeSize = 3
+	evaluators := aicommons.NewEvaluatorRegistry(nil, evalCfg)
+	licenses := &staticLicenses{modelID: model.ModelID}
+	cfg := aicommons.DefaultInferenceConfig()
+	market := aicommons.NewInferenceMarket(models, evaluators, licenses, nil, cfg)
+
+	// Evaluators re-execute disputes with honest inference
+	honest := types.Hash{0x0d}
+	var evalNodes []*aicommons.EvaluatorNode
+	for i := 0; i < 3; i++ {
+		addr := types.Address{byte(0x10 + i)}
+		pub, priv, _ := ed25519.GenerateKey(nil)
+		if err := evaluators.Register(ctx, addr, pub, evalCfg.MinBond, 1); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		proposal := &types.Proposal{
+			Type:   types.ProposalEvaluatorAdmission,
+			Status: types.ProposalStatusPassed,
+			Data:   &types.EvaluatorAdmissionData{Evaluator: addr, PublicKey: pub},
+		}
+		if err := evaluators.ApplyProposal(ctx, proposal); err != nil {
+			t.Fatalf("ApplyProposal failed: %v", err)
+		}
+		bench := aicommons.NewDisputeBenchmarker(market, &fixedInference{result: honest}, nil)
+		evalNodes = append(evalNodes, aicommons.NewEvaluatorNode(addr, priv, bench))
+	}
+
+	nodeAddr := types.Address{0x20}
+	nodePub, nodePriv, _ := ed25519.GenerateKey(nil)
+	node := types.NewInferenceNode(nodeAddr, types.MinInferenceStake)
+	node.HostedModels = append(node.HostedModels, model.ModelID)
+	if err := market.RegisterNode(node, nodePub); err != nil {
+		t.Fatalf("RegisterNode failed: %v", err)
+	}
+
+	reqPub, reqPriv, _ := ed25519.GenerateKey(nil)
+	newRequest := func(nonce uint64) *types.InferenceRequest {
+		req := &types.InferenceRequest{
+			ModelID:      model.ModelID,
+			InputHash:    types.Hash{0x01},
+			Refund:       types.Address{0x30},
+			RequesterKey: reqPub,
+			Escrow:       cfg.MinEscrow,
+			Timeout:      10,
+			Nonce:        nonce,
+		}
+		req.RequestID = req.ComputeID()
+		if err := market.Apply(ctx, &types.InferenceOp{Type: types.InferenceOpRequest, Request: req}, 100, 1); err != nil {
+			t.Fatalf("SubmitRequest failed: %v", err)
+		}
+		return req
+	}
+	postResult := func(req *types.InferenceRequest, result types.Hash) {
+		res := &types.InferenceResult{
+			RequestID:  req.RequestID,
+			Node:       nodeAddr,
+			ResultHash: result,
+			LicenseID:  types.Hash{0x1c},
+		}
+		digest := res.SigningHash()
+		res.Signature = ed25519.Sign(nodePriv, digest[:])
+		if err := market.PostResult(ctx, res, 105); err != nil {
+			t.Fatalf("PostResult failed: %v", err)
+		}
+	}
+	dispute := func(req *types.InferenceRequest) *types.EvaluationJob {
+		d := &types.InferenceDispute{RequestID: req.RequestID}
+		digest := d.SigningHash()
+		d.Signature = ed25519.Sign(reqPriv, digest[:])
+		if err := market.Dispute(ctx, d, 106, 1); err != nil {
+			t.Fatalf("Dispute failed: %v", err)
+		}
+		escrow, _ := market.GetEscrow(req.RequestID)
+		for _, n := range evalNodes {
+			att, err := n.Evaluate(ctx, escrow.DisputeJob)
+			if err != nil {
+				t.Fatalf("Evaluate failed: %v", err)
+			}
+			if err := evaluators.SubmitAttestation(ctx, att); err != nil {
+				t.Fatalf("SubmitAttestation failed: %v", err)
+			}
+		}
+		return escrow.DisputeJob
+	}
+
+	undisputed := newRequest(1)
+	upheld := newRequest(2)
+	overturned := newRequest(3)
+	unanswered := newRequest(4)
+
+	postResult(undisputed, honest)
+	postResult(upheld, honest)
+	postResult(overturned, types.Hash{0xee})
+
+	// Unlicensed and unsigned results are rejected
+	forged := &types.InferenceResult{RequestID: unanswered.RequestID, Node: nodeAddr, LicenseID: types.Hash{0x1c}}
+	if err := market.PostResult(ctx, forged, 105); err != aicommons.ErrInvalidResult {
+		t.Errorf("Expected ErrInvalidResult, got %v", err)
+	}
+
+	// Only the requester can dispute
+	bad := &types.InferenceDispute{RequestID: upheld.RequestID, Signature: make([]byte, ed25519.SignatureSize)}
+	if err := market.Dispute(ctx, bad, 106, 1); err != aicommons.ErrInvalidDispute {
+		t.Errorf("Expected ErrInvalidDispute, got %v", err)
+	}
+
+	dispute(upheld)
+	job := dispute(overturned)
+
+	payouts, err := market.ProcessBlock(ctx, job.Deadline+1)
+	if err != nil {
+		t.Fatalf("ProcessBlock failed: %v", err)
+	}
+	if len(payouts) != 4 {
+		t.Fatalf("Expected 4 payouts, got %d", len(payouts))
+	}
+
+	want := map[types.Hash]types.Address{
+		undisputed.RequestID: nodeAddr,
+		upheld.RequestID:     nodeAddr,
+		overturned.RequestID: unanswered.Refund,
+		unanswered.RequestID: unanswered.Refund,
+	}
+	for _, p := range payouts {
+		if p.Recipient != want[p.RequestID] || p.Amount != cfg.MinEscrow {
+			t.Errorf("Unexpected payout %+v", p)
+		}
+	}
+
+	if node.TotalQueries != 3 || node.CorrectResults != 2 {
+		t.Errorf("Expected 2 of 3 correct results, got %d of %d", node.CorrectResults, node.TotalQueries)
+	}
+	if licenses.used != 2 {
+		t.Errorf("Expected 2 metered inferences, got %d", licenses.used)
+	}
+
+	// Settled requests take no further operations
+	if err := market.Dispute(ctx, &types.InferenceDispute{RequestID: undisputed.RequestID}, 200, 1); err != aicommons.ErrRequestClosed {
+		t.Errorf("Expected ErrRequestClosed, got %v", err)
+	}
+}
+
+// Test dependency-aware scheduling of a multi-model pipeline
+func TestTaskPipeline(t *testing.T) {
+	ctx := context.Background()
+
+	models := aicommons.NewModelRegistry(&memModelStore{models: make(map[types.Hash]*types.ModelEntry)}, nil)
+	teacher := &types.ModelEntry{ModelID: types.Hash{0x01}}
+	student := &types.ModelEntry{ModelID: types.Hash{0x02}}
+	for _, m := range []*types.ModelEntry{teacher, student} {
+		if err := models.RegisterModel(ctx, signRegistration(m, 10000), 1); err != nil {
+			t.Fatalf("RegisterModel failed: %v", err)
+		}
+	}
+	ta := aicommons.NewTaskAssigner(models, nil)
+
+	// Stages may only depend on earlier stages
+	cyclic := []aicommons.TaskSpec{{ModelID: teacher.ModelID, DependsOn: []int{0}}}
+	if _, err := ta.CreatePipeline(ctx, 1, cyclic, 10); err != aicommons.ErrInvalidPipeline {
+		t.Errorf("Expected ErrInvalidPipeline, got %v", err)
+	}
+
+	// preprocess -> train -> distill into the student model
+	specs := []aicommons.TaskSpec{
+		{ModelID: teacher.ModelID, DatasetCID: "bafy-raw", BatchEnd: 100},
+		{ModelID: teacher.ModelID, DatasetCID: "bafy-clean", BatchEnd: 100, DependsOn: []int{0}},
+		{ModelID: student.ModelID, DatasetCID: "bafy-clean", BatchEnd: 100, DependsOn: []int{1}},
+	}
+	pipeline, err := ta.CreatePipeline(ctx, 1, specs, 10)
+	if err != nil {
+		t.Fatalf("CreatePipeline failed: %v", err)
+	}
+	if len(ta.GetBlockedTasks()) != 2 {
+		t.Fatalf("Expected 2 blocked stages, got %d", len(ta.GetBlockedTasks()))
+	}
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	miner := types.AddressFromPublicKey(pub)
+	seed := ta.VRFSeed()
+	proof, err := vrf.Prove(priv, vrf.TaskInput(seed))
+	if err != nil {
+		t.Fatalf("Prove failed: %v", err)
+	}
+	if _, err := ta.AssignTask(ctx, pub, types.Hash{0x01}, proof, 1.0, 20); err != aicommons.ErrStaleVRFSeed {
+		t.Errorf("Expected ErrStaleVRFSeed, got %v", err)
+	}
+	forged := append([]byte(nil), proof...)
+	forged[40] ^= 0xff
+	if _, err := ta.AssignTask(ctx, pub, seed, forged, 1.0, 20); err != aicommons.ErrInvalidVRFProof {
+		t.Errorf("Expected ErrInvalidVRFProof, got %v", err)
+	}
+
+	for stage, taskID := range pipeline.Tasks {
+		assignment, err := ta.AssignTask(ctx, pub, seed, proof, 1.0, 20)
+		if err != nil {
+			t.Fatalf("Stage %d: AssignTask failed: %v", stage, err)
+		}
+		if assignment.Task.TaskID != taskID {
+			t.Fatalf("Stage %d: assigned out of order", stage)
+		}
+
+		// Nothing else is released until this stage is verified
+		if _, err := ta.AssignTask(ctx, pub, seed, proof, 1.0, 20); err != aicommons.ErrNoTasksAvailable {
+			t.Fatalf("Stage %d: expected ErrNoTasksAvailable, got %v", stage, err)
+		}
+
+		if _, err := ta.VerifyResult(ctx, taskID, true, 21); err != aicommons.ErrResultNotSubmitted {
+			t.Errorf("Expected ErrResultNotSubmitted, got %v", err)
+		}
+		if err := ta.SubmitResult(ctx, taskID, miner, types.Hash{byte(stage)}, 0.5, 21); err != nil {
+			t.Fatalf("SubmitResult failed: %v", err)
+		}
+
+		// A rejected result goes back to the pool without releasing anything
+		if stage == 1 {
+			if released, err := ta.VerifyResult(ctx, taskID, false, 22); err != nil || len(released) != 0 {
+				t.Fatalf("Rejecting result: released %d, %v", len(released), err)
+			}
+			if _, err := ta.AssignTask(ctx, pub, seed, proof, 1.0, 23); err != nil {
+				t.Fatalf("Reassign failed: %v", err)
+			}
+			if err := ta.SubmitResult(ctx, taskID, miner, types.Hash{byte(stage)}, 0.5, 23); err != nil {
+				t.Fatalf("SubmitResult failed: %v", err)
+			}
+		}
+
+		released, err := ta.VerifyResult(ctx, taskID, true, 24)
+		if err != nil {
+			t.Fatalf("VerifyResult failed: %v", err)
+		}
+		if stage < len(pipeline.Tasks)-1 && (len(released) != 1 || released[0].TaskID != pipeline.Tasks[stage+1]) {
+			t.Fatalf("Stage %d: expected next stage to be released", stage)
+		}
+	}
+
+	progress := ta.RoundProgress(1)
+	if progress.Pipelines != 1 || progress.Completed != 1 || progress.VerifiedTasks != 3 {
+		t.Errorf("Unexpected round progress %+v", progress)
+	}
+	if !pipeline.Complete() || pipeline.CompletedAt != 24 {
+		t.Errorf("Pipeline should complete at block 24, got %d", pipeline.CompletedAt)
+	}
+}
+
+// Test bonded, rate-limited model registration and bond forfeiture
+func TestModelRegistrationBonds(t *testing.T) {
+	ctx := context.Background()
+	cfg := aicommons.DefaultRegistryConfig()
+	cfg.RateLimit = 2
+	cfg.RateWindow = 100
+	models := aicommons.NewModelRegistry(&memModelStore{models: make(map[types.Hash]*types.ModelEntry)}, cfg)
+
+	// Registrations must carry the minimum bond
+	if err := models.RegisterModel(ctx, signRegistration(&types.ModelEntry{Domain: "a"}, cfg.MinBond-1), 1); err != aicommons.ErrRegistrationBond {
+		t.Errorf("Expected ErrRegistrationBond, got %v", err)
+	}
+
+	// Registrations must be signed by the proposer
+	forged := signRegistration(&types.ModelEntry{Domain: "a"}, cfg.MinBond)
+	forged.Model.ProposerAddress = types.Address{0x99}
+	if err := models.RegisterModel(ctx, forged, 1); err != aicommons.ErrInvalidRegistration {
+		t.Errorf("Expected ErrInvalidRegistration, got %v", err)
+	}
+
+	// One proposer registering under a shared key is rate limited
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	proposer := types.AddressFromPublicKey(pub)
+	register := func(domain string, block uint64) (*types.ModelEntry, error) {
+		model := &types.ModelEntry{Domain: domain, ProposerAddress: proposer}
+		reg := &types.ModelRegistration{Model: model, Bond: cfg.MinBond, PublicKey: pub}
+		digest := reg.SigningHash()
+		reg.Signature = ed25519.Sign(priv, digest[:])
+		return model, models.RegisterModel(ctx, reg, block)
+	}
+
+	spam, err := register("spam-1", 10)
+	if err != nil {
+		t.Fatalf("RegisterModel failed: %v", err)
+	}
+	kept, err := register("spam-2", 20)
+	if err != nil {
+		t.Fatalf("RegisterModel failed: %v", err)
+	}
+	if _, err := register("spam-3", 30); err != aicommons.ErrRateLimited {
+		t.Errorf("Expected ErrRateLimited, got %v", err)
+	}
+	if _, err := register("spam-3", 110); err != nil {
+		t.Errorf("Registration after the window should succeed: %v", err)
+	}
+
+	// Bonds stay locked until the lock period ends
+	if _, err := models.RefundBond(kept.ModelID, proposer, 21); err != aicommons.ErrBondLocked {
+		t.Errorf("Expected ErrBondLocked, got %v", err)
+	}
+
+	// Governance forfeits the abusive registration's bond
+	proposal := &types.Proposal{
+		Type:   types.ProposalBondForfeiture,
+		Status: types.ProposalStatusPassed,
+		Data:   &types.BondForfeitureData{ModelID: spam.ModelID, Reason: "spam"},
+	}
+	bond, err := models.ApplyProposal(ctx, proposal)
+	if err != nil {
+		t.Fatalf("ApplyProposal failed: %v", err)
+	}
+	if bond.Amount != cfg.MinBond || bond.Status != aicommons.BondForfeited {
+		t.Errorf("Unexpected forfeited bond %+v", bond)
+	}
+	if spam.Status != types.ModelStatusDeprecated {
+		t.Error("Forfeited model should be deprecated")
+	}
+	if _, err := models.RefundBond(spam.ModelID, proposer, 10+cfg.BondLockPeriod); err != aicommons.ErrBondReleased {
+		t.Errorf("Expected ErrBondReleased, got %v", err)
+	}
+
+	// The honest registration is refunded after the lock period
+	if _, err := models.RefundBond(kept.ModelID, types.Address{0x01}, 20+cfg.BondLockPeriod); err != aicommons.ErrUnauthorized {
+		t.Errorf("Expected ErrUnauthorized, got %v", err)
+	}
+	refund, err := models.RefundBond(kept.ModelID, proposer, 20+cfg.BondLockPeriod)
+	if err != nil || refund != cfg.MinBond {
+		t.Errorf("RefundBond: %d, %v", refund, err)
+	}
+}
+
+// Test that epoch checkpoints freeze the registry and prove models against
+// their root
+func TestRegistryCheckpoints(t *testing.T) {
+	ctx := context.Background()
+	models := aicommons.NewModelRegistry(&memModelStore{models: make(map[types.Hash]*types.ModelEntry)}, nil)
+
+	model := &types.ModelEntry{Domain: "vision"}
+	if err := models.RegisterModel(ctx, signRegistration(model, 10000), 1); err != nil {
+		t.Fatalf("RegisterModel failed: %v", err)
+	}
+	contributor := types.Address{0x01}
+	models.RecordContribution(ctx, &aicommons.Contribution{Contributor: contributor, ModelID: model.ModelID, Compute: 100, Quality: 0.9})
+
+	if _, err := models.ProveModel(model.ModelID, 0); !errors.Is(err, aicommons.ErrNoCheckpoint) {
+		t.Errorf("Expected ErrNoCheckpoint before any checkpoint, got %v", err)
+	}
+
+	root, err := models.RegistryRoot(ctx, 2)
+	if err != nil || root.IsEmpty() {
+		t.Fatalf("RegistryRoot: %x, %v", root, err)
+	}
+
+	// Later contributions do not move a taken checkpoint
+	models.RecordContribution(ctx, &aicommons.Contribution{Contributor: types.Address{0x02}, ModelID: model.ModelID, Compute: 100, Quality: 0.5})
+	if again, _ := models.RegistryRoot(ctx, 2); again != root {
+		t.Error("Checkpoint root changed after a contribution")
+	}
+	if _, err := models.RegistryRoot(ctx, 1); !errors.Is(err, aicommons.ErrNoCheckpoint) {
+		t.Errorf("Expected ErrNoCheckpoint for an earlier epoch, got %v", err)
+	}
+
+	proof, err := models.ProveModel(model.ModelID, 0)
+	if err != nil {
+		t.Fatalf("ProveModel failed: %v", err)
+	}
+	if proof.Epoch != 2 || proof.Root != root {
+		t.Errorf("Proof is for epoch %d root %x", proof.Epoch, proof.Root)
+	}
+	if err := proof.Verify(root); err != nil {
+		t.Errorf("Proof does not verify: %v", err)
+	}
+	if share := proof.Checkpoint.Share(contributor); share != 1 {
+		t.Errorf("Contributor share is %v at the checkpoint, want 1", share)
+	}
+
+	// A tampered checkpoint fails
+	proof.Checkpoint.Accuracy = 0.99
+	if err := proof.Verify(root); !errors.Is(err, aicommons.ErrInvalidModelProof) {
+		t.Errorf("Expected ErrInvalidModelProof, got %v", err)
+	}
+
+	// An unregistered model gets a proof of absence
+	absent, err := models.ProveModel(types.Hash{0xaa}, 2)
+	if err != nil || absent.Checkpoint != nil {
+		t.Fatalf("ProveModel for an unknown model: %+v, %v", absent, err)
+	}
+	if err := absent.Verify(root); err != nil {
+		t.Errorf("Absence proof does not verify: %v", err)
+	}
+
+	// The next epoch sees the later contribution
+	next, _ := models.RegistryRoot(ctx, 3)
+	if next == root {
+		t.Error("Next checkpoint did not change")
+	}
+}
@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ccoin/core/internal/rpc"
+	"github.com/ccoin/core/internal/storage"
+)
+
+// analyticsCall is the range an analytics query was asked for
+type analyticsCall struct {
+	from, to, step uint64
+	bins           int
+}
+
+// recordingAnalytics answers every query with one point and records the
+// range it was asked for
+type recordingAnalytics struct {
+	calls []analyticsCall
+}
+
+func (a *recordingAnalytics) DifficultyHistory(ctx context.Context, fromHeight, toHeight, step uint64) ([]storage.DifficultyPoint, error) {
+	a.calls = append(a.calls, analyticsCall{fromHeight, toHeight, step, 0})
+	return []storage.DifficultyPoint{{Height: fromHeight, Timestamp: 1000, Difficulty: big.NewInt(0x1234)}}, nil
+}
+
+func (a *recordingAnalytics) QualityHistory(ctx context.Context, fromEpoch, toEpoch, step uint64) ([]storage.QualityPoint, error) {
+	a.calls = append(a.calls, analyticsCall{fromEpoch, toEpoch, step, 0})
+	return []storage.QualityPoint{{FromEpoch: fromEpoch, ToEpoch: toEpoch, AverageQuality: 0.5, Blocks: 3}}, nil
+}
+
+func (a *recordingAnalytics) ReputationHistograms(ctx context.Context, fromEpoch, toEpoch, step uint64, bins int) ([]storage.ReputationHistogram, error) {
+	a.calls = append(a.calls, analyticsCall{fromEpoch, toEpoch, step, bins})
+	return []storage.ReputationHistogram{{FromEpoch: fromEpoch, ToEpoch: toEpoch, Counts: make([]int64, bins)}}, nil
+}
+
+func (a *recordingAnalytics) StakingHistory(ctx context.Context, fromEpoch, toEpoch, step uint64) ([]storage.StakingPoint, error) {
+	a.calls = append(a.calls, analyticsCall{fromEpoch, toEpoch, step, 0})
+	return []storage.StakingPoint{{Epoch: toEpoch, TotalStaked: 500, Stakers: 2}}, nil
+}
+
+// epochDAG is a header-only DAG at a fixed epoch
+type epochDAG struct {
+	bodylessDAG
+	epoch uint64
+}
+
+func (d epochDAG) GetEpoch() uint64 { return d.epoch }
+
+// Test that series steps fit a range into the requested number of points
+func TestSeriesStep(t *testing.T) {
+	for _, tc := range []struct {
+		from, to  uint64
+		maxPoints int
+		step      uint64
+	}{
+		{0, 99, 100, 1},
+		{0, 100, 100, 2},
+		{10, 10, 100, 1},
+		{0, 999, 0, 2},
+		{0, 99999, 1000000, 20},
+	} {
+		if got := rpc.SeriesStep(tc.from, tc.to, tc.maxPoints); got != tc.step {
+			t.Errorf("SeriesStep(%d, %d, %d) = %d, want %d", tc.from, tc.to, tc.maxPoints, got, tc.step)
+		}
+	}
+}
+
+// Test that history requests default their upper bound to the chain tip,
+// sample with the series step and convert the stored points
+func TestAnalyticsHistory(t *testing.T) {
+	ctx := context.Background()
+	a := &recordingAnalytics{}
+	s := rpc.NewServer(nil, &rpc.Backends{DAG: epochDAG{epoch: 40}, Analytics: a})
+
+	diff, err := s.GetDifficultyHistory(ctx, &rpc.GetDifficultyHistoryRequest{FromHeight: 2, MaxPoints: 3})
+	if err != nil {
+		t.Fatalf("GetDifficultyHistory failed: %v", err)
+	}
+	if a.calls[0] != (analyticsCall{2, 7, 2, 0}) || diff.Step != 2 {
+		t.Errorf("Difficulty queried for %+v with step %d, want heights 2..7 every 2", a.calls[0], diff.Step)
+	}
+	if len(diff.Points) != 1 || diff.Points[0].Difficulty != "4660" || diff.Points[0].Height != 2 {
+		t.Errorf("Difficulty points = %+v", diff.Points)
+	}
+
+	quality, err := s.GetQualityHistory(ctx, &rpc.GetQualityHistoryRequest{EpochRangeRequest: rpc.EpochRangeRequest{FromEpoch: 1}})
+	if err != nil {
+		t.Fatalf("GetQualityHistory failed: %v", err)
+	}
+	if a.calls[1] != (analyticsCall{1, 40, 1, 0}) || len(quality.Points) != 1 || quality.Points[0].AverageQuality != 0.5 {
+		t.Errorf("Quality queried for %+v: %+v", a.calls[1], quality)
+	}
+
+	staking, err := s.GetStakingHistory(ctx, &rpc.GetStakingHistoryRequest{EpochRangeRequest: rpc.EpochRangeRequest{FromEpoch: 0, ToEpoch: 19, MaxPoints: 10}})
+	if err != nil {
+		t.Fatalf("GetStakingHistory failed: %v", err)
+	}
+	if a.calls[2] != (analyticsCall{0, 19, 2, 0}) || staking.Step != 2 || staking.Points[0].TotalStaked != 500 {
+		t.Errorf("Staking queried for %+v: %+v", a.calls[2], staking)
+	}
+
+	hist, err := s.GetReputationHistogram(ctx, &rpc.GetReputationHistogramRequest{EpochRangeRequest: rpc.EpochRangeRequest{FromEpoch: 5}})
+	if err != nil {
+		t.Fatalf("GetReputationHistogram failed: %v", err)
+	}
+	if a.calls[3] != (analyticsCall{5, 40, 1, rpc.DefaultHistogramBins}) {
+		t.Errorf("Histograms queried for %+v", a.calls[3])
+	}
+	if len(hist.BinEdges) != rpc.DefaultHistogramBins+1 || hist.BinEdges[0] != 0.1 || math.Abs(hist.BinEdges[rpc.DefaultHistogramBins]-3.0) > 1e-9 {
+		t.Errorf("Bin edges = %v, want %d edges over [0.1, 3.0]", hist.BinEdges, rpc.DefaultHistogramBins+1)
+	}
+	if len(hist.Histograms) != 1 || len(hist.Histograms[0].Counts) != rpc.DefaultHistogramBins {
+		t.Errorf("Histograms = %+v", hist.Histograms)
+	}
+}
+
+// Test that inverted ranges and oversized histograms are refused before
+// the backend is queried, and that a node without analytics says so
+func TestAnalyticsInvalid(t *testing.T) {
+	ctx := context.Background()
+	a := &recordingAnalytics{}
+	s := rpc.NewServer(nil, &rpc.Backends{DAG: epochDAG{epoch: 40}, Analytics: a})
+
+	inverted := rpc.EpochRangeRequest{FromEpoch: 10, ToEpoch: 5}
+	for name, call := range map[string]func() error{
+		"difficulty": func() error {
+			_, err := s.GetDifficultyHistory(ctx, &rpc.GetDifficultyHistoryRequest{FromHeight: 8})
+			return err
+		},
+		"quality": func() error {
+			_, err := s.GetQualityHistory(ctx, &rpc.GetQualityHistoryRequest{EpochRangeRequest: inverted})
+			return err
+		},
+		"staking": func() error {
+			_, err := s.GetStakingHistory(ctx, &rpc.GetStakingHistoryRequest{EpochRangeRequest: inverted})
+			return err
+		},
+		"histogram": func() error {
+			_, err := s.GetReputationHistogram(ctx, &rpc.GetReputationHistogramRequest{EpochRangeRequest: inverted})
+			return err
+		},
+		"bins": func() error {
+			_, err := s.GetReputationHistogram(ctx, &rpc.GetReputationHistogramRequest{Bins: rpc.MaxHistogramBins + 1})
+			return err
+		},
+	} {
+		if err := call(); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: expected InvalidArgument, got %v", name, err)
+		}
+	}
+	if len(a.calls) != 0 {
+		t.Errorf("Backend queried for invalid requests: %+v", a.calls)
+	}
+
+	s = rpc.NewServer(nil, &rpc.Backends{DAG: epochDAG{}})
+	if _, err := s.GetStakingHistory(ctx, &rpc.GetStakingHistoryRequest{}); status.Code(err) != codes.Unimplemented {
+		t.Errorf("Expected Unimplemented without analytics, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ccoin/core/internal/archive"
+	"github.com/ccoin/core/internal/threshold"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Mock block source
+type mockArchiveSource struct {
+	blocks []*types.Block
+}
+
+func (s *mockArchiveSource) GetMainChainByTime(ctx context.Context, from, to int64) ([]*types.Block, error) {
+	var out []*types.Block
+	for _, b := range s.blocks {
+		if int64(b.Header.Timestamp) >= from && int64(b.Header.Timestamp) < to {
+			out = append(out, b)
+		}
+	}
+	return out, nil
+}
+
+// TestArchiveExport tests exporting and opening a custodian archive
+func TestArchiveExport(t *testing.T) {
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	tx := &types.Transaction{
+		TxHash:      types.Hash{0xaa},
+		Nullifiers:  []types.Hash{{1}},
+		Commitments: []types.Commitment{{Value: types.Hash{2}, EncryptedNote: []byte("secret note")}},
+		Fee:         50,
+		Memo:        []byte("private memo"),
+		Disclosures: []types.Disclosure{{Type: types.DisclosureRange, PublicData: []byte{0, 100}}},
+	}
+	src := &mockArchiveSource{blocks: []*types.Block{
+		types.NewBlock(&types.BlockHeader{Height: 1, Timestamp: uint64(day.Add(time.Hour).Unix())}, []*types.Transaction{tx}),
+		types.NewBlock(&types.BlockHeader{Height: 2, Timestamp: uint64(day.Add(48 * time.Hour).Unix())}, nil),
+	}}
+
+	pk, shares, err := threshold.Deal(3, 2)
+	if err != nil {
+		t.Fatalf("Deal failed: %v", err)
+	}
+
+	a, err := archive.Export(context.Background(), src, day, day.Add(24*time.Hour), pk)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if a.Header.Blocks != 1 || a.Header.Transactions != 1 {
+		t.Errorf("header counts = %d blocks, %d txs", a.Header.Blocks, a.Header.Transactions)
+	}
+	if bytes.Contains(a.Sealed.Ciphertext, []byte("memo")) {
+		t.Error("archive leaks plaintext")
+	}
+
+	var buf bytes.Buffer
+	if _, err := a.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	read, err := archive.Read(&buf)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	// One custodian alone cannot open it
+	s1, _ := read.Share(shares[0])
+	if _, err := read.Open(pk, []*threshold.DecryptionShare{s1}); err != threshold.ErrNotEnoughShares {
+		t.Fatalf("expected ErrNotEnoughShares, got %v", err)
+	}
+
+	s3, _ := read.Share(shares[2])
+	records, err := read.Open(pk, []*threshold.DecryptionShare{s1, s3})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if len(records) != 1 || records[0].TxHash != tx.TxHash || records[0].Fee != 50 {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+	if len(records[0].Disclosures) != 1 || records[0].Disclosures[0].Type != types.DisclosureRange {
+		t.Error("disclosure not archived")
+	}
+}
@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ccoin/core/internal/atrest"
+	"github.com/ccoin/core/internal/mempool"
+	"github.com/ccoin/core/internal/storage"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Test key derivation, file encryption and the modes
+func TestAtRestFiles(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &atrest.Config{Mode: atrest.ModeFull, Passphrase: "correct horse"}
+	c, err := atrest.Open(dir, cfg)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, err := atrest.Open(dir, &atrest.Config{Mode: atrest.ModeFull, Passphrase: "wrong"}); !errors.Is(err, atrest.ErrWrongKey) {
+		t.Errorf("Expected ErrWrongKey, got %v", err)
+	}
+	if _, err := atrest.Open(dir, &atrest.Config{Mode: "some"}); !errors.Is(err, atrest.ErrUnknownMode) {
+		t.Errorf("Expected ErrUnknownMode, got %v", err)
+	}
+	if off, err := atrest.Open(dir, &atrest.Config{Mode: atrest.ModeOff}); off != nil || err != nil {
+		t.Errorf("Expected no cipher when off, got %v, %v", off, err)
+	}
+	if c, err = atrest.Open(dir, cfg); err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+
+	path := filepath.Join(dir, "secret.json")
+	secret := []byte(`{"seed":"00112233"}`)
+	if err := c.WriteFile(path, secret, 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if raw, _ := os.ReadFile(path); bytes.Contains(raw, []byte("seed")) {
+		t.Error("File was written in the clear")
+	}
+	if got, err := c.ReadFile(path); err != nil || !bytes.Equal(got, secret) {
+		t.Errorf("ReadFile returned %q, %v", got, err)
+	}
+	var none *atrest.Cipher
+	if _, err := none.ReadFile(path); !errors.Is(err, atrest.ErrEncrypted) {
+		t.Errorf("Expected ErrEncrypted without a key, got %v", err)
+	}
+
+	// A file is bound to its name
+	moved := filepath.Join(dir, "other.json")
+	raw, _ := os.ReadFile(path)
+	os.WriteFile(moved, raw, 0600)
+	if _, err := c.ReadFile(moved); !errors.Is(err, atrest.ErrWrongKey) {
+		t.Errorf("Expected a renamed file to fail, got %v", err)
+	}
+
+	// Files written before encryption was turned on still read
+	legacy := filepath.Join(dir, "legacy.json")
+	os.WriteFile(legacy, secret, 0600)
+	if got, err := c.ReadFile(legacy); err != nil || !bytes.Equal(got, secret) {
+		t.Errorf("Legacy ReadFile returned %q, %v", got, err)
+	}
+
+	// The performance mode leaves data other than keys in the clear
+	keysDir := t.TempDir()
+	keys, err := atrest.Open(keysDir, &atrest.Config{Mode: atrest.ModeKeys, KeyCommand: "echo " + strings.Repeat("ab", 32)})
+	if err != nil {
+		t.Fatalf("Open with a key command failed: %v", err)
+	}
+	if keys.Keys() == nil || keys.Data() != nil || c.Data() == nil {
+		t.Error("Unexpected ciphers for the modes")
+	}
+	if _, err := atrest.Open(keysDir, &atrest.Config{Mode: atrest.ModeKeys, KeyCommand: "echo " + strings.Repeat("cd", 32)}); !errors.Is(err, atrest.ErrWrongKey) {
+		t.Errorf("Expected ErrWrongKey from another key command, got %v", err)
+	}
+}
+
+// Test that the mempool journal and the embedded store only open with
+// their key
+func TestAtRestStores(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	c, err := atrest.Open(dir, &atrest.Config{Mode: atrest.ModeFull, Passphrase: "pw"})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	journal := filepath.Join(dir, mempool.DefaultJournalFile)
+	cfg := mempool.DefaultConfig()
+	cfg.JournalCipher = c.Data()
+	mp := mempool.NewMempool(cfg)
+	if _, err := mp.OpenJournal(journal, nil); err != nil {
+		t.Fatalf("OpenJournal failed: %v", err)
+	}
+	tx := newTestTx(1, 100)
+	if err := mp.Add(tx); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	mp.CloseJournal()
+
+	if _, err := mempool.NewMempool(nil).OpenJournal(journal, nil); !errors.Is(err, atrest.ErrEncrypted) {
+		t.Errorf("Expected ErrEncrypted replaying without a key, got %v", err)
+	}
+	restarted := mempool.NewMempool(cfg)
+	if n, err := restarted.OpenJournal(journal, nil); err != nil || n != 1 || !restarted.Has(tx.TxHash) {
+		t.Errorf("Encrypted replay restored %d, %v", n, err)
+	}
+	restarted.CloseJournal()
+
+	db := filepath.Join(dir, "db")
+	store, err := storage.Open(ctx, &storage.Config{Backend: storage.BackendPebble, Dir: db, Cipher: c.Data()})
+	if err != nil {
+		t.Fatalf("Open store failed: %v", err)
+	}
+	genesis := &types.Block{Header: &types.BlockHeader{Hash: types.Hash{1}, Timestamp: 100}}
+	if err := store.SaveBlock(ctx, genesis); err != nil {
+		t.Fatalf("SaveBlock failed: %v", err)
+	}
+	store.Close()
+
+	if _, err := storage.Open(ctx, &storage.Config{Backend: storage.BackendPebble, Dir: db}); !errors.Is(err, atrest.ErrEncrypted) {
+		t.Errorf("Expected ErrEncrypted opening without a key, got %v", err)
+	}
+	store, err = storage.Open(ctx, &storage.Config{Backend: storage.BackendPebble, Dir: db, Cipher: c.Data()})
+	if err != nil {
+		t.Fatalf("Reopen store failed: %v", err)
+	}
+	if h, err := store.GetBlockHeader(ctx, genesis.Header.Hash); err != nil || h.Timestamp != 100 {
+		t.Errorf("GetBlockHeader returned %v, %v", h, err)
+	}
+	store.Close()
+
+	// A store created in the clear is not encrypted in place
+	clear := filepath.Join(dir, "clear")
+	store, err = storage.Open(ctx, &storage.Config{Backend: storage.BackendPebble, Dir: clear})
+	if err != nil {
+		t.Fatalf("Open clear store failed: %v", err)
+	}
+	store.SaveBlock(ctx, genesis)
+	store.Close()
+	if _, err := storage.Open(ctx, &storage.Config{Backend: storage.BackendPebble, Dir: clear, Cipher: c.Data()}); !errors.Is(err, atrest.ErrNotEncrypted) {
+		t.Errorf("Expected ErrNotEncrypted, got %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+
+	"github.com/ccoin/core/internal/p2p"
+)
+
+// Test subnet bans, expiry and that the ban file and export round-trip
+func TestBanManager(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+	bans, err := p2p.NewBanManager(path)
+	if err != nil {
+		t.Fatalf("NewBanManager failed: %v", err)
+	}
+
+	added, err := bans.Ban(
+		p2p.Ban{Target: "10.1.0.0/16", Reason: "spam"},
+		p2p.Ban{Target: "2001:db8::1"},
+		p2p.Ban{Target: "192.0.2.1", Expires: time.Now().Add(-time.Minute).Unix()},
+	)
+	if err != nil || added != 3 {
+		t.Fatalf("Ban added %d: %v", added, err)
+	}
+	if _, err := bans.Ban(p2p.Ban{Target: "not-a-target"}); err == nil {
+		t.Error("Expected an invalid target to be rejected")
+	}
+
+	for addr, want := range map[string]bool{
+		"/ip4/10.1.2.3/tcp/9000":     true,
+		"/ip4/10.2.0.1/tcp/9000":     false,
+		"/ip6/2001:db8::1/tcp/9000":  true,
+		"/ip4/192.0.2.1/tcp/9000":    false, // expired
+		"/dns4/example.com/tcp/9000": false,
+	} {
+		if got := bans.IsAddrBanned(multiaddr.StringCast(addr)); got != want {
+			t.Errorf("IsAddrBanned(%s) = %v, want %v", addr, got, want)
+		}
+	}
+
+	// Reloading keeps the bans in force
+	reloaded, err := p2p.NewBanManager(path)
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	list := reloaded.Bans()
+	if len(list) != 2 || list[0].Target != "10.1.0.0/16" || list[0].Source != p2p.BanSourceLocal {
+		t.Fatalf("Unexpected bans after reload: %+v", list)
+	}
+
+	var buf bytes.Buffer
+	if err := p2p.WriteBanList(&buf, list); err != nil {
+		t.Fatalf("WriteBanList failed: %v", err)
+	}
+	read, err := p2p.ReadBanList(&buf)
+	if err != nil || len(read) != 2 || read[1].Target != "2001:db8::1" {
+		t.Errorf("Unexpected ban list %+v: %v", read, err)
+	}
+
+	if removed, _ := reloaded.Unban("10.1.0.0/16"); !removed {
+		t.Error("Expected the subnet ban to be removed")
+	}
+	if reloaded.IsAddrBanned(multiaddr.StringCast("/ip4/10.1.2.3/tcp/9000")) {
+		t.Error("Address still banned after Unban")
+	}
+}
+
+// Test that feeds must be signed and newer than the last one ingested,
+// and that they replace their own earlier bans but not local ones
+func TestBanFeed(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	bans, _ := p2p.NewBanManager("")
+	bans.Ban(p2p.Ban{Target: "198.51.100.7", Reason: "local"})
+
+	feed := &p2p.BanFeed{Issued: 100, Bans: []p2p.Ban{{Target: "198.51.100.7"}, {Target: "203.0.113.0/24"}}}
+	feed.Sign(priv)
+
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	if _, err := bans.IngestFeed("community", otherPub, feed); err != p2p.ErrBanFeedSignature {
+		t.Errorf("Expected ErrBanFeedSignature, got %v", err)
+	}
+	if n, err := bans.IngestFeed("community", pub, feed); err != nil || n != 2 {
+		t.Fatalf("IngestFeed ingested %d: %v", n, err)
+	}
+	if _, err := bans.IngestFeed("community", pub, feed); err != p2p.ErrBanFeedStale {
+		t.Errorf("Expected ErrBanFeedStale, got %v", err)
+	}
+
+	list := bans.Bans()
+	if len(list) != 2 || list[0].Source != p2p.BanSourceLocal || list[1].Source != "community" {
+		t.Fatalf("Unexpected bans %+v", list)
+	}
+
+	// A newer feed drops the subnet it no longer lists
+	next := &p2p.BanFeed{Issued: 200, Bans: []p2p.Ban{{Target: "198.51.100.8"}}}
+	next.Sign(priv)
+	if _, err := bans.IngestFeed("community", pub, next); err != nil {
+		t.Fatalf("IngestFeed failed: %v", err)
+	}
+	if bans.IsAddrBanned(multiaddr.StringCast("/ip4/203.0.113.9/tcp/9000")) {
+		t.Error("Subnet from the earlier feed still banned")
+	}
+	if !bans.IsAddrBanned(multiaddr.StringCast("/ip4/198.51.100.7/tcp/9000")) {
+		t.Error("Local ban lost to the feed")
+	}
+}
+
+// Test that misbehaving peers lose score and are banned at the threshold,
+// and that the ban outlives the node
+func TestPeerScoring(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+	bans, _ := p2p.NewBanManager(path)
+	cfg := p2p.DefaultScoringConfig()
+	cfg.HalfLife = 0
+	scorer := p2p.NewPeerScorer(cfg, bans, nil)
+
+	key, _, _ := crypto.GenerateEd25519Key(rand.Reader)
+	id, _ := peer.IDFromPrivateKey(key)
+
+	for i := 0; i < 30; i++ {
+		scorer.Reward(id)
+	}
+	if got := scorer.Score(id); got != cfg.MaxScore {
+		t.Errorf("Score after rewards = %v, want cap %v", got, cfg.MaxScore)
+	}
+
+	bad := p2p.Misbehavior{Topic: p2p.BlockTopic, Reason: "invalid block"}
+	for i := 0; i < 4; i++ {
+		scorer.Penalize(id, bad)
+	}
+	if got := scorer.Score(id); got != -80 || bans.IsPeerBanned(id) {
+		t.Fatalf("Score %v, banned %v before the threshold", got, bans.IsPeerBanned(id))
+	}
+	scorer.Penalize(id, bad)
+	if !bans.IsPeerBanned(id) {
+		t.Fatal("Peer not banned at the threshold")
+	}
+	if got := scorer.Score(id); got != 0 {
+		t.Errorf("Score %v after ban, want reset to 0", got)
+	}
+
+	reloaded, err := p2p.NewBanManager(path)
+	if err != nil || !reloaded.IsPeerBanned(id) {
+		t.Fatalf("Ban not persisted: %v", err)
+	}
+	ban := reloaded.Bans()[0]
+	if ban.Expires == 0 || ban.Expires > time.Now().Add(cfg.BanDuration).Unix()+1 {
+		t.Errorf("Unexpected ban expiry in %+v", ban)
+	}
+}
@@ -0,0 +1,214 @@
+// Package tests provides tests for committee selection and its audit log.
+package tests
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/ccoin/core/internal/aicommons"
+	"github.com/ccoin/core/internal/committee"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// staticBeacon derives each epoch's beacon from a fixed seed
+type staticBeacon struct {
+	seed types.Hash
+}
+
+func (b *staticBeacon) Beacon(ctx context.Context, epoch uint64) (types.Hash, error) {
+	return committee.DeriveBeacon(epoch, []types.Hash{b.seed}), nil
+}
+
+// staticCandidates returns the same snapshot every epoch
+type staticCandidates []types.CommitteeCandidate
+
+func (s staticCandidates) CommitteeCandidates(ctx context.Context, epoch uint64) ([]types.CommitteeCandidate, error) {
+	return s, nil
+}
+
+// Test weighted selection from a beacon
+func TestCommitteeSelection(t *testing.T) {
+	candidates := []types.CommitteeCandidate{
+		{Address: types.Address{0x01}, Stake: 1000, Reputation: 0.9},
+		{Address: types.Address{0x02}, Stake: 2000, Reputation: 0.5},
+		{Address: types.Address{0x03}, Stake: 500, Reputation: 1.0},
+		{Address: types.Address{0x04}, Stake: 5000, Reputation: 0},
+		{Address: types.Address{0x05}, Stake: 0, Reputation: 1.0},
+		{Address: types.Address{0x06}, Stake: 800, Reputation: 0.7},
+	}
+	beacon := committee.DeriveBeacon(3, []types.Hash{{0xaa}, {0xbb}})
+
+	record := committee.Select(types.CommitteeEvaluator, 3, beacon, candidates, 3)
+	if len(record.Members) != 3 {
+		t.Fatalf("Expected 3 members, got %d", len(record.Members))
+	}
+	seen := make(map[types.Address]bool)
+	for _, m := range record.Members {
+		if seen[m] {
+			t.Errorf("Member %x drawn twice", m)
+		}
+		seen[m] = true
+	}
+	if err := committee.Verify(record); err != nil {
+		t.Errorf("Verify failed: %v", err)
+	}
+
+	// Candidate order does not matter, nor does the order of the
+	// blocks behind the beacon
+	reversed := make([]types.CommitteeCandidate, len(candidates))
+	for i, c := range candidates {
+		reversed[len(candidates)-1-i] = c
+	}
+	again := committee.Select(types.CommitteeEvaluator, 3, committee.DeriveBeacon(3, []types.Hash{{0xbb}, {0xaa}}), reversed, 3)
+	if again.Hash() != record.Hash() {
+		t.Error("Selection depends on candidate or block order")
+	}
+
+	// Candidates without weight are never drawn, even when seats remain
+	all := committee.Select(types.CommitteeEvaluator, 3, beacon, candidates, len(candidates))
+	if len(all.Members) != 4 {
+		t.Errorf("Expected 4 weighted members, got %d", len(all.Members))
+	}
+	for _, m := range all.Members {
+		if m == (types.Address{0x04}) || m == (types.Address{0x05}) {
+			t.Errorf("Unweighted candidate %x was drawn", m)
+		}
+	}
+
+	// Forged members fail verification; a changed snapshot no longer
+	// matches the committed record
+	forged := *record
+	forged.Members = append([]types.Address(nil), record.Members...)
+	forged.Members[0] = types.Address{0x04}
+	if err := committee.Verify(&forged); err != committee.ErrSelectionMismatch {
+		t.Errorf("Expected ErrSelectionMismatch for forged members, got %v", err)
+	}
+	forged = *record
+	forged.Candidates = append([]types.CommitteeCandidate(nil), record.Candidates...)
+	forged.Candidates[0].Stake *= 10
+	if forged.Hash() == record.Hash() {
+		t.Error("Changing the snapshot did not change the record hash")
+	}
+}
+
+// Test recording, auditing and persisting rotations
+func TestCommitteeAuditLog(t *testing.T) {
+	ctx := context.Background()
+	beacon := &staticBeacon{seed: types.Hash{0x42}}
+	store := committee.NewInMemoryStore()
+	candidates := staticCandidates{
+		{Address: types.Address{0x01}, Stake: 100, Reputation: 1},
+		{Address: types.Address{0x02}, Stake: 100, Reputation: 1},
+		{Address: types.Address{0x03}, Stake: 100, Reputation: 1},
+	}
+
+	log := committee.NewAuditLog(beacon, store)
+	log.Register(types.CommitteeEvaluator, 2, 2, candidates)
+
+	if _, err := log.Audit(ctx, types.CommitteeEvaluator, 4); err != committee.ErrUnknownCommittee {
+		t.Errorf("Expected ErrUnknownCommittee before rotation, got %v", err)
+	}
+
+	root, err := log.Root(ctx, 4)
+	if err != nil {
+		t.Fatalf("Root failed: %v", err)
+	}
+	if root.IsEmpty() {
+		t.Fatal("Rotation epoch has an empty root")
+	}
+	if again, _ := log.Root(ctx, 4); again != root {
+		t.Error("Root changed between calls")
+	}
+	if off, _ := log.Root(ctx, 5); !off.IsEmpty() {
+		t.Error("Epoch without a rotation has a root")
+	}
+
+	// The committee serving epoch 5 is the one drawn at 4
+	record, err := log.Committee(ctx, types.CommitteeEvaluator, 5)
+	if err != nil {
+		t.Fatalf("Committee failed: %v", err)
+	}
+	if record.Epoch != 4 || len(record.Members) != 2 {
+		t.Errorf("Unexpected record: epoch %d, %d members", record.Epoch, len(record.Members))
+	}
+
+	audit, err := log.Audit(ctx, types.CommitteeEvaluator, 5)
+	if err != nil {
+		t.Fatalf("Audit failed: %v", err)
+	}
+	if !audit.BeaconValid || !audit.SelectionValid {
+		t.Errorf("Audit failed: beacon %t, selection %t", audit.BeaconValid, audit.SelectionValid)
+	}
+	if audit.Root != root || audit.RecordHash != record.Hash() {
+		t.Error("Audit does not match the recorded rotation")
+	}
+
+	// A restarted log reads the same records back instead of drawing again
+	restarted := committee.NewAuditLog(beacon, store)
+	restarted.Register(types.CommitteeEvaluator, 2, 2, staticCandidates{})
+	if r, _ := restarted.Root(ctx, 4); r != root {
+		t.Error("Restarted log produced a different root")
+	}
+
+	// A recorded beacon that no longer matches the chain fails the audit
+	moved := committee.NewAuditLog(&staticBeacon{seed: types.Hash{0x43}}, store)
+	moved.Register(types.CommitteeEvaluator, 2, 2, candidates)
+	audit, err = moved.Audit(ctx, types.CommitteeEvaluator, 4)
+	if err != nil {
+		t.Fatalf("Audit failed: %v", err)
+	}
+	if audit.BeaconValid || !audit.SelectionValid {
+		t.Errorf("Expected only the beacon check to fail: beacon %t, selection %t", audit.BeaconValid, audit.SelectionValid)
+	}
+}
+
+// Test drawing the evaluator active set through the audit log
+func TestEvaluatorCommitteeLog(t *testing.T) {
+	ctx := context.Background()
+	cfg := aicommons.DefaultEvaluatorConfig()
+	cfg.ActiveSetSize = 2
+	cfg.RotationPeriod = 1
+	registry := aicommons.NewEvaluatorRegistry(nil, cfg)
+
+	for i := 0; i < 4; i++ {
+		addr := types.Address{byte(i + 1)}
+		pub, _, _ := ed25519.GenerateKey(nil)
+		if err := registry.Register(ctx, addr, pub, cfg.MinBond*uint64(i+1), 1); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		proposal := &types.Proposal{
+			Type:   types.ProposalEvaluatorAdmission,
+			Status: types.ProposalStatusPassed,
+			Data:   &types.EvaluatorAdmissionData{Evaluator: addr, PublicKey: pub},
+		}
+		if err := registry.ApplyProposal(ctx, proposal); err != nil {
+			t.Fatalf("ApplyProposal failed: %v", err)
+		}
+	}
+
+	log := committee.NewAuditLog(&staticBeacon{seed: types.Hash{0x07}}, committee.NewInMemoryStore())
+	registry.SetCommitteeLog(log)
+
+	active := registry.Rotate(3)
+	record, err := log.Committee(ctx, types.CommitteeEvaluator, 3)
+	if err != nil {
+		t.Fatalf("Committee failed: %v", err)
+	}
+	if len(active) != 2 || len(record.Candidates) != 4 {
+		t.Fatalf("Expected 2 of 4 evaluators, got %d of %d", len(active), len(record.Candidates))
+	}
+	for i := range active {
+		if active[i] != record.Members[i] {
+			t.Errorf("Active set does not follow the recorded selection")
+		}
+	}
+
+	audit, err := log.Audit(ctx, types.CommitteeEvaluator, 3)
+	if err != nil {
+		t.Fatalf("Audit failed: %v", err)
+	}
+	if !audit.SelectionValid {
+		t.Error("Recorded evaluator selection did not verify")
+	}
+}
@@ -0,0 +1,235 @@
+package tests
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ccoin/core/internal/config"
+)
+
+// Test that TOML and YAML files yield the same settings, whether flat or
+// grouped into tables
+func TestConfigParse(t *testing.T) {
+	toml := `# node settings
+network = "mainnet"
+prune = 1000 # heights
+bootstrap = ["/ip4/1.2.3.4/tcp/9000/p2p/a", "/ip4/5.6.7.8/tcp/9000/p2p/b"]
+
+[db]
+host = 'db.internal'
+max_size = 5
+
+[log]
+level = "info,p2p=debug"
+json = true
+`
+	yaml := `# node settings
+network: mainnet
+prune: 1000 # heights
+bootstrap:
+  - /ip4/1.2.3.4/tcp/9000/p2p/a
+  - "/ip4/5.6.7.8/tcp/9000/p2p/b"
+db:
+  host: 'db.internal'
+  max_size: 5
+log:
+  level: "info,p2p=debug"
+  json: true
+`
+	want := map[string]string{
+		"network":     "mainnet",
+		"prune":       "1000",
+		"bootstrap":   "/ip4/1.2.3.4/tcp/9000/p2p/a,/ip4/5.6.7.8/tcp/9000/p2p/b",
+		"db-host":     "db.internal",
+		"db-max-size": "5",
+		"log-level":   "info,p2p=debug",
+		"log-json":    "true",
+	}
+
+	for format, data := range map[config.Format]string{config.FormatTOML: toml, config.FormatYAML: yaml} {
+		settings, err := config.Parse([]byte(data), format)
+		if err != nil {
+			t.Fatalf("%s: Parse failed: %v", format, err)
+		}
+		got := make(map[string]string)
+		for _, s := range settings {
+			got[s.Name] = s.Value
+		}
+		if len(got) != len(want) {
+			t.Errorf("%s: expected %d settings, got %v", format, len(want), got)
+		}
+		for name, value := range want {
+			if got[name] != value {
+				t.Errorf("%s: %s = %q, want %q", format, name, got[name], value)
+			}
+		}
+	}
+
+	if _, err := config.Parse([]byte("network mainnet\n"), config.FormatTOML); !errors.Is(err, config.ErrSyntax) {
+		t.Errorf("Expected ErrSyntax, got %v", err)
+	}
+	if _, err := config.Parse([]byte("network = \"mainnet\n"), config.FormatTOML); !errors.Is(err, config.ErrSyntax) {
+		t.Errorf("Expected ErrSyntax for an unterminated string, got %v", err)
+	}
+}
+
+// Test that flags override the environment, which overrides the config
+// file, which overrides the defaults
+func TestConfigPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, config.DefaultFile), []byte(`
+db-host = "from-file"
+db-port = 6432
+db-name = "from-file"
+network = "from-file"
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("ccoind", flag.ContinueOnError)
+	host := fs.String("db-host", "localhost", "")
+	port := fs.Int("db-port", 5432, "")
+	name := fs.String("db-name", "ccoin", "")
+	network := fs.String("network", "testnet", "")
+	expiry := fs.Duration("mempool-expiry", time.Hour, "")
+	fs.String("config", "", "")
+	fs.String("data-dir", "", "")
+	if err := fs.Parse([]string{"-data-dir", dir, "-db-host", "from-flag"}); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{"CCOIN_DB_NAME": "from-env", "CCOIN_DB_HOST": "from-env"}
+	if err := config.ApplyEnv(fs, func(k string) (string, bool) { v, ok := env[k]; return v, ok }); err != nil {
+		t.Fatalf("ApplyEnv failed: %v", err)
+	}
+	path, err := config.Find("", dir)
+	if err != nil || path == "" {
+		t.Fatalf("Expected to find the default config file, got %q: %v", path, err)
+	}
+	if err := config.ApplyFile(fs, path); err != nil {
+		t.Fatalf("ApplyFile failed: %v", err)
+	}
+
+	if *host != "from-flag" || *name != "from-env" || *network != "from-file" || *port != 6432 || *expiry != time.Hour {
+		t.Errorf("Wrong precedence: host=%s name=%s network=%s port=%d expiry=%s", *host, *name, *network, *port, *expiry)
+	}
+
+	// Unknown settings and bad values name the file and line
+	bad := filepath.Join(dir, "bad.toml")
+	os.WriteFile(bad, []byte("network = \"x\"\ndb-hots = \"y\"\n"), 0600)
+	if err := config.ApplyFile(fs, bad); !errors.Is(err, config.ErrUnknownSetting) || !strings.Contains(err.Error(), "bad.toml:2") {
+		t.Errorf("Expected unknown setting at bad.toml:2, got %v", err)
+	}
+	fresh := flag.NewFlagSet("ccoind", flag.ContinueOnError)
+	fresh.Int("db-port", 5432, "")
+	os.WriteFile(bad, []byte("db-port = \"five\"\n"), 0600)
+	if err := config.ApplyFile(fresh, bad); !errors.Is(err, config.ErrInvalidConfig) {
+		t.Errorf("Expected ErrInvalidConfig, got %v", err)
+	}
+	if err := config.ApplyEnv(fresh, func(string) (string, bool) { return "x", true }); !errors.Is(err, config.ErrInvalidConfig) {
+		t.Errorf("Expected ErrInvalidConfig from the environment, got %v", err)
+	}
+}
+
+// Test that the annotated default config reads back as the node's
+// defaults and that Validate catches bad values
+func TestConfigDefaults(t *testing.T) {
+	for _, format := range []config.Format{config.FormatTOML, config.FormatYAML} {
+		var node config.Node
+		fs := flag.NewFlagSet("ccoind", flag.ContinueOnError)
+		node.RegisterFlags(fs)
+
+		var b strings.Builder
+		if err := config.WriteDefaults(&b, fs, format, "defaults"); err != nil {
+			t.Fatalf("%s: WriteDefaults failed: %v", format, err)
+		}
+		if !strings.Contains(b.String(), "# PostgreSQL host\n") {
+			t.Errorf("%s: expected settings annotated with their usage", format)
+		}
+
+		settings, err := config.Parse([]byte(b.String()), format)
+		if err != nil {
+			t.Fatalf("%s: defaults do not parse: %v", format, err)
+		}
+		n := 0
+		fs.VisitAll(func(f *flag.Flag) { n++ })
+		if len(settings) != n {
+			t.Errorf("%s: expected %d settings, got %d", format, n, len(settings))
+		}
+		for _, s := range settings {
+			if f := fs.Lookup(s.Name); f == nil || f.DefValue != s.Value {
+				t.Errorf("%s: %s = %q does not match its default", format, s.Name, s.Value)
+			}
+		}
+		if err := node.Validate(); err != nil {
+			t.Errorf("%s: defaults are invalid: %v", format, err)
+		}
+	}
+
+	var node config.Node
+	fs := flag.NewFlagSet("ccoind", flag.ContinueOnError)
+	node.RegisterFlags(fs)
+	fs.Set("db-backend", "sqlite")
+	fs.Set("log-level", "loud")
+	fs.Set("rpc", "9001")
+	err := node.Validate()
+	if !errors.Is(err, config.ErrInvalidConfig) {
+		t.Fatalf("Expected ErrInvalidConfig, got %v", err)
+	}
+	for _, name := range []string{"db-backend", "log-level", "rpc"} {
+		if !strings.Contains(err.Error(), name+":") {
+			t.Errorf("Expected %s to be reported in %v", name, err)
+		}
+	}
+}
+
+// Test that the network flag selects its preset's default ports without
+// overriding ports that were set, and that custom networks need an ID
+func TestConfigNetwork(t *testing.T) {
+	var node config.Node
+	fs := flag.NewFlagSet("ccoind", flag.ContinueOnError)
+	node.RegisterFlags(fs)
+	fs.String("config", "", "")
+	if err := fs.Parse([]string{"-data-dir", t.TempDir(), "-network", "devnet", "-rpc", "0.0.0.0:7000"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.Load(fs); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	devnet, _ := config.LookupNetwork(config.NetworkDevnet)
+	if node.ListenAddr != "/ip4/0.0.0.0/tcp/19000" || node.RPCAddr != "0.0.0.0:7000" || node.JSONRPCAddr != "127.0.0.1:19002" {
+		t.Errorf("Wrong devnet ports: listen=%s rpc=%s jsonrpc=%s", node.ListenAddr, node.RPCAddr, node.JSONRPCAddr)
+	}
+	if params := node.NetworkParams(); params.ID != devnet.ID || !params.Genesis.IsEmpty() {
+		t.Errorf("Expected the devnet preset, got %+v", params)
+	}
+
+	fs.Set("network", config.NetworkCustom)
+	if err := node.Validate(); err == nil || !strings.Contains(err.Error(), "network-id:") {
+		t.Errorf("Expected a custom network without an ID to be invalid, got %v", err)
+	}
+	genesis := strings.Repeat("ab", 32)
+	fs.Set("network-id", "4242")
+	fs.Set("genesis-hash", "0x"+genesis)
+	if err := node.Validate(); err != nil {
+		t.Fatalf("Custom network is invalid: %v", err)
+	}
+	params := node.NetworkParams()
+	if params.ID != 4242 || params.Genesis.String() != genesis {
+		t.Errorf("Expected network 4242 pinned to %s, got %+v", genesis, params)
+	}
+
+	fs.Set("network", "moonnet")
+	fs.Set("genesis-hash", "abcd")
+	err := node.Validate()
+	for _, name := range []string{"network", "network-id", "genesis-hash"} {
+		if err == nil || !strings.Contains(err.Error(), name+":") {
+			t.Errorf("Expected %s to be reported in %v", name, err)
+		}
+	}
+}
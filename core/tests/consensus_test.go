@@ -0,0 +1,109 @@
+// Package tests provides integration tests for consensus.
+package tests
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ccoin/core/internal/consensus"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Mock miner store
+type mockMinerStore struct {
+	miners map[types.Address]*types.Miner
+}
+
+func newMockMinerStore() *mockMinerStore {
+	return &mockMinerStore{miners: make(map[types.Address]*types.Miner)}
+}
+
+func (s *mockMinerStore) GetMiner(ctx context.Context, addr types.Address) (*types.Miner, error) {
+	m, ok := s.miners[addr]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	copied := *m
+	return &copied, nil
+}
+
+func (s *mockMinerStore) SaveMiner(ctx context.Context, m *types.Miner) error {
+	copied := *m
+	s.miners[m.Address] = &copied
+	return nil
+}
+
+func (s *mockMinerStore) GetActiveMiners(ctx context.Context, epoch uint64) ([]*types.Miner, error) {
+	return nil, nil
+}
+
+func (s *mockMinerStore) UpdateReputations(ctx context.Context, epoch uint64) error {
+	return nil
+}
+
+func payoutBlock(miner, payout types.Address, height uint64, txs ...*types.Transaction) *types.Block {
+	return types.NewBlock(&types.BlockHeader{
+		MinerAddress:    miner,
+		PayoutAddress:   payout,
+		ReputationScore: types.InitialReputation,
+		QualityScore:    0.5,
+		Difficulty:      big.NewInt(1),
+		Height:          height,
+	}, txs)
+}
+
+// TestMinerPayoutAddress tests separating reward payouts from the
+// miner's identity address
+func TestMinerPayoutAddress(t *testing.T) {
+	ctx := context.Background()
+	store := newMockMinerStore()
+	c := consensus.NewConsensus(nil, store, nil)
+
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	miner := types.AddressFromPublicKey(pub)
+	cold := types.Address{0xc0, 0x1d}
+
+	// Until a change is registered, rewards go to the identity address
+	if err := c.ProcessBlock(ctx, payoutBlock(miner, cold, 1)); err != consensus.ErrPayoutMismatch {
+		t.Fatalf("expected ErrPayoutMismatch, got %v", err)
+	}
+	if err := c.ProcessBlock(ctx, payoutBlock(miner, miner, 1)); err != nil {
+		t.Fatalf("ProcessBlock failed: %v", err)
+	}
+
+	change := &types.PayoutChange{MinerKey: pub, Payout: cold, Nonce: 1}
+	digest := change.SigningHash()
+
+	// A change signed by another key is rejected
+	_, otherPriv, _ := ed25519.GenerateKey(nil)
+	change.Signature = ed25519.Sign(otherPriv, digest[:])
+	if err := c.ValidatePayoutChange(ctx, change); err != consensus.ErrInvalidPayoutChange {
+		t.Fatalf("expected ErrInvalidPayoutChange, got %v", err)
+	}
+
+	change.Signature = ed25519.Sign(priv, digest[:])
+	tx := &types.Transaction{PayoutChange: change}
+	if err := c.ProcessBlock(ctx, payoutBlock(miner, miner, 2, tx)); err != nil {
+		t.Fatalf("ProcessBlock with payout change failed: %v", err)
+	}
+
+	// The change waits out the delay before taking effect
+	active := 2 + uint64(types.PayoutChangeDelay)
+	if got := c.PayoutAddress(ctx, miner, active-1); got != miner {
+		t.Errorf("payout before activation = %x, want identity address", got)
+	}
+	if got := c.PayoutAddress(ctx, miner, active); got != cold {
+		t.Errorf("payout at activation = %x, want cold address", got)
+	}
+	if err := c.ProcessBlock(ctx, payoutBlock(miner, cold, active)); err != nil {
+		t.Fatalf("ProcessBlock paying cold address failed: %v", err)
+	}
+
+	// Replaying the same change is rejected
+	if err := c.ValidatePayoutChange(ctx, change); err != consensus.ErrStalePayoutChange {
+		t.Errorf("expected ErrStalePayoutChange, got %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+// Package tests provides tests for credential authorities and identity
+// disclosures.
+package tests
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+
+	"github.com/ccoin/core/internal/credentials"
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// passedProposal wraps proposal data as a passed proposal
+func passedProposal(id byte, pt types.ProposalType, data types.ProposalData) *types.Proposal {
+	return &types.Proposal{
+		ProposalID: types.Hash{id},
+		Type:       pt,
+		Status:     types.ProposalStatusPassed,
+		Data:       data,
+	}
+}
+
+// Test issuing credentials and governing authorities and revocations
+func TestCredentialRegistry(t *testing.T) {
+	ctx := context.Background()
+	store := credentials.NewInMemoryStore()
+	registry := credentials.NewRegistry(store)
+
+	key, err := zkp.GenerateAuthorityKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateAuthorityKey failed: %v", err)
+	}
+	holder := zkp.HolderID([]byte("holder-secret"))
+	serial := types.Address{0x5e}
+	cred, err := key.Issue(serial, holder)
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	if err := zkp.VerifyCredential(cred); err != nil {
+		t.Fatalf("Issued credential rejected: %v", err)
+	}
+	forged := *cred
+	forged.Serial = types.Address{0x5f}
+	if err := zkp.VerifyCredential(&forged); err != zkp.ErrInvalidCredential {
+		t.Errorf("Expected ErrInvalidCredential, got %v", err)
+	}
+
+	// Keys off the curve are refused
+	var bad types.Hash
+	for i := range bad {
+		bad[i] = 0xff
+	}
+	invalid := passedProposal(1, types.ProposalIdentityAuthority, &types.IdentityAuthorityData{PublicKey: bad, Name: "Bad"})
+	if err := registry.ApplyProposal(ctx, invalid); err != zkp.ErrInvalidAuthorityKey {
+		t.Errorf("Expected ErrInvalidAuthorityKey, got %v", err)
+	}
+
+	register := passedProposal(2, types.ProposalIdentityAuthority, &types.IdentityAuthorityData{
+		PublicKey: key.PublicKey(), Name: "Regulator", Domain: "EU",
+	})
+	if err := registry.ApplyProposal(ctx, register); err != nil {
+		t.Fatalf("ApplyProposal failed: %v", err)
+	}
+	if a, ok := registry.Authority(key.PublicKey()); !ok || a.Domain != "EU" {
+		t.Errorf("Authority not registered: %+v", a)
+	}
+
+	// Disclosures are checked against the registry
+	dm := zkp.NewDisclosureManager(nil)
+	dm.SetCredentialRegistry(registry)
+	if _, ok := dm.Authority(key.PublicKey()); !ok {
+		t.Error("Disclosure manager does not see the governed authority")
+	}
+	unknown := &zkp.IdentityDisclosure{AuthorityPubKey: types.Hash{0xa1}, RevocationRoot: registry.RevocationRoot()}
+	if err := dm.VerifyIdentityDisclosure(ctx, unknown); err != zkp.ErrUnknownAuthority {
+		t.Errorf("Expected ErrUnknownAuthority, got %v", err)
+	}
+
+	root, path, err := registry.Prove(serial)
+	if err != nil {
+		t.Fatalf("Prove failed: %v", err)
+	}
+	if !zkp.VerifyNonMembership(root, serial, path) {
+		t.Error("Non-revocation path does not verify")
+	}
+
+	// A revocation must produce the root voters approved
+	wrong := passedProposal(3, types.ProposalCredentialRevocation, &types.CredentialRevocationData{
+		Revoke: []types.Address{serial}, Root: root,
+	})
+	if err := registry.ApplyProposal(ctx, wrong); err != credentials.ErrRootMismatch {
+		t.Errorf("Expected ErrRootMismatch, got %v", err)
+	}
+	revoke := passedProposal(4, types.ProposalCredentialRevocation, &types.CredentialRevocationData{
+		Revoke: []types.Address{serial},
+		Root:   registry.PreviewRevocation([]types.Address{serial}, nil),
+	})
+	if err := registry.ApplyProposal(ctx, revoke); err != nil {
+		t.Fatalf("ApplyProposal failed: %v", err)
+	}
+	if !registry.IsRevoked(serial) {
+		t.Error("Credential not revoked")
+	}
+	if _, _, err := registry.Prove(serial); err != zkp.ErrCredentialRevoked {
+		t.Errorf("Expected ErrCredentialRevoked, got %v", err)
+	}
+
+	// Disclosures made before the revocation are stale
+	stale := &zkp.IdentityDisclosure{AuthorityPubKey: key.PublicKey(), RevocationRoot: root}
+	if err := dm.VerifyIdentityDisclosure(ctx, stale); err != zkp.ErrRevocationRootMismatch {
+		t.Errorf("Expected ErrRevocationRootMismatch, got %v", err)
+	}
+
+	// Authorities and revocations persist across restarts
+	restarted := credentials.NewRegistry(store)
+	if err := restarted.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if restarted.RevocationRoot() != registry.RevocationRoot() || len(restarted.Authorities()) != 1 {
+		t.Error("Restarted registry differs")
+	}
+
+	remove := passedProposal(5, types.ProposalIdentityAuthority, &types.IdentityAuthorityData{
+		PublicKey: key.PublicKey(), Remove: true,
+	})
+	if err := registry.ApplyProposal(ctx, remove); err != nil {
+		t.Fatalf("ApplyProposal failed: %v", err)
+	}
+	if _, ok := registry.Authority(key.PublicKey()); ok {
+		t.Error("Removed authority still registered")
+	}
+}
+
+// Test proving and verifying an identity disclosure
+func TestIdentityDisclosure(t *testing.T) {
+	ctx := context.Background()
+	cm := zkp.NewCircuitManager()
+	if err := cm.CompileIdentityCircuit(); err != nil {
+		t.Fatalf("Failed to compile identity circuit: %v", err)
+	}
+	dm := zkp.NewDisclosureManager(cm)
+	registry := credentials.NewRegistry(credentials.NewInMemoryStore())
+	dm.SetCredentialRegistry(registry)
+
+	key, _ := zkp.GenerateAuthorityKey(rand.Reader)
+	register := passedProposal(1, types.ProposalIdentityAuthority, &types.IdentityAuthorityData{
+		PublicKey: key.PublicKey(), Name: "Regulator", Domain: "EU",
+	})
+	if err := registry.ApplyProposal(ctx, register); err != nil {
+		t.Fatalf("ApplyProposal failed: %v", err)
+	}
+
+	secret := []byte("holder-secret")
+	serial := types.Address{0x5e}
+	cred, _ := key.Issue(serial, zkp.HolderID(secret))
+	root, path, err := registry.Prove(serial)
+	if err != nil {
+		t.Fatalf("Prove failed: %v", err)
+	}
+
+	if _, err := dm.CreateIdentityDisclosure(ctx, cred, []byte("other-secret"), root, path, types.Hash{0x01}); err != zkp.ErrInvalidCredential {
+		t.Errorf("Expected ErrInvalidCredential for the wrong holder, got %v", err)
+	}
+
+	binding := types.Hash{0x01}
+	d, err := dm.CreateIdentityDisclosure(ctx, cred, secret, root, path, binding)
+	if err != nil {
+		t.Fatalf("CreateIdentityDisclosure failed: %v", err)
+	}
+	if err := dm.VerifyIdentityDisclosure(ctx, d); err != nil {
+		t.Fatalf("VerifyIdentityDisclosure failed: %v", err)
+	}
+
+	// The disclosure only counts on a transaction spending the bound note
+	tx := &types.Transaction{
+		Nullifiers:      []types.Hash{binding},
+		Disclosures:     []types.Disclosure{d.Disclosure()},
+		DisclosureFlags: uint32(zkp.FlagIdentityRequired),
+	}
+	if err := dm.ValidateDisclosures(ctx, tx, zkp.FlagIdentityRequired); err != nil {
+		t.Errorf("ValidateDisclosures failed: %v", err)
+	}
+	tx.Nullifiers = []types.Hash{{0x02}}
+	if err := dm.ValidateDisclosures(ctx, tx, zkp.FlagIdentityRequired); err == nil {
+		t.Error("Disclosure accepted on a transaction it is not bound to")
+	}
+
+	// A tampered commitment fails the proof
+	tampered := *d
+	tampered.CredentialCommitment[0] ^= 1
+	if err := dm.VerifyIdentityDisclosure(ctx, &tampered); err != zkp.ErrDisclosureProofInvalid {
+		t.Errorf("Expected ErrDisclosureProofInvalid, got %v", err)
+	}
+}
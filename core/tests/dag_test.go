@@ -0,0 +1,470 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ccoin/core/internal/dag"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// memDAGStore is an in-memory dag.Store
+type memDAGStore struct {
+	mu     sync.Mutex
+	blocks map[types.Hash]*types.Block
+	main   map[types.Hash]bool
+}
+
+func newMemDAGStore() *memDAGStore {
+	return &memDAGStore{blocks: make(map[types.Hash]*types.Block), main: make(map[types.Hash]bool)}
+}
+
+func (s *memDAGStore) GetBlock(ctx context.Context, hash types.Hash) (*types.Block, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok := s.blocks[hash]; ok {
+		return b, nil
+	}
+	return nil, dag.ErrBlockNotFound
+}
+
+func (s *memDAGStore) GetBlockHeader(ctx context.Context, hash types.Hash) (*types.BlockHeader, error) {
+	b, err := s.GetBlock(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	return b.Header, nil
+}
+
+func (s *memDAGStore) SaveBlock(ctx context.Context, block *types.Block) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blocks[block.Header.Hash] = block
+	return nil
+}
+
+func (s *memDAGStore) GetBlocksByHeight(ctx context.Context, height uint64) ([]*types.BlockHeader, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var headers []*types.BlockHeader
+	for _, b := range s.blocks {
+		if b.Header.Height == height {
+			headers = append(headers, b.Header)
+		}
+	}
+	return headers, nil
+}
+
+func (s *memDAGStore) GetChildren(ctx context.Context, hash types.Hash) ([]types.Hash, error) {
+	return nil, nil
+}
+
+func (s *memDAGStore) GetMainChain(ctx context.Context, fromHeight, toHeight uint64) ([]*types.BlockHeader, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var headers []*types.BlockHeader
+	for hash := range s.main {
+		if h := s.blocks[hash].Header; h.Height >= fromHeight && h.Height <= toHeight {
+			headers = append(headers, h)
+		}
+	}
+	sort.Slice(headers, func(i, j int) bool { return headers[i].Height < headers[j].Height })
+	return headers, nil
+}
+
+func (s *memDAGStore) UpdateMainChain(ctx context.Context, onChain, offChain []types.Hash) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, h := range offChain {
+		delete(s.main, h)
+	}
+	for _, h := range onChain {
+		s.main[h] = true
+	}
+	return nil
+}
+
+func (s *memDAGStore) GetTips(ctx context.Context) ([]types.Hash, error) {
+	return nil, nil
+}
+
+// testBlock builds a block with the given id and parents
+func testBlock(id byte, height uint64, parents ...types.Hash) *types.Block {
+	return &types.Block{Header: &types.BlockHeader{
+		Hash:            types.Hash{id},
+		Version:         1,
+		Parents:         parents,
+		Height:          height,
+		Difficulty:      big.NewInt(1000),
+		ReputationScore: 1.0,
+	}}
+}
+
+// chainBlock returns a block on parent mined by miner with a real hash.
+// commit, if not nil, fills in the header's roots before it is hashed.
+func chainBlock(t *testing.T, height uint64, parent types.Hash, miner types.Address, commit func(*types.Block) error, txs ...*types.Transaction) *types.Block {
+	t.Helper()
+	header := &types.BlockHeader{
+		Version:         1,
+		Parents:         []types.Hash{parent},
+		TxRoot:          dag.ComputeTxRoot(txs),
+		MinerAddress:    miner,
+		Height:          height,
+		Timestamp:       height,
+		Difficulty:      new(big.Int).Lsh(big.NewInt(1), 256),
+		ReputationScore: 1.0,
+	}
+	block := types.NewBlock(header, txs)
+	if commit != nil {
+		if err := commit(block); err != nil {
+			t.Fatalf("Failed to commit block %d: %v", height, err)
+		}
+	}
+	header.Hash = header.ComputeHash()
+	return block
+}
+
+// Test orphan buffering, parent requests and reconnection
+func TestOrphanManager(t *testing.T) {
+	ctx := context.Background()
+	d := dag.NewDAG(newMemDAGStore(), nil)
+	orphans := dag.NewOrphanManager(d, nil, &dag.OrphanConfig{MaxOrphans: 3, Expiry: time.Minute})
+
+	var requested []types.Hash
+	orphans.SetParentRequester(func(ctx context.Context, hashes []types.Hash) {
+		requested = append(requested, hashes...)
+	})
+
+	genesis := testBlock(1, 0)
+	a := testBlock(2, 1, genesis.Header.Hash)
+	b := testBlock(3, 2, a.Header.Hash)
+	c := testBlock(4, 3, b.Header.Hash, a.Header.Hash)
+
+	if _, err := orphans.ProcessBlock(ctx, genesis); err != nil {
+		t.Fatalf("ProcessBlock(genesis) failed: %v", err)
+	}
+
+	// Descendants arrive before their parent
+	for _, blk := range []*types.Block{c, b} {
+		if _, err := orphans.ProcessBlock(ctx, blk); err != dag.ErrOrphanBlock {
+			t.Fatalf("Expected ErrOrphanBlock, got %v", err)
+		}
+	}
+	if orphans.Len() != 2 {
+		t.Fatalf("Expected 2 orphans, got %d", orphans.Len())
+	}
+	if len(requested) == 0 || requested[len(requested)-1] != a.Header.Hash {
+		t.Errorf("Expected a request for the missing parent, got %v", requested)
+	}
+	if missing := orphans.Missing(ctx); len(missing) != 1 || missing[0] != a.Header.Hash {
+		t.Errorf("Expected only %s missing, got %v", a.Header.Hash, missing)
+	}
+
+	// The parent connects the whole chain in order
+	added, err := orphans.ProcessBlock(ctx, a)
+	if err != nil {
+		t.Fatalf("ProcessBlock(a) failed: %v", err)
+	}
+	if len(added) != 3 || added[0] != a || added[1] != b || added[2] != c {
+		t.Errorf("Expected a, b, c to connect in order, got %d blocks", len(added))
+	}
+	if orphans.Len() != 0 || !d.HasBlock(ctx, c.Header.Hash) {
+		t.Error("All orphans should be connected")
+	}
+
+	// The pool is bounded and evicts the oldest orphan
+	for i := byte(0); i < 4; i++ {
+		orphans.ProcessBlock(ctx, testBlock(0x10+i, 5, types.Hash{0xf0 + i}))
+		time.Sleep(time.Millisecond)
+	}
+	if orphans.Len() != 3 || orphans.Has(types.Hash{0x10}) {
+		t.Errorf("Expected the oldest orphan evicted, have %d", orphans.Len())
+	}
+
+	// Orphans expire
+	if n := orphans.Expire(time.Now().Add(2 * time.Minute)); n != 3 || orphans.Len() != 0 {
+		t.Errorf("Expected 3 orphans expired, got %d (%d left)", n, orphans.Len())
+	}
+}
+
+// Test GHOSTDAG coloring and the total ordering
+func TestGhostdagOrdering(t *testing.T) {
+	ctx := context.Background()
+	d := dag.NewDAG(newMemDAGStore(), &dag.Config{CacheSize: 100, MaxParents: 8, GhostdagK: 1})
+
+	// Three blocks mined in parallel on genesis, merged by d1. With K = 1
+	// only one of the side blocks fits in the blue set.
+	genesis := testBlock(1, 0)
+	a := testBlock(2, 1, genesis.Header.Hash)
+	b := testBlock(3, 1, genesis.Header.Hash)
+	c := testBlock(4, 1, genesis.Header.Hash)
+	d1 := testBlock(5, 2, a.Header.Hash, b.Header.Hash, c.Header.Hash)
+	e := testBlock(6, 3, d1.Header.Hash)
+
+	for _, blk := range []*types.Block{genesis, a, b, c, d1, e} {
+		if err := d.AddBlock(ctx, blk); err != nil {
+			t.Fatalf("AddBlock(%x) failed: %v", blk.Header.Hash[0], err)
+		}
+	}
+
+	data, err := d.GetGhostdagData(ctx, d1.Header.Hash)
+	if err != nil {
+		t.Fatalf("GetGhostdagData failed: %v", err)
+	}
+	// Equal work: the larger hash is selected and the merge set is ordered by hash
+	if data.SelectedParent != c.Header.Hash {
+		t.Errorf("Expected c as selected parent, got %x", data.SelectedParent[0])
+	}
+	if len(data.MergeSetBlues) != 2 || len(data.MergeSetReds) != 1 || data.MergeSetReds[0] != b.Header.Hash {
+		t.Errorf("Expected blues [c a] and reds [b], got %v / %v", data.MergeSetBlues, data.MergeSetReds)
+	}
+
+	// Side blocks add to the score, so d1 outweighs any single parent
+	parent, _ := d.GetGhostdagData(ctx, c.Header.Hash)
+	if data.BlueWork.Cmp(parent.BlueWork) <= 0 {
+		t.Error("Merged blue work should raise the score")
+	}
+	if data.BlueScore != 3 {
+		t.Errorf("Expected blue score 3, got %d", data.BlueScore)
+	}
+
+	ordered, err := d.GetOrderedBlocks(ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("GetOrderedBlocks failed: %v", err)
+	}
+	want := []types.Hash{genesis.Header.Hash, c.Header.Hash, a.Header.Hash, b.Header.Hash, d1.Header.Hash, e.Header.Hash}
+	if len(ordered) != len(want) {
+		t.Fatalf("Expected %d ordered blocks, got %d", len(want), len(ordered))
+	}
+	for i := range want {
+		if ordered[i] != want[i] {
+			t.Errorf("Position %d: expected %x, got %x", i, want[i][0], ordered[i][0])
+		}
+	}
+
+	// Ranges select a window of the same ordering
+	window, _ := d.GetOrderedBlocks(ctx, 2, 4)
+	if len(window) != 3 || window[0] != a.Header.Hash || window[2] != d1.Header.Hash {
+		t.Errorf("Unexpected window %v", window)
+	}
+	if d.GetMainChainTip() != e.Header.Hash {
+		t.Errorf("Expected e as main chain tip")
+	}
+}
+
+// tipsDAGStore is a memDAGStore with a fixed set of stored tips
+type tipsDAGStore struct {
+	*memDAGStore
+	tips []types.Hash
+}
+
+func (s *tipsDAGStore) GetTips(ctx context.Context) ([]types.Hash, error) {
+	return s.tips, nil
+}
+
+// Test that a restart picks the same main chain tip among tips of equal
+// score, the larger hash
+func TestInitializeTieBreak(t *testing.T) {
+	ctx := context.Background()
+	store := &tipsDAGStore{memDAGStore: newMemDAGStore()}
+	for id := byte(2); id <= 4; id++ {
+		tip := testBlock(id, 1, types.Hash{1})
+		tip.Header.CumulativeScore = big.NewFloat(5)
+		store.SaveBlock(ctx, tip)
+		store.tips = append(store.tips, tip.Header.Hash)
+	}
+
+	for i := 0; i < 20; i++ {
+		d := dag.NewDAG(store, nil)
+		if err := d.Initialize(ctx); err != nil {
+			t.Fatalf("Initialize failed: %v", err)
+		}
+		if tip := d.GetMainChainTip(); tip != (types.Hash{4}) {
+			t.Fatalf("Restart %d picked %x as main chain tip, want 04", i, tip[0])
+		}
+	}
+}
+
+// batchDAGStore is a memDAGStore that saves blocks in batches and can be
+// made to fail them
+type batchDAGStore struct {
+	*memDAGStore
+	batches int
+	fail    bool
+}
+
+func (s *batchDAGStore) BatchSaveBlocks(ctx context.Context, blocks []*types.Block) error {
+	if s.fail {
+		return errors.New("batch failed")
+	}
+	s.batches++
+	for _, block := range blocks {
+		s.SaveBlock(ctx, block)
+	}
+	return nil
+}
+
+// Test that a run of blocks is colored, saved in one batch and linked
+// into the DAG, and that a failed batch adds none of them
+func TestAddBlocksBatch(t *testing.T) {
+	ctx := context.Background()
+	store := &batchDAGStore{memDAGStore: newMemDAGStore()}
+	d := dag.NewDAG(store, nil)
+
+	genesis := testBlock(1, 0)
+	if err := d.AddBlock(ctx, genesis); err != nil {
+		t.Fatalf("AddBlock failed: %v", err)
+	}
+	a := testBlock(2, 1, genesis.Header.Hash)
+	b := testBlock(3, 1, genesis.Header.Hash)
+	c := testBlock(4, 2, a.Header.Hash, b.Header.Hash)
+
+	store.fail = true
+	if err := d.AddBlocks(ctx, []*types.Block{a, b, c}); err == nil {
+		t.Fatal("Expected the failed batch to be reported")
+	}
+	if d.HasBlock(ctx, a.Header.Hash) || d.GetHeight() != 0 {
+		t.Fatal("Blocks of a failed batch were added")
+	}
+
+	store.fail = false
+	if err := d.AddBlocks(ctx, []*types.Block{genesis, a, b, c}); err != nil {
+		t.Fatalf("AddBlocks failed: %v", err)
+	}
+	if store.batches != 1 || d.GetHeight() != 2 || d.GetMainChainTip() != c.Header.Hash {
+		t.Errorf("Unexpected DAG after batch: %d batches, height %d", store.batches, d.GetHeight())
+	}
+	if tips := d.GetTips(); len(tips) != 1 || tips[0] != c.Header.Hash {
+		t.Errorf("Unexpected tips %v", tips)
+	}
+
+	// A block whose parent is neither stored nor earlier in the batch
+	// is an orphan
+	orphan := testBlock(6, 4, types.Hash{5})
+	if err := d.AddBlocks(ctx, []*types.Block{orphan}); err != dag.ErrOrphanBlock {
+		t.Errorf("Expected ErrOrphanBlock, got %v", err)
+	}
+}
+
+// Test the checks gossip runs on a block before relaying it
+func TestCheckBlock(t *testing.T) {
+	tx := &types.Transaction{Version: 1, Fee: 10}
+	tx.TxHash = tx.ComputeHash()
+
+	build := func(mutate func(*types.Block)) *types.Block {
+		block := &types.Block{
+			Header: &types.BlockHeader{
+				Version:         1,
+				Parents:         []types.Hash{{1}},
+				Height:          1,
+				Difficulty:      new(big.Int).Lsh(big.NewInt(1), 256),
+				ReputationScore: 1.0,
+				QualityScore:    0.5,
+			},
+			Transactions: []*types.Transaction{tx},
+		}
+		block.Header.TxRoot = dag.ComputeTxRoot(block.Transactions)
+		if mutate != nil {
+			mutate(block)
+		}
+		block.Header.Hash = block.Header.ComputeHash()
+		return block
+	}
+
+	if err := dag.CheckBlock(build(nil)); err != nil {
+		t.Fatalf("Valid block failed the checks: %v", err)
+	}
+
+	rehashed := build(nil)
+	rehashed.Header.Hash[0] ^= 1
+	for _, c := range []struct {
+		name  string
+		block *types.Block
+		want  error
+	}{
+		{"no header", &types.Block{}, dag.ErrMissingHeader},
+		{"version", build(func(b *types.Block) { b.Header.Version = 2 }), dag.ErrInvalidBlockVersion},
+		{"hash", rehashed, dag.ErrBlockHashMismatch},
+		{"parents", build(func(b *types.Block) { b.Header.Parents = make([]types.Hash, types.MaxParents+1) }), dag.ErrTooManyParents},
+		{"reputation", build(func(b *types.Block) { b.Header.ReputationScore = 0 }), dag.ErrInvalidReputation},
+		{"quality", build(func(b *types.Block) { b.Header.QualityScore = 1.5 }), dag.ErrInvalidQualityScore},
+		{"difficulty", build(func(b *types.Block) { b.Header.Difficulty = big.NewInt(1) }), dag.ErrInvalidDifficulty},
+		{"tx root", build(func(b *types.Block) { b.Header.TxRoot = types.Hash{2} }), dag.ErrInvalidTxRoot},
+		{"tx hash", build(func(b *types.Block) {
+			b.Transactions = []*types.Transaction{{Version: 1, TxHash: types.Hash{3}}}
+			b.Header.TxRoot = dag.ComputeTxRoot(b.Transactions)
+		}), dag.ErrTxHashMismatch},
+	} {
+		if err := dag.CheckBlock(c.block); !errors.Is(err, c.want) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, err)
+		}
+	}
+}
+
+// fixedShielded is a shielded pool with fixed spent nullifiers and one
+// accepted anchor. Proofs of transactions paying fee 13 fail.
+type fixedShielded struct {
+	spent  map[types.Hash]bool
+	anchor types.Hash
+}
+
+func (s *fixedShielded) HasNullifiers(ctx context.Context, nullifiers []types.Hash) ([]bool, error) {
+	spent := make([]bool, len(nullifiers))
+	for i, nullifier := range nullifiers {
+		spent[i] = s.spent[nullifier]
+	}
+	return spent, nil
+}
+
+func (s *fixedShielded) AnchorAge(root types.Hash) (int, bool) {
+	return 0, root == s.anchor
+}
+
+func (s *fixedShielded) VerifyTransaction(ctx context.Context, tx *types.Transaction) error {
+	if tx.Fee == 13 {
+		return errors.New("proof failed")
+	}
+	return nil
+}
+
+// Test that block transactions are checked against the shielded pool
+func TestValidateShielded(t *testing.T) {
+	ctx := context.Background()
+	d := dag.NewDAG(newMemDAGStore(), nil)
+	genesis := testBlock(0xff, 0)
+	if err := d.AddBlock(ctx, genesis); err != nil {
+		t.Fatalf("Failed to add genesis: %v", err)
+	}
+	validator := dag.NewBlockValidator(d)
+	validator.SetShieldedState(&fixedShielded{spent: map[types.Hash]bool{{9}: true}, anchor: types.Hash{7}})
+
+	shieldedTx := func(anchor types.Hash, fee uint64, nullifiers ...types.Hash) *types.Transaction {
+		tx := &types.Transaction{Version: 1, Nullifiers: nullifiers, Anchor: anchor, Fee: fee}
+		tx.TxHash = tx.ComputeHash()
+		return tx
+	}
+	for _, c := range []struct {
+		name string
+		tx   *types.Transaction
+		want error
+	}{
+		// Blocks passing the shielded checks go on to fail for their
+		// missing PoUW result
+		{"valid", shieldedTx(types.Hash{7}, 1, types.Hash{1}), dag.ErrInvalidPoUW},
+		{"transparent", shieldedTx(types.Hash{8}, 13), dag.ErrInvalidPoUW},
+		{"spent", shieldedTx(types.Hash{7}, 1, types.Hash{1}, types.Hash{9}), dag.ErrNullifierSpent},
+		{"repeated", shieldedTx(types.Hash{7}, 1, types.Hash{1}, types.Hash{1}), dag.ErrDuplicateNullifier},
+		{"anchor", shieldedTx(types.Hash{8}, 1, types.Hash{1}), dag.ErrInvalidAnchor},
+		{"proof", shieldedTx(types.Hash{7}, 13, types.Hash{1}), dag.ErrInvalidTxProof},
+	} {
+		block := chainBlock(t, 1, genesis.Header.Hash, types.Address{}, nil, c.tx)
+		if err := validator.ValidateBlock(ctx, block); err != c.want {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, err)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package tests
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/ccoin/core/internal/economics"
+	"github.com/ccoin/core/internal/reputation"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// memSlashingStore keeps stakes, evidence and delegations in memory
+type memSlashingStore struct {
+	stakes      map[types.Address]*reputation.StakeInfo
+	delegations map[[2]types.Address]reputation.Delegation
+}
+
+func newMemSlashingStore() *memSlashingStore {
+	return &memSlashingStore{
+		stakes:      make(map[types.Address]*reputation.StakeInfo),
+		delegations: make(map[[2]types.Address]reputation.Delegation),
+	}
+}
+
+func (s *memSlashingStore) SaveStake(ctx context.Context, stake *reputation.StakeInfo) error {
+	s.stakes[stake.Address] = stake
+	return nil
+}
+
+func (s *memSlashingStore) GetStake(ctx context.Context, addr types.Address) (*reputation.StakeInfo, error) {
+	return s.stakes[addr], nil
+}
+
+func (s *memSlashingStore) SaveEvidence(ctx context.Context, evidence *reputation.SlashingEvidence) error {
+	return nil
+}
+
+func (s *memSlashingStore) GetPendingEvidence(ctx context.Context) ([]*reputation.SlashingEvidence, error) {
+	return nil, nil
+}
+
+func (s *memSlashingStore) SaveDelegation(ctx context.Context, d *reputation.Delegation) error {
+	s.delegations[[2]types.Address{d.Miner, d.Delegator}] = *d
+	return nil
+}
+
+func (s *memSlashingStore) GetDelegations(ctx context.Context) ([]*reputation.Delegation, error) {
+	var out []*reputation.Delegation
+	for _, d := range s.delegations {
+		d := d
+		out = append(out, &d)
+	}
+	return out, nil
+}
+
+// delegationBlock builds a block mined by miner carrying delegations
+func delegationBlock(miner types.Address, height uint64, ops ...*types.StakeDelegation) *types.Block {
+	block := &types.Block{Header: &types.BlockHeader{Height: height, MinerAddress: miner, ReputationScore: 1.0}}
+	for _, op := range ops {
+		block.Transactions = append(block.Transactions, &types.Transaction{Version: 1, Delegation: op})
+	}
+	return block
+}
+
+// Test that delegated stake counts towards the minimum, shares staker
+// rewards and slashings, and unbonds after the unbonding period
+func TestStakeDelegation(t *testing.T) {
+	ctx := context.Background()
+	store := newMemSlashingStore()
+	sm := reputation.NewSlashingManager(store, nil)
+
+	miner := types.Address{0x11}
+	if err := sm.Stake(ctx, miner, 600000, 1); err != nil {
+		t.Fatalf("Stake failed: %v", err)
+	}
+	if sm.IsEligibleToMine(miner) {
+		t.Fatal("Miner below the minimum stake is eligible")
+	}
+
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	sign := func(d *types.StakeDelegation) *types.StakeDelegation {
+		d.DelegatorKey = pub
+		digest := d.SigningHash()
+		d.Signature = ed25519.Sign(priv, digest[:])
+		return d
+	}
+	delegator := types.AddressFromPublicKey(pub)
+
+	// Bonding makes the miner eligible and earns a share of its block's
+	// staker reward
+	bond := sign(&types.StakeDelegation{Miner: miner, Action: types.DelegationBond, Amount: 400000, Nonce: 1})
+	if err := sm.ApplyBlock(ctx, delegationBlock(miner, 10, bond)); err != nil {
+		t.Fatalf("ApplyBlock failed: %v", err)
+	}
+	if !sm.IsEligibleToMine(miner) || sm.DelegatedStake(miner) != 400000 {
+		t.Fatalf("Delegated stake not counted: %d", sm.DelegatedStake(miner))
+	}
+	_, staker, _, _, _ := economics.DefaultRewardDistribution().CalculateDistribution(economics.CalculateMinerReward(10, 1.0))
+	d := sm.Delegations(miner)[0]
+	if want := uint64(float64(staker) * 0.4); d.Delegator != delegator || d.Rewards != want {
+		t.Errorf("Delegator rewarded %d, want %d", d.Rewards, want)
+	}
+
+	// A replayed delegation is ignored
+	if err := sm.ApplyBlock(ctx, delegationBlock(types.Address{0x22}, 11, bond)); err != nil {
+		t.Fatalf("ApplyBlock failed: %v", err)
+	}
+	if sm.DelegatedStake(miner) != 400000 {
+		t.Errorf("Replayed delegation applied: %d", sm.DelegatedStake(miner))
+	}
+
+	// Slashing the miner slashes its delegations at the same rate
+	evidence := &reputation.SlashingEvidence{EvidenceHash: types.Hash{1}, Type: reputation.SlashTypeDoubleSign, MinerAddress: miner}
+	if err := sm.SubmitEvidence(ctx, evidence); err != nil {
+		t.Fatalf("SubmitEvidence failed: %v", err)
+	}
+	if err := sm.ProcessSlashing(ctx, evidence.EvidenceHash); err != nil {
+		t.Fatalf("ProcessSlashing failed: %v", err)
+	}
+	d = sm.Delegations(miner)[0]
+	if d.Bonded != 320000 || d.Slashed != 80000 || evidence.SlashAmount != 200000 {
+		t.Errorf("Unexpected slashing: bonded %d, slashed %d, total %d", d.Bonded, d.Slashed, evidence.SlashAmount)
+	}
+
+	// Unbonding more than is bonded is rejected
+	over := sign(&types.StakeDelegation{Miner: miner, Action: types.DelegationUnbond, Amount: 320001, Nonce: 2})
+	if err := sm.ValidateDelegation(over); err != reputation.ErrInsufficientStake {
+		t.Errorf("Expected ErrInsufficientStake, got %v", err)
+	}
+
+	unbond := sign(&types.StakeDelegation{Miner: miner, Action: types.DelegationUnbond, Amount: 100000, Nonce: 2})
+	if err := sm.ApplyBlock(ctx, delegationBlock(types.Address{0x22}, 20, unbond)); err != nil {
+		t.Fatalf("ApplyBlock failed: %v", err)
+	}
+	d = sm.DelegationsBy(delegator)[0]
+	if d.Bonded != 220000 || d.Unbonding != 100000 || d.UnbondingUntil != 20+types.UnbondingPeriod {
+		t.Fatalf("Unexpected unbonding %+v", d)
+	}
+	if sm.IsEligibleToMine(miner) {
+		t.Error("Unbonding stake still counted towards the minimum")
+	}
+
+	// Unbonding stake is released after the unbonding period
+	if err := sm.ApplyBlock(ctx, delegationBlock(types.Address{0x22}, 20+types.UnbondingPeriod)); err != nil {
+		t.Fatalf("ApplyBlock failed: %v", err)
+	}
+	if d = sm.DelegationsBy(delegator)[0]; d.Unbonding != 0 || d.Bonded != 220000 {
+		t.Errorf("Unbonding not released: %+v", d)
+	}
+
+	// Delegations survive a reload from the store
+	reloaded := reputation.NewSlashingManager(store, nil)
+	if err := reloaded.LoadDelegations(ctx); err != nil {
+		t.Fatalf("LoadDelegations failed: %v", err)
+	}
+	if got := reloaded.Delegations(miner); len(got) != 1 || *got[0] != *d {
+		t.Errorf("Reloaded delegations %+v, want %+v", got, d)
+	}
+}
+
+// Test that unstaked amounts queue for their own unbonding periods,
+// complete as blocks are applied, and are slashed only for offenses
+// committed before they were requested
+func TestUnbondingQueue(t *testing.T) {
+	ctx := context.Background()
+	cfg := reputation.DefaultSlashingConfig()
+	cfg.StakeLockPeriod = 0
+	cfg.UnbondingPeriod = 100
+	sm := reputation.NewSlashingManager(newMemSlashingStore(), cfg)
+
+	miner := types.Address{0x33}
+	if err := sm.Stake(ctx, miner, 1000000, 1); err != nil {
+		t.Fatalf("Stake failed: %v", err)
+	}
+	if err := sm.Unstake(ctx, miner, 0, 10); err != reputation.ErrZeroUnstake {
+		t.Errorf("Expected ErrZeroUnstake, got %v", err)
+	}
+
+	// Two partial withdrawals with their own completion heights
+	if err := sm.Unstake(ctx, miner, 100000, 10); err != nil {
+		t.Fatalf("Unstake failed: %v", err)
+	}
+	cfg.UnbondingPeriod = 200
+	if err := sm.Unstake(ctx, miner, 200000, 50); err != nil {
+		t.Fatalf("Unstake failed: %v", err)
+	}
+	if err := sm.Unstake(ctx, miner, 800000, 60); err != reputation.ErrInsufficientStake {
+		t.Errorf("Expected ErrInsufficientStake, got %v", err)
+	}
+	queue := sm.Unbonding(miner)
+	if len(queue) != 2 || queue[0].CompletesAt != 110 || queue[1].CompletesAt != 250 {
+		t.Fatalf("Unexpected unbonding queue %+v %+v", queue[0], queue[1])
+	}
+	stake := sm.GetStakeInfo(miner)
+	if stake.AvailableStake != 700000 || stake.LockedStake != 300000 {
+		t.Errorf("Unexpected stake after unstaking: %+v", stake)
+	}
+
+	// An offense at height 30 reaches the withdrawal requested at 50 but
+	// not the one requested at 10
+	evidence := &reputation.SlashingEvidence{EvidenceHash: types.Hash{2}, Type: reputation.SlashTypeInvalidBlock, MinerAddress: miner, BlockHeight: 30}
+	sm.SubmitEvidence(ctx, evidence)
+	if err := sm.ProcessSlashing(ctx, evidence.EvidenceHash); err != nil {
+		t.Fatalf("ProcessSlashing failed: %v", err)
+	}
+	queue = sm.Unbonding(miner)
+	if queue[0].Amount != 100000 || queue[1].Amount != 190000 || evidence.SlashAmount != 35000+10000 {
+		t.Errorf("Unexpected slashing of %d: %+v %+v", evidence.SlashAmount, queue[0], queue[1])
+	}
+
+	// Withdrawals complete at maturity as blocks are applied
+	if err := sm.ApplyBlock(ctx, delegationBlock(types.Address{0x44}, 110)); err != nil {
+		t.Fatalf("ApplyBlock failed: %v", err)
+	}
+	if queue = sm.Unbonding(miner); len(queue) != 1 || stake.LockedStake != 190000 || stake.TotalStaked != 900000 {
+		t.Errorf("First withdrawal not completed: %+v, %+v", queue, stake)
+	}
+	if err := sm.ApplyBlock(ctx, delegationBlock(types.Address{0x44}, 250)); err != nil {
+		t.Fatalf("ApplyBlock failed: %v", err)
+	}
+	if len(sm.Unbonding(miner)) != 0 || stake.LockedStake != 0 {
+		t.Errorf("Second withdrawal not completed: %+v", stake)
+	}
+}
@@ -0,0 +1,237 @@
+package tests
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ccoin/core/internal/diagnostics"
+)
+
+// readBundle returns the files of a tar.gz bundle in archive order
+func readBundle(t *testing.T, r io.Reader) ([]string, map[string][]byte) {
+	t.Helper()
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatalf("Bundle is not gzipped: %v", err)
+	}
+	tr := tar.NewReader(gz)
+
+	var names []string
+	files := map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Bad bundle archive: %v", err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+		files[hdr.Name] = data
+	}
+	return names, files
+}
+
+// diagnosticsConfig returns a config with a data dir and a log file whose
+// tail is the last eight bytes
+func diagnosticsConfig(t *testing.T) *diagnostics.Config {
+	t.Helper()
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "node.log")
+	if err := os.WriteFile(logFile, []byte("old lines\nrecent\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := diagnostics.DefaultConfig()
+	cfg.ListenAddr = ""
+	cfg.AuthToken = "secret"
+	cfg.DataDir = dir
+	cfg.LogFile = logFile
+	cfg.MaxLogBytes = 8
+	return cfg
+}
+
+// Test that the admin handler requires the bearer token and serves
+// goroutine dumps and metrics with the registered sources
+func TestDiagnosticsHandler(t *testing.T) {
+	d := diagnostics.NewDiagnostics(diagnosticsConfig(t))
+	d.RegisterMetrics("mempool", func() interface{} { return map[string]int{"size": 3} })
+	server := httptest.NewServer(d.Handler())
+	defer server.Close()
+
+	get := func(path, token string) (*http.Response, []byte) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, body
+	}
+
+	for _, token := range []string{"", "wrong"} {
+		if resp, _ := get("/debug/metrics", token); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Token %q: got %d, want 401", token, resp.StatusCode)
+		}
+	}
+
+	resp, body := get("/debug/metrics", "secret")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Metrics = %d %s", resp.StatusCode, body)
+	}
+	var metrics map[string]json.RawMessage
+	if err := json.Unmarshal(body, &metrics); err != nil {
+		t.Fatalf("Undecodable metrics %s: %v", body, err)
+	}
+	if !strings.Contains(string(metrics["runtime"]), "goroutines") {
+		t.Errorf("Metrics lack the runtime statistics: %s", body)
+	}
+	var mempool map[string]int
+	if err := json.Unmarshal(metrics["mempool"], &mempool); err != nil || mempool["size"] != 3 {
+		t.Errorf("Mempool metrics = %s: %v", metrics["mempool"], err)
+	}
+
+	resp, body = get("/debug/goroutines", "secret")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "goroutine ") {
+		t.Errorf("Goroutines = %d %.80s", resp.StatusCode, body)
+	}
+
+	for _, seconds := range []string{"0", "-1", "abc"} {
+		if resp, _ := get("/debug/bundle?seconds="+seconds, "secret"); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("seconds=%s: got %d, want 400", seconds, resp.StatusCode)
+		}
+	}
+	if resp, _ := get("/debug/bundle?seconds=3600", "secret"); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("seconds=3600: got %d, want 503", resp.StatusCode)
+	}
+
+	resp, body = get("/debug/bundle?seconds=1", "secret")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/gzip" {
+		t.Fatalf("Bundle = %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if cd := resp.Header.Get("Content-Disposition"); !strings.Contains(cd, "ccoin-diag-") {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	if names, _ := readBundle(t, bytes.NewReader(body)); len(names) == 0 || names[0] != "metrics.json" {
+		t.Errorf("Bundle holds %v", names)
+	}
+}
+
+// Test that a bundle holds the profiles, trace, metrics and the tail of
+// the log, in a stable order
+func TestDiagnosticsBundle(t *testing.T) {
+	cfg := diagnosticsConfig(t)
+	d := diagnostics.NewDiagnostics(cfg)
+
+	// A cancelled context ends the recording at once
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var buf bytes.Buffer
+	if err := d.WriteBundle(ctx, &buf, time.Second); err != nil {
+		t.Fatalf("WriteBundle failed: %v", err)
+	}
+	names, files := readBundle(t, &buf)
+
+	want := []string{
+		"metrics.json", "goroutines.txt", "trace.out", "cpu.pprof",
+		"heap.pprof", "allocs.pprof", "goroutine.pprof", "block.pprof", "mutex.pprof",
+		"node.log",
+	}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("Bundle holds %v, want %v", names, want)
+	}
+	if string(files["node.log"]) != "\nrecent\n" {
+		t.Errorf("Log tail = %q, want the last 8 bytes", files["node.log"])
+	}
+	if !json.Valid(files["metrics.json"]) {
+		t.Errorf("metrics.json is not JSON: %.80s", files["metrics.json"])
+	}
+	if len(files["trace.out"]) == 0 || len(files["goroutines.txt"]) == 0 {
+		t.Error("Bundle has an empty trace or goroutine dump")
+	}
+
+	if err := d.WriteBundle(ctx, io.Discard, cfg.MaxDuration+time.Second); !errors.Is(err, diagnostics.ErrDurationTooLong) {
+		t.Errorf("Expected ErrDurationTooLong, got %v", err)
+	}
+
+	// CaptureBundle writes the same archive under the data dir
+	path, err := d.CaptureBundle(ctx, time.Second)
+	if err != nil {
+		t.Fatalf("CaptureBundle failed: %v", err)
+	}
+	if filepath.Dir(path) != filepath.Join(cfg.DataDir, diagnostics.BundleDirName) {
+		t.Errorf("Bundle written to %s", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if names, _ := readBundle(t, f); len(names) != len(want) {
+		t.Errorf("Captured bundle holds %v", names)
+	}
+}
+
+// Test that only one capture runs at a time
+func TestDiagnosticsConcurrentCapture(t *testing.T) {
+	d := diagnostics.NewDiagnostics(diagnosticsConfig(t))
+
+	// Hold the first capture in its metrics snapshot
+	var once sync.Once
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	d.RegisterMetrics("blocking", func() interface{} {
+		once.Do(func() {
+			close(entered)
+			<-release
+		})
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- d.WriteBundle(ctx, io.Discard, time.Second) }()
+	<-entered
+
+	if err := d.WriteBundle(ctx, io.Discard, time.Second); !errors.Is(err, diagnostics.ErrCaptureInProgress) {
+		t.Errorf("Expected ErrCaptureInProgress, got %v", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("First capture failed: %v", err)
+	}
+	if err := d.WriteBundle(ctx, io.Discard, time.Second); err != nil {
+		t.Errorf("Capture after the first finished failed: %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ccoin/core/internal/consensus"
+	"github.com/ccoin/core/internal/dag"
+	"github.com/ccoin/core/internal/pouw"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// timedChain builds a main chain of n blocks spaced interval seconds apart
+// and returns its DAG and tip
+func timedChain(t *testing.T, n int, interval uint64, target *big.Int) (*dag.DAG, *types.BlockHeader) {
+	t.Helper()
+	ctx := context.Background()
+	d := dag.NewDAG(newMemDAGStore(), nil)
+
+	var parent *types.Block
+	for i := 0; i < n; i++ {
+		var block *types.Block
+		if parent == nil {
+			block = testBlock(byte(i+1), 0)
+		} else {
+			block = testBlock(byte(i+1), uint64(i), parent.Header.Hash)
+		}
+		block.Header.Timestamp = 1000 + uint64(i)*interval
+		block.Header.Difficulty = new(big.Int).Set(target)
+		if err := d.AddBlock(ctx, block); err != nil {
+			t.Fatalf("AddBlock(%d) failed: %v", i, err)
+		}
+		parent = block
+	}
+	return d, parent.Header
+}
+
+// Test that consensus retargets from main chain block times once per window
+func TestConsensusRetarget(t *testing.T) {
+	ctx := context.Background()
+	target := new(big.Int).Lsh(big.NewInt(1), 200)
+	config := &consensus.Config{TargetBlockTime: 10, DifficultyWindow: 10, EpochLength: types.EpochLength}
+
+	tests := []struct {
+		name     string
+		interval uint64
+		want     *big.Int
+	}{
+		{"fast", 5, new(big.Int).Rsh(target, 1)},
+		{"on target", 10, target},
+		{"slow", 20, new(big.Int).Lsh(target, 1)},
+		{"clamped", 100, new(big.Int).Lsh(target, 2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The tip is at height 9, so the next block opens a window
+			d, tip := timedChain(t, 10, tt.interval, target)
+			c := consensus.NewConsensus(d, newMockMinerStore(), config)
+			if got := c.CalculateDifficulty(ctx, []*types.BlockHeader{tip}); got.Cmp(tt.want) != 0 {
+				t.Errorf("Expected target 2^%d, got 2^%d", tt.want.BitLen()-1, got.BitLen()-1)
+			}
+		})
+	}
+
+	// Inside a window the target is inherited
+	d, tip := timedChain(t, 6, 5, target)
+	c := consensus.NewConsensus(d, newMockMinerStore(), config)
+	if got := c.CalculateDifficulty(ctx, []*types.BlockHeader{tip}); got.Cmp(target) != 0 {
+		t.Error("Blocks inside a window should inherit the parent's target")
+	}
+}
+
+// Test loading block times from the main chain into a difficulty manager
+func TestDifficultyManagerChain(t *testing.T) {
+	ctx := context.Background()
+	target := new(big.Int).Lsh(big.NewInt(1), 200)
+	cfg := &pouw.DifficultyConfig{TargetBlockTime: 10, AdjustmentWindow: 10, InitialDifficulty: target}
+
+	dm := pouw.NewDifficultyManager(cfg)
+	if err := dm.LoadBlockTimes(ctx, 9); err != pouw.ErrNoChain {
+		t.Errorf("Expected ErrNoChain, got %v", err)
+	}
+
+	// Fast blocks lower the target
+	fast, tip := timedChain(t, 12, 5, target)
+	dm.SetChain(fast)
+	if err := dm.LoadBlockTimes(ctx, tip.Height); err != nil {
+		t.Fatalf("LoadBlockTimes failed: %v", err)
+	}
+	if got := dm.AdjustDifficulty(); got.Cmp(new(big.Int).Rsh(target, 1)) != 0 {
+		t.Errorf("Fast blocks should halve the target, got 2^%d", got.BitLen()-1)
+	}
+
+	// Slow blocks raise it
+	dm = pouw.NewDifficultyManager(cfg)
+	slow, tip := timedChain(t, 12, 20, target)
+	dm.SetChain(slow)
+	if err := dm.LoadBlockTimes(ctx, tip.Height); err != nil {
+		t.Fatalf("LoadBlockTimes failed: %v", err)
+	}
+	if got := dm.AdjustDifficulty(); got.Cmp(new(big.Int).Lsh(target, 1)) != 0 {
+		t.Errorf("Slow blocks should double the target, got 2^%d", got.BitLen()-1)
+	}
+	if got := dm.CalculateNextDifficulty([]uint64{0, 10, 20}); got.Cmp(new(big.Int).Lsh(target, 1)) != 0 {
+		t.Error("On-target timestamps should keep the adjusted target")
+	}
+}
+
+// Test that the validator holds blocks to the retargeted difficulty
+func TestValidateDifficulty(t *testing.T) {
+	ctx := context.Background()
+	maxTarget := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	d, tip := timedChain(t, 6, 10, maxTarget)
+	c := consensus.NewConsensus(d, newMockMinerStore(), &consensus.Config{TargetBlockTime: 10, DifficultyWindow: 10, EpochLength: types.EpochLength})
+	validator := dag.NewBlockValidator(d)
+	validator.SetDifficultyTargets(c)
+
+	block := func(difficulty *big.Int) *types.Block {
+		return chainBlock(t, tip.Height+1, tip.Hash, types.Address{}, func(b *types.Block) error {
+			b.Header.Timestamp = tip.Timestamp + 10
+			b.Header.Difficulty = difficulty
+			return nil
+		})
+	}
+
+	// A block with the inherited target goes on to fail for its missing
+	// PoUW result
+	if err := validator.ValidateBlock(ctx, block(maxTarget)); err != dag.ErrInvalidPoUW {
+		t.Errorf("Expected ErrInvalidPoUW, got %v", err)
+	}
+	if err := validator.ValidateBlock(ctx, block(new(big.Int).Sub(maxTarget, big.NewInt(1)))); err != dag.ErrWrongDifficulty {
+		t.Errorf("Expected ErrWrongDifficulty, got %v", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/ccoin/core/internal/economics"
+	"github.com/ccoin/core/pkg/types"
 )
 
 // Test block reward calculation
@@ -216,3 +217,128 @@ func TestNextHalving(t *testing.T) {
 		}
 	}
 }
+
+// staticPending serves a fixed mempool snapshot
+type staticPending []*types.Transaction
+
+func (p staticPending) Pending() []*types.Transaction { return p }
+
+// feeTx builds a one-nullifier transaction paying rate per gas
+func feeTx(seed byte, rate uint64) *types.Transaction {
+	tx := &types.Transaction{Nullifiers: []types.Hash{{seed}}}
+	tx.Fee = rate * economics.EstimateGas(tx)
+	return tx
+}
+
+// Test fee estimation from base fee, mempool backlog and block history
+func TestFeeEstimator(t *testing.T) {
+	cfg := economics.DefaultFeeEstimatorConfig()
+	cfg.BlockGas = 2 * economics.EstimateGas(feeTx(0, 0)) // Two txs per block
+
+	pending := staticPending{}
+	fe := economics.NewFeeEstimator(economics.NewFeeMarket(nil), pending, cfg)
+
+	if _, err := fe.Estimate(0); err != economics.ErrInvalidTarget {
+		t.Errorf("Expected ErrInvalidTarget, got %v", err)
+	}
+
+	// Idle network: the base fee
+	est, err := fe.Estimate(1)
+	if err != nil {
+		t.Fatalf("Estimate failed: %v", err)
+	}
+	if est.FeeRate != economics.BaseFee {
+		t.Errorf("Expected base fee %d, got %d", economics.BaseFee, est.FeeRate)
+	}
+
+	// A backlog of three txs only fits two per block
+	pending = staticPending{feeTx(1, 5000), feeTx(2, 4000), feeTx(3, 3000)}
+	fe = economics.NewFeeEstimator(economics.NewFeeMarket(nil), pending, cfg)
+	if est, _ := fe.Estimate(1); est.MempoolRate != 4001 || est.FeeRate != 4001 {
+		t.Errorf("Expected to outbid the second tx, got %+v", est)
+	}
+	if est, _ := fe.Estimate(2); est.MempoolRate != 0 {
+		t.Errorf("Backlog fits in two blocks, got mempool rate %d", est.MempoolRate)
+	}
+
+	// Recent blocks: near targets use a higher percentile
+	block := &types.Block{}
+	for i := uint64(0); i <= 10; i++ {
+		block.Transactions = append(block.Transactions, feeTx(byte(i), 2000+i))
+	}
+	fe.AddBlock(block)
+	near, _ := fe.Estimate(2)
+	far, _ := fe.Estimate(100)
+	if near.HistoryRate != 2007 || far.HistoryRate != 2005 {
+		t.Errorf("Expected history rates 2007 and 2005, got %d and %d", near.HistoryRate, far.HistoryRate)
+	}
+	if near.FeeRate < near.HistoryRate || near.Fee(economics.StandardSendGas) != near.FeeRate*economics.StandardSendGas {
+		t.Errorf("Unexpected estimate %+v", near)
+	}
+}
+
+// Test economic parameter simulation
+func TestEconomicSimulation(t *testing.T) {
+	cfg := economics.SimulationConfig{
+		StartHeight:  economics.HalvingInterval - 5*types.EpochLength + 17,
+		Epochs:       10,
+		FeesPerBlock: 5000,
+	}
+
+	base, err := economics.Simulate(economics.DefaultEconomicParams(), cfg)
+	if err != nil {
+		t.Fatalf("Simulate failed: %v", err)
+	}
+	if base.Config.StartHeight%types.EpochLength != 0 {
+		t.Errorf("Start height %d not aligned to an epoch", base.Config.StartHeight)
+	}
+	if len(base.Projections) != 10 {
+		t.Fatalf("Expected 10 epochs, got %d", len(base.Projections))
+	}
+
+	// The halving falls in the sixth epoch
+	if base.Projections[5].Emission != base.Projections[4].Emission/2 {
+		t.Errorf("Expected emission to halve: %d then %d",
+			base.Projections[4].Emission, base.Projections[5].Emission)
+	}
+	p := base.Projections[0]
+	if p.Fees != 5000*types.EpochLength {
+		t.Errorf("Expected fees %d, got %d", 5000*types.EpochLength, p.Fees)
+	}
+	if p.MinerRewards+p.StakerRewards+p.TreasuryInflow+p.ProposerRewards+p.Burned != p.Emission+p.Fees {
+		t.Error("Distribution does not account for emission and fees")
+	}
+
+	// Identical inputs give identical hashes
+	again, _ := economics.Simulate(economics.DefaultEconomicParams(), cfg)
+	if again.Hash != base.Hash {
+		t.Error("Simulation not deterministic")
+	}
+
+	params := economics.DefaultEconomicParams()
+	params.EmissionScale = 0.5
+	params.Fees.BurnShare, params.Fees.MinerShare = 0.5, 0.3
+	halved, err := economics.Simulate(params, cfg)
+	if err != nil {
+		t.Fatalf("Simulate failed: %v", err)
+	}
+	if halved.Hash == base.Hash {
+		t.Error("Different parameters gave the same hash")
+	}
+	if halved.Projections[0].Emission != base.Projections[0].Emission/2 {
+		t.Errorf("Expected half emission, got %d", halved.Projections[0].Emission)
+	}
+	if halved.Projections[9].Supply >= base.Projections[9].Supply {
+		t.Error("Expected lower supply under reduced emission")
+	}
+
+	// Shares must sum to one
+	params.Fees.TreasuryShare = 0.5
+	if _, err := economics.Simulate(params, cfg); err != economics.ErrInvalidParams {
+		t.Errorf("Expected ErrInvalidParams, got %v", err)
+	}
+	cfg.Epochs = 0
+	if _, err := economics.Simulate(economics.DefaultEconomicParams(), cfg); err != economics.ErrInvalidSimulation {
+		t.Errorf("Expected ErrInvalidSimulation, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/ccoin/core/internal/pouw"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Test the toy executor's batch and per-sample gradients: the batch
+// gradient is the sum of the samples'
+func TestToyExecutor(t *testing.T) {
+	ctx := context.Background()
+	task := &types.Task{ModelID: types.Hash{0x01}, DataHash: types.Hash{0x02}}
+	x := pouw.NewToyExecutor()
+
+	if _, err := x.ComputeSampleGradients(ctx, task, []uint32{0}); !errors.Is(err, pouw.ErrModelNotLoaded) {
+		t.Errorf("Expected ErrModelNotLoaded, got %v", err)
+	}
+	if err := x.LoadModel(ctx, task.ModelID, []int16{1, 2, 3, 4, 5, 6, 7, 8}); err != nil {
+		t.Fatal(err)
+	}
+
+	batchSize, err := x.BatchSize(ctx, task)
+	if err != nil || batchSize == 0 {
+		t.Fatalf("BatchSize = %d: %v", batchSize, err)
+	}
+	if _, err := x.ComputeSampleGradients(ctx, task, []uint32{batchSize}); !errors.Is(err, pouw.ErrSampleOutOfRange) {
+		t.Errorf("Expected ErrSampleOutOfRange, got %v", err)
+	}
+
+	full, err := x.ComputeGradients(ctx, task)
+	if err != nil {
+		t.Fatalf("ComputeGradients failed: %v", err)
+	}
+	sum := make([]int64, len(full))
+	for i := uint32(0); i < batchSize; i++ {
+		g, err := x.ComputeSampleGradients(ctx, task, []uint32{i})
+		if err != nil {
+			t.Fatalf("ComputeSampleGradients(%d) failed: %v", i, err)
+		}
+		for j := range g {
+			sum[j] += g[j]
+		}
+	}
+	if !reflect.DeepEqual(full, sum) {
+		t.Errorf("Batch gradient %v is not the sum of the samples' %v", full, sum)
+	}
+}
+
+// Test that an executor behind the gRPC sidecar answers exactly as it does
+// in process
+func TestExecutorSidecar(t *testing.T) {
+	ctx := context.Background()
+	local := pouw.NewToyExecutor()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := pouw.NewExecutorServer(pouw.NewToyExecutor())
+	go server.Serve(lis)
+	defer server.Stop()
+
+	remote, err := pouw.DialExecutor(ctx, lis.Addr().String())
+	if err != nil {
+		t.Fatalf("DialExecutor failed: %v", err)
+	}
+	defer remote.Close()
+
+	task := &types.Task{
+		TaskID:       types.Hash{0x01},
+		ModelID:      types.Hash{0x02},
+		DataHash:     types.Hash{0x03},
+		BatchIndex:   4,
+		LearningRate: 0.5,
+	}
+	weights := []int16{3, -2, 5, 1, -4, 2, 0, 7}
+	for _, x := range []pouw.TrainingExecutor{local, remote} {
+		if err := x.LoadModel(ctx, task.ModelID, weights); err != nil {
+			t.Fatalf("LoadModel failed: %v", err)
+		}
+	}
+
+	wantSize, _ := local.BatchSize(ctx, task)
+	gotSize, err := remote.BatchSize(ctx, task)
+	if err != nil || gotSize != wantSize {
+		t.Errorf("BatchSize = %d, want %d: %v", gotSize, wantSize, err)
+	}
+
+	samples := []uint32{0, 2, wantSize - 1}
+	want, _ := local.ComputeSampleGradients(ctx, task, samples)
+	got, err := remote.ComputeSampleGradients(ctx, task, samples)
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ComputeSampleGradients = %v, want %v: %v", got, want, err)
+	}
+
+	want, _ = local.ComputeGradients(ctx, task)
+	got, err = remote.ComputeGradients(ctx, task)
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ComputeGradients = %v, want %v: %v", got, want, err)
+	}
+
+	wantLoss, _ := local.EvaluateLoss(ctx, task, weights)
+	gotLoss, err := remote.EvaluateLoss(ctx, task, weights)
+	if err != nil || gotLoss != wantLoss {
+		t.Errorf("EvaluateLoss = %d, want %d: %v", gotLoss, wantLoss, err)
+	}
+
+	// Errors come back over the wire
+	other := *task
+	other.ModelID = types.Hash{0x09}
+	if _, err := remote.ComputeSampleGradients(ctx, &other, samples); err == nil {
+		t.Error("Expected an error for a model the sidecar has not loaded")
+	}
+}
@@ -0,0 +1,300 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/ccoin/core/internal/explorer"
+	"github.com/ccoin/core/internal/history"
+	"github.com/ccoin/core/internal/reputation"
+	"github.com/ccoin/core/internal/storage"
+	"github.com/ccoin/core/pkg/common"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// explorerChain is a linear chain served to the explorer
+type explorerChain struct {
+	blocks []*types.Block
+}
+
+func newExplorerChain(n int) *explorerChain {
+	c := &explorerChain{}
+	for i := 0; i < n; i++ {
+		header := &types.BlockHeader{Height: uint64(i), Timestamp: uint64(1000 + i)}
+		if i > 0 {
+			header.Parents = []types.Hash{c.blocks[i-1].Header.Hash}
+		}
+		tx := &types.Transaction{Fee: uint64(i), Nullifiers: []types.Hash{{byte(i), 0xaa}}}
+		tx.TxHash = types.Hash{byte(i), 0x77}
+		header.Hash = types.Hash{byte(i), 0x01}
+		c.blocks = append(c.blocks, types.NewBlock(header, []*types.Transaction{tx}))
+	}
+	return c
+}
+
+func (c *explorerChain) GetBlock(ctx context.Context, hash types.Hash) (*types.Block, error) {
+	for _, b := range c.blocks {
+		if b.Header.Hash == hash {
+			return b, nil
+		}
+	}
+	return nil, storage.ErrNotFound
+}
+
+func (c *explorerChain) GetHeadersAtHeight(ctx context.Context, height uint64) ([]*types.BlockHeader, error) {
+	if height >= uint64(len(c.blocks)) {
+		return nil, nil
+	}
+	return []*types.BlockHeader{c.blocks[height].Header}, nil
+}
+
+func (c *explorerChain) GetMainChain(ctx context.Context, from, to uint64) ([]*types.BlockHeader, error) {
+	var headers []*types.BlockHeader
+	for h := from; h <= to && h < uint64(len(c.blocks)); h++ {
+		headers = append(headers, c.blocks[h].Header)
+	}
+	return headers, nil
+}
+
+func (c *explorerChain) GetTips() []types.Hash { return []types.Hash{c.GetMainChainTip()} }
+func (c *explorerChain) GetMainChainTip() types.Hash {
+	return c.blocks[len(c.blocks)-1].Header.Hash
+}
+func (c *explorerChain) GetHeight() uint64 { return uint64(len(c.blocks) - 1) }
+func (c *explorerChain) GetEpoch() uint64  { return 0 }
+
+// GetTransaction finds included transactions by hash
+func (c *explorerChain) GetTransaction(ctx context.Context, txHash types.Hash) (*storage.TxRecord, error) {
+	for _, b := range c.blocks {
+		for i, tx := range b.Transactions {
+			if tx.TxHash == txHash {
+				return &storage.TxRecord{Tx: tx, BlockHash: b.Header.Hash, BlockHeight: b.Header.Height, Index: i}, nil
+			}
+		}
+	}
+	return nil, storage.ErrNotFound
+}
+
+func (c *explorerChain) GetTransactionByNullifier(ctx context.Context, nullifier types.Hash) (*storage.TxRecord, error) {
+	for _, b := range c.blocks {
+		for _, tx := range b.Transactions {
+			for _, n := range tx.Nullifiers {
+				if n == nullifier {
+					return c.GetTransaction(ctx, tx.TxHash)
+				}
+			}
+		}
+	}
+	return nil, storage.ErrNotFound
+}
+
+func (c *explorerChain) GetCommitments(ctx context.Context, from, to uint64) ([]*storage.CommitmentRecord, error) {
+	return nil, nil
+}
+
+// explorerPool holds one pending transaction
+type explorerPool struct {
+	tx *types.Transaction
+}
+
+func (p *explorerPool) Get(txHash types.Hash) *types.Transaction {
+	if p.tx != nil && p.tx.TxHash == txHash {
+		return p.tx
+	}
+	return nil
+}
+
+func (p *explorerPool) Size() int { return 1 }
+
+// getExplorer decodes a GET response and returns its status
+func getExplorer(t *testing.T, url string, v interface{}) int {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if v != nil && resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("GET %s: %v", url, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// Test the explorer's REST endpoints over a small chain
+func TestExplorerREST(t *testing.T) {
+	chain := newExplorerChain(5)
+	pending := &types.Transaction{TxHash: types.Hash{0xee}, Fee: 9}
+	e := explorer.NewExplorer(nil, &explorer.Backends{
+		DAG:          chain,
+		Transactions: chain,
+		Mempool:      &explorerPool{tx: pending},
+	})
+	server := httptest.NewServer(e.Handler())
+	defer server.Close()
+	api := server.URL + explorer.APIPrefix
+
+	var status explorer.StatusView
+	if code := getExplorer(t, api+"/status", &status); code != http.StatusOK || status.Height != 4 || status.PendingTxs != 1 {
+		t.Errorf("status: %d %+v", code, status)
+	}
+
+	// Pages run newest first
+	var page explorer.BlockListView
+	getExplorer(t, api+"/blocks?limit=2", &page)
+	if len(page.Blocks) != 2 || page.Blocks[0].Height != 4 || page.Blocks[1].Height != 3 || page.Next != 3 {
+		t.Fatalf("first page: %+v", page)
+	}
+	var last explorer.BlockListView
+	getExplorer(t, api+"/blocks?limit=10&before=3", &last)
+	if len(last.Blocks) != 3 || last.Blocks[2].Height != 0 || last.Next != 0 {
+		t.Errorf("last page: %+v", last)
+	}
+	if last.Blocks[0].TxCount != 1 {
+		t.Errorf("Listed block has %d transactions", last.Blocks[0].TxCount)
+	}
+
+	var block explorer.BlockView
+	getExplorer(t, api+"/blocks/"+chain.blocks[2].Header.Hash.String(), &block)
+	if block.Height != 2 || len(block.Transactions) != 1 || block.Parents[0] != chain.blocks[1].Header.Hash.String() {
+		t.Errorf("block: %+v", block)
+	}
+	if code := getExplorer(t, api+"/blocks/"+types.Hash{0x99}.String(), nil); code != http.StatusNotFound {
+		t.Errorf("Unknown block returned %d", code)
+	}
+	if code := getExplorer(t, api+"/blocks/zz", nil); code != http.StatusBadRequest {
+		t.Errorf("Invalid hash returned %d", code)
+	}
+
+	var tx explorer.TxView
+	getExplorer(t, api+"/txs/"+chain.blocks[3].Transactions[0].TxHash.String(), &tx)
+	if tx.BlockHeight != 3 || tx.Pending || tx.Fee != 3 {
+		t.Errorf("included tx: %+v", tx)
+	}
+	var pendingTx explorer.TxView
+	getExplorer(t, api+"/txs/"+pending.TxHash.String(), &pendingTx)
+	if !pendingTx.Pending || pendingTx.Fee != 9 || pendingTx.BlockHash != "" {
+		t.Errorf("pending tx: %+v", pendingTx)
+	}
+	var spender explorer.TxView
+	getExplorer(t, api+"/nullifiers/"+types.Hash{1, 0xaa}.String(), &spender)
+	if spender.BlockHeight != 1 {
+		t.Errorf("spender: %+v", spender)
+	}
+	if code := getExplorer(t, api+"/nullifiers/"+types.Hash{1, 0xaa}.String()+"?height=0", nil); code != http.StatusNotFound {
+		t.Errorf("Nullifier spent after the height returned %d", code)
+	}
+
+	// Backends the node does not run are reported as such
+	if code := getExplorer(t, api+"/supply", nil); code != http.StatusNotImplemented {
+		t.Errorf("supply without a backend returned %d", code)
+	}
+	if code := getExplorer(t, api+"/supply?height=2", nil); code != http.StatusNotImplemented {
+		t.Errorf("supply at a height without history returned %d", code)
+	}
+}
+
+// Test that queries with a height are answered from the history index
+func TestExplorerHistory(t *testing.T) {
+	chain := &historyChain{height: 12}
+	cfg := history.DefaultConfig()
+	cfg.Dir = t.TempDir()
+	cfg.Interval = 10
+	index, err := history.NewIndex(cfg, chain, chain)
+	if err != nil {
+		t.Fatalf("Failed to open index: %v", err)
+	}
+	index.SetStakes(&historyStakes{staked: 200})
+	if err := index.Update(context.Background()); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	chain.height = 20
+
+	miner := types.Address{1}
+	live := &history.Checkpoint{Stakes: []*reputation.StakeInfo{{Address: miner, TotalStaked: 500}}}
+	e := explorer.NewExplorer(nil, &explorer.Backends{Stakes: live, History: index})
+	server := httptest.NewServer(e.Handler())
+	defer server.Close()
+	api := server.URL + explorer.APIPrefix
+	address := api + "/addresses/" + common.BytesToHex(miner[:])
+
+	var past explorer.AddressView
+	getExplorer(t, address+"?height=15", &past)
+	if past.Staked != 200 || past.AsOf != 12 || past.Height != 15 || past.DelegatedStake != 100 {
+		t.Errorf("address at 15: %+v", past)
+	}
+	if past.Miner == nil || past.Miner.Blocks != 15 || past.Miner.Reputation != 1.5 {
+		t.Errorf("miner account at 15: %+v", past.Miner)
+	}
+	var current explorer.AddressView
+	getExplorer(t, address, &current)
+	if current.Staked != 500 || current.AsOf != 0 || current.Miner != nil {
+		t.Errorf("current address: %+v", current)
+	}
+	if code := getExplorer(t, address+"?height=5", nil); code != http.StatusNotFound {
+		t.Errorf("address below the first checkpoint returned %d", code)
+	}
+
+	var supply explorer.SupplyView
+	getExplorer(t, api+"/supply?height=15", &supply)
+	if supply.Height != 15 || supply.Circulating != 150 {
+		t.Errorf("supply at 15: %+v", supply)
+	}
+	var st explorer.StateView
+	getExplorer(t, api+"/state?height=7", &st)
+	if st.Height != 7 || st.Supply != 70 || st.BlockHash != historyHash(7).String() || st.Miners != 1 {
+		t.Errorf("state at 7: %+v", st)
+	}
+	if code := getExplorer(t, api+"/state?height=21", nil); code != http.StatusNotFound {
+		t.Errorf("state above the chain returned %d", code)
+	}
+	if code := getExplorer(t, api+"/state?height=x", nil); code != http.StatusBadRequest {
+		t.Errorf("invalid height returned %d", code)
+	}
+	var proposals []*explorer.ProposalView
+	if code := getExplorer(t, api+"/proposals?height=15", &proposals); code != http.StatusOK || len(proposals) != 0 {
+		t.Errorf("proposals at 15: %d %v", code, proposals)
+	}
+}
+
+// Test that WebSocket subscribers receive the topics they asked for
+func TestExplorerStream(t *testing.T) {
+	chain := newExplorerChain(2)
+	e := explorer.NewExplorer(nil, &explorer.Backends{DAG: chain})
+	server := httptest.NewServer(e.Handler())
+	defer server.Close()
+	defer e.Stop()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + explorer.APIPrefix + "/ws?topics=blocks"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	// Give the server time to register the subscriber
+	time.Sleep(50 * time.Millisecond)
+	e.NotifyTransaction(&types.Transaction{TxHash: types.Hash{0x01}})
+	e.NotifyBlock(chain.blocks[1])
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var event explorer.Event
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatalf("ReadJSON failed: %v", err)
+	}
+	if event.Type != explorer.EventBlock || event.Block == nil || event.Block.Height != 1 {
+		t.Errorf("Expected the block event, got %+v", event)
+	}
+
+	if _, _, err := websocket.DefaultDialer.Dial(strings.Replace(url, "blocks", "votes", 1), nil); err == nil {
+		t.Error("Expected an unknown topic to be refused")
+	}
+}
@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ccoin/core/internal/faucet"
+	"github.com/ccoin/core/internal/rpc"
+)
+
+// faucetSender records faucet payments
+type faucetSender struct {
+	err  error
+	sent []*rpc.SendTransactionRequest
+}
+
+func (s *faucetSender) SendTransaction(ctx context.Context, req *rpc.SendTransactionRequest) (*rpc.SendTransactionResponse, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.sent = append(s.sent, req)
+	return &rpc.SendTransactionResponse{TxHash: "0xabcd", Status: rpc.SendStatusAccepted}, nil
+}
+
+// Test faucet payments and per-address and per-IP rate limits
+func TestFaucet(t *testing.T) {
+	ctx := context.Background()
+	sender := &faucetSender{}
+	f := faucet.NewFaucet(nil, sender)
+	server := httptest.NewServer(f.Handler())
+	defer server.Close()
+
+	addr := "0x11223344556677889900112233445566778899aa"
+	grant, err := faucet.RequestCoins(ctx, server.URL, addr)
+	if err != nil {
+		t.Fatalf("RequestCoins failed: %v", err)
+	}
+	if grant.TxHash != "0xabcd" || grant.Amount != faucet.DefaultConfig().Amount {
+		t.Errorf("Unexpected grant %+v", grant)
+	}
+	if len(sender.sent) != 1 || sender.sent[0].To != addr {
+		t.Fatalf("Expected one payment to %s, got %+v", addr, sender.sent)
+	}
+
+	// The same client is limited for the IP interval
+	_, err = faucet.RequestCoins(ctx, server.URL, "0x00000000000000000000000000000000000000bb")
+	var limited *faucet.RateLimitError
+	if !errors.As(err, &limited) || limited.RetryAfter <= 0 || limited.RetryAfter > time.Hour {
+		t.Errorf("Expected IP rate limit, got %v", err)
+	}
+
+	// The same address is limited from any IP, whatever key it carries
+	if _, err := f.Request(ctx, addr+"00112233", "198.51.100.7"); !errors.Is(err, faucet.ErrRateLimited) {
+		t.Errorf("Expected address rate limit, got %v", err)
+	}
+
+	if _, err := f.Request(ctx, "not-an-address", "198.51.100.8"); !errors.Is(err, faucet.ErrInvalidAddress) {
+		t.Errorf("Expected ErrInvalidAddress, got %v", err)
+	}
+
+	// A failed payment does not use up the requester's allowance
+	other := "0x0000000000000000000000000000000000000002"
+	sender.err = status.Error(codes.FailedPrecondition, "wallet is locked")
+	if _, err := f.Request(ctx, other, "198.51.100.9"); err == nil {
+		t.Error("Expected payment failure")
+	}
+	sender.err = nil
+	if _, err := f.Request(ctx, other, "198.51.100.9"); err != nil {
+		t.Errorf("Request after failed payment: %v", err)
+	}
+}
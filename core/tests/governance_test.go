@@ -209,3 +209,160 @@ func TestProposalExecution(t *testing.T) {
 		t.Error("Passed proposal should be queued")
 	}
 }
+
+// historyStore is an in-memory governance store
+type historyStore struct {
+	proposals map[types.Hash]*types.Proposal
+	votes     map[types.Hash][]*governance.Vote
+}
+
+func newHistoryStore() *historyStore {
+	return &historyStore{
+		proposals: make(map[types.Hash]*types.Proposal),
+		votes:     make(map[types.Hash][]*governance.Vote),
+	}
+}
+
+func (s *historyStore) SaveProposal(ctx context.Context, p *types.Proposal) error {
+	s.proposals[p.ProposalID] = p
+	return nil
+}
+
+func (s *historyStore) GetProposal(ctx context.Context, id types.Hash) (*types.Proposal, error) {
+	return s.proposals[id], nil
+}
+
+func (s *historyStore) SaveVote(ctx context.Context, v *governance.Vote) error {
+	s.votes[v.ProposalID] = append(s.votes[v.ProposalID], v)
+	return nil
+}
+
+func (s *historyStore) GetVotes(ctx context.Context, proposalID types.Hash) ([]*governance.Vote, error) {
+	return s.votes[proposalID], nil
+}
+
+// Test per-address vote and proposal history
+func TestGovernanceHistory(t *testing.T) {
+	ctx := context.Background()
+	gm := governance.NewGovernanceManager(newHistoryStore(), nil)
+
+	alice := types.Address{1}
+	bob := types.Address{2}
+
+	first, err := gm.CreateProposal(ctx, types.ProposalTreasurySpend, alice, "Fund A", "", &types.TreasurySpendData{}, 100)
+	if err != nil {
+		t.Fatalf("CreateProposal failed: %v", err)
+	}
+	second, err := gm.CreateProposal(ctx, types.ProposalTreasurySpend, alice, "Fund B", "", &types.TreasurySpendData{}, 101)
+	if err != nil {
+		t.Fatalf("CreateProposal failed: %v", err)
+	}
+
+	if err := gm.CastVote(ctx, first.ProposalID, bob, true, 500, "good", 150); err != nil {
+		t.Fatalf("CastVote failed: %v", err)
+	}
+	if err := gm.CastVote(ctx, second.ProposalID, bob, false, 500, "", 150); err != nil {
+		t.Fatalf("CastVote failed: %v", err)
+	}
+	if err := gm.CastVote(ctx, second.ProposalID, alice, true, 100, "", 150); err != nil {
+		t.Fatalf("CastVote failed: %v", err)
+	}
+
+	// First passes; second stays open
+	end := first.VotingEndBlock + 1
+	if err := gm.FinalizeProposal(ctx, first.ProposalID, 1000, end); err != nil {
+		t.Fatalf("FinalizeProposal failed: %v", err)
+	}
+
+	history := gm.VoterHistory(bob)
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 votes for bob, got %d", len(history))
+	}
+	if history[0].Vote.ProposalID != first.ProposalID || history[0].Title != "Fund A" {
+		t.Error("Votes should be in the order cast")
+	}
+	if history[0].Outcome != types.ProposalStatusPassed || !history[0].WithOutcome() {
+		t.Error("Bob's first vote should agree with the passed outcome")
+	}
+	if history[1].Decided() || history[1].WithOutcome() {
+		t.Error("Bob's second vote is on an open proposal")
+	}
+
+	proposals := gm.ProposerHistory(alice)
+	if len(proposals) != 2 || proposals[0].ProposalID != first.ProposalID {
+		t.Fatalf("Expected alice's 2 proposals in order, got %d", len(proposals))
+	}
+	if len(gm.ProposerHistory(bob)) != 0 || len(gm.VoterHistory(types.Address{9})) != 0 {
+		t.Error("Unknown addresses should have no history")
+	}
+}
+
+// Test that governance outcomes depend only on block height, so a node
+// syncing from genesis converges with one that followed the chain live
+func TestGovernanceReplay(t *testing.T) {
+	ctx := context.Background()
+	config := governance.DefaultGovernanceConfig()
+	config.VotingPeriod = 10
+	config.ExecutionDelay = 5
+
+	alice := types.Address{1}
+	bob := types.Address{2}
+
+	// Both nodes see the same proposals and votes
+	replay := func() (*governance.GovernanceManager, []*types.Proposal) {
+		gm := governance.NewGovernanceManager(newHistoryStore(), config)
+		var proposals []*types.Proposal
+		for i, title := range []string{"Fund A", "Fund B", "Fund C"} {
+			p, err := gm.CreateProposal(ctx, types.ProposalTreasurySpend, alice, title, "", &types.TreasurySpendData{}, uint64(100+i))
+			if err != nil {
+				t.Fatalf("CreateProposal failed: %v", err)
+			}
+			proposals = append(proposals, p)
+		}
+		if err := gm.CastVote(ctx, proposals[0].ProposalID, bob, true, 500, "", 105); err != nil {
+			t.Fatalf("CastVote failed: %v", err)
+		}
+		if err := gm.CastVote(ctx, proposals[1].ProposalID, bob, false, 500, "", 105); err != nil {
+			t.Fatalf("CastVote failed: %v", err)
+		}
+		return gm, proposals
+	}
+
+	// The live node processes every block
+	live, liveProposals := replay()
+	var liveExecuted []*types.Proposal
+	for height := uint64(100); height <= 130; height++ {
+		executed, err := live.ProcessBlock(ctx, height, 1000)
+		if err != nil {
+			t.Fatalf("ProcessBlock(%d) failed: %v", height, err)
+		}
+		if len(executed) > 0 && height != liveProposals[0].VotingEndBlock+config.ExecutionDelay {
+			t.Errorf("Proposal executed at %d, before or after its timelock", height)
+		}
+		liveExecuted = append(liveExecuted, executed...)
+	}
+
+	// The syncing node catches up in one step
+	synced, syncedProposals := replay()
+	syncedExecuted, err := synced.ProcessBlock(ctx, 130, 1000)
+	if err != nil {
+		t.Fatalf("ProcessBlock failed: %v", err)
+	}
+
+	if len(liveExecuted) != 1 || len(syncedExecuted) != 1 || liveExecuted[0].ProposalID != syncedExecuted[0].ProposalID {
+		t.Fatalf("Both nodes should execute the passed proposal once, got %d and %d", len(liveExecuted), len(syncedExecuted))
+	}
+	want := []types.ProposalStatus{types.ProposalStatusExecuted, types.ProposalStatusRejected, types.ProposalStatusRejected}
+	for i := range want {
+		a, b := liveProposals[i], syncedProposals[i]
+		if a.Status != want[i] || b.Status != want[i] {
+			t.Errorf("Proposal %d: expected %v on both nodes, got %v and %v", i, want[i], a.Status, b.Status)
+		}
+		if a.ExecutedAt != b.ExecutedAt {
+			t.Errorf("Proposal %d: executed at %d live but %d when synced", i, a.ExecutedAt, b.ExecutedAt)
+		}
+	}
+	if liveProposals[0].ExecutedAt != liveProposals[0].VotingEndBlock+config.ExecutionDelay {
+		t.Errorf("Execution should be recorded at the timelock's end, got %d", liveProposals[0].ExecutedAt)
+	}
+}
@@ -0,0 +1,220 @@
+// Package tests provides tests for archive node history queries.
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ccoin/core/internal/history"
+	"github.com/ccoin/core/internal/reputation"
+	"github.com/ccoin/core/internal/state"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// historyChain is a main chain whose block at each height has a state
+// with a supply of ten times the height
+type historyChain struct {
+	height uint64
+	pinned []uint64
+}
+
+func historyHash(height uint64) types.Hash {
+	var h types.Hash
+	h[0] = 0xab
+	h[1] = byte(height >> 8)
+	h[2] = byte(height)
+	return h
+}
+
+func (c *historyChain) GetHeight() uint64 {
+	return c.height
+}
+
+func (c *historyChain) GetMainChain(ctx context.Context, from, to uint64) ([]*types.BlockHeader, error) {
+	var headers []*types.BlockHeader
+	for h := from; h <= to && h <= c.height; h++ {
+		headers = append(headers, &types.BlockHeader{Height: h, Hash: historyHash(h)})
+	}
+	return headers, nil
+}
+
+func (c *historyChain) heightOf(hash types.Hash) (uint64, error) {
+	if hash[0] != 0xab {
+		return 0, errors.New("unknown block")
+	}
+	return uint64(hash[1])<<8 | uint64(hash[2]), nil
+}
+
+func (c *historyChain) State(ctx context.Context, hash types.Hash) (*state.State, error) {
+	height, err := c.heightOf(hash)
+	if err != nil {
+		return nil, err
+	}
+	return &state.State{
+		Supply: height * 10,
+		Miners: map[types.Address]*state.MinerAccount{
+			{1}: {Blocks: height, Reputation: 1.5},
+		},
+	}, nil
+}
+
+func (c *historyChain) Pin(ctx context.Context, hash types.Hash) error {
+	height, err := c.heightOf(hash)
+	if err != nil {
+		return err
+	}
+	c.pinned = append(c.pinned, height)
+	return nil
+}
+
+// historyStakes stakes a miner its own amount
+type historyStakes struct {
+	staked uint64
+}
+
+func (s *historyStakes) Stakes() []*reputation.StakeInfo {
+	return []*reputation.StakeInfo{{Address: types.Address{1}, TotalStaked: s.staked, AvailableStake: s.staked}}
+}
+
+func (s *historyStakes) AllDelegations() []*reputation.Delegation {
+	return []*reputation.Delegation{{Delegator: types.Address{2}, Miner: types.Address{1}, Bonded: s.staked / 2}}
+}
+
+// historyProposals holds a single proposal
+type historyProposals struct {
+	status types.ProposalStatus
+}
+
+func (p *historyProposals) Proposals() []*types.Proposal {
+	return []*types.Proposal{{
+		ProposalID:      types.Hash{9},
+		ProposerAddress: types.Address{1},
+		Title:           "raise the block size",
+		Data:            &types.TreasurySpendData{Amount: 5},
+		Status:          p.status,
+	}}
+}
+
+// Test that checkpoints are recorded once per interval and serve stakes
+// and proposals as of the latest one at or below a height
+func TestHistoryCheckpoints(t *testing.T) {
+	ctx := context.Background()
+	chain := &historyChain{}
+	stakes := &historyStakes{staked: 100}
+	proposals := &historyProposals{status: types.ProposalStatusActive}
+
+	cfg := history.DefaultConfig()
+	cfg.Dir = t.TempDir()
+	cfg.Interval = 10
+	index, err := history.NewIndex(cfg, chain, chain)
+	if err != nil {
+		t.Fatalf("Failed to open index: %v", err)
+	}
+	index.SetStakes(stakes)
+	index.SetProposals(proposals)
+
+	// Checkpoints land on the first height seen in each interval
+	for _, step := range []struct {
+		height uint64
+		staked uint64
+		status types.ProposalStatus
+	}{
+		{0, 100, types.ProposalStatusActive},
+		{5, 150, types.ProposalStatusActive},
+		{12, 200, types.ProposalStatusActive},
+		{19, 250, types.ProposalStatusActive},
+		{25, 300, types.ProposalStatusPassed},
+	} {
+		chain.height = step.height
+		stakes.staked = step.staked
+		proposals.status = step.status
+		if err := index.Update(ctx); err != nil {
+			t.Fatalf("Update at %d: %v", step.height, err)
+		}
+	}
+	if fmt.Sprint(chain.pinned) != "[0 12 25]" {
+		t.Errorf("Expected states pinned at [0 12 25], got %v", chain.pinned)
+	}
+
+	for _, want := range []struct {
+		query, asOf, staked uint64
+		active              int
+	}{
+		{0, 0, 100, 1},
+		{11, 0, 100, 1},
+		{12, 12, 200, 1},
+		{24, 12, 200, 1},
+		{25, 25, 300, 0},
+	} {
+		cp, err := index.Checkpoint(want.query)
+		if err != nil {
+			t.Fatalf("Checkpoint(%d): %v", want.query, err)
+		}
+		if cp.Height != want.asOf || cp.Hash != historyHash(want.asOf) {
+			t.Errorf("Checkpoint(%d) is at %d, want %d", want.query, cp.Height, want.asOf)
+		}
+		if info := cp.GetStakeInfo(types.Address{1}); info == nil || info.TotalStaked != want.staked {
+			t.Errorf("Checkpoint(%d) stake = %+v, want %d", want.query, info, want.staked)
+		}
+		if got := cp.DelegatedStake(types.Address{1}); got != want.staked/2 {
+			t.Errorf("Checkpoint(%d) delegated = %d, want %d", want.query, got, want.staked/2)
+		}
+		if got := len(cp.GetActiveProposals()); got != want.active {
+			t.Errorf("Checkpoint(%d) has %d active proposals, want %d", want.query, got, want.active)
+		}
+	}
+	if _, err := index.Checkpoint(26); !errors.Is(err, history.ErrHeightAhead) {
+		t.Errorf("Expected ErrHeightAhead past the chain, got %v", err)
+	}
+
+	// The state at any height is exact, not as of a checkpoint
+	header, st, err := index.State(ctx, 17)
+	if err != nil {
+		t.Fatalf("State(17): %v", err)
+	}
+	if header.Height != 17 || st.Supply != 170 || st.Miners[types.Address{1}].Blocks != 17 {
+		t.Errorf("State(17) = height %d, supply %d", header.Height, st.Supply)
+	}
+	if _, _, err := index.State(ctx, 26); !errors.Is(err, history.ErrHeightAhead) {
+		t.Errorf("Expected ErrHeightAhead past the chain, got %v", err)
+	}
+
+	// Reopened, checkpoints are read back from disk and pinned again on
+	// first use
+	reopened := &historyChain{height: 25}
+	index, err = history.NewIndex(cfg, reopened, reopened)
+	if err != nil {
+		t.Fatalf("Failed to reopen index: %v", err)
+	}
+	cp, err := index.Checkpoint(20)
+	if err != nil {
+		t.Fatalf("Checkpoint(20) after reopening: %v", err)
+	}
+	if cp.Height != 12 || cp.GetStakeInfo(types.Address{1}).TotalStaked != 200 {
+		t.Errorf("Reopened checkpoint at %d", cp.Height)
+	}
+	if len(cp.DelegationsBy(types.Address{2})) != 1 || len(cp.ProposerHistory(types.Address{1})) != 1 {
+		t.Error("Reopened checkpoint lost delegations or proposals")
+	}
+	if p := cp.GetProposal(types.Hash{9}); p == nil || p.Title != "raise the block size" || p.Data != nil {
+		t.Errorf("Expected the proposal without its data, got %+v", p)
+	}
+	if _, _, err := index.State(ctx, 20); err != nil {
+		t.Fatalf("State(20) after reopening: %v", err)
+	}
+	if fmt.Sprint(reopened.pinned) != "[0 12]" {
+		t.Errorf("Expected states pinned at [0 12] after reopening, got %v", reopened.pinned)
+	}
+
+	// Nothing was recorded below the first checkpoint of a fresh index
+	cfg.Dir = t.TempDir()
+	empty, err := history.NewIndex(cfg, reopened, reopened)
+	if err != nil {
+		t.Fatalf("Failed to open index: %v", err)
+	}
+	if _, err := empty.Checkpoint(5); !errors.Is(err, history.ErrNoCheckpoint) {
+		t.Errorf("Expected ErrNoCheckpoint, got %v", err)
+	}
+}
@@ -0,0 +1,249 @@
+package tests
+
+import (
+	"context"
+	"crypto/ed25519"
+	"math/big"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ccoin/core/internal/consensus"
+	"github.com/ccoin/core/internal/dag"
+	"github.com/ccoin/core/internal/economics"
+	"github.com/ccoin/core/internal/mempool"
+	"github.com/ccoin/core/internal/miner"
+	"github.com/ccoin/core/internal/pouw"
+	"github.com/ccoin/core/internal/rpc"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// testNode wires the node components in process over memory stores
+type testNode struct {
+	dag       *dag.DAG
+	validator *dag.BlockValidator
+	pool      *mempool.Mempool
+	miners    *mockMinerStore
+	consensus *consensus.Consensus
+	supply    *economics.SupplyManager
+	treasury  *economics.Treasury
+	miner     *miner.Miner
+	rpc       *rpc.Server
+	address   types.Address
+	genesis   *types.Block
+
+	// applyErr is the first failure applying a mined block
+	applyErr error
+}
+
+func newTestNode(t *testing.T) *testNode {
+	ctx := context.Background()
+	n := &testNode{
+		dag:      dag.NewDAG(newMemDAGStore(), nil),
+		pool:     mempool.NewMempool(nil),
+		miners:   newMockMinerStore(),
+		supply:   economics.NewSupplyManager(nil),
+		treasury: economics.NewTreasury(nil),
+	}
+	n.validator = dag.NewBlockValidator(n.dag)
+	n.consensus = consensus.NewConsensus(n.dag, n.miners, nil)
+
+	n.genesis = testBlock(1, 0)
+	n.genesis.Header.Difficulty = new(big.Int).Lsh(big.NewInt(1), 255)
+	n.genesis.Header.Timestamp = 1
+	if err := n.dag.AddBlock(ctx, n.genesis); err != nil {
+		t.Fatalf("AddBlock(genesis) failed: %v", err)
+	}
+
+	_, key, _ := ed25519.GenerateKey(nil)
+	cfg := miner.DefaultConfig()
+	cfg.Address = types.AddressFromPublicKey(key.Public().(ed25519.PublicKey))
+	n.address = cfg.Address
+	engine := pouw.NewEngine(pouw.NewTaskQueue(nil), nil, nil)
+	n.miner = miner.NewMiner(n.dag, n.validator, n.pool, engine, miner.NewStaticKey(key), cfg)
+	n.miner.SetPayoutSource(n.consensus)
+	n.miner.SetBlockHandler(n.applyBlock)
+
+	n.rpc = rpc.NewServer(nil, &rpc.Backends{
+		DAG:     n.dag,
+		Mempool: n.pool,
+		Supply:  n.supply,
+		Miner:   n.miner,
+	})
+	return n
+}
+
+// applyBlock runs consensus and reward accounting for a block added to
+// the DAG
+func (n *testNode) applyBlock(ctx context.Context, block *types.Block) {
+	if n.applyErr != nil {
+		return
+	}
+	if err := n.consensus.ProcessBlock(ctx, block); err != nil {
+		n.applyErr = err
+		return
+	}
+
+	h := block.Header
+	reward := n.consensus.CalculateBlockReward(h.Height, h.ReputationScore)
+	if err := n.supply.MintReward(h.Height, reward); err != nil {
+		n.applyErr = err
+		return
+	}
+	_, _, toTreasury, _, _ := economics.DefaultRewardDistribution().CalculateDistribution(reward)
+	n.applyErr = n.treasury.Deposit(toTreasury, h.Height, h.Hash)
+}
+
+// mine builds a block for a simulated PoUW result and submits it
+func (n *testNode) mine(t *testing.T, taskID byte, quality float64) *types.Block {
+	ctx := context.Background()
+	task := &types.Task{TaskID: types.Hash{taskID}}
+	result := &pouw.PoUWResult{GradientHash: types.Hash{taskID, 0x01}, QualityScore: quality, Proof: []byte{taskID}}
+
+	block, err := n.miner.BuildBlock(ctx, task, result)
+	if err != nil {
+		t.Fatalf("BuildBlock failed: %v", err)
+	}
+	if err := n.miner.SubmitBlock(ctx, block); err != nil {
+		t.Fatalf("SubmitBlock failed: %v", err)
+	}
+	if n.applyErr != nil {
+		t.Fatalf("Applying block failed: %v", n.applyErr)
+	}
+	return block
+}
+
+// Test a block's path from pending transactions to RPC-visible state
+func TestBlockLifecycle(t *testing.T) {
+	ctx := context.Background()
+	n := newTestNode(t)
+
+	// Transactions arrive over RPC and are visible as pending
+	txs := []*types.Transaction{newTestTx(1, 300), newTestTx(2, 200), newTestTx(3, 100)}
+	for i, tx := range txs {
+		resp, err := n.rpc.SubmitTransaction(ctx, &rpc.SubmitTransactionRequest{Transaction: tx})
+		if err != nil {
+			t.Fatalf("SubmitTransaction(%d) failed: %v", i, err)
+		}
+		if resp.TxHash != tx.TxHash.String() {
+			t.Errorf("SubmitTransaction(%d) returned %s, want %s", i, resp.TxHash, tx.TxHash)
+		}
+	}
+	pending, err := n.rpc.GetTransaction(ctx, &rpc.GetTransactionRequest{TxHash: txs[0].TxHash.String()})
+	if err != nil || !pending.Pending {
+		t.Fatalf("Expected a pending transaction, got %v", err)
+	}
+
+	// A block assembled from them validates; one missing a transaction
+	// under the same root does not
+	task := &types.Task{TaskID: types.Hash{0x10}}
+	result := &pouw.PoUWResult{GradientHash: types.Hash{0x10, 0x01}, QualityScore: 0.8, Proof: []byte{1}}
+	block, err := n.miner.BuildBlock(ctx, task, result)
+	if err != nil {
+		t.Fatalf("BuildBlock failed: %v", err)
+	}
+	if len(block.Transactions) != len(txs) {
+		t.Fatalf("Expected %d transactions in the block, got %d", len(txs), len(block.Transactions))
+	}
+	if err := n.validator.ValidateBlock(ctx, block); err != nil {
+		t.Fatalf("ValidateBlock failed: %v", err)
+	}
+	truncated := types.NewBlock(block.Header, block.Transactions[1:])
+	if err := n.validator.ValidateBlock(ctx, truncated); err != dag.ErrInvalidTxRoot {
+		t.Errorf("Expected ErrInvalidTxRoot, got %v", err)
+	}
+
+	// Applying it confirms the transactions and pays the miner
+	if err := n.miner.SubmitBlock(ctx, block); err != nil {
+		t.Fatalf("SubmitBlock failed: %v", err)
+	}
+	if n.applyErr != nil {
+		t.Fatalf("Applying block failed: %v", n.applyErr)
+	}
+	if n.pool.Size() != 0 {
+		t.Errorf("Expected an empty mempool, %d left", n.pool.Size())
+	}
+	if _, err := n.rpc.GetTransaction(ctx, &rpc.GetTransactionRequest{TxHash: txs[0].TxHash.String()}); status.Code(err) != codes.NotFound {
+		t.Errorf("Confirmed transaction should no longer be pending, got %v", err)
+	}
+
+	record, err := n.miners.GetMiner(ctx, n.address)
+	if err != nil {
+		t.Fatalf("Miner record not saved: %v", err)
+	}
+	reward := n.consensus.CalculateBlockReward(1, types.InitialReputation)
+	if record.TotalBlocks != 1 || record.EpochQualityAverage() != 0.8 || record.TotalRewards != reward {
+		t.Errorf("Unexpected miner record %+v", record)
+	}
+	if n.supply.GetTotalMinted() != reward || n.supply.GetCirculatingSupply() != reward {
+		t.Errorf("Expected %d minted, got %d", reward, n.supply.GetTotalMinted())
+	}
+	_, _, toTreasury, _, _ := economics.DefaultRewardDistribution().CalculateDistribution(reward)
+	if toTreasury == 0 || n.treasury.GetBalance() != toTreasury {
+		t.Errorf("Expected treasury balance %d, got %d", toTreasury, n.treasury.GetBalance())
+	}
+
+	// The block and the new state are visible over RPC
+	got, err := n.rpc.GetBlock(ctx, &rpc.GetBlockRequest{Hash: block.Header.Hash.String()})
+	if err != nil {
+		t.Fatalf("GetBlock failed: %v", err)
+	}
+	if got.Block.Header.Hash != block.Header.Hash || len(got.Block.Transactions) != len(txs) {
+		t.Error("GetBlock should return the mined block")
+	}
+	st, err := n.rpc.GetStatus(ctx, &rpc.GetStatusRequest{})
+	if err != nil {
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+	if st.Height != 1 || st.MempoolSize != 0 {
+		t.Errorf("Expected height 1 and an empty mempool, got %d and %d", st.Height, st.MempoolSize)
+	}
+	supply, err := n.rpc.GetSupply(ctx, &rpc.GetSupplyRequest{})
+	if err != nil || supply.Circulating != reward {
+		t.Errorf("Expected circulating supply %d, got %+v (%v)", reward, supply, err)
+	}
+}
+
+// Test that consecutive blocks extend the DAG and accumulate rewards
+func TestBlockLifecycleChain(t *testing.T) {
+	ctx := context.Background()
+	n := newTestNode(t)
+
+	var total uint64
+	parent := n.genesis
+	for i := byte(1); i <= 3; i++ {
+		if err := n.pool.Add(newTestTx(i, 100)); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		block := n.mine(t, i, 0.5)
+		if block.Header.Height != uint64(i) || block.Header.Parents[0] != parent.Header.Hash {
+			t.Fatalf("Block %d should extend the previous block", i)
+		}
+		total += n.consensus.CalculateBlockReward(block.Header.Height, block.Header.ReputationScore)
+		parent = block
+	}
+
+	if n.dag.GetHeight() != 3 {
+		t.Errorf("Expected height 3, got %d", n.dag.GetHeight())
+	}
+	if n.supply.GetTotalMinted() != total {
+		t.Errorf("Expected %d minted, got %d", total, n.supply.GetTotalMinted())
+	}
+	record, _ := n.miners.GetMiner(ctx, n.address)
+	if record == nil || record.TotalBlocks != 3 || record.TotalRewards != total {
+		t.Errorf("Unexpected miner record %+v", record)
+	}
+
+	// Mining is controllable over RPC
+	if _, err := n.rpc.StartMining(ctx, &rpc.StartMiningRequest{}); err != nil {
+		t.Fatalf("StartMining failed: %v", err)
+	}
+	if _, err := n.rpc.StartMining(ctx, &rpc.StartMiningRequest{}); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("Expected AlreadyExists, got %v", err)
+	}
+	st, err := n.rpc.StopMining(ctx, &rpc.StopMiningRequest{})
+	if err != nil || st.Running {
+		t.Errorf("Expected the miner stopped, got %+v (%v)", st, err)
+	}
+}
@@ -0,0 +1,231 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ccoin/core/internal/ipfs"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// pbBytes encodes a length-delimited protobuf field
+func pbBytes(field int, data []byte) []byte {
+	buf := binary.AppendUvarint(nil, uint64(field<<3|2))
+	buf = binary.AppendUvarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+// fileNode encodes a dag-pb UnixFS file node linking to children
+func fileNode(children ...ipfs.CID) []byte {
+	var node []byte
+	for _, c := range children {
+		node = append(node, pbBytes(2, pbBytes(1, c.Bytes()))...)
+	}
+	unixfs := []byte{0x08, 0x02} // Type = File
+	return append(node, pbBytes(1, unixfs)...)
+}
+
+// carFile encodes a CARv1 with a placeholder header and the given blocks
+func carFile(cids []ipfs.CID, blocks [][]byte) []byte {
+	header := []byte{0xa0} // empty dag-cbor map
+	buf := binary.AppendUvarint(nil, uint64(len(header)))
+	buf = append(buf, header...)
+	for i, c := range cids {
+		section := append(c.Bytes(), blocks[i]...)
+		buf = binary.AppendUvarint(buf, uint64(len(section)))
+		buf = append(buf, section...)
+	}
+	return buf
+}
+
+func TestCIDParsing(t *testing.T) {
+	for _, s := range []string{
+		"QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn",
+		ipfs.RawCID([]byte("weights")).String(),
+	} {
+		c, err := ipfs.ParseCID(s)
+		if err != nil {
+			t.Fatalf("ParseCID(%s) failed: %v", s, err)
+		}
+		if c.String() != s {
+			t.Errorf("Round trip mismatch: %s != %s", c.String(), s)
+		}
+	}
+
+	if _, err := ipfs.ParseCID("zdj7W"); err != ipfs.ErrUnsupportedCID {
+		t.Errorf("Expected ErrUnsupportedCID, got %v", err)
+	}
+}
+
+func TestExtractCAR(t *testing.T) {
+	chunks := [][]byte{[]byte("layer-0 weights "), []byte("layer-1 weights")}
+	leaves := []ipfs.CID{ipfs.RawCID(chunks[0]), ipfs.RawCID(chunks[1])}
+
+	rootBlock := fileNode(leaves...)
+	digest := sha256.Sum256(rootBlock)
+	rootCID := ipfs.CID{Version: 1, Codec: ipfs.CodecDagPB, Digest: digest[:]}
+
+	car := carFile([]ipfs.CID{rootCID, leaves[0], leaves[1]}, [][]byte{rootBlock, chunks[0], chunks[1]})
+
+	var out bytes.Buffer
+	n, err := ipfs.ExtractCAR(bytes.NewReader(car), rootCID, &out)
+	if err != nil {
+		t.Fatalf("ExtractCAR failed: %v", err)
+	}
+	want := append(append([]byte{}, chunks[0]...), chunks[1]...)
+	if !bytes.Equal(out.Bytes(), want) || n != int64(len(want)) {
+		t.Errorf("Extracted %q, want %q", out.Bytes(), want)
+	}
+
+	// A tampered leaf fails verification
+	tampered := carFile([]ipfs.CID{rootCID, leaves[0], leaves[1]}, [][]byte{rootBlock, chunks[0], []byte("poisoned")})
+	if _, err := ipfs.ExtractCAR(bytes.NewReader(tampered), rootCID, &bytes.Buffer{}); err != ipfs.ErrHashMismatch {
+		t.Errorf("Expected ErrHashMismatch, got %v", err)
+	}
+
+	// Blocks out of DFS order are rejected
+	reordered := carFile([]ipfs.CID{rootCID, leaves[1], leaves[0]}, [][]byte{rootBlock, chunks[1], chunks[0]})
+	if _, err := ipfs.ExtractCAR(bytes.NewReader(reordered), rootCID, &bytes.Buffer{}); err != ipfs.ErrUnexpectedBlock {
+		t.Errorf("Expected ErrUnexpectedBlock, got %v", err)
+	}
+
+	// A stream that stops early is incomplete
+	if _, err := ipfs.ExtractCAR(bytes.NewReader(car[:len(car)-4]), rootCID, &bytes.Buffer{}); err != ipfs.ErrTruncatedCAR {
+		t.Errorf("Expected ErrTruncatedCAR, got %v", err)
+	}
+}
+
+// fakeKubo serves raw blocks as CARs on the gateway path and implements
+// the add and pin/add RPC commands
+type fakeKubo struct {
+	mu      sync.Mutex
+	blocks  map[string][]byte
+	pinned  map[string]bool
+	fetches int
+}
+
+func (k *fakeKubo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/ipfs/"):
+		id := strings.TrimPrefix(r.URL.Path, "/ipfs/")
+		data, ok := k.blocks[id]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		k.fetches++
+		c, _ := ipfs.ParseCID(id)
+		w.Write(carFile([]ipfs.CID{c}, [][]byte{data}))
+	case r.URL.Path == "/api/v0/add":
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		id := ipfs.RawCID(data).String()
+		k.blocks[id] = data
+		k.pinned[id] = true
+		json.NewEncoder(w).Encode(map[string]string{"Hash": id})
+	case r.URL.Path == "/api/v0/pin/add":
+		k.pinned[r.URL.Query().Get("arg")] = true
+		w.Write([]byte("{}"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// staticResolver resolves every model to the same CID
+type staticResolver string
+
+func (r staticResolver) WeightsCID(ctx context.Context, modelID types.Hash) (string, error) {
+	return string(r), nil
+}
+
+// Test saving, caching, fetching and pinning model weights
+func TestModelStore(t *testing.T) {
+	ctx := context.Background()
+	kubo := &fakeKubo{blocks: make(map[string][]byte), pinned: make(map[string]bool)}
+	server := httptest.NewServer(kubo)
+	defer server.Close()
+
+	newConfig := func(dir string) *ipfs.Config {
+		cfg := ipfs.DefaultConfig()
+		cfg.Gateway, cfg.API, cfg.CacheDir = server.URL, server.URL, dir
+		cfg.MaxObjectSize = 64
+		return cfg
+	}
+
+	dir := t.TempDir()
+	store, err := ipfs.NewModelStore(newConfig(dir), nil)
+	if err != nil {
+		t.Fatalf("NewModelStore failed: %v", err)
+	}
+	model := types.Hash{0x01}
+	weights := []byte("model weights v1")
+	if err := store.SaveWeights(ctx, model, weights); err != nil {
+		t.Fatalf("SaveWeights failed: %v", err)
+	}
+	cid, err := store.GetWeightsCID(ctx, model)
+	if err != nil || cid != ipfs.RawCID(weights).String() || !kubo.pinned[cid] {
+		t.Fatalf("Unexpected CID %q (pinned %v): %v", cid, kubo.pinned[cid], err)
+	}
+	if err := store.SaveWeights(ctx, model, make([]byte, 65)); err != ipfs.ErrObjectTooLarge {
+		t.Errorf("Expected ErrObjectTooLarge, got %v", err)
+	}
+	if _, err := store.GetWeights(ctx, types.Hash{0x02}); err != ipfs.ErrUnknownModel {
+		t.Errorf("Expected ErrUnknownModel, got %v", err)
+	}
+
+	// The index and cache survive a restart without touching the gateway
+	reopened, err := ipfs.NewModelStore(newConfig(dir), nil)
+	if err != nil {
+		t.Fatalf("NewModelStore failed: %v", err)
+	}
+	got, err := reopened.GetWeights(ctx, model)
+	if err != nil || !bytes.Equal(got, weights) || kubo.fetches != 0 {
+		t.Errorf("Cached weights mismatch (%d fetches): %v", kubo.fetches, err)
+	}
+
+	// Another node resolves the CID, fetches and verifies, then pins
+	delete(kubo.pinned, cid)
+	other, _ := ipfs.NewModelStore(newConfig(t.TempDir()), staticResolver(cid))
+	for i := 0; i < 2; i++ {
+		got, err := other.GetWeights(ctx, types.Hash{0x03})
+		if err != nil || !bytes.Equal(got, weights) {
+			t.Fatalf("GetWeights failed: %v", err)
+		}
+	}
+	if kubo.fetches != 1 || !kubo.pinned[cid] {
+		t.Errorf("Expected one fetch and a pin, got %d fetches, pinned %v", kubo.fetches, kubo.pinned[cid])
+	}
+
+	// Content that does not match its CID is rejected
+	kubo.blocks[cid] = []byte("poisoned weights")
+	fresh, _ := ipfs.NewModelStore(newConfig(t.TempDir()), staticResolver(cid))
+	if _, err := fresh.GetWeights(ctx, types.Hash{0x03}); err != ipfs.ErrHashMismatch {
+		t.Errorf("Expected ErrHashMismatch, got %v", err)
+	}
+
+	// The cache evicts the least recently used content past its limit
+	small := newConfig(t.TempDir())
+	small.MaxCacheSize = 24
+	bounded, _ := ipfs.NewModelStore(small, nil)
+	bounded.SaveWeights(ctx, types.Hash{0x04}, []byte("first weights"))
+	bounded.SaveWeights(ctx, types.Hash{0x05}, []byte("second weights"))
+	if stats := bounded.Stats(); stats.Entries != 1 || stats.Bytes != 14 {
+		t.Errorf("Expected one cached entry of 14 bytes, got %+v", stats)
+	}
+}
@@ -0,0 +1,213 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ccoin/core/internal/rpc"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// bodylessDAG is a DAG whose blocks have all been pruned to headers, so
+// GetBlock finds no block and no error
+type bodylessDAG struct{}
+
+func (bodylessDAG) GetBlock(ctx context.Context, hash types.Hash) (*types.Block, error) {
+	return nil, nil
+}
+func (bodylessDAG) GetTips() []types.Hash       { return nil }
+func (bodylessDAG) GetMainChainTip() types.Hash { return types.Hash{} }
+func (bodylessDAG) GetHeight() uint64           { return 7 }
+func (bodylessDAG) GetEpoch() uint64            { return 0 }
+func (bodylessDAG) GetHeadersAtHeight(ctx context.Context, height uint64) ([]*types.BlockHeader, error) {
+	return nil, nil
+}
+
+// postJSONRPC posts a JSON-RPC body to the handler and returns the status
+// code and response body
+func postJSONRPC(t *testing.T, server *httptest.Server, body string) (int, []byte) {
+	t.Helper()
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, data
+}
+
+// jsonrpcReply is a decoded response with its members kept raw, so tests
+// can tell a null member from a missing one
+type jsonrpcReply map[string]json.RawMessage
+
+// errorCode returns the reply's error code, or 0 if it has no error
+func (r jsonrpcReply) errorCode(t *testing.T) int {
+	t.Helper()
+	raw, ok := r["error"]
+	if !ok {
+		return 0
+	}
+	var e rpc.JSONRPCError
+	if err := json.Unmarshal(raw, &e); err != nil {
+		t.Fatalf("Undecodable error %s: %v", raw, err)
+	}
+	return e.Code
+}
+
+// decodeReply decodes a single response
+func decodeReply(t *testing.T, data []byte) jsonrpcReply {
+	t.Helper()
+	var r jsonrpcReply
+	if err := json.Unmarshal(data, &r); err != nil {
+		t.Fatalf("Undecodable response %s: %v", data, err)
+	}
+	return r
+}
+
+// Test single requests: results, null results and the error codes of
+// malformed requests, bad params and unknown methods
+func TestJSONRPCRequests(t *testing.T) {
+	s := rpc.NewServer(&rpc.Config{Version: "test", Network: "devnet"}, &rpc.Backends{DAG: bodylessDAG{}})
+	server := httptest.NewServer(s.JSONRPCHandler())
+	defer server.Close()
+
+	code, data := postJSONRPC(t, server, `{"jsonrpc":"2.0","id":1,"method":"ccoin_getStatus"}`)
+	r := decodeReply(t, data)
+	if code != http.StatusOK || string(r["id"]) != "1" || r.errorCode(t) != 0 {
+		t.Fatalf("getStatus = %d %s", code, data)
+	}
+	var st rpc.GetStatusResponse
+	if err := json.Unmarshal(r["result"], &st); err != nil || st.Version != "test" || st.Height != 7 {
+		t.Errorf("getStatus result = %s: %v", r["result"], err)
+	}
+
+	// A successful call with no result still carries a null result
+	hash := `"` + (types.Hash{1}).String() + `"`
+	_, data = postJSONRPC(t, server, `{"jsonrpc":"2.0","id":"b","method":"ccoin_getBlock","params":[`+hash+`]}`)
+	r = decodeReply(t, data)
+	if result, ok := r["result"]; !ok || string(result) != "null" || r.errorCode(t) != 0 {
+		t.Errorf("getBlock of a pruned block = %s, want a null result", data)
+	}
+
+	for _, tc := range []struct {
+		name string
+		body string
+		code int
+	}{
+		{"parse error", `{"jsonrpc":`, rpc.JSONRPCParseError},
+		{"not an object", `"ccoin_getStatus"`, rpc.JSONRPCInvalidRequest},
+		{"wrong version", `{"jsonrpc":"1.0","id":1,"method":"ccoin_getStatus"}`, rpc.JSONRPCInvalidRequest},
+		{"no method", `{"jsonrpc":"2.0","id":1}`, rpc.JSONRPCInvalidRequest},
+		{"unknown method", `{"jsonrpc":"2.0","id":1,"method":"ccoin_mintCoins"}`, rpc.JSONRPCMethodNotFound},
+		{"params not an array", `{"jsonrpc":"2.0","id":1,"method":"ccoin_getBlock","params":{"hash":"0x01"}}`, rpc.JSONRPCInvalidParams},
+		{"missing param", `{"jsonrpc":"2.0","id":1,"method":"ccoin_getBlock","params":[]}`, rpc.JSONRPCInvalidParams},
+		{"extra param", `{"jsonrpc":"2.0","id":1,"method":"ccoin_getBlock","params":[` + hash + `,1]}`, rpc.JSONRPCInvalidParams},
+		{"mistyped param", `{"jsonrpc":"2.0","id":1,"method":"ccoin_getBlock","params":[1]}`, rpc.JSONRPCInvalidParams},
+		{"invalid hash", `{"jsonrpc":"2.0","id":1,"method":"ccoin_getBlock","params":["0x01"]}`, rpc.JSONRPCInvalidParams},
+		{"backend missing", `{"jsonrpc":"2.0","id":1,"method":"ccoin_listBans"}`, rpc.JSONRPCUnavailable},
+	} {
+		_, data := postJSONRPC(t, server, tc.body)
+		r := decodeReply(t, data)
+		if got := r.errorCode(t); got != tc.code {
+			t.Errorf("%s: error code %d, want %d (%s)", tc.name, got, tc.code, data)
+		}
+		if _, ok := r["result"]; ok {
+			t.Errorf("%s: error response has a result: %s", tc.name, data)
+		}
+	}
+
+	if resp, err := http.Get(server.URL); err != nil || resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET = %v, %v; want 405", resp, err)
+	}
+}
+
+// Test that notifications get no response, alone or in a batch
+func TestJSONRPCNotifications(t *testing.T) {
+	s := rpc.NewServer(nil, &rpc.Backends{DAG: bodylessDAG{}})
+	server := httptest.NewServer(s.JSONRPCHandler())
+	defer server.Close()
+
+	for _, body := range []string{
+		`{"jsonrpc":"2.0","method":"ccoin_getStatus"}`,
+		`{"jsonrpc":"2.0","method":"ccoin_mintCoins"}`,
+		`{"jsonrpc":"2.0","method":"ccoin_getBlock","params":{"hash":"0x01"}}`,
+		`{"jsonrpc":"2.0","method":"ccoin_getBlock","params":[]}`,
+		`[{"jsonrpc":"2.0","method":"ccoin_getStatus"},{"jsonrpc":"2.0","method":"ccoin_getTips"}]`,
+	} {
+		if code, data := postJSONRPC(t, server, body); code != http.StatusNoContent || len(data) != 0 {
+			t.Errorf("%s: got %d %q, want no content", body, code, data)
+		}
+	}
+}
+
+// Test that a batch is answered with one response per request that has
+// an id, malformed entries included
+func TestJSONRPCBatch(t *testing.T) {
+	s := rpc.NewServer(nil, &rpc.Backends{DAG: bodylessDAG{}})
+	server := httptest.NewServer(s.JSONRPCHandler())
+	defer server.Close()
+
+	_, data := postJSONRPC(t, server, `[
+		{"jsonrpc":"2.0","id":1,"method":"ccoin_getStatus"},
+		{"jsonrpc":"2.0","method":"ccoin_getStatus"},
+		{"jsonrpc":"2.0","id":"two","method":"ccoin_mintCoins"},
+		{"jsonrpc":"2.0","id":3,"method":"ccoin_getBlock","params":[1]},
+		{"jsonrpc":"1.0","id":4,"method":"ccoin_getStatus"}
+	]`)
+	var replies []jsonrpcReply
+	if err := json.Unmarshal(data, &replies); err != nil {
+		t.Fatalf("Undecodable batch response %s: %v", data, err)
+	}
+
+	want := []struct {
+		id   string
+		code int
+	}{
+		{"1", 0},
+		{`"two"`, rpc.JSONRPCMethodNotFound},
+		{"3", rpc.JSONRPCInvalidParams},
+		{"4", rpc.JSONRPCInvalidRequest},
+	}
+	if len(replies) != len(want) {
+		t.Fatalf("Got %d responses, want %d: %s", len(replies), len(want), data)
+	}
+	for i, w := range want {
+		if string(replies[i]["id"]) != w.id || replies[i].errorCode(t) != w.code {
+			t.Errorf("Response %d = id %s error %d, want id %s error %d", i, replies[i]["id"], replies[i].errorCode(t), w.id, w.code)
+		}
+	}
+	if _, ok := replies[0]["result"]; !ok {
+		t.Error("Successful batch response has no result")
+	}
+
+	// An empty batch is one invalid request, malformed JSON one parse
+	// error, and each malformed entry its own invalid request
+	for body, code := range map[string]int{
+		`[]`:                rpc.JSONRPCInvalidRequest,
+		`[{"jsonrpc":"2.0"`: rpc.JSONRPCParseError,
+	} {
+		_, data := postJSONRPC(t, server, body)
+		r := decodeReply(t, data)
+		if got := r.errorCode(t); got != code || string(r["id"]) != "null" {
+			t.Errorf("%s: got %s, want error %d", body, data, code)
+		}
+	}
+	_, data = postJSONRPC(t, server, `[1,2]`)
+	replies = nil
+	if err := json.Unmarshal(data, &replies); err != nil || len(replies) != 2 {
+		t.Fatalf("Batch of malformed entries = %s: %v", data, err)
+	}
+	for _, r := range replies {
+		if r.errorCode(t) != rpc.JSONRPCInvalidRequest {
+			t.Errorf("Malformed entry answered with %s", data)
+		}
+	}
+}
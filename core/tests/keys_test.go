@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ccoin/core/internal/keys"
+	"github.com/ccoin/core/internal/vrf"
+)
+
+// Test that the file provider reads existing key files and rotates them
+// in their own encoding
+func TestFileKeyProvider(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	p := keys.NewFileProvider(dir)
+
+	if _, err := p.Signer(ctx, keys.KeyMiner); !errors.Is(err, keys.ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+	if _, err := p.Signer(ctx, "../miner"); !errors.Is(err, keys.ErrInvalidKeyName) {
+		t.Errorf("Expected ErrInvalidKeyName, got %v", err)
+	}
+
+	// A miner key file holds the seed
+	_, priv, _ := ed25519.GenerateKey(nil)
+	os.WriteFile(filepath.Join(dir, "miner.key"), priv.Seed(), 0600)
+	s, err := p.Signer(ctx, keys.KeyMiner)
+	if err != nil {
+		t.Fatalf("Signer failed: %v", err)
+	}
+	if !bytes.Equal(s.Public(), priv.Public().(ed25519.PublicKey)) {
+		t.Error("Signer returned another key")
+	}
+	rotated, err := p.Rotate(ctx, keys.KeyMiner)
+	if err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	if bytes.Equal(rotated.Public(), s.Public()) {
+		t.Error("Rotate kept the key")
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "miner.key")); len(data) != ed25519.SeedSize {
+		t.Errorf("Rotated miner key is %d bytes, want a seed", len(data))
+	}
+	if old, _ := filepath.Glob(filepath.Join(dir, "miner.key.*")); len(old) != 1 {
+		t.Errorf("Expected the old key to be kept, found %v", old)
+	}
+
+	// A new node key uses the libp2p encoding the node reads
+	node, err := p.Rotate(ctx, keys.KeyNode)
+	if err != nil {
+		t.Fatalf("Rotate node failed: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "node.key"))
+	if len(data) != 68 || !bytes.Equal(data[:4], []byte{0x08, 0x01, 0x12, 0x40}) {
+		t.Errorf("Node key is not libp2p encoded: %x", data)
+	}
+	reread, err := p.Signer(ctx, keys.KeyNode)
+	if err != nil || !bytes.Equal(reread.Public(), node.Public()) {
+		t.Errorf("Rereading the node key returned %v", err)
+	}
+}
+
+// Test signing through a signing service
+func TestRemoteKeyProvider(t *testing.T) {
+	ctx := context.Background()
+	backend := keys.NewFileProvider(t.TempDir())
+	if _, err := backend.Rotate(ctx, keys.KeyMiner); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	server := httptest.NewServer(keys.Handler(backend, "secret"))
+	defer server.Close()
+
+	if _, err := keys.NewRemoteProvider(server.URL, "wrong").Signer(ctx, keys.KeyMiner); err == nil {
+		t.Error("Expected a wrong token to fail")
+	}
+
+	p := keys.NewRemoteProvider(server.URL, "secret")
+	if _, err := p.Signer(ctx, keys.KeyNode); !errors.Is(err, keys.ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+	s, err := p.Signer(ctx, keys.KeyMiner)
+	if err != nil {
+		t.Fatalf("Signer failed: %v", err)
+	}
+	local, _ := backend.Signer(ctx, keys.KeyMiner)
+	if !bytes.Equal(s.Public(), local.Public()) {
+		t.Error("Remote signer has another key")
+	}
+
+	msg := []byte("header hash")
+	sig, err := s.Sign(msg)
+	if err != nil || !ed25519.Verify(s.Public(), msg, sig) {
+		t.Errorf("Sign returned an invalid signature: %v", err)
+	}
+	proof, err := s.ProveVRF(msg)
+	if err != nil {
+		t.Fatalf("ProveVRF failed: %v", err)
+	}
+	if _, err := vrf.Verify(s.Public(), proof, msg); err != nil {
+		t.Errorf("VRF proof does not verify: %v", err)
+	}
+
+	// A signer made before a rotation rejects the new key's signatures
+	rotated, err := p.Rotate(ctx, keys.KeyMiner)
+	if err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	if bytes.Equal(rotated.Public(), s.Public()) {
+		t.Error("Rotate kept the key")
+	}
+	if _, err := s.Sign(msg); !errors.Is(err, keys.ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature after rotation, got %v", err)
+	}
+	if _, err := rotated.Sign(msg); err != nil {
+		t.Errorf("Sign with the rotated key failed: %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+// Package tests provides tests for the service lifecycle manager.
+package tests
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ccoin/core/internal/lifecycle"
+)
+
+// lifecycleRecorder records services starting and stopping
+type lifecycleRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *lifecycleRecorder) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *lifecycleRecorder) service(name string, deps ...string) lifecycle.Service {
+	return lifecycle.Service{
+		Name:      name,
+		DependsOn: deps,
+		Start: func(context.Context) error {
+			r.record("start " + name)
+			return nil
+		},
+		Stop: func(context.Context) error {
+			r.record("stop " + name)
+			return nil
+		},
+	}
+}
+
+// Test that services start after their dependencies and stop before them
+func TestLifecycleOrder(t *testing.T) {
+	rec := &lifecycleRecorder{}
+	m := lifecycle.NewManager(nil)
+	m.Add(rec.service("rpc", "p2p", "mempool"))
+	m.Add(rec.service("p2p", "mempool"))
+	m.Add(rec.service("mempool"))
+	m.Add(rec.service("diagnostics"))
+
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+	for _, st := range m.Status() {
+		if st.State != lifecycle.StateRunning {
+			t.Errorf("%s is %s after start", st.Name, st.State)
+		}
+	}
+	if err := m.Stop(context.Background()); err != nil {
+		t.Fatalf("Failed to stop: %v", err)
+	}
+
+	want := []string{
+		"start mempool", "start p2p", "start rpc", "start diagnostics",
+		"stop diagnostics", "stop rpc", "stop p2p", "stop mempool",
+	}
+	if !reflect.DeepEqual(rec.events, want) {
+		t.Errorf("Events %v, want %v", rec.events, want)
+	}
+
+	// Stopping again does nothing
+	if err := m.Stop(context.Background()); err != nil || len(rec.events) != len(want) {
+		t.Errorf("Second stop: %v, %d events", err, len(rec.events))
+	}
+}
+
+// Test that a service failing to start stops those already started
+func TestLifecycleStartFailure(t *testing.T) {
+	rec := &lifecycleRecorder{}
+	m := lifecycle.NewManager(nil)
+	m.Add(rec.service("mempool"))
+	m.Add(rec.service("p2p", "mempool"))
+	m.Add(lifecycle.Service{
+		Name:      "rpc",
+		DependsOn: []string{"p2p"},
+		Start:     func(context.Context) error { return errors.New("address in use") },
+		Stop: func(context.Context) error {
+			rec.record("stop rpc")
+			return nil
+		},
+	})
+
+	if err := m.Start(context.Background()); err == nil {
+		t.Fatal("Start should fail")
+	}
+	want := []string{"start mempool", "start p2p", "stop p2p", "stop mempool"}
+	if !reflect.DeepEqual(rec.events, want) {
+		t.Errorf("Events %v, want %v", rec.events, want)
+	}
+	for _, st := range m.Status() {
+		if st.Name == "rpc" && (st.State != lifecycle.StateFailed || st.Error == "") {
+			t.Errorf("Failed service status: %+v", st)
+		}
+	}
+}
+
+// Test that missing, duplicate and cyclic dependencies are rejected
+// before anything starts
+func TestLifecycleDependencyErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		services []lifecycle.Service
+		want     error
+	}{
+		{"unknown", []lifecycle.Service{{Name: "rpc", DependsOn: []string{"p2p"}}}, lifecycle.ErrUnknownDependency},
+		{"duplicate", []lifecycle.Service{{Name: "rpc"}, {Name: "rpc"}}, lifecycle.ErrDuplicateService},
+		{"cycle", []lifecycle.Service{
+			{Name: "a", DependsOn: []string{"b"}},
+			{Name: "b", DependsOn: []string{"c"}},
+			{Name: "c", DependsOn: []string{"a"}},
+		}, lifecycle.ErrDependencyCycle},
+	}
+	for _, tc := range cases {
+		m := lifecycle.NewManager(nil)
+		for _, s := range tc.services {
+			m.Add(s)
+		}
+		if err := m.Start(context.Background()); !errors.Is(err, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+// Test that service contexts outlive the start context and are cancelled
+// as each service stops, and that a stuck service is abandoned
+func TestLifecycleStop(t *testing.T) {
+	cfg := lifecycle.DefaultConfig()
+	cfg.StopTimeout = 50 * time.Millisecond
+	m := lifecycle.NewManager(cfg)
+
+	var svcCtx context.Context
+	release := make(chan struct{})
+	defer close(release)
+	m.Add(lifecycle.Service{
+		Name: "worker",
+		Start: func(ctx context.Context) error {
+			svcCtx = ctx
+			return nil
+		},
+	})
+	m.Add(lifecycle.Service{
+		Name:      "stuck",
+		DependsOn: []string{"worker"},
+		Stop: func(context.Context) error {
+			<-release
+			return nil
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := m.Start(ctx); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+	cancel()
+	if svcCtx.Err() != nil {
+		t.Fatal("Service context cancelled with the start context")
+	}
+
+	err := m.Stop(context.Background())
+	if !errors.Is(err, lifecycle.ErrStopTimeout) {
+		t.Errorf("Expected stop timeout, got %v", err)
+	}
+	if svcCtx.Err() == nil {
+		t.Error("Service context not cancelled on stop")
+	}
+	status := m.Status()
+	if status[0].State != lifecycle.StateStopped || status[1].State != lifecycle.StateFailed {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+}
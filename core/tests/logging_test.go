@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ccoin/core/internal/logging"
+)
+
+// Test that module loggers honor per-module levels, follow Setup even
+// when obtained before it, and write JSON records carrying their module
+func TestLoggingModuleLevels(t *testing.T) {
+	defer logging.Setup(logging.DefaultConfig())
+
+	p2pLog := logging.Module("p2p")
+
+	path := filepath.Join(t.TempDir(), "node.log")
+	closer, err := logging.Setup(&logging.Config{
+		Level: "warn, p2p=debug",
+		File:  path,
+		JSON:  true,
+	})
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	poolLog := logging.Module("mempool")
+
+	p2pLog.Debug("peer connected", "peer", "a")
+	poolLog.Info("transaction removed")
+	poolLog.With("tx", "b").Warn("fee floor raised")
+
+	// Raising a module's level at runtime silences it
+	logging.SetLevel("p2p", slog.LevelError)
+	p2pLog.Warn("sync failed")
+	closer.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open log: %v", err)
+	}
+	defer f.Close()
+
+	var records []map[string]interface{}
+	lines := bufio.NewScanner(f)
+	for lines.Scan() {
+		var r map[string]interface{}
+		if err := json.Unmarshal(lines.Bytes(), &r); err != nil {
+			t.Fatalf("Log line is not JSON: %q", lines.Text())
+		}
+		records = append(records, r)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d: %v", len(records), records)
+	}
+	if records[0]["msg"] != "peer connected" || records[0]["module"] != "p2p" || records[0]["level"] != "DEBUG" {
+		t.Errorf("Unexpected p2p record: %v", records[0])
+	}
+	if records[1]["msg"] != "fee floor raised" || records[1]["module"] != "mempool" || records[1]["tx"] != "b" {
+		t.Errorf("Unexpected mempool record: %v", records[1])
+	}
+
+	if _, err := logging.Setup(&logging.Config{Level: "info,p2p=loud"}); !errors.Is(err, logging.ErrInvalidLevel) {
+		t.Errorf("Expected ErrInvalidLevel, got %v", err)
+	}
+}
+
+// Test that the log file rotates at its maximum size, keeping only the
+// configured number of backups
+func TestLoggingRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node.log")
+	f, err := logging.OpenRotatingFile(path, 100, 2)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile failed: %v", err)
+	}
+	defer f.Close()
+
+	line := strings.Repeat("x", 39) + "\n"
+	for i := 0; i < 10; i++ {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("Expected %s: %v", filepath.Base(name), err)
+		}
+		if info.Size() > 100 {
+			t.Errorf("%s is %d bytes, above the maximum", filepath.Base(name), info.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected at most 2 backups")
+	}
+}
@@ -0,0 +1,394 @@
+// Package tests provides tests for mempool components.
+package tests
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ccoin/core/internal/economics"
+	"github.com/ccoin/core/internal/mempool"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// newTestTx creates a transaction with a unique nullifier
+func newTestTx(seed byte, fee uint64) *types.Transaction {
+	tx := &types.Transaction{
+		Version:    1,
+		Nullifiers: []types.Hash{{seed}},
+		Fee:        fee,
+	}
+	tx.TxHash = tx.ComputeHash()
+	return tx
+}
+
+// Test idempotent submission with request IDs
+func TestSubmitIdempotent(t *testing.T) {
+	mp := mempool.NewMempool(nil)
+	tx := newTestTx(1, 100)
+
+	hash, err := mp.Submit("req-1", tx)
+	if err != nil {
+		t.Fatalf("First submit failed: %v", err)
+	}
+	if hash != tx.TxHash {
+		t.Error("Submit should return the transaction hash")
+	}
+
+	// Retry with the same request ID returns the original result
+	hash2, err := mp.Submit("req-1", tx)
+	if err != nil {
+		t.Errorf("Retry should return original result, got %v", err)
+	}
+	if hash2 != hash {
+		t.Error("Retry should return original hash")
+	}
+	if mp.Size() != 1 {
+		t.Errorf("Expected 1 tx in pool, got %d", mp.Size())
+	}
+
+	// Reusing the request ID for a different transaction is rejected
+	if _, err := mp.Submit("req-1", newTestTx(2, 100)); err != mempool.ErrRequestIDConflict {
+		t.Errorf("Expected ErrRequestIDConflict, got %v", err)
+	}
+
+	// Without a request ID, duplicates are reported as usual
+	if _, err := mp.Submit("", tx); err != mempool.ErrTxAlreadyExists {
+		t.Errorf("Expected ErrTxAlreadyExists, got %v", err)
+	}
+}
+
+// Test submission retention bound
+func TestSubmissionRetention(t *testing.T) {
+	cfg := mempool.DefaultConfig()
+	cfg.MaxSubmissions = 2
+	mp := mempool.NewMempool(cfg)
+
+	for i := byte(1); i <= 3; i++ {
+		if _, err := mp.Submit(string([]byte{'r', '0' + i}), newTestTx(i, 100)); err != nil {
+			t.Fatalf("Submit %d failed: %v", i, err)
+		}
+	}
+
+	if _, ok := mp.LookupSubmission("r1"); ok {
+		t.Error("Oldest request ID should have been evicted")
+	}
+	if _, ok := mp.LookupSubmission("r3"); !ok {
+		t.Error("Newest request ID should be retained")
+	}
+}
+
+// Test journal replay after a restart and a torn write
+func TestMempoolJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), mempool.DefaultJournalFile)
+
+	mp := mempool.NewMempool(nil)
+	if n, err := mp.OpenJournal(path, nil); err != nil || n != 0 {
+		t.Fatalf("OpenJournal on empty dir: %d, %v", n, err)
+	}
+	txs := []*types.Transaction{newTestTx(1, 100), newTestTx(2, 200), newTestTx(3, 300), newTestTx(4, 400)}
+	for _, tx := range txs {
+		if err := mp.Add(tx); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	mp.Remove(txs[0].TxHash)
+	mp.RemoveConfirmed(&types.Block{Transactions: []*types.Transaction{txs[1]}})
+	if err := mp.CloseJournal(); err != nil {
+		t.Fatalf("CloseJournal failed: %v", err)
+	}
+
+	// Simulate a crash mid-write
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{1, 0, 0, 1, 0, 0xde, 0xad})
+	f.Close()
+
+	// Replay restores the remaining transactions, minus any rejected
+	spent := errors.New("spent")
+	restarted := mempool.NewMempool(nil)
+	n, err := restarted.OpenJournal(path, func(tx *types.Transaction) error {
+		if tx.TxHash == txs[3].TxHash {
+			return spent
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("OpenJournal failed: %v", err)
+	}
+	if n != 1 || !restarted.Has(txs[2].TxHash) {
+		t.Fatalf("Expected only tx 3 to be restored, got %d", n)
+	}
+	restarted.CloseJournal()
+
+	// Expired entries are pruned on replay
+	cfg := mempool.DefaultConfig()
+	cfg.JournalExpiry = time.Nanosecond
+	time.Sleep(1100 * time.Millisecond)
+	expired := mempool.NewMempool(cfg)
+	if n, err := expired.OpenJournal(path, nil); err != nil || n != 0 {
+		t.Errorf("Expected expired journal to be empty, got %d, %v", n, err)
+	}
+	expired.CloseJournal()
+}
+
+// Test fee-bump replacement of conflicting transactions
+func TestMempoolReplacement(t *testing.T) {
+	mp := mempool.NewMempool(nil)
+	var events []mempool.RemovalEvent
+	mp.SetRemovalHandler(func(e mempool.RemovalEvent) {
+		events = append(events, e)
+	})
+
+	original := newTestTx(1, 100)
+	if err := mp.Add(original); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	// Same nullifier, below the 10% bump
+	if err := mp.Add(newTestTx(1, 109)); err != mempool.ErrReplacementUnderpriced {
+		t.Fatalf("Expected ErrReplacementUnderpriced, got %v", err)
+	}
+
+	replacement := newTestTx(1, 110)
+	if err := mp.Add(replacement); err != nil {
+		t.Fatalf("Replacement failed: %v", err)
+	}
+	if mp.Has(original.TxHash) || !mp.Has(replacement.TxHash) {
+		t.Fatal("Replacement should displace the original")
+	}
+	if len(events) != 1 || events[0].Reason != mempool.RemovalReplaced ||
+		events[0].Tx.TxHash != original.TxHash || events[0].ReplacedBy != replacement.TxHash {
+		t.Fatalf("Expected one replaced event, got %+v", events)
+	}
+
+	// A transaction conflicting with two pending ones must outbid both
+	second := newTestTx(2, 100)
+	if err := mp.Add(second); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	combined := &types.Transaction{Version: 1, Nullifiers: []types.Hash{{1}, {2}}, Fee: 220}
+	combined.TxHash = combined.ComputeHash()
+	if err := mp.Add(combined); err != mempool.ErrReplacementUnderpriced {
+		t.Fatalf("Expected ErrReplacementUnderpriced, got %v", err)
+	}
+	combined.Fee = 231
+	combined.TxHash = combined.ComputeHash()
+	if err := mp.Add(combined); err != nil {
+		t.Fatalf("Combined replacement failed: %v", err)
+	}
+	if mp.Size() != 1 || len(events) != 3 {
+		t.Fatalf("Expected 1 tx and 3 events, got %d and %d", mp.Size(), len(events))
+	}
+}
+
+// Test TTL-based eviction
+func TestMempoolExpiry(t *testing.T) {
+	cfg := mempool.DefaultConfig()
+	cfg.TxTTL = time.Hour
+	mp := mempool.NewMempool(cfg)
+	var expired []types.Hash
+	mp.SetRemovalHandler(func(e mempool.RemovalEvent) {
+		if e.Reason == mempool.RemovalExpired {
+			expired = append(expired, e.Tx.TxHash)
+		}
+	})
+
+	tx := newTestTx(1, 100)
+	if err := mp.Add(tx); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	if n := mp.Expire(time.Now().Add(30 * time.Minute)); n != 0 {
+		t.Errorf("Expected nothing expired before the TTL, got %d", n)
+	}
+	if n := mp.Expire(time.Now().Add(2 * time.Hour)); n != 1 {
+		t.Errorf("Expected 1 expired, got %d", n)
+	}
+	if mp.Has(tx.TxHash) || len(expired) != 1 || expired[0] != tx.TxHash {
+		t.Error("Expired transaction should be removed and reported")
+	}
+}
+
+// rateTx creates a transaction paying rate per gas
+func rateTx(seed byte, rate uint64) *types.Transaction {
+	tx := newTestTx(seed, 0)
+	tx.Fee = rate * economics.EstimateGas(tx)
+	tx.TxHash = tx.ComputeHash()
+	return tx
+}
+
+// Test that evictions raise the fee floor and that it decays after blocks
+func TestMempoolFeeFloor(t *testing.T) {
+	cfg := mempool.DefaultConfig()
+	cfg.MaxSize = 2
+	cfg.FloorHalfLife = time.Hour
+	mp := mempool.NewMempool(cfg)
+
+	for i, rate := range []uint64{10, 20, 30} {
+		if err := mp.Add(rateTx(byte(i+1), rate)); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	// Evicting the rate 10 transaction sets the floor a step above it
+	if floor := mp.MinFeeRate(); floor != 110 {
+		t.Fatalf("Expected floor 110 after eviction, got %d", floor)
+	}
+	if err := mp.Add(rateTx(4, 50)); !errors.Is(err, mempool.ErrInsufficientFee) {
+		t.Errorf("Expected a transaction below the floor to be rejected, got %v", err)
+	}
+	top := rateTx(5, 200)
+	if err := mp.Add(top); err != nil {
+		t.Fatalf("Add above the floor failed: %v", err)
+	}
+	if floor := mp.MinFeeRate(); floor != 120 {
+		t.Errorf("Expected floor 120 after second eviction, got %d", floor)
+	}
+
+	est, err := economics.NewFeeEstimator(economics.NewFeeMarket(nil), mp, nil).Estimate(1)
+	if err != nil || est.MinFeeRate != 120 {
+		t.Errorf("Expected the estimate to report the floor, got %+v %v", est, err)
+	}
+
+	// The floor holds until a block arrives
+	if floor := mp.MinFeeRateAt(time.Now().Add(10 * time.Hour)); floor != 120 {
+		t.Errorf("Expected floor to hold without blocks, got %d", floor)
+	}
+	mp.RemoveConfirmed(types.NewBlock(&types.BlockHeader{}, []*types.Transaction{top}))
+	now := time.Now()
+	if floor := mp.MinFeeRateAt(now.Add(time.Hour)); floor != 60 {
+		t.Errorf("Expected floor to halve after a half-life, got %d", floor)
+	}
+	if floor := mp.MinFeeRateAt(now.Add(2 * time.Hour)); floor != 0 {
+		t.Errorf("Expected floor to reset below half an increment, got %d", floor)
+	}
+}
+
+// Test that a backlog persisting across blocks raises the floor
+func TestMempoolFeeFloorBacklog(t *testing.T) {
+	cfg := mempool.DefaultConfig()
+	cfg.MaxSize = 4
+	cfg.FloorBacklogBlocks = 2
+	mp := mempool.NewMempool(cfg)
+
+	for i, rate := range []uint64{40, 25, 70} {
+		if err := mp.Add(rateTx(byte(i+1), rate)); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	empty := types.NewBlock(&types.BlockHeader{}, nil)
+	mp.RemoveConfirmed(empty)
+	if floor := mp.MinFeeRate(); floor != 0 {
+		t.Errorf("Expected no floor after one backlogged block, got %d", floor)
+	}
+	mp.RemoveConfirmed(empty)
+	if floor := mp.MinFeeRate(); floor != 25 {
+		t.Errorf("Expected the floor at the lowest pending rate, got %d", floor)
+	}
+}
+
+// fakeAnchors maps roots to ages; missing roots are stale
+type fakeAnchors map[types.Hash]int
+
+func (f fakeAnchors) AnchorAge(root types.Hash) (int, bool) {
+	age, ok := f[root]
+	return age, ok
+}
+
+// Test anchor freshness checks and eviction as the window moves
+func TestMempoolStaleAnchors(t *testing.T) {
+	mp := mempool.NewMempool(nil)
+	anchors := fakeAnchors{{1}: 0, {2}: 5}
+	mp.SetAnchorTracker(anchors)
+	var stale []types.Hash
+	mp.SetRemovalHandler(func(e mempool.RemovalEvent) {
+		if e.Reason == mempool.RemovalStaleAnchor {
+			stale = append(stale, e.Tx.TxHash)
+		}
+	})
+
+	fresh := newTestTx(1, 100)
+	fresh.Anchor = types.Hash{1}
+	fresh.TxHash = fresh.ComputeHash()
+	old := newTestTx(2, 100)
+	old.Anchor = types.Hash{2}
+	old.TxHash = old.ComputeHash()
+	unknown := newTestTx(3, 100)
+	unknown.Anchor = types.Hash{3}
+	unknown.TxHash = unknown.ComputeHash()
+
+	if err := mp.Add(fresh); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := mp.Add(old); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := mp.Add(unknown); !errors.Is(err, mempool.ErrStaleAnchor) {
+		t.Fatalf("Expected ErrStaleAnchor, got %v", err)
+	}
+	if age, ok := mp.AnchorAge(old.TxHash); !ok || age != 5 {
+		t.Errorf("Expected anchor age 5, got %d", age)
+	}
+
+	// A new block ages the fresh anchor and pushes the old one out
+	anchors[types.Hash{1}] = 1
+	delete(anchors, types.Hash{2})
+	if n := mp.RevalidateAnchors(); n != 1 {
+		t.Fatalf("Expected 1 eviction, got %d", n)
+	}
+	if mp.Has(old.TxHash) || len(stale) != 1 || stale[0] != old.TxHash {
+		t.Error("Stale transaction should be evicted and reported")
+	}
+	if age, _ := mp.AnchorAge(fresh.TxHash); age != 1 {
+		t.Errorf("Expected refreshed anchor age 1, got %d", age)
+	}
+}
+
+// fakeDisclosurePolicy rejects transactions with listed fees
+type fakeDisclosurePolicy map[uint64]bool
+
+func (f fakeDisclosurePolicy) CheckDisclosures(ctx context.Context, tx *types.Transaction) error {
+	if f[tx.Fee] {
+		return errors.New("disclosures missing")
+	}
+	return nil
+}
+
+// Test disclosure policy checks on admission and after policy changes
+func TestMempoolDisclosurePolicy(t *testing.T) {
+	mp := mempool.NewMempool(nil)
+	policy := fakeDisclosurePolicy{}
+	mp.SetDisclosurePolicy(policy)
+	var evicted []types.Hash
+	mp.SetRemovalHandler(func(e mempool.RemovalEvent) {
+		if e.Reason == mempool.RemovalPolicy {
+			evicted = append(evicted, e.Tx.TxHash)
+		}
+	})
+
+	policy[200] = true
+	if err := mp.Add(newTestTx(1, 200)); err == nil {
+		t.Fatal("Transaction failing the policy was admitted")
+	}
+	a := newTestTx(2, 100)
+	b := newTestTx(3, 300)
+	for _, tx := range []*types.Transaction{a, b} {
+		if err := mp.Add(tx); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	// Tightening the policy evicts what no longer meets it
+	policy[300] = true
+	if n := mp.RevalidateDisclosures(); n != 1 {
+		t.Fatalf("Expected 1 evicted, got %d", n)
+	}
+	if mp.Has(b.TxHash) || !mp.Has(a.TxHash) || len(evicted) != 1 || evicted[0] != b.TxHash {
+		t.Error("Policy eviction removed the wrong transactions")
+	}
+}
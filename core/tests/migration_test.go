@@ -0,0 +1,229 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ccoin/core/internal/storage"
+)
+
+// Test that registered online migrations are well formed
+func TestOnlineMigrations(t *testing.T) {
+	for _, m := range storage.OnlineMigrations {
+		found, err := storage.FindOnlineMigration(m.Name)
+		if err != nil || found != m {
+			t.Errorf("FindOnlineMigration(%s) = %v, %v", m.Name, found, err)
+		}
+		hasKey := false
+		for _, c := range m.Columns {
+			hasKey = hasKey || c == m.Key
+		}
+		if !hasKey {
+			t.Errorf("Migration %s does not copy its key %s", m.Name, m.Key)
+		}
+	}
+
+	if _, err := storage.FindOnlineMigration("999_missing"); !errors.Is(err, storage.ErrUnknownMigration) {
+		t.Errorf("Expected ErrUnknownMigration, got %v", err)
+	}
+}
+
+// migrationDB connects to the database named by CCOIN_TEST_POSTGRES, a
+// libpq connection string, and registers a migration widening a scratch
+// table. Both are dropped when the test ends.
+func migrationDB(t *testing.T) (*storage.PostgresStore, *pgx.Conn, *storage.OnlineMigration) {
+	t.Helper()
+	connString := os.Getenv("CCOIN_TEST_POSTGRES")
+	if connString == "" {
+		t.Skip("CCOIN_TEST_POSTGRES not set")
+	}
+	ctx := context.Background()
+
+	pc, err := pgx.ParseConfig(connString)
+	if err != nil {
+		t.Fatalf("Invalid CCOIN_TEST_POSTGRES: %v", err)
+	}
+	cfg := storage.DefaultConfig()
+	cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Database = pc.Host, int(pc.Port), pc.User, pc.Password, pc.Database
+	store, err := storage.NewPostgresStore(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewPostgresStore failed: %v", err)
+	}
+	conn, err := pgx.Connect(ctx, connString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mig := &storage.OnlineMigration{
+		Name:    "900_test_widen",
+		Table:   "migration_test",
+		Target:  "migration_test_v2",
+		Key:     "id",
+		Columns: []string{"id", "value"},
+		CreateSQL: []string{
+			`CREATE TABLE migration_test_v2 (id BYTEA PRIMARY KEY, value TEXT, extra INT DEFAULT 7)`,
+		},
+	}
+	drop := func() {
+		conn.Exec(ctx, `DROP TABLE IF EXISTS migration_test, migration_test_v2, migration_test_pre_900_test_widen CASCADE`)
+		conn.Exec(ctx, `DROP FUNCTION IF EXISTS dual_write_900_test_widen() CASCADE`)
+		conn.Exec(ctx, `DELETE FROM online_migrations WHERE name = $1`, mig.Name)
+	}
+	drop()
+	if _, err := conn.Exec(ctx, `CREATE TABLE migration_test (id BYTEA PRIMARY KEY, value TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 10; i++ {
+		if _, err := conn.Exec(ctx, `INSERT INTO migration_test VALUES ($1, $2)`, []byte{byte(i)}, fmt.Sprint(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	storage.OnlineMigrations = append(storage.OnlineMigrations, mig)
+	t.Cleanup(func() {
+		storage.OnlineMigrations = storage.OnlineMigrations[:len(storage.OnlineMigrations)-1]
+		drop()
+		conn.Close(ctx)
+		store.Close()
+	})
+	return store, conn, mig
+}
+
+// countRows counts the rows of a table
+func countRows(t *testing.T, conn *pgx.Conn, table string) int {
+	t.Helper()
+	var n int
+	if err := conn.QueryRow(context.Background(), "SELECT count(*) FROM "+table).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// Test that writes to the source table are mirrored into the shadow table
+// once dual-write is on
+func TestMigrationDualWrite(t *testing.T) {
+	ctx := context.Background()
+	store, conn, mig := migrationDB(t)
+	m := storage.NewMigrator(store, nil)
+
+	if err := m.EnableDualWrite(ctx, mig); err != nil {
+		t.Fatalf("EnableDualWrite failed: %v", err)
+	}
+	if n := countRows(t, conn, mig.Target); n != 0 {
+		t.Fatalf("Shadow table has %d rows before any write", n)
+	}
+
+	for _, stmt := range []string{
+		`INSERT INTO migration_test VALUES ('\x20', 'new')`,
+		`UPDATE migration_test SET value = 'changed' WHERE id = '\x01'`,
+		`DELETE FROM migration_test WHERE id = '\x20'`,
+		`INSERT INTO migration_test VALUES ('\x21', 'kept')`,
+	} {
+		if _, err := conn.Exec(ctx, stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	rows, err := conn.Query(ctx, `SELECT id, value FROM migration_test_v2 ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for rows.Next() {
+		var id []byte
+		var value string
+		if err := rows.Scan(&id, &value); err != nil {
+			t.Fatal(err)
+		}
+		got[fmt.Sprintf("%x", id)] = value
+	}
+	rows.Close()
+	if len(got) != 2 || got["01"] != "changed" || got["21"] != "kept" {
+		t.Errorf("Shadow table holds %v, want the updated and inserted rows only", got)
+	}
+
+	st, err := m.Status(ctx, mig.Name)
+	if err != nil || st.Phase != storage.PhaseDualWrite {
+		t.Errorf("Status = %+v, %v; want phase %s", st, err, storage.PhaseDualWrite)
+	}
+}
+
+// Test that an interrupted backfill resumes from its cursor and that
+// cutover swaps the shadow table in
+func TestMigrationBackfillCutover(t *testing.T) {
+	ctx := context.Background()
+	store, conn, mig := migrationDB(t)
+
+	// Interrupt after the first batch
+	interrupted, cancel := context.WithCancel(ctx)
+	m := storage.NewMigrator(store, &storage.MigratorConfig{
+		BatchSize:  3,
+		OnProgress: func(storage.MigrationStatus) { cancel() },
+	})
+	if err := m.Backfill(ctx, mig); err == nil {
+		t.Error("Backfill ran before dual-write was enabled")
+	}
+	if err := m.EnableDualWrite(ctx, mig); err != nil {
+		t.Fatalf("EnableDualWrite failed: %v", err)
+	}
+
+	if err := m.Backfill(interrupted, mig); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the backfill to stop on cancel, got %v", err)
+	}
+	st, err := m.Status(ctx, mig.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Phase != storage.PhaseBackfill || st.RowsCopied != 3 || fmt.Sprintf("%x", st.Cursor) != "03" {
+		t.Fatalf("Status after one batch = %+v", st)
+	}
+	if n := countRows(t, conn, mig.Target); n != 3 {
+		t.Fatalf("Shadow table has %d rows after one batch, want 3", n)
+	}
+
+	// Resume, then write a row the backfill has passed
+	var batches []storage.MigrationStatus
+	m = storage.NewMigrator(store, &storage.MigratorConfig{
+		BatchSize:  3,
+		OnProgress: func(st storage.MigrationStatus) { batches = append(batches, st) },
+	})
+	if err := m.Backfill(ctx, mig); err != nil {
+		t.Fatalf("Resumed backfill failed: %v", err)
+	}
+	if len(batches) != 3 || batches[0].RowsCopied != 6 || batches[len(batches)-1].RowsCopied != 10 {
+		t.Errorf("Resumed backfill progress %+v, want batches from row 4 to 10", batches)
+	}
+	if _, err := conn.Exec(ctx, `INSERT INTO migration_test VALUES ('\x00', 'late')`); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRows(t, conn, mig.Target); n != 11 {
+		t.Errorf("Shadow table has %d rows, want 11", n)
+	}
+
+	if err := m.Cutover(ctx, mig); err != nil {
+		t.Fatalf("Cutover failed: %v", err)
+	}
+	if n := countRows(t, conn, mig.Table); n != 11 {
+		t.Errorf("Migrated table has %d rows, want 11", n)
+	}
+	if n := countRows(t, conn, "migration_test_pre_900_test_widen"); n != 11 {
+		t.Errorf("Source table kept %d rows, want 11", n)
+	}
+	var extra int
+	if err := conn.QueryRow(ctx, `SELECT extra FROM migration_test WHERE id = '\x05'`).Scan(&extra); err != nil || extra != 7 {
+		t.Errorf("Migrated table lacks the new column: %d, %v", extra, err)
+	}
+
+	st, err = m.Status(ctx, mig.Name)
+	if err != nil || st.Phase != storage.PhaseComplete {
+		t.Errorf("Status = %+v, %v; want phase %s", st, err, storage.PhaseComplete)
+	}
+	if err := m.Run(ctx, mig); !errors.Is(err, storage.ErrMigrationComplete) {
+		t.Errorf("Expected ErrMigrationComplete, got %v", err)
+	}
+}
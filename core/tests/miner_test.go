@@ -0,0 +1,319 @@
+package tests
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ccoin/core/internal/dag"
+	"github.com/ccoin/core/internal/mempool"
+	"github.com/ccoin/core/internal/miner"
+	"github.com/ccoin/core/internal/pouw"
+	"github.com/ccoin/core/internal/reputation"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Test assembling a block from a PoUW result and submitting it
+func TestMinerBuildAndSubmit(t *testing.T) {
+	ctx := context.Background()
+	d := dag.NewDAG(newMemDAGStore(), nil)
+	genesis := testBlock(1, 0)
+	genesis.Header.Difficulty = new(big.Int).Lsh(big.NewInt(1), 255)
+	genesis.Header.Timestamp = 1
+	if err := d.AddBlock(ctx, genesis); err != nil {
+		t.Fatalf("AddBlock(genesis) failed: %v", err)
+	}
+
+	pool := mempool.NewMempool(nil)
+	tx := newTestTx(1, 100)
+	if err := pool.Add(tx); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	_, key, _ := ed25519.GenerateKey(nil)
+	cfg := miner.DefaultConfig()
+	cfg.Address = types.AddressFromPublicKey(key.Public().(ed25519.PublicKey))
+	engine := pouw.NewEngine(pouw.NewTaskQueue(nil), nil, nil)
+	m := miner.NewMiner(d, nil, pool, engine, miner.NewStaticKey(key), cfg)
+
+	var mined []*types.Block
+	m.SetBlockHandler(func(ctx context.Context, block *types.Block) {
+		mined = append(mined, block)
+	})
+
+	task := &types.Task{TaskID: types.Hash{0xaa}}
+	result := &pouw.PoUWResult{GradientHash: types.Hash{0xbb}, QualityScore: 0.5, Proof: []byte{1}}
+	block, err := m.BuildBlock(ctx, task, result)
+	if err != nil {
+		t.Fatalf("BuildBlock failed: %v", err)
+	}
+
+	h := block.Header
+	if len(h.Parents) != 1 || h.Parents[0] != genesis.Header.Hash || h.Height != 1 {
+		t.Errorf("Expected a block at height 1 on genesis, got height %d on %v", h.Height, h.Parents)
+	}
+	if h.TaskID != task.TaskID || h.PoUWResult != result.GradientHash || h.QualityScore != 0.5 {
+		t.Error("Header should embed the PoUW result")
+	}
+	if h.MinerAddress != cfg.Address || h.PayoutAddress != cfg.Address {
+		t.Error("Rewards should default to the miner address")
+	}
+	if len(block.Transactions) != 1 || h.TxRoot != dag.ComputeTxRoot(block.Transactions) {
+		t.Error("Block should carry the pending transaction under its root")
+	}
+	if h.Hash != h.ComputeHash() || new(big.Int).SetBytes(h.Hash[:]).Cmp(h.Difficulty) >= 0 {
+		t.Error("Header hash should meet the inherited target")
+	}
+	if !h.VerifySignature() {
+		t.Error("Header should be signed with the miner key")
+	}
+
+	if err := m.SubmitBlock(ctx, block); err != nil {
+		t.Fatalf("SubmitBlock failed: %v", err)
+	}
+	if !d.HasBlock(ctx, h.Hash) {
+		t.Error("Submitted block should be in the DAG")
+	}
+	if pool.Size() != 0 {
+		t.Errorf("Confirmed transactions should leave the mempool, %d left", pool.Size())
+	}
+	if len(mined) != 1 || mined[0] != block {
+		t.Error("Block handler should see the mined block")
+	}
+
+	// An unreachable target fails the nonce search
+	cfg.MaxNonceAttempts = 16
+	block.Header.Difficulty = big.NewInt(1)
+	hard := miner.NewMiner(d, nil, pool, engine, miner.NewStaticKey(key), cfg)
+	if _, err := hard.BuildBlock(ctx, task, result); err == nil {
+		t.Error("Expected BuildBlock to fail below an unreachable target")
+	}
+}
+
+// Test that the block template changes, and subscribers hear of it, when
+// a block moves the tips
+func TestBlockTemplateNotifications(t *testing.T) {
+	ctx := context.Background()
+	d := dag.NewDAG(newMemDAGStore(), nil)
+	genesis := testBlock(1, 0)
+	genesis.Header.Difficulty = new(big.Int).Lsh(big.NewInt(1), 255)
+	genesis.Header.Timestamp = 1
+	if err := d.AddBlock(ctx, genesis); err != nil {
+		t.Fatalf("AddBlock(genesis) failed: %v", err)
+	}
+
+	_, key, _ := ed25519.GenerateKey(nil)
+	keys := miner.NewStaticKey(key)
+	cfg := miner.DefaultConfig()
+	cfg.Address = keys.Address()
+	engine := pouw.NewEngine(pouw.NewTaskQueue(nil), nil, nil)
+	m := miner.NewMiner(d, nil, mempool.NewMempool(nil), engine, keys, cfg)
+
+	template, err := m.BlockTemplate(ctx)
+	if err != nil {
+		t.Fatalf("BlockTemplate failed: %v", err)
+	}
+	if len(template.Parents) != 1 || template.Parents[0] != genesis.Header.Hash || template.Height != 1 {
+		t.Errorf("Unexpected template %+v", template)
+	}
+	if again, _ := m.BlockTemplate(ctx); again.ID != template.ID {
+		t.Error("Template ID changed without a new block")
+	}
+
+	updates, cancel := m.Subscribe()
+	defer cancel()
+
+	task := &types.Task{TaskID: types.Hash{0xaa}}
+	result := &pouw.PoUWResult{GradientHash: types.Hash{0xbb}, QualityScore: 0.5, Proof: []byte{1}}
+	block, err := m.BuildBlock(ctx, task, result)
+	if err != nil {
+		t.Fatalf("BuildBlock failed: %v", err)
+	}
+	if err := m.SubmitBlock(ctx, block); err != nil {
+		t.Fatalf("SubmitBlock failed: %v", err)
+	}
+
+	select {
+	case n := <-updates:
+		if n.Kind != miner.NotifyNewTips || n.Template.ID == template.ID || n.Template.Parents[0] != block.Header.Hash {
+			t.Errorf("Unexpected notification %v %+v", n.Kind, n.Template)
+		}
+	default:
+		t.Fatal("No notification for the new tip")
+	}
+
+	// The same tips notify nothing further
+	m.NotifyBlock(ctx, block)
+	select {
+	case n := <-updates:
+		t.Errorf("Unexpected notification %v", n.Kind)
+	default:
+	}
+}
+
+// Test that mining starts only with the configured address's key
+func TestMinerStartStop(t *testing.T) {
+	d := dag.NewDAG(newMemDAGStore(), nil)
+	engine := pouw.NewEngine(pouw.NewTaskQueue(nil), nil, nil)
+
+	_, key, _ := ed25519.GenerateKey(nil)
+	cfg := miner.DefaultConfig()
+	cfg.Address = types.Address{0x01}
+	m := miner.NewMiner(d, nil, mempool.NewMempool(nil), engine, miner.NewStaticKey(key), cfg)
+	if err := m.Start(); err == nil {
+		t.Fatal("Expected Start to fail without the address's key")
+	}
+
+	cfg.Address = types.AddressFromPublicKey(key.Public().(ed25519.PublicKey))
+	m = miner.NewMiner(d, nil, mempool.NewMempool(nil), engine, miner.NewStaticKey(key), cfg)
+	if err := m.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := m.Start(); err != miner.ErrAlreadyRunning {
+		t.Errorf("Expected ErrAlreadyRunning, got %v", err)
+	}
+	if st := m.Status(); !st.Running || st.Address != cfg.Address || st.Reputation != types.InitialReputation {
+		t.Errorf("Unexpected status %+v", st)
+	}
+
+	m.Stop()
+	if m.Status().Running {
+		t.Error("Miner should be stopped")
+	}
+}
+
+// unreadableRoots is a nullifier accumulator whose store times out
+type unreadableRoots struct{}
+
+func (unreadableRoots) NextRoot(ctx context.Context, parent types.Hash, nullifiers []types.Hash) (types.Hash, error) {
+	return types.Hash{}, context.DeadlineExceeded
+}
+
+// Test that the validator checks header signatures and reports signed
+// invalid blocks as slashing evidence against their miner
+func TestBlockSignatures(t *testing.T) {
+	ctx := context.Background()
+	d := dag.NewDAG(newMemDAGStore(), nil)
+	genesis := testBlock(1, 0)
+	genesis.Header.Difficulty = new(big.Int).Lsh(big.NewInt(1), 255)
+	genesis.Header.Timestamp = 1
+	if err := d.AddBlock(ctx, genesis); err != nil {
+		t.Fatalf("AddBlock(genesis) failed: %v", err)
+	}
+
+	_, key, _ := ed25519.GenerateKey(nil)
+	keys := miner.NewStaticKey(key)
+	cfg := miner.DefaultConfig()
+	cfg.Address = keys.Address()
+	engine := pouw.NewEngine(pouw.NewTaskQueue(nil), nil, nil)
+	m := miner.NewMiner(d, nil, mempool.NewMempool(nil), engine, keys, cfg)
+
+	validator := dag.NewBlockValidator(d)
+	validator.SetSignatureRequired(true)
+	var reported []*types.BlockHeader
+	validator.SetInvalidBlockHandler(func(ctx context.Context, header *types.BlockHeader, reason error) {
+		reported = append(reported, header)
+	})
+
+	task := &types.Task{TaskID: types.Hash{0xaa}}
+	result := &pouw.PoUWResult{GradientHash: types.Hash{0xbb}, QualityScore: 0.5, Proof: []byte{1}}
+	block, err := m.BuildBlock(ctx, task, result)
+	if err != nil {
+		t.Fatalf("BuildBlock failed: %v", err)
+	}
+	if err := validator.ValidateBlock(ctx, block); err != nil {
+		t.Fatalf("Signed block should validate: %v", err)
+	}
+
+	// A signature by any other key is rejected, and since it does not
+	// identify the miner nothing is reported
+	forged := *block.Header
+	_, other, _ := ed25519.GenerateKey(nil)
+	forged.Sign(other)
+	if err := validator.ValidateBlock(ctx, &types.Block{Header: &forged}); !errors.Is(err, dag.ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for a foreign signature, got %v", err)
+	}
+	unsigned := *block.Header
+	unsigned.Signature = nil
+	if err := validator.ValidateBlock(ctx, &types.Block{Header: &unsigned}); !errors.Is(err, dag.ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for an unsigned block, got %v", err)
+	}
+	if len(reported) != 0 {
+		t.Fatalf("Blocks the miner did not sign should not be reported, got %d", len(reported))
+	}
+	if _, err := reputation.BlockEvidence(&unsigned, dag.ErrInvalidSignature); err != reputation.ErrNotSlashable {
+		t.Errorf("Expected ErrNotSlashable for an unsigned block, got %v", err)
+	}
+
+	// A block the miner signed but that breaks consensus rules is evidence
+	invalid := *block.Header
+	invalid.CommitteeRoot = types.Hash{0x01}
+	invalid.Hash = invalid.ComputeHash()
+	invalid.Sign(key)
+	if err := validator.ValidateBlock(ctx, &types.Block{Header: &invalid}); err == nil {
+		t.Fatal("Expected the altered block to be invalid")
+	}
+	if len(reported) != 1 || reported[0].Hash != invalid.Hash {
+		t.Fatalf("Signed invalid block should be reported, got %d", len(reported))
+	}
+
+	// Failing to read chain state is this node's fault, not the miner's
+	validator.SetNullifierRoots(unreadableRoots{})
+	if err := validator.ValidateBlock(ctx, block); !errors.Is(err, dag.ErrValidationUnavailable) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a wrapped store error, got %v", err)
+	}
+	validator.SetNullifierRoots(nil)
+	if len(reported) != 1 {
+		t.Fatalf("Store failure reported as evidence, got %d reports", len(reported))
+	}
+
+	evidence, err := reputation.BlockEvidence(reported[0], dag.ErrInvalidCommitteeRoot)
+	if err != nil {
+		t.Fatalf("BlockEvidence failed: %v", err)
+	}
+	if evidence.Type != reputation.SlashTypeInvalidBlock || evidence.MinerAddress != cfg.Address {
+		t.Errorf("Evidence should accuse the signer, got %+v", evidence)
+	}
+	header, err := reputation.VerifyBlockEvidence(evidence)
+	if err != nil {
+		t.Fatalf("VerifyBlockEvidence failed: %v", err)
+	}
+	if header.Hash != invalid.Hash {
+		t.Error("Evidence should carry the signed header")
+	}
+	evidence.MinerAddress = types.Address{0x02}
+	if _, err := reputation.VerifyBlockEvidence(evidence); err != reputation.ErrInvalidEvidence {
+		t.Errorf("Expected ErrInvalidEvidence for a different accused miner, got %v", err)
+	}
+}
+
+// Test creating and loading a miner identity key
+func TestMinerKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), miner.DefaultKeyFile)
+	key, err := miner.CreateKey(path)
+	if err != nil {
+		t.Fatalf("CreateKey failed: %v", err)
+	}
+	if _, err := miner.CreateKey(path); err != miner.ErrKeyExists {
+		t.Errorf("Expected ErrKeyExists, got %v", err)
+	}
+
+	loaded, err := miner.LoadKey(path)
+	if err != nil {
+		t.Fatalf("LoadKey failed: %v", err)
+	}
+	if !key.Equal(loaded) {
+		t.Error("Loaded key should match the created one")
+	}
+
+	keys := miner.NewStaticKey(loaded)
+	if _, err := keys.SigningKey(keys.Address()); err != nil {
+		t.Errorf("SigningKey failed for the key's address: %v", err)
+	}
+	if _, err := keys.SigningKey(types.Address{0x01}); err != miner.ErrKeyMismatch {
+		t.Errorf("Expected ErrKeyMismatch, got %v", err)
+	}
+}
@@ -0,0 +1,571 @@
+// Package tests provides tests for p2p message encoding.
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/multiformats/go-multiaddr"
+
+	"github.com/ccoin/core/internal/p2p"
+	"github.com/ccoin/core/internal/state"
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Test block encoding round trip
+func TestBlockEncoding(t *testing.T) {
+	header := &types.BlockHeader{
+		Version:         1,
+		Parents:         []types.Hash{{1}, {2}},
+		TxRoot:          types.Hash{3},
+		PoUWProof:       []byte{4, 5, 6},
+		QualityScore:    0.5,
+		MinerAddress:    types.Address{7},
+		ReputationScore: 1.25,
+		Difficulty:      big.NewInt(1 << 40),
+		Nonce:           42,
+		Timestamp:       1700000000,
+		Height:          9,
+		CumulativeScore: big.NewFloat(12345),
+		ExtraData:       []byte("ccoin"),
+	}
+	header.Hash = header.ComputeHash()
+
+	tx := &types.Transaction{
+		Version:     1,
+		Nullifiers:  []types.Hash{{8}},
+		Commitments: []types.Commitment{{Value: types.Hash{9}}},
+		Fee:         100,
+		Memo:        []byte("memo"),
+	}
+	block := types.NewBlock(header, []*types.Transaction{tx})
+
+	data, err := p2p.EncodeBlock(block)
+	if err != nil {
+		t.Fatalf("EncodeBlock failed: %v", err)
+	}
+	decoded, err := p2p.DecodeBlock(data)
+	if err != nil {
+		t.Fatalf("DecodeBlock failed: %v", err)
+	}
+
+	h := decoded.Header
+	if h.Hash != header.Hash || h.ComputeHash() != header.Hash {
+		t.Error("Decoded header should keep its hash")
+	}
+	if len(h.Parents) != 2 || h.Height != 9 || h.Difficulty.Cmp(header.Difficulty) != 0 {
+		t.Error("Decoded header fields mismatch")
+	}
+	if h.QualityScore != 0.5 || h.ReputationScore != 1.25 || string(h.ExtraData) != "ccoin" {
+		t.Error("Decoded header scores or extra data mismatch")
+	}
+	if len(decoded.Transactions) != 1 || decoded.Transactions[0].Fee != 100 {
+		t.Error("Decoded transactions mismatch")
+	}
+
+	// Truncated input is rejected
+	if _, err := p2p.DecodeBlock(data[:len(data)-1]); err == nil {
+		t.Error("Truncated block should fail to decode")
+	}
+}
+
+// Test that encodings survive a decode unchanged, so cached encodings can
+// be served in place of fresh ones, and that gossip handlers reuse the
+// values decoded during validation
+func TestMessageReuse(t *testing.T) {
+	header := &types.BlockHeader{Version: 1, Parents: []types.Hash{{1}}, Difficulty: big.NewInt(1), Height: 1}
+	header.Hash = header.ComputeHash()
+	tx := &types.Transaction{Version: 1, Fee: 5, Memo: []byte("memo")}
+	tx.TxHash = tx.ComputeHash()
+	block := types.NewBlock(header, []*types.Transaction{tx})
+
+	data, _ := p2p.EncodeBlock(block)
+	decoded, err := p2p.DecodeBlock(data)
+	if err != nil {
+		t.Fatalf("DecodeBlock failed: %v", err)
+	}
+	if again, _ := p2p.EncodeBlock(decoded); !bytes.Equal(again, data) {
+		t.Error("Re-encoding a decoded block should give the same bytes")
+	}
+
+	list, _ := p2p.EncodeBlocks([]*types.Block{block, decoded})
+	blocks, err := p2p.DecodeBlocks(list)
+	if err != nil || len(blocks) != 2 || blocks[1].Header.Hash != header.Hash {
+		t.Fatalf("Decoded blocks mismatch: %v", err)
+	}
+
+	msg := &pubsub.Message{Message: &pb.Message{Data: data}, ValidatorData: decoded}
+	if got, err := p2p.BlockFromMessage(msg); err != nil || got != decoded {
+		t.Error("BlockFromMessage should reuse the validated block")
+	}
+	msg.ValidatorData = nil
+	if got, err := p2p.BlockFromMessage(msg); err != nil || got.Header.Hash != header.Hash {
+		t.Errorf("BlockFromMessage should decode unvalidated messages: %v", err)
+	}
+
+	txData, _ := p2p.EncodeTransaction(tx)
+	msg = &pubsub.Message{Message: &pb.Message{Data: txData}}
+	if got, err := p2p.TransactionFromMessage(msg); err != nil || got.TxHash != tx.TxHash {
+		t.Errorf("TransactionFromMessage should decode unvalidated messages: %v", err)
+	}
+
+	// Framing round trip
+	var stream bytes.Buffer
+	network := p2p.NetworkIDFor("devnet")
+	if err := (&p2p.Message{Type: p2p.MsgTypeBlocks, Payload: list, NetworkID: network}).Encode(&stream); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	var framed p2p.Message
+	if err := framed.Decode(&stream); err != nil || framed.Type != p2p.MsgTypeBlocks || framed.NetworkID != network || !bytes.Equal(framed.Payload, list) {
+		t.Errorf("Decoded message mismatch: %v", err)
+	}
+}
+
+// Test sync protocol message encoding
+func TestSyncMessages(t *testing.T) {
+	headers := []*types.BlockHeader{
+		{Version: 1, Height: 1, Difficulty: big.NewInt(1)},
+		{Version: 1, Height: 2, Difficulty: big.NewInt(2), Parents: []types.Hash{{1}}},
+	}
+	data, err := p2p.EncodeHeaders(headers)
+	if err != nil {
+		t.Fatalf("EncodeHeaders failed: %v", err)
+	}
+	decoded, err := p2p.DecodeHeaders(data)
+	if err != nil {
+		t.Fatalf("DecodeHeaders failed: %v", err)
+	}
+	if len(decoded) != 2 || decoded[1].Height != 2 || len(decoded[1].Parents) != 1 {
+		t.Error("Decoded headers mismatch")
+	}
+
+	req := &p2p.GetBlocksMessage{Hashes: []types.Hash{{1}, {2}, {3}}}
+	data, err = p2p.EncodeGetBlocks(req)
+	if err != nil {
+		t.Fatalf("EncodeGetBlocks failed: %v", err)
+	}
+	got, err := p2p.DecodeGetBlocks(data)
+	if err != nil {
+		t.Fatalf("DecodeGetBlocks failed: %v", err)
+	}
+	if len(got.Hashes) != 3 || got.Hashes[2] != req.Hashes[2] {
+		t.Error("Decoded GetBlocks mismatch")
+	}
+
+	data, _ = p2p.EncodeGetHeaders(&p2p.GetHeadersMessage{FromHeight: 100, Count: 50})
+	gh, err := p2p.DecodeGetHeaders(data)
+	if err != nil || gh.FromHeight != 100 || gh.Count != 50 {
+		t.Errorf("Decoded GetHeaders mismatch: %+v, %v", gh, err)
+	}
+}
+
+// Test that the pruned height survives a status round trip and is zero
+// from nodes that predate it
+func TestStatusPrunedHeight(t *testing.T) {
+	data, err := p2p.EncodeStatus(&p2p.StatusMessage{Version: p2p.SyncProtocolVersion, Height: 5000, Roles: p2p.RoleRelay, PrunedHeight: 3000})
+	if err != nil {
+		t.Fatalf("EncodeStatus failed: %v", err)
+	}
+	status, err := p2p.DecodeStatus(data)
+	if err != nil || status.PrunedHeight != 3000 || status.Roles != p2p.RoleRelay {
+		t.Fatalf("Decoded status mismatch: %+v %v", status, err)
+	}
+	if status, err = p2p.DecodeStatus(data[:84]); err != nil || status.PrunedHeight != 0 {
+		t.Errorf("Status without a pruned height decoded as %+v %v", status, err)
+	}
+}
+
+// Test light client messages round trip with their proofs intact
+func TestLightMessages(t *testing.T) {
+	notes := []*p2p.BlockNotes{{
+		Hash:        types.Hash{1},
+		Height:      7,
+		Offset:      12,
+		Commitments: []types.Commitment{{Value: types.Hash{2}, EncryptedNote: []byte("note")}, {Value: types.Hash{3}}},
+		Nullifiers:  []types.Hash{{4}},
+	}}
+	data, err := p2p.EncodeBlockNotes(notes)
+	if err != nil {
+		t.Fatalf("EncodeBlockNotes failed: %v", err)
+	}
+	decoded, err := p2p.DecodeBlockNotes(data)
+	if err != nil {
+		t.Fatalf("DecodeBlockNotes failed: %v", err)
+	}
+	if len(decoded) != 1 || decoded[0].Offset != 12 || len(decoded[0].Commitments) != 2 ||
+		string(decoded[0].Commitments[0].EncryptedNote) != "note" || decoded[0].Nullifiers[0] != (types.Hash{4}) {
+		t.Errorf("Decoded note data mismatch: %+v", decoded)
+	}
+	if _, err := p2p.DecodeBlockNotes(data[:len(data)-1]); err == nil {
+		t.Error("Truncated note data decoded")
+	}
+
+	// A state proof still verifies after a round trip
+	tree := state.NewTree()
+	tree.Set(state.CommitmentsKey, types.Hash{5})
+	tree.Set(state.SupplyKey, state.AmountValue(100))
+	data, _ = p2p.EncodeProof(&p2p.ProofMessage{Value: types.Hash{5}, Proof: tree.Prove(state.CommitmentsKey)})
+	proof, err := p2p.DecodeProof(data)
+	if err != nil {
+		t.Fatalf("DecodeProof failed: %v", err)
+	}
+	if !state.VerifyProof(tree.Root(), state.CommitmentsKey, proof.Value, proof.Proof) {
+		t.Error("Decoded state proof does not verify")
+	}
+
+	// So does a commitment tree path
+	ctx := context.Background()
+	commitments := zkp.NewCommitmentTree(zkp.NewInMemoryTreeStore(), zkp.TreeDepth)
+	for i := byte(1); i <= 5; i++ {
+		if _, err := commitments.AddCommitment(ctx, types.Hash{i}); err != nil {
+			t.Fatalf("AddCommitment failed: %v", err)
+		}
+	}
+	path, err := commitments.GetPath(ctx, 3)
+	if err != nil {
+		t.Fatalf("GetPath failed: %v", err)
+	}
+	data, _ = p2p.EncodePath(path)
+	got, err := p2p.DecodePath(data)
+	if err != nil {
+		t.Fatalf("DecodePath failed: %v", err)
+	}
+	if got.LeafPosition != 3 || !zkp.VerifyMerklePath(types.Hash{4}, got, commitments.GetRoot()) {
+		t.Error("Decoded Merkle path does not verify")
+	}
+
+	data, _ = p2p.EncodeGetPath(&p2p.GetPathMessage{Hash: types.Hash{6}, Position: 3})
+	gp, err := p2p.DecodeGetPath(data)
+	if err != nil || gp.Hash != (types.Hash{6}) || gp.Position != 3 {
+		t.Errorf("Decoded GetPath mismatch: %+v, %v", gp, err)
+	}
+}
+
+// Test task encoding round trip
+func TestTaskEncoding(t *testing.T) {
+	task := &types.Task{
+		TaskID:        types.Hash{1},
+		ModelID:       types.Hash{2},
+		DatasetCID:    "bafy-data",
+		BatchStart:    64,
+		BatchSize:     32,
+		Objective:     types.Hash{3},
+		Status:        types.TaskStatusAssigned,
+		AssignedMiner: types.Address{4},
+		AssignedAt:    10,
+		Deadline:      20,
+		Reward:        500,
+	}
+	data, err := p2p.EncodeTask(task)
+	if err != nil {
+		t.Fatalf("EncodeTask failed: %v", err)
+	}
+	decoded, err := p2p.DecodeTask(data)
+	if err != nil {
+		t.Fatalf("DecodeTask failed: %v", err)
+	}
+	if decoded.TaskID != task.TaskID || decoded.DatasetCID != "bafy-data" || decoded.BatchSize != 32 ||
+		decoded.AssignedMiner != task.AssignedMiner || decoded.Deadline != 20 || decoded.Reward != 500 {
+		t.Errorf("Decoded task mismatch: %+v", decoded)
+	}
+	if _, err := p2p.DecodeTask(data[:len(data)-1]); err == nil {
+		t.Error("Truncated task decoded")
+	}
+}
+
+// Test transaction announcements round trip and are bounded
+func TestInventoryEncoding(t *testing.T) {
+	msg := &p2p.InventoryMessage{Hashes: []types.Hash{{1}, {2}, {3}}}
+	data, err := p2p.EncodeInventory(msg)
+	if err != nil {
+		t.Fatalf("EncodeInventory failed: %v", err)
+	}
+	decoded, err := p2p.DecodeInventory(data)
+	if err != nil {
+		t.Fatalf("DecodeInventory failed: %v", err)
+	}
+	if len(decoded.Hashes) != 3 || decoded.Hashes[2] != (types.Hash{3}) {
+		t.Errorf("Decoded inventory mismatch: %v", decoded.Hashes)
+	}
+	if _, err := p2p.DecodeInventory(data[:len(data)-1]); err == nil {
+		t.Error("Truncated inventory decoded")
+	}
+
+	large := &p2p.InventoryMessage{Hashes: make([]types.Hash, p2p.MaxAnnouncedTxs+1)}
+	if _, err := p2p.EncodeInventory(large); !errors.Is(err, p2p.ErrMessageTooLarge) {
+		t.Errorf("Expected ErrMessageTooLarge encoding, got %v", err)
+	}
+	data = binary.BigEndian.AppendUint16(nil, p2p.MaxAnnouncedTxs+1)
+	data = append(data, make([]byte, (p2p.MaxAnnouncedTxs+1)*types.HashSize)...)
+	if _, err := p2p.DecodeInventory(data); !errors.Is(err, p2p.ErrMessageTooLarge) {
+		t.Errorf("Expected ErrMessageTooLarge decoding, got %v", err)
+	}
+}
+
+// Test the handshake accepts peers on the same network and chain only
+func TestStatusHandshake(t *testing.T) {
+	testnet := p2p.NetworkIDFor("testnet")
+	if testnet == p2p.NetworkIDFor("mainnet") {
+		t.Fatal("Networks should have distinct IDs")
+	}
+	local := &p2p.StatusMessage{Version: p2p.SyncProtocolVersion, NetworkID: testnet, GenesisHash: types.Hash{1}}
+
+	for _, c := range []struct {
+		name   string
+		remote p2p.StatusMessage
+		want   error
+	}{
+		{"compatible", p2p.StatusMessage{Version: p2p.SyncProtocolVersion, NetworkID: testnet, GenesisHash: types.Hash{1}}, nil},
+		{"no chain yet", p2p.StatusMessage{Version: p2p.SyncProtocolVersion, NetworkID: testnet}, nil},
+		{"version", p2p.StatusMessage{Version: p2p.SyncProtocolVersion + 1, NetworkID: testnet}, p2p.ErrSyncProtocolVersion},
+		{"network", p2p.StatusMessage{Version: p2p.SyncProtocolVersion, NetworkID: testnet + 1}, p2p.ErrWrongNetwork},
+		{"genesis", p2p.StatusMessage{Version: p2p.SyncProtocolVersion, NetworkID: testnet, GenesisHash: types.Hash{2}}, p2p.ErrWrongGenesis},
+	} {
+		data, err := p2p.EncodeStatus(&c.remote)
+		if err != nil {
+			t.Fatalf("EncodeStatus failed: %v", err)
+		}
+		remote, err := p2p.DecodeStatus(data)
+		if err != nil {
+			t.Fatalf("DecodeStatus failed: %v", err)
+		}
+		if err := p2p.CheckStatus(local, remote); err != c.want {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, err)
+		}
+	}
+
+	// A node without a chain yet accepts any genesis block
+	fresh := &p2p.StatusMessage{Version: p2p.SyncProtocolVersion, NetworkID: testnet}
+	if err := p2p.CheckStatus(fresh, local); err != nil {
+		t.Errorf("Fresh node rejected a peer: %v", err)
+	}
+}
+
+// Test peers are grouped by network and concentration is reported
+func TestPeerDiversity(t *testing.T) {
+	for addr, want := range map[string]string{
+		"/ip4/203.0.113.7/tcp/9000":       "203.0.0.0/16",
+		"/ip4/192.168.1.5/tcp/9000":       p2p.LocalNetwork,
+		"/ip6/::1/tcp/9000":               p2p.LocalNetwork,
+		"/ip6/2001:db8:1:2::5/udp/9000":   "2001:db8::/32",
+		"/dns4/seed.example.org/tcp/9000": "",
+	} {
+		if got := p2p.NetworkOf(multiaddr.StringCast(addr)); got != want {
+			t.Errorf("NetworkOf(%s) = %q, want %q", addr, got, want)
+		}
+	}
+
+	peers := []*p2p.PeerInfo{
+		{Network: "203.0.0.0/16", Outbound: true},
+		{Network: "203.0.0.0/16", Outbound: true},
+		{Network: "203.0.0.0/16", Outbound: true},
+		{Network: "198.51.0.0/16", Outbound: true},
+		{Network: p2p.LocalNetwork},
+		{Network: p2p.LocalNetwork},
+	}
+	stats := p2p.ComputeDiversity(peers, nil)
+	if stats.Peers != 6 || stats.Outbound != 4 || len(stats.Networks) != 3 {
+		t.Fatalf("Unexpected totals: %+v", stats)
+	}
+	if top := stats.Networks[0]; top.Network != "203.0.0.0/16" || top.Peers != 3 || top.Outbound != 3 {
+		t.Errorf("Expected the busiest network first, got %+v", top)
+	}
+	if len(stats.Warnings) != 1 || !strings.Contains(stats.Warnings[0], "3 of 4 outbound peers") {
+		t.Errorf("Expected an outbound concentration warning, got %v", stats.Warnings)
+	}
+
+	// The local network is never reported as concentrated
+	stats = p2p.ComputeDiversity([]*p2p.PeerInfo{
+		{Network: p2p.LocalNetwork, Outbound: true},
+		{Network: p2p.LocalNetwork, Outbound: true},
+		{Network: p2p.LocalNetwork, Outbound: true},
+		{Network: p2p.LocalNetwork, Outbound: true},
+	}, nil)
+	if len(stats.Warnings) != 0 {
+		t.Errorf("Expected no warnings for local peers, got %v", stats.Warnings)
+	}
+}
+
+// Test that static relays must name their peer
+func TestParseRelays(t *testing.T) {
+	const id = "12D3KooWGzxzKZYveHXtpG6AsrUJBcWxHBFS2HsEoGTxrMLvKXtf"
+	relays, err := p2p.ParseRelays([]string{
+		"/ip4/203.0.113.7/tcp/9000/p2p/" + id,
+		" /ip4/203.0.113.7/udp/9000/quic-v1/p2p/" + id,
+	})
+	if err != nil {
+		t.Fatalf("ParseRelays failed: %v", err)
+	}
+	if len(relays) != 1 || relays[0].ID.String() != id || len(relays[0].Addrs) != 2 {
+		t.Errorf("Addresses of one relay should be merged: %+v", relays)
+	}
+
+	for _, addr := range []string{"/ip4/203.0.113.7/tcp/9000", "not-an-address"} {
+		if _, err := p2p.ParseRelays([]string{addr}); err == nil {
+			t.Errorf("ParseRelays(%q) should fail", addr)
+		}
+	}
+}
+
+// Test nullifier filter and spent proof encoding round trips
+func TestNullifierFilterEncoding(t *testing.T) {
+	f := zkp.NewNullifierFilter(3, types.Hash{0x07}, 100, 1e-6)
+	f.Add(types.Hash{0x01})
+	data, err := p2p.EncodeNullifierFilter(f)
+	if err != nil {
+		t.Fatalf("EncodeNullifierFilter failed: %v", err)
+	}
+	if !bytes.HasPrefix(data, f.Hash[:]) {
+		t.Error("Encoded filter should lead with its block hash")
+	}
+	decoded, err := p2p.DecodeNullifierFilter(data)
+	if err != nil {
+		t.Fatalf("DecodeNullifierFilter failed: %v", err)
+	}
+	if !decoded.Equal(f) || !decoded.MayContain(types.Hash{0x01}) {
+		t.Errorf("Filter changed in round trip: %+v", decoded)
+	}
+	if _, err := p2p.DecodeNullifierFilter(data[:len(data)-1]); err == nil {
+		t.Error("Truncated filter should not decode")
+	}
+
+	list, err := p2p.EncodeNullifierFilters([]*zkp.NullifierFilter{f, f})
+	if err != nil {
+		t.Fatalf("EncodeNullifierFilters failed: %v", err)
+	}
+	filters, err := p2p.DecodeNullifierFilters(list)
+	if err != nil || len(filters) != 2 || !filters[1].Equal(f) {
+		t.Errorf("Filter list changed in round trip: %v", err)
+	}
+
+	path := &zkp.MerklePath{Siblings: []types.Hash{{0x02}, {0x03}}, PathBits: []bool{true, false}, LeafPosition: 2}
+	for _, msg := range []*p2p.SpentMessage{{}, {Spent: true, Path: path}} {
+		data, err := p2p.EncodeSpent(msg)
+		if err != nil {
+			t.Fatalf("EncodeSpent failed: %v", err)
+		}
+		decoded, err := p2p.DecodeSpent(data)
+		if err != nil {
+			t.Fatalf("DecodeSpent failed: %v", err)
+		}
+		if decoded.Spent != msg.Spent || (msg.Spent && decoded.Path.LeafPosition != path.LeafPosition) {
+			t.Errorf("Spent proof changed in round trip: %+v", decoded)
+		}
+	}
+}
+
+// Test gossip profiles keep a valid mesh degree
+func TestGossipProfiles(t *testing.T) {
+	for _, name := range []string{p2p.GossipProfileDatacenter, p2p.GossipProfileHome, p2p.GossipProfileMobile} {
+		g, err := p2p.GossipProfile(name)
+		if err != nil {
+			t.Fatalf("GossipProfile(%s) failed: %v", name, err)
+		}
+		if g.Dlo > g.D || g.D > g.Dhi {
+			t.Errorf("%s: invalid degree %d/%d/%d", name, g.Dlo, g.D, g.Dhi)
+		}
+		if g.ValidationTimeouts[p2p.BlockTopic] == 0 || g.TaskRateLimit <= 0 {
+			t.Errorf("%s: missing block timeout or task rate limit", name)
+		}
+	}
+
+	if _, err := p2p.GossipProfile("satellite"); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+}
+
+// Test that each network gossips on its own topics
+func TestTopicName(t *testing.T) {
+	mainnet, testnet := p2p.NetworkIDFor("mainnet"), p2p.NetworkIDFor("testnet")
+	if p2p.TopicName(p2p.BlockTopic, mainnet) == p2p.TopicName(p2p.BlockTopic, testnet) {
+		t.Error("Networks share a block topic")
+	}
+	if p2p.TopicName(p2p.BlockTopic, mainnet) == p2p.TopicName(p2p.TransactionTopic, mainnet) {
+		t.Error("Topics share a name on one network")
+	}
+	if name := p2p.TopicName(p2p.BlockTopic, mainnet); !strings.HasPrefix(name, p2p.BlockTopic+"/") {
+		t.Errorf("Block topic on mainnet is %q", name)
+	}
+}
+
+// Test that a saved node identity keeps its peer ID
+func TestNodeIdentity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), p2p.DefaultIdentityFile)
+
+	key, err := p2p.CreateIdentity(path)
+	if err != nil {
+		t.Fatalf("CreateIdentity failed: %v", err)
+	}
+	if _, err := p2p.CreateIdentity(path); err != p2p.ErrIdentityExists {
+		t.Errorf("Expected ErrIdentityExists, got %v", err)
+	}
+
+	loaded, err := p2p.LoadIdentity(path)
+	if err != nil {
+		t.Fatalf("LoadIdentity failed: %v", err)
+	}
+	if !key.Equals(loaded) {
+		t.Error("Loaded identity differs from the saved one")
+	}
+}
+
+// Test that a private network key reads back as saved
+func TestPrivateNetworkKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), p2p.DefaultPSKFile)
+
+	psk, err := p2p.CreatePSK(path)
+	if err != nil {
+		t.Fatalf("CreatePSK failed: %v", err)
+	}
+	if _, err := p2p.CreatePSK(path); err != p2p.ErrPSKExists {
+		t.Errorf("Expected ErrPSKExists, got %v", err)
+	}
+
+	loaded, err := p2p.LoadPSK(path)
+	if err != nil {
+		t.Fatalf("LoadPSK failed: %v", err)
+	}
+	if !bytes.Equal(psk, loaded) {
+		t.Error("Loaded key differs from the saved one")
+	}
+}
+
+// Test advertising node roles in sync status messages
+func TestStatusRoles(t *testing.T) {
+	roles := p2p.RoleRelay | p2p.RoleShielded | p2p.RoleRPC
+	data, err := p2p.EncodeStatus(&p2p.StatusMessage{Version: p2p.SyncProtocolVersion, Height: 7, Roles: roles})
+	if err != nil {
+		t.Fatalf("EncodeStatus failed: %v", err)
+	}
+	status, err := p2p.DecodeStatus(data)
+	if err != nil {
+		t.Fatalf("DecodeStatus failed: %v", err)
+	}
+	if status.Roles != roles || status.Height != 7 {
+		t.Errorf("Status mismatch: %+v", status)
+	}
+	if got := roles.String(); got != "relay,shielded,rpc" {
+		t.Errorf("Expected relay,shielded,rpc, got %s", got)
+	}
+	if roles.Has(p2p.RoleMiner) || !roles.Has(p2p.RoleShielded|p2p.RoleRPC) {
+		t.Error("Has reports the wrong roles")
+	}
+
+	// Peers that predate role reporting send shorter status messages
+	legacy, err := p2p.DecodeStatus(data[:80])
+	if err != nil {
+		t.Fatalf("DecodeStatus failed on a legacy status: %v", err)
+	}
+	if legacy.Roles != 0 || legacy.Roles.Strings() != nil || legacy.Roles.String() != "unknown" {
+		t.Errorf("Expected unknown roles, got %v", legacy.Roles.Strings())
+	}
+}
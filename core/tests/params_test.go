@@ -0,0 +1,114 @@
+// Package tests provides tests for the consensus parameter registry.
+package tests
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/ccoin/core/internal/params"
+	"github.com/ccoin/core/internal/reputation"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Test governance changes to consensus parameters
+func TestConsensusParams(t *testing.T) {
+	ctx := context.Background()
+	store := params.NewInMemoryStore()
+	registry := params.NewRegistry(store)
+	if err := registry.Register(reputation.ParamDefinitions...); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := registry.Register(reputation.ParamDefinitions...); err != nil {
+		t.Errorf("Re-registering identical definitions failed: %v", err)
+	}
+	conflict := params.Definition{Name: reputation.ParamEWMAAlpha, Default: 0.2, Min: 0, Max: 1}
+	if err := registry.Register(conflict); !errors.Is(err, params.ErrParamConflict) {
+		t.Errorf("Expected ErrParamConflict, got %v", err)
+	}
+
+	if v := registry.Value(reputation.ParamEWMAAlpha, 0); v != reputation.EWMAAlpha {
+		t.Errorf("Expected default alpha %v, got %v", reputation.EWMAAlpha, v)
+	}
+
+	// Bounds and activation are checked
+	for _, data := range []*types.ConsensusParameterData{
+		{Changes: []types.ConsensusParamChange{{Name: reputation.ParamEWMAAlpha, Value: 0.9}}, ActivationHeight: 500},
+		{Changes: []types.ConsensusParamChange{{Name: reputation.ParamBanDurationBlocks, Value: 2000.5}}, ActivationHeight: 500},
+		{Changes: []types.ConsensusParamChange{{Name: reputation.ParamEWMAAlpha, Value: math.NaN()}}, ActivationHeight: 500},
+	} {
+		if err := registry.Validate(data, 100); !errors.Is(err, params.ErrParamOutOfBounds) {
+			t.Errorf("Expected ErrParamOutOfBounds for %+v, got %v", data.Changes[0], err)
+		}
+	}
+	unknown := &types.ConsensusParameterData{Changes: []types.ConsensusParamChange{{Name: "nope", Value: 1}}, ActivationHeight: 500}
+	if err := registry.Validate(unknown, 100); !errors.Is(err, params.ErrUnknownParam) {
+		t.Errorf("Expected ErrUnknownParam, got %v", err)
+	}
+
+	proposal := &types.Proposal{
+		ProposalID:     types.Hash{1},
+		Type:           types.ProposalParameterAdjust,
+		Status:         types.ProposalStatusPassed,
+		VotingEndBlock: 100,
+		Data: &types.ConsensusParameterData{
+			Changes:          []types.ConsensusParamChange{{Name: reputation.ParamEWMAAlpha, Value: 0.5}},
+			ActivationHeight: 100,
+		},
+	}
+	if err := registry.ApplyProposal(ctx, proposal); err != params.ErrActivationTooEarly {
+		t.Errorf("Expected ErrActivationTooEarly, got %v", err)
+	}
+	proposal.Data.(*types.ConsensusParameterData).ActivationHeight = 1500
+	if err := registry.ApplyProposal(ctx, proposal); err != nil {
+		t.Fatalf("ApplyProposal failed: %v", err)
+	}
+	if v := registry.Value(reputation.ParamEWMAAlpha, 1499); v != reputation.EWMAAlpha {
+		t.Errorf("Change applied before activation: %v", v)
+	}
+	if v := registry.Value(reputation.ParamEWMAAlpha, 1500); v != 0.5 {
+		t.Errorf("Expected alpha 0.5 at activation, got %v", v)
+	}
+
+	// Reputation follows the schedule: identical histories diverge only
+	// from the activation height
+	withDefaults := reputation.NewEpochManager(newCountingReputationStore())
+	tuned := reputation.NewEpochManager(newCountingReputationStore())
+	if err := tuned.SetParams(registry); err != nil {
+		t.Fatalf("SetParams failed: %v", err)
+	}
+	miner := types.Address{7}
+	process := func(height uint64) {
+		block := &types.Block{Header: &types.BlockHeader{Height: height, MinerAddress: miner, QualityScore: 2}}
+		for _, em := range []*reputation.EpochManager{withDefaults, tuned} {
+			if err := em.ProcessBlock(ctx, block); err != nil {
+				t.Fatalf("ProcessBlock at %d failed: %v", height, err)
+			}
+		}
+	}
+	process(1499)
+	if withDefaults.GetMinerReputation(miner) != tuned.GetMinerReputation(miner) {
+		t.Error("Reputation diverged before activation")
+	}
+	before := tuned.GetMinerReputation(miner)
+	process(1500)
+	if got, want := tuned.GetMinerReputation(miner), 0.5*2+0.5*before; math.Abs(got-want) > 1e-9 {
+		t.Errorf("Expected reputation %v with the new alpha, got %v", want, got)
+	}
+	if withDefaults.GetMinerReputation(miner) >= tuned.GetMinerReputation(miner) {
+		t.Error("Higher alpha did not move reputation faster")
+	}
+
+	// A restarted registry reloads the schedule
+	reloaded := params.NewRegistry(store)
+	if err := reloaded.Register(reputation.ParamDefinitions...); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := reloaded.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if v := reloaded.Value(reputation.ParamEWMAAlpha, 2000); v != 0.5 {
+		t.Errorf("Reloaded registry lost the change: %v", v)
+	}
+}
@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ccoin/core/internal/reputation"
+	"github.com/ccoin/core/internal/storage"
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Test that the embedded store keeps the DAG, nullifiers and miners
+// across a reopen and prunes like the PostgreSQL store
+func TestPebbleStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := storage.Open(ctx, &storage.Config{Backend: storage.BackendPebble, Dir: dir})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	genesis := &types.Block{Header: &types.BlockHeader{Hash: types.Hash{1}, Height: 0, Timestamp: 100}}
+	tx := &types.Transaction{TxHash: types.Hash{0xaa}, Nullifiers: []types.Hash{{0xbb}}, Memo: []byte("memo")}
+	child := &types.Block{
+		Header:       &types.BlockHeader{Hash: types.Hash{2}, Parents: []types.Hash{{1}}, Height: 1, Timestamp: 200},
+		Transactions: []*types.Transaction{tx},
+	}
+	for _, b := range []*types.Block{genesis, child} {
+		if err := store.SaveBlock(ctx, b); err != nil {
+			t.Fatalf("SaveBlock failed: %v", err)
+		}
+	}
+	if err := store.UpdateMainChain(ctx, []types.Hash{{1}, {2}}, nil); err != nil {
+		t.Fatalf("UpdateMainChain failed: %v", err)
+	}
+
+	tips, _ := store.GetTips(ctx)
+	if len(tips) != 1 || tips[0] != child.Header.Hash {
+		t.Errorf("Unexpected tips %v", tips)
+	}
+	children, _ := store.GetChildren(ctx, genesis.Header.Hash)
+	if len(children) != 1 || children[0] != child.Header.Hash {
+		t.Errorf("Unexpected children %v", children)
+	}
+	if _, err := store.GetBlockHeader(ctx, types.Hash{3}); err != storage.ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if err := store.AddNullifier(ctx, types.Hash{0xbb}, tx.TxHash, 1); err != zkp.ErrNullifierSpent {
+		t.Errorf("Expected ErrNullifierSpent, got %v", err)
+	}
+
+	// A rejected spend writes neither its other nullifiers nor its anchor
+	anchor := types.Hash{0xdd}
+	for want, nullifiers := range map[error][]types.Hash{
+		zkp.ErrNullifierSpent:  {{0xcc}, {0xbb}},
+		zkp.ErrNullifierRepeat: {{0xcc}, {0xcc}},
+	} {
+		if err := store.AddSpends(ctx, nullifiers, tx.TxHash, 1, &anchor); err != want {
+			t.Errorf("AddSpends(%x) = %v, want %v", nullifiers, err, want)
+		}
+	}
+	if spent, _ := store.HasNullifier(ctx, types.Hash{0xcc}); spent {
+		t.Error("Rejected spend left a nullifier")
+	}
+	if roots, _ := store.RecentAnchors(ctx, 10); len(roots) != 0 {
+		t.Errorf("Rejected spend left anchors %v", roots)
+	}
+	if err := store.AddSpends(ctx, []types.Hash{{0xcc}}, tx.TxHash, 1, &anchor); err != nil {
+		t.Fatalf("AddSpends failed: %v", err)
+	}
+	if roots, _ := store.RecentAnchors(ctx, 10); len(roots) != 1 || roots[0] != anchor {
+		t.Errorf("Anchors %v, want %v", roots, anchor)
+	}
+
+	stake := &reputation.StakeInfo{Address: types.Address{0x11}, TotalStaked: 1000}
+	if err := store.SaveStake(ctx, stake); err != nil {
+		t.Fatalf("SaveStake failed: %v", err)
+	}
+	if missing, err := store.GetStake(ctx, types.Address{0x22}); missing != nil || err != nil {
+		t.Errorf("Expected no stake, got %v, %v", missing, err)
+	}
+	store.Close()
+
+	store, err = storage.Open(ctx, &storage.Config{Backend: storage.BackendPebble, Dir: dir})
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer store.Close()
+
+	chain, err := store.GetMainChain(ctx, 0, 10)
+	if err != nil || len(chain) != 2 || chain[1].Hash != child.Header.Hash {
+		t.Fatalf("Unexpected main chain %v, %v", chain, err)
+	}
+	byTime, _ := store.GetMainChainByTime(ctx, 150, 250)
+	if len(byTime) != 1 || len(byTime[0].Transactions) != 1 {
+		t.Errorf("Unexpected blocks by time %v", byTime)
+	}
+	info, err := store.GetNullifierInfo(ctx, types.Hash{0xbb})
+	if err != nil || info.TxHash != tx.TxHash || info.BlockHeight != 1 {
+		t.Errorf("Unexpected nullifier info %+v, %v", info, err)
+	}
+	if got, _ := store.GetStake(ctx, stake.Address); got == nil || got.TotalStaked != 1000 {
+		t.Errorf("Stake not persisted: %+v", got)
+	}
+
+	// Pruning strips the bodies below the height and records it
+	if n, err := store.PruneBlocks(ctx, 2); err != nil || n != 2 {
+		t.Fatalf("PruneBlocks pruned %d: %v", n, err)
+	}
+	if h, _ := store.PrunedHeight(ctx); h != 2 {
+		t.Errorf("Pruned height %d, want 2", h)
+	}
+	block, err := store.GetBlock(ctx, child.Header.Hash)
+	if err != nil || len(block.Transactions) != 1 || block.Transactions[0].Memo != nil {
+		t.Errorf("Transaction not pruned: %+v, %v", block, err)
+	}
+}
+
+// Test the transaction, nullifier and commitment lookups explorers use
+func TestPebbleExplorerIndexes(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(ctx, &storage.Config{Backend: storage.BackendPebble, Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	spend := &types.Transaction{
+		TxHash:      types.Hash{0xaa},
+		Nullifiers:  []types.Hash{{0xbb}},
+		Commitments: []types.Commitment{{Value: types.Hash{0xc1}, EncryptedNote: []byte("note")}, {Value: types.Hash{0xc2}}},
+	}
+	later := &types.Transaction{TxHash: types.Hash{0xab}, Commitments: []types.Commitment{{Value: types.Hash{0xc3}}}}
+	blocks := []*types.Block{
+		{Header: &types.BlockHeader{Hash: types.Hash{1}, Height: 0}},
+		{Header: &types.BlockHeader{Hash: types.Hash{2}, Parents: []types.Hash{{1}}, Height: 1}, Transactions: []*types.Transaction{spend}},
+		{Header: &types.BlockHeader{Hash: types.Hash{3}, Parents: []types.Hash{{2}}, Height: 2}, Transactions: []*types.Transaction{later}},
+	}
+	for _, b := range blocks {
+		if err := store.SaveBlock(ctx, b); err != nil {
+			t.Fatalf("SaveBlock failed: %v", err)
+		}
+	}
+
+	record, err := store.GetTransaction(ctx, spend.TxHash)
+	if err != nil || record.BlockHash != (types.Hash{2}) || record.BlockHeight != 1 || record.Tx.TxHash != spend.TxHash {
+		t.Errorf("Unexpected transaction record %+v, %v", record, err)
+	}
+	if _, err := store.GetTransaction(ctx, types.Hash{0xff}); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if record, err := store.GetTransactionByNullifier(ctx, types.Hash{0xbb}); err != nil || record.Tx.TxHash != spend.TxHash {
+		t.Errorf("Unexpected spender %+v, %v", record, err)
+	}
+	if _, err := store.GetTransactionByNullifier(ctx, types.Hash{0xbc}); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unspent nullifier, got %v", err)
+	}
+
+	commitments, err := store.GetCommitments(ctx, 1, 1)
+	if err != nil || len(commitments) != 2 {
+		t.Fatalf("GetCommitments returned %d, %v", len(commitments), err)
+	}
+	first := commitments[0]
+	if first.Commitment.Value != (types.Hash{0xc1}) || string(first.Commitment.EncryptedNote) != "note" ||
+		first.TxHash != spend.TxHash || first.BlockHash != (types.Hash{2}) || commitments[1].Output != 1 {
+		t.Errorf("Unexpected commitment records %+v, %+v", first, commitments[1])
+	}
+	if all, _ := store.GetCommitments(ctx, 0, 10); len(all) != 3 || all[2].Commitment.Value != (types.Hash{0xc3}) {
+		t.Errorf("Unexpected commitments in range %+v", all)
+	}
+
+	// A parallel block including the same transaction does not move its
+	// commitments
+	parallel := &types.Block{Header: &types.BlockHeader{Hash: types.Hash{4}, Parents: []types.Hash{{2}}, Height: 2}, Transactions: []*types.Transaction{later}}
+	if err := store.SaveBlock(ctx, parallel); err != nil {
+		t.Fatalf("SaveBlock failed: %v", err)
+	}
+	if all, _ := store.GetCommitments(ctx, 2, 2); len(all) != 1 || all[0].BlockHash != (types.Hash{3}) {
+		t.Errorf("Unexpected commitments after a parallel block %+v", all)
+	}
+}
@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ccoin/core/internal/p2p"
+	"github.com/ccoin/core/internal/rpc"
+)
+
+// peerList is a fixed set of connected peers
+type peerList []*p2p.PeerInfo
+
+func (l peerList) PeerCount() int         { return len(l) }
+func (l peerList) Peers() []*p2p.PeerInfo { return l }
+
+// Test that GetPeers reports each peer's counters, oldest connection
+// first, and includes the misbehavior log only when verbose
+func TestGetPeers(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+
+	misbehaving := &p2p.PeerInfo{
+		ID:          peer.ID("misbehaving"),
+		Addrs:       []multiaddr.Multiaddr{multiaddr.StringCast("/ip4/203.0.113.7/tcp/9000")},
+		ConnectedAt: now,
+		Stats: p2p.PeerStats{
+			BlocksRelayed:        4,
+			InvalidMessages:      2,
+			DuplicateMessages:    9,
+			AvgValidationLatency: 1500 * time.Microsecond,
+			Misbehavior: []p2p.Misbehavior{
+				{Time: now, Topic: p2p.BlockTopic, Reason: "bad proof"},
+				{Time: now.Add(time.Second), Topic: p2p.SyncProtocolID, Reason: "unrequested block"},
+			},
+		},
+	}
+	older := &p2p.PeerInfo{ID: peer.ID("older"), ConnectedAt: now.Add(-time.Hour)}
+	s := rpc.NewServer(nil, &rpc.Backends{Peers: peerList{misbehaving, older}})
+
+	resp, err := s.GetPeers(ctx, &rpc.GetPeersRequest{})
+	if err != nil {
+		t.Fatalf("GetPeers failed: %v", err)
+	}
+	if len(resp.Peers) != 2 || resp.Peers[0].ID != older.ID.String() {
+		t.Fatalf("Peers = %+v, want the older connection first", resp.Peers)
+	}
+	got := resp.Peers[1]
+	if got.BlocksRelayed != 4 || got.InvalidMessages != 2 || got.DuplicateMessages != 9 || got.AvgValidationMillis != 1.5 {
+		t.Errorf("Counters = %+v", got)
+	}
+	if got.Misbehaviors != 2 || got.Misbehavior != nil {
+		t.Errorf("Misbehavior = %d %+v, want a count and no log", got.Misbehaviors, got.Misbehavior)
+	}
+	if len(got.Addrs) != 1 || got.Addrs[0] != "/ip4/203.0.113.7/tcp/9000" {
+		t.Errorf("Addrs = %v", got.Addrs)
+	}
+
+	resp, err = s.GetPeers(ctx, &rpc.GetPeersRequest{Verbose: true})
+	if err != nil {
+		t.Fatalf("GetPeers failed: %v", err)
+	}
+	log := resp.Peers[1].Misbehavior
+	if len(log) != 2 || log[1].Topic != p2p.SyncProtocolID || log[1].Reason != "unrequested block" || log[1].Time != now.Unix()+1 {
+		t.Errorf("Verbose misbehavior log = %+v", log)
+	}
+
+	s = rpc.NewServer(nil, &rpc.Backends{})
+	if _, err := s.GetPeers(ctx, &rpc.GetPeersRequest{}); status.Code(err) != codes.Unimplemented {
+		t.Errorf("Expected Unimplemented without p2p, got %v", err)
+	}
+}
+
+// Test that misbehavior reported against a connected peer shows in its
+// snapshot and reaches the misbehavior handler
+func TestPeerMisbehavior(t *testing.T) {
+	ctx := context.Background()
+	newNode := func(bootstrap ...string) *p2p.Node {
+		cfg := p2p.DefaultConfig()
+		cfg.ListenAddrs = []string{"/ip4/127.0.0.1/tcp/0"}
+		cfg.BootstrapPeers = bootstrap
+		cfg.EnableMDNS = false
+		n, err := p2p.NewNode(ctx, cfg)
+		if err != nil {
+			t.Fatalf("NewNode failed: %v", err)
+		}
+		t.Cleanup(func() { n.Close() })
+		return n
+	}
+	a := newNode()
+	b := newNode(a.Addrs()[0].String() + "/p2p/" + a.ID().String())
+
+	var reported []p2p.Misbehavior
+	b.SetMisbehaviorHandler(func(id peer.ID, m p2p.Misbehavior) {
+		if id == a.ID() {
+			reported = append(reported, m)
+		}
+	})
+
+	// A report against a peer that is not connected is not kept
+	b.ReportMisbehavior(peer.ID("stranger"), p2p.BlockTopic, "ignored")
+	b.ReportMisbehavior(a.ID(), p2p.BlockTopic, "invalid block")
+	b.ReportMisbehavior(a.ID(), p2p.SyncProtocolID, "unrequested block")
+
+	var info *p2p.PeerInfo
+	for _, p := range b.Peers() {
+		if p.ID == a.ID() {
+			info = p
+		}
+	}
+	if info == nil {
+		t.Fatal("Bootstrap peer is not listed")
+	}
+	if len(info.Stats.Misbehavior) != 2 || info.Stats.Misbehavior[0].Reason != "invalid block" ||
+		info.Stats.Misbehavior[1].Topic != p2p.SyncProtocolID {
+		t.Errorf("Misbehavior log = %+v", info.Stats.Misbehavior)
+	}
+	if len(reported) != 2 {
+		t.Errorf("Handler saw %+v", reported)
+	}
+
+	// Snapshots are copies
+	info.Stats.Misbehavior[0].Reason = "changed"
+	for _, p := range b.Peers() {
+		if p.ID == a.ID() && p.Stats.Misbehavior[0].Reason != "invalid block" {
+			t.Error("Changing a snapshot changed the node's log")
+		}
+	}
+}
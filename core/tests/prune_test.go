@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ccoin/core/internal/storage"
+)
+
+// memPruneStore records the heights pruned below
+type memPruneStore struct {
+	pruned uint64
+	calls  []uint64
+}
+
+func (s *memPruneStore) PruneBlocks(ctx context.Context, below uint64) (int64, error) {
+	s.calls = append(s.calls, below)
+	n := int64(below - s.pruned)
+	s.pruned = below
+	return n, nil
+}
+
+func (s *memPruneStore) PrunedHeight(ctx context.Context) (uint64, error) {
+	return s.pruned, nil
+}
+
+type fixedHeight uint64
+
+func (h fixedHeight) GetHeight() uint64 { return uint64(h) }
+
+type fixedSnapshot uint64
+
+func (h fixedSnapshot) LatestHeight() uint64 { return uint64(h) }
+
+// Test that the pruner keeps Depth heights, prunes in batches, never
+// passes the latest snapshot and resumes from the store
+func TestPruner(t *testing.T) {
+	ctx := context.Background()
+	store := &memPruneStore{}
+	cfg := storage.DefaultPruneConfig()
+	cfg.Depth = storage.MinPruneDepth
+	cfg.BatchHeights = 400
+
+	if _, err := storage.NewPruner(ctx, store, fixedHeight(0), &storage.PruneConfig{Depth: 10, BatchHeights: 1}); err == nil {
+		t.Error("Pruner accepted a depth below the minimum")
+	}
+
+	// Nothing is pruned until the chain is deeper than Depth
+	pruner, err := storage.NewPruner(ctx, store, fixedHeight(cfg.Depth-1), cfg)
+	if err != nil {
+		t.Fatalf("NewPruner failed: %v", err)
+	}
+	if err := pruner.Prune(ctx); err != nil || len(store.calls) != 0 {
+		t.Fatalf("Pruned a shallow chain: %v %v", store.calls, err)
+	}
+
+	pruner, _ = storage.NewPruner(ctx, store, fixedHeight(cfg.Depth+1000), cfg)
+	pruner.SetSnapshots(fixedSnapshot(900))
+	if err := pruner.Prune(ctx); err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if pruner.PrunedHeight() != 900 || len(store.calls) != 3 || store.calls[0] != 400 || store.calls[2] != 900 {
+		t.Errorf("Pruned to %d in %v, want 900 in batches of 400", pruner.PrunedHeight(), store.calls)
+	}
+
+	// A new pruner resumes where the store left off
+	pruner, _ = storage.NewPruner(ctx, store, fixedHeight(cfg.Depth+1000), cfg)
+	if pruner.PrunedHeight() != 900 {
+		t.Errorf("Resumed at %d, want 900", pruner.PrunedHeight())
+	}
+	if err := pruner.Prune(ctx); err != nil || pruner.PrunedHeight() != 1000 {
+		t.Errorf("Pruned to %d without snapshots, want 1000: %v", pruner.PrunedHeight(), err)
+	}
+}
@@ -3,6 +3,7 @@ package tests
 
 import (
 	"context"
+	"sync"
 	"testing"
 
 	"github.com/ccoin/core/internal/reputation"
@@ -187,3 +188,117 @@ func (s *mockSlashingStore) SaveStake(ctx context.Context, addr types.Address, s
 func (s *mockSlashingStore) GetStake(ctx context.Context, addr types.Address) (uint64, error) {
 	return s.stakes[addr], nil
 }
+
+// countingReputationStore counts saves to check batched persistence
+type countingReputationStore struct {
+	mu     sync.Mutex
+	miners map[types.Address]reputation.MinerReputation
+	epochs map[uint64]*reputation.Epoch
+	saves  int
+}
+
+func newCountingReputationStore() *countingReputationStore {
+	return &countingReputationStore{
+		miners: make(map[types.Address]reputation.MinerReputation),
+		epochs: make(map[uint64]*reputation.Epoch),
+	}
+}
+
+func (s *countingReputationStore) SaveMiner(ctx context.Context, m *reputation.MinerReputation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.miners[m.Address] = *m
+	s.saves++
+	return nil
+}
+
+func (s *countingReputationStore) GetMiner(ctx context.Context, addr types.Address) (*reputation.MinerReputation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.miners[addr]
+	return &m, nil
+}
+
+func (s *countingReputationStore) ListMiners(ctx context.Context, limit int) ([]*reputation.MinerReputation, error) {
+	return nil, nil
+}
+
+func (s *countingReputationStore) SaveEpoch(ctx context.Context, e *reputation.Epoch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.epochs[e.EpochNumber] = e
+	return nil
+}
+
+func (s *countingReputationStore) GetEpoch(ctx context.Context, num uint64) (*reputation.Epoch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.epochs[num], nil
+}
+
+// Test the pipeline matches synchronous processing with batched saves
+func TestReputationPipeline(t *testing.T) {
+	ctx := context.Background()
+	miners := []types.Address{{1}, {2}, {3}}
+	blocks := make([]*types.Block, 2500)
+	for i := range blocks {
+		blocks[i] = &types.Block{Header: &types.BlockHeader{
+			Height:       uint64(i + 1),
+			MinerAddress: miners[i%len(miners)],
+			QualityScore: 0.5 + float64(i%7)/7,
+		}}
+	}
+
+	syncStore := newCountingReputationStore()
+	syncEM := reputation.NewEpochManager(syncStore)
+	for _, b := range blocks {
+		if err := syncEM.ProcessBlock(ctx, b); err != nil {
+			t.Fatalf("ProcessBlock failed: %v", err)
+		}
+	}
+
+	store := newCountingReputationStore()
+	em := reputation.NewEpochManager(store)
+	cfg := reputation.DefaultPipelineConfig()
+	cfg.BatchBlocks = 100
+	pipeline := reputation.NewPipeline(em, cfg)
+	pipeline.Start(ctx)
+	for i, b := range blocks {
+		if err := pipeline.Submit(ctx, b); err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+		if i == 1500 {
+			if err := pipeline.Flush(ctx); err != nil {
+				t.Fatalf("Flush failed: %v", err)
+			}
+			if em.GetMinerReputation(blocks[i].Header.MinerAddress) == reputation.InitialReputation {
+				t.Error("Flushed blocks should be applied")
+			}
+		}
+	}
+	if err := pipeline.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if err := pipeline.Submit(ctx, blocks[0]); err != reputation.ErrPipelineStopped {
+		t.Errorf("Expected ErrPipelineStopped, got %v", err)
+	}
+
+	// Same state and checkpoints, far fewer saves
+	for _, addr := range miners {
+		if em.GetMinerReputation(addr) != syncEM.GetMinerReputation(addr) || store.miners[addr] != syncStore.miners[addr] {
+			t.Errorf("Miner %x diverged from synchronous processing", addr[:1])
+		}
+	}
+	if em.StateRoot() != syncEM.StateRoot() {
+		t.Error("State roots should match")
+	}
+	for _, epoch := range []uint64{0, 1} {
+		a, b := store.epochs[epoch], syncStore.epochs[epoch]
+		if a == nil || b == nil || a.StateRoot == (types.Hash{}) || a.StateRoot != b.StateRoot {
+			t.Errorf("Epoch %d checkpoints should match", epoch)
+		}
+	}
+	if store.saves*10 > syncStore.saves {
+		t.Errorf("Expected batched saves, got %d vs %d synchronous", store.saves, syncStore.saves)
+	}
+}
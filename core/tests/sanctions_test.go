@@ -0,0 +1,113 @@
+// Package tests provides tests for the sanctions registry.
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ccoin/core/internal/sanctions"
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Test sanctions tree non-membership paths
+func TestSanctionsTree(t *testing.T) {
+	listed := []types.Address{{0x01}, {0x80, 0x01}, {0x80, 0x02}, {19: 0xff}}
+	tree := zkp.NewSanctionsTree(listed...)
+	root := tree.Root()
+
+	if empty := zkp.NewSanctionsTree().Root(); empty == root {
+		t.Error("Listing addresses did not change the root")
+	}
+
+	for _, addr := range listed {
+		if _, err := tree.Prove(addr); err != zkp.ErrAddressSanctioned {
+			t.Errorf("Expected ErrAddressSanctioned for %x, got %v", addr, err)
+		}
+	}
+
+	// Addresses sharing long prefixes with listed ones still get paths
+	for _, addr := range []types.Address{{0x80, 0x03}, {0x01, 0x01}, {19: 0xfe}, {}} {
+		path, err := tree.Prove(addr)
+		if err != nil {
+			t.Fatalf("Prove failed for %x: %v", addr, err)
+		}
+		if !zkp.VerifyNonMembership(root, addr, path) {
+			t.Errorf("Non-membership path for %x did not verify", addr)
+		}
+		if zkp.VerifyNonMembership(root, listed[1], path) {
+			t.Error("Path verified for a listed address")
+		}
+	}
+
+	// Listing an address invalidates old paths
+	path, _ := tree.Prove(types.Address{0x42})
+	tree.Update([]types.Address{{0x42}}, nil)
+	if zkp.VerifyNonMembership(tree.Root(), types.Address{0x42}, path) {
+		t.Error("Old path verified after the address was listed")
+	}
+	tree.Update(nil, []types.Address{{0x42}})
+	if tree.Root() != root {
+		t.Error("Removing the address did not restore the root")
+	}
+}
+
+// Test governance updates of the sanctions registry
+func TestSanctionsRegistry(t *testing.T) {
+	ctx := context.Background()
+	store := sanctions.NewInMemoryStore()
+	registry := sanctions.NewRegistry(store)
+
+	bad := types.Address{0xba, 0xd0}
+	proposal := &types.Proposal{
+		ProposalID: types.Hash{1},
+		Type:       types.ProposalSanctionsUpdate,
+		Status:     types.ProposalStatusActive,
+		Data: &types.SanctionsUpdateData{
+			Add:  []types.Address{bad},
+			Root: registry.PreviewUpdate([]types.Address{bad}, nil),
+		},
+	}
+
+	if err := registry.ApplyProposal(ctx, proposal); err != sanctions.ErrProposalNotPassed {
+		t.Errorf("Expected ErrProposalNotPassed, got %v", err)
+	}
+	proposal.Status = types.ProposalStatusPassed
+	if err := registry.ApplyProposal(ctx, proposal); err != nil {
+		t.Fatalf("ApplyProposal failed: %v", err)
+	}
+	if !registry.IsSanctioned(bad) {
+		t.Error("Address not listed after the proposal")
+	}
+	if _, _, err := registry.Prove(bad); err != zkp.ErrAddressSanctioned {
+		t.Errorf("Expected ErrAddressSanctioned, got %v", err)
+	}
+
+	root, path, err := registry.Prove(types.Address{0x01})
+	if err != nil {
+		t.Fatalf("Prove failed: %v", err)
+	}
+	if root != registry.SanctionsRoot() || !zkp.VerifyNonMembership(root, types.Address{0x01}, path) {
+		t.Error("Registry path does not verify against its root")
+	}
+
+	// The proposal must name the root its change produces
+	stale := &types.Proposal{
+		ProposalID: types.Hash{2},
+		Type:       types.ProposalSanctionsUpdate,
+		Status:     types.ProposalStatusPassed,
+		Data:       &types.SanctionsUpdateData{Remove: []types.Address{bad}, Root: root},
+	}
+	if err := registry.ApplyProposal(ctx, stale); err != sanctions.ErrRootMismatch {
+		t.Errorf("Expected ErrRootMismatch, got %v", err)
+	}
+
+	// A restarted registry reloads the list
+	reloaded := sanctions.NewRegistry(store)
+	if err := reloaded.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if reloaded.Root() != registry.Root() {
+		t.Error("Reloaded registry has a different root")
+	}
+}
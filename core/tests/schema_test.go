@@ -0,0 +1,35 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ccoin/core/internal/storage"
+)
+
+// Test that the embedded schema migrations are numbered without gaps and
+// can all be reverted
+func TestSchemaMigrations(t *testing.T) {
+	list, err := storage.SchemaMigrations()
+	if err != nil {
+		t.Fatalf("SchemaMigrations failed: %v", err)
+	}
+	if len(list) == 0 {
+		t.Fatal("No schema migrations embedded")
+	}
+
+	for i, m := range list {
+		if m.Version != i+1 {
+			t.Fatalf("Migration %s has version %d, want %d", m.Name, m.Version, i+1)
+		}
+		if !strings.Contains(m.Up, "CREATE") && !strings.Contains(m.Up, "ALTER") {
+			t.Errorf("Migration %03d_%s has no schema changes", m.Version, m.Name)
+		}
+		if m.Down == "" {
+			t.Errorf("Migration %03d_%s has no down migration", m.Version, m.Name)
+		}
+	}
+	if list[0].Name != "initial" {
+		t.Errorf("First migration is %s", list[0].Name)
+	}
+}
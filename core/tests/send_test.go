@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ccoin/core/internal/mempool"
+	"github.com/ccoin/core/internal/rpc"
+	"github.com/ccoin/core/internal/wallet"
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// submissionLog remembers earlier submissions by request ID
+type submissionLog map[string]*mempool.SubmissionResult
+
+func (l submissionLog) Submit(requestID string, tx *types.Transaction) (types.Hash, error) {
+	return types.Hash{}, errors.New("unexpected submit")
+}
+
+func (l submissionLog) LookupSubmission(requestID string) (*mempool.SubmissionResult, bool) {
+	res, ok := l[requestID]
+	return res, ok
+}
+
+func (l submissionLog) Get(txHash types.Hash) *types.Transaction { return nil }
+func (l submissionLog) Size() int                                { return 0 }
+
+// emptyWallet has no notes to spend and records the amounts asked of it
+type emptyWallet struct {
+	rpc.WalletBackend
+	targets []uint64
+}
+
+func (w *emptyWallet) SelectNotes(target uint64) ([]*wallet.Note, uint64, error) {
+	w.targets = append(w.targets, target)
+	return nil, 0, wallet.ErrInsufficientFunds
+}
+
+// fixedAnchor is a shielded state with no notes
+type fixedAnchor struct{}
+
+func (fixedAnchor) GetCurrentAnchor() types.Hash { return types.Hash{} }
+
+func (fixedAnchor) GetMerklePath(ctx context.Context, position uint64) (*zkp.MerklePath, error) {
+	return nil, errors.New("no notes")
+}
+
+// withdrawals returns n withdrawals w0... to distinct transparent addresses
+func withdrawals(n int) []rpc.Withdrawal {
+	out := make([]rpc.Withdrawal, n)
+	for i := range out {
+		var to types.Address
+		to[0] = byte(i + 1)
+		out[i] = rpc.Withdrawal{ID: fmt.Sprintf("w%d", i), To: fmt.Sprintf("0x%x", to[:]), Amount: uint64(100 * (i + 1))}
+	}
+	return out
+}
+
+// The transaction circuit SendBatch tests share, compiled once by
+// batchCircuits
+var (
+	batchOnce     sync.Once
+	batchCircuit  *zkp.CircuitManager
+	batchCompiled error
+)
+
+// batchCircuits returns a circuit manager with a transaction circuit of
+// three outputs, so two withdrawals fit in each transaction
+func batchCircuits(t *testing.T) *zkp.CircuitManager {
+	t.Helper()
+	batchOnce.Do(func() {
+		batchCircuit = zkp.NewCircuitManager()
+		batchCompiled = batchCircuit.CompileTransactionCircuit(1, 3)
+	})
+	if batchCompiled != nil {
+		t.Fatalf("CompileTransactionCircuit failed: %v", batchCompiled)
+	}
+	return batchCircuit
+}
+
+// Test that a batch is split into transactions of at most the circuit's
+// outputs less one for change, and that every withdrawal is reported
+// with its transaction and output index or with its chunk's error
+func TestSendBatch(t *testing.T) {
+	ctx := context.Background()
+
+	cm := batchCircuits(t)
+
+	// Of three chunks, the first was admitted and the second rejected by
+	// an earlier attempt; the third is new
+	accepted := types.Hash{0xaa}
+	log := submissionLog{
+		"payroll/0": {RequestID: "payroll/0", TxHash: accepted},
+		"payroll/1": {RequestID: "payroll/1", Err: mempool.ErrDoubleSpend},
+	}
+	w := &emptyWallet{}
+	s := rpc.NewServer(nil, &rpc.Backends{Mempool: log, Wallet: w, Shielded: fixedAnchor{}, Circuits: cm})
+
+	resp, err := s.SendBatch(ctx, &rpc.SendBatchRequest{BatchID: "payroll", Withdrawals: withdrawals(5), FeePerTx: 10})
+	if err != nil {
+		t.Fatalf("SendBatch failed: %v", err)
+	}
+	if len(resp.Transactions) != 3 {
+		t.Fatalf("Got %d transactions, want 3", len(resp.Transactions))
+	}
+
+	first := resp.Transactions[0]
+	if first.Status != rpc.SendStatusDuplicate || first.TxHash != accepted.String() || first.Error != "" {
+		t.Errorf("First transaction = %+v, want the earlier admission", first)
+	}
+	want := []rpc.BatchOutput{
+		{ID: "w0", TxHash: accepted.String(), OutputIndex: 0},
+		{ID: "w1", TxHash: accepted.String(), OutputIndex: 1},
+	}
+	if len(resp.Outputs) != len(want) {
+		t.Fatalf("Outputs = %+v, want %+v", resp.Outputs, want)
+	}
+	for i := range want {
+		if resp.Outputs[i] != want[i] {
+			t.Errorf("Output %d = %+v, want %+v", i, resp.Outputs[i], want[i])
+		}
+	}
+
+	// Failed withdrawals carry their chunk's error and keep their output
+	// index within the chunk
+	failed := map[string]string{}
+	for _, f := range resp.Failed {
+		failed[f.ID] = f.Error
+	}
+	if len(failed) != 3 || failed["w2"] != mempool.ErrDoubleSpend.Error() || failed["w3"] != mempool.ErrDoubleSpend.Error() ||
+		failed["w4"] != wallet.ErrInsufficientFunds.Error() {
+		t.Errorf("Failed = %+v", resp.Failed)
+	}
+	if outs := resp.Transactions[1].Outputs; len(outs) != 2 || outs[0].ID != "w2" || outs[1].OutputIndex != 1 {
+		t.Errorf("Second transaction outputs = %+v", outs)
+	}
+
+	// Only the new chunk selected notes, for its withdrawal and fee
+	if len(w.targets) != 1 || w.targets[0] != 510 {
+		t.Errorf("Notes selected for %v, want [510]", w.targets)
+	}
+}
+
+// Test that invalid batches are refused before any notes are selected
+func TestSendBatchInvalid(t *testing.T) {
+	ctx := context.Background()
+
+	cm := batchCircuits(t)
+	w := &emptyWallet{}
+	s := rpc.NewServer(nil, &rpc.Backends{Mempool: submissionLog{}, Wallet: w, Shielded: fixedAnchor{}, Circuits: cm})
+
+	duplicate := withdrawals(2)
+	duplicate[1].ID = duplicate[0].ID
+	zero := withdrawals(1)
+	zero[0].Amount = 0
+	badAddress := withdrawals(1)
+	badAddress[0].To = "0x1234"
+
+	for name, wds := range map[string][]rpc.Withdrawal{
+		"empty":       nil,
+		"too many":    withdrawals(rpc.MaxBatchWithdrawals + 1),
+		"duplicate":   duplicate,
+		"zero amount": zero,
+		"bad address": badAddress,
+	} {
+		if _, err := s.SendBatch(ctx, &rpc.SendBatchRequest{Withdrawals: wds}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: expected InvalidArgument, got %v", name, err)
+		}
+	}
+	if len(w.targets) != 0 {
+		t.Errorf("Notes selected for invalid batches: %v", w.targets)
+	}
+
+	s = rpc.NewServer(nil, &rpc.Backends{Mempool: submissionLog{}})
+	if _, err := s.SendBatch(ctx, &rpc.SendBatchRequest{Withdrawals: withdrawals(1)}); status.Code(err) != codes.Unimplemented {
+		t.Errorf("Expected Unimplemented without a wallet, got %v", err)
+	}
+}
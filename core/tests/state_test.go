@@ -0,0 +1,316 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ccoin/core/internal/dag"
+	"github.com/ccoin/core/internal/economics"
+	"github.com/ccoin/core/internal/state"
+	"github.com/ccoin/core/internal/zkp"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// stateRoot commits a chainBlock to the state root the manager computes
+// for it
+func stateRoot(m *state.Manager) func(*types.Block) error {
+	return func(b *types.Block) error {
+		root, err := m.NextStateRoot(context.Background(), b.Header.Parents[0], b)
+		b.Header.StateRoot = root
+		return err
+	}
+}
+
+// Test sparse Merkle tree membership and non-membership proofs
+func TestStateTree(t *testing.T) {
+	tree := state.NewTree()
+	empty := tree.Root()
+	for i := byte(1); i <= 20; i++ {
+		tree.Set(types.Hash{i, i}, types.Hash{i})
+	}
+	root := tree.Root()
+	if root == empty {
+		t.Fatal("Root unchanged after inserts")
+	}
+
+	for i := byte(1); i <= 20; i++ {
+		key := types.Hash{i, i}
+		proof := tree.Prove(key)
+		if !state.VerifyProof(root, key, types.Hash{i}, proof) {
+			t.Errorf("Proof for key %d failed", i)
+		}
+		if state.VerifyProof(root, key, types.Hash{i + 1}, proof) {
+			t.Errorf("Proof for key %d verified a wrong value", i)
+		}
+	}
+
+	// Unset keys prove a zero value
+	missing := types.Hash{0xaa}
+	if !state.VerifyProof(root, missing, types.Hash{}, tree.Prove(missing)) {
+		t.Error("Non-membership proof failed")
+	}
+
+	// Clearing every key restores the empty root
+	for i := byte(1); i <= 20; i++ {
+		tree.Set(types.Hash{i, i}, types.Hash{})
+	}
+	if tree.Root() != empty {
+		t.Error("Root not empty after clearing every key")
+	}
+}
+
+// Test that block state roots follow supply, treasury and miner accounts
+func TestStateManager(t *testing.T) {
+	ctx := context.Background()
+	d := dag.NewDAG(newMemDAGStore(), nil)
+	m := state.NewManager(d, 0)
+
+	genesis := testBlock(0xff, 0)
+	if err := d.AddBlock(ctx, genesis); err != nil {
+		t.Fatalf("Failed to add genesis: %v", err)
+	}
+
+	miner := types.Address{1}
+	b1 := chainBlock(t, 1, genesis.Header.Hash, miner, stateRoot(m), spendTx(types.Hash{1}))
+	if err := d.AddBlock(ctx, b1); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	b2 := chainBlock(t, 2, b1.Header.Hash, miner, stateRoot(m))
+	if err := d.AddBlock(ctx, b2); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+
+	// Supply, treasury and the miner account accumulate both rewards
+	reward := economics.CalculateMinerReward(1, 1.0)
+	minerShare, _, treasury, _, burn := economics.DefaultRewardDistribution().CalculateDistribution(reward)
+	s, err := m.State(ctx, b2.Header.Hash)
+	if err != nil {
+		t.Fatalf("State failed: %v", err)
+	}
+	if s.Supply != 2*(reward-burn) || s.Treasury != 2*treasury {
+		t.Errorf("Supply %d, treasury %d; expected %d, %d", s.Supply, s.Treasury, 2*(reward-burn), 2*treasury)
+	}
+	account := s.Miners[miner]
+	if account == nil || account.Blocks != 2 || account.Rewards != 2*minerShare {
+		t.Fatalf("Unexpected miner account %+v", account)
+	}
+
+	// Leaves are provable against the header's state root
+	value, proof, err := m.Prove(ctx, b2.Header.Hash, state.SupplyKey)
+	if err != nil {
+		t.Fatalf("Prove failed: %v", err)
+	}
+	if value != state.AmountValue(s.Supply) || !state.VerifyProof(b2.Header.StateRoot, state.SupplyKey, value, proof) {
+		t.Error("Supply proof failed")
+	}
+	value, proof, err = m.Prove(ctx, b2.Header.Hash, state.MinerKey(miner))
+	if err != nil || value != account.Hash() || !state.VerifyProof(b2.Header.StateRoot, state.MinerKey(miner), value, proof) {
+		t.Error("Miner account proof failed")
+	}
+
+	// A fresh manager rebuilds the same roots from stored blocks
+	rebuilt := state.NewManager(d, 1)
+	for _, block := range []*types.Block{b2, b1} {
+		s, err := rebuilt.State(ctx, block.Header.Hash)
+		if err != nil || s.Root() != block.Header.StateRoot {
+			t.Errorf("Rebuilt state root at height %d differs: %v", block.Header.Height, err)
+		}
+	}
+
+	// The validator checks the committed root
+	validator := dag.NewBlockValidator(d)
+	validator.SetStateRoots(m)
+	bad := chainBlock(t, 3, b2.Header.Hash, miner, stateRoot(m))
+	bad.Header.StateRoot = b2.Header.StateRoot
+	bad.Header.Hash = bad.Header.ComputeHash()
+	if err := validator.ValidateBlock(ctx, bad); err != dag.ErrInvalidStateRoot {
+		t.Errorf("Expected ErrInvalidStateRoot, got %v", err)
+	}
+}
+
+// receiptTx returns a transaction spending nullifiers and creating
+// outputs commitments
+func receiptTx(outputs int, disclosures []types.DisclosureType, nullifiers ...types.Hash) *types.Transaction {
+	tx := &types.Transaction{Version: 1, Nullifiers: nullifiers}
+	for i := 0; i < outputs; i++ {
+		tx.Commitments = append(tx.Commitments, types.Commitment{Value: types.Hash{byte(i + 1), nullifiers[0][0]}})
+	}
+	for _, d := range disclosures {
+		tx.Disclosures = append(tx.Disclosures, types.Disclosure{Type: d})
+	}
+	tx.TxHash = tx.ComputeHash()
+	return tx
+}
+
+// Test receipt derivation, receipts roots and receipt paths
+func TestStateReceipts(t *testing.T) {
+	ctx := context.Background()
+	d := dag.NewDAG(newMemDAGStore(), nil)
+	m := state.NewManager(d, 0)
+
+	genesis := testBlock(0xff, 0)
+	if err := d.AddBlock(ctx, genesis); err != nil {
+		t.Fatalf("Failed to add genesis: %v", err)
+	}
+
+	// The second transaction re-spends the first one's nullifier
+	miner := types.Address{1}
+	txs := []*types.Transaction{
+		receiptTx(2, []types.DisclosureType{types.DisclosureRange}, types.Hash{1}),
+		receiptTx(1, nil, types.Hash{1}, types.Hash{2}),
+	}
+	b1 := chainBlock(t, 1, genesis.Header.Hash, miner, stateRoot(m), txs...)
+	root, err := m.NextReceiptsRoot(ctx, genesis.Header.Hash, b1)
+	if err != nil || root.IsEmpty() {
+		t.Fatalf("NextReceiptsRoot failed: %v", err)
+	}
+	b1.Header.ReceiptsRoot = root
+	b1.Header.Hash = b1.Header.ComputeHash()
+	if err := d.AddBlock(ctx, b1); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	b2 := chainBlock(t, 2, b1.Header.Hash, miner, stateRoot(m), receiptTx(1, nil, types.Hash{3}))
+	if err := d.AddBlock(ctx, b2); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+
+	receipts, err := m.Receipts(ctx, b1.Header.Hash)
+	if err != nil || len(receipts) != 2 {
+		t.Fatalf("Receipts failed: %v", err)
+	}
+	first, second := receipts[0], receipts[1]
+	if first.Status != types.ReceiptAccepted || first.GasUsed != economics.EstimateGas(txs[0]) {
+		t.Errorf("Unexpected first receipt %+v", first)
+	}
+	if len(first.VerifiedDisclosures) != 1 || first.VerifiedDisclosures[0] != types.DisclosureRange {
+		t.Errorf("Expected the range disclosure, got %v", first.VerifiedDisclosures)
+	}
+	if len(first.CommitmentPositions) != 2 || first.CommitmentPositions[1] != 1 {
+		t.Errorf("Unexpected first positions %v", first.CommitmentPositions)
+	}
+	if second.Status != types.ReceiptConflict || len(second.CommitmentPositions) != 1 || second.CommitmentPositions[0] != 2 {
+		t.Errorf("Unexpected second receipt %+v", second)
+	}
+
+	// Positions continue along the chain
+	later, err := m.Receipts(ctx, b2.Header.Hash)
+	if err != nil || len(later) != 1 || later[0].CommitmentPositions[0] != 3 {
+		t.Errorf("Expected position 3 in the next block, got %+v %v", later, err)
+	}
+
+	// Receipts are provable against the header
+	for i, r := range receipts {
+		path := dag.ReceiptPath(receipts, i)
+		if !dag.VerifyReceiptPath(b1.Header.ReceiptsRoot, r, i, path) {
+			t.Errorf("Receipt %d path failed", i)
+		}
+	}
+	if dag.VerifyReceiptPath(b1.Header.ReceiptsRoot, second, 0, dag.ReceiptPath(receipts, 0)) {
+		t.Error("Path verified the wrong receipt")
+	}
+
+	// A fresh manager derives the same receipts from stored blocks
+	rebuilt, err := state.NewManager(d, 1).Receipts(ctx, b1.Header.Hash)
+	if err != nil || dag.ComputeReceiptsRoot(rebuilt) != root {
+		t.Errorf("Rebuilt receipts differ: %v", err)
+	}
+
+	// The validator checks the committed root; b2 left it empty
+	validator := dag.NewBlockValidator(d)
+	validator.SetReceiptsRoots(m)
+	if err := validator.ValidateBlock(ctx, b2); err != dag.ErrInvalidReceiptsRoot {
+		t.Errorf("Expected ErrInvalidReceiptsRoot, got %v", err)
+	}
+}
+
+// Test that a snapshot restores the state on a node without the blocks
+// before it
+func TestStateSnapshot(t *testing.T) {
+	ctx := context.Background()
+	d := dag.NewDAG(newMemDAGStore(), nil)
+	m := state.NewManager(d, 0)
+	nullifiers := m.NullifierRoots()
+
+	genesis := testBlock(0xff, 0)
+	if err := d.AddBlock(ctx, genesis); err != nil {
+		t.Fatalf("Failed to add genesis: %v", err)
+	}
+
+	// The state root covers the nullifier root, so commit to that first
+	commit := func(b *types.Block) error {
+		if err := nullifierRoot(nullifiers)(b); err != nil {
+			return err
+		}
+		return stateRoot(m)(b)
+	}
+	block := func(height uint64, parent types.Hash, tx *types.Transaction) *types.Block {
+		b := chainBlock(t, height, parent, types.Address{byte(height)}, commit, tx)
+		if err := d.AddBlock(ctx, b); err != nil {
+			t.Fatalf("Failed to add block: %v", err)
+		}
+		return b
+	}
+	tx := spendTx(types.Hash{1})
+	tx.Commitments = []types.Commitment{{Value: types.Hash{2}}, {Value: types.Hash{3}}}
+	tx.TxHash = tx.ComputeHash()
+	b1 := block(1, genesis.Header.Hash, tx)
+	b2 := block(2, b1.Header.Hash, spendTx(types.Hash{4}))
+
+	snap, err := m.Snapshot(ctx, b2.Header.Hash)
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	data, err := state.EncodeSnapshot(snap)
+	if err != nil {
+		t.Fatalf("EncodeSnapshot failed: %v", err)
+	}
+	decoded, err := state.DecodeSnapshot(data)
+	if err != nil {
+		t.Fatalf("DecodeSnapshot failed: %v", err)
+	}
+	if len(decoded.Nullifiers) != 2 || decoded.Commitments.Size != 2 || len(decoded.Miners) != 2 {
+		t.Fatalf("Unexpected snapshot contents: %+v", decoded)
+	}
+
+	// Commitments are provable against the commitment root light clients
+	// read from the state
+	s, err := m.State(ctx, b2.Header.Hash)
+	if err != nil {
+		t.Fatalf("State failed: %v", err)
+	}
+	path, err := m.CommitmentRoots().Path(ctx, b2.Header.Hash, 1)
+	if err != nil || !zkp.VerifyMerklePath(types.Hash{3}, path, s.CommitmentRoot) {
+		t.Errorf("Commitment path does not verify: %v", err)
+	}
+
+	// A node holding only genesis restores b2's state and computes the
+	// same roots for the next block
+	fresh := dag.NewDAG(newMemDAGStore(), nil)
+	if err := fresh.AddBlock(ctx, genesis); err != nil {
+		t.Fatalf("Failed to add genesis: %v", err)
+	}
+	restored := state.NewManager(fresh, 0)
+	if err := restored.Restore(b2.Header, decoded); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	next := chainBlock(t, 3, b2.Header.Hash, types.Address{3}, stateRoot(m), spendTx(types.Hash{5}))
+	root, err := restored.NextStateRoot(ctx, b2.Header.Hash, next)
+	if err != nil || root != next.Header.StateRoot {
+		t.Errorf("Restored state root differs: %v", err)
+	}
+
+	// Snapshots that do not match the header are rejected
+	tampered, _ := state.DecodeSnapshot(data)
+	tampered.Supply++
+	if err := state.NewManager(fresh, 0).Restore(b2.Header, tampered); err != state.ErrInvalidSnapshot {
+		t.Errorf("Expected ErrInvalidSnapshot for a wrong supply, got %v", err)
+	}
+	tampered, _ = state.DecodeSnapshot(data)
+	tampered.Nullifiers = tampered.Nullifiers[:1]
+	if err := state.NewManager(fresh, 0).Restore(b2.Header, tampered); err != state.ErrInvalidSnapshot {
+		t.Errorf("Expected ErrInvalidSnapshot for missing nullifiers, got %v", err)
+	}
+	if _, err := state.DecodeSnapshot(data[:len(data)-1]); err != state.ErrInvalidSnapshot {
+		t.Errorf("Expected ErrInvalidSnapshot for a truncated snapshot, got %v", err)
+	}
+}
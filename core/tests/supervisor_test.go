@@ -0,0 +1,131 @@
+// Package tests provides tests for subsystem supervision.
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ccoin/core/internal/supervisor"
+)
+
+// Test that a panicking subsystem is restarted and reported
+func TestSupervisorRestartsOnPanic(t *testing.T) {
+	dir := t.TempDir()
+	cfg := supervisor.DefaultConfig()
+	cfg.DataDir = dir
+	cfg.InitialBackoff = time.Millisecond
+	cfg.MaxBackoff = time.Millisecond
+	sup := supervisor.NewSupervisor(cfg)
+
+	var runs int32
+	err := sup.Go(context.Background(), "worker", func(ctx context.Context) error {
+		if atomic.AddInt32(&runs, 1) < 3 {
+			panic("boom")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+	sup.Wait()
+
+	if atomic.LoadInt32(&runs) != 3 {
+		t.Errorf("Expected 3 runs, got %d", runs)
+	}
+	if !sup.Degraded() {
+		t.Error("Supervisor should report degraded after a crash")
+	}
+
+	status := sup.Status()
+	if len(status) != 1 || status[0].Restarts != 2 || status[0].State != supervisor.StateStopped {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+
+	reports, _ := filepath.Glob(filepath.Join(dir, supervisor.CrashDirName, "*.json"))
+	if len(reports) != 2 {
+		t.Errorf("Expected 2 crash reports, got %d", len(reports))
+	}
+	if _, err := os.Stat(reports[0]); err != nil {
+		t.Errorf("Crash report not readable: %v", err)
+	}
+}
+
+// Test that a subsystem gives up after the restart limit
+func TestSupervisorGivesUp(t *testing.T) {
+	cfg := supervisor.DefaultConfig()
+	cfg.InitialBackoff = time.Millisecond
+	cfg.MaxRestarts = 2
+	sup := supervisor.NewSupervisor(cfg)
+
+	sup.Go(context.Background(), "flaky", func(ctx context.Context) error {
+		panic("always")
+	})
+	sup.Wait()
+
+	status := sup.Status()
+	if status[0].State != supervisor.StateFailed {
+		t.Errorf("Expected FAILED, got %s", status[0].State)
+	}
+}
+
+// waitDegraded polls until the supervisor reports degraded as want
+func waitDegraded(t *testing.T, sup *supervisor.Supervisor, want bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for sup.Degraded() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("Degraded still %v, want %v: %+v", !want, want, sup.Status())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// Test that a restarted subsystem is no longer degraded once it has run
+// for the reset window without crashing, while a failed one stays so
+func TestSupervisorRecovers(t *testing.T) {
+	cfg := supervisor.DefaultConfig()
+	cfg.InitialBackoff = time.Millisecond
+	cfg.MaxRestarts = 1
+	cfg.ResetWindow = 50 * time.Millisecond
+	sup := supervisor.NewSupervisor(cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var runs int32
+	sup.Go(ctx, "worker", func(ctx context.Context) error {
+		if atomic.AddInt32(&runs, 1) == 1 {
+			panic("once")
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	waitDegraded(t, sup, true)
+	waitDegraded(t, sup, false)
+
+	status := sup.Status()
+	if status[0].State != supervisor.StateRunning || status[0].Restarts != 1 || status[0].LastPanic != "once" {
+		t.Errorf("Recovered status = %+v", status[0])
+	}
+
+	// A panic reported from outside Go clears the same way
+	sup.ReportPanic(supervisor.Protect("worker", func() error { panic("handler") }))
+	waitDegraded(t, sup, true)
+	waitDegraded(t, sup, false)
+
+	sup.Go(ctx, "flaky", func(ctx context.Context) error {
+		panic("always")
+	})
+	waitDegraded(t, sup, true)
+	time.Sleep(3 * cfg.ResetWindow)
+	if !sup.Degraded() {
+		t.Error("Failed subsystem no longer reported degraded")
+	}
+
+	cancel()
+	sup.Wait()
+}
@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ccoin/core/internal/telemetry"
+)
+
+// Test that telemetry sends exactly the previewed report, and only when
+// an endpoint is configured
+func TestTelemetryReporter(t *testing.T) {
+	var received [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, body)
+	}))
+	defer server.Close()
+
+	sources := telemetry.Sources{
+		Height: func() uint64 { return 42 },
+		Peers:  func() int { return 12 },
+		Proofs: func() map[string]telemetry.ProofTiming {
+			return map[string]telemetry.ProofTiming{
+				"transaction": telemetry.NewProofTiming(4, 2*time.Second, 10, 50*time.Millisecond),
+			}
+		},
+	}
+
+	// Off by default: no endpoint, nothing sent
+	cfg := telemetry.DefaultConfig()
+	cfg.DataDir = t.TempDir()
+	reporter, err := telemetry.NewReporter(cfg, sources)
+	if err != nil {
+		t.Fatalf("NewReporter failed: %v", err)
+	}
+	if reporter.Enabled() {
+		t.Error("Telemetry enabled without an endpoint")
+	}
+	if err := reporter.Send(context.Background()); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.DataDir, telemetry.LogFileName)); !os.IsNotExist(err) {
+		t.Error("Disabled telemetry wrote a log")
+	}
+
+	cfg.Endpoint = server.URL
+	cfg.Version = "0.1.0"
+	reporter, err = telemetry.NewReporter(cfg, sources)
+	if err != nil {
+		t.Fatalf("NewReporter failed: %v", err)
+	}
+	preview, err := reporter.Preview()
+	if err != nil {
+		t.Fatalf("Preview failed: %v", err)
+	}
+	if err := reporter.Send(context.Background()); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if len(received) != 1 || string(received[0]) != string(preview) {
+		t.Fatalf("Sent %q, previewed %q", received, preview)
+	}
+
+	var report telemetry.Report
+	if err := json.Unmarshal(preview, &report); err != nil {
+		t.Fatalf("Invalid report: %v", err)
+	}
+	if report.Height != 42 || report.Peers != "8-31" || report.Version != "0.1.0" {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if timing := report.Proofs["transaction"]; timing.MeanProveMs != 500 || timing.MeanVerifyMs != 5 {
+		t.Errorf("Unexpected proof timing %+v", timing)
+	}
+
+	// Every sent report is logged locally
+	log, err := os.ReadFile(filepath.Join(cfg.DataDir, telemetry.LogFileName))
+	if err != nil || !strings.Contains(string(log), string(preview)) {
+		t.Errorf("Sent report not logged: %v", err)
+	}
+
+	// Remote endpoints must use https
+	cfg.Endpoint = "http://telemetry.example.org/report"
+	if _, err := telemetry.NewReporter(cfg, sources); err != telemetry.ErrInsecureEndpoint {
+		t.Errorf("Expected ErrInsecureEndpoint, got %v", err)
+	}
+}
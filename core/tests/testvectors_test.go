@@ -0,0 +1,77 @@
+// Package tests provides tests for the consensus test vectors.
+package tests
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ccoin/core/pkg/testvectors"
+)
+
+// Test that this implementation reproduces every published vector
+func TestVectorsConformance(t *testing.T) {
+	v, err := testvectors.Canonical()
+	if err != nil {
+		t.Fatalf("Failed to load vectors: %v", err)
+	}
+	if len(v.BlockHashes) == 0 || len(v.TxHashes) == 0 || len(v.Nullifiers) == 0 ||
+		len(v.MerkleRoots) == 0 || len(v.Rewards) == 0 || len(v.Difficulty) == 0 {
+		t.Fatal("Vectors missing a category")
+	}
+	for _, f := range testvectors.Verify(v, testvectors.Reference()) {
+		t.Error(f)
+	}
+}
+
+// Test that vectors.json is what go generate produces, so a consensus
+// change cannot land without regenerating it
+func TestVectorsUpToDate(t *testing.T) {
+	v, err := testvectors.Generate()
+	if err != nil {
+		t.Fatalf("Failed to generate vectors: %v", err)
+	}
+	generated, err := v.Marshal()
+	if err != nil {
+		t.Fatalf("Failed to encode vectors: %v", err)
+	}
+	canonical, err := testvectors.Canonical()
+	if err != nil {
+		t.Fatalf("Failed to load vectors: %v", err)
+	}
+	published, err := canonical.Marshal()
+	if err != nil {
+		t.Fatalf("Failed to encode vectors: %v", err)
+	}
+	if !bytes.Equal(generated, published) {
+		t.Error("vectors.json is stale; run go generate ./pkg/testvectors")
+	}
+}
+
+// Test that the runner reports a vector an implementation disagrees with
+func TestVectorsDetectMismatch(t *testing.T) {
+	v, err := testvectors.Canonical()
+	if err != nil {
+		t.Fatalf("Failed to load vectors: %v", err)
+	}
+	v.BlockHashes[0].Nonce++
+	v.Rewards[0].Burn++
+	v.Nullifiers[0].SpendingKey = "0xzz"
+
+	failures := testvectors.Verify(v, testvectors.Reference())
+	if len(failures) != 3 {
+		t.Fatalf("Expected 3 failures, got %d: %v", len(failures), failures)
+	}
+	kinds := map[string]bool{}
+	for _, f := range failures {
+		kinds[f.Kind] = true
+	}
+	for _, kind := range []string{"block hash", "rewards", "note commitment"} {
+		if !kinds[kind] {
+			t.Errorf("Missing %s failure in %v", kind, failures)
+		}
+	}
+
+	if _, err := testvectors.Load(bytes.NewReader([]byte(`{"version": 99}`))); err == nil {
+		t.Error("Unsupported version should fail to load")
+	}
+}
@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/ccoin/core/internal/mempool"
+	"github.com/ccoin/core/internal/p2p"
+	"github.com/ccoin/core/internal/threshold"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// TestSealedTransactionReveal tests sealing a transaction's operations to
+// the committee and opening them after inclusion
+func TestSealedTransactionReveal(t *testing.T) {
+	pk, shares, err := threshold.Deal(4, 3)
+	if err != nil {
+		t.Fatalf("Deal failed: %v", err)
+	}
+
+	tx := &types.Transaction{
+		Version:    1,
+		Nullifiers: []types.Hash{{1}},
+		Fee:        100,
+		PayoutChange: &types.PayoutChange{
+			MinerKey: make([]byte, 32),
+			Payout:   types.Address{0xc0, 0x1d},
+			Nonce:    1,
+		},
+	}
+	plaintext := p2p.AppendOperations(nil, tx)
+	tx.PayoutChange = nil
+	tx.Sealed, err = threshold.Seal(pk, 7, plaintext, tx.SealLabel())
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	tx.TxHash = tx.ComputeHash()
+
+	// The encrypted mempool only takes payloads sealed to the current key
+	cfg := mempool.DefaultConfig()
+	cfg.RequireSealed = true
+	pool := mempool.NewMempool(cfg)
+	if err := pool.Add(tx); err != mempool.ErrNoCommitteeKey {
+		t.Fatalf("expected ErrNoCommitteeKey, got %v", err)
+	}
+	pool.SetCommitteeKey(7, pk)
+	if err := pool.Add(tx); err != nil {
+		t.Fatalf("Add sealed tx failed: %v", err)
+	}
+
+	clear := &types.Transaction{
+		Version:      1,
+		Nullifiers:   []types.Hash{{2}},
+		Fee:          100,
+		PayoutChange: &types.PayoutChange{Nonce: 1},
+	}
+	clear.TxHash = clear.ComputeHash()
+	if err := pool.Add(clear); err != mempool.ErrUnsealedOperations {
+		t.Errorf("expected ErrUnsealedOperations, got %v", err)
+	}
+
+	// A payload moved to another transaction fails verification
+	moved := &types.Transaction{Version: 1, Nullifiers: []types.Hash{{3}}, Fee: 100, Sealed: tx.Sealed}
+	moved.TxHash = moved.ComputeHash()
+	if err := pool.Add(moved); err != mempool.ErrInvalidSealed {
+		t.Errorf("expected ErrInvalidSealed, got %v", err)
+	}
+
+	// Wire encoding keeps the payload sealed
+	encoded, _ := p2p.EncodeTransaction(tx)
+	decoded, err := p2p.DecodeTransaction(encoded)
+	if err != nil {
+		t.Fatalf("DecodeTransaction failed: %v", err)
+	}
+	if decoded.PayoutChange != nil || decoded.ComputeHash() != tx.TxHash {
+		t.Fatal("decoded sealed transaction differs")
+	}
+
+	// Once included, threshold shares open it
+	revealer := threshold.NewRevealer(p2p.DecodeOperations)
+	revealer.SetKey(7, pk, nil)
+	block := types.NewBlock(&types.BlockHeader{}, []*types.Transaction{decoded})
+	if _, err := revealer.Include(block); err != nil {
+		t.Fatalf("Include failed: %v", err)
+	}
+
+	var opened *types.Transaction
+	for i, ks := range shares[:3] {
+		share, err := ks.DecryptShare(decoded.Sealed)
+		if err != nil {
+			t.Fatalf("DecryptShare failed: %v", err)
+		}
+		opened, err = revealer.AddShare(&threshold.RevealShare{TxHash: tx.TxHash, Share: share})
+		if err != nil {
+			t.Fatalf("AddShare failed: %v", err)
+		}
+		if i < 2 && opened != nil {
+			t.Fatal("opened before threshold")
+		}
+	}
+	if opened == nil || opened.PayoutChange == nil {
+		t.Fatal("transaction not opened at threshold")
+	}
+	if opened.PayoutChange.Payout != (types.Address{0xc0, 0x1d}) {
+		t.Errorf("opened payout = %x", opened.PayoutChange.Payout)
+	}
+	if opened.ComputeHash() != tx.TxHash {
+		t.Error("opening changed the transaction hash")
+	}
+}
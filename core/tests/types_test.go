@@ -2,10 +2,12 @@
 package tests
 
 import (
+	"math"
 	"math/big"
 	"testing"
 	"time"
 
+	"github.com/ccoin/core/pkg/common"
 	"github.com/ccoin/core/pkg/types"
 )
 
@@ -37,6 +39,50 @@ func TestBlockHeader(t *testing.T) {
 	}
 }
 
+// Test that optional header fields are hashed under their own tags, so
+// that one root cannot pass for another
+func TestBlockHeaderOptionalFields(t *testing.T) {
+	root := types.Hash{0xab, 0xcd}
+	header := func() *types.BlockHeader {
+		return &types.BlockHeader{
+			Version:    1,
+			Parents:    []types.Hash{{1, 2, 3}},
+			Difficulty: big.NewInt(1000),
+			Nonce:      12345,
+			Timestamp:  1700000000,
+		}
+	}
+
+	seen := map[types.Hash]string{}
+	for name, set := range map[string]func(h *types.BlockHeader){
+		"no":        func(h *types.BlockHeader) {},
+		"committee": func(h *types.BlockHeader) { h.CommitteeRoot = root },
+		"nullifier": func(h *types.BlockHeader) { h.NullifierRoot = root },
+		"registry":  func(h *types.BlockHeader) { h.RegistryRoot = root },
+		"receipts":  func(h *types.BlockHeader) { h.ReceiptsRoot = root },
+	} {
+		h := header()
+		set(h)
+		hash := h.ComputeHash()
+		if other, ok := seen[hash]; ok {
+			t.Errorf("Headers with %s and %s roots hash alike", name, other)
+		}
+		seen[hash] = name
+	}
+
+	// A VRF proof cannot absorb the root that follows it
+	spent := header()
+	spent.MinerPublicKey = []byte{1, 2, 3}
+	spent.VRFProof = []byte{4, 5, 6}
+	spent.NullifierRoot = root
+	longer := header()
+	longer.MinerPublicKey = spent.MinerPublicKey
+	longer.VRFProof = append([]byte{4, 5, 6}, root[:]...)
+	if spent.ComputeHash() == longer.ComputeHash() {
+		t.Error("A longer VRF proof hashes like a nullifier root")
+	}
+}
+
 // Test Transaction creation
 func TestTransaction(t *testing.T) {
 	tx := types.Transaction{
@@ -125,3 +171,135 @@ func TestCommitment(t *testing.T) {
 		t.Error("Commitment value should not be empty")
 	}
 }
+
+// Canonical JSON conformance vectors: every node must produce exactly
+// these bytes
+func TestCanonicalJSONVectors(t *testing.T) {
+	vectors := []struct {
+		in, out string
+	}{
+		{`{"b":1,"a":2}`, `{"a":2,"b":1}`},
+		{` { "z" : [ 3 , 2 , 1 ] , "a" : { "y" : null , "x" : true } } `, `{"a":{"x":true,"y":null},"z":[3,2,1]}`},
+		{`{"\u20ac":1,"\r":2,"1":3,"\u00e9":4,"\ud83d\ude00":5,"\ufb33":6}`, "{\"\\r\":2,\"1\":3,\"é\":4,\"€\":1,\"😀\":5,\"דּ\":6}"},
+		{`18446744073709551615`, `18446744073709551615`},
+		{`-9223372036854775808`, `-9223372036854775808`},
+		{`-0`, `0`},
+		{`1.0`, `1`},
+		{`0.000001`, `0.000001`},
+		{`1e-7`, `1e-7`},
+		{`1E21`, `1e+21`},
+		{`123456789012345678901.5`, `1.2345678901234568e+20`},
+		{`4611686018427387904.0`, `4611686018427388000`},
+		{`0.1`, `0.1`},
+		{`"<&>\u0001\/"`, "\"<&>\\u0001/\""},
+	}
+	for _, v := range vectors {
+		got, err := common.Canonicalize([]byte(v.in))
+		if err != nil {
+			t.Errorf("Canonicalize(%s): %v", v.in, err)
+			continue
+		}
+		if string(got) != v.out {
+			t.Errorf("Canonicalize(%s) = %s, want %s", v.in, got, v.out)
+		}
+		if !common.IsCanonical(got) {
+			t.Errorf("Output %s should be canonical", got)
+		}
+	}
+
+	invalid := []string{
+		`{"a":1,"a":2}`,
+		`18446744073709551616`,
+		`-9223372036854775809`,
+		`1e400`,
+		`{"a":1} {}`,
+	}
+	for _, in := range invalid {
+		if _, err := common.Canonicalize([]byte(in)); err == nil {
+			t.Errorf("Canonicalize(%s) should fail", in)
+		}
+	}
+
+	if _, err := common.CanonicalJSON(math.NaN()); err != common.ErrInvalidNumber {
+		t.Errorf("Expected ErrInvalidNumber for NaN, got %v", err)
+	}
+	if _, err := common.CanonicalJSON("\xff"); err != common.ErrInvalidString {
+		t.Errorf("Expected ErrInvalidString, got %v", err)
+	}
+}
+
+// Test canonical encodings of signed governance and evaluation objects
+func TestCanonicalConsensusObjects(t *testing.T) {
+	vote := &types.Vote{
+		ProposalID:   types.Hash{0xab},
+		VoterAddress: types.Address{0x01, 0x02},
+		VotePower:    math.MaxUint64,
+		InFavor:      true,
+		VotedAt:      42,
+	}
+	got, err := vote.CanonicalJSON()
+	if err != nil {
+		t.Fatalf("Vote encoding failed: %v", err)
+	}
+	want := `{"in_favor":true,"proposal_id":"0xab00000000000000000000000000000000000000000000000000000000000000",` +
+		`"vote_power":18446744073709551615,"voted_at":42,"voter":"0x0102000000000000000000000000000000000000"}`
+	if string(got) != want {
+		t.Errorf("Vote encoding mismatch:\n got %s\nwant %s", got, want)
+	}
+
+	attestation := &types.AccuracyAttestation{
+		JobID:     types.Hash{0x01},
+		Evaluator: types.Address{0x02},
+		Accuracy:  0.9125,
+		Loss:      1e-7,
+		Signature: []byte{0xde, 0xad},
+	}
+	got, err = attestation.CanonicalJSON()
+	if err != nil {
+		t.Fatalf("Attestation encoding failed: %v", err)
+	}
+	want = `{"accuracy":0.9125,"evaluator":"0x0200000000000000000000000000000000000000",` +
+		`"job_id":"0x0100000000000000000000000000000000000000000000000000000000000000","loss":1e-7}`
+	if string(got) != want {
+		t.Errorf("Attestation encoding mismatch:\n got %s\nwant %s", got, want)
+	}
+
+	proposal := &types.Proposal{
+		ProposalID:      types.Hash{0x09},
+		Type:            types.ProposalTreasurySpend,
+		ProposerAddress: types.Address{0x03},
+		Title:           "Fund",
+		Description:     "Grant \"alpha\"",
+		Data: &types.TreasurySpendData{
+			Recipient: types.Address{0x04},
+			Amount:    5000,
+			Purpose:   "research",
+		},
+		QuorumRequired:    0.1,
+		ApprovalThreshold: 0.5,
+		VotingStartBlock:  100,
+		VotingEndBlock:    50500,
+		VotesFor:          7,
+	}
+	first, err := proposal.CanonicalJSON()
+	if err != nil {
+		t.Fatalf("Proposal encoding failed: %v", err)
+	}
+	if !common.IsCanonical(first) {
+		t.Errorf("Proposal encoding should be canonical: %s", first)
+	}
+
+	// Tallies and status are not part of the signed content
+	proposal.VotesFor = 1000
+	proposal.Status = types.ProposalStatusPassed
+	second, _ := proposal.CanonicalJSON()
+	if string(first) != string(second) {
+		t.Error("Proposal encoding should not depend on tallies or status")
+	}
+
+	proposal.Title = "Fund more"
+	third, _ := proposal.CanonicalJSON()
+	if string(first) == string(third) {
+		t.Error("Proposal encoding should change with its content")
+	}
+}
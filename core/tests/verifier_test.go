@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ccoin/core/internal/pouw"
+	"github.com/ccoin/core/internal/vrf"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// weightStore holds model weights in memory
+type weightStore map[types.Hash][]byte
+
+func (s weightStore) GetWeights(ctx context.Context, modelID types.Hash) ([]byte, error) {
+	if w, ok := s[modelID]; ok {
+		return w, nil
+	}
+	return nil, errors.New("no weights")
+}
+
+func (s weightStore) GetWeightsCID(ctx context.Context, modelID types.Hash) (string, error) {
+	return "", nil
+}
+
+func (s weightStore) SaveWeights(ctx context.Context, modelID types.Hash, weights []byte) error {
+	s[modelID] = weights
+	return nil
+}
+
+// taskTable looks up tasks by ID
+type taskTable map[types.Hash]*types.Task
+
+func (t taskTable) GetTask(taskID types.Hash) (*types.Task, error) {
+	if task, ok := t[taskID]; ok {
+		return task, nil
+	}
+	return nil, pouw.ErrNoTask
+}
+
+// slowExecutor re-executes nothing until its context is done
+type slowExecutor struct {
+	*pouw.ToyExecutor
+}
+
+func (x slowExecutor) ComputeSampleGradients(ctx context.Context, task *types.Task, samples []uint32) ([]int64, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// verifiedTask returns a task drawn with a fresh miner key, its model's
+// weights and an honest result for it computed on the toy executor, the
+// way the mining engine does
+func verifiedTask(t *testing.T) (*types.Task, weightStore, *types.GradientResult) {
+	t.Helper()
+	ctx := context.Background()
+
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	task := &types.Task{
+		TaskID:         types.Hash{0x01},
+		ModelID:        types.Hash{0x02},
+		DataHash:       types.Hash{0x03},
+		MinerPublicKey: pub,
+		VRFSeed:        types.Hash{0x04},
+	}
+	proof, err := vrf.Prove(priv, vrf.TaskInput(task.VRFSeed))
+	if err != nil {
+		t.Fatalf("Prove failed: %v", err)
+	}
+	task.VRFProof = proof
+
+	weights := []int16{3, -2, 5, 1, -4, 2, 0, 7}
+	store := weightStore{task.ModelID: pouw.EncodeWeights(weights)}
+
+	x := pouw.NewToyExecutor()
+	if err := x.LoadModel(ctx, task.ModelID, weights); err != nil {
+		t.Fatal(err)
+	}
+	output, err := vrf.ProofToHash(task.VRFProof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &types.GradientResult{
+		TaskID:       task.TaskID,
+		GradientHash: types.Hash{0x05},
+		OldLoss:      10,
+		NewLoss:      5,
+		QualityScore: 0.5,
+	}
+	result.VRFSeed = pouw.SubsetSeed(output, result.GradientHash)
+	batchSize, _ := x.BatchSize(ctx, task)
+	samples := pouw.SelectVerificationSubset(batchSize, pouw.VerificationSubsetFraction, result.VRFSeed)
+	subset, err := x.ComputeSampleGradients(ctx, task, samples)
+	if err != nil {
+		t.Fatalf("ComputeSampleGradients failed: %v", err)
+	}
+	result.VerificationSubset = pouw.EncodeGradient(subset)
+	return task, store, result
+}
+
+// Test that re-executing the verification subset accepts honest results
+// and rejects tampered gradients and subsets the miner chose
+func TestVerifySubset(t *testing.T) {
+	ctx := context.Background()
+
+	task, store, result := verifiedTask(t)
+	v := pouw.NewVerifier(store, nil, pouw.NewToyExecutor(), nil)
+	if err := v.VerifyGradientResult(ctx, result); !errors.Is(err, pouw.ErrNoTaskSource) {
+		t.Errorf("Expected ErrNoTaskSource, got %v", err)
+	}
+	v.SetTaskSource(taskTable{task.TaskID: task})
+	if err := v.VerifyGradientResult(ctx, result); err != nil {
+		t.Fatalf("Honest result rejected: %v", err)
+	}
+
+	// A gradient off by more than the tolerance
+	claimed, _ := pouw.DecodeGradient(result.VerificationSubset)
+	claimed[0] += 1 << 40
+	tampered := *result
+	tampered.VerificationSubset = pouw.EncodeGradient(claimed)
+	if err := v.VerifyGradientResult(ctx, &tampered); !errors.Is(err, pouw.ErrVerificationFailed) {
+		t.Errorf("Expected ErrVerificationFailed for a tampered subset, got %v", err)
+	}
+
+	// A seed not derived from the task's VRF output
+	reseeded := *result
+	reseeded.VRFSeed = types.Hash{0xff}
+	if err := v.VerifyGradientResult(ctx, &reseeded); !errors.Is(err, pouw.ErrInvalidSubset) {
+		t.Errorf("Expected ErrInvalidSubset for another seed, got %v", err)
+	}
+
+	// A VRF proof that does not verify under the miner's key
+	forged := *task
+	forged.VRFProof = append([]byte(nil), task.VRFProof...)
+	forged.VRFProof[len(forged.VRFProof)-1] ^= 0xff
+	v.SetTaskSource(taskTable{task.TaskID: &forged})
+	if err := v.VerifyGradientResult(ctx, result); !errors.Is(err, pouw.ErrInvalidSubset) {
+		t.Errorf("Expected ErrInvalidSubset for a bad VRF proof, got %v", err)
+	}
+}
+
+// Test that re-execution is bounded by the timeout and needs an executor
+func TestVerifySubsetExecutor(t *testing.T) {
+	ctx := context.Background()
+	task, store, result := verifiedTask(t)
+	tasks := taskTable{task.TaskID: task}
+
+	cfg := pouw.DefaultVerifierConfig()
+	cfg.Timeout = 20 * time.Millisecond
+	v := pouw.NewVerifier(store, nil, slowExecutor{pouw.NewToyExecutor()}, cfg)
+	v.SetTaskSource(tasks)
+	if err := v.VerifyGradientResult(ctx, result); !errors.Is(err, pouw.ErrVerificationTimeout) {
+		t.Errorf("Expected ErrVerificationTimeout, got %v", err)
+	}
+
+	v = pouw.NewVerifier(store, nil, nil, nil)
+	v.SetTaskSource(tasks)
+	if err := v.VerifyGradientResult(ctx, result); !errors.Is(err, pouw.ErrNoExecutor) {
+		t.Errorf("Expected ErrNoExecutor, got %v", err)
+	}
+}
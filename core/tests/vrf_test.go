@@ -0,0 +1,96 @@
+// Package tests provides tests for the ECVRF implementation.
+package tests
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"testing"
+
+	"github.com/ccoin/core/internal/vrf"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Test the ECVRF-EDWARDS25519-SHA512-TAI examples of RFC 9381
+func TestVRFVectors(t *testing.T) {
+	vectors := []struct {
+		sk, alpha, pi, beta string
+	}{
+		{
+			sk:    "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60",
+			alpha: "",
+			pi:    "8657106690b5526245a92b003bb079ccd1a92130477671f6fc01ad16f26f723f26f8a57ccaed74ee1b190bed1f479d9727d2d0f9b005a6e456a35d4fb0daab1268a1b0db10836d9826a528ca76567805",
+			beta:  "90cf1df3b703cce59e2a35b925d411164068269d7b2d29f3301c03dd757876ff66b71dda49d2de59d03450451af026798e8f81cd2e333de5cdf4f3e140fdd8ae",
+		},
+		{
+			sk:    "4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb",
+			alpha: "72",
+			pi:    "f3141cd382dc42909d19ec5110469e4feae18300e94f304590abdced48aed5933bf0864a62558b3ed7f2fea45c92a465301b3bbf5e3e54ddf2d935be3b67926da3ef39226bbc355bdc9850112c8f4b02",
+			beta:  "eb4440665d3891d668e7e0fcaf587f1b4bd7fbfe99d0eb2211ccec90496310eb5e33821bc613efb94db5e5b54c70a848a0bef4553a41befc57663b56373a5031",
+		},
+	}
+
+	for i, v := range vectors {
+		seed, _ := hex.DecodeString(v.sk)
+		sk := ed25519.NewKeyFromSeed(seed)
+		alpha, _ := hex.DecodeString(v.alpha)
+
+		proof, err := vrf.Prove(sk, alpha)
+		if err != nil {
+			t.Fatalf("Vector %d: Prove failed: %v", i, err)
+		}
+		if got := hex.EncodeToString(proof); got != v.pi {
+			t.Errorf("Vector %d: expected proof %s, got %s", i, v.pi, got)
+		}
+
+		beta, err := vrf.Verify(sk.Public().(ed25519.PublicKey), proof, alpha)
+		if err != nil {
+			t.Fatalf("Vector %d: Verify failed: %v", i, err)
+		}
+		if got := hex.EncodeToString(beta); got != v.beta {
+			t.Errorf("Vector %d: expected output %s, got %s", i, v.beta, got)
+		}
+		if hashed, _ := vrf.ProofToHash(proof); !bytes.Equal(hashed, beta) {
+			t.Errorf("Vector %d: ProofToHash disagrees with Verify", i)
+		}
+	}
+}
+
+// Test that proofs only verify for their key and input
+func TestVRFRejects(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	alpha := vrf.TaskInput(types.Hash{0xaa})
+
+	proof, err := vrf.Prove(priv, alpha)
+	if err != nil {
+		t.Fatalf("Prove failed: %v", err)
+	}
+	if _, err := vrf.Verify(pub, proof, alpha); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+
+	if _, err := vrf.Verify(pub, proof, vrf.TaskInput(types.Hash{0xab})); err != vrf.ErrInvalidProof {
+		t.Errorf("Expected ErrInvalidProof for another input, got %v", err)
+	}
+	if _, err := vrf.Verify(otherPub, proof, alpha); err != vrf.ErrInvalidProof {
+		t.Errorf("Expected ErrInvalidProof for another key, got %v", err)
+	}
+	for _, i := range []int{0, 40, vrf.ProofSize - 1} {
+		tampered := append([]byte(nil), proof...)
+		tampered[i] ^= 0x01
+		if _, err := vrf.Verify(pub, tampered, alpha); err != vrf.ErrInvalidProof {
+			t.Errorf("Expected ErrInvalidProof with byte %d flipped, got %v", i, err)
+		}
+	}
+	if _, err := vrf.Verify(pub, proof[:vrf.ProofSize-1], alpha); err != vrf.ErrInvalidProof {
+		t.Errorf("Expected ErrInvalidProof for a short proof, got %v", err)
+	}
+
+	// The identity point has small order
+	identity := make(ed25519.PublicKey, ed25519.PublicKeySize)
+	identity[0] = 0x01
+	if _, err := vrf.Verify(identity, proof, alpha); err != vrf.ErrInvalidPublicKey {
+		t.Errorf("Expected ErrInvalidPublicKey, got %v", err)
+	}
+}
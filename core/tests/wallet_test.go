@@ -0,0 +1,345 @@
+// Package tests provides tests for the wallet.
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ccoin/core/internal/wallet"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// Test BIP-39 reference vectors (passphrase "TREZOR")
+func TestMnemonicVectors(t *testing.T) {
+	testCases := []struct {
+		entropy  string
+		mnemonic string
+		seed     string
+	}{
+		{
+			"00000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			"legal winner thank year wave sausage worth useful legal winner thank yellow",
+			"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+		},
+	}
+
+	for _, tc := range testCases {
+		entropy, _ := hex.DecodeString(tc.entropy)
+		mnemonic, err := wallet.EntropyToMnemonic(entropy)
+		if err != nil {
+			t.Fatalf("EntropyToMnemonic failed: %v", err)
+		}
+		if mnemonic != tc.mnemonic {
+			t.Errorf("Expected mnemonic %q, got %q", tc.mnemonic, mnemonic)
+		}
+
+		decoded, err := wallet.MnemonicToEntropy(mnemonic)
+		if err != nil || hex.EncodeToString(decoded) != tc.entropy {
+			t.Errorf("Round trip failed: %x, %v", decoded, err)
+		}
+
+		seed := wallet.MnemonicToSeed(mnemonic, "TREZOR")
+		if hex.EncodeToString(seed) != tc.seed {
+			t.Errorf("Seed mismatch for %q", tc.mnemonic)
+		}
+	}
+
+	// Bad checksum
+	if wallet.ValidateMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon") {
+		t.Error("Mnemonic with bad checksum should be invalid")
+	}
+}
+
+// Test wordlist selection, mnemonic errors and the passphrase extension
+func TestMnemonicLanguagesAndPassphrase(t *testing.T) {
+	if _, err := wallet.LookupWordlist("klingon"); !errors.Is(err, wallet.ErrUnknownLanguage) {
+		t.Errorf("Expected ErrUnknownLanguage, got %v", err)
+	}
+	wl, err := wallet.LookupWordlist("English")
+	if err != nil || wl.Language != wallet.LanguageEnglish {
+		t.Fatalf("LookupWordlist failed: %v", err)
+	}
+
+	mnemonic, err := wl.NewMnemonic(wallet.Entropy128)
+	if err != nil {
+		t.Fatalf("NewMnemonic failed: %v", err)
+	}
+	if detected, err := wallet.DetectWordlist(mnemonic); err != nil || detected != wl {
+		t.Errorf("Detected %v, %v", detected, err)
+	}
+
+	words := strings.Fields(mnemonic)
+	if _, err := wallet.MnemonicToEntropy(strings.Join(words[:11], " ")); !errors.Is(err, wallet.ErrInvalidMnemonic) {
+		t.Errorf("Expected ErrInvalidMnemonic, got %v", err)
+	}
+	misspelled := append([]string{}, words...)
+	misspelled[2] = "abandn"
+	_, err = wallet.MnemonicToEntropy(strings.Join(misspelled, " "))
+	if !errors.Is(err, wallet.ErrUnknownWord) || !strings.Contains(err.Error(), "abandn") || !strings.Contains(err.Error(), "word 3") {
+		t.Errorf("Unknown word not reported: %v", err)
+	}
+	_, err = wallet.MnemonicToEntropy("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon")
+	if !errors.Is(err, wallet.ErrInvalidChecksum) {
+		t.Errorf("Expected ErrInvalidChecksum, got %v", err)
+	}
+
+	// The passphrase changes the seed; composed and decomposed accents
+	// give the same one
+	plain := wallet.MnemonicToSeed(mnemonic, "")
+	composed := wallet.MnemonicToSeed(mnemonic, "caf\u00e9")
+	decomposed := wallet.MnemonicToSeed(mnemonic, "cafe\u0301")
+	if bytes.Equal(plain, composed) || !bytes.Equal(composed, decomposed) {
+		t.Error("Passphrase not applied or not normalized")
+	}
+
+	cfg := wallet.DefaultConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.ScryptN = 1 << 10
+	cfg.Language = "klingon"
+	if _, _, err := wallet.New(cfg, "password", ""); !errors.Is(err, wallet.ErrUnknownLanguage) {
+		t.Errorf("Expected ErrUnknownLanguage, got %v", err)
+	}
+	if _, err := wallet.Restore(cfg, strings.Join(misspelled, " "), "password", ""); !errors.Is(err, wallet.ErrUnknownWord) {
+		t.Errorf("Expected ErrUnknownWord, got %v", err)
+	}
+}
+
+// Test keystore create, lock, unlock and sign
+func TestWalletKeystore(t *testing.T) {
+	cfg := wallet.DefaultConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.ScryptN = 1 << 10
+
+	w, mnemonic, err := wallet.New(cfg, "password", "")
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	if !wallet.ValidateMnemonic(mnemonic) {
+		t.Error("Generated mnemonic should be valid")
+	}
+	if !wallet.Exists(cfg.DataDir) {
+		t.Error("Keystore should exist on disk")
+	}
+
+	addr := w.Address()
+	msg := []byte("hello")
+	sig, err := w.Sign(addr, msg)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	pub, _ := w.PublicKey(addr)
+	if !wallet.VerifySignature(pub, msg, sig) {
+		t.Error("Signature should verify")
+	}
+
+	// Reopen from disk: locked until unlocked with the right password
+	w2, err := wallet.Open(cfg)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if !w2.IsLocked() {
+		t.Error("Opened wallet should be locked")
+	}
+	if _, err := w2.Sign(addr, msg); err != wallet.ErrLocked {
+		t.Errorf("Expected ErrLocked, got %v", err)
+	}
+	if err := w2.Unlock("wrong"); err != wallet.ErrWrongPassword {
+		t.Errorf("Expected ErrWrongPassword, got %v", err)
+	}
+	if err := w2.Unlock("password"); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if w2.Address() != addr {
+		t.Error("Reopened wallet should derive the same address")
+	}
+
+	// Restoring the mnemonic elsewhere derives the same keys
+	cfg2 := *cfg
+	cfg2.DataDir = t.TempDir()
+	w3, err := wallet.Restore(&cfg2, mnemonic, "other", "")
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if w3.Address() != addr || w3.ShieldedAddresses()[0] != w.ShieldedAddresses()[0] {
+		t.Error("Restored wallet should derive the same addresses")
+	}
+}
+
+// Test note selection, reservation and spending
+func TestWalletNoteSelection(t *testing.T) {
+	cfg := wallet.DefaultConfig()
+	cfg.DataDir = t.TempDir()
+
+	w, _, err := wallet.New(cfg, "password", "")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	addr := w.ShieldedAddress()
+	for i, value := range []uint64{30, 50, 20} {
+		note := &wallet.Note{Value: value, Address: addr, Position: uint64(i)}
+		note.Commitment[0] = byte(i + 1)
+		if err := w.AddNote(note); err != nil {
+			t.Fatalf("AddNote failed: %v", err)
+		}
+	}
+
+	if err := w.AddNote(&wallet.Note{Value: 1, Address: types.Address{0xff}}); err == nil {
+		t.Error("Notes for foreign addresses should be rejected")
+	}
+
+	// Largest notes first: 50 + 30 covers 60 with 20 change
+	notes, change, err := w.SelectNotes(60)
+	if err != nil {
+		t.Fatalf("SelectNotes failed: %v", err)
+	}
+	if len(notes) != 2 || notes[0].Value != 50 || notes[1].Value != 30 || change != 20 {
+		t.Errorf("Unexpected selection: %d notes, change %d", len(notes), change)
+	}
+
+	// Reserved notes are not offered again
+	if _, _, err := w.SelectNotes(30); err != wallet.ErrInsufficientFunds {
+		t.Errorf("Expected ErrInsufficientFunds, got %v", err)
+	}
+
+	bal, err := w.Balance(context.Background())
+	if err != nil {
+		t.Fatalf("Balance failed: %v", err)
+	}
+	if bal.Shielded != 20 || bal.Pending != 80 {
+		t.Errorf("Expected shielded 20 / pending 80, got %d / %d", bal.Shielded, bal.Pending)
+	}
+
+	w.Release(notes[1].Commitment)
+	if err := w.MarkSpent(notes[0].Commitment); err != nil {
+		t.Fatalf("MarkSpent failed: %v", err)
+	}
+	if got := len(w.Notes()); got != 2 {
+		t.Errorf("Expected 2 spendable notes, got %d", got)
+	}
+
+	// Spent state survives reopening
+	w2, err := wallet.Open(cfg)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if got := len(w2.Notes()); got != 2 {
+		t.Errorf("Expected 2 spendable notes after reopen, got %d", got)
+	}
+}
+
+// Test privacy options round and split change without losing value
+func TestPrivacyChangeOutputs(t *testing.T) {
+	opts := &wallet.PrivacyOptions{DecoyOutputs: true, RoundChange: 1000}
+
+	outputs, fee, err := opts.ChangeOutputs(12345, 10, 3)
+	if err != nil {
+		t.Fatalf("ChangeOutputs failed: %v", err)
+	}
+	if fee != 10+345 {
+		t.Errorf("fee = %d, want rounding remainder added", fee)
+	}
+	if len(outputs) != 3 {
+		t.Fatalf("got %d change outputs, want 3", len(outputs))
+	}
+	var total uint64
+	for _, v := range outputs {
+		if v%1000 != 0 {
+			t.Errorf("change output %d not rounded", v)
+		}
+		total += v
+	}
+	if total != 12000 {
+		t.Errorf("change total = %d, want 12000", total)
+	}
+
+	// Without options change is a single output
+	var none *wallet.PrivacyOptions
+	outputs, fee, err = none.ChangeOutputs(500, 10, 1)
+	if err != nil || len(outputs) != 1 || outputs[0] != 500 || fee != 10 {
+		t.Errorf("plain change = %v, %d, %v", outputs, fee, err)
+	}
+	if _, _, err := none.ChangeOutputs(500, 10, 0); err != wallet.ErrNoOutputSlots {
+		t.Errorf("expected ErrNoOutputSlots, got %v", err)
+	}
+}
+
+// Test structured memo encoding and filtering received notes by memo
+func TestStructuredMemos(t *testing.T) {
+	model := types.Hash{0xaa}
+	memos := []*wallet.Memo{
+		{Type: wallet.MemoText, Text: "thanks for lunch"},
+		{Type: wallet.MemoPaymentRef, Reference: "INV-1001"},
+		{Type: wallet.MemoLicense, Subject: model, Reference: "commercial/42"},
+		{Type: wallet.MemoGovernance, Subject: types.Hash{0xbb}, Text: "bounty for proposal review"},
+	}
+	encoded := make([][]byte, len(memos))
+	for i, m := range memos {
+		data, err := wallet.EncodeMemo(m)
+		if err != nil {
+			t.Fatalf("EncodeMemo(%s) failed: %v", m.Type, err)
+		}
+		got, err := wallet.ParseMemo(data)
+		if err != nil || *got != *m {
+			t.Errorf("Memo %s did not round trip: %+v, %v", m.Type, got, err)
+		}
+		encoded[i] = data
+	}
+
+	if _, err := wallet.EncodeMemo(&wallet.Memo{Type: wallet.MemoText, Text: "\x01spoofed"}); !errors.Is(err, wallet.ErrMalformedMemo) {
+		t.Errorf("Text starting with a type byte should be rejected, got %v", err)
+	}
+	if _, err := wallet.EncodeMemo(&wallet.Memo{Type: wallet.MemoPaymentRef}); !errors.Is(err, wallet.ErrMalformedMemo) {
+		t.Errorf("Empty payment reference should be rejected, got %v", err)
+	}
+	if _, err := wallet.ParseMemo([]byte{0x07, 'x'}); err != wallet.ErrUnknownMemoType {
+		t.Errorf("Expected ErrUnknownMemoType, got %v", err)
+	}
+
+	cfg := wallet.DefaultConfig()
+	cfg.DataDir = t.TempDir()
+	w, _, err := wallet.New(cfg, "password", "")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	addr := w.ShieldedAddress()
+	for i, memo := range append(encoded, []byte{0x02, 0x00}) {
+		note := &wallet.Note{Value: 10, Address: addr, Position: uint64(i), Height: uint64(i), Memo: memo}
+		note.Commitment[0] = byte(i + 1)
+		if err := w.AddNote(note); err != nil {
+			t.Fatalf("AddNote failed: %v", err)
+		}
+	}
+
+	if got := w.FindNotes(nil); len(got) != 5 {
+		t.Errorf("Expected all 5 notes without a filter, got %d", len(got))
+	}
+	if got := w.FindNotes(&wallet.NoteFilter{Reference: "INV-1001"}); len(got) != 1 || got[0].Position != 1 {
+		t.Errorf("Expected the invoice payment, got %d notes", len(got))
+	}
+	if got := w.FindNotes(&wallet.NoteFilter{Types: []wallet.MemoType{wallet.MemoLicense}, Subject: model}); len(got) != 1 || got[0].Position != 2 {
+		t.Errorf("Expected the license payment, got %d notes", len(got))
+	}
+	if got := w.FindNotes(&wallet.NoteFilter{FromHeight: 3, Types: []wallet.MemoType{wallet.MemoGovernance, wallet.MemoLicense}}); len(got) != 1 || got[0].Position != 3 {
+		t.Errorf("Expected the governance note, got %d notes", len(got))
+	}
+
+	if err := w.MarkSpent(w.FindNotes(&wallet.NoteFilter{Reference: "INV-1001"})[0].Commitment); err != nil {
+		t.Fatalf("MarkSpent failed: %v", err)
+	}
+	if got := w.FindNotes(&wallet.NoteFilter{Reference: "INV-1001"}); len(got) != 0 {
+		t.Error("Spent payments should be hidden by default")
+	}
+	if got := w.FindNotes(&wallet.NoteFilter{Reference: "INV-1001", IncludeSpent: true}); len(got) != 1 || !got[0].Spent {
+		t.Error("Spent payments should be listed with IncludeSpent")
+	}
+}
@@ -0,0 +1,209 @@
+package tests
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ccoin/core/internal/reputation"
+	"github.com/ccoin/core/internal/watchtower"
+	"github.com/ccoin/core/pkg/common"
+	"github.com/ccoin/core/pkg/types"
+)
+
+// watchDelegations serves fixed delegations to the watchtower
+type watchDelegations map[types.Address][]*reputation.Delegation
+
+func (d watchDelegations) DelegationsBy(delegator types.Address) []*reputation.Delegation {
+	return d[delegator]
+}
+
+// webhookSink records the alerts posted to it
+type webhookSink struct {
+	mu     sync.Mutex
+	alerts []watchtower.Alert
+	got    chan struct{}
+}
+
+func (s *webhookSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var alert watchtower.Alert
+	json.NewDecoder(r.Body).Decode(&alert)
+	s.mu.Lock()
+	s.alerts = append(s.alerts, alert)
+	s.mu.Unlock()
+	s.got <- struct{}{}
+}
+
+// watchRequest builds a signed watchtower request
+func watchRequest(key ed25519.PrivateKey, action string, kind watchtower.Kind, target, webhook string, nonce uint64) *watchtower.Request {
+	req := &watchtower.Request{Action: action, Kind: kind, Target: target, Webhook: webhook, Nonce: nonce}
+	req.Sign(key)
+	return req
+}
+
+// Test that only a miner's delegators may subscribe to it, that requests
+// are signed, fresh and rate limited, and that subscriptions persist
+func TestWatchtowerSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	_, key, _ := ed25519.GenerateKey(nil)
+	_, stranger, _ := ed25519.GenerateKey(nil)
+	delegator := types.AddressFromPublicKey(key.Public().(ed25519.PublicKey))
+	miner := types.Address{0x11}
+	minerHex := common.BytesToHex(miner[:])
+
+	cfg := watchtower.DefaultConfig()
+	cfg.Path = filepath.Join(t.TempDir(), "watchtower.json")
+	cfg.RequestLimit = 3
+	tower, err := watchtower.NewWatchtower(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tower.SetDelegations(watchDelegations{delegator: {{Delegator: delegator, Miner: miner, Bonded: 100}}})
+	server := httptest.NewServer(tower.Handler())
+	defer server.Close()
+
+	hook := "https://example.com/hook"
+	sub, err := watchtower.Submit(ctx, server.URL, watchRequest(key, watchtower.ActionSubscribe, watchtower.KindMiner, minerHex, hook, 1))
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if sub.Target != minerHex || sub.Subscriber != common.BytesToHex(delegator[:]) {
+		t.Errorf("Unexpected subscription %+v", sub)
+	}
+
+	// Replays, forgeries and strangers are turned away
+	if _, err := tower.Handle(ctx, watchRequest(key, watchtower.ActionSubscribe, watchtower.KindMiner, minerHex, hook, 1)); !errors.Is(err, watchtower.ErrStaleNonce) {
+		t.Errorf("Expected ErrStaleNonce, got %v", err)
+	}
+	forged := watchRequest(key, watchtower.ActionUnsubscribe, watchtower.KindMiner, minerHex, hook, 2)
+	forged.Webhook = "https://example.com/other"
+	if _, err := tower.Handle(ctx, forged); !errors.Is(err, watchtower.ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature, got %v", err)
+	}
+	if _, err := tower.Handle(ctx, watchRequest(stranger, watchtower.ActionSubscribe, watchtower.KindMiner, minerHex, hook, 1)); !errors.Is(err, watchtower.ErrNotAuthorized) {
+		t.Errorf("Expected ErrNotAuthorized, got %v", err)
+	}
+	if _, err := tower.Handle(ctx, watchRequest(key, watchtower.ActionSubscribe, watchtower.KindMiner, minerHex, "ftp://example.com", 2)); !errors.Is(err, watchtower.ErrInvalidRequest) {
+		t.Errorf("Expected ErrInvalidRequest, got %v", err)
+	}
+
+	// Anyone may watch a model, within the request limit
+	model := common.BytesToHex(make([]byte, types.HashSize))
+	if _, err := tower.Handle(ctx, watchRequest(key, watchtower.ActionSubscribe, watchtower.KindModel, model, hook, 2)); err != nil {
+		t.Fatalf("Model subscribe failed: %v", err)
+	}
+	if _, err := tower.Handle(ctx, watchRequest(key, watchtower.ActionSubscribe, watchtower.KindModel, model, hook, 3)); err != nil {
+		t.Fatalf("Repeated subscribe failed: %v", err)
+	}
+	_, err = watchtower.Submit(ctx, server.URL, watchRequest(key, watchtower.ActionUnsubscribe, watchtower.KindModel, model, hook, 4))
+	var limited *watchtower.RateLimitError
+	if !errors.As(err, &limited) || limited.RetryAfter <= 0 || limited.RetryAfter > time.Minute {
+		t.Errorf("Expected rate limit, got %v", err)
+	}
+	if n := len(tower.Subscriptions(delegator)); n != 2 {
+		t.Errorf("Expected 2 subscriptions, got %d", n)
+	}
+
+	// Subscriptions and nonces survive a restart
+	reopened, err := watchtower.NewWatchtower(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(reopened.Subscriptions(types.Address{})); n != 2 {
+		t.Errorf("Expected 2 subscriptions after reopening, got %d", n)
+	}
+	if _, err := reopened.Handle(ctx, watchRequest(key, watchtower.ActionUnsubscribe, watchtower.KindModel, model, hook, 3)); !errors.Is(err, watchtower.ErrStaleNonce) {
+		t.Errorf("Expected ErrStaleNonce after reopening, got %v", err)
+	}
+	if _, err := reopened.Handle(ctx, watchRequest(key, watchtower.ActionUnsubscribe, watchtower.KindModel, model, hook, 5)); err != nil {
+		t.Errorf("Unsubscribe failed: %v", err)
+	}
+	if _, err := reopened.Handle(ctx, watchRequest(key, watchtower.ActionUnsubscribe, watchtower.KindModel, model, hook, 6)); !errors.Is(err, watchtower.ErrNotSubscribed) {
+		t.Errorf("Expected ErrNotSubscribed, got %v", err)
+	}
+}
+
+// Test that slashing evidence and license revocations reach the
+// subscribed webhooks, within the alert limit
+func TestWatchtowerAlerts(t *testing.T) {
+	ctx := context.Background()
+	_, key, _ := ed25519.GenerateKey(nil)
+	miner := types.Address{0x22}
+	license := types.Hash{0x33}
+
+	sink := &webhookSink{got: make(chan struct{}, 16)}
+	hook := httptest.NewServer(sink)
+	defer hook.Close()
+
+	cfg := watchtower.DefaultConfig()
+	cfg.AlertLimit = 2
+	tower, err := watchtower.NewWatchtower(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tower.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer tower.Stop()
+
+	for i, req := range []*watchtower.Request{
+		watchRequest(key, watchtower.ActionSubscribe, watchtower.KindMiner, common.BytesToHex(miner[:]), hook.URL, 1),
+		watchRequest(key, watchtower.ActionSubscribe, watchtower.KindLicense, common.BytesToHex(license[:]), hook.URL, 2),
+	} {
+		if _, err := tower.Handle(ctx, req); err != nil {
+			t.Fatalf("Subscribe %d failed: %v", i, err)
+		}
+	}
+
+	wait := func(n int) {
+		for i := 0; i < n; i++ {
+			select {
+			case <-sink.got:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Timed out waiting for alert %d", i+1)
+			}
+		}
+	}
+
+	evidence := &reputation.SlashingEvidence{
+		Type:         reputation.SlashTypeDoubleSign,
+		MinerAddress: miner,
+		BlockHeight:  42,
+		Description:  "two blocks at height 42",
+	}
+	tower.NotifyEvidence(evidence)
+	wait(1)
+	evidence.Processed, evidence.SlashAmount = true, 500
+	tower.NotifyEvidence(evidence)
+	wait(1)
+
+	// The third alert in the window is dropped, and models are only
+	// alerted to their watchers
+	tower.NotifyEvidence(evidence)
+	tower.NotifyLicenseRevoked(license, "terms breached")
+	tower.NotifyModelDeprecated(types.Hash{0x44}, "unwatched")
+	wait(1)
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if len(sink.alerts) != 3 {
+		t.Fatalf("Expected 3 alerts, got %+v", sink.alerts)
+	}
+	first, slashed, revoked := sink.alerts[0], sink.alerts[1], sink.alerts[2]
+	if first.Event != watchtower.EventEvidence || first.Offense != "double sign" || first.Height != 42 || first.Kind != watchtower.KindMiner {
+		t.Errorf("Unexpected evidence alert %+v", first)
+	}
+	if slashed.Event != watchtower.EventSlashed || slashed.Amount != 500 {
+		t.Errorf("Unexpected slashing alert %+v", slashed)
+	}
+	if revoked.Event != watchtower.EventLicenseRevoked || revoked.Reason != "terms breached" || revoked.Suppressed != 0 {
+		t.Errorf("Unexpected revocation alert %+v", revoked)
+	}
+}
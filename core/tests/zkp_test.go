@@ -2,7 +2,14 @@
 package tests
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/ccoin/core/internal/zkp"
@@ -156,29 +163,830 @@ func TestMerkleTree(t *testing.T) {
 	}
 }
 
-// Test transaction builder
+// Test the tree resumes from its flushed frontier
+func TestCommitmentTreeFlush(t *testing.T) {
+	ctx := context.Background()
+	store := zkp.NewInMemoryTreeStore()
+	tree := zkp.NewCommitmentTree(store, 16)
+	if err := tree.Initialize(ctx); err != nil {
+		t.Fatalf("Failed to initialize tree: %v", err)
+	}
+
+	for i := byte(1); i <= 5; i++ {
+		if _, err := tree.AddCommitment(ctx, types.Hash{i}); err != nil {
+			t.Fatalf("Failed to add commitment: %v", err)
+		}
+	}
+	if err := tree.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	reloaded := zkp.NewCommitmentTree(store, 16)
+	if err := reloaded.Initialize(ctx); err != nil {
+		t.Fatalf("Failed to reload tree: %v", err)
+	}
+	if reloaded.GetRoot() != tree.GetRoot() || reloaded.GetSize() != 5 {
+		t.Fatal("Reloaded tree mismatch")
+	}
+
+	// Appends after reload extend the same tree
+	tree.AddCommitment(ctx, types.Hash{6})
+	reloaded.AddCommitment(ctx, types.Hash{6})
+	if reloaded.GetRoot() != tree.GetRoot() {
+		t.Error("Roots diverged after reload")
+	}
+
+	found, position, err := reloaded.ContainsCommitment(ctx, types.Hash{4})
+	if err != nil || !found || position != 3 {
+		t.Errorf("ContainsCommitment = %v, %d, %v", found, position, err)
+	}
+	path, err := reloaded.GetPath(ctx, 5)
+	if err != nil || !reloaded.VerifyPath(types.Hash{6}, path, reloaded.GetRoot()) {
+		t.Error("Path for unflushed leaf failed")
+	}
+}
+
+// Test transaction builder with each proving backend
 func TestTransactionBuilder(t *testing.T) {
+	t.Run("groth16", func(t *testing.T) {
+		testTransactionBuilder(t, zkp.Groth16Backend{})
+	})
+	t.Run("plonk", func(t *testing.T) {
+		testTransactionBuilder(t, zkp.NewUnsafePLONKBackend())
+	})
+}
+
+func testTransactionBuilder(t *testing.T, backend zkp.Backend) {
 	ctx := context.Background()
 	cm := zkp.NewCircuitManager()
+	cm.SetBackend(zkp.ProofTypeTransaction, backend)
+	if err := cm.CompileTransactionCircuit(1, 2); err != nil {
+		t.Fatalf("Failed to compile transaction circuit: %v", err)
+	}
 
-	// Note: Circuit compilation would be done separately in setup
-	// This test uses simulated proofs
+	tree := zkp.NewCommitmentTree(zkp.NewInMemoryTreeStore(), zkp.TreeDepth)
+	if err := tree.Initialize(ctx); err != nil {
+		t.Fatalf("Failed to initialize tree: %v", err)
+	}
+	pool := zkp.NewShieldedPool(tree, zkp.NewNullifierSet(zkp.NewInMemoryNullifierStore(), nil), cm, zkp.NewDisclosureManager(cm))
 
-	// Create test note
+	// Create a spendable note in the tree
+	spendingKey := make([]byte, 32)
+	spendingKey[0] = 1
+	owner := zkp.ShieldedAddress(spendingKey)
+	blinder := make([]byte, 32)
+	blinder[0] = 7
 	note := &zkp.Note{
 		Value:      1000,
-		Position:   0,
-		Commitment: types.Hash{1, 2, 3},
-		Blinder:    make([]byte, 32),
-		Spent:      false,
+		Address:    owner,
+		Blinder:    blinder,
+		Commitment: zkp.NoteCommitment(1000, owner, blinder),
+	}
+	position, err := tree.AddCommitment(ctx, note.Commitment)
+	if err != nil {
+		t.Fatalf("Failed to add commitment: %v", err)
+	}
+	note.Position = position
+	path, err := tree.GetPath(ctx, position)
+	if err != nil {
+		t.Fatalf("Failed to get path: %v", err)
 	}
 
-	// Create output
 	recipient := types.Address{10, 20, 30}
-	
-	// Build would create transaction
-	// (simplified test without full proof generation)
-	_ = zkp.NewTransactionBuilder(cm)
-	_ = note
-	_ = recipient
+
+	build := func(key []byte) *zkp.TransactionBuilder {
+		builder := zkp.NewTransactionBuilder(cm)
+		if err := builder.AddInput(note, key, path); err != nil {
+			t.Fatalf("AddInput failed: %v", err)
+		}
+		builder.AddOutput(900, recipient, nil)
+		builder.SetFee(100)
+		return builder
+	}
+
+	// A commitment the note does not open is rejected
+	forged := *note
+	forged.Value = 2000
+	if err := zkp.NewTransactionBuilder(cm).AddInput(&forged, spendingKey, path); err != zkp.ErrInvalidNote {
+		t.Errorf("expected ErrInvalidNote, got %v", err)
+	}
+
+	// A key the note is not addressed to cannot prove the spend, so an
+	// opening alone does not yield fresh nullifiers for the note
+	otherKey := make([]byte, 32)
+	otherKey[0] = 2
+	if _, err := build(otherKey).Build(ctx, pool.GetCurrentAnchor()); err == nil {
+		t.Error("Spent a note with another spending key")
+	}
+
+	tx, err := build(spendingKey).Build(ctx, pool.GetCurrentAnchor())
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if tx.Proof.ProofType != backend.System() {
+		t.Errorf("proof type = %d, want %d", tx.Proof.ProofType, backend.System())
+	}
+	if len(tx.Commitments) != 2 {
+		t.Errorf("expected outputs padded to 2, got %d", len(tx.Commitments))
+	}
+	if tx.Nullifiers[0] != zkp.DeriveNullifier(spendingKey, note.Commitment, position) {
+		t.Error("nullifier does not match the spent note")
+	}
+
+	// Tampering with a public input invalidates the proof
+	tampered := *tx
+	tampered.Fee = 50
+	if err := pool.ProcessTransaction(ctx, &tampered, 1); err != zkp.ErrProofFailed {
+		t.Errorf("expected ErrProofFailed for tampered fee, got %v", err)
+	}
+
+	// A nullifier listed twice is rejected, and neither rejection wrote
+	// anything
+	repeated := *tx
+	repeated.Nullifiers = []types.Hash{tx.Nullifiers[0], tx.Nullifiers[0]}
+	if err := pool.ProcessTransaction(ctx, &repeated, 1); err != zkp.ErrNullifierRepeat {
+		t.Errorf("expected ErrNullifierRepeat, got %v", err)
+	}
+	if pool.GetCurrentAnchor() != tx.Anchor {
+		t.Error("Rejected transactions changed the commitment tree")
+	}
+
+	if err := pool.ProcessTransaction(ctx, tx, 1); err != nil {
+		t.Fatalf("ProcessTransaction failed: %v", err)
+	}
+	if spent, err := pool.HasNullifiers(ctx, tx.Nullifiers); err != nil || !spent[0] {
+		t.Errorf("Nullifier not spent: %v, %v", spent, err)
+	}
+	if pool.GetCurrentAnchor() == tx.Anchor {
+		t.Error("Outputs not added to the commitment tree")
+	}
+}
+
+// TestCircuitKeyPersistence tests reusing saved keys instead of setup
+func TestCircuitKeyPersistence(t *testing.T) {
+	dir := t.TempDir()
+
+	cm := zkp.NewCircuitManager()
+	if err := cm.CompileRangeCircuit(); err != nil {
+		t.Fatalf("Failed to compile range circuit: %v", err)
+	}
+	if err := cm.SaveKeys(dir); err != nil {
+		t.Fatalf("SaveKeys failed: %v", err)
+	}
+
+	vkBytes := func(cm *zkp.CircuitManager) []byte {
+		vk, err := cm.GetVerifyingKey(zkp.ProofTypeRangeDisclosure)
+		if err != nil {
+			t.Fatalf("GetVerifyingKey failed: %v", err)
+		}
+		var buf bytes.Buffer
+		vk.WriteTo(&buf)
+		return buf.Bytes()
+	}
+
+	// A second node loading the keys ends up with the same verifying key
+	loaded := zkp.NewCircuitManager()
+	if err := loaded.LoadKeys(dir); err != nil {
+		t.Fatalf("LoadKeys failed: %v", err)
+	}
+	if err := loaded.CompileRangeCircuit(); err != nil {
+		t.Fatalf("Failed to compile range circuit: %v", err)
+	}
+	if !bytes.Equal(vkBytes(cm), vkBytes(loaded)) {
+		t.Error("loaded verifying key differs from saved key")
+	}
+
+	// With only the verifying key a node can verify but not prove
+	if err := os.Remove(filepath.Join(dir, "range.pk")); err != nil {
+		t.Fatal(err)
+	}
+	verifier := zkp.NewCircuitManager()
+	if err := verifier.LoadKeys(dir); err != nil {
+		t.Fatalf("LoadKeys failed: %v", err)
+	}
+	if err := verifier.CompileRangeCircuit(); err != nil {
+		t.Fatalf("Failed to compile range circuit: %v", err)
+	}
+	_, err := verifier.GenerateProof(context.Background(), zkp.ProofTypeRangeDisclosure, &zkp.RangeDisclosureCircuit{})
+	if err != zkp.ErrProvingKeyMissing {
+		t.Errorf("expected ErrProvingKeyMissing, got %v", err)
+	}
+}
+
+// Test the anchor window keeps only the most recent roots
+func TestAnchorWindow(t *testing.T) {
+	ctx := context.Background()
+	store := zkp.NewInMemoryAnchorStore()
+	window := zkp.NewAnchorWindow(store, 3)
+
+	for i := byte(1); i <= 5; i++ {
+		if err := window.Add(ctx, types.Hash{i}, uint64(i)); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if window.Contains(types.Hash{2}) {
+		t.Error("Evicted root still accepted")
+	}
+	if !window.Contains(types.Hash{3}) || !window.Contains(types.Hash{5}) {
+		t.Error("Recent root rejected")
+	}
+	if age, ok := window.Age(types.Hash{3}); !ok || age != 2 {
+		t.Errorf("Expected age 2, got %d", age)
+	}
+
+	// A restarted node reloads the same window
+	reloaded := zkp.NewAnchorWindow(store, 3)
+	if err := reloaded.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if reloaded.Len() != 3 || !reloaded.Contains(types.Hash{3}) || reloaded.Contains(types.Hash{2}) {
+		t.Error("Reloaded window mismatch")
+	}
+}
+
+func TestNoteEncryption(t *testing.T) {
+	viewingKey := bytes.Repeat([]byte{7}, zkp.NoteKeySize)
+	transmissionKey, err := zkp.TransmissionKey(viewingKey)
+	if err != nil {
+		t.Fatalf("TransmissionKey failed: %v", err)
+	}
+
+	note := &zkp.NotePlaintext{
+		Value:   1234,
+		Address: types.Address{1, 2, 3},
+		Blinder: bytes.Repeat([]byte{9}, 32),
+		Memo:    []byte("invoice 42"),
+	}
+	encrypted, err := zkp.EncryptNote(transmissionKey, note, nil)
+	if err != nil {
+		t.Fatalf("EncryptNote failed: %v", err)
+	}
+
+	opened, err := zkp.DecryptNote(viewingKey, encrypted)
+	if err != nil {
+		t.Fatalf("DecryptNote failed: %v", err)
+	}
+	if opened.Value != note.Value || opened.Address != note.Address ||
+		!bytes.Equal(opened.Blinder, note.Blinder) || !bytes.Equal(opened.Memo, note.Memo) {
+		t.Error("Decrypted note mismatch")
+	}
+
+	// Other viewing keys must not open it
+	other := bytes.Repeat([]byte{8}, zkp.NoteKeySize)
+	if _, err := zkp.DecryptNote(other, encrypted); err != zkp.ErrNoteNotDecrypted {
+		t.Errorf("Expected ErrNoteNotDecrypted, got %v", err)
+	}
+}
+
+func TestPaymentDisclosure(t *testing.T) {
+	recipientKey := bytes.Repeat([]byte{7}, zkp.NoteKeySize)
+	transmissionKey, _ := zkp.TransmissionKey(recipientKey)
+	ovk := bytes.Repeat([]byte{5}, zkp.NoteKeySize)
+
+	note := &zkp.NotePlaintext{
+		Value:   500,
+		Address: types.Address{4, 5, 6},
+		Blinder: bytes.Repeat([]byte{3}, 32),
+	}
+	encrypted, err := zkp.EncryptNote(transmissionKey, note, ovk)
+	if err != nil {
+		t.Fatalf("EncryptNote failed: %v", err)
+	}
+	tx := &types.Transaction{
+		Version: 1,
+		Commitments: []types.Commitment{{
+			Value:         zkp.NoteCommitment(note.Value, note.Address, note.Blinder),
+			EncryptedNote: encrypted,
+		}},
+	}
+	tx.TxHash = tx.ComputeHash()
+
+	// The sender recovers the note with its outgoing viewing key
+	recovered, esk, tk, err := zkp.RecoverNote(ovk, encrypted)
+	if err != nil {
+		t.Fatalf("RecoverNote failed: %v", err)
+	}
+	d := &zkp.PaymentDisclosure{
+		TxHash:          tx.TxHash,
+		Value:           recovered.Value,
+		Recipient:       recovered.Address,
+		TransmissionKey: tk,
+		Blinder:         recovered.Blinder,
+		EphemeralSecret: esk,
+	}
+
+	decoded, err := zkp.DecodePaymentDisclosure(d.Encode())
+	if err != nil {
+		t.Fatalf("DecodePaymentDisclosure failed: %v", err)
+	}
+	if err := zkp.VerifyPaymentDisclosure(tx, decoded); err != nil {
+		t.Fatalf("Valid disclosure rejected: %v", err)
+	}
+
+	decoded.Value++
+	if err := zkp.VerifyPaymentDisclosure(tx, decoded); err != zkp.ErrPaymentDisclosureMismatch {
+		t.Errorf("Expected ErrPaymentDisclosureMismatch, got %v", err)
+	}
+}
+
+// Test disclosure policy rules and governance updates
+func TestDisclosurePolicy(t *testing.T) {
+	ctx := context.Background()
+	store := zkp.NewInMemoryPolicyStore()
+	policy := zkp.NewPolicyEngine("testnet", store)
+	dm := zkp.NewDisclosureManager(nil)
+	dm.SetPolicy(policy)
+	authority := types.Hash{0xa1}
+	dm.RegisterAuthority(zkp.Authority{PublicKey: authority, Name: "Regulator", Domain: "EU"})
+
+	proposal := &types.Proposal{
+		ProposalID: types.Hash{1},
+		Type:       types.ProposalParameterAdjust,
+		Status:     types.ProposalStatusPassed,
+		Data: &types.DisclosurePolicyData{
+			Network: "testnet",
+			Rules: []types.DisclosureRuleData{
+				{Name: "high-value", MinValue: 1000, Require: uint32(zkp.FlagSanctionsRequired)},
+				{Name: "eu-inference", Recipients: []uint8{uint8(zkp.RecipientInference)},
+					Jurisdictions: []string{"EU"}, Require: uint32(zkp.FlagTemporalRequired)},
+			},
+		},
+	}
+	other := *proposal
+	other.Data = &types.DisclosurePolicyData{Network: "mainnet"}
+	if err := policy.ApplyProposal(ctx, &other); err != zkp.ErrPolicyNetwork {
+		t.Fatalf("Expected ErrPolicyNetwork, got %v", err)
+	}
+	if err := policy.ApplyProposal(ctx, proposal); err != nil {
+		t.Fatalf("ApplyProposal failed: %v", err)
+	}
+
+	// Without a range disclosure the value may be high
+	tx := &types.Transaction{}
+	if got := policy.Required(dm.TxAttributes(tx)); got != zkp.FlagSanctionsRequired {
+		t.Errorf("Expected sanctions required, got %b", got)
+	}
+	if err := dm.CheckDisclosures(ctx, tx); err == nil {
+		t.Error("Transaction without required disclosures was accepted")
+	}
+
+	// A range below the threshold lifts the rule; claiming a flag
+	// without attaching the disclosure does not satisfy it
+	low := (&zkp.RangeDisclosure{MinValue: 0, MaxValue: 999}).Disclosure()
+	tx.Disclosures = []types.Disclosure{low}
+	if got := policy.Required(dm.TxAttributes(tx)); got != zkp.FlagNone {
+		t.Errorf("Expected nothing required, got %b", got)
+	}
+	tx.Disclosures = nil
+	tx.DisclosureFlags = uint32(zkp.FlagSanctionsRequired)
+	if err := dm.ValidateDisclosures(ctx, tx, zkp.FlagSanctionsRequired); err == nil {
+		t.Error("Claimed flag without a disclosure satisfied the policy")
+	}
+
+	// Jurisdiction rules match the authority behind identity disclosures
+	id := (&zkp.IdentityDisclosure{AuthorityPubKey: authority}).Disclosure()
+	inference := &types.Transaction{
+		Inference:   &types.InferenceOp{},
+		Disclosures: []types.Disclosure{low, id},
+	}
+	if got := policy.Required(dm.TxAttributes(inference)); got != zkp.FlagTemporalRequired {
+		t.Errorf("Expected temporal required, got %b", got)
+	}
+	inference.Inference = nil
+	if got := policy.Required(dm.TxAttributes(inference)); got != zkp.FlagNone {
+		t.Errorf("Expected nothing required for a transfer, got %b", got)
+	}
+
+	// Rules persist across restarts
+	restarted := zkp.NewPolicyEngine("testnet", store)
+	if err := restarted.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if len(restarted.Rules()) != 2 {
+		t.Fatalf("Expected 2 rules, got %d", len(restarted.Rules()))
+	}
+}
+
+// Test policy rule text and explanations
+func TestPolicyRuleText(t *testing.T) {
+	ctx := context.Background()
+	src := `
+# Every spend proves its notes are off the sanctions list
+rule baseline: any => require sanctions
+rule high-value: value >= 10000 and recipient in (transfer, payout) => require range, identity
+rule eu: jurisdiction in (EU, UK) => require temporal
+`
+	rules, err := zkp.ParsePolicyRules(src)
+	if err != nil {
+		t.Fatalf("ParsePolicyRules failed: %v", err)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("Expected 3 rules, got %d", len(rules))
+	}
+	if r := rules[1]; r.MinValue != 10000 || len(r.Recipients) != 2 || r.Require != zkp.FlagRangeRequired|zkp.FlagIdentityRequired {
+		t.Errorf("high-value parsed as %+v", r)
+	}
+
+	// Text and proposal data both round-trip
+	again, err := zkp.ParsePolicyRules(zkp.FormatPolicyRules(rules))
+	if err != nil || !reflect.DeepEqual(again, rules) {
+		t.Errorf("Formatted rules did not parse back: %v", err)
+	}
+	data := zkp.PolicyProposalData("testnet", rules)
+	if fromData, err := zkp.PolicyRulesFromProposal(data); err != nil || !reflect.DeepEqual(fromData, rules) {
+		t.Errorf("Proposal data did not round-trip: %v", err)
+	}
+
+	for _, bad := range []string{
+		"rule a: value > 5 => require range",
+		"rule a: any => require everything",
+		"rule a: => require range",
+		"rule a: recipient in (miner) => require range",
+		"rule a: any => require range\nrule a: any => require identity",
+	} {
+		if _, err := zkp.ParsePolicyRules(bad); !errors.Is(err, zkp.ErrPolicySyntax) {
+			t.Errorf("Expected ErrPolicySyntax for %q, got %v", bad, err)
+		}
+	}
+
+	policy := zkp.NewPolicyEngine("testnet", zkp.NewInMemoryPolicyStore())
+	proposal := &types.Proposal{
+		ProposalID: types.Hash{2},
+		Type:       types.ProposalParameterAdjust,
+		Status:     types.ProposalStatusPassed,
+		Data:       data,
+	}
+	if err := policy.ApplyProposal(ctx, proposal); err != nil {
+		t.Fatalf("ApplyProposal failed: %v", err)
+	}
+
+	// Without a range disclosure the high-value rule applies
+	e := policy.Explain(&zkp.TxAttributes{MaxValue: math.MaxUint64, Recipient: zkp.RecipientTransfer})
+	if e.Required != zkp.FlagSanctionsRequired|zkp.FlagRangeRequired|zkp.FlagIdentityRequired {
+		t.Errorf("Unexpected required flags %v", e.Required.Names())
+	}
+	if !e.Rules[1].Matched || e.Rules[2].Matched {
+		t.Error("Unexpected rule outcomes for an unbounded transfer")
+	}
+
+	// A range below the threshold leaves only the baseline
+	e = policy.Explain(&zkp.TxAttributes{MaxValue: 500, Recipient: zkp.RecipientTransfer, Jurisdictions: []string{"UK"}})
+	if e.Required != zkp.FlagSanctionsRequired|zkp.FlagTemporalRequired {
+		t.Errorf("Unexpected required flags %v", e.Required.Names())
+	}
+	if e.Rules[1].Matched || e.Rules[1].Reason == "" {
+		t.Error("Expected the high-value rule to be skipped with a reason")
+	}
+}
+
+// spendIndex is a SpendSource over a fixed set of confirmed transactions
+type spendIndex map[types.Hash]struct {
+	tx     *types.Transaction
+	height uint64
+}
+
+func (s spendIndex) SpendingTransaction(ctx context.Context, nullifier types.Hash) (*types.Transaction, uint64, error) {
+	spend, ok := s[nullifier]
+	if !ok {
+		return nil, 0, zkp.ErrNullifierInvalid
+	}
+	return spend.tx, spend.height, nil
+}
+
+// Test aggregate disclosures over a set of transactions
+func TestAggregateDisclosure(t *testing.T) {
+	ctx := context.Background()
+	cm := zkp.NewCircuitManager()
+	if err := cm.CompileAggregateCircuit(); err != nil {
+		t.Fatalf("Failed to compile aggregate circuit: %v", err)
+	}
+	dm := zkp.NewDisclosureManager(cm)
+
+	spendingKey := []byte("aggregate-spending-key")
+	owner, payee := types.Address{0x0a}, types.Address{0x0b}
+	notes := []*zkp.Note{
+		{Value: 100, Address: owner, Blinder: []byte{1}, Position: 0},
+		{Value: 50, Address: owner, Blinder: []byte{2}, Position: 1},
+		{Value: 30, Address: owner, Blinder: []byte{3}, Position: 2},
+	}
+	change := &zkp.Note{Value: 30, Address: owner, Blinder: []byte{4}}
+	nullifier := func(n *zkp.Note) types.Hash {
+		return zkp.DeriveNullifierFromNote(spendingKey, n.Value, n.Blinder, n.Address, n.Position)
+	}
+	commitment := func(n *zkp.Note) types.Commitment {
+		return types.Commitment{Value: zkp.NoteCommitment(n.Value, n.Address, n.Blinder)}
+	}
+
+	// Two transactions in epoch 1 paying out 120 and 30, the first
+	// returning 30 in change
+	tx1 := &types.Transaction{
+		TxHash:      types.Hash{0x01},
+		Nullifiers:  []types.Hash{nullifier(notes[0]), nullifier(notes[1])},
+		Commitments: []types.Commitment{commitment(&zkp.Note{Value: 120, Address: payee, Blinder: []byte{5}}), commitment(change)},
+	}
+	tx2 := &types.Transaction{
+		TxHash:      types.Hash{0x02},
+		Nullifiers:  []types.Hash{nullifier(notes[2])},
+		Commitments: []types.Commitment{commitment(&zkp.Note{Value: 30, Address: payee, Blinder: []byte{6}})},
+	}
+	index := spendIndex{}
+	for _, s := range []struct {
+		tx     *types.Transaction
+		height uint64
+	}{{tx1, 1200}, {tx2, 1800}} {
+		for _, n := range s.tx.Nullifiers {
+			index[n] = s
+		}
+	}
+
+	from, to := zkp.EpochHeights(1)
+	d, err := dm.CreateAggregateDisclosure(ctx, spendingKey, notes, []*zkp.Note{change}, 0, 200, from, to)
+	if err != nil {
+		t.Fatalf("CreateAggregateDisclosure failed: %v", err)
+	}
+	if err := dm.VerifyAggregateDisclosure(ctx, d); err != zkp.ErrSpendSourceUnavailable {
+		t.Errorf("Expected ErrSpendSourceUnavailable, got %v", err)
+	}
+	dm.SetSpendSource(index)
+	if err := dm.VerifyAggregateDisclosure(ctx, d); err != nil {
+		t.Fatalf("VerifyAggregateDisclosure failed: %v", err)
+	}
+
+	// The outflow of 150 is outside [0, 140]
+	if _, err := dm.CreateAggregateDisclosure(ctx, spendingKey, notes, []*zkp.Note{change}, 0, 140, from, to); err != zkp.ErrDisclosureRequirementFailed {
+		t.Errorf("Expected ErrDisclosureRequirementFailed, got %v", err)
+	}
+
+	// Tightened bounds no longer match the proof
+	forged := *d
+	forged.MaxOutflow = 140
+	if err := dm.VerifyAggregateDisclosure(ctx, &forged); err != zkp.ErrDisclosureProofInvalid {
+		t.Errorf("Expected ErrDisclosureProofInvalid, got %v", err)
+	}
+
+	// Leaving out an input of a covered transaction understates outflow
+	partial, err := dm.CreateAggregateDisclosure(ctx, spendingKey, []*zkp.Note{notes[0], notes[2]}, []*zkp.Note{change}, 0, 200, from, to)
+	if err != nil {
+		t.Fatalf("CreateAggregateDisclosure failed: %v", err)
+	}
+	if err := dm.VerifyAggregateDisclosure(ctx, partial); err != zkp.ErrAggregateIncomplete {
+		t.Errorf("Expected ErrAggregateIncomplete, got %v", err)
+	}
+
+	// Transactions must fall in the disclosed window
+	forged = *d
+	forged.FromHeight, forged.ToHeight = zkp.EpochHeights(2)
+	if err := dm.VerifyAggregateDisclosure(ctx, &forged); err != zkp.ErrAggregateOutsideWindow {
+		t.Errorf("Expected ErrAggregateOutsideWindow, got %v", err)
+	}
+
+	// Attached to a transaction it is verified with the other disclosures
+	attached := d.Disclosure()
+	parsed, err := zkp.ParseAggregateDisclosure(&attached)
+	if err != nil {
+		t.Fatalf("ParseAggregateDisclosure failed: %v", err)
+	}
+	if len(parsed.Nullifiers) != 3 || len(parsed.Change) != 1 || parsed.MaxOutflow != 200 || parsed.FromHeight != from {
+		t.Error("Attached disclosure did not round-trip")
+	}
+	tx := &types.Transaction{Disclosures: []types.Disclosure{attached}}
+	if err := dm.ValidateDisclosures(ctx, tx, zkp.FlagNone); err != nil {
+		t.Errorf("ValidateDisclosures failed: %v", err)
+	}
+}
+
+// Test proving a PoUW training step and verifying it from a block header
+func TestGradientProof(t *testing.T) {
+	ctx := context.Background()
+	if q := zkp.GradientQuality(400, 300); q != 250000 {
+		t.Errorf("Expected quality 250000, got %d", q)
+	}
+	if q := zkp.GradientQuality(300, 400); q != 0 {
+		t.Errorf("Expected no quality without improvement, got %d", q)
+	}
+
+	cm := zkp.NewCircuitManager()
+	if err := cm.CompileGradientCircuit(); err != nil {
+		t.Fatalf("Failed to compile gradient circuit: %v", err)
+	}
+
+	// Labels fit weights of 3 on the first feature; the step moves
+	// the weights from 1 to 2
+	work := &zkp.GradientWork{Task: types.Hash{0x7a}}
+	for i := range work.Features {
+		work.Features[i][0] = int16(i + 1)
+		work.Labels[i] = int16(3 * (i + 1))
+	}
+	work.Weights[0], work.Updated[0] = 1, 2
+
+	p, err := cm.ProveGradient(ctx, work)
+	if err != nil {
+		t.Fatalf("ProveGradient failed: %v", err)
+	}
+	if p.LossBefore != work.Loss(work.Weights) || p.LossAfter >= p.LossBefore {
+		t.Errorf("Unexpected losses %d -> %d", p.LossBefore, p.LossAfter)
+	}
+
+	header := &types.BlockHeader{
+		TaskID:       work.Task,
+		PoUWResult:   work.Result(),
+		PoUWProof:    p.Bytes(),
+		QualityScore: zkp.QualityScore(p.Quality),
+	}
+	if err := cm.VerifyPoUW(ctx, header); err != nil {
+		t.Fatalf("VerifyPoUW failed: %v", err)
+	}
+
+	// The quality score must be the proven one
+	inflated := *header
+	inflated.QualityScore = 0.99
+	if err := cm.VerifyPoUW(ctx, &inflated); err != zkp.ErrGradientMismatch {
+		t.Errorf("Expected ErrGradientMismatch, got %v", err)
+	}
+
+	// So must the losses and the work committed to
+	forged := *p
+	forged.LossAfter--
+	if err := cm.VerifyGradientProof(ctx, &forged); err != zkp.ErrProofFailed {
+		t.Errorf("Expected ErrProofFailed for forged losses, got %v", err)
+	}
+	moved := *header
+	moved.TaskID = types.Hash{0x7b}
+	if err := cm.VerifyPoUW(ctx, &moved); err != zkp.ErrProofFailed {
+		t.Errorf("Expected ErrProofFailed for another task, got %v", err)
+	}
+
+	// Steps that do not lower the loss cannot be proven
+	work.Updated[0] = 1
+	if _, err := cm.ProveGradient(ctx, work); err != zkp.ErrGradientNoImprovement {
+		t.Errorf("Expected ErrGradientNoImprovement, got %v", err)
+	}
+}
+
+// blockAnchor is a stored header and the commitment root after it
+type blockAnchor struct {
+	header *types.BlockHeader
+	root   types.Hash
+}
+
+// blockAnchors is a BlockAnchors over fixed headers and commitment roots
+type blockAnchors map[types.Hash]blockAnchor
+
+func (a blockAnchors) BlockAnchor(ctx context.Context, hash types.Hash) (*types.BlockHeader, types.Hash, error) {
+	anchor, ok := a[hash]
+	if !ok {
+		return nil, types.Hash{}, errors.New("unknown block")
+	}
+	return anchor.header, anchor.root, nil
+}
+
+// Test temporal disclosures anchored to block timestamps
+func TestTemporalDisclosure(t *testing.T) {
+	ctx := context.Background()
+	cm := zkp.NewCircuitManager()
+	if err := cm.CompileTemporalCircuit(); err != nil {
+		t.Fatalf("Failed to compile temporal circuit: %v", err)
+	}
+	dm := zkp.NewDisclosureManager(cm)
+
+	spendingKey := []byte("temporal-spending-key")
+	note := &zkp.Note{Value: 75, Address: types.Address{0x0c}, Blinder: []byte{7}}
+	tree := zkp.NewCommitmentTree(zkp.NewInMemoryTreeStore(), zkp.TreeDepth)
+	tree.AddCommitment(ctx, zkp.NoteCommitment(0, types.Address{}, []byte{1}))
+	position, _ := tree.AddCommitment(ctx, zkp.NoteCommitment(note.Value, note.Address, note.Blinder))
+	path, err := tree.GetPath(ctx, position)
+	if err != nil {
+		t.Fatalf("GetPath failed: %v", err)
+	}
+
+	// The note exists after block 1 at t=1000; block 2 is a day later
+	created, anchor := types.Hash{0x01}, types.Hash{0x02}
+	anchors := blockAnchors{}
+	anchors[created] = blockAnchor{&types.BlockHeader{Hash: created, Timestamp: 1000}, tree.GetRoot()}
+	anchors[anchor] = blockAnchor{&types.BlockHeader{Hash: anchor, Timestamp: 1000 + 86400}, types.Hash{0xff}}
+
+	if _, err := dm.CreateTemporalDisclosure(ctx, spendingKey, note, position, path, created, anchor, 3600); err != zkp.ErrTemporalAnchorsUnavailable {
+		t.Errorf("Expected ErrTemporalAnchorsUnavailable, got %v", err)
+	}
+	dm.SetTemporalAnchors(anchors)
+
+	// A duration longer than the blocks are apart cannot be proven
+	if _, err := dm.CreateTemporalDisclosure(ctx, spendingKey, note, position, path, created, anchor, 2*86400); err != zkp.ErrTemporalTooRecent {
+		t.Errorf("Expected ErrTemporalTooRecent, got %v", err)
+	}
+
+	d, err := dm.CreateTemporalDisclosure(ctx, spendingKey, note, position, path, created, anchor, 86400)
+	if err != nil {
+		t.Fatalf("CreateTemporalDisclosure failed: %v", err)
+	}
+	if err := dm.VerifyTemporalDisclosure(ctx, d); err != nil {
+		t.Fatalf("VerifyTemporalDisclosure failed: %v", err)
+	}
+
+	// Claiming a longer duration fails against the header timestamps
+	forged := *d
+	forged.MinDuration = 86401
+	if err := dm.VerifyTemporalDisclosure(ctx, &forged); err != zkp.ErrTemporalTooRecent {
+		t.Errorf("Expected ErrTemporalTooRecent, got %v", err)
+	}
+
+	// Swapping in an earlier block does not match the proven root
+	anchors[types.Hash{0x03}] = blockAnchor{&types.BlockHeader{Hash: types.Hash{0x03}, Timestamp: 10}, types.Hash{0xee}}
+	forged = *d
+	forged.CreationBlock = types.Hash{0x03}
+	if err := dm.VerifyTemporalDisclosure(ctx, &forged); err != zkp.ErrTemporalRootMismatch {
+		t.Errorf("Expected ErrTemporalRootMismatch, got %v", err)
+	}
+
+	// The proof is bound to the note's nullifier
+	forged = *d
+	forged.Nullifier = types.Hash{0x09}
+	if err := dm.VerifyTemporalDisclosure(ctx, &forged); err != zkp.ErrDisclosureProofInvalid {
+		t.Errorf("Expected ErrDisclosureProofInvalid, got %v", err)
+	}
+
+	// Attached to a transaction it must be bound to a spent nullifier
+	attached := d.Disclosure()
+	parsed, err := zkp.ParseTemporalDisclosure(&attached)
+	if err != nil || !reflect.DeepEqual(parsed, d) {
+		t.Fatalf("Disclosure did not round-trip: %+v, %v", parsed, err)
+	}
+	tx := &types.Transaction{Nullifiers: []types.Hash{d.Nullifier}, Disclosures: []types.Disclosure{attached}, DisclosureFlags: uint32(zkp.FlagTemporalRequired)}
+	if err := dm.ValidateDisclosures(ctx, tx, zkp.FlagTemporalRequired); err != nil {
+		t.Errorf("ValidateDisclosures failed: %v", err)
+	}
+	tx.Nullifiers = []types.Hash{{0x09}}
+	if err := dm.ValidateDisclosures(ctx, tx, zkp.FlagTemporalRequired); err != zkp.ErrDisclosureProofInvalid {
+		t.Errorf("Expected ErrDisclosureProofInvalid, got %v", err)
+	}
+}
+
+// Test nullifier filters: no false negatives, and nullifiers ruled out
+// skip the store
+func TestNullifierFilters(t *testing.T) {
+	ctx := context.Background()
+	nullifier := func(i int) types.Hash {
+		return types.Hash(sha256.Sum256([]byte{byte(i >> 8), byte(i)}))
+	}
+
+	// An epoch's filter holds its nullifiers
+	f := zkp.NewNullifierFilter(0, types.Hash{0x01}, 500, 1e-6)
+	for i := 0; i < 500; i++ {
+		f.Add(nullifier(i))
+	}
+	for i := 0; i < 500; i++ {
+		if !f.MayContain(nullifier(i)) {
+			t.Fatalf("Filter missing nullifier %d", i)
+		}
+	}
+	if err := f.Validate(); err != nil {
+		t.Fatalf("Filter invalid: %v", err)
+	}
+
+	// Filters rule nothing out until they cover the chain
+	filters := zkp.NewNullifierFilters(nil, nil)
+	if !filters.MaybeSpent(nullifier(1000)) {
+		t.Error("Filters not covering the chain should rule nothing out")
+	}
+	if err := filters.AddFilter(f); err != nil {
+		t.Fatalf("AddFilter failed: %v", err)
+	}
+	filters.Track(types.EpochLength)
+	filters.Add(types.EpochLength, []types.Hash{nullifier(600)})
+	if !filters.Ready() {
+		t.Fatal("Filters should be ready")
+	}
+	for _, i := range []int{0, 499, 600} {
+		if !filters.MaybeSpent(nullifier(i)) {
+			t.Errorf("Spent nullifier %d ruled out", i)
+		}
+	}
+	if filters.MaybeSpent(nullifier(1000)) {
+		t.Error("Unspent nullifier not ruled out")
+	}
+
+	// Filters for other epochs are checked against the sealed ones
+	tampered := *f
+	tampered.Bits = append([]byte(nil), f.Bits...)
+	tampered.Bits[0] ^= 0xff
+	if err := filters.Verify(&tampered); err != zkp.ErrFilterMismatch {
+		t.Errorf("Expected ErrFilterMismatch, got %v", err)
+	}
+	tampered.Epoch = 5
+	if err := filters.AddFilter(&tampered); err != zkp.ErrFilterUnknown {
+		t.Errorf("Expected ErrFilterUnknown, got %v", err)
+	}
+
+	// PreCheck only looks up nullifiers the filters cannot rule out
+	store := zkp.NewInMemoryNullifierStore()
+	store.AddNullifier(ctx, nullifier(600), types.Hash{}, types.EpochLength)
+	ns := zkp.NewNullifierSet(store, nil)
+	ns.SetFilters(filters)
+	spent, err := ns.PreCheck(ctx, []types.Hash{nullifier(600), nullifier(1000), nullifier(0)})
+	if err != nil {
+		t.Fatalf("PreCheck failed: %v", err)
+	}
+	if !spent[0] || spent[1] || spent[2] {
+		t.Errorf("Unexpected PreCheck results %v", spent)
+	}
 }